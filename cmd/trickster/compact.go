@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/bbolt"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/config"
+)
+
+// cmdCompactBBolt is the subcommand that compacts the database files of the bbolt caches
+const cmdCompactBBolt = "compact-bbolt"
+
+// compactBBolt parses the compact-bbolt subcommand arguments, and compacts the database file
+// of each bbolt cache in the configuration. Trickster must not be running against the files
+func compactBBolt(arguments []string) error {
+
+	flagSet := flag.NewFlagSet(cmdCompactBBolt, flag.ContinueOnError)
+	configPath := flagSet.String("config", "", "Path to the Trickster configuration file")
+	cacheName := flagSet.String("cache", "", "Name of the bbolt cache to compact (default all)")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return errors.New("missing -config")
+	}
+
+	conf, _, err := config.Load(applicationName, applicationVersion,
+		[]string{"-config", *configPath})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(conf.Caches))
+	for k, cc := range conf.Caches {
+		if cc.CacheTypeID != types.CacheTypeBbolt || (*cacheName != "" && k != *cacheName) {
+			continue
+		}
+		names = append(names, k)
+	}
+	if len(names) == 0 {
+		if *cacheName != "" {
+			return fmt.Errorf("no bbolt cache named %s", *cacheName)
+		}
+		return errors.New("no bbolt caches are configured")
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		cc := conf.Caches[k]
+		before, after, err := bbolt.CompactFile(cc.BBolt)
+		if err != nil {
+			return fmt.Errorf("cache %s: %s", k, err.Error())
+		}
+		fmt.Printf("compacted cache %s (%s) from %d to %d bytes\n",
+			k, cc.BBolt.Filename, before, after)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCompactConf = `
+[origins]
+  [origins.default]
+    origin_type = 'reverseproxycache'
+    origin_url = 'http://1'
+    cache_name = 'bolt'
+
+[caches]
+  [caches.bolt]
+    cache_type = 'bbolt'
+    [caches.bolt.bbolt]
+      filename = '%s'
+      bucket = 'trickster'
+      bucket_per_origin = true
+`
+
+func TestCompactBBolt(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cp := filepath.Join(dir, "trickster.conf")
+	conf := fmt.Sprintf(testCompactConf, filepath.Join(dir, "trickster.db"))
+	if err = ioutil.WriteFile(cp, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = compactBBolt([]string{"-config", cp}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "trickster.db")); err != nil {
+		t.Error(err)
+	}
+
+	err = compactBBolt([]string{"-config", cp, "-cache", "missing"})
+	if err == nil {
+		t.Error("expected error for unknown cache")
+	}
+
+	err = compactBBolt([]string{})
+	if err == nil {
+		t.Error("expected error for missing config")
+	}
+
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,18 +32,34 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	ro "github.com/tricksterproxy/trickster/pkg/config/reload/options"
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
+	mem "github.com/tricksterproxy/trickster/pkg/memory"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/handlers"
 	th "github.com/tricksterproxy/trickster/pkg/proxy/handlers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/fips"
 	"github.com/tricksterproxy/trickster/pkg/routing"
 	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/tracing"
 	tr "github.com/tricksterproxy/trickster/pkg/tracing/registration"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+	"github.com/tricksterproxy/trickster/pkg/util/middleware"
+	"github.com/tricksterproxy/trickster/pkg/util/systemd"
 )
 
 var cfgLock = &sync.Mutex{}
 
+// memoryGovernor applies the running config's memory options
+var memoryGovernor *mem.Governor
+
+// configGeneration is the number of configurations applied since startup
+var configGeneration int
+
 func runConfig(oldConf *config.Config, wg *sync.WaitGroup, log *log.Logger,
 	oldCaches map[string]cache.Cache, args []string, errorsFatal bool) error {
 
@@ -102,6 +119,12 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, log *log.Logg
 
 	log = applyLoggingConfig(conf, oldConf, log)
 
+	if oldConf != nil {
+		notifySystemd(systemd.Reloading, log)
+	}
+	// systemd is told the service is ready once the config is applied, or a reload has failed
+	defer notifySystemd(systemd.Ready, log)
+
 	for _, w := range conf.LoaderWarnings {
 		log.Warn(w, tl.Pairs{})
 	}
@@ -114,30 +137,206 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, log *log.Logg
 		return err
 	}
 
-	// every config (re)load is a new router
-	router := mux.NewRouter()
-	router.HandleFunc(conf.Main.PingHandlerPath, th.PingHandleFunc(conf)).Methods(http.MethodGet)
+	applyMemoryConfig(conf, log)
+
+	// the configured feature flags replace any that were updated at runtime
+	featureflags.Apply(conf.FeatureFlags)
 
 	var caches = applyCachingConfig(conf, oldConf, log, oldCaches)
 	rh := handlers.ReloadHandleFunc(runConfig, conf, wg, log, caches, args)
+	uh := handlers.RulesUpdateHandleFunc(func(nc *config.Config) error {
+		return applyRuntimeRules(nc, caches, tracers, log)
+	}, conf, log)
+	fh := handlers.FeatureFlagsHandleFunc(conf, log)
+	ch := handlers.CompactHandleFunc(caches)
 
-	_, err = routing.RegisterProxyRoutes(conf, router, caches, tracers, log, false)
+	frontend, clients, err := newFrontendRouter(conf, caches, tracers, log)
 	if err != nil {
 		handleStartupIssue("route registration failed", tl.Pairs{"detail": err.Error()},
 			log, errorsFatal)
 		return err
 	}
 
-	applyListenerConfigs(conf, oldConf, router, http.HandlerFunc(rh), log, tracers)
+	mr := newMetricsRouter(conf, clients, caches, http.HandlerFunc(rh), http.HandlerFunc(uh),
+		http.HandlerFunc(fh), http.HandlerFunc(ch), tracers, log)
+
+	applyListenerConfigs(conf, oldConf, frontend, mr, http.HandlerFunc(rh), http.HandlerFunc(uh),
+		http.HandlerFunc(fh), http.HandlerFunc(ch), log, tracers, caches)
 
 	metrics.LastReloadSuccessfulTimestamp.Set(float64(time.Now().Unix()))
 	metrics.LastReloadSuccessful.Set(1)
+	configGeneration++
+	metrics.ConfigGeneration.Set(float64(configGeneration))
+	if oldConf != nil {
+		metrics.ConfigReloads.WithLabelValues("success").Inc()
+		logConfigDiff(conf.Diff(oldConf), log)
+	}
 	// add Config Reload HUP Signal Monitor
 	if oldConf != nil && oldConf.Resources != nil {
 		oldConf.Resources.QuitChan <- true // this signals the old hup monitor goroutine to exit
+		if oldConf.Resources.BackgroundQuitChan != nil {
+			close(oldConf.Resources.BackgroundQuitChan) // this stops the old origins' background tasks
+		}
 	}
 	startHupMonitor(conf, wg, log, caches, args)
+	startSystemdWatchdog(log)
+
+	// start any background tasks (e.g., derived queries) for the new origin clients
+	for _, c := range clients {
+		if bc, ok := c.(origins.BackgroundClient); ok {
+			bc.StartBackgroundTasks(conf.Resources.BackgroundQuitChan, log)
+		}
+	}
+
+	// start discovering the cluster peers, if they are not configured statically
+	if conf.Resources.Cluster != nil {
+		go conf.Resources.Cluster.Run(conf.Resources.BackgroundQuitChan)
+	}
+
+	// start the retention window trimmers of the new origins' cached timeseries
+	for k, o := range conf.Origins {
+		if o.RetentionTrimmer == nil {
+			continue
+		}
+		if tc, ok := clients[k].(origins.TimeseriesClient); ok {
+			go o.RetentionTrimmer.Run(engines.RetentionTrimFunc(o, caches[o.CacheName], tc, log),
+				conf.Resources.BackgroundQuitChan)
+		}
+	}
+
+	// start the replicators of the new origins' cached timeseries
+	for k, o := range conf.Origins {
+		if o.Replicator == nil {
+			continue
+		}
+		if tc, ok := clients[k].(origins.TimeseriesClient); ok {
+			go o.Replicator.Run(engines.ReplicationReadFunc(o, caches[o.CacheName], tc, log),
+				conf.Resources.BackgroundQuitChan)
+		}
+	}
+
+	// start the listeners that ingest pre-computed timeseries into the new origins' caches
+	for k, o := range conf.Origins {
+		if o.Ingestor == nil {
+			continue
+		}
+		if tc, ok := clients[k].(origins.TimeseriesClient); ok {
+			name := k
+			go o.Ingestor.Run(engines.IngestMergeFunc(o, caches[o.CacheName], tc, log),
+				func(err error) {
+					log.Warn("cache ingestion failed",
+						tl.Pairs{"originName": name, "detail": err.Error()})
+				}, conf.Resources.BackgroundQuitChan)
+		}
+	}
+
+	// obtain and renew the ACME certificates, swapping each one into the tls listener
+	// as it is stored
+	if conf.ACME.Enabled() {
+		go acme.New(conf.ACME, log).Run(func() { swapTLSCertificates(conf, log) },
+			conf.Resources.BackgroundQuitChan)
+	}
+
+	// start the warmers that replay the popular queries of each cache's origins
+	replay := engines.WarmingReplayFunc(conf.Origins, clients, log)
+	for k, c := range caches {
+		w := c.Configuration().Warmer
+		if w == nil {
+			continue
+		}
+		name := k
+		go w.Run(replay, func(err error) {
+			log.Warn("could not persist recorded cache warming queries",
+				tl.Pairs{"cacheName": name, "detail": err.Error()})
+		}, conf.Resources.BackgroundQuitChan)
+	}
+
+	return nil
+}
+
+// newFrontendRouter registers the config's routes with a new router, and wraps it in the
+// frontend middleware. every config (re)load, and every runtime rules update, is a new router
+func newFrontendRouter(conf *config.Config, caches map[string]cache.Cache,
+	tracers tracing.Tracers, log *log.Logger) (http.Handler, origins.Origins, error) {
+
+	router := mux.NewRouter()
+	if conf.Metrics == nil || !conf.Metrics.FrontendAdminDisabled {
+		registerAdminRoutes(conf, router)
+	}
+
+	clients, err := routing.RegisterProxyRoutes(conf, router, caches, tracers, log, false)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	// apply the frontend security headers profile to all responses
+	var frontend http.Handler = router
+	if p, ok := conf.SecurityHeaders[conf.Frontend.SecurityHeadersName]; ok {
+		frontend = middleware.SecurityHeaders(p, router)
+	}
+	// resolve the identity of the user making the request, as asserted by a trusted proxy
+	frontend = middleware.Identity(conf.Frontend.IdentityResolver, frontend)
+	// apply the forwarded headers policy to inbound requests before they are routed
+	frontend = middleware.TrustedProxies(conf.Frontend.ForwardedHeadersPolicy,
+		conf.Frontend.TrustedProxyNets, frontend)
+
+	return frontend, clients, nil
+}
+
+// registerAdminRoutes registers the ping and cluster status routes with the router
+func registerAdminRoutes(conf *config.Config, router *mux.Router) {
+	router.HandleFunc(conf.Main.PingHandlerPath, th.PingHandleFunc(conf)).Methods(http.MethodGet)
+	router.HandleFunc(conf.Main.ClusterHandlerPath, th.ClusterHandleFunc(conf)).Methods(http.MethodGet)
+}
+
+// newMetricsRouter returns the router of the metrics listener, which serves the metrics, config,
+// cache statistics and timeseries extents endpoints, and the admin and purge endpoints when the
+// metrics config calls for them
+func newMetricsRouter(conf *config.Config, clients origins.Origins, caches map[string]cache.Cache,
+	reloadHandler, rulesHandler, flagsHandler, compactHandler http.Handler,
+	tracers tracing.Tracers, log *log.Logger) http.Handler {
+	mr := http.NewServeMux()
+	mr.Handle("/metrics", metrics.Handler())
+	mr.HandleFunc(conf.Main.ConfigHandlerPath, th.ConfigHandleFunc(conf))
+	mr.HandleFunc(conf.Main.CacheStatsHandlerPath, th.CacheStatsHandleFunc(caches))
+	mr.HandleFunc(conf.Main.ExtentsHandlerPath, engines.ExtentsHandleFunc(conf.Origins, clients))
+	if conf.Main.PprofServer == "both" || conf.Main.PprofServer == "metrics" {
+		routing.RegisterPprofRoutes("metrics", mr, log)
+	}
+	if conf.Metrics == nil {
+		return mr
+	}
+	ar := mux.NewRouter()
+	if conf.Metrics.ServeAdmin {
+		mr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
+		mr.Handle(conf.ReloadConfig.RulesHandlerPath, rulesHandler)
+		mr.Handle(conf.ReloadConfig.FlagsHandlerPath, flagsHandler)
+		mr.Handle(conf.ReloadConfig.CompactHandlerPath, compactHandler)
+		registerAdminRoutes(conf, ar)
+		routing.RegisterHealthRoutes(conf, ar, clients, tracers, log)
+	}
+	if conf.Metrics.ServePurge {
+		routing.RegisterPurgeRoute(conf, ar, clients, caches, log)
+	}
+	if conf.Metrics.ServeAdmin || conf.Metrics.ServePurge {
+		mr.Handle("/", ar)
+	}
+	if conf.Metrics.Authorizer != nil {
+		return middleware.Authorize(authz.New("metrics", conf.Metrics.Authorizer), log, mr)
+	}
+	return mr
+}
+
+// applyRuntimeRules swaps the routes of a config updated by the runtime rules handler into the
+// frontend listeners. The caches, listeners and background tasks of the running config are kept
+func applyRuntimeRules(conf *config.Config, caches map[string]cache.Cache,
+	tracers tracing.Tracers, log *log.Logger) error {
+	frontend, _, err := newFrontendRouter(conf, caches, tracers, log)
+	if err != nil {
+		return err
+	}
+	lg.UpdateRouter("httpListener", frontend)
+	lg.UpdateRouter("tlsListener", frontend)
 	return nil
 }
 
@@ -152,6 +351,9 @@ func applyLoggingConfig(c, oc *config.Config, oldLog *log.Logger) *log.Logger {
 	}
 
 	if oc != nil && oc.Logging != nil {
+		if c.Logging.CanonicalLogLevel != oc.Logging.CanonicalLogLevel && oldLog != nil {
+			oldLog.SetCanonicalLogLevel(c.Logging.CanonicalLogLevel)
+		}
 		if c.Logging.LogFile == oc.Logging.LogFile &&
 			c.Logging.LogLevel == oc.Logging.LogLevel {
 			// no changes in logging config,
@@ -177,6 +379,15 @@ func applyLoggingConfig(c, oc *config.Config, oldLog *log.Logger) *log.Logger {
 	return initLogger(c)
 }
 
+// applyMemoryConfig replaces the running memory governor with one for the new config
+func applyMemoryConfig(c *config.Config, logger *log.Logger) {
+	if c.Memory == nil {
+		return
+	}
+	memoryGovernor.Stop()
+	memoryGovernor = mem.Start(c.Memory, logger)
+}
+
 func applyCachingConfig(c, oc *config.Config, logger *log.Logger,
 	oldCaches map[string]cache.Cache) map[string]cache.Cache {
 
@@ -243,6 +454,7 @@ func initLogger(c *config.Config) *log.Logger {
 			"goArch":    applicationGoArch,
 			"commitID":  applicationGitCommitID,
 			"buildTime": applicationBuildTime,
+			"fipsMode":  fips.Enabled,
 			"logLevel":  c.Logging.LogLevel,
 			"config":    c.ConfigFilePath(),
 			"pid":       os.Getpid(),
@@ -262,8 +474,46 @@ func delayedLogCloser(log *log.Logger, delay time.Duration) {
 	log.Close()
 }
 
+// logConfigDiff logs the changes made by a configuration reload, and records them in the
+// last reload changes metric
+func logConfigDiff(d *config.Diff, logger *log.Logger) {
+	metrics.ConfigLastReloadChanges.Reset()
+	for section, changes := range d.Counts() {
+		for change, n := range changes {
+			metrics.ConfigLastReloadChanges.WithLabelValues(section, change).Set(float64(n))
+		}
+	}
+	if d.IsEmpty() {
+		logger.Info("configuration reloaded with no changes",
+			tl.Pairs{"generation": configGeneration})
+		return
+	}
+	pairs := tl.Pairs{"generation": configGeneration}
+	for k, v := range map[string][]string{
+		"originsAdded":    d.Origins.Added,
+		"originsRemoved":  d.Origins.Removed,
+		"originsChanged":  d.Origins.Changed,
+		"cachesAdded":     d.Caches.Added,
+		"cachesRemoved":   d.Caches.Removed,
+		"cachesChanged":   d.Caches.Changed,
+		"pathsAdded":      d.Paths.Added,
+		"pathsRemoved":    d.Paths.Removed,
+		"pathsChanged":    d.Paths.Changed,
+		"sectionsChanged": d.Sections,
+	} {
+		if len(v) > 0 {
+			pairs[k] = strings.Join(v, ",")
+		}
+	}
+	logger.Info("configuration changes applied", pairs)
+}
+
 func handleStartupIssue(event string, detail log.Pairs, logger *log.Logger, exitFatal bool) {
 	metrics.LastReloadSuccessful.Set(0)
+	// a failure that is not fatal is a failed reload
+	if !exitFatal {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+	}
 	if event != "" {
 		if logger != nil {
 			if exitFatal {
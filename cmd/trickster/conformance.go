@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/conformance"
+)
+
+// cmdConformance is the subcommand that runs the time series provider conformance kit
+const cmdConformance = "conformance"
+
+// runConformance parses the conformance subcommand arguments, runs the requested
+// scenarios against the requested built-in providers, and prints the results
+func runConformance(arguments []string) error {
+
+	flagSet := flag.NewFlagSet(cmdConformance, flag.ContinueOnError)
+	providers := flagSet.String("providers", "",
+		"Comma-separated list of providers to validate (default all)")
+	scenarios := flagSet.String("scenarios", "",
+		"Comma-separated list of scenarios to run (default all)")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+
+	pl := conformance.Providers()
+	if *providers != "" {
+		pl = pl[:0]
+		for _, n := range strings.Split(*providers, ",") {
+			p := conformance.Lookup(strings.TrimSpace(n))
+			if p == nil {
+				return fmt.Errorf("unknown provider: %s", n)
+			}
+			pl = append(pl, p)
+		}
+	}
+
+	var names []string
+	if *scenarios != "" {
+		names = strings.Split(*scenarios, ",")
+		for i, n := range names {
+			names[i] = strings.TrimSpace(n)
+			if !isScenario(names[i]) {
+				return fmt.Errorf("unknown scenario: %s", n)
+			}
+		}
+	}
+
+	var failed int
+	for _, p := range pl {
+		for _, r := range conformance.Run(p, names...) {
+			switch {
+			case r.Skipped:
+				fmt.Printf("SKIP  %-12s %s\n", r.Provider, r.Scenario)
+			case r.Err != nil:
+				failed++
+				fmt.Printf("FAIL  %-12s %s: %s\n", r.Provider, r.Scenario, r.Err.Error())
+			default:
+				fmt.Printf("PASS  %-12s %s\n", r.Provider, r.Scenario)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d scenarios failed", failed)
+	}
+	return nil
+}
+
+func isScenario(name string) bool {
+	for _, s := range conformance.Scenarios() {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
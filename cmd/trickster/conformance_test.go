@@ -0,0 +1,38 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "testing"
+
+func TestRunConformance(t *testing.T) {
+
+	err := runConformance([]string{"-providers", "prometheus", "-scenarios", "parse,delta_miss_hit"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = runConformance([]string{"-providers", "invalid"})
+	if err == nil {
+		t.Error("expected error for unknown provider")
+	}
+
+	err = runConformance([]string{"-scenarios", "invalid"})
+	if err == nil {
+		t.Error("expected error for unknown scenario")
+	}
+
+}
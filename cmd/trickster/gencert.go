@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/selfsigned"
+)
+
+// cmdGenerateCert is the subcommand that writes a self-signed certificate and key to PEM files
+const cmdGenerateCert = "generate-cert"
+
+// generateCert parses the generate-cert subcommand arguments, and writes a new
+// self-signed certificate and private key to the requested PEM files
+func generateCert(arguments []string) error {
+
+	flagSet := flag.NewFlagSet(cmdGenerateCert, flag.ContinueOnError)
+	certPath := flagSet.String("cert-out", "cert.pem", "Path to write the PEM-encoded certificate")
+	keyPath := flagSet.String("key-out", "key.pem", "Path to write the PEM-encoded private key")
+	hosts := flagSet.String("hosts", strings.Join(selfsigned.DefaultHosts, ","),
+		"Comma-separated list of hostnames and IP addresses the certificate is valid for")
+	days := flagSet.Int("validity-days", int(selfsigned.DefaultValidity/(24*time.Hour)),
+		"Number of days the certificate is valid for")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("invalid validity-days: %d", *days)
+	}
+
+	cert, key, err := selfsigned.Generate(strings.Split(*hosts, ","),
+		time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(*certPath, cert, 0644); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(*keyPath, key, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote self-signed certificate to %s and private key to %s\n", *certPath, *keyPath)
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateCert(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-gencert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cp := filepath.Join(dir, "cert.pem")
+	kp := filepath.Join(dir, "key.pem")
+
+	err = generateCert([]string{"-cert-out", cp, "-key-out", kp, "-hosts", "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = tls.LoadX509KeyPair(cp, kp); err != nil {
+		t.Error(err)
+	}
+
+	err = generateCert([]string{"-validity-days", "0"})
+	if err == nil {
+		t.Error("expected error for invalid validity-days")
+	}
+
+}
@@ -21,22 +21,66 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	ph "github.com/tricksterproxy/trickster/pkg/proxy/handlers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/listener"
 	ttls "github.com/tricksterproxy/trickster/pkg/proxy/tls"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/tickets"
 	"github.com/tricksterproxy/trickster/pkg/routing"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
-	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
 )
 
 var lg = listener.NewListenerGroup()
 
+func sessionTicketOptionsEqual(fc, fc2 *config.FrontendConfig) bool {
+	return fc.TLSSessionTicketsDisabled == fc2.TLSSessionTicketsDisabled &&
+		fc.TLSSessionTicketKeyRotationSecs == fc2.TLSSessionTicketKeyRotationSecs &&
+		fc.TLSSessionTicketCacheName == fc2.TLSSessionTicketCacheName
+}
+
+// applySessionTicketConfig attaches a session ticket key rotator to the listener's tls config,
+// when the frontend config calls for Trickster-managed key rotation
+func applySessionTicketConfig(conf *config.Config, tlsConfig *tls.Config,
+	caches map[string]cache.Cache, log *log.Logger) error {
+	if tlsConfig == nil || conf.Frontend.TLSSessionTicketsDisabled ||
+		conf.Frontend.TLSSessionTicketKeyRotationSecs == 0 {
+		return nil
+	}
+	var c cache.Cache
+	if conf.Frontend.TLSSessionTicketCacheName != "" {
+		c = caches[conf.Frontend.TLSSessionTicketCacheName]
+	}
+	r := tickets.NewRotator(tlsConfig,
+		time.Duration(conf.Frontend.TLSSessionTicketKeyRotationSecs)*time.Second, c, log)
+	return r.Attach()
+}
+
+// swapTLSCertificates loads the certificates of the config and swaps them into the running
+// tls listener, without interrupting its connections
+func swapTLSCertificates(conf *config.Config, log *log.Logger) {
+	tlsConfig, err := conf.TLSCertConfig()
+	if err != nil {
+		log.Error("unable to update tls config due to certificate error", tl.Pairs{"detail": err})
+		return
+	}
+	if tlsConfig == nil {
+		return
+	}
+	if l := lg.Get("tlsListener"); l != nil {
+		if cs := l.CertSwapper(); cs != nil {
+			cs.SetCerts(tlsConfig.Certificates)
+		}
+	}
+}
+
 func applyListenerConfigs(conf, oldConf *config.Config,
-	router, reloadHandler http.Handler, log *log.Logger,
-	tracers tracing.Tracers) {
+	router, metricsRouter, reloadHandler, rulesHandler, flagsHandler, compactHandler http.Handler,
+	log *log.Logger,
+	tracers tracing.Tracers, caches map[string]cache.Cache) {
 
 	var err error
 	var tlsConfig *tls.Config
@@ -47,20 +91,21 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 
 	adminRouter := http.NewServeMux()
 	adminRouter.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
+	adminRouter.Handle(conf.ReloadConfig.RulesHandlerPath, rulesHandler)
+	adminRouter.Handle(conf.ReloadConfig.FlagsHandlerPath, flagsHandler)
+	adminRouter.Handle(conf.ReloadConfig.CompactHandlerPath, compactHandler)
+
+	// on the initial load, use any sockets passed by systemd socket activation
+	if oldConf == nil {
+		inheritSystemdListeners(log)
+	}
 
 	// No changes in frontend config
 	if oldConf != nil && oldConf.Frontend != nil &&
 		oldConf.Frontend.Equal(conf.Frontend) {
 		lg.UpdateFrontendRouters(router, adminRouter)
 		if ttls.OptionsChanged(conf, oldConf) {
-			tlsConfig, _ = conf.TLSCertConfig()
-			l := lg.Get("tlsListener")
-			if l != nil {
-				cs := l.CertSwapper()
-				if cs != nil {
-					cs.SetCerts(tlsConfig.Certificates)
-				}
-			}
+			swapTLSCertificates(conf, log)
 		}
 	}
 
@@ -82,16 +127,24 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 	if conf.Frontend.ServeTLS && conf.Frontend.TLSListenPort > 0 && (!hasOldFC ||
 		!oldConf.Frontend.ServeTLS ||
 		(oldConf.Frontend.TLSListenAddress != conf.Frontend.TLSListenAddress ||
-			oldConf.Frontend.TLSListenPort != conf.Frontend.TLSListenPort)) {
+			oldConf.Frontend.TLSListenPort != conf.Frontend.TLSListenPort ||
+			oldConf.Frontend.AddressFamily != conf.Frontend.AddressFamily ||
+			!str.Equal(oldConf.Frontend.TLSListenAddresses, conf.Frontend.TLSListenAddresses) ||
+			!oldConf.Frontend.TLSPolicy.Equal(conf.Frontend.TLSPolicy) ||
+			!sessionTicketOptionsEqual(oldConf.Frontend, conf.Frontend) ||
+			!str.Equal(oldConf.Frontend.TLSClientCAPaths, conf.Frontend.TLSClientCAPaths))) {
 		lg.DrainAndClose("tlsListener", drainTimeout)
 		tlsConfig, err = conf.TLSCertConfig()
 		if err != nil {
 			log.Error("unable to start tls listener due to certificate error", tl.Pairs{"detail": err})
+		} else if err = applySessionTicketConfig(conf, tlsConfig, caches, log); err != nil {
+			log.Error("unable to start tls listener due to session ticket key error", tl.Pairs{"detail": err})
 		} else {
 			wg.Add(1)
 			tracerFlusherSet = true
-			go lg.StartListener("tlsListener",
-				conf.Frontend.TLSListenAddress, conf.Frontend.TLSListenPort,
+			go lg.StartListener("tlsListener", listener.Network(conf.Frontend.AddressFamily),
+				listener.Addresses(conf.Frontend.TLSListenAddress, conf.Frontend.TLSListenAddresses),
+				conf.Frontend.TLSListenPort,
 				conf.Frontend.ConnectionsLimit, tlsConfig, router, wg, tracers, true,
 				time.Duration(conf.ReloadConfig.DrainTimeoutSecs)*time.Second, log)
 		}
@@ -100,76 +153,75 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		// the TLS listener port needs to be stopped
 		lg.DrainAndClose("tlsListener", drainTimeout)
 	} else if conf.Frontend.ServeTLS && ttls.OptionsChanged(conf, oldConf) {
-		tlsConfig, _ = conf.TLSCertConfig()
-		if err != nil {
-			log.Error("unable to update tls config to certificate error", tl.Pairs{"detail": err})
-			return
-		}
-		l := lg.Get("tlsListener")
-		if l != nil {
-			cs := l.CertSwapper()
-			if cs != nil {
-				cs.SetCerts(tlsConfig.Certificates)
-			}
-		}
+		swapTLSCertificates(conf, log)
 	}
 
 	// if the plaintext HTTP port is configured, then set up the http listener instance
 	if conf.Frontend.ListenPort > 0 && (!hasOldFC ||
 		(oldConf.Frontend.ListenAddress != conf.Frontend.ListenAddress ||
-			oldConf.Frontend.ListenPort != conf.Frontend.ListenPort)) {
+			oldConf.Frontend.ListenPort != conf.Frontend.ListenPort ||
+			oldConf.Frontend.AddressFamily != conf.Frontend.AddressFamily ||
+			!str.Equal(oldConf.Frontend.ListenAddresses, conf.Frontend.ListenAddresses))) {
 		lg.DrainAndClose("httpListener", drainTimeout)
 		wg.Add(1)
 		var t2 tracing.Tracers
 		if !tracerFlusherSet {
 			t2 = tracers
 		}
-		go lg.StartListener("httpListener",
-			conf.Frontend.ListenAddress, conf.Frontend.ListenPort,
+		go lg.StartListener("httpListener", listener.Network(conf.Frontend.AddressFamily),
+			listener.Addresses(conf.Frontend.ListenAddress, conf.Frontend.ListenAddresses),
+			conf.Frontend.ListenPort,
 			conf.Frontend.ConnectionsLimit, nil, router, wg, t2, true, 0, log)
 	}
 
-	// if the Metrics HTTP port is configured, then set up the http listener instance
+	// if the Metrics HTTP port is configured, then set up the http listener instance,
+	// which serves TLS when the metrics config provides a certificate
 	if conf.Metrics != nil && conf.Metrics.ListenPort > 0 &&
-		(!hasOldMC || (conf.Metrics.ListenAddress != oldConf.Metrics.ListenAddress ||
-			conf.Metrics.ListenPort != oldConf.Metrics.ListenPort)) {
+		(!hasOldMC || !conf.Metrics.ListenerEqual(oldConf.Metrics)) {
 		lg.DrainAndClose("metricsListener", 0)
-		mr := http.NewServeMux()
-		mr.Handle("/metrics", metrics.Handler())
-		mr.HandleFunc(conf.Main.ConfigHandlerPath, ph.ConfigHandleFunc(conf))
-		if conf.Main.PprofServer == "both" || conf.Main.PprofServer == "metrics" {
-			routing.RegisterPprofRoutes("metrics", mr, log)
+		metricsTLSConfig, err := conf.MetricsTLSConfig()
+		if err != nil {
+			log.Error("unable to start metrics listener due to certificate error",
+				tl.Pairs{"detail": err})
+		} else {
+			wg.Add(1)
+			go lg.StartListener("metricsListener", listener.Network(conf.Metrics.AddressFamily),
+				listener.Addresses(conf.Metrics.ListenAddress, conf.Metrics.ListenAddresses),
+				conf.Metrics.ListenPort,
+				conf.Frontend.ConnectionsLimit, metricsTLSConfig, metricsRouter, wg, nil, true, 0, log)
 		}
-		wg.Add(1)
-		go lg.StartListener("metricsListener",
-			conf.Metrics.ListenAddress, conf.Metrics.ListenPort,
-			conf.Frontend.ConnectionsLimit, nil, mr, wg, nil, true, 0, log)
 	} else {
-		mr := http.NewServeMux()
-		mr.Handle("/metrics", metrics.Handler())
-		mr.HandleFunc(conf.Main.ConfigHandlerPath, ph.ConfigHandleFunc(conf))
-		lg.UpdateRouter("metricsListener", mr)
+		lg.UpdateRouter("metricsListener", metricsRouter)
 	}
 
 	// if the Reload HTTP port is configured, then set up the http listener instance
 	if conf.ReloadConfig != nil && conf.ReloadConfig.ListenPort > 0 &&
 		(!hasOldRC || (conf.ReloadConfig.ListenAddress != oldConf.ReloadConfig.ListenAddress ||
-			conf.ReloadConfig.ListenPort != oldConf.ReloadConfig.ListenPort)) {
+			conf.ReloadConfig.ListenPort != oldConf.ReloadConfig.ListenPort ||
+			conf.ReloadConfig.AddressFamily != oldConf.ReloadConfig.AddressFamily ||
+			!str.Equal(conf.ReloadConfig.ListenAddresses, oldConf.ReloadConfig.ListenAddresses))) {
 		wg.Add(1)
 		lg.DrainAndClose("reloadListener", time.Millisecond*500)
 		mr := http.NewServeMux()
 		mr.HandleFunc(conf.Main.ConfigHandlerPath, ph.ConfigHandleFunc(conf))
 		mr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
+		mr.Handle(conf.ReloadConfig.RulesHandlerPath, rulesHandler)
+		mr.Handle(conf.ReloadConfig.FlagsHandlerPath, flagsHandler)
+		mr.Handle(conf.ReloadConfig.CompactHandlerPath, compactHandler)
 		if conf.Main.PprofServer == "both" || conf.Main.PprofServer == "reload" {
 			routing.RegisterPprofRoutes("reload", mr, log)
 		}
-		go lg.StartListener("reloadListener",
-			conf.ReloadConfig.ListenAddress, conf.ReloadConfig.ListenPort,
+		go lg.StartListener("reloadListener", listener.Network(conf.ReloadConfig.AddressFamily),
+			listener.Addresses(conf.ReloadConfig.ListenAddress, conf.ReloadConfig.ListenAddresses),
+			conf.ReloadConfig.ListenPort,
 			conf.Frontend.ConnectionsLimit, nil, mr, wg, nil, true, 0, log)
 	} else {
 		mr := http.NewServeMux()
 		mr.HandleFunc(conf.Main.ConfigHandlerPath, ph.ConfigHandleFunc(conf))
 		mr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
+		mr.Handle(conf.ReloadConfig.RulesHandlerPath, rulesHandler)
+		mr.Handle(conf.ReloadConfig.FlagsHandlerPath, flagsHandler)
+		mr.Handle(conf.ReloadConfig.CompactHandlerPath, compactHandler)
 		lg.UpdateRouter("reloadListener", mr)
 	}
 }
@@ -18,6 +18,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"sync"
 
@@ -42,6 +43,47 @@ var wg = &sync.WaitGroup{}
 func main() {
 	runtime.ApplicationName = applicationName
 	runtime.ApplicationVersion = applicationVersion
+	if len(os.Args) > 1 && os.Args[1] == cmdGenerateCert {
+		if err := generateCert(os.Args[2:]); err != nil {
+			fmt.Println("\nERROR: Could not generate certificate:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == cmdConformance {
+		if err := runConformance(os.Args[2:]); err != nil {
+			fmt.Println("\nERROR: Conformance check failed:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == cmdMockOrigin {
+		if err := runMockOrigin(os.Args[2:]); err != nil {
+			fmt.Println("\nERROR: Could not serve mock origin:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == cmdReplay {
+		if err := runReplay(os.Args[2:]); err != nil {
+			fmt.Println("\nERROR: Could not replay capture:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == cmdCompactBBolt {
+		if err := compactBBolt(os.Args[2:]); err != nil {
+			fmt.Println("\nERROR: Could not compact bbolt cache:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if isWindowsService() {
+		if err := runWindowsService(os.Args[1:]); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 	runConfig(nil, wg, nil, nil, os.Args[1:], fatalStartupErrors)
 	wg.Wait()
 }
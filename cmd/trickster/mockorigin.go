@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/mock"
+)
+
+// cmdMockOrigin is the subcommand that serves synthetic time series data as a mock origin
+const cmdMockOrigin = "mock-origin"
+
+// defaultMockOriginPorts are the listen ports used when -port is not provided,
+// which match the default ports of the simulated origin types
+var defaultMockOriginPorts = map[string]int{
+	"prometheus": 9090,
+	"influxdb":   8086,
+	"clickhouse": 8123,
+}
+
+// newMockOrigin parses the mock-origin subcommand arguments and returns the http.Server
+func newMockOrigin(arguments []string) (*http.Server, error) {
+
+	o := mock.NewOptions()
+
+	flagSet := flag.NewFlagSet(cmdMockOrigin, flag.ContinueOnError)
+	flagSet.StringVar(&o.OriginType, "origin-type", o.OriginType,
+		"Origin type to simulate: prometheus, influxdb or clickhouse")
+	port := flagSet.Int("port", 0, "Port to listen on (default is the origin type's default port)")
+	address := flagSet.String("listen-address", "", "IP address to listen on (default all)")
+	flagSet.IntVar(&o.SeriesCount, "series-count", o.SeriesCount,
+		"Number of series in each time series response")
+	latency := flagSet.Int("latency-ms", 0, "Minimum delay before each response, in milliseconds")
+	jitter := flagSet.Int("latency-jitter-ms", 0,
+		"Maximum random delay added to each response, in milliseconds")
+	flagSet.Float64Var(&o.FailureRate, "failure-rate", o.FailureRate,
+		"Fraction of requests, between 0 and 1, that fail with -failure-status")
+	flagSet.IntVar(&o.FailureStatus, "failure-status", o.FailureStatus,
+		"HTTP status code of injected failures")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return nil, err
+	}
+
+	o.Latency = time.Duration(*latency) * time.Millisecond
+	o.LatencyJitter = time.Duration(*jitter) * time.Millisecond
+
+	m, err := mock.New(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if *port == 0 {
+		*port = defaultMockOriginPorts[o.OriginType]
+	}
+
+	return &http.Server{Addr: *address + ":" + strconv.Itoa(*port), Handler: m}, nil
+}
+
+// runMockOrigin serves the mock origin until the process exits
+func runMockOrigin(arguments []string) error {
+	srv, err := newMockOrigin(arguments)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving mock %s origin on %s\n", srv.Handler.(*mock.Origin).Options().OriginType, srv.Addr)
+	return srv.ListenAndServe()
+}
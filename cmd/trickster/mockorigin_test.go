@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewMockOrigin(t *testing.T) {
+
+	srv, err := newMockOrigin([]string{"-origin-type", "influxdb", "-series-count", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Addr != ":8086" {
+		t.Errorf("expected %s got %s", ":8086", srv.Addr)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://mock/ping", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+
+	_, err = newMockOrigin([]string{"-failure-rate", "2"})
+	if err == nil {
+		t.Error("expected error for invalid failure rate")
+	}
+
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture"
+)
+
+// cmdReplay is the subcommand that serves a traffic capture as the origin
+const cmdReplay = "replay"
+
+var errNoCaptureFile = errors.New("-capture is required")
+
+// replaySession is a parsed replay subcommand
+type replaySession struct {
+	srv     *http.Server
+	entries []*capture.Entry
+	target  string
+	speed   float64
+}
+
+// newReplay parses the replay subcommand arguments and loads the capture file
+func newReplay(arguments []string) (*replaySession, error) {
+
+	flagSet := flag.NewFlagSet(cmdReplay, flag.ContinueOnError)
+	path := flagSet.String("capture", "", "Path to the capture file to replay")
+	origin := flagSet.String("origin", "", "Name of the captured origin to replay (default all)")
+	port := flagSet.Int("port", 9090, "Port on which to serve the captured upstream responses")
+	address := flagSet.String("listen-address", "", "IP address to listen on (default all)")
+	target := flagSet.String("target", "",
+		"Base URL of a Trickster to which the captured client requests are sent (default none)")
+	speed := flagSet.Float64("speed", 1,
+		"Multiplier of the captured pace of client requests; 0 sends them back-to-back")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return nil, err
+	}
+	if *path == "" {
+		return nil, errNoCaptureFile
+	}
+
+	entries, err := capture.Load(*path, *origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &replaySession{
+		srv: &http.Server{Addr: *address + ":" + strconv.Itoa(*port),
+			Handler: capture.NewReplayer(entries)},
+		entries: entries,
+		target:  *target,
+		speed:   *speed,
+	}, nil
+}
+
+// runReplay serves the captured upstream responses, and when a target is provided,
+// drives the captured client requests against it and exits
+func runReplay(arguments []string) error {
+	rs, err := newReplay(arguments)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving %d captured upstream requests on %s\n",
+		rs.srv.Handler.(*capture.Replayer).Len(), rs.srv.Addr)
+	if rs.target == "" {
+		return rs.srv.ListenAndServe()
+	}
+	ln, err := net.Listen("tcp", rs.srv.Addr)
+	if err != nil {
+		return err
+	}
+	go rs.srv.Serve(ln)
+	defer rs.srv.Close()
+	return rs.drive(context.Background())
+}
+
+// drive sends the captured client requests to the target and prints the outcome of each
+func (rs *replaySession) drive(ctx context.Context) error {
+	results, err := capture.Drive(ctx, nil, rs.target, rs.entries, rs.speed)
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("ERR  %s %s: %s\n", r.Entry.Method, r.Entry.URL, r.Err.Error())
+			continue
+		}
+		fmt.Printf("%d  %s %s\n", r.StatusCode, r.Entry.Method, r.Entry.URL)
+	}
+	fmt.Printf("replayed %d client requests, %d failed\n", len(results), failed)
+	return nil
+}
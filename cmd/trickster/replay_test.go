@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testCapture = `{"kind":"client","origin":"test","time":"2020-05-01T00:00:00Z","method":"GET","url":"/api/v1/query?query=up"}
+{"kind":"upstream","origin":"test","time":"2020-05-01T00:00:00Z","method":"GET","url":"http://origin/api/v1/query?query=up","response":{"status":200,"body":"b2s="}}
+`
+
+func TestNewReplay(t *testing.T) {
+
+	_, err := newReplay([]string{})
+	if err != errNoCaptureFile {
+		t.Errorf("expected %v got %v", errNoCaptureFile, err)
+	}
+
+	f, err := ioutil.TempFile("/tmp", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testCapture)
+	f.Close()
+
+	rs, err := newReplay([]string{"-capture", f.Name(), "-port", "9099"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.srv.Addr != ":9099" {
+		t.Errorf("expected %s got %s", ":9099", rs.srv.Addr)
+	}
+
+	w := httptest.NewRecorder()
+	rs.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://replay/api/v1/query?query=up", nil))
+	if w.Body.String() != "ok" {
+		t.Errorf("expected %s got %s", "ok", w.Body.String())
+	}
+
+	ts := httptest.NewServer(rs.srv.Handler)
+	defer ts.Close()
+	rs.target = ts.URL
+	if err := rs.drive(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+}
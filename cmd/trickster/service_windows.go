@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// windowsServiceName is the name under which Trickster registers with the Service Control Manager
+const windowsServiceName = "trickster"
+
+// isWindowsService returns true when Trickster was started by the Windows Service Control Manager
+func isWindowsService() bool {
+	interactive, err := svc.IsAnInteractiveSession()
+	return err == nil && !interactive
+}
+
+// runWindowsService runs Trickster as a Windows service until the service is stopped
+func runWindowsService(args []string) error {
+	return svc.Run(windowsServiceName, &windowsService{args: args})
+}
+
+type windowsService struct {
+	args []string
+}
+
+// Execute implements svc.Handler
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest,
+	changes chan<- svc.Status) (bool, uint32) {
+
+	changes <- svc.Status{State: svc.StartPending}
+
+	// services are started in the system directory, so relative paths (such as the
+	// default config and cache paths) are resolved from the executable's directory
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+
+	if err := runConfig(nil, wg, nil, nil, s.args, false); err != nil {
+		return true, 1
+	}
+
+	changes <- svc.Status{State: svc.Running,
+		Accepts: svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange}
+
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			changes <- r.CurrentStatus
+		case svc.ParamChange:
+			// a paramchange control request reloads the config, as a SIGHUP does elsewhere
+			select {
+			case hups <- syscall.SIGHUP:
+			default:
+			}
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
@@ -21,6 +21,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/config"
@@ -38,8 +39,18 @@ func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
 	if conf == nil || conf.Resources == nil {
 		return
 	}
+	// a config from a remote config source is also polled for changes
+	var poll <-chan time.Time
+	var ticker *time.Ticker
+	if conf.IsRemote() && conf.ReloadConfig != nil && conf.ReloadConfig.RemotePollIntervalSecs > 0 {
+		ticker = time.NewTicker(time.Duration(conf.ReloadConfig.RemotePollIntervalSecs) * time.Second)
+		poll = ticker.C
+	}
 	// assumes all parameters are instantiated
 	go func() {
+		if ticker != nil {
+			defer ticker.Stop()
+		}
 		for {
 			select {
 			case <-hups:
@@ -54,6 +65,19 @@ func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
 				}
 				conf.Main.ReloaderLock.Unlock()
 				log.Warn("configuration NOT reloaded", tl.Pairs{})
+			case <-poll:
+				conf.Main.ReloaderLock.Lock()
+				if conf.IsStale() {
+					log.Warn("configuration reload starting now", tl.Pairs{"source": "remotePoll",
+						"config": conf.ConfigFilePath()})
+					err := runConfig(conf, wg, log, caches, args, false)
+					if err == nil {
+						conf.Main.ReloaderLock.Unlock()
+						return
+					}
+					log.Warn("configuration NOT reloaded", tl.Pairs{"source": "remotePoll"})
+				}
+				conf.Main.ReloaderLock.Unlock()
 			case <-conf.Resources.QuitChan:
 				return
 			}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"sync"
+
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/systemd"
+)
+
+// systemdListenerNames maps the FileDescriptorName of each socket-activated socket
+// to the Trickster listener that will accept connections on it
+var systemdListenerNames = map[string]string{
+	"http":    "httpListener",
+	"tls":     "tlsListener",
+	"metrics": "metricsListener",
+	"reload":  "reloadListener",
+}
+
+var systemdWatchdogOnce sync.Once
+
+// inheritSystemdListeners provides any sockets passed by systemd socket activation
+// to the listener group, so they are used in place of binding the configured ports
+func inheritSystemdListeners(log *tl.Logger) {
+	ls, err := systemd.Listeners()
+	if err != nil {
+		log.Error("unable to inherit systemd sockets", tl.Pairs{"detail": err})
+		return
+	}
+	for k, v := range ls {
+		name, ok := systemdListenerNames[k]
+		if !ok {
+			log.Warn("ignoring systemd socket with unknown name", tl.Pairs{"name": k})
+			for _, l := range v {
+				l.Close()
+			}
+			continue
+		}
+		log.Info("inheriting systemd sockets", tl.Pairs{"name": name, "sockets": len(v)})
+		lg.Inherit(name, v)
+	}
+}
+
+// notifySystemd sends the provided state to systemd, when run as a Type=notify service
+func notifySystemd(state string, log *tl.Logger) {
+	if _, err := systemd.Notify(state); err != nil {
+		log.Warn("unable to notify systemd", tl.Pairs{"state": state, "detail": err})
+	}
+}
+
+// startSystemdWatchdog sends keep-alives to systemd for the life of the process,
+// when the service's WatchdogSec is set
+func startSystemdWatchdog(log *tl.Logger) {
+	systemdWatchdogOnce.Do(func() {
+		d, err := systemd.WatchdogInterval()
+		if err != nil {
+			log.Warn("unable to start systemd watchdog", tl.Pairs{"detail": err})
+			return
+		}
+		if d > 0 {
+			log.Info("starting systemd watchdog", tl.Pairs{"interval": d.String()})
+			go systemd.RunWatchdog(d, nil)
+		}
+	})
+}
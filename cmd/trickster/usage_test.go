@@ -46,9 +46,30 @@ func ExamplePrintUsage() {
 	//  Using a configuration file:
 	//   trickster -config /path/to/file.conf [-log-level DEBUG|INFO|WARN|ERROR] [-proxy-port 8480] [-metrics-port 8481]
 	//
+	//  Using a remote configuration source (http, https, s3, etcd or consul), polled for changes:
+	//   trickster -config https://config.example.com/trickster.conf [-config-public-key /path/to/key.pem]
+	//
 	//  Using origin-url and origin-type:
 	//   trickster -origin-url https://example.com -origin-type reverseproxycache [-log-level DEBUG|INFO|WARN|ERROR] [-proxy-port 8480] [-metrics-port 8481]
 	//
+	//  Serving TLS with an in-memory self-signed certificate for localhost (development only):
+	//   trickster -config /path/to/file.conf -dev-tls
+	//
+	//  Writing a self-signed certificate and key to PEM files:
+	//   trickster generate-cert [-cert-out cert.pem] [-key-out key.pem] [-hosts localhost,127.0.0.1,::1] [-validity-days 365]
+	//
+	//  Compacting the bbolt cache files of a configuration while Trickster is stopped:
+	//   trickster compact-bbolt -config /path/to/file.conf [-cache name]
+	//
+	//  Validating the time series providers against the conformance kit:
+	//   trickster conformance [-providers prometheus,influxdb,clickhouse,irondb] [-scenarios parse,delta_partial_hit]
+	//
+	//  Serving synthetic data as a mock origin for demos and load testing:
+	//   trickster mock-origin [-origin-type prometheus|influxdb|clickhouse] [-port 9090] [-series-count 1] [-latency-ms 0] [-latency-jitter-ms 0] [-failure-rate 0] [-failure-status 503]
+	//
+	//  Replaying a traffic capture as the origin, optionally driving its client requests against a Trickster:
+	//   trickster replay -capture /path/to/capture.jsonl [-origin name] [-port 9090] [-target http://localhost:8480] [-speed 1]
+	//
 	// ------
 	//
 	//  Simple HTTP Reverse Proxy Cache listening on 8080:
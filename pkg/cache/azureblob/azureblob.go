@@ -0,0 +1,347 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package azureblob is the Azure Blob Storage implementation of the Trickster Cache. Objects
+// are stored as block blobs in an Azure Blob Storage container, so that a durable cache can
+// be shared by each Trickster using the container
+package azureblob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	abo "github.com/tricksterproxy/trickster/pkg/cache/azureblob/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// apiVersion is the version of the Blob service REST API with which requests are made
+const apiVersion = "2020-04-08"
+
+// headerExpiration is the blob metadata header holding the blob's expiration, in
+// milliseconds since the epoch. Metadata names must be valid C# identifiers
+const headerExpiration = "X-Ms-Meta-Trickster_expiration"
+
+// ErrMissingAccount is returned when neither the account name nor the endpoint is known
+var ErrMissingAccount = errors.New("azure blob account_name must be provided")
+
+// ErrMissingCredentials is returned when neither an account key nor a SAS token is known
+var ErrMissingCredentials = errors.New("azure blob account_key or sas_token must be provided")
+
+// New returns a new Azure Blob Cache
+func New(cacheName string, cfg *options.Options, logger *tl.Logger) *objectstore.Cache {
+	return &objectstore.Cache{Name: cacheName, Config: cfg, Logger: logger,
+		Client: &Client{Options: cfg.AzureBlob}, Prefix: cfg.AzureBlob.Prefix}
+}
+
+// Client is the Blob service REST API client of an Azure Blob Cache
+type Client struct {
+	Options *abo.Options
+
+	client   *http.Client
+	endpoint *url.URL
+	account  string
+	key      []byte
+	sas      url.Values
+}
+
+// Connect verifies that the configured container is accessible
+func (c *Client) Connect() error {
+	o := c.Options
+	c.account = o.AccountName
+	if c.account == "" {
+		c.account = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		if c.account == "" {
+			return ErrMissingAccount
+		}
+		endpoint = "https://" + c.account + ".blob.core.windows.net"
+	}
+	var err error
+	if c.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/")); err != nil {
+		return err
+	}
+
+	c.key, c.sas = nil, nil
+	if o.SASToken != "" {
+		if c.sas, err = url.ParseQuery(strings.TrimPrefix(o.SASToken, "?")); err != nil {
+			return err
+		}
+	} else {
+		key := o.AccountKey
+		if key == "" {
+			key = os.Getenv("AZURE_STORAGE_KEY")
+		}
+		if key == "" || c.account == "" {
+			return ErrMissingCredentials
+		}
+		if c.key, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return err
+		}
+	}
+	c.client = &http.Client{Timeout: o.Timeout}
+
+	resp, err := c.do(http.MethodGet, c.blobURL("", "restype=container"), nil, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure blob container %s is not accessible: status %d",
+			o.Container, resp.StatusCode)
+	}
+	return nil
+}
+
+// Put writes the blob to the container
+func (c *Client) Put(name string, data []byte, expiration time.Time) error {
+	h := blobHeader(expiration)
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("X-Ms-Blob-Type", "BlockBlob")
+	resp, err := c.do(http.MethodPut, c.blobURL(name, ""), h, data)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusCreated)
+}
+
+// Get reads the blob from the container
+func (c *Client) Get(name string) ([]byte, time.Time, error) {
+	resp, err := c.do(http.MethodGet, c.blobURL(name, ""), nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, time.Time{}, objectstore.ErrObjectNotFound
+	default:
+		return nil, time.Time{}, responseError(resp)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var expiration time.Time
+	if ms, err := strconv.ParseInt(resp.Header.Get(headerExpiration), 10, 64); err == nil {
+		expiration = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return data, expiration, nil
+}
+
+// SetExpiration updates the expiration of the blob by replacing its metadata
+func (c *Client) SetExpiration(name string, expiration time.Time) error {
+	resp, err := c.do(http.MethodPut, c.blobURL(name, "comp=metadata"),
+		blobHeader(expiration), nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// Delete removes the blob from the container
+func (c *Client) Delete(name string) error {
+	resp, err := c.do(http.MethodDelete, c.blobURL(name, ""), nil, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusAccepted)
+}
+
+// enumerationResults is the response to a List Blobs request
+type enumerationResults struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List returns the names of the blobs in the container that begin with prefix
+func (c *Client) List(prefix string) ([]string, error) {
+	var names []string
+	q := url.Values{"restype": []string{"container"}, "comp": []string{"list"},
+		"prefix": []string{prefix}}
+	for {
+		resp, err := c.do(http.MethodGet, c.blobURL("", q.Encode()), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var er enumerationResults
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return responseError(resp)
+			}
+			return xml.NewDecoder(resp.Body).Decode(&er)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range er.Blobs {
+			names = append(names, b.Name)
+		}
+		if er.NextMarker == "" {
+			return names, nil
+		}
+		q.Set("marker", er.NextMarker)
+	}
+}
+
+// Close closes the Client's idle connections
+func (c *Client) Close() error {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// blobHeader returns the headers of a blob that expires at the provided time
+func blobHeader(expiration time.Time) http.Header {
+	return http.Header{headerExpiration: []string{strconv.FormatInt(
+		expiration.UnixNano()/int64(time.Millisecond), 10)}}
+}
+
+// blobURL returns the URL of the named blob, or of the container when name is empty, with
+// the provided query
+func (c *Client) blobURL(name, query string) *url.URL {
+	u := *c.endpoint
+	u.Path += "/" + c.Options.Container
+	if name != "" {
+		u.Path += "/" + name
+	}
+	u.RawPath = ""
+	u.RawQuery = query
+	return &u
+}
+
+// do sends an authorized request to the Blob service
+func (c *Client) do(method string, u *url.URL, h http.Header, body []byte) (*http.Response, error) {
+	if c.sas != nil {
+		q := u.Query()
+		for k, v := range c.sas {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		r.Header[k] = v
+	}
+	r.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("X-Ms-Version", apiVersion)
+	if c.sas == nil {
+		r.Header.Set("Authorization", "SharedKey "+c.account+":"+
+			base64.StdEncoding.EncodeToString(signature(r, c.account, c.key)))
+	}
+	return c.client.Do(r)
+}
+
+// signature returns the Shared Key signature of the request, which is the HMAC-SHA256 of
+// the request's canonical string, keyed by the account key
+func signature(r *http.Request, account string, key []byte) []byte {
+	var contentLength string
+	if r.ContentLength > 0 {
+		contentLength = strconv.FormatInt(r.ContentLength, 10)
+	}
+	var b strings.Builder
+	for _, v := range []string{r.Method, r.Header.Get("Content-Encoding"),
+		r.Header.Get("Content-Language"), contentLength, r.Header.Get("Content-Md5"),
+		r.Header.Get("Content-Type"), "", r.Header.Get("If-Modified-Since"),
+		r.Header.Get("If-Match"), r.Header.Get("If-None-Match"),
+		r.Header.Get("If-Unmodified-Since"), r.Header.Get("Range")} {
+		b.WriteString(v + "\n")
+	}
+
+	// the canonicalized headers are each x-ms- header, sorted by lowercase name
+	var names []string
+	for k := range r.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		b.WriteString(k + ":" + strings.TrimSpace(r.Header.Get(k)) + "\n")
+	}
+
+	// the canonicalized resource is the account and path, followed by each query
+	// parameter, sorted by lowercase name
+	b.WriteString("/" + account + r.URL.EscapedPath())
+	q := r.URL.Query()
+	names = names[:0]
+	for k := range q {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := append([]string(nil), q[k]...)
+		sort.Strings(v)
+		b.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(v, ","))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(b.String()))
+	return mac.Sum(nil)
+}
+
+// blobError is the error document of a failed request
+type blobError struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// checkResponse closes the response body, and returns an error if the status is not expected
+func checkResponse(resp *http.Response, expected int) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == expected {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return responseError(resp)
+}
+
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e blobError
+	if xml.Unmarshal(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), &e) == nil {
+		return fmt.Errorf("azure blob error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("azure blob responded with status %d", resp.StatusCode)
+}
@@ -0,0 +1,451 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azureblob
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	abo "github.com/tricksterproxy/trickster/pkg/cache/azureblob/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "azureblob"
+const cacheKey = "cacheKey"
+const testAccount = "devstoreaccount1"
+const testContainer = "trickster-test"
+const testSAS = "sv=2020-04-08&sig=test"
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("test-key"))
+
+type testBlob struct {
+	data       []byte
+	expiration string
+}
+
+// testBlobService is an in-memory Blob service serving a single container at a path-style
+// endpoint, as the Azurite emulator does
+type testBlobService struct {
+	mtx   sync.Mutex
+	blobs map[string]*testBlob
+}
+
+func newTestBlobService() (*testBlobService, *httptest.Server) {
+	s := &testBlobService{blobs: make(map[string]*testBlob)}
+	return s, httptest.NewServer(s)
+}
+
+func (s *testBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	if r.Header.Get("X-Ms-Version") != apiVersion || r.Header.Get("X-Ms-Date") == "" ||
+		(r.URL.Query().Get("sig") != "test" && r.Header.Get("Authorization") != "SharedKey "+
+			testAccount+":"+base64.StdEncoding.EncodeToString(signature(r, testAccount, key))) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"utf-8\"?><Error>" +
+			"<Code>AuthenticationFailed</Code><Message>test</Message></Error>"))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/"+testAccount+"/")
+	if path != testContainer && !strings.HasPrefix(path, testContainer+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(path, testContainer), "/")
+	qp := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case name == "" && qp.Get("restype") == "container" && qp.Get("comp") == "list":
+		// lists one blob per page, to exercise continuation
+		var names []string
+		for k := range s.blobs {
+			if strings.HasPrefix(k, qp.Get("prefix")) && k >= qp.Get("marker") {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		var b bytes.Buffer
+		b.WriteString("<EnumerationResults><Blobs>")
+		if len(names) > 0 {
+			b.WriteString("<Blob><Name>" + names[0] + "</Name></Blob>")
+		}
+		b.WriteString("</Blobs><NextMarker>")
+		if len(names) > 1 {
+			b.WriteString(names[1])
+		}
+		b.WriteString("</NextMarker></EnumerationResults>")
+		w.Write(b.Bytes())
+	case name == "" && qp.Get("restype") == "container" && r.Method == http.MethodGet:
+	case r.Method == http.MethodPut && qp.Get("comp") == "metadata":
+		b, ok := s.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>BlobNotFound</Code><Message>test</Message></Error>"))
+			return
+		}
+		b.expiration = r.Header.Get(headerExpiration)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.blobs[name] = &testBlob{data: body, expiration: r.Header.Get(headerExpiration)}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		b, ok := s.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if b.expiration != "" {
+			w.Header().Set(headerExpiration, b.expiration)
+		}
+		w.Write(b.data)
+	case r.Method == http.MethodDelete:
+		if _, ok := s.blobs[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>BlobNotFound</Code><Message>test</Message></Error>"))
+			return
+		}
+		delete(s.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestOptions(endpoint string) *abo.Options {
+	o := abo.NewOptions()
+	o.Endpoint = endpoint + "/" + testAccount
+	o.AccountName = testAccount
+	o.AccountKey = testKey
+	o.Container = testContainer
+	return o
+}
+
+func newTestClient(t *testing.T) (*Client, *testBlobService, func()) {
+	s, ts := newTestBlobService()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, s, func() {
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, ts := newTestBlobService()
+	defer ts.Close()
+	cacheConfig := co.Options{CacheType: cacheType, AzureBlob: newTestOptions(ts.URL)}
+	c := New("test", &cacheConfig, tl.ConsoleLogger("error"))
+	if c.Prefix != "trickster/" {
+		t.Errorf("expected %s got %s", "trickster/", c.Prefix)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// it should store a value under the configured prefix
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected hit, got %s %s %v", string(data), ls, err)
+	}
+	keys, err := c.Keys("")
+	if err != nil || !reflect.DeepEqual(keys, []string{cacheKey}) {
+		t.Errorf("expected %v got %v %v", []string{cacheKey}, keys, err)
+	}
+}
+
+func TestAzureBlobClient_Connect(t *testing.T) {
+	_, _, closer := newTestClient(t)
+	closer()
+}
+
+func TestAzureBlobClient_ConnectSAS(t *testing.T) {
+	s, ts := newTestBlobService()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	c.Options.AccountName = ""
+	c.Options.AccountKey = ""
+	c.Options.SASToken = "?" + testSAS
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if _, ok := s.blobs[cacheKey]; !ok {
+		t.Error("expected blob")
+	}
+}
+
+func TestAzureBlobClient_ConnectEnvironmentCredentials(t *testing.T) {
+	_, ts := newTestBlobService()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	c.Options.AccountName = ""
+	c.Options.AccountKey = ""
+
+	os.Setenv("AZURE_STORAGE_ACCOUNT", testAccount)
+	os.Setenv("AZURE_STORAGE_KEY", testKey)
+	defer func() {
+		os.Unsetenv("AZURE_STORAGE_ACCOUNT")
+		os.Unsetenv("AZURE_STORAGE_KEY")
+	}()
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+	if c.account != testAccount {
+		t.Errorf("expected %s got %s", testAccount, c.account)
+	}
+}
+
+func TestAzureBlobClient_ConnectFailed(t *testing.T) {
+	_, ts := newTestBlobService()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	c.Options.Container = "missing"
+	const expected = "azure blob container missing is not accessible: status 404"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+	c.Options.Container = testContainer
+
+	c.Options.AccountKey = "invalid"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for invalid account key")
+	}
+
+	c.Options.AccountKey = ""
+	if err := c.Connect(); err != ErrMissingCredentials {
+		t.Errorf("expected %v got %v", ErrMissingCredentials, err)
+	}
+
+	c.Options.SASToken = "%"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for invalid sas token")
+	}
+
+	c.Options.Endpoint = ""
+	c.Options.AccountName = ""
+	if err := c.Connect(); err != ErrMissingAccount {
+		t.Errorf("expected %v got %v", ErrMissingAccount, err)
+	}
+
+	c.Options.Endpoint = ":"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for invalid endpoint")
+	}
+}
+
+func TestAzureBlobClient_PutGet(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	// it should put a blob
+	exp := time.Unix(1577836800, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err != nil {
+		t.Error(err)
+	}
+	if b, ok := s.blobs[cacheKey]; !ok || b.expiration != "1577836800000" {
+		t.Errorf("expected blob with expiration, got %v", b)
+	}
+
+	// it should get a blob and its expiration
+	data, e, err := c.Get(cacheKey)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+	if !e.Equal(exp) {
+		t.Errorf("expected %v got %v", exp, e)
+	}
+
+	// it should not find a blob that was not put
+	if _, _, err = c.Get("missing"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+
+	// names are escaped in the blob URL
+	if err = c.Put("a key/with?chars", []byte("escaped"), exp); err != nil {
+		t.Error(err)
+	}
+	if data, _, _ = c.Get("a key/with?chars"); string(data) != "escaped" {
+		t.Errorf("expected %s got %s", "escaped", string(data))
+	}
+
+	// a blob without an expiration never expires
+	s.blobs["noexp"] = &testBlob{data: []byte("data")}
+	if _, e, err = c.Get("noexp"); err != nil || !e.IsZero() {
+		t.Errorf("expected zero expiration, got %v %v", e, err)
+	}
+}
+
+func TestAzureBlobClient_SetExpiration(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetExpiration(cacheKey, time.Unix(2, 0)); err != nil {
+		t.Error(err)
+	}
+	if b := s.blobs[cacheKey]; b.expiration != "2000" || string(b.data) != "data" {
+		t.Errorf("expected blob with new expiration, got %v", b)
+	}
+
+	// the expiration of a missing blob can't be set
+	if err := c.SetExpiration("missing", time.Unix(2, 0)); err == nil ||
+		err.Error() != "azure blob error BlobNotFound: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestAzureBlobClient_Delete(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.Delete(cacheKey); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := c.Get(cacheKey); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+	if err := c.Delete(cacheKey); err == nil ||
+		err.Error() != "azure blob error BlobNotFound: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestAzureBlobClient_List(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	for _, k := range []string{"a.dpc.1", "a.dpc.2", "a.dpc.3", "a.opc.1", "b.dpc.1"} {
+		s.blobs["trickster/"+k] = &testBlob{data: []byte("data")}
+	}
+	s.blobs["other/a.dpc.4"] = &testBlob{data: []byte("data")}
+
+	names, err := c.List("trickster/a.dpc.")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"trickster/a.dpc.1", "trickster/a.dpc.2", "trickster/a.dpc.3"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+
+	names, err = c.List("trickster/c.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no names got %v", names)
+	}
+
+	c.account = "invalid"
+	if _, err = c.List(""); err == nil ||
+		err.Error() != "azure blob error AuthenticationFailed: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestAzureBlobClient_Errors(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	closer()
+
+	// requests to an unavailable endpoint fail
+	exp := time.Unix(1, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, _, err := c.Get(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.Delete(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.SetExpiration(cacheKey, exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := c.List(""); err == nil {
+		t.Error("expected error")
+	}
+
+	// requests with invalid signatures are denied
+	c, _, closer = newTestClient(t)
+	defer closer()
+	c.key = []byte("invalid")
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil ||
+		err.Error() != "azure blob error AuthenticationFailed: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, _, err := c.Get(cacheKey); err == nil ||
+		err.Error() != "azure blob error AuthenticationFailed: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+
+	r, _ := http.NewRequest(http.MethodPut, "http://127.0.0.1:10000/"+testAccount+
+		"/trickster/a%20key?prefix=b&prefix=a&comp=list", strings.NewReader("data"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	r.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	r.Header.Set("X-Ms-Date", "Wed, 01 Jan 2020 00:00:00 GMT")
+	r.Header.Set(headerExpiration, "1577836800000")
+	r.Header.Set("X-Ms-Version", apiVersion)
+
+	const expected = "4z4UzL1bzXYrz7BUzSwIMlyial4YICniUBCxi14MJ2Y="
+	sig := base64.StdEncoding.EncodeToString(signature(r, testAccount, []byte("test-key")))
+	if sig != expected {
+		t.Errorf("expected %s got %s", expected, sig)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrMissingContainer is returned when no container is configured
+var ErrMissingContainer = errors.New("azure blob container must be provided")
+
+// ErrInvalidEndpoint is returned when the endpoint is not an http or https URL
+var ErrInvalidEndpoint = errors.New("azure blob endpoint must be an http or https URL")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("azure blob timeout_ms must be greater than 0")
+
+// Options is a collection of Configurations for storing cached data in an Azure Blob Storage
+// container
+type Options struct {
+	// Endpoint is the base URL of the storage account's Blob service. The default is the
+	// Azure endpoint of the AccountName
+	Endpoint string `toml:"endpoint"`
+	// AccountName is the name of the storage account. When empty, the AZURE_STORAGE_ACCOUNT
+	// environment variable is used
+	AccountName string `toml:"account_name"`
+	// AccountKey is the storage account's access key, with which requests are authorized by
+	// Shared Key. When empty, the AZURE_STORAGE_KEY environment variable is used
+	AccountKey string `toml:"account_key"`
+	// SASToken is a shared access signature that authorizes requests instead of the AccountKey
+	SASToken string `toml:"sas_token"`
+	// Container is the name of the container in which blobs are stored
+	Container string `toml:"container"`
+	// Prefix is prepended to the cache key to form the name of each blob
+	Prefix string `toml:"prefix"`
+	// TimeoutMS is the timeout of each request to the Blob service
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new Azure Blob Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Prefix:    d.DefaultAzureBlobPrefix,
+		TimeoutMS: d.DefaultAzureBlobTimeoutMS,
+		Timeout:   time.Duration(d.DefaultAzureBlobTimeoutMS) * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if o.Container == "" {
+		return ErrMissingContainer
+	}
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
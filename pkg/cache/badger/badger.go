@@ -38,6 +38,10 @@ type Cache struct {
 	locker locks.NamedLocker
 
 	dbh *badger.DB
+
+	// value log garbage collection
+	gcQuit chan struct{}
+	gcDone chan struct{}
 }
 
 // Locker returns the cache's locker
@@ -61,6 +65,9 @@ func (c *Cache) Connect() error {
 
 	opts := badger.DefaultOptions(c.Config.Badger.Directory)
 	opts.ValueDir = c.Config.Badger.ValueDirectory
+	if c.Config.Badger.ValueLogFileSizeBytes > 0 {
+		opts.ValueLogFileSize = c.Config.Badger.ValueLogFileSizeBytes
+	}
 
 	var err error
 	c.dbh, err = badger.Open(opts)
@@ -68,11 +75,59 @@ func (c *Cache) Connect() error {
 		return err
 	}
 
+	if c.Config.Badger.ValueLogGCInterval > 0 {
+		c.gcQuit = make(chan struct{})
+		c.gcDone = make(chan struct{})
+		go c.valueLogGC(c.Config.Badger.ValueLogGCInterval)
+	}
+
 	return nil
 }
 
+// valueLogGC garbage collects the value log at the provided interval until the cache is closed
+func (c *Cache) valueLogGC(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer func() {
+		ticker.Stop()
+		close(c.gcDone)
+	}()
+	for {
+		select {
+		case <-c.gcQuit:
+			return
+		case <-ticker.C:
+			c.runValueLogGC()
+		}
+	}
+}
+
+// runValueLogGC rewrites value log files until none has enough discardable space to be
+// reclaimed, and returns the number of files rewritten
+func (c *Cache) runValueLogGC() int {
+	var n int
+	for {
+		err := c.dbh.RunValueLogGC(c.Config.Badger.ValueLogGCRatio)
+		if err != nil {
+			if err != badger.ErrNoRewrite && err != badger.ErrRejected {
+				c.Logger.Warn("badger value log gc failed", log.Pairs{"reason": err.Error()})
+			}
+			break
+		}
+		n++
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "value_log_gc", "rewrite")
+	}
+	_, vlog := c.dbh.Size()
+	c.Logger.Debug("badger value log gc", log.Pairs{"rewrites": n, "valueLogBytes": vlog})
+	return n
+}
+
 // Store places the the data into the Badger Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
 	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 	c.Logger.Debug("badger cache store", log.Pairs{"key": cacheKey, "ttl": ttl})
 	return c.dbh.Update(func(txn *badger.Txn) error {
@@ -90,6 +145,10 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 			return err
 		}
 		data, err = item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		data, err = cache.DecodeValue(c.Config, cacheKey, data)
 		return err
 
 	})
@@ -136,13 +195,36 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	})
 }
 
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	var keys []string
+	err := c.dbh.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		p := []byte(prefix)
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			keys = append(keys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	return keys, err
+}
+
 // Close closes the Badger Cache
 func (c *Cache) Close() error {
+	if c.gcQuit != nil {
+		close(c.gcQuit)
+		<-c.gcDone
+		c.gcQuit = nil
+	}
 	return c.dbh.Close()
 }
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	var data []byte
 	err := c.dbh.Update(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(cacheKey))
@@ -63,6 +63,38 @@ func TestBadgerCache_Connect(t *testing.T) {
 	bc.Close()
 }
 
+func TestBadgerCache_ValueLogGC(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Badger.Directory)
+	cacheConfig.Badger.ValueLogGCRatio = 0.5
+	cacheConfig.Badger.ValueLogGCInterval = 10 * time.Millisecond
+	cacheConfig.Badger.ValueLogFileSizeBytes = 1 << 20
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if bc.gcQuit == nil {
+		t.Error("expected value log gc to start")
+	}
+
+	// a cache with nothing to reclaim rewrites no files
+	if n := bc.runValueLogGC(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	done := bc.gcDone
+	if err := bc.Close(); err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("expected value log gc to stop")
+	}
+}
+
 func TestBadgerCache_ConnectFailed(t *testing.T) {
 	cacheConfig := newCacheConfig(t)
 	cacheConfig.Badger.Directory = "/root/trickster-test-noaccess"
@@ -284,3 +316,28 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestBadgerCache_Keys(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Badger.Directory)
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Error(err)
+	}
+	defer bc.Close()
+
+	for _, k := range []string{"a.dpc.1", "a.opc.1", "b.dpc.1"} {
+		if err := bc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+
+	keys, err := bc.Keys("a.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 2 || keys[0] != "a.dpc.1" || keys[1] != "a.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a.dpc.1", "a.opc.1"}, keys)
+	}
+}
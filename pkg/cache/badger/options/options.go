@@ -17,18 +17,78 @@
 package options
 
 import (
+	"errors"
+	"time"
+
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 )
 
+// ErrInvalidValueLogGCRatio is returned when the value log GC ratio is not between 0 and 1
+var ErrInvalidValueLogGCRatio = errors.New(
+	"badger value_log_gc_ratio must be greater than 0 and less than 1")
+
+// ErrInvalidValueLogGCInterval is returned when the value log GC interval is negative
+var ErrInvalidValueLogGCInterval = errors.New(
+	"badger value_log_gc_interval_secs must not be negative")
+
+// ErrInvalidValueLogFileSize is returned when the value log file size is out of Badger's range
+var ErrInvalidValueLogFileSize = errors.New(
+	"badger value_log_file_size_bytes must be 0, or at least 1MB and less than 2GB")
+
+// value log file size limits enforced by Badger
+const (
+	minValueLogFileSize = 1 << 20
+	maxValueLogFileSize = 2 << 30
+)
+
 // Options is a collection of Configurations for storing cached data on the Filesystem in a Badger key-value store
 type Options struct {
 	// Directory represents the path on disk where the Badger database should store data
 	Directory string `toml:"directory"`
 	// ValueDirectory represents the path on disk where the Badger database will store its value log.
 	ValueDirectory string `toml:"value_directory"`
+	// ValueLogGCIntervalSecs is how often the value log is garbage collected, to reclaim the
+	// space of expired and removed values. 0 disables value log garbage collection
+	ValueLogGCIntervalSecs int `toml:"value_log_gc_interval_secs"`
+	// ValueLogGCRatio is the fraction of a value log file that must be discardable for the file
+	// to be rewritten during garbage collection
+	ValueLogGCRatio float64 `toml:"value_log_gc_ratio"`
+	// ValueLogFileSizeBytes is the maximum size of each value log file. Smaller files are
+	// reclaimed sooner by garbage collection. 0 uses Badger's default of 1GB
+	ValueLogFileSizeBytes int64 `toml:"value_log_file_size_bytes"`
+
+	// ValueLogGCInterval is the time.Duration representation of ValueLogGCIntervalSecs
+	ValueLogGCInterval time.Duration `toml:"-"`
 }
 
 // NewOptions returns a reference to a new Badger Options
 func NewOptions() *Options {
-	return &Options{Directory: d.DefaultCachePath, ValueDirectory: d.DefaultCachePath}
+	o := &Options{
+		Directory:              d.DefaultCachePath,
+		ValueDirectory:         d.DefaultCachePath,
+		ValueLogGCIntervalSecs: d.DefaultBadgerValueLogGCIntervalSecs,
+		ValueLogGCRatio:        d.DefaultBadgerValueLogGCRatio,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.ValueLogGCRatio <= 0 || o.ValueLogGCRatio >= 1 {
+		return ErrInvalidValueLogGCRatio
+	}
+	if o.ValueLogGCIntervalSecs < 0 {
+		return ErrInvalidValueLogGCInterval
+	}
+	if o.ValueLogFileSizeBytes != 0 && (o.ValueLogFileSizeBytes < minValueLogFileSize ||
+		o.ValueLogFileSizeBytes >= maxValueLogFileSize) {
+		return ErrInvalidValueLogFileSize
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the Options' intervals
+func (o *Options) SetDurations() {
+	o.ValueLogGCInterval = time.Duration(o.ValueLogGCIntervalSecs) * time.Second
 }
@@ -24,3 +24,34 @@ func TestNewOptions(t *testing.T) {
 		t.Error("expected non-nil options")
 	}
 }
+
+func TestValidate(t *testing.T) {
+
+	o := NewOptions()
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	o.ValueLogGCRatio = 1
+	if err := o.Validate(); err != ErrInvalidValueLogGCRatio {
+		t.Errorf("expected %v got %v", ErrInvalidValueLogGCRatio, err)
+	}
+
+	o.ValueLogGCRatio = 0.5
+	o.ValueLogGCIntervalSecs = -1
+	if err := o.Validate(); err != ErrInvalidValueLogGCInterval {
+		t.Errorf("expected %v got %v", ErrInvalidValueLogGCInterval, err)
+	}
+
+	o.ValueLogGCIntervalSecs = 0
+	o.ValueLogFileSizeBytes = 1024
+	if err := o.Validate(); err != ErrInvalidValueLogFileSize {
+		t.Errorf("expected %v got %v", ErrInvalidValueLogFileSize, err)
+	}
+
+	o.ValueLogFileSizeBytes = 64 << 20
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+}
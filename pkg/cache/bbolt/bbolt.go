@@ -19,10 +19,12 @@ package bbolt
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	bo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/index"
 	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	"github.com/tricksterproxy/trickster/pkg/cache/options"
@@ -43,6 +45,11 @@ type Cache struct {
 	lockPrefix string
 
 	dbh *bbolt.DB
+	// dbMtx is held for writing while the database is swapped with its compacted copy
+	dbMtx sync.RWMutex
+	// writeMtx is held for writing while the database is compacted, so that objects are not
+	// written to or removed from the database while it is copied
+	writeMtx sync.RWMutex
 }
 
 // Locker returns the cache's locker
@@ -72,13 +79,7 @@ func (c *Cache) Connect() error {
 		return err
 	}
 
-	err = c.dbh.Update(func(tx *bbolt.Tx) error {
-		_, err2 := tx.CreateBucketIfNotExists([]byte(c.Config.BBolt.Bucket))
-		if err2 != nil {
-			return fmt.Errorf("create bucket: %s", err2)
-		}
-		return nil
-	})
+	err = createBuckets(c.dbh, c.Config.BBolt)
 	if err != nil {
 		return err
 	}
@@ -92,6 +93,11 @@ func (c *Cache) Connect() error {
 
 // Store places an object in the cache using the specified key and ttl
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
 	return c.store(cacheKey, data, ttl, true)
 }
 
@@ -110,7 +116,11 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 
 	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
-	err := writeToBBolt(c.dbh, c.Config.BBolt.Bucket, cacheKey, o.ToBytes())
+	c.writeMtx.RLock()
+	c.dbMtx.RLock()
+	err := writeToBBolt(c.dbh, bucketOf(c.Config.BBolt, cacheKey), cacheKey, o.ToBytes())
+	c.dbMtx.RUnlock()
+	c.writeMtx.RUnlock()
 	nl.Release()
 	if err != nil {
 		return err
@@ -122,6 +132,37 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 	return nil
 }
 
+// createBuckets creates the bucket and the origin buckets of the options in the database,
+// if they do not exist
+func createBuckets(dbh *bbolt.DB, o *bo.Options) error {
+	return dbh.Update(func(tx *bbolt.Tx) error {
+		names := []string{o.Bucket}
+		for _, name := range o.OriginBuckets {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create bucket: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+// bucketOf returns the name of the bucket in which the object with the key is stored,
+// which is that of the origin with the longest cache key prefix of the key, if any
+func bucketOf(o *bo.Options, cacheKey string) string {
+	var prefix string
+	bucket := o.Bucket
+	for k, v := range o.OriginBuckets {
+		if len(k) > len(prefix) && strings.HasPrefix(cacheKey, k+".") {
+			prefix = k
+			bucket = v
+		}
+	}
+	return bucket
+}
+
 func writeToBBolt(dbh *bbolt.DB, bucketName, cacheKey string, data []byte) error {
 	err := dbh.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(bucketName))
@@ -133,7 +174,14 @@ func writeToBBolt(dbh *bbolt.DB, bucketName, cacheKey string, data []byte) error
 
 // Retrieve looks for an object in cache and returns it (or an error if not found)
 func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
-	return c.retrieve(cacheKey, allowExpired, true)
+	data, lookupStatus, err := c.retrieve(cacheKey, allowExpired, true)
+	if err != nil {
+		return data, lookupStatus, err
+	}
+	if data, err = cache.DecodeValue(c.Config, cacheKey, data); err != nil {
+		return nil, status.LookupStatusError, err
+	}
+	return data, lookupStatus, nil
 }
 
 func (c *Cache) retrieve(cacheKey string, allowExpired bool,
@@ -141,9 +189,14 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 
 	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
 	var data []byte
+	c.dbMtx.RLock()
 	err := c.dbh.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
-		data = b.Get([]byte(cacheKey))
+		b := tx.Bucket([]byte(bucketOf(c.Config.BBolt, cacheKey)))
+		// the value is only valid for the life of the transaction
+		if v := b.Get([]byte(cacheKey)); v != nil {
+			data = make([]byte, len(v))
+			copy(data, v)
+		}
 		if data == nil {
 			c.Logger.Debug("bbolt cache miss", log.Pairs{"key": cacheKey})
 			metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
@@ -151,6 +204,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 		}
 		return nil
 	})
+	c.dbMtx.RUnlock()
 	nl.RRelease()
 	if err != nil {
 		return nil, status.LookupStatusKeyMiss, err
@@ -174,7 +228,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 	if allowExpired || o.Expiration.IsZero() || o.Expiration.After(time.Now()) {
 		c.Logger.Debug("bbolt cache retrieve", log.Pairs{"cacheKey": cacheKey})
 		if atime {
-			go c.Index.UpdateObjectAccessTime(cacheKey)
+			c.Index.QueueObjectAccess(cacheKey)
 		}
 		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return o.Value, status.LookupStatusHit, nil
@@ -187,6 +241,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
 }
 
@@ -197,10 +252,14 @@ func (c *Cache) Remove(cacheKey string) {
 
 func (c *Cache) remove(cacheKey string, isBulk bool) error {
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	c.writeMtx.RLock()
+	c.dbMtx.RLock()
 	err := c.dbh.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
+		b := tx.Bucket([]byte(bucketOf(c.Config.BBolt, cacheKey)))
 		return b.Delete([]byte(cacheKey))
 	})
+	c.dbMtx.RUnlock()
+	c.writeMtx.RUnlock()
 	nl.Release()
 	if err != nil {
 		c.Logger.Error("bbolt cache key delete failure",
@@ -215,6 +274,33 @@ func (c *Cache) remove(cacheKey string, isBulk bool) error {
 	return nil
 }
 
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	return c.Index.Keys(prefix), nil
+}
+
+// Inspect returns the statistics of the cached objects, including the topN largest and
+// most-accessed objects
+func (c *Cache) Inspect(topN int) *cache.Stats {
+	return c.Index.Stats(topN, time.Now())
+}
+
+// InspectObject returns the statistics of the object cached at the key, or nil if the key
+// is not cached
+func (c *Cache) InspectObject(cacheKey string) *cache.ObjectStats {
+	return c.Index.ObjectStats(cacheKey, time.Now())
+}
+
+// SetTags associates the object cached at the key with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	c.Index.SetTags(cacheKey, tags)
+}
+
+// TaggedKeys returns the keys of the cached objects that are associated with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	return c.Index.TaggedKeys(tag), nil
+}
+
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg := &sync.WaitGroup{}
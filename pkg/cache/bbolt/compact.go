@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bbolt
+
+import (
+	"os"
+	"time"
+
+	bo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/coreos/bbolt"
+)
+
+// compactTxMaxBytes is the most key and value bytes copied into the compacted database
+// in a single transaction
+const compactTxMaxBytes = 64 << 20
+
+// compactSuffix is appended to the database filename to name its compacted copy
+const compactSuffix = ".compact"
+
+// Compact rewrites the cache's database file without its free pages, and returns its size in
+// bytes before and after compaction. Objects are moved to their origin's bucket as they are
+// copied. The cache continues to serve objects while it is compacted, but objects are not
+// stored or removed until the compacted database is in place
+func (c *Cache) Compact() (int64, int64, error) {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+
+	start := time.Now()
+	fn := c.Config.BBolt.Filename
+	before, after, err := compactTo(c.dbh, fn+compactSuffix, c.Config.BBolt)
+	if err != nil {
+		os.Remove(fn + compactSuffix)
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "compaction", "failed")
+		c.Logger.Error("bbolt cache compaction failed",
+			log.Pairs{"cacheName": c.Name, "reason": err.Error()})
+		return before, before, err
+	}
+
+	// reads are held only while the database is swapped with its compacted copy
+	c.dbMtx.Lock()
+	defer c.dbMtx.Unlock()
+	if err = c.dbh.Close(); err == nil {
+		err = os.Rename(fn+compactSuffix, fn)
+	}
+	c.dbh, err = reopen(fn, err)
+	if err != nil {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "compaction", "failed")
+		c.Logger.Error("bbolt cache compaction failed",
+			log.Pairs{"cacheName": c.Name, "reason": err.Error()})
+		return before, before, err
+	}
+
+	metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "compaction", "completed")
+	c.Logger.Info("bbolt cache compacted", log.Pairs{"cacheName": c.Name,
+		"bytesBefore": before, "bytesAfter": after, "elapsed": time.Since(start).String()})
+	return before, after, nil
+}
+
+// reopen opens the database file after it is swapped with its compacted copy, and returns
+// any error that occurred during the swap, since the file is unchanged when it fails
+func reopen(fn string, swapErr error) (*bbolt.DB, error) {
+	dbh, err := bbolt.Open(fn, 0644, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return dbh, swapErr
+}
+
+// CompactFile rewrites the bbolt database file of the options without its free pages, and
+// returns its size in bytes before and after compaction. Objects are moved to their origin's
+// bucket as they are copied. The file must not be in use by a running cache
+func CompactFile(o *bo.Options) (int64, int64, error) {
+	src, err := bbolt.Open(o.Filename, 0644, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return 0, 0, err
+	}
+	before, after, err := compactTo(src, o.Filename+compactSuffix, o)
+	if cerr := src.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(o.Filename+compactSuffix, o.Filename)
+	}
+	if err != nil {
+		os.Remove(o.Filename + compactSuffix)
+		return before, before, err
+	}
+	return before, after, nil
+}
+
+// compactTo copies every bucket of the src database into a new database file at dst, placing
+// each object of the options' buckets in the bucket of its key, and returns the sizes of the
+// src and dst files
+func compactTo(src *bbolt.DB, dst string, o *bo.Options) (int64, int64, error) {
+	fi, err := os.Stat(src.Path())
+	if err != nil {
+		return 0, 0, err
+	}
+	before := fi.Size()
+
+	os.Remove(dst)
+	dbh, err := bbolt.Open(dst, 0644, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return before, 0, err
+	}
+	if err = copyBuckets(dbh, src, o); err != nil {
+		dbh.Close()
+		return before, 0, err
+	}
+	if err = dbh.Close(); err != nil {
+		return before, 0, err
+	}
+
+	if fi, err = os.Stat(dst); err != nil {
+		return before, 0, err
+	}
+	return before, fi.Size(), nil
+}
+
+// copyBuckets copies the buckets of src into dst in transactions of up to compactTxMaxBytes.
+// The objects of the options' buckets are placed in the bucket of their key, and the objects
+// of any other bucket are copied to the bucket of the same name
+func copyBuckets(dst, src *bbolt.DB, o *bo.Options) error {
+
+	managed := map[string]bool{o.Bucket: true}
+	for _, name := range o.OriginBuckets {
+		managed[name] = true
+	}
+	if err := createBuckets(dst, o); err != nil {
+		return err
+	}
+
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+	var size int64
+
+	err = src.View(func(stx *bbolt.Tx) error {
+		return stx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				// nested buckets are not used by the cache, so they are not copied
+				if v == nil {
+					return nil
+				}
+				if size+int64(len(k)+len(v)) > compactTxMaxBytes {
+					if err := tx.Commit(); err != nil {
+						return err
+					}
+					var err error
+					if tx, err = dst.Begin(true); err != nil {
+						return err
+					}
+					size = 0
+				}
+				bucket := string(name)
+				if managed[bucket] {
+					bucket = bucketOf(o, string(k))
+				}
+				db, err := tx.CreateBucketIfNotExists([]byte(bucket))
+				if err != nil {
+					return err
+				}
+				size += int64(len(k) + len(v))
+				return db.Put(k, v)
+			})
+		})
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bbolt
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	bo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestBucketOf(t *testing.T) {
+	o := &bo.Options{Bucket: "trickster", OriginBuckets: map[string]string{
+		"prom": "trickster.prom", "prom.eu": "trickster.prom-eu"}}
+	tests := []struct {
+		key, expected string
+	}{
+		{"prom.dpc.1", "trickster.prom"},
+		{"prom.eu.dpc.1", "trickster.prom-eu"},
+		{"prometheus.dpc.1", "trickster"},
+		{"cache.index", "trickster"},
+	}
+	for _, test := range tests {
+		if b := bucketOf(o, test.key); b != test.expected {
+			t.Errorf("expected %s got %s for %s", test.expected, b, test.key)
+		}
+	}
+}
+
+func TestBboltCache_Compact(t *testing.T) {
+
+	const testDbPath = "/tmp/test-compact.db"
+	os.Remove(testDbPath)
+	defer os.Remove(testDbPath)
+
+	cacheConfig := co.Options{CacheType: cacheType, BBolt: &bo.Options{
+		Filename: testDbPath, Bucket: "trickster_test"}, Index: &io.Options{ReapInterval: time.Second}}
+	bc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// removed objects leave free pages in the database file
+	value := make([]byte, 8192)
+	for i := 0; i < 200; i++ {
+		if err := bc.Store("removed."+strconv.Itoa(i), value, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		bc.Remove("removed." + strconv.Itoa(i))
+	}
+	if err := bc.Store("prom.dpc.1", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	// the object stored before the origin had its own bucket is moved to it by compaction
+	cacheConfig.BBolt.OriginBuckets = map[string]string{"prom": "trickster_test.prom"}
+	bc = &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	if _, _, err := bc.Retrieve("prom.dpc.1", false); err == nil {
+		t.Error("expected key not found error")
+	}
+
+	before, after, err := bc.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("expected compacted size < %d got %d", before, after)
+	}
+	if _, err = os.Stat(testDbPath + compactSuffix); !os.IsNotExist(err) {
+		t.Error("expected the compacted copy to be renamed")
+	}
+
+	data, _, err := bc.Retrieve("prom.dpc.1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+
+	// the compacted database is writable
+	if err = bc.Store("prom.dpc.2", []byte("data2"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if data, _, err = bc.Retrieve("prom.dpc.2", false); err != nil || string(data) != "data2" {
+		t.Errorf("expected %s got %s", "data2", string(data))
+	}
+}
+
+func TestCompactFile(t *testing.T) {
+
+	const testDbPath = "/tmp/test-compact-file.db"
+	os.Remove(testDbPath)
+	defer os.Remove(testDbPath)
+
+	o := &bo.Options{Filename: testDbPath, Bucket: "trickster_test"}
+	cacheConfig := co.Options{CacheType: cacheType, BBolt: o,
+		Index: &io.Options{ReapInterval: time.Second}}
+	bc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Store("prom.dpc.1", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	bc.Close()
+
+	o.OriginBuckets = map[string]string{"prom": "trickster_test.prom"}
+	if _, _, err := CompactFile(o); err != nil {
+		t.Fatal(err)
+	}
+
+	bc = &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	data, _, err := bc.Retrieve("prom.dpc.1", false)
+	if err != nil || string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+
+	if _, _, err = CompactFile(&bo.Options{Filename: "/nonexistent/dir/test.db"}); err == nil {
+		t.Error("expected error for invalid filename")
+	}
+}
@@ -26,6 +26,13 @@ type Options struct {
 	Filename string `toml:"filename"`
 	// Bucket represents the name of the bucket within BBolt under which Trickster's keys will be stored.
 	Bucket string `toml:"bucket"`
+	// BucketPerOrigin, when true, stores the objects of each origin that uses the cache in its
+	// own bucket, named for the Bucket and the origin, rather than in the Bucket
+	BucketPerOrigin bool `toml:"bucket_per_origin"`
+
+	// OriginBuckets maps the cache key prefix of each origin that uses the cache to the name
+	// of its bucket, when BucketPerOrigin is true. It is set when the config is loaded
+	OriginBuckets map[string]string `toml:"-"`
 }
 
 // NewOptions returns a reference to a new bbolt Options
@@ -20,8 +20,12 @@ package cache
 
 import (
 	"errors"
+	"io"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/compression"
+	"github.com/tricksterproxy/trickster/pkg/cache/encryption"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	"github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
@@ -31,7 +35,8 @@ import (
 var ErrKNF = errors.New("key not found in cache")
 
 // Cache is the interface for the supported caching fabrics
-// When making new cache types, Retrieve() must return an error on cache miss
+// When making new cache types, Retrieve() must return an error on cache miss,
+// and Store() must not retain data after returning, since callers may reuse it
 type Cache interface {
 	Connect() error
 	Store(cacheKey string, data []byte, ttl time.Duration) error
@@ -62,8 +67,116 @@ type MemoryCache interface {
 	SetLocker(locks.NamedLocker)
 }
 
+// Lister is implemented by caches that can enumerate the keys of their objects, which is
+// required to invalidate objects by key prefix rather than by exact key
+type Lister interface {
+	// Keys returns the keys of the cached objects that begin with prefix
+	Keys(prefix string) ([]string, error)
+}
+
+// Tagger is implemented by caches that can associate their objects with cache tags, which
+// is required to invalidate groups of objects by tag
+type Tagger interface {
+	// SetTags associates the object cached at the key with the provided tags
+	SetTags(cacheKey string, tags []string)
+	// TaggedKeys returns the keys of the cached objects that are associated with tag
+	TaggedKeys(tag string) ([]string, error)
+}
+
+// BulkRetriever is implemented by caches that can retrieve multiple objects in fewer
+// round trips than retrieving them one at a time
+type BulkRetriever interface {
+	// BulkRetrieve returns the objects cached at the provided keys, keyed by cache key.
+	// Keys that are not found in the cache are absent from the result
+	BulkRetrieve(cacheKeys []string) (map[string][]byte, error)
+}
+
+// BulkStorer is implemented by caches that can store multiple objects in fewer
+// round trips than storing them one at a time
+type BulkStorer interface {
+	// BulkStore places the provided objects, keyed by cache key, in the cache with the ttl
+	BulkStore(objects map[string][]byte, ttl time.Duration) error
+}
+
+// Streamer is implemented by caches that can store and retrieve objects as streams, so
+// that large objects are written and read in chunks rather than held in memory in full
+type Streamer interface {
+	// StoreStream places the object read from r in the cache using the specified key and ttl
+	StoreStream(cacheKey string, r io.Reader, ttl time.Duration) error
+	// RetrieveStream writes the object cached at the key to w. As with Retrieve, an error is
+	// returned on cache miss, in which case nothing is written to w
+	RetrieveStream(cacheKey string, allowExpired bool, w io.Writer) (status.LookupStatus, error)
+}
+
+// Compactor is implemented by caches whose storage can be compacted to reclaim the space
+// that was used by removed objects
+type Compactor interface {
+	// Compact rewrites the cache's storage without its free space, and returns its size in
+	// bytes before and after compaction
+	Compact() (int64, int64, error)
+}
+
 // ReferenceObject defines an interface for a cache object possessing the ability to report
 // the approximate comprehensive byte size of its members, to assist with cache size management
 type ReferenceObject interface {
 	Size() int
 }
+
+// ClampTTL returns the ttl bounded by the cache's configured minimum and maximum TTLs,
+// and records a cache event when the ttl is clamped
+func ClampTTL(o *options.Options, ttl time.Duration) time.Duration {
+	switch {
+	case o.MaxTTL > 0 && ttl > o.MaxTTL:
+		metrics.ObserveCacheEvent(o.Name, o.CacheType, "ttl_clamp", "max_ttl")
+		return o.MaxTTL
+	case o.MinTTL > 0 && ttl < o.MinTTL:
+		metrics.ObserveCacheEvent(o.Name, o.CacheType, "ttl_clamp", "min_ttl")
+		return o.MinTTL
+	}
+	return ttl
+}
+
+// Compress returns data encoded with the cache's configured compression codec, for storage.
+// data is returned unmodified when the cache is not configured for compression
+func Compress(o *options.Options, data []byte) []byte {
+	if o.CompressionCodec == "" || data == nil {
+		return data
+	}
+	return compression.Encode(compression.Names[o.CompressionCodec], data)
+}
+
+// Decompress returns the value of data retrieved from the cache, which was encoded by Compress.
+// data is returned unmodified when the cache is not configured for compression
+func Decompress(o *options.Options, data []byte) ([]byte, error) {
+	if o.CompressionCodec == "" || data == nil {
+		return data, nil
+	}
+	b, err := compression.Decode(data)
+	if err != nil {
+		metrics.ObserveCacheEvent(o.Name, o.CacheType, "decompress", "failed")
+	}
+	return b, err
+}
+
+// EncodeValue returns data compressed and encrypted according to the cache's options, for
+// storage in a persistent cache
+func EncodeValue(o *options.Options, cacheKey string, data []byte) ([]byte, error) {
+	data = Compress(o, data)
+	if o.Encryption == nil || !o.Encryption.Enabled() || data == nil {
+		return data, nil
+	}
+	return encryption.Seal(o.Encryption, cacheKey, data)
+}
+
+// DecodeValue returns the value of data retrieved from a persistent cache, which was encoded
+// by EncodeValue
+func DecodeValue(o *options.Options, cacheKey string, data []byte) ([]byte, error) {
+	if o.Encryption != nil && o.Encryption.Enabled() && data != nil {
+		var err error
+		if data, err = encryption.Open(o.Encryption, cacheKey, data); err != nil {
+			metrics.ObserveCacheEvent(o.Name, o.CacheType, "decrypt", "failed")
+			return nil, err
+		}
+	}
+	return Decompress(o, data)
+}
@@ -0,0 +1,596 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cassandra is the Cassandra implementation of the Trickster Cache, which also
+// supports ScyllaDB. It speaks the CQL native protocol, stores objects as rows of a table
+// with Cassandra's native per-row TTLs, and routes each request to the node that owns the
+// object's token, so that a very large cache can be shared by every Trickster in a deployment
+package cassandra
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	co "github.com/tricksterproxy/trickster/pkg/cache/cassandra/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const (
+	// pipelineBatchSize is the most requests sent to a node in each pipeline of a bulk
+	// operation, which bounds the streams in use on a connection
+	pipelineBatchSize = 100
+	// maxTTL is the longest TTL accepted by Cassandra, which is 20 years
+	maxTTL = 630720000 * time.Second
+	// murmur3Partitioner is the only partitioner whose tokens are computed by the cache
+	murmur3Partitioner = "org.apache.cassandra.dht.Murmur3Partitioner"
+	// consistencyOne is the consistency level of the queries of the system tables, which
+	// are local to the node
+	consistencyOne = 0x0001
+)
+
+// ErrNotConnected is returned when the cache is used before it is connected
+var ErrNotConnected = errors.New("cassandra cache is not connected")
+
+// Cache represents a Cassandra cache object that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	locker locks.NamedLocker
+
+	// hosts are the nodes to which requests are sent, with the nodes of the local data
+	// center first
+	hosts []*host
+	// ring is the cluster's token ring, which is nil when requests are not token-aware
+	ring *ring
+	// next is the index of the host of the next request that is not token-aware
+	next uint32
+
+	readConsistency  uint16
+	writeConsistency uint16
+	selectStmt       string
+	insertStmt       string
+	deleteStmt       string
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect connects to the first reachable contact point, verifies that the cache's table
+// exists, and discovers the cluster's nodes and token ring when requests are token-aware
+func (c *Cache) Connect() error {
+	o := c.Config.Cassandra
+	c.Logger.Info("connecting to cassandra", tl.Pairs{"hosts": o.Hosts, "tls": o.UseTLS,
+		"keyspace": o.Keyspace, "table": o.Table})
+
+	var tc *tls.Config
+	if o.UseTLS {
+		var err error
+		if tc, err = c.tlsConfig(); err != nil {
+			return err
+		}
+	}
+
+	c.readConsistency = co.ConsistencyLevels[o.ReadConsistency]
+	c.writeConsistency = co.ConsistencyLevels[o.WriteConsistency]
+	table := o.Keyspace + "." + o.Table
+	c.selectStmt = "SELECT value FROM " + table + " WHERE key = ?"
+	c.insertStmt = "INSERT INTO " + table + " (key, value) VALUES (?, ?) USING TTL ?"
+	c.deleteStmt = "DELETE FROM " + table + " WHERE key = ?"
+
+	newHost := func(addr string) *host {
+		return &host{addr: addr, tlsConfig: tc, username: o.Username, password: o.Password,
+			timeout: o.Timeout, statements: []string{c.selectStmt, c.insertStmt, c.deleteStmt},
+			idle: make(chan *conn, o.MaxIdleConns)}
+	}
+
+	var control *host
+	var cn *conn
+	var err error
+	for _, addr := range o.Hosts {
+		h := newHost(addr)
+		if cn, err = h.get(); err == nil {
+			control = h
+			break
+		}
+		c.Logger.Warn("could not connect to cassandra host",
+			tl.Pairs{"host": addr, "reason": err.Error()})
+	}
+	if control == nil {
+		return fmt.Errorf("could not connect to cassandra: %s", err.Error())
+	}
+	defer control.put(cn)
+
+	c.hosts = []*host{control}
+	if o.TokenAware {
+		hosts, r, err := discover(cn, control, newHost)
+		if err != nil {
+			c.Logger.Warn("cassandra token-aware routing is disabled",
+				tl.Pairs{"reason": err.Error()})
+		} else {
+			c.hosts = hosts
+			c.ring = r
+		}
+	} else {
+		for _, addr := range o.Hosts[1:] {
+			c.hosts = append(c.hosts, newHost(addr))
+		}
+	}
+
+	if o.LocalDC != "" {
+		local := make([]*host, 0, len(c.hosts))
+		var remote []*host
+		for _, h := range c.hosts {
+			if h.dc == o.LocalDC {
+				local = append(local, h)
+			} else {
+				remote = append(remote, h)
+			}
+		}
+		c.hosts = append(local, remote...)
+	}
+
+	c.Logger.Info("connected to cassandra", tl.Pairs{"nodes": len(c.hosts),
+		"tokenAware": c.ring != nil})
+	return nil
+}
+
+// discover returns the nodes of the cluster and its token ring, from the system tables of
+// the control connection's node
+func discover(cn *conn, control *host, newHost func(string) *host) ([]*host, *ring, error) {
+	rows, err := cn.query("SELECT data_center, tokens, partitioner FROM system.local",
+		consistencyOne)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) != 1 {
+		return nil, nil, errors.New("could not read system.local")
+	}
+	if p := string(rows[0][2]); p != murmur3Partitioner {
+		return nil, nil, fmt.Errorf("unsupported partitioner %s", p)
+	}
+	control.dc = string(rows[0][0])
+	tokens := make(map[int64]*host)
+	if err = addTokens(tokens, control, rows[0][1]); err != nil {
+		return nil, nil, err
+	}
+	hosts := []*host{control}
+
+	_, port, _ := net.SplitHostPort(control.addr)
+	// system.peers_v2, added in Cassandra 4.0, includes the peers' native ports, which
+	// system.peers does not, so the control node's port is assumed for each peer
+	rows, err = cn.query(
+		"SELECT native_address, native_port, data_center, tokens FROM system.peers_v2",
+		consistencyOne)
+	if _, ok := err.(*cqlError); ok {
+		rows, err = cn.query("SELECT rpc_address, data_center, tokens FROM system.peers",
+			consistencyOne)
+		for i, row := range rows {
+			rows[i] = [][]byte{row[0], nil, row[1], row[2]}
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		if len(row[0]) != net.IPv4len && len(row[0]) != net.IPv6len {
+			continue
+		}
+		p := port
+		if len(row[1]) == 4 {
+			p = strconv.Itoa(int(int32(uint32(row[1][0])<<24 | uint32(row[1][1])<<16 |
+				uint32(row[1][2])<<8 | uint32(row[1][3]))))
+		}
+		h := newHost(net.JoinHostPort(net.IP(row[0]).String(), p))
+		h.dc = string(row[2])
+		if err = addTokens(tokens, h, row[3]); err != nil {
+			return nil, nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, newRing(tokens), nil
+}
+
+// addTokens adds the tokens of a node, which are a set of their decimal representations
+func addTokens(tokens map[int64]*host, h *host, b []byte) error {
+	for _, s := range decodeSet(b) {
+		t, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid token %s of node %s", s, h.addr)
+		}
+		tokens[t] = h
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of connections to the nodes
+func (c *Cache) tlsConfig() (*tls.Config, error) {
+	o := c.Config.Cassandra
+	tc := &tls.Config{ServerName: o.ServerName, InsecureSkipVerify: o.InsecureSkipVerify}
+	if len(o.CertificateAuthorityPaths) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range o.CertificateAuthorityPaths {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("unable to append to CA Certs from file %s", path)
+			}
+		}
+		tc.RootCAs = pool
+	}
+	if o.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertPath, o.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Store places the the data into Cassandra using the provided Key and TTL
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	c.Logger.Debug("cassandra cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
+	return c.store(cacheKey, data, ttl)
+}
+
+func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration) error {
+	_, err := c.execute(cacheKey, c.insertStmt, c.writeConsistency,
+		[]byte(cacheKey), value(data), ttlValue(ttl))
+	return err
+}
+
+// Retrieve gets data from Cassandra using the provided Key. Cassandra does not return
+// expired rows, so allowExpired is not used
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	data, err := c.retrieve(cacheKey)
+	if err == cache.ErrKNF {
+		c.Logger.Debug("cassandra cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, err
+	}
+	if err == nil {
+		data, err = cache.DecodeValue(c.Config, cacheKey, data)
+	}
+	if err != nil {
+		c.Logger.Debug("cassandra cache retrieve failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+	c.Logger.Debug("cassandra cache retrieve", tl.Pairs{"key": cacheKey})
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	return data, status.LookupStatusHit, nil
+}
+
+// retrieve returns the stored value of the object at the key, or cache.ErrKNF
+func (c *Cache) retrieve(cacheKey string) ([]byte, error) {
+	resp, err := c.execute(cacheKey, c.selectStmt, c.readConsistency, []byte(cacheKey))
+	if err != nil {
+		return nil, err
+	}
+	return rowValue(resp)
+}
+
+// SetTTL updates the TTL for the provided cache object, if it is still present. Cassandra
+// sets TTLs when rows are written, so the object is read and written again with the TTL
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := c.retrieve(cacheKey)
+	if err == nil {
+		err = c.store(cacheKey, data, ttl)
+	}
+	if err != nil && err != cache.ErrKNF {
+		c.Logger.Debug("cassandra cache set ttl failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	}
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.Logger.Debug("cassandra cache remove", tl.Pairs{"key": cacheKey})
+	if _, err := c.execute(cacheKey, c.deleteStmt, c.writeConsistency,
+		[]byte(cacheKey)); err != nil {
+		c.Logger.Error("cassandra cache key delete failure",
+			tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+}
+
+// BulkRemove removes a list of objects from the cache, with the deletes sent to the node
+// owning each object as pipelines of concurrent requests
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug("cassandra cache bulk remove", tl.Pairs{"keys": len(cacheKeys)})
+	err := c.pipeline(cacheKeys, c.deleteStmt, c.writeConsistency, func(k string) [][]byte {
+		return [][]byte{[]byte(k)}
+	}, func(i int, f *frame) error {
+		_, _, err := result(f)
+		return err
+	})
+	if err != nil {
+		c.Logger.Error("cassandra cache bulk delete failure", tl.Pairs{"reason": err.Error()})
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
+}
+
+// BulkRetrieve returns the objects cached at the provided keys, with the reads sent to the
+// node owning each object as pipelines of concurrent requests
+func (c *Cache) BulkRetrieve(cacheKeys []string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(cacheKeys))
+	err := c.pipeline(cacheKeys, c.selectStmt, c.readConsistency, func(k string) [][]byte {
+		return [][]byte{[]byte(k)}
+	}, func(i int, f *frame) error {
+		b, err := rowValue(f)
+		if err == cache.ErrKNF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := cache.DecodeValue(c.Config, cacheKeys[i], b)
+		if err != nil {
+			return nil
+		}
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		out[cacheKeys[i]] = data
+		return nil
+	})
+	for _, k := range cacheKeys {
+		if _, ok := out[k]; !ok {
+			metrics.ObserveCacheMiss(k, c.Name, c.Config.CacheType)
+		}
+	}
+	c.Logger.Debug("cassandra cache bulk retrieve",
+		tl.Pairs{"keys": len(cacheKeys), "hits": len(out)})
+	return out, err
+}
+
+// BulkStore places the provided objects in the cache with the ttl, with the writes sent to
+// the node owning each object as pipelines of concurrent requests
+func (c *Cache) BulkStore(objects map[string][]byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	keys := make([]string, 0, len(objects))
+	values := make(map[string][]byte, len(objects))
+	for k, v := range objects {
+		data, err := cache.EncodeValue(c.Config, k, v)
+		if err != nil {
+			return err
+		}
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+		keys = append(keys, k)
+		values[k] = data
+	}
+	c.Logger.Debug("cassandra cache bulk store", tl.Pairs{"keys": len(keys)})
+	t := ttlValue(ttl)
+	return c.pipeline(keys, c.insertStmt, c.writeConsistency, func(k string) [][]byte {
+		return [][]byte{[]byte(k), value(values[k]), t}
+	}, func(i int, f *frame) error {
+		_, _, err := result(f)
+		return err
+	})
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	for _, h := range c.hosts {
+		h.close()
+	}
+	return nil
+}
+
+// route returns the nodes to which a request for the key may be sent, in order of
+// preference. When requests are token-aware, the node owning the key's token is first
+func (c *Cache) route(key []byte) []*host {
+	n := len(c.hosts)
+	if n == 0 {
+		return nil
+	}
+	out := make([]*host, 0, n)
+	start := 0
+	if c.ring != nil {
+		if h := c.ring.owner(murmur3Token(key), c.Config.Cassandra.LocalDC); h != nil {
+			out = append(out, h)
+		}
+	} else {
+		start = int(atomic.AddUint32(&c.next, 1) % uint32(n))
+	}
+	for i := 0; i < n; i++ {
+		if h := c.hosts[(start+i)%n]; len(out) == 0 || h != out[0] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// get returns a connection to the first reachable node of the route
+func (c *Cache) get(route []*host) (*host, *conn, error) {
+	err := ErrNotConnected
+	for _, h := range route {
+		cn, e := h.get()
+		if e == nil {
+			return h, cn, nil
+		}
+		err = e
+	}
+	return nil, nil, err
+}
+
+// execute executes the prepared statement on a node of the key's route, and returns its
+// response, or the error it represents
+func (c *Cache) execute(cacheKey, stmt string, consistency uint16,
+	values ...[]byte) (*frame, error) {
+	h, cn, err := c.get(c.route([]byte(cacheKey)))
+	if err != nil {
+		return nil, err
+	}
+	defer h.put(cn)
+	resp, err := cn.execute(stmt, consistency, values...)
+	if err != nil {
+		return nil, err
+	}
+	return resp, errorForFrame(resp)
+}
+
+// pipeline executes the prepared statement for each key on the node owning the key, as
+// pipelines of concurrent requests, and calls handle with the index of the key and its
+// response. The first error is returned
+func (c *Cache) pipeline(keys []string, stmt string, consistency uint16,
+	values func(string) [][]byte, handle func(int, *frame) error) error {
+	if len(c.hosts) == 0 {
+		return ErrNotConnected
+	}
+	byHost := make(map[*host][]int)
+	// routes holds the route of the first key of each node, which is used to fall back to
+	// other nodes when the owner is unreachable
+	routes := make(map[*host][]*host)
+	var order []*host
+	for i, k := range keys {
+		route := c.route([]byte(k))
+		h := route[0]
+		if _, ok := byHost[h]; !ok {
+			order = append(order, h)
+			routes[h] = route
+		}
+		byHost[h] = append(byHost[h], i)
+	}
+	var ferr error
+	for _, h := range order {
+		indexes := byHost[h]
+		for j := 0; j < len(indexes); j += pipelineBatchSize {
+			end := j + pipelineBatchSize
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			if err := c.pipelineBatch(routes[h], keys, indexes[j:end], stmt, consistency,
+				values, handle); err != nil && ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+func (c *Cache) pipelineBatch(route []*host, keys []string, indexes []int, stmt string,
+	consistency uint16, values func(string) [][]byte, handle func(int, *frame) error) error {
+	h, cn, err := c.get(route)
+	if err != nil {
+		return err
+	}
+	defer h.put(cn)
+	id, ok := cn.prepared[stmt]
+	if !ok {
+		if id, err = cn.prepare(stmt); err != nil {
+			return err
+		}
+	}
+	frames := make([]*frame, len(indexes))
+	for j, i := range indexes {
+		frames[j] = executeFrame(id, consistency, values(keys[i])...)
+	}
+	resps, err := cn.pipeline(frames)
+	if err != nil {
+		return err
+	}
+	var ferr error
+	for j, resp := range resps {
+		if e, ok := errorForFrame(resp).(*cqlError); ok && e.code == errUnprepared {
+			// the statement is prepared again by the next request on the connection
+			delete(cn.prepared, stmt)
+		}
+		if err = handle(indexes[j], resp); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
+
+// rowValue returns the value of the single-column row of a SELECT response, or
+// cache.ErrKNF if there is no row
+func rowValue(f *frame) ([]byte, error) {
+	d, kind, err := result(f)
+	if err != nil {
+		return nil, err
+	}
+	if kind != resultRows {
+		return nil, fmt.Errorf("unexpected cassandra result kind 0x%04x", kind)
+	}
+	rows := d.rows()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 || rows[0][0] == nil {
+		return nil, cache.ErrKNF
+	}
+	return rows[0][0], nil
+}
+
+// value returns the blob value of an object, which is empty rather than null when the
+// object is empty
+func value(data []byte) []byte {
+	if data == nil {
+		return []byte{}
+	}
+	return data
+}
+
+// ttlValue returns the int value of the ttl in seconds. 0 means never expire, so sub-second
+// ttls are rounded up
+func ttlValue(ttl time.Duration) []byte {
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	secs := uint32(ttl / time.Second)
+	if secs == 0 && ttl > 0 {
+		secs = 1
+	}
+	return []byte{byte(secs >> 24), byte(secs >> 16), byte(secs >> 8), byte(secs)}
+}
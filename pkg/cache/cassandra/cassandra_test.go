@@ -0,0 +1,855 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	co "github.com/tricksterproxy/trickster/pkg/cache/cassandra/options"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/selfsigned"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "cassandra"
+const cacheKey = "cacheKey"
+
+type testRow struct {
+	value []byte
+	ttl   uint32
+}
+
+// testCluster is an in-memory Cassandra cluster of nodes speaking the CQL native protocol,
+// which share a single table
+type testCluster struct {
+	mtx   sync.Mutex
+	rows  map[string]testRow
+	nodes []*testNode
+	table string
+	// username and password enable the PasswordAuthenticator when they are set
+	username string
+	password string
+	// noPeersV2 rejects queries of system.peers_v2, as Cassandra before 4.0 does
+	noPeersV2   bool
+	partitioner string
+	// consistency is the consistency level of the last statement executed
+	consistency uint16
+}
+
+// testNode is a node of a testCluster
+type testNode struct {
+	cluster *testCluster
+	l       net.Listener
+	dc      string
+	tokens  []string
+	// keys are the partition keys of the statements executed on the node
+	keys []string
+	// prepared maps the ids of the statements prepared on the node to the statements
+	prepared map[string]string
+}
+
+// newTestCluster starts a cluster with a node for each data center, which owns the tokens
+func newTestCluster(tc *tls.Config, dcs []string, tokens ...[]string) *testCluster {
+	s := &testCluster{rows: make(map[string]testRow), table: "trickster.cache",
+		partitioner: murmur3Partitioner}
+	for i, dc := range dcs {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			panic(err)
+		}
+		if tc != nil {
+			l = tls.NewListener(l, tc)
+		}
+		n := &testNode{cluster: s, l: l, dc: dc, tokens: tokens[i],
+			prepared: make(map[string]string)}
+		s.nodes = append(s.nodes, n)
+		go func() {
+			for {
+				nc, err := n.l.Accept()
+				if err != nil {
+					return
+				}
+				go n.serve(nc)
+			}
+		}()
+	}
+	return s
+}
+
+func (s *testCluster) close() {
+	for _, n := range s.nodes {
+		n.l.Close()
+	}
+}
+
+func (s *testCluster) row(key string) (testRow, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, ok := s.rows[key]
+	return r, ok
+}
+
+func (n *testNode) addr() string {
+	return n.l.Addr().String()
+}
+
+// keyCount returns the number of statements executed on the node
+func (n *testNode) keyCount() int {
+	n.cluster.mtx.Lock()
+	defer n.cluster.mtx.Unlock()
+	return len(n.keys)
+}
+
+func errorBody(code int32, msg string) []byte {
+	e := &encoder{}
+	e.int(code)
+	e.string(msg)
+	return e.b
+}
+
+func rowsBody(rows ...[][]byte) []byte {
+	e := &encoder{}
+	e.int(resultRows)
+	columns := 0
+	if len(rows) > 0 {
+		columns = len(rows[0])
+	}
+	e.int(metadataGlobalTableSpec)
+	e.int(int32(columns))
+	e.string("system")
+	e.string("local")
+	for i := 0; i < columns; i++ {
+		e.string("c" + strconv.Itoa(i))
+		e.short(0x0022) // set<varchar>, whose element type is also skipped
+		e.short(0x000d)
+	}
+	e.int(int32(len(rows)))
+	for _, r := range rows {
+		for _, v := range r {
+			e.bytes(v)
+		}
+	}
+	return e.b
+}
+
+func setValue(values []string) []byte {
+	e := &encoder{}
+	e.int(int32(len(values)))
+	for _, v := range values {
+		e.bytes([]byte(v))
+	}
+	return e.b
+}
+
+func (n *testNode) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	s := n.cluster
+	s.mtx.Lock()
+	authed := s.username == ""
+	s.mtx.Unlock()
+	for {
+		// the requests that have already been received are responded to in reverse order,
+		// as a node may respond to concurrent requests in any order
+		var reqs []*frame
+		for len(reqs) == 0 || r.Buffered() > 0 {
+			h := make([]byte, headerSize)
+			if _, err := io.ReadFull(r, h); err != nil || h[0] != protocolVersion {
+				return
+			}
+			body := make([]byte, binary.BigEndian.Uint32(h[5:9]))
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			reqs = append(reqs, &frame{opcode: h[4],
+				stream: int16(binary.BigEndian.Uint16(h[2:4])), body: body})
+		}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			req := reqs[i]
+			var resp *frame
+			switch {
+			case req.opcode == opStartup:
+				resp = &frame{opcode: opReady}
+				if !authed {
+					e := &encoder{}
+					e.string("org.apache.cassandra.auth.PasswordAuthenticator")
+					resp = &frame{opcode: opAuthenticate, body: e.b}
+				}
+			case req.opcode == opAuthResponse:
+				d := &decoder{b: req.body}
+				s.mtx.Lock()
+				authed = string(d.bytes()) == "\x00"+s.username+"\x00"+s.password
+				s.mtx.Unlock()
+				resp = &frame{opcode: opAuthSuccess}
+				if !authed {
+					resp = &frame{opcode: opError, body: errorBody(0x0100, "Bad credentials")}
+				}
+			case !authed:
+				resp = &frame{opcode: opError, body: errorBody(0x000a, "not authenticated")}
+			default:
+				resp = n.handle(req)
+			}
+			resp.stream = req.stream
+			o := make([]byte, headerSize)
+			o[0] = protocolVersion | responseFlag
+			binary.BigEndian.PutUint16(o[2:4], uint16(resp.stream))
+			o[4] = resp.opcode
+			binary.BigEndian.PutUint32(o[5:9], uint32(len(resp.body)))
+			if _, err := nc.Write(append(o, resp.body...)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (n *testNode) handle(req *frame) *frame {
+	s := n.cluster
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	d := &decoder{b: req.body}
+	switch req.opcode {
+	case opQuery:
+		q := string(d.read(int(d.int())))
+		switch {
+		case strings.HasSuffix(q, "FROM system.local"):
+			return &frame{opcode: opResult, body: rowsBody([][]byte{[]byte(n.dc),
+				setValue(n.tokens), []byte(s.partitioner)})}
+		case strings.HasSuffix(q, "FROM system.peers_v2"):
+			if s.noPeersV2 {
+				return &frame{opcode: opError,
+					body: errorBody(0x2200, "unconfigured table peers_v2")}
+			}
+			var rows [][][]byte
+			for _, p := range s.nodes {
+				if p == n {
+					continue
+				}
+				host, port, _ := net.SplitHostPort(p.addr())
+				pn, _ := strconv.Atoi(port)
+				pb := make([]byte, 4)
+				binary.BigEndian.PutUint32(pb, uint32(pn))
+				rows = append(rows, [][]byte{net.ParseIP(host).To4(), pb, []byte(p.dc),
+					setValue(p.tokens)})
+			}
+			return &frame{opcode: opResult, body: rowsBody(rows...)}
+		case strings.HasSuffix(q, "FROM system.peers"):
+			var rows [][][]byte
+			for _, p := range s.nodes {
+				if p != n {
+					rows = append(rows, [][]byte{net.ParseIP("127.0.0.1").To4(), []byte(p.dc),
+						setValue(p.tokens)})
+				}
+			}
+			return &frame{opcode: opResult, body: rowsBody(rows...)}
+		}
+	case opPrepare:
+		stmt := string(d.read(int(d.int())))
+		if !strings.Contains(stmt, " "+s.table+" ") {
+			return &frame{opcode: opError, body: errorBody(0x2200, "unconfigured table")}
+		}
+		// as with Cassandra, the id is the hash of the statement
+		id := md5.Sum([]byte(stmt))
+		n.prepared[string(id[:])] = stmt
+		e := &encoder{}
+		e.int(resultPrepared)
+		e.shortBytes(id[:])
+		// the metadata of the statement's variables and result is not read by the cache
+		e.int(0)
+		e.int(0)
+		e.int(0)
+		e.int(metadataNoMetadata)
+		e.int(0)
+		return &frame{opcode: opResult, body: e.b}
+	case opExecute:
+		id := d.shortBytes()
+		stmt, ok := n.prepared[string(id)]
+		if !ok {
+			e := &encoder{}
+			e.int(errUnprepared)
+			e.string("unprepared statement")
+			e.shortBytes(id)
+			return &frame{opcode: opError, body: e.b}
+		}
+		s.consistency = d.short()
+		d.read(1)
+		values := make([][]byte, d.short())
+		for i := range values {
+			values[i] = d.bytes()
+		}
+		key := string(values[0])
+		n.keys = append(n.keys, key)
+		switch {
+		case strings.HasPrefix(stmt, "SELECT"):
+			r, ok := s.rows[key]
+			if !ok {
+				return &frame{opcode: opResult, body: []byte{0, 0, 0, 2, 0, 0, 0, 4, 0, 0, 0, 1, 0, 0, 0, 0}}
+			}
+			e := &encoder{}
+			e.int(resultRows)
+			e.int(metadataNoMetadata)
+			e.int(1)
+			e.int(1)
+			e.bytes(r.value)
+			return &frame{opcode: opResult, body: e.b}
+		case strings.HasPrefix(stmt, "INSERT"):
+			s.rows[key] = testRow{value: append([]byte{}, values[1]...),
+				ttl: binary.BigEndian.Uint32(values[2])}
+		case strings.HasPrefix(stmt, "DELETE"):
+			delete(s.rows, key)
+		}
+		return &frame{opcode: opResult, body: []byte{0, 0, 0, resultVoid}}
+	}
+	return &frame{opcode: opError, body: errorBody(0x000a, "unsupported request")}
+}
+
+func newCache(hosts ...string) *Cache {
+	cacheConfig := &options.Options{CacheType: cacheType, Cassandra: co.NewOptions()}
+	cacheConfig.Cassandra.Hosts = hosts
+	return &Cache{Name: "test", Config: cacheConfig, Logger: tl.ConsoleLogger("error"),
+		locker: locks.NewNamedLocker()}
+}
+
+// newSingleNode returns a cluster with a single node, and a connected cache
+func newSingleNode(t *testing.T) (*testCluster, *Cache) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	c := newCache(s.nodes[0].addr())
+	if err := c.Connect(); err != nil {
+		s.close()
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func TestConfiguration(t *testing.T) {
+	c := newCache("127.0.0.1:9042")
+	cfg := c.Configuration()
+	if cfg.CacheType != cacheType {
+		t.Fatalf("expected %s got %s", cacheType, cfg.CacheType)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	c := Cache{locker: locks.NewNamedLocker()}
+	l := c.Locker()
+	c.SetLocker(locks.NewNamedLocker())
+	if l == c.Locker() {
+		t.Errorf("error setting locker")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	c := newCache("127.0.0.1:1", s.nodes[0].addr())
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+	c.Close()
+
+	// it should fail when the table does not exist
+	c.Config.Cassandra.Table = "missing"
+	const expected = "could not connect to cassandra: cassandra error 0x2200: unconfigured table"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+	c.Config.Cassandra.Table = "cache"
+
+	// it should fail when no host is reachable
+	s.close()
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unreachable hosts")
+	}
+}
+
+func TestConnectAuth(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	defer s.close()
+	s.mtx.Lock()
+	s.username = "user"
+	s.password = "pass"
+	s.mtx.Unlock()
+
+	c := newCache(s.nodes[0].addr())
+	const expected = "could not connect to cassandra: cassandra requires authentication with " +
+		"org.apache.cassandra.auth.PasswordAuthenticator"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+
+	c.Config.Cassandra.Username = "user"
+	c.Config.Cassandra.Password = "wrong"
+	if err := c.Connect(); err == nil {
+		t.Error("expected authentication error")
+	}
+
+	c.Config.Cassandra.Password = "pass"
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	data, _, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+}
+
+func TestConnectTLS(t *testing.T) {
+	certPEM, keyPEM, err := selfsigned.Generate([]string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestCluster(&tls.Config{Certificates: []tls.Certificate{cert}}, []string{"dc1"},
+		[]string{"0"})
+	defer s.close()
+
+	f, err := ioutil.TempFile("", "trickster-cassandra-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(certPEM)
+	f.Close()
+
+	c := newCache(s.nodes[0].addr())
+	c.Config.Cassandra.UseTLS = true
+
+	// it should fail when the node's certificate is not trusted
+	if err := c.Connect(); err == nil {
+		t.Error("expected certificate verification error")
+	}
+
+	c.Config.Cassandra.CertificateAuthorityPaths = []string{f.Name()}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	c.Config.Cassandra.CertificateAuthorityPaths = []string{"/nonexistent/ca.pem"}
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
+
+func TestStoreRetrieve(t *testing.T) {
+	s, c := newSingleNode(t)
+	defer s.close()
+	defer c.Close()
+
+	// it should be a cache miss
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	if err = c.Store(cacheKey, []byte("data"), 60*time.Second); err != nil {
+		t.Error(err)
+	}
+	if r, _ := s.row(cacheKey); r.ttl != 60 {
+		t.Errorf("expected %d got %d", 60, r.ttl)
+	}
+	if s.consistency != co.ConsistencyLevels["local_one"] {
+		t.Errorf("expected %d got %d", co.ConsistencyLevels["local_one"], s.consistency)
+	}
+
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// empty objects should be stored as empty values
+	if err = c.Store(cacheKey, nil, time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, ls, _ = c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	defer s.close()
+	c := newCache(s.nodes[0].addr())
+	c.Config.Cassandra.ReadConsistency = "quorum"
+	c.Config.Cassandra.WriteConsistency = "all"
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	if s.consistency != co.ConsistencyLevels["all"] {
+		t.Errorf("expected %d got %d", co.ConsistencyLevels["all"], s.consistency)
+	}
+	c.Retrieve(cacheKey, false)
+	if s.consistency != co.ConsistencyLevels["quorum"] {
+		t.Errorf("expected %d got %d", co.ConsistencyLevels["quorum"], s.consistency)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	defer s.close()
+	c := newCache(s.nodes[0].addr())
+	c.Config.Encryption = eo.NewOptions()
+	c.Config.Encryption.ActiveKeyID = "k1"
+	c.Config.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := c.Config.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if r, _ := s.row(cacheKey); strings.Contains(string(r.value), "data") {
+		t.Error("expected the stored value to be encrypted")
+	}
+	data, _, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	s, c := newSingleNode(t)
+	defer s.close()
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.SetTTL(cacheKey, time.Hour)
+	if r, _ := s.row(cacheKey); r.ttl != 3600 || string(r.value) != "data" {
+		t.Errorf("expected %d data got %d %s", 3600, r.ttl, r.value)
+	}
+
+	// it should not create a missing object
+	c.SetTTL("missing", time.Hour)
+	if _, ok := s.row("missing"); ok {
+		t.Error("expected missing object")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s, c := newSingleNode(t)
+	defer s.close()
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.Remove(cacheKey)
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+	// it should not fail for a missing key
+	c.Remove(cacheKey)
+}
+
+func TestUnprepared(t *testing.T) {
+	s, c := newSingleNode(t)
+	defer s.close()
+	defer c.Close()
+
+	// the node forgets its prepared statements, as it does when it restarts
+	s.mtx.Lock()
+	s.nodes[0].prepared = make(map[string]string)
+	s.mtx.Unlock()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+// owner returns the index of the node owning the key, which is the node of the first token
+// that is greater than or equal to the key's token
+func owner(s *testCluster, key string) int {
+	type nodeToken struct {
+		token int64
+		node  int
+	}
+	var tokens []nodeToken
+	for i, n := range s.nodes {
+		for _, v := range n.tokens {
+			t, _ := strconv.ParseInt(v, 10, 64)
+			tokens = append(tokens, nodeToken{t, i})
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].token < tokens[j].token })
+	kt := murmur3Token([]byte(key))
+	for _, t := range tokens {
+		if t.token >= kt {
+			return t.node
+		}
+	}
+	return tokens[0].node
+}
+
+func TestTokenAware(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1", "dc1", "dc1"},
+		[]string{"-6000000000000000000", "0"},
+		[]string{"-3000000000000000000", "3000000000000000000"},
+		[]string{"-9000000000000000000", "6000000000000000000"})
+	defer s.close()
+	c := newCache(s.nodes[1].addr())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if len(c.hosts) != 3 || c.ring == nil || len(c.ring.tokens) != 6 {
+		t.Fatalf("expected %d hosts and %d tokens", 3, 6)
+	}
+
+	// each request should be sent to the node owning the key
+	for i := 0; i < 30; i++ {
+		k := cacheKey + strconv.Itoa(i)
+		o := s.nodes[owner(s, k)]
+		before := o.keyCount()
+		if err := c.Store(k, []byte("data"), time.Minute); err != nil {
+			t.Error(err)
+		}
+		if o.keyCount() != before+1 {
+			t.Errorf("expected key %s on node %s", k, o.addr())
+		}
+	}
+
+	// it should fall back to another node when the owner is unreachable
+	k := cacheKey + "0"
+	s.nodes[owner(s, k)].l.Close()
+	c.Close()
+	if _, ls, _ := c.Retrieve(k, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+func TestTokenAwareLocalDC(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1", "dc2"},
+		[]string{"-3000000000000000000"}, []string{"3000000000000000000"})
+	defer s.close()
+	s.mtx.Lock()
+	s.noPeersV2 = true
+	s.mtx.Unlock()
+	c := newCache(s.nodes[0].addr())
+	c.Config.Cassandra.LocalDC = "dc2"
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// system.peers does not include the peers' ports, so the contact point's is assumed
+	if len(c.hosts) != 2 || c.hosts[0].addr != s.nodes[0].addr() || c.hosts[0].dc != "dc2" {
+		t.Fatalf("unexpected hosts %v", c.hosts)
+	}
+
+	// the key should be routed to the node of the local data center
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if s.nodes[0].keyCount() != 1 || s.nodes[1].keyCount() != 0 {
+		t.Errorf("expected %d %d got %d %d", 1, 0, s.nodes[0].keyCount(), s.nodes[1].keyCount())
+	}
+}
+
+func TestNotTokenAware(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1", "dc1"}, []string{"0"}, []string{"1"})
+	defer s.close()
+	c := newCache(s.nodes[0].addr(), s.nodes[1].addr())
+	c.Config.Cassandra.TokenAware = false
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.ring != nil {
+		t.Error("expected nil ring")
+	}
+
+	// requests should be sent to the hosts in turn
+	for i := 0; i < 10; i++ {
+		c.Store(cacheKey, []byte("data"), time.Minute)
+	}
+	if s.nodes[0].keyCount() != 5 || s.nodes[1].keyCount() != 5 {
+		t.Errorf("expected %d %d got %d %d", 5, 5, s.nodes[0].keyCount(), s.nodes[1].keyCount())
+	}
+}
+
+func TestUnsupportedPartitioner(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1"}, []string{"0"})
+	defer s.close()
+	s.mtx.Lock()
+	s.partitioner = "org.apache.cassandra.dht.RandomPartitioner"
+	s.mtx.Unlock()
+	c := newCache(s.nodes[0].addr())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.ring != nil {
+		t.Error("expected nil ring")
+	}
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBulkOperations(t *testing.T) {
+	s := newTestCluster(nil, []string{"dc1", "dc1"},
+		[]string{"-4611686018427387904", "4611686018427387904"}, []string{"0"})
+	defer s.close()
+	c := newCache(s.nodes[0].addr())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	objects := make(map[string][]byte)
+	keys := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		k := cacheKey + strconv.Itoa(i)
+		objects[k] = []byte("data-" + k)
+		keys = append(keys, k)
+	}
+
+	if err := c.BulkStore(objects, time.Minute); err != nil {
+		t.Error(err)
+	}
+	if r, _ := s.row(keys[0]); r.ttl != 60 {
+		t.Errorf("expected %d got %d", 60, r.ttl)
+	}
+
+	// the objects should be written to the nodes owning them
+	n0 := s.nodes[0].keyCount()
+	if n0 == 0 || n0 == len(objects) {
+		t.Errorf("expected objects on both nodes, got %d of %d on the first", n0, len(objects))
+	}
+	for _, k := range s.nodes[0].keys {
+		if owner(s, k) != 0 {
+			t.Errorf("unexpected key %s on node %s", k, s.nodes[0].addr())
+		}
+	}
+
+	out, err := c.BulkRetrieve(append(keys, "missing"))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != len(objects) {
+		t.Errorf("expected %d got %d", len(objects), len(out))
+	}
+	for k, v := range objects {
+		if string(out[k]) != string(v) {
+			t.Errorf("wanted \"%s\". got \"%s\".", v, out[k])
+		}
+	}
+
+	c.BulkRemove(append([]string{"missing"}, keys[:100]...))
+	out, err = c.BulkRetrieve(keys)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != len(objects)-100 {
+		t.Errorf("expected %d got %d", len(objects)-100, len(out))
+	}
+
+	// it should return the error of a rejected statement
+	s.mtx.Lock()
+	s.table = "other.table"
+	for _, n := range s.nodes {
+		n.prepared = make(map[string]string)
+	}
+	s.mtx.Unlock()
+	if err := c.BulkStore(objects, time.Minute); err == nil {
+		t.Error("expected error for unprepared statements")
+	}
+}
+
+func TestNotConnected(t *testing.T) {
+	c := newCache()
+	if _, _, err := c.Retrieve(cacheKey, false); err != ErrNotConnected {
+		t.Errorf("expected %v got %v", ErrNotConnected, err)
+	}
+	if _, err := c.BulkRetrieve([]string{cacheKey}); err != ErrNotConnected {
+		t.Errorf("expected %v got %v", ErrNotConnected, err)
+	}
+}
+
+func TestTTLValue(t *testing.T) {
+	tests := []struct {
+		ttl      time.Duration
+		expected uint32
+	}{
+		{time.Minute, 60},
+		{500 * time.Millisecond, 1},
+		{0, 0},
+		{100 * 365 * 24 * time.Hour, 630720000},
+	}
+	for i, test := range tests {
+		if v := binary.BigEndian.Uint32(ttlValue(test.ttl)); v != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, v)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// host is a Cassandra node, and the pool of idle connections to it
+type host struct {
+	addr      string
+	dc        string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	timeout   time.Duration
+	// statements are prepared on each new connection, which also verifies that the
+	// cache's table exists
+	statements []string
+	idle       chan *conn
+}
+
+// get returns an idle connection to the node, or a new one if none are idle
+func (h *host) get() (*conn, error) {
+	select {
+	case c := <-h.idle:
+		return c, nil
+	default:
+	}
+	return h.dial()
+}
+
+// put returns a connection to the pool of idle connections, or closes it if the pool is
+// full or the connection can't be reused
+func (h *host) put(c *conn) {
+	if c.broken {
+		c.nc.Close()
+		return
+	}
+	select {
+	case h.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+// dial opens, authenticates and prepares the statements of a new connection to the node
+func (h *host) dial() (*conn, error) {
+	d := &net.Dialer{Timeout: h.timeout}
+	var nc net.Conn
+	var err error
+	if h.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(d, "tcp", h.addr, h.tlsConfig)
+	} else {
+		nc, err = d.Dial("tcp", h.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := newConn(nc, h.timeout)
+	if err = c.startup(h.username, h.password); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	for _, stmt := range h.statements {
+		if _, err = c.prepare(stmt); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// close closes the node's idle connections
+func (h *host) close() {
+	for {
+		select {
+		case c := <-h.idle:
+			c.nc.Close()
+		default:
+			return
+		}
+	}
+}
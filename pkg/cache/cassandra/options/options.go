@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ConsistencyLevels maps the names of the consistency levels accepted for reads and writes
+// to their codes in the CQL native protocol
+var ConsistencyLevels = map[string]uint16{
+	"any":          0x0000,
+	"one":          0x0001,
+	"two":          0x0002,
+	"three":        0x0003,
+	"quorum":       0x0004,
+	"all":          0x0005,
+	"local_quorum": 0x0006,
+	"each_quorum":  0x0007,
+	"local_one":    0x000a,
+}
+
+// identifier matches the unquoted CQL identifiers accepted as keyspace and table names
+var identifier = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,47}$`)
+
+// ErrMissingHosts is returned when no hosts are configured
+var ErrMissingHosts = errors.New("cassandra hosts must be provided")
+
+// ErrInvalidHost is returned when a host is not a host:port address
+var ErrInvalidHost = errors.New("cassandra hosts must be host:port addresses")
+
+// ErrInvalidKeyspace is returned when the keyspace or table is not a valid CQL identifier
+var ErrInvalidKeyspace = errors.New(
+	"cassandra keyspace and table must be alphanumeric identifiers starting with a letter")
+
+// ErrInvalidConsistency is returned when a consistency level is not supported
+var ErrInvalidConsistency = errors.New("cassandra read_consistency and write_consistency must be " +
+	"one of 'any', 'one', 'two', 'three', 'quorum', 'all', 'local_quorum', 'each_quorum' or 'local_one'")
+
+// ErrInvalidReadConsistency is returned when the read consistency level is 'any', which
+// only applies to writes
+var ErrInvalidReadConsistency = errors.New("cassandra read_consistency must not be 'any'")
+
+// ErrInvalidCredentials is returned when only one of the username and password is provided
+var ErrInvalidCredentials = errors.New("cassandra username and password must be provided together")
+
+// ErrInvalidClientCert is returned when only one of the client cert and key paths is provided
+var ErrInvalidClientCert = errors.New(
+	"cassandra client_cert_path and client_key_path must be provided together")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("cassandra timeout_ms must be greater than 0")
+
+// ErrInvalidMaxIdleConns is returned when the idle connection limit is negative
+var ErrInvalidMaxIdleConns = errors.New("cassandra max_idle_conns must not be negative")
+
+// Options is a collection of Configurations for storing cached data in Cassandra or ScyllaDB
+type Options struct {
+	// Hosts is the list of host:port addresses of the contact points, through which the
+	// cache discovers the other nodes of the cluster
+	Hosts []string `toml:"hosts"`
+	// Keyspace is the keyspace of the cache's table
+	Keyspace string `toml:"keyspace"`
+	// Table is the name of the cache's table, which must have a text primary key named key
+	// and a blob column named value
+	Table string `toml:"table"`
+	// ReadConsistency is the consistency level of reads
+	ReadConsistency string `toml:"read_consistency"`
+	// WriteConsistency is the consistency level of writes and deletes
+	WriteConsistency string `toml:"write_consistency"`
+	// TokenAware sends each request to the node that owns the object's token, using the
+	// token ring discovered from the contact point. When false, requests are sent to the
+	// contact points in turn, which coordinate them
+	TokenAware bool `toml:"token_aware"`
+	// LocalDC is the data center whose nodes are preferred when routing requests. When empty,
+	// the nodes of all data centers are used
+	LocalDC string `toml:"local_dc"`
+	// Username is the PasswordAuthenticator username. When empty, connections are not authenticated
+	Username string `toml:"username"`
+	// Password is the PasswordAuthenticator password
+	Password string `toml:"password"`
+	// UseTLS indicates that connections to the nodes are made over TLS
+	UseTLS bool `toml:"use_tls"`
+	// InsecureSkipVerify indicates that the nodes' certificates are not verified
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// CertificateAuthorityPaths provides a list of custom Certificate Authorities for the
+	// nodes, which are considered in addition to any system CA's
+	CertificateAuthorityPaths []string `toml:"certificate_authority_paths"`
+	// ServerName overrides the server name used to verify the nodes' certificates. The
+	// host of each node's address is used by default
+	ServerName string `toml:"server_name"`
+	// ClientCertPath provides the path to the Client Certificate when using Mutual Authorization
+	ClientCertPath string `toml:"client_cert_path"`
+	// ClientKeyPath provides the path to the Client Key when using Mutual Authorization
+	ClientKeyPath string `toml:"client_key_path"`
+	// MaxIdleConns is the most idle connections held open to each node
+	MaxIdleConns int `toml:"max_idle_conns"`
+	// TimeoutMS is the timeout of connecting to a node, and of each request to it
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new Cassandra Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Hosts:            []string{d.DefaultCassandraHost},
+		Keyspace:         d.DefaultCassandraKeyspace,
+		Table:            d.DefaultCassandraTable,
+		ReadConsistency:  d.DefaultCassandraConsistency,
+		WriteConsistency: d.DefaultCassandraConsistency,
+		TokenAware:       true,
+		MaxIdleConns:     d.DefaultCassandraMaxIdleConns,
+		TimeoutMS:        d.DefaultCassandraTimeoutMS,
+		Timeout:          time.Duration(d.DefaultCassandraTimeoutMS) * time.Millisecond,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	if o.Hosts != nil {
+		o2.Hosts = make([]string, len(o.Hosts))
+		copy(o2.Hosts, o.Hosts)
+	}
+	if o.CertificateAuthorityPaths != nil {
+		o2.CertificateAuthorityPaths = make([]string, len(o.CertificateAuthorityPaths))
+		copy(o2.CertificateAuthorityPaths, o.CertificateAuthorityPaths)
+	}
+	return &o2
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if len(o.Hosts) == 0 {
+		return ErrMissingHosts
+	}
+	for _, h := range o.Hosts {
+		if _, port, err := net.SplitHostPort(h); err != nil || port == "" {
+			return ErrInvalidHost
+		}
+	}
+	if !identifier.MatchString(o.Keyspace) || !identifier.MatchString(o.Table) {
+		return ErrInvalidKeyspace
+	}
+	if _, ok := ConsistencyLevels[o.ReadConsistency]; !ok {
+		return ErrInvalidConsistency
+	}
+	if _, ok := ConsistencyLevels[o.WriteConsistency]; !ok {
+		return ErrInvalidConsistency
+	}
+	if o.ReadConsistency == "any" {
+		return ErrInvalidReadConsistency
+	}
+	if (o.Username == "") != (o.Password == "") {
+		return ErrInvalidCredentials
+	}
+	if (o.ClientCertPath == "") != (o.ClientKeyPath == "") {
+		return ErrInvalidClientCert
+	}
+	if o.MaxIdleConns < 0 {
+		return ErrInvalidMaxIdleConns
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Error("expected non-nil options")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		hosts    []string
+		keyspace string
+		table    string
+		read     string
+		write    string
+		username string
+		password string
+		certPath string
+		idle     int
+		timeout  int
+		expected error
+	}{
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "local_one", "", "", "", 4, 2000, nil},
+		{[]string{"a:9042", "b:9042"}, "ks1", "cache_v2", "quorum", "any", "user", "pass", "", 0, 2000, nil},
+		{nil, "trickster", "cache", "local_one", "local_one", "", "", "", 4, 2000, ErrMissingHosts},
+		{[]string{"localhost"}, "trickster", "cache", "local_one", "local_one", "", "", "", 4, 2000,
+			ErrInvalidHost},
+		{[]string{"localhost:9042"}, "", "cache", "local_one", "local_one", "", "", "", 4, 2000,
+			ErrInvalidKeyspace},
+		{[]string{"localhost:9042"}, "trickster", "cache;", "local_one", "local_one", "", "", "", 4, 2000,
+			ErrInvalidKeyspace},
+		{[]string{"localhost:9042"}, "trickster", "cache", "serial", "local_one", "", "", "", 4, 2000,
+			ErrInvalidConsistency},
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "LOCAL_ONE", "", "", "", 4, 2000,
+			ErrInvalidConsistency},
+		{[]string{"localhost:9042"}, "trickster", "cache", "any", "local_one", "", "", "", 4, 2000,
+			ErrInvalidReadConsistency},
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "local_one", "user", "", "", 4, 2000,
+			ErrInvalidCredentials},
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "local_one", "", "", "cert.pem", 4,
+			2000, ErrInvalidClientCert},
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "local_one", "", "", "", -1, 2000,
+			ErrInvalidMaxIdleConns},
+		{[]string{"localhost:9042"}, "trickster", "cache", "local_one", "local_one", "", "", "", 4, 0,
+			ErrInvalidTimeout},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.Hosts = test.hosts
+		o.Keyspace = test.keyspace
+		o.Table = test.table
+		o.ReadConsistency = test.read
+		o.WriteConsistency = test.write
+		o.Username = test.username
+		o.Password = test.password
+		o.ClientCertPath = test.certPath
+		o.MaxIdleConns = test.idle
+		o.TimeoutMS = test.timeout
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.CertificateAuthorityPaths = []string{"ca.pem"}
+	o2 := o.Clone()
+	o2.Hosts[0] = "other:9042"
+	o2.CertificateAuthorityPaths[0] = "other.pem"
+	if o.Hosts[0] != "cassandra:9042" || o.CertificateAuthorityPaths[0] != "ca.pem" {
+		t.Errorf("expected cassandra:9042 ca.pem got %s %s", o.Hosts[0], o.CertificateAuthorityPaths[0])
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.TimeoutMS = 250
+	o.SetDurations()
+	if o.Timeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.Timeout)
+	}
+}
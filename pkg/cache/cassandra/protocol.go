@@ -0,0 +1,562 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// the CQL native protocol opcodes used by the cache
+const (
+	opError        = 0x00
+	opStartup      = 0x01
+	opReady        = 0x02
+	opAuthenticate = 0x03
+	opQuery        = 0x07
+	opResult       = 0x08
+	opPrepare      = 0x09
+	opExecute      = 0x0a
+	opAuthResponse = 0x0f
+	opAuthSuccess  = 0x10
+)
+
+// the kinds of RESULT responses handled by the cache
+const (
+	resultVoid     = 0x0001
+	resultRows     = 0x0002
+	resultPrepared = 0x0004
+)
+
+// the flags of rows metadata
+const (
+	metadataGlobalTableSpec = 0x0001
+	metadataHasMorePages    = 0x0002
+	metadataNoMetadata      = 0x0004
+)
+
+// the flags of query parameters
+const (
+	queryValues       = 0x01
+	querySkipMetadata = 0x02
+)
+
+const (
+	// protocolVersion is version 4 of the CQL native protocol, which is supported by
+	// Cassandra 2.2 and later, and by ScyllaDB
+	protocolVersion = 0x04
+	responseFlag    = 0x80
+	headerSize      = 9
+	// maxFrameSize is the largest response frame accepted, which is Cassandra's default
+	// limit of native_transport_max_frame_size_in_mb before 4.0
+	maxFrameSize = 256 * 1024 * 1024
+	cqlVersion   = "3.0.0"
+	// errUnprepared is the error code returned when a node does not know a prepared statement
+	errUnprepared = 0x2500
+)
+
+// errShortFrame is returned when a frame body is shorter than its contents
+var errShortFrame = errors.New("invalid cassandra frame: unexpected end of body")
+
+// cqlError is an ERROR response from a node
+type cqlError struct {
+	code    int32
+	message string
+}
+
+func (e *cqlError) Error() string {
+	return fmt.Sprintf("cassandra error 0x%04x: %s", e.code, e.message)
+}
+
+// frame is a CQL native protocol request or response
+type frame struct {
+	opcode byte
+	stream int16
+	body   []byte
+}
+
+// encoder appends the CQL native protocol notations to a frame body
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) byte(v byte) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) short(v uint16) {
+	e.b = append(e.b, byte(v>>8), byte(v))
+}
+
+func (e *encoder) int(v int32) {
+	e.b = append(e.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) string(s string) {
+	e.short(uint16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) longString(s string) {
+	e.int(int32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// bytes appends a [bytes], which is null when b is nil
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int(-1)
+		return
+	}
+	e.int(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) shortBytes(b []byte) {
+	e.short(uint16(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) stringMap(m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.short(uint16(len(keys)))
+	for _, k := range keys {
+		e.string(k)
+		e.string(m[k])
+	}
+}
+
+// decoder reads the CQL native protocol notations from a frame body. The first error is
+// retained, and reads after an error return zero values
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errShortFrame
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) short() uint16 {
+	b := d.read(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (d *decoder) int() int32 {
+	b := d.read(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) string() string {
+	return string(d.read(int(d.short())))
+}
+
+// bytes reads a [bytes], which is nil when it is null
+func (d *decoder) bytes() []byte {
+	n := d.int()
+	if n < 0 {
+		return nil
+	}
+	return d.read(int(n))
+}
+
+func (d *decoder) shortBytes() []byte {
+	return d.read(int(d.short()))
+}
+
+// option skips a column type, including the types of any collection elements
+func (d *decoder) option() {
+	switch d.short() {
+	case 0x0000: // custom
+		d.string()
+	case 0x0020, 0x0022: // list, set
+		d.option()
+	case 0x0021: // map
+		d.option()
+		d.option()
+	case 0x0030: // udt
+		d.string()
+		d.string()
+		for n := int(d.short()); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.option()
+		}
+	case 0x0031: // tuple
+		for n := int(d.short()); n > 0 && d.err == nil; n-- {
+			d.option()
+		}
+	}
+}
+
+// rows reads a Rows result, and returns the raw value of each column of each row
+func (d *decoder) rows() [][][]byte {
+	flags := d.int()
+	columns := int(d.int())
+	if flags&metadataHasMorePages != 0 {
+		d.bytes()
+	}
+	if flags&metadataNoMetadata == 0 {
+		global := flags&metadataGlobalTableSpec != 0
+		if global {
+			d.string()
+			d.string()
+		}
+		for i := 0; i < columns && d.err == nil; i++ {
+			if !global {
+				d.string()
+				d.string()
+			}
+			d.string()
+			d.option()
+		}
+	}
+	n := int(d.int())
+	if d.err != nil || n < 0 || columns < 0 {
+		return nil
+	}
+	rows := make([][][]byte, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		row := make([][]byte, columns)
+		for j := range row {
+			row[j] = d.bytes()
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// decodeSet decodes a set or list of text values
+func decodeSet(b []byte) []string {
+	d := &decoder{b: b}
+	n := int(d.int())
+	if n < 0 {
+		return nil
+	}
+	out := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, string(d.bytes()))
+	}
+	return out
+}
+
+// errorForFrame returns the error represented by a response, which is nil unless it is
+// an ERROR response
+func errorForFrame(f *frame) error {
+	if f.opcode != opError {
+		return nil
+	}
+	d := &decoder{b: f.body}
+	e := &cqlError{code: d.int(), message: d.string()}
+	if d.err != nil {
+		return d.err
+	}
+	return e
+}
+
+// result returns the decoder of a RESULT response positioned after its kind, and the kind
+func result(f *frame) (*decoder, int32, error) {
+	if err := errorForFrame(f); err != nil {
+		return nil, 0, err
+	}
+	if f.opcode != opResult {
+		return nil, 0, fmt.Errorf("unexpected cassandra response opcode 0x%02x", f.opcode)
+	}
+	d := &decoder{b: f.body}
+	return d, d.int(), nil
+}
+
+func startupFrame() *frame {
+	e := &encoder{}
+	e.stringMap(map[string]string{"CQL_VERSION": cqlVersion})
+	return &frame{opcode: opStartup, body: e.b}
+}
+
+// authResponseFrame returns the response to a PasswordAuthenticator challenge, which is a
+// SASL PLAIN token
+func authResponseFrame(username, password string) *frame {
+	e := &encoder{}
+	e.bytes([]byte("\x00" + username + "\x00" + password))
+	return &frame{opcode: opAuthResponse, body: e.b}
+}
+
+func queryFrame(query string, consistency uint16) *frame {
+	e := &encoder{}
+	e.longString(query)
+	e.short(consistency)
+	e.byte(0)
+	return &frame{opcode: opQuery, body: e.b}
+}
+
+func prepareFrame(query string) *frame {
+	e := &encoder{}
+	e.longString(query)
+	return &frame{opcode: opPrepare, body: e.b}
+}
+
+// executeFrame returns a request executing the prepared statement with the values. The
+// metadata of rows results is skipped, since the statements' columns are known
+func executeFrame(id []byte, consistency uint16, values ...[]byte) *frame {
+	e := &encoder{}
+	e.shortBytes(id)
+	e.short(consistency)
+	e.byte(queryValues | querySkipMetadata)
+	e.short(uint16(len(values)))
+	for _, v := range values {
+		e.bytes(v)
+	}
+	return &frame{opcode: opExecute, body: e.b}
+}
+
+// conn is a connection to a Cassandra node
+type conn struct {
+	nc      net.Conn
+	rw      *bufio.ReadWriter
+	timeout time.Duration
+	// prepared maps the statements prepared on the connection's node to their ids
+	prepared map[string][]byte
+	// broken is set when the connection is in an unknown state, and can't be reused
+	broken bool
+}
+
+func newConn(nc net.Conn, timeout time.Duration) *conn {
+	return &conn{nc: nc, timeout: timeout, prepared: make(map[string][]byte),
+		rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}
+}
+
+// write buffers a request, to be sent with the next flush
+func (c *conn) write(f *frame) error {
+	h := make([]byte, headerSize)
+	h[0] = protocolVersion
+	binary.BigEndian.PutUint16(h[2:4], uint16(f.stream))
+	h[4] = f.opcode
+	binary.BigEndian.PutUint32(h[5:9], uint32(len(f.body)))
+	for _, b := range [][]byte{h, f.body} {
+		if _, err := c.rw.Write(b); err != nil {
+			c.broken = true
+			return err
+		}
+	}
+	return nil
+}
+
+// flush sends the buffered requests
+func (c *conn) flush() error {
+	c.nc.SetDeadline(time.Now().Add(c.timeout))
+	if err := c.rw.Flush(); err != nil {
+		c.broken = true
+		return err
+	}
+	return nil
+}
+
+// read reads the next response. Server-initiated events, which have negative stream ids,
+// are not requested by the cache and are skipped
+func (c *conn) read() (*frame, error) {
+	for {
+		h := make([]byte, headerSize)
+		if _, err := io.ReadFull(c.rw, h); err != nil {
+			c.broken = true
+			return nil, err
+		}
+		if h[0] != protocolVersion|responseFlag {
+			c.broken = true
+			return nil, fmt.Errorf("invalid cassandra response version 0x%02x", h[0])
+		}
+		if h[1] != 0 {
+			c.broken = true
+			return nil, fmt.Errorf("unsupported cassandra response flags 0x%02x", h[1])
+		}
+		n := binary.BigEndian.Uint32(h[5:9])
+		if n > maxFrameSize {
+			c.broken = true
+			return nil, errors.New("invalid cassandra response length")
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(c.rw, body); err != nil {
+			c.broken = true
+			return nil, err
+		}
+		f := &frame{opcode: h[4], stream: int16(binary.BigEndian.Uint16(h[2:4])), body: body}
+		if f.stream >= 0 {
+			return f, nil
+		}
+	}
+}
+
+// roundTrip sends a single request and returns its response
+func (c *conn) roundTrip(f *frame) (*frame, error) {
+	f.stream = 0
+	if err := c.write(f); err != nil {
+		return nil, err
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	resp, err := c.read()
+	if err == nil && resp.stream != 0 {
+		c.broken = true
+		err = fmt.Errorf("unexpected cassandra response stream %d", resp.stream)
+	}
+	return resp, err
+}
+
+// pipeline sends the requests on separate streams, and returns their responses in the
+// order of the requests, regardless of the order in which the node responds
+func (c *conn) pipeline(frames []*frame) ([]*frame, error) {
+	for i, f := range frames {
+		f.stream = int16(i)
+		if err := c.write(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	out := make([]*frame, len(frames))
+	for range frames {
+		resp, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		if int(resp.stream) >= len(out) || out[resp.stream] != nil {
+			c.broken = true
+			return nil, fmt.Errorf("unexpected cassandra response stream %d", resp.stream)
+		}
+		out[resp.stream] = resp
+	}
+	return out, nil
+}
+
+// startup initializes the connection, and authenticates it with the PasswordAuthenticator
+// credentials if the node requires authentication
+func (c *conn) startup(username, password string) error {
+	resp, err := c.roundTrip(startupFrame())
+	if err != nil {
+		return err
+	}
+	if err = errorForFrame(resp); err != nil {
+		return err
+	}
+	switch resp.opcode {
+	case opReady:
+		return nil
+	case opAuthenticate:
+	default:
+		return fmt.Errorf("unexpected cassandra response opcode 0x%02x", resp.opcode)
+	}
+	if username == "" {
+		d := &decoder{b: resp.body}
+		return fmt.Errorf("cassandra requires authentication with %s", d.string())
+	}
+	resp, err = c.roundTrip(authResponseFrame(username, password))
+	if err != nil {
+		return err
+	}
+	if err = errorForFrame(resp); err != nil {
+		return err
+	}
+	if resp.opcode != opAuthSuccess {
+		return fmt.Errorf("unexpected cassandra response opcode 0x%02x", resp.opcode)
+	}
+	return nil
+}
+
+// query runs an unprepared query, and returns the rows of its result
+func (c *conn) query(q string, consistency uint16) ([][][]byte, error) {
+	resp, err := c.roundTrip(queryFrame(q, consistency))
+	if err != nil {
+		return nil, err
+	}
+	d, kind, err := result(resp)
+	if err != nil {
+		return nil, err
+	}
+	if kind != resultRows {
+		return nil, fmt.Errorf("unexpected cassandra result kind 0x%04x", kind)
+	}
+	rows := d.rows()
+	return rows, d.err
+}
+
+// prepare prepares the statement on the connection's node, and retains its id
+func (c *conn) prepare(stmt string) ([]byte, error) {
+	resp, err := c.roundTrip(prepareFrame(stmt))
+	if err != nil {
+		return nil, err
+	}
+	d, kind, err := result(resp)
+	if err != nil {
+		return nil, err
+	}
+	if kind != resultPrepared {
+		return nil, fmt.Errorf("unexpected cassandra result kind 0x%04x", kind)
+	}
+	id := d.shortBytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	c.prepared[stmt] = id
+	return id, nil
+}
+
+// execute executes the prepared statement with the values, and returns its response. The
+// statement is prepared again if the node has evicted it from its cache of statements
+func (c *conn) execute(stmt string, consistency uint16, values ...[]byte) (*frame, error) {
+	for i := 0; ; i++ {
+		id, ok := c.prepared[stmt]
+		if !ok {
+			var err error
+			if id, err = c.prepare(stmt); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := c.roundTrip(executeFrame(id, consistency, values...))
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := errorForFrame(resp).(*cqlError); ok && e.code == errUnprepared && i == 0 {
+			delete(c.prepared, stmt)
+			continue
+		}
+		return resp, nil
+	}
+}
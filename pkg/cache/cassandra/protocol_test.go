@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"testing"
+)
+
+func TestDecoderRows(t *testing.T) {
+	e := &encoder{}
+	e.int(metadataHasMorePages)
+	e.int(4)
+	e.bytes([]byte("paging-state"))
+	for i, col := range []func(){
+		func() { e.short(0x0000); e.string("org.apache.cassandra.db.marshal.DateType") },
+		func() { e.short(0x0021); e.short(0x000d); e.short(0x0020); e.short(0x0009) },
+		func() {
+			e.short(0x0030)
+			e.string("ks")
+			e.string("udt")
+			e.short(1)
+			e.string("field")
+			e.short(0x000d)
+		},
+		func() { e.short(0x0031); e.short(2); e.short(0x0002); e.short(0x0003) },
+	} {
+		e.string("ks")
+		e.string("table")
+		e.string("c" + string(rune('0'+i)))
+		col()
+	}
+	e.int(1)
+	e.bytes([]byte("a"))
+	e.bytes(nil)
+	e.bytes([]byte{})
+	e.bytes([]byte("d"))
+
+	d := &decoder{b: e.b}
+	rows := d.rows()
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if len(rows) != 1 || len(rows[0]) != 4 {
+		t.Fatalf("expected 1 row of 4 columns got %v", rows)
+	}
+	if string(rows[0][0]) != "a" || rows[0][1] != nil || rows[0][2] == nil ||
+		string(rows[0][3]) != "d" {
+		t.Errorf("unexpected row %q", rows[0])
+	}
+
+	// it should fail when the body is truncated
+	d = &decoder{b: e.b[:len(e.b)-1]}
+	if d.rows(); d.err != errShortFrame {
+		t.Errorf("expected %v got %v", errShortFrame, d.err)
+	}
+}
+
+func TestErrorForFrame(t *testing.T) {
+	e := &encoder{}
+	e.int(0x1000)
+	e.string("Cannot achieve consistency level LOCAL_QUORUM")
+	err := errorForFrame(&frame{opcode: opError, body: e.b})
+	const expected = "cassandra error 0x1000: Cannot achieve consistency level LOCAL_QUORUM"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+	if err = errorForFrame(&frame{opcode: opError}); err != errShortFrame {
+		t.Errorf("expected %v got %v", errShortFrame, err)
+	}
+	if err = errorForFrame(&frame{opcode: opResult}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// ring is the token ring of a cluster, which maps each token to the node that owns it
+type ring struct {
+	tokens []int64
+	hosts  []*host
+}
+
+// newRing returns the ring of the nodes' tokens
+func newRing(tokens map[int64]*host) *ring {
+	r := &ring{tokens: make([]int64, 0, len(tokens)), hosts: make([]*host, 0, len(tokens))}
+	for t := range tokens {
+		r.tokens = append(r.tokens, t)
+	}
+	sort.Slice(r.tokens, func(i, j int) bool { return r.tokens[i] < r.tokens[j] })
+	for _, t := range r.tokens {
+		r.hosts = append(r.hosts, tokens[t])
+	}
+	return r
+}
+
+// owner returns the node that owns the token, which is the node of the first ring token
+// that is greater than or equal to it. When dc is not empty, the first node of the data
+// center in ring order is returned instead, or nil if there is none
+func (r *ring) owner(token int64, dc string) *host {
+	if len(r.tokens) == 0 {
+		return nil
+	}
+	i := sort.Search(len(r.tokens), func(i int) bool { return r.tokens[i] >= token })
+	for j := 0; j < len(r.hosts); j++ {
+		h := r.hosts[(i+j)%len(r.hosts)]
+		if dc == "" || h.dc == dc {
+			return h
+		}
+	}
+	return nil
+}
+
+// murmur3Token returns the token of a partition key under Cassandra's Murmur3Partitioner,
+// which is the first half of the key's 128-bit x64 MurmurHash3. Cassandra's implementation
+// sign-extends the bytes of the key's tail, so keys with non-ASCII tails hash differently
+// than with the reference implementation
+func murmur3Token(key []byte) int64 {
+	const c1, c2 = 0x87c37b91114253d5, 0x4cf5ad432745937f
+	var h1, h2 uint64
+	n := len(key)
+	for i := 0; i+16 <= n; i += 16 {
+		k1 := binary.LittleEndian.Uint64(key[i:])
+		k2 := binary.LittleEndian.Uint64(key[i+8:])
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+	tail := key[n&^15:]
+	var k1, k2 uint64
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 ^= uint64(int64(int8(tail[i]))) << (uint(i-8) * 8)
+	}
+	if len(tail) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	for i := len(tail) - 1; i >= 0 && i < 8; i-- {
+		k1 ^= uint64(int64(int8(tail[i]))) << (uint(i) * 8)
+	}
+	if len(tail) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	token := int64(h1)
+	// the minimum token is reserved by the partitioner
+	if token == math.MinInt64 {
+		return math.MaxInt64
+	}
+	return token
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMurmur3Token(t *testing.T) {
+	tests := []struct {
+		key      []byte
+		expected int64
+	}{
+		{nil, 0},
+		{[]byte{0}, 0x4610abe56eff5cb5},
+		{[]byte{0, 1}, 0x7cb3f5c58dab264c},
+		{[]byte("hello"), -3758069500696749310},
+	}
+	for i, test := range tests {
+		if token := murmur3Token(test.key); token != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, token)
+		}
+	}
+
+	// keys longer than a block, and with tails of every length, should hash consistently
+	key := []byte("a cache key that is longer than sixteen bytes \xff\xfe\xfd")
+	for n := 0; n <= len(key); n++ {
+		if murmur3Token(key[:n]) != murmur3Token(append([]byte{}, key[:n]...)) {
+			t.Errorf("expected consistent token for %d-byte key", n)
+		}
+		if n > 0 && murmur3Token(key[:n]) == math.MinInt64 {
+			t.Errorf("unexpected minimum token for %d-byte key", n)
+		}
+	}
+}
+
+func TestRingOwner(t *testing.T) {
+	h1 := &host{addr: "h1", dc: "dc1"}
+	h2 := &host{addr: "h2", dc: "dc2"}
+	h3 := &host{addr: "h3", dc: "dc1"}
+	r := newRing(map[int64]*host{-100: h1, 0: h2, 100: h3})
+
+	tests := []struct {
+		token    int64
+		dc       string
+		expected *host
+	}{
+		{-200, "", h1},
+		{-100, "", h1},
+		{-99, "", h2},
+		{50, "", h3},
+		{101, "", h1},
+		{-99, "dc1", h3},
+		{101, "dc2", h2},
+		{0, "dc3", nil},
+	}
+	for i, test := range tests {
+		if h := r.owner(test.token, test.dc); h != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, h)
+		}
+	}
+
+	if h := newRing(nil).owner(0, ""); h != nil {
+		t.Errorf("expected nil host got %v", h)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package compression provides the codecs used to compress the values stored in a cache
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// Codec identifies the compression codec of a cache value, and is written as its first byte,
+// so that values stored with different codecs can be read regardless of the configured codec
+type Codec byte
+
+const (
+	// None indicates the value is not compressed
+	None Codec = iota
+	// Snappy indicates the value is compressed in the Snappy block format
+	Snappy
+	// Gzip indicates the value is compressed in the gzip format
+	Gzip
+)
+
+// Names is a map of Codecs keyed by their configuration name
+var Names = map[string]Codec{
+	"none":   None,
+	"snappy": Snappy,
+	"gzip":   Gzip,
+}
+
+// ErrEmptyValue indicates the value has no codec header
+var ErrEmptyValue = errors.New("compressed value has no codec header")
+
+// ErrUnknownCodec indicates the value's codec header is not a known Codec
+var ErrUnknownCodec = errors.New("compressed value has an unknown codec")
+
+// Encode returns data compressed with the codec and prefixed with the codec's header. When
+// compression does not reduce the size of data, it is prefixed with the None codec instead.
+// The returned slice never shares memory with data
+func Encode(codec Codec, data []byte) []byte {
+	var b []byte
+	switch codec {
+	case Snappy:
+		b = make([]byte, snappy.MaxEncodedLen(len(data))+1)
+		b = b[:len(snappy.Encode(b[1:], data))+1]
+	case Gzip:
+		buf := bytes.NewBuffer(make([]byte, 1, len(data)/4+1))
+		w := gzip.NewWriter(buf)
+		w.Write(data)
+		w.Close()
+		b = buf.Bytes()
+	}
+	if b == nil || len(b) > len(data) {
+		codec = None
+		b = make([]byte, len(data)+1)
+		copy(b[1:], data)
+	}
+	b[0] = byte(codec)
+	return b
+}
+
+// Decode returns the value of data encoded by Encode
+func Decode(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyValue
+	}
+	switch Codec(data[0]) {
+	case None:
+		return data[1:], nil
+	case Snappy:
+		return snappy.Decode(nil, data[1:])
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, ErrUnknownCodec
+}
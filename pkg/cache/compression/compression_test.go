@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compression
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+
+	data := []byte(strings.Repeat(`{"metric":{"__name__":"up"},"values":[[1577836800,"1"]]}`, 100))
+
+	for name, codec := range Names {
+		b := Encode(codec, data)
+		if Codec(b[0]) != codec {
+			t.Errorf("%s: expected codec %d got %d", name, codec, b[0])
+		}
+		if codec != None && len(b) >= len(data) {
+			t.Errorf("%s: expected compressed size less than %d got %d", name, len(data), len(b))
+		}
+		d, err := Decode(b)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(d, data) {
+			t.Errorf("%s: decoded value does not match", name)
+		}
+	}
+
+}
+
+func TestEncodeIncompressible(t *testing.T) {
+
+	data := []byte{0x7f}
+	b := Encode(Gzip, data)
+	if Codec(b[0]) != None {
+		t.Errorf("expected codec %d got %d", None, b[0])
+	}
+	if !bytes.Equal(b[1:], data) {
+		t.Errorf("expected %v got %v", data, b[1:])
+	}
+
+	// the encoded value must not share memory with data
+	b[1] = 0
+	if data[0] != 0x7f {
+		t.Error("encoded value shares memory with data")
+	}
+
+}
+
+func TestDecodeErrors(t *testing.T) {
+
+	_, err := Decode(nil)
+	if err != ErrEmptyValue {
+		t.Errorf("expected %v got %v", ErrEmptyValue, err)
+	}
+
+	_, err = Decode([]byte{0xff, 0})
+	if err != ErrUnknownCodec {
+		t.Errorf("expected %v got %v", ErrUnknownCodec, err)
+	}
+
+	_, err = Decode([]byte{byte(Gzip), 0})
+	if err == nil {
+		t.Error("expected error for invalid gzip value")
+	}
+
+}
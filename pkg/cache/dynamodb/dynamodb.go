@@ -0,0 +1,502 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dynamodb is the DynamoDB implementation of the Trickster Cache. Objects are stored
+// as items of a DynamoDB table, so that a durable cache can be shared by serverless
+// deployments of Trickster that are unable to run a cache server alongside them
+package dynamodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+const (
+	// targetPrefix prefixes the operation name in the X-Amz-Target header of each request
+	targetPrefix = "DynamoDB_20120810."
+	contentType  = "application/x-amz-json-1.0"
+
+	// valueAttribute holds the cached object. It is omitted for empty objects
+	valueAttribute = "cache_value"
+	// writtenAttribute holds the time an item was written, in nanoseconds since the epoch,
+	// which orders concurrent writes of the same key
+	writtenAttribute = "written_at"
+
+	// maxItemSize is the largest item, including its attribute names, that DynamoDB accepts
+	maxItemSize = 400 * 1024
+	// maxBatchRetries is the most times the unprocessed items of a batch write are retried
+	maxBatchRetries = 5
+	// batchRetryBackoff is the wait before the first retry of unprocessed items, which
+	// doubles with each further retry
+	batchRetryBackoff = 50 * time.Millisecond
+)
+
+// ErrItemTooLarge is returned when an object is too large to be stored as a DynamoDB item
+var ErrItemTooLarge = errors.New("object exceeds the dynamodb item size limit")
+
+// Cache represents a DynamoDB cache object that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	locker locks.NamedLocker
+
+	client     *http.Client
+	endpoint   string
+	creds      sigv4.Credentials
+	readPacer  *pacer
+	writePacer *pacer
+	now        func() time.Time
+	sleep      func(time.Duration)
+}
+
+// attributeValue is a DynamoDB attribute value of the String, Number or Binary type
+type attributeValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+	B []byte `json:"B,omitempty"`
+}
+
+// item is a DynamoDB item, or the key of one, keyed by attribute name
+type item map[string]attributeValue
+
+// request is the body of each single-table request made by the cache
+type request struct {
+	TableName                 string            `json:"TableName"`
+	Key                       item              `json:"Key,omitempty"`
+	Item                      item              `json:"Item,omitempty"`
+	ConditionExpression       string            `json:"ConditionExpression,omitempty"`
+	UpdateExpression          string            `json:"UpdateExpression,omitempty"`
+	ProjectionExpression      string            `json:"ProjectionExpression,omitempty"`
+	FilterExpression          string            `json:"FilterExpression,omitempty"`
+	ExpressionAttributeNames  map[string]string `json:"ExpressionAttributeNames,omitempty"`
+	ExpressionAttributeValues item              `json:"ExpressionAttributeValues,omitempty"`
+	ExclusiveStartKey         item              `json:"ExclusiveStartKey,omitempty"`
+	ReturnConsumedCapacity    string            `json:"ReturnConsumedCapacity,omitempty"`
+}
+
+// consumedCapacity reports the capacity units consumed by a request
+type consumedCapacity struct {
+	CapacityUnits float64 `json:"CapacityUnits"`
+}
+
+// writeRequest is a single delete of a BatchWriteItem request
+type writeRequest struct {
+	DeleteRequest struct {
+		Key item `json:"Key"`
+	} `json:"DeleteRequest"`
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect verifies that the configured table is accessible, and keyed by the key attribute
+func (c *Cache) Connect() error {
+	o := c.Config.DynamoDB
+	c.Logger.Info("connecting to dynamodb", tl.Pairs{"endpoint": o.Endpoint, "table": o.Table})
+
+	c.endpoint = strings.TrimSuffix(o.Endpoint, "/")
+	if c.endpoint == "" {
+		c.endpoint = "https://dynamodb." + o.Region + ".amazonaws.com"
+	}
+	c.creds = sigv4.NewCredentials(o.AccessKeyID, o.SecretAccessKey, o.SessionToken)
+	c.client = &http.Client{Timeout: o.Timeout}
+	c.readPacer = &pacer{rate: float64(o.ReadCapacityUnits)}
+	c.writePacer = &pacer{rate: float64(o.WriteCapacityUnits)}
+	if c.now == nil {
+		c.now = time.Now
+	}
+	if c.sleep == nil {
+		c.sleep = time.Sleep
+	}
+
+	var dt struct {
+		Table struct {
+			KeySchema []struct {
+				AttributeName string `json:"AttributeName"`
+				KeyType       string `json:"KeyType"`
+			} `json:"KeySchema"`
+		} `json:"Table"`
+	}
+	if err := c.do("DescribeTable", request{TableName: o.Table}, &dt); err != nil {
+		return err
+	}
+	for _, k := range dt.Table.KeySchema {
+		if k.KeyType == "HASH" && k.AttributeName != o.KeyAttribute {
+			return fmt.Errorf("dynamodb table %s is partitioned by %s, not the key_attribute %s",
+				o.Table, k.AttributeName, o.KeyAttribute)
+		}
+	}
+
+	// items are treated as misses once expired regardless, but are only deleted by
+	// DynamoDB when Time to Live is enabled on the table for the TTL attribute
+	var dttl struct {
+		TimeToLiveDescription struct {
+			TimeToLiveStatus string `json:"TimeToLiveStatus"`
+			AttributeName    string `json:"AttributeName"`
+		} `json:"TimeToLiveDescription"`
+	}
+	if err := c.do("DescribeTimeToLive", request{TableName: o.Table}, &dttl); err == nil &&
+		(dttl.TimeToLiveDescription.TimeToLiveStatus != "ENABLED" ||
+			dttl.TimeToLiveDescription.AttributeName != o.TTLAttribute) {
+		c.Logger.Warn("dynamodb table time to live is not enabled for the ttl attribute",
+			tl.Pairs{"table": o.Table, "ttlAttribute": o.TTLAttribute})
+	}
+	return nil
+}
+
+// Store places the the data into the DynamoDB table using the provided Key and TTL. When
+// Tricksters write the same key concurrently, a write is only applied if no later write
+// has been, so an object is never replaced by an older one
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+	o := c.Config.DynamoDB
+	if c.itemSize(cacheKey, data) > maxItemSize {
+		return ErrItemTooLarge
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	c.Logger.Debug("dynamodb cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
+
+	now := c.now()
+	written := attributeValue{N: strconv.FormatInt(now.UnixNano(), 10)}
+	it := item{
+		o.KeyAttribute:   {S: cacheKey},
+		o.TTLAttribute:   c.expiration(now, ttl),
+		writtenAttribute: written,
+	}
+	if len(data) > 0 {
+		it[valueAttribute] = attributeValue{B: data}
+	}
+	err = c.do("PutItem", request{
+		TableName:                 o.Table,
+		Item:                      it,
+		ConditionExpression:       "attribute_not_exists(#k) OR #w <= :w",
+		ExpressionAttributeNames:  map[string]string{"#k": o.KeyAttribute, "#w": writtenAttribute},
+		ExpressionAttributeValues: item{":w": written},
+	}, nil)
+	if isConditionalCheckFailed(err) {
+		c.Logger.Debug("dynamodb cache store superseded by a later write", tl.Pairs{"key": cacheKey})
+		return nil
+	}
+	return err
+}
+
+// Retrieve gets data from the DynamoDB table using the provided Key
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+
+	o := c.Config.DynamoDB
+	var out struct {
+		Item item `json:"Item"`
+	}
+	err := c.do("GetItem", request{TableName: o.Table, Key: c.key(cacheKey)}, &out)
+	if err == nil && out.Item == nil {
+		c.Logger.Debug("dynamodb cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	var data []byte
+	if err == nil {
+		data, err = cache.DecodeValue(c.Config, cacheKey, out.Item[valueAttribute].B)
+	}
+	if err != nil {
+		c.Logger.Debug("dynamodb cache retrieve failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+
+	// DynamoDB deletes expired items in the background, typically within a few days of
+	// their expiration, so expired items are treated as misses until then
+	if !allowExpired {
+		if secs, err := strconv.ParseInt(out.Item[o.TTLAttribute].N, 10, 64); err == nil &&
+			!c.now().Before(time.Unix(secs, 0)) {
+			c.Logger.Debug("dynamodb cache miss", tl.Pairs{"key": cacheKey, "reason": "expired"})
+			metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+			return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+		}
+	}
+
+	c.Logger.Debug("dynamodb cache retrieve", tl.Pairs{"key": cacheKey})
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	return data, status.LookupStatusHit, nil
+}
+
+// SetTTL updates the TTL for the provided cache object, if it is still present
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	o := c.Config.DynamoDB
+	err := c.do("UpdateItem", request{
+		TableName:                 o.Table,
+		Key:                       c.key(cacheKey),
+		UpdateExpression:          "SET #t = :t",
+		ConditionExpression:       "attribute_exists(#k)",
+		ExpressionAttributeNames:  map[string]string{"#k": o.KeyAttribute, "#t": o.TTLAttribute},
+		ExpressionAttributeValues: item{":t": c.expiration(c.now(), ttl)},
+	}, nil)
+	if err != nil && !isConditionalCheckFailed(err) {
+		c.Logger.Debug("dynamodb cache set ttl failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	}
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.Logger.Debug("dynamodb cache remove", tl.Pairs{"key": cacheKey})
+	err := c.do("DeleteItem", request{TableName: c.Config.DynamoDB.Table,
+		Key: c.key(cacheKey)}, nil)
+	if err != nil {
+		c.Logger.Error("dynamodb cache key delete failure",
+			tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+}
+
+// BulkRemove removes a list of objects from the cache, in batches of BatchSize. Batches are
+// paced to stay within WriteCapacityUnits, and items left unprocessed by DynamoDB, such as
+// when the table's throughput is exceeded, are retried with a backoff
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug("dynamodb cache bulk remove", tl.Pairs{})
+	o := c.Config.DynamoDB
+	for i := 0; i < len(cacheKeys); i += o.BatchSize {
+		end := i + o.BatchSize
+		if end > len(cacheKeys) {
+			end = len(cacheKeys)
+		}
+		reqs := make([]writeRequest, end-i)
+		for j, k := range cacheKeys[i:end] {
+			reqs[j].DeleteRequest.Key = c.key(k)
+		}
+		for attempt := 0; len(reqs) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				c.Logger.Error("dynamodb cache bulk delete failure",
+					tl.Pairs{"reason": "unprocessed items", "count": len(reqs)})
+				break
+			}
+			if attempt > 0 {
+				c.sleep(batchRetryBackoff << uint(attempt-1))
+			}
+			c.sleep(c.writePacer.wait(c.now()))
+			var out struct {
+				UnprocessedItems map[string][]writeRequest `json:"UnprocessedItems"`
+				ConsumedCapacity []consumedCapacity        `json:"ConsumedCapacity"`
+			}
+			err := c.do("BatchWriteItem", struct {
+				RequestItems           map[string][]writeRequest `json:"RequestItems"`
+				ReturnConsumedCapacity string                    `json:"ReturnConsumedCapacity"`
+			}{map[string][]writeRequest{o.Table: reqs}, "TOTAL"}, &out)
+			if err != nil {
+				c.Logger.Error("dynamodb cache bulk delete failure", tl.Pairs{"reason": err.Error()})
+				break
+			}
+			var units float64
+			for _, cc := range out.ConsumedCapacity {
+				units += cc.CapacityUnits
+			}
+			c.writePacer.consume(units, c.now())
+			unprocessed := out.UnprocessedItems[o.Table]
+			metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(reqs)-len(unprocessed)))
+			reqs = unprocessed
+		}
+	}
+}
+
+// Keys returns the keys of the cached objects that begin with prefix, including expired
+// items not yet deleted by DynamoDB. The table is scanned in full, with its pages paced to
+// stay within ReadCapacityUnits
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	o := c.Config.DynamoDB
+	in := request{
+		TableName:                o.Table,
+		ProjectionExpression:     "#k",
+		ExpressionAttributeNames: map[string]string{"#k": o.KeyAttribute},
+		ReturnConsumedCapacity:   "TOTAL",
+	}
+	if prefix != "" {
+		in.FilterExpression = "begins_with(#k, :p)"
+		in.ExpressionAttributeValues = item{":p": {S: prefix}}
+	}
+	var keys []string
+	for {
+		c.sleep(c.readPacer.wait(c.now()))
+		var out struct {
+			Items            []item            `json:"Items"`
+			LastEvaluatedKey item              `json:"LastEvaluatedKey"`
+			ConsumedCapacity *consumedCapacity `json:"ConsumedCapacity"`
+		}
+		if err := c.do("Scan", in, &out); err != nil {
+			return nil, err
+		}
+		if out.ConsumedCapacity != nil {
+			c.readPacer.consume(out.ConsumedCapacity.CapacityUnits, c.now())
+		}
+		for _, it := range out.Items {
+			keys = append(keys, it[o.KeyAttribute].S)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// key returns the primary key of the item storing cacheKey
+func (c *Cache) key(cacheKey string) item {
+	return item{c.Config.DynamoDB.KeyAttribute: {S: cacheKey}}
+}
+
+// expiration returns the TTL attribute value of an item stored at now for ttl, which
+// DynamoDB requires in seconds since the epoch
+func (c *Cache) expiration(now time.Time, ttl time.Duration) attributeValue {
+	return attributeValue{N: strconv.FormatInt(now.Add(ttl).Unix(), 10)}
+}
+
+// itemSize returns the size DynamoDB counts for the item storing data at cacheKey, which
+// is the total length of its attribute names and values
+func (c *Cache) itemSize(cacheKey string, data []byte) int {
+	// numbers are counted as up to 21 bytes
+	const numberSize = 21
+	o := c.Config.DynamoDB
+	return len(o.KeyAttribute) + len(cacheKey) + len(valueAttribute) + len(data) +
+		len(o.TTLAttribute) + numberSize + len(writtenAttribute) + numberSize
+}
+
+// do sends a signed request for the DynamoDB operation, and decodes the response into out
+func (c *Cache) do(operation string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("X-Amz-Target", targetPrefix+operation)
+	sigv4.Sign(r, body, c.creds, c.Config.DynamoDB.Region, "dynamodb", c.now())
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &apiError{}
+		if json.Unmarshal(b, e) != nil || e.Type == "" {
+			return fmt.Errorf("dynamodb responded with status %d", resp.StatusCode)
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// apiError is the error document of a failed request
+type apiError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// code returns the error's exception name, without the namespace that prefixes it
+func (e *apiError) code() string {
+	return e.Type[strings.LastIndex(e.Type, "#")+1:]
+}
+
+func (e *apiError) Error() string {
+	return "dynamodb error " + e.code() + ": " + e.Message
+}
+
+// isConditionalCheckFailed returns true if err is the rejection of a conditional write
+func isConditionalCheckFailed(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.code() == "ConditionalCheckFailedException"
+}
+
+// pacer spaces requests so that the capacity units they consume average no more than rate
+// per second. A rate of 0 does not limit requests
+type pacer struct {
+	rate float64
+	next time.Time
+	mtx  sync.Mutex
+}
+
+// wait returns how long to wait at now before the next request
+func (p *pacer) wait(now time.Time) time.Duration {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if d := p.next.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// consume accounts for the capacity units consumed by a request completed at now
+func (p *pacer) consume(units float64, now time.Time) {
+	if p.rate <= 0 || units <= 0 {
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(units / p.rate * float64(time.Second)))
+}
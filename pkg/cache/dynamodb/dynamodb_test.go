@@ -0,0 +1,568 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	do "github.com/tricksterproxy/trickster/pkg/cache/dynamodb/options"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+const cacheType = "dynamodb"
+const cacheKey = "cacheKey"
+const testTable = "trickster-test"
+
+// testDynamoDB is an in-memory DynamoDB API serving a single table keyed by cache_key
+type testDynamoDB struct {
+	mtx   sync.Mutex
+	items map[string]item
+	// unprocessed is the number of batch writes that leave all of their items unprocessed
+	unprocessed int
+	ttlStatus   string
+	requests    int
+}
+
+func newTestDynamoDB() (*testDynamoDB, *httptest.Server) {
+	s := &testDynamoDB{items: make(map[string]item), ttlStatus: "ENABLED"}
+	return s, httptest.NewServer(s)
+}
+
+func writeError(w http.ResponseWriter, code string) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#` + code +
+		`","message":"test"}`))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, _ := json.Marshal(v)
+	w.Write(b)
+}
+
+func (s *testDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests++
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), sigv4.Algorithm+" Credential=test/") ||
+		!strings.Contains(r.Header.Get("Authorization"), "/dynamodb/aws4_request") ||
+		r.Header.Get(sigv4.HeaderAmzSHA256) != sigv4.SHA256Hex(body) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != contentType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var in struct {
+		request
+		RequestItems map[string][]writeRequest `json:"RequestItems"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeError(w, "SerializationException")
+		return
+	}
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	if op == "BatchWriteItem" {
+		in.TableName = testTable
+		for k := range in.RequestItems {
+			in.TableName = k
+		}
+	}
+	if in.TableName != testTable {
+		writeError(w, "ResourceNotFoundException")
+		return
+	}
+
+	switch op {
+	case "DescribeTable":
+		w.Write([]byte(`{"Table":{"KeySchema":[{"AttributeName":"cache_key","KeyType":"HASH"}]}}`))
+	case "DescribeTimeToLive":
+		w.Write([]byte(`{"TimeToLiveDescription":{"TimeToLiveStatus":"` + s.ttlStatus +
+			`","AttributeName":"expires_at"}}`))
+	case "PutItem":
+		k := in.Item["cache_key"].S
+		if cur, ok := s.items[k]; ok {
+			c, _ := strconv.ParseInt(cur[writtenAttribute].N, 10, 64)
+			n, _ := strconv.ParseInt(in.ExpressionAttributeValues[":w"].N, 10, 64)
+			if c > n {
+				writeError(w, "ConditionalCheckFailedException")
+				return
+			}
+		}
+		s.items[k] = in.Item
+		w.Write([]byte("{}"))
+	case "GetItem":
+		it, ok := s.items[in.Key["cache_key"].S]
+		if !ok {
+			w.Write([]byte("{}"))
+			return
+		}
+		writeJSON(w, map[string]item{"Item": it})
+	case "UpdateItem":
+		it, ok := s.items[in.Key["cache_key"].S]
+		if !ok {
+			writeError(w, "ConditionalCheckFailedException")
+			return
+		}
+		it["expires_at"] = in.ExpressionAttributeValues[":t"]
+		w.Write([]byte("{}"))
+	case "DeleteItem":
+		delete(s.items, in.Key["cache_key"].S)
+		w.Write([]byte("{}"))
+	case "BatchWriteItem":
+		reqs := in.RequestItems[testTable]
+		if len(reqs) > do.MaxBatchSize {
+			writeError(w, "ValidationException")
+			return
+		}
+		if s.unprocessed > 0 {
+			s.unprocessed--
+			writeJSON(w, map[string]interface{}{"UnprocessedItems": in.RequestItems})
+			return
+		}
+		for _, req := range reqs {
+			delete(s.items, req.DeleteRequest.Key["cache_key"].S)
+		}
+		writeJSON(w, map[string]interface{}{"UnprocessedItems": map[string]interface{}{},
+			"ConsumedCapacity": []consumedCapacity{{CapacityUnits: float64(len(reqs))}}})
+	case "Scan":
+		// scans one item per page, to exercise pagination
+		var keys []string
+		for k := range s.items {
+			if k > in.ExclusiveStartKey["cache_key"].S {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		out := map[string]interface{}{"Items": []item{},
+			"ConsumedCapacity": consumedCapacity{CapacityUnits: 0.5}}
+		if len(keys) > 0 {
+			if strings.HasPrefix(keys[0], in.ExpressionAttributeValues[":p"].S) {
+				out["Items"] = []item{{"cache_key": {S: keys[0]}}}
+			}
+			if len(keys) > 1 {
+				out["LastEvaluatedKey"] = item{"cache_key": {S: keys[0]}}
+			}
+		}
+		writeJSON(w, out)
+	default:
+		writeError(w, "UnknownOperationException")
+	}
+}
+
+func newCacheConfig(endpoint string) co.Options {
+	o := do.NewOptions()
+	o.Endpoint = endpoint
+	o.Table = testTable
+	o.AccessKeyID = "test"
+	o.SecretAccessKey = "secret"
+	return co.Options{CacheType: cacheType, DynamoDB: o}
+}
+
+func newTestCache(t *testing.T) (*Cache, *testDynamoDB, func()) {
+	s, ts := newTestDynamoDB()
+	cacheConfig := newCacheConfig(ts.URL)
+	c := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, s, func() {
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	cacheConfig := newCacheConfig("")
+	c := Cache{Config: &cacheConfig}
+	cfg := c.Configuration()
+	if cfg.CacheType != cacheType {
+		t.Errorf("expected %s got %s", cacheType, cfg.CacheType)
+	}
+}
+
+func TestDynamoDBCache_SetLocker(t *testing.T) {
+	c := Cache{}
+	c.SetLocker(locks.NewNamedLocker())
+	if c.Locker() == nil {
+		t.Error("expected non-nil locker")
+	}
+}
+
+func TestDynamoDBCache_Connect(t *testing.T) {
+	_, _, closer := newTestCache(t)
+	closer()
+
+	// a table without time to live enabled is usable
+	s, ts := newTestDynamoDB()
+	defer ts.Close()
+	s.ttlStatus = "DISABLED"
+	cacheConfig := newCacheConfig(ts.URL)
+	c := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error")}
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDynamoDBCache_ConnectFailed(t *testing.T) {
+	_, ts := newTestDynamoDB()
+	defer ts.Close()
+	cacheConfig := newCacheConfig(ts.URL)
+	cacheConfig.DynamoDB.Table = "missing"
+	c := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error")}
+	const expected = "dynamodb error ResourceNotFoundException: test"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+
+	cacheConfig.DynamoDB.Table = testTable
+	cacheConfig.DynamoDB.KeyAttribute = "other"
+	const expected2 = "dynamodb table trickster-test is partitioned by cache_key, not the key_attribute other"
+	if err := c.Connect(); err == nil || err.Error() != expected2 {
+		t.Errorf("expected error '%s' got '%v'", expected2, err)
+	}
+
+	cacheConfig.DynamoDB.Endpoint = "http://127.0.0.1:1"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unavailable endpoint")
+	}
+}
+
+func TestDynamoDBCache_StoreRetrieve(t *testing.T) {
+
+	c, s, closer := newTestCache(t)
+	defer closer()
+
+	// it should store a value
+	if err := c.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second); err != nil {
+		t.Error(err)
+	}
+	it, ok := s.items[cacheKey]
+	if !ok {
+		t.Fatal("expected item under the cache key")
+	}
+	if exp, _ := strconv.ParseInt(it["expires_at"].N, 10, 64); exp < time.Now().Add(59*time.Second).Unix() {
+		t.Errorf("unexpected expiration %d", exp)
+	}
+
+	// it should retrieve a value
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// it should miss a key that was not stored
+	_, ls, err = c.Retrieve("missing", false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// empty values are stored without a value attribute
+	if err = c.Store("empty", []byte{}, time.Minute); err != nil {
+		t.Error(err)
+	}
+	if data, ls, err = c.Retrieve("empty", false); err != nil || ls != status.LookupStatusHit ||
+		len(data) != 0 {
+		t.Errorf("expected empty hit, got %s %s %v", string(data), ls, err)
+	}
+}
+
+func TestDynamoDBCache_StoreConcurrent(t *testing.T) {
+
+	c, _, closer := newTestCache(t)
+	defer closer()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	if err := c.Store(cacheKey, []byte("newer"), time.Minute); err != nil {
+		t.Error(err)
+	}
+
+	// a write that began before the stored one does not replace it
+	c.now = func() time.Time { return now.Add(-time.Second) }
+	if err := c.Store(cacheKey, []byte("older"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if data, _, _ := c.Retrieve(cacheKey, false); string(data) != "newer" {
+		t.Errorf("expected %s got %s", "newer", string(data))
+	}
+
+	c.now = func() time.Time { return now.Add(time.Second) }
+	if err := c.Store(cacheKey, []byte("newest"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if data, _, _ := c.Retrieve(cacheKey, false); string(data) != "newest" {
+		t.Errorf("expected %s got %s", "newest", string(data))
+	}
+}
+
+func TestDynamoDBCache_StoreTooLarge(t *testing.T) {
+
+	c, s, closer := newTestCache(t)
+	defer closer()
+
+	if err := c.Store(cacheKey, make([]byte, maxItemSize), time.Minute); err != ErrItemTooLarge {
+		t.Errorf("expected %v got %v", ErrItemTooLarge, err)
+	}
+	if len(s.items) != 0 {
+		t.Errorf("expected %d items got %d", 0, len(s.items))
+	}
+}
+
+func TestDynamoDBCache_RetrieveExpired(t *testing.T) {
+
+	c, _, closer := newTestCache(t)
+	defer closer()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	if err := c.Store(cacheKey, []byte("data"), time.Second); err != nil {
+		t.Error(err)
+	}
+	c.now = func() time.Time { return now.Add(2 * time.Second) }
+
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err != cache.ErrKNF || ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected expired miss, got %s %v", ls, err)
+	}
+
+	data, ls, err := c.Retrieve(cacheKey, true)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected expired hit, got %s %s %v", string(data), ls, err)
+	}
+
+	// SetTTL extends the expiration
+	c.SetTTL(cacheKey, time.Minute)
+	if _, ls, _ = c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// SetTTL of a missing key does not create it
+	c.SetTTL("missing", time.Minute)
+	if _, ls, _ = c.Retrieve("missing", false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestDynamoDBCache_Encryption(t *testing.T) {
+
+	c, s, closer := newTestCache(t)
+	defer closer()
+	c.Config.CompressionCodec = "snappy"
+	c.Config.Encryption = eo.NewOptions()
+	c.Config.Encryption.ActiveKeyID = "k1"
+	c.Config.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := c.Config.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if v := s.items[cacheKey][valueAttribute].B; strings.Contains(string(v), "data") {
+		t.Error("value was stored in plaintext")
+	}
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected hit, got %s %s %v", string(data), ls, err)
+	}
+}
+
+func TestDynamoDBCache_Remove(t *testing.T) {
+
+	c, _, closer := newTestCache(t)
+	defer closer()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.Remove(cacheKey)
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestDynamoDBCache_BulkRemove(t *testing.T) {
+
+	c, s, closer := newTestCache(t)
+	defer closer()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	var sleeps []time.Duration
+	c.sleep = func(d time.Duration) {
+		if d > 0 {
+			sleeps = append(sleeps, d)
+		}
+	}
+	// batches are paced to 10 WCU per second
+	c.writePacer.rate = 10
+
+	keys := make([]string, do.MaxBatchSize+1)
+	for i := range keys {
+		keys[i] = cacheKey + strconv.Itoa(i)
+		s.items[keys[i]] = item{"cache_key": {S: keys[i]}}
+	}
+	s.requests = 0
+	s.unprocessed = 1
+	c.BulkRemove(keys)
+	if len(s.items) != 0 {
+		t.Errorf("expected %d items got %d", 0, len(s.items))
+	}
+	// the first batch is unprocessed and retried
+	if s.requests != 3 {
+		t.Errorf("expected %d requests got %d", 3, s.requests)
+	}
+	expected := []time.Duration{batchRetryBackoff, 2500 * time.Millisecond}
+	if !reflect.DeepEqual(sleeps, expected) {
+		t.Errorf("expected %v got %v", expected, sleeps)
+	}
+
+	// items that remain unprocessed are abandoned after the retries
+	s.items[cacheKey] = item{"cache_key": {S: cacheKey}}
+	s.requests = 0
+	s.unprocessed = maxBatchRetries + 1
+	c.BulkRemove([]string{cacheKey})
+	if s.requests != maxBatchRetries+1 {
+		t.Errorf("expected %d requests got %d", maxBatchRetries+1, s.requests)
+	}
+	if _, ok := s.items[cacheKey]; !ok {
+		t.Error("expected unprocessed item to remain")
+	}
+}
+
+func TestDynamoDBCache_Keys(t *testing.T) {
+
+	c, s, closer := newTestCache(t)
+	defer closer()
+
+	for _, k := range []string{"a.dpc.1", "a.dpc.2", "a.opc.1", "b.dpc.1"} {
+		s.items[k] = item{"cache_key": {S: k}}
+	}
+
+	keys, err := c.Keys("a.dpc.")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"a.dpc.1", "a.dpc.2"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v got %v", expected, keys)
+	}
+
+	keys, err = c.Keys("c.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys got %v", keys)
+	}
+
+	// pages are paced to 1 RCU per second
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	var slept time.Duration
+	c.sleep = func(d time.Duration) { slept += d }
+	c.readPacer.rate = 1
+	if keys, _ = c.Keys(""); len(keys) != 4 {
+		t.Errorf("expected %d keys got %d", 4, len(keys))
+	}
+	// 4 pages of 0.5 RCU, the first of which is not delayed
+	if slept != 500*time.Millisecond+time.Second+1500*time.Millisecond {
+		t.Errorf("unexpected pacing delay %s", slept)
+	}
+
+	c.creds.AccessKeyID = "invalid"
+	if _, err = c.Keys(""); err == nil {
+		t.Error("expected error for rejected credentials")
+	}
+}
+
+func TestDynamoDBCache_Errors(t *testing.T) {
+
+	c, _, closer := newTestCache(t)
+	closer()
+
+	// requests to an unavailable endpoint fail
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err == nil {
+		t.Error("expected error")
+	}
+	if _, ls, err := c.Retrieve(cacheKey, false); err == nil || ls != status.LookupStatusError {
+		t.Errorf("expected error, got %s %v", ls, err)
+	}
+	c.Remove(cacheKey)
+	c.BulkRemove([]string{cacheKey})
+	c.SetTTL(cacheKey, time.Minute)
+
+	// requests with invalid credentials are denied
+	c, _, closer = newTestCache(t)
+	defer closer()
+	c.creds.AccessKeyID = "invalid"
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err == nil ||
+		err.Error() != "dynamodb responded with status 403" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, ls, err := c.Retrieve(cacheKey, false); err == nil || ls != status.LookupStatusError {
+		t.Errorf("expected error, got %s %v", ls, err)
+	}
+}
+
+func TestPacer(t *testing.T) {
+
+	now := time.Now()
+	p := &pacer{}
+	p.consume(100, now)
+	if d := p.wait(now); d != 0 {
+		t.Errorf("expected no delay got %s", d)
+	}
+
+	p = &pacer{rate: 4}
+	p.consume(2, now)
+	p.consume(2, now)
+	if d := p.wait(now); d != time.Second {
+		t.Errorf("expected %s got %s", time.Second, d)
+	}
+	if d := p.wait(now.Add(2 * time.Second)); d != 0 {
+		t.Errorf("expected no delay got %s", d)
+	}
+}
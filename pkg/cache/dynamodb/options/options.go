@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// MaxBatchSize is the most items that DynamoDB accepts in a single BatchWriteItem request
+const MaxBatchSize = 25
+
+// ErrMissingTable is returned when no table is configured
+var ErrMissingTable = errors.New("dynamodb table must be provided")
+
+// ErrInvalidEndpoint is returned when the endpoint is not an http or https URL
+var ErrInvalidEndpoint = errors.New("dynamodb endpoint must be an http or https URL")
+
+// ErrMissingAttribute is returned when the key or TTL attribute name is empty
+var ErrMissingAttribute = errors.New("dynamodb key_attribute and ttl_attribute must be provided")
+
+// ErrInvalidBatchSize is returned when the batch size is not between 1 and MaxBatchSize
+var ErrInvalidBatchSize = errors.New("dynamodb batch_size must be between 1 and 25")
+
+// ErrInvalidCapacityUnits is returned when a capacity unit limit is negative
+var ErrInvalidCapacityUnits = errors.New(
+	"dynamodb read_capacity_units and write_capacity_units must not be negative")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("dynamodb timeout_ms must be greater than 0")
+
+// Options is a collection of Configurations for storing cached data in a DynamoDB table
+type Options struct {
+	// Endpoint is the base URL of the DynamoDB API. The default is the AWS endpoint of the Region
+	Endpoint string `toml:"endpoint"`
+	// Table is the name of the table in which items are stored
+	Table string `toml:"table"`
+	// Region is the region of the table, which is used to sign requests
+	Region string `toml:"region"`
+	// KeyAttribute is the name of the table's string partition key, which holds the cache key
+	KeyAttribute string `toml:"key_attribute"`
+	// TTLAttribute is the name of the attribute holding each item's expiration, in seconds
+	// since the epoch. It should be enabled as the table's Time to Live attribute
+	TTLAttribute string `toml:"ttl_attribute"`
+	// AccessKeyID is the access key of the credentials. When empty, the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used
+	AccessKeyID string `toml:"access_key_id"`
+	// SecretAccessKey is the secret key of the credentials
+	SecretAccessKey string `toml:"secret_access_key"`
+	// SessionToken is the session token of temporary credentials
+	SessionToken string `toml:"session_token"`
+	// ReadCapacityUnits limits the read capacity per second consumed by scans of the table,
+	// so that they do not exhaust its provisioned throughput. 0 means no limit
+	ReadCapacityUnits int `toml:"read_capacity_units"`
+	// WriteCapacityUnits limits the write capacity per second consumed by batch writes to
+	// the table. 0 means no limit
+	WriteCapacityUnits int `toml:"write_capacity_units"`
+	// BatchSize is the number of items in each BatchWriteItem request
+	BatchSize int `toml:"batch_size"`
+	// TimeoutMS is the timeout of each request to the DynamoDB API
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new DynamoDB Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Region:       d.DefaultDynamoDBRegion,
+		KeyAttribute: d.DefaultDynamoDBKeyAttribute,
+		TTLAttribute: d.DefaultDynamoDBTTLAttribute,
+		BatchSize:    d.DefaultDynamoDBBatchSize,
+		TimeoutMS:    d.DefaultDynamoDBTimeoutMS,
+		Timeout:      time.Duration(d.DefaultDynamoDBTimeoutMS) * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if o.Table == "" {
+		return ErrMissingTable
+	}
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	if o.KeyAttribute == "" || o.TTLAttribute == "" {
+		return ErrMissingAttribute
+	}
+	if o.BatchSize < 1 || o.BatchSize > MaxBatchSize {
+		return ErrInvalidBatchSize
+	}
+	if o.ReadCapacityUnits < 0 || o.WriteCapacityUnits < 0 {
+		return ErrInvalidCapacityUnits
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
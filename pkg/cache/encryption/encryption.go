@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package encryption provides the authenticated encryption of the values stored in a cache
+package encryption
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+)
+
+// envelopeVersion is the first byte of each encrypted value, identifying its envelope format:
+// version, key ID length, key ID, nonce and then the sealed value
+const envelopeVersion = 1
+
+// ErrInvalidEnvelope indicates the value is not an encrypted value
+var ErrInvalidEnvelope = errors.New("value is not an encrypted envelope")
+
+// ErrUnknownKey indicates the value was encrypted with a key that is not configured
+var ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+
+// Seal returns data encrypted with the active key, in an envelope that identifies the key.
+// The cache key is authenticated with the value, so it can't be read under another key
+func Seal(o *options.Options, cacheKey string, data []byte) ([]byte, error) {
+	aead, ok := o.Ciphers[o.ActiveKeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	hl := 2 + len(o.ActiveKeyID)
+	b := make([]byte, hl+aead.NonceSize(), hl+aead.NonceSize()+len(data)+aead.Overhead())
+	b[0] = envelopeVersion
+	b[1] = byte(len(o.ActiveKeyID))
+	copy(b[2:], o.ActiveKeyID)
+	nonce := b[hl:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(b, nonce, data, []byte(cacheKey)), nil
+}
+
+// Open returns the value of data encrypted by Seal, using the key identified by its envelope
+func Open(o *options.Options, cacheKey string, data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != envelopeVersion || len(data) < 2+int(data[1]) {
+		return nil, ErrInvalidEnvelope
+	}
+	hl := 2 + int(data[1])
+	aead, ok := o.Ciphers[string(data[2:hl])]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(data) < hl+aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	nonce := data[hl : hl+aead.NonceSize()]
+	return aead.Open(nil, nonce, data[hl+aead.NonceSize():], []byte(cacheKey))
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package encryption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+)
+
+func testOptions(t *testing.T) *options.Options {
+	o := options.NewOptions()
+	o.ActiveKeyID = "k1"
+	o.Keys["k1"] = &options.KeyOptions{Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}
+	o.Keys["k2"] = &options.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := o.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestSealOpen(t *testing.T) {
+
+	o := testOptions(t)
+	data := []byte("trickster")
+
+	b, err := Seal(o, "cacheKey", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, data) {
+		t.Error("sealed value contains the plaintext")
+	}
+	if string(b[2:4]) != "k1" {
+		t.Errorf("expected key ID %s got %s", "k1", string(b[2:4]))
+	}
+
+	// the value should remain readable after the active key is rotated
+	o.ActiveKeyID = "k2"
+	d, err := Open(o, "cacheKey", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, data) {
+		t.Errorf("expected %s got %s", string(data), string(d))
+	}
+
+	// the value should not be readable under another cache key
+	if _, err = Open(o, "cacheKey2", b); err == nil {
+		t.Error("expected error for mismatched cache key")
+	}
+
+	// the value should not be readable once its key is removed
+	delete(o.Ciphers, "k1")
+	if _, err = Open(o, "cacheKey", b); err != ErrUnknownKey {
+		t.Errorf("expected %v got %v", ErrUnknownKey, err)
+	}
+
+	o.ActiveKeyID = "k3"
+	if _, err = Seal(o, "cacheKey", data); err != ErrUnknownKey {
+		t.Errorf("expected %v got %v", ErrUnknownKey, err)
+	}
+
+}
+
+func TestOpenInvalidEnvelope(t *testing.T) {
+
+	o := testOptions(t)
+	for i, b := range [][]byte{nil, {1}, {2, 2, 'k', '1'}, {1, 9, 'k', '1'}, {1, 2, 'k', '1', 0}} {
+		if _, err := Open(o, "cacheKey", b); err != ErrInvalidEnvelope {
+			t.Errorf("test %d: expected %v got %v", i, ErrInvalidEnvelope, err)
+		}
+	}
+
+}
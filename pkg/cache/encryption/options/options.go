@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrNoActiveKey is returned when keys are configured without naming the key to encrypt with
+var ErrNoActiveKey = errors.New("encryption active_key_id must be one of the configured keys")
+
+// ErrKeyIDTooLong is returned when a key ID is too long to embed in an encrypted value
+var ErrKeyIDTooLong = errors.New("encryption key IDs must be 1 to 255 bytes long")
+
+// Options is a collection of Configurations for encrypting the values stored in a cache
+type Options struct {
+	// ActiveKeyID is the ID of the key with which values are encrypted when they are stored
+	ActiveKeyID string `toml:"active_key_id"`
+	// Keys is a map of the keys with which values can be decrypted, keyed by their ID. Keys
+	// that are no longer active are retained to decrypt values stored before a key rotation
+	Keys map[string]*KeyOptions `toml:"keys"`
+
+	// Ciphers is a map of the AES-GCM ciphers of the Keys, keyed by their ID
+	Ciphers map[string]cipher.AEAD `toml:"-"`
+}
+
+// KeyOptions is a collection of Configurations for a single encryption key, which is a
+// base64-encoded AES-128, AES-192 or AES-256 key provided by exactly one of its options
+type KeyOptions struct {
+	// Key is the base64-encoded key
+	Key string `toml:"key"`
+	// KeyFile is the path to a file whose contents are the base64-encoded key
+	KeyFile string `toml:"key_file"`
+	// KeyEnv is the name of an environment variable whose value is the base64-encoded key
+	KeyEnv string `toml:"key_env"`
+}
+
+// NewOptions returns a new Encryption Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{Keys: make(map[string]*KeyOptions)}
+}
+
+// Clone returns an exact copy of the Options
+func (o *Options) Clone() *Options {
+	o2 := &Options{ActiveKeyID: o.ActiveKeyID, Keys: make(map[string]*KeyOptions, len(o.Keys)),
+		Ciphers: o.Ciphers}
+	for k, v := range o.Keys {
+		if v != nil {
+			o2.Keys[k] = &KeyOptions{Key: v.Key, KeyFile: v.KeyFile, KeyEnv: v.KeyEnv}
+		}
+	}
+	return o2
+}
+
+// Enabled returns true if values are encrypted
+func (o *Options) Enabled() bool {
+	return o.ActiveKeyID != ""
+}
+
+// LoadKeys validates the Options, reads each key from its configured source, and sets the
+// Ciphers of the keys
+func (o *Options) LoadKeys() error {
+	if !o.Enabled() {
+		if len(o.Keys) > 0 {
+			return ErrNoActiveKey
+		}
+		o.Ciphers = nil
+		return nil
+	}
+	if _, ok := o.Keys[o.ActiveKeyID]; !ok {
+		return ErrNoActiveKey
+	}
+	ciphers := make(map[string]cipher.AEAD, len(o.Keys))
+	for id, k := range o.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return ErrKeyIDTooLong
+		}
+		if k == nil {
+			return fmt.Errorf("encryption key %s has no key, key_file or key_env", id)
+		}
+		key, err := k.load()
+		if err != nil {
+			return fmt.Errorf("encryption key %s: %s", id, err.Error())
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("encryption key %s: %s", id, err.Error())
+		}
+		if ciphers[id], err = cipher.NewGCM(block); err != nil {
+			return fmt.Errorf("encryption key %s: %s", id, err.Error())
+		}
+	}
+	o.Ciphers = ciphers
+	return nil
+}
+
+// load returns the decoded key read from its configured source
+func (k *KeyOptions) load() ([]byte, error) {
+	var s string
+	var n int
+	if k.Key != "" {
+		s = k.Key
+		n++
+	}
+	if k.KeyFile != "" {
+		b, err := ioutil.ReadFile(k.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+		n++
+	}
+	if k.KeyEnv != "" {
+		s = os.Getenv(k.KeyEnv)
+		if s == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", k.KeyEnv)
+		}
+		n++
+	}
+	if n != 1 {
+		return nil, errors.New("exactly one of key, key_file or key_env must be provided")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
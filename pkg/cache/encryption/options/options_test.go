@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testKey1 = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+const testKey2 = "ZmVkY2JhOTg3NjU0MzIxMA=="
+
+func TestLoadKeys(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "trickster-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testKey2 + "\n")
+	f.Close()
+
+	os.Setenv("TRICKSTER_TEST_CACHE_KEY", testKey1)
+	defer os.Unsetenv("TRICKSTER_TEST_CACHE_KEY")
+
+	o := NewOptions()
+	if err = o.LoadKeys(); err != nil {
+		t.Error(err)
+	}
+	if o.Enabled() {
+		t.Errorf("expected %t got %t", false, true)
+	}
+
+	o.ActiveKeyID = "k1"
+	o.Keys["k1"] = &KeyOptions{Key: testKey1}
+	o.Keys["k2"] = &KeyOptions{KeyFile: f.Name()}
+	o.Keys["k3"] = &KeyOptions{KeyEnv: "TRICKSTER_TEST_CACHE_KEY"}
+	if err = o.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Ciphers) != 3 {
+		t.Errorf("expected %d got %d", 3, len(o.Ciphers))
+	}
+
+	o2 := o.Clone()
+	if o2.ActiveKeyID != "k1" || len(o2.Keys) != 3 || len(o2.Ciphers) != 3 {
+		t.Error("clone mismatch")
+	}
+	o2.Keys["k1"].Key = "*****"
+	if o.Keys["k1"].Key != testKey1 {
+		t.Error("clone shares keys with the original")
+	}
+
+}
+
+func TestLoadKeysErrors(t *testing.T) {
+
+	tests := []struct {
+		activeKeyID string
+		keys        map[string]*KeyOptions
+	}{
+		{ // 0: keys without an active key
+			"", map[string]*KeyOptions{"k1": {Key: testKey1}},
+		},
+		{ // 1: active key not configured
+			"k2", map[string]*KeyOptions{"k1": {Key: testKey1}},
+		},
+		{ // 2: no key source
+			"k1", map[string]*KeyOptions{"k1": {}},
+		},
+		{ // 3: multiple key sources
+			"k1", map[string]*KeyOptions{"k1": {Key: testKey1, KeyEnv: "HOME"}},
+		},
+		{ // 4: invalid base64
+			"k1", map[string]*KeyOptions{"k1": {Key: "not base64!"}},
+		},
+		{ // 5: invalid key length
+			"k1", map[string]*KeyOptions{"k1": {Key: "a2V5"}},
+		},
+		{ // 6: missing key file
+			"k1", map[string]*KeyOptions{"k1": {KeyFile: "/nonexistent/trickster.key"}},
+		},
+		{ // 7: unset environment variable
+			"k1", map[string]*KeyOptions{"k1": {KeyEnv: "TRICKSTER_TEST_UNSET_KEY"}},
+		},
+		{ // 8: nil key
+			"k1", map[string]*KeyOptions{"k1": nil},
+		},
+	}
+
+	for i, test := range tests {
+		o := &Options{ActiveKeyID: test.activeKeyID, Keys: test.keys}
+		if err := o.LoadKeys(); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+}
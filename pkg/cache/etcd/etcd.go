@@ -0,0 +1,522 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package etcd is the etcd implementation of the Trickster Cache. It speaks the etcd v3 JSON
+// gateway, and is intended for small, hot objects in deployments that already operate an
+// etcd cluster. It can also coordinate the Tricksters sharing the cluster with distributed
+// locks, so that only one of them fetches and writes a given object at a time
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// etcd v3 JSON gateway paths
+const (
+	pathRange        = "/v3/kv/range"
+	pathPut          = "/v3/kv/put"
+	pathDeleteRange  = "/v3/kv/deleterange"
+	pathTxn          = "/v3/kv/txn"
+	pathLeaseGrant   = "/v3/lease/grant"
+	pathLeaseRevoke  = "/v3/lease/revoke"
+	pathAuthenticate = "/v3/auth/authenticate"
+	pathStatus       = "/v3/maintenance/status"
+)
+
+const (
+	// maxTxnOps is the most operations that etcd accepts in a transaction with its default
+	// --max-txn-ops, which bounds the deletes of each BulkRemove transaction
+	maxTxnOps = 128
+	// keysPageSize is the most keys returned by each range request of Keys
+	keysPageSize = 1000
+	// leaseReuseWindow is how long a lease granted for an object's TTL is reused for the
+	// objects stored after it with the same TTL, so that a burst of writes doesn't grant a
+	// lease per object. Those objects expire up to this much earlier than their TTL
+	leaseReuseWindow = time.Second
+	// lockPollInterval is the wait between attempts to acquire a distributed lock held by
+	// another Trickster
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// ErrValueTooLarge is returned when an object is larger than the cache's max_value_bytes
+var ErrValueTooLarge = errors.New("object exceeds the etcd max_value_bytes limit")
+
+// Cache represents an etcd cache object that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	locker locks.NamedLocker
+
+	client    *http.Client
+	endpoints []string
+	// current is the index of the endpoint to which requests are sent
+	current uint32
+	token   string
+	// leases are the object leases granted within the leaseReuseWindow, keyed by TTL seconds
+	leases map[int64]lease
+	mtx    sync.Mutex
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// lease is a lease granted to the objects stored with the same TTL
+type lease struct {
+	id      int64Value
+	granted time.Time
+}
+
+// int64Value is an int64 of the etcd JSON gateway, which encodes them as strings
+type int64Value int64
+
+// MarshalJSON encodes the int64Value as a string
+func (v int64Value) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatInt(int64(v), 10) + `"`), nil
+}
+
+// UnmarshalJSON decodes an int64Value encoded as a string or a number
+func (v *int64Value) UnmarshalJSON(b []byte) error {
+	i, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(i)
+	return nil
+}
+
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type rangeRequest struct {
+	Key      []byte     `json:"key"`
+	RangeEnd []byte     `json:"range_end,omitempty"`
+	Limit    int64Value `json:"limit,omitempty"`
+	KeysOnly bool       `json:"keys_only,omitempty"`
+}
+
+type rangeResponse struct {
+	Kvs  []keyValue `json:"kvs"`
+	More bool       `json:"more"`
+}
+
+type putRequest struct {
+	Key         []byte     `json:"key"`
+	Value       []byte     `json:"value,omitempty"`
+	Lease       int64Value `json:"lease,omitempty"`
+	IgnoreValue bool       `json:"ignore_value,omitempty"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+// compare is a comparison of a transaction. The cache only compares keys' create revisions
+type compare struct {
+	Key            []byte     `json:"key"`
+	Target         string     `json:"target"`
+	Result         string     `json:"result"`
+	CreateRevision int64Value `json:"create_revision"`
+}
+
+type requestOp struct {
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []compare   `json:"compare,omitempty"`
+	Success []requestOp `json:"success,omitempty"`
+}
+
+type leaseRequest struct {
+	TTL int64Value `json:"TTL,omitempty"`
+	ID  int64Value `json:"ID,omitempty"`
+}
+
+type authRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker. When the cache's distributed locks are enabled, the
+// locker's write locks are also acquired in etcd
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	if c.Config != nil && c.Config.Etcd != nil && c.Config.Etcd.DistributedLocks {
+		l = &locker{NamedLocker: l, c: c}
+	}
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect authenticates with the configured credentials, if any, and verifies that an
+// endpoint of the etcd cluster is reachable
+func (c *Cache) Connect() error {
+	o := c.Config.Etcd
+	c.Logger.Info("connecting to etcd", tl.Pairs{"endpoints": o.Endpoints})
+
+	c.endpoints = make([]string, len(o.Endpoints))
+	for i, e := range o.Endpoints {
+		c.endpoints[i] = strings.TrimSuffix(e, "/")
+	}
+	c.client = &http.Client{Timeout: o.Timeout}
+	c.leases = make(map[int64]lease)
+	if c.now == nil {
+		c.now = time.Now
+	}
+	if c.sleep == nil {
+		c.sleep = time.Sleep
+	}
+
+	if o.Username != "" {
+		var out struct {
+			Token string `json:"token"`
+		}
+		if err := c.do(pathAuthenticate, authRequest{Name: o.Username, Password: o.Password},
+			&out); err != nil {
+			return fmt.Errorf("could not authenticate with etcd: %s", err.Error())
+		}
+		c.setToken(out.Token)
+	}
+
+	var st struct {
+		Version string     `json:"version"`
+		DBSize  int64Value `json:"dbSize"`
+	}
+	if err := c.do(pathStatus, struct{}{}, &st); err != nil {
+		return fmt.Errorf("could not connect to etcd: %s", err.Error())
+	}
+	c.Logger.Info("connected to etcd", tl.Pairs{"version": st.Version, "dbSizeBytes": int64(st.DBSize)})
+	return nil
+}
+
+// Store places the the data into etcd using the provided Key and TTL. Objects larger than
+// max_value_bytes are not stored
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+	if len(data) > c.Config.Etcd.MaxValueBytes {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "store", "too_large")
+		return ErrValueTooLarge
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	c.Logger.Debug("etcd cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
+	id, err := c.objectLease(ttl)
+	if err != nil {
+		return err
+	}
+	return c.do(pathPut, putRequest{Key: c.key(cacheKey), Value: data, Lease: id}, nil)
+}
+
+// Retrieve gets data from etcd using the provided Key. etcd deletes objects when their
+// leases expire, so expired objects are not retrieved, regardless of allowExpired
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	var out rangeResponse
+	err := c.do(pathRange, rangeRequest{Key: c.key(cacheKey)}, &out)
+	if err == nil && len(out.Kvs) == 0 {
+		c.Logger.Debug("etcd cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	var data []byte
+	if err == nil {
+		data, err = cache.DecodeValue(c.Config, cacheKey, out.Kvs[0].Value)
+	}
+	if err != nil {
+		c.Logger.Debug("etcd cache retrieve failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+	c.Logger.Debug("etcd cache retrieve", tl.Pairs{"key": cacheKey})
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	return data, status.LookupStatusHit, nil
+}
+
+// SetTTL updates the TTL for the provided cache object, if it is still present, by
+// attaching it to a lease with the new TTL
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	id, err := c.objectLease(ttl)
+	if err == nil {
+		err = c.do(pathPut, putRequest{Key: c.key(cacheKey), Lease: id, IgnoreValue: true}, nil)
+	}
+	if err != nil {
+		c.Logger.Debug("etcd cache set ttl failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	}
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.Logger.Debug("etcd cache remove", tl.Pairs{"key": cacheKey})
+	if err := c.do(pathDeleteRange, deleteRangeRequest{Key: c.key(cacheKey)}, nil); err != nil {
+		c.Logger.Error("etcd cache key delete failure",
+			tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+}
+
+// BulkRemove removes a list of objects from the cache, in transactions of up to maxTxnOps
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug("etcd cache bulk remove", tl.Pairs{})
+	for i := 0; i < len(cacheKeys); i += maxTxnOps {
+		end := i + maxTxnOps
+		if end > len(cacheKeys) {
+			end = len(cacheKeys)
+		}
+		ops := make([]requestOp, end-i)
+		for j, k := range cacheKeys[i:end] {
+			ops[j].RequestDeleteRange = &deleteRangeRequest{Key: c.key(k)}
+		}
+		if err := c.do(pathTxn, txnRequest{Success: ops}, nil); err != nil {
+			c.Logger.Error("etcd cache bulk delete failure", tl.Pairs{"reason": err.Error()})
+			continue
+		}
+		metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(ops)))
+	}
+}
+
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	o := c.Config.Etcd
+	start := c.key(prefix)
+	end := prefixEnd(start)
+	var keys []string
+	for {
+		var out rangeResponse
+		if err := c.do(pathRange, rangeRequest{Key: start, RangeEnd: end, Limit: keysPageSize,
+			KeysOnly: true}, &out); err != nil {
+			return nil, err
+		}
+		for _, kv := range out.Kvs {
+			keys = append(keys, strings.TrimPrefix(string(kv.Key), o.KeyPrefix))
+		}
+		if !out.More || len(out.Kvs) == 0 {
+			return keys, nil
+		}
+		// the next page begins after the last key of this one
+		start = append(append([]byte(nil), out.Kvs[len(out.Kvs)-1].Key...), 0)
+	}
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// key returns the etcd key of the object stored at cacheKey
+func (c *Cache) key(cacheKey string) []byte {
+	return []byte(c.Config.Etcd.KeyPrefix + cacheKey)
+}
+
+// prefixEnd returns the end of the range of keys that begin with prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// every byte is 0xff, so the range extends to the end of the key space
+	return []byte{0}
+}
+
+// objectLease returns a lease that expires after ttl, which is reused by the objects stored
+// with the same TTL within the leaseReuseWindow
+func (c *Cache) objectLease(ttl time.Duration) (int64Value, error) {
+	secs := int64(math.Ceil(ttl.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	now := c.now()
+	c.mtx.Lock()
+	if l, ok := c.leases[secs]; ok {
+		// a lease is not reused past half its TTL, so short leases don't expire before use
+		if d := now.Sub(l.granted); d < leaseReuseWindow && d < time.Duration(secs)*time.Second/2 {
+			c.mtx.Unlock()
+			return l.id, nil
+		}
+	}
+	c.mtx.Unlock()
+	id, err := c.grantLease(secs)
+	if err != nil {
+		return 0, err
+	}
+	c.mtx.Lock()
+	for k, l := range c.leases {
+		if now.Sub(l.granted) >= leaseReuseWindow {
+			delete(c.leases, k)
+		}
+	}
+	c.leases[secs] = lease{id: id, granted: now}
+	c.mtx.Unlock()
+	return id, nil
+}
+
+// grantLease grants a lease with the TTL in seconds, and returns its ID
+func (c *Cache) grantLease(secs int64) (int64Value, error) {
+	var out struct {
+		ID int64Value `json:"ID"`
+	}
+	if err := c.do(pathLeaseGrant, leaseRequest{TTL: int64Value(secs)}, &out); err != nil {
+		return 0, err
+	}
+	return out.ID, nil
+}
+
+func (c *Cache) setToken(token string) {
+	c.mtx.Lock()
+	c.token = token
+	c.mtx.Unlock()
+}
+
+func (c *Cache) getToken() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.token
+}
+
+// do sends the request to the etcd JSON gateway path, and decodes the response into out.
+// When an endpoint is unreachable or unavailable, the request is sent to the next endpoint,
+// which is then used for further requests
+func (c *Cache) do(path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	first := int(atomic.LoadUint32(&c.current))
+	for i := range c.endpoints {
+		j := (first + i) % len(c.endpoints)
+		var b []byte
+		b, err = c.post(c.endpoints[j], path, body)
+		if e, ok := err.(*apiError); err != nil &&
+			(!ok || e.status == http.StatusServiceUnavailable) {
+			continue
+		}
+		if j != first {
+			atomic.StoreUint32(&c.current, uint32(j))
+		}
+		if err != nil || out == nil {
+			return err
+		}
+		return json.Unmarshal(b, out)
+	}
+	return err
+}
+
+// post sends the body to the endpoint's gateway path. A request that is rejected because
+// the auth token expired is resent once with a new token
+func (c *Cache) post(endpoint, path string, body []byte) ([]byte, error) {
+	if path == pathAuthenticate {
+		return c.send(endpoint, path, body, "")
+	}
+	b, err := c.send(endpoint, path, body, c.getToken())
+	if e, ok := err.(*apiError); ok && e.status == http.StatusUnauthorized &&
+		c.Config.Etcd.Username != "" {
+		o := c.Config.Etcd
+		ab, _ := json.Marshal(authRequest{Name: o.Username, Password: o.Password})
+		if ab, err = c.send(endpoint, pathAuthenticate, ab, ""); err != nil {
+			return nil, err
+		}
+		var out struct {
+			Token string `json:"token"`
+		}
+		if err = json.Unmarshal(ab, &out); err != nil {
+			return nil, err
+		}
+		c.setToken(out.Token)
+		b, err = c.send(endpoint, path, body, out.Token)
+	}
+	return b, err
+}
+
+// send posts the body to the endpoint's gateway path with the auth token, if any
+func (c *Cache) send(endpoint, path string, body []byte, token string) ([]byte, error) {
+	r, err := http.NewRequest(http.MethodPost, endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &apiError{status: resp.StatusCode}
+		json.Unmarshal(b, e)
+		return nil, e
+	}
+	return b, nil
+}
+
+// apiError is the error document of a failed request
+type apiError struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("etcd responded with status %d", e.status)
+	}
+	return "etcd error: " + e.Message
+}
@@ -0,0 +1,556 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/etcd/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "etcd"
+const cacheKey = "cacheKey"
+
+type testKeyValue struct {
+	value []byte
+	lease int64Value
+}
+
+// testEtcd is an in-memory etcd v3 JSON gateway
+type testEtcd struct {
+	mtx       sync.Mutex
+	kvs       map[string]testKeyValue
+	leases    map[int64Value]int64Value
+	nextLease int64Value
+	// password enables authentication of the user "test" when it is set
+	password string
+	token    string
+	// pageSize limits the keys of each range response, when set
+	pageSize int
+	requests map[string]int
+}
+
+func newTestEtcd() (*testEtcd, *httptest.Server) {
+	s := &testEtcd{kvs: make(map[string]testKeyValue), leases: make(map[int64Value]int64Value),
+		requests: make(map[string]int)}
+	return s, httptest.NewServer(s)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	w.Write([]byte(`{"error":"` + message + `","message":"` + message + `"}`))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, _ := json.Marshal(v)
+	w.Write(b)
+}
+
+// put applies a put request, and returns an error message if it is rejected
+func (s *testEtcd) put(in putRequest) string {
+	if in.Lease != 0 {
+		if _, ok := s.leases[in.Lease]; !ok {
+			return "etcdserver: requested lease not found"
+		}
+	}
+	kv, ok := s.kvs[string(in.Key)]
+	if in.IgnoreValue {
+		if !ok {
+			return "etcdserver: key not found"
+		}
+		in.Value = kv.value
+	}
+	s.kvs[string(in.Key)] = testKeyValue{value: in.Value, lease: in.Lease}
+	return ""
+}
+
+func (s *testEtcd) deleteRange(in deleteRangeRequest) {
+	for k := range s.kvs {
+		if k == string(in.Key) || (in.RangeEnd != nil && k >= string(in.Key) &&
+			k < string(in.RangeEnd)) {
+			delete(s.kvs, k)
+		}
+	}
+}
+
+// revoke revokes the lease, which deletes its keys
+func (s *testEtcd) revoke(id int64Value) {
+	delete(s.leases, id)
+	for k, kv := range s.kvs {
+		if kv.lease == id {
+			delete(s.kvs, k)
+		}
+	}
+}
+
+func (s *testEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests[r.URL.Path]++
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Path == pathAuthenticate {
+		var in authRequest
+		json.Unmarshal(body, &in)
+		if in.Name != "test" || in.Password != s.password {
+			writeError(w, http.StatusBadRequest, "etcdserver: authentication failed")
+			return
+		}
+		s.token = "token" + strconv.Itoa(s.requests[pathAuthenticate])
+		writeJSON(w, map[string]string{"token": s.token})
+		return
+	}
+	if s.password != "" && r.Header.Get("Authorization") != s.token {
+		writeError(w, http.StatusUnauthorized, "etcdserver: invalid auth token")
+		return
+	}
+
+	switch r.URL.Path {
+	case pathStatus:
+		w.Write([]byte(`{"version":"3.4.13","dbSize":"20480"}`))
+	case pathLeaseGrant:
+		var in leaseRequest
+		json.Unmarshal(body, &in)
+		s.nextLease++
+		s.leases[s.nextLease] = in.TTL
+		writeJSON(w, leaseRequest{ID: s.nextLease, TTL: in.TTL})
+	case pathLeaseRevoke:
+		var in leaseRequest
+		json.Unmarshal(body, &in)
+		if _, ok := s.leases[in.ID]; !ok {
+			writeError(w, http.StatusNotFound, "etcdserver: requested lease not found")
+			return
+		}
+		s.revoke(in.ID)
+		w.Write([]byte("{}"))
+	case pathPut:
+		var in putRequest
+		json.Unmarshal(body, &in)
+		if msg := s.put(in); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		w.Write([]byte("{}"))
+	case pathDeleteRange:
+		var in deleteRangeRequest
+		json.Unmarshal(body, &in)
+		s.deleteRange(in)
+		w.Write([]byte("{}"))
+	case pathRange:
+		var in rangeRequest
+		json.Unmarshal(body, &in)
+		var keys []string
+		for k := range s.kvs {
+			if k == string(in.Key) || (in.RangeEnd != nil && k >= string(in.Key) &&
+				k < string(in.RangeEnd)) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		limit := int(in.Limit)
+		if s.pageSize > 0 && (limit == 0 || s.pageSize < limit) {
+			limit = s.pageSize
+		}
+		var out rangeResponse
+		if limit > 0 && len(keys) > limit {
+			keys = keys[:limit]
+			out.More = true
+		}
+		for _, k := range keys {
+			kv := keyValue{Key: []byte(k)}
+			if !in.KeysOnly {
+				kv.Value = s.kvs[k].value
+			}
+			out.Kvs = append(out.Kvs, kv)
+		}
+		writeJSON(w, out)
+	case pathTxn:
+		var in txnRequest
+		json.Unmarshal(body, &in)
+		for _, c := range in.Compare {
+			// only comparisons of create revisions with 0 (the key doesn't exist) are used
+			if _, ok := s.kvs[string(c.Key)]; ok {
+				w.Write([]byte(`{"succeeded":false}`))
+				return
+			}
+		}
+		for _, op := range in.Success {
+			if op.RequestPut != nil {
+				if msg := s.put(*op.RequestPut); msg != "" {
+					writeError(w, http.StatusBadRequest, msg)
+					return
+				}
+			}
+			if op.RequestDeleteRange != nil {
+				s.deleteRange(*op.RequestDeleteRange)
+			}
+		}
+		w.Write([]byte(`{"succeeded":true}`))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func newCacheConfig(endpoints ...string) co.Options {
+	o := eo.NewOptions()
+	o.Endpoints = endpoints
+	return co.Options{CacheType: cacheType, Etcd: o}
+}
+
+// testClock is a clock that is advanced by sleeping
+type testClock struct {
+	mtx sync.Mutex
+	t   time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.t
+}
+
+func (c *testClock) sleep(d time.Duration) {
+	c.mtx.Lock()
+	c.t = c.t.Add(d)
+	c.mtx.Unlock()
+}
+
+func newTestCache(t *testing.T, ts *httptest.Server, clock *testClock) *Cache {
+	cacheConfig := newCacheConfig(ts.URL)
+	c := &Cache{Name: "test", Config: &cacheConfig, Logger: tl.ConsoleLogger("error"),
+		now: clock.now, sleep: clock.sleep}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConfiguration(t *testing.T) {
+	cacheConfig := newCacheConfig()
+	c := Cache{Config: &cacheConfig}
+	cfg := c.Configuration()
+	if cfg.CacheType != cacheType {
+		t.Errorf("expected %s got %s", cacheType, cfg.CacheType)
+	}
+}
+
+func TestEtcdCache_SetLocker(t *testing.T) {
+	cacheConfig := newCacheConfig()
+	c := Cache{Config: &cacheConfig}
+	c.SetLocker(locks.NewNamedLocker())
+	if _, ok := c.Locker().(*locker); ok {
+		t.Error("expected local locker")
+	}
+	cacheConfig.Etcd.DistributedLocks = true
+	c.SetLocker(locks.NewNamedLocker())
+	if _, ok := c.Locker().(*locker); !ok {
+		t.Error("expected distributed locker")
+	}
+}
+
+func TestEtcdCache_Connect(t *testing.T) {
+
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	s.password = "secret"
+
+	cacheConfig := newCacheConfig(ts.URL)
+	cacheConfig.Etcd.Username = "test"
+	cacheConfig.Etcd.Password = "wrong"
+	c := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error")}
+	const expected = "could not authenticate with etcd: etcd error: etcdserver: authentication failed"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+
+	cacheConfig.Etcd.Password = "secret"
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// an expired token is renewed
+	s.token = "expired"
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if s.requests[pathAuthenticate] != 3 {
+		t.Errorf("expected %d got %d", 3, s.requests[pathAuthenticate])
+	}
+
+	cacheConfig.Etcd.Endpoints = []string{"http://127.0.0.1:1"}
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unavailable endpoint")
+	}
+}
+
+func TestEtcdCache_Failover(t *testing.T) {
+
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	cacheConfig := newCacheConfig("http://127.0.0.1:1", ts.URL+"/")
+	c := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error")}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if c.current != 1 {
+		t.Errorf("expected %d got %d", 1, c.current)
+	}
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if s.requests[pathPut] != 1 {
+		t.Errorf("expected %d got %d", 1, s.requests[pathPut])
+	}
+}
+
+func TestEtcdCache_StoreRetrieve(t *testing.T) {
+
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	clock := &testClock{t: time.Unix(1000, 0)}
+	c := newTestCache(t, ts, clock)
+	defer c.Close()
+
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err != cache.ErrKNF || ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v %s got %v %s", cache.ErrKNF, status.LookupStatusKeyMiss, err, ls)
+	}
+
+	if err = c.Store(cacheKey, []byte("data"), 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	b, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil || ls != status.LookupStatusHit || string(b) != "data" {
+		t.Errorf("expected data %s got %s %s %v", status.LookupStatusHit, string(b), ls, err)
+	}
+	kv := s.kvs[eo.NewOptions().KeyPrefix+cacheKey]
+	if s.leases[kv.lease] != 90 {
+		t.Errorf("expected %d got %d", 90, s.leases[kv.lease])
+	}
+
+	// objects stored with the same TTL shortly after share the lease
+	clock.sleep(100 * time.Millisecond)
+	c.Store("other", []byte("data"), 90*time.Second)
+	if s.kvs[eo.NewOptions().KeyPrefix+"other"].lease != kv.lease {
+		t.Error("expected shared lease")
+	}
+	clock.sleep(time.Second)
+	c.Store("other", []byte("data"), 90*time.Second)
+	if s.kvs[eo.NewOptions().KeyPrefix+"other"].lease == kv.lease {
+		t.Error("expected new lease")
+	}
+	if s.requests[pathLeaseGrant] != 2 {
+		t.Errorf("expected %d got %d", 2, s.requests[pathLeaseGrant])
+	}
+
+	// the object is deleted when its lease expires
+	s.revoke(kv.lease)
+	if _, _, err = c.Retrieve(cacheKey, true); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
+func TestEtcdCache_StoreTooLarge(t *testing.T) {
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	c := newTestCache(t, ts, &testClock{t: time.Unix(1000, 0)})
+	defer c.Close()
+	c.Config.Etcd.MaxValueBytes = 4
+	if err := c.Store(cacheKey, []byte("12345"), time.Minute); err != ErrValueTooLarge {
+		t.Errorf("expected %v got %v", ErrValueTooLarge, err)
+	}
+	if len(s.kvs) != 0 {
+		t.Errorf("expected %d got %d", 0, len(s.kvs))
+	}
+}
+
+func TestEtcdCache_SetTTL(t *testing.T) {
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	c := newTestCache(t, ts, &testClock{t: time.Unix(1000, 0)})
+	defer c.Close()
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	c.SetTTL(cacheKey, time.Hour)
+	kv := s.kvs[eo.NewOptions().KeyPrefix+cacheKey]
+	if s.leases[kv.lease] != 3600 || string(kv.value) != "data" {
+		t.Errorf("expected %d data got %d %s", 3600, s.leases[kv.lease], string(kv.value))
+	}
+
+	// a missing object is not created
+	c.SetTTL("missing", time.Hour)
+	if _, ok := s.kvs[eo.NewOptions().KeyPrefix+"missing"]; ok {
+		t.Error("expected missing object")
+	}
+}
+
+func TestEtcdCache_Remove(t *testing.T) {
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	c := newTestCache(t, ts, &testClock{t: time.Unix(1000, 0)})
+	defer c.Close()
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	c.Remove(cacheKey)
+	if len(s.kvs) != 0 {
+		t.Errorf("expected %d got %d", 0, len(s.kvs))
+	}
+}
+
+func TestEtcdCache_BulkRemove(t *testing.T) {
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	c := newTestCache(t, ts, &testClock{t: time.Unix(1000, 0)})
+	defer c.Close()
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = cacheKey + strconv.Itoa(i)
+		c.Store(keys[i], []byte("data"), time.Minute)
+	}
+	c.BulkRemove(keys[:299])
+	if len(s.kvs) != 1 {
+		t.Errorf("expected %d got %d", 1, len(s.kvs))
+	}
+	if s.requests[pathTxn] != 3 {
+		t.Errorf("expected %d got %d", 3, s.requests[pathTxn])
+	}
+}
+
+func TestEtcdCache_Keys(t *testing.T) {
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	c := newTestCache(t, ts, &testClock{t: time.Unix(1000, 0)})
+	defer c.Close()
+
+	for _, k := range []string{"a.1", "a.2", "a.3", "b.1"} {
+		c.Store(k, []byte("data"), time.Minute)
+	}
+	s.pageSize = 2
+	keys, err := c.Keys("a.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0] != "a.1" || keys[2] != "a.3" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if s.requests[pathRange] != 2 {
+		t.Errorf("expected %d got %d", 2, s.requests[pathRange])
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix, expected string
+	}{
+		{"a", "b"},
+		{"a\xff", "b"},
+		{"\xff\xff", "\x00"},
+	}
+	for _, test := range tests {
+		if s := string(prefixEnd([]byte(test.prefix))); s != test.expected {
+			t.Errorf("expected %q got %q", test.expected, s)
+		}
+	}
+}
+
+func TestEtcdCache_DistributedLocks(t *testing.T) {
+
+	s, ts := newTestEtcd()
+	defer ts.Close()
+	clock := &testClock{t: time.Unix(1000, 0)}
+	c1 := newTestCache(t, ts, clock)
+	c1.Config.Etcd.DistributedLocks = true
+	c1.SetLocker(locks.NewNamedLocker())
+	c2 := newTestCache(t, ts, clock)
+	c2.Config.Etcd.DistributedLocks = true
+	c2.SetLocker(locks.NewNamedLocker())
+
+	nl, err := c1.Locker().Acquire(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.kvs[eo.NewOptions().LockPrefix+cacheKey]; !ok {
+		t.Error("expected lock key")
+	}
+
+	// the lock is held by the other Trickster, so the wait times out and proceeds
+	start := clock.now()
+	nl2, err := c2.Locker().Acquire(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.now().Sub(start); d < c2.Config.Etcd.LockTimeout {
+		t.Errorf("expected wait of at least %s got %s", c2.Config.Etcd.LockTimeout, d)
+	}
+	if nl2.(*lock).lease != 0 {
+		t.Error("expected lock without lease")
+	}
+	nl2.Release()
+
+	nl.Release()
+	if len(s.kvs) != 0 || len(s.leases) != 0 {
+		t.Errorf("expected %d %d got %d %d", 0, 0, len(s.kvs), len(s.leases))
+	}
+
+	// an upgraded read lock acquires the distributed lock
+	rl, err := c2.Locker().RAcquire(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.kvs) != 0 {
+		t.Errorf("expected %d got %d", 0, len(s.kvs))
+	}
+	wl, err := rl.Upgrade()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl.(*lock).lease == 0 {
+		t.Error("expected lock with lease")
+	}
+	wl.Release()
+	if len(s.kvs) != 0 {
+		t.Errorf("expected %d got %d", 0, len(s.kvs))
+	}
+
+	// the local lock is acquired when etcd is unavailable
+	ts.Close()
+	nl, err = c1.Locker().Acquire(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl.Release()
+}
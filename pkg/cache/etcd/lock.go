@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcd
+
+import (
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// locker is the NamedLocker of a cache with distributed locks. Its write locks are acquired
+// locally, and then in etcd, so that one Trickster at a time writes each object. Read locks
+// are only acquired locally
+type locker struct {
+	locks.NamedLocker
+	c *Cache
+}
+
+// lock is a NamedLock of a locker, which holds the distributed lock's lease while it is a
+// write lock. The lease is 0 for read locks, and for write locks whose distributed lock
+// could not be acquired
+type lock struct {
+	locks.NamedLock
+	c     *Cache
+	name  string
+	lease int64Value
+}
+
+// Acquire acquires the write lock of the name, locally and then in etcd
+func (l *locker) Acquire(name string) (locks.NamedLock, error) {
+	nl, err := l.NamedLocker.Acquire(name)
+	if err != nil {
+		return nil, err
+	}
+	return &lock{NamedLock: nl, c: l.c, name: name, lease: l.c.acquireLock(name)}, nil
+}
+
+// RAcquire acquires the read lock of the name, locally
+func (l *locker) RAcquire(name string) (locks.NamedLock, error) {
+	nl, err := l.NamedLocker.RAcquire(name)
+	if err != nil {
+		return nil, err
+	}
+	return &lock{NamedLock: nl, c: l.c, name: name}, nil
+}
+
+// Release releases the write lock, in etcd and then locally
+func (l *lock) Release() error {
+	if l.lease != 0 {
+		l.c.releaseLock(l.lease)
+		l.lease = 0
+	}
+	return l.NamedLock.Release()
+}
+
+// Upgrade upgrades the read lock to a write lock, locally and then in etcd
+func (l *lock) Upgrade() (locks.NamedLock, error) {
+	nl, err := l.NamedLock.Upgrade()
+	if err != nil {
+		return nil, err
+	}
+	return &lock{NamedLock: nl, c: l.c, name: l.name, lease: l.c.acquireLock(l.name)}, nil
+}
+
+// acquireLock acquires the distributed lock of the name, by creating its key with a lease
+// that expires after the lock TTL, and returns the lease. While another Trickster holds the
+// lock, it is retried until the lock timeout. When the lock can't be acquired, 0 is
+// returned, and the caller proceeds with only its local lock, so that an unavailable etcd
+// cluster or a stuck Trickster doesn't block requests
+func (c *Cache) acquireLock(name string) int64Value {
+	o := c.Config.Etcd
+	id, err := c.grantLease(int64(o.LockTTL.Seconds()))
+	if err != nil {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "distributed_lock", "failed")
+		c.Logger.Warn("etcd lock lease grant failed", tl.Pairs{"name": name, "reason": err.Error()})
+		return 0
+	}
+	key := []byte(o.LockPrefix + name)
+	txn := txnRequest{
+		Compare: []compare{{Key: key, Target: "CREATE", Result: "EQUAL"}},
+		Success: []requestOp{{RequestPut: &putRequest{Key: key, Value: []byte(c.Name), Lease: id}}},
+	}
+	deadline := c.now().Add(o.LockTimeout)
+	for {
+		var out struct {
+			Succeeded bool `json:"succeeded"`
+		}
+		if err = c.do(pathTxn, txn, &out); err != nil {
+			metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "distributed_lock", "failed")
+			c.Logger.Warn("etcd lock acquisition failed", tl.Pairs{"name": name, "reason": err.Error()})
+			break
+		}
+		if out.Succeeded {
+			return id
+		}
+		if !c.now().Before(deadline) {
+			metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "distributed_lock", "timeout")
+			c.Logger.Debug("etcd lock acquisition timed out", tl.Pairs{"name": name})
+			break
+		}
+		c.sleep(lockPollInterval)
+	}
+	c.releaseLock(id)
+	return 0
+}
+
+// releaseLock revokes the lease of a distributed lock, which deletes the lock's key
+func (c *Cache) releaseLock(id int64Value) {
+	if err := c.do(pathLeaseRevoke, leaseRequest{ID: id}, nil); err != nil {
+		c.Logger.Warn("etcd lock release failed", tl.Pairs{"reason": err.Error()})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// MaxRequestBytes is the largest request that etcd accepts with its default --max-request-bytes
+const MaxRequestBytes = 1536 * 1024
+
+// ErrMissingEndpoints is returned when no endpoints are configured
+var ErrMissingEndpoints = errors.New("etcd endpoints must be provided")
+
+// ErrInvalidEndpoint is returned when an endpoint is not an http or https URL
+var ErrInvalidEndpoint = errors.New("etcd endpoints must be http or https URLs")
+
+// ErrInvalidKeyPrefix is returned when the key or lock prefix is empty, or one is a prefix
+// of the other, so that the keys of cached objects and locks could overlap
+var ErrInvalidKeyPrefix = errors.New(
+	"etcd key_prefix and lock_prefix must not be empty or prefixes of one another")
+
+// ErrInvalidCredentials is returned when only one of the username and password is provided
+var ErrInvalidCredentials = errors.New("etcd username and password must be provided together")
+
+// ErrInvalidMaxValueBytes is returned when the value size limit is out of range
+var ErrInvalidMaxValueBytes = errors.New("etcd max_value_bytes must be between 1 and 1572864")
+
+// ErrInvalidLockTTL is returned when the lock TTL is not positive
+var ErrInvalidLockTTL = errors.New("etcd lock_ttl_secs must be greater than 0")
+
+// ErrInvalidLockTimeout is returned when the lock timeout is negative
+var ErrInvalidLockTimeout = errors.New("etcd lock_timeout_ms must not be negative")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("etcd timeout_ms must be greater than 0")
+
+// Options is a collection of Configurations for storing cached data in etcd
+type Options struct {
+	// Endpoints is the list of client URLs of the etcd cluster's members. Requests are sent to
+	// the first endpoint that is reachable
+	Endpoints []string `toml:"endpoints"`
+	// KeyPrefix prefixes the etcd key of each cached object
+	KeyPrefix string `toml:"key_prefix"`
+	// LockPrefix prefixes the etcd key of each distributed lock
+	LockPrefix string `toml:"lock_prefix"`
+	// Username is the etcd user. When empty, requests are not authenticated
+	Username string `toml:"username"`
+	// Password is the etcd user's password
+	Password string `toml:"password"`
+	// MaxValueBytes is the size of the largest object that is stored. etcd is suited to small
+	// objects, and larger objects are not cached, so they don't exhaust the cluster's quota
+	MaxValueBytes int `toml:"max_value_bytes"`
+	// DistributedLocks also acquires the cache's write locks in etcd, so that only one
+	// Trickster sharing the cluster fetches and writes a given object at a time
+	DistributedLocks bool `toml:"distributed_locks"`
+	// LockTTLSecs is the TTL of a distributed lock, after which a lock held by a Trickster
+	// that failed to release it expires
+	LockTTLSecs int `toml:"lock_ttl_secs"`
+	// LockTimeoutMS is how long to wait for a distributed lock held by another Trickster,
+	// before proceeding with only the local lock
+	LockTimeoutMS int `toml:"lock_timeout_ms"`
+	// TimeoutMS is the timeout of each request to etcd
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// LockTTL is the time.Duration representation of LockTTLSecs
+	LockTTL time.Duration `toml:"-"`
+	// LockTimeout is the time.Duration representation of LockTimeoutMS
+	LockTimeout time.Duration `toml:"-"`
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new etcd Options Reference with default values set
+func NewOptions() *Options {
+	o := &Options{
+		Endpoints:     []string{d.DefaultEtcdEndpoint},
+		KeyPrefix:     d.DefaultEtcdKeyPrefix,
+		LockPrefix:    d.DefaultEtcdLockPrefix,
+		MaxValueBytes: d.DefaultEtcdMaxValueBytes,
+		LockTTLSecs:   d.DefaultEtcdLockTTLSecs,
+		LockTimeoutMS: d.DefaultEtcdLockTimeoutMS,
+		TimeoutMS:     d.DefaultEtcdTimeoutMS,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	if o.Endpoints != nil {
+		o2.Endpoints = make([]string, len(o.Endpoints))
+		copy(o2.Endpoints, o.Endpoints)
+	}
+	return &o2
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if len(o.Endpoints) == 0 {
+		return ErrMissingEndpoints
+	}
+	for _, e := range o.Endpoints {
+		u, err := url.Parse(e)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	if o.KeyPrefix == "" || o.LockPrefix == "" || strings.HasPrefix(o.KeyPrefix, o.LockPrefix) ||
+		strings.HasPrefix(o.LockPrefix, o.KeyPrefix) {
+		return ErrInvalidKeyPrefix
+	}
+	if (o.Username == "") != (o.Password == "") {
+		return ErrInvalidCredentials
+	}
+	if o.MaxValueBytes < 1 || o.MaxValueBytes > MaxRequestBytes {
+		return ErrInvalidMaxValueBytes
+	}
+	if o.LockTTLSecs <= 0 {
+		return ErrInvalidLockTTL
+	}
+	if o.LockTimeoutMS < 0 {
+		return ErrInvalidLockTimeout
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the second- and millisecond-based
+// options
+func (o *Options) SetDurations() {
+	o.LockTTL = time.Duration(o.LockTTLSecs) * time.Second
+	o.LockTimeout = time.Duration(o.LockTimeoutMS) * time.Millisecond
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
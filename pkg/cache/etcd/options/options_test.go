@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Fatal("expected non-nil options")
+	}
+	if o.Timeout != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, o.Timeout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		endpoints  []string
+		keyPrefix  string
+		lockPrefix string
+		username   string
+		password   string
+		maxValue   int
+		lockTTL    int
+		lockWait   int
+		timeout    int
+		expected   error
+	}{
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", 65536, 30, 5000, 2000, nil},
+		{[]string{"http://etcd1:2379", "https://etcd2:2379"}, "/c/", "/l/", "u", "p",
+			MaxRequestBytes, 30, 0, 2000, nil},
+		{nil, "/c/", "/l/", "", "", 65536, 30, 5000, 2000, ErrMissingEndpoints},
+		{[]string{"etcd:2379"}, "/c/", "/l/", "", "", 65536, 30, 5000, 2000, ErrInvalidEndpoint},
+		{[]string{"http://"}, "/c/", "/l/", "", "", 65536, 30, 5000, 2000, ErrInvalidEndpoint},
+		{[]string{"http://etcd:2379"}, "", "/l/", "", "", 65536, 30, 5000, 2000, ErrInvalidKeyPrefix},
+		{[]string{"http://etcd:2379"}, "/c/", "/c/l/", "", "", 65536, 30, 5000, 2000,
+			ErrInvalidKeyPrefix},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "u", "", 65536, 30, 5000, 2000,
+			ErrInvalidCredentials},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", 0, 30, 5000, 2000,
+			ErrInvalidMaxValueBytes},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", MaxRequestBytes + 1, 30, 5000, 2000,
+			ErrInvalidMaxValueBytes},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", 65536, 0, 5000, 2000, ErrInvalidLockTTL},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", 65536, 30, -1, 2000,
+			ErrInvalidLockTimeout},
+		{[]string{"http://etcd:2379"}, "/c/", "/l/", "", "", 65536, 30, 5000, 0, ErrInvalidTimeout},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.Endpoints = test.endpoints
+		o.KeyPrefix = test.keyPrefix
+		o.LockPrefix = test.lockPrefix
+		o.Username = test.username
+		o.Password = test.password
+		o.MaxValueBytes = test.maxValue
+		o.LockTTLSecs = test.lockTTL
+		o.LockTimeoutMS = test.lockWait
+		o.TimeoutMS = test.timeout
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o2 := o.Clone()
+	o2.Endpoints[0] = "http://other:2379"
+	if o.Endpoints[0] != "http://etcd:2379" {
+		t.Errorf("expected %s got %s", "http://etcd:2379", o.Endpoints[0])
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.LockTTLSecs = 10
+	o.LockTimeoutMS = 100
+	o.TimeoutMS = 250
+	o.SetDurations()
+	if o.LockTTL != 10*time.Second || o.LockTimeout != 100*time.Millisecond ||
+		o.Timeout != 250*time.Millisecond {
+		t.Errorf("unexpected durations %s %s %s", o.LockTTL, o.LockTimeout, o.Timeout)
+	}
+}
@@ -21,20 +21,48 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/index"
 	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
 	"github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	"github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
+// maxFileNameLength is the longest legacy filename derived from a cache key, which leaves
+// room for the file extension within the 255-byte filename limit of most filesystems
+const maxFileNameLength = 200
+
+// tempFileMarker is added to the names of files that are being written, followed by a
+// unique suffix. Escaped legacy filenames never contain '%' followed by a non-hex letter
+const tempFileMarker = ".%tmp."
+
+// tempFileMaxAge is the age after which a temporary file is considered abandoned
+const tempFileMaxAge = 10 * time.Minute
+
+// tempFileSeq distinguishes the temporary files of concurrent writes
+var tempFileSeq uint64
+
+// reservedNames are the device names that Windows does not allow as a filename,
+// with or without an extension, which are escaped in legacy filenames
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
 // Cache describes a Filesystem Cache
 type Cache struct {
 	Name       string
@@ -43,6 +71,12 @@ type Cache struct {
 	Logger     *log.Logger
 	locker     locks.NamedLocker
 	lockPrefix string
+
+	pending     map[string]*pendingWrite
+	pendingLock sync.Mutex
+	dirty       map[string]bool
+	dirtyLock   sync.Mutex
+	quit        chan struct{}
 }
 
 // Locker returns the cache's locker
@@ -64,20 +98,37 @@ func (c *Cache) Configuration() *options.Options {
 func (c *Cache) Connect() error {
 	c.Logger.Info("filesystem cache setup", log.Pairs{"name": c.Name,
 		"cachePath": c.Config.Filesystem.CachePath})
-	if err := makeDirectory(c.Config.Filesystem.CachePath); err != nil {
+	if err := makeDirectory(c.Config.Filesystem.CachePath, c.Config.Filesystem.DirPerm); err != nil {
+		return err
+	}
+	if err := c.migrateFlatLayout(); err != nil {
 		return err
 	}
+	removeTempFiles(c.Config.Filesystem.CachePath)
 	c.lockPrefix = c.Name + ".file."
+	c.pending = make(map[string]*pendingWrite)
+	c.dirty = make(map[string]bool)
+	c.quit = make(chan struct{})
+	c.startBackgroundWriters()
 
 	// Load Index here and pass bytes as param2
 	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
 	c.Index = index.NewIndex(c.Name, c.Config.CacheType, indexData,
 		c.Config.Index, c.BulkRemove, c.storeNoIndex, c.Logger)
+	if c.shouldReconcile(len(indexData) > 0) {
+		c.reconcile()
+	}
+	c.markOpen()
 	return nil
 }
 
 // Store places an object in the cache using the specified key and ttl
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
 	return c.store(cacheKey, data, ttl, true)
 }
 
@@ -103,14 +154,30 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 
 	dataFile := c.getFileName(cacheKey)
 
+	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
+
+	if c.batchable(len(data)) {
+		c.addPendingWrite(cacheKey, o.ToBytes())
+		c.Logger.Debug("filesystem cache store batched",
+			log.Pairs{"key": cacheKey, "dataFile": dataFile, "indexed": updateIndex})
+		if updateIndex {
+			c.Index.UpdateObject(o)
+		}
+		return nil
+	}
+
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
 
-	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
-	err := ioutil.WriteFile(dataFile, o.ToBytes(), os.FileMode(0777))
+	// this write supersedes any pending batched write for the key
+	c.cancelPendingWrite(cacheKey)
+	err := c.writeFile(dataFile, o.ToBytes(), c.Config.Filesystem.SyncMode == flo.SyncModeWrite)
 	if err != nil {
 		nl.Release()
 		return err
 	}
+	if c.Config.Filesystem.SyncMode == flo.SyncModePeriodic {
+		c.markDirty(dataFile)
+	}
 	c.Logger.Debug("filesystem cache store",
 		log.Pairs{"key": cacheKey, "dataFile": dataFile, "indexed": updateIndex})
 	if updateIndex {
@@ -122,16 +189,31 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 
 // Retrieve looks for an object in cache and returns it (or an error if not found)
 func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
-	return c.retrieve(cacheKey, allowExpired, true)
+	data, lookupStatus, err := c.retrieve(cacheKey, allowExpired, true)
+	if err != nil {
+		return data, lookupStatus, err
+	}
+	if data, err = cache.DecodeValue(c.Config, cacheKey, data); err != nil {
+		return nil, status.LookupStatusError, err
+	}
+	return data, lookupStatus, nil
 }
 
 func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte, status.LookupStatus, error) {
 
 	dataFile := c.getFileName(cacheKey)
 
-	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
-	data, err := ioutil.ReadFile(dataFile)
-	nl.RRelease()
+	data, ok := c.getPendingWrite(cacheKey)
+	var err error
+	if !ok {
+		nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+		data, err = readFile(dataFile)
+		nl.RRelease()
+
+		if os.IsNotExist(err) {
+			data, err = c.migrate(cacheKey, dataFile)
+		}
+	}
 
 	if err != nil {
 		c.Logger.Debug("filesystem cache miss", log.Pairs{"key": cacheKey, "dataFile": dataFile})
@@ -147,6 +229,15 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 		return nil, status.LookupStatusError, err2
 	}
 
+	// the filename is a hash of the key, so the key in the object confirms it is the
+	// requested one, and not a colliding key
+	if o.Key != "" && o.Key != cacheKey {
+		c.Logger.Debug("filesystem cache key mismatch",
+			log.Pairs{"key": cacheKey, "objectKey": o.Key, "dataFile": dataFile})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+
 	// if retrieve() is being called to load the index, the index will be nil, so just return the value
 	// so as to instantiate the index
 	if c.Index == nil {
@@ -157,7 +248,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 	if allowExpired || o.Expiration.IsZero() || o.Expiration.After(time.Now()) {
 		c.Logger.Debug("filesystem cache retrieve", log.Pairs{"key": cacheKey, "dataFile": dataFile})
 		if atime {
-			go c.Index.UpdateObjectAccessTime(cacheKey)
+			c.Index.QueueObjectAccess(cacheKey)
 		}
 		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return o.Value, status.LookupStatusHit, nil
@@ -170,6 +261,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
 }
 
@@ -180,14 +272,45 @@ func (c *Cache) Remove(cacheKey string) {
 
 func (c *Cache) remove(cacheKey string, isBulk bool) {
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	batched := c.cancelPendingWrite(cacheKey)
 	err := os.Remove(c.getFileName(cacheKey))
+	if os.IsNotExist(err) {
+		err = os.Remove(c.getLegacyFileName(cacheKey))
+	}
 	nl.Release()
-	if err == nil && !isBulk {
+	if (err == nil || batched) && !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
 	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
 }
 
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	return c.Index.Keys(prefix), nil
+}
+
+// Inspect returns the statistics of the cached objects, including the topN largest and
+// most-accessed objects
+func (c *Cache) Inspect(topN int) *cache.Stats {
+	return c.Index.Stats(topN, time.Now())
+}
+
+// InspectObject returns the statistics of the object cached at the key, or nil if the key
+// is not cached
+func (c *Cache) InspectObject(cacheKey string) *cache.ObjectStats {
+	return c.Index.ObjectStats(cacheKey, time.Now())
+}
+
+// SetTags associates the object cached at the key with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	c.Index.SetTags(cacheKey, tags)
+}
+
+// TaggedKeys returns the keys of the cached objects that are associated with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	return c.Index.TaggedKeys(tag), nil
+}
+
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg := &sync.WaitGroup{}
@@ -202,29 +325,175 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
-// Close is not used for Cache
+// Close flushes the index and any batched writes, and stops the Cache's background writers
 func (c *Cache) Close() error {
 	if c.Index != nil {
 		c.Index.Close()
 	}
+	if c.quit != nil {
+		close(c.quit)
+		c.quit = nil
+		// the index is flushed ahead of the batched writes, which include its own
+		if c.Index != nil {
+			c.Index.Flush(c.Logger)
+		}
+		c.flushPendingWrites()
+		c.syncDirtyFiles()
+		c.markClosed()
+	}
 	return nil
 }
 
+// getFileName returns the filename of the object stored under the cache key, which is
+// placed in a shard of the cache path
 func (c *Cache) getFileName(cacheKey string) string {
-	prefix := strings.Replace(c.Config.Filesystem.CachePath+"/"+cacheKey+".", "//", "/", 1)
-	return prefix + "data"
+	return filepath.Join(c.Config.Filesystem.CachePath, shardedName(md5.Checksum(cacheKey)))
 }
 
-// makeDirectory creates a directory on the filesystem and returns the error in the event of a failure.
-func makeDirectory(path string) error {
-	err := os.MkdirAll(path, 0755)
+// getLegacyFileName returns the filename under which the object was stored before
+// filenames were derived from a hash of the cache key
+func (c *Cache) getLegacyFileName(cacheKey string) string {
+	return filepath.Join(c.Config.Filesystem.CachePath, legacyFileName(cacheKey)+".data")
+}
+
+// migrate moves an object stored under its legacy filename to the hashed filename,
+// and returns its data
+func (c *Cache) migrate(cacheKey, dataFile string) ([]byte, error) {
+	legacyFile := c.getLegacyFileName(cacheKey)
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	defer nl.Release()
+	if _, err := os.Stat(legacyFile); err != nil {
+		return nil, err
+	}
+	if err := c.makeShardDir(dataFile, false); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(legacyFile, dataFile); err != nil {
+		return nil, err
+	}
+	c.Logger.Debug("filesystem cache migrated legacy file",
+		log.Pairs{"key": cacheKey, "legacyFile": legacyFile, "dataFile": dataFile})
+	return readFile(dataFile)
+}
+
+// legacyFileName returns the filename formerly used for the cache key. Bytes other than
+// ASCII letters, digits, '.', '_' and '-' are escaped as %XX, as is the first byte of a
+// name reserved by Windows. Names that are too long are truncated and suffixed with a
+// hash of the full key, so they remain unique.
+func legacyFileName(cacheKey string) string {
+	var sb strings.Builder
+	for i := 0; i < len(cacheKey); i++ {
+		b := cacheKey[i]
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+			b == '.' || b == '_' || b == '-' {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	name := sb.String()
+	if base := strings.SplitN(name, ".", 2)[0]; reservedNames[strings.ToUpper(base)] {
+		name = fmt.Sprintf("%%%02X", name[0]) + name[1:]
+	}
+	if len(name) > maxFileNameLength {
+		h := md5.Checksum(cacheKey)
+		name = name[:maxFileNameLength-len(h)-1] + "." + h
+	}
+	return name
+}
+
+// writeFile writes the data to the named file
+func (c *Cache) writeFile(path string, data []byte, sync bool) error {
+	return c.writeFileAtomic(path, sync, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomic creates a temporary file in the directory of the named file, calls write
+// to fill it, and renames it over the named file, so that neither readers nor a crash ever
+// observe a partially-written file. When sync is true, the file is fsynced before it is
+// renamed, and the directory after, so that the rename is only persisted with the data
+func (c *Cache) writeFileAtomic(path string, sync bool, write func(*os.File) error) error {
+	tmp := path + tempFileMarker + strconv.Itoa(os.Getpid()) + "." +
+		strconv.FormatUint(atomic.AddUint64(&tempFileSeq, 1), 10)
+	perm := c.Config.Filesystem.FilePerm
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if os.IsNotExist(err) {
+		// the first write to a shard creates its directory
+		if err = c.makeShardDir(path, sync); err == nil {
+			f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	if err == nil {
-		s := ""
-		if !strings.HasSuffix(path, "/") {
-			s = "/"
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if sync {
+		syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// syncDir fsyncs the named directory, which persists its new and renamed entries.
+// not all platforms support it
+func syncDir(path string) {
+	if d, err := os.Open(path); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// removeTempFiles removes the temporary files in the cache path and its shards that were
+// left behind by writes interrupted by a crash. Recent files may belong to another
+// process's write in progress, and are kept
+func removeTempFiles(path string) {
+	files, _ := filepath.Glob(filepath.Join(path, "*"+tempFileMarker+"*"))
+	shardFiles, _ := filepath.Glob(filepath.Join(path, "*", "*", "*"+tempFileMarker+"*"))
+	files = append(files, shardFiles...)
+	cutoff := time.Now().Add(-tempFileMaxAge)
+	for _, name := range files {
+		if fi, err := os.Stat(name); err == nil && fi.ModTime().Before(cutoff) {
+			os.Remove(name)
 		}
+	}
+}
+
+// readFile reads the named file while holding a shared file lock. Files are no longer
+// written in place, but the lock is kept for processes of earlier versions sharing the
+// cache path
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err = lockFile(f, false); err != nil {
+		return nil, err
+	}
+	defer unlockFile(f)
+	return ioutil.ReadAll(f)
+}
+
+// makeDirectory creates a directory on the filesystem and returns the error in the event of a failure.
+func makeDirectory(path string, perm os.FileMode) error {
+	err := os.MkdirAll(path, perm)
+	if err == nil {
 		// verify writability by attempting to touch a test file in the cache path
-		tf := path + s + ".test." + strconv.FormatInt(time.Now().Unix(), 10)
+		tf := filepath.Join(path, ".test."+strconv.FormatInt(time.Now().Unix(), 10))
 		err = ioutil.WriteFile(tf, []byte(""), 0600)
 		if err == nil {
 			os.Remove(tf)
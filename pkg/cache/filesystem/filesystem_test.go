@@ -19,28 +19,32 @@ package filesystem
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
 	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
 )
 
 const cacheType = "filesystem"
 const cacheKey = "cacheKey"
 
-func storeBenchmark(b *testing.B) Cache {
+func storeBenchmark(b *testing.B) *Cache {
 	dir, _ := ioutil.TempDir("/tmp", cacheType)
 	cacheConfig := co.Options{CacheType: cacheType,
-		Filesystem: &flo.Options{CachePath: dir}, Index: &io.Options{ReapInterval: time.Second}}
-	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+		Filesystem: newFilesystemOptions(dir), Index: &io.Options{ReapInterval: time.Second}}
+	fc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
 	defer os.RemoveAll(cacheConfig.BBolt.Filename)
 
 	err := fc.Connect()
@@ -64,10 +68,16 @@ func newCacheConfig(t *testing.T) co.Options {
 	if err != nil {
 		t.Fatalf("could not create temp directory (%s): %s", dir, err)
 	}
-	return co.Options{CacheType: cacheType, Filesystem: &flo.Options{CachePath: dir},
+	return co.Options{CacheType: cacheType, Filesystem: newFilesystemOptions(dir),
 		Index: &io.Options{ReapInterval: time.Second}}
 }
 
+func newFilesystemOptions(dir string) *flo.Options {
+	o := flo.NewOptions()
+	o.CachePath = dir
+	return o
+}
+
 func TestConfiguration(t *testing.T) {
 	cacheConfig := newCacheConfig(t)
 	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
@@ -110,7 +120,7 @@ func TestFilesystemCache_ConnectFailed(t *testing.T) {
 func TestFilesystemCache_Store(t *testing.T) {
 
 	const expected1 = "invalid ttl: -1"
-	const expected2 = "open /root/noaccess.trickster.filesystem.cache/cacheKey.data:"
+	expected2 := "open /root/noaccess.trickster.filesystem.cache/" + md5.Checksum(cacheKey) + ".data:"
 
 	cacheConfig := newCacheConfig(t)
 	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
@@ -148,6 +158,153 @@ func TestFilesystemCache_Store(t *testing.T) {
 
 }
 
+func TestFilesystemCache_StoreLongKey(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	key := "/api/v1/query_range?" + strings.Repeat("query=up&", 100)
+	err = fc.Store(key, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, ls, err := fc.Retrieve(key, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+}
+
+func TestFilesystemCache_RetrieveLegacyFile(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// move the object to where it was stored before filenames were hashed
+	legacyFile := fc.getLegacyFileName(cacheKey)
+	err = os.Rename(fc.getFileName(cacheKey), legacyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, ls, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// the object should have been migrated to its hashed filename
+	if _, err = os.Stat(legacyFile); !os.IsNotExist(err) {
+		t.Errorf("expected legacy file to be removed, got %v", err)
+	}
+	if _, err = os.Stat(fc.getFileName(cacheKey)); err != nil {
+		t.Error(err)
+	}
+
+	// a legacy file should also be removable
+	err = os.Rename(fc.getFileName(cacheKey), legacyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Remove(cacheKey)
+	if _, err = os.Stat(legacyFile); !os.IsNotExist(err) {
+		t.Errorf("expected legacy file to be removed, got %v", err)
+	}
+
+}
+
+func TestFilesystemCache_RetrieveKeyMismatch(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a hash collision by placing the object at another key's filename
+	os.MkdirAll(filepath.Dir(fc.getFileName(cacheKey+"2")), 0755)
+	err = os.Rename(fc.getFileName(cacheKey), fc.getFileName(cacheKey+"2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ls, err := fc.Retrieve(cacheKey+"2", false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+}
+
+func TestLegacyFileName(t *testing.T) {
+
+	long := strings.Repeat("a", 300)
+
+	tests := []struct {
+		key, expected string
+	}{
+		{"9f86d081884c7d659a2feaa0c55ad015", "9f86d081884c7d659a2feaa0c55ad015"},
+		{"cache.index", "cache.index"},
+		{"path/to:key?", "path%2Fto%3Akey%3F"},
+		{"con", "%63on"},
+		{"LPT1.index", "%4CPT1.index"},
+		{"console", "console"},
+		{long, long[:167] + "." + md5.Checksum(long)},
+		{long + "b", long[:167] + "." + md5.Checksum(long+"b")},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			name := legacyFileName(test.key)
+			if len(name) > maxFileNameLength {
+				t.Errorf("expected filename of at most %d bytes, got %d", maxFileNameLength, len(name))
+			}
+			if name != test.expected {
+				t.Errorf("expected %s got %s", test.expected, name)
+			}
+		})
+	}
+}
+
 func BenchmarkCache_Store(b *testing.B) {
 	fc := storeBenchmark(b)
 	defer fc.Close()
@@ -391,6 +548,7 @@ func TestFilesystemCache_Retrieve(t *testing.T) {
 
 	// should fail
 	filename := fc.getFileName(cacheKey + "-invalid")
+	os.MkdirAll(filepath.Dir(filename), 0755)
 	err = ioutil.WriteFile(filename, []byte("junk"), os.FileMode(0777))
 	if err != nil {
 		t.Error(err)
@@ -447,6 +605,7 @@ func BenchmarkCache_Retrieve(b *testing.B) {
 
 		filename := fc.getFileName(cacheKey + strconv.Itoa(n))
 		// create a corrupted cache entry and expect an error
+		os.MkdirAll(filepath.Dir(filename), 0755)
 		ioutil.WriteFile(filename, []byte("junk"), os.FileMode(0777))
 
 		// it should fail to retrieve a value
@@ -622,3 +781,255 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestFilesystemCache_Keys(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer fc.Close()
+
+	for _, k := range []string{"a.dpc.1", "a.opc.1", "b.dpc.1"} {
+		if err = fc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+
+	keys, err := fc.Keys("a.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 2 || keys[0] != "a.dpc.1" || keys[1] != "a.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a.dpc.1", "a.opc.1"}, keys)
+	}
+}
+
+func TestFilesystemCache_TaggedKeys(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer fc.Close()
+
+	for _, k := range []string{"a.opc.1", "a.opc.2", "b.opc.1"} {
+		if err = fc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+	fc.SetTags("a.opc.2", []string{"tenant-a"})
+	fc.SetTags("b.opc.1", []string{"tenant-a", "tenant-b"})
+
+	keys, err := fc.TaggedKeys("tenant-a")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 2 || keys[0] != "a.opc.2" || keys[1] != "b.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a.opc.2", "b.opc.1"}, keys)
+	}
+}
+
+func TestFilesystemCache_FileMode(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on windows")
+	}
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.Filesystem.CachePath = filepath.Join(cacheConfig.Filesystem.CachePath, "cache")
+	cacheConfig.Filesystem.FilePerm = 0600
+	cacheConfig.Filesystem.DirPerm = 0700
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	if err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	fi, err := os.Stat(cacheConfig.Filesystem.CachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Errorf("expected %s got %s", os.FileMode(0700), fi.Mode().Perm())
+	}
+
+	fi, err = os.Stat(fc.getFileName(cacheKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected %s got %s", os.FileMode(0600), fi.Mode().Perm())
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", cacheType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := filepath.Join(dir, "a.data"+tempFileMarker+"1.1")
+	recent := filepath.Join(dir, "b.data"+tempFileMarker+"1.2")
+	data := filepath.Join(dir, "c.data")
+	for _, f := range []string{old, recent, data} {
+		if err = ioutil.WriteFile(f, []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Now().Add(-2 * tempFileMaxAge)
+	os.Chtimes(old, mtime, mtime)
+	os.Chtimes(data, mtime, mtime)
+
+	removeTempFiles(dir)
+
+	if _, err = os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", old)
+	}
+	for _, f := range []string{recent, data} {
+		if _, err = os.Stat(f); err != nil {
+			t.Errorf("expected %s to exist: %v", f, err)
+		}
+	}
+}
+
+func TestFilesystemCache_Compression(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.CompressionCodec = "snappy"
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(strings.Repeat("data", 1024))
+	err = fc.Store(cacheKey, data, time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the data file should hold the compressed value
+	fi, err := os.Stat(fc.getFileName(cacheKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() >= int64(len(data)) {
+		t.Errorf("expected file size less than %d got %d", len(data), fi.Size())
+	}
+
+	// values stored with another codec should remain retrievable
+	for _, c := range []string{"snappy", "gzip", "none"} {
+		cacheConfig.CompressionCodec = c
+		b, ls, err := fc.Retrieve(cacheKey, false)
+		if err != nil {
+			t.Error(err)
+		}
+		if ls != status.LookupStatusHit {
+			t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+		}
+		if string(b) != string(data) {
+			t.Errorf("%s: retrieved value does not match", c)
+		}
+	}
+
+	// a value stored without a codec header can't be decoded
+	cacheConfig.CompressionCodec = ""
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheConfig.CompressionCodec = "gzip"
+	_, ls, err := fc.Retrieve(cacheKey, false)
+	if err == nil {
+		t.Error("expected error for value without codec header")
+	}
+	if ls != status.LookupStatusError {
+		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
+	}
+
+}
+
+func TestFilesystemCache_Encryption(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.CompressionCodec = "gzip"
+	cacheConfig.Encryption = eo.NewOptions()
+	cacheConfig.Encryption.ActiveKeyID = "k1"
+	cacheConfig.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}
+	if err := cacheConfig.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(strings.Repeat("data", 1024))
+	err = fc.Store(cacheKey, data, time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the data file should not hold the plaintext value
+	b, err := ioutil.ReadFile(fc.getFileName(cacheKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "datadata") {
+		t.Error("data file contains the plaintext value")
+	}
+
+	// the value should remain retrievable after the active key is rotated
+	cacheConfig.Encryption.ActiveKeyID = "k2"
+	cacheConfig.Encryption.Keys["k2"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := cacheConfig.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	b, ls, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if string(b) != string(data) {
+		t.Error("retrieved value does not match")
+	}
+
+	// the value should not be retrievable once its key is removed
+	delete(cacheConfig.Encryption.Keys, "k1")
+	if err := cacheConfig.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	_, ls, err = fc.Retrieve(cacheKey, false)
+	if err == nil {
+		t.Error("expected error for value encrypted with a removed key")
+	}
+	if ls != status.LookupStatusError {
+		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
+	}
+
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// hashedNameLength is the length of an object's filename: a hex-encoded MD5 hash of its
+// cache key, followed by the .data extension
+const hashedNameLength = 32 + len(".data")
+
+// shardedName returns the path of the object file with the provided hash, relative to the
+// cache path. Files are spread across two levels of subdirectories, named after the first
+// two pairs of hex digits of the hash, so that no directory holds more than a small
+// fraction of a large cache's files
+func shardedName(hash string) string {
+	return filepath.Join(hash[0:2], hash[2:4], hash+".data")
+}
+
+// isHashedName returns true if the filename is that of an object in the flat layout of
+// earlier versions, which stored all files directly in the cache path
+func isHashedName(name string) bool {
+	if len(name) != hashedNameLength || !strings.HasSuffix(name, ".data") {
+		return false
+	}
+	for i := 0; i < 32; i++ {
+		b := name[i]
+		if (b < '0' || b > '9') && (b < 'a' || b > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// makeShardDir creates the shard directory of the named file. When sync is true, the
+// parent directories are fsynced, so that the new directories are persisted
+func (c *Cache) makeShardDir(path string, sync bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, c.Config.Filesystem.DirPerm); err != nil {
+		return err
+	}
+	if sync {
+		syncDir(filepath.Dir(dir))
+		syncDir(c.Config.Filesystem.CachePath)
+	}
+	return nil
+}
+
+// migrateFlatLayout moves the object files stored directly in the cache path by earlier
+// versions into their shards. It is called before the cache index is loaded, since the
+// index itself is stored as an object
+func (c *Cache) migrateFlatLayout() error {
+	d, err := os.Open(c.Config.Filesystem.CachePath)
+	if err != nil {
+		return err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	var n int
+	for _, name := range names {
+		if !isHashedName(name) {
+			continue
+		}
+		dataFile := filepath.Join(c.Config.Filesystem.CachePath, shardedName(name[:32]))
+		if err = c.makeShardDir(dataFile, false); err != nil {
+			return err
+		}
+		if err = os.Rename(filepath.Join(c.Config.Filesystem.CachePath, name), dataFile); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		syncDir(c.Config.Filesystem.CachePath)
+		c.Logger.Info("filesystem cache migrated flat layout",
+			log.Pairs{"cacheName": c.Name, "files": n})
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+func TestShardedName(t *testing.T) {
+	h := md5.Checksum(cacheKey)
+	expected := filepath.Join(h[0:2], h[2:4], h+".data")
+	if s := shardedName(h); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+}
+
+func TestIsHashedName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{md5.Checksum(cacheKey) + ".data", true},
+		{md5.Checksum(cacheKey), false},
+		{"cacheKey.data", false},
+		{"B9DC72C3ED2E391F311A1DA6C3B4B942.data", false},
+		{md5.Checksum(cacheKey) + ".data" + tempFileMarker + "1.1", false},
+	}
+	for i, test := range tests {
+		if v := isHashedName(test.name); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestMigrateFlatLayout(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	dir := cacheConfig.Filesystem.CachePath
+	defer os.RemoveAll(dir)
+
+	// write an object and the index in the flat layout of earlier versions
+	o := &index.Object{Key: cacheKey, Value: []byte("data"), Expiration: time.Now().Add(time.Minute)}
+	flatFile := filepath.Join(dir, md5.Checksum(cacheKey)+".data")
+	if err := ioutil.WriteFile(flatFile, o.ToBytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx := &index.Index{Objects: map[string]*index.Object{cacheKey: {Key: cacheKey,
+		Expiration: o.Expiration, Size: 4}}}
+	ib, _ := idx.MarshalMsg(nil)
+	indexObj := &index.Object{Key: index.IndexKey, Value: ib, Expiration: time.Now().Add(time.Hour)}
+	if err := ioutil.WriteFile(filepath.Join(dir, md5.Checksum(index.IndexKey)+".data"),
+		indexObj.ToBytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherFile := filepath.Join(dir, "other.data")
+	if err := ioutil.WriteFile(otherFile, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	if _, ok := fc.Index.Objects[cacheKey]; !ok {
+		t.Error("expected the migrated index to be loaded")
+	}
+	data, _, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if _, err = os.Stat(flatFile); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be moved", flatFile)
+	}
+	if _, err = os.Stat(otherFile); err != nil {
+		t.Errorf("expected %s to be kept: %v", otherFile, err)
+	}
+}
@@ -17,16 +17,137 @@
 package options
 
 import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 )
 
+// Sync Modes define the durability of Filesystem Cache writes
+const (
+	// SyncModeNone leaves flushing written files to disk to the operating system
+	SyncModeNone = "none"
+	// SyncModeWrite fsyncs each file as it is written. Batched writes are fsynced as a group
+	SyncModeWrite = "write"
+	// SyncModePeriodic fsyncs the files written since the last sync at a fixed interval
+	SyncModePeriodic = "periodic"
+)
+
+// Reconcile Modes define when the Filesystem Cache index is rebuilt from the files on disk
+const (
+	// ReconcileModeAuto reconciles the index when the cache was not closed cleanly
+	ReconcileModeAuto = "auto"
+	// ReconcileModeAlways reconciles the index each time the cache is opened
+	ReconcileModeAlways = "always"
+	// ReconcileModeNever never reconciles the index
+	ReconcileModeNever = "never"
+)
+
+// ErrInvalidSyncMode is returned when the sync mode is not supported
+var ErrInvalidSyncMode = errors.New("invalid filesystem sync_mode")
+
+// ErrInvalidReconcileMode is returned when the reconcile mode is not supported
+var ErrInvalidReconcileMode = errors.New("invalid filesystem reconcile_mode")
+
+// ErrInvalidSyncInterval is returned when the periodic sync interval is not positive
+var ErrInvalidSyncInterval = errors.New("filesystem sync_interval_ms must be greater than 0")
+
+// ErrInvalidBatchOptions is returned when the write batching options are invalid
+var ErrInvalidBatchOptions = errors.New("filesystem batch_max_bytes can't be negative, " +
+	"and batch_interval_ms must be greater than 0 when batching is enabled")
+
+// ErrInvalidFileMode is returned when a file or directory mode is not an octal permission
+var ErrInvalidFileMode = errors.New("filesystem file_mode and dir_mode must be octal " +
+	"permissions between 0000 and 0777")
+
 // Options is a collection of Configurations for storing cached data on the Filesystem
 type Options struct {
 	// CachePath represents the path on disk where our cache will live
 	CachePath string `toml:"cache_path"`
+	// SyncMode is the durability mode of writes: 'none', 'write' or 'periodic'
+	SyncMode string `toml:"sync_mode"`
+	// SyncIntervalMS is the interval at which written files are fsynced in 'periodic' mode
+	SyncIntervalMS int `toml:"sync_interval_ms"`
+	// BatchMaxBytes is the largest object whose write is batched. 0 disables batching
+	BatchMaxBytes int `toml:"batch_max_bytes"`
+	// BatchIntervalMS is the interval at which batched writes are flushed to disk
+	BatchIntervalMS int `toml:"batch_interval_ms"`
+	// FileMode is the octal permissions of written files, before the umask is applied
+	FileMode string `toml:"file_mode"`
+	// DirMode is the octal permissions of the cache directory, before the umask is applied
+	DirMode string `toml:"dir_mode"`
+	// ReconcileMode is when the index is rebuilt from the files on disk: 'auto', 'always' or 'never'
+	ReconcileMode string `toml:"reconcile_mode"`
+
+	// SyncInterval is the time.Duration representation of SyncIntervalMS
+	SyncInterval time.Duration `toml:"-"`
+	// BatchInterval is the time.Duration representation of BatchIntervalMS
+	BatchInterval time.Duration `toml:"-"`
+	// FilePerm is the os.FileMode representation of FileMode
+	FilePerm os.FileMode `toml:"-"`
+	// DirPerm is the os.FileMode representation of DirMode
+	DirPerm os.FileMode `toml:"-"`
 }
 
 // NewOptions returns a new Filesystem Options Reference with default values set
 func NewOptions() *Options {
-	return &Options{CachePath: d.DefaultCachePath}
+	return &Options{
+		CachePath:       d.DefaultCachePath,
+		SyncMode:        d.DefaultFilesystemSyncMode,
+		SyncIntervalMS:  d.DefaultFilesystemSyncIntervalMS,
+		BatchIntervalMS: d.DefaultFilesystemBatchIntervalMS,
+		FileMode:        d.DefaultFilesystemFileMode,
+		DirMode:         d.DefaultFilesystemDirMode,
+		ReconcileMode:   d.DefaultFilesystemReconcileMode,
+		SyncInterval:    time.Duration(d.DefaultFilesystemSyncIntervalMS) * time.Millisecond,
+		BatchInterval:   time.Duration(d.DefaultFilesystemBatchIntervalMS) * time.Millisecond,
+		FilePerm:        0777,
+		DirPerm:         0755,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	switch o.SyncMode {
+	case SyncModeNone, SyncModeWrite:
+	case SyncModePeriodic:
+		if o.SyncIntervalMS <= 0 {
+			return ErrInvalidSyncInterval
+		}
+	default:
+		return ErrInvalidSyncMode
+	}
+	switch o.ReconcileMode {
+	case ReconcileModeAuto, ReconcileModeAlways, ReconcileModeNever:
+	default:
+		return ErrInvalidReconcileMode
+	}
+	if o.BatchMaxBytes < 0 || (o.BatchMaxBytes > 0 && o.BatchIntervalMS <= 0) {
+		return ErrInvalidBatchOptions
+	}
+	var err error
+	if o.FilePerm, err = parseMode(o.FileMode); err != nil {
+		return err
+	}
+	if o.DirPerm, err = parseMode(o.DirMode); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseMode returns the os.FileMode of the provided octal permissions
+func parseMode(s string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, ErrInvalidFileMode
+	}
+	return os.FileMode(m), nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.SyncInterval = time.Duration(o.SyncIntervalMS) * time.Millisecond
+	o.BatchInterval = time.Duration(o.BatchIntervalMS) * time.Millisecond
 }
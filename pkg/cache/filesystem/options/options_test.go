@@ -16,7 +16,11 @@
 
 package options
 
-import "testing"
+import (
+	"os"
+	"testing"
+	"time"
+)
 
 func TestNewOptions(t *testing.T) {
 	o := NewOptions()
@@ -24,3 +28,97 @@ func TestNewOptions(t *testing.T) {
 		t.Error("expected non-nil options")
 	}
 }
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		mode          string
+		syncInterval  int
+		batchMaxBytes int
+		batchInterval int
+		expected      error
+	}{
+		{SyncModeNone, 0, 0, 0, nil},
+		{SyncModeWrite, 0, 1024, 50, nil},
+		{SyncModePeriodic, 1000, 0, 0, nil},
+		{SyncModePeriodic, 0, 0, 0, ErrInvalidSyncInterval},
+		{"always", 0, 0, 0, ErrInvalidSyncMode},
+		{SyncModeNone, 0, -1, 50, ErrInvalidBatchOptions},
+		{SyncModeNone, 0, 1024, 0, ErrInvalidBatchOptions},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.SyncMode = test.mode
+		o.SyncIntervalMS = test.syncInterval
+		o.BatchMaxBytes = test.batchMaxBytes
+		o.BatchIntervalMS = test.batchInterval
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestValidateReconcileMode(t *testing.T) {
+	for _, mode := range []string{ReconcileModeAuto, ReconcileModeAlways, ReconcileModeNever} {
+		o := NewOptions()
+		o.ReconcileMode = mode
+		if err := o.Validate(); err != nil {
+			t.Errorf("mode %s: %v", mode, err)
+		}
+	}
+	o := NewOptions()
+	o.ReconcileMode = "sometimes"
+	if err := o.Validate(); err != ErrInvalidReconcileMode {
+		t.Errorf("expected %v got %v", ErrInvalidReconcileMode, err)
+	}
+}
+
+func TestValidateFileModes(t *testing.T) {
+
+	tests := []struct {
+		fileMode string
+		dirMode  string
+		expected error
+	}{
+		{"0640", "0750", nil},
+		{"600", "700", nil},
+		{"0640", "0755", nil},
+		{"0648", "0750", ErrInvalidFileMode},
+		{"0640", "01777", ErrInvalidFileMode},
+		{"", "0750", ErrInvalidFileMode},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.FileMode = test.fileMode
+		o.DirMode = test.dirMode
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+
+	o := NewOptions()
+	o.FileMode = "0640"
+	o.DirMode = "0750"
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if o.FilePerm != 0640 || o.DirPerm != 0750 {
+		t.Errorf("expected %s and %s got %s and %s", os.FileMode(0640), os.FileMode(0750),
+			o.FilePerm, o.DirPerm)
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.SyncIntervalMS = 250
+	o.BatchIntervalMS = 10
+	o.SetDurations()
+	if o.SyncInterval != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.SyncInterval)
+	}
+	if o.BatchInterval != 10*time.Millisecond {
+		t.Errorf("expected %s got %s", 10*time.Millisecond, o.BatchInterval)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// openMarkerName is the name of the file in the cache path that marks the cache as open. It is
+// removed when the cache is closed, so finding it when the cache is opened means the process
+// that last used the cache exited without closing it, and the index may be behind the files
+const openMarkerName = ".trickster.open"
+
+// openMarkerPath returns the path of the cache's open marker file
+func (c *Cache) openMarkerPath() string {
+	return filepath.Join(c.Config.Filesystem.CachePath, openMarkerName)
+}
+
+// markOpen writes the open marker file, which is removed when the cache is closed
+func (c *Cache) markOpen() {
+	err := ioutil.WriteFile(c.openMarkerPath(), []byte(strconv.Itoa(os.Getpid())),
+		c.Config.Filesystem.FilePerm)
+	if err != nil {
+		c.Logger.Warn("filesystem cache could not write open marker",
+			log.Pairs{"cacheName": c.Name, "detail": err.Error()})
+		return
+	}
+	syncDir(c.Config.Filesystem.CachePath)
+}
+
+// markClosed removes the open marker file, once the index and all writes have been flushed
+func (c *Cache) markClosed() {
+	os.Remove(c.openMarkerPath())
+}
+
+// shouldReconcile returns true if the index should be rebuilt from the files on disk,
+// according to the reconcile mode and whether an index was loaded
+func (c *Cache) shouldReconcile(indexLoaded bool) bool {
+	switch c.Config.Filesystem.ReconcileMode {
+	case flo.ReconcileModeAlways:
+		return true
+	case flo.ReconcileModeNever:
+		return false
+	}
+	if !indexLoaded {
+		return true
+	}
+	_, err := os.Stat(c.openMarkerPath())
+	return err == nil
+}
+
+// reconcile rebuilds the index from the object files in the cache path's shards, each of which
+// holds its object's key and expiration. Objects written since the index was last flushed are
+// indexed, indexed objects whose files are gone are removed from the index, and orphaned files,
+// which have expired or can't be read as the object their filename belongs to, are removed
+func (c *Cache) reconcile() {
+
+	start := time.Now()
+
+	indexed := make(map[string]string)
+	for _, k := range c.Index.Keys("") {
+		indexed[c.getFileName(k)] = k
+	}
+
+	files, _ := filepath.Glob(filepath.Join(c.Config.Filesystem.CachePath, "*", "*", "*.data"))
+	found := make(map[string]bool, len(files))
+	restores := make([]*index.Object, 0)
+	var reaped int
+	now := time.Now()
+
+	for _, name := range files {
+		if !isHashedName(filepath.Base(name)) {
+			continue
+		}
+		if k, ok := indexed[name]; ok {
+			found[k] = true
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		data, err := readFile(name)
+		if err != nil {
+			continue
+		}
+		o, err := index.ObjectFromBytes(data)
+		if err != nil || o.Key == "" || c.getFileName(o.Key) != name ||
+			(!o.Expiration.IsZero() && o.Expiration.Before(now)) {
+			if os.Remove(name) == nil {
+				reaped++
+			}
+			continue
+		}
+		if o.Key == index.IndexKey {
+			continue
+		}
+		restores = append(restores, &index.Object{Key: o.Key, Expiration: o.Expiration,
+			Size: int64(len(o.Value)), LastWrite: fi.ModTime(), LastAccess: fi.ModTime()})
+	}
+
+	restored := c.Index.RestoreObjects(restores)
+
+	// objects still stored under their legacy filenames are migrated when they are next read
+	missing := make([]string, 0)
+	for _, k := range indexed {
+		if found[k] {
+			continue
+		}
+		if _, err := os.Stat(c.getLegacyFileName(k)); err == nil {
+			continue
+		}
+		missing = append(missing, k)
+	}
+	if len(missing) > 0 {
+		c.Index.RemoveObjects(missing, false)
+	}
+
+	c.Logger.Info("filesystem cache index reconciled",
+		log.Pairs{"cacheName": c.Name, "files": len(files), "restoredObjects": restored,
+			"missingObjects": len(missing), "reapedFiles": reaped,
+			"elapsedMS": time.Since(start).Milliseconds()})
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestReconcileAfterCrash(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+
+	fc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fc.openMarkerPath()); err != nil {
+		t.Fatal("expected open marker:", err)
+	}
+
+	for _, k := range []string{"flushed", "deleted"} {
+		if err := fc.Store(k, []byte("data"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fc.Index.Flush(fc.Logger)
+
+	// these changes are not in the flushed index
+	if err := fc.Store("unflushed", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(fc.getFileName("deleted")); err != nil {
+		t.Fatal(err)
+	}
+	expired := &index.Object{Key: "expired", Value: []byte("data"),
+		Expiration: time.Now().Add(-time.Minute)}
+	if err := fc.writeFile(fc.getFileName("expired"), expired.ToBytes(), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.writeFile(fc.getFileName("corrupt"), []byte("corrupt"), false); err != nil {
+		t.Fatal(err)
+	}
+	// an object file whose name is not that of its key
+	misplaced := &index.Object{Key: "other", Value: []byte("data")}
+	if err := fc.writeFile(fc.getFileName("misplaced"), misplaced.ToBytes(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the process exits without closing the cache
+	fc.Index.Close()
+	close(fc.quit)
+
+	fc2 := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc2.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer fc2.Close()
+
+	for _, k := range []string{"flushed", "unflushed"} {
+		if _, ok := fc2.Index.Objects[k]; !ok {
+			t.Errorf("expected %s in index", k)
+		}
+		if _, _, err := fc2.Retrieve(k, false); err != nil {
+			t.Errorf("expected %s to be retrieved: %v", k, err)
+		}
+	}
+	if o := fc2.Index.Objects["unflushed"]; o != nil && o.Size == 0 {
+		t.Error("expected restored object size")
+	}
+	for _, k := range []string{"deleted", "expired", "corrupt", "misplaced", "other"} {
+		if _, ok := fc2.Index.Objects[k]; ok {
+			t.Errorf("expected %s not to be in index", k)
+		}
+	}
+	for _, k := range []string{"expired", "corrupt", "misplaced"} {
+		if _, err := os.Stat(fc2.getFileName(k)); !os.IsNotExist(err) {
+			t.Errorf("expected %s file to be reaped", k)
+		}
+	}
+	if fc2.Index.ObjectCount != 2 {
+		t.Errorf("expected %d got %d", 2, fc2.Index.ObjectCount)
+	}
+}
+
+func TestReconcileModes(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+
+	fc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.Store("stored", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	fc.Close()
+	if _, err := os.Stat(fc.openMarkerPath()); !os.IsNotExist(err) {
+		t.Error("expected open marker to be removed")
+	}
+
+	// an object file written by a process that did not update the index
+	o := &index.Object{Key: "unindexed", Value: []byte("data"), Expiration: time.Now().Add(time.Hour)}
+	if err := fc.writeFile(fc.getFileName("unindexed"), o.ToBytes(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mode     string
+		marker   bool
+		expected bool
+	}{
+		{flo.ReconcileModeAuto, false, false},
+		{flo.ReconcileModeNever, true, false},
+		{flo.ReconcileModeAuto, true, true},
+		{flo.ReconcileModeAlways, false, true},
+	}
+
+	for i, test := range tests {
+		cacheConfig.Filesystem.ReconcileMode = test.mode
+		if test.marker {
+			ioutil.WriteFile(fc.openMarkerPath(), nil, 0644)
+		}
+		fc2 := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"),
+			locker: locks.NewNamedLocker()}
+		if err := fc2.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fc2.Index.Objects["stored"]; !ok {
+			t.Errorf("test %d: expected flushed index to be loaded", i)
+		}
+		if _, ok := fc2.Index.Objects["unindexed"]; ok != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, ok)
+		}
+		// the index is not flushed, so each test starts from the same index
+		fc2.Index.Close()
+		close(fc2.quit)
+		fc2.markClosed()
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/compression"
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// streamed objects are written in the same MessagePack encoding as an index.Object, with the
+// value last, and its length written as a fixed-width bin32 header that is filled in once
+// the value has been written
+const bin32 = 0xc6
+
+// errKeyMismatch indicates the data file holds an object for a different, colliding key
+var errKeyMismatch = errors.New("object key mismatch")
+
+// StoreStream places the object read from r in the cache using the specified key and ttl,
+// writing it to its data file in chunks rather than buffering it in memory
+func (c *Cache) StoreStream(cacheKey string, r io.Reader, ttl time.Duration) error {
+
+	ttl = cache.ClampTTL(c.Config, ttl)
+	if ttl < 1 {
+		return fmt.Errorf("invalid ttl: %d", int64(ttl.Seconds()))
+	}
+
+	if cacheKey == "" {
+		return fmt.Errorf("cacheKey required")
+	}
+
+	// values are encrypted as a whole, so encrypted streams are buffered in full
+	if c.Config.Encryption != nil && c.Config.Encryption.Enabled() {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return c.Store(cacheKey, data, ttl)
+	}
+
+	dataFile := c.getFileName(cacheKey)
+	o := &index.Object{Key: cacheKey, Expiration: time.Now().Add(ttl)}
+
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+
+	// this write supersedes any pending batched write for the key
+	c.cancelPendingWrite(cacheKey)
+	// streamed values are not compressed, but are prefixed with the codec header of an
+	// uncompressed value, so they can be retrieved by Retrieve like any other value
+	if c.Config.CompressionCodec != "" {
+		r = io.MultiReader(bytes.NewReader([]byte{byte(compression.None)}), r)
+	}
+	n, err := c.writeFileStream(dataFile, streamHeader(o), r,
+		c.Config.Filesystem.SyncMode == flo.SyncModeWrite)
+	if err != nil {
+		nl.Release()
+		return err
+	}
+	if c.Config.Filesystem.SyncMode == flo.SyncModePeriodic {
+		c.markDirty(dataFile)
+	}
+	o.Size = n
+	c.Index.UpdateObject(o)
+	nl.Release()
+
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(n))
+	c.Logger.Debug("filesystem cache store stream",
+		log.Pairs{"key": cacheKey, "dataFile": dataFile, "objectSize": n})
+	return nil
+}
+
+// RetrieveStream writes the object cached at the key to w, reading it from its data file
+// in chunks rather than buffering it in memory
+func (c *Cache) RetrieveStream(cacheKey string, allowExpired bool,
+	w io.Writer) (status.LookupStatus, error) {
+
+	// batched writes are held in memory, legacy files are migrated on retrieval, and
+	// encrypted values are decrypted as a whole, so all are retrieved in full
+	if _, ok := c.getPendingWrite(cacheKey); ok ||
+		(c.Config.Encryption != nil && c.Config.Encryption.Enabled()) {
+		return c.retrieveTo(cacheKey, allowExpired, w)
+	}
+
+	dataFile := c.getFileName(cacheKey)
+
+	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+	n, err := c.readFileStream(dataFile, cacheKey, allowExpired, w)
+	nl.RRelease()
+
+	switch {
+	case os.IsNotExist(err):
+		return c.retrieveTo(cacheKey, allowExpired, w)
+	case err == errKeyMismatch || err == cache.ErrKNF:
+		c.Logger.Debug("filesystem cache miss", log.Pairs{"key": cacheKey, "dataFile": dataFile})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return status.LookupStatusKeyMiss, cache.ErrKNF
+	case err != nil && n == 0:
+		_, err2 := metrics.CacheError(cacheKey, c.Name, c.Config.CacheType,
+			"value for key [%s] could not be deserialized from cache")
+		return status.LookupStatusError, err2
+	case err != nil:
+		// the object was partially written to w, so there is no way to recover
+		return status.LookupStatusError, err
+	}
+
+	c.Logger.Debug("filesystem cache retrieve stream", log.Pairs{"key": cacheKey, "dataFile": dataFile})
+	c.Index.QueueObjectAccess(cacheKey)
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(n))
+	return status.LookupStatusHit, nil
+}
+
+// retrieveTo retrieves the object cached at the key in full, and writes it to w
+func (c *Cache) retrieveTo(cacheKey string, allowExpired bool,
+	w io.Writer) (status.LookupStatus, error) {
+	data, lookupStatus, err := c.Retrieve(cacheKey, allowExpired)
+	if err != nil {
+		return lookupStatus, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return status.LookupStatusError, err
+	}
+	return lookupStatus, nil
+}
+
+// streamHeader returns the encoding of the object's fields, followed by the key of its
+// value and a bin32 header with a zero length
+func streamHeader(o *index.Object) []byte {
+	b := msgp.AppendMapHeader(nil, 6)
+	b = msgp.AppendString(b, "key")
+	b = msgp.AppendString(b, o.Key)
+	b = msgp.AppendString(b, "expiration")
+	b = msgp.AppendTime(b, o.Expiration)
+	b = msgp.AppendString(b, "lastwrite")
+	b = msgp.AppendTime(b, o.LastWrite)
+	b = msgp.AppendString(b, "lastaccess")
+	b = msgp.AppendTime(b, o.LastAccess)
+	b = msgp.AppendString(b, "size")
+	b = msgp.AppendInt64(b, o.Size)
+	b = msgp.AppendString(b, "value")
+	return append(b, bin32, 0, 0, 0, 0)
+}
+
+// writeFileStream writes the header and the data read from r to the named file, and then
+// fills in the length of the value in the header. It returns the number of bytes read from r
+func (c *Cache) writeFileStream(path string, header []byte, r io.Reader,
+	sync bool) (int64, error) {
+	var n int64
+	err := c.writeFileAtomic(path, sync, func(f *os.File) error {
+		if _, err := f.Write(header); err != nil {
+			return err
+		}
+		var err error
+		if n, err = io.Copy(f, r); err != nil {
+			return err
+		}
+		if n > math.MaxUint32 {
+			return fmt.Errorf("object size %d exceeds the maximum of %d", n, uint32(math.MaxUint32))
+		}
+		sz := make([]byte, 4)
+		binary.BigEndian.PutUint32(sz, uint32(n))
+		_, err = f.WriteAt(sz, int64(len(header)-4))
+		return err
+	})
+	return n, err
+}
+
+// copyCompressed copies the compressed value of size sz read from r to w. Uncompressed values
+// are copied in chunks, while compressed values are decoded in full
+func copyCompressed(w io.Writer, r io.Reader, sz int64) (int64, error) {
+	h := make([]byte, 1)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return 0, err
+	}
+	if compression.Codec(h[0]) == compression.None {
+		return io.CopyN(w, r, sz-1)
+	}
+	b := make([]byte, sz)
+	b[0] = h[0]
+	if _, err := io.ReadFull(r, b[1:]); err != nil {
+		return 0, err
+	}
+	data, err := compression.Decode(b)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// readFileStream decodes the object in the named file while holding a shared file lock,
+// and copies its value to w. It returns the number of bytes written to w
+func (c *Cache) readFileStream(path, cacheKey string, allowExpired bool,
+	w io.Writer) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err = lockFile(f, false); err != nil {
+		return 0, err
+	}
+	defer unlockFile(f)
+
+	mr := msgp.NewReader(f)
+	fields, err := mr.ReadMapHeader()
+	if err != nil {
+		return 0, err
+	}
+	for ; fields > 0; fields-- {
+		field, err := mr.ReadMapKeyPtr()
+		if err != nil {
+			return 0, err
+		}
+		switch string(field) {
+		case "key":
+			key, err := mr.ReadString()
+			if err != nil {
+				return 0, err
+			}
+			// the filename is a hash of the key, so the key in the object confirms it is
+			// the requested one, and not a colliding key
+			if key != "" && key != cacheKey {
+				return 0, errKeyMismatch
+			}
+		case "value":
+			exp := c.Index.GetExpiration(cacheKey)
+			if !allowExpired && !exp.IsZero() && !exp.After(time.Now()) {
+				// Cache Object has been expired but not reaped, go ahead and delete it
+				go c.remove(cacheKey, false)
+				return 0, cache.ErrKNF
+			}
+			sz, err := mr.ReadBytesHeader()
+			if err != nil {
+				return 0, err
+			}
+			if c.Config.CompressionCodec != "" {
+				return copyCompressed(w, mr, int64(sz))
+			}
+			return io.CopyN(w, mr, int64(sz))
+		default:
+			if err = mr.Skip(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return 0, errors.New("object has no value")
+}
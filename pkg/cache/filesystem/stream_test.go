@@ -0,0 +1,283 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestFilesystemCache_StoreStream(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a streamed object is retrievable both in full and as a stream
+	large := strings.Repeat("data", 1<<18)
+	err = fc.StoreStream(cacheKey, strings.NewReader(large), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ls, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != large {
+		t.Errorf("expected %d bytes got %d", len(large), len(data))
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	buf := &bytes.Buffer{}
+	ls, err = fc.RetrieveStream(cacheKey, false, buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if buf.String() != large {
+		t.Errorf("expected %d bytes got %d", len(large), buf.Len())
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if o, ok := fc.Index.Objects[cacheKey]; !ok || o.Size != int64(len(large)) {
+		t.Errorf("expected indexed object of size %d", len(large))
+	}
+
+	// an object stored in full is retrievable as a stream
+	err = fc.Store(cacheKey+"2", []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if _, err = fc.RetrieveStream(cacheKey+"2", false, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", buf.String())
+	}
+
+	// an object that failed to be read is not left behind, and the previous object is kept
+	err = fc.StoreStream(cacheKey+"3", strings.NewReader("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fc.StoreStream(cacheKey+"3", &errReader{}, time.Duration(60)*time.Second)
+	if err == nil {
+		t.Error("expected error for failed reader")
+	}
+	buf.Reset()
+	if _, err = fc.RetrieveStream(cacheKey+"3", false, buf); err != nil || buf.String() != "data" {
+		t.Errorf("expected previous object to be kept, got \"%s\": %v", buf.String(), err)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(fc.getFileName(cacheKey+"3")),
+		"*"+tempFileMarker+"*")); len(tmp) != 0 {
+		t.Errorf("expected failed write to be removed, got %v", tmp)
+	}
+
+	if err = fc.StoreStream("", strings.NewReader("data"), time.Second); err == nil {
+		t.Error("expected error for empty cache key")
+	}
+	if err = fc.StoreStream(cacheKey, strings.NewReader("data"), 0); err == nil {
+		t.Error("expected error for invalid ttl")
+	}
+}
+
+func TestFilesystemCache_RetrieveStream(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.Filesystem.BatchMaxBytes = 16
+	// a long interval so the test controls when batches are flushed
+	cacheConfig.Filesystem.BatchInterval = time.Hour
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// it should miss
+	buf := &bytes.Buffer{}
+	ls, err := fc.RetrieveStream(cacheKey, false, buf)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// a pending batched write is retrievable as a stream
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fc.RetrieveStream(cacheKey, false, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", buf.String())
+	}
+	fc.flushPendingWrites()
+
+	// simulate a hash collision by placing the object at another key's filename
+	os.MkdirAll(filepath.Dir(fc.getFileName(cacheKey+"2")), 0755)
+	err = os.Rename(fc.getFileName(cacheKey), fc.getFileName(cacheKey+"2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	ls, err = fc.RetrieveStream(cacheKey+"2", false, buf)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss || buf.Len() != 0 {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// an expired object should miss unless expired objects are allowed
+	err = fc.StoreStream(cacheKey, strings.NewReader("expired"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Index.UpdateObjectTTL(cacheKey, -time.Second)
+	if _, err = fc.RetrieveStream(cacheKey, true, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != "expired" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "expired", buf.String())
+	}
+	buf.Reset()
+	if ls, _ = fc.RetrieveStream(cacheKey, false, buf); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// a corrupt object is an error
+	os.MkdirAll(filepath.Dir(fc.getFileName(cacheKey+"3")), 0755)
+	if err = ioutil.WriteFile(fc.getFileName(cacheKey+"3"), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ls, err = fc.RetrieveStream(cacheKey+"3", false, buf); err == nil ||
+		ls != status.LookupStatusError {
+		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
+	}
+}
+
+// errReader is an io.Reader that always fails
+type errReader struct{}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("test error")
+}
+
+func TestFilesystemCache_StreamCompression(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.CompressionCodec = "gzip"
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("data", 1024)
+
+	// a streamed value is retrievable in full
+	err = fc.StoreStream(cacheKey, strings.NewReader(data), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != data {
+		t.Error("retrieved value does not match")
+	}
+	buf := &bytes.Buffer{}
+	if _, err = fc.RetrieveStream(cacheKey, false, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != data {
+		t.Error("retrieved stream does not match")
+	}
+
+	// a compressed value is retrievable as a stream
+	err = fc.Store(cacheKey, []byte(data), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if _, err = fc.RetrieveStream(cacheKey, false, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != data {
+		t.Error("retrieved stream does not match")
+	}
+
+}
+
+func TestFilesystemCache_StreamEncryption(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.Encryption = eo.NewOptions()
+	cacheConfig.Encryption.ActiveKeyID = "k1"
+	cacheConfig.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := cacheConfig.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("data", 1024)
+	err = fc.StoreStream(cacheKey, strings.NewReader(data), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = fc.RetrieveStream(cacheKey, false, buf); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != data {
+		t.Error("retrieved stream does not match")
+	}
+
+}
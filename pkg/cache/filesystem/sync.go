@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// pendingWrite is a batched write that has not yet been flushed to disk
+type pendingWrite struct {
+	data []byte
+}
+
+// startBackgroundWriters starts the write batcher and periodic syncer, when configured
+func (c *Cache) startBackgroundWriters() {
+	o := c.Config.Filesystem
+	if o.BatchMaxBytes > 0 {
+		go runBackground(o.BatchInterval, c.flushPendingWrites, c.quit)
+	}
+	if o.SyncMode == flo.SyncModePeriodic {
+		go runBackground(o.SyncInterval, c.syncDirtyFiles, c.quit)
+	}
+}
+
+// runBackground calls f at the provided interval until quit is closed
+func runBackground(interval time.Duration, f func(), quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			f()
+		}
+	}
+}
+
+// batchable returns true if a write of the provided size should be batched
+func (c *Cache) batchable(size int) bool {
+	return c.Config.Filesystem.BatchMaxBytes > 0 && size <= c.Config.Filesystem.BatchMaxBytes
+}
+
+// addPendingWrite queues the serialized object to be written by the next batch flush
+func (c *Cache) addPendingWrite(cacheKey string, data []byte) {
+	c.pendingLock.Lock()
+	c.pending[cacheKey] = &pendingWrite{data: data}
+	c.pendingLock.Unlock()
+}
+
+// getPendingWrite returns the serialized object for the key if its write is pending
+func (c *Cache) getPendingWrite(cacheKey string) ([]byte, bool) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if w, ok := c.pending[cacheKey]; ok {
+		return w.data, true
+	}
+	return nil, false
+}
+
+// cancelPendingWrite removes any pending write for the key, and returns true if there was one
+func (c *Cache) cancelPendingWrite(cacheKey string) bool {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if _, ok := c.pending[cacheKey]; ok {
+		delete(c.pending, cacheKey)
+		return true
+	}
+	return false
+}
+
+// flushPendingWrites writes all pending writes to disk. In the 'write' sync mode,
+// the written files are then fsynced as a group
+func (c *Cache) flushPendingWrites() {
+
+	c.pendingLock.Lock()
+	if len(c.pending) == 0 {
+		c.pendingLock.Unlock()
+		return
+	}
+	writes := make(map[string]*pendingWrite, len(c.pending))
+	for k, w := range c.pending {
+		writes[k] = w
+	}
+	c.pendingLock.Unlock()
+
+	files := make([]string, 0, len(writes))
+	for k, w := range writes {
+		nl, _ := c.locker.Acquire(c.lockPrefix + k)
+		c.pendingLock.Lock()
+		// skip writes that were superseded or removed since the flush began
+		if c.pending[k] != w {
+			c.pendingLock.Unlock()
+			nl.Release()
+			continue
+		}
+		delete(c.pending, k)
+		c.pendingLock.Unlock()
+		dataFile := c.getFileName(k)
+		if err := c.writeFile(dataFile, w.data, false); err != nil {
+			c.Logger.Error("filesystem cache batched write failed",
+				log.Pairs{"cacheName": c.Name, "key": k, "dataFile": dataFile, "detail": err.Error()})
+		} else {
+			files = append(files, dataFile)
+		}
+		nl.Release()
+	}
+
+	c.Logger.Debug("filesystem cache flushed batched writes",
+		log.Pairs{"cacheName": c.Name, "files": len(files)})
+
+	switch c.Config.Filesystem.SyncMode {
+	case flo.SyncModeWrite:
+		c.syncFiles(files)
+	case flo.SyncModePeriodic:
+		c.markDirty(files...)
+	}
+}
+
+// markDirty records written files to be fsynced by the next periodic sync
+func (c *Cache) markDirty(files ...string) {
+	c.dirtyLock.Lock()
+	for _, f := range files {
+		c.dirty[f] = true
+	}
+	c.dirtyLock.Unlock()
+}
+
+// syncDirtyFiles fsyncs the files written since the last periodic sync
+func (c *Cache) syncDirtyFiles() {
+	c.dirtyLock.Lock()
+	if len(c.dirty) == 0 {
+		c.dirtyLock.Unlock()
+		return
+	}
+	files := make([]string, 0, len(c.dirty))
+	for f := range c.dirty {
+		files = append(files, f)
+	}
+	c.dirty = make(map[string]bool)
+	c.dirtyLock.Unlock()
+	c.syncFiles(files)
+}
+
+// syncFiles fsyncs the provided files, followed by their directories
+func (c *Cache) syncFiles(files []string) {
+	if len(files) == 0 {
+		return
+	}
+	dirs := make(map[string]bool)
+	for _, name := range files {
+		dirs[filepath.Dir(name)] = true
+		f, err := os.Open(name)
+		if err != nil {
+			// the file was removed after it was written
+			continue
+		}
+		if err = f.Sync(); err != nil {
+			c.Logger.Error("filesystem cache fsync failed",
+				log.Pairs{"cacheName": c.Name, "dataFile": name, "detail": err.Error()})
+		}
+		f.Close()
+	}
+	for d := range dirs {
+		syncDir(d)
+	}
+}
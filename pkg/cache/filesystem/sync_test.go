@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestFilesystemCache_BatchedWrites(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.Filesystem.SyncMode = flo.SyncModeWrite
+	cacheConfig.Filesystem.BatchMaxBytes = 16
+	// a long interval so the test controls when batches are flushed
+	cacheConfig.Filesystem.BatchInterval = time.Hour
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a small write should be batched, and retrievable before it is flushed
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(fc.getFileName(cacheKey)); !os.IsNotExist(err) {
+		t.Errorf("expected batched write to be pending, got %v", err)
+	}
+	data, ls, err := fc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// a large write should be written immediately
+	large := strings.Repeat("data", 8)
+	err = fc.Store(cacheKey+"2", []byte(large), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(fc.getFileName(cacheKey + "2")); err != nil {
+		t.Error(err)
+	}
+
+	fc.flushPendingWrites()
+	if _, err = os.Stat(fc.getFileName(cacheKey)); err != nil {
+		t.Error(err)
+	}
+	if len(fc.pending) != 0 {
+		t.Errorf("expected %d got %d", 0, len(fc.pending))
+	}
+
+	// removing a pending write should prevent it from being flushed
+	err = fc.Store(cacheKey+"3", []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Remove(cacheKey + "3")
+	fc.flushPendingWrites()
+	if _, err = os.Stat(fc.getFileName(cacheKey + "3")); !os.IsNotExist(err) {
+		t.Errorf("expected removed write not to be flushed, got %v", err)
+	}
+	if _, ls, _ = fc.Retrieve(cacheKey+"3", false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// closing the cache should flush pending writes
+	err = fc.Store(cacheKey+"4", []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.Close()
+	if _, err = os.Stat(fc.getFileName(cacheKey + "4")); err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestFilesystemCache_PeriodicSync(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	cacheConfig.Filesystem.SyncMode = flo.SyncModePeriodic
+	cacheConfig.Filesystem.SyncInterval = time.Hour
+	fc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fc.dirty[fc.getFileName(cacheKey)] {
+		t.Error("expected written file to be marked for sync")
+	}
+
+	// a file removed before it is synced should be skipped
+	fc.markDirty(fc.getFileName(cacheKey + "-removed"))
+
+	fc.syncDirtyFiles()
+	if len(fc.dirty) != 0 {
+		t.Errorf("expected %d got %d", 0, len(fc.dirty))
+	}
+
+}
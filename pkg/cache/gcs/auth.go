@@ -0,0 +1,204 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// scope is the OAuth2 scope of the access tokens with which requests are authorized
+const scope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// tokenRefreshMargin is how long before its expiry an access token is refreshed
+const tokenRefreshMargin = time.Minute
+
+// defaultMetadataHost is the host of the metadata server of GCE instances and GKE pods,
+// which may be overridden by the GCE_METADATA_HOST environment variable
+const defaultMetadataHost = "metadata.google.internal"
+
+// errInvalidPrivateKey is returned when a service account's private key is not an RSA key
+var errInvalidPrivateKey = errors.New("gcs service account private_key is not a valid RSA key")
+
+// tokenResponse is the response to an access token request
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// tokenSource provides the OAuth2 access tokens with which requests are authorized,
+// fetching a new token when the current one nears its expiry
+type tokenSource struct {
+	fetch func(now time.Time) (*tokenResponse, error)
+
+	mtx    sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns a current access token
+func (ts *tokenSource) Token(now time.Time) (string, error) {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	if ts.token != "" && now.Add(tokenRefreshMargin).Before(ts.expiry) {
+		return ts.token, nil
+	}
+	tr, err := ts.fetch(now)
+	if err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", errors.New("gcs token response has no access_token")
+	}
+	ts.token = tr.AccessToken
+	ts.expiry = now.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+// serviceAccountKey is the JSON key of a service account
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// newServiceAccountTokenSource returns a tokenSource that exchanges a JWT signed by the
+// service account's key for each access token
+func newServiceAccountTokenSource(client *http.Client, b []byte) (*tokenSource, error) {
+	var k serviceAccountKey
+	if err := json.Unmarshal(b, &k); err != nil {
+		return nil, err
+	}
+	if k.Type != "service_account" || k.ClientEmail == "" || k.TokenURI == "" {
+		return nil, errors.New("gcs credentials are not a service account key")
+	}
+	pk, err := parsePrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenSource{fetch: func(now time.Time) (*tokenResponse, error) {
+		jwt, err := signJWT(pk, k.PrivateKeyID, map[string]interface{}{
+			"iss":   k.ClientEmail,
+			"scope": scope,
+			"aud":   k.TokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.PostForm(k.TokenURI, url.Values{
+			"grant_type": []string{"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  []string{jwt},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return decodeToken(resp)
+	}}, nil
+}
+
+// newMetadataTokenSource returns a tokenSource that fetches each access token of the
+// instance's service account from the metadata server
+func newMetadataTokenSource(client *http.Client) *tokenSource {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+	u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token"
+	return &tokenSource{fetch: func(time.Time) (*tokenResponse, error) {
+		r, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Metadata-Flavor", "Google")
+		resp, err := client.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		return decodeToken(resp)
+	}}
+}
+
+func decodeToken(resp *http.Response) (*tokenResponse, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("gcs token request responded with status %d", resp.StatusCode)
+	}
+	tr := &tokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(tr); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// parsePrivateKey parses the PEM-encoded PKCS #8 or PKCS #1 RSA private key
+func parsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errInvalidPrivateKey
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if pk, ok := k.(*rsa.PrivateKey); ok {
+			return pk, nil
+		}
+		return nil, errInvalidPrivateKey
+	}
+	pk, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errInvalidPrivateKey
+	}
+	return pk, nil
+}
+
+// signJWT returns the RS256-signed JSON Web Token of the claims
+func signJWT(pk *rsa.PrivateKey, keyID string, claims map[string]interface{}) (string, error) {
+	h, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	s := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	sum := sha256.Sum256([]byte(s))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, pk, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return s + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gcs is the Google Cloud Storage implementation of the Trickster Cache. Objects are
+// stored in a GCS bucket, so that a durable cache can be shared by each Trickster using the
+// bucket
+package gcs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gso "github.com/tricksterproxy/trickster/pkg/cache/gcs/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// headerExpiration is the object metadata header holding the object's expiration, in
+// milliseconds since the epoch
+const headerExpiration = "X-Goog-Meta-Trickster-Expiration"
+
+// New returns a new GCS Cache
+func New(cacheName string, cfg *options.Options, logger *tl.Logger) *objectstore.Cache {
+	return &objectstore.Cache{Name: cacheName, Config: cfg, Logger: logger,
+		Client: &Client{Options: cfg.GCS}, Prefix: cfg.GCS.Prefix}
+}
+
+// Client is the Cloud Storage XML API client of a GCS Cache
+type Client struct {
+	Options *gso.Options
+
+	client   *http.Client
+	endpoint *url.URL
+	tokens   *tokenSource
+}
+
+// Connect verifies that the configured bucket is accessible
+func (c *Client) Connect() error {
+	o := c.Options
+	var err error
+	if c.endpoint, err = url.Parse(strings.TrimSuffix(o.Endpoint, "/")); err != nil {
+		return err
+	}
+	c.client = &http.Client{Timeout: o.Timeout}
+
+	path := o.CredentialsFile
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if c.tokens, err = newServiceAccountTokenSource(c.client, b); err != nil {
+			return err
+		}
+	} else {
+		c.tokens = newMetadataTokenSource(c.client)
+	}
+
+	// listing a single object verifies the endpoint, credentials and bucket
+	resp, err := c.do(http.MethodGet, c.objectURL("", "list-type=2&max-keys=1"), nil, nil)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gcs bucket %s is not accessible: status %d", o.Bucket, resp.StatusCode)
+	}
+	return nil
+}
+
+// Put writes the object to the bucket
+func (c *Client) Put(name string, data []byte, expiration time.Time) error {
+	resp, err := c.do(http.MethodPut, c.objectURL(name, ""), objectHeader(expiration), data)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// Get reads the object from the bucket
+func (c *Client) Get(name string) ([]byte, time.Time, error) {
+	resp, err := c.do(http.MethodGet, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, time.Time{}, objectstore.ErrObjectNotFound
+	default:
+		return nil, time.Time{}, responseError(resp)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, objectInfo(name, resp.Header).Expiration, nil
+}
+
+// Stat describes the object from its headers, without reading it
+func (c *Client) Stat(name string) (objectstore.ObjectInfo, error) {
+	resp, err := c.do(http.MethodHead, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return objectstore.ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return objectstore.ObjectInfo{}, objectstore.ErrObjectNotFound
+	default:
+		return objectstore.ObjectInfo{}, fmt.Errorf("gcs responded with status %d", resp.StatusCode)
+	}
+	return objectInfo(name, resp.Header), nil
+}
+
+// SetExpiration updates the expiration of the object. Since the custom metadata of an
+// object can only be modified by the JSON API, the object is copied onto itself with the
+// new expiration
+func (c *Client) SetExpiration(name string, expiration time.Time) error {
+	h := objectHeader(expiration)
+	h.Set("X-Goog-Copy-Source", c.Options.Bucket+"/"+(&url.URL{Path: name}).EscapedPath())
+	h.Set("X-Goog-Metadata-Directive", "REPLACE")
+	resp, err := c.do(http.MethodPut, c.objectURL(name, ""), h, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// Delete removes the object from the bucket
+func (c *Client) Delete(name string) error {
+	resp, err := c.do(http.MethodDelete, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusNoContent)
+}
+
+// listBucketResult is the response to a list objects request
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the names of the objects in the bucket that begin with prefix
+func (c *Client) List(prefix string) ([]string, error) {
+	infos, err := c.ListInfo(prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, oi := range infos {
+		names[i] = oi.Name
+	}
+	return names, nil
+}
+
+// ListInfo returns the descriptions of the objects in the bucket that begin with prefix
+func (c *Client) ListInfo(prefix string) ([]objectstore.ObjectInfo, error) {
+	var infos []objectstore.ObjectInfo
+	q := url.Values{"list-type": []string{"2"}, "prefix": []string{prefix}}
+	for {
+		resp, err := c.do(http.MethodGet, c.objectURL("", q.Encode()), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var lr listBucketResult
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return responseError(resp)
+			}
+			return xml.NewDecoder(resp.Body).Decode(&lr)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range lr.Contents {
+			infos = append(infos, objectstore.ObjectInfo{Name: o.Key, Size: o.Size,
+				LastModified: o.LastModified})
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			return infos, nil
+		}
+		q.Set("continuation-token", lr.NextContinuationToken)
+	}
+}
+
+// Close closes the Client's idle connections
+func (c *Client) Close() error {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// objectHeader returns the headers of an object that expires at the provided time
+func objectHeader(expiration time.Time) http.Header {
+	return http.Header{
+		"Content-Type": []string{"application/octet-stream"},
+		headerExpiration: []string{strconv.FormatInt(
+			expiration.UnixNano()/int64(time.Millisecond), 10)},
+	}
+}
+
+// objectInfo returns the description of the named object from its response headers
+func objectInfo(name string, h http.Header) objectstore.ObjectInfo {
+	oi := objectstore.ObjectInfo{Name: name}
+	oi.Size, _ = strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	oi.LastModified, _ = http.ParseTime(h.Get("Last-Modified"))
+	if ms, err := strconv.ParseInt(h.Get(headerExpiration), 10, 64); err == nil {
+		oi.Expiration = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return oi
+}
+
+// objectURL returns the URL of the named object, or of the bucket when name is empty,
+// with the provided query
+func (c *Client) objectURL(name, query string) *url.URL {
+	u := *c.endpoint
+	u.Path += "/" + c.Options.Bucket + "/" + name
+	u.RawPath = ""
+	u.RawQuery = query
+	return &u
+}
+
+// do sends an authorized request to the Cloud Storage API
+func (c *Client) do(method string, u *url.URL, h http.Header, body []byte) (*http.Response, error) {
+	token, err := c.tokens.Token(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return c.client.Do(r)
+}
+
+// gcsError is the error document of a failed request
+type gcsError struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// checkResponse closes the response body, and returns an error if the status is not expected
+func checkResponse(resp *http.Response, expected int) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == expected {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return responseError(resp)
+}
+
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e gcsError
+	if xml.Unmarshal(b, &e) == nil {
+		return fmt.Errorf("gcs error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("gcs responded with status %d", resp.StatusCode)
+}
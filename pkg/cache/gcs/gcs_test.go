@@ -0,0 +1,611 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gso "github.com/tricksterproxy/trickster/pkg/cache/gcs/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "gcs"
+const cacheKey = "cacheKey"
+const testBucket = "trickster-test"
+const testToken = "test-token"
+const metadataToken = "metadata-token"
+
+var testKey *rsa.PrivateKey
+
+// testModified is the last modified time reported for each object
+var testModified = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func init() {
+	var err error
+	if testKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+}
+
+type testObject struct {
+	data       []byte
+	expiration string
+}
+
+// testGCS is an in-memory Cloud Storage XML API serving a single bucket, along with the
+// token endpoint of a service account and a metadata server
+type testGCS struct {
+	mtx           sync.Mutex
+	objects       map[string]*testObject
+	tokenRequests int
+}
+
+func newTestGCS() (*testGCS, *httptest.Server) {
+	s := &testGCS{objects: make(map[string]*testObject)}
+	return s, httptest.NewServer(s)
+}
+
+func (s *testGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch r.URL.Path {
+	case "/token":
+		s.tokenRequests++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" ||
+			!verifyJWT(r.FormValue("assertion")) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"` + testToken + `","expires_in":3600}`))
+		return
+	case "/computeMetadata/v1/instance/service-accounts/default/token":
+		s.tokenRequests++
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"` + metadataToken + `","expires_in":3600}`))
+		return
+	}
+
+	if a := r.Header.Get("Authorization"); a != "Bearer "+testToken && a != "Bearer "+metadataToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if !strings.HasPrefix(path, testBucket+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchBucket</Code><Message>test</Message></Error>"))
+		return
+	}
+	name := strings.TrimPrefix(path, testBucket+"/")
+	qp := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch {
+	case name == "" && r.Method == http.MethodGet && qp.Get("list-type") == "2":
+		// lists one object per page, to exercise continuation
+		var names []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, qp.Get("prefix")) && k > qp.Get("continuation-token") {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			w.Write([]byte("<ListBucketResult></ListBucketResult>"))
+			return
+		}
+		w.Write([]byte("<ListBucketResult><Contents><Key>" + names[0] + "</Key>" +
+			"<Size>" + strconv.Itoa(len(s.objects[names[0]].data)) + "</Size>" +
+			"<LastModified>" + testModified.Format("2006-01-02T15:04:05.000Z") +
+			"</LastModified></Contents>" +
+			"<IsTruncated>" + strconv.FormatBool(len(names) > 1) + "</IsTruncated>" +
+			"<NextContinuationToken>" + names[0] + "</NextContinuationToken></ListBucketResult>"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Goog-Copy-Source") != "":
+		src, _ := url.PathUnescape(r.Header.Get("X-Goog-Copy-Source"))
+		o, ok := s.objects[strings.TrimPrefix(src, testBucket+"/")]
+		if !ok || r.Header.Get("X-Goog-Metadata-Directive") != "REPLACE" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>test</Message></Error>"))
+			return
+		}
+		s.objects[name] = &testObject{data: o.data, expiration: r.Header.Get(headerExpiration)}
+	case r.Method == http.MethodPut:
+		s.objects[name] = &testObject{data: body, expiration: r.Header.Get(headerExpiration)}
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		o, ok := s.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if o.expiration != "" {
+			w.Header().Set(headerExpiration, o.expiration)
+		}
+		w.Header().Set("Last-Modified", testModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
+		w.Write(o.data)
+	case r.Method == http.MethodDelete:
+		if _, ok := s.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>test</Message></Error>"))
+			return
+		}
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verifyJWT returns true if the token is signed by the test key, for the test service account
+func verifyJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+		return false
+	}
+	b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	json.Unmarshal(b, &claims)
+	return claims["iss"] == "trickster@test.iam.gserviceaccount.com" && claims["scope"] == scope
+}
+
+// writeTestCredentials writes the JSON key of the test service account, and returns its path
+func writeTestCredentials(t *testing.T, tokenURI string) string {
+	b, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, _ := json.Marshal(serviceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "trickster@test.iam.gserviceaccount.com",
+		PrivateKeyID: "1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})),
+		TokenURI:     tokenURI,
+	})
+	return writeTestFile(t, k)
+}
+
+// writeTestFile writes the data to a temporary file, and returns its path
+func writeTestFile(t *testing.T, data []byte) string {
+	f, err := ioutil.TempFile("", "trickster-gcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func newTestOptions(t *testing.T, endpoint string) *gso.Options {
+	o := gso.NewOptions()
+	o.Endpoint = endpoint
+	o.Bucket = testBucket
+	o.CredentialsFile = writeTestCredentials(t, endpoint+"/token")
+	return o
+}
+
+func newTestClient(t *testing.T) (*Client, *testGCS, func()) {
+	s, ts := newTestGCS()
+	c := &Client{Options: newTestOptions(t, ts.URL)}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, s, func() {
+		c.Close()
+		ts.Close()
+		os.Remove(c.Options.CredentialsFile)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, ts := newTestGCS()
+	defer ts.Close()
+	cacheConfig := co.Options{CacheType: cacheType, GCS: newTestOptions(t, ts.URL)}
+	defer os.Remove(cacheConfig.GCS.CredentialsFile)
+	c := New("test", &cacheConfig, tl.ConsoleLogger("error"))
+	if c.Prefix != "trickster/" {
+		t.Errorf("expected %s got %s", "trickster/", c.Prefix)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// it should store a value under the configured prefix
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected hit, got %s %s %v", string(data), ls, err)
+	}
+	keys, err := c.Keys("")
+	if err != nil || !reflect.DeepEqual(keys, []string{cacheKey}) {
+		t.Errorf("expected %v got %v %v", []string{cacheKey}, keys, err)
+	}
+}
+
+func TestGCSClient_Connect(t *testing.T) {
+	_, s, closer := newTestClient(t)
+	defer closer()
+	if s.tokenRequests != 1 {
+		t.Errorf("expected %d token requests got %d", 1, s.tokenRequests)
+	}
+}
+
+func TestGCSClient_ConnectEnvironmentCredentials(t *testing.T) {
+	_, ts := newTestGCS()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(t, ts.URL)}
+	defer os.Remove(c.Options.CredentialsFile)
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", c.Options.CredentialsFile)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	c.Options.CredentialsFile = ""
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGCSClient_ConnectMetadata(t *testing.T) {
+	s, ts := newTestGCS()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(t, ts.URL)}
+	os.Remove(c.Options.CredentialsFile)
+	c.Options.CredentialsFile = ""
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(ts.URL, "http://"))
+	defer os.Unsetenv("GCE_METADATA_HOST")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if s.tokenRequests != 1 {
+		t.Errorf("expected %d token requests got %d", 1, s.tokenRequests)
+	}
+}
+
+func TestGCSClient_ConnectFailed(t *testing.T) {
+	_, ts := newTestGCS()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(t, ts.URL)}
+	defer os.Remove(c.Options.CredentialsFile)
+	c.Options.Bucket = "missing"
+	const expected = "gcs bucket missing is not accessible: status 404"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+
+	c.Options.Endpoint = ":"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for invalid endpoint")
+	}
+	c.Options.Endpoint = ts.URL
+
+	// the service account's tokens are rejected by a token endpoint that can't verify them
+	path := writeTestCredentials(t, ts.URL+"/missing")
+	defer os.Remove(path)
+	c.Options.CredentialsFile = path
+	if err := c.Connect(); err == nil || err.Error() != "gcs token request responded with status 401" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	c.Options.CredentialsFile = path + ".missing"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for missing credentials file")
+	}
+
+	for _, k := range []string{"{", `{"type":"authorized_user"}`,
+		`{"type":"service_account","client_email":"a","token_uri":"b","private_key":"c"}`} {
+		c.Options.CredentialsFile = writeTestFile(t, []byte(k))
+		if err := c.Connect(); err == nil {
+			t.Errorf("expected error for credentials %s", k)
+		}
+		os.Remove(c.Options.CredentialsFile)
+	}
+}
+
+func TestGCSClient_PutGet(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	// it should put an object
+	exp := time.Unix(1577836800, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err != nil {
+		t.Error(err)
+	}
+	if o, ok := s.objects[cacheKey]; !ok || o.expiration != "1577836800000" {
+		t.Errorf("expected object with expiration, got %v", o)
+	}
+
+	// it should get an object and its expiration
+	data, e, err := c.Get(cacheKey)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+	if !e.Equal(exp) {
+		t.Errorf("expected %v got %v", exp, e)
+	}
+
+	// it should not find an object that was not put
+	if _, _, err = c.Get("missing"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+
+	// names are escaped in the object URL
+	if err = c.Put("a key/with?chars", []byte("escaped"), exp); err != nil {
+		t.Error(err)
+	}
+	if data, _, _ = c.Get("a key/with?chars"); string(data) != "escaped" {
+		t.Errorf("expected %s got %s", "escaped", string(data))
+	}
+
+	// an object without an expiration never expires
+	s.objects["noexp"] = &testObject{data: []byte("data")}
+	if _, e, err = c.Get("noexp"); err != nil || !e.IsZero() {
+		t.Errorf("expected zero expiration, got %v %v", e, err)
+	}
+}
+
+func TestGCSClient_SetExpiration(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put("a key", []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetExpiration("a key", time.Unix(2, 0)); err != nil {
+		t.Error(err)
+	}
+	if o := s.objects["a key"]; o.expiration != "2000" || string(o.data) != "data" {
+		t.Errorf("expected copied object with new expiration, got %v", o)
+	}
+
+	// the expiration of a missing object can't be set
+	if err := c.SetExpiration("missing", time.Unix(2, 0)); err == nil ||
+		err.Error() != "gcs error NoSuchKey: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGCSClient_Delete(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.Delete(cacheKey); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := c.Get(cacheKey); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+	if err := c.Delete(cacheKey); err == nil || err.Error() != "gcs error NoSuchKey: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGCSClient_List(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	for _, k := range []string{"a.dpc.1", "a.dpc.2", "a.opc.1", "b.dpc.1"} {
+		s.objects["trickster/"+k] = &testObject{data: []byte("data")}
+	}
+	s.objects["other/a.dpc.3"] = &testObject{data: []byte("data")}
+
+	names, err := c.List("trickster/a.dpc.")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"trickster/a.dpc.1", "trickster/a.dpc.2"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+
+	names, err = c.List("trickster/c.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no names got %v", names)
+	}
+
+	c.tokens.token = "invalid"
+	if _, err = c.List(""); err == nil || err.Error() != "gcs responded with status 401" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGCSClient_ListInfo(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	s.objects["trickster/a"] = &testObject{data: []byte("data")}
+	s.objects["trickster/b"] = &testObject{data: []byte("longer data")}
+
+	infos, err := c.ListInfo("trickster/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []objectstore.ObjectInfo{
+		{Name: "trickster/a", Size: 4, LastModified: testModified},
+		{Name: "trickster/b", Size: 11, LastModified: testModified},
+	}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("expected %v got %v", expected, infos)
+	}
+}
+
+func TestGCSClient_Stat(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	defer closer()
+
+	exp := time.Unix(1577934245, 0)
+	if err := c.Put("trickster/"+cacheKey, []byte("data"), exp); err != nil {
+		t.Fatal(err)
+	}
+
+	oi, err := c.Stat("trickster/" + cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Name != "trickster/"+cacheKey || oi.Size != 4 || !oi.LastModified.Equal(testModified) ||
+		!oi.Expiration.Equal(exp) {
+		t.Errorf("unexpected object info: %v", oi)
+	}
+
+	if _, err = c.Stat("trickster/missing"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+
+	c.tokens.token = "invalid"
+	if _, err = c.Stat("trickster/" + cacheKey); err == nil ||
+		err.Error() != "gcs responded with status 401" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGCSClient_Errors(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	closer()
+
+	// requests to an unavailable endpoint fail
+	exp := time.Unix(1, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, _, err := c.Get(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.Delete(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.SetExpiration(cacheKey, exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := c.List(""); err == nil {
+		t.Error("expected error")
+	}
+
+	// requests with invalid tokens are denied
+	c, _, closer = newTestClient(t)
+	defer closer()
+	c.tokens.token = "invalid"
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil ||
+		err.Error() != "gcs responded with status 401" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, _, err := c.Get(cacheKey); err == nil || err.Error() != "gcs responded with status 401" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+
+	var fetches int
+	ts := &tokenSource{fetch: func(time.Time) (*tokenResponse, error) {
+		fetches++
+		return &tokenResponse{AccessToken: "token" + strconv.Itoa(fetches), ExpiresIn: 3600}, nil
+	}}
+
+	// tokens are reused until they near their expiry
+	now := time.Now()
+	for _, test := range []struct {
+		now      time.Time
+		expected string
+	}{
+		{now, "token1"},
+		{now.Add(58 * time.Minute), "token1"},
+		{now.Add(59 * time.Minute), "token2"},
+	} {
+		token, err := ts.Token(test.now)
+		if err != nil {
+			t.Error(err)
+		}
+		if token != test.expected {
+			t.Errorf("expected %s got %s", test.expected, token)
+		}
+	}
+
+	// a response without a token is an error
+	ts = &tokenSource{fetch: func(time.Time) (*tokenResponse, error) {
+		return &tokenResponse{}, nil
+	}}
+	if _, err := ts.Token(now); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(testKey)})
+	if _, err := parsePrivateKey(string(b)); err != nil {
+		t.Error(err)
+	}
+
+	b = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")})
+	if _, err := parsePrivateKey(string(b)); err != errInvalidPrivateKey {
+		t.Errorf("expected %v got %v", errInvalidPrivateKey, err)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrMissingBucket is returned when no bucket is configured
+var ErrMissingBucket = errors.New("gcs bucket must be provided")
+
+// ErrInvalidEndpoint is returned when the endpoint is not an http or https URL
+var ErrInvalidEndpoint = errors.New("gcs endpoint must be an http or https URL")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("gcs timeout_ms must be greater than 0")
+
+// Options is a collection of Configurations for storing cached data in a Google Cloud
+// Storage bucket
+type Options struct {
+	// Endpoint is the base URL of the Cloud Storage XML API
+	Endpoint string `toml:"endpoint"`
+	// Bucket is the name of the bucket in which objects are stored
+	Bucket string `toml:"bucket"`
+	// Prefix is prepended to the cache key to form the name of each object
+	Prefix string `toml:"prefix"`
+	// CredentialsFile is the path to the JSON key of the service account with which requests
+	// are authorized. When empty, the GOOGLE_APPLICATION_CREDENTIALS environment variable is
+	// used, and otherwise the service account of the instance, from the metadata server
+	CredentialsFile string `toml:"credentials_file"`
+	// TimeoutMS is the timeout of each request to the Cloud Storage API
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new GCS Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Endpoint:  d.DefaultGCSEndpoint,
+		Prefix:    d.DefaultGCSPrefix,
+		TimeoutMS: d.DefaultGCSTimeoutMS,
+		Timeout:   time.Duration(d.DefaultGCSTimeoutMS) * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if o.Bucket == "" {
+		return ErrMissingBucket
+	}
+	u, err := url.Parse(o.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidEndpoint
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
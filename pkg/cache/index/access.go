@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import "time"
+
+// access is an object access queued for the Index to record
+type access struct {
+	key  string
+	time time.Time
+}
+
+// QueueObjectAccess queues the access of the object with the provided key, to be recorded
+// by the Index's access recorder in a batch with others. When the queue is full, the caller
+// waits for room, so that retrievals are slowed, rather than the recorder being outpaced.
+// When the Index has no access queue, the access is recorded immediately
+func (idx *Index) QueueObjectAccess(key string) {
+	if idx.accesses == nil {
+		idx.UpdateObjectAccessTime(key)
+		return
+	}
+	select {
+	case idx.accesses <- access{key: key, time: time.Now()}:
+	case <-idx.accessRecorderDone:
+		// the recorder has exited, so the Index is closing
+	}
+}
+
+// accessRecorder records the queued object accesses in batches of the configured size, or
+// at the configured interval when there are fewer than a batch
+func (idx *Index) accessRecorder() {
+	batch := make([]access, 0, idx.options.AccessBatchSize)
+	ticker := time.NewTicker(idx.options.AccessFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case a := <-idx.accesses:
+			batch = append(batch, a)
+			if len(batch) >= idx.options.AccessBatchSize {
+				idx.recordAccesses(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				idx.recordAccesses(batch)
+				batch = batch[:0]
+			}
+		case <-idx.closing:
+			if len(batch) > 0 {
+				idx.recordAccesses(batch)
+			}
+			close(idx.accessRecorderDone)
+			return
+		}
+	}
+}
+
+// recordAccesses updates the LastAccess of each object in the batch under a single lock
+func (idx *Index) recordAccesses(batch []access) {
+	idx.mtx.Lock()
+	for _, a := range batch {
+		idx.updateObjectAccessTime(a.key, a.time)
+	}
+	idx.mtx.Unlock()
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"testing"
+	"time"
+
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+)
+
+func TestQueueObjectAccess(t *testing.T) {
+
+	o := io.NewOptions()
+	o.ReapInterval = 0
+	o.FlushInterval = 0
+	o.AccessQueueSize = 4
+	o.AccessBatchSize = 2
+	o.AccessFlushInterval = 50 * time.Millisecond
+
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	idx.UpdateObject(&Object{Key: "a", Value: []byte("a")})
+	idx.UpdateObject(&Object{Key: "b", Value: []byte("b")})
+
+	// the first two accesses are recorded as a batch, and the third at the next interval
+	idx.QueueObjectAccess("a")
+	idx.QueueObjectAccess("a")
+	idx.QueueObjectAccess("b")
+	time.Sleep(200 * time.Millisecond)
+
+	idx.mtx.Lock()
+	if h := idx.Objects["a"].Hits; h != 2 {
+		t.Errorf("expected %d got %d", 2, h)
+	}
+	if h := idx.Objects["b"].Hits; h != 1 {
+		t.Errorf("expected %d got %d", 1, h)
+	}
+	idx.mtx.Unlock()
+
+	idx.Close()
+	select {
+	case <-idx.accessRecorderDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected access recorder to exit")
+	}
+
+	// once the recorder has exited, accesses are not queued, rather than waiting for room
+	for i := 0; i < 10; i++ {
+		idx.QueueObjectAccess("a")
+	}
+
+	// without an access queue, accesses are recorded immediately
+	o = io.NewOptions()
+	o.ReapInterval = 0
+	o.AccessQueueSize = 0
+	idx = NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	idx.UpdateObject(&Object{Key: "a", Value: []byte("a")})
+	idx.QueueObjectAccess("a")
+	if h := idx.Objects["a"].Hits; h != 1 {
+		t.Errorf("expected %d got %d", 1, h)
+	}
+
+}
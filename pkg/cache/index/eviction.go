@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/index/options"
+)
+
+// EvictionPolicy determines the order in which the Index evicts Objects when the cache
+// exceeds its size limits. The Index serializes all calls to its EvictionPolicy, so
+// implementations need not be safe for concurrent use
+type EvictionPolicy interface {
+	// Name returns the name of the EvictionPolicy
+	Name() string
+	// Add is called when an Object is written to the Index
+	Add(o *Object)
+	// Access is called when an Object in the Index is accessed
+	Access(o *Object)
+	// Remove is called when an Object is removed from the Index. evicted is true when
+	// the Object was removed to maintain the cache's size limits
+	Remove(key string, evicted bool)
+	// Order sorts the provided Objects in the order they should be evicted
+	Order(objs []*Object)
+}
+
+// NewEvictionPolicy returns a new EvictionPolicy for the provided name, which defaults to LRU
+func NewEvictionPolicy(name string) EvictionPolicy {
+	switch strings.ToLower(name) {
+	case options.EvictionPolicyLFU:
+		return &lfuPolicy{counts: make(map[string]int64)}
+	case options.EvictionPolicyARC:
+		return newARCPolicy()
+	case options.EvictionPolicySizeWeighted:
+		return &sizeWeightedPolicy{}
+	}
+	return &lruPolicy{}
+}
+
+// lruPolicy evicts the least-recently-accessed Objects first
+type lruPolicy struct{}
+
+func (p *lruPolicy) Name() string                    { return options.EvictionPolicyLRU }
+func (p *lruPolicy) Add(o *Object)                   {}
+func (p *lruPolicy) Access(o *Object)                {}
+func (p *lruPolicy) Remove(key string, evicted bool) {}
+
+func (p *lruPolicy) Order(objs []*Object) {
+	sort.Sort(objectsAtime(objs))
+}
+
+// lfuPolicy evicts the least-frequently-accessed Objects first, and the least-recently-accessed
+// among those with the same frequency. Frequencies are halved after each eviction exercise so
+// that formerly popular Objects can age out of the cache
+type lfuPolicy struct {
+	counts map[string]int64
+}
+
+func (p *lfuPolicy) Name() string { return options.EvictionPolicyLFU }
+
+func (p *lfuPolicy) Add(o *Object) {
+	p.counts[o.Key]++
+}
+
+func (p *lfuPolicy) Access(o *Object) {
+	p.counts[o.Key]++
+}
+
+func (p *lfuPolicy) Remove(key string, evicted bool) {
+	delete(p.counts, key)
+}
+
+func (p *lfuPolicy) Order(objs []*Object) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		ci, cj := p.counts[objs[i].Key], p.counts[objs[j].Key]
+		if ci != cj {
+			return ci < cj
+		}
+		return objs[i].LastAccess.Before(objs[j].LastAccess)
+	})
+	for k, c := range p.counts {
+		p.counts[k] = (c + 1) / 2
+	}
+}
+
+// sizeWeightedPolicy evicts the Objects with the largest product of size and time since
+// last access first, so that large, stale Objects free their space before small, hot ones
+type sizeWeightedPolicy struct{}
+
+func (p *sizeWeightedPolicy) Name() string                    { return options.EvictionPolicySizeWeighted }
+func (p *sizeWeightedPolicy) Add(o *Object)                   {}
+func (p *sizeWeightedPolicy) Access(o *Object)                {}
+func (p *sizeWeightedPolicy) Remove(key string, evicted bool) {}
+
+func (p *sizeWeightedPolicy) Order(objs []*Object) {
+	now := time.Now()
+	weight := func(o *Object) float64 {
+		return float64(o.Size+1) * float64(now.Sub(o.LastAccess)+1)
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return weight(objs[i]) > weight(objs[j])
+	})
+}
+
+// arcPolicy evicts Objects per the Adaptive Replacement Cache algorithm. Resident Objects
+// are tracked as either recent (accessed once) or frequent (accessed more than once), and
+// the keys of evicted Objects are remembered in ghost lists. A write of a key found in a
+// ghost list adapts the target size of the recent list toward the workload, and the Index
+// evicts from the recent list first while it exceeds its target, or the frequent list otherwise
+type arcPolicy struct {
+	target   int
+	recent   map[string]bool
+	frequent map[string]bool
+	ghosts   [2]*list.List
+	ghostIdx [2]map[string]*list.Element
+}
+
+const (
+	ghostRecent = iota
+	ghostFrequent
+)
+
+func newARCPolicy() *arcPolicy {
+	return &arcPolicy{
+		recent:   make(map[string]bool),
+		frequent: make(map[string]bool),
+		ghosts:   [2]*list.List{list.New(), list.New()},
+		ghostIdx: [2]map[string]*list.Element{
+			make(map[string]*list.Element), make(map[string]*list.Element)},
+	}
+}
+
+func (p *arcPolicy) Name() string { return options.EvictionPolicyARC }
+
+func (p *arcPolicy) Add(o *Object) {
+	key := o.Key
+	if p.recent[key] || p.frequent[key] {
+		p.Access(o)
+		return
+	}
+	capacity := len(p.recent) + len(p.frequent) + 1
+	if _, ok := p.ghostIdx[ghostRecent][key]; ok {
+		p.target += maxInt(1, p.ghosts[ghostFrequent].Len()/p.ghosts[ghostRecent].Len())
+		if p.target > capacity {
+			p.target = capacity
+		}
+		p.removeGhost(ghostRecent, key)
+		p.frequent[key] = true
+		return
+	}
+	if _, ok := p.ghostIdx[ghostFrequent][key]; ok {
+		p.target -= maxInt(1, p.ghosts[ghostRecent].Len()/p.ghosts[ghostFrequent].Len())
+		if p.target < 0 {
+			p.target = 0
+		}
+		p.removeGhost(ghostFrequent, key)
+		p.frequent[key] = true
+		return
+	}
+	p.recent[key] = true
+}
+
+func (p *arcPolicy) Access(o *Object) {
+	if p.recent[o.Key] {
+		delete(p.recent, o.Key)
+		p.frequent[o.Key] = true
+	}
+}
+
+func (p *arcPolicy) Remove(key string, evicted bool) {
+	g := -1
+	if p.recent[key] {
+		delete(p.recent, key)
+		g = ghostRecent
+	} else if p.frequent[key] {
+		delete(p.frequent, key)
+		g = ghostFrequent
+	}
+	if !evicted || g < 0 {
+		return
+	}
+	p.ghostIdx[g][key] = p.ghosts[g].PushFront(key)
+	// each ghost list remembers no more keys than there are resident objects
+	limit := len(p.recent) + len(p.frequent)
+	for _, i := range []int{ghostRecent, ghostFrequent} {
+		for p.ghosts[i].Len() > limit {
+			p.removeGhost(i, p.ghosts[i].Back().Value.(string))
+		}
+	}
+}
+
+func (p *arcPolicy) removeGhost(g int, key string) {
+	if e, ok := p.ghostIdx[g][key]; ok {
+		p.ghosts[g].Remove(e)
+		delete(p.ghostIdx[g], key)
+	}
+}
+
+func (p *arcPolicy) Order(objs []*Object) {
+	recentFirst := len(p.recent) > p.target
+	rank := func(o *Object) int {
+		if p.frequent[o.Key] == recentFirst {
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		ri, rj := rank(objs[i]), rank(objs[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return objs[i].LastAccess.Before(objs[j].LastAccess)
+	})
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
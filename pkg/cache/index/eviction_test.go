@@ -0,0 +1,183 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"testing"
+	"time"
+
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+)
+
+func testEvictionObjects() []*Object {
+	now := time.Now()
+	return []*Object{
+		{Key: "a", Size: 10, LastAccess: now.Add(-time.Minute)},
+		{Key: "b", Size: 1000, LastAccess: now.Add(-time.Second * 30)},
+		{Key: "c", Size: 10, LastAccess: now.Add(-time.Second * 10)},
+	}
+}
+
+func testEvictionOrder(objs []*Object) string {
+	var s string
+	for _, o := range objs {
+		s += o.Key
+	}
+	return s
+}
+
+func TestNewEvictionPolicy(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"", io.EvictionPolicyLRU},
+		{"lru", io.EvictionPolicyLRU},
+		{"LFU", io.EvictionPolicyLFU},
+		{"arc", io.EvictionPolicyARC},
+		{"size_weighted", io.EvictionPolicySizeWeighted},
+	}
+	for _, test := range tests {
+		if n := NewEvictionPolicy(test.name).Name(); n != test.expected {
+			t.Errorf("expected %s got %s", test.expected, n)
+		}
+	}
+}
+
+func TestLRUPolicy(t *testing.T) {
+	p := NewEvictionPolicy(io.EvictionPolicyLRU)
+	objs := testEvictionObjects()
+	objs[0], objs[2] = objs[2], objs[0]
+	for _, o := range objs {
+		p.Add(o)
+		p.Access(o)
+	}
+	p.Order(objs)
+	if s := testEvictionOrder(objs); s != "abc" {
+		t.Errorf("expected %s got %s", "abc", s)
+	}
+	p.Remove("a", true)
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := NewEvictionPolicy(io.EvictionPolicyLFU).(*lfuPolicy)
+	objs := testEvictionObjects()
+	for _, o := range objs {
+		p.Add(o)
+	}
+	p.Access(objs[0])
+	p.Access(objs[0])
+	p.Access(objs[2])
+	p.Order(objs)
+	if s := testEvictionOrder(objs); s != "bca" {
+		t.Errorf("expected %s got %s", "bca", s)
+	}
+	// counts are halved after each ordering
+	if p.counts["a"] != 2 {
+		t.Errorf("expected %d got %d", 2, p.counts["a"])
+	}
+	p.Remove("a", true)
+	if _, ok := p.counts["a"]; ok {
+		t.Error("expected count to be removed")
+	}
+}
+
+func TestSizeWeightedPolicy(t *testing.T) {
+	p := NewEvictionPolicy(io.EvictionPolicySizeWeighted)
+	objs := testEvictionObjects()
+	for _, o := range objs {
+		p.Add(o)
+		p.Access(o)
+	}
+	p.Order(objs)
+	if s := testEvictionOrder(objs); s != "bac" {
+		t.Errorf("expected %s got %s", "bac", s)
+	}
+	p.Remove("a", true)
+}
+
+func TestARCPolicy(t *testing.T) {
+	p := NewEvictionPolicy(io.EvictionPolicyARC).(*arcPolicy)
+	objs := testEvictionObjects()
+	for _, o := range objs {
+		p.Add(o)
+	}
+
+	// a is accessed again, and is promoted to the frequent list
+	p.Access(objs[0])
+	if !p.frequent["a"] || p.recent["a"] {
+		t.Error("expected a to be frequent")
+	}
+
+	// the recent list exceeds its target of 0, so its objects are evicted first
+	p.Order(objs)
+	if s := testEvictionOrder(objs); s != "bca" {
+		t.Errorf("expected %s got %s", "bca", s)
+	}
+
+	// an evicted recent object is remembered in the recent ghost list, and
+	// rewriting it increases the recent list's target and makes it frequent
+	p.Remove("b", true)
+	if _, ok := p.ghostIdx[ghostRecent]["b"]; !ok {
+		t.Error("expected b in recent ghost list")
+	}
+	p.Add(&Object{Key: "b"})
+	if p.target != 1 {
+		t.Errorf("expected %d got %d", 1, p.target)
+	}
+	if !p.frequent["b"] {
+		t.Error("expected b to be frequent")
+	}
+
+	// with the recent list at its target, frequent objects are evicted first
+	p.Order(objs)
+	if s := testEvictionOrder(objs); s != "abc" {
+		t.Errorf("expected %s got %s", "abc", s)
+	}
+
+	// an evicted frequent object that is rewritten decreases the recent list's target
+	p.Remove("a", true)
+	p.Add(&Object{Key: "a"})
+	if p.target != 0 {
+		t.Errorf("expected %d got %d", 0, p.target)
+	}
+
+	// objects that are removed without eviction are not remembered
+	p.Remove("c", false)
+	if _, ok := p.ghostIdx[ghostRecent]["c"]; ok {
+		t.Error("expected c to not be in recent ghost list")
+	}
+}
+
+func TestReapEvictionPolicy(t *testing.T) {
+	o := &io.Options{ReapInterval: time.Second * 10, FlushInterval: time.Second * 10,
+		MaxSizeObjects: 2, EvictionPolicy: io.EvictionPolicyLFU}
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	idx.UpdateObject(&Object{Key: "test.1", Value: []byte("test_value")})
+	idx.UpdateObject(&Object{Key: "test.2", Value: []byte("test_value")})
+	idx.UpdateObject(&Object{Key: "test.3", Value: []byte("test_value")})
+	idx.UpdateObjectAccessTime("test.1")
+	idx.UpdateObjectAccessTime("test.3")
+
+	// the least-frequently-accessed object is evicted even though it is not the oldest
+	idx.reap(testLogger)
+	if _, ok := idx.Objects["test.2"]; ok {
+		t.Errorf("expected key %s to be missing", "test.2")
+	}
+	if _, ok := idx.Objects["test.1"]; !ok {
+		t.Errorf("expected key %s to be present", "test.1")
+	}
+}
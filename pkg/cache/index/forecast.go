@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	gm "github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// ForecastSizeRatios are the fractions of the cache's max size at which the share
+// of retained cache hits is projected
+var ForecastSizeRatios = []float64{0.75, 0.5, 0.25}
+
+// reuseGapSamples is the number of recent cache hits whose reuse gap is sampled
+const reuseGapSamples = 4096
+
+// forecastSmoothing is the weight of the latest interval in the smoothed ingest and removal rates
+const forecastSmoothing = 0.3
+
+// Forecast is a projection of a cache's capacity usage
+type Forecast struct {
+	// IngestRate is the smoothed rate at which bytes are written to the cache, per second
+	IngestRate float64
+	// RemovalRate is the smoothed rate at which bytes are removed from the cache, per second
+	RemovalRate float64
+	// TimeToFull is the projected time until the cache reaches its max size in bytes.
+	// It is 0 if the cache is full, and -1 if the cache is not growing or has no max size
+	TimeToFull time.Duration
+	// HitRetention is the projected share of cache hits retained at each of the
+	// ForecastSizeRatios. It is nil until cache hits have been sampled
+	HitRetention map[float64]float64
+}
+
+// recordReuseGap samples the time between consecutive accesses of an object. the caller
+// must hold the index lock
+func (idx *Index) recordReuseGap(gap time.Duration) {
+	if len(idx.reuseGaps) < reuseGapSamples {
+		idx.reuseGaps = append(idx.reuseGaps, gap)
+		return
+	}
+	idx.reuseGaps[idx.reuseGapsPos] = gap
+	idx.reuseGapsPos = (idx.reuseGapsPos + 1) % reuseGapSamples
+}
+
+// forecaster periodically updates the cache's usage forecast
+func (idx *Index) forecaster(log *tl.Logger) {
+	defer close(idx.forecasterDone)
+	for {
+		idx.mtx.Lock()
+		interval := idx.options.ForecastInterval
+		idx.mtx.Unlock()
+		select {
+		case <-idx.closing:
+			return
+		case <-time.After(interval):
+		}
+		f := idx.forecast(time.Now())
+		log.Debug("cache usage forecast updated", tl.Pairs{"cacheName": idx.name,
+			"ingestRate": f.IngestRate, "removalRate": f.RemovalRate, "timeToFull": f.TimeToFull.String()})
+	}
+}
+
+type recency struct {
+	lastAccess time.Time
+	size       int64
+}
+
+// forecast updates the cache's ingest and removal rates with the bytes written and removed
+// since the last forecast, and projects its time to full and hit retention at smaller sizes
+func (idx *Index) forecast(now time.Time) *Forecast {
+
+	idx.mtx.Lock()
+	elapsed := now.Sub(idx.lastForecast).Seconds()
+	first := idx.lastForecast.IsZero()
+	if !first && elapsed > 0 {
+		ingest := float64(idx.ingestBytes) / elapsed
+		removal := float64(idx.removedBytes) / elapsed
+		if idx.ingestRate == 0 && idx.removalRate == 0 {
+			idx.ingestRate, idx.removalRate = ingest, removal
+		} else {
+			idx.ingestRate += forecastSmoothing * (ingest - idx.ingestRate)
+			idx.removalRate += forecastSmoothing * (removal - idx.removalRate)
+		}
+	}
+	idx.ingestBytes = 0
+	idx.removedBytes = 0
+	idx.lastForecast = now
+
+	f := &Forecast{IngestRate: idx.ingestRate, RemovalRate: idx.removalRate, TimeToFull: -1}
+
+	maxSize := idx.options.MaxSizeBytes
+	size := idx.CacheSize
+	if maxSize > 0 {
+		if size >= maxSize {
+			f.TimeToFull = 0
+		} else if growth := f.IngestRate - f.RemovalRate; growth > 0 {
+			f.TimeToFull = time.Duration(float64(maxSize-size) / growth * float64(time.Second))
+		}
+	}
+
+	var recencies []recency
+	var gaps []time.Duration
+	if len(idx.reuseGaps) > 0 {
+		recencies = make([]recency, 0, len(idx.Objects))
+		for _, o := range idx.Objects {
+			if o.Key == IndexKey {
+				continue
+			}
+			recencies = append(recencies, recency{lastAccess: o.LastAccess, size: o.Size})
+		}
+		gaps = make([]time.Duration, len(idx.reuseGaps))
+		copy(gaps, idx.reuseGaps)
+	}
+	idx.mtx.Unlock()
+
+	if len(gaps) > 0 {
+		capacity := maxSize
+		if capacity <= 0 {
+			capacity = size
+		}
+		f.HitRetention = projectHitRetention(recencies, gaps, capacity, now)
+	}
+
+	gm.CacheIngestRate.WithLabelValues(idx.name, idx.cacheType).Set(f.IngestRate)
+	gm.CacheRemovalRate.WithLabelValues(idx.name, idx.cacheType).Set(f.RemovalRate)
+	if maxSize > 0 {
+		gm.CacheTimeToFull.WithLabelValues(idx.name, idx.cacheType).Set(f.TimeToFull.Seconds())
+	}
+	for r, v := range f.HitRetention {
+		gm.CacheProjectedHitRetention.WithLabelValues(idx.name, idx.cacheType,
+			strconv.FormatFloat(r, 'f', -1, 64)).Set(v)
+	}
+
+	return f
+}
+
+// projectHitRetention approximates the share of cache hits that would be retained if the
+// cache were limited to each of the ForecastSizeRatios of its capacity. With
+// least-recently-accessed eviction, a smaller cache keeps objects for only as long as it
+// takes for that many bytes of more recently-accessed objects to displace them. A hit is
+// retained when the time since its object's previous access is within that period.
+func projectHitRetention(recencies []recency, gaps []time.Duration,
+	capacity int64, now time.Time) map[float64]float64 {
+
+	sort.Slice(recencies, func(i, j int) bool {
+		return recencies[i].lastAccess.After(recencies[j].lastAccess)
+	})
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+
+	out := make(map[float64]float64, len(ForecastSizeRatios))
+	for _, r := range ForecastSizeRatios {
+		target := int64(math.Round(r * float64(capacity)))
+		// the period for which objects are kept at the target size, which is the age of
+		// the least-recently-accessed object that still fits
+		var kept time.Duration = math.MaxInt64
+		var cumulative int64
+		for i, o := range recencies {
+			cumulative += o.size
+			if cumulative > target {
+				kept = 0
+				if i > 0 {
+					kept = now.Sub(recencies[i-1].lastAccess)
+				}
+				break
+			}
+		}
+		retained := sort.Search(len(gaps), func(i int) bool { return gaps[i] > kept })
+		out[r] = float64(retained) / float64(len(gaps))
+	}
+	return out
+}
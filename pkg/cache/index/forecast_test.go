@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+)
+
+func TestForecaster(t *testing.T) {
+	idx := NewIndex("test", "test", nil, &io.Options{ForecastInterval: time.Millisecond * 10},
+		testBulkRemoveFunc, nil, testLogger)
+	time.Sleep(100 * time.Millisecond)
+	idx.Close()
+	select {
+	case <-idx.forecasterDone:
+	case <-time.After(time.Second):
+		t.Error("expected true")
+	}
+}
+
+func TestForecast(t *testing.T) {
+
+	idx := NewIndex("test", "test", nil, &io.Options{MaxSizeBytes: 1000},
+		testBulkRemoveFunc, nil, testLogger)
+
+	now := time.Now()
+	idx.lastForecast = now.Add(-10 * time.Second)
+
+	// 600 bytes written and 100 removed over 10s
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		idx.UpdateObject(&Object{Key: k, Value: make([]byte, 100)})
+	}
+	idx.RemoveObject("f")
+
+	f := idx.forecast(now)
+	if f.IngestRate != 60 {
+		t.Errorf("expected %d got %f", 60, f.IngestRate)
+	}
+	if f.RemovalRate != 10 {
+		t.Errorf("expected %d got %f", 10, f.RemovalRate)
+	}
+	// 500 bytes remain at a growth of 50 bytes/sec
+	if f.TimeToFull != 10*time.Second {
+		t.Errorf("expected %s got %s", 10*time.Second, f.TimeToFull)
+	}
+	if f.HitRetention != nil {
+		t.Errorf("expected nil hit retention, got %v", f.HitRetention)
+	}
+
+	// with nothing written in the next interval, the smoothed rates decline
+	f = idx.forecast(now.Add(10 * time.Second))
+	if math.Abs(f.IngestRate-42) > 0.001 {
+		t.Errorf("expected %d got %f", 42, f.IngestRate)
+	}
+	if math.Abs(f.RemovalRate-7) > 0.001 {
+		t.Errorf("expected %d got %f", 7, f.RemovalRate)
+	}
+	// 500 bytes remain at a growth of 35 bytes/sec
+	if d := f.TimeToFull - 14285714285*time.Nanosecond; d > time.Millisecond || d < -time.Millisecond {
+		t.Errorf("expected %s got %s", 14285714285*time.Nanosecond, f.TimeToFull)
+	}
+
+	// a full cache
+	idx.UpdateObject(&Object{Key: "g", Value: make([]byte, 1000)})
+	f = idx.forecast(now.Add(30 * time.Second))
+	if f.TimeToFull != 0 {
+		t.Errorf("expected %d got %s", 0, f.TimeToFull)
+	}
+
+	// a cache without a max size has no time to full
+	idx.UpdateOptions(&io.Options{})
+	f = idx.forecast(now.Add(40 * time.Second))
+	if f.TimeToFull != -1 {
+		t.Errorf("expected %d got %s", -1, f.TimeToFull)
+	}
+}
+
+func TestForecastNotGrowing(t *testing.T) {
+
+	idx := NewIndex("test", "test", nil, &io.Options{MaxSizeBytes: 1000},
+		testBulkRemoveFunc, nil, testLogger)
+
+	now := time.Now()
+	idx.lastForecast = now.Add(-10 * time.Second)
+
+	idx.UpdateObject(&Object{Key: "a", Value: make([]byte, 100)})
+	idx.UpdateObject(&Object{Key: "b", Value: make([]byte, 100)})
+	idx.RemoveObjects([]string{"a", "b"}, false)
+
+	f := idx.forecast(now)
+	if f.TimeToFull != -1 {
+		t.Errorf("expected %d got %s", -1, f.TimeToFull)
+	}
+}
+
+func TestForecastHitRetention(t *testing.T) {
+
+	idx := NewIndex("test", "test", nil, &io.Options{MaxSizeBytes: 400},
+		testBulkRemoveFunc, nil, testLogger)
+
+	now := time.Now()
+	// four 100-byte objects, last accessed 1, 2, 3 and 4 minutes ago
+	for i := 1; i <= 4; i++ {
+		k := string(rune('a' + i))
+		idx.UpdateObject(&Object{Key: k, Value: make([]byte, 100)})
+		idx.Objects[k].LastAccess = now.Add(-time.Duration(i) * time.Minute)
+	}
+
+	// hits with reuse gaps of 30s, 90s, 150s and 210s
+	for _, g := range []int{30, 90, 150, 210} {
+		idx.recordReuseGap(time.Duration(g) * time.Second)
+	}
+
+	f := idx.forecast(now)
+	expected := map[float64]float64{0.75: 0.75, 0.5: 0.5, 0.25: 0.25}
+	for r, v := range expected {
+		if f.HitRetention[r] != v {
+			t.Errorf("expected %f for %f got %f", v, r, f.HitRetention[r])
+		}
+	}
+}
+
+func TestRecordReuseGap(t *testing.T) {
+	idx := &Index{}
+	for i := 0; i < reuseGapSamples+2; i++ {
+		idx.recordReuseGap(time.Duration(i))
+	}
+	if len(idx.reuseGaps) != reuseGapSamples {
+		t.Errorf("expected %d got %d", reuseGapSamples, len(idx.reuseGaps))
+	}
+	if idx.reuseGaps[1] != time.Duration(reuseGapSamples+1) {
+		t.Errorf("expected %d got %d", reuseGapSamples+1, idx.reuseGaps[1])
+	}
+}
+
+func TestUpdateObjectAccessTimeReuseGap(t *testing.T) {
+	idx := NewIndex("test", "test", nil, &io.Options{}, testBulkRemoveFunc, nil, testLogger)
+	idx.UpdateObject(&Object{Key: "a", Value: []byte("x")})
+	idx.UpdateObjectAccessTime("a")
+	idx.UpdateObjectAccessTime("b")
+	if len(idx.reuseGaps) != 1 {
+		t.Errorf("expected %d got %d", 1, len(idx.reuseGaps))
+	}
+}
@@ -19,6 +19,7 @@ package index
 
 import (
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +27,8 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/cache/index/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/memory"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	gm "github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
@@ -51,17 +54,39 @@ type Index struct {
 	bulkRemoveFunc func([]string)                     `msg:"-"`
 	flushFunc      func(cacheKey string, data []byte) `msg:"-"`
 	lastWrite      time.Time                          `msg:"-"`
-
-	isClosing     bool
-	flusherExited bool
-	reaperExited  bool
+	policy         EvictionPolicy                     `msg:"-"`
+
+	// capacity usage forecasting
+	ingestBytes  int64           `msg:"-"`
+	removedBytes int64           `msg:"-"`
+	reuseGaps    []time.Duration `msg:"-"`
+	reuseGapsPos int             `msg:"-"`
+	lastForecast time.Time       `msg:"-"`
+	ingestRate   float64         `msg:"-"`
+	removalRate  float64         `msg:"-"`
+
+	// queued object accesses, recorded in batches by the access recorder
+	accesses           chan access   `msg:"-"`
+	accessRecorderDone chan struct{} `msg:"-"`
+
+	// closing is closed by Close to signal the Index's subroutines to exit, and each
+	// subroutine closes its done channel once it has exited
+	closing        chan struct{} `msg:"-"`
+	closeOnce      sync.Once     `msg:"-"`
+	flusherDone    chan struct{} `msg:"-"`
+	reaperDone     chan struct{} `msg:"-"`
+	forecasterDone chan struct{} `msg:"-"`
 
 	mtx sync.Mutex
 }
 
 // Close is called to signal the index to shut down any subroutines
 func (idx *Index) Close() {
-	idx.isClosing = true
+	idx.closeOnce.Do(func() {
+		if idx.closing != nil {
+			close(idx.closing)
+		}
+	})
 }
 
 // ToBytes returns a serialized byte slice representing the Index
@@ -83,6 +108,10 @@ type Object struct {
 	LastAccess time.Time `msg:"lastaccess"`
 	// Size the size of the Object in bytes
 	Size int64 `msg:"size"`
+	// Tags are the cache tags associated with the Object, for group invalidation
+	Tags []string `msg:"tags"`
+	// Hits is the number of times the Object has been accessed since it was indexed
+	Hits int64 `msg:"-"`
 	// Value is the value of the Object stored in the Cache
 	// It is used by Caches but not by the Index
 	Value []byte `msg:"value,omitempty"`
@@ -121,9 +150,15 @@ func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
 	i.flushFunc = flushFunc
 	i.bulkRemoveFunc = bulkRemoveFunc
 	i.options = o
+	i.policy = NewEvictionPolicy(o.EvictionPolicy)
+	for _, obj := range i.Objects {
+		i.policy.Add(obj)
+	}
+	i.closing = make(chan struct{})
 
 	if flushFunc != nil {
 		if o.FlushInterval > 0 {
+			i.flusherDone = make(chan struct{})
 			go i.flusher(log)
 		} else {
 			log.Warn("cache index flusher did not start",
@@ -132,12 +167,25 @@ func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
 	}
 
 	if o.ReapInterval > 0 {
+		i.reaperDone = make(chan struct{})
 		go i.reaper(log)
 	} else {
 		log.Warn("cache reaper did not start",
 			tl.Pairs{"cacheName": i.name, "reapInterval": o.ReapInterval})
 	}
 
+	if o.ForecastInterval > 0 {
+		i.lastForecast = time.Now()
+		i.forecasterDone = make(chan struct{})
+		go i.forecaster(log)
+	}
+
+	if o.AccessQueueSize > 0 && o.AccessBatchSize > 0 && o.AccessFlushInterval > 0 {
+		i.accesses = make(chan access, o.AccessQueueSize)
+		i.accessRecorderDone = make(chan struct{})
+		go i.accessRecorder()
+	}
+
 	gm.CacheMaxObjects.WithLabelValues(cacheName, cacheType).Set(float64(o.MaxSizeObjects))
 	gm.CacheMaxBytes.WithLabelValues(cacheName, cacheType).Set(float64(o.MaxSizeBytes))
 
@@ -148,17 +196,53 @@ func NewIndex(cacheName, cacheType string, indexData []byte, o *options.Options,
 func (idx *Index) UpdateOptions(o *options.Options) {
 	idx.mtx.Lock()
 	idx.options = o
+	// a new eviction policy starts out with the Objects already in the Index
+	if idx.policy != nil && idx.policy.Name() != NewEvictionPolicy(o.EvictionPolicy).Name() {
+		idx.policy = nil
+		idx.evictionPolicy()
+	}
 	idx.mtx.Unlock()
 }
 
+// EvictionPolicyName returns the name of the Index's eviction policy
+func (idx *Index) EvictionPolicyName() string {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	return idx.evictionPolicy().Name()
+}
+
+// evictionPolicy returns the Index's EvictionPolicy, creating it from the Index's
+// Options and Objects if necessary. The caller must hold the Index lock
+func (idx *Index) evictionPolicy() EvictionPolicy {
+	if idx.policy == nil {
+		var name string
+		if idx.options != nil {
+			name = idx.options.EvictionPolicy
+		}
+		idx.policy = NewEvictionPolicy(name)
+		for _, obj := range idx.Objects {
+			idx.policy.Add(obj)
+		}
+	}
+	return idx.policy
+}
+
 // UpdateObjectAccessTime updates the LastAccess for the object with the provided key
 func (idx *Index) UpdateObjectAccessTime(key string) {
 	idx.mtx.Lock()
-	if _, ok := idx.Objects[key]; ok {
-		idx.Objects[key].LastAccess = time.Now()
-	}
+	idx.updateObjectAccessTime(key, time.Now())
 	idx.mtx.Unlock()
+}
 
+// updateObjectAccessTime updates the LastAccess for the object with the provided key to the
+// provided time. The caller must hold the Index lock
+func (idx *Index) updateObjectAccessTime(key string, now time.Time) {
+	if o, ok := idx.Objects[key]; ok {
+		idx.recordReuseGap(now.Sub(o.LastAccess))
+		o.LastAccess = now
+		o.Hits++
+		idx.evictionPolicy().Access(o)
+	}
 }
 
 // UpdateObjectTTL updates the Expiration for the object with the provided key
@@ -182,16 +266,24 @@ func (idx *Index) UpdateObject(obj *Object) {
 
 	idx.lastWrite = time.Now()
 
+	// objects stored as a stream have no Value, and are provided with their Size
 	if obj.ReferenceValue != nil {
 		obj.Size = int64(obj.ReferenceValue.Size())
-	} else {
+	} else if len(obj.Value) > 0 {
 		obj.Size = int64(len(obj.Value))
 	}
 	obj.Value = nil
 	obj.LastAccess = time.Now()
 	obj.LastWrite = obj.LastAccess
 
+	idx.ingestBytes += obj.Size
 	if o, ok := idx.Objects[key]; ok {
+		// a rewritten object keeps its tags unless it is provided with new ones
+		if obj.Tags == nil {
+			obj.Tags = o.Tags
+		}
+		obj.Hits = o.Hits
+		idx.removedBytes += o.Size
 		atomic.AddInt64(&idx.CacheSize, obj.Size-o.Size)
 	} else {
 		atomic.AddInt64(&idx.CacheSize, obj.Size)
@@ -201,9 +293,41 @@ func (idx *Index) UpdateObject(obj *Object) {
 	metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 
 	idx.Objects[key] = obj
+	idx.evictionPolicy().Add(obj)
 	idx.mtx.Unlock()
 }
 
+// RestoreObjects adds the Metadata of Objects recovered from the cache's storage to the Index,
+// keeping their LastWrite and LastAccess times. Objects already in the Index are skipped, and
+// the number of Objects added is returned
+func (idx *Index) RestoreObjects(objs []*Object) int {
+	idx.mtx.Lock()
+	var n int
+	for _, obj := range objs {
+		if obj.Key == "" {
+			continue
+		}
+		if _, ok := idx.Objects[obj.Key]; ok {
+			continue
+		}
+		if len(obj.Value) > 0 {
+			obj.Size = int64(len(obj.Value))
+		}
+		obj.Value = nil
+		atomic.AddInt64(&idx.CacheSize, obj.Size)
+		atomic.AddInt64(&idx.ObjectCount, 1)
+		idx.Objects[obj.Key] = obj
+		idx.evictionPolicy().Add(obj)
+		n++
+	}
+	if n > 0 {
+		idx.lastWrite = time.Now()
+		metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+	}
+	idx.mtx.Unlock()
+	return n
+}
+
 // RemoveObject removes an Object's Metadata from the Index
 func (idx *Index) RemoveObject(key string) {
 	idx.mtx.Lock()
@@ -211,10 +335,12 @@ func (idx *Index) RemoveObject(key string) {
 	if o, ok := idx.Objects[key]; ok {
 		atomic.AddInt64(&idx.CacheSize, -o.Size)
 		atomic.AddInt64(&idx.ObjectCount, -1)
+		idx.removedBytes += o.Size
 
 		metrics.ObserveCacheOperation(idx.name, idx.cacheType, "del", "none", float64(o.Size))
 
 		delete(idx.Objects, key)
+		idx.evictionPolicy().Remove(key, false)
 		metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 	}
 	idx.mtx.Unlock()
@@ -225,19 +351,71 @@ func (idx *Index) RemoveObjects(keys []string, noLock bool) {
 	if !noLock {
 		idx.mtx.Lock()
 	}
+	idx.removeObjects(keys, false)
+	if !noLock {
+		idx.mtx.Unlock()
+	}
+}
+
+// removeObjects removes a list of Objects' Metadata from the Index, notifying the eviction
+// policy whether they were evicted. The caller must hold the Index lock
+func (idx *Index) removeObjects(keys []string, evicted bool) {
 	for _, key := range keys {
 		if o, ok := idx.Objects[key]; ok {
 			atomic.AddInt64(&idx.CacheSize, -o.Size)
 			atomic.AddInt64(&idx.ObjectCount, -1)
+			idx.removedBytes += o.Size
 			metrics.ObserveCacheOperation(idx.name, idx.cacheType, "del", "none", float64(o.Size))
 			delete(idx.Objects, key)
+			idx.evictionPolicy().Remove(key, evicted)
 			metrics.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
 		}
 	}
 	idx.lastWrite = time.Now()
-	if !noLock {
-		idx.mtx.Unlock()
+}
+
+// Keys returns the keys of the indexed Objects that begin with prefix
+func (idx *Index) Keys(prefix string) []string {
+	idx.mtx.Lock()
+	keys := make([]string, 0, len(idx.Objects))
+	for k := range idx.Objects {
+		if k != IndexKey && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	idx.mtx.Unlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// SetTags sets the cache tags of the indexed Object with the provided key
+func (idx *Index) SetTags(key string, tags []string) {
+	idx.mtx.Lock()
+	if o, ok := idx.Objects[key]; ok {
+		o.Tags = tags
+		idx.lastWrite = time.Now()
 	}
+	idx.mtx.Unlock()
+}
+
+// TaggedKeys returns the keys of the indexed Objects that are associated with tag
+func (idx *Index) TaggedKeys(tag string) []string {
+	idx.mtx.Lock()
+	keys := make([]string, 0)
+	for k, o := range idx.Objects {
+		if k == IndexKey {
+			continue
+		}
+		for _, t := range o.Tags {
+			if t == tag {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	idx.mtx.Unlock()
+	sort.Strings(keys)
+	return keys
 }
 
 // GetExpiration returns the cache index's expiration for the object of the given key
@@ -253,16 +431,34 @@ func (idx *Index) GetExpiration(cacheKey string) time.Time {
 
 // flusher periodically calls the cache's index flush func that writes the cache index to disk
 func (idx *Index) flusher(log *tl.Logger) {
+	defer close(idx.flusherDone)
 	var lastFlush time.Time
-	for !idx.isClosing {
-		time.Sleep(idx.options.FlushInterval)
-		if idx.lastWrite.Before(lastFlush) {
+	for {
+		idx.mtx.Lock()
+		interval := idx.options.FlushInterval
+		idx.mtx.Unlock()
+		select {
+		case <-idx.closing:
+			return
+		case <-time.After(interval):
+		}
+		idx.mtx.Lock()
+		lastWrite := idx.lastWrite
+		idx.mtx.Unlock()
+		if lastWrite.Before(lastFlush) {
 			continue
 		}
 		idx.flushOnce(log)
 		lastFlush = time.Now()
 	}
-	idx.flusherExited = true
+}
+
+// Flush writes the index to its cache now, rather than at the next periodic flush
+func (idx *Index) Flush(log *tl.Logger) {
+	if idx.flushFunc == nil {
+		return
+	}
+	idx.flushOnce(log)
 }
 
 func (idx *Index) flushOnce(log *tl.Logger) {
@@ -279,17 +475,24 @@ func (idx *Index) flushOnce(log *tl.Logger) {
 
 // reaper continually iterates through the cache to find expired elements and removes them
 func (idx *Index) reaper(log *tl.Logger) {
-	for !idx.isClosing {
+	defer close(idx.reaperDone)
+	for {
 		idx.reap(log)
-		time.Sleep(idx.options.ReapInterval)
+		idx.mtx.Lock()
+		interval := idx.options.ReapInterval
+		idx.mtx.Unlock()
+		select {
+		case <-idx.closing:
+			return
+		case <-time.After(interval):
+		}
 	}
-	idx.reaperExited = true
 }
 
 type objectsAtime []*Object
 
 // reap makes a single iteration through the cache index to to find and remove expired elements
-// and evict least-recently-accessed elements to maintain the Maximum allowed Cache Size
+// and evict elements in the order of the eviction policy to maintain the Maximum allowed Cache Size
 func (idx *Index) reap(log *tl.Logger) {
 
 	idx.mtx.Lock()
@@ -320,8 +523,21 @@ func (idx *Index) reap(log *tl.Logger) {
 		cacheChanged = true
 	}
 
+	// origins with a cache_quota are held to it independently of the cache's overall size limits
+	var quotaEvicted bool
+	if remainders, quotaEvicted = idx.enforceQuotas(remainders, log); quotaEvicted {
+		cacheChanged = true
+	}
+
+	// while the process is under memory pressure, memory caches shed records per their eviction policy
+	var pressureBytes int64
+	if idx.cacheType == types.CacheTypeMemory.String() {
+		pressureBytes = memory.PressureEvictionBytes(idx.CacheSize)
+	}
+
 	if ((idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes) ||
-		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects)) &&
+		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects) ||
+		pressureBytes > 0) &&
 		len(remainders) > 0 {
 
 		var evictionType string
@@ -329,13 +545,17 @@ func (idx *Index) reap(log *tl.Logger) {
 			evictionType = "size_bytes"
 		} else if idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects {
 			evictionType = "size_objects"
+		} else if pressureBytes > 0 {
+			evictionType = "memory_pressure"
 		} else {
 			return
 		}
 
-		log.Debug("max cache size reached. evicting least-recently-accessed records",
+		policy := idx.evictionPolicy()
+
+		log.Debug("max cache size reached. evicting records",
 			tl.Pairs{
-				"reason":         evictionType,
+				"reason": evictionType, "evictionPolicy": policy.Name(),
 				"cacheSizeBytes": idx.CacheSize, "maxSizeBytes": idx.options.MaxSizeBytes,
 				"cacheSizeObjects": idx.ObjectCount, "maxSizeObjects": idx.options.MaxSizeObjects,
 			},
@@ -343,7 +563,7 @@ func (idx *Index) reap(log *tl.Logger) {
 
 		removals = make([]string, 0)
 
-		sort.Sort(remainders)
+		policy.Order(remainders)
 
 		i := 0
 		j := len(remainders)
@@ -359,6 +579,13 @@ func (idx *Index) reap(log *tl.Logger) {
 				bytesSelected += remainders[i].Size
 				i++
 			}
+		} else if evictionType == "memory_pressure" {
+			bytesSelected := int64(0)
+			for bytesSelected < pressureBytes && i < j {
+				removals = append(removals, remainders[i].Key)
+				bytesSelected += remainders[i].Size
+				i++
+			}
 		} else {
 			objectsNeeded := (idx.ObjectCount - idx.options.MaxSizeObjects)
 			if idx.options.MaxSizeObjects > idx.options.MaxSizeBackoffObjects {
@@ -375,7 +602,7 @@ func (idx *Index) reap(log *tl.Logger) {
 		if len(removals) > 0 {
 			metrics.ObserveCacheEvent(idx.name, idx.cacheType, "eviction", evictionType)
 			go idx.bulkRemoveFunc(removals)
-			idx.RemoveObjects(removals, true)
+			idx.removeObjects(removals, true)
 			cacheChanged = true
 		}
 
@@ -57,22 +57,22 @@ func TestNewIndex(t *testing.T) {
 	idx.flushOnce(testLogger)
 
 	idx.Close()
-	time.Sleep(500 * time.Millisecond)
-	if !idx.reaperExited {
-		t.Error("expected true")
-	}
-	if !idx.flusherExited {
-		t.Error("expected true")
+	for _, done := range []chan struct{}{idx.reaperDone, idx.flusherDone} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("expected true")
+		}
 	}
 
 	idx2 := NewIndex("test", "test", idx.ToBytes(), cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	if idx2 == nil {
 		t.Errorf("nil cache index")
 	}
+	idx2.Close()
 
-	cacheConfig.Index.FlushInterval = 0
-	cacheConfig.Index.ReapInterval = 0
-	idx3 := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	// idx2's subroutines still read their options, so idx3 is given its own
+	idx3 := NewIndex("test", "test", nil, &io.Options{}, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
 	if idx3 == nil {
 		t.Errorf("nil cache index")
 	}
@@ -244,6 +244,62 @@ func TestRemoveObject(t *testing.T) {
 
 }
 
+func TestRestoreObjects(t *testing.T) {
+
+	cacheConfig := &co.Options{CacheType: "test",
+		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+			FlushInterval: time.Second * time.Duration(10)}}
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+
+	idx.UpdateObject(&Object{Key: "existing", Value: []byte("test_value")})
+
+	lw := time.Now().Add(-time.Hour)
+	n := idx.RestoreObjects([]*Object{
+		{Key: "existing", Value: []byte("other_value")},
+		{Key: "restored", Value: []byte("test_value"), LastWrite: lw, LastAccess: lw},
+		{Key: ""},
+	})
+	if n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	o, ok := idx.Objects["restored"]
+	if !ok {
+		t.Fatal("restored object missing from index")
+	}
+	if !o.LastAccess.Equal(lw) {
+		t.Errorf("expected %s got %s", lw, o.LastAccess)
+	}
+	if o.Size != 10 || o.Value != nil {
+		t.Errorf("expected size 10 and no value, got %d %v", o.Size, o.Value)
+	}
+	if idx.ObjectCount != 2 || idx.CacheSize != 20 {
+		t.Errorf("expected 2 objects and 20 bytes, got %d %d", idx.ObjectCount, idx.CacheSize)
+	}
+
+}
+
+func TestFlush(t *testing.T) {
+
+	var flushed []byte
+	cacheConfig := &co.Options{CacheType: "test",
+		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+			FlushInterval: time.Second * time.Duration(10)}}
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc,
+		func(cacheKey string, data []byte) { flushed = data }, testLogger)
+	idx.UpdateObject(&Object{Key: "test", Value: []byte("test_value")})
+
+	idx.Flush(testLogger)
+	idx2 := NewIndex("test", "test", flushed, cacheConfig.Index, testBulkRemoveFunc, nil, testLogger)
+	if _, ok := idx2.Objects["test"]; !ok {
+		t.Error("expected flushed object in index")
+	}
+
+	// an index without a flush func is never flushed
+	idx2.Flush(testLogger)
+
+}
+
 func TestSort(t *testing.T) {
 
 	o := objectsAtime{
@@ -319,6 +375,22 @@ func TestUpdateOptions(t *testing.T) {
 	if idx.options.MaxSizeBytes != 5 {
 		t.Errorf("expected %d got %d", 5, idx.options.MaxSizeBytes)
 	}
+
+	if idx.EvictionPolicyName() != io.EvictionPolicyLRU {
+		t.Errorf("expected %s got %s", io.EvictionPolicyLRU, idx.EvictionPolicyName())
+	}
+
+	idx.UpdateObject(&Object{Key: "test", Value: []byte("test_value")})
+	options = io.NewOptions()
+	options.EvictionPolicy = io.EvictionPolicyLFU
+	idx.UpdateOptions(options)
+
+	if idx.EvictionPolicyName() != io.EvictionPolicyLFU {
+		t.Errorf("expected %s got %s", io.EvictionPolicyLFU, idx.EvictionPolicyName())
+	}
+	if c := idx.policy.(*lfuPolicy).counts["test"]; c != 1 {
+		t.Errorf("expected %d got %d", 1, c)
+	}
 }
 
 func TestRemoveObjects(t *testing.T) {
@@ -333,3 +405,51 @@ func TestRemoveObjects(t *testing.T) {
 		t.Error("key should not be in map")
 	}
 }
+
+func TestKeys(t *testing.T) {
+	cacheConfig := &co.Options{CacheType: "test",
+		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+			FlushInterval: time.Second * time.Duration(10)}}
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	for _, k := range []string{"a.dpc.2", "a.dpc.1", "a.opc.1", "b.dpc.1", IndexKey} {
+		idx.UpdateObject(&Object{Key: k, Value: []byte("test_value")})
+	}
+	keys := idx.Keys("a.dpc.")
+	if len(keys) != 2 || keys[0] != "a.dpc.1" || keys[1] != "a.dpc.2" {
+		t.Errorf("expected %v got %v", []string{"a.dpc.1", "a.dpc.2"}, keys)
+	}
+	keys = idx.Keys("")
+	if len(keys) != 4 {
+		t.Errorf("expected %d got %d", 4, len(keys))
+	}
+}
+
+func TestTaggedKeys(t *testing.T) {
+	cacheConfig := &co.Options{CacheType: "test",
+		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+			FlushInterval: time.Second * time.Duration(10)}}
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+	for _, k := range []string{"a.opc.2", "a.opc.1", "b.opc.1"} {
+		idx.UpdateObject(&Object{Key: k, Value: []byte("test_value")})
+	}
+	idx.SetTags("a.opc.2", []string{"tenant-a", "series"})
+	idx.SetTags("a.opc.1", []string{"tenant-a"})
+	idx.SetTags("missing", []string{"tenant-a"})
+
+	keys := idx.TaggedKeys("tenant-a")
+	if len(keys) != 2 || keys[0] != "a.opc.1" || keys[1] != "a.opc.2" {
+		t.Errorf("expected %v got %v", []string{"a.opc.1", "a.opc.2"}, keys)
+	}
+
+	// a rewritten object keeps its tags
+	idx.UpdateObject(&Object{Key: "a.opc.2", Value: []byte("test_value")})
+	keys = idx.TaggedKeys("series")
+	if len(keys) != 1 || keys[0] != "a.opc.2" {
+		t.Errorf("expected %v got %v", []string{"a.opc.2"}, keys)
+	}
+
+	keys = idx.TaggedKeys("tenant-b")
+	if len(keys) != 0 {
+		t.Errorf("expected %d got %d", 0, len(keys))
+	}
+}
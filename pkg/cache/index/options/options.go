@@ -17,11 +17,34 @@
 package options
 
 import (
+	"fmt"
 	"time"
 
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 )
 
+const (
+	// EvictionPolicyLRU evicts the least-recently-accessed objects first
+	EvictionPolicyLRU = "lru"
+	// EvictionPolicyLFU evicts the least-frequently-accessed objects first
+	EvictionPolicyLFU = "lfu"
+	// EvictionPolicyARC evicts objects per the Adaptive Replacement Cache algorithm, which
+	// balances recency and frequency of access based on the cache's workload
+	EvictionPolicyARC = "arc"
+	// EvictionPolicySizeWeighted evicts the objects with the largest product of size and
+	// time since last access first
+	EvictionPolicySizeWeighted = "size_weighted"
+)
+
+// EvictionPolicyNames is the set of supported Cache Index eviction policy names
+var EvictionPolicyNames = map[string]bool{
+	EvictionPolicyLRU:          true,
+	EvictionPolicyLFU:          true,
+	EvictionPolicyARC:          true,
+	EvictionPolicySizeWeighted: true,
+}
+
 // Options defines the operation of the Cache Indexer
 type Options struct {
 	// ReapIntervalSecs defines how long the Cache Index reaper sleeps between reap cycles
@@ -40,9 +63,29 @@ type Options struct {
 	// MaxSizeBackoffObjects indicates how far under max_size_objects the cache size must
 	// be to complete object-size-based eviction exercise.
 	MaxSizeBackoffObjects int64 `toml:"max_size_backoff_objects"`
+	// ForecastIntervalSecs sets how often the Cache Index updates its capacity usage forecast.
+	// 0 disables forecasting
+	ForecastIntervalSecs int `toml:"forecast_interval_secs"`
+	// EvictionPolicy selects the order in which the Index evicts objects to keep the cache
+	// within its size limits. Options are 'lru', 'lfu', 'arc' and 'size_weighted'
+	EvictionPolicy string `toml:"eviction_policy"`
+	// AccessQueueSize is the number of object accesses that can be queued for the Cache Index to
+	// record. Retrievals wait for room once it is full. 0 records each access as it happens
+	AccessQueueSize int `toml:"access_queue_size"`
+	// AccessBatchSize is the number of queued object accesses that the Cache Index records at once
+	AccessBatchSize int `toml:"access_batch_size"`
+	// AccessFlushIntervalMS is how often the Cache Index records the queued object accesses when
+	// there are fewer than a batch
+	AccessFlushIntervalMS int `toml:"access_flush_interval_ms"`
+
+	ReapInterval        time.Duration `toml:"-"`
+	FlushInterval       time.Duration `toml:"-"`
+	ForecastInterval    time.Duration `toml:"-"`
+	AccessFlushInterval time.Duration `toml:"-"`
 
-	ReapInterval  time.Duration `toml:"-"`
-	FlushInterval time.Duration `toml:"-"`
+	// Quotas maps the cache key prefix of each origin with a cache_quota to its quota.
+	// The Index enforces each namespace's quota independently of the cache's size limits
+	Quotas map[string]*qo.Options `toml:"-"`
 }
 
 // NewOptions returns a new Cache Index Options Reference with default values set
@@ -54,6 +97,11 @@ func NewOptions() *Options {
 		MaxSizeBackoffBytes:   d.DefaultMaxSizeBackoffBytes,
 		MaxSizeObjects:        d.DefaultMaxSizeObjects,
 		MaxSizeBackoffObjects: d.DefaultMaxSizeBackoffObjects,
+		ForecastIntervalSecs:  d.DefaultCacheIndexForecast,
+		EvictionPolicy:        d.DefaultCacheIndexEvictionPolicy,
+		AccessQueueSize:       d.DefaultCacheIndexAccessQueueSize,
+		AccessBatchSize:       d.DefaultCacheIndexAccessBatchSize,
+		AccessFlushIntervalMS: d.DefaultCacheIndexAccessFlushMS,
 	}
 }
 
@@ -70,5 +118,45 @@ func (o *Options) Equal(o2 *Options) bool {
 		o.MaxSizeBytes == o2.MaxSizeBytes &&
 		o.MaxSizeBackoffBytes == o2.MaxSizeBackoffBytes &&
 		o.MaxSizeObjects == o2.MaxSizeObjects &&
-		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects
+		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects &&
+		o.ForecastIntervalSecs == o2.ForecastIntervalSecs &&
+		o.EvictionPolicy == o2.EvictionPolicy &&
+		o.AccessQueueSize == o2.AccessQueueSize &&
+		o.AccessBatchSize == o2.AccessBatchSize &&
+		o.AccessFlushIntervalMS == o2.AccessFlushIntervalMS &&
+		quotasEqual(o.Quotas, o2.Quotas)
+}
+
+func quotasEqual(q1, q2 map[string]*qo.Options) bool {
+	if len(q1) != len(q2) {
+		return false
+	}
+	for k, v := range q1 {
+		if !v.Equal(q2[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateEvictionPolicy returns an error if the Options' EvictionPolicy is not supported
+func (o *Options) ValidateEvictionPolicy() error {
+	if _, ok := EvictionPolicyNames[o.EvictionPolicy]; !ok {
+		return fmt.Errorf("invalid eviction_policy: %s", o.EvictionPolicy)
+	}
+	return nil
+}
+
+// ValidateAccessQueue returns an error if the Options' access queue settings are invalid
+func (o *Options) ValidateAccessQueue() error {
+	if o.AccessQueueSize < 0 {
+		return fmt.Errorf("invalid access_queue_size: %d", o.AccessQueueSize)
+	}
+	if o.AccessBatchSize < 1 {
+		return fmt.Errorf("invalid access_batch_size: %d", o.AccessBatchSize)
+	}
+	if o.AccessFlushIntervalMS < 1 {
+		return fmt.Errorf("invalid access_flush_interval_ms: %d", o.AccessFlushIntervalMS)
+	}
+	return nil
 }
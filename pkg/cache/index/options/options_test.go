@@ -16,7 +16,11 @@
 
 package options
 
-import "testing"
+import (
+	"testing"
+
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
+)
 
 func TestNewOptions(t *testing.T) {
 	o := NewOptions()
@@ -37,4 +41,62 @@ func TestEqual(t *testing.T) {
 		t.Error("expected true")
 	}
 
+	o2 := NewOptions()
+	o2.Quotas = map[string]*qo.Options{"test": {MaxSizeObjects: 10}}
+	if o.Equal(o2) {
+		t.Error("expected false")
+	}
+
+	o.Quotas = map[string]*qo.Options{"test": {MaxSizeObjects: 10}}
+	if !o.Equal(o2) {
+		t.Error("expected true")
+	}
+
+}
+
+func TestValidateEvictionPolicy(t *testing.T) {
+
+	o := NewOptions()
+
+	if err := o.ValidateEvictionPolicy(); err != nil {
+		t.Error(err)
+	}
+
+	o.EvictionPolicy = "mru"
+	if err := o.ValidateEvictionPolicy(); err == nil {
+		t.Error("expected error for invalid eviction policy")
+	}
+
+}
+
+func TestValidateAccessQueue(t *testing.T) {
+
+	o := NewOptions()
+
+	if err := o.ValidateAccessQueue(); err != nil {
+		t.Error(err)
+	}
+
+	o.AccessQueueSize = 0
+	if err := o.ValidateAccessQueue(); err != nil {
+		t.Error(err)
+	}
+
+	o.AccessQueueSize = -1
+	if err := o.ValidateAccessQueue(); err == nil {
+		t.Error("expected error for invalid access_queue_size")
+	}
+
+	o.AccessQueueSize = 1
+	o.AccessBatchSize = 0
+	if err := o.ValidateAccessQueue(); err == nil {
+		t.Error("expected error for invalid access_batch_size")
+	}
+
+	o.AccessBatchSize = 1
+	o.AccessFlushIntervalMS = 0
+	if err := o.ValidateAccessQueue(); err == nil {
+		t.Error("expected error for invalid access_flush_interval_ms")
+	}
+
 }
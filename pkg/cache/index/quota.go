@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	gm "github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// namespaceUsage is the size of a cache namespace in bytes and objects
+type namespaceUsage struct {
+	bytes   int64
+	objects int64
+}
+
+// namespaceOf returns the namespace in quotas to which key belongs, or an empty string if
+// none. When namespaces are nested, the longest one wins
+func namespaceOf(key string, quotas map[string]*qo.Options) string {
+	var ns string
+	for k := range quotas {
+		if len(k) > len(ns) && strings.HasPrefix(key, k+".") {
+			ns = k
+		}
+	}
+	return ns
+}
+
+// enforceQuotas evicts objects from each namespace that exceeds its quota, in the order of the
+// eviction policy, and returns the objects that remain and whether any were evicted. The caller must hold the Index lock
+func (idx *Index) enforceQuotas(remainders objectsAtime, log *tl.Logger) (objectsAtime, bool) {
+
+	quotas := idx.options.Quotas
+	if len(quotas) == 0 || len(remainders) == 0 {
+		return remainders, false
+	}
+
+	namespaces := make(map[string]string, len(remainders))
+	usage := make(map[string]*namespaceUsage, len(quotas))
+	for k := range quotas {
+		usage[k] = &namespaceUsage{}
+	}
+	for _, o := range remainders {
+		ns := namespaceOf(o.Key, quotas)
+		if ns == "" {
+			continue
+		}
+		namespaces[o.Key] = ns
+		usage[ns].bytes += o.Size
+		usage[ns].objects++
+	}
+
+	exceeded := false
+	for ns, q := range quotas {
+		u := usage[ns]
+		if (q.MaxSizeBytes > 0 && u.bytes > q.MaxSizeBytes) ||
+			(q.MaxSizeObjects > 0 && u.objects > q.MaxSizeObjects) {
+			exceeded = true
+			break
+		}
+	}
+
+	removals := make([]string, 0)
+	if exceeded {
+		idx.evictionPolicy().Order(remainders)
+		kept := make(objectsAtime, 0, len(remainders))
+		for _, o := range remainders {
+			ns, ok := namespaces[o.Key]
+			if !ok {
+				kept = append(kept, o)
+				continue
+			}
+			q, u := quotas[ns], usage[ns]
+			if (q.MaxSizeBytes > 0 && u.bytes > q.MaxSizeBytes) ||
+				(q.MaxSizeObjects > 0 && u.objects > q.MaxSizeObjects) {
+				removals = append(removals, o.Key)
+				u.bytes -= o.Size
+				u.objects--
+				continue
+			}
+			kept = append(kept, o)
+		}
+		remainders = kept
+
+		if len(removals) > 0 {
+			log.Debug("cache namespace quota reached. evicting records",
+				tl.Pairs{"cacheName": idx.name, "evictionPolicy": idx.policy.Name(),
+					"objectsEvicted": len(removals)})
+			metrics.ObserveCacheEvent(idx.name, idx.cacheType, "eviction", "namespace_quota")
+			go idx.bulkRemoveFunc(removals)
+			idx.removeObjects(removals, true)
+		}
+	}
+
+	for ns, u := range usage {
+		gm.CacheNamespaceObjects.WithLabelValues(idx.name, idx.cacheType, ns).Set(float64(u.objects))
+		gm.CacheNamespaceBytes.WithLabelValues(idx.name, idx.cacheType, ns).Set(float64(u.bytes))
+	}
+
+	return remainders, len(removals) > 0
+}
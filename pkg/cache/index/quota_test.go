@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"testing"
+	"time"
+
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
+)
+
+func TestNamespaceOf(t *testing.T) {
+
+	quotas := map[string]*qo.Options{"a": {}, "a.b": {}, "c": {}}
+
+	tests := []struct {
+		key, expected string
+	}{
+		{"a.1234", "a"},
+		{"a.b.1234", "a.b"},
+		{"c.dpc.1234", "c"},
+		{"ab.1234", ""},
+		{"d.1234", ""},
+	}
+
+	for _, test := range tests {
+		if ns := namespaceOf(test.key, quotas); ns != test.expected {
+			t.Errorf("expected %s got %s for %s", test.expected, ns, test.key)
+		}
+	}
+}
+
+func TestReapQuotas(t *testing.T) {
+
+	o := io.NewOptions()
+	o.ReapInterval = 0
+	o.FlushInterval = 0
+	o.ForecastIntervalSecs = 0
+	o.MaxSizeObjects = 100
+	o.Quotas = map[string]*qo.Options{
+		"a": {MaxSizeObjects: 2},
+		"b": {MaxSizeBytes: 25},
+	}
+
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+
+	base := time.Now().Add(-time.Hour)
+	keys := []string{"a.1", "b.1", "c.1", "a.2", "b.2", "c.2", "a.3", "b.3", "c.3"}
+	for i, k := range keys {
+		idx.UpdateObject(&Object{Key: k, Value: []byte("test_value")})
+		idx.Objects[k].LastAccess = base.Add(time.Duration(i) * time.Minute)
+	}
+
+	idx.reap(testLogger)
+
+	// the least-recently-accessed objects of each namespace over its quota are evicted
+	for _, k := range []string{"a.1", "b.1"} {
+		if _, ok := idx.Objects[k]; ok {
+			t.Errorf("expected key %s to be missing", k)
+		}
+	}
+
+	// objects outside of a namespace are unaffected by quotas
+	for _, k := range []string{"a.2", "a.3", "b.2", "b.3", "c.1", "c.2", "c.3"} {
+		if _, ok := idx.Objects[k]; !ok {
+			t.Errorf("expected key %s to be present", k)
+		}
+	}
+
+	if idx.ObjectCount != 7 {
+		t.Errorf("expected %d got %d", 7, idx.ObjectCount)
+	}
+
+	// a reap within quota evicts nothing
+	idx.reap(testLogger)
+	if idx.ObjectCount != 7 {
+		t.Errorf("expected %d got %d", 7, idx.ObjectCount)
+	}
+}
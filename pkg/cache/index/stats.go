@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+)
+
+// Stats returns the statistics of the indexed objects as of now, including the topN largest
+// and most-accessed objects
+func (idx *Index) Stats(topN int, now time.Time) *cache.Stats {
+	s := cache.NewStats()
+
+	idx.mtx.Lock()
+	objs := make([]*cache.ObjectStats, 0, len(idx.Objects))
+	for _, o := range idx.Objects {
+		s.Add(o.Size, now.Sub(o.LastWrite))
+		objs = append(objs, objectStats(o, now))
+	}
+	idx.mtx.Unlock()
+
+	s.Largest = cache.TopObjects(objs, topN, func(a, b *cache.ObjectStats) bool {
+		return a.Size > b.Size
+	})
+	s.Hottest = cache.TopObjects(objs, topN, func(a, b *cache.ObjectStats) bool {
+		return a.Hits > b.Hits
+	})
+	return s
+}
+
+// ObjectStats returns the statistics of the indexed object with the provided key as of now,
+// or nil if the key is not indexed
+func (idx *Index) ObjectStats(key string, now time.Time) *cache.ObjectStats {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	o, ok := idx.Objects[key]
+	if !ok {
+		return nil
+	}
+	return objectStats(o, now)
+}
+
+func objectStats(o *Object, now time.Time) *cache.ObjectStats {
+	st := &cache.ObjectStats{
+		Key:      o.Key,
+		Size:     o.Size,
+		Hits:     o.Hits,
+		AgeSecs:  int64(now.Sub(o.LastWrite).Seconds()),
+		IdleSecs: int64(now.Sub(o.LastAccess).Seconds()),
+	}
+	if !o.Expiration.IsZero() && o.Expiration.After(now) {
+		st.TTLSecs = int64(o.Expiration.Sub(now).Seconds())
+	}
+	return st
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+)
+
+func TestStats(t *testing.T) {
+
+	o := io.NewOptions()
+	o.ReapInterval = 0
+	o.FlushInterval = 0
+	o.ForecastIntervalSecs = 0
+
+	idx := NewIndex("test", "test", nil, o, testBulkRemoveFunc, nil, testLogger)
+	idx.UpdateObject(&Object{Key: "a", Value: []byte("aaaa")})
+	idx.UpdateObject(&Object{Key: "b", Value: []byte("bb"), Expiration: time.Now().Add(time.Hour)})
+	idx.UpdateObject(&Object{Key: "c", Value: []byte("c")})
+	for i := 0; i < 3; i++ {
+		idx.UpdateObjectAccessTime("b")
+	}
+	idx.UpdateObjectAccessTime("c")
+
+	// a rewritten object keeps its hits
+	idx.UpdateObject(&Object{Key: "c", Value: []byte("cc")})
+
+	// the objects are aged by reporting their stats two hours from now
+	s := idx.Stats(2, time.Now().Add(2*time.Hour))
+
+	if s.Objects != 3 || s.Bytes != 8 {
+		t.Errorf("expected %d/%d got %d/%d", 3, 8, s.Objects, s.Bytes)
+	}
+
+	if len(s.AgeHistogram) != len(cache.AgeBuckets)+1 {
+		t.Fatalf("expected %d got %d", len(cache.AgeBuckets)+1, len(s.AgeHistogram))
+	}
+	for _, b := range s.AgeHistogram {
+		var expected int64
+		if b.LE == "21600" {
+			expected = 3
+		}
+		if b.Objects != expected {
+			t.Errorf("expected %d got %d for bucket %s", expected, b.Objects, b.LE)
+		}
+	}
+
+	if len(s.Largest) != 2 || s.Largest[0].Key != "a" || s.Largest[1].Key != "b" {
+		t.Errorf("unexpected largest objects %v %v", s.Largest[0], s.Largest[1])
+	}
+
+	if len(s.Hottest) != 2 || s.Hottest[0].Key != "b" || s.Hottest[0].Hits != 3 ||
+		s.Hottest[1].Key != "c" || s.Hottest[1].Hits != 1 {
+		t.Errorf("unexpected hottest objects %v %v", s.Hottest[0], s.Hottest[1])
+	}
+
+	// b expired an hour before the stats were reported
+	if s.Largest[1].TTLSecs != 0 {
+		t.Errorf("expected %d got %d", 0, s.Largest[1].TTLSecs)
+	}
+
+	if s = idx.Stats(10, time.Now()); len(s.Largest) != 3 || s.Largest[1].TTLSecs <= 0 {
+		t.Errorf("unexpected largest objects %v", s.Largest)
+	}
+
+	if st := idx.ObjectStats("b", time.Now().Add(2*time.Hour)); st == nil || st.Size != 2 ||
+		st.Hits != 3 || st.AgeSecs < 7200 || st.TTLSecs != 0 {
+		t.Errorf("unexpected object stats %v", st)
+	}
+
+	if st := idx.ObjectStats("z", time.Now()); st != nil {
+		t.Errorf("expected nil got %v", st)
+	}
+
+}
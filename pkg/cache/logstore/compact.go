@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logstore
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// compactor compacts the sealed segments whose dead fraction reaches the compaction dead ratio
+// at the provided interval until the cache is closed
+func (c *Cache) compactor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer func() {
+		ticker.Stop()
+		close(c.compactDone)
+	}()
+	for {
+		select {
+		case <-c.compactQuit:
+			return
+		case <-ticker.C:
+			ratio := c.Config.Logstore.CompactDeadRatio
+			c.compact(func(s *segment) bool {
+				return s.size > 0 && float64(s.dead)/float64(s.size) >= ratio
+			})
+		}
+	}
+}
+
+// Compact rewrites the live records of each sealed segment that holds dead records to the
+// active segment and removes the sealed segment, and returns the size of the segments in bytes
+// before and after compaction. The cache continues to serve and store objects while it is
+// compacted
+func (c *Cache) Compact() (int64, int64, error) {
+	return c.compact(func(s *segment) bool { return s.dead > 0 })
+}
+
+// compact compacts the sealed segments selected by the provided func, in the order they
+// were written
+func (c *Cache) compact(selectFunc func(*segment) bool) (int64, int64, error) {
+	c.compactMtx.Lock()
+	defer c.compactMtx.Unlock()
+
+	start := time.Now()
+	c.mtx.RLock()
+	before := c.diskSize()
+	candidates := make([]*segment, 0)
+	for _, s := range c.segments {
+		if s != c.active && selectFunc(s) {
+			candidates = append(candidates, s)
+		}
+	}
+	c.mtx.RUnlock()
+	if len(candidates) == 0 {
+		return before, before, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].id < candidates[j].id })
+
+	var err error
+	for _, s := range candidates {
+		if err = c.compactSegment(s); err != nil {
+			break
+		}
+	}
+
+	c.mtx.RLock()
+	after := c.diskSize()
+	c.mtx.RUnlock()
+	if err != nil {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "compaction", "failed")
+		c.Logger.Error("logstore cache compaction failed",
+			log.Pairs{"cacheName": c.Name, "reason": err.Error()})
+		return before, after, err
+	}
+
+	metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "compaction", "completed")
+	c.Logger.Info("logstore cache compacted", log.Pairs{"cacheName": c.Name,
+		"segments": len(candidates), "bytesBefore": before, "bytesAfter": after,
+		"elapsed": time.Since(start).String()})
+	return before, after, nil
+}
+
+// diskSize returns the total size of the segments. The caller must hold mtx
+func (c *Cache) diskSize() int64 {
+	var n int64
+	for _, s := range c.segments {
+		n += s.size
+	}
+	return n
+}
+
+// compactSegment copies the live records of the sealed segment to the active segment, and
+// then removes the segment. A tombstone is copied while an older segment remains that may
+// hold a record it removes, unless its key has since been stored again
+func (c *Cache) compactSegment(s *segment) error {
+	dir := c.Config.Logstore.Directory
+
+	c.mtx.RLock()
+	var older bool
+	for id := range c.segments {
+		if id < s.id {
+			older = true
+			break
+		}
+	}
+	size := s.size
+	c.mtx.RUnlock()
+
+	entries, covered, err := readHint(dir, s.id)
+	if err != nil || covered != size {
+		if entries, _, err = scanSegment(s.fh, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		if e.offset+e.size() > size || (e.kind == kindTombstone && !older) {
+			continue
+		}
+		if !c.isLive(s.id, e) {
+			continue
+		}
+		b := make([]byte, e.size())
+		if _, err = s.fh.ReadAt(b, e.offset); err != nil {
+			return err
+		}
+		r, err := decodeRecord(b)
+		if err != nil || r.key != e.key {
+			// the object can't be copied, so it is removed rather than lost with the segment
+			c.Logger.Warn("logstore cache record could not be compacted",
+				log.Pairs{"cacheName": c.Name, "segment": s.id, "offset": e.offset})
+			c.remove(e.key, false)
+			continue
+		}
+		c.writeMtx.Lock()
+		// the record is checked again, since its key may have been written while it was read
+		if c.isLive(s.id, e) {
+			var l location
+			if l, err = c.append(r, b); err == nil {
+				c.commit(e.key, r.kind, l)
+			}
+		}
+		c.writeMtx.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	// the copies are synced before the segment is removed, so they can't be lost with it
+	c.writeMtx.Lock()
+	err = c.active.fh.Sync()
+	c.writeMtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	delete(c.segments, s.id)
+	c.mtx.Unlock()
+	// waits for any reads of the segment to complete
+	s.mtx.Lock()
+	s.closed = true
+	s.fh.Close()
+	s.mtx.Unlock()
+	os.Remove(hintName(dir, s.id))
+	return os.Remove(segmentName(dir, s.id))
+}
+
+// isLive returns true if the record located by the hint entry is the key's latest record, or
+// is a tombstone for a key that has not since been stored again
+func (c *Cache) isLive(id uint64, e *hintEntry) bool {
+	c.mtx.RLock()
+	l, ok := c.keydir[e.key]
+	c.mtx.RUnlock()
+	if e.kind == kindTombstone {
+		return !ok
+	}
+	return ok && l.segment == id && l.offset == e.offset
+}
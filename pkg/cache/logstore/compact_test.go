@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logstore
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	cfg.Logstore.SegmentSizeBytes = 256
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	// nothing is compacted while there are no dead records
+	c.Store("key.0", []byte("data"), time.Minute)
+	before, after, err := c.Compact()
+	if err != nil || before != after {
+		t.Errorf("unexpected result %d %d %v", before, after, err)
+	}
+
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 10; i++ {
+			c.Store("key."+strconv.Itoa(i), []byte("data"+strconv.Itoa(j)), time.Minute)
+		}
+	}
+	c.Remove("key.9")
+	first := c.active.id
+	for id := range c.segments {
+		if id < first {
+			first = id
+		}
+	}
+
+	before, after, err = c.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("expected %d to be less than %d", after, before)
+	}
+	if _, ok := c.segments[first]; ok {
+		t.Errorf("expected segment %d to be removed", first)
+	}
+	if _, err = os.Stat(segmentName(cfg.Logstore.Directory, first)); !os.IsNotExist(err) {
+		t.Errorf("expected segment %d file to be removed", first)
+	}
+	for i := 0; i < 9; i++ {
+		expectValue(t, c, "key."+strconv.Itoa(i), "data2")
+	}
+	expectMiss(t, c, "key.9")
+
+	// the compacted cache is reopened to the same objects
+	c.Close()
+	c = newCache(t, cfg)
+	for i := 0; i < 9; i++ {
+		expectValue(t, c, "key."+strconv.Itoa(i), "data2")
+	}
+	expectMiss(t, c, "key.9")
+}
+
+func TestCompactKeepsTombstones(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	cfg.Logstore.SegmentSizeBytes = 128
+	c := newCache(t, cfg)
+
+	// key.1 is stored in the first segment, and removed in a later one
+	c.Store("key.1", []byte("data"), time.Minute)
+	first := c.active.id
+	for i := 2; i < 8; i++ {
+		c.Store("key."+strconv.Itoa(i), []byte("data"), time.Minute)
+	}
+	c.Remove("key.1")
+	removed := c.active.id
+	for i := 2; i < 8; i++ {
+		c.Store("key."+strconv.Itoa(i), []byte("data"), time.Minute)
+	}
+	if removed == first || removed == c.active.id {
+		t.Fatalf("expected the tombstone in a sealed segment, got %d %d %d",
+			first, removed, c.active.id)
+	}
+
+	// only the tombstone's segment is compacted, so the tombstone must be kept
+	if _, _, err := c.compact(func(s *segment) bool { return s.id == removed }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.segments[first]; !ok {
+		t.Fatal("expected the first segment to remain")
+	}
+	c.Close()
+
+	c = newCache(t, cfg)
+	defer c.Close()
+	expectMiss(t, c, "key.1")
+	expectValue(t, c, "key.7", "data")
+}
+
+func TestCompactor(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	cfg.Logstore.SegmentSizeBytes = 128
+	cfg.Logstore.CompactInterval = 10 * time.Millisecond
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			c.Store("key."+strconv.Itoa(i), []byte("data"+strconv.Itoa(j)), time.Minute)
+		}
+	}
+
+	// the compactor is polled, since each pass syncs the active segment
+	var pending []uint64
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		pending = pending[:0]
+		c.mtx.RLock()
+		for _, s := range c.segments {
+			if s != c.active && float64(s.dead)/float64(s.size) >= cfg.Logstore.CompactDeadRatio {
+				pending = append(pending, s.id)
+			}
+		}
+		c.mtx.RUnlock()
+		if len(pending) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, id := range pending {
+		t.Errorf("expected segment %d to be compacted", id)
+	}
+	for i := 0; i < 4; i++ {
+		expectValue(t, c, "key."+strconv.Itoa(i), "data3")
+	}
+}
@@ -0,0 +1,582 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logstore is the log-structured implementation of the Trickster Cache, which appends
+// objects to segment files on the Filesystem, and locates them with an in-memory hash index
+package logstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// indexFileName is the name of the file in the directory that holds the cache index
+const indexFileName = "logstore.index"
+
+// location locates the latest record of a key in the segments
+type location struct {
+	segment    uint64
+	offset     int64
+	size       int64
+	expiration int64
+	written    int64
+}
+
+// Cache describes a Logstore Cache
+type Cache struct {
+	Name       string
+	Config     *options.Options
+	Logger     *log.Logger
+	Index      *index.Index
+	locker     locks.NamedLocker
+	lockPrefix string
+
+	// mtx guards the keydir, the segments, and the segments' sizes
+	mtx sync.RWMutex
+	// keydir maps each key to the location of its latest record
+	keydir   map[string]location
+	segments map[uint64]*segment
+	// active is the segment that records are appended to
+	active *segment
+	// writeMtx is held while a record is appended and the keydir is updated, so that the
+	// order of the records in the segments matches the order of the keydir's updates
+	writeMtx sync.Mutex
+	// compactMtx is held while segments are compacted
+	compactMtx  sync.Mutex
+	compactQuit chan struct{}
+	compactDone chan struct{}
+	// hints tracks the hint files being written for sealed segments
+	hints sync.WaitGroup
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect opens the segments in the directory, rebuilds the keydir from them, loads the index
+// and starts the compactor goroutine
+func (c *Cache) Connect() error {
+	dir := c.Config.Logstore.Directory
+	c.Logger.Info("logstore cache setup", log.Pairs{"name": c.Name, "directory": dir})
+
+	c.lockPrefix = c.Name + ".logstore."
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.Logger.Info("logstore cache loaded", log.Pairs{"name": c.Name, "segments": len(c.segments),
+		"objects": len(c.keydir), "elapsed": time.Since(start).String()})
+
+	indexData, _ := ioutil.ReadFile(filepath.Join(dir, indexFileName))
+	c.Index = index.NewIndex(c.Name, c.Config.CacheType, indexData,
+		c.Config.Index, c.BulkRemove, c.storeIndex, c.Logger)
+	c.reconcile()
+
+	if c.Config.Logstore.CompactInterval > 0 {
+		c.compactQuit = make(chan struct{})
+		c.compactDone = make(chan struct{})
+		go c.compactor(c.Config.Logstore.CompactInterval)
+	}
+	return nil
+}
+
+// load opens the segments in the directory and replays their records into the keydir, using
+// each segment's hint file for the records it covers. The last segment remains active unless
+// it is full
+func (c *Cache) load() error {
+	dir := c.Config.Logstore.Directory
+	ids, err := segmentIDs(dir)
+	if err != nil {
+		return err
+	}
+	c.keydir = make(map[string]location)
+	c.segments = make(map[uint64]*segment, len(ids)+1)
+	now := time.Now().UnixNano()
+	for i, id := range ids {
+		s, err := c.loadSegment(id, i == len(ids)-1, now)
+		if err != nil {
+			c.closeSegments()
+			return err
+		}
+		c.segments[id] = s
+	}
+
+	// the bytes of each segment that are not held by a live record are dead
+	live := make(map[uint64]int64, len(c.segments))
+	for _, l := range c.keydir {
+		live[l.segment] += l.size
+	}
+	for id, s := range c.segments {
+		s.dead = s.size - live[id]
+	}
+
+	var next uint64 = 1
+	if len(ids) > 0 {
+		last := c.segments[ids[len(ids)-1]]
+		if last.size < c.Config.Logstore.SegmentSizeBytes {
+			c.active = last
+			return nil
+		}
+		next = last.id + 1
+	}
+	if err = c.openActive(next); err != nil {
+		c.closeSegments()
+	}
+	return err
+}
+
+// loadSegment opens the segment and replays its records into the keydir. A torn record at the
+// end of the last segment, left by an interrupted write, is truncated
+func (c *Cache) loadSegment(id uint64, last bool, now int64) (*segment, error) {
+	dir := c.Config.Logstore.Directory
+	fh, err := os.OpenFile(segmentName(dir, id), os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	entries, covered, err := readHint(dir, id)
+	if err != nil || covered > fi.Size() {
+		entries, covered = nil, 0
+	}
+	tail, end, err := scanSegment(fh, covered)
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	if end < fi.Size() {
+		c.Logger.Warn("logstore cache segment has a torn record",
+			log.Pairs{"cacheName": c.Name, "segment": id, "offset": end, "bytes": fi.Size() - end})
+		if last {
+			if err = fh.Truncate(end); err != nil {
+				fh.Close()
+				return nil, err
+			}
+		}
+	}
+	entries = append(entries, tail...)
+	// a sealed segment without a complete hint file is given one, so that it is not read in
+	// full the next time the cache is opened
+	if !last && len(tail) > 0 {
+		if err = writeHint(dir, id, end, entries); err != nil {
+			c.Logger.Warn("logstore cache could not write hint file",
+				log.Pairs{"cacheName": c.Name, "segment": id, "detail": err.Error()})
+		}
+	}
+	for _, e := range entries {
+		c.replay(id, e, now)
+	}
+	return &segment{id: id, fh: fh, size: end}, nil
+}
+
+// replay applies the record located by the hint entry to the keydir
+func (c *Cache) replay(id uint64, e *hintEntry, now int64) {
+	if e.kind == kindTombstone || (e.expiration != 0 && e.expiration <= now) {
+		delete(c.keydir, e.key)
+		return
+	}
+	c.keydir[e.key] = location{segment: id, offset: e.offset, size: e.size(),
+		expiration: e.expiration, written: e.written}
+}
+
+// openActive creates the segment with the provided id and makes it the active segment
+func (c *Cache) openActive(id uint64) error {
+	fh, err := os.OpenFile(segmentName(c.Config.Logstore.Directory, id),
+		os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	s := &segment{id: id, fh: fh}
+	c.mtx.Lock()
+	c.segments[id] = s
+	c.active = s
+	c.mtx.Unlock()
+	return nil
+}
+
+// rotate seals the active segment, and starts a new one. The caller must hold writeMtx
+func (c *Cache) rotate() error {
+	sealed := c.active
+	if err := sealed.fh.Sync(); err != nil {
+		return err
+	}
+	if err := c.openActive(sealed.id + 1); err != nil {
+		return err
+	}
+	c.hints.Add(1)
+	go c.writeSealedHint(sealed)
+	return nil
+}
+
+// writeSealedHint writes the hint file of the sealed segment, unless it has since been compacted
+func (c *Cache) writeSealedHint(s *segment) {
+	defer c.hints.Done()
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.closed {
+		return
+	}
+	entries, end, err := scanSegment(s.fh, 0)
+	if err == nil {
+		err = writeHint(c.Config.Logstore.Directory, s.id, end, entries)
+	}
+	if err != nil {
+		c.Logger.Warn("logstore cache could not write hint file",
+			log.Pairs{"cacheName": c.Name, "segment": s.id, "detail": err.Error()})
+	}
+}
+
+// append writes the encoded record to the active segment, first sealing it if the record would
+// take it past the segment size, and returns the record's location. The caller must hold
+// writeMtx, and commit the location
+func (c *Cache) append(r *record, b []byte) (location, error) {
+	n := int64(len(b))
+	if c.active.size > 0 && c.active.size+n > c.Config.Logstore.SegmentSizeBytes {
+		if err := c.rotate(); err != nil {
+			return location{}, err
+		}
+	}
+	s := c.active
+	if _, err := s.fh.WriteAt(b, s.size); err != nil {
+		return location{}, err
+	}
+	if c.Config.Logstore.SyncWrites {
+		if err := s.fh.Sync(); err != nil {
+			return location{}, err
+		}
+	}
+	return location{segment: s.id, offset: s.size, size: n,
+		expiration: r.expiration, written: r.written}, nil
+}
+
+// commit updates the keydir and the segments' accounting for the appended record. The caller
+// must hold writeMtx
+func (c *Cache) commit(key string, kind byte, l location) {
+	c.mtx.Lock()
+	s := c.segments[l.segment]
+	s.size = l.offset + l.size
+	if old, ok := c.keydir[key]; ok {
+		if prev, ok := c.segments[old.segment]; ok {
+			prev.dead += old.size
+		}
+	}
+	if kind == kindPut {
+		c.keydir[key] = l
+	} else {
+		// a tombstone is only needed until the records it removes are compacted
+		delete(c.keydir, key)
+		s.dead += l.size
+	}
+	c.mtx.Unlock()
+}
+
+// Store places an object in the cache using the specified key and ttl
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+
+	now := time.Now()
+	exp := now.Add(ttl)
+	r := &record{kind: kindPut, key: cacheKey, value: data,
+		expiration: exp.UnixNano(), written: now.UnixNano()}
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	c.writeMtx.Lock()
+	l, err := c.append(r, r.encode())
+	if err == nil {
+		c.commit(cacheKey, kindPut, l)
+	}
+	c.writeMtx.Unlock()
+	nl.Release()
+	if err != nil {
+		return err
+	}
+	c.Logger.Debug("logstore cache store", log.Pairs{"key": cacheKey, "ttl": ttl})
+	c.Index.UpdateObject(&index.Object{Key: cacheKey, Value: data, Expiration: exp})
+	return nil
+}
+
+// storeIndex writes the cache index to its file in the directory. The index is kept out of the
+// segments, since each flush would otherwise append a copy of the whole index to them
+func (c *Cache) storeIndex(cacheKey string, data []byte) {
+	err := writeFileAtomic(filepath.Join(c.Config.Logstore.Directory, indexFileName), data)
+	if err != nil {
+		c.Logger.Error("logstore cache failed to write index",
+			log.Pairs{"cacheName": c.Name, "indexSize": len(data), "detail": err.Error()})
+	}
+}
+
+// Retrieve looks for an object in cache and returns it (or an error if not found)
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	nl, _ := c.locker.RAcquire(c.lockPrefix + cacheKey)
+	data, err := c.read(cacheKey)
+	nl.RRelease()
+	if err == cache.ErrKNF {
+		c.Logger.Debug("logstore cache miss", log.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, err
+	}
+	if err != nil {
+		go c.remove(cacheKey, false)
+		_, err = metrics.CacheError(cacheKey, c.Name, c.Config.CacheType,
+			"value for key [%s] could not be read from cache")
+		return nil, status.LookupStatusError, err
+	}
+
+	exp := c.Index.GetExpiration(cacheKey)
+	if !allowExpired && !exp.IsZero() && !exp.After(time.Now()) {
+		// Cache Object has been expired but not reaped, go ahead and delete it
+		go c.remove(cacheKey, false)
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+
+	c.Logger.Debug("logstore cache retrieve", log.Pairs{"cacheKey": cacheKey})
+	c.Index.QueueObjectAccess(cacheKey)
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	if data, err = cache.DecodeValue(c.Config, cacheKey, data); err != nil {
+		return nil, status.LookupStatusError, err
+	}
+	return data, status.LookupStatusHit, nil
+}
+
+// read returns the value of the key's latest record, verifying the record's checksum
+func (c *Cache) read(cacheKey string) ([]byte, error) {
+	c.mtx.RLock()
+	l, ok := c.keydir[cacheKey]
+	var s *segment
+	if ok {
+		s, ok = c.segments[l.segment]
+	}
+	if !ok {
+		c.mtx.RUnlock()
+		return nil, cache.ErrKNF
+	}
+	// the segment can't be closed by compaction while it is read
+	s.mtx.RLock()
+	c.mtx.RUnlock()
+	b := make([]byte, l.size)
+	_, err := s.fh.ReadAt(b, l.offset)
+	s.mtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	r, err := decodeRecord(b)
+	if err != nil {
+		return nil, err
+	}
+	if r.kind != kindPut || r.key != cacheKey {
+		return nil, errCorruptRecord
+	}
+	return r.value, nil
+}
+
+// SetTTL updates the TTL for the provided cache object
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	go c.Index.UpdateObjectTTL(cacheKey, ttl)
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.remove(cacheKey, false)
+}
+
+// remove appends a tombstone for the key, if it is in the keydir
+func (c *Cache) remove(cacheKey string, isBulk bool) error {
+	r := &record{kind: kindTombstone, key: cacheKey, written: time.Now().UnixNano()}
+	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
+	c.writeMtx.Lock()
+	c.mtx.RLock()
+	_, ok := c.keydir[cacheKey]
+	c.mtx.RUnlock()
+	var err error
+	if ok {
+		var l location
+		if l, err = c.append(r, r.encode()); err == nil {
+			c.commit(cacheKey, kindTombstone, l)
+		}
+	}
+	c.writeMtx.Unlock()
+	nl.Release()
+	if err != nil {
+		c.Logger.Error("logstore cache key delete failure",
+			log.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return err
+	}
+	if !isBulk {
+		go c.Index.RemoveObject(cacheKey)
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+	c.Logger.Debug("logstore cache key delete", log.Pairs{"key": cacheKey})
+	return nil
+}
+
+// BulkRemove removes a list of objects from the cache
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	// tombstones are appended one at a time, so there is nothing to gain by removing the
+	// objects concurrently
+	for _, cacheKey := range cacheKeys {
+		c.remove(cacheKey, true)
+	}
+}
+
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	return c.Index.Keys(prefix), nil
+}
+
+// Inspect returns the statistics of the cached objects, including the topN largest and
+// most-accessed objects
+func (c *Cache) Inspect(topN int) *cache.Stats {
+	return c.Index.Stats(topN, time.Now())
+}
+
+// InspectObject returns the statistics of the object cached at the key, or nil if the key
+// is not cached
+func (c *Cache) InspectObject(cacheKey string) *cache.ObjectStats {
+	return c.Index.ObjectStats(cacheKey, time.Now())
+}
+
+// SetTags associates the object cached at the key with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	c.Index.SetTags(cacheKey, tags)
+}
+
+// TaggedKeys returns the keys of the cached objects that are associated with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	return c.Index.TaggedKeys(tag), nil
+}
+
+// reconcile adds the objects of the keydir that are missing from the index, such as those
+// written after the index was last flushed, and removes the indexed objects that are not in
+// the keydir
+func (c *Cache) reconcile() {
+	indexed := c.Index.Keys("")
+	found := make(map[string]bool, len(indexed))
+	for _, k := range indexed {
+		found[k] = true
+	}
+
+	c.mtx.RLock()
+	restores := make([]*index.Object, 0)
+	for k, l := range c.keydir {
+		if found[k] {
+			continue
+		}
+		o := &index.Object{Key: k, Size: l.size - recordSize(len(k), 0),
+			LastWrite: time.Unix(0, l.written)}
+		o.LastAccess = o.LastWrite
+		if l.expiration != 0 {
+			o.Expiration = time.Unix(0, l.expiration)
+		}
+		restores = append(restores, o)
+	}
+	missing := make([]string, 0)
+	for _, k := range indexed {
+		if _, ok := c.keydir[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	c.mtx.RUnlock()
+
+	restored := c.Index.RestoreObjects(restores)
+	if len(missing) > 0 {
+		c.Index.RemoveObjects(missing, false)
+	}
+	if restored > 0 || len(missing) > 0 {
+		c.Logger.Info("logstore cache index reconciled", log.Pairs{"cacheName": c.Name,
+			"restored": restored, "removed": len(missing)})
+	}
+}
+
+// closeSegments closes the file of each segment
+func (c *Cache) closeSegments() {
+	c.mtx.Lock()
+	for _, s := range c.segments {
+		s.mtx.Lock()
+		s.closed = true
+		s.fh.Close()
+		s.mtx.Unlock()
+	}
+	c.segments = nil
+	c.mtx.Unlock()
+}
+
+// Close stops the compactor, flushes the index, and closes the segments, writing a hint file
+// for the active segment so that it is not read in full the next time the cache is opened
+func (c *Cache) Close() error {
+	if c.compactQuit != nil {
+		close(c.compactQuit)
+		<-c.compactDone
+		c.compactQuit = nil
+	}
+	if c.Index != nil {
+		c.Index.Close()
+		c.Index.Flush(c.Logger)
+	}
+	c.hints.Wait()
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	if c.active == nil {
+		return nil
+	}
+	err := c.active.fh.Sync()
+	if err == nil {
+		var entries []*hintEntry
+		var end int64
+		if entries, end, err = scanSegment(c.active.fh, 0); err == nil {
+			err = writeHint(c.Config.Logstore.Directory, c.active.id, end, entries)
+		}
+	}
+	c.closeSegments()
+	c.active = nil
+	return err
+}
@@ -0,0 +1,379 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/index"
+	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	lso "github.com/tricksterproxy/trickster/pkg/cache/logstore/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "logstore"
+const cacheKey = "cacheKey"
+
+func newCacheConfig(t *testing.T) *co.Options {
+	dir, err := ioutil.TempDir("/tmp", cacheType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := lso.NewOptions()
+	o.Directory = dir
+	o.CompactIntervalSecs = 0
+	o.SetDurations()
+	return &co.Options{CacheType: cacheType, Logstore: o,
+		Index: &io.Options{ReapInterval: time.Second}}
+}
+
+func newCache(t *testing.T, cfg *co.Options) *Cache {
+	c := &Cache{Name: "test", Config: cfg, Logger: tl.ConsoleLogger("error"),
+		locker: locks.NewNamedLocker()}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func expectValue(t *testing.T, c *Cache, key, expected string) {
+	t.Helper()
+	data, ls, err := c.Retrieve(key, false)
+	if err != nil {
+		t.Fatalf("key %s: %v", key, err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("key %s: expected %s got %s", key, status.LookupStatusHit, ls)
+	}
+	if string(data) != expected {
+		t.Errorf("key %s: expected %s got %s", key, expected, string(data))
+	}
+}
+
+func expectMiss(t *testing.T, c *Cache, key string) {
+	t.Helper()
+	_, ls, err := c.Retrieve(key, false)
+	if err != cache.ErrKNF {
+		t.Errorf("key %s: expected error %v got %v", key, cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("key %s: expected %s got %s", key, status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestConfiguration(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := Cache{Config: cfg}
+	if c.Configuration().CacheType != cacheType {
+		t.Errorf("expected %s got %s", cacheType, c.Configuration().CacheType)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	c := &Cache{}
+	l := locks.NewNamedLocker()
+	c.SetLocker(l)
+	if c.Locker() != l {
+		t.Error("expected the locker that was set")
+	}
+}
+
+func TestConnectFailed(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	// a file where the directory should be can't be used
+	name := filepath.Join(cfg.Logstore.Directory, "file")
+	ioutil.WriteFile(name, []byte("data"), 0644)
+	cfg.Logstore.Directory = name
+	c := &Cache{Name: "test", Config: cfg, Logger: tl.ConsoleLogger("error"),
+		locker: locks.NewNamedLocker()}
+	if err := c.Connect(); err == nil {
+		t.Error("expected error")
+		c.Close()
+	}
+}
+
+func TestStoreRetrieveRemove(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	expectMiss(t, c, cacheKey)
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, c, cacheKey, "data")
+
+	// an overwritten record is dead
+	if err := c.Store(cacheKey, []byte("data2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, c, cacheKey, "data2")
+	if c.active.dead != recordSize(len(cacheKey), 4) {
+		t.Errorf("expected %d got %d", recordSize(len(cacheKey), 4), c.active.dead)
+	}
+
+	c.Remove(cacheKey)
+	expectMiss(t, c, cacheKey)
+	time.Sleep(10 * time.Millisecond)
+	if !c.Index.GetExpiration(cacheKey).IsZero() {
+		t.Error("expected the key to be removed from the index")
+	}
+	if c.active.dead != c.active.size {
+		t.Errorf("expected %d got %d", c.active.size, c.active.dead)
+	}
+
+	// removing a key that isn't cached appends nothing
+	size := c.active.size
+	c.Remove("missing")
+	if c.active.size != size {
+		t.Errorf("expected %d got %d", size, c.active.size)
+	}
+}
+
+func TestRetrieveExpired(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	data, ls, err := c.Retrieve(cacheKey, true)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("unexpected result %s %s %v", string(data), ls, err)
+	}
+	expectMiss(t, c, cacheKey)
+	time.Sleep(10 * time.Millisecond)
+	c.mtx.RLock()
+	_, ok := c.keydir[cacheKey]
+	c.mtx.RUnlock()
+	if ok {
+		t.Error("expected the expired object to be removed")
+	}
+}
+
+func TestRetrieveCorrupt(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// the last byte of the value is overwritten
+	c.active.fh.WriteAt([]byte("x"), c.active.size-1)
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err == nil || ls != status.LookupStatusError {
+		t.Errorf("expected error, got %s %v", ls, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	expectMiss(t, c, cacheKey)
+}
+
+func TestSetTTL(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	c.SetTTL(cacheKey, time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if d := time.Until(c.Index.GetExpiration(cacheKey)); d < 59*time.Minute {
+		t.Errorf("expected about %s got %s", time.Hour, d)
+	}
+}
+
+func TestBulkRemoveKeysTags(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	for i := 0; i < 4; i++ {
+		if err := c.Store("key."+strconv.Itoa(i), []byte("data"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Store("other", []byte("data"), time.Minute)
+	c.SetTags("key.1", []string{"tag1"})
+
+	keys, _ := c.Keys("key.")
+	if len(keys) != 4 {
+		t.Errorf("expected 4 got %d", len(keys))
+	}
+	keys, _ = c.TaggedKeys("tag1")
+	if len(keys) != 1 || keys[0] != "key.1" {
+		t.Errorf("expected [key.1] got %v", keys)
+	}
+	if s := c.Inspect(2); s == nil || s.Objects != 5 {
+		t.Errorf("unexpected stats %v", s)
+	}
+	if s := c.InspectObject("key.1"); s == nil {
+		t.Error("expected object stats")
+	}
+
+	c.BulkRemove([]string{"key.0", "key.2", "missing"})
+	expectMiss(t, c, "key.0")
+	expectMiss(t, c, "key.2")
+	expectValue(t, c, "key.1", "data")
+}
+
+func TestReopen(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	cfg.Logstore.SegmentSizeBytes = 256
+	c := newCache(t, cfg)
+
+	for i := 0; i < 20; i++ {
+		if err := c.Store("key."+strconv.Itoa(i), []byte("data"+strconv.Itoa(i)), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Remove("key.3")
+	c.Store("key.4", []byte("updated"), time.Minute)
+	c.Store("expired", []byte("data"), -time.Second)
+	if len(c.segments) < 3 {
+		t.Errorf("expected several segments, got %d", len(c.segments))
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every segment has a hint file
+	ids, _ := segmentIDs(cfg.Logstore.Directory)
+	for _, id := range ids {
+		if _, err := os.Stat(hintName(cfg.Logstore.Directory, id)); err != nil {
+			t.Errorf("segment %d: %v", id, err)
+		}
+	}
+
+	c = newCache(t, cfg)
+	defer c.Close()
+	expectMiss(t, c, "key.3")
+	expectMiss(t, c, "expired")
+	expectValue(t, c, "key.4", "updated")
+	expectValue(t, c, "key.19", "data19")
+	if len(c.keydir) != 19 {
+		t.Errorf("expected 19 got %d", len(c.keydir))
+	}
+	if keys, _ := c.Keys(""); len(keys) != 19 {
+		t.Errorf("expected 19 got %d", len(keys))
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	c.Store("key.1", []byte("data"), time.Minute)
+	c.Store("key.2", []byte("data"), time.Minute)
+	c.Close()
+
+	// the index is replaced by one that is missing key.2 and lists an unknown key
+	c = newCache(t, cfg)
+	c.Index.RemoveObject("key.2")
+	c.Index.RestoreObjects([]*index.Object{{Key: "unknown", Size: 1}})
+	c.Index.Flush(c.Logger)
+	c.compactQuit = nil
+	c.closeSegments()
+
+	c = newCache(t, cfg)
+	defer c.Close()
+	keys, _ := c.Keys("")
+	if len(keys) != 2 || keys[0] != "key.1" || keys[1] != "key.2" {
+		t.Errorf("expected [key.1 key.2] got %v", keys)
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	c := newCache(t, cfg)
+	c.Store("key.1", []byte("data1"), time.Minute)
+	c.Store("key.2", []byte("data2"), time.Minute)
+	name := segmentName(cfg.Logstore.Directory, c.active.id)
+	size := c.active.size
+	c.Index.Flush(c.Logger)
+	// the cache is abandoned without being closed, after a partial write
+	c.closeSegments()
+	b := (&record{kind: kindPut, key: "key.3", value: []byte("data3")}).encode()
+	fh, _ := os.OpenFile(name, os.O_WRONLY, 0644)
+	fh.WriteAt(b[:len(b)-2], size)
+	fh.Close()
+
+	c = newCache(t, cfg)
+	defer c.Close()
+	expectValue(t, c, "key.1", "data1")
+	expectValue(t, c, "key.2", "data2")
+	expectMiss(t, c, "key.3")
+	if fi, _ := os.Stat(name); fi.Size() != size {
+		t.Errorf("expected %d got %d", size, fi.Size())
+	}
+	// writes continue after the truncated tail
+	c.Store("key.3", []byte("data3"), time.Minute)
+	expectValue(t, c, "key.3", "data3")
+}
+
+func TestConcurrentStoreRetrieve(t *testing.T) {
+	cfg := newCacheConfig(t)
+	defer os.RemoveAll(cfg.Logstore.Directory)
+	cfg.Logstore.SegmentSizeBytes = 1024
+	c := newCache(t, cfg)
+	defer c.Close()
+
+	done := make(chan bool)
+	for g := 0; g < 4; g++ {
+		go func(g int) {
+			for i := 0; i < 50; i++ {
+				key := "key." + strconv.Itoa(i%10)
+				c.Store(key, []byte("data"+strconv.Itoa(g)), time.Minute)
+				c.Retrieve(key, false)
+				if i%7 == 0 {
+					c.Remove(key)
+				}
+			}
+			done <- true
+		}(g)
+	}
+	go func() {
+		for i := 0; i < 5; i++ {
+			c.Compact()
+		}
+		done <- true
+	}()
+	for i := 0; i < 5; i++ {
+		<-done
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// MaxSegmentSizeMB is the largest segment size, which keeps each segment's offsets and record
+// sizes within the range of its hint file's fields
+const MaxSegmentSizeMB = 4095
+
+// ErrMissingDirectory is returned when no directory is configured
+var ErrMissingDirectory = errors.New("logstore directory must be provided")
+
+// ErrInvalidSegmentSize is returned when the segment size is out of range
+var ErrInvalidSegmentSize = errors.New("logstore segment_size_mb must be between 1 and 4095")
+
+// ErrInvalidCompactInterval is returned when the compaction interval is negative
+var ErrInvalidCompactInterval = errors.New("logstore compact_interval_secs must not be negative")
+
+// ErrInvalidCompactDeadRatio is returned when the compaction dead ratio is not between 0 and 1
+var ErrInvalidCompactDeadRatio = errors.New(
+	"logstore compact_dead_ratio must be greater than 0 and less than 1")
+
+// Options is a collection of Configurations for storing cached data in append-only segment
+// files on the Filesystem
+type Options struct {
+	// Directory is the path on disk where the segment files and the cache index are stored
+	Directory string `toml:"directory"`
+	// SegmentSizeMB is the size at which the segment being written to is sealed, and a new
+	// segment is started. Only sealed segments are compacted
+	SegmentSizeMB int `toml:"segment_size_mb"`
+	// CompactIntervalSecs is how often the sealed segments are checked for compaction.
+	// 0 disables automatic compaction
+	CompactIntervalSecs int `toml:"compact_interval_secs"`
+	// CompactDeadRatio is the fraction of a sealed segment that must be held by overwritten,
+	// removed or expired objects for the segment to be compacted automatically
+	CompactDeadRatio float64 `toml:"compact_dead_ratio"`
+	// SyncWrites fsyncs the segment after each write, rather than leaving it to the
+	// operating system
+	SyncWrites bool `toml:"sync_writes"`
+
+	// SegmentSizeBytes is the byte representation of SegmentSizeMB
+	SegmentSizeBytes int64 `toml:"-"`
+	// CompactInterval is the time.Duration representation of CompactIntervalSecs
+	CompactInterval time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new logstore Options Reference with default values set
+func NewOptions() *Options {
+	o := &Options{
+		Directory:           d.DefaultCachePath,
+		SegmentSizeMB:       d.DefaultLogstoreSegmentSizeMB,
+		CompactIntervalSecs: d.DefaultLogstoreCompactIntervalSecs,
+		CompactDeadRatio:    d.DefaultLogstoreCompactDeadRatio,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	return &o2
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if o.Directory == "" {
+		return ErrMissingDirectory
+	}
+	if o.SegmentSizeMB < 1 || o.SegmentSizeMB > MaxSegmentSizeMB {
+		return ErrInvalidSegmentSize
+	}
+	if o.CompactIntervalSecs < 0 {
+		return ErrInvalidCompactInterval
+	}
+	if o.CompactDeadRatio <= 0 || o.CompactDeadRatio >= 1 {
+		return ErrInvalidCompactDeadRatio
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration and byte representations of the second- and
+// megabyte-based options
+func (o *Options) SetDurations() {
+	o.SegmentSizeBytes = int64(o.SegmentSizeMB) << 20
+	o.CompactInterval = time.Duration(o.CompactIntervalSecs) * time.Second
+}
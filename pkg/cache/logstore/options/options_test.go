@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Fatal("expected non-nil options")
+	}
+	if o.SegmentSizeBytes != 512<<20 {
+		t.Errorf("expected %d got %d", 512<<20, o.SegmentSizeBytes)
+	}
+	if o.CompactInterval != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, o.CompactInterval)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		directory string
+		segmentMB int
+		interval  int
+		ratio     float64
+		expected  error
+	}{
+		{"/tmp/logstore", 512, 60, 0.5, nil},
+		{"/tmp/logstore", MaxSegmentSizeMB, 0, 0.9, nil},
+		{"", 512, 60, 0.5, ErrMissingDirectory},
+		{"/tmp/logstore", 0, 60, 0.5, ErrInvalidSegmentSize},
+		{"/tmp/logstore", MaxSegmentSizeMB + 1, 60, 0.5, ErrInvalidSegmentSize},
+		{"/tmp/logstore", 512, -1, 0.5, ErrInvalidCompactInterval},
+		{"/tmp/logstore", 512, 60, 0, ErrInvalidCompactDeadRatio},
+		{"/tmp/logstore", 512, 60, 1, ErrInvalidCompactDeadRatio},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.Directory = test.directory
+		o.SegmentSizeMB = test.segmentMB
+		o.CompactIntervalSecs = test.interval
+		o.CompactDeadRatio = test.ratio
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o2 := o.Clone()
+	o2.Directory = "/other"
+	if o.Directory == "/other" {
+		t.Error("expected clone to be independent")
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.SegmentSizeMB = 2
+	o.CompactIntervalSecs = 10
+	o.SetDurations()
+	if o.SegmentSizeBytes != 2<<20 || o.CompactInterval != 10*time.Second {
+		t.Errorf("unexpected values %d %s", o.SegmentSizeBytes, o.CompactInterval)
+	}
+}
@@ -0,0 +1,320 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// record kinds
+const (
+	kindPut       = byte(1)
+	kindTombstone = byte(2)
+)
+
+// recordHeaderSize is the size of a record's header, which is its crc32, kind, key and value
+// lengths, expiration and write time, in that order
+const recordHeaderSize = 4 + 1 + 4 + 4 + 8 + 8
+
+// hintEntrySize is the size of a hint entry, excluding its key, which is the record's kind,
+// key and value lengths, offset, expiration and write time, in that order
+const hintEntrySize = 1 + 4 + 4 + 8 + 8 + 8
+
+const (
+	segmentSuffix = ".seg"
+	hintSuffix    = ".hint"
+	tempSuffix    = ".tmp"
+)
+
+// errCorruptRecord is returned when a record's checksum or lengths are invalid
+var errCorruptRecord = errors.New("corrupt record")
+
+// errCorruptHint is returned when a hint file's checksum or entries are invalid
+var errCorruptHint = errors.New("corrupt hint file")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// record is a single entry of a segment, which either stores or removes the object of its key
+type record struct {
+	kind       byte
+	key        string
+	value      []byte
+	expiration int64
+	written    int64
+}
+
+// size returns the size of the encoded record
+func (r *record) size() int64 {
+	return recordSize(len(r.key), len(r.value))
+}
+
+func recordSize(keyLen, valueLen int) int64 {
+	return int64(recordHeaderSize + keyLen + valueLen)
+}
+
+// encode returns the record as it is appended to a segment
+func (r *record) encode() []byte {
+	b := make([]byte, r.size())
+	b[4] = r.kind
+	binary.BigEndian.PutUint32(b[5:], uint32(len(r.key)))
+	binary.BigEndian.PutUint32(b[9:], uint32(len(r.value)))
+	binary.BigEndian.PutUint64(b[13:], uint64(r.expiration))
+	binary.BigEndian.PutUint64(b[21:], uint64(r.written))
+	copy(b[recordHeaderSize:], r.key)
+	copy(b[recordHeaderSize+len(r.key):], r.value)
+	binary.BigEndian.PutUint32(b, crc32.Checksum(b[4:], crcTable))
+	return b
+}
+
+// decodeRecord returns the record encoded in b, which must be exactly the record's size
+func decodeRecord(b []byte) (*record, error) {
+	if len(b) < recordHeaderSize {
+		return nil, errCorruptRecord
+	}
+	kl := int(binary.BigEndian.Uint32(b[5:]))
+	vl := int(binary.BigEndian.Uint32(b[9:]))
+	if int64(len(b)) != recordSize(kl, vl) ||
+		binary.BigEndian.Uint32(b) != crc32.Checksum(b[4:], crcTable) {
+		return nil, errCorruptRecord
+	}
+	r := &record{
+		kind:       b[4],
+		expiration: int64(binary.BigEndian.Uint64(b[13:])),
+		written:    int64(binary.BigEndian.Uint64(b[21:])),
+		key:        string(b[recordHeaderSize : recordHeaderSize+kl]),
+		value:      b[recordHeaderSize+kl:],
+	}
+	if r.kind != kindPut && r.kind != kindTombstone {
+		return nil, errCorruptRecord
+	}
+	return r, nil
+}
+
+// hintEntry locates a record in its segment, and is how the record is replayed into the
+// keydir without reading its value
+type hintEntry struct {
+	kind       byte
+	key        string
+	valueLen   uint32
+	offset     int64
+	expiration int64
+	written    int64
+}
+
+// size returns the size of the record that the hint entry locates
+func (e *hintEntry) size() int64 {
+	return recordSize(len(e.key), int(e.valueLen))
+}
+
+// segment is an append-only file of records
+type segment struct {
+	id uint64
+	fh *os.File
+	// size is the number of bytes of valid records in the segment
+	size int64
+	// dead is the number of bytes held by records that were overwritten, removed or are
+	// tombstones
+	dead int64
+	// mtx is held for reading while a record is read from the segment, and for writing
+	// while the segment is closed after it is compacted
+	mtx sync.RWMutex
+	// closed is true once the segment's file is closed
+	closed bool
+}
+
+// segmentName returns the filename of the segment with the provided id
+func segmentName(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+// hintName returns the filename of the hint file of the segment with the provided id
+func hintName(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", id, hintSuffix))
+}
+
+// segmentIDs returns the ids of the segments in the directory, in ascending order
+func segmentIDs(dir string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(files))
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// scanSegment reads the records of the segment file from the offset, and returns a hint entry
+// for each, and the offset at which the valid records end. Reading stops at the first record
+// that is incomplete or fails its checksum, which is the torn tail of an interrupted write
+func scanSegment(fh *os.File, from int64) ([]*hintEntry, int64, error) {
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, from, err
+	}
+	end := fi.Size()
+	entries := make([]*hintEntry, 0)
+	r := bufio.NewReaderSize(io.NewSectionReader(fh, from, end-from), 1<<20)
+	offset := from
+	hdr := make([]byte, recordHeaderSize)
+	var buf []byte
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return entries, offset, nil
+			}
+			return entries, offset, err
+		}
+		kl := int(binary.BigEndian.Uint32(hdr[5:]))
+		vl := int(binary.BigEndian.Uint32(hdr[9:]))
+		n := recordSize(kl, vl)
+		// a torn header can claim any length, so it is only trusted to the end of the file
+		if offset+n > end {
+			return entries, offset, nil
+		}
+		if int64(cap(buf)) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		copy(buf, hdr)
+		if _, err := io.ReadFull(r, buf[recordHeaderSize:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return entries, offset, nil
+			}
+			return entries, offset, err
+		}
+		rec, err := decodeRecord(buf)
+		if err != nil {
+			return entries, offset, nil
+		}
+		entries = append(entries, &hintEntry{kind: rec.kind, key: rec.key,
+			valueLen: uint32(vl), offset: offset, expiration: rec.expiration, written: rec.written})
+		offset += n
+	}
+}
+
+// writeHint writes the hint file of the segment, which holds an entry for each of the segment's
+// records through the covered offset. It is written to a temporary file that is renamed into
+// place, so a hint file is never partially written
+func writeHint(dir string, id uint64, covered int64, entries []*hintEntry) error {
+	n := 8 + 4
+	for _, e := range entries {
+		n += hintEntrySize + len(e.key)
+	}
+	b := make([]byte, 8, n)
+	binary.BigEndian.PutUint64(b, uint64(covered))
+	for _, e := range entries {
+		var h [hintEntrySize]byte
+		h[0] = e.kind
+		binary.BigEndian.PutUint32(h[1:], uint32(len(e.key)))
+		binary.BigEndian.PutUint32(h[5:], e.valueLen)
+		binary.BigEndian.PutUint64(h[9:], uint64(e.offset))
+		binary.BigEndian.PutUint64(h[17:], uint64(e.expiration))
+		binary.BigEndian.PutUint64(h[25:], uint64(e.written))
+		b = append(b, h[:]...)
+		b = append(b, e.key...)
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(b, crcTable))
+	b = append(b, sum[:]...)
+	return writeFileAtomic(hintName(dir, id), b)
+}
+
+// readHint returns the entries of the segment's hint file, and the offset through which they
+// cover the segment
+func readHint(dir string, id uint64) ([]*hintEntry, int64, error) {
+	b, err := ioutil.ReadFile(hintName(dir, id))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) < 12 || binary.BigEndian.Uint32(b[len(b)-4:]) !=
+		crc32.Checksum(b[:len(b)-4], crcTable) {
+		return nil, 0, errCorruptHint
+	}
+	covered := int64(binary.BigEndian.Uint64(b))
+	b = b[8 : len(b)-4]
+	entries := make([]*hintEntry, 0, len(b)/(hintEntrySize+64))
+	for len(b) > 0 {
+		if len(b) < hintEntrySize {
+			return nil, 0, errCorruptHint
+		}
+		kl := int(binary.BigEndian.Uint32(b[1:]))
+		if len(b) < hintEntrySize+kl {
+			return nil, 0, errCorruptHint
+		}
+		e := &hintEntry{
+			kind:       b[0],
+			valueLen:   binary.BigEndian.Uint32(b[5:]),
+			offset:     int64(binary.BigEndian.Uint64(b[9:])),
+			expiration: int64(binary.BigEndian.Uint64(b[17:])),
+			written:    int64(binary.BigEndian.Uint64(b[25:])),
+			key:        string(b[hintEntrySize : hintEntrySize+kl]),
+		}
+		if e.offset+e.size() > covered {
+			return nil, 0, errCorruptHint
+		}
+		entries = append(entries, e)
+		b = b[hintEntrySize+kl:]
+	}
+	return entries, covered, nil
+}
+
+// writeFileAtomic writes the data to a temporary file that is synced and renamed over the
+// named file
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + tempSuffix
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fh.Write(data)
+	if err == nil {
+		err = fh.Sync()
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
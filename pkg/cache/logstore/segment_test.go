@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRecordEncodeDecode(t *testing.T) {
+	r := &record{kind: kindPut, key: "key", value: []byte("value"), expiration: 2, written: 1}
+	b := r.encode()
+	if int64(len(b)) != r.size() {
+		t.Errorf("expected %d got %d", r.size(), len(b))
+	}
+	r2, err := decodeRecord(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.kind != kindPut || r2.key != "key" || string(r2.value) != "value" ||
+		r2.expiration != 2 || r2.written != 1 {
+		t.Errorf("unexpected record %v", r2)
+	}
+
+	tests := [][]byte{
+		b[:10],
+		b[:len(b)-1],
+		append(append([]byte{}, b[:len(b)-1]...), 'x'),
+	}
+	for i, test := range tests {
+		if _, err := decodeRecord(test); err != errCorruptRecord {
+			t.Errorf("test %d: expected %v got %v", i, errCorruptRecord, err)
+		}
+	}
+
+	// a record with an unknown kind is corrupt
+	r.kind = 9
+	if _, err := decodeRecord(r.encode()); err != errCorruptRecord {
+		t.Errorf("expected %v got %v", errCorruptRecord, err)
+	}
+}
+
+func TestScanSegment(t *testing.T) {
+	dir, _ := ioutil.TempDir("/tmp", "logstore")
+	defer os.RemoveAll(dir)
+	fh, err := os.OpenFile(segmentName(dir, 1), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	b1 := (&record{kind: kindPut, key: "key1", value: []byte("value1")}).encode()
+	b2 := (&record{kind: kindTombstone, key: "key1"}).encode()
+	fh.Write(b1)
+	fh.Write(b2)
+	end := int64(len(b1) + len(b2))
+
+	entries, n, err := scanSegment(fh, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != end || len(entries) != 2 {
+		t.Fatalf("expected %d 2 got %d %d", end, n, len(entries))
+	}
+	if entries[1].kind != kindTombstone || entries[1].offset != int64(len(b1)) {
+		t.Errorf("unexpected entry %v", entries[1])
+	}
+
+	// scanning from an offset only returns the later records
+	entries, _, _ = scanSegment(fh, int64(len(b1)))
+	if len(entries) != 1 {
+		t.Errorf("expected 1 got %d", len(entries))
+	}
+
+	// a torn record and a header with an impossible length end the scan
+	fh.Write(b1[:len(b1)-3])
+	if entries, n, _ = scanSegment(fh, 0); n != end || len(entries) != 2 {
+		t.Errorf("expected %d 2 got %d %d", end, n, len(entries))
+	}
+	fh.Truncate(end)
+	fh.WriteAt([]byte{0, 0, 0, 0, kindPut, 255, 255, 255, 255}, end)
+	if entries, n, _ = scanSegment(fh, 0); n != end || len(entries) != 2 {
+		t.Errorf("expected %d 2 got %d %d", end, n, len(entries))
+	}
+}
+
+func TestHint(t *testing.T) {
+	dir, _ := ioutil.TempDir("/tmp", "logstore")
+	defer os.RemoveAll(dir)
+
+	entries := []*hintEntry{
+		{kind: kindPut, key: "key1", valueLen: 6, offset: 0, expiration: 5, written: 4},
+		{kind: kindTombstone, key: "key1", offset: 35},
+	}
+	if err := writeHint(dir, 1, 68, entries); err != nil {
+		t.Fatal(err)
+	}
+	entries2, covered, err := readHint(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if covered != 68 || len(entries2) != 2 || *entries2[0] != *entries[0] ||
+		*entries2[1] != *entries[1] {
+		t.Errorf("unexpected hint %d %v", covered, entries2)
+	}
+
+	if _, _, err = readHint(dir, 2); err == nil {
+		t.Error("expected error for missing hint")
+	}
+
+	// a hint file that fails its checksum is ignored
+	b, _ := ioutil.ReadFile(hintName(dir, 1))
+	b[10]++
+	ioutil.WriteFile(hintName(dir, 1), b, 0644)
+	if _, _, err = readHint(dir, 1); err != errCorruptHint {
+		t.Errorf("expected %v got %v", errCorruptHint, err)
+	}
+
+	// as is one whose entries extend past the offset it covers
+	writeHint(dir, 1, 40, entries)
+	if _, _, err = readHint(dir, 1); err != errCorruptHint {
+		t.Errorf("expected %v got %v", errCorruptHint, err)
+	}
+}
+
+func TestSegmentIDs(t *testing.T) {
+	dir, _ := ioutil.TempDir("/tmp", "logstore")
+	defer os.RemoveAll(dir)
+	for _, name := range []string{segmentName(dir, 10), segmentName(dir, 2),
+		hintName(dir, 2), dir + "/other.seg", dir + "/" + indexFileName} {
+		ioutil.WriteFile(name, nil, 0644)
+	}
+	ids, err := segmentIDs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 10 {
+		t.Errorf("expected [2 10] got %v", ids)
+	}
+	if _, err = segmentIDs(dir + "/missing"); err == nil {
+		t.Error("expected error")
+	}
+}
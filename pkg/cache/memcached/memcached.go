@@ -0,0 +1,375 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memcached is the Memcached implementation of the Trickster Cache. It speaks the
+// memcached binary protocol, with optional SASL authentication and TLS, and distributes
+// objects across a list of servers by consistent hashing, so that it works against managed
+// clusters such as Amazon ElastiCache and Memcachier
+package memcached
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// pipelineBatchSize is the most requests sent to a server in each pipeline of a bulk
+// operation, which bounds the responses a server may buffer before they are read
+const pipelineBatchSize = 100
+
+// Cache represents a Memcached cache object that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	locker locks.NamedLocker
+
+	servers []*server
+	ring    *ring
+	now     func() time.Time
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect builds the consistent hash ring of the configured servers, and verifies that
+// each server is reachable and accepts the configured credentials
+func (c *Cache) Connect() error {
+	o := c.Config.Memcached
+	c.Logger.Info("connecting to memcached", tl.Pairs{"servers": o.Servers, "tls": o.UseTLS})
+
+	if c.now == nil {
+		c.now = time.Now
+	}
+
+	var tc *tls.Config
+	if o.UseTLS {
+		var err error
+		if tc, err = c.tlsConfig(); err != nil {
+			return err
+		}
+	}
+
+	c.servers = make([]*server, len(o.Servers))
+	for i, addr := range o.Servers {
+		c.servers[i] = &server{addr: addr, tlsConfig: tc, username: o.Username,
+			password: o.Password, timeout: o.Timeout, idle: make(chan *conn, o.MaxIdleConns)}
+	}
+	c.ring = newRing(c.servers, o.VirtualNodes)
+
+	for _, s := range c.servers {
+		cn, err := s.get()
+		if err != nil {
+			return fmt.Errorf("could not connect to memcached server %s: %s", s.addr, err.Error())
+		}
+		s.put(cn)
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of connections to the servers
+func (c *Cache) tlsConfig() (*tls.Config, error) {
+	o := c.Config.Memcached
+	tc := &tls.Config{ServerName: o.ServerName, InsecureSkipVerify: o.InsecureSkipVerify}
+	if len(o.CertificateAuthorityPaths) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range o.CertificateAuthorityPaths {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("unable to append to CA Certs from file %s", path)
+			}
+		}
+		tc.RootCAs = pool
+	}
+	if o.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertPath, o.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Store places the the data into Memcached using the provided Key and TTL
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	c.Logger.Debug("memcached cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
+	k := storageKey(cacheKey)
+	_, err = c.roundTrip(k, &packet{opcode: opSet, extras: setExtras(c.now(), ttl),
+		key: []byte(k), value: data})
+	return err
+}
+
+// Retrieve gets data from Memcached using the provided Key. Memcached does not return
+// expired objects, so allowExpired is not used
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	k := storageKey(cacheKey)
+	resp, err := c.roundTrip(k, &packet{opcode: opGet, key: []byte(k)})
+	if err == ErrNotFound {
+		c.Logger.Debug("memcached cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	var data []byte
+	if err == nil {
+		data, err = cache.DecodeValue(c.Config, cacheKey, resp.value)
+	}
+	if err != nil {
+		c.Logger.Debug("memcached cache retrieve failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+	c.Logger.Debug("memcached cache retrieve", tl.Pairs{"key": cacheKey})
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	return data, status.LookupStatusHit, nil
+}
+
+// SetTTL updates the TTL for the provided cache object, if it is still present
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	k := storageKey(cacheKey)
+	_, err := c.roundTrip(k, &packet{opcode: opTouch, extras: touchExtras(c.now(), ttl),
+		key: []byte(k)})
+	if err != nil && err != ErrNotFound {
+		c.Logger.Debug("memcached cache set ttl failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	}
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.Logger.Debug("memcached cache remove", tl.Pairs{"key": cacheKey})
+	k := storageKey(cacheKey)
+	_, err := c.roundTrip(k, &packet{opcode: opDelete, key: []byte(k)})
+	if err != nil && err != ErrNotFound {
+		c.Logger.Error("memcached cache key delete failure",
+			tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+}
+
+// BulkRemove removes a list of objects from the cache, with the deletes sent to each
+// server as pipelines of quiet requests
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug("memcached cache bulk remove", tl.Pairs{"keys": len(cacheKeys)})
+	err := c.pipeline(cacheKeys, func(k string) *packet {
+		return &packet{opcode: opDeleteQ, key: []byte(storageKey(k))}
+	}, func(i int, p *packet) error {
+		if err := errorForStatus(p.status, p.value); err != ErrNotFound {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		c.Logger.Error("memcached cache bulk delete failure", tl.Pairs{"reason": err.Error()})
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
+}
+
+// BulkRetrieve returns the objects cached at the provided keys, with the gets sent to each
+// server as pipelines of quiet requests, to which servers only respond with hits
+func (c *Cache) BulkRetrieve(cacheKeys []string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(cacheKeys))
+	err := c.pipeline(cacheKeys, func(k string) *packet {
+		return &packet{opcode: opGetKQ, key: []byte(storageKey(k))}
+	}, func(i int, p *packet) error {
+		if p.status != statusOK {
+			return nil
+		}
+		data, err := cache.DecodeValue(c.Config, cacheKeys[i], p.value)
+		if err != nil {
+			return nil
+		}
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+		out[cacheKeys[i]] = data
+		return nil
+	})
+	for _, k := range cacheKeys {
+		if _, ok := out[k]; !ok {
+			metrics.ObserveCacheMiss(k, c.Name, c.Config.CacheType)
+		}
+	}
+	c.Logger.Debug("memcached cache bulk retrieve",
+		tl.Pairs{"keys": len(cacheKeys), "hits": len(out)})
+	return out, err
+}
+
+// BulkStore places the provided objects in the cache with the ttl, with the sets sent to
+// each server as pipelines of quiet requests, to which servers only respond with errors
+func (c *Cache) BulkStore(objects map[string][]byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	keys := make([]string, 0, len(objects))
+	values := make(map[string][]byte, len(objects))
+	for k, v := range objects {
+		data, err := cache.EncodeValue(c.Config, k, v)
+		if err != nil {
+			return err
+		}
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+		keys = append(keys, k)
+		values[k] = data
+	}
+	c.Logger.Debug("memcached cache bulk store", tl.Pairs{"keys": len(keys)})
+	extras := setExtras(c.now(), ttl)
+	return c.pipeline(keys, func(k string) *packet {
+		return &packet{opcode: opSetQ, extras: extras, key: []byte(storageKey(k)), value: values[k]}
+	}, func(i int, p *packet) error {
+		return errorForStatus(p.status, p.value)
+	})
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	for _, s := range c.servers {
+		s.close()
+	}
+	return nil
+}
+
+// roundTrip sends a single request to the server holding the storage key, and returns its
+// response, or the error represented by the response's status
+func (c *Cache) roundTrip(key string, p *packet) (*packet, error) {
+	s := c.ring.get(key)
+	if s == nil {
+		return nil, errors.New("memcached cache is not connected")
+	}
+	cn, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	defer s.put(cn)
+	resp, err := cn.roundTrip(p)
+	if err != nil {
+		return nil, err
+	}
+	return resp, errorForStatus(resp.status, resp.value)
+}
+
+// pipeline sends the request made by req for each key to the server holding the key, as
+// pipelines of quiet requests terminated by a noop, and calls handle with the index of the
+// key and each response received before the noop's. The first error is returned
+func (c *Cache) pipeline(keys []string, req func(string) *packet,
+	handle func(int, *packet) error) error {
+	if c.ring == nil {
+		return errors.New("memcached cache is not connected")
+	}
+	byServer := make(map[*server][]int)
+	for i, k := range keys {
+		s := c.ring.get(storageKey(k))
+		byServer[s] = append(byServer[s], i)
+	}
+	var ferr error
+	for s, indexes := range byServer {
+		for j := 0; j < len(indexes); j += pipelineBatchSize {
+			end := j + pipelineBatchSize
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			if err := c.pipelineBatch(s, keys, indexes[j:end], req, handle); err != nil &&
+				ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+func (c *Cache) pipelineBatch(s *server, keys []string, indexes []int,
+	req func(string) *packet, handle func(int, *packet) error) error {
+	cn, err := s.get()
+	if err != nil {
+		return err
+	}
+	defer s.put(cn)
+	for _, i := range indexes {
+		p := req(keys[i])
+		// the opaque of each request is echoed in its response, and identifies its key
+		p.opaque = uint32(i)
+		if err = cn.write(p); err != nil {
+			return err
+		}
+	}
+	if err = cn.write(&packet{opcode: opNoop}); err != nil {
+		return err
+	}
+	if err = cn.flush(); err != nil {
+		return err
+	}
+	var ferr error
+	for {
+		resp, err := cn.read()
+		if err != nil {
+			return err
+		}
+		if resp.opcode == opNoop {
+			return ferr
+		}
+		if int(resp.opaque) >= len(keys) {
+			continue
+		}
+		if err = handle(int(resp.opaque), resp); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+}
+
+// storageKey returns the key at which an object is stored in memcached. Keys longer than
+// memcached's key length limit are replaced with their SHA-256 hash
+func storageKey(cacheKey string) string {
+	if len(cacheKey) <= maxKeyLength {
+		return cacheKey
+	}
+	h := sha256.Sum256([]byte(cacheKey))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,557 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	mo "github.com/tricksterproxy/trickster/pkg/cache/memcached/options"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/selfsigned"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "memcached"
+const cacheKey = "cacheKey"
+
+type testItem struct {
+	value      []byte
+	expiration uint32
+}
+
+// testMemcached is an in-memory memcached server speaking the binary protocol
+type testMemcached struct {
+	mtx      sync.Mutex
+	items    map[string]testItem
+	l        net.Listener
+	username string
+	password string
+	// maxItemSize is the largest value accepted by the server. 0 means no limit
+	maxItemSize int
+}
+
+func newTestMemcached(tc *tls.Config) *testMemcached {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	if tc != nil {
+		l = tls.NewListener(l, tc)
+	}
+	s := &testMemcached{items: make(map[string]testItem), l: l}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+func (s *testMemcached) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *testMemcached) close() {
+	s.l.Close()
+}
+
+func (s *testMemcached) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	s.mtx.Lock()
+	username, password := s.username, s.password
+	s.mtx.Unlock()
+	authed := username == ""
+	for {
+		h := make([]byte, headerSize)
+		if _, err := io.ReadFull(r, h); err != nil || h[0] != magicRequest {
+			return
+		}
+		keyLen := int(binary.BigEndian.Uint16(h[2:4]))
+		extrasLen := int(h[4])
+		body := make([]byte, binary.BigEndian.Uint32(h[8:12]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		req := &packet{opcode: h[1], opaque: binary.BigEndian.Uint32(h[12:16]),
+			extras: body[:extrasLen], key: body[extrasLen : extrasLen+keyLen],
+			value: body[extrasLen+keyLen:]}
+		var resp *packet
+		if req.opcode == opSASLAuth {
+			resp = &packet{status: statusAuthError}
+			if string(req.key) == "PLAIN" &&
+				string(req.value) == "\x00"+username+"\x00"+password {
+				authed = true
+				resp.status = statusOK
+			}
+		} else if !authed {
+			resp = &packet{status: statusAuthError}
+		} else {
+			resp = s.handle(req)
+		}
+		if resp == nil {
+			continue
+		}
+		resp.opcode = req.opcode
+		resp.opaque = req.opaque
+		o := make([]byte, headerSize)
+		o[0] = magicResponse
+		o[1] = resp.opcode
+		binary.BigEndian.PutUint16(o[2:4], uint16(len(resp.key)))
+		o[4] = byte(len(resp.extras))
+		binary.BigEndian.PutUint16(o[6:8], resp.status)
+		binary.BigEndian.PutUint32(o[8:12], uint32(len(resp.extras)+len(resp.key)+len(resp.value)))
+		binary.BigEndian.PutUint32(o[12:16], resp.opaque)
+		o = append(append(append(o, resp.extras...), resp.key...), resp.value...)
+		if _, err := nc.Write(o); err != nil {
+			return
+		}
+	}
+}
+
+// handle returns the response to a request, or nil for a quiet request that succeeded
+func (s *testMemcached) handle(req *packet) *packet {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	k := string(req.key)
+	quiet := req.opcode == opGetKQ || req.opcode == opSetQ || req.opcode == opDeleteQ
+	switch req.opcode {
+	case opNoop:
+		return &packet{}
+	case opGet, opGetKQ:
+		it, ok := s.items[k]
+		if !ok {
+			if quiet {
+				return nil
+			}
+			return &packet{status: statusKeyNotFound, value: []byte("Not found")}
+		}
+		resp := &packet{extras: make([]byte, 4), value: it.value}
+		if req.opcode == opGetKQ {
+			resp.key = req.key
+		}
+		return resp
+	case opSet, opSetQ:
+		if s.maxItemSize > 0 && len(req.value) > s.maxItemSize {
+			return &packet{status: statusTooLarge, value: []byte("Too large")}
+		}
+		v := make([]byte, len(req.value))
+		copy(v, req.value)
+		s.items[k] = testItem{value: v, expiration: binary.BigEndian.Uint32(req.extras[4:8])}
+	case opDelete, opDeleteQ:
+		if _, ok := s.items[k]; !ok {
+			return &packet{status: statusKeyNotFound, value: []byte("Not found")}
+		}
+		delete(s.items, k)
+	case opTouch:
+		it, ok := s.items[k]
+		if !ok {
+			return &packet{status: statusKeyNotFound, value: []byte("Not found")}
+		}
+		it.expiration = binary.BigEndian.Uint32(req.extras)
+		s.items[k] = it
+	default:
+		return &packet{status: 0x81, value: []byte("Unknown command")}
+	}
+	if quiet {
+		return nil
+	}
+	return &packet{}
+}
+
+func (s *testMemcached) item(key string) (testItem, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	it, ok := s.items[key]
+	return it, ok
+}
+
+func newCache(servers ...*testMemcached) *Cache {
+	cacheConfig := &co.Options{CacheType: cacheType, Memcached: mo.NewOptions()}
+	cacheConfig.Memcached.Servers = make([]string, len(servers))
+	for i, s := range servers {
+		cacheConfig.Memcached.Servers[i] = s.addr()
+	}
+	return &Cache{Name: "test", Config: cacheConfig, Logger: tl.ConsoleLogger("error"),
+		locker: locks.NewNamedLocker()}
+}
+
+func TestConfiguration(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	cfg := c.Configuration()
+	if cfg.CacheType != cacheType {
+		t.Fatalf("expected %s got %s", cacheType, cfg.CacheType)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	c := Cache{locker: locks.NewNamedLocker()}
+	l := c.Locker()
+	c.SetLocker(locks.NewNamedLocker())
+	if l == c.Locker() {
+		t.Errorf("error setting locker")
+	}
+}
+
+func TestConnect(t *testing.T) {
+	s := newTestMemcached(nil)
+	c := newCache(s)
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+	c.Close()
+
+	// it should fail when a server is unreachable
+	s.close()
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unreachable server")
+	}
+}
+
+func TestConnectAuth(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	s.mtx.Lock()
+	s.username = "user"
+	s.password = "pass"
+	s.mtx.Unlock()
+
+	c := newCache(s)
+	c.Config.Memcached.Username = "user"
+	c.Config.Memcached.Password = "wrong"
+	if err := c.Connect(); err == nil {
+		t.Error("expected authentication error")
+	}
+
+	c.Config.Memcached.Password = "pass"
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	data, _, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+}
+
+func TestConnectTLS(t *testing.T) {
+	certPEM, keyPEM, err := selfsigned.Generate([]string{"127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMemcached(&tls.Config{Certificates: []tls.Certificate{cert}})
+	defer s.close()
+
+	f, err := ioutil.TempFile("", "trickster-memcached-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(certPEM)
+	f.Close()
+
+	c := newCache(s)
+	c.Config.Memcached.UseTLS = true
+
+	// it should fail when the server's certificate is not trusted
+	if err := c.Connect(); err == nil {
+		t.Error("expected certificate verification error")
+	}
+
+	c.Config.Memcached.CertificateAuthorityPaths = []string{f.Name()}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	c.Config.Memcached.CertificateAuthorityPaths = []string{"/nonexistent/ca.pem"}
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
+
+func TestStoreRetrieve(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// it should be a cache miss
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	if err = c.Store(cacheKey, []byte("data"), 60*time.Second); err != nil {
+		t.Error(err)
+	}
+	if it, _ := s.item(cacheKey); it.expiration != 60 {
+		t.Errorf("expected %d got %d", 60, it.expiration)
+	}
+
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// it should reject objects larger than the server's item size limit
+	s.mtx.Lock()
+	s.maxItemSize = 10
+	s.mtx.Unlock()
+	if err = c.Store(cacheKey, []byte(strings.Repeat("x", 11)), time.Minute); err != ErrTooLarge {
+		t.Errorf("expected %v got %v", ErrTooLarge, err)
+	}
+}
+
+func TestStoreLongKey(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	k := strings.Repeat("k", maxKeyLength+1)
+	if err := c.Store(k, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if _, ok := s.item(storageKey(k)); !ok || len(storageKey(k)) > maxKeyLength {
+		t.Errorf("expected hashed storage key for %d-byte key", len(k))
+	}
+	data, _, err := c.Retrieve(k, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	c.Config.Encryption = eo.NewOptions()
+	c.Config.Encryption.ActiveKeyID = "k1"
+	c.Config.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := c.Config.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if it, _ := s.item(cacheKey); strings.Contains(string(it.value), "data") {
+		t.Error("expected the stored value to be encrypted")
+	}
+	data, _, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.SetTTL(cacheKey, time.Hour)
+	if it, _ := s.item(cacheKey); it.expiration != 3600 {
+		t.Errorf("expected %d got %d", 3600, it.expiration)
+	}
+
+	// it should not fail for a missing key
+	c.SetTTL("missing", time.Hour)
+}
+
+func TestRemove(t *testing.T) {
+	s := newTestMemcached(nil)
+	defer s.close()
+	c := newCache(s)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.Remove(cacheKey)
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+	// it should not fail for a missing key
+	c.Remove(cacheKey)
+}
+
+func TestBulkOperations(t *testing.T) {
+	s1 := newTestMemcached(nil)
+	defer s1.close()
+	s2 := newTestMemcached(nil)
+	defer s2.close()
+	c := newCache(s1, s2)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	objects := make(map[string][]byte)
+	keys := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		k := cacheKey + strings.Repeat(".", i%7) + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		objects[k] = []byte("data-" + k)
+		keys = append(keys, k)
+	}
+
+	if err := c.BulkStore(objects, time.Minute); err != nil {
+		t.Error(err)
+	}
+
+	// the objects should be distributed across both servers
+	s1.mtx.Lock()
+	n1 := len(s1.items)
+	s1.mtx.Unlock()
+	if n1 == 0 || n1 == len(objects) {
+		t.Errorf("expected objects on both servers, got %d of %d on the first", n1, len(objects))
+	}
+
+	out, err := c.BulkRetrieve(append(keys, "missing"))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != len(objects) {
+		t.Errorf("expected %d got %d", len(objects), len(out))
+	}
+	for k, v := range objects {
+		if string(out[k]) != string(v) {
+			t.Errorf("wanted \"%s\". got \"%s\".", v, out[k])
+		}
+	}
+
+	remove := append([]string{"missing"}, keys[:100]...)
+	c.BulkRemove(remove)
+	out, err = c.BulkRetrieve(keys)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != len(objects)-100 {
+		t.Errorf("expected %d got %d", len(objects)-100, len(out))
+	}
+
+	// it should return the error of a rejected object
+	for _, s := range []*testMemcached{s1, s2} {
+		s.mtx.Lock()
+		s.maxItemSize = 1
+		s.mtx.Unlock()
+	}
+	if err := c.BulkStore(objects, time.Minute); err != ErrTooLarge {
+		t.Errorf("expected %v got %v", ErrTooLarge, err)
+	}
+}
+
+func TestNotConnected(t *testing.T) {
+	c := newCache()
+	if _, _, err := c.Retrieve(cacheKey, false); err == nil {
+		t.Error("expected error for unconnected cache")
+	}
+	if _, err := c.BulkRetrieve([]string{cacheKey}); err == nil {
+		t.Error("expected error for unconnected cache")
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	tests := []struct {
+		ttl      time.Duration
+		expected uint32
+	}{
+		{time.Minute, 60},
+		{500 * time.Millisecond, 1},
+		{0, 0},
+		{31 * 24 * time.Hour, uint32(now.Add(31 * 24 * time.Hour).Unix())},
+	}
+	for i, test := range tests {
+		if e := expiration(now, test.ttl); e != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, e)
+		}
+	}
+}
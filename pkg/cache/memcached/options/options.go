@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrMissingServers is returned when no servers are configured
+var ErrMissingServers = errors.New("memcached servers must be provided")
+
+// ErrInvalidServer is returned when a server is not a host:port address
+var ErrInvalidServer = errors.New("memcached servers must be host:port addresses")
+
+// ErrInvalidVirtualNodes is returned when the number of virtual nodes is not positive
+var ErrInvalidVirtualNodes = errors.New("memcached virtual_nodes must be greater than 0")
+
+// ErrInvalidCredentials is returned when only one of the username and password is provided
+var ErrInvalidCredentials = errors.New("memcached username and password must be provided together")
+
+// ErrInvalidClientCert is returned when only one of the client cert and key paths is provided
+var ErrInvalidClientCert = errors.New(
+	"memcached client_cert_path and client_key_path must be provided together")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("memcached timeout_ms must be greater than 0")
+
+// ErrInvalidMaxIdleConns is returned when the idle connection limit is negative
+var ErrInvalidMaxIdleConns = errors.New("memcached max_idle_conns must not be negative")
+
+// Options is a collection of Configurations for storing cached data in Memcached
+type Options struct {
+	// Servers is the list of host:port addresses of the Memcached servers, across which
+	// objects are distributed by consistent hashing
+	Servers []string `toml:"servers"`
+	// VirtualNodes is the number of points each server is given on the consistent hash ring.
+	// More points spread objects more evenly across the servers
+	VirtualNodes int `toml:"virtual_nodes"`
+	// Username is the SASL PLAIN username. When empty, connections are not authenticated
+	Username string `toml:"username"`
+	// Password is the SASL PLAIN password
+	Password string `toml:"password"`
+	// UseTLS indicates that connections to the servers are made over TLS
+	UseTLS bool `toml:"use_tls"`
+	// InsecureSkipVerify indicates that the servers' certificates are not verified
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// CertificateAuthorityPaths provides a list of custom Certificate Authorities for the
+	// servers, which are considered in addition to any system CA's
+	CertificateAuthorityPaths []string `toml:"certificate_authority_paths"`
+	// ServerName overrides the server name used to verify the servers' certificates. The
+	// host of each server's address is used by default
+	ServerName string `toml:"server_name"`
+	// ClientCertPath provides the path to the Client Certificate when using Mutual Authorization
+	ClientCertPath string `toml:"client_cert_path"`
+	// ClientKeyPath provides the path to the Client Key when using Mutual Authorization
+	ClientKeyPath string `toml:"client_key_path"`
+	// MaxIdleConns is the most idle connections held open to each server
+	MaxIdleConns int `toml:"max_idle_conns"`
+	// TimeoutMS is the timeout of connecting to a server, and of each request to it
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new Memcached Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Servers:      []string{d.DefaultMemcachedServer},
+		VirtualNodes: d.DefaultMemcachedVirtualNodes,
+		MaxIdleConns: d.DefaultMemcachedMaxIdleConns,
+		TimeoutMS:    d.DefaultMemcachedTimeoutMS,
+		Timeout:      time.Duration(d.DefaultMemcachedTimeoutMS) * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if len(o.Servers) == 0 {
+		return ErrMissingServers
+	}
+	for _, s := range o.Servers {
+		if _, port, err := net.SplitHostPort(s); err != nil || port == "" {
+			return ErrInvalidServer
+		}
+	}
+	if o.VirtualNodes <= 0 {
+		return ErrInvalidVirtualNodes
+	}
+	if (o.Username == "") != (o.Password == "") {
+		return ErrInvalidCredentials
+	}
+	if (o.ClientCertPath == "") != (o.ClientKeyPath == "") {
+		return ErrInvalidClientCert
+	}
+	if o.MaxIdleConns < 0 {
+		return ErrInvalidMaxIdleConns
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
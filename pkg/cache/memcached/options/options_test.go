@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Error("expected non-nil options")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		servers  []string
+		vnodes   int
+		username string
+		password string
+		certPath string
+		idle     int
+		timeout  int
+		expected error
+	}{
+		{[]string{"localhost:11211"}, 160, "", "", "", 4, 1000, nil},
+		{[]string{"a:11211", "b:11211"}, 40, "user", "pass", "", 0, 1000, nil},
+		{nil, 160, "", "", "", 4, 1000, ErrMissingServers},
+		{[]string{"localhost"}, 160, "", "", "", 4, 1000, ErrInvalidServer},
+		{[]string{"localhost:11211"}, 0, "", "", "", 4, 1000, ErrInvalidVirtualNodes},
+		{[]string{"localhost:11211"}, 160, "user", "", "", 4, 1000, ErrInvalidCredentials},
+		{[]string{"localhost:11211"}, 160, "", "", "cert.pem", 4, 1000, ErrInvalidClientCert},
+		{[]string{"localhost:11211"}, 160, "", "", "", -1, 1000, ErrInvalidMaxIdleConns},
+		{[]string{"localhost:11211"}, 160, "", "", "", 4, 0, ErrInvalidTimeout},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.Servers = test.servers
+		o.VirtualNodes = test.vnodes
+		o.Username = test.username
+		o.Password = test.password
+		o.ClientCertPath = test.certPath
+		o.MaxIdleConns = test.idle
+		o.TimeoutMS = test.timeout
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.TimeoutMS = 250
+	o.SetDurations()
+	if o.Timeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.Timeout)
+	}
+}
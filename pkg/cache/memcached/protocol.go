@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// the memcached binary protocol opcodes used by the cache
+const (
+	opGet      = 0x00
+	opSet      = 0x01
+	opDelete   = 0x04
+	opNoop     = 0x0a
+	opGetKQ    = 0x0d
+	opSetQ     = 0x11
+	opDeleteQ  = 0x14
+	opTouch    = 0x1c
+	opSASLAuth = 0x21
+)
+
+// the memcached binary protocol response statuses handled by the cache
+const (
+	statusOK          = 0x00
+	statusKeyNotFound = 0x01
+	statusTooLarge    = 0x03
+	statusAuthError   = 0x20
+)
+
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+	headerSize    = 24
+	// maxKeyLength is the longest key accepted by memcached
+	maxKeyLength = 250
+	// maxRelativeExpiration is the longest expiration memcached interprets as relative to
+	// now; longer expirations are interpreted as a unix timestamp
+	maxRelativeExpiration = 30 * 24 * time.Hour
+)
+
+// ErrNotFound is the status returned by memcached when a key is not found
+var ErrNotFound = errors.New("memcached key not found")
+
+// ErrTooLarge is the status returned by memcached when an object exceeds its item size limit
+var ErrTooLarge = errors.New("object exceeds the memcached item size limit")
+
+// statusError is a non-OK status returned by memcached
+type statusError struct {
+	status uint16
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("memcached error status 0x%02x: %s", e.status, e.msg)
+}
+
+// errorForStatus returns the error represented by a response status, or nil if it is OK
+func errorForStatus(status uint16, body []byte) error {
+	switch status {
+	case statusOK:
+		return nil
+	case statusKeyNotFound:
+		return ErrNotFound
+	case statusTooLarge:
+		return ErrTooLarge
+	}
+	return &statusError{status: status, msg: string(body)}
+}
+
+// packet is a memcached binary protocol request or response
+type packet struct {
+	opcode byte
+	status uint16
+	opaque uint32
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+// conn is a connection to a memcached server
+type conn struct {
+	nc      net.Conn
+	rw      *bufio.ReadWriter
+	timeout time.Duration
+	// broken is set when the connection is in an unknown state, and can't be reused
+	broken bool
+}
+
+func newConn(nc net.Conn, timeout time.Duration) *conn {
+	return &conn{nc: nc, timeout: timeout,
+		rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}
+}
+
+// write buffers a request, to be sent with the next flush
+func (c *conn) write(p *packet) error {
+	h := make([]byte, headerSize)
+	h[0] = magicRequest
+	h[1] = p.opcode
+	binary.BigEndian.PutUint16(h[2:4], uint16(len(p.key)))
+	h[4] = byte(len(p.extras))
+	binary.BigEndian.PutUint32(h[8:12], uint32(len(p.extras)+len(p.key)+len(p.value)))
+	binary.BigEndian.PutUint32(h[12:16], p.opaque)
+	for _, b := range [][]byte{h, p.extras, p.key, p.value} {
+		if _, err := c.rw.Write(b); err != nil {
+			c.broken = true
+			return err
+		}
+	}
+	return nil
+}
+
+// flush sends the buffered requests
+func (c *conn) flush() error {
+	c.nc.SetDeadline(time.Now().Add(c.timeout))
+	if err := c.rw.Flush(); err != nil {
+		c.broken = true
+		return err
+	}
+	return nil
+}
+
+// read reads the next response
+func (c *conn) read() (*packet, error) {
+	h := make([]byte, headerSize)
+	if _, err := io.ReadFull(c.rw, h); err != nil {
+		c.broken = true
+		return nil, err
+	}
+	if h[0] != magicResponse {
+		c.broken = true
+		return nil, fmt.Errorf("invalid memcached response magic 0x%02x", h[0])
+	}
+	keyLen := int(binary.BigEndian.Uint16(h[2:4]))
+	extrasLen := int(h[4])
+	bodyLen := int(binary.BigEndian.Uint32(h[8:12]))
+	if keyLen+extrasLen > bodyLen {
+		c.broken = true
+		return nil, errors.New("invalid memcached response length")
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(c.rw, body); err != nil {
+		c.broken = true
+		return nil, err
+	}
+	return &packet{
+		opcode: h[1],
+		status: binary.BigEndian.Uint16(h[6:8]),
+		opaque: binary.BigEndian.Uint32(h[12:16]),
+		extras: body[:extrasLen],
+		key:    body[extrasLen : extrasLen+keyLen],
+		value:  body[extrasLen+keyLen:],
+	}, nil
+}
+
+// roundTrip sends a single request and returns its response
+func (c *conn) roundTrip(p *packet) (*packet, error) {
+	if err := c.write(p); err != nil {
+		return nil, err
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// authenticate authenticates the connection with the SASL PLAIN mechanism
+func (c *conn) authenticate(username, password string) error {
+	resp, err := c.roundTrip(&packet{opcode: opSASLAuth, key: []byte("PLAIN"),
+		value: []byte("\x00" + username + "\x00" + password)})
+	if err != nil {
+		return err
+	}
+	if resp.status == statusAuthError {
+		return errors.New("memcached authentication failed")
+	}
+	return errorForStatus(resp.status, resp.value)
+}
+
+// expiration returns the expiration field of a request with the ttl, which memcached
+// requires as a unix timestamp when longer than 30 days
+func expiration(now time.Time, ttl time.Duration) uint32 {
+	if ttl > maxRelativeExpiration {
+		return uint32(now.Add(ttl).Unix())
+	}
+	secs := uint32(ttl / time.Second)
+	if secs == 0 && ttl > 0 {
+		// 0 means never expire, so sub-second ttls are rounded up
+		secs = 1
+	}
+	return secs
+}
+
+// setExtras returns the extras of a set request, which are the flags and expiration
+func setExtras(now time.Time, ttl time.Duration) []byte {
+	e := make([]byte, 8)
+	binary.BigEndian.PutUint32(e[4:8], expiration(now, ttl))
+	return e
+}
+
+// touchExtras returns the extras of a touch request, which is the expiration
+func touchExtras(now time.Time, ttl time.Duration) []byte {
+	e := make([]byte, 4)
+	binary.BigEndian.PutUint32(e, expiration(now, ttl))
+	return e
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// ring distributes keys across servers by consistent hashing, so that adding or removing a
+// server only moves the keys of the ring segments it gains or loses. Each server is given
+// a number of virtual nodes on the ring, which spreads keys evenly across the servers
+type ring struct {
+	points  []uint32
+	servers map[uint32]*server
+}
+
+func newRing(servers []*server, virtualNodes int) *ring {
+	r := &ring{
+		points:  make([]uint32, 0, len(servers)*virtualNodes),
+		servers: make(map[uint32]*server, len(servers)*virtualNodes),
+	}
+	for _, s := range servers {
+		for i := 0; i < virtualNodes; i++ {
+			p := hash(s.addr + "-" + strconv.Itoa(i))
+			// on the rare collision of two points, the first server keeps the point
+			if _, ok := r.servers[p]; ok {
+				continue
+			}
+			r.servers[p] = s
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// get returns the server holding the key, which is the server of the first point on the
+// ring at or after the key's hash
+func (r *ring) get(key string) *server {
+	if r == nil || len(r.points) == 0 {
+		return nil
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.servers[r.points[i]]
+}
+
+func hash(s string) uint32 {
+	h := md5.Sum([]byte(s))
+	return binary.LittleEndian.Uint32(h[:4])
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+
+	servers := []*server{{addr: "a:11211"}, {addr: "b:11211"}, {addr: "c:11211"}}
+	r := newRing(servers, 160)
+	if len(r.points) != 480 {
+		t.Errorf("expected %d got %d", 480, len(r.points))
+	}
+
+	counts := make(map[*server]int)
+	assigned := make(map[string]*server)
+	for i := 0; i < 3000; i++ {
+		k := "key" + strconv.Itoa(i)
+		s := r.get(k)
+		counts[s]++
+		assigned[k] = s
+		if r.get(k) != s {
+			t.Errorf("expected consistent server for %s", k)
+		}
+	}
+	for _, s := range servers {
+		if counts[s] < 500 {
+			t.Errorf("expected an even distribution, got %d keys on %s", counts[s], s.addr)
+		}
+	}
+
+	// removing a server should only move the keys it held
+	r2 := newRing(servers[:2], 160)
+	for k, s := range assigned {
+		if s != servers[2] && r2.get(k) != s {
+			t.Errorf("expected %s to remain on %s", k, s.addr)
+		}
+	}
+
+	if newRing(nil, 160).get("key") != nil {
+		t.Error("expected nil server for empty ring")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// server is a memcached server, and the pool of idle connections to it
+type server struct {
+	addr      string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	timeout   time.Duration
+	idle      chan *conn
+}
+
+// get returns an idle connection to the server, or a new one if none are idle
+func (s *server) get() (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	return s.dial()
+}
+
+// put returns a connection to the pool of idle connections, or closes it if the pool is
+// full or the connection can't be reused
+func (s *server) put(c *conn) {
+	if c.broken {
+		c.nc.Close()
+		return
+	}
+	select {
+	case s.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+// dial opens and authenticates a new connection to the server
+func (s *server) dial() (*conn, error) {
+	d := &net.Dialer{Timeout: s.timeout}
+	var nc net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		nc, err = tls.DialWithDialer(d, "tcp", s.addr, s.tlsConfig)
+	} else {
+		nc, err = d.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := newConn(nc, s.timeout)
+	if s.username != "" {
+		if err = c.authenticate(s.username, s.password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// close closes the server's idle connections
+func (s *server) close() {
+	for {
+		select {
+		case c := <-s.idle:
+			c.nc.Close()
+		default:
+			return
+		}
+	}
+}
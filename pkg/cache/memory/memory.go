@@ -69,11 +69,22 @@ func (c *Cache) Connect() error {
 
 // StoreReference stores an object directly to the memory cache without requiring serialization
 func (c *Cache) StoreReference(cacheKey string, data cache.ReferenceObject, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	return c.store(cacheKey, nil, data, ttl, true)
 }
 
 // Store places an object in the cache using the specified key and ttl
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	// the caller may reuse data once Store returns, so a copy is retained. compressed
+	// values never share memory with data, so they are retained as they are
+	if c.Config.CompressionCodec != "" {
+		data = cache.Compress(c.Config, data)
+	} else if data != nil {
+		b := make([]byte, len(data))
+		copy(b, data)
+		data = b
+	}
 	return c.store(cacheKey, data, nil, ttl, true)
 }
 
@@ -128,7 +139,11 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 		return nil, s, err
 	}
 	if o != nil {
-		return o.Value, s, nil
+		b, err := cache.Decompress(c.Config, o.Value)
+		if err != nil {
+			return nil, status.LookupStatusError, err
+		}
+		return b, s, nil
 	}
 	return nil, s, nil
 }
@@ -147,7 +162,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) (*index
 		if allowExpired || o.Expiration.IsZero() || o.Expiration.After(time.Now()) {
 			c.Logger.Debug("memory cache retrieve", tl.Pairs{"cacheKey": cacheKey})
 			if atime {
-				go c.Index.UpdateObjectAccessTime(cacheKey)
+				c.Index.QueueObjectAccess(cacheKey)
 			}
 			metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(o.Value)))
 			return o, status.LookupStatusHit, nil
@@ -161,6 +176,7 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) (*index
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
 }
 
@@ -179,6 +195,33 @@ func (c *Cache) remove(cacheKey string, isBulk bool) {
 	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
 }
 
+// Keys returns the keys of the cached objects that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	return c.Index.Keys(prefix), nil
+}
+
+// Inspect returns the statistics of the cached objects, including the topN largest and
+// most-accessed objects
+func (c *Cache) Inspect(topN int) *cache.Stats {
+	return c.Index.Stats(topN, time.Now())
+}
+
+// InspectObject returns the statistics of the object cached at the key, or nil if the key
+// is not cached
+func (c *Cache) InspectObject(cacheKey string) *cache.ObjectStats {
+	return c.Index.ObjectStats(cacheKey, time.Now())
+}
+
+// SetTags associates the object cached at the key with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	c.Index.SetTags(cacheKey, tags)
+}
+
+// TaggedKeys returns the keys of the cached objects that are associated with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	return c.Index.TaggedKeys(tag), nil
+}
+
 // BulkRemove removes a list of objects from the cache
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg := &sync.WaitGroup{}
@@ -141,6 +141,18 @@ func TestCache_Store(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+
+	// it should not retain the caller's slice
+	b := []byte("data")
+	err = mc.Store(cacheKey, b, time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	copy(b, "xxxx")
+	data, _, _ := mc.Retrieve(cacheKey, false)
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
 }
 
 func BenchmarkCache_Store(b *testing.B) {
@@ -434,6 +446,42 @@ func TestMemoryCache_SetTTL(t *testing.T) {
 
 }
 
+func TestMemoryCache_ClampTTL(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.MaxTTL = time.Duration(300) * time.Second
+	cacheConfig.MinTTL = time.Duration(30) * time.Second
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mc.Close()
+
+	// a year-long ttl should be clamped to MaxTTL
+	err = mc.Store(cacheKey, []byte("data"), time.Duration(31536000)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	e := int(time.Until(mc.Index.GetExpiration(cacheKey)).Seconds())
+	if e > 300 || e < 290 {
+		t.Errorf("expected ttl of ~%d, got %d", 300, e)
+	}
+
+	// a 1s ttl should be raised to MinTTL
+	mc.SetTTL(cacheKey, time.Second)
+
+	time.Sleep(time.Millisecond * 10)
+
+	e = int(time.Until(mc.Index.GetExpiration(cacheKey)).Seconds())
+	if e > 30 || e < 20 {
+		t.Errorf("expected ttl of ~%d, got %d", 30, e)
+	}
+
+}
+
 func BenchmarkCache_SetTTL(b *testing.B) {
 	mc := storeBenchmark(b)
 
@@ -472,3 +520,86 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestCache_Keys(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mc.Close()
+
+	for _, k := range []string{"a.dpc.1", "a.opc.1", "b.dpc.1"} {
+		if err = mc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+
+	keys, err := mc.Keys("a.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 2 || keys[0] != "a.dpc.1" || keys[1] != "a.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a.dpc.1", "a.opc.1"}, keys)
+	}
+}
+
+func TestCache_TaggedKeys(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mc.Close()
+
+	for _, k := range []string{"a.opc.1", "a.opc.2", "b.opc.1"} {
+		if err = mc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+	mc.SetTags("a.opc.2", []string{"tenant-a"})
+	mc.SetTags("b.opc.1", []string{"tenant-a", "tenant-b"})
+
+	keys, err := mc.TaggedKeys("tenant-a")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 2 || keys[0] != "a.opc.2" || keys[1] != "b.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a.opc.2", "b.opc.1"}, keys)
+	}
+}
+
+func TestCache_StoreCompressed(t *testing.T) {
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.CompressionCodec = "snappy"
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// it should not retain the caller's slice
+	b := []byte("datadatadatadata")
+	err = mc.Store(cacheKey, b, time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	copy(b, "xxxx")
+	data, ls, err := mc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if string(data) != "datadatadatadata" {
+		t.Errorf("expected %s got %s", "datadatadatadata", string(data))
+	}
+}
@@ -18,6 +18,9 @@ package metrics
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
@@ -38,8 +41,45 @@ func CacheError(cacheKey, cacheName, cacheType string, msg string) ([]byte, erro
 	return nil, fmt.Errorf(msg, cacheKey)
 }
 
+// lookupCounts tallies the hits and misses of a cache's get operations
+type lookupCounts struct {
+	hits   int64
+	misses int64
+}
+
+// lookups holds the *lookupCounts of each cache, by cache name
+var lookups sync.Map
+
+// Lookups returns the number of the named cache's get operations that were hits and misses
+// since the process started
+func Lookups(cache string) (int64, int64) {
+	v, ok := lookups.Load(cache)
+	if !ok {
+		return 0, 0
+	}
+	lc := v.(*lookupCounts)
+	return atomic.LoadInt64(&lc.hits), atomic.LoadInt64(&lc.misses)
+}
+
+func observeLookup(cache, status string) {
+	v, ok := lookups.Load(cache)
+	if !ok {
+		v, _ = lookups.LoadOrStore(cache, &lookupCounts{})
+	}
+	lc := v.(*lookupCounts)
+	switch {
+	case strings.HasSuffix(status, "hit"):
+		atomic.AddInt64(&lc.hits, 1)
+	case status == "miss":
+		atomic.AddInt64(&lc.misses, 1)
+	}
+}
+
 // ObserveCacheOperation increments counters as cache operations occur
 func ObserveCacheOperation(cache, cacheType, operation, status string, bytes float64) {
+	if operation == "get" {
+		observeLookup(cache, status)
+	}
 	metrics.CacheObjectOperations.WithLabelValues(cache, cacheType, operation, status).Inc()
 	if bytes > 0 {
 		metrics.CacheByteOperations.WithLabelValues(cache, cacheType, operation, status).Add(bytes)
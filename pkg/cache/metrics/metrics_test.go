@@ -56,3 +56,17 @@ func TestObserveCacheEvent(t *testing.T) {
 func TestObserveCacheSizeChange(t *testing.T) {
 	ObserveCacheSizeChange(testCacheName, testCacheType, 0, 0)
 }
+
+func TestLookups(t *testing.T) {
+	const name = "test-lookups"
+	if h, m := Lookups(name); h != 0 || m != 0 {
+		t.Errorf("expected %d/%d got %d/%d", 0, 0, h, m)
+	}
+	ObserveCacheOperation(name, testCacheType, "get", "hit", 1)
+	ObserveCacheOperation(name, testCacheType, "get", "l2_hit", 1)
+	ObserveCacheMiss(testCacheKey, name, testCacheType)
+	ObserveCacheOperation(name, testCacheType, "set", "none", 1)
+	if h, m := Lookups(name); h != 2 || m != 1 {
+		t.Errorf("expected %d/%d got %d/%d", 2, 1, h, m)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package migration is the Migration implementation of the Trickster Cache. It writes
+// to both an old and a new persistent cache and reads from the new cache before the old,
+// so that operators can move a cache from one type to another without a cold cache
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Cache represents a Migration cache object that conforms to the Cache interface. Objects
+// are written to both caches, and objects read only from the old cache are optionally
+// backfilled into the new cache. Once the old cache no longer serves hits, it can be
+// dropped by configuring the new cache's type directly
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	// Old is the cache being migrated from
+	Old cache.Cache
+	// New is the cache being migrated to, which is the cache of record
+	New    cache.Cache
+	locker locks.NamedLocker
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect connects both the old and new caches
+func (c *Cache) Connect() error {
+	o := c.Config.Migration
+	c.Logger.Info("migration cache setup", tl.Pairs{"name": c.Name,
+		"oldCacheType": o.OldCacheType, "newCacheType": o.NewCacheType})
+	for _, cc := range []cache.Cache{c.New, c.Old} {
+		if cc.Locker() == nil {
+			cc.SetLocker(locks.NewNamedLocker())
+		}
+		if err := cc.Connect(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store places the object in both caches using the provided key and ttl. The new cache is
+// written first and is the cache of record, so a failure to write to the old cache is
+// observed but not returned
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	if err := c.New.Store(cacheKey, data, ttl); err != nil {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "dual_write", "new_failed")
+		// ensure a stale copy is not served by the new cache after a failed overwrite
+		c.New.Remove(cacheKey)
+		return err
+	}
+	if err := c.Old.Store(cacheKey, data, ttl); err != nil {
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "dual_write", "old_failed")
+		c.Logger.Debug("migration cache could not write to old cache",
+			tl.Pairs{"name": c.Name, "cacheKey": cacheKey, "detail": err.Error()})
+	}
+	return nil
+}
+
+// Retrieve looks for an object in the new cache, and then in the old cache, and returns
+// it (or an error if not found). The ratio of new_hit to old_hit operations shows the
+// progress of the migration. Objects found only in the old cache are backfilled into the
+// new cache for the configured backfill TTL
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if data, ls, err := c.New.Retrieve(cacheKey, allowExpired); err == nil {
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "new_hit", float64(len(data)))
+		return data, ls, nil
+	}
+	data, ls, err := c.Old.Retrieve(cacheKey, allowExpired)
+	if err != nil {
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, ls, err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "old_hit", float64(len(data)))
+	// an object retrieved while allowing expiration may be expired, so it is not backfilled
+	if ttl := c.Config.Migration.BackfillTTL; ttl > 0 && !allowExpired {
+		if c.New.Store(cacheKey, data, ttl) == nil {
+			metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "backfill", "old_hit")
+		}
+	}
+	return data, ls, nil
+}
+
+// SetTTL updates the TTL of the object in both caches
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.New.SetTTL(cacheKey, ttl)
+	c.Old.SetTTL(cacheKey, ttl)
+}
+
+// Remove removes the object from both caches
+func (c *Cache) Remove(cacheKey string) {
+	c.New.Remove(cacheKey)
+	c.Old.Remove(cacheKey)
+}
+
+// BulkRemove removes a list of objects from both caches
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.New.BulkRemove(cacheKeys)
+	c.Old.BulkRemove(cacheKeys)
+}
+
+// Keys returns the keys of the objects in either cache that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	keys := make(map[string]bool)
+	for i, cc := range []cache.Cache{c.New, c.Old} {
+		l, ok := cc.(cache.Lister)
+		if !ok {
+			return nil, fmt.Errorf("cache type %s can only invalidate objects by key",
+				c.cacheType(i))
+		}
+		k, err := l.Keys(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range k {
+			keys[key] = true
+		}
+	}
+	return sortedKeys(keys), nil
+}
+
+// SetTags associates the object in both caches with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	for _, cc := range []cache.Cache{c.New, c.Old} {
+		if tg, ok := cc.(cache.Tagger); ok {
+			tg.SetTags(cacheKey, tags)
+		}
+	}
+}
+
+// TaggedKeys returns the keys of the objects in either cache that are associated with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	keys := make(map[string]bool)
+	for i, cc := range []cache.Cache{c.New, c.Old} {
+		tg, ok := cc.(cache.Tagger)
+		if !ok {
+			return nil, fmt.Errorf("cache type %s can't invalidate objects by tag",
+				c.cacheType(i))
+		}
+		k, err := tg.TaggedKeys(tag)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range k {
+			keys[key] = true
+		}
+	}
+	return sortedKeys(keys), nil
+}
+
+// Close closes both caches
+func (c *Cache) Close() error {
+	errOld := c.Old.Close()
+	if err := c.New.Close(); err != nil {
+		return err
+	}
+	return errOld
+}
+
+// cacheType returns the configured type of the new (0) or old (1) cache
+func (c *Cache) cacheType(i int) string {
+	if i == 0 {
+		return c.Config.Migration.NewCacheType
+	}
+	return c.Config.Migration.OldCacheType
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package migration
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheKey = "cacheKey"
+
+var errStoreFailed = errors.New("store failed")
+
+// failingCache is a cache whose writes fail
+type failingCache struct {
+	*memory.Cache
+}
+
+func (c *failingCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	return errStoreFailed
+}
+
+// newTestCache returns a connected Migration Cache whose old and new caches are memory caches
+func newTestCache(t *testing.T) (*Cache, *memory.Cache, *memory.Cache) {
+	cfg := co.NewOptions()
+	cfg.Name = "test"
+	cfg.CacheType = "migration"
+	cfg.Index.ReapInterval = 0
+	cfg.Migration.OldCacheType = "bbolt"
+	cfg.Migration.NewCacheType = "logstore"
+
+	newMemoryCache := func() *memory.Cache {
+		mc := cfg.Clone()
+		mc.CacheType = "memory"
+		return &memory.Cache{Name: "test", Config: mc, Logger: tl.ConsoleLogger("error")}
+	}
+	oc, nc := newMemoryCache(), newMemoryCache()
+
+	c := &Cache{Name: "test", Config: cfg, Logger: tl.ConsoleLogger("error"), Old: oc, New: nc}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, oc, nc
+}
+
+func TestConfiguration(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+	if c.Configuration().CacheType != "migration" {
+		t.Errorf("expected %s got %s", "migration", c.Configuration().CacheType)
+	}
+	if c.Locker() == nil || oc.Locker() == nil || nc.Locker() == nil {
+		t.Error("expected non-nil locker")
+	}
+}
+
+func TestCache_StoreDualWrite(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for _, cc := range []cache.Cache{oc, nc} {
+		if b, _, err := cc.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+			t.Errorf("expected %s got %s: %v", "data", string(b), err)
+		}
+	}
+
+	b, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit || string(b) != "data" {
+		t.Errorf("expected %s got %s", "data", string(b))
+	}
+}
+
+func TestCache_StoreFailure(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	// a failed write to the old cache is not an error
+	c.Old = &failingCache{Cache: oc}
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	if b, _, err := nc.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+
+	// a failed write to the new cache is, and removes the new cache's stale copy
+	c.Old = oc
+	c.New = &failingCache{Cache: nc}
+	if err := c.Store(cacheKey, []byte("data2"), time.Minute); err != errStoreFailed {
+		t.Errorf("expected %v got %v", errStoreFailed, err)
+	}
+	if _, _, err := nc.Retrieve(cacheKey, false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
+func TestCache_RetrieveFallback(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	// objects are not backfilled unless a backfill ttl is configured
+	oc.Store(cacheKey, []byte("data"), time.Minute)
+	b, _, err := c.Retrieve(cacheKey, false)
+	if err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+	if _, _, err := nc.Retrieve(cacheKey, false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	// the new cache is preferred
+	nc.Store(cacheKey, []byte("new"), time.Minute)
+	if b, _, err = c.Retrieve(cacheKey, false); err != nil || string(b) != "new" {
+		t.Errorf("expected %s got %s: %v", "new", string(b), err)
+	}
+
+	if _, ls, err := c.Retrieve("missing", false); err != cache.ErrKNF ||
+		ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
+func TestCache_RetrieveBackfill(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+	c.Config.Migration.BackfillTTL = time.Minute
+
+	// objects read while allowing expiration are not backfilled
+	oc.Store("expired", []byte("data"), time.Minute)
+	if _, _, err := c.Retrieve("expired", true); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := nc.Retrieve("expired", false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	oc.Store(cacheKey, []byte("data"), time.Minute)
+	if b, _, err := c.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+	if b, _, err := nc.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	c.Store("a", []byte("data"), time.Minute)
+	c.Store("b", []byte("data"), time.Minute)
+	c.Store("c", []byte("data"), time.Minute)
+
+	c.Remove("a")
+	c.BulkRemove([]string{"b", "c"})
+	for _, key := range []string{"a", "b", "c"} {
+		for _, cc := range []cache.Cache{oc, nc} {
+			if _, _, err := cc.Retrieve(key, false); err != cache.ErrKNF {
+				t.Errorf("expected %v got %v", cache.ErrKNF, err)
+			}
+		}
+	}
+}
+
+func TestCache_SetTTL(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	c.SetTTL(cacheKey, time.Hour)
+	// the index updates the ttl asynchronously
+	time.Sleep(10 * time.Millisecond)
+	for _, cc := range []*memory.Cache{oc, nc} {
+		e := cc.Index.GetExpiration(cacheKey)
+		if e.Before(time.Now().Add(59 * time.Minute)) {
+			t.Errorf("expected expiration after %s got %s", time.Now().Add(59*time.Minute), e)
+		}
+	}
+}
+
+func TestCache_KeysAndTags(t *testing.T) {
+	c, oc, nc := newTestCache(t)
+	defer c.Close()
+
+	// keys and tags are the union of both caches
+	oc.Store("prefix.b", []byte("data"), time.Minute)
+	oc.SetTags("prefix.b", []string{"tag"})
+	c.Store("prefix.a", []byte("data"), time.Minute)
+	c.SetTags("prefix.a", []string{"tag"})
+
+	expected := []string{"prefix.a", "prefix.b"}
+	keys, err := c.Keys("prefix.")
+	if err != nil || len(keys) != 2 || keys[0] != expected[0] || keys[1] != expected[1] {
+		t.Errorf("expected %v got %v: %v", expected, keys, err)
+	}
+	keys, err = c.TaggedKeys("tag")
+	if err != nil || len(keys) != 2 || keys[0] != expected[0] || keys[1] != expected[1] {
+		t.Errorf("expected %v got %v: %v", expected, keys, err)
+	}
+
+	// an old cache that can't list or tag its objects can't be invalidated by them
+	c.Old = &struct{ cache.Cache }{oc}
+	if _, err = c.Keys("prefix."); err == nil {
+		t.Error("expected error for cache that can't list its keys")
+	}
+	if _, err = c.TaggedKeys("tag"); err == nil {
+		t.Error("expected error for cache that can't tag its keys")
+	}
+	c.Old = oc
+	c.New = &struct{ cache.Cache }{nc}
+	if _, err = c.Keys("prefix."); err == nil {
+		t.Error("expected error for cache that can't list its keys")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+)
+
+// ErrInvalidCacheType is returned when the old or new cache is not a supported cache type
+var ErrInvalidCacheType = errors.New("migration old_cache_type and new_cache_type must each be " +
+	"one of 'filesystem', 'bbolt', 'badger', 'logstore', 'redis', 'memcached', 'cassandra', 's3', " +
+	"'dynamodb', 'gcs' or 'azureblob'")
+
+// ErrSameCacheType is returned when the old and new caches are of the same type, since both
+// would be configured by the same section of the cache's options
+var ErrSameCacheType = errors.New("migration old_cache_type and new_cache_type must be different")
+
+// ErrInvalidBackfillTTL is returned when the backfill TTL is negative
+var ErrInvalidBackfillTTL = errors.New("migration backfill_ttl_secs must not be negative")
+
+// Options is a collection of Configurations for migrating the objects of a cache from one
+// persistent cache type to another
+type Options struct {
+	// OldCacheType is the type of the cache being migrated from, which is configured by its
+	// own section of the cache's options (e.g., [caches.default.bbolt])
+	OldCacheType string `toml:"old_cache_type"`
+	// NewCacheType is the type of the cache being migrated to, which is configured by its
+	// own section of the cache's options (e.g., [caches.default.logstore])
+	NewCacheType string `toml:"new_cache_type"`
+	// BackfillTTLSecs is the TTL of objects copied into the new cache when they are only
+	// found in the old cache, whose remaining TTL is not known. 0 disables backfilling
+	BackfillTTLSecs int `toml:"backfill_ttl_secs"`
+
+	// BackfillTTL is the time.Duration representation of BackfillTTLSecs
+	BackfillTTL time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new Migration Options Reference
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	return &o2
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	for _, ct := range []string{o.OldCacheType, o.NewCacheType} {
+		switch types.Names[ct] {
+		case types.CacheTypeFilesystem, types.CacheTypeBbolt, types.CacheTypeBadgerDB,
+			types.CacheTypeRedis, types.CacheTypeS3, types.CacheTypeDynamoDB, types.CacheTypeGCS,
+			types.CacheTypeAzureBlob, types.CacheTypeMemcached, types.CacheTypeCassandra,
+			types.CacheTypeLogstore:
+		default:
+			return ErrInvalidCacheType
+		}
+	}
+	if o.OldCacheType == o.NewCacheType {
+		return ErrSameCacheType
+	}
+	if o.BackfillTTLSecs < 0 {
+		return ErrInvalidBackfillTTL
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the seconds-based options
+func (o *Options) SetDurations() {
+	o.BackfillTTL = time.Duration(o.BackfillTTLSecs) * time.Second
+}
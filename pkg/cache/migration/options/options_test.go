@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Error("expected non-nil options")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		oldType  string
+		newType  string
+		ttl      int
+		expected error
+	}{
+		{"bbolt", "logstore", 0, nil},
+		{"filesystem", "redis", 300, nil},
+		{"memory", "logstore", 0, ErrInvalidCacheType},
+		{"bbolt", "tiered", 0, ErrInvalidCacheType},
+		{"", "logstore", 0, ErrInvalidCacheType},
+		{"bbolt", "", 0, ErrInvalidCacheType},
+		{"bbolt", "bbolt", 0, ErrSameCacheType},
+		{"bbolt", "logstore", -1, ErrInvalidBackfillTTL},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.OldCacheType = test.oldType
+		o.NewCacheType = test.newType
+		o.BackfillTTLSecs = test.ttl
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{OldCacheType: "bbolt", NewCacheType: "logstore"}
+	o2 := o.Clone()
+	o2.OldCacheType = "filesystem"
+	if o.OldCacheType != "bbolt" {
+		t.Errorf("expected %s got %s", "bbolt", o.OldCacheType)
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.BackfillTTLSecs = 30
+	o.SetDurations()
+	if o.BackfillTTL != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.BackfillTTL)
+	}
+}
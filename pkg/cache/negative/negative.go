@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package negative provides the negative caching configuration that determines which
+// failed upstream responses are cached, and for how long
+package negative
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// These are the keys of a negative cache config that are not response status codes
+const (
+	// KeyDNSError configures the TTL for the 502 responses to requests for which the
+	// origin's hostname could not be resolved
+	KeyDNSError = "dns_error"
+	// KeyConnectError configures the TTL for the 502 responses to requests for which the
+	// origin could not be reached, or failed to respond
+	KeyConnectError = "connect_error"
+	// KeyJitterPct configures the maximum percentage by which each TTL is randomly shortened
+	KeyJitterPct = "jitter_pct"
+)
+
+// ErrInvalidJitterPct is returned when the jitter percentage is outside of 0-100
+var ErrInvalidJitterPct = errors.New("jitter_pct must be between 0 and 100")
+
+// Cache is a negative cache config, with its TTLs converted to time.Durations
+type Cache struct {
+	// TTLs maps the negatively cached response status codes to their TTLs
+	TTLs map[int]time.Duration
+	// DNSErrorTTL is the TTL of the responses to requests for which the origin's hostname
+	// could not be resolved. When 0, those responses are not cached
+	DNSErrorTTL time.Duration
+	// ConnectErrorTTL is the TTL of the responses to requests for which the origin could not
+	// be reached, or failed to respond. When 0, those responses are not cached
+	ConnectErrorTTL time.Duration
+	// JitterPct is the maximum percentage by which each TTL is randomly shortened, so that
+	// objects that were negatively cached at the same time are not all re-fetched at once
+	JitterPct int
+}
+
+// New returns a new Cache from the provided negative cache config, which maps status
+// codes, and the names of the non-HTTP errors, to their TTLs in seconds
+func New(conf map[string]int) (*Cache, error) {
+	c := &Cache{TTLs: make(map[int]time.Duration)}
+	for k, v := range conf {
+		switch k {
+		case KeyDNSError:
+			c.DNSErrorTTL = time.Duration(v) * time.Second
+		case KeyConnectError:
+			c.ConnectErrorTTL = time.Duration(v) * time.Second
+		case KeyJitterPct:
+			if v < 0 || v > 100 {
+				return nil, ErrInvalidJitterPct
+			}
+			c.JitterPct = v
+		default:
+			code, err := strconv.Atoi(k)
+			if err != nil || code < 400 || code >= 600 {
+				return nil, fmt.Errorf("%s is not a valid status code", k)
+			}
+			c.TTLs[code] = time.Duration(v) * time.Second
+		}
+	}
+	return c, nil
+}
+
+// Clone returns an exact copy of the subject Cache
+func (c *Cache) Clone() *Cache {
+	if c == nil {
+		return nil
+	}
+	c2 := &Cache{
+		TTLs:            make(map[int]time.Duration, len(c.TTLs)),
+		DNSErrorTTL:     c.DNSErrorTTL,
+		ConnectErrorTTL: c.ConnectErrorTTL,
+		JitterPct:       c.JitterPct,
+	}
+	for k, v := range c.TTLs {
+		c2.TTLs[k] = v
+	}
+	return c2
+}
+
+// Jitter returns the provided TTL, randomly shortened by up to the Cache's JitterPct
+func (c *Cache) Jitter(d time.Duration) time.Duration {
+	if c == nil || c.JitterPct <= 0 || d <= 0 {
+		return d
+	}
+	max := int64(d) * int64(c.JitterPct) / 100
+	if max <= 0 {
+		return d
+	}
+	return d - time.Duration(rand.Int63n(max+1))
+}
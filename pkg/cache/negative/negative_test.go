@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package negative
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+
+	c, err := New(map[string]int{"404": 5, "500": 10, KeyDNSError: 30, KeyConnectError: 2,
+		KeyJitterPct: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.TTLs) != 2 || c.TTLs[404] != 5*time.Second || c.TTLs[500] != 10*time.Second {
+		t.Errorf("unexpected ttls %v", c.TTLs)
+	}
+	if c.DNSErrorTTL != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, c.DNSErrorTTL)
+	}
+	if c.ConnectErrorTTL != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, c.ConnectErrorTTL)
+	}
+	if c.JitterPct != 20 {
+		t.Errorf("expected %d got %d", 20, c.JitterPct)
+	}
+
+	c, err = New(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if c.TTLs == nil {
+		t.Error("expected non-nil ttls")
+	}
+
+	for _, k := range []string{"a", "200", "1212"} {
+		_, err = New(map[string]int{k: 1})
+		if err == nil || err.Error() != k+" is not a valid status code" {
+			t.Errorf("expected invalid status code error for %s got %v", k, err)
+		}
+	}
+
+	for _, v := range []int{-1, 101} {
+		_, err = New(map[string]int{KeyJitterPct: v})
+		if err != ErrInvalidJitterPct {
+			t.Errorf("expected %v got %v", ErrInvalidJitterPct, err)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	var c *Cache
+	if c.Clone() != nil {
+		t.Error("expected nil clone")
+	}
+
+	c = &Cache{TTLs: map[int]time.Duration{404: time.Second}, DNSErrorTTL: 1,
+		ConnectErrorTTL: 2, JitterPct: 3}
+	c2 := c.Clone()
+	c2.TTLs[404] = 0
+	if c.TTLs[404] != time.Second {
+		t.Error("expected a copy of the ttls")
+	}
+	if c2.DNSErrorTTL != 1 || c2.ConnectErrorTTL != 2 || c2.JitterPct != 3 {
+		t.Errorf("clone mismatch %v", c2)
+	}
+}
+
+func TestJitter(t *testing.T) {
+
+	var c *Cache
+	if d := c.Jitter(time.Second); d != time.Second {
+		t.Errorf("expected %s got %s", time.Second, d)
+	}
+
+	c = &Cache{}
+	if d := c.Jitter(time.Second); d != time.Second {
+		t.Errorf("expected %s got %s", time.Second, d)
+	}
+
+	c.JitterPct = 10
+	if d := c.Jitter(0); d != 0 {
+		t.Errorf("expected %d got %s", 0, d)
+	}
+	for i := 0; i < 100; i++ {
+		if d := c.Jitter(time.Second); d < 900*time.Millisecond || d > time.Second {
+			t.Errorf("expected ttl between %s and %s got %s", 900*time.Millisecond, time.Second, d)
+		}
+	}
+
+	c.JitterPct = 100
+	for i := 0; i < 100; i++ {
+		if d := c.Jitter(time.Second); d < 0 || d > time.Second {
+			t.Errorf("expected ttl between %d and %s got %s", 0, time.Second, d)
+		}
+	}
+}
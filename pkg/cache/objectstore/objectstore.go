@@ -0,0 +1,325 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package objectstore is the Trickster Cache for object storage services such as S3, GCS and
+// Azure Blob Storage. It provides the caching behavior common to each service, which is
+// accessed through a thin Client implemented by the service's package
+package objectstore
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// ErrObjectNotFound is returned by a Client when the requested object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// maxConcurrentDeletes is the most objects removed at once by BulkRemove, when the Client
+// can't remove them in bulk
+const maxConcurrentDeletes = 16
+
+// Client is the interface to an object storage service. Object storage services have no
+// per-object TTL, so each object is stored along with its expiration, and expired objects
+// are treated as misses until they are removed by the service's lifecycle rules
+type Client interface {
+	// Connect verifies that the configured bucket is accessible
+	Connect() error
+	// Put writes the object, which expires at the provided time
+	Put(name string, data []byte, expiration time.Time) error
+	// Get returns the object and its expiration, or ErrObjectNotFound. The expiration is
+	// zero when the object has none
+	Get(name string) ([]byte, time.Time, error)
+	// SetExpiration updates the expiration of an existing object
+	SetExpiration(name string, expiration time.Time) error
+	// Delete removes the object
+	Delete(name string) error
+	// List returns the names of the objects that begin with prefix
+	List(prefix string) ([]string, error)
+	// Close releases the Client's idle connections
+	Close() error
+}
+
+// BulkDeleter is implemented by Clients that can remove many objects in a single request
+type BulkDeleter interface {
+	// BulkDelete removes the objects, and returns the number that were removed
+	BulkDelete(names []string) (int, error)
+}
+
+// ObjectInfo describes an object in the object storage service
+type ObjectInfo struct {
+	Name string
+	Size int64
+	// LastModified is the time the object was last written
+	LastModified time.Time
+	// Expiration is the object's expiration, which is zero when it has none or is not known
+	Expiration time.Time
+}
+
+// InfoLister is implemented by Clients that can list the sizes and write times of objects,
+// which allows the Cache to be inspected without maintaining an Index of its objects
+type InfoLister interface {
+	// ListInfo returns the descriptions of the objects that begin with prefix. The
+	// Expiration of each description is not known
+	ListInfo(prefix string) ([]ObjectInfo, error)
+}
+
+// Statter is implemented by Clients that can describe an object, including its expiration,
+// without reading the object
+type Statter interface {
+	// Stat returns the description of the object, or ErrObjectNotFound
+	Stat(name string) (ObjectInfo, error)
+}
+
+// Cache represents an object storage cache that conforms to the Cache interface
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	// Client is the interface to the object storage service
+	Client Client
+	// Prefix is prepended to the cache key to form the name of each object
+	Prefix string
+	locker locks.NamedLocker
+
+	now func() time.Time
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect verifies that the configured bucket is accessible
+func (c *Cache) Connect() error {
+	c.Logger.Info("connecting to "+c.Config.CacheType,
+		tl.Pairs{"name": c.Name, "prefix": c.Prefix})
+	if c.now == nil {
+		c.now = time.Now
+	}
+	return c.Client.Connect()
+}
+
+// Store places the the data into the object store using the provided Key and TTL
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+	c.Logger.Debug(c.Config.CacheType+" cache store", tl.Pairs{"key": cacheKey, "ttl": ttl})
+	return c.Client.Put(c.Prefix+cacheKey, data, c.now().Add(ttl))
+}
+
+// Retrieve gets data from the object store using the provided Key
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+
+	data, expiration, err := c.Client.Get(c.Prefix + cacheKey)
+	if err == ErrObjectNotFound {
+		c.Logger.Debug(c.Config.CacheType+" cache miss", tl.Pairs{"key": cacheKey})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	if err == nil {
+		data, err = cache.DecodeValue(c.Config, cacheKey, data)
+	}
+	if err != nil {
+		c.Logger.Debug(c.Config.CacheType+" cache retrieve failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+
+	if !allowExpired && !expiration.IsZero() && !c.now().Before(expiration) {
+		c.Logger.Debug(c.Config.CacheType+" cache miss",
+			tl.Pairs{"key": cacheKey, "reason": "expired"})
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+
+	c.Logger.Debug(c.Config.CacheType+" cache retrieve", tl.Pairs{"key": cacheKey})
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+	return data, status.LookupStatusHit, nil
+}
+
+// SetTTL updates the TTL for the provided cache object
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	if err := c.Client.SetExpiration(c.Prefix+cacheKey, c.now().Add(ttl)); err != nil {
+		c.Logger.Debug(c.Config.CacheType+" cache set ttl failed",
+			tl.Pairs{"key": cacheKey, "reason": err.Error()})
+	}
+}
+
+// Remove removes an object in cache, if present
+func (c *Cache) Remove(cacheKey string) {
+	c.Logger.Debug(c.Config.CacheType+" cache remove", tl.Pairs{"key": cacheKey})
+	if err := c.Client.Delete(c.Prefix + cacheKey); err != nil {
+		c.Logger.Error(c.Config.CacheType+" cache key delete failure",
+			tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+		return
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
+}
+
+// BulkRemove removes a list of objects from the cache
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug(c.Config.CacheType+" cache bulk remove", tl.Pairs{})
+	names := make([]string, len(cacheKeys))
+	for i, k := range cacheKeys {
+		names[i] = c.Prefix + k
+	}
+
+	if bd, ok := c.Client.(BulkDeleter); ok {
+		n, err := bd.BulkDelete(names)
+		if err != nil {
+			c.Logger.Error(c.Config.CacheType+" cache bulk delete failure",
+				tl.Pairs{"reason": err.Error()})
+		}
+		metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(n))
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentDeletes)
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(cacheKey, name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := c.Client.Delete(name); err != nil {
+				c.Logger.Error(c.Config.CacheType+" cache key delete failure",
+					tl.Pairs{"cacheKey": cacheKey, "reason": err.Error()})
+				return
+			}
+			metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 1)
+		}(cacheKeys[i], name)
+	}
+	wg.Wait()
+}
+
+// Keys returns the keys of the cached objects that begin with prefix, including expired
+// objects not yet removed by the service's lifecycle rules
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	names, err := c.Client.List(c.Prefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, strings.TrimPrefix(name, c.Prefix))
+	}
+	return keys, nil
+}
+
+// Inspect returns the statistics of the cached objects, including the topN largest, as
+// listed from the object storage service. Since the objects are not indexed, they are listed
+// on each call, and their accesses are not tracked, so Hottest is always empty. Expired
+// objects not yet removed by the service's lifecycle rules are included. Inspect returns nil
+// when the Client can't list the objects' sizes
+func (c *Cache) Inspect(topN int) *cache.Stats {
+	il, ok := c.Client.(InfoLister)
+	if !ok {
+		return nil
+	}
+	infos, err := il.ListInfo(c.Prefix)
+	if err != nil {
+		c.Logger.Warn(c.Config.CacheType+" cache inspection failed",
+			tl.Pairs{"name": c.Name, "reason": err.Error()})
+		return nil
+	}
+
+	now := c.now()
+	s := cache.NewStats()
+	objs := make([]*cache.ObjectStats, 0, len(infos))
+	for _, oi := range infos {
+		s.Add(oi.Size, now.Sub(oi.LastModified))
+		objs = append(objs, c.objectStats(oi, now))
+	}
+	s.Largest = cache.TopObjects(objs, topN, func(a, b *cache.ObjectStats) bool {
+		return a.Size > b.Size
+	})
+	s.Hottest = []*cache.ObjectStats{}
+
+	// expirations are not listed, so they are read for the few objects that are reported
+	if st, ok := c.Client.(Statter); ok {
+		for _, o := range s.Largest {
+			if oi, err := st.Stat(c.Prefix + o.Key); err == nil {
+				o.TTLSecs = c.objectStats(oi, now).TTLSecs
+			}
+		}
+	}
+	return s
+}
+
+// InspectObject returns the statistics of the object cached at the key, or nil if the key
+// is not cached or the Client can't describe it
+func (c *Cache) InspectObject(cacheKey string) *cache.ObjectStats {
+	st, ok := c.Client.(Statter)
+	if !ok {
+		return nil
+	}
+	oi, err := st.Stat(c.Prefix + cacheKey)
+	if err != nil {
+		if err != ErrObjectNotFound {
+			c.Logger.Debug(c.Config.CacheType+" cache inspect object failed",
+				tl.Pairs{"key": cacheKey, "reason": err.Error()})
+		}
+		return nil
+	}
+	return c.objectStats(oi, c.now())
+}
+
+// objectStats returns the statistics of the described object as of now. Accesses are not
+// tracked, so Hits and IdleSecs are always 0
+func (c *Cache) objectStats(oi ObjectInfo, now time.Time) *cache.ObjectStats {
+	st := &cache.ObjectStats{
+		Key:     strings.TrimPrefix(oi.Name, c.Prefix),
+		Size:    oi.Size,
+		AgeSecs: int64(now.Sub(oi.LastModified).Seconds()),
+	}
+	if !oi.Expiration.IsZero() && oi.Expiration.After(now) {
+		st.TTLSecs = int64(oi.Expiration.Sub(now).Seconds())
+	}
+	return st
+}
+
+// Close closes the Cache
+func (c *Cache) Close() error {
+	return c.Client.Close()
+}
@@ -0,0 +1,515 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstore
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheType = "s3"
+const cacheKey = "cacheKey"
+const testPrefix = "trickster/"
+
+var errTest = errors.New("test error")
+
+type testObject struct {
+	data       []byte
+	expiration time.Time
+	modified   time.Time
+}
+
+// testClient is an in-memory object store
+type testClient struct {
+	mtx     sync.Mutex
+	objects map[string]*testObject
+	// err, when set, is returned by each request
+	err     error
+	deletes int
+}
+
+func newTestClient() *testClient {
+	return &testClient{objects: make(map[string]*testObject)}
+}
+
+func (tc *testClient) Connect() error {
+	return tc.err
+}
+
+func (tc *testClient) Put(name string, data []byte, expiration time.Time) error {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return tc.err
+	}
+	tc.objects[name] = &testObject{data: data, expiration: expiration, modified: time.Now()}
+	return nil
+}
+
+func (tc *testClient) Get(name string) ([]byte, time.Time, error) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return nil, time.Time{}, tc.err
+	}
+	o, ok := tc.objects[name]
+	if !ok {
+		return nil, time.Time{}, ErrObjectNotFound
+	}
+	return o.data, o.expiration, nil
+}
+
+func (tc *testClient) SetExpiration(name string, expiration time.Time) error {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return tc.err
+	}
+	o, ok := tc.objects[name]
+	if !ok {
+		return ErrObjectNotFound
+	}
+	o.expiration = expiration
+	return nil
+}
+
+func (tc *testClient) Delete(name string) error {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return tc.err
+	}
+	tc.deletes++
+	delete(tc.objects, name)
+	return nil
+}
+
+func (tc *testClient) List(prefix string) ([]string, error) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return nil, tc.err
+	}
+	var names []string
+	for k := range tc.objects {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (tc *testClient) Close() error {
+	return nil
+}
+
+// testBulkClient is an in-memory object store that removes objects in bulk
+type testBulkClient struct {
+	*testClient
+	bulkDeletes int
+}
+
+func (tc *testBulkClient) BulkDelete(names []string) (int, error) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return 0, tc.err
+	}
+	tc.bulkDeletes++
+	for _, name := range names {
+		delete(tc.objects, name)
+	}
+	return len(names), nil
+}
+
+// testInfoClient is an in-memory object store that can describe its objects
+type testInfoClient struct {
+	*testClient
+	stats int
+}
+
+func (tc *testInfoClient) ListInfo(prefix string) ([]ObjectInfo, error) {
+	names, err := tc.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	infos := make([]ObjectInfo, 0, len(names))
+	for _, name := range names {
+		o := tc.objects[name]
+		infos = append(infos, ObjectInfo{Name: name, Size: int64(len(o.data)),
+			LastModified: o.modified})
+	}
+	return infos, nil
+}
+
+func (tc *testInfoClient) Stat(name string) (ObjectInfo, error) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	if tc.err != nil {
+		return ObjectInfo{}, tc.err
+	}
+	tc.stats++
+	o, ok := tc.objects[name]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	return ObjectInfo{Name: name, Size: int64(len(o.data)), LastModified: o.modified,
+		Expiration: o.expiration}, nil
+}
+
+func newTestCache(t *testing.T, client Client) *Cache {
+	c := &Cache{Config: &co.Options{CacheType: cacheType}, Logger: tl.ConsoleLogger("error"),
+		Client: client, Prefix: testPrefix, locker: locks.NewNamedLocker()}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConfiguration(t *testing.T) {
+	c := Cache{Config: &co.Options{CacheType: cacheType}}
+	cfg := c.Configuration()
+	if cfg.CacheType != cacheType {
+		t.Errorf("expected %s got %s", cacheType, cfg.CacheType)
+	}
+}
+
+func TestObjectStoreCache_SetLocker(t *testing.T) {
+	c := Cache{}
+	c.SetLocker(locks.NewNamedLocker())
+	if c.Locker() == nil {
+		t.Error("expected non-nil locker")
+	}
+}
+
+func TestObjectStoreCache_Connect(t *testing.T) {
+	tc := newTestClient()
+	c := newTestCache(t, tc)
+	if c.now == nil {
+		t.Error("expected non-nil clock")
+	}
+	tc.err = errTest
+	if err := c.Connect(); err != errTest {
+		t.Errorf("expected %v got %v", errTest, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObjectStoreCache_StoreRetrieve(t *testing.T) {
+
+	tc := newTestClient()
+	c := newTestCache(t, tc)
+
+	// it should store a value under the prefix
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	if err := c.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second); err != nil {
+		t.Error(err)
+	}
+	o, ok := tc.objects[testPrefix+cacheKey]
+	if !ok {
+		t.Fatal("expected object under the configured prefix")
+	}
+	if !o.expiration.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected %v got %v", now.Add(time.Minute), o.expiration)
+	}
+
+	// it should retrieve a value
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// it should miss a key that was not stored
+	_, ls, err = c.Retrieve("missing", false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// an object without an expiration never expires
+	tc.objects[testPrefix+"noexp"] = &testObject{data: []byte("data")}
+	if _, ls, _ = c.Retrieve("noexp", false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+}
+
+func TestObjectStoreCache_RetrieveExpired(t *testing.T) {
+
+	c := newTestCache(t, newTestClient())
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	if err := c.Store(cacheKey, []byte("data"), time.Second); err != nil {
+		t.Error(err)
+	}
+	c.now = func() time.Time { return now.Add(2 * time.Second) }
+
+	_, ls, err := c.Retrieve(cacheKey, false)
+	if err != cache.ErrKNF || ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected expired miss, got %s %v", ls, err)
+	}
+
+	data, ls, err := c.Retrieve(cacheKey, true)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected expired hit, got %s %s %v", string(data), ls, err)
+	}
+
+	// SetTTL extends the expiration
+	c.SetTTL(cacheKey, time.Minute)
+	if _, ls, _ = c.Retrieve(cacheKey, false); ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// SetTTL of a missing key is harmless
+	c.SetTTL("missing", time.Minute)
+	if _, ls, _ = c.Retrieve("missing", false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestObjectStoreCache_Remove(t *testing.T) {
+
+	c := newTestCache(t, newTestClient())
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	c.Remove(cacheKey)
+	if _, ls, _ := c.Retrieve(cacheKey, false); ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
+func TestObjectStoreCache_BulkRemove(t *testing.T) {
+
+	// objects are removed concurrently by a client that can't remove them in bulk
+	tc := newTestClient()
+	c := newTestCache(t, tc)
+	keys := make([]string, maxConcurrentDeletes*2+1)
+	for i := range keys {
+		keys[i] = cacheKey + strconv.Itoa(i)
+		tc.objects[testPrefix+keys[i]] = &testObject{data: []byte("data")}
+	}
+	tc.objects["other/"+cacheKey] = &testObject{data: []byte("data")}
+	c.BulkRemove(keys)
+	if len(tc.objects) != 1 {
+		t.Errorf("expected %d objects got %d", 1, len(tc.objects))
+	}
+	if tc.deletes != len(keys) {
+		t.Errorf("expected %d deletes got %d", len(keys), tc.deletes)
+	}
+
+	// and in bulk by a client that can
+	bc := &testBulkClient{testClient: newTestClient()}
+	c = newTestCache(t, bc)
+	for i := range keys {
+		bc.objects[testPrefix+keys[i]] = &testObject{data: []byte("data")}
+	}
+	c.BulkRemove(keys)
+	if len(bc.objects) != 0 {
+		t.Errorf("expected %d objects got %d", 0, len(bc.objects))
+	}
+	if bc.bulkDeletes != 1 || bc.deletes != 0 {
+		t.Errorf("expected a single bulk delete, got %d bulk and %d single",
+			bc.bulkDeletes, bc.deletes)
+	}
+}
+
+func TestObjectStoreCache_Keys(t *testing.T) {
+
+	tc := newTestClient()
+	c := newTestCache(t, tc)
+
+	for _, k := range []string{"a.dpc.1", "a.dpc.2", "a.opc.1", "b.dpc.1"} {
+		tc.objects[testPrefix+k] = &testObject{data: []byte("data")}
+	}
+	tc.objects["other/a.dpc.3"] = &testObject{data: []byte("data")}
+
+	keys, err := c.Keys("a.dpc.")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"a.dpc.1", "a.dpc.2"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v got %v", expected, keys)
+	}
+
+	keys, err = c.Keys("c.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys got %v", keys)
+	}
+
+	tc.err = errTest
+	if _, err = c.Keys(""); err != errTest {
+		t.Errorf("expected %v got %v", errTest, err)
+	}
+}
+
+func TestObjectStoreCache_Errors(t *testing.T) {
+
+	tc := newTestClient()
+	c := newTestCache(t, tc)
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+
+	// values that can't be decoded are errors
+	c.Config.CompressionCodec = "snappy"
+	if _, ls, err := c.Retrieve(cacheKey, false); err == nil || ls != status.LookupStatusError {
+		t.Errorf("expected error, got %s %v", ls, err)
+	}
+	c.Config.CompressionCodec = ""
+
+	// client errors are returned or logged
+	tc.err = errTest
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != errTest {
+		t.Errorf("expected %v got %v", errTest, err)
+	}
+	if _, ls, err := c.Retrieve(cacheKey, false); err != errTest || ls != status.LookupStatusError {
+		t.Errorf("expected error, got %s %v", ls, err)
+	}
+	c.Remove(cacheKey)
+	c.BulkRemove([]string{cacheKey})
+	c.SetTTL(cacheKey, time.Minute)
+	if len(tc.objects) != 1 {
+		t.Errorf("expected %d objects got %d", 1, len(tc.objects))
+	}
+
+	bc := &testBulkClient{testClient: tc}
+	c.Client = bc
+	c.BulkRemove([]string{cacheKey})
+	if len(tc.objects) != 1 {
+		t.Errorf("expected %d objects got %d", 1, len(tc.objects))
+	}
+}
+
+func TestObjectStoreCache_Inspect(t *testing.T) {
+
+	tc := &testInfoClient{testClient: newTestClient()}
+	c := newTestCache(t, tc)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Store("a", []byte("12345"), time.Minute)
+	c.Store("b", []byte("1"), time.Hour)
+	c.Store("c", []byte("123"), time.Hour)
+	tc.objects[testPrefix+"a"].modified = now.Add(-30 * time.Second)
+	tc.objects[testPrefix+"b"].modified = now.Add(-2 * time.Hour)
+	tc.objects[testPrefix+"c"].modified = now.Add(-30 * 24 * time.Hour)
+	// objects outside of the prefix are not the cache's
+	tc.objects["other/d"] = &testObject{data: []byte("1234567"), modified: now}
+
+	s := c.Inspect(2)
+	if s == nil {
+		t.Fatal("expected non-nil stats")
+	}
+	if s.Objects != 3 || s.Bytes != 9 {
+		t.Errorf("expected %d objects and %d bytes got %d and %d", 3, 9, s.Objects, s.Bytes)
+	}
+	if len(s.AgeHistogram) != len(cache.AgeBuckets)+1 {
+		t.Fatalf("expected %d got %d", len(cache.AgeBuckets)+1, len(s.AgeHistogram))
+	}
+	if s.AgeHistogram[0].Objects != 1 || s.AgeHistogram[4].Objects != 1 ||
+		s.AgeHistogram[len(cache.AgeBuckets)].Objects != 1 {
+		t.Errorf("unexpected age histogram")
+	}
+	if len(s.Largest) != 2 || s.Largest[0].Key != "a" || s.Largest[1].Key != "c" {
+		t.Errorf("unexpected largest objects")
+	} else if s.Largest[0].TTLSecs != 60 || s.Largest[0].AgeSecs != 30 {
+		t.Errorf("unexpected stats for a: %v", s.Largest[0])
+	}
+	if s.Hottest == nil || len(s.Hottest) != 0 {
+		t.Errorf("expected empty hottest objects")
+	}
+	// only the reported objects are described individually
+	if tc.stats != 2 {
+		t.Errorf("expected %d got %d", 2, tc.stats)
+	}
+
+	tc.err = errTest
+	if s = c.Inspect(2); s != nil {
+		t.Error("expected nil stats on error")
+	}
+
+	// clients that can't list the objects' sizes can't be inspected
+	c = newTestCache(t, newTestClient())
+	if s = c.Inspect(2); s != nil {
+		t.Error("expected nil stats")
+	}
+	if o := c.InspectObject("a"); o != nil {
+		t.Error("expected nil object stats")
+	}
+
+}
+
+func TestObjectStoreCache_InspectObject(t *testing.T) {
+
+	tc := &testInfoClient{testClient: newTestClient()}
+	c := newTestCache(t, tc)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	tc.objects[testPrefix+cacheKey].modified = now.Add(-10 * time.Second)
+
+	o := c.InspectObject(cacheKey)
+	if o == nil {
+		t.Fatal("expected non-nil object stats")
+	}
+	if o.Key != cacheKey || o.Size != 4 || o.AgeSecs != 10 || o.TTLSecs != 60 {
+		t.Errorf("unexpected object stats: %v", o)
+	}
+
+	if o = c.InspectObject("missing"); o != nil {
+		t.Error("expected nil object stats for missing key")
+	}
+
+	tc.err = errTest
+	if o = c.InspectObject(cacheKey); o != nil {
+		t.Error("expected nil object stats on error")
+	}
+
+}
@@ -17,12 +17,28 @@
 package options
 
 import (
+	"time"
+
+	azureblob "github.com/tricksterproxy/trickster/pkg/cache/azureblob/options"
 	badger "github.com/tricksterproxy/trickster/pkg/cache/badger/options"
 	bbolt "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	cassandra "github.com/tricksterproxy/trickster/pkg/cache/cassandra/options"
+	dynamodb "github.com/tricksterproxy/trickster/pkg/cache/dynamodb/options"
+	encryption "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	etcd "github.com/tricksterproxy/trickster/pkg/cache/etcd/options"
 	filesystem "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	gcs "github.com/tricksterproxy/trickster/pkg/cache/gcs/options"
 	index "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	logstore "github.com/tricksterproxy/trickster/pkg/cache/logstore/options"
+	memcached "github.com/tricksterproxy/trickster/pkg/cache/memcached/options"
+	migration "github.com/tricksterproxy/trickster/pkg/cache/migration/options"
+	quota "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
 	redis "github.com/tricksterproxy/trickster/pkg/cache/redis/options"
+	s3 "github.com/tricksterproxy/trickster/pkg/cache/s3/options"
+	tiered "github.com/tricksterproxy/trickster/pkg/cache/tiered/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
+	warmingopts "github.com/tricksterproxy/trickster/pkg/cache/warming/options"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 )
 
@@ -42,12 +58,54 @@ type Options struct {
 	BBolt *bbolt.Options `toml:"bbolt"`
 	// Badger provides options for BadgerDB caching
 	Badger *badger.Options `toml:"badger"`
+	// S3 provides options for S3 caching
+	S3 *s3.Options `toml:"s3"`
+	// DynamoDB provides options for DynamoDB caching
+	DynamoDB *dynamodb.Options `toml:"dynamodb"`
+	// GCS provides options for Google Cloud Storage caching
+	GCS *gcs.Options `toml:"gcs"`
+	// AzureBlob provides options for Azure Blob Storage caching
+	AzureBlob *azureblob.Options `toml:"azureblob"`
+	// Memcached provides options for Memcached caching
+	Memcached *memcached.Options `toml:"memcached"`
+	// Etcd provides options for etcd caching
+	Etcd *etcd.Options `toml:"etcd"`
+	// Cassandra provides options for Cassandra and ScyllaDB caching
+	Cassandra *cassandra.Options `toml:"cassandra"`
+	// Logstore provides options for log-structured Filesystem caching
+	Logstore *logstore.Options `toml:"logstore"`
+	// Tiered provides options for layering a memory cache in front of a persistent cache
+	Tiered *tiered.Options `toml:"tiered"`
+	// Migration provides options for migrating a cache from one persistent cache type to another
+	Migration *migration.Options `toml:"migration"`
+	// Encryption provides options for encrypting the values stored in persistent caches
+	Encryption *encryption.Options `toml:"encryption"`
+	// Warming provides options for replaying popular queries to keep the cache hot
+	Warming *warmingopts.Options `toml:"warming"`
+	// MaxTTLSecs is the maximum TTL of any object stored in the cache, regardless of the TTL
+	// calculated by the origin's caching policy. 0 means no maximum
+	MaxTTLSecs int `toml:"max_ttl_secs"`
+	// MinTTLSecs is the minimum TTL of any object stored in the cache. 0 means no minimum
+	MinTTLSecs int `toml:"min_ttl_secs"`
+	// MaxObjectSizeBytes is the largest size of any object stored in the cache, so that a single
+	// oversized object can't evict the rest of the cache. Larger objects are proxied without
+	// being cached. 0 means no maximum
+	MaxObjectSizeBytes int `toml:"max_object_size_bytes"`
+	// CompressionCodec is the codec with which values are compressed before they are stored:
+	// "snappy", "gzip" or "none". Empty means values are stored without a codec header
+	CompressionCodec string `toml:"compression_codec"`
 
 	//  Synthetic Values
 
 	// CacheTypeID represents the internal constant for the provided CacheType string
 	// and is automatically populated at startup
 	CacheTypeID types.CacheType `toml:"-"`
+	// MaxTTL is the time.Duration representation of MaxTTLSecs
+	MaxTTL time.Duration `toml:"-"`
+	// MinTTL is the time.Duration representation of MinTTLSecs
+	MinTTL time.Duration `toml:"-"`
+	// Warmer records and replays the cache's popular queries according to its Warming options
+	Warmer *warming.Warmer `toml:"-"`
 }
 
 // NewOptions will return a pointer to an OriginConfig with the default configuration settings
@@ -60,6 +118,18 @@ func NewOptions() *Options {
 		Filesystem:  filesystem.NewOptions(),
 		BBolt:       bbolt.NewOptions(),
 		Badger:      badger.NewOptions(),
+		S3:          s3.NewOptions(),
+		DynamoDB:    dynamodb.NewOptions(),
+		GCS:         gcs.NewOptions(),
+		AzureBlob:   azureblob.NewOptions(),
+		Memcached:   memcached.NewOptions(),
+		Etcd:        etcd.NewOptions(),
+		Cassandra:   cassandra.NewOptions(),
+		Logstore:    logstore.NewOptions(),
+		Tiered:      tiered.NewOptions(),
+		Migration:   migration.NewOptions(),
+		Encryption:  encryption.NewOptions(),
+		Warming:     warmingopts.NewOptions(),
 		Index:       index.NewOptions(),
 	}
 }
@@ -71,6 +141,12 @@ func (cc *Options) Clone() *Options {
 	c.Name = cc.Name
 	c.CacheType = cc.CacheType
 	c.CacheTypeID = cc.CacheTypeID
+	c.MaxTTLSecs = cc.MaxTTLSecs
+	c.MaxTTL = cc.MaxTTL
+	c.MinTTLSecs = cc.MinTTLSecs
+	c.MinTTL = cc.MinTTL
+	c.MaxObjectSizeBytes = cc.MaxObjectSizeBytes
+	c.CompressionCodec = cc.CompressionCodec
 
 	c.Index.FlushInterval = cc.Index.FlushInterval
 	c.Index.FlushIntervalSecs = cc.Index.FlushIntervalSecs
@@ -80,14 +156,148 @@ func (cc *Options) Clone() *Options {
 	c.Index.MaxSizeObjects = cc.Index.MaxSizeObjects
 	c.Index.ReapInterval = cc.Index.ReapInterval
 	c.Index.ReapIntervalSecs = cc.Index.ReapIntervalSecs
+	c.Index.ForecastInterval = cc.Index.ForecastInterval
+	c.Index.ForecastIntervalSecs = cc.Index.ForecastIntervalSecs
+	c.Index.EvictionPolicy = cc.Index.EvictionPolicy
+	c.Index.AccessQueueSize = cc.Index.AccessQueueSize
+	c.Index.AccessBatchSize = cc.Index.AccessBatchSize
+	c.Index.AccessFlushIntervalMS = cc.Index.AccessFlushIntervalMS
+	c.Index.AccessFlushInterval = cc.Index.AccessFlushInterval
+	if cc.Index.Quotas != nil {
+		c.Index.Quotas = make(map[string]*quota.Options, len(cc.Index.Quotas))
+		for k, v := range cc.Index.Quotas {
+			c.Index.Quotas[k] = v.Clone()
+		}
+	}
 
 	c.Badger.Directory = cc.Badger.Directory
 	c.Badger.ValueDirectory = cc.Badger.ValueDirectory
+	c.Badger.ValueLogGCIntervalSecs = cc.Badger.ValueLogGCIntervalSecs
+	c.Badger.ValueLogGCRatio = cc.Badger.ValueLogGCRatio
+	c.Badger.ValueLogFileSizeBytes = cc.Badger.ValueLogFileSizeBytes
+	c.Badger.ValueLogGCInterval = cc.Badger.ValueLogGCInterval
 
 	c.Filesystem.CachePath = cc.Filesystem.CachePath
+	c.Filesystem.SyncMode = cc.Filesystem.SyncMode
+	c.Filesystem.SyncIntervalMS = cc.Filesystem.SyncIntervalMS
+	c.Filesystem.SyncInterval = cc.Filesystem.SyncInterval
+	c.Filesystem.BatchMaxBytes = cc.Filesystem.BatchMaxBytes
+	c.Filesystem.BatchIntervalMS = cc.Filesystem.BatchIntervalMS
+	c.Filesystem.BatchInterval = cc.Filesystem.BatchInterval
+	c.Filesystem.FileMode = cc.Filesystem.FileMode
+	c.Filesystem.DirMode = cc.Filesystem.DirMode
+	c.Filesystem.FilePerm = cc.Filesystem.FilePerm
+	c.Filesystem.DirPerm = cc.Filesystem.DirPerm
+	c.Filesystem.ReconcileMode = cc.Filesystem.ReconcileMode
+
+	c.S3.Endpoint = cc.S3.Endpoint
+	c.S3.Bucket = cc.S3.Bucket
+	c.S3.Prefix = cc.S3.Prefix
+	c.S3.Region = cc.S3.Region
+	c.S3.AccessKeyID = cc.S3.AccessKeyID
+	c.S3.SecretAccessKey = cc.S3.SecretAccessKey
+	c.S3.SessionToken = cc.S3.SessionToken
+	c.S3.PathStyle = cc.S3.PathStyle
+	c.S3.PartSizeBytes = cc.S3.PartSizeBytes
+	c.S3.TimeoutMS = cc.S3.TimeoutMS
+	c.S3.Timeout = cc.S3.Timeout
+
+	if cc.DynamoDB != nil {
+		c.DynamoDB.Endpoint = cc.DynamoDB.Endpoint
+		c.DynamoDB.Table = cc.DynamoDB.Table
+		c.DynamoDB.Region = cc.DynamoDB.Region
+		c.DynamoDB.KeyAttribute = cc.DynamoDB.KeyAttribute
+		c.DynamoDB.TTLAttribute = cc.DynamoDB.TTLAttribute
+		c.DynamoDB.AccessKeyID = cc.DynamoDB.AccessKeyID
+		c.DynamoDB.SecretAccessKey = cc.DynamoDB.SecretAccessKey
+		c.DynamoDB.SessionToken = cc.DynamoDB.SessionToken
+		c.DynamoDB.ReadCapacityUnits = cc.DynamoDB.ReadCapacityUnits
+		c.DynamoDB.WriteCapacityUnits = cc.DynamoDB.WriteCapacityUnits
+		c.DynamoDB.BatchSize = cc.DynamoDB.BatchSize
+		c.DynamoDB.TimeoutMS = cc.DynamoDB.TimeoutMS
+		c.DynamoDB.Timeout = cc.DynamoDB.Timeout
+	}
+
+	if cc.GCS != nil {
+		c.GCS.Endpoint = cc.GCS.Endpoint
+		c.GCS.Bucket = cc.GCS.Bucket
+		c.GCS.Prefix = cc.GCS.Prefix
+		c.GCS.CredentialsFile = cc.GCS.CredentialsFile
+		c.GCS.TimeoutMS = cc.GCS.TimeoutMS
+		c.GCS.Timeout = cc.GCS.Timeout
+	}
+
+	if cc.AzureBlob != nil {
+		c.AzureBlob.Endpoint = cc.AzureBlob.Endpoint
+		c.AzureBlob.AccountName = cc.AzureBlob.AccountName
+		c.AzureBlob.AccountKey = cc.AzureBlob.AccountKey
+		c.AzureBlob.SASToken = cc.AzureBlob.SASToken
+		c.AzureBlob.Container = cc.AzureBlob.Container
+		c.AzureBlob.Prefix = cc.AzureBlob.Prefix
+		c.AzureBlob.TimeoutMS = cc.AzureBlob.TimeoutMS
+		c.AzureBlob.Timeout = cc.AzureBlob.Timeout
+	}
+
+	if cc.Memcached != nil {
+		c.Memcached.Servers = make([]string, len(cc.Memcached.Servers))
+		copy(c.Memcached.Servers, cc.Memcached.Servers)
+		c.Memcached.VirtualNodes = cc.Memcached.VirtualNodes
+		c.Memcached.Username = cc.Memcached.Username
+		c.Memcached.Password = cc.Memcached.Password
+		c.Memcached.UseTLS = cc.Memcached.UseTLS
+		c.Memcached.InsecureSkipVerify = cc.Memcached.InsecureSkipVerify
+		if cc.Memcached.CertificateAuthorityPaths != nil {
+			c.Memcached.CertificateAuthorityPaths = make([]string,
+				len(cc.Memcached.CertificateAuthorityPaths))
+			copy(c.Memcached.CertificateAuthorityPaths, cc.Memcached.CertificateAuthorityPaths)
+		}
+		c.Memcached.ServerName = cc.Memcached.ServerName
+		c.Memcached.ClientCertPath = cc.Memcached.ClientCertPath
+		c.Memcached.ClientKeyPath = cc.Memcached.ClientKeyPath
+		c.Memcached.MaxIdleConns = cc.Memcached.MaxIdleConns
+		c.Memcached.TimeoutMS = cc.Memcached.TimeoutMS
+		c.Memcached.Timeout = cc.Memcached.Timeout
+	}
+
+	if cc.Etcd != nil {
+		c.Etcd = cc.Etcd.Clone()
+	}
+
+	if cc.Cassandra != nil {
+		c.Cassandra = cc.Cassandra.Clone()
+	}
+
+	if cc.Logstore != nil {
+		c.Logstore = cc.Logstore.Clone()
+	}
+
+	c.Tiered.L2CacheType = cc.Tiered.L2CacheType
+	c.Tiered.L1MaxSizeBytes = cc.Tiered.L1MaxSizeBytes
+	c.Tiered.L1MaxSizeObjects = cc.Tiered.L1MaxSizeObjects
+	c.Tiered.L1PromotionTTLSecs = cc.Tiered.L1PromotionTTLSecs
+	c.Tiered.L1PromotionTTL = cc.Tiered.L1PromotionTTL
+
+	if cc.Migration != nil {
+		c.Migration = cc.Migration.Clone()
+	}
+
+	if cc.Encryption != nil {
+		c.Encryption = cc.Encryption.Clone()
+	}
+
+	if cc.Warming != nil {
+		c.Warming = cc.Warming.Clone()
+	}
 
 	c.BBolt.Bucket = cc.BBolt.Bucket
 	c.BBolt.Filename = cc.BBolt.Filename
+	c.BBolt.BucketPerOrigin = cc.BBolt.BucketPerOrigin
+	if cc.BBolt.OriginBuckets != nil {
+		c.BBolt.OriginBuckets = make(map[string]string, len(cc.BBolt.OriginBuckets))
+		for k, v := range cc.BBolt.OriginBuckets {
+			c.BBolt.OriginBuckets[k] = v
+		}
+	}
 
 	c.Redis.ClientType = cc.Redis.ClientType
 	c.Redis.DB = cc.Redis.DB
@@ -108,6 +318,9 @@ func (cc *Options) Clone() *Options {
 	c.Redis.ReadTimeoutMS = cc.Redis.ReadTimeoutMS
 	c.Redis.SentinelMaster = cc.Redis.SentinelMaster
 	c.Redis.WriteTimeoutMS = cc.Redis.WriteTimeoutMS
+	c.Redis.PipelineBatchSize = cc.Redis.PipelineBatchSize
+	c.Redis.ClientCacheMaxObjects = cc.Redis.ClientCacheMaxObjects
+	c.Redis.ClientCacheTTLMS = cc.Redis.ClientCacheTTLMS
 
 	return c
 
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the cache namespace quota options for an origin
+package options
+
+import "errors"
+
+// Options configures the share of a cache that an origin's namespace may occupy. When
+// multiple origins share a cache, each origin's quota is enforced independently of the
+// others and of the cache's overall size limits
+type Options struct {
+	// MaxSizeBytes is the maximum number of bytes the origin's objects may occupy in the
+	// cache before the Index evicts them. 0 means no byte quota
+	MaxSizeBytes int64 `toml:"max_size_bytes"`
+	// MaxSizeObjects is the maximum number of objects the origin may store in the cache
+	// before the Index evicts them. 0 means no object quota
+	MaxSizeObjects int64 `toml:"max_size_objects"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		MaxSizeBytes:   o.MaxSizeBytes,
+		MaxSizeObjects: o.MaxSizeObjects,
+	}
+}
+
+// Equal returns true if all members of the subject and provided Options are identical
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return o.MaxSizeBytes == o2.MaxSizeBytes &&
+		o.MaxSizeObjects == o2.MaxSizeObjects
+}
+
+// Enabled returns true if a byte or object quota is configured
+func (o *Options) Enabled() bool {
+	return o != nil && (o.MaxSizeBytes > 0 || o.MaxSizeObjects > 0)
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.MaxSizeBytes < 0 {
+		return errors.New("cache_quota max_size_bytes must not be negative")
+	}
+	if o.MaxSizeObjects < 0 {
+		return errors.New("cache_quota max_size_objects must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestOptions(t *testing.T) {
+
+	o := NewOptions()
+	if o.Enabled() {
+		t.Error("expected quota to be disabled by default")
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	o.MaxSizeObjects = 10
+	if !o.Enabled() {
+		t.Error("expected quota to be enabled")
+	}
+
+	o2 := o.Clone()
+	if !o.Equal(o2) {
+		t.Error("expected clone to be equal")
+	}
+	o2.MaxSizeBytes = 1024
+	if o.Equal(o2) {
+		t.Error("expected options to differ")
+	}
+	if o.Equal(nil) {
+		t.Error("expected options to differ from nil")
+	}
+
+	var o3 *Options
+	if o3.Enabled() {
+		t.Error("expected nil quota to be disabled")
+	}
+
+	o.MaxSizeBytes = -1
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for negative max_size_bytes")
+	}
+	o.MaxSizeBytes = 0
+	o.MaxSizeObjects = -1
+	if err := o.Validate(); err == nil {
+		t.Error("expected error for negative max_size_objects")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// batches calls f with consecutive slices of keys, each no longer than the pipeline batch size
+func (c *Cache) batches(keys []string, f func([]string) error) error {
+	n := c.Config.Redis.PipelineBatchSize
+	if n <= 0 {
+		n = len(keys)
+	}
+	for len(keys) > 0 {
+		if n > len(keys) {
+			n = len(keys)
+		}
+		if err := f(keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// BulkRemove removes a list of objects from the cache, with one pipelined DEL per key,
+// so that the keys of a Redis Cluster needn't be in the same hash slot
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.Logger.Debug("redis cache bulk remove", tl.Pairs{"keys": len(cacheKeys)})
+	c.invalidateClientCache(cacheKeys...)
+	err := c.batches(cacheKeys, func(keys []string) error {
+		pipe := c.client.Pipeline()
+		for _, k := range keys {
+			pipe.Del(k)
+		}
+		_, err := pipe.Exec()
+		return err
+	})
+	if err != nil {
+		c.Logger.Warn("redis cache bulk remove failed",
+			tl.Pairs{"cacheName": c.Name, "detail": err.Error()})
+	}
+	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
+}
+
+// BulkRetrieve returns the objects cached at the provided keys. Keys are retrieved with
+// MGET, or with pipelined GETs in a Redis Cluster, whose keys may be in different hash slots
+func (c *Cache) BulkRetrieve(cacheKeys []string) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(cacheKeys))
+	keys := make([]string, 0, len(cacheKeys))
+	for _, k := range cacheKeys {
+		if c.clientCache != nil {
+			if data, ok := c.clientCache.get(k); ok {
+				metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "client_cache", "hit")
+				metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit",
+					float64(len(data)))
+				out[k] = data
+				continue
+			}
+		}
+		keys = append(keys, k)
+	}
+
+	_, isCluster := c.client.(*redis.ClusterClient)
+	err := c.batches(keys, func(keys []string) error {
+		values := make([]interface{}, len(keys))
+		if isCluster {
+			pipe := c.client.Pipeline()
+			cmds := make([]*redis.StringCmd, len(keys))
+			for i, k := range keys {
+				cmds[i] = pipe.Get(k)
+			}
+			if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+				return err
+			}
+			for i, cmd := range cmds {
+				if v, err := cmd.Result(); err == nil {
+					values[i] = v
+				}
+			}
+		} else {
+			var err error
+			if values, err = c.client.MGet(keys...).Result(); err != nil {
+				return err
+			}
+		}
+		for i, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				metrics.ObserveCacheMiss(keys[i], c.Name, c.Config.CacheType)
+				continue
+			}
+			data, err := cache.DecodeValue(c.Config, keys[i], []byte(s))
+			if err != nil {
+				metrics.ObserveCacheMiss(keys[i], c.Name, c.Config.CacheType)
+				continue
+			}
+			if c.clientCache != nil {
+				c.clientCache.set(keys[i], data)
+			}
+			metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+			out[keys[i]] = data
+		}
+		return nil
+	})
+	c.Logger.Debug("redis cache bulk retrieve",
+		tl.Pairs{"keys": len(cacheKeys), "hits": len(out)})
+	return out, err
+}
+
+// BulkStore places the provided objects in the cache with the ttl, with pipelined SETs,
+// since MSET can't set the objects' TTL
+func (c *Cache) BulkStore(objects map[string][]byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	keys := make([]string, 0, len(objects))
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	c.invalidateClientCache(keys...)
+	c.Logger.Debug("redis cache bulk store", tl.Pairs{"keys": len(keys)})
+	return c.batches(keys, func(keys []string) error {
+		pipe := c.client.Pipeline()
+		for _, k := range keys {
+			data, err := cache.EncodeValue(c.Config, k, objects[k])
+			if err != nil {
+				pipe.Close()
+				return err
+			}
+			metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
+			pipe.Set(k, data, ttl)
+		}
+		_, err := pipe.Exec()
+		return err
+	})
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// clientCache holds the most-recently-retrieved objects of a Redis cache in memory, so that
+// hot objects are served without a round trip to Redis. Objects are removed from the
+// clientCache when they are written or removed through the same Trickster process, and
+// otherwise expire after the clientCache's ttl, which bounds how long an object written by
+// another process may be served stale
+type clientCache struct {
+	maxObjects int
+	ttl        time.Duration
+	lru        *list.List
+	entries    map[string]*list.Element
+	mtx        sync.Mutex
+}
+
+type clientCacheEntry struct {
+	key        string
+	data       []byte
+	expiration time.Time
+}
+
+func newClientCache(maxObjects int, ttl time.Duration) *clientCache {
+	return &clientCache{
+		maxObjects: maxObjects,
+		ttl:        ttl,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the object cached at the key, if present and unexpired
+func (cc *clientCache) get(key string) ([]byte, bool) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+	e, ok := cc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	ce := e.Value.(*clientCacheEntry)
+	if time.Now().After(ce.expiration) {
+		cc.lru.Remove(e)
+		delete(cc.entries, key)
+		return nil, false
+	}
+	cc.lru.MoveToFront(e)
+	data := make([]byte, len(ce.data))
+	copy(data, ce.data)
+	return data, true
+}
+
+// set caches a copy of the object at the key, evicting the least-recently-used
+// object if the clientCache is full
+func (cc *clientCache) set(key string, data []byte) {
+	d := make([]byte, len(data))
+	copy(d, data)
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+	ce := &clientCacheEntry{key: key, data: d, expiration: time.Now().Add(cc.ttl)}
+	if e, ok := cc.entries[key]; ok {
+		e.Value = ce
+		cc.lru.MoveToFront(e)
+		return
+	}
+	cc.entries[key] = cc.lru.PushFront(ce)
+	for cc.lru.Len() > cc.maxObjects {
+		e := cc.lru.Back()
+		cc.lru.Remove(e)
+		delete(cc.entries, e.Value.(*clientCacheEntry).key)
+	}
+}
+
+// remove removes the objects cached at the keys
+func (cc *clientCache) remove(keys ...string) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+	for _, key := range keys {
+		if e, ok := cc.entries[key]; ok {
+			cc.lru.Remove(e)
+			delete(cc.entries, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientCache(t *testing.T) {
+
+	cc := newClientCache(2, time.Minute)
+
+	cc.set("a", []byte("data-a"))
+	cc.set("b", []byte("data-b"))
+
+	// accessing a makes b the least recently used entry
+	if d, ok := cc.get("a"); !ok || string(d) != "data-a" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data-a", d)
+	}
+
+	cc.set("c", []byte("data-c"))
+	if _, ok := cc.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := cc.get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+
+	cc.remove("a", "c")
+	if _, ok := cc.get("a"); ok {
+		t.Error("expected a to be removed")
+	}
+
+	cc = newClientCache(2, time.Millisecond)
+	cc.set("a", []byte("data-a"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cc.get("a"); ok {
+		t.Error("expected a to be expired")
+	}
+}
@@ -64,6 +64,14 @@ type Options struct {
 	IdleTimeoutMS int `toml:"idle_timeout_ms"`
 	// IdleCheckFrequencyMS is the frequency of idle checks made by idle connections reaper.
 	IdleCheckFrequencyMS int `toml:"idle_check_frequency_ms"`
+	// PipelineBatchSize is the maximum number of commands sent to Redis in each pipeline
+	// by bulk operations
+	PipelineBatchSize int `toml:"pipeline_batch_size"`
+	// ClientCacheMaxObjects is the maximum number of hot objects held in Trickster's memory
+	// after they are retrieved from Redis. 0 disables client-side caching
+	ClientCacheMaxObjects int `toml:"client_cache_max_objects"`
+	// ClientCacheTTLMS is how long an object is held in the client-side cache
+	ClientCacheTTLMS int `toml:"client_cache_ttl_ms"`
 }
 
 // NewOptions returns a new Redis Options Reference with default values set
@@ -73,5 +81,8 @@ func NewOptions() *Options {
 		Protocol:   d.DefaultRedisProtocol,
 		Endpoint:   d.DefaultRedisEndpoint,
 		Endpoints:  []string{d.DefaultRedisEndpoint},
+
+		PipelineBatchSize: d.DefaultRedisPipelineBatchSize,
+		ClientCacheTTLMS:  d.DefaultRedisClientCacheTTLMS,
 	}
 }
@@ -19,6 +19,8 @@
 package redis
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -34,6 +36,12 @@ import (
 // Redis is the string "redis"
 const Redis = "redis"
 
+// scanCount is the number of keys requested from Redis in each SCAN iteration
+const scanCount = 1000
+
+// globEscaper escapes the characters that have special meaning in a SCAN MATCH pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
 // Cache represents a redis cache object that conforms to the Cache interface
 type Cache struct {
 	Name   string
@@ -41,8 +49,9 @@ type Cache struct {
 	Logger *tl.Logger
 	locker locks.NamedLocker
 
-	client redis.Cmdable
-	closer func() error
+	client      redis.Cmdable
+	closer      func() error
+	clientCache *clientCache
 }
 
 // Locker returns the cache's locker
@@ -65,6 +74,11 @@ func (c *Cache) Connect() error {
 	c.Logger.Info("connecting to redis",
 		tl.Pairs{"protocol": c.Config.Redis.Protocol, "Endpoint": c.Config.Redis.Endpoint})
 
+	if c.Config.Redis.ClientCacheMaxObjects > 0 {
+		c.clientCache = newClientCache(c.Config.Redis.ClientCacheMaxObjects,
+			durationFromMS(c.Config.Redis.ClientCacheTTLMS))
+	}
+
 	switch c.Config.Redis.ClientType {
 	case "sentinel":
 		opts, err := c.sentinelOpts()
@@ -96,18 +110,40 @@ func (c *Cache) Connect() error {
 
 // Store places the the data into the Redis Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	ttl = cache.ClampTTL(c.Config, ttl)
+	data, err := cache.EncodeValue(c.Config, cacheKey, data)
+	if err != nil {
+		return err
+	}
 	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 	c.Logger.Debug("redis cache store", tl.Pairs{"key": cacheKey})
+	c.invalidateClientCache(cacheKey)
 	return c.client.Set(cacheKey, data, ttl).Err()
 }
 
 // Retrieve gets data from the Redis Cache using the provided Key
 // because Redis manages Object Expiration internally, allowExpired is not used.
 func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if c.clientCache != nil {
+		if data, ok := c.clientCache.get(cacheKey); ok {
+			c.Logger.Debug("redis client-side cache retrieve", tl.Pairs{"key": cacheKey})
+			metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "client_cache", "hit")
+			metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
+			return data, status.LookupStatusHit, nil
+		}
+	}
+
 	res, err := c.client.Get(cacheKey).Result()
 
+	var data []byte
 	if err == nil {
-		data := []byte(res)
+		data, err = cache.DecodeValue(c.Config, cacheKey, []byte(res))
+	}
+
+	if err == nil {
+		if c.clientCache != nil {
+			c.clientCache.set(cacheKey, data)
+		}
 		c.Logger.Debug("redis cache retrieve", tl.Pairs{"key": cacheKey})
 		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return data, status.LookupStatusHit, nil
@@ -127,20 +163,44 @@ func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.Loo
 // Remove removes an object in cache, if present
 func (c *Cache) Remove(cacheKey string) {
 	c.Logger.Debug("redis cache remove", tl.Pairs{"key": cacheKey})
+	c.invalidateClientCache(cacheKey)
 	c.client.Del(cacheKey)
 	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, 0)
 }
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	ttl = cache.ClampTTL(c.Config, ttl)
 	c.client.Expire(cacheKey, ttl)
 }
 
-// BulkRemove removes a list of objects from the cache. noLock is not used for Redis
-func (c *Cache) BulkRemove(cacheKeys []string) {
-	c.Logger.Debug("redis cache bulk remove", tl.Pairs{})
-	c.client.Del(cacheKeys...)
-	metrics.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
+// Keys returns the keys of the cached objects that begin with prefix. In a Redis Cluster,
+// the keys of each master are scanned
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	match := globEscaper.Replace(prefix) + "*"
+	cc, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scan(c.client, match)
+	}
+	var keys []string
+	var mtx sync.Mutex
+	err := cc.ForEachMaster(func(client *redis.Client) error {
+		k, err := scan(client, match)
+		mtx.Lock()
+		keys = append(keys, k...)
+		mtx.Unlock()
+		return err
+	})
+	return keys, err
+}
+
+func scan(client redis.Cmdable, match string) ([]string, error) {
+	var keys []string
+	it := client.Scan(0, match, scanCount).Iterator()
+	for it.Next() {
+		keys = append(keys, it.Val())
+	}
+	return keys, it.Err()
 }
 
 // Close disconnects from the Redis Cache
@@ -149,6 +209,12 @@ func (c *Cache) Close() error {
 	return c.closer()
 }
 
+func (c *Cache) invalidateClientCache(cacheKeys ...string) {
+	if c.clientCache != nil {
+		c.clientCache.remove(cacheKeys...)
+	}
+}
+
 func durationFromMS(input int) time.Duration {
 	return time.Duration(int64(input)) * time.Millisecond
 }
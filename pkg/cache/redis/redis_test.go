@@ -17,10 +17,12 @@
 package redis
 
 import (
+	"sort"
 	"strconv"
 	"testing"
 	"time"
 
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	ro "github.com/tricksterproxy/trickster/pkg/cache/redis/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
@@ -517,6 +519,107 @@ func BenchmarkCache_BulkRemove(b *testing.B) {
 	}
 }
 
+func TestCache_BulkStoreRetrieve(t *testing.T) {
+
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+	rc.Config.Redis.PipelineBatchSize = 2
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer rc.Close()
+
+	objects := map[string][]byte{"a": []byte("data-a"), "b": []byte("data-b"),
+		"c": []byte("data-c")}
+
+	// it should store the values in batches
+	err = rc.BulkStore(objects, time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// it should retrieve the stored values and skip the missing one
+	out, err := rc.BulkRetrieve([]string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != 3 {
+		t.Errorf("expected %d got %d", 3, len(out))
+	}
+	for k, v := range objects {
+		if string(out[k]) != string(v) {
+			t.Errorf("wanted \"%s\". got \"%s\".", v, out[k])
+		}
+	}
+
+	rc.BulkRemove([]string{"a", "b", "c"})
+
+	out, err = rc.BulkRetrieve([]string{"a", "b", "c"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("expected %d got %d", 0, len(out))
+	}
+}
+
+func TestCache_ClientCache(t *testing.T) {
+
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+	rc.Config.Redis.ClientCacheMaxObjects = 10
+	rc.Config.Redis.ClientCacheTTLMS = 60000
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer rc.Close()
+
+	if rc.clientCache == nil {
+		t.Fatal("expected non-nil client cache")
+	}
+
+	err = rc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the first retrieve should populate the client-side cache
+	_, _, err = rc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := rc.clientCache.get(cacheKey); !ok {
+		t.Errorf("expected %s in client cache", cacheKey)
+	}
+
+	// a store should invalidate the client-side entry
+	err = rc.Store(cacheKey, []byte("data2"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	data, ls, err := rc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data2" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data2", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// a remove should invalidate the client-side entry
+	rc.Remove(cacheKey)
+	_, ls, _ = rc.Retrieve(cacheKey, false)
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+}
+
 func TestLocker(t *testing.T) {
 	cache := Cache{locker: locks.NewNamedLocker()}
 	l := cache.Locker()
@@ -526,3 +629,101 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func TestCache_Keys(t *testing.T) {
+
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer rc.Close()
+
+	for _, k := range []string{"a*.dpc.1", "a*.opc.1", "ab.dpc.1", "b.dpc.1"} {
+		if err = rc.Store(k, []byte("data"), time.Duration(60)*time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// the prefix's glob characters must be matched literally
+	keys, err := rc.Keys("a*.")
+	if err != nil {
+		t.Error(err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a*.dpc.1" || keys[1] != "a*.opc.1" {
+		t.Errorf("expected %v got %v", []string{"a*.dpc.1", "a*.opc.1"}, keys)
+	}
+}
+
+func TestRedisCache_Compression(t *testing.T) {
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+	rc.Config.CompressionCodec = "gzip"
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = rc.Store(cacheKey, []byte("datadatadatadata"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the value should be retrievable after the codec is changed
+	rc.Config.CompressionCodec = "snappy"
+	data, ls, err := rc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if string(data) != "datadatadatadata" {
+		t.Errorf("expected %s got %s", "datadatadatadata", string(data))
+	}
+}
+
+func TestRedisCache_Encryption(t *testing.T) {
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+	rc.Config.Encryption = eo.NewOptions()
+	rc.Config.Encryption.ActiveKeyID = "k1"
+	rc.Config.Encryption.Keys["k1"] = &eo.KeyOptions{Key: "ZmVkY2JhOTg3NjU0MzIxMA=="}
+	if err := rc.Config.Encryption.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = rc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the value should be stored encrypted
+	res, err := rc.client.Get(cacheKey).Result()
+	if err != nil {
+		t.Error(err)
+	}
+	if res == "data" {
+		t.Error("value was stored in plaintext")
+	}
+
+	data, ls, err := rc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+}
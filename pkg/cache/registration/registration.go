@@ -20,12 +20,24 @@ package registration
 
 import (
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/azureblob"
 	"github.com/tricksterproxy/trickster/pkg/cache/badger"
 	"github.com/tricksterproxy/trickster/pkg/cache/bbolt"
+	"github.com/tricksterproxy/trickster/pkg/cache/cassandra"
+	"github.com/tricksterproxy/trickster/pkg/cache/dynamodb"
+	"github.com/tricksterproxy/trickster/pkg/cache/etcd"
 	"github.com/tricksterproxy/trickster/pkg/cache/filesystem"
+	"github.com/tricksterproxy/trickster/pkg/cache/gcs"
+	"github.com/tricksterproxy/trickster/pkg/cache/logstore"
+	"github.com/tricksterproxy/trickster/pkg/cache/memcached"
 	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/migration"
 	"github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/redis"
+	"github.com/tricksterproxy/trickster/pkg/cache/s3"
+	"github.com/tricksterproxy/trickster/pkg/cache/tiered"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
@@ -37,6 +49,16 @@ const (
 	ctRedis      = "redis"
 	ctBBolt      = "bbolt"
 	ctBadger     = "badger"
+	ctS3         = "s3"
+	ctDynamoDB   = "dynamodb"
+	ctGCS        = "gcs"
+	ctAzureBlob  = "azureblob"
+	ctMemcached  = "memcached"
+	ctEtcd       = "etcd"
+	ctCassandra  = "cassandra"
+	ctLogstore   = "logstore"
+	ctTiered     = "tiered"
+	ctMigration  = "migration"
 )
 
 // Caches maintains a list of active caches
@@ -72,6 +94,21 @@ func CloseCaches(caches map[string]cache.Cache) error {
 
 // NewCache returns a Cache object based on the provided config.CachingConfig
 func NewCache(cacheName string, cfg *options.Options, logger *tl.Logger) cache.Cache {
+	c := newCache(cacheName, cfg, logger)
+	c.SetLocker(locks.NewNamedLocker())
+	c.Connect()
+	if cfg.Warming.Enabled() && cfg.Warmer == nil {
+		var err error
+		cfg.Warmer, err = warming.New(cacheName, cfg.Warming)
+		if err != nil {
+			logger.Warn("could not restore recorded cache warming queries",
+				tl.Pairs{"cacheName": cacheName, "path": cfg.Warming.StatePath, "detail": err.Error()})
+		}
+	}
+	return c
+}
+
+func newCache(cacheName string, cfg *options.Options, logger *tl.Logger) cache.Cache {
 
 	var c cache.Cache
 
@@ -84,12 +121,44 @@ func NewCache(cacheName string, cfg *options.Options, logger *tl.Logger) cache.C
 		c = &bbolt.Cache{Name: cacheName, Config: cfg, Logger: logger}
 	case ctBadger:
 		c = &badger.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctS3:
+		c = s3.New(cacheName, cfg, logger)
+	case ctDynamoDB:
+		c = &dynamodb.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctGCS:
+		c = gcs.New(cacheName, cfg, logger)
+	case ctAzureBlob:
+		c = azureblob.New(cacheName, cfg, logger)
+	case ctMemcached:
+		c = &memcached.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctEtcd:
+		c = &etcd.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctCassandra:
+		c = &cassandra.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctLogstore:
+		c = &logstore.Cache{Name: cacheName, Config: cfg, Logger: logger}
+	case ctTiered:
+		// the persistent tier is configured as a cache of its own type, sharing the
+		// remainder of the tiered cache's options
+		l2 := cfg.Clone()
+		l2.CacheType = cfg.Tiered.L2CacheType
+		l2.CacheTypeID = types.Names[l2.CacheType]
+		c = &tiered.Cache{Name: cacheName, Config: cfg, Logger: logger,
+			L2: newCache(cacheName, l2, logger)}
+	case ctMigration:
+		// the old and new caches are each configured as a cache of their own type, sharing
+		// the remainder of the migration cache's options
+		oc := cfg.Clone()
+		oc.CacheType = cfg.Migration.OldCacheType
+		oc.CacheTypeID = types.Names[oc.CacheType]
+		nc := cfg.Clone()
+		nc.CacheType = cfg.Migration.NewCacheType
+		nc.CacheTypeID = types.Names[nc.CacheType]
+		c = &migration.Cache{Name: cacheName, Config: cfg, Logger: logger,
+			Old: newCache(cacheName, oc, logger), New: newCache(cacheName, nc, logger)}
 	default:
 		// Default to MemoryCache
 		c = &memory.Cache{Name: cacheName, Config: cfg, Logger: logger}
 	}
-
-	c.SetLocker(locks.NewNamedLocker())
-	c.Connect()
 	return c
 }
@@ -21,13 +21,24 @@ import (
 	"os"
 	"testing"
 
+	abo "github.com/tricksterproxy/trickster/pkg/cache/azureblob/options"
 	bao "github.com/tricksterproxy/trickster/pkg/cache/badger/options"
 	bbo "github.com/tricksterproxy/trickster/pkg/cache/bbolt/options"
+	cso "github.com/tricksterproxy/trickster/pkg/cache/cassandra/options"
+	do "github.com/tricksterproxy/trickster/pkg/cache/dynamodb/options"
+	eto "github.com/tricksterproxy/trickster/pkg/cache/etcd/options"
 	flo "github.com/tricksterproxy/trickster/pkg/cache/filesystem/options"
+	gso "github.com/tricksterproxy/trickster/pkg/cache/gcs/options"
 	io "github.com/tricksterproxy/trickster/pkg/cache/index/options"
+	lso "github.com/tricksterproxy/trickster/pkg/cache/logstore/options"
+	mco "github.com/tricksterproxy/trickster/pkg/cache/memcached/options"
+	mgo "github.com/tricksterproxy/trickster/pkg/cache/migration/options"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	ro "github.com/tricksterproxy/trickster/pkg/cache/redis/options"
+	so "github.com/tricksterproxy/trickster/pkg/cache/s3/options"
+	to "github.com/tricksterproxy/trickster/pkg/cache/tiered/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	wo "github.com/tricksterproxy/trickster/pkg/cache/warming/options"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
@@ -46,10 +57,12 @@ func TestLoadCachesFromConfig(t *testing.T) {
 		switch v {
 		case types.CacheTypeBbolt:
 			defer os.RemoveAll(cfg.BBolt.Filename)
-		case types.CacheTypeFilesystem:
+		case types.CacheTypeFilesystem, types.CacheTypeTiered:
 			defer os.RemoveAll(cfg.Filesystem.CachePath)
 		case types.CacheTypeBadgerDB:
 			defer os.RemoveAll(cfg.Badger.Directory)
+		case types.CacheTypeLogstore:
+			defer os.RemoveAll(cfg.Logstore.Directory)
 		}
 	}
 
@@ -74,10 +87,30 @@ func TestLoadCachesFromConfig(t *testing.T) {
 
 }
 
+func TestNewCacheWarmer(t *testing.T) {
+
+	cfg := newCacheConfig(t, "memory")
+	c := NewCache("default", cfg, tl.ConsoleLogger("error"))
+	defer c.Close()
+	if cfg.Warmer != nil {
+		t.Error("expected nil warmer")
+	}
+
+	cfg = newCacheConfig(t, "memory")
+	cfg.Warming = wo.NewOptions()
+	cfg.Warming.TopN = 10
+	c2 := NewCache("default", cfg, tl.ConsoleLogger("error"))
+	defer c2.Close()
+	if !cfg.Warmer.Recording() {
+		t.Error("expected recording warmer")
+	}
+}
+
 func newCacheConfig(t *testing.T, cacheType string) *co.Options {
 
 	bd := "."
 	fd := "."
+	ld := "."
 	var err error
 
 	ctid, ok := types.Names[cacheType]
@@ -92,19 +125,41 @@ func newCacheConfig(t *testing.T, cacheType string) *co.Options {
 			t.Error(err)
 		}
 
-	case types.CacheTypeFilesystem:
+	case types.CacheTypeFilesystem, types.CacheTypeTiered:
 		fd, err = ioutil.TempDir("/tmp", cacheType)
 		if err != nil {
 			t.Error(err)
 		}
+
+	case types.CacheTypeLogstore:
+		ld, err = ioutil.TempDir("/tmp", cacheType)
+		if err != nil {
+			t.Error(err)
+		}
 	}
 
+	lo := lso.NewOptions()
+	lo.Directory = ld
+
+	fo := flo.NewOptions()
+	fo.CachePath = fd
+
 	return &co.Options{
 		CacheType:  cacheType,
 		Redis:      &ro.Options{Protocol: "tcp", Endpoint: "redis:6379", Endpoints: []string{"redis:6379"}},
-		Filesystem: &flo.Options{CachePath: fd},
+		Filesystem: fo,
 		BBolt:      &bbo.Options{Filename: "/tmp/test.db", Bucket: "trickster_test"},
 		Badger:     &bao.Options{Directory: bd, ValueDirectory: bd},
+		S3:         &so.Options{Endpoint: "http://127.0.0.1:1", Bucket: "trickster_test"},
+		DynamoDB:   &do.Options{Endpoint: "http://127.0.0.1:1", Table: "trickster_test"},
+		GCS:        &gso.Options{Endpoint: "http://127.0.0.1:1", Bucket: "trickster_test"},
+		AzureBlob:  &abo.Options{Endpoint: "http://127.0.0.1:1", Container: "trickster_test"},
+		Memcached:  &mco.Options{Servers: []string{"127.0.0.1:1"}, VirtualNodes: 160},
+		Etcd:       &eto.Options{Endpoints: []string{"http://127.0.0.1:1"}, KeyPrefix: "/trickster_test/"},
+		Cassandra:  &cso.Options{Hosts: []string{"127.0.0.1:1"}, Keyspace: "trickster_test", Table: "cache"},
+		Logstore:   lo,
+		Tiered:     to.NewOptions(),
+		Migration:  &mgo.Options{OldCacheType: "memcached", NewCacheType: "etcd"},
 		Index: &io.Options{
 			ReapIntervalSecs:      3,
 			FlushIntervalSecs:     5,
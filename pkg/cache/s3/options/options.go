@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// MinPartSizeBytes is the smallest part size of a multipart upload that S3 accepts
+const MinPartSizeBytes = 5242880
+
+// ErrMissingBucket is returned when no bucket is configured
+var ErrMissingBucket = errors.New("s3 bucket must be provided")
+
+// ErrInvalidEndpoint is returned when the endpoint is not an http or https URL
+var ErrInvalidEndpoint = errors.New("s3 endpoint must be an http or https URL")
+
+// ErrInvalidPartSize is returned when the part size is smaller than S3 accepts
+var ErrInvalidPartSize = errors.New("s3 part_size_bytes must be at least 5242880")
+
+// ErrInvalidTimeout is returned when the request timeout is not positive
+var ErrInvalidTimeout = errors.New("s3 timeout_ms must be greater than 0")
+
+// Options is a collection of Configurations for storing cached data in an S3-compatible bucket
+type Options struct {
+	// Endpoint is the base URL of the S3 API. The default is the AWS S3 endpoint of the Region
+	Endpoint string `toml:"endpoint"`
+	// Bucket is the name of the bucket in which objects are stored
+	Bucket string `toml:"bucket"`
+	// Prefix is prepended to the cache key to form the name of each object
+	Prefix string `toml:"prefix"`
+	// Region is the region of the bucket, which is used to sign requests
+	Region string `toml:"region"`
+	// AccessKeyID is the access key of the credentials. When empty, the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used
+	AccessKeyID string `toml:"access_key_id"`
+	// SecretAccessKey is the secret key of the credentials
+	SecretAccessKey string `toml:"secret_access_key"`
+	// SessionToken is the session token of temporary credentials
+	SessionToken string `toml:"session_token"`
+	// PathStyle addresses the bucket in the URL path instead of the hostname, as required
+	// by most S3-compatible services such as MinIO
+	PathStyle bool `toml:"path_style"`
+	// PartSizeBytes is the size of the parts in which objects larger than it are uploaded
+	PartSizeBytes int `toml:"part_size_bytes"`
+	// TimeoutMS is the timeout of each request to the S3 API
+	TimeoutMS int `toml:"timeout_ms"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new S3 Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		Prefix:        d.DefaultS3Prefix,
+		Region:        d.DefaultS3Region,
+		PartSizeBytes: d.DefaultS3PartSizeBytes,
+		TimeoutMS:     d.DefaultS3TimeoutMS,
+		Timeout:       time.Duration(d.DefaultS3TimeoutMS) * time.Millisecond,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	if o.Bucket == "" {
+		return ErrMissingBucket
+	}
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	if o.PartSizeBytes < MinPartSizeBytes {
+		return ErrInvalidPartSize
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the millisecond-based options
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Error("expected non-nil options")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		bucket   string
+		endpoint string
+		partSize int
+		timeout  int
+		expected error
+	}{
+		{"trickster", "", MinPartSizeBytes, 1000, nil},
+		{"trickster", "http://minio:9000", MinPartSizeBytes, 1000, nil},
+		{"", "", MinPartSizeBytes, 1000, ErrMissingBucket},
+		{"trickster", "minio:9000", MinPartSizeBytes, 1000, ErrInvalidEndpoint},
+		{"trickster", "ftp://minio", MinPartSizeBytes, 1000, ErrInvalidEndpoint},
+		{"trickster", "", MinPartSizeBytes - 1, 1000, ErrInvalidPartSize},
+		{"trickster", "", MinPartSizeBytes, 0, ErrInvalidTimeout},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.Bucket = test.bucket
+		o.Endpoint = test.endpoint
+		o.PartSizeBytes = test.partSize
+		o.TimeoutMS = test.timeout
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.TimeoutMS = 250
+	o.SetDurations()
+	if o.Timeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.Timeout)
+	}
+}
@@ -0,0 +1,434 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package s3 is the S3 implementation of the Trickster Cache. Objects are stored in an
+// S3-compatible bucket (AWS S3, MinIO, Ceph RGW), so that a durable cache can be shared
+// by each Trickster using the bucket
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	so "github.com/tricksterproxy/trickster/pkg/cache/s3/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+// headerExpiration is the object metadata header holding the object's expiration, in
+// milliseconds since the epoch
+const headerExpiration = "X-Amz-Meta-Trickster-Expiration"
+
+// maxBulkDelete is the most objects that can be removed by a single DeleteObjects request
+const maxBulkDelete = 1000
+
+// New returns a new S3 Cache
+func New(cacheName string, cfg *options.Options, logger *tl.Logger) *objectstore.Cache {
+	return &objectstore.Cache{Name: cacheName, Config: cfg, Logger: logger,
+		Client: &Client{Options: cfg.S3}, Prefix: cfg.S3.Prefix}
+}
+
+// Client is the S3 API client of an S3 Cache
+type Client struct {
+	Options *so.Options
+
+	client   *http.Client
+	endpoint *url.URL
+	creds    sigv4.Credentials
+}
+
+// Connect verifies that the configured bucket is accessible
+func (c *Client) Connect() error {
+	o := c.Options
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + o.Region + ".amazonaws.com"
+	}
+	var err error
+	if c.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/")); err != nil {
+		return err
+	}
+
+	c.creds = sigv4.NewCredentials(o.AccessKeyID, o.SecretAccessKey, o.SessionToken)
+	c.client = &http.Client{Timeout: o.Timeout}
+
+	// a HEAD of the bucket is the cheapest request that verifies the endpoint and credentials
+	resp, err := c.do(http.MethodHead, c.bucketURL(""), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 bucket %s is not accessible: status %d", o.Bucket, resp.StatusCode)
+	}
+	return nil
+}
+
+// Put writes the object to the bucket
+func (c *Client) Put(name string, data []byte, expiration time.Time) error {
+	h := objectHeader(expiration)
+	if len(data) > c.Options.PartSizeBytes {
+		return c.putMultipart(name, data, h)
+	}
+	resp, err := c.do(http.MethodPut, c.objectURL(name, ""), h, data)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// initiateMultipartUploadResult is the response to a CreateMultipartUpload request
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completeMultipartUpload is the body of a CompleteMultipartUpload request
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// putMultipart uploads the data in parts of PartSizeBytes. The object is only visible once
+// the upload is completed, so a partially uploaded object is never read
+func (c *Client) putMultipart(name string, data []byte, h http.Header) error {
+
+	resp, err := c.do(http.MethodPost, c.objectURL(name, "uploads="), h, nil)
+	if err != nil {
+		return err
+	}
+	var ir initiateMultipartUploadResult
+	if err = decodeResponse(resp, &ir); err != nil {
+		return err
+	}
+	uploadID := url.QueryEscape(ir.UploadID)
+
+	err = func() error {
+		cu := completeMultipartUpload{}
+		ps := c.Options.PartSizeBytes
+		for i := 0; i*ps < len(data); i++ {
+			end := (i + 1) * ps
+			if end > len(data) {
+				end = len(data)
+			}
+			resp, err := c.do(http.MethodPut, c.objectURL(name,
+				"partNumber="+strconv.Itoa(i+1)+"&uploadId="+uploadID), nil, data[i*ps:end])
+			if err != nil {
+				return err
+			}
+			if err = checkResponse(resp, http.StatusOK); err != nil {
+				return err
+			}
+			cu.Parts = append(cu.Parts, completedPart{PartNumber: i + 1,
+				ETag: resp.Header.Get("ETag")})
+		}
+		body, err := xml.Marshal(cu)
+		if err != nil {
+			return err
+		}
+		resp, err := c.do(http.MethodPost, c.objectURL(name, "uploadId="+uploadID), nil, body)
+		if err != nil {
+			return err
+		}
+		// a completion can fail after the 200 OK status has been sent, in which case the
+		// error is reported in the body
+		return decodeResponse(resp, &struct{}{})
+	}()
+
+	if err != nil {
+		resp, aerr := c.do(http.MethodDelete, c.objectURL(name, "uploadId="+uploadID), nil, nil)
+		if aerr == nil {
+			resp.Body.Close()
+		}
+	}
+	return err
+}
+
+// Get reads the object from the bucket
+func (c *Client) Get(name string) ([]byte, time.Time, error) {
+	resp, err := c.do(http.MethodGet, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, time.Time{}, objectstore.ErrObjectNotFound
+	default:
+		return nil, time.Time{}, fmt.Errorf("s3 responded with status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, objectInfo(name, resp.Header).Expiration, nil
+}
+
+// Stat describes the object from its headers, without reading it
+func (c *Client) Stat(name string) (objectstore.ObjectInfo, error) {
+	resp, err := c.do(http.MethodHead, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return objectstore.ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return objectstore.ObjectInfo{}, objectstore.ErrObjectNotFound
+	default:
+		return objectstore.ObjectInfo{}, fmt.Errorf("s3 responded with status %d", resp.StatusCode)
+	}
+	return objectInfo(name, resp.Header), nil
+}
+
+// SetExpiration updates the expiration of the object. Since S3 object metadata can't be
+// modified, the object is copied onto itself with the new expiration
+func (c *Client) SetExpiration(name string, expiration time.Time) error {
+	h := objectHeader(expiration)
+	h.Set("X-Amz-Copy-Source", "/"+c.Options.Bucket+"/"+sigv4.URIEncode(name, false))
+	h.Set("X-Amz-Metadata-Directive", "REPLACE")
+	resp, err := c.do(http.MethodPut, c.objectURL(name, ""), h, nil)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, &struct{}{})
+}
+
+// Delete removes the object from the bucket
+func (c *Client) Delete(name string) error {
+	resp, err := c.do(http.MethodDelete, c.objectURL(name, ""), nil, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusNoContent)
+}
+
+// deleteObjects is the body of a DeleteObjects request
+type deleteObjects struct {
+	XMLName xml.Name       `xml:"Delete"`
+	Quiet   bool           `xml:"Quiet"`
+	Objects []deleteObject `xml:"Object"`
+}
+
+type deleteObject struct {
+	Key string `xml:"Key"`
+}
+
+// BulkDelete removes the objects from the bucket, in DeleteObjects requests of up to
+// maxBulkDelete objects. A failed request does not prevent the remaining requests
+func (c *Client) BulkDelete(names []string) (int, error) {
+	var n int
+	var lastErr error
+	for i := 0; i < len(names); i += maxBulkDelete {
+		end := i + maxBulkDelete
+		if end > len(names) {
+			end = len(names)
+		}
+		d := deleteObjects{Quiet: true, Objects: make([]deleteObject, 0, end-i)}
+		for _, name := range names[i:end] {
+			d.Objects = append(d.Objects, deleteObject{Key: name})
+		}
+		body, err := xml.Marshal(d)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		sum := md5.Sum(body)
+		h := http.Header{"Content-Md5": []string{base64.StdEncoding.EncodeToString(sum[:])}}
+		resp, err := c.do(http.MethodPost, c.bucketURL("delete="), h, body)
+		if err == nil {
+			err = checkResponse(resp, http.StatusOK)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		n += end - i
+	}
+	return n, lastErr
+}
+
+// listBucketResult is the response to a ListObjectsV2 request
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the names of the objects in the bucket that begin with prefix
+func (c *Client) List(prefix string) ([]string, error) {
+	infos, err := c.ListInfo(prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, oi := range infos {
+		names[i] = oi.Name
+	}
+	return names, nil
+}
+
+// ListInfo returns the descriptions of the objects in the bucket that begin with prefix
+func (c *Client) ListInfo(prefix string) ([]objectstore.ObjectInfo, error) {
+	var infos []objectstore.ObjectInfo
+	q := url.Values{"list-type": []string{"2"}, "prefix": []string{prefix}}
+	for {
+		resp, err := c.do(http.MethodGet, c.bucketURL(q.Encode()), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var lr listBucketResult
+		if err = decodeResponse(resp, &lr); err != nil {
+			return nil, err
+		}
+		for _, o := range lr.Contents {
+			infos = append(infos, objectstore.ObjectInfo{Name: o.Key, Size: o.Size,
+				LastModified: o.LastModified})
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			return infos, nil
+		}
+		q.Set("continuation-token", lr.NextContinuationToken)
+	}
+}
+
+// Close closes the Client's idle connections
+func (c *Client) Close() error {
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// objectHeader returns the headers of an object that expires at the provided time
+func objectHeader(expiration time.Time) http.Header {
+	return http.Header{
+		"Content-Type": []string{"application/octet-stream"},
+		headerExpiration: []string{strconv.FormatInt(
+			expiration.UnixNano()/int64(time.Millisecond), 10)},
+	}
+}
+
+// objectInfo returns the description of the named object from its response headers
+func objectInfo(name string, h http.Header) objectstore.ObjectInfo {
+	oi := objectstore.ObjectInfo{Name: name}
+	oi.Size, _ = strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	oi.LastModified, _ = http.ParseTime(h.Get("Last-Modified"))
+	if ms, err := strconv.ParseInt(h.Get(headerExpiration), 10, 64); err == nil {
+		oi.Expiration = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return oi
+}
+
+// bucketURL returns the URL of the bucket with the provided query
+func (c *Client) bucketURL(query string) *url.URL {
+	return c.objectURL("", query)
+}
+
+// objectURL returns the URL of the named object, with the provided query
+func (c *Client) objectURL(name, query string) *url.URL {
+	u := &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host, RawQuery: query}
+	path := c.endpoint.Path + "/"
+	if c.Options.PathStyle {
+		path += c.Options.Bucket + "/"
+	} else {
+		u.Host = c.Options.Bucket + "." + u.Host
+	}
+	u.Path = path + name
+	u.RawPath = sigv4.URIEncode(path, false) + sigv4.URIEncode(name, false)
+	return u
+}
+
+// do sends a signed request to the S3 API
+func (c *Client) do(method string, u *url.URL, h http.Header, body []byte) (*http.Response, error) {
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		r.Header[k] = v
+	}
+	sigv4.Sign(r, body, c.creds, c.Options.Region, "s3", time.Now())
+	return c.client.Do(r)
+}
+
+// s3Error is the error document of a failed request
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// checkResponse closes the response body, and returns an error if the status is not expected
+func checkResponse(resp *http.Response, expected int) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == expected {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return responseError(resp)
+}
+
+// decodeResponse decodes the XML response body into v, or returns the error it describes
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var e s3Error
+	if xml.Unmarshal(b, &e) == nil {
+		return fmt.Errorf("s3 error %s: %s", e.Code, e.Message)
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(b, v)
+}
+
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e s3Error
+	if xml.Unmarshal(b, &e) == nil {
+		return fmt.Errorf("s3 error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("s3 responded with status %d", resp.StatusCode)
+}
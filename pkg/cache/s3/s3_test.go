@@ -0,0 +1,573 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/objectstore"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	so "github.com/tricksterproxy/trickster/pkg/cache/s3/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+const cacheType = "s3"
+const cacheKey = "cacheKey"
+const testBucket = "trickster-test"
+
+// testModified is the last modified time reported for each object
+var testModified = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type testObject struct {
+	data       []byte
+	expiration string
+}
+
+// testS3 is an in-memory S3 API serving a single bucket with path-style addressing
+type testS3 struct {
+	mtx     sync.Mutex
+	objects map[string]*testObject
+	uploads map[string]map[int][]byte
+	// failComplete fails the completion of multipart uploads with an error document
+	failComplete bool
+	requests     int
+}
+
+func newTestS3() (*testS3, *httptest.Server) {
+	s := &testS3{objects: make(map[string]*testObject), uploads: make(map[string]map[int][]byte)}
+	return s, httptest.NewServer(s)
+}
+
+func (s *testS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests++
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), sigv4.Algorithm+" Credential=test/") ||
+		r.Header.Get(sigv4.HeaderAmzSHA256) != sigv4.SHA256Hex(body) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path != testBucket && !strings.HasPrefix(path, testBucket+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(path, testBucket), "/")
+	qp := r.URL.Query()
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet && qp.Get("list-type") == "2":
+		// lists one object per page, to exercise continuation
+		var names []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, qp.Get("prefix")) && k > qp.Get("continuation-token") {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			w.Write([]byte("<ListBucketResult></ListBucketResult>"))
+			return
+		}
+		w.Write([]byte("<ListBucketResult><Contents><Key>" + names[0] + "</Key>" +
+			"<Size>" + strconv.Itoa(len(s.objects[names[0]].data)) + "</Size>" +
+			"<LastModified>" + testModified.Format("2006-01-02T15:04:05.000Z") +
+			"</LastModified></Contents>" +
+			"<IsTruncated>" + strconv.FormatBool(len(names) > 1) + "</IsTruncated>" +
+			"<NextContinuationToken>" + names[0] + "</NextContinuationToken></ListBucketResult>"))
+	case key == "" && r.Method == http.MethodPost && qp.Get("delete") == "" && r.URL.RawQuery == "delete=":
+		var d deleteObjects
+		if err := xml.Unmarshal(body, &d); err != nil || r.Header.Get("Content-Md5") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, o := range d.Objects {
+			delete(s.objects, o.Key)
+		}
+		w.Write([]byte("<DeleteResult></DeleteResult>"))
+	case r.Method == http.MethodPost && r.URL.RawQuery == "uploads=":
+		id := "upload/" + strconv.Itoa(len(s.uploads))
+		s.uploads[id] = make(map[int][]byte)
+		s.objects[id] = &testObject{expiration: r.Header.Get(headerExpiration)}
+		w.Write([]byte("<InitiateMultipartUploadResult><UploadId>" + id +
+			"</UploadId></InitiateMultipartUploadResult>"))
+	case r.Method == http.MethodPut && qp.Get("uploadId") != "":
+		n, _ := strconv.Atoi(qp.Get("partNumber"))
+		s.uploads[qp.Get("uploadId")][n] = body
+		w.Header().Set("ETag", `"etag`+strconv.Itoa(n)+`"`)
+	case r.Method == http.MethodPost && qp.Get("uploadId") != "":
+		id := qp.Get("uploadId")
+		if s.failComplete {
+			w.Write([]byte("<Error><Code>InternalError</Code><Message>test</Message></Error>"))
+			return
+		}
+		var cu completeMultipartUpload
+		xml.Unmarshal(body, &cu)
+		o := s.objects[id]
+		for i, p := range cu.Parts {
+			if p.PartNumber != i+1 || p.ETag != `"etag`+strconv.Itoa(i+1)+`"` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			o.data = append(o.data, s.uploads[id][p.PartNumber]...)
+		}
+		s.objects[key] = o
+		delete(s.objects, id)
+		delete(s.uploads, id)
+		w.Write([]byte("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>"))
+	case r.Method == http.MethodDelete && qp.Get("uploadId") != "":
+		delete(s.objects, qp.Get("uploadId"))
+		delete(s.uploads, qp.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		o, ok := s.objects[strings.TrimPrefix(src, "/"+testBucket+"/")]
+		if !ok || r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>test</Message></Error>"))
+			return
+		}
+		s.objects[key] = &testObject{data: o.data, expiration: r.Header.Get(headerExpiration)}
+		w.Write([]byte("<CopyObjectResult></CopyObjectResult>"))
+	case r.Method == http.MethodPut:
+		s.objects[key] = &testObject{data: body, expiration: r.Header.Get(headerExpiration)}
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		o, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(headerExpiration, o.expiration)
+		w.Header().Set("Last-Modified", testModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
+		w.Write(o.data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestOptions(endpoint string) *so.Options {
+	o := so.NewOptions()
+	o.Endpoint = endpoint
+	o.Bucket = testBucket
+	o.AccessKeyID = "test"
+	o.SecretAccessKey = "secret"
+	o.PathStyle = true
+	o.PartSizeBytes = so.MinPartSizeBytes
+	return o
+}
+
+func newTestClient(t *testing.T) (*Client, *testS3, func()) {
+	s, ts := newTestS3()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, s, func() {
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, ts := newTestS3()
+	defer ts.Close()
+	cacheConfig := co.Options{CacheType: cacheType, S3: newTestOptions(ts.URL)}
+	c := New("test", &cacheConfig, tl.ConsoleLogger("error"))
+	if c.Prefix != "trickster/" {
+		t.Errorf("expected %s got %s", "trickster/", c.Prefix)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// it should store a value under the configured prefix
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Error(err)
+	}
+	data, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil || ls != status.LookupStatusHit || string(data) != "data" {
+		t.Errorf("expected hit, got %s %s %v", string(data), ls, err)
+	}
+	keys, err := c.Keys("")
+	if err != nil || !reflect.DeepEqual(keys, []string{cacheKey}) {
+		t.Errorf("expected %v got %v %v", []string{cacheKey}, keys, err)
+	}
+}
+
+func TestS3Client_Connect(t *testing.T) {
+	_, _, closer := newTestClient(t)
+	closer()
+}
+
+func TestS3Client_ConnectFailed(t *testing.T) {
+	_, ts := newTestS3()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	c.Options.Bucket = "missing"
+	const expected = "s3 bucket missing is not accessible: status 404"
+	if err := c.Connect(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s' got '%v'", expected, err)
+	}
+
+	c.Options.Endpoint = "http://127.0.0.1:1"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for unavailable endpoint")
+	}
+
+	c.Options.Endpoint = ":"
+	if err := c.Connect(); err == nil {
+		t.Error("expected error for invalid endpoint")
+	}
+}
+
+func TestS3Client_ConnectEnvironmentCredentials(t *testing.T) {
+	_, ts := newTestS3()
+	defer ts.Close()
+	c := &Client{Options: newTestOptions(ts.URL)}
+	c.Options.AccessKeyID = ""
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "test")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_SESSION_TOKEN", "token")
+	defer func() {
+		os.Unsetenv("AWS_ACCESS_KEY_ID")
+		os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+		os.Unsetenv("AWS_SESSION_TOKEN")
+	}()
+	if err := c.Connect(); err != nil {
+		t.Error(err)
+	}
+	if c.creds.SessionToken != "token" {
+		t.Errorf("expected %s got %s", "token", c.creds.SessionToken)
+	}
+}
+
+func TestS3Client_PutGet(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	// it should put an object
+	exp := time.Unix(1577836800, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err != nil {
+		t.Error(err)
+	}
+	if o, ok := s.objects[cacheKey]; !ok || o.expiration != "1577836800000" {
+		t.Errorf("expected object with expiration, got %v", o)
+	}
+
+	// it should get an object and its expiration
+	data, e, err := c.Get(cacheKey)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("expected %s got %s", "data", string(data))
+	}
+	if !e.Equal(exp) {
+		t.Errorf("expected %v got %v", exp, e)
+	}
+
+	// it should not find an object that was not put
+	if _, _, err = c.Get("missing"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+
+	// names are escaped in the object URL
+	if err = c.Put("a key/with+chars", []byte("escaped"), exp); err != nil {
+		t.Error(err)
+	}
+	if data, _, _ = c.Get("a key/with+chars"); string(data) != "escaped" {
+		t.Errorf("expected %s got %s", "escaped", string(data))
+	}
+
+	// an object without an expiration never expires
+	s.objects["noexp"] = &testObject{data: []byte("data")}
+	if _, e, err = c.Get("noexp"); err != nil || !e.IsZero() {
+		t.Errorf("expected zero expiration, got %v %v", e, err)
+	}
+}
+
+func TestS3Client_SetExpiration(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetExpiration(cacheKey, time.Unix(2, 0)); err != nil {
+		t.Error(err)
+	}
+	if o := s.objects[cacheKey]; o.expiration != "2000" || string(o.data) != "data" {
+		t.Errorf("expected copied object with new expiration, got %v", o)
+	}
+
+	// the expiration of a missing object can't be set
+	if err := c.SetExpiration("missing", time.Unix(2, 0)); err == nil ||
+		err.Error() != "s3 error NoSuchKey: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestS3Client_PutMultipart(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	data := bytes.Repeat([]byte("0123456789"), so.MinPartSizeBytes/4)
+	if err := c.Put(cacheKey, data, time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.uploads) != 0 {
+		t.Errorf("expected completed uploads, got %d", len(s.uploads))
+	}
+	got, e, err := c.Get(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("expected %d bytes got %d", len(data), len(got))
+	}
+	if !e.Equal(time.Unix(1, 0)) {
+		t.Errorf("expected %v got %v", time.Unix(1, 0), e)
+	}
+
+	// a failed completion aborts the upload
+	s.failComplete = true
+	err = c.Put("failed", data, time.Unix(1, 0))
+	if err == nil || err.Error() != "s3 error InternalError: test" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(s.uploads) != 0 {
+		t.Errorf("expected aborted upload, got %d", len(s.uploads))
+	}
+	if _, _, err = c.Get("failed"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+}
+
+func TestS3Client_Delete(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	defer closer()
+
+	if err := c.Put(cacheKey, []byte("data"), time.Unix(1, 0)); err != nil {
+		t.Error(err)
+	}
+	if err := c.Delete(cacheKey); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := c.Get(cacheKey); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+}
+
+func TestS3Client_BulkDelete(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	names := make([]string, maxBulkDelete+1)
+	for i := range names {
+		names[i] = cacheKey + strconv.Itoa(i)
+		s.objects[names[i]] = &testObject{data: []byte("data")}
+	}
+	s.requests = 0
+	n, err := c.BulkDelete(names)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != len(names) {
+		t.Errorf("expected %d got %d", len(names), n)
+	}
+	if len(s.objects) != 0 {
+		t.Errorf("expected %d objects got %d", 0, len(s.objects))
+	}
+	if s.requests != 2 {
+		t.Errorf("expected %d requests got %d", 2, s.requests)
+	}
+
+	c.creds.AccessKeyID = "invalid"
+	if n, err = c.BulkDelete(names); n != 0 || err == nil {
+		t.Errorf("expected error for rejected credentials, got %d %v", n, err)
+	}
+}
+
+func TestS3Client_List(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	for _, k := range []string{"a.dpc.1", "a.dpc.2", "a.opc.1", "b.dpc.1"} {
+		s.objects["trickster/"+k] = &testObject{data: []byte("data")}
+	}
+	s.objects["other/a.dpc.3"] = &testObject{data: []byte("data")}
+
+	names, err := c.List("trickster/a.dpc.")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"trickster/a.dpc.1", "trickster/a.dpc.2"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v got %v", expected, names)
+	}
+
+	names, err = c.List("trickster/c.")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no names got %v", names)
+	}
+
+	c.creds.AccessKeyID = "invalid"
+	if _, err = c.List(""); err == nil {
+		t.Error("expected error for rejected credentials")
+	}
+}
+
+func TestS3Client_ListInfo(t *testing.T) {
+
+	c, s, closer := newTestClient(t)
+	defer closer()
+
+	s.objects["trickster/a"] = &testObject{data: []byte("data")}
+	s.objects["trickster/b"] = &testObject{data: []byte("longer data")}
+
+	infos, err := c.ListInfo("trickster/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []objectstore.ObjectInfo{
+		{Name: "trickster/a", Size: 4, LastModified: testModified},
+		{Name: "trickster/b", Size: 11, LastModified: testModified},
+	}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("expected %v got %v", expected, infos)
+	}
+}
+
+func TestS3Client_Stat(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	defer closer()
+
+	exp := time.Unix(1577934245, 0)
+	if err := c.Put("trickster/"+cacheKey, []byte("data"), exp); err != nil {
+		t.Fatal(err)
+	}
+
+	oi, err := c.Stat("trickster/" + cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Name != "trickster/"+cacheKey || oi.Size != 4 || !oi.LastModified.Equal(testModified) ||
+		!oi.Expiration.Equal(exp) {
+		t.Errorf("unexpected object info: %v", oi)
+	}
+
+	if _, err = c.Stat("trickster/missing"); err != objectstore.ErrObjectNotFound {
+		t.Errorf("expected %v got %v", objectstore.ErrObjectNotFound, err)
+	}
+
+	c.creds.AccessKeyID = "invalid"
+	if _, err = c.Stat("trickster/" + cacheKey); err == nil {
+		t.Error("expected error for rejected credentials")
+	}
+}
+
+func TestS3Client_Errors(t *testing.T) {
+
+	c, _, closer := newTestClient(t)
+	closer()
+
+	// requests to an unavailable endpoint fail
+	exp := time.Unix(1, 0)
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, _, err := c.Get(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.Delete(cacheKey); err == nil {
+		t.Error("expected error")
+	}
+	if err := c.SetExpiration(cacheKey, exp); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := c.List(""); err == nil {
+		t.Error("expected error")
+	}
+
+	// requests with invalid credentials are denied
+	c, _, closer = newTestClient(t)
+	defer closer()
+	c.creds.AccessKeyID = "invalid"
+	if err := c.Put(cacheKey, []byte("data"), exp); err == nil ||
+		err.Error() != "s3 responded with status 403" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, _, err := c.Get(cacheKey); err == nil || err.Error() != "s3 responded with status 403" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := c.Put(cacheKey, make([]byte, so.MinPartSizeBytes+1), exp); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestObjectURL(t *testing.T) {
+
+	c := &Client{Options: newTestOptions("")}
+	c.Options.PathStyle = false
+	c.endpoint, _ = url.Parse("https://s3.us-east-1.amazonaws.com")
+
+	const expected = "https://trickster-test.s3.us-east-1.amazonaws.com/trickster/a%20b"
+	if u := c.objectURL("trickster/a b", ""); u.String() != expected {
+		t.Errorf("expected %s got %s", expected, u.String())
+	}
+
+	c.Options.PathStyle = true
+	c.endpoint, _ = url.Parse("http://minio:9000/s3")
+	const expected2 = "http://minio:9000/s3/trickster-test/trickster/key?uploads="
+	if u := c.objectURL("trickster/key", "uploads="); u.String() != expected2 {
+		t.Errorf("expected %s got %s", expected2, u.String())
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AgeBuckets are the upper bounds of the age histogram buckets reported in Stats, before
+// the final +Inf bucket
+var AgeBuckets = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour,
+	6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// Inspector is implemented by caches that can describe their contents, either from an Index
+// of their objects or by listing them from the underlying store
+type Inspector interface {
+	// Inspect returns the statistics of the cached objects, including the topN largest
+	// and most-accessed objects
+	Inspect(topN int) *Stats
+	// InspectObject returns the statistics of the object cached at the key, or nil if the
+	// key is not cached
+	InspectObject(cacheKey string) *ObjectStats
+}
+
+// Stats describes the objects in a cache
+type Stats struct {
+	// Objects is the number of objects in the cache
+	Objects int64 `json:"objects"`
+	// Bytes is the total size of the objects in the cache
+	Bytes int64 `json:"bytes"`
+	// AgeHistogram counts the objects by the time since they were written
+	AgeHistogram []*AgeBucket `json:"age_histogram"`
+	// Largest are the largest objects in the cache, largest first
+	Largest []*ObjectStats `json:"largest"`
+	// Hottest are the most-accessed objects in the cache, most-accessed first
+	Hottest []*ObjectStats `json:"hottest"`
+}
+
+// AgeBucket counts the objects written no more than LE seconds ago, and after the LE of
+// the previous bucket. The LE of the last bucket is +Inf
+type AgeBucket struct {
+	LE      string `json:"le"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// ObjectStats describes an object in a cache
+type ObjectStats struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	// Hits is the number of times the object has been accessed since it was indexed
+	Hits int64 `json:"hits"`
+	// AgeSecs is the time since the object was written
+	AgeSecs int64 `json:"age_secs"`
+	// IdleSecs is the time since the object was last accessed
+	IdleSecs int64 `json:"idle_secs"`
+	// TTLSecs is the time until the object expires, or 0 if it has expired or has no expiration
+	TTLSecs int64 `json:"ttl_secs"`
+}
+
+// NewStats returns an empty Stats with an AgeBucket for each of the AgeBuckets
+func NewStats() *Stats {
+	s := &Stats{AgeHistogram: make([]*AgeBucket, len(AgeBuckets)+1)}
+	for i, d := range AgeBuckets {
+		s.AgeHistogram[i] = &AgeBucket{LE: strconv.FormatInt(int64(d.Seconds()), 10)}
+	}
+	s.AgeHistogram[len(AgeBuckets)] = &AgeBucket{LE: "+Inf"}
+	return s
+}
+
+// Add counts an object of the provided size that was written age ago
+func (s *Stats) Add(size int64, age time.Duration) {
+	i := sort.Search(len(AgeBuckets), func(i int) bool { return age <= AgeBuckets[i] })
+	s.AgeHistogram[i].Objects++
+	s.AgeHistogram[i].Bytes += size
+	s.Objects++
+	s.Bytes += size
+}
+
+// TopObjects returns the first n of the objects when ordered by less, with ties broken by key
+func TopObjects(objs []*ObjectStats, n int,
+	less func(a, b *ObjectStats) bool) []*ObjectStats {
+	sorted := make([]*ObjectStats, len(objs))
+	copy(sorted, objs)
+	sort.Slice(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) {
+			return true
+		}
+		if less(sorted[j], sorted[i]) {
+			return false
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
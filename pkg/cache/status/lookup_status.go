@@ -49,6 +49,8 @@ const (
 	LookupStatusError
 	// LookupStatusProxyHit indicates that the request joined an existing proxy download of the same object
 	LookupStatusProxyHit
+	// LookupStatusPeer indicates that the request was served by the cluster peer that owns the cache key
+	LookupStatusPeer
 )
 
 var cacheLookupStatusNames = map[string]LookupStatus{
@@ -63,6 +65,7 @@ var cacheLookupStatusNames = map[string]LookupStatus{
 	"nchit":       LookupStatusNegativeCacheHit,
 	"proxy-hit":   LookupStatusProxyHit,
 	"error":       LookupStatusError,
+	"peer":        LookupStatusPeer,
 }
 
 var cacheLookupStatusValues = map[LookupStatus]string{
@@ -77,6 +80,7 @@ var cacheLookupStatusValues = map[LookupStatus]string{
 	LookupStatusNegativeCacheHit: "nchit",
 	LookupStatusProxyHit:         "proxy-hit",
 	LookupStatusError:            "error",
+	LookupStatusPeer:             "peer",
 }
 
 func (s LookupStatus) String() string {
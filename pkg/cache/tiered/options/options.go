@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrInvalidL2CacheType is returned when the persistent tier is not a supported cache type
+var ErrInvalidL2CacheType = errors.New("tiered l2_cache_type must be one of 'filesystem', " +
+	"'bbolt', 'badger', 'logstore', 'redis', 'memcached', 'cassandra', 's3', 'dynamodb', 'gcs' or " +
+	"'azureblob'")
+
+// ErrInvalidL1Size is returned when the memory tier is given a negative maximum size
+var ErrInvalidL1Size = errors.New("tiered l1_max_size_bytes and l1_max_size_objects must not be negative")
+
+// ErrInvalidPromotionTTL is returned when the promotion TTL is not positive
+var ErrInvalidPromotionTTL = errors.New("tiered l1_promotion_ttl_secs must be greater than 0")
+
+// Options is a collection of Configurations for layering a memory cache in front of a
+// persistent cache
+type Options struct {
+	// L2CacheType is the type of the persistent cache behind the memory tier, which is
+	// configured by its own section of the cache's options (e.g., [caches.default.filesystem])
+	L2CacheType string `toml:"l2_cache_type"`
+	// L1MaxSizeBytes is the size in bytes the memory tier can grow to before it evicts its
+	// least-recently-accessed objects. 0 means no maximum
+	L1MaxSizeBytes int64 `toml:"l1_max_size_bytes"`
+	// L1MaxSizeObjects is the number of objects the memory tier can grow to before it evicts
+	// its least-recently-accessed objects. 0 means no maximum
+	L1MaxSizeObjects int64 `toml:"l1_max_size_objects"`
+	// L1PromotionTTLSecs is the TTL of objects copied into the memory tier when they are read
+	// from the persistent tier, whose remaining TTL is not known
+	L1PromotionTTLSecs int `toml:"l1_promotion_ttl_secs"`
+
+	// L1PromotionTTL is the time.Duration representation of L1PromotionTTLSecs
+	L1PromotionTTL time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new Tiered Options Reference with default values set
+func NewOptions() *Options {
+	return &Options{
+		L2CacheType:        d.DefaultTieredL2CacheType,
+		L1MaxSizeBytes:     d.DefaultTieredL1MaxSizeBytes,
+		L1MaxSizeObjects:   d.DefaultTieredL1MaxSizeObjects,
+		L1PromotionTTLSecs: d.DefaultTieredL1PromotionTTLSecs,
+		L1PromotionTTL:     time.Duration(d.DefaultTieredL1PromotionTTLSecs) * time.Second,
+	}
+}
+
+// Validate returns an error if the options are invalid
+func (o *Options) Validate() error {
+	switch types.Names[o.L2CacheType] {
+	case types.CacheTypeFilesystem, types.CacheTypeBbolt, types.CacheTypeBadgerDB,
+		types.CacheTypeRedis, types.CacheTypeS3, types.CacheTypeDynamoDB, types.CacheTypeGCS,
+		types.CacheTypeAzureBlob, types.CacheTypeMemcached, types.CacheTypeCassandra,
+		types.CacheTypeLogstore:
+	default:
+		return ErrInvalidL2CacheType
+	}
+	if o.L1MaxSizeBytes < 0 || o.L1MaxSizeObjects < 0 {
+		return ErrInvalidL1Size
+	}
+	if o.L1PromotionTTLSecs <= 0 {
+		return ErrInvalidPromotionTTL
+	}
+	return nil
+}
+
+// SetDurations sets the time.Duration representations of the seconds-based options
+func (o *Options) SetDurations() {
+	o.L1PromotionTTL = time.Duration(o.L1PromotionTTLSecs) * time.Second
+}
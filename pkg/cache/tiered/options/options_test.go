@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o == nil {
+		t.Error("expected non-nil options")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		l2Type   string
+		maxBytes int64
+		ttl      int
+		expected error
+	}{
+		{"filesystem", 1024, 60, nil},
+		{"redis", 0, 60, nil},
+		{"dynamodb", 1024, 60, nil},
+		{"gcs", 1024, 60, nil},
+		{"azureblob", 1024, 60, nil},
+		{"memory", 1024, 60, ErrInvalidL2CacheType},
+		{"tiered", 1024, 60, ErrInvalidL2CacheType},
+		{"", 1024, 60, ErrInvalidL2CacheType},
+		{"bbolt", -1, 60, ErrInvalidL1Size},
+		{"bbolt", 1024, 0, ErrInvalidPromotionTTL},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.L2CacheType = test.l2Type
+		o.L1MaxSizeBytes = test.maxBytes
+		o.L1PromotionTTLSecs = test.ttl
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
+
+func TestSetDurations(t *testing.T) {
+	o := NewOptions()
+	o.L1PromotionTTLSecs = 30
+	o.SetDurations()
+	if o.L1PromotionTTL != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.L1PromotionTTL)
+	}
+}
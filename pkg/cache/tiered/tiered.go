@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tiered is the Tiered implementation of the Trickster Cache. It layers a
+// size-bounded memory cache in front of a persistent cache, so that frequently-read
+// objects are served without a round trip to disk or the network
+package tiered
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Cache represents a Tiered cache object that conforms to the Cache interface. Objects are
+// written through to both tiers, and objects read from the persistent tier are promoted
+// to the memory tier
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger *tl.Logger
+	// L2 is the persistent tier, which is the cache of record
+	L2     cache.Cache
+	l1     *memory.Cache
+	locker locks.NamedLocker
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect initializes the memory tier and connects the persistent tier
+func (c *Cache) Connect() error {
+	o := c.Config.Tiered
+	c.Logger.Info("tiered cache setup", tl.Pairs{"name": c.Name, "l2CacheType": o.L2CacheType,
+		"l1MaxSizeBytes": o.L1MaxSizeBytes, "l1MaxSizeObjects": o.L1MaxSizeObjects})
+
+	// each tier reports metrics under the cache's name, labeled with its own cache type
+	lc := c.Config.Clone()
+	lc.CacheType = types.CacheTypeMemory.String()
+	lc.CacheTypeID = types.CacheTypeMemory
+	lc.Index.MaxSizeBytes = o.L1MaxSizeBytes
+	lc.Index.MaxSizeObjects = o.L1MaxSizeObjects
+	c.l1 = &memory.Cache{Name: c.Name, Config: lc, Logger: c.Logger}
+	c.l1.SetLocker(locks.NewNamedLocker())
+	if err := c.l1.Connect(); err != nil {
+		return err
+	}
+
+	if c.L2.Locker() == nil {
+		c.L2.SetLocker(locks.NewNamedLocker())
+	}
+	return c.L2.Connect()
+}
+
+// Store places the object in both tiers using the provided key and ttl. The persistent
+// tier is written first, so that the memory tier never holds an object that is not also
+// in the cache of record
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	if err := c.L2.Store(cacheKey, data, ttl); err != nil {
+		c.l1.Remove(cacheKey)
+		return err
+	}
+	return c.l1.Store(cacheKey, data, ttl)
+}
+
+// Retrieve looks for an object in the memory tier, and then in the persistent tier, and
+// returns it (or an error if not found). Objects found only in the persistent tier are
+// promoted to the memory tier for the configured promotion TTL
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if data, ls, err := c.l1.Retrieve(cacheKey, allowExpired); err == nil {
+		metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "l1_hit", float64(len(data)))
+		return data, ls, nil
+	}
+	data, ls, err := c.L2.Retrieve(cacheKey, allowExpired)
+	if err != nil {
+		metrics.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, ls, err
+	}
+	metrics.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "l2_hit", float64(len(data)))
+	// an object retrieved while allowing expiration may be expired, so it is not promoted
+	if !allowExpired {
+		c.l1.Store(cacheKey, data, c.Config.Tiered.L1PromotionTTL)
+		metrics.ObserveCacheEvent(c.Name, c.Config.CacheType, "promote", "l2_hit")
+	}
+	return data, ls, nil
+}
+
+// SetTTL updates the TTL of the object in both tiers
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.L2.SetTTL(cacheKey, ttl)
+	c.l1.SetTTL(cacheKey, ttl)
+}
+
+// Remove removes the object from both tiers
+func (c *Cache) Remove(cacheKey string) {
+	c.l1.Remove(cacheKey)
+	c.L2.Remove(cacheKey)
+}
+
+// BulkRemove removes a list of objects from both tiers
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.l1.BulkRemove(cacheKeys)
+	c.L2.BulkRemove(cacheKeys)
+}
+
+// Keys returns the keys of the objects in the persistent tier that begin with prefix
+func (c *Cache) Keys(prefix string) ([]string, error) {
+	if l, ok := c.L2.(cache.Lister); ok {
+		return l.Keys(prefix)
+	}
+	return nil, fmt.Errorf("cache type %s can only invalidate objects by key",
+		c.Config.Tiered.L2CacheType)
+}
+
+// SetTags associates the object in the persistent tier with the provided tags
+func (c *Cache) SetTags(cacheKey string, tags []string) {
+	if tg, ok := c.L2.(cache.Tagger); ok {
+		tg.SetTags(cacheKey, tags)
+	}
+}
+
+// TaggedKeys returns the keys of the objects in the persistent tier that are associated
+// with tag
+func (c *Cache) TaggedKeys(tag string) ([]string, error) {
+	if tg, ok := c.L2.(cache.Tagger); ok {
+		return tg.TaggedKeys(tag)
+	}
+	return nil, fmt.Errorf("cache type %s can't invalidate objects by tag",
+		c.Config.Tiered.L2CacheType)
+}
+
+// Close closes both tiers
+func (c *Cache) Close() error {
+	if c.l1 != nil {
+		c.l1.Close()
+	}
+	return c.L2.Close()
+}
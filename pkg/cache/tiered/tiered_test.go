@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tiered
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/memory"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const cacheKey = "cacheKey"
+
+var errStoreFailed = errors.New("store failed")
+
+// failingCache is a persistent tier whose writes fail
+type failingCache struct {
+	*memory.Cache
+}
+
+func (c *failingCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	return errStoreFailed
+}
+
+// newTestCache returns a connected Tiered Cache whose persistent tier is a memory cache
+func newTestCache(t *testing.T) (*Cache, *memory.Cache) {
+	cfg := co.NewOptions()
+	cfg.Name = "test"
+	cfg.CacheType = "tiered"
+	cfg.Index.ReapInterval = 0
+
+	l2cfg := cfg.Clone()
+	l2cfg.CacheType = "memory"
+	l2 := &memory.Cache{Name: "test", Config: l2cfg, Logger: tl.ConsoleLogger("error")}
+
+	c := &Cache{Name: "test", Config: cfg, Logger: tl.ConsoleLogger("error"), L2: l2}
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, l2
+}
+
+func TestConfiguration(t *testing.T) {
+	c, _ := newTestCache(t)
+	defer c.Close()
+	if c.Configuration().CacheType != "tiered" {
+		t.Errorf("expected %s got %s", "tiered", c.Configuration().CacheType)
+	}
+	if c.Locker() == nil {
+		t.Error("expected non-nil locker")
+	}
+	if c.l1.Config.CacheType != "memory" {
+		t.Errorf("expected %s got %s", "memory", c.l1.Config.CacheType)
+	}
+	if c.l1.Config.Index.MaxSizeBytes != c.Config.Tiered.L1MaxSizeBytes {
+		t.Errorf("expected %d got %d", c.Config.Tiered.L1MaxSizeBytes, c.l1.Config.Index.MaxSizeBytes)
+	}
+}
+
+func TestCache_StoreWriteThrough(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range []cache.Cache{c.l1, l2} {
+		if b, _, err := tier.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+			t.Errorf("expected %s got %s: %v", "data", string(b), err)
+		}
+	}
+
+	b, ls, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit || string(b) != "data" {
+		t.Errorf("expected %s got %s", "data", string(b))
+	}
+}
+
+func TestCache_StoreFailure(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+	c.L2 = &failingCache{Cache: l2}
+
+	if err := c.Store(cacheKey, []byte("data"), time.Minute); err != errStoreFailed {
+		t.Errorf("expected %v got %v", errStoreFailed, err)
+	}
+	if _, _, err := c.l1.Retrieve(cacheKey, false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
+func TestCache_RetrievePromotion(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+
+	// objects read while allowing expiration are not promoted
+	l2.Store("expired", []byte("data"), time.Minute)
+	if _, _, err := c.Retrieve("expired", true); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := c.l1.Retrieve("expired", false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	l2.Store(cacheKey, []byte("data"), time.Minute)
+	if _, _, err := c.l1.Retrieve(cacheKey, false); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	b, _, err := c.Retrieve(cacheKey, false)
+	if err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+	if b, _, err = c.l1.Retrieve(cacheKey, false); err != nil || string(b) != "data" {
+		t.Errorf("expected %s got %s: %v", "data", string(b), err)
+	}
+
+	if _, ls, err := c.Retrieve("missing", false); err != cache.ErrKNF ||
+		ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+
+	c.Store("a", []byte("data"), time.Minute)
+	c.Store("b", []byte("data"), time.Minute)
+	c.Store("c", []byte("data"), time.Minute)
+
+	c.Remove("a")
+	c.BulkRemove([]string{"b", "c"})
+	for _, key := range []string{"a", "b", "c"} {
+		for _, tier := range []cache.Cache{c.l1, l2} {
+			if _, _, err := tier.Retrieve(key, false); err != cache.ErrKNF {
+				t.Errorf("expected %v got %v", cache.ErrKNF, err)
+			}
+		}
+	}
+}
+
+func TestCache_SetTTL(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+
+	c.Store(cacheKey, []byte("data"), time.Minute)
+	c.SetTTL(cacheKey, time.Hour)
+	// the index updates the ttl asynchronously
+	time.Sleep(10 * time.Millisecond)
+	for _, tier := range []*memory.Cache{c.l1, l2} {
+		e := tier.Index.GetExpiration(cacheKey)
+		if e.Before(time.Now().Add(59 * time.Minute)) {
+			t.Errorf("expected expiration after %s got %s", time.Now().Add(59*time.Minute), e)
+		}
+	}
+}
+
+func TestCache_KeysAndTags(t *testing.T) {
+	c, l2 := newTestCache(t)
+	defer c.Close()
+
+	c.Store("prefix.a", []byte("data"), time.Minute)
+	c.SetTags("prefix.a", []string{"tag"})
+
+	keys, err := c.Keys("prefix.")
+	if err != nil || len(keys) != 1 || keys[0] != "prefix.a" {
+		t.Errorf("expected %v got %v: %v", []string{"prefix.a"}, keys, err)
+	}
+	keys, err = c.TaggedKeys("tag")
+	if err != nil || len(keys) != 1 || keys[0] != "prefix.a" {
+		t.Errorf("expected %v got %v: %v", []string{"prefix.a"}, keys, err)
+	}
+
+	// a persistent tier that can't list or tag its objects can't be invalidated by them
+	c.L2 = &struct{ cache.Cache }{l2}
+	if _, err = c.Keys("prefix."); err == nil {
+		t.Error("expected error")
+	}
+	if _, err = c.TaggedKeys("tag"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -32,6 +32,26 @@ const (
 	CacheTypeBbolt
 	// CacheTypeBadgerDB indicates a BadgerDB cache
 	CacheTypeBadgerDB
+	// CacheTypeS3 indicates an S3 cache
+	CacheTypeS3
+	// CacheTypeTiered indicates a memory cache layered in front of a persistent cache
+	CacheTypeTiered
+	// CacheTypeDynamoDB indicates a DynamoDB cache
+	CacheTypeDynamoDB
+	// CacheTypeGCS indicates a Google Cloud Storage cache
+	CacheTypeGCS
+	// CacheTypeAzureBlob indicates an Azure Blob Storage cache
+	CacheTypeAzureBlob
+	// CacheTypeMemcached indicates a Memcached cache
+	CacheTypeMemcached
+	// CacheTypeEtcd indicates an etcd cache
+	CacheTypeEtcd
+	// CacheTypeCassandra indicates a Cassandra or ScyllaDB cache
+	CacheTypeCassandra
+	// CacheTypeLogstore indicates a log-structured Filesystem cache
+	CacheTypeLogstore
+	// CacheTypeMigration indicates a cache that writes to both an old and a new persistent cache
+	CacheTypeMigration
 )
 
 // Names is a map of cache types keyed by name
@@ -41,6 +61,16 @@ var Names = map[string]CacheType{
 	"redis":      CacheTypeRedis,
 	"bbolt":      CacheTypeBbolt,
 	"badger":     CacheTypeBadgerDB,
+	"s3":         CacheTypeS3,
+	"tiered":     CacheTypeTiered,
+	"dynamodb":   CacheTypeDynamoDB,
+	"gcs":        CacheTypeGCS,
+	"azureblob":  CacheTypeAzureBlob,
+	"memcached":  CacheTypeMemcached,
+	"etcd":       CacheTypeEtcd,
+	"cassandra":  CacheTypeCassandra,
+	"logstore":   CacheTypeLogstore,
+	"migration":  CacheTypeMigration,
 }
 
 // Values is a map of cache types keyed by internal id
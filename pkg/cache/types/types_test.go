@@ -24,7 +24,7 @@ func TestCacheTypeString(t *testing.T) {
 
 	t1 := CacheTypeMemory
 	t2 := CacheTypeFilesystem
-	var t3 CacheType = 13
+	var t3 CacheType = 15
 
 	if t1.String() != "memory" {
 		t.Errorf("expected %s got %s", "memory", t1.String())
@@ -34,8 +34,8 @@ func TestCacheTypeString(t *testing.T) {
 		t.Errorf("expected %s got %s", "filesystem", t2.String())
 	}
 
-	if t3.String() != "13" {
-		t.Errorf("expected %s got %s", "13", t3.String())
+	if t3.String() != "15" {
+		t.Errorf("expected %s got %s", "15", t3.String())
 	}
 
 }
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the background warming options for a cache
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the replay of popular queries against a cache's origins, which keeps
+// the cache hot after a restart or deploy
+type Options struct {
+	// Queries lists the queries to replay against each origin, keyed by origin name. Each is
+	// a path and query string, as requested of the origin through Trickster
+	Queries map[string][]string `toml:"queries"`
+	// TopN is the number of the most requested queries to record from the traffic of the
+	// cache's origins, which are replayed along with Queries. 0 disables recording
+	TopN int `toml:"top_n"`
+	// StatePath is the file to which the recorded queries are persisted on each interval,
+	// so they are replayed after a restart. When empty, they are kept only in memory
+	StatePath string `toml:"state_path"`
+	// IntervalSecs is the interval between warming passes, after the first pass at startup
+	IntervalSecs int `toml:"interval_secs"`
+	// Concurrency is the maximum number of queries that are replayed at once
+	Concurrency int `toml:"concurrency"`
+
+	// Interval is the time.Duration representation of IntervalSecs
+	Interval time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		IntervalSecs: d.DefaultCacheWarmingIntervalSecs,
+		Concurrency:  d.DefaultCacheWarmingConcurrency,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := &Options{
+		TopN:         o.TopN,
+		StatePath:    o.StatePath,
+		IntervalSecs: o.IntervalSecs,
+		Concurrency:  o.Concurrency,
+		Interval:     o.Interval,
+	}
+	if o.Queries != nil {
+		o2.Queries = make(map[string][]string, len(o.Queries))
+		for k, v := range o.Queries {
+			o2.Queries[k] = append([]string(nil), v...)
+		}
+	}
+	return o2
+}
+
+// Enabled returns true if there are queries to replay, or queries are to be recorded
+func (o *Options) Enabled() bool {
+	return o != nil && (len(o.Queries) > 0 || o.TopN > 0)
+}
+
+// SetDurations sets the time.Duration representations of the Options' seconds-based values
+func (o *Options) SetDurations() {
+	o.Interval = time.Duration(o.IntervalSecs) * time.Second
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.TopN < 0 {
+		return errors.New("warming top_n must not be negative")
+	}
+	if o.IntervalSecs <= 0 {
+		return errors.New("warming interval_secs must be positive")
+	}
+	if o.Concurrency <= 0 {
+		return errors.New("warming concurrency must be positive")
+	}
+	return nil
+}
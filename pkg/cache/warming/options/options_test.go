@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o.Enabled() {
+		t.Error("expected disabled warming")
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.TopN = 10
+	o.Queries = map[string][]string{"test": {"/api/v1/query?query=up"}}
+	o2 := o.Clone()
+	if !o2.Enabled() || o2.TopN != 10 || o2.Queries["test"][0] != "/api/v1/query?query=up" {
+		t.Errorf("unexpected clone %v", o2)
+	}
+	o2.Queries["test"][0] = "changed"
+	if o.Queries["test"][0] != "/api/v1/query?query=up" {
+		t.Error("expected queries to be copied")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []func(*Options){
+		func(o *Options) { o.TopN = -1 },
+		func(o *Options) { o.IntervalSecs = 0 },
+		func(o *Options) { o.Concurrency = 0 },
+	}
+	for i, f := range tests {
+		o := NewOptions()
+		f(o)
+		if o.Validate() == nil {
+			t.Errorf("expected error for case %d", i)
+		}
+	}
+}
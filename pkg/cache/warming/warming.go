@@ -0,0 +1,286 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package warming keeps a cache hot after a restart or deploy, by replaying configured and
+// recorded popular queries against the cache's origins in the background
+package warming
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/warming/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// trackedFactor is the multiple of TopN queries whose hits are counted before the least
+// requested are pruned
+const trackedFactor = 10
+
+// Query is a request that is replayed against an origin to warm the cache
+type Query struct {
+	Origin string `json:"origin"`
+	URI    string `json:"uri"`
+	Hits   int64  `json:"hits,omitempty"`
+}
+
+// ReplayFunc replays the request URI against the named origin, returning the status code
+// of the response
+type ReplayFunc func(originName, uri string) (int, error)
+
+type replayContextKey struct{}
+
+// WithReplay returns a copy of ctx that marks its request as a warming replay, which is
+// not recorded as client traffic
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayContextKey{}, true)
+}
+
+// IsReplay returns true if the context belongs to a warming replay
+func IsReplay(ctx context.Context) bool {
+	v, _ := ctx.Value(replayContextKey{}).(bool)
+	return v
+}
+
+type queryKey struct {
+	origin string
+	uri    string
+}
+
+// Warmer records the most requested queries of a cache's origins, and periodically
+// replays them, along with any configured queries, to keep the cache hot
+type Warmer struct {
+	cacheName string
+	options   *options.Options
+
+	mtx    sync.Mutex
+	hits   map[queryKey]int64
+	warmed bool
+}
+
+// New returns a new Warmer for the named cache, restoring any queries that were recorded
+// to the state file by a previous run. The Warmer is usable even when an error is returned
+// because the state file could not be read
+func New(cacheName string, o *options.Options) (*Warmer, error) {
+	w := &Warmer{
+		cacheName: cacheName,
+		options:   o,
+		hits:      make(map[queryKey]int64),
+	}
+	if err := w.load(); err != nil && !os.IsNotExist(err) {
+		return w, err
+	}
+	return w, nil
+}
+
+// Recording returns true if the Warmer records the queries of the cache's origins
+func (w *Warmer) Recording() bool {
+	return w != nil && w.options.TopN > 0
+}
+
+// Record counts a request of the named origin toward the most requested queries
+func (w *Warmer) Record(originName, uri string) {
+	if !w.Recording() {
+		return
+	}
+	w.mtx.Lock()
+	w.hits[queryKey{origin: originName, uri: uri}]++
+	if len(w.hits) > w.options.TopN*trackedFactor {
+		w.prune()
+	}
+	w.mtx.Unlock()
+}
+
+// prune keeps only the TopN most requested queries, and halves their hits so that newly
+// popular queries can overtake them. The caller must hold the lock
+func (w *Warmer) prune() {
+	top := w.top()
+	w.hits = make(map[queryKey]int64, len(top))
+	for _, q := range top {
+		w.hits[queryKey{origin: q.Origin, uri: q.URI}] = q.Hits/2 + 1
+	}
+}
+
+// top returns the TopN most requested queries. The caller must hold the lock
+func (w *Warmer) top() []Query {
+	queries := make([]Query, 0, len(w.hits))
+	for k, v := range w.hits {
+		queries = append(queries, Query{Origin: k.origin, URI: k.uri, Hits: v})
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Hits != queries[j].Hits {
+			return queries[i].Hits > queries[j].Hits
+		}
+		if queries[i].Origin != queries[j].Origin {
+			return queries[i].Origin < queries[j].Origin
+		}
+		return queries[i].URI < queries[j].URI
+	})
+	if len(queries) > w.options.TopN {
+		queries = queries[:w.options.TopN]
+	}
+	return queries
+}
+
+// Top returns the most requested queries recorded by the Warmer, in order of hits
+func (w *Warmer) Top() []Query {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.top()
+}
+
+// Queries returns the configured queries, followed by the recorded queries that were not
+// also configured
+func (w *Warmer) Queries() []Query {
+	seen := make(map[queryKey]bool)
+	var queries []Query
+	origins := make([]string, 0, len(w.options.Queries))
+	for k := range w.options.Queries {
+		origins = append(origins, k)
+	}
+	sort.Strings(origins)
+	for _, o := range origins {
+		for _, u := range w.options.Queries[o] {
+			k := queryKey{origin: o, uri: u}
+			if !seen[k] {
+				seen[k] = true
+				queries = append(queries, Query{Origin: o, URI: u})
+			}
+		}
+	}
+	for _, q := range w.Top() {
+		if k := (queryKey{origin: q.Origin, uri: q.URI}); !seen[k] {
+			seen[k] = true
+			queries = append(queries, q)
+		}
+	}
+	return queries
+}
+
+// Run warms the cache, unless it was already warmed by a previous Run of the Warmer, and
+// warms it again on each Interval until quit is closed. The recorded queries are persisted
+// after each interval's pass, with any error passed to onError
+func (w *Warmer) Run(replay ReplayFunc, onError func(error), quit <-chan struct{}) {
+	w.mtx.Lock()
+	warmed := w.warmed
+	w.warmed = true
+	w.mtx.Unlock()
+	if !warmed {
+		w.Warm(replay)
+	}
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			w.Warm(replay)
+			if err := w.Save(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Warm makes a single pass through the queries, replaying up to Concurrency at once, and
+// returns the number of queries that warmed the cache and that failed
+func (w *Warmer) Warm(replay ReplayFunc) (int, int) {
+	queries := w.Queries()
+	if len(queries) == 0 {
+		return 0, 0
+	}
+	var warmed, failed int
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, w.options.Concurrency)
+	for _, q := range queries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(q Query) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result := "failed"
+			if code, err := replay(q.Origin, q.URI); err == nil && code >= 200 && code < 300 {
+				result = "warmed"
+			}
+			metrics.CacheWarmingQueries.WithLabelValues(w.cacheName, q.Origin, result).Inc()
+			mtx.Lock()
+			if result == "warmed" {
+				warmed++
+			} else {
+				failed++
+			}
+			mtx.Unlock()
+		}(q)
+	}
+	wg.Wait()
+	return warmed, failed
+}
+
+// Save writes the recorded queries to the state file, if one is configured
+func (w *Warmer) Save() error {
+	if !w.Recording() || w.options.StatePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(w.Top())
+	if err != nil {
+		return err
+	}
+	// the file is replaced only once fully written, so a crash never leaves a partial state
+	f, err := ioutil.TempFile(filepath.Dir(w.options.StatePath), ".warming")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), w.options.StatePath)
+}
+
+// load restores the recorded queries from the state file, if one is configured
+func (w *Warmer) load() error {
+	if !w.Recording() || w.options.StatePath == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(w.options.StatePath)
+	if err != nil {
+		return err
+	}
+	var queries []Query
+	if err = json.Unmarshal(b, &queries); err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	for _, q := range queries {
+		w.hits[queryKey{origin: q.Origin, uri: q.URI}] = q.Hits
+	}
+	w.mtx.Unlock()
+	return nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package warming
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/warming/options"
+)
+
+func testOptions() *options.Options {
+	o := options.NewOptions()
+	o.TopN = 2
+	o.Queries = map[string][]string{"test": {"/api/v1/query?query=up"}}
+	return o
+}
+
+func TestReplayContext(t *testing.T) {
+	if IsReplay(context.Background()) {
+		t.Error("expected non-replay context")
+	}
+	if !IsReplay(WithReplay(context.Background())) {
+		t.Error("expected replay context")
+	}
+}
+
+func TestRecord(t *testing.T) {
+
+	var w *Warmer
+	w.Record("test", "/") // nil warmer must not panic
+
+	w, _ = New("default", testOptions())
+	for i := 0; i < 3; i++ {
+		w.Record("test", "/a")
+	}
+	w.Record("test", "/b")
+	w.Record("test", "/b")
+	w.Record("other", "/c")
+
+	top := w.Top()
+	if len(top) != 2 || top[0].URI != "/a" || top[0].Hits != 3 || top[1].URI != "/b" {
+		t.Errorf("unexpected top queries %v", top)
+	}
+
+	// exceeding the tracked queries prunes all but the top queries
+	for i := 0; i < 2*trackedFactor; i++ {
+		w.Record("test", "/x"+string(rune('a'+i)))
+	}
+	w.mtx.Lock()
+	n := len(w.hits)
+	w.mtx.Unlock()
+	if n > 2*trackedFactor {
+		t.Errorf("expected pruned queries, got %d", n)
+	}
+
+	queries := w.Queries()
+	if len(queries) != 3 || queries[0].URI != "/api/v1/query?query=up" || queries[1].URI != "/a" {
+		t.Errorf("unexpected queries %v", queries)
+	}
+
+	o := testOptions()
+	o.TopN = 0
+	w, _ = New("default", o)
+	w.Record("test", "/a")
+	if w.Recording() || len(w.Top()) != 0 {
+		t.Error("expected no recorded queries")
+	}
+}
+
+func TestWarm(t *testing.T) {
+
+	w, _ := New("default", testOptions())
+	w.Record("test", "/a")
+	w.Record("test", "/fail")
+	w.Record("test", "/error")
+
+	var mtx sync.Mutex
+	replayed := make(map[string]bool)
+	replay := func(originName, uri string) (int, error) {
+		mtx.Lock()
+		replayed[uri] = true
+		mtx.Unlock()
+		switch uri {
+		case "/fail":
+			return 502, nil
+		case "/error":
+			return 0, errors.New("test")
+		}
+		return 200, nil
+	}
+
+	warmed, failed := w.Warm(replay)
+	if warmed != 2 || failed != 1 {
+		t.Errorf("expected 2 warmed and 1 failed, got %d and %d", warmed, failed)
+	}
+	if !replayed["/api/v1/query?query=up"] || !replayed["/a"] {
+		t.Errorf("unexpected replays %v", replayed)
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	o := testOptions()
+	o.IntervalSecs = 1
+	o.Interval = 10 * time.Millisecond
+	w, _ := New("default", o)
+
+	passes := make(chan struct{}, 10)
+	replay := func(originName, uri string) (int, error) {
+		select {
+		case passes <- struct{}{}:
+		default:
+		}
+		return 200, nil
+	}
+
+	quit := make(chan struct{})
+	go w.Run(replay, func(err error) { t.Error(err) }, quit)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-passes:
+		case <-time.After(time.Second):
+			t.Fatal("expected warming pass")
+		}
+	}
+	close(quit)
+
+	if !w.warmed {
+		t.Error("expected warmed cache")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-warming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := testOptions()
+	o.StatePath = filepath.Join(dir, "warming.json")
+
+	w, err := New("default", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Record("test", "/a")
+	w.Record("test", "/a")
+	w.Record("test", "/b")
+	if err = w.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = New("default", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := w.Top()
+	if len(top) != 2 || top[0].URI != "/a" || top[0].Hits != 2 {
+		t.Errorf("unexpected restored queries %v", top)
+	}
+
+	ioutil.WriteFile(o.StatePath, []byte("{"), 0600)
+	w, err = New("default", o)
+	if err == nil || len(w.Top()) != 0 {
+		t.Error("expected error and no restored queries")
+	}
+
+	o.StatePath = filepath.Join(dir, "missing", "warming.json")
+	if err = w.Save(); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cluster divides the cache key space among the peers of a Trickster cluster using
+// consistent hashing. Requests for keys owned by another peer are forwarded to that peer, so
+// each object is cached once across the cluster, rather than once on every peer. The peers
+// are either configured statically, or discovered from seed peers or DNS and then kept
+// current by gossiping cluster membership between peers.
+package cluster
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Cluster routes requests to the peer that owns their cache key
+type Cluster struct {
+	options    *options.Options
+	gossipPath string
+	client     *http.Client
+	gossiper   *http.Client
+
+	mtx     sync.RWMutex
+	self    string
+	ring    *ring
+	peers   []string
+	members map[string]time.Time
+	failed  map[string]time.Time
+
+	now        func() time.Time
+	resolve    func(host string) ([]string, error)
+	localAddrs func() ([]net.Addr, error)
+}
+
+// New returns a new Cluster for the provided options. gossipPath is the path of the
+// cluster's gossip handler, which must be the same on each peer
+func New(o *options.Options, gossipPath string) *Cluster {
+	c := &Cluster{
+		options:    o,
+		gossipPath: gossipPath,
+		self:       options.NormalizeURL(o.Self),
+		members:    make(map[string]time.Time),
+		failed:     make(map[string]time.Time),
+		now:        time.Now,
+		resolve:    net.LookupHost,
+		localAddrs: net.InterfaceAddrs,
+		gossiper:   &http.Client{Timeout: o.GossipInterval},
+		client: &http.Client{
+			Timeout: o.Timeout,
+			// redirects are passed through to the client, as if it had requested the peer directly
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	now := c.now()
+	if o.Discovery() == options.DiscoveryStatic {
+		for _, p := range o.Peers {
+			c.members[options.NormalizeURL(p)] = now
+		}
+	} else if c.self != "" {
+		c.members[c.self] = now
+	}
+	c.rebuild()
+	return c
+}
+
+// Self returns the base URL of this peer, which is empty when it has not yet been discovered
+func (c *Cluster) Self() string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.self
+}
+
+// Owner returns the base URL of the peer that owns key
+func (c *Cluster) Owner(key string) string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.ring.owner(key)
+}
+
+// Discovery returns the method by which the cluster's peers are discovered
+func (c *Cluster) Discovery() string {
+	return c.options.Discovery()
+}
+
+// GossipPath returns the path of the cluster's gossip handler
+func (c *Cluster) GossipPath() string {
+	return c.gossipPath
+}
+
+// Peers returns the base URLs of the peers that currently make up the ring
+func (c *Cluster) Peers() []string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.peers
+}
+
+// Forward serves the request from the peer that owns key, and returns true if it did. It
+// returns false when the request should be served locally: when this peer owns the key, the
+// request was already forwarded by another peer, the request can't be replayed, or the
+// owning peer could not be reached.
+func (c *Cluster) Forward(w http.ResponseWriter, r *http.Request, key string) bool {
+
+	if c == nil || r.RequestURI == "" || methods.HasBody(r.Method) ||
+		r.Header.Get(headers.NameTricksterPeer) != "" {
+		return false
+	}
+
+	c.mtx.RLock()
+	self := c.self
+	owner := c.ring.owner(key)
+	c.mtx.RUnlock()
+	if owner == "" || self == "" || owner == self {
+		return false
+	}
+
+	if c.isFailed(owner) {
+		metrics.ProxyPeerRequests.WithLabelValues(owner, "bypassed").Inc()
+		return false
+	}
+
+	// RequestURI is the path as requested by the client, before any origin path prefix was stripped
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return false
+	}
+	req, err := http.NewRequest(r.Method, owner+u.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	req = req.WithContext(r.Context())
+	req.Header = r.Header.Clone()
+	headers.StripClientHeaders(req.Header)
+	req.Header.Set(headers.NameTricksterPeer, self)
+	// the original Host is retained, so the owner routes the request to the same origin
+	req.Host = r.Host
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// a request canceled by the client says nothing about the owner's health
+		if r.Context().Err() == nil {
+			c.fail(owner)
+			metrics.ProxyPeerRequests.WithLabelValues(owner, "failed").Inc()
+		}
+		return false
+	}
+	defer resp.Body.Close()
+
+	h := w.Header()
+	for k, v := range resp.Header {
+		h[k] = v
+	}
+	headers.StripClientHeaders(h)
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	metrics.ProxyPeerRequests.WithLabelValues(owner, "forwarded").Inc()
+	return true
+}
+
+// fail records that the peer could not be reached, so it is bypassed until the failover
+// duration has elapsed
+func (c *Cluster) fail(peer string) {
+	if c.options.Failover <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	c.failed[peer] = c.now().Add(c.options.Failover)
+	c.mtx.Unlock()
+}
+
+func (c *Cluster) isFailed(peer string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	until, ok := c.failed[peer]
+	if !ok {
+		return false
+	}
+	if !c.now().Before(until) {
+		delete(c.failed, peer)
+		return false
+	}
+	return true
+}
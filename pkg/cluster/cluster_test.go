@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+func testCluster(self string, peers ...string) *Cluster {
+	o := options.NewOptions()
+	o.Self = self
+	o.Peers = peers
+	return New(o, "/trickster/cluster/gossip")
+}
+
+// keyOwnedBy returns a key that the cluster assigns to peer
+func keyOwnedBy(c *Cluster, peer string) string {
+	for i := 0; ; i++ {
+		k := "key" + strconv.Itoa(i)
+		if c.Owner(k) == peer {
+			return k
+		}
+	}
+}
+
+func testRequest(method string) *http.Request {
+	r := httptest.NewRequest(method, "http://trickster.example.com/default/api/v1/query?query=up", nil)
+	r.Header.Set(headers.NameConnection, "close")
+	return r
+}
+
+func TestNilCluster(t *testing.T) {
+	var c *Cluster
+	if c.Forward(httptest.NewRecorder(), testRequest(http.MethodGet), "test") {
+		t.Error("expected nil cluster to not forward")
+	}
+}
+
+func TestForward(t *testing.T) {
+
+	var received *http.Request
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Header().Set(headers.NameTricksterResult, "engine=DeltaProxyCache; status=hit")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("from peer"))
+	}))
+	defer peer.Close()
+
+	self := "http://127.0.0.1:1"
+	c := testCluster(self+"/", self, peer.URL+"/")
+	if c.Self() != self {
+		t.Errorf("expected %s got %s", self, c.Self())
+	}
+
+	// keys owned by this peer are served locally
+	w := httptest.NewRecorder()
+	if c.Forward(w, testRequest(http.MethodGet), keyOwnedBy(c, self)) {
+		t.Error("expected locally-owned key to not be forwarded")
+	}
+
+	// keys owned by another peer are served by it
+	key := keyOwnedBy(c, peer.URL)
+	w = httptest.NewRecorder()
+	if !c.Forward(w, testRequest(http.MethodGet), key) {
+		t.Fatal("expected key to be forwarded")
+	}
+	b, _ := ioutil.ReadAll(w.Body)
+	if string(b) != "from peer" {
+		t.Errorf("expected %s got %s", "from peer", string(b))
+	}
+	if w.Header().Get(headers.NameTricksterResult) != "engine=DeltaProxyCache; status=hit" {
+		t.Errorf("unexpected result header %s", w.Header().Get(headers.NameTricksterResult))
+	}
+	if received.Header.Get(headers.NameTricksterPeer) != self {
+		t.Errorf("expected %s got %s", self, received.Header.Get(headers.NameTricksterPeer))
+	}
+	if received.Host != "trickster.example.com" {
+		t.Errorf("expected %s got %s", "trickster.example.com", received.Host)
+	}
+	if received.URL.RequestURI() != "/default/api/v1/query?query=up" {
+		t.Errorf("unexpected request uri %s", received.URL.RequestURI())
+	}
+
+	// requests that were already forwarded, or that have a body, are served locally
+	r := testRequest(http.MethodGet)
+	r.Header.Set(headers.NameTricksterPeer, peer.URL)
+	if c.Forward(httptest.NewRecorder(), r, key) {
+		t.Error("expected forwarded request to not be forwarded again")
+	}
+	if c.Forward(httptest.NewRecorder(), testRequest(http.MethodPost), key) {
+		t.Error("expected POST request to not be forwarded")
+	}
+
+}
+
+func TestForwardFailover(t *testing.T) {
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	peerURL := peer.URL
+	peer.Close()
+
+	self := "http://127.0.0.1:1"
+	c := testCluster(self, self, peerURL)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	key := keyOwnedBy(c, peerURL)
+
+	// an unreachable owner is served locally, and bypassed until the failover has elapsed
+	if c.Forward(httptest.NewRecorder(), testRequest(http.MethodGet), key) {
+		t.Error("expected unreachable peer to not serve the request")
+	}
+	if !c.isFailed(peerURL) {
+		t.Error("expected peer to be marked as failed")
+	}
+
+	now = now.Add(c.options.Failover)
+	if c.isFailed(peerURL) {
+		t.Error("expected peer failover to have elapsed")
+	}
+
+}
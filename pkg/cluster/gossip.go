@@ -0,0 +1,308 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// maxGossipBytes is the largest gossip message that is accepted from a peer
+const maxGossipBytes = 1 << 20
+
+// gossipMessage is exchanged between peers to share the cluster membership each one knows of.
+// Members are described by how long ago they were last seen, rather than when, so that the
+// exchange is not affected by clock skew between the peers
+type gossipMessage struct {
+	From    string         `json:"from"`
+	Members []gossipMember `json:"members"`
+}
+
+type gossipMember struct {
+	URL   string `json:"url"`
+	AgeMS int64  `json:"age_ms"`
+}
+
+// Run exchanges cluster membership with a random peer on each GossipInterval until quit is
+// closed. It returns immediately when the peers are configured statically.
+func (c *Cluster) Run(quit <-chan struct{}) {
+	if c.options.Discovery() == options.DiscoveryStatic {
+		return
+	}
+	c.Gossip()
+	ticker := time.NewTicker(c.options.GossipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			c.Gossip()
+		}
+	}
+}
+
+// Gossip runs a single round of discovery: it resolves the DiscoveryDNS name, if any, then
+// exchanges cluster membership with one randomly chosen peer, and removes the members that
+// have not been heard from within the MemberTimeout
+func (c *Cluster) Gossip() {
+	var resolved []string
+	if c.options.DiscoveryDNS != "" {
+		resolved = c.resolvePeers()
+	}
+
+	c.mtx.Lock()
+	self := c.self
+	contacts := c.contacts(resolved)
+	c.mtx.Unlock()
+
+	if self != "" && len(contacts) > 0 {
+		target := contacts[rand.Intn(len(contacts))]
+		msg, err := c.exchange(target, c.message())
+		if err != nil {
+			metrics.ProxyPeerGossip.WithLabelValues("failed").Inc()
+		} else {
+			metrics.ProxyPeerGossip.WithLabelValues("success").Inc()
+			c.merge(msg)
+		}
+	}
+
+	c.mtx.Lock()
+	c.prune()
+	c.mtx.Unlock()
+}
+
+// resolvePeers returns the base URLs of the addresses that DiscoveryDNS resolves to. If this
+// peer's own URL is not configured, it is taken from the address that belongs to this host.
+func (c *Cluster) resolvePeers() []string {
+	host, port, err := net.SplitHostPort(c.options.DiscoveryDNS)
+	if err != nil {
+		return nil
+	}
+	addrs, err := c.resolve(host)
+	if err != nil {
+		return nil
+	}
+	scheme := "http"
+	if u, err := url.Parse(c.Self()); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	peers := make([]string, len(addrs))
+	for i, a := range addrs {
+		peers[i] = scheme + "://" + net.JoinHostPort(a, port)
+	}
+	if c.Self() == "" {
+		c.detectSelf(addrs, peers)
+	}
+	return peers
+}
+
+// detectSelf sets this peer's URL to the first of peers whose address is assigned to a local
+// network interface
+func (c *Cluster) detectSelf(addrs, peers []string) {
+	local, err := c.localAddrs()
+	if err != nil {
+		return
+	}
+	for _, la := range local {
+		ipn, ok := la.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		for i, a := range addrs {
+			if ip := net.ParseIP(a); ip != nil && ip.Equal(ipn.IP) {
+				c.mtx.Lock()
+				c.self = peers[i]
+				c.members[c.self] = c.now()
+				c.rebuild()
+				c.mtx.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// contacts returns the peers that may be gossiped with: the known members, the seeds and
+// the resolved peers, excluding this peer. The caller must hold the lock.
+func (c *Cluster) contacts(resolved []string) []string {
+	set := make(map[string]bool, len(c.members)+len(c.options.Seeds)+len(resolved))
+	for m := range c.members {
+		set[m] = true
+	}
+	for _, p := range c.options.Peers {
+		set[options.NormalizeURL(p)] = true
+	}
+	for _, p := range c.options.Seeds {
+		set[options.NormalizeURL(p)] = true
+	}
+	for _, p := range resolved {
+		set[p] = true
+	}
+	delete(set, c.self)
+	l := make([]string, 0, len(set))
+	for p := range set {
+		l = append(l, p)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// message returns the cluster membership known to this peer
+func (c *Cluster) message() *gossipMessage {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	now := c.now()
+	msg := &gossipMessage{From: c.self, Members: make([]gossipMember, 0, len(c.members))}
+	for m, seen := range c.members {
+		age := now.Sub(seen)
+		if m == c.self {
+			age = 0
+		}
+		msg.Members = append(msg.Members, gossipMember{URL: m, AgeMS: int64(age / time.Millisecond)})
+	}
+	return msg
+}
+
+// exchange sends this peer's membership to target and returns target's membership
+func (c *Cluster) exchange(target string, msg *gossipMessage) (*gossipMessage, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, target+c.gossipPath, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	resp, err := c.gossiper.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("peer %s responded with status %d", target, resp.StatusCode)
+	}
+	reply := &gossipMessage{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxGossipBytes)).Decode(reply); err != nil {
+		return nil, err
+	}
+	if reply.From == "" {
+		reply.From = target
+	}
+	return reply, nil
+}
+
+// merge adds the members of msg that were seen more recently than this peer has seen them,
+// and rebuilds the ring if the membership changed
+func (c *Cluster) merge(msg *gossipMessage) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := c.now()
+	if from := options.NormalizeURL(msg.From); from != "" && from != c.self {
+		c.members[from] = now
+		delete(c.failed, from)
+	}
+	for _, m := range msg.Members {
+		u := options.NormalizeURL(m.URL)
+		if u == "" || u == c.self || m.AgeMS < 0 {
+			continue
+		}
+		age := time.Duration(m.AgeMS) * time.Millisecond
+		if age >= c.options.MemberTimeout {
+			continue
+		}
+		if seen := now.Add(-age); seen.After(c.members[u]) {
+			c.members[u] = seen
+		}
+	}
+	c.rebuild()
+}
+
+// prune removes the members that have not been heard from within the MemberTimeout, and
+// rebuilds the ring if the membership changed. The caller must hold the lock.
+func (c *Cluster) prune() {
+	now := c.now()
+	for m, seen := range c.members {
+		if m != c.self && now.Sub(seen) >= c.options.MemberTimeout {
+			delete(c.members, m)
+		}
+	}
+	c.rebuild()
+}
+
+// rebuild recreates the ring when the members differ from the peers in the current ring.
+// The caller must hold the lock.
+func (c *Cluster) rebuild() {
+	peers := make([]string, 0, len(c.members))
+	for m := range c.members {
+		peers = append(peers, m)
+	}
+	sort.Strings(peers)
+	if c.ring != nil && equal(peers, c.peers) {
+		return
+	}
+	c.peers = peers
+	c.ring = newRing(peers, c.options.VirtualNodes)
+	metrics.ProxyClusterPeers.Set(float64(len(peers)))
+}
+
+// GossipHandler returns an http.Handler that merges the cluster membership sent by a peer,
+// and responds with the membership known to this peer
+func (c *Cluster) GossipHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := &gossipMessage{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxGossipBytes)).Decode(msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.merge(msg)
+		b, err := json.Marshal(c.message())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	})
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+)
+
+// testPeer is a cluster peer served by an httptest.Server
+type testPeer struct {
+	*Cluster
+	server *httptest.Server
+}
+
+func newTestPeer(seeds ...string) *testPeer {
+	tp := &testPeer{}
+	tp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tp.GossipHandler().ServeHTTP(w, r)
+	}))
+	o := options.NewOptions()
+	o.Self = tp.server.URL
+	o.Seeds = seeds
+	tp.Cluster = New(o, "/trickster/cluster/gossip")
+	return tp
+}
+
+func TestGossip(t *testing.T) {
+
+	a := newTestPeer("http://127.0.0.1:1")
+	defer a.server.Close()
+	b := newTestPeer(a.server.URL)
+	defer b.server.Close()
+	c := newTestPeer(b.server.URL)
+	defer c.server.Close()
+
+	if l := len(a.Peers()); l != 1 {
+		t.Errorf("expected %d got %d", 1, l)
+	}
+
+	// b joins through a, then c joins through b and learns of a
+	b.Gossip()
+	c.Gossip()
+	if l := len(c.Peers()); l != 3 {
+		t.Errorf("expected %d got %d", 3, l)
+	}
+	if l := len(a.Peers()); l != 2 {
+		t.Errorf("expected %d got %d", 2, l)
+	}
+
+	// every peer agrees on the owner of a key once they have converged
+	for i := 0; i < 10 && len(a.Peers()) < 3; i++ {
+		a.Gossip()
+	}
+	if l := len(a.Peers()); l != 3 {
+		t.Fatalf("expected %d got %d", 3, l)
+	}
+	for _, k := range []string{"key1", "key2", "key3"} {
+		if a.Owner(k) != c.Owner(k) {
+			t.Errorf("expected peers to agree on the owner of %s", k)
+		}
+	}
+
+}
+
+func TestGossipPrune(t *testing.T) {
+
+	a := newTestPeer("http://127.0.0.1:1")
+	defer a.server.Close()
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.merge(&gossipMessage{From: "http://127.0.0.1:2", Members: []gossipMember{
+		{URL: "http://127.0.0.1:3", AgeMS: 2000},
+		// members that have already timed out are not added
+		{URL: "http://127.0.0.1:4", AgeMS: 10000},
+	}})
+	if l := len(a.Peers()); l != 3 {
+		t.Fatalf("expected %d got %d", 3, l)
+	}
+
+	now = now.Add(8 * time.Second)
+	a.Gossip()
+	if p := a.Peers(); len(p) != 2 || p[0] != "http://127.0.0.1:2" {
+		t.Errorf("unexpected peers %v", p)
+	}
+
+	now = now.Add(2 * time.Second)
+	a.Gossip()
+	if p := a.Peers(); len(p) != 1 || p[0] != a.Self() {
+		t.Errorf("unexpected peers %v", p)
+	}
+
+}
+
+func TestGossipDNS(t *testing.T) {
+
+	o := options.NewOptions()
+	o.DiscoveryDNS = "trickster.default.svc.cluster.local:8480"
+	c := New(o, "/trickster/cluster/gossip")
+	if c.Self() != "" {
+		t.Errorf("expected empty self got %s", c.Self())
+	}
+
+	// requests are not forwarded until this peer's own URL is known
+	if c.Forward(httptest.NewRecorder(), testRequest(http.MethodGet), "test") {
+		t.Error("expected request to not be forwarded")
+	}
+
+	c.resolve = func(host string) ([]string, error) {
+		if host != "trickster.default.svc.cluster.local" {
+			return nil, errors.New("not found")
+		}
+		return []string{"127.0.0.1", "127.0.0.2"}, nil
+	}
+	c.localAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{&net.IPNet{IP: net.ParseIP("127.0.0.2"), Mask: net.CIDRMask(8, 32)}}, nil
+	}
+	c.gossiper.Timeout = 100 * time.Millisecond
+
+	peers := c.resolvePeers()
+	if len(peers) != 2 || peers[0] != "http://127.0.0.1:8480" {
+		t.Errorf("unexpected peers %v", peers)
+	}
+	if c.Self() != "http://127.0.0.2:8480" {
+		t.Errorf("expected %s got %s", "http://127.0.0.2:8480", c.Self())
+	}
+
+	c.mtx.Lock()
+	contacts := c.contacts(peers)
+	c.mtx.Unlock()
+	if len(contacts) != 1 || contacts[0] != "http://127.0.0.1:8480" {
+		t.Errorf("unexpected contacts %v", contacts)
+	}
+
+}
+
+func TestGossipHandler(t *testing.T) {
+
+	c := testCluster("http://127.0.0.1:1", "http://127.0.0.1:1")
+	h := c.GossipHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/trickster/cluster/gossip",
+		bytes.NewReader([]byte("{"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	b, _ := json.Marshal(&gossipMessage{From: "http://127.0.0.1:2"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/trickster/cluster/gossip",
+		bytes.NewReader(b)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	msg := &gossipMessage{}
+	if err := json.NewDecoder(w.Body).Decode(msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.From != "http://127.0.0.1:1" || len(msg.Members) != 2 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+}
+
+func TestRunStatic(t *testing.T) {
+	c := testCluster("http://127.0.0.1:1", "http://127.0.0.1:1")
+	// Run returns immediately when the peers are configured statically
+	c.Run(make(chan struct{}))
+}
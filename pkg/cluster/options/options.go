@@ -0,0 +1,213 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the cluster peer options
+package options
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Discovery methods, as returned by Options.Discovery
+const (
+	// DiscoveryStatic indicates the cluster's peers are the configured list of Peers
+	DiscoveryStatic = "static"
+	// DiscoveryGossip indicates the cluster's peers are discovered by exchanging membership
+	// with the Seeds and the peers they know of
+	DiscoveryGossip = "gossip"
+	// DiscoveryDNS indicates the cluster's peers are discovered by resolving DiscoveryDNS,
+	// and then exchanging membership with the resolved peers
+	DiscoveryDNS = "dns"
+)
+
+// Options configures the peers of a Trickster cluster, which divide the cache key space
+// among themselves so that each object is cached by only one peer
+type Options struct {
+	// Self is the base URL at which the other peers reach this Trickster. With static
+	// discovery, it must be one of the Peers. With DNS discovery, it may be left empty to use
+	// the resolved address that belongs to this host
+	Self string `toml:"self"`
+	// Peers is the list of base URLs of every peer in the cluster, including Self
+	Peers []string `toml:"peers"`
+	// Seeds is a list of base URLs of peers that are contacted to join the cluster, after
+	// which the other peers are discovered by gossip
+	Seeds []string `toml:"seeds"`
+	// DiscoveryDNS is a host:port (e.g., a Kubernetes headless service name and the frontend
+	// port), whose addresses are periodically resolved and contacted to join the cluster
+	DiscoveryDNS string `toml:"discovery_dns"`
+	// GossipIntervalMS is the interval between exchanges of cluster membership with a peer
+	GossipIntervalMS int `toml:"gossip_interval_ms"`
+	// MemberTimeoutMS is the duration after which a discovered peer that has not been heard
+	// from, directly or through another peer, is removed from the cluster
+	MemberTimeoutMS int `toml:"member_timeout_ms"`
+	// VirtualNodes is the number of points each peer occupies on the consistent hash ring.
+	// More points distribute the key space more evenly among the peers
+	VirtualNodes int `toml:"virtual_nodes"`
+	// TimeoutMS is the maximum duration of a request forwarded to a peer
+	TimeoutMS int `toml:"timeout_ms"`
+	// FailoverSecs is the duration for which a peer that could not be reached is bypassed,
+	// with requests for the keys it owns served locally
+	FailoverSecs int `toml:"failover_secs"`
+
+	// Timeout is the parsed value of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+	// Failover is the parsed value of FailoverSecs
+	Failover time.Duration `toml:"-"`
+	// GossipInterval is the parsed value of GossipIntervalMS
+	GossipInterval time.Duration `toml:"-"`
+	// MemberTimeout is the parsed value of MemberTimeoutMS
+	MemberTimeout time.Duration `toml:"-"`
+}
+
+// Errors returned by Validate
+var (
+	ErrInvalidPeer         = errors.New("cluster peers must be absolute http or https URLs")
+	ErrInvalidSelf         = errors.New("cluster self must be one of the cluster peers")
+	ErrInvalidVirtualNodes = errors.New("cluster virtual_nodes must be greater than 0")
+	ErrInvalidTimeout      = errors.New("cluster timeout_ms must be greater than 0")
+	ErrInvalidFailover     = errors.New("cluster failover_secs must be 0 or greater")
+	ErrInvalidDiscoveryDNS = errors.New("cluster discovery_dns must be in host:port format")
+	ErrInvalidGossip       = errors.New("cluster member_timeout_ms must be greater than gossip_interval_ms, which must be greater than 0")
+)
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		VirtualNodes: d.DefaultClusterVirtualNodes,
+		TimeoutMS:    d.DefaultClusterTimeoutMS,
+		FailoverSecs: d.DefaultClusterFailoverSecs,
+
+		GossipIntervalMS: d.DefaultClusterGossipIntervalMS,
+		MemberTimeoutMS:  d.DefaultClusterMemberTimeoutMS,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Self:             o.Self,
+		Peers:            cloneList(o.Peers),
+		Seeds:            cloneList(o.Seeds),
+		DiscoveryDNS:     o.DiscoveryDNS,
+		GossipIntervalMS: o.GossipIntervalMS,
+		MemberTimeoutMS:  o.MemberTimeoutMS,
+		VirtualNodes:     o.VirtualNodes,
+		TimeoutMS:        o.TimeoutMS,
+		FailoverSecs:     o.FailoverSecs,
+		Timeout:          o.Timeout,
+		Failover:         o.Failover,
+		GossipInterval:   o.GossipInterval,
+		MemberTimeout:    o.MemberTimeout,
+	}
+}
+
+func cloneList(l []string) []string {
+	if l == nil {
+		return nil
+	}
+	c := make([]string, len(l))
+	copy(c, l)
+	return c
+}
+
+// Enabled returns true if any cluster peers or means of discovering them are configured
+func (o *Options) Enabled() bool {
+	return o != nil && (len(o.Peers) > 0 || len(o.Seeds) > 0 || o.DiscoveryDNS != "")
+}
+
+// Discovery returns the method by which the cluster's peers are discovered
+func (o *Options) Discovery() string {
+	switch {
+	case o.DiscoveryDNS != "":
+		return DiscoveryDNS
+	case len(o.Seeds) > 0:
+		return DiscoveryGossip
+	}
+	return DiscoveryStatic
+}
+
+// Validate returns an error if any of the options are out of range
+func (o *Options) Validate() error {
+	if !o.Enabled() {
+		return nil
+	}
+	var found bool
+	for _, p := range append(cloneList(o.Peers), o.Seeds...) {
+		if !isPeerURL(p) {
+			return ErrInvalidPeer
+		}
+		if NormalizeURL(p) == NormalizeURL(o.Self) {
+			found = true
+		}
+	}
+	switch o.Discovery() {
+	case DiscoveryStatic:
+		if !found {
+			return ErrInvalidSelf
+		}
+	case DiscoveryGossip:
+		if !isPeerURL(o.Self) {
+			return ErrInvalidSelf
+		}
+	case DiscoveryDNS:
+		if _, _, err := net.SplitHostPort(o.DiscoveryDNS); err != nil {
+			return ErrInvalidDiscoveryDNS
+		}
+		if o.Self != "" && !isPeerURL(o.Self) {
+			return ErrInvalidSelf
+		}
+	}
+	if o.GossipIntervalMS <= 0 || o.MemberTimeoutMS <= o.GossipIntervalMS {
+		return ErrInvalidGossip
+	}
+	if o.VirtualNodes <= 0 {
+		return ErrInvalidVirtualNodes
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	if o.FailoverSecs < 0 {
+		return ErrInvalidFailover
+	}
+	return nil
+}
+
+// SetDurations sets the parsed duration fields from their configured values
+func (o *Options) SetDurations() {
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+	o.Failover = time.Duration(o.FailoverSecs) * time.Second
+	o.GossipInterval = time.Duration(o.GossipIntervalMS) * time.Millisecond
+	o.MemberTimeout = time.Duration(o.MemberTimeoutMS) * time.Millisecond
+}
+
+func isPeerURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// NormalizeURL returns the peer URL without any trailing slashes, so that it can be
+// compared to other peer URLs and joined with request paths
+func NormalizeURL(u string) string {
+	return strings.TrimRight(u, "/")
+}
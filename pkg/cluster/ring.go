@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// ring is a consistent hash ring of the cluster peers. Each peer occupies several points
+// on the ring, and owns the keys that hash between its points and the preceding points, so
+// adding or removing a peer only moves the keys adjacent to its own points
+type ring struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+func newRing(peers []string, virtualNodes int) *ring {
+	r := &ring{
+		points: make([]uint64, 0, len(peers)*virtualNodes),
+		owners: make(map[uint64]string, len(peers)*virtualNodes),
+	}
+	for _, p := range peers {
+		for i := 0; i < virtualNodes; i++ {
+			h := hash(p + "#" + strconv.Itoa(i))
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.owners[h] = p
+			r.points = append(r.points, h)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// owner returns the peer that owns key
+func (r *ring) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// shares returns the fraction of the key space owned by each peer
+func (r *ring) shares() map[string]float64 {
+	m := make(map[string]float64)
+	n := len(r.points)
+	if n == 1 {
+		m[r.owners[r.points[0]]] = 1
+		return m
+	}
+	for i, p := range r.points {
+		// each point owns the arc from the preceding point, which wraps around the ring at 0
+		prev := r.points[(i+n-1)%n]
+		m[r.owners[p]] += float64(p-prev) / (1 << 64)
+	}
+	return m
+}
+
+func hash(s string) uint64 {
+	sum := md5.Sum([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
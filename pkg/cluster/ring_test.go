@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRingOwner(t *testing.T) {
+
+	peers := []string{"http://a:8480", "http://b:8480", "http://c:8480"}
+	r := newRing(peers, 160)
+
+	counts := make(map[string]int)
+	owners := make(map[string]string)
+	for i := 0; i < 30000; i++ {
+		k := "key" + strconv.Itoa(i)
+		o := r.owner(k)
+		if o != r.owner(k) {
+			t.Fatalf("expected consistent owner for %s", k)
+		}
+		counts[o]++
+		owners[k] = o
+	}
+
+	// each peer owns roughly a third of the key space
+	for _, p := range peers {
+		if counts[p] < 7500 || counts[p] > 12500 {
+			t.Errorf("unbalanced ring: %s owns %d of 30000 keys", p, counts[p])
+		}
+	}
+
+	// adding a peer only moves keys to the new peer
+	r = newRing(append(peers, "http://d:8480"), 160)
+	var moved int
+	for k, o := range owners {
+		if no := r.owner(k); no != o {
+			if no != "http://d:8480" {
+				t.Fatalf("expected %s to move to the new peer, got %s", k, no)
+			}
+			moved++
+		}
+	}
+	if moved < 5000 || moved > 10000 {
+		t.Errorf("expected about a quarter of the keys to move, got %d of 30000", moved)
+	}
+
+}
+
+func TestRingEmpty(t *testing.T) {
+	r := newRing(nil, 160)
+	if o := r.owner("test"); o != "" {
+		t.Errorf("expected empty owner got %s", o)
+	}
+}
+
+func TestRingShares(t *testing.T) {
+
+	peers := []string{"http://a:8480", "http://b:8480", "http://c:8480"}
+	shares := newRing(peers, 160).shares()
+	var total float64
+	for _, p := range peers {
+		if shares[p] < 0.25 || shares[p] > 0.42 {
+			t.Errorf("unbalanced ring: %s owns %f of the key space", p, shares[p])
+		}
+		total += shares[p]
+	}
+	if total < 0.999 || total > 1.001 {
+		t.Errorf("expected shares to total 1 got %f", total)
+	}
+
+	shares = newRing(peers[:1], 1).shares()
+	if shares[peers[0]] != 1 {
+		t.Errorf("expected %d got %f", 1, shares[peers[0]])
+	}
+
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+)
+
+// Peer states reported by Status
+const (
+	StateSelf   = "self"
+	StateAlive  = "alive"
+	StateFailed = "failed"
+)
+
+// Status describes the cluster as seen by this peer
+type Status struct {
+	Self      string       `json:"self"`
+	Discovery string       `json:"discovery"`
+	Peers     []PeerStatus `json:"peers"`
+}
+
+// PeerStatus describes a cluster peer and its share of the cache key space
+type PeerStatus struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+	// LastSeenMS is how long ago the peer was last heard from, directly or through another
+	// peer. It is omitted when the peers are configured statically
+	LastSeenMS *int64 `json:"last_seen_ms_ago,omitempty"`
+	// ShardRatio is the fraction of the cache key space owned by the peer
+	ShardRatio float64 `json:"shard_ratio"`
+}
+
+// Status returns the current status of the cluster
+func (c *Cluster) Status() *Status {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	now := c.now()
+	discovery := c.options.Discovery()
+	shares := c.ring.shares()
+	s := &Status{
+		Self:      c.self,
+		Discovery: discovery,
+		Peers:     make([]PeerStatus, 0, len(c.peers)),
+	}
+	for _, p := range c.peers {
+		ps := PeerStatus{URL: p, State: StateAlive, ShardRatio: shares[p]}
+		if p == c.self {
+			ps.State = StateSelf
+		} else if until, ok := c.failed[p]; ok && now.Before(until) {
+			ps.State = StateFailed
+		}
+		if discovery != options.DiscoveryStatic && p != c.self {
+			ms := int64(now.Sub(c.members[p]) / time.Millisecond)
+			ps.LastSeenMS = &ms
+		}
+		s.Peers = append(s.Peers, ps)
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster/options"
+)
+
+func TestStatus(t *testing.T) {
+
+	self := "http://127.0.0.1:1"
+	c := testCluster(self, self, "http://127.0.0.1:2", "http://127.0.0.1:3")
+	c.fail("http://127.0.0.1:3")
+
+	s := c.Status()
+	if s.Self != self || s.Discovery != options.DiscoveryStatic {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if len(s.Peers) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(s.Peers))
+	}
+	var total float64
+	for i, state := range []string{StateSelf, StateAlive, StateFailed} {
+		if s.Peers[i].State != state {
+			t.Errorf("expected %s got %s", state, s.Peers[i].State)
+		}
+		if s.Peers[i].LastSeenMS != nil {
+			t.Error("expected no last seen time for static peers")
+		}
+		total += s.Peers[i].ShardRatio
+	}
+	if total < 0.999 || total > 1.001 {
+		t.Errorf("expected shard ratios to total 1 got %f", total)
+	}
+
+}
+
+func TestStatusGossip(t *testing.T) {
+
+	o := options.NewOptions()
+	o.Self = "http://127.0.0.1:1"
+	o.Seeds = []string{"http://127.0.0.1:2"}
+	c := New(o, "/trickster/cluster/gossip")
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.merge(&gossipMessage{From: "http://127.0.0.1:2"})
+	now = now.Add(1500 * time.Millisecond)
+
+	s := c.Status()
+	if s.Discovery != options.DiscoveryGossip || len(s.Peers) != 2 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if s.Peers[0].LastSeenMS != nil {
+		t.Error("expected no last seen time for self")
+	}
+	if s.Peers[1].LastSeenMS == nil || *s.Peers[1].LastSeenMS != 1500 {
+		t.Errorf("unexpected last seen time %v", s.Peers[1].LastSeenMS)
+	}
+
+}
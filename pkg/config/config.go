@@ -21,29 +21,49 @@ package config
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/compression"
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	cache "github.com/tricksterproxy/trickster/pkg/cache/options"
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/types"
+	cl "github.com/tricksterproxy/trickster/pkg/cluster"
+	cluster "github.com/tricksterproxy/trickster/pkg/cluster/options"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 	reload "github.com/tricksterproxy/trickster/pkg/config/reload/options"
+	"github.com/tricksterproxy/trickster/pkg/config/remote"
+	ff "github.com/tricksterproxy/trickster/pkg/featureflags/options"
+	memory "github.com/tricksterproxy/trickster/pkg/memory/options"
+	azo "github.com/tricksterproxy/trickster/pkg/proxy/authz/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	sh "github.com/tricksterproxy/trickster/pkg/proxy/headers/security/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/identity"
+	invalidation "github.com/tricksterproxy/trickster/pkg/proxy/invalidation/options"
 	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	prometheus "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
 	rule "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	pao "github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
 	rewriter "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
 	rwopts "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter/options"
+	acme "github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
 	to "github.com/tricksterproxy/trickster/pkg/proxy/tls/options"
+	tp "github.com/tricksterproxy/trickster/pkg/proxy/tls/policy/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tracing "github.com/tricksterproxy/trickster/pkg/tracing/options"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -72,6 +92,22 @@ type Config struct {
 	RequestRewriters map[string]*rwopts.Options `toml:"request_rewriters"`
 	// ReloadConfig provides configurations for in-process config reloading
 	ReloadConfig *reload.Options `toml:"reloading"`
+	// Memory provides the soft memory limit and garbage collection configurations
+	Memory *memory.Options `toml:"memory"`
+	// Cluster provides the peers among which the cache key space is divided
+	Cluster *cluster.Options `toml:"cluster"`
+	// Invalidation configures the webhook through which signed requests invalidate cached objects
+	Invalidation *invalidation.Options `toml:"invalidation"`
+	// SecurityHeaders is a map of named security response header profiles
+	SecurityHeaders map[string]*sh.Options `toml:"security_headers"`
+	// Authorizers is a map of named external authorization service profiles
+	Authorizers map[string]*azo.Options `toml:"authorizers"`
+	// TLSPolicies is a map of named TLS policy profiles, in addition to the built-in profiles
+	TLSPolicies map[string]*tp.Options `toml:"tls_policies"`
+	// ACME configures the TLS listener certificates that are obtained from an ACME certificate authority
+	ACME *acme.Options `toml:"acme"`
+	// FeatureFlags is a map of the feature flags that gate behaviors by percentage of requests and by origin
+	FeatureFlags map[string]*ff.Options `toml:"feature_flags"`
 
 	// Resources holds runtime resources uses by the Config
 	Resources *Resources `toml:"-"`
@@ -96,6 +132,21 @@ type MainConfig struct {
 	ReloadHandlerPath string `toml:"reload_handler_path"`
 	// HeatlHandlerPath provides the base Health Check Handler path
 	HealthHandlerPath string `toml:"health_handler_path"`
+	// ReplicationHandlerPath provides the base Cache Replication Handler path
+	ReplicationHandlerPath string `toml:"replication_handler_path"`
+	// ClusterHandlerPath provides the path to register the Cluster Status Handler, under which
+	// the cluster peers also exchange membership
+	ClusterHandlerPath string `toml:"cluster_handler_path"`
+	// InvalidationHandlerPath provides the path to register the Cache Invalidation Webhook Handler
+	InvalidationHandlerPath string `toml:"invalidation_handler_path"`
+	// CacheStatsHandlerPath provides the path to register the Cache Statistics Handler on the metrics listener
+	CacheStatsHandlerPath string `toml:"cache_stats_handler_path"`
+	// ExtentsHandlerPath provides the path to register the Timeseries Extents Handler on the metrics listener
+	ExtentsHandlerPath string `toml:"extents_handler_path"`
+	// PurgeHandlerPath provides the path to register the Cache Purge Handler on the metrics listener
+	PurgeHandlerPath string `toml:"purge_handler_path"`
+	// HeatmapHandlerPath provides the base Latency Heatmap Handler path
+	HeatmapHandlerPath string `toml:"heatmap_handler_path"`
 	// PprofServer provides the name of the http listener that will host the pprof debugging routes
 	// Options are: "metrics", "reload", "both", or "off"; default is both
 	PprofServer string `toml:"pprof_server"`
@@ -108,6 +159,9 @@ type MainConfig struct {
 
 	configFilePath      string
 	configLastModified  time.Time
+	includedFiles       map[string]time.Time
+	configSource        *remote.Source
+	configVersion       string
 	configRateLimitTime time.Time
 	stalenessCheckLock  sync.Mutex
 }
@@ -122,9 +176,62 @@ type FrontendConfig struct {
 	TLSListenAddress string `toml:"tls_listen_address"`
 	// TLSListenPort is the TCP Port for the tls http listener for the application
 	TLSListenPort int `toml:"tls_listen_port"`
+	// ListenAddresses provides multiple IP addresses for the main http listener. When set,
+	// it is used instead of ListenAddress
+	ListenAddresses []string `toml:"listen_addresses"`
+	// TLSListenAddresses provides multiple IP addresses for the tls http listener. When set,
+	// it is used instead of TLSListenAddress
+	TLSListenAddresses []string `toml:"tls_listen_addresses"`
+	// AddressFamily is the address family of the http and tls listeners: 'tcp4', 'tcp6' or 'dual'
+	AddressFamily string `toml:"address_family"`
 	// ConnectionsLimit indicates how many concurrent front end connections trickster will handle at any time
 	ConnectionsLimit int `toml:"connections_limit"`
-
+	// SecurityHeadersName is the name of the security headers profile applied to all frontend responses,
+	// unless the response's origin config provides its own profile
+	SecurityHeadersName string `toml:"security_headers_name"`
+	// TrustedProxies is a list of IP addresses and CIDRs of proxies from which inbound
+	// X-Forwarded-* and Forwarded headers are trusted
+	TrustedProxies []string `toml:"trusted_proxies"`
+	// ForwardedHeadersPolicy indicates how inbound X-Forwarded-* and Forwarded headers are treated:
+	// 'append' trusts them from all clients, 'sanitize' trusts them only from TrustedProxies,
+	// and 'replace' always discards them
+	ForwardedHeadersPolicy string `toml:"forwarded_headers_policy"`
+	// TLSPolicyName is the name of the TLS policy profile applied to the TLS listener
+	TLSPolicyName string `toml:"tls_policy_name"`
+	// TLSSessionTicketsDisabled disables TLS session resumption via session tickets, so that
+	// every client connection performs a full handshake
+	TLSSessionTicketsDisabled bool `toml:"tls_session_tickets_disabled"`
+	// TLSSessionTicketKeyRotationSecs is the interval at which session ticket keys are rotated.
+	// When 0, the Go runtime's built-in key rotation is used
+	TLSSessionTicketKeyRotationSecs int `toml:"tls_session_ticket_key_rotation_secs"`
+	// TLSSessionTicketCacheName is the name of a cache used to share session ticket keys
+	// across Trickster replicas
+	TLSSessionTicketCacheName string `toml:"tls_session_ticket_cache_name"`
+	// TLSClientCAPaths provides a list of Certificate Authorities used to verify client certificates
+	// presented to the TLS listener. When set, clients may authenticate with a certificate (mTLS)
+	TLSClientCAPaths []string `toml:"tls_client_ca_paths"`
+	// IdentityMode indicates how the identity of the user making a request is resolved:
+	// 'trusted_headers' trusts identity headers injected by an upstream SSO proxy. empty disables identity
+	IdentityMode string `toml:"identity_mode"`
+	// IdentityUserHeader is the header that provides the name of the user
+	IdentityUserHeader string `toml:"identity_user_header"`
+	// IdentityGroupsHeader is the header that provides the comma-separated groups of the user
+	IdentityGroupsHeader string `toml:"identity_groups_header"`
+	// IdentityTrustedProxies is a list of IP addresses and CIDRs of proxies from which identity headers are trusted
+	IdentityTrustedProxies []string `toml:"identity_trusted_proxies"`
+	// IdentityTrustedPeers is a list of client certificate Common Names or DNS Subject Alternative Names
+	// of mTLS peers from which identity headers are trusted
+	IdentityTrustedPeers []string `toml:"identity_trusted_peers"`
+
+	// TrustedProxyNets is the parsed representation of TrustedProxies
+	TrustedProxyNets headers.TrustedProxies `toml:"-"`
+	// TLSPolicy is the TLS policy profile represented by TLSPolicyName
+	TLSPolicy *tp.Options `toml:"-"`
+	// IdentityResolver resolves request identities as configured by the Identity options
+	IdentityResolver *identity.Resolver `toml:"-"`
+	// DevTLS indicates the TLS listener should serve an in-memory self-signed certificate,
+	// as set by the -dev-tls command line flag
+	DevTLS bool `toml:"-"`
 	// ServeTLS indicates whether to listen and serve on the TLS port, meaning
 	// at least one origin configuration has a valid certificate and key file configured.
 	ServeTLS bool `toml:"-"`
@@ -136,6 +243,9 @@ type LoggingConfig struct {
 	LogFile string `toml:"log_file"`
 	// LogLevel provides the most granular level (e.g., DEBUG, INFO, ERROR) to log
 	LogLevel string `toml:"log_level"`
+	// CanonicalLogLevel provides the level (e.g., DEBUG, INFO, NONE) at which a single summarizing
+	// event is logged for each proxied request
+	CanonicalLogLevel string `toml:"canonical_log_level"`
 }
 
 // MetricsConfig is a collection of Metrics Collection configurations
@@ -144,11 +254,83 @@ type MetricsConfig struct {
 	ListenAddress string `toml:"listen_address"`
 	// ListenPort is TCP Port from which the Application Metrics are available for pulling at /metrics
 	ListenPort int `toml:"listen_port"`
+	// ListenAddresses provides multiple IP addresses for the metrics listener. When set,
+	// it is used instead of ListenAddress
+	ListenAddresses []string `toml:"listen_addresses"`
+	// AddressFamily is the address family of the metrics listener: 'tcp4', 'tcp6' or 'dual'
+	AddressFamily string `toml:"address_family"`
+	// TLSCertificatePath is the path of the certificate (or full chain) that the metrics listener
+	// serves TLS with. When set, the metrics listener serves only TLS
+	TLSCertificatePath string `toml:"tls_certificate_path"`
+	// TLSPrivateKeyPath is the path of the private key of TLSCertificatePath
+	TLSPrivateKeyPath string `toml:"tls_private_key_path"`
+	// TLSClientCAPaths provides the paths of the CA certificates that issue client certificates.
+	// When set, the metrics listener requires clients to present a certificate issued by one of them
+	TLSClientCAPaths []string `toml:"tls_client_ca_paths"`
+	// TLSPolicyName is the name of the TLS policy that governs the metrics listener's TLS versions,
+	// cipher suites and curves
+	TLSPolicyName string `toml:"tls_policy_name"`
+	// AuthorizerName is the name of the external authorizer that allows or denies requests
+	// to the metrics listener
+	AuthorizerName string `toml:"authorizer_name"`
+	// ServeAdmin, when true, serves the ping, cluster status, origin health and heatmap endpoints,
+	// and the reload, rules, flags and compact handlers, on the metrics listener. It requires
+	// the metrics listener to authenticate its clients with an authorizer or client certificates
+	ServeAdmin bool `toml:"serve_admin"`
+	// FrontendAdminDisabled, when true, does not serve the ping, cluster status, origin health
+	// and heatmap endpoints on the frontend listeners
+	FrontendAdminDisabled bool `toml:"frontend_admin_disabled"`
+	// ServePurge, when true, serves the cache purge endpoint on the metrics listener. It requires
+	// the metrics listener to authenticate its clients with an authorizer or client certificates
+	ServePurge bool `toml:"serve_purge"`
+
+	// TLSPolicy is the reference to the TLS policy options as indicated by TLSPolicyName
+	TLSPolicy *tp.Options `toml:"-"`
+	// Authorizer is the reference to the external authorizer options as indicated by AuthorizerName
+	Authorizer *azo.Options `toml:"-"`
+}
+
+// ErrInvalidMetricsTLS is returned when the metrics listener's TLS configuration is incomplete
+var ErrInvalidMetricsTLS = errors.New(
+	"metrics tls_certificate_path and tls_private_key_path must be provided together, " +
+		"and are required by tls_client_ca_paths and tls_policy_name")
+
+// ErrUnauthenticatedPurge is returned when the purge endpoint is served by a metrics listener
+// that does not authenticate its clients
+var ErrUnauthenticatedPurge = errors.New(
+	"metrics serve_purge requires authorizer_name or tls_client_ca_paths")
+
+// ErrUnauthenticatedAdmin is returned when the administrative handlers are served by a metrics
+// listener that does not authenticate its clients
+var ErrUnauthenticatedAdmin = errors.New(
+	"metrics serve_admin requires authorizer_name or tls_client_ca_paths")
+
+// ServeTLS returns true if the metrics listener serves TLS
+func (mc *MetricsConfig) ServeTLS() bool {
+	return mc != nil && mc.TLSCertificatePath != "" && mc.TLSPrivateKeyPath != ""
+}
+
+// ListenerEqual returns true if the metrics listeners of the subject and provided
+// MetricsConfigs are configured identically
+func (mc *MetricsConfig) ListenerEqual(mc2 *MetricsConfig) bool {
+	return mc2 != nil && mc.ListenAddress == mc2.ListenAddress &&
+		mc.ListenPort == mc2.ListenPort &&
+		mc.AddressFamily == mc2.AddressFamily &&
+		str.Equal(mc.ListenAddresses, mc2.ListenAddresses) &&
+		mc.TLSCertificatePath == mc2.TLSCertificatePath &&
+		mc.TLSPrivateKeyPath == mc2.TLSPrivateKeyPath &&
+		str.Equal(mc.TLSClientCAPaths, mc2.TLSClientCAPaths) &&
+		mc.TLSPolicyName == mc2.TLSPolicyName
 }
 
 // Resources is a collection of values used by configs at runtime that are not part of the config itself
 type Resources struct {
 	QuitChan chan bool `toml:"-"`
+	// BackgroundQuitChan is closed when the config is replaced, signaling
+	// any background tasks started under it to exit
+	BackgroundQuitChan chan struct{} `toml:"-"`
+	// Cluster routes requests among the cluster peers, when clustering is enabled
+	Cluster  *cl.Cluster `toml:"-"`
 	metadata *toml.MetaData
 }
 
@@ -172,28 +354,39 @@ func NewConfig() *Config {
 			"default": cache.NewOptions(),
 		},
 		Logging: &LoggingConfig{
-			LogFile:  d.DefaultLogFile,
-			LogLevel: d.DefaultLogLevel,
+			LogFile:           d.DefaultLogFile,
+			LogLevel:          d.DefaultLogLevel,
+			CanonicalLogLevel: d.DefaultCanonicalLogLevel,
 		},
 		Main: &MainConfig{
-			ConfigHandlerPath: d.DefaultConfigHandlerPath,
-			PingHandlerPath:   d.DefaultPingHandlerPath,
-			ReloadHandlerPath: d.DefaultReloadHandlerPath,
-			HealthHandlerPath: d.DefaultHealthHandlerPath,
-			PprofServer:       d.DefaultPprofServerName,
-			ServerName:        hn,
+			ConfigHandlerPath:       d.DefaultConfigHandlerPath,
+			PingHandlerPath:         d.DefaultPingHandlerPath,
+			ReloadHandlerPath:       d.DefaultReloadHandlerPath,
+			HealthHandlerPath:       d.DefaultHealthHandlerPath,
+			ReplicationHandlerPath:  d.DefaultReplicationHandlerPath,
+			ClusterHandlerPath:      d.DefaultClusterHandlerPath,
+			InvalidationHandlerPath: d.DefaultInvalidationHandlerPath,
+			PurgeHandlerPath:        d.DefaultPurgeHandlerPath,
+			CacheStatsHandlerPath:   d.DefaultCacheStatsHandlerPath,
+			ExtentsHandlerPath:      d.DefaultExtentsHandlerPath,
+			HeatmapHandlerPath:      d.DefaultHeatmapHandlerPath,
+			PprofServer:             d.DefaultPprofServerName,
+			ServerName:              hn,
 		},
 		Metrics: &MetricsConfig{
-			ListenPort: d.DefaultMetricsListenPort,
+			ListenPort:    d.DefaultMetricsListenPort,
+			AddressFamily: d.DefaultAddressFamily,
 		},
 		Origins: map[string]*origins.Options{
 			"default": origins.NewOptions(),
 		},
 		Frontend: &FrontendConfig{
-			ListenPort:       d.DefaultProxyListenPort,
-			ListenAddress:    d.DefaultProxyListenAddress,
-			TLSListenPort:    d.DefaultTLSProxyListenPort,
-			TLSListenAddress: d.DefaultTLSProxyListenAddress,
+			ListenPort:             d.DefaultProxyListenPort,
+			ListenAddress:          d.DefaultProxyListenAddress,
+			TLSListenPort:          d.DefaultTLSProxyListenPort,
+			TLSListenAddress:       d.DefaultTLSProxyListenAddress,
+			AddressFamily:          d.DefaultAddressFamily,
+			ForwardedHeadersPolicy: d.DefaultForwardedHeadersPolicy,
 		},
 		NegativeCacheConfigs: map[string]NegativeCacheConfig{
 			"default": NewNegativeCacheConfig(),
@@ -202,9 +395,14 @@ func NewConfig() *Config {
 			"default": tracing.NewOptions(),
 		},
 		ReloadConfig:   reload.NewOptions(),
+		Memory:         memory.NewOptions(),
+		Cluster:        cluster.NewOptions(),
+		Invalidation:   invalidation.NewOptions(),
+		ACME:           acme.NewOptions(),
 		LoaderWarnings: make([]string, 0),
 		Resources: &Resources{
-			QuitChan: make(chan bool, 1),
+			QuitChan:           make(chan bool, 1),
+			BackgroundQuitChan: make(chan struct{}),
 		},
 	}
 }
@@ -216,6 +414,9 @@ func NewNegativeCacheConfig() NegativeCacheConfig {
 
 // loadFile loads application configuration from a TOML-formatted file.
 func (c *Config) loadFile(flags *Flags) error {
+	if remote.IsRemote(flags.ConfigPath) {
+		return c.loadRemote(flags)
+	}
 	b, err := ioutil.ReadFile(flags.ConfigPath)
 	if err != nil {
 		c.setDefaults(&toml.MetaData{})
@@ -226,6 +427,17 @@ func (c *Config) loadFile(flags *Flags) error {
 
 // loadTOMLConfig loads application configuration from a TOML-formatted byte slice.
 func (c *Config) loadTOMLConfig(tml string, flags *Flags) error {
+	var dir string
+	var allowIncludes bool
+	if flags != nil && flags.ConfigPath != "" && !remote.IsRemote(flags.ConfigPath) {
+		dir = filepath.Dir(flags.ConfigPath)
+		allowIncludes = true
+	}
+	tml, included, err := expandTemplates(tml, dir, allowIncludes)
+	if err != nil {
+		c.setDefaults(&toml.MetaData{})
+		return err
+	}
 	md, err := toml.Decode(tml, c)
 	if err != nil {
 		c.setDefaults(&toml.MetaData{})
@@ -235,6 +447,42 @@ func (c *Config) loadTOMLConfig(tml string, flags *Flags) error {
 	if err == nil {
 		c.Main.configFilePath = flags.ConfigPath
 		c.Main.configLastModified = c.CheckFileLastModified()
+		if len(included) > 0 {
+			c.Main.includedFiles = make(map[string]time.Time, len(included))
+			for _, f := range included {
+				c.Main.includedFiles[f] = fileLastModified(f)
+			}
+		}
+	}
+	return err
+}
+
+// loadRemote loads application configuration from a TOML-formatted remote config source,
+// verifying its signature when a public key is provided
+func (c *Config) loadRemote(flags *Flags) error {
+	var pk ed25519.PublicKey
+	var err error
+	if flags.ConfigPublicKey != "" {
+		pk, err = remote.LoadPublicKey(flags.ConfigPublicKey)
+		if err != nil {
+			c.setDefaults(&toml.MetaData{})
+			return err
+		}
+	}
+	src, err := remote.New(flags.ConfigPath, pk)
+	if err != nil {
+		c.setDefaults(&toml.MetaData{})
+		return err
+	}
+	b, v, err := src.Fetch("")
+	if err != nil {
+		c.setDefaults(&toml.MetaData{})
+		return err
+	}
+	err = c.loadTOMLConfig(string(b), flags)
+	if err == nil {
+		c.Main.configSource = src
+		c.Main.configVersion = v
 	}
 	return err
 }
@@ -244,7 +492,11 @@ func (c *Config) CheckFileLastModified() time.Time {
 	if c.Main == nil || c.Main.configFilePath == "" {
 		return time.Time{}
 	}
-	file, err := os.Stat(c.Main.configFilePath)
+	return fileLastModified(c.Main.configFilePath)
+}
+
+func fileLastModified(path string) time.Time {
+	file, err := os.Stat(path)
 	if err != nil {
 		return time.Time{}
 	}
@@ -261,12 +513,71 @@ func (c *Config) setDefaults(metadata *toml.MetaData) error {
 		return err
 	}
 
+	if err = c.processFrontendConfig(); err != nil {
+		return err
+	}
+
+	if err = c.processAddressFamilies(); err != nil {
+		return err
+	}
+
+	if err = c.processMetricsConfig(); err != nil {
+		return err
+	}
+
+	if c.Memory == nil {
+		c.Memory = memory.NewOptions()
+	}
+	if err = c.Memory.Validate(); err != nil {
+		return err
+	}
+	c.Memory.SetDurations()
+
+	if c.Cluster == nil {
+		c.Cluster = cluster.NewOptions()
+	}
+	if err = c.Cluster.Validate(); err != nil {
+		return err
+	}
+	c.Cluster.SetDurations()
+
+	if c.Invalidation == nil {
+		c.Invalidation = invalidation.NewOptions()
+	}
+	if err = c.Invalidation.Validate(); err != nil {
+		return err
+	}
+	c.Invalidation.SetDurations()
+
+	if c.ACME == nil {
+		c.ACME = acme.NewOptions()
+	}
+	if err = c.ACME.Validate(); err != nil {
+		return err
+	}
+	c.ACME.SetDurations()
+
 	if c.RequestRewriters != nil {
 		if c.CompiledRewriters, err = rewriter.ProcessConfigs(c.RequestRewriters); err != nil {
 			return err
 		}
 	}
 
+	if err = sh.ProcessSecurityHeadersOptions(c.SecurityHeaders, metadata); err != nil {
+		return err
+	}
+
+	if err = azo.ProcessAuthorizerOptions(c.Authorizers, metadata); err != nil {
+		return err
+	}
+
+	if c.TLSPolicies == nil {
+		c.TLSPolicies = make(map[string]*tp.Options)
+	}
+	if err = tp.ProcessTLSPolicyOptions(c.TLSPolicies); err != nil {
+		return err
+	}
+
 	if err = c.processOriginConfigs(metadata); err != nil {
 		return err
 	}
@@ -277,6 +588,13 @@ func (c *Config) setDefaults(metadata *toml.MetaData) error {
 		return err
 	}
 
+	if err = ff.ProcessFeatureFlagOptions(c.FeatureFlags, metadata, "feature_flags"); err != nil {
+		return err
+	}
+	if err = c.ValidateFeatureFlags(c.FeatureFlags); err != nil {
+		return err
+	}
+
 	if err = c.validateConfigMappings(); err != nil {
 		return err
 	}
@@ -288,6 +606,18 @@ func (c *Config) setDefaults(metadata *toml.MetaData) error {
 	return nil
 }
 
+// ValidateFeatureFlags returns an error if a feature flag applies to an origin that is not configured
+func (c *Config) ValidateFeatureFlags(mo map[string]*ff.Options) error {
+	for k, v := range mo {
+		for _, n := range v.Origins {
+			if _, ok := c.Origins[n]; !ok {
+				return fmt.Errorf("invalid feature flag %s: origin %s is not configured", k, n)
+			}
+		}
+	}
+	return nil
+}
+
 // ErrInvalidPprofServerName returns an error for invalid pprof server name
 var ErrInvalidPprofServerName = errors.New("invalid pprof server name")
 
@@ -302,6 +632,113 @@ func (c *Config) processPprofConfig() error {
 	return ErrInvalidPprofServerName
 }
 
+func (c *Config) processMetricsConfig() error {
+	mc := c.Metrics
+	if mc == nil {
+		return nil
+	}
+	if (mc.TLSCertificatePath == "") != (mc.TLSPrivateKeyPath == "") ||
+		(!mc.ServeTLS() && (len(mc.TLSClientCAPaths) > 0 || mc.TLSPolicyName != "")) {
+		return ErrInvalidMetricsTLS
+	}
+	authenticated := mc.AuthorizerName != "" || len(mc.TLSClientCAPaths) > 0
+	if mc.ServePurge && !authenticated {
+		return ErrUnauthenticatedPurge
+	}
+	if mc.ServeAdmin && !authenticated {
+		return ErrUnauthenticatedAdmin
+	}
+	if mc.FrontendAdminDisabled && !mc.ServeAdmin {
+		c.LoaderWarnings = append(c.LoaderWarnings, "metrics frontend_admin_disabled is set "+
+			"without serve_admin, so the ping, health and heatmap endpoints are not served")
+	}
+	return nil
+}
+
+// ErrInvalidAddressFamily returns an error for an invalid listener address family
+var ErrInvalidAddressFamily = errors.New("invalid address family")
+
+func (c *Config) processAddressFamilies() error {
+	families := []*string{&c.Frontend.AddressFamily}
+	if c.Metrics != nil {
+		families = append(families, &c.Metrics.AddressFamily)
+	}
+	if c.ReloadConfig != nil {
+		families = append(families, &c.ReloadConfig.AddressFamily)
+	}
+	for _, f := range families {
+		switch *f {
+		case "tcp4", "tcp6", "dual":
+		case "":
+			*f = d.DefaultAddressFamily
+		default:
+			return ErrInvalidAddressFamily
+		}
+	}
+	return nil
+}
+
+// ErrInvalidForwardedHeadersPolicy returns an error for invalid forwarded headers policy
+var ErrInvalidForwardedHeadersPolicy = errors.New("invalid forwarded headers policy")
+
+// ErrInvalidIdentityMode returns an error for an invalid identity mode
+var ErrInvalidIdentityMode = errors.New("invalid identity mode")
+
+// ErrNoIdentityTrustSources returns an error when the trusted_headers identity mode
+// is configured without any trusted proxies or peers
+var ErrNoIdentityTrustSources = errors.New(
+	"identity mode trusted_headers requires identity_trusted_proxies or identity_trusted_peers")
+
+// ErrInvalidSessionTicketKeyRotation returns an error for a negative session ticket key rotation interval
+var ErrInvalidSessionTicketKeyRotation = errors.New("invalid tls session ticket key rotation interval")
+
+func (c *Config) processFrontendConfig() error {
+	if c.Frontend.ForwardedHeadersPolicy == "" {
+		c.Frontend.ForwardedHeadersPolicy = d.DefaultForwardedHeadersPolicy
+	}
+	if !headers.IsValidForwardedPolicy(c.Frontend.ForwardedHeadersPolicy) {
+		return ErrInvalidForwardedHeadersPolicy
+	}
+	tp, err := headers.ParseTrustedProxies(c.Frontend.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	c.Frontend.TrustedProxyNets = tp
+	if c.Frontend.TLSSessionTicketKeyRotationSecs < 0 {
+		return ErrInvalidSessionTicketKeyRotation
+	}
+	if c.Frontend.TLSSessionTicketCacheName != "" && c.Frontend.TLSSessionTicketKeyRotationSecs == 0 {
+		c.Frontend.TLSSessionTicketKeyRotationSecs = d.DefaultTLSSessionTicketKeyRotationSecs
+	}
+	return c.processIdentityConfig()
+}
+
+func (c *Config) processIdentityConfig() error {
+	if !identity.IsValidMode(c.Frontend.IdentityMode) {
+		return ErrInvalidIdentityMode
+	}
+	c.Frontend.IdentityResolver = nil
+	if c.Frontend.IdentityMode == "" {
+		return nil
+	}
+	if len(c.Frontend.IdentityTrustedProxies) == 0 && len(c.Frontend.IdentityTrustedPeers) == 0 {
+		return ErrNoIdentityTrustSources
+	}
+	if c.Frontend.IdentityUserHeader == "" {
+		c.Frontend.IdentityUserHeader = d.DefaultIdentityUserHeader
+	}
+	if c.Frontend.IdentityGroupsHeader == "" {
+		c.Frontend.IdentityGroupsHeader = d.DefaultIdentityGroupsHeader
+	}
+	tp, err := headers.ParseTrustedProxies(c.Frontend.IdentityTrustedProxies)
+	if err != nil {
+		return err
+	}
+	c.Frontend.IdentityResolver = identity.NewResolver(c.Frontend.IdentityUserHeader,
+		c.Frontend.IdentityGroupsHeader, tp, c.Frontend.IdentityTrustedPeers)
+	return nil
+}
+
 func (c *Config) validateTLSConfigs() error {
 	for _, oc := range c.Origins {
 		if oc.TLS != nil {
@@ -314,18 +751,92 @@ func (c *Config) validateTLSConfigs() error {
 			}
 		}
 	}
+	// the TLS listener serves the ACME certificates once they are obtained
+	if c.ACME.Enabled() {
+		c.Frontend.ServeTLS = true
+	}
 	return nil
 }
 
 var pathMembers = []string{"path", "match_type", "handler", "methods", "cache_key_params",
 	"cache_key_headers", "default_ttl_secs", "request_headers", "response_headers",
 	"response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
-	"req_rewriter_name",
+	"req_rewriter_name", "downstream_caching_headers", "cache_tags", "max_object_size_bytes",
+	"negative_cache_name",
 }
 
 func (c *Config) validateConfigMappings() error {
+
+	if c.Frontend.SecurityHeadersName != "" {
+		if _, ok := c.SecurityHeaders[c.Frontend.SecurityHeadersName]; !ok {
+			return fmt.Errorf("invalid security headers name [%s] provided in frontend config",
+				c.Frontend.SecurityHeadersName)
+		}
+	}
+
+	if c.Frontend.TLSPolicyName != "" {
+		p, ok := c.TLSPolicies[c.Frontend.TLSPolicyName]
+		if !ok {
+			return fmt.Errorf("invalid tls policy name [%s] provided in frontend config",
+				c.Frontend.TLSPolicyName)
+		}
+		c.Frontend.TLSPolicy = p
+	}
+
+	if c.Metrics != nil && c.Metrics.TLSPolicyName != "" {
+		p, ok := c.TLSPolicies[c.Metrics.TLSPolicyName]
+		if !ok {
+			return fmt.Errorf("invalid tls policy name [%s] provided in metrics config",
+				c.Metrics.TLSPolicyName)
+		}
+		c.Metrics.TLSPolicy = p
+	}
+
+	if c.Metrics != nil && c.Metrics.AuthorizerName != "" {
+		p, ok := c.Authorizers[c.Metrics.AuthorizerName]
+		if !ok {
+			return fmt.Errorf("invalid authorizer name [%s] provided in metrics config",
+				c.Metrics.AuthorizerName)
+		}
+		c.Metrics.Authorizer = p
+	}
+
+	if c.Frontend.TLSSessionTicketCacheName != "" {
+		if _, ok := c.Caches[c.Frontend.TLSSessionTicketCacheName]; !ok {
+			return fmt.Errorf("invalid tls session ticket cache name [%s] provided in frontend config",
+				c.Frontend.TLSSessionTicketCacheName)
+		}
+	}
+
 	for k, oc := range c.Origins {
 
+		if oc.TLS != nil && oc.TLS.PolicyName != "" {
+			p, ok := c.TLSPolicies[oc.TLS.PolicyName]
+			if !ok {
+				return fmt.Errorf("invalid tls policy name [%s] provided in origin config [%s]",
+					oc.TLS.PolicyName, k)
+			}
+			oc.TLS.Policy = p
+		}
+
+		if oc.SecurityHeadersName != "" {
+			p, ok := c.SecurityHeaders[oc.SecurityHeadersName]
+			if !ok {
+				return fmt.Errorf("invalid security headers name [%s] provided in origin config [%s]",
+					oc.SecurityHeadersName, k)
+			}
+			oc.SecurityHeaders = p
+		}
+
+		if oc.AuthorizerName != "" {
+			p, ok := c.Authorizers[oc.AuthorizerName]
+			if !ok {
+				return fmt.Errorf("invalid authorizer name [%s] provided in origin config [%s]",
+					oc.AuthorizerName, k)
+			}
+			oc.Authorizer = p
+		}
+
 		if err := origins.ValidateOriginName(k); err != nil {
 			return err
 		}
@@ -378,6 +889,14 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.RuleName = v.RuleName
 		}
 
+		if metadata.IsDefined("origins", k, "upstream_type") {
+			oc.UpstreamType = v.UpstreamType
+		}
+
+		if metadata.IsDefined("origins", k, "max_hops") {
+			oc.MaxHops = v.MaxHops
+		}
+
 		if metadata.IsDefined("origins", k, "path_routing_disabled") {
 			oc.PathRoutingDisabled = v.PathRoutingDisabled
 		}
@@ -399,6 +918,24 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.ForwardedHeaders = v.ForwardedHeaders
 		}
 
+		if metadata.IsDefined("origins", k, "upstream_header_allowlist") {
+			oc.UpstreamHeaderAllowList = v.UpstreamHeaderAllowList
+		}
+
+		if metadata.IsDefined("origins", k, "upstream_header_denylist") {
+			oc.UpstreamHeaderDenyList = v.UpstreamHeaderDenyList
+		} else if ot := strings.ToLower(oc.OriginType); ot != "rpc" && ot != "reverseproxycache" {
+			oc.UpstreamHeaderDenyList = d.DefaultUpstreamHeaderDenyList()
+		}
+
+		if metadata.IsDefined("origins", k, "security_headers_name") {
+			oc.SecurityHeadersName = v.SecurityHeadersName
+		}
+
+		if metadata.IsDefined("origins", k, "authorizer_name") {
+			oc.AuthorizerName = v.AuthorizerName
+		}
+
 		if metadata.IsDefined("origins", k, "require_tls") {
 			oc.RequireTLS = v.RequireTLS
 		}
@@ -412,6 +949,10 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.CacheKeyPrefix = v.CacheKeyPrefix
 		}
 
+		if metadata.IsDefined("origins", k, "cache_generation") {
+			oc.CacheGeneration = v.CacheGeneration
+		}
+
 		if metadata.IsDefined("origins", k, "origin_url") {
 			oc.OriginURL = v.OriginURL
 		}
@@ -463,49 +1004,39 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.BackfillToleranceSecs = v.BackfillToleranceSecs
 		}
 
+		if metadata.IsDefined("origins", k, "gap_fill") {
+			oc.GapFill = strings.ToLower(v.GapFill)
+			if _, ok := timeseries.GapFillModes[oc.GapFill]; !ok {
+				return fmt.Errorf("invalid gap_fill: %s in origin config %s", v.GapFill, k)
+			}
+		}
+
+		if metadata.IsDefined("origins", k, "gap_fill_max_secs") {
+			oc.GapFillMaxSecs = v.GapFillMaxSecs
+		}
+
+		if metadata.IsDefined("origins", k, "revision_header") {
+			oc.RevisionHeader = v.RevisionHeader
+		}
+
+		if metadata.IsDefined("origins", k, "explain_header_enabled") {
+			oc.ExplainHeaderEnabled = v.ExplainHeaderEnabled
+		}
+
+		if metadata.IsDefined("origins", k, "request_collapsing_enabled") {
+			oc.RequestCollapsingEnabled = v.RequestCollapsingEnabled
+		}
+
+		if metadata.IsDefined("origins", k, "clock_skew_tolerance_ms") {
+			if v.ClockSkewToleranceMS < 0 {
+				return fmt.Errorf("clock_skew_tolerance_ms can't be negative in origin config %s", k)
+			}
+			oc.ClockSkewToleranceMS = v.ClockSkewToleranceMS
+		}
+
 		if metadata.IsDefined("origins", k, "paths") {
-			var j = 0
-			for l, p := range v.Paths {
-				if metadata.IsDefined("origins", k, "paths", l, "req_rewriter_name") &&
-					p.ReqRewriterName != "" {
-					ri, ok := c.CompiledRewriters[p.ReqRewriterName]
-					if !ok {
-						return fmt.Errorf("invalid rewriter name %s in path %s of origin config %s",
-							p.ReqRewriterName, l, k)
-					}
-					p.ReqRewriter = ri
-				}
-				if len(p.Methods) == 0 {
-					p.Methods = []string{http.MethodGet, http.MethodHead}
-				}
-				p.Custom = make([]string, 0)
-				for _, pm := range pathMembers {
-					if metadata.IsDefined("origins", k, "paths", l, pm) {
-						p.Custom = append(p.Custom, pm)
-					}
-				}
-				if metadata.IsDefined("origins", k, "paths", l, "response_body") {
-					p.ResponseBodyBytes = []byte(p.ResponseBody)
-					p.HasCustomResponseBody = true
-				}
-				if metadata.IsDefined("origins", k, "paths", l, "collapsed_forwarding") {
-					if _, ok := forwarding.CollapsedForwardingTypeNames[p.CollapsedForwardingName]; !ok {
-						return fmt.Errorf("invalid collapsed_forwarding name: %s", p.CollapsedForwardingName)
-					}
-					p.CollapsedForwardingType =
-						forwarding.GetCollapsedForwardingType(p.CollapsedForwardingName)
-				} else {
-					p.CollapsedForwardingType = forwarding.CFTypeBasic
-				}
-				if mt, ok := matching.Names[strings.ToLower(p.MatchTypeName)]; ok {
-					p.MatchType = mt
-					p.MatchTypeName = p.MatchType.String()
-				} else {
-					p.MatchType = matching.PathMatchTypeExact
-					p.MatchTypeName = p.MatchType.String()
-				}
-				oc.Paths[p.Path+"-"+strings.Join(p.Methods, "-")] = p
-				j++
+			if err := c.processPathConfigs(k, v, oc, metadata); err != nil {
+				return err
 			}
 		}
 
@@ -549,96 +1080,773 @@ func (c *Config) processOriginConfigs(metadata *toml.MetaData) error {
 			oc.DearticulateUpstreamRanges = v.DearticulateUpstreamRanges
 		}
 
+		if metadata.IsDefined("origins", k, "panic_recovery_disabled") {
+			oc.PanicRecoveryDisabled = v.PanicRecoveryDisabled
+		}
+
+		if metadata.IsDefined("origins", k, "quarantine_panic_cache_keys") {
+			oc.QuarantinePanicCacheKeys = v.QuarantinePanicCacheKeys
+		}
+
+		if metadata.IsDefined("origins", k, "fast_json_decode") {
+			oc.FastJSONDecode = v.FastJSONDecode
+		}
+
+		if metadata.IsDefined("origins", k, "fast_json_min_bytes") {
+			oc.FastJSONMinBytes = v.FastJSONMinBytes
+		}
+
 		if metadata.IsDefined("origins", k, "tls") {
 			oc.TLS = &to.Options{
 				InsecureSkipVerify:        v.TLS.InsecureSkipVerify,
 				CertificateAuthorityPaths: v.TLS.CertificateAuthorityPaths,
+				CABundlePath:              v.TLS.CABundlePath,
+				SPKIPins:                  v.TLS.SPKIPins,
+				ServerName:                v.TLS.ServerName,
 				PrivateKeyPath:            v.TLS.PrivateKeyPath,
 				FullChainCertPath:         v.TLS.FullChainCertPath,
 				ClientCertPath:            v.TLS.ClientCertPath,
 				ClientKeyPath:             v.TLS.ClientKeyPath,
+				PolicyName:                v.TLS.PolicyName,
 			}
 		}
 
-		c.Origins[k] = oc
-	}
-	return nil
-}
+		if metadata.IsDefined("origins", k, "dns", "cache_ttl_secs") {
+			oc.DNS.CacheTTLSecs = v.DNS.CacheTTLSecs
+		}
 
-func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
+		if metadata.IsDefined("origins", k, "dns", "negative_ttl_secs") {
+			oc.DNS.NegativeTTLSecs = v.DNS.NegativeTTLSecs
+		}
 
-	// setCachingDefaults assumes that processOriginConfigs was just ran
+		if metadata.IsDefined("origins", k, "dns", "re_resolve_on_error") {
+			oc.DNS.ReResolveOnError = v.DNS.ReResolveOnError
+		}
 
-	for k, v := range c.Caches {
+		if metadata.IsDefined("origins", k, "dns", "serve_stale") {
+			oc.DNS.ServeStale = v.DNS.ServeStale
+		}
 
-		if _, ok := c.activeCaches[k]; !ok {
-			// a configured cache was not used by any origin. don't even instantiate it
-			delete(c.Caches, k)
-			continue
+		if metadata.IsDefined("origins", k, "dns", "strategy") {
+			oc.DNS.Strategy = strings.ToLower(v.DNS.Strategy)
 		}
 
-		cc := cache.NewOptions()
-		cc.Name = k
+		if err := oc.DNS.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
 
-		if metadata.IsDefined("caches", k, "cache_type") {
-			cc.CacheType = strings.ToLower(v.CacheType)
-			if n, ok := types.Names[cc.CacheType]; ok {
-				cc.CacheTypeID = n
-			}
+		if metadata.IsDefined("origins", k, "error_budget", "error_rate_threshold") {
+			oc.ErrorBudget.ErrorRateThreshold = v.ErrorBudget.ErrorRateThreshold
 		}
 
-		if metadata.IsDefined("caches", k, "index", "reap_interval_secs") {
-			cc.Index.ReapIntervalSecs = v.Index.ReapIntervalSecs
+		if metadata.IsDefined("origins", k, "error_budget", "latency_threshold_ms") {
+			oc.ErrorBudget.LatencyThresholdMS = v.ErrorBudget.LatencyThresholdMS
 		}
 
-		if metadata.IsDefined("caches", k, "index", "flush_interval_secs") {
-			cc.Index.FlushIntervalSecs = v.Index.FlushIntervalSecs
+		if metadata.IsDefined("origins", k, "error_budget", "window_secs") {
+			oc.ErrorBudget.WindowSecs = v.ErrorBudget.WindowSecs
 		}
 
-		if metadata.IsDefined("caches", k, "index", "max_size_bytes") {
-			cc.Index.MaxSizeBytes = v.Index.MaxSizeBytes
+		if metadata.IsDefined("origins", k, "error_budget", "min_requests") {
+			oc.ErrorBudget.MinRequests = v.ErrorBudget.MinRequests
 		}
 
-		if metadata.IsDefined("caches", k, "index", "max_size_backoff_bytes") {
-			cc.Index.MaxSizeBackoffBytes = v.Index.MaxSizeBackoffBytes
+		if metadata.IsDefined("origins", k, "error_budget", "ttl_stretch_factor") {
+			oc.ErrorBudget.TTLStretchFactor = v.ErrorBudget.TTLStretchFactor
 		}
 
-		if cc.Index.MaxSizeBytes > 0 && cc.Index.MaxSizeBackoffBytes > cc.Index.MaxSizeBytes {
-			return errors.New("MaxSizeBackoffBytes can't be larger than MaxSizeBytes")
+		if metadata.IsDefined("origins", k, "error_budget", "max_stretched_ttl_secs") {
+			oc.ErrorBudget.MaxStretchedTTLSecs = v.ErrorBudget.MaxStretchedTTLSecs
 		}
 
-		if metadata.IsDefined("caches", k, "index", "max_size_objects") {
-			cc.Index.MaxSizeObjects = v.Index.MaxSizeObjects
+		if err := oc.ErrorBudget.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
 		}
+		oc.ErrorBudget.SetDurations()
 
-		if metadata.IsDefined("caches", k, "index", "max_size_backoff_objects") {
-			cc.Index.MaxSizeBackoffObjects = v.Index.MaxSizeBackoffObjects
+		if metadata.IsDefined("origins", k, "slo", "hit_ratio_target") {
+			oc.SLO.HitRatioTarget = v.SLO.HitRatioTarget
 		}
 
-		if cc.Index.MaxSizeObjects > 0 && cc.Index.MaxSizeBackoffObjects > cc.Index.MaxSizeObjects {
-			return errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
+		if metadata.IsDefined("origins", k, "slo", "latency_target_ms") {
+			oc.SLO.LatencyTargetMS = v.SLO.LatencyTargetMS
 		}
 
-		if cc.CacheTypeID == types.CacheTypeRedis {
+		if metadata.IsDefined("origins", k, "slo", "latency_objective") {
+			oc.SLO.LatencyObjective = v.SLO.LatencyObjective
+		}
 
-			var hasEndpoint, hasEndpoints bool
+		if metadata.IsDefined("origins", k, "slo", "period_secs") {
+			oc.SLO.PeriodSecs = v.SLO.PeriodSecs
+		}
 
-			ct := strings.ToLower(v.Redis.ClientType)
-			if metadata.IsDefined("caches", k, "redis", "client_type") {
-				cc.Redis.ClientType = ct
-			}
+		if metadata.IsDefined("origins", k, "slo", "burn_rate_windows_secs") {
+			oc.SLO.BurnRateWindowsSecs = v.SLO.BurnRateWindowsSecs
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "protocol") {
-				cc.Redis.Protocol = v.Redis.Protocol
-			}
+		if err := oc.SLO.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		oc.SLO.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "endpoint") {
-				cc.Redis.Endpoint = v.Redis.Endpoint
-				hasEndpoint = true
-			}
+		if metadata.IsDefined("origins", k, "heatmap", "max_samples") {
+			oc.Heatmap.MaxSamples = v.Heatmap.MaxSamples
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "endpoints") {
-				cc.Redis.Endpoints = v.Redis.Endpoints
+		if metadata.IsDefined("origins", k, "heatmap", "window_secs") {
+			oc.Heatmap.WindowSecs = v.Heatmap.WindowSecs
+		}
+
+		if err := oc.Heatmap.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		oc.Heatmap.SetDurations()
+
+		if metadata.IsDefined("origins", k, "capture", "path") {
+			oc.Capture.Path = v.Capture.Path
+		}
+
+		if metadata.IsDefined("origins", k, "capture", "redact_headers") {
+			oc.Capture.RedactHeaders = v.Capture.RedactHeaders
+		}
+
+		if metadata.IsDefined("origins", k, "capture", "redact_params") {
+			oc.Capture.RedactParams = v.Capture.RedactParams
+		}
+
+		if metadata.IsDefined("origins", k, "compression", "enabled") {
+			oc.Compression.Enabled = v.Compression.Enabled
+		}
+
+		if metadata.IsDefined("origins", k, "compression", "level") {
+			oc.Compression.Level = v.Compression.Level
+		}
+
+		if metadata.IsDefined("origins", k, "compression", "min_size_bytes") {
+			oc.Compression.MinSizeBytes = v.Compression.MinSizeBytes
+		}
+
+		if metadata.IsDefined("origins", k, "compression", "parallel_min_size_bytes") {
+			oc.Compression.ParallelMinSizeBytes = v.Compression.ParallelMinSizeBytes
+		}
+
+		if err := oc.Compression.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+
+		if metadata.IsDefined("origins", k, "retention", "window_days") {
+			oc.Retention.WindowDays = v.Retention.WindowDays
+		}
+
+		if metadata.IsDefined("origins", k, "retention", "trim_interval_secs") {
+			oc.Retention.TrimIntervalSecs = v.Retention.TrimIntervalSecs
+		}
+
+		if err := oc.Retention.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		oc.Retention.SetDurations()
+
+		if metadata.IsDefined("origins", k, "cache_quota", "max_size_bytes") {
+			oc.CacheQuota.MaxSizeBytes = v.CacheQuota.MaxSizeBytes
+		}
+
+		if metadata.IsDefined("origins", k, "cache_quota", "max_size_objects") {
+			oc.CacheQuota.MaxSizeObjects = v.CacheQuota.MaxSizeObjects
+		}
+
+		if err := oc.CacheQuota.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+
+		if metadata.IsDefined("origins", k, "replication", "peers") {
+			oc.Replication.Peers = v.Replication.Peers
+		}
+
+		if metadata.IsDefined("origins", k, "replication", "shared_secret") {
+			oc.Replication.SharedSecret = v.Replication.SharedSecret
+		}
+
+		if metadata.IsDefined("origins", k, "replication", "interval_ms") {
+			oc.Replication.IntervalMS = v.Replication.IntervalMS
+		}
+
+		if metadata.IsDefined("origins", k, "replication", "timeout_ms") {
+			oc.Replication.TimeoutMS = v.Replication.TimeoutMS
+		}
+
+		if metadata.IsDefined("origins", k, "replication", "max_pending") {
+			oc.Replication.MaxPending = v.Replication.MaxPending
+		}
+
+		if err := oc.Replication.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		oc.Replication.SetDurations()
+
+		if metadata.IsDefined("origins", k, "ingest", "source") {
+			oc.Ingest.Source = v.Ingest.Source
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "brokers") {
+			oc.Ingest.Brokers = v.Ingest.Brokers
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "topic") {
+			oc.Ingest.Topic = v.Ingest.Topic
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "client_id") {
+			oc.Ingest.ClientID = v.Ingest.ClientID
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "username") {
+			oc.Ingest.Username = v.Ingest.Username
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "password") {
+			oc.Ingest.Password = v.Ingest.Password
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "qos") {
+			oc.Ingest.QoS = v.Ingest.QoS
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "start_offset") {
+			oc.Ingest.StartOffset = v.Ingest.StartOffset
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "reconnect_interval_ms") {
+			oc.Ingest.ReconnectIntervalMS = v.Ingest.ReconnectIntervalMS
+		}
+
+		if metadata.IsDefined("origins", k, "ingest", "max_message_bytes") {
+			oc.Ingest.MaxMessageBytes = v.Ingest.MaxMessageBytes
+		}
+
+		if err := oc.Ingest.Validate(); err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		oc.Ingest.SetDurations()
+
+		if v.Prometheus != nil {
+			if metadata.IsDefined("origins", k, "prometheus", "lookback_delta_secs") {
+				oc.Prometheus.LookbackDeltaSecs = v.Prometheus.LookbackDeltaSecs
+			}
+			if metadata.IsDefined("origins", k, "prometheus", "replica_labels") {
+				oc.Prometheus.ReplicaLabels = v.Prometheus.ReplicaLabels
+			}
+			for l, dq := range v.Prometheus.DerivedQueries {
+				dqo := prometheus.NewDerivedQueryOptions()
+				dqo.Name = l
+				if metadata.IsDefined("origins", k, "prometheus", "derived_queries", l, "query") {
+					dqo.Query = dq.Query
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "derived_queries", l, "step_secs") {
+					dqo.StepSecs = dq.StepSecs
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "derived_queries", l, "range_secs") {
+					dqo.RangeSecs = dq.RangeSecs
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "derived_queries", l, "interval_secs") {
+					dqo.IntervalSecs = dq.IntervalSecs
+				}
+				if err := dqo.Validate(); err != nil {
+					return fmt.Errorf("%s in origin config %s", err.Error(), k)
+				}
+				oc.Prometheus.DerivedQueries[l] = dqo
+			}
+			for l, qr := range v.Prometheus.QueryRewrites {
+				qro := &prometheus.QueryRewriteOptions{}
+				if metadata.IsDefined("origins", k, "prometheus", "query_rewrites", l, "match") {
+					qro.Match = qr.Match
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "query_rewrites", l, "replacement") {
+					qro.Replacement = qr.Replacement
+				}
+				oc.Prometheus.QueryRewrites[l] = qro
+			}
+			if metadata.IsDefined("origins", k, "prometheus", "remote_read_cache_enabled") {
+				oc.Prometheus.RemoteReadCacheEnabled = v.Prometheus.RemoteReadCacheEnabled
+			}
+			if v.Prometheus.RemoteWrite != nil {
+				rw := oc.Prometheus.RemoteWrite
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "enabled") {
+					rw.Enabled = v.Prometheus.RemoteWrite.Enabled
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "max_buffered_requests") {
+					rw.MaxBufferedRequests = v.Prometheus.RemoteWrite.MaxBufferedRequests
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "max_batch_requests") {
+					rw.MaxBatchRequests = v.Prometheus.RemoteWrite.MaxBatchRequests
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "flush_interval_ms") {
+					rw.FlushIntervalMS = v.Prometheus.RemoteWrite.FlushIntervalMS
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "min_backoff_ms") {
+					rw.MinBackoffMS = v.Prometheus.RemoteWrite.MinBackoffMS
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "max_backoff_ms") {
+					rw.MaxBackoffMS = v.Prometheus.RemoteWrite.MaxBackoffMS
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "wal") {
+					rw.WAL = v.Prometheus.RemoteWrite.WAL
+				}
+				if metadata.IsDefined("origins", k, "prometheus", "remote_write", "wal_ttl_secs") {
+					rw.WALTTLSecs = v.Prometheus.RemoteWrite.WALTTLSecs
+				}
+				for l, rr := range v.Prometheus.RemoteWrite.RelabelRules {
+					rro := prometheus.NewRelabelRuleOptions()
+					if metadata.IsDefined("origins", k, "prometheus", "remote_write", "relabel_rules", l, "action") {
+						rro.Action = rr.Action
+					}
+					if metadata.IsDefined("origins", k, "prometheus", "remote_write", "relabel_rules", l, "source_labels") {
+						rro.SourceLabels = rr.SourceLabels
+					}
+					if metadata.IsDefined("origins", k, "prometheus", "remote_write", "relabel_rules", l, "separator") {
+						rro.Separator = rr.Separator
+					}
+					if metadata.IsDefined("origins", k, "prometheus", "remote_write", "relabel_rules", l, "regex") {
+						rro.Regex = rr.Regex
+					}
+					rw.RelabelRules[l] = rro
+				}
+				if err := rw.Validate(); err != nil {
+					return fmt.Errorf("%s in origin config %s", err.Error(), k)
+				}
+				if err := rw.Compile(); err != nil {
+					return fmt.Errorf("%s in origin config %s", err.Error(), k)
+				}
+			}
+			if err := oc.Prometheus.Compile(); err != nil {
+				return fmt.Errorf("%s in origin config %s", err.Error(), k)
+			}
+		}
+
+		if v.VictoriaMetrics != nil {
+			if metadata.IsDefined("origins", k, "victoriametrics", "latency_offset_secs") {
+				oc.VictoriaMetrics.LatencyOffsetSecs = v.VictoriaMetrics.LatencyOffsetSecs
+			}
+			if metadata.IsDefined("origins", k, "victoriametrics", "deny_partial_response") {
+				oc.VictoriaMetrics.DenyPartialResponse = v.VictoriaMetrics.DenyPartialResponse
+			}
+			if err := oc.VictoriaMetrics.Validate(); err != nil {
+				return fmt.Errorf("%s in origin config %s", err.Error(), k)
+			}
+		}
+
+		if v.Graphite != nil {
+			if metadata.IsDefined("origins", k, "graphite", "step_secs") {
+				oc.Graphite.StepSecs = v.Graphite.StepSecs
+			}
+			if err := oc.Graphite.Validate(); err != nil {
+				return fmt.Errorf("%s in origin config %s", err.Error(), k)
+			}
+		}
+
+		if v.OpenTSDB != nil {
+			if metadata.IsDefined("origins", k, "opentsdb", "step_secs") {
+				oc.OpenTSDB.StepSecs = v.OpenTSDB.StepSecs
+			}
+			if err := oc.OpenTSDB.Validate(); err != nil {
+				return fmt.Errorf("%s in origin config %s", err.Error(), k)
+			}
+		}
+
+		if v.PrometheusAPI != nil {
+			for l, pt := range v.PrometheusAPI.Templates {
+				pto := &pao.TemplateOptions{}
+				if metadata.IsDefined("origins", k, "prometheus_api", "templates", l, "match") {
+					pto.Match = pt.Match
+				}
+				if metadata.IsDefined("origins", k, "prometheus_api", "templates", l, "query") {
+					pto.Query = pt.Query
+				}
+				if metadata.IsDefined("origins", k, "prometheus_api", "templates", l, "params") {
+					pto.Params = pt.Params
+				}
+				oc.PrometheusAPI.Templates[l] = pto
+			}
+			if err := oc.PrometheusAPI.Compile(); err != nil {
+				return fmt.Errorf("%s in origin config %s", err.Error(), k)
+			}
+			if oc.PrometheusAPI.Enabled() && oc.OriginType != "influxdb" && oc.OriginType != "clickhouse" {
+				return fmt.Errorf("prometheus_api requires an influxdb or clickhouse origin in origin config %s", k)
+			}
+		}
+
+		c.Origins[k] = oc
+	}
+	return nil
+}
+
+// processPathConfigs overlays the paths of the provided origin config, as decoded from TOML,
+// onto the paths of the origin options
+func (c *Config) processPathConfigs(k string, v, oc *origins.Options,
+	metadata *toml.MetaData) error {
+	for l, p := range v.Paths {
+		if metadata.IsDefined("origins", k, "paths", l, "req_rewriter_name") &&
+			p.ReqRewriterName != "" {
+			ri, ok := c.CompiledRewriters[p.ReqRewriterName]
+			if !ok {
+				return fmt.Errorf("invalid rewriter name %s in path %s of origin config %s",
+					p.ReqRewriterName, l, k)
+			}
+			p.ReqRewriter = ri
+		}
+		if len(p.Methods) == 0 {
+			p.Methods = []string{http.MethodGet, http.MethodHead}
+		}
+		p.Custom = make([]string, 0)
+		for _, pm := range pathMembers {
+			if metadata.IsDefined("origins", k, "paths", l, pm) {
+				p.Custom = append(p.Custom, pm)
+			}
+		}
+		if p.MaxObjectSizeBytes < 0 {
+			return fmt.Errorf("invalid max_object_size_bytes in path %s of origin config %s: can't be negative",
+				l, k)
+		}
+		if metadata.IsDefined("origins", k, "paths", l, "response_body") {
+			p.ResponseBodyBytes = []byte(p.ResponseBody)
+			p.HasCustomResponseBody = true
+		}
+		if metadata.IsDefined("origins", k, "paths", l, "collapsed_forwarding") {
+			if _, ok := forwarding.CollapsedForwardingTypeNames[p.CollapsedForwardingName]; !ok {
+				return fmt.Errorf("invalid collapsed_forwarding name: %s", p.CollapsedForwardingName)
+			}
+			p.CollapsedForwardingType =
+				forwarding.GetCollapsedForwardingType(p.CollapsedForwardingName)
+		} else {
+			p.CollapsedForwardingType = forwarding.CFTypeBasic
+		}
+		if mt, ok := matching.Names[strings.ToLower(p.MatchTypeName)]; ok {
+			p.MatchType = mt
+			p.MatchTypeName = p.MatchType.String()
+		} else {
+			p.MatchType = matching.PathMatchTypeExact
+			p.MatchTypeName = p.MatchType.String()
+		}
+		oc.Paths[p.Path+"-"+strings.Join(p.Methods, "-")] = p
+	}
+	return nil
+}
+
+// processCacheQuotas maps the cache_quota of each origin to its namespace in the Index
+// of the cache the origin uses. It must run after the origins' cache key prefixes are set
+func (c *Config) processCacheQuotas() error {
+
+	for _, cc := range c.Caches {
+		cc.Index.Quotas = nil
+	}
+
+	for k, oc := range c.Origins {
+		if oc.OriginType == "rule" || !oc.CacheQuota.Enabled() {
+			continue
+		}
+		cc, ok := c.Caches[oc.CacheName]
+		if !ok {
+			return fmt.Errorf("invalid cache name [%s] provided in origin config [%s]", oc.CacheName, k)
+		}
+		switch cc.CacheTypeID {
+		case types.CacheTypeMemory, types.CacheTypeFilesystem, types.CacheTypeBbolt:
+		default:
+			return fmt.Errorf("cache_quota requires a memory, filesystem or bbolt cache in origin config %s", k)
+		}
+		if cc.Index.Quotas == nil {
+			cc.Index.Quotas = make(map[string]*qo.Options)
+		}
+		if _, ok := cc.Index.Quotas[oc.CacheKeyPrefix]; ok {
+			return fmt.Errorf("cache_quota namespace [%s] is used by another origin in origin config %s",
+				oc.CacheKeyPrefix, k)
+		}
+		cc.Index.Quotas[oc.CacheKeyPrefix] = oc.CacheQuota
+	}
+
+	return nil
+}
+
+// bucketPerOrigin returns true if the named cache is a bbolt cache that stores the objects
+// of each origin in its own bucket
+func (c *Config) bucketPerOrigin(cacheName string) bool {
+	cc, ok := c.Caches[cacheName]
+	return ok && cc.CacheTypeID == types.CacheTypeBbolt && cc.BBolt.BucketPerOrigin
+}
+
+// processOriginBuckets maps the cache key prefix of each origin that uses a bbolt cache with
+// bucket_per_origin to its bucket. It must run after the origins' cache key prefixes are set
+func (c *Config) processOriginBuckets() error {
+
+	for _, cc := range c.Caches {
+		cc.BBolt.OriginBuckets = nil
+	}
+
+	// visit the origins in order so a conflict is always reported against the same origin
+	names := make([]string, 0, len(c.Origins))
+	for k := range c.Origins {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		oc := c.Origins[k]
+		if oc.OriginType == "rule" || !c.bucketPerOrigin(oc.CacheName) {
+			continue
+		}
+		cc := c.Caches[oc.CacheName]
+		if cc.BBolt.OriginBuckets == nil {
+			cc.BBolt.OriginBuckets = make(map[string]string)
+		}
+		if _, ok := cc.BBolt.OriginBuckets[oc.CacheKeyPrefix]; ok {
+			return fmt.Errorf("bbolt bucket for cache key prefix [%s] is used by another origin in origin config %s",
+				oc.CacheKeyPrefix, k)
+		}
+		cc.BBolt.OriginBuckets[oc.CacheKeyPrefix] = cc.BBolt.Bucket + "." + k
+	}
+
+	return nil
+}
+
+func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
+
+	// setCachingDefaults assumes that processOriginConfigs was just ran
+
+	if c.Frontend.TLSSessionTicketCacheName != "" {
+		c.activeCaches[c.Frontend.TLSSessionTicketCacheName] = true
+	}
+
+	for k, v := range c.Caches {
+
+		if _, ok := c.activeCaches[k]; !ok {
+			// a configured cache was not used by any origin. don't even instantiate it
+			delete(c.Caches, k)
+			continue
+		}
+
+		cc := cache.NewOptions()
+		cc.Name = k
+
+		if metadata.IsDefined("caches", k, "cache_type") {
+			cc.CacheType = strings.ToLower(v.CacheType)
+			if n, ok := types.Names[cc.CacheType]; ok {
+				cc.CacheTypeID = n
+			}
+		}
+
+		if metadata.IsDefined("caches", k, "tiered", "l2_cache_type") {
+			cc.Tiered.L2CacheType = strings.ToLower(v.Tiered.L2CacheType)
+		}
+
+		if metadata.IsDefined("caches", k, "tiered", "l1_max_size_bytes") {
+			cc.Tiered.L1MaxSizeBytes = v.Tiered.L1MaxSizeBytes
+		}
+
+		if metadata.IsDefined("caches", k, "tiered", "l1_max_size_objects") {
+			cc.Tiered.L1MaxSizeObjects = v.Tiered.L1MaxSizeObjects
+		}
+
+		if metadata.IsDefined("caches", k, "tiered", "l1_promotion_ttl_secs") {
+			cc.Tiered.L1PromotionTTLSecs = v.Tiered.L1PromotionTTLSecs
+		}
+
+		if metadata.IsDefined("caches", k, "migration", "old_cache_type") {
+			cc.Migration.OldCacheType = strings.ToLower(v.Migration.OldCacheType)
+		}
+
+		if metadata.IsDefined("caches", k, "migration", "new_cache_type") {
+			cc.Migration.NewCacheType = strings.ToLower(v.Migration.NewCacheType)
+		}
+
+		if metadata.IsDefined("caches", k, "migration", "backfill_ttl_secs") {
+			cc.Migration.BackfillTTLSecs = v.Migration.BackfillTTLSecs
+		}
+
+		// the persistent tier of a tiered cache, and the old and new caches of a migration
+		// cache, are configured by the options of their own cache types
+		storageTypes := map[types.CacheType]bool{cc.CacheTypeID: true}
+		switch cc.CacheTypeID {
+		case types.CacheTypeTiered:
+			if err := cc.Tiered.Validate(); err != nil {
+				return err
+			}
+			storageTypes = map[types.CacheType]bool{types.Names[cc.Tiered.L2CacheType]: true}
+		case types.CacheTypeMigration:
+			if err := cc.Migration.Validate(); err != nil {
+				return fmt.Errorf("%s in cache %s", err.Error(), k)
+			}
+			storageTypes = map[types.CacheType]bool{
+				types.Names[cc.Migration.OldCacheType]: true,
+				types.Names[cc.Migration.NewCacheType]: true,
+			}
+		}
+		cc.Tiered.SetDurations()
+		cc.Migration.SetDurations()
+
+		if metadata.IsDefined("caches", k, "index", "reap_interval_secs") {
+			cc.Index.ReapIntervalSecs = v.Index.ReapIntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "index", "flush_interval_secs") {
+			cc.Index.FlushIntervalSecs = v.Index.FlushIntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "index", "forecast_interval_secs") {
+			cc.Index.ForecastIntervalSecs = v.Index.ForecastIntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "index", "max_size_bytes") {
+			cc.Index.MaxSizeBytes = v.Index.MaxSizeBytes
+		}
+
+		if metadata.IsDefined("caches", k, "index", "max_size_backoff_bytes") {
+			cc.Index.MaxSizeBackoffBytes = v.Index.MaxSizeBackoffBytes
+		}
+
+		if cc.Index.MaxSizeBytes > 0 && cc.Index.MaxSizeBackoffBytes > cc.Index.MaxSizeBytes {
+			return errors.New("MaxSizeBackoffBytes can't be larger than MaxSizeBytes")
+		}
+
+		if metadata.IsDefined("caches", k, "index", "max_size_objects") {
+			cc.Index.MaxSizeObjects = v.Index.MaxSizeObjects
+		}
+
+		if metadata.IsDefined("caches", k, "index", "max_size_backoff_objects") {
+			cc.Index.MaxSizeBackoffObjects = v.Index.MaxSizeBackoffObjects
+		}
+
+		if cc.Index.MaxSizeObjects > 0 && cc.Index.MaxSizeBackoffObjects > cc.Index.MaxSizeObjects {
+			return errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
+		}
+
+		if metadata.IsDefined("caches", k, "index", "eviction_policy") {
+			cc.Index.EvictionPolicy = strings.ToLower(v.Index.EvictionPolicy)
+			if err := cc.Index.ValidateEvictionPolicy(); err != nil {
+				return fmt.Errorf("%s in cache %s", err.Error(), k)
+			}
+		}
+
+		if metadata.IsDefined("caches", k, "index", "access_queue_size") {
+			cc.Index.AccessQueueSize = v.Index.AccessQueueSize
+		}
+
+		if metadata.IsDefined("caches", k, "index", "access_batch_size") {
+			cc.Index.AccessBatchSize = v.Index.AccessBatchSize
+		}
+
+		if metadata.IsDefined("caches", k, "index", "access_flush_interval_ms") {
+			cc.Index.AccessFlushIntervalMS = v.Index.AccessFlushIntervalMS
+		}
+
+		if err := cc.Index.ValidateAccessQueue(); err != nil {
+			return fmt.Errorf("%s in cache %s", err.Error(), k)
+		}
+
+		if metadata.IsDefined("caches", k, "max_ttl_secs") {
+			cc.MaxTTLSecs = v.MaxTTLSecs
+		}
+
+		if metadata.IsDefined("caches", k, "min_ttl_secs") {
+			cc.MinTTLSecs = v.MinTTLSecs
+		}
+
+		if cc.MaxTTLSecs < 0 || cc.MinTTLSecs < 0 {
+			return errors.New("MaxTTLSecs and MinTTLSecs can't be negative")
+		}
+
+		if cc.MaxTTLSecs > 0 && cc.MinTTLSecs > cc.MaxTTLSecs {
+			return errors.New("MinTTLSecs can't be larger than MaxTTLSecs")
+		}
+
+		if metadata.IsDefined("caches", k, "max_object_size_bytes") {
+			cc.MaxObjectSizeBytes = v.MaxObjectSizeBytes
+		}
+
+		if cc.MaxObjectSizeBytes < 0 {
+			return fmt.Errorf("invalid max_object_size_bytes in cache %s: can't be negative", k)
+		}
+
+		cc.MaxTTL = time.Duration(cc.MaxTTLSecs) * time.Second
+		cc.MinTTL = time.Duration(cc.MinTTLSecs) * time.Second
+
+		if metadata.IsDefined("caches", k, "compression_codec") {
+			cc.CompressionCodec = strings.ToLower(v.CompressionCodec)
+		}
+
+		if _, ok := compression.Names[cc.CompressionCodec]; cc.CompressionCodec != "" && !ok {
+			return fmt.Errorf("compression_codec must be one of 'snappy', 'gzip' or 'none': %s",
+				cc.CompressionCodec)
+		}
+
+		if metadata.IsDefined("caches", k, "encryption", "active_key_id") {
+			cc.Encryption.ActiveKeyID = v.Encryption.ActiveKeyID
+		}
+
+		if metadata.IsDefined("caches", k, "encryption", "keys") {
+			cc.Encryption.Keys = v.Encryption.Keys
+		}
+
+		if err := cc.Encryption.LoadKeys(); err != nil {
+			return fmt.Errorf("invalid encryption config in cache %s: %s", k, err.Error())
+		}
+
+		// values in the memory cache are never at rest
+		if cc.Encryption.Enabled() && storageTypes[types.CacheTypeMemory] {
+			return fmt.Errorf("encryption is not supported by the memory cache %s", k)
+		}
+
+		if metadata.IsDefined("caches", k, "warming", "queries") {
+			cc.Warming.Queries = v.Warming.Queries
+		}
+
+		if metadata.IsDefined("caches", k, "warming", "top_n") {
+			cc.Warming.TopN = v.Warming.TopN
+		}
+
+		if metadata.IsDefined("caches", k, "warming", "state_path") {
+			cc.Warming.StatePath = v.Warming.StatePath
+		}
+
+		if metadata.IsDefined("caches", k, "warming", "interval_secs") {
+			cc.Warming.IntervalSecs = v.Warming.IntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "warming", "concurrency") {
+			cc.Warming.Concurrency = v.Warming.Concurrency
+		}
+
+		if err := cc.Warming.Validate(); err != nil {
+			return fmt.Errorf("invalid warming config in cache %s: %s", k, err.Error())
+		}
+		cc.Warming.SetDurations()
+
+		// queries are replayed through their origin, so it must store them in this cache
+		for on := range cc.Warming.Queries {
+			if o, ok := c.Origins[on]; !ok || o.CacheName != k {
+				return fmt.Errorf("invalid warming config in cache %s: origin %s does not use the cache",
+					k, on)
+			}
+		}
+
+		if storageTypes[types.CacheTypeRedis] {
+
+			var hasEndpoint, hasEndpoints bool
+
+			ct := strings.ToLower(v.Redis.ClientType)
+			if metadata.IsDefined("caches", k, "redis", "client_type") {
+				cc.Redis.ClientType = ct
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "protocol") {
+				cc.Redis.Protocol = v.Redis.Protocol
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "endpoint") {
+				cc.Redis.Endpoint = v.Redis.Endpoint
+				hasEndpoint = true
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "endpoints") {
+				cc.Redis.Endpoints = v.Redis.Endpoints
 				hasEndpoints = true
 			}
 
@@ -655,79 +1863,477 @@ func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
 				}
 			}
 
-			if metadata.IsDefined("caches", k, "redis", "sentinel_master") {
-				cc.Redis.SentinelMaster = v.Redis.SentinelMaster
-			}
+			if metadata.IsDefined("caches", k, "redis", "sentinel_master") {
+				cc.Redis.SentinelMaster = v.Redis.SentinelMaster
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "password") {
+				cc.Redis.Password = v.Redis.Password
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "db") {
+				cc.Redis.DB = v.Redis.DB
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "max_retries") {
+				cc.Redis.MaxRetries = v.Redis.MaxRetries
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "min_retry_backoff_ms") {
+				cc.Redis.MinRetryBackoffMS = v.Redis.MinRetryBackoffMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "max_retry_backoff_ms") {
+				cc.Redis.MaxRetryBackoffMS = v.Redis.MaxRetryBackoffMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "dial_timeout_ms") {
+				cc.Redis.DialTimeoutMS = v.Redis.DialTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "read_timeout_ms") {
+				cc.Redis.ReadTimeoutMS = v.Redis.ReadTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "write_timeout_ms") {
+				cc.Redis.WriteTimeoutMS = v.Redis.WriteTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "pool_size") {
+				cc.Redis.PoolSize = v.Redis.PoolSize
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "min_idle_conns") {
+				cc.Redis.MinIdleConns = v.Redis.MinIdleConns
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "max_conn_age_ms") {
+				cc.Redis.MaxConnAgeMS = v.Redis.MaxConnAgeMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "pool_timeout_ms") {
+				cc.Redis.PoolTimeoutMS = v.Redis.PoolTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "idle_timeout_ms") {
+				cc.Redis.IdleTimeoutMS = v.Redis.IdleTimeoutMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "idle_check_frequency_ms") {
+				cc.Redis.IdleCheckFrequencyMS = v.Redis.IdleCheckFrequencyMS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "pipeline_batch_size") {
+				cc.Redis.PipelineBatchSize = v.Redis.PipelineBatchSize
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "client_cache_max_objects") {
+				cc.Redis.ClientCacheMaxObjects = v.Redis.ClientCacheMaxObjects
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "client_cache_ttl_ms") {
+				cc.Redis.ClientCacheTTLMS = v.Redis.ClientCacheTTLMS
+			}
+
+			if cc.Redis.PipelineBatchSize <= 0 {
+				return fmt.Errorf("invalid pipeline_batch_size in cache %s: must be greater than 0", k)
+			}
+
+			if cc.Redis.ClientCacheMaxObjects < 0 || cc.Redis.ClientCacheTTLMS <= 0 {
+				return fmt.Errorf("invalid client-side cache config in cache %s", k)
+			}
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "cache_path") {
+			cc.Filesystem.CachePath = v.Filesystem.CachePath
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "sync_mode") {
+			cc.Filesystem.SyncMode = strings.ToLower(v.Filesystem.SyncMode)
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "sync_interval_ms") {
+			cc.Filesystem.SyncIntervalMS = v.Filesystem.SyncIntervalMS
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "batch_max_bytes") {
+			cc.Filesystem.BatchMaxBytes = v.Filesystem.BatchMaxBytes
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "batch_interval_ms") {
+			cc.Filesystem.BatchIntervalMS = v.Filesystem.BatchIntervalMS
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "file_mode") {
+			cc.Filesystem.FileMode = v.Filesystem.FileMode
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "dir_mode") {
+			cc.Filesystem.DirMode = v.Filesystem.DirMode
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "reconcile_mode") {
+			cc.Filesystem.ReconcileMode = strings.ToLower(v.Filesystem.ReconcileMode)
+		}
+
+		if err := cc.Filesystem.Validate(); err != nil {
+			return err
+		}
+		cc.Filesystem.SetDurations()
+
+		if metadata.IsDefined("caches", k, "bbolt", "filename") {
+			cc.BBolt.Filename = v.BBolt.Filename
+		}
+
+		if metadata.IsDefined("caches", k, "bbolt", "bucket") {
+			cc.BBolt.Bucket = v.BBolt.Bucket
+		}
+
+		if metadata.IsDefined("caches", k, "bbolt", "bucket_per_origin") {
+			cc.BBolt.BucketPerOrigin = v.BBolt.BucketPerOrigin
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "endpoint") {
+			cc.S3.Endpoint = v.S3.Endpoint
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "bucket") {
+			cc.S3.Bucket = v.S3.Bucket
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "prefix") {
+			cc.S3.Prefix = v.S3.Prefix
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "region") {
+			cc.S3.Region = v.S3.Region
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "access_key_id") {
+			cc.S3.AccessKeyID = v.S3.AccessKeyID
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "secret_access_key") {
+			cc.S3.SecretAccessKey = v.S3.SecretAccessKey
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "session_token") {
+			cc.S3.SessionToken = v.S3.SessionToken
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "path_style") {
+			cc.S3.PathStyle = v.S3.PathStyle
+		}
+
+		if metadata.IsDefined("caches", k, "s3", "part_size_bytes") {
+			cc.S3.PartSizeBytes = v.S3.PartSizeBytes
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "password") {
-				cc.Redis.Password = v.Redis.Password
-			}
+		if metadata.IsDefined("caches", k, "s3", "timeout_ms") {
+			cc.S3.TimeoutMS = v.S3.TimeoutMS
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "db") {
-				cc.Redis.DB = v.Redis.DB
+		if storageTypes[types.CacheTypeS3] {
+			if err := cc.S3.Validate(); err != nil {
+				return err
 			}
+		}
+		cc.S3.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "max_retries") {
-				cc.Redis.MaxRetries = v.Redis.MaxRetries
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "endpoint") {
+			cc.DynamoDB.Endpoint = v.DynamoDB.Endpoint
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "min_retry_backoff_ms") {
-				cc.Redis.MinRetryBackoffMS = v.Redis.MinRetryBackoffMS
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "table") {
+			cc.DynamoDB.Table = v.DynamoDB.Table
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "max_retry_backoff_ms") {
-				cc.Redis.MaxRetryBackoffMS = v.Redis.MaxRetryBackoffMS
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "region") {
+			cc.DynamoDB.Region = v.DynamoDB.Region
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "dial_timeout_ms") {
-				cc.Redis.DialTimeoutMS = v.Redis.DialTimeoutMS
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "key_attribute") {
+			cc.DynamoDB.KeyAttribute = v.DynamoDB.KeyAttribute
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "read_timeout_ms") {
-				cc.Redis.ReadTimeoutMS = v.Redis.ReadTimeoutMS
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "ttl_attribute") {
+			cc.DynamoDB.TTLAttribute = v.DynamoDB.TTLAttribute
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "write_timeout_ms") {
-				cc.Redis.WriteTimeoutMS = v.Redis.WriteTimeoutMS
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "access_key_id") {
+			cc.DynamoDB.AccessKeyID = v.DynamoDB.AccessKeyID
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "pool_size") {
-				cc.Redis.PoolSize = v.Redis.PoolSize
-			}
+		if metadata.IsDefined("caches", k, "dynamodb", "secret_access_key") {
+			cc.DynamoDB.SecretAccessKey = v.DynamoDB.SecretAccessKey
+		}
 
-			if metadata.IsDefined("caches", k, "redis", "min_idle_conns") {
-				cc.Redis.MinIdleConns = v.Redis.MinIdleConns
+		if metadata.IsDefined("caches", k, "dynamodb", "session_token") {
+			cc.DynamoDB.SessionToken = v.DynamoDB.SessionToken
+		}
+
+		if metadata.IsDefined("caches", k, "dynamodb", "read_capacity_units") {
+			cc.DynamoDB.ReadCapacityUnits = v.DynamoDB.ReadCapacityUnits
+		}
+
+		if metadata.IsDefined("caches", k, "dynamodb", "write_capacity_units") {
+			cc.DynamoDB.WriteCapacityUnits = v.DynamoDB.WriteCapacityUnits
+		}
+
+		if metadata.IsDefined("caches", k, "dynamodb", "batch_size") {
+			cc.DynamoDB.BatchSize = v.DynamoDB.BatchSize
+		}
+
+		if metadata.IsDefined("caches", k, "dynamodb", "timeout_ms") {
+			cc.DynamoDB.TimeoutMS = v.DynamoDB.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeDynamoDB] {
+			if err := cc.DynamoDB.Validate(); err != nil {
+				return err
 			}
+		}
+		cc.DynamoDB.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "max_conn_age_ms") {
-				cc.Redis.MaxConnAgeMS = v.Redis.MaxConnAgeMS
+		if metadata.IsDefined("caches", k, "gcs", "endpoint") {
+			cc.GCS.Endpoint = v.GCS.Endpoint
+		}
+
+		if metadata.IsDefined("caches", k, "gcs", "bucket") {
+			cc.GCS.Bucket = v.GCS.Bucket
+		}
+
+		if metadata.IsDefined("caches", k, "gcs", "prefix") {
+			cc.GCS.Prefix = v.GCS.Prefix
+		}
+
+		if metadata.IsDefined("caches", k, "gcs", "credentials_file") {
+			cc.GCS.CredentialsFile = v.GCS.CredentialsFile
+		}
+
+		if metadata.IsDefined("caches", k, "gcs", "timeout_ms") {
+			cc.GCS.TimeoutMS = v.GCS.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeGCS] {
+			if err := cc.GCS.Validate(); err != nil {
+				return err
 			}
+		}
+		cc.GCS.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "pool_timeout_ms") {
-				cc.Redis.PoolTimeoutMS = v.Redis.PoolTimeoutMS
+		if metadata.IsDefined("caches", k, "azureblob", "endpoint") {
+			cc.AzureBlob.Endpoint = v.AzureBlob.Endpoint
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "account_name") {
+			cc.AzureBlob.AccountName = v.AzureBlob.AccountName
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "account_key") {
+			cc.AzureBlob.AccountKey = v.AzureBlob.AccountKey
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "sas_token") {
+			cc.AzureBlob.SASToken = v.AzureBlob.SASToken
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "container") {
+			cc.AzureBlob.Container = v.AzureBlob.Container
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "prefix") {
+			cc.AzureBlob.Prefix = v.AzureBlob.Prefix
+		}
+
+		if metadata.IsDefined("caches", k, "azureblob", "timeout_ms") {
+			cc.AzureBlob.TimeoutMS = v.AzureBlob.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeAzureBlob] {
+			if err := cc.AzureBlob.Validate(); err != nil {
+				return err
 			}
+		}
+		cc.AzureBlob.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "idle_timeout_ms") {
-				cc.Redis.IdleTimeoutMS = v.Redis.IdleTimeoutMS
+		if metadata.IsDefined("caches", k, "memcached", "servers") {
+			cc.Memcached.Servers = v.Memcached.Servers
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "virtual_nodes") {
+			cc.Memcached.VirtualNodes = v.Memcached.VirtualNodes
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "username") {
+			cc.Memcached.Username = v.Memcached.Username
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "password") {
+			cc.Memcached.Password = v.Memcached.Password
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "use_tls") {
+			cc.Memcached.UseTLS = v.Memcached.UseTLS
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "insecure_skip_verify") {
+			cc.Memcached.InsecureSkipVerify = v.Memcached.InsecureSkipVerify
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "certificate_authority_paths") {
+			cc.Memcached.CertificateAuthorityPaths = v.Memcached.CertificateAuthorityPaths
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "server_name") {
+			cc.Memcached.ServerName = v.Memcached.ServerName
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "client_cert_path") {
+			cc.Memcached.ClientCertPath = v.Memcached.ClientCertPath
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "client_key_path") {
+			cc.Memcached.ClientKeyPath = v.Memcached.ClientKeyPath
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "max_idle_conns") {
+			cc.Memcached.MaxIdleConns = v.Memcached.MaxIdleConns
+		}
+
+		if metadata.IsDefined("caches", k, "memcached", "timeout_ms") {
+			cc.Memcached.TimeoutMS = v.Memcached.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeMemcached] {
+			if err := cc.Memcached.Validate(); err != nil {
+				return err
 			}
+		}
+		cc.Memcached.SetDurations()
 
-			if metadata.IsDefined("caches", k, "redis", "idle_check_frequency_ms") {
-				cc.Redis.IdleCheckFrequencyMS = v.Redis.IdleCheckFrequencyMS
+		if metadata.IsDefined("caches", k, "etcd", "endpoints") {
+			cc.Etcd.Endpoints = v.Etcd.Endpoints
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "key_prefix") {
+			cc.Etcd.KeyPrefix = v.Etcd.KeyPrefix
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "lock_prefix") {
+			cc.Etcd.LockPrefix = v.Etcd.LockPrefix
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "username") {
+			cc.Etcd.Username = v.Etcd.Username
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "password") {
+			cc.Etcd.Password = v.Etcd.Password
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "max_value_bytes") {
+			cc.Etcd.MaxValueBytes = v.Etcd.MaxValueBytes
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "distributed_locks") {
+			cc.Etcd.DistributedLocks = v.Etcd.DistributedLocks
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "lock_ttl_secs") {
+			cc.Etcd.LockTTLSecs = v.Etcd.LockTTLSecs
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "lock_timeout_ms") {
+			cc.Etcd.LockTimeoutMS = v.Etcd.LockTimeoutMS
+		}
+
+		if metadata.IsDefined("caches", k, "etcd", "timeout_ms") {
+			cc.Etcd.TimeoutMS = v.Etcd.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeEtcd] {
+			if err := cc.Etcd.Validate(); err != nil {
+				return err
 			}
 		}
+		cc.Etcd.SetDurations()
 
-		if metadata.IsDefined("caches", k, "filesystem", "cache_path") {
-			cc.Filesystem.CachePath = v.Filesystem.CachePath
+		if metadata.IsDefined("caches", k, "cassandra", "hosts") {
+			cc.Cassandra.Hosts = v.Cassandra.Hosts
 		}
 
-		if metadata.IsDefined("caches", k, "bbolt", "filename") {
-			cc.BBolt.Filename = v.BBolt.Filename
+		if metadata.IsDefined("caches", k, "cassandra", "keyspace") {
+			cc.Cassandra.Keyspace = v.Cassandra.Keyspace
 		}
 
-		if metadata.IsDefined("caches", k, "bbolt", "bucket") {
-			cc.BBolt.Bucket = v.BBolt.Bucket
+		if metadata.IsDefined("caches", k, "cassandra", "table") {
+			cc.Cassandra.Table = v.Cassandra.Table
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "read_consistency") {
+			cc.Cassandra.ReadConsistency = strings.ToLower(v.Cassandra.ReadConsistency)
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "write_consistency") {
+			cc.Cassandra.WriteConsistency = strings.ToLower(v.Cassandra.WriteConsistency)
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "token_aware") {
+			cc.Cassandra.TokenAware = v.Cassandra.TokenAware
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "local_dc") {
+			cc.Cassandra.LocalDC = v.Cassandra.LocalDC
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "username") {
+			cc.Cassandra.Username = v.Cassandra.Username
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "password") {
+			cc.Cassandra.Password = v.Cassandra.Password
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "use_tls") {
+			cc.Cassandra.UseTLS = v.Cassandra.UseTLS
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "insecure_skip_verify") {
+			cc.Cassandra.InsecureSkipVerify = v.Cassandra.InsecureSkipVerify
 		}
 
+		if metadata.IsDefined("caches", k, "cassandra", "certificate_authority_paths") {
+			cc.Cassandra.CertificateAuthorityPaths = v.Cassandra.CertificateAuthorityPaths
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "server_name") {
+			cc.Cassandra.ServerName = v.Cassandra.ServerName
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "client_cert_path") {
+			cc.Cassandra.ClientCertPath = v.Cassandra.ClientCertPath
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "client_key_path") {
+			cc.Cassandra.ClientKeyPath = v.Cassandra.ClientKeyPath
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "max_idle_conns") {
+			cc.Cassandra.MaxIdleConns = v.Cassandra.MaxIdleConns
+		}
+
+		if metadata.IsDefined("caches", k, "cassandra", "timeout_ms") {
+			cc.Cassandra.TimeoutMS = v.Cassandra.TimeoutMS
+		}
+
+		if storageTypes[types.CacheTypeCassandra] {
+			if err := cc.Cassandra.Validate(); err != nil {
+				return err
+			}
+		}
+		cc.Cassandra.SetDurations()
+
 		if metadata.IsDefined("caches", k, "badger", "directory") {
 			cc.Badger.Directory = v.Badger.Directory
 		}
@@ -736,6 +2342,52 @@ func (c *Config) processCachingConfigs(metadata *toml.MetaData) error {
 			cc.Badger.ValueDirectory = v.Badger.ValueDirectory
 		}
 
+		if metadata.IsDefined("caches", k, "badger", "value_log_gc_interval_secs") {
+			cc.Badger.ValueLogGCIntervalSecs = v.Badger.ValueLogGCIntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "badger", "value_log_gc_ratio") {
+			cc.Badger.ValueLogGCRatio = v.Badger.ValueLogGCRatio
+		}
+
+		if metadata.IsDefined("caches", k, "badger", "value_log_file_size_bytes") {
+			cc.Badger.ValueLogFileSizeBytes = v.Badger.ValueLogFileSizeBytes
+		}
+
+		if storageTypes[types.CacheTypeBadgerDB] {
+			if err := cc.Badger.Validate(); err != nil {
+				return fmt.Errorf("%s in cache %s", err.Error(), k)
+			}
+		}
+		cc.Badger.SetDurations()
+
+		if metadata.IsDefined("caches", k, "logstore", "directory") {
+			cc.Logstore.Directory = v.Logstore.Directory
+		}
+
+		if metadata.IsDefined("caches", k, "logstore", "segment_size_mb") {
+			cc.Logstore.SegmentSizeMB = v.Logstore.SegmentSizeMB
+		}
+
+		if metadata.IsDefined("caches", k, "logstore", "compact_interval_secs") {
+			cc.Logstore.CompactIntervalSecs = v.Logstore.CompactIntervalSecs
+		}
+
+		if metadata.IsDefined("caches", k, "logstore", "compact_dead_ratio") {
+			cc.Logstore.CompactDeadRatio = v.Logstore.CompactDeadRatio
+		}
+
+		if metadata.IsDefined("caches", k, "logstore", "sync_writes") {
+			cc.Logstore.SyncWrites = v.Logstore.SyncWrites
+		}
+
+		if storageTypes[types.CacheTypeLogstore] {
+			if err := cc.Logstore.Validate(); err != nil {
+				return fmt.Errorf("%s in cache %s", err.Error(), k)
+			}
+		}
+		cc.Logstore.SetDurations()
+
 		c.Caches[k] = cc
 	}
 	return nil
@@ -753,28 +2405,94 @@ func (c *Config) Clone() *Config {
 	nc.Main.PingHandlerPath = c.Main.PingHandlerPath
 	nc.Main.ReloadHandlerPath = c.Main.ReloadHandlerPath
 	nc.Main.HealthHandlerPath = c.Main.HealthHandlerPath
+	nc.Main.ReplicationHandlerPath = c.Main.ReplicationHandlerPath
+	nc.Main.ClusterHandlerPath = c.Main.ClusterHandlerPath
+	nc.Main.InvalidationHandlerPath = c.Main.InvalidationHandlerPath
+	nc.Main.PurgeHandlerPath = c.Main.PurgeHandlerPath
+	nc.Main.CacheStatsHandlerPath = c.Main.CacheStatsHandlerPath
+	nc.Main.ExtentsHandlerPath = c.Main.ExtentsHandlerPath
+	nc.Main.HeatmapHandlerPath = c.Main.HeatmapHandlerPath
 	nc.Main.PprofServer = c.Main.PprofServer
 	nc.Main.ServerName = c.Main.ServerName
 
 	nc.Main.configFilePath = c.Main.configFilePath
 	nc.Main.configLastModified = c.Main.configLastModified
+	if c.Main.includedFiles != nil {
+		nc.Main.includedFiles = make(map[string]time.Time, len(c.Main.includedFiles))
+		for k, v := range c.Main.includedFiles {
+			nc.Main.includedFiles[k] = v
+		}
+	}
+	nc.Main.configSource = c.Main.configSource
+	nc.Main.configVersion = c.Main.configVersion
 	nc.Main.configRateLimitTime = c.Main.configRateLimitTime
 
 	nc.Logging.LogFile = c.Logging.LogFile
 	nc.Logging.LogLevel = c.Logging.LogLevel
+	nc.Logging.CanonicalLogLevel = c.Logging.CanonicalLogLevel
 
 	nc.Metrics.ListenAddress = c.Metrics.ListenAddress
 	nc.Metrics.ListenPort = c.Metrics.ListenPort
+	nc.Metrics.ListenAddresses = str.CloneList(c.Metrics.ListenAddresses)
+	nc.Metrics.AddressFamily = c.Metrics.AddressFamily
+	nc.Metrics.TLSCertificatePath = c.Metrics.TLSCertificatePath
+	nc.Metrics.TLSPrivateKeyPath = c.Metrics.TLSPrivateKeyPath
+	nc.Metrics.TLSClientCAPaths = str.CloneList(c.Metrics.TLSClientCAPaths)
+	nc.Metrics.TLSPolicyName = c.Metrics.TLSPolicyName
+	nc.Metrics.AuthorizerName = c.Metrics.AuthorizerName
+	nc.Metrics.ServeAdmin = c.Metrics.ServeAdmin
+	nc.Metrics.FrontendAdminDisabled = c.Metrics.FrontendAdminDisabled
+	nc.Metrics.ServePurge = c.Metrics.ServePurge
 
 	nc.Frontend.ListenAddress = c.Frontend.ListenAddress
 	nc.Frontend.ListenPort = c.Frontend.ListenPort
 	nc.Frontend.TLSListenAddress = c.Frontend.TLSListenAddress
 	nc.Frontend.TLSListenPort = c.Frontend.TLSListenPort
+	nc.Frontend.ListenAddresses = str.CloneList(c.Frontend.ListenAddresses)
+	nc.Frontend.TLSListenAddresses = str.CloneList(c.Frontend.TLSListenAddresses)
+	nc.Frontend.AddressFamily = c.Frontend.AddressFamily
 	nc.Frontend.ConnectionsLimit = c.Frontend.ConnectionsLimit
 	nc.Frontend.ServeTLS = c.Frontend.ServeTLS
+	nc.Frontend.DevTLS = c.Frontend.DevTLS
+	nc.Frontend.SecurityHeadersName = c.Frontend.SecurityHeadersName
+	nc.Frontend.ForwardedHeadersPolicy = c.Frontend.ForwardedHeadersPolicy
+	nc.Frontend.TrustedProxyNets = c.Frontend.TrustedProxyNets
+	nc.Frontend.TLSPolicyName = c.Frontend.TLSPolicyName
+	nc.Frontend.TLSPolicy = c.Frontend.TLSPolicy
+	nc.Frontend.TLSSessionTicketsDisabled = c.Frontend.TLSSessionTicketsDisabled
+	nc.Frontend.TLSSessionTicketKeyRotationSecs = c.Frontend.TLSSessionTicketKeyRotationSecs
+	nc.Frontend.TLSSessionTicketCacheName = c.Frontend.TLSSessionTicketCacheName
+	if c.Frontend.TrustedProxies != nil {
+		nc.Frontend.TrustedProxies = make([]string, len(c.Frontend.TrustedProxies))
+		copy(nc.Frontend.TrustedProxies, c.Frontend.TrustedProxies)
+	}
+	nc.Frontend.TLSClientCAPaths = str.CloneList(c.Frontend.TLSClientCAPaths)
+	nc.Frontend.IdentityMode = c.Frontend.IdentityMode
+	nc.Frontend.IdentityUserHeader = c.Frontend.IdentityUserHeader
+	nc.Frontend.IdentityGroupsHeader = c.Frontend.IdentityGroupsHeader
+	nc.Frontend.IdentityTrustedProxies = str.CloneList(c.Frontend.IdentityTrustedProxies)
+	nc.Frontend.IdentityTrustedPeers = str.CloneList(c.Frontend.IdentityTrustedPeers)
+	nc.Frontend.IdentityResolver = c.Frontend.IdentityResolver
+
+	if c.Memory != nil {
+		nc.Memory = c.Memory.Clone()
+	}
+
+	if c.Cluster != nil {
+		nc.Cluster = c.Cluster.Clone()
+	}
+
+	if c.Invalidation != nil {
+		nc.Invalidation = c.Invalidation.Clone()
+	}
+
+	if c.ACME != nil {
+		nc.ACME = c.ACME.Clone()
+	}
 
 	nc.Resources = &Resources{
-		QuitChan: make(chan bool, 1),
+		QuitChan:           make(chan bool, 1),
+		BackgroundQuitChan: make(chan struct{}),
 	}
 
 	for k, v := range c.Origins {
@@ -800,6 +2518,36 @@ func (c *Config) Clone() *Config {
 		}
 	}
 
+	if c.SecurityHeaders != nil && len(c.SecurityHeaders) > 0 {
+		nc.SecurityHeaders = make(map[string]*sh.Options)
+		for k, v := range c.SecurityHeaders {
+			nc.SecurityHeaders[k] = v.Clone()
+		}
+	}
+
+	if c.Authorizers != nil && len(c.Authorizers) > 0 {
+		nc.Authorizers = make(map[string]*azo.Options)
+		for k, v := range c.Authorizers {
+			nc.Authorizers[k] = v.Clone()
+		}
+		if p, ok := nc.Authorizers[c.Metrics.AuthorizerName]; ok {
+			nc.Metrics.Authorizer = p
+		}
+	}
+
+	if c.TLSPolicies != nil && len(c.TLSPolicies) > 0 {
+		nc.TLSPolicies = make(map[string]*tp.Options)
+		for k, v := range c.TLSPolicies {
+			nc.TLSPolicies[k] = v.Clone()
+		}
+		if p, ok := nc.TLSPolicies[c.Frontend.TLSPolicyName]; ok {
+			nc.Frontend.TLSPolicy = p
+		}
+		if p, ok := nc.TLSPolicies[c.Metrics.TLSPolicyName]; ok {
+			nc.Metrics.TLSPolicy = p
+		}
+	}
+
 	if c.RequestRewriters != nil && len(c.RequestRewriters) > 0 {
 		nc.RequestRewriters = make(map[string]*rwopts.Options)
 		for k, v := range c.RequestRewriters {
@@ -807,6 +2555,13 @@ func (c *Config) Clone() *Config {
 		}
 	}
 
+	if c.FeatureFlags != nil && len(c.FeatureFlags) > 0 {
+		nc.FeatureFlags = make(map[string]*ff.Options)
+		for k, v := range c.FeatureFlags {
+			nc.FeatureFlags[k] = v.Clone()
+		}
+	}
+
 	return nc
 }
 
@@ -827,6 +2582,17 @@ func (c *Config) IsStale() bool {
 
 	c.Main.configRateLimitTime =
 		time.Now().Add(time.Second * time.Duration(c.ReloadConfig.RateLimitSecs))
+	if c.Main.configSource != nil {
+		// a remote config is stale when the source has a new version of it
+		_, v, err := c.Main.configSource.Fetch(c.Main.configVersion)
+		return err == nil && v != c.Main.configVersion
+	}
+	// a config file is also stale when any of its included files has changed
+	for f, lm := range c.Main.includedFiles {
+		if fileLastModified(f) != lm {
+			return true
+		}
+	}
 	t := c.CheckFileLastModified()
 	if t.IsZero() {
 		return false
@@ -851,6 +2617,14 @@ func (c *Config) String() string {
 			// also strip out potentially sensitive headers
 			hideAuthorizationCredentials(v.HealthCheckHeaders)
 
+			if v.Replication != nil && v.Replication.SharedSecret != "" {
+				v.Replication.SharedSecret = "*****"
+			}
+
+			if v.Ingest != nil && v.Ingest.Password != "" {
+				v.Ingest.Password = "*****"
+			}
+
 			if v.Paths != nil {
 				for _, p := range v.Paths {
 					hideAuthorizationCredentials(p.RequestHeaders)
@@ -860,11 +2634,73 @@ func (c *Config) String() string {
 		}
 	}
 
-	// strip Redis password
+	// strip Redis, Memcached, etcd and Cassandra passwords, S3, DynamoDB and Azure Blob
+	// credentials and encryption keys
 	for k, v := range cp.Caches {
 		if v != nil && cp.Caches[k].Redis.Password != "" {
 			cp.Caches[k].Redis.Password = "*****"
 		}
+		if v != nil && cp.Caches[k].S3 != nil {
+			if cp.Caches[k].S3.SecretAccessKey != "" {
+				cp.Caches[k].S3.SecretAccessKey = "*****"
+			}
+			if cp.Caches[k].S3.SessionToken != "" {
+				cp.Caches[k].S3.SessionToken = "*****"
+			}
+		}
+		if v != nil && cp.Caches[k].DynamoDB != nil {
+			if cp.Caches[k].DynamoDB.SecretAccessKey != "" {
+				cp.Caches[k].DynamoDB.SecretAccessKey = "*****"
+			}
+			if cp.Caches[k].DynamoDB.SessionToken != "" {
+				cp.Caches[k].DynamoDB.SessionToken = "*****"
+			}
+		}
+		if v != nil && cp.Caches[k].AzureBlob != nil {
+			if cp.Caches[k].AzureBlob.AccountKey != "" {
+				cp.Caches[k].AzureBlob.AccountKey = "*****"
+			}
+			if cp.Caches[k].AzureBlob.SASToken != "" {
+				cp.Caches[k].AzureBlob.SASToken = "*****"
+			}
+		}
+		if v != nil && cp.Caches[k].Memcached != nil && cp.Caches[k].Memcached.Password != "" {
+			cp.Caches[k].Memcached.Password = "*****"
+		}
+		if v != nil && cp.Caches[k].Etcd != nil && cp.Caches[k].Etcd.Password != "" {
+			cp.Caches[k].Etcd.Password = "*****"
+		}
+		if v != nil && cp.Caches[k].Cassandra != nil && cp.Caches[k].Cassandra.Password != "" {
+			cp.Caches[k].Cassandra.Password = "*****"
+		}
+		if v != nil && cp.Caches[k].Encryption != nil {
+			for _, key := range cp.Caches[k].Encryption.Keys {
+				if key != nil && key.Key != "" {
+					key.Key = "*****"
+				}
+			}
+		}
+	}
+
+	if cp.Invalidation != nil && cp.Invalidation.SharedSecret != "" {
+		cp.Invalidation.SharedSecret = "*****"
+	}
+
+	if cp.ACME != nil {
+		for _, v := range cp.ACME.Domains {
+			if v == nil {
+				continue
+			}
+			if v.SecretAccessKey != "" {
+				v.SecretAccessKey = "*****"
+			}
+			if v.SessionToken != "" {
+				v.SessionToken = "*****"
+			}
+			if v.APIToken != "" {
+				v.APIToken = "*****"
+			}
+		}
 	}
 
 	var buf bytes.Buffer
@@ -873,6 +2709,11 @@ func (c *Config) String() string {
 	return buf.String()
 }
 
+// IsRemote returns true if this configuration is based on a remote config source
+func (c *Config) IsRemote() bool {
+	return c.Main != nil && c.Main.configSource != nil
+}
+
 // ConfigFilePath returns the file path from which this configuration is based
 func (c *Config) ConfigFilePath() string {
 	if c.Main != nil {
@@ -883,7 +2724,17 @@ func (c *Config) ConfigFilePath() string {
 
 // Equal returns true if the FrontendConfigs are identical in value.
 func (fc *FrontendConfig) Equal(fc2 *FrontendConfig) bool {
-	return *fc == *fc2
+	// SecurityHeadersName and the forwarded headers policy are applied to the router,
+	// which is rebuilt on every reload, so they do not affect listener equality
+	return fc.ListenAddress == fc2.ListenAddress &&
+		fc.ListenPort == fc2.ListenPort &&
+		fc.TLSListenAddress == fc2.TLSListenAddress &&
+		fc.TLSListenPort == fc2.TLSListenPort &&
+		str.Equal(fc.ListenAddresses, fc2.ListenAddresses) &&
+		str.Equal(fc.TLSListenAddresses, fc2.TLSListenAddresses) &&
+		fc.AddressFamily == fc2.AddressFamily &&
+		fc.ConnectionsLimit == fc2.ConnectionsLimit &&
+		fc.ServeTLS == fc2.ServeTLS
 }
 
 var sensitiveCredentials = map[string]bool{headers.NameAuthorization: true}
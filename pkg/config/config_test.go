@@ -19,11 +19,15 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
+	eo "github.com/tricksterproxy/trickster/pkg/cache/encryption/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
@@ -53,7 +57,7 @@ func TestClone(t *testing.T) {
 	oc.CompressableTypeList = []string{"text/plain"}
 	oc.CompressableTypes = map[string]bool{"text/plain": true}
 	oc.NegativeCacheName = "default"
-	oc.NegativeCache = map[int]time.Duration{404: time.Duration(10) * time.Second}
+	oc.NegativeCache = &negative.Cache{TTLs: map[int]time.Duration{404: time.Duration(10) * time.Second}}
 	oc.FastForwardPath = po.NewOptions()
 	oc.TLS = &to.Options{CertificateAuthorityPaths: []string{"foo"}}
 	oc.HealthCheckHeaders = map[string]string{headers.NameAuthorization: expected}
@@ -84,11 +88,41 @@ func TestString(t *testing.T) {
 	c1.Origins["default"].Paths["test"] = &po.Options{}
 
 	c1.Caches["default"].Redis.Password = "plaintext-password"
+	c1.Origins["default"].Ingest.Password = "ingest-password"
+	c1.Caches["default"].S3.SecretAccessKey = "s3-secret"
+	c1.Invalidation.SharedSecret = "invalidation-secret"
+	c1.Caches["default"].S3.SessionToken = "s3-token"
+	c1.Caches["default"].DynamoDB.SecretAccessKey = "dynamodb-secret"
+	c1.Caches["default"].DynamoDB.SessionToken = "dynamodb-token"
+	c1.Caches["default"].AzureBlob.AccountKey = "azureblob-key"
+	c1.Caches["default"].AzureBlob.SASToken = "azureblob-sas"
+	c1.Caches["default"].Encryption.Keys["k1"] = &eo.KeyOptions{Key: "encryption-key"}
 
 	s := c1.String()
 	if !strings.Contains(s, `password = "*****"`) {
 		t.Errorf("missing password mask: %s", "*****")
 	}
+	if strings.Contains(s, "ingest-password") {
+		t.Error("expected ingest password to be masked")
+	}
+	if strings.Contains(s, "s3-secret") || strings.Contains(s, "s3-token") {
+		t.Error("expected s3 credentials to be masked")
+	}
+	if strings.Contains(s, "dynamodb-secret") || strings.Contains(s, "dynamodb-token") {
+		t.Error("expected dynamodb credentials to be masked")
+	}
+	if strings.Contains(s, "azureblob-key") || strings.Contains(s, "azureblob-sas") {
+		t.Error("expected azure blob credentials to be masked")
+	}
+	if strings.Contains(s, "invalidation-secret") {
+		t.Error("expected invalidation secret to be masked")
+	}
+	if strings.Contains(s, "encryption-key") {
+		t.Error("expected encryption key to be masked")
+	}
+	if c1.Caches["default"].Encryption.Keys["k1"].Key != "encryption-key" {
+		t.Error("expected encryption key to be masked only in the copy")
+	}
 }
 
 func TestHideAuthorizationCredentials(t *testing.T) {
@@ -154,6 +188,107 @@ func TestProcessPprofConfig(t *testing.T) {
 
 }
 
+func TestProcessFrontendConfig(t *testing.T) {
+
+	c := NewConfig()
+	c.Frontend.ForwardedHeadersPolicy = ""
+	c.Frontend.TrustedProxies = []string{"10.0.0.0/8", "127.0.0.1"}
+
+	err := c.processFrontendConfig()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Frontend.ForwardedHeadersPolicy != d.DefaultForwardedHeadersPolicy {
+		t.Errorf("expected %s got %s", d.DefaultForwardedHeadersPolicy, c.Frontend.ForwardedHeadersPolicy)
+	}
+
+	if len(c.Frontend.TrustedProxyNets) != 2 {
+		t.Errorf("expected %d got %d", 2, len(c.Frontend.TrustedProxyNets))
+	}
+
+	c.Frontend.TLSSessionTicketCacheName = "default"
+	err = c.processFrontendConfig()
+	if err != nil {
+		t.Error(err)
+	}
+	if c.Frontend.TLSSessionTicketKeyRotationSecs != d.DefaultTLSSessionTicketKeyRotationSecs {
+		t.Errorf("expected %d got %d", d.DefaultTLSSessionTicketKeyRotationSecs,
+			c.Frontend.TLSSessionTicketKeyRotationSecs)
+	}
+
+	c.Frontend.TLSSessionTicketKeyRotationSecs = -1
+	err = c.processFrontendConfig()
+	if err != ErrInvalidSessionTicketKeyRotation {
+		t.Errorf("expected error for invalid session ticket key rotation, got %v", err)
+	}
+	c.Frontend.TLSSessionTicketKeyRotationSecs = 0
+
+	c.Frontend.IdentityMode = "trusted_headers"
+	err = c.processFrontendConfig()
+	if err != ErrNoIdentityTrustSources {
+		t.Errorf("expected error for missing identity trust sources, got %v", err)
+	}
+
+	c.Frontend.IdentityTrustedProxies = []string{"192.168.0.0/16"}
+	err = c.processFrontendConfig()
+	if err != nil {
+		t.Error(err)
+	}
+	if c.Frontend.IdentityResolver == nil {
+		t.Error("expected non-nil identity resolver")
+	}
+	if c.Frontend.IdentityUserHeader != d.DefaultIdentityUserHeader {
+		t.Errorf("expected %s got %s", d.DefaultIdentityUserHeader, c.Frontend.IdentityUserHeader)
+	}
+
+	c.Frontend.IdentityTrustedProxies = []string{"x"}
+	err = c.processFrontendConfig()
+	if err == nil {
+		t.Error("expected error for invalid identity trusted proxy")
+	}
+
+	c.Frontend.IdentityMode = "x"
+	err = c.processFrontendConfig()
+	if err != ErrInvalidIdentityMode {
+		t.Errorf("expected error for invalid identity mode, got %v", err)
+	}
+	c.Frontend.IdentityMode = ""
+
+	c.Frontend.TrustedProxies = []string{"x"}
+	err = c.processFrontendConfig()
+	if err == nil {
+		t.Error("expected error for invalid trusted proxy")
+	}
+
+	c.Frontend.ForwardedHeadersPolicy = "x"
+	err = c.processFrontendConfig()
+	if err != ErrInvalidForwardedHeadersPolicy {
+		t.Errorf("expected error for invalid forwarded headers policy, got %v", err)
+	}
+
+}
+
+func TestProcessAddressFamilies(t *testing.T) {
+
+	c, _ := emptyTestConfig()
+
+	c.Frontend.AddressFamily = ""
+	c.ReloadConfig.AddressFamily = "tcp6"
+	if err := c.processAddressFamilies(); err != nil {
+		t.Error(err)
+	}
+	if c.Frontend.AddressFamily != d.DefaultAddressFamily {
+		t.Errorf("expected %s got %s", d.DefaultAddressFamily, c.Frontend.AddressFamily)
+	}
+
+	c.Metrics.AddressFamily = "x"
+	if err := c.processAddressFamilies(); err != ErrInvalidAddressFamily {
+		t.Errorf("expected error for invalid address family, got %v", err)
+	}
+
+}
+
 func TestSetDefaults(t *testing.T) {
 
 	c, _ := emptyTestConfig()
@@ -204,6 +339,33 @@ func TestValidateConfigMappings(t *testing.T) {
 		t.Error(err)
 	}
 
+	c.Frontend.TLSPolicyName = "invalid"
+	err = c.validateConfigMappings()
+	if err == nil {
+		t.Error("expected error for invalid frontend tls policy name")
+	}
+
+	c.Frontend.TLSPolicyName = "modern"
+	c.Origins["test"].TLS.PolicyName = "invalid"
+	err = c.validateConfigMappings()
+	if err == nil {
+		t.Error("expected error for invalid origin tls policy name")
+	}
+
+	c.Origins["test"].TLS.PolicyName = ""
+	c.Frontend.TLSSessionTicketCacheName = "invalid"
+	err = c.validateConfigMappings()
+	if err == nil {
+		t.Error("expected error for invalid session ticket cache name")
+	}
+
+	c.Frontend.TLSSessionTicketCacheName = ""
+	c.Origins["test"].AuthorizerName = "invalid"
+	err = c.validateConfigMappings()
+	if err == nil {
+		t.Error("expected error for invalid authorizer name")
+	}
+
 }
 
 const testRule = `
@@ -354,6 +516,40 @@ func TestIsStale(t *testing.T) {
 	}
 }
 
+func TestIsStaleRemote(t *testing.T) {
+
+	_, tml := emptyTestConfig()
+	doc := tml
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(doc))
+	}))
+	defer ts.Close()
+
+	c, _, err := Load("testing", "testing", []string{"-config", ts.URL + "/trickster.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ReloadConfig.RateLimitSecs = 0
+
+	if !c.IsRemote() || !c.Clone().IsRemote() {
+		t.Error("expected remote config")
+	}
+	if c.IsStale() {
+		t.Error("expected non-stale config")
+	}
+
+	doc = tml + "\n# changed\n"
+	if !c.IsStale() {
+		t.Error("expected stale config")
+	}
+
+	// an unreachable source does not make the config stale
+	ts.Close()
+	if c.IsStale() {
+		t.Error("expected non-stale config")
+	}
+}
+
 func TestConfigFilePath(t *testing.T) {
 
 	c, _ := emptyTestConfig()
@@ -379,4 +575,16 @@ func TestFrontendConfigEqual(t *testing.T) {
 		t.Errorf("expected %t got %t", true, b)
 	}
 
+	f2.SecurityHeadersName = "test"
+	b = f1.Equal(f2)
+	if !b {
+		t.Errorf("expected %t got %t", true, b)
+	}
+
+	f2.ListenPort = 8480
+	b = f1.Equal(f2)
+	if b {
+		t.Errorf("expected %t got %t", false, b)
+	}
+
 }
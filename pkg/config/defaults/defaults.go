@@ -27,6 +27,12 @@ const (
 	DefaultLogFile = ""
 	// DefaultLogLevel is the default level for logging
 	DefaultLogLevel = "INFO"
+	// DefaultCanonicalLogLevel is the default level at which each request's canonical log line is logged
+	DefaultCanonicalLogLevel = "DEBUG"
+
+	// DefaultAddressFamily is the default address family of the listeners: 'dual', 'tcp4' or 'tcp6'.
+	// 'dual' accepts both IPv4 and IPv6 connections where the host supports it
+	DefaultAddressFamily = "dual"
 
 	// DefaultProxyListenPort is the default port that the HTTP frontend will listen on
 	DefaultProxyListenPort = 8480
@@ -54,6 +60,41 @@ const (
 	DefaultDrainTimeoutSecs = 30
 	// DefaultRateLimitSecs is the default Rate Limit time for Config Reloads
 	DefaultRateLimitSecs = 3
+	// DefaultRemotePollIntervalSecs is the default interval at which a remote config source is
+	// polled for changes
+	DefaultRemotePollIntervalSecs = 60
+
+	// DefaultMemoryCgroupLimitRatio is the default fraction of a detected cgroup memory limit
+	// that is used as the soft memory limit when none is configured
+	DefaultMemoryCgroupLimitRatio = 0.9
+	// DefaultMemoryWatermarkRatio is the default fraction of the soft memory limit above
+	// which Trickster considers itself to be under memory pressure
+	DefaultMemoryWatermarkRatio = 0.85
+	// DefaultMemoryPressureEvictionRatio is the default fraction of each memory cache's size
+	// that is evicted during each reap while under memory pressure
+	DefaultMemoryPressureEvictionRatio = 0.2
+	// DefaultMemoryCheckIntervalMS is the default interval at which memory use is checked
+	DefaultMemoryCheckIntervalMS = 1000
+
+	// DefaultFeatureFlagEnabled is the default enabled state of a configured feature flag
+	DefaultFeatureFlagEnabled = true
+	// DefaultFeatureFlagPercentage is the default percentage of requests a feature flag applies to
+	DefaultFeatureFlagPercentage = 100
+
+	// DefaultClusterVirtualNodes is the default number of points each cluster peer occupies
+	// on the consistent hash ring
+	DefaultClusterVirtualNodes = 160
+	// DefaultClusterTimeoutMS is the default maximum duration of a request forwarded to a peer
+	DefaultClusterTimeoutMS = 180000
+	// DefaultClusterFailoverSecs is the default duration for which an unreachable peer is
+	// bypassed, with requests for the keys it owns served locally
+	DefaultClusterFailoverSecs = 10
+	// DefaultClusterGossipIntervalMS is the default interval between exchanges of cluster
+	// membership with a peer
+	DefaultClusterGossipIntervalMS = 1000
+	// DefaultClusterMemberTimeoutMS is the default duration after which a discovered peer that
+	// has not been heard from is removed from the cluster
+	DefaultClusterMemberTimeoutMS = 10000
 
 	// DefaultTracerType is the default distributed tracer exporter implementation
 	DefaultTracerType = "none"
@@ -75,12 +116,121 @@ const (
 	DefaultMaxTTLSecs = 86400
 	// DefaultRevalidationFactor is the default Cache Object Freshness Lifetime to TTL multiplier
 	DefaultRevalidationFactor = 2
+	// DefaultBadgerValueLogGCIntervalSecs is the default interval (in seconds) of Badger value log garbage collection
+	DefaultBadgerValueLogGCIntervalSecs = 300
+	// DefaultBadgerValueLogGCRatio is the default discardable fraction of a Badger value log file that is rewritten
+	DefaultBadgerValueLogGCRatio = 0.5
 	// DefaultRedisClientType is the default Redis Client Type
 	DefaultRedisClientType = "standard"
 	// DefaultRedisProtocol is the default Redis Client protocol
 	DefaultRedisProtocol = "tcp"
 	// DefaultRedisEndpoint is the default Redis Client endpoint
 	DefaultRedisEndpoint = "redis:6379"
+	// DefaultRedisPipelineBatchSize is the default maximum number of commands in each
+	// pipeline sent to Redis by bulk operations
+	DefaultRedisPipelineBatchSize = 500
+	// DefaultRedisClientCacheTTLMS is the default time an object is held in the
+	// Redis client-side cache (in milliseconds)
+	DefaultRedisClientCacheTTLMS = 1000
+	// DefaultFilesystemSyncMode is the default durability mode of Filesystem Cache writes
+	DefaultFilesystemSyncMode = "none"
+	// DefaultFilesystemReconcileMode is the default mode of rebuilding the Filesystem Cache index
+	// from the files on disk
+	DefaultFilesystemReconcileMode = "auto"
+	// DefaultFilesystemSyncIntervalMS is the default interval at which the Filesystem Cache
+	// fsyncs written files when using the periodic sync mode
+	DefaultFilesystemSyncIntervalMS = 1000
+	// DefaultFilesystemFileMode is the default permissions of the files written by the
+	// Filesystem Cache, before the process umask is applied
+	DefaultFilesystemFileMode = "0777"
+	// DefaultFilesystemDirMode is the default permissions of the Filesystem Cache directory,
+	// before the process umask is applied
+	DefaultFilesystemDirMode = "0755"
+	// DefaultFilesystemBatchIntervalMS is the default interval at which the Filesystem Cache
+	// flushes batched writes
+	DefaultFilesystemBatchIntervalMS = 50
+	// DefaultS3Region is the default region of the S3 Cache bucket
+	DefaultS3Region = "us-east-1"
+	// DefaultS3Prefix is the default prefix of the S3 Cache object names
+	DefaultS3Prefix = "trickster/"
+	// DefaultS3PartSizeBytes is the default size of the parts of S3 Cache multipart uploads
+	DefaultS3PartSizeBytes = 8388608
+	// DefaultS3TimeoutMS is the default timeout of S3 Cache requests
+	DefaultS3TimeoutMS = 5000
+	// DefaultGCSEndpoint is the default base URL of the GCS Cache's Cloud Storage XML API
+	DefaultGCSEndpoint = "https://storage.googleapis.com"
+	// DefaultGCSPrefix is the default prefix of the GCS Cache object names
+	DefaultGCSPrefix = "trickster/"
+	// DefaultGCSTimeoutMS is the default timeout of GCS Cache requests
+	DefaultGCSTimeoutMS = 5000
+	// DefaultAzureBlobPrefix is the default prefix of the Azure Blob Cache blob names
+	DefaultAzureBlobPrefix = "trickster/"
+	// DefaultAzureBlobTimeoutMS is the default timeout of Azure Blob Cache requests
+	DefaultAzureBlobTimeoutMS = 5000
+	// DefaultDynamoDBRegion is the default region of the DynamoDB Cache table
+	DefaultDynamoDBRegion = "us-east-1"
+	// DefaultDynamoDBKeyAttribute is the default name of the DynamoDB Cache table's partition key
+	DefaultDynamoDBKeyAttribute = "cache_key"
+	// DefaultDynamoDBTTLAttribute is the default name of the DynamoDB Cache table's TTL attribute
+	DefaultDynamoDBTTLAttribute = "expires_at"
+	// DefaultDynamoDBBatchSize is the default number of items in each DynamoDB Cache batch write
+	DefaultDynamoDBBatchSize = 25
+	// DefaultDynamoDBTimeoutMS is the default timeout of DynamoDB Cache requests
+	DefaultDynamoDBTimeoutMS = 5000
+	// DefaultMemcachedServer is the default address of the Memcached Cache server
+	DefaultMemcachedServer = "memcached:11211"
+	// DefaultMemcachedVirtualNodes is the default number of points given to each Memcached
+	// Cache server on the consistent hash ring
+	DefaultMemcachedVirtualNodes = 160
+	// DefaultMemcachedMaxIdleConns is the default number of idle connections held open to
+	// each Memcached Cache server
+	DefaultMemcachedMaxIdleConns = 4
+	// DefaultMemcachedTimeoutMS is the default timeout of Memcached Cache requests
+	DefaultMemcachedTimeoutMS = 1000
+	// DefaultEtcdEndpoint is the default client URL of the etcd Cache cluster
+	DefaultEtcdEndpoint = "http://etcd:2379"
+	// DefaultEtcdKeyPrefix is the default prefix of the etcd Cache's object keys
+	DefaultEtcdKeyPrefix = "/trickster/cache/"
+	// DefaultEtcdLockPrefix is the default prefix of the etcd Cache's distributed lock keys
+	DefaultEtcdLockPrefix = "/trickster/locks/"
+	// DefaultEtcdMaxValueBytes is the default size limit of objects stored in the etcd Cache
+	DefaultEtcdMaxValueBytes = 65536
+	// DefaultEtcdLockTTLSecs is the default TTL of the etcd Cache's distributed locks
+	DefaultEtcdLockTTLSecs = 30
+	// DefaultEtcdLockTimeoutMS is the default wait for a distributed lock held by another Trickster
+	DefaultEtcdLockTimeoutMS = 5000
+	// DefaultEtcdTimeoutMS is the default timeout of etcd Cache requests
+	DefaultEtcdTimeoutMS = 2000
+	// DefaultCassandraHost is the default address of the Cassandra Cache's contact point
+	DefaultCassandraHost = "cassandra:9042"
+	// DefaultCassandraKeyspace is the default keyspace of the Cassandra Cache's table
+	DefaultCassandraKeyspace = "trickster"
+	// DefaultCassandraTable is the default name of the Cassandra Cache's table
+	DefaultCassandraTable = "cache"
+	// DefaultCassandraConsistency is the default consistency level of Cassandra Cache reads and writes
+	DefaultCassandraConsistency = "local_one"
+	// DefaultCassandraMaxIdleConns is the default number of idle connections held open to
+	// each Cassandra Cache node
+	DefaultCassandraMaxIdleConns = 4
+	// DefaultCassandraTimeoutMS is the default timeout of Cassandra Cache requests
+	DefaultCassandraTimeoutMS = 2000
+	// DefaultLogstoreSegmentSizeMB is the default size at which a Logstore Cache segment is sealed
+	DefaultLogstoreSegmentSizeMB = 512
+	// DefaultLogstoreCompactIntervalSecs is the default interval (in seconds) at which Logstore
+	// Cache segments are checked for compaction
+	DefaultLogstoreCompactIntervalSecs = 60
+	// DefaultLogstoreCompactDeadRatio is the default dead fraction of a Logstore Cache segment
+	// that is compacted
+	DefaultLogstoreCompactDeadRatio = 0.5
+	// DefaultTieredL2CacheType is the default type of the persistent tier of a Tiered Cache
+	DefaultTieredL2CacheType = "filesystem"
+	// DefaultTieredL1MaxSizeBytes is the default max size in bytes of the memory tier of a Tiered Cache
+	DefaultTieredL1MaxSizeBytes = 67108864
+	// DefaultTieredL1MaxSizeObjects is the default max object count of the memory tier of a Tiered Cache
+	DefaultTieredL1MaxSizeObjects = 0
+	// DefaultTieredL1PromotionTTLSecs is the default TTL of objects promoted into the memory tier
+	// of a Tiered Cache
+	DefaultTieredL1PromotionTTLSecs = 60
 	// DefaultBBoltFile is the default bbolt Cache filename
 	DefaultBBoltFile = "trickster.db"
 	// DefaultBBoltBucket is the default bbolt Cache bucket name
@@ -89,6 +239,16 @@ const (
 	DefaultCacheIndexReap = 3
 	// DefaultCacheIndexFlush is the default Cache Index Flush interval (in seconds)
 	DefaultCacheIndexFlush = 5
+	// DefaultCacheIndexForecast is the default Cache Index usage forecast interval (in seconds)
+	DefaultCacheIndexForecast = 60
+	// DefaultCacheIndexAccessQueueSize is the default number of object accesses queued for the Cache Index
+	DefaultCacheIndexAccessQueueSize = 10000
+	// DefaultCacheIndexAccessBatchSize is the default number of queued object accesses recorded at once
+	DefaultCacheIndexAccessBatchSize = 100
+	// DefaultCacheIndexAccessFlushMS is the default interval (in ms) at which queued object accesses are recorded
+	DefaultCacheIndexAccessFlushMS = 100
+	// DefaultCacheIndexEvictionPolicy is the default Cache Index eviction policy
+	DefaultCacheIndexEvictionPolicy = "lru"
 	// DefaultCacheMaxSizeBytes is the default Max Cache Size in Bytes
 	DefaultCacheMaxSizeBytes = 536870912
 	// DefaultMaxSizeBackoffBytes is the default Max Cache Backoff Size in Bytes
@@ -99,12 +259,73 @@ const (
 	DefaultMaxSizeBackoffObjects = 100
 	// DefaultMaxObjectSizeBytes is the default Max Size of any Cache Object
 	DefaultMaxObjectSizeBytes = 524288
+	// DefaultCompressionLevel is the default gzip level for compressing responses to clients
+	DefaultCompressionLevel = 6
+	// DefaultCompressionMinSizeBytes is the default minimum size of a response body to be compressed
+	DefaultCompressionMinSizeBytes = 1024
+	// DefaultCompressionParallelMinSizeBytes is the default minimum size of a response body to be
+	// compressed in blocks on multiple CPUs
+	DefaultCompressionParallelMinSizeBytes = 1048576
+	// DefaultCacheWarmingIntervalSecs is the default interval between passes of a cache's
+	// background warmer, after its first pass at startup
+	DefaultCacheWarmingIntervalSecs = 300
+	// DefaultCacheWarmingConcurrency is the default number of queries a cache's warmer
+	// replays at once
+	DefaultCacheWarmingConcurrency = 4
+	// DefaultRetentionTrimIntervalSecs is the default interval between passes of an origin's
+	// retention window trimmer
+	DefaultRetentionTrimIntervalSecs = 3600
+	// DefaultReplicationIntervalMS is the default interval between pushes of an origin's
+	// newly-written timeseries to its replication peers
+	DefaultReplicationIntervalMS = 5000
+	// DefaultReplicationTimeoutMS is the default maximum duration of a replication request to a peer
+	DefaultReplicationTimeoutMS = 5000
+	// DefaultReplicationMaxPending is the default maximum number of objects awaiting replication
+	DefaultReplicationMaxPending = 10000
+	// DefaultInvalidationMaxSkewSecs is the default maximum difference between the timestamp of a
+	// signed invalidation request and the current time
+	DefaultInvalidationMaxSkewSecs = 300
+	// DefaultACMEDirectoryURL is the default directory of the ACME certificate authority, which
+	// is that of Let's Encrypt
+	DefaultACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+	// DefaultACMERenewBeforeSecs is the default time before a managed certificate's expiration
+	// at which it is renewed
+	DefaultACMERenewBeforeSecs = 2592000
+	// DefaultACMECheckIntervalSecs is the default interval between checks of whether managed
+	// certificates are due for renewal
+	DefaultACMECheckIntervalSecs = 43200
+	// DefaultACMERetryIntervalSecs is the default wait before a failed certificate order is retried
+	DefaultACMERetryIntervalSecs = 600
+	// DefaultACMEPropagationDelaySecs is the default wait between publishing a DNS-01 challenge
+	// record and asking the certificate authority to validate it
+	DefaultACMEPropagationDelaySecs = 60
+	// DefaultACMETimeoutMS is the default timeout of each request to the ACME and DNS provider APIs
+	DefaultACMETimeoutMS = 30000
+	// DefaultIngestStartOffset is the default offset of a Kafka partition from which an origin's
+	// ingestion listener starts consuming
+	DefaultIngestStartOffset = "latest"
+	// DefaultIngestQoS is the default MQTT quality of service of an origin's ingestion subscription
+	DefaultIngestQoS = 1
+	// DefaultIngestReconnectIntervalMS is the default wait before an origin's ingestion listener
+	// reconnects to its brokers after the connection fails
+	DefaultIngestReconnectIntervalMS = 5000
+	// DefaultIngestMaxMessageBytes is the default size limit of a message consumed by an origin's
+	// ingestion listener
+	DefaultIngestMaxMessageBytes = 16777216
+	// DefaultFastJSONMinBytes is the default minimum size of a timeseries document for it to be
+	// decoded with the fast JSON decoder. It is faster at all sizes, so this only leaves tiny
+	// documents like errors and empty results, which are the likeliest to fall back, to encoding/json
+	DefaultFastJSONMinBytes = 1024
 	// DefaultOriginTRF is the default Timeseries Retention Factor for Time Series-based Origins
 	DefaultOriginTRF = 1024
 	// DefaultOriginTEM is the default Timeseries Eviction Method for Time Series-based Origins
 	DefaultOriginTEM = evictionmethods.EvictionMethodOldest
 	// DefaultOriginTEMName is the default Timeseries Eviction Method name for Time Series-based Origins
 	DefaultOriginTEMName = "oldest"
+	// DefaultUpstreamType is the default origin type of the origin behind an upstream Trickster
+	DefaultUpstreamType = "reverseproxycache"
+	// DefaultMaxHops is the default maximum number of Tricksters a request may pass through
+	DefaultMaxHops = 8
 	// DefaultOriginTimeoutSecs is the default Upstream Request Timeout for Origins
 	DefaultOriginTimeoutSecs = 180
 	// DefaultOriginCacheName is the default Cache Name for Origins
@@ -115,6 +336,10 @@ const (
 	DefaultTracingConfigName = "default"
 	// DefaultBackfillToleranceSecs is the default Backfill Tolerance setting for Origins
 	DefaultBackfillToleranceSecs = 0
+	// DefaultGapFill is the default gap fill mode of timeseries responses
+	DefaultGapFill = "none"
+	// DefaultGapFillMaxSecs is the default longest interval over which a value is carried forward
+	DefaultGapFillMaxSecs = 300
 	// DefaultKeepAliveTimeoutSecs is the default Keep Alive Timeout for Origins' upstream client pools
 	DefaultKeepAliveTimeoutSecs = 300
 	// DefaultMaxIdleConns is the default number of Idle Connections in Origins' upstream client pools
@@ -131,14 +356,127 @@ const (
 	DefaultPingHandlerPath = "/trickster/ping"
 	// DefaultReloadHandlerPath defines the default path for the Reload Handler
 	DefaultReloadHandlerPath = "/trickster/config/reload"
+	// DefaultRulesHandlerPath defines the default path for the Runtime Rules Update Handler
+	DefaultRulesHandlerPath = "/trickster/config/rules"
+	// DefaultFlagsHandlerPath defines the default path for the Feature Flags Handler
+	DefaultFlagsHandlerPath = "/trickster/config/flags"
+	// DefaultCompactHandlerPath defines the default path for the Cache Compaction Handler
+	DefaultCompactHandlerPath = "/trickster/caches/compact"
 	// DefaultHealthHandlerPath defines the default path for the Health Handler
 	DefaultHealthHandlerPath = "/trickster/health"
+	// DefaultClusterHandlerPath defines the default path for the Cluster Status Handler
+	DefaultClusterHandlerPath = "/trickster/cluster"
+	// DefaultReplicationHandlerPath defines the default base path for the inbound Cache Replication Handler
+	DefaultReplicationHandlerPath = "/trickster/replication"
+	// DefaultHeatmapHandlerPath defines the default base path for the Latency Heatmap Handler
+	DefaultHeatmapHandlerPath = "/trickster/heatmap"
+	// DefaultInvalidationHandlerPath defines the default path for the Cache Invalidation Webhook Handler
+	DefaultInvalidationHandlerPath = "/trickster/invalidate"
+	// DefaultCacheStatsHandlerPath defines the default path for the Cache Statistics Handler of the metrics listener
+	DefaultCacheStatsHandlerPath = "/trickster/caches"
+	// DefaultCacheStatsTopN is the default number of the largest and most-accessed objects reported
+	// for each cache by the Cache Statistics Handler
+	DefaultCacheStatsTopN = 10
+	// DefaultExtentsHandlerPath defines the default path for the Timeseries Extents Handler of the metrics listener
+	DefaultExtentsHandlerPath = "/trickster/extents"
+	// DefaultPurgeHandlerPath defines the default path for the Cache Purge Handler of the metrics listener
+	DefaultPurgeHandlerPath = "/trickster/purge"
 	// DefaultMaxRuleExecutions is the default value for the number of allowed Rule executions per Request
 	DefaultMaxRuleExecutions = 16
 	// DefaultPprofServerName defines the default Pprof Server Name
 	DefaultPprofServerName = "both"
 	// DefaultForwardedHeaders defines which class of 'Forwarded' headers are attached to upstream requests
 	DefaultForwardedHeaders = "standard"
+	// DefaultForwardedHeadersPolicy defines how inbound 'Forwarded' headers are treated
+	DefaultForwardedHeadersPolicy = "sanitize"
+	// DefaultTLSSessionTicketKeyRotationSecs is the default session ticket key rotation interval used
+	// when session ticket keys are shared via a cache
+	DefaultTLSSessionTicketKeyRotationSecs = 3600
+	// DefaultDNSCacheTTLSecs is the default TTL for cached origin hostname resolutions; 0 disables the cache
+	DefaultDNSCacheTTLSecs = 0
+	// DefaultDNSNegativeTTLSecs is the default TTL for cached origin hostname resolution failures
+	DefaultDNSNegativeTTLSecs = 5
+	// DefaultDNSReResolveOnError is the default setting for re-resolving origin hostnames on dial failures
+	DefaultDNSReResolveOnError = true
+	// DefaultDNSServeStale is the default setting for using expired resolutions when re-resolution fails
+	DefaultDNSServeStale = true
+	// DefaultDNSStrategy is the default strategy for selecting which resolved origin address is dialed
+	DefaultDNSStrategy = "first"
+	// DefaultErrorBudgetWindowSecs is the default window over which an origin's error rate and latency are measured
+	DefaultErrorBudgetWindowSecs = 60
+	// DefaultErrorBudgetMinRequests is the default minimum number of upstream requests in a window
+	// before an origin can be considered degraded
+	DefaultErrorBudgetMinRequests = 20
+	// DefaultErrorBudgetTTLStretchFactor is the default multiplier applied to cache freshness while an origin is degraded
+	DefaultErrorBudgetTTLStretchFactor = 4.0
+	// DefaultErrorBudgetMaxStretchedTTLSecs is the default upper bound of a stretched cache TTL
+	DefaultErrorBudgetMaxStretchedTTLSecs = 3600
+	// DefaultSLOLatencyObjective is the default fraction of requests that must be
+	// served within an origin's SLO latency target
+	DefaultSLOLatencyObjective = 0.99
+	// DefaultSLOPeriodSecs is the default compliance period over which an origin's SLO error budget is measured
+	DefaultSLOPeriodSecs = 2592000
+	// DefaultHeatmapMaxSamples is the default number of an origin's recent requests sampled for its latency heatmap
+	DefaultHeatmapMaxSamples = 1000
+	// DefaultHeatmapWindowSecs is the default maximum age of an origin's latency heatmap samples
+	DefaultHeatmapWindowSecs = 900
+	// DefaultIdentityUserHeader is the default header from which a trusted proxy's asserted user is read
+	DefaultIdentityUserHeader = "X-Auth-Request-User"
+	// DefaultIdentityGroupsHeader is the default header from which a trusted proxy's asserted groups are read
+	DefaultIdentityGroupsHeader = "X-Auth-Request-Groups"
+	// DefaultAuthorizerTimeoutMS is the default timeout of a call to an external authorization service
+	DefaultAuthorizerTimeoutMS = 1000
+	// DefaultAuthorizerDecisionCacheTTLSecs is the default duration an authorization decision is cached
+	DefaultAuthorizerDecisionCacheTTLSecs = 10
+	// DefaultAuthorizerDecisionCacheMaxEntries is the default maximum number of cached authorization decisions
+	DefaultAuthorizerDecisionCacheMaxEntries = 10000
+	// DefaultAuthorizerStatusOnError is the default response status when the authorization service can't be reached
+	DefaultAuthorizerStatusOnError = 403
+	// DefaultSecurityHeadersContentTypeNoSniff is the default setting for including
+	// 'X-Content-Type-Options: nosniff' in a security headers profile
+	DefaultSecurityHeadersContentTypeNoSniff = true
+	// DefaultSecurityHeadersReferrerPolicy is the default Referrer-Policy of a security headers profile
+	DefaultSecurityHeadersReferrerPolicy = "strict-origin-when-cross-origin"
+	// DefaultPrometheusLookbackDeltaSecs is the default lookback delta of a Prometheus origin
+	DefaultPrometheusLookbackDeltaSecs = 300
+	// DefaultVictoriaMetricsLatencyOffsetSecs is the default -search.latencyOffset of a
+	// VictoriaMetrics origin, within which the origin omits the newest datapoints of a range query
+	DefaultVictoriaMetricsLatencyOffsetSecs = 30
+	// DefaultVictoriaMetricsDenyPartialResponse is the default setting for requesting that a
+	// VictoriaMetrics cluster origin fail a query, rather than respond with partial results
+	DefaultVictoriaMetricsDenyPartialResponse = true
+	// DefaultGraphiteStepSecs is the default seconds per point of the finest retention of a Graphite origin
+	DefaultGraphiteStepSecs = 60
+	// DefaultOpenTSDBStepSecs is the default step of an OpenTSDB origin's queries that are not downsampled
+	DefaultOpenTSDBStepSecs = 60
+	// DefaultDerivedQueryStepSecs is the default step of a Prometheus derived query
+	DefaultDerivedQueryStepSecs = 60
+	// DefaultDerivedQueryRangeSecs is the default time range, ending now, kept warm by a derived query
+	DefaultDerivedQueryRangeSecs = 21600
+	// DefaultDerivedQueryIntervalSecs is the default evaluation interval of a derived query
+	DefaultDerivedQueryIntervalSecs = 60
+	// DefaultRemoteWriteMaxBufferedRequests is the default number of remote_write requests that
+	// are buffered for an origin before further requests are rejected
+	DefaultRemoteWriteMaxBufferedRequests = 10000
+	// DefaultRemoteWriteMaxBatchRequests is the default number of buffered remote_write requests
+	// that are combined into each request to the origin
+	DefaultRemoteWriteMaxBatchRequests = 50
+	// DefaultRemoteWriteFlushIntervalMS is the default interval at which buffered remote_write
+	// requests are forwarded to the origin
+	DefaultRemoteWriteFlushIntervalMS = 1000
+	// DefaultRemoteWriteMinBackoffMS is the default initial delay before a failed remote_write
+	// forward is retried
+	DefaultRemoteWriteMinBackoffMS = 100
+	// DefaultRemoteWriteMaxBackoffMS is the default maximum delay between retries of a failed
+	// remote_write forward
+	DefaultRemoteWriteMaxBackoffMS = 30000
+	// DefaultRemoteWriteWALTTLSecs is the default retention of remote_write requests written
+	// to the cache before they are forwarded
+	DefaultRemoteWriteWALTTLSecs = 7200
+	// DefaultRelabelSeparator is the default separator of the joined source label values of a relabel rule
+	DefaultRelabelSeparator = ";"
+	// DefaultRelabelRegex is the default regular expression of a relabel rule
+	DefaultRelabelRegex = "(.*)"
 )
 
 // DefaultCompressableTypes returns a list of types that Trickster should compress before caching
@@ -155,3 +493,40 @@ func DefaultCompressableTypes() []string {
 		"application/xml",
 	}
 }
+
+// DefaultSLOBurnRateWindowsSecs returns the default windows over which SLO burn rates are reported
+func DefaultSLOBurnRateWindowsSecs() []int {
+	return []int{300, 3600, 21600}
+}
+
+// DefaultCaptureRedactHeaders returns the list of headers whose values are redacted
+// from origin captures unless the capture config overrides the list
+func DefaultCaptureRedactHeaders() []string {
+	return []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+}
+
+// DefaultCaptureRedactParams returns the list of URL query parameters whose values are
+// redacted from origin captures unless the capture config overrides the list
+func DefaultCaptureRedactParams() []string {
+	return []string{"u", "p", "password", "token", "access_token", "api_key", "apikey"}
+}
+
+// DefaultAuthorizerRequestHeaders returns the list of client request headers that are passed
+// to an external authorization service unless the authorizer config overrides the list
+func DefaultAuthorizerRequestHeaders() []string {
+	return []string{"Authorization", "Cookie"}
+}
+
+// DefaultAuthorizerClientHeaders returns the list of authorization service response headers that
+// are passed to the client on a denied request unless the authorizer config overrides the list
+func DefaultAuthorizerClientHeaders() []string {
+	return []string{"WWW-Authenticate", "Location", "Content-Type"}
+}
+
+// DefaultUpstreamHeaderDenyList returns the list of client request headers that are not forwarded
+// to time series origins unless the origin config explicitly overrides the list. These are the
+// client's credentials, and Trickster's own headers, which include the client identity headers
+// of the external authorizer. X-Trickster-Hops is always forwarded for loop detection
+func DefaultUpstreamHeaderDenyList() []string {
+	return []string{"Cookie", "Authorization", "Proxy-Authorization", "X-Trickster-*"}
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"sort"
+
+	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DiffSection lists the names of the items of a configuration section that were added,
+// removed or changed between two configurations
+type DiffSection struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Diff is a structured summary of the differences between two configurations
+type Diff struct {
+	// Origins lists the origins that were added, removed or changed, apart from their paths
+	Origins DiffSection
+	// Caches lists the caches that were added, removed or changed
+	Caches DiffSection
+	// Paths lists the paths that were added, removed or changed, as origin:path, for the
+	// origins in both configurations
+	Paths DiffSection
+	// Sections lists the other top-level sections of the configuration that were changed
+	Sections []string
+}
+
+// diffSections are the top-level sections, besides origins and caches, that are compared
+var diffSections = []struct {
+	name string
+	get  func(*Config) interface{}
+}{
+	{"main", func(c *Config) interface{} { return c.Main }},
+	{"frontend", func(c *Config) interface{} { return c.Frontend }},
+	{"logging", func(c *Config) interface{} { return c.Logging }},
+	{"metrics", func(c *Config) interface{} { return c.Metrics }},
+	{"tracing", func(c *Config) interface{} { return c.TracingConfigs }},
+	{"negative_caches", func(c *Config) interface{} { return c.NegativeCacheConfigs }},
+	{"rules", func(c *Config) interface{} { return c.Rules }},
+	{"request_rewriters", func(c *Config) interface{} { return c.RequestRewriters }},
+	{"reloading", func(c *Config) interface{} { return c.ReloadConfig }},
+	{"memory", func(c *Config) interface{} { return c.Memory }},
+	{"cluster", func(c *Config) interface{} { return c.Cluster }},
+	{"invalidation", func(c *Config) interface{} { return c.Invalidation }},
+	{"security_headers", func(c *Config) interface{} { return c.SecurityHeaders }},
+	{"authorizers", func(c *Config) interface{} { return c.Authorizers }},
+	{"tls_policies", func(c *Config) interface{} { return c.TLSPolicies }},
+	{"acme", func(c *Config) interface{} { return c.ACME }},
+	{"feature_flags", func(c *Config) interface{} { return c.FeatureFlags }},
+}
+
+// Diff returns the differences between the subject configuration and the provided
+// previous configuration. Items are compared by their TOML encoding, so only settings
+// that can be configured are compared
+func (c *Config) Diff(old *Config) *Diff {
+	d := &Diff{}
+	if old == nil {
+		return d
+	}
+
+	oldOrigins := make(map[string]string, len(old.Origins))
+	for k, v := range old.Origins {
+		oldOrigins[k] = encodeOrigin(v)
+	}
+	newOrigins := make(map[string]string, len(c.Origins))
+	for k, v := range c.Origins {
+		newOrigins[k] = encodeOrigin(v)
+	}
+	d.Origins = diffItems(oldOrigins, newOrigins)
+
+	oldCaches := make(map[string]string, len(old.Caches))
+	for k, v := range old.Caches {
+		oldCaches[k] = encodeTOML(v)
+	}
+	newCaches := make(map[string]string, len(c.Caches))
+	for k, v := range c.Caches {
+		newCaches[k] = encodeTOML(v)
+	}
+	d.Caches = diffItems(oldCaches, newCaches)
+
+	oldPaths := make(map[string]string)
+	newPaths := make(map[string]string)
+	for k, v := range c.Origins {
+		ov, ok := old.Origins[k]
+		if !ok || v == nil || ov == nil {
+			continue
+		}
+		for l, p := range ov.Paths {
+			oldPaths[k+":"+l] = encodePath(p)
+		}
+		for l, p := range v.Paths {
+			newPaths[k+":"+l] = encodePath(p)
+		}
+	}
+	d.Paths = diffItems(oldPaths, newPaths)
+
+	for _, s := range diffSections {
+		if encodeTOML(s.get(old)) != encodeTOML(s.get(c)) {
+			d.Sections = append(d.Sections, s.name)
+		}
+	}
+
+	return d
+}
+
+// IsEmpty returns true if the Diff has no differences
+func (d *Diff) IsEmpty() bool {
+	return d.Origins.isEmpty() && d.Caches.isEmpty() && d.Paths.isEmpty() &&
+		len(d.Sections) == 0
+}
+
+// Counts returns the number of added, removed and changed items, by section
+func (d *Diff) Counts() map[string]map[string]int {
+	m := map[string]map[string]int{
+		"origins": d.Origins.counts(),
+		"caches":  d.Caches.counts(),
+		"paths":   d.Paths.counts(),
+	}
+	for _, s := range diffSections {
+		m[s.name] = map[string]int{"changed": 0}
+	}
+	for _, s := range d.Sections {
+		m[s]["changed"] = 1
+	}
+	return m
+}
+
+func (s DiffSection) isEmpty() bool {
+	return len(s.Added) == 0 && len(s.Removed) == 0 && len(s.Changed) == 0
+}
+
+func (s DiffSection) counts() map[string]int {
+	return map[string]int{"added": len(s.Added), "removed": len(s.Removed),
+		"changed": len(s.Changed)}
+}
+
+// diffItems returns the sorted names of the items that were added, removed or changed
+// between the old and new encodings of a section
+func diffItems(old, new map[string]string) DiffSection {
+	s := DiffSection{}
+	for k, v := range new {
+		if ov, ok := old[k]; !ok {
+			s.Added = append(s.Added, k)
+		} else if ov != v {
+			s.Changed = append(s.Changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			s.Removed = append(s.Removed, k)
+		}
+	}
+	sort.Strings(s.Added)
+	sort.Strings(s.Removed)
+	sort.Strings(s.Changed)
+	return s
+}
+
+func encodeTOML(v interface{}) string {
+	var buf bytes.Buffer
+	toml.NewEncoder(&buf).Encode(v)
+	return buf.String()
+}
+
+// encodeOrigin encodes an origin without its paths, which are compared separately
+func encodeOrigin(oc *origins.Options) string {
+	if oc == nil {
+		return ""
+	}
+	o := oc.Clone()
+	o.Paths = nil
+	return encodeTOML(o)
+}
+
+// encodePath encodes a path without its handler, which the toml library can't encode
+func encodePath(pc *po.Options) string {
+	if pc == nil {
+		return ""
+	}
+	p := pc.Clone()
+	p.Handler = nil
+	p.KeyHasher = nil
+	return encodeTOML(p)
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+func TestDiff(t *testing.T) {
+
+	c1, _ := emptyTestConfig()
+	c2 := c1.Clone()
+
+	d := c2.Diff(c1)
+	if !d.IsEmpty() {
+		t.Errorf("expected empty diff got %v", d)
+	}
+
+	c2.Origins["added"] = oo.NewOptions()
+	c2.Origins["test"].TimeoutSecs = c1.Origins["test"].TimeoutSecs + 1
+	c2.Origins["test"].Paths["/new-GET"] = po.NewOptions()
+	delete(c2.Caches, "default")
+	c2.Logging.LogLevel = "debug"
+
+	d = c2.Diff(c1)
+	if d.IsEmpty() {
+		t.Fatal("expected non-empty diff")
+	}
+	if len(d.Origins.Added) != 1 || d.Origins.Added[0] != "added" {
+		t.Errorf("unexpected added origins %v", d.Origins.Added)
+	}
+	if len(d.Origins.Changed) != 1 || d.Origins.Changed[0] != "test" {
+		t.Errorf("unexpected changed origins %v", d.Origins.Changed)
+	}
+	if len(d.Caches.Removed) != 1 || d.Caches.Removed[0] != "default" {
+		t.Errorf("unexpected removed caches %v", d.Caches.Removed)
+	}
+	if len(d.Paths.Added) != 1 || d.Paths.Added[0] != "test:/new-GET" {
+		t.Errorf("unexpected added paths %v", d.Paths.Added)
+	}
+	if len(d.Sections) != 1 || d.Sections[0] != "logging" {
+		t.Errorf("unexpected changed sections %v", d.Sections)
+	}
+
+	counts := d.Counts()
+	if counts["origins"]["added"] != 1 || counts["origins"]["changed"] != 1 ||
+		counts["caches"]["removed"] != 1 || counts["logging"]["changed"] != 1 ||
+		counts["memory"]["changed"] != 0 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+
+	d = c2.Diff(nil)
+	if !d.IsEmpty() {
+		t.Errorf("expected empty diff got %v", d)
+	}
+}
@@ -25,6 +25,7 @@ import (
 const (
 	// Command-line flags
 	cfConfig      = "config"
+	cfConfigKey   = "config-public-key"
 	cfVersion     = "version"
 	cfValidate    = "validate-config"
 	cfLogLevel    = "log-level"
@@ -33,17 +34,20 @@ const (
 	cfOriginType  = "origin-type"
 	cfProxyPort   = "proxy-port"
 	cfMetricsPort = "metrics-port"
+	cfDevTLS      = "dev-tls"
 )
 
 // Flags holds the values for whitelisted flags
 type Flags struct {
 	PrintVersion      bool
 	ValidateConfig    bool
+	DevTLS            bool
 	customPath        bool
 	ProxyListenPort   int
 	MetricsListenPort int
 	InstanceID        int
 	ConfigPath        string
+	ConfigPublicKey   string
 	Origin            string
 	OriginType        string
 	LogLevel          string
@@ -59,7 +63,10 @@ func parseFlags(applicationName string, arguments []string) (*Flags, error) {
 	flagSet.BoolVar(&flags.ValidateConfig, cfValidate, false,
 		"Validates a Trickster config and exits without running the server")
 	flagSet.StringVar(&flags.ConfigPath, cfConfig, "",
-		"Path to Trickster Config File")
+		"Path to Trickster Config File, or the URL of a remote config source"+
+			" (http, https, s3, etcd, consul)")
+	flagSet.StringVar(&flags.ConfigPublicKey, cfConfigKey, "",
+		"Path to the Ed25519 public key that verifies the signature of a remote config")
 	flagSet.StringVar(&flags.LogLevel, cfLogLevel, "",
 		"Level of Logging to use (debug, info, warn, error)")
 	flagSet.IntVar(&flags.InstanceID, cfInstanceID, 0,
@@ -74,6 +81,9 @@ func parseFlags(applicationName string, arguments []string) (*Flags, error) {
 	flagSet.IntVar(&flags.MetricsListenPort, cfMetricsPort, 0,
 		"Port that the /metrics endpoint will listen on")
 
+	flagSet.BoolVar(&flags.DevTLS, cfDevTLS, false,
+		"Serves TLS using an in-memory self-signed certificate for localhost (development use only)")
+
 	err := flagSet.Parse(arguments)
 	if err != nil {
 		return nil, err
@@ -109,4 +119,10 @@ func (c *Config) loadFlags(flags *Flags) {
 	if flags.InstanceID > 0 {
 		c.Main.InstanceID = flags.InstanceID
 	}
+	if flags.DevTLS {
+		c.Frontend.DevTLS = true
+		c.Frontend.ServeTLS = true
+		c.LoaderWarnings = append(c.LoaderWarnings,
+			"dev-tls is enabled and serving a self-signed certificate; do not use in production")
+	}
 }
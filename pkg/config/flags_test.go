@@ -35,6 +35,7 @@ func TestLoadFlags(t *testing.T) {
 		"info",
 		"-instance-id",
 		"1",
+		"-dev-tls",
 	}
 
 	// it should read command line flags
@@ -56,4 +57,7 @@ func TestLoadFlags(t *testing.T) {
 	if c.Metrics.ListenPort != 9092 {
 		t.Errorf("wanted \"%d\". got \"%d\".", 9092, c.Metrics.ListenPort)
 	}
+	if !c.Frontend.DevTLS || !c.Frontend.ServeTLS {
+		t.Error("expected dev tls to be enabled")
+	}
 }
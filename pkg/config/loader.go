@@ -20,9 +20,11 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 )
 
 // Load returns the Application Configuration, starting with a default config,
@@ -75,16 +77,13 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		return nil, flags, errors.New("no valid origins configured")
 	}
 
+	negativeCaches := make(map[string]*negative.Cache, len(c.NegativeCacheConfigs))
 	for k, n := range c.NegativeCacheConfigs {
-		for c := range n {
-			ci, err := strconv.Atoi(c)
-			if err != nil {
-				return nil, flags, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
-			}
-			if ci < 400 || ci >= 600 {
-				return nil, flags, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
-			}
+		nc, err := negative.New(n)
+		if err != nil {
+			return nil, flags, fmt.Errorf(`invalid negative cache config in %s: %v`, k, err)
 		}
+		negativeCaches[k] = nc
 	}
 
 	for k, o := range c.Origins {
@@ -112,11 +111,34 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		o.PathPrefix = url.Path
 		o.Timeout = time.Duration(o.TimeoutSecs) * time.Second
 		o.BackfillTolerance = time.Duration(o.BackfillToleranceSecs) * time.Second
+		o.GapFillMax = time.Duration(o.GapFillMaxSecs) * time.Second
+		o.ClockSkewTolerance = time.Duration(o.ClockSkewToleranceMS) * time.Millisecond
 		o.TimeseriesRetention = time.Duration(o.TimeseriesRetentionFactor)
 		o.TimeseriesTTL = time.Duration(o.TimeseriesTTLSecs) * time.Second
 		o.FastForwardTTL = time.Duration(o.FastForwardTTLSecs) * time.Second
 		o.MaxTTL = time.Duration(o.MaxTTLSecs) * time.Second
 
+		if o.DNS != nil {
+			o.DNS.CacheTTL = time.Duration(o.DNS.CacheTTLSecs) * time.Second
+			o.DNS.NegativeTTL = time.Duration(o.DNS.NegativeTTLSecs) * time.Second
+		}
+
+		if o.Prometheus != nil {
+			o.Prometheus.SetDurations()
+		}
+
+		if o.VictoriaMetrics != nil {
+			o.VictoriaMetrics.SetDurations()
+		}
+
+		if o.Graphite != nil {
+			o.Graphite.SetDurations()
+		}
+
+		if o.OpenTSDB != nil {
+			o.OpenTSDB.SetDurations()
+		}
+
 		if o.CompressableTypeList != nil {
 			o.CompressableTypes = make(map[string]bool)
 			for _, v := range o.CompressableTypeList {
@@ -124,21 +146,35 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 			}
 		}
 
+		o.UpstreamHeaderFilter = headers.NewFilter(o.UpstreamHeaderAllowList, o.UpstreamHeaderDenyList)
+
 		if o.CacheKeyPrefix == "" {
-			o.CacheKeyPrefix = o.Host
+			// origins with a cache_quota or their own bbolt bucket are namespaced by name,
+			// since several of them may share a host
+			if o.CacheQuota.Enabled() || c.bucketPerOrigin(o.CacheName) {
+				o.CacheKeyPrefix = k
+			} else {
+				o.CacheKeyPrefix = o.Host
+			}
 		}
 
-		nc, ok := c.NegativeCacheConfigs[o.NegativeCacheName]
+		nc, ok := negativeCaches[o.NegativeCacheName]
 		if !ok {
 			return nil, flags, fmt.Errorf(`invalid negative cache name: %s`, o.NegativeCacheName)
 		}
+		o.NegativeCache = nc.Clone()
 
-		nc2 := map[int]time.Duration{}
-		for c, s := range nc {
-			ci, _ := strconv.Atoi(c)
-			nc2[ci] = time.Duration(s) * time.Second
+		for _, p := range o.Paths {
+			if p.NegativeCacheName == "" {
+				continue
+			}
+			nc, ok := negativeCaches[p.NegativeCacheName]
+			if !ok {
+				return nil, flags, fmt.Errorf(`invalid negative cache name %s in path %s of origin config %s`,
+					p.NegativeCacheName, p.Path, k)
+			}
+			p.NegativeCache = nc.Clone()
 		}
-		o.NegativeCache = nc2
 
 		// enforce MaxTTL
 		if o.TimeseriesTTLSecs > o.MaxTTLSecs {
@@ -153,9 +189,19 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		}
 	}
 
+	if err := c.processCacheQuotas(); err != nil {
+		return nil, flags, err
+	}
+
+	if err := c.processOriginBuckets(); err != nil {
+		return nil, flags, err
+	}
+
 	for _, c := range c.Caches {
 		c.Index.FlushInterval = time.Duration(c.Index.FlushIntervalSecs) * time.Second
 		c.Index.ReapInterval = time.Duration(c.Index.ReapIntervalSecs) * time.Second
+		c.Index.ForecastInterval = time.Duration(c.Index.ForecastIntervalSecs) * time.Second
+		c.Index.AccessFlushInterval = time.Duration(c.Index.AccessFlushIntervalMS) * time.Millisecond
 	}
 
 	return c, flags, nil
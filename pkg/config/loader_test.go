@@ -17,7 +17,11 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
@@ -26,6 +30,7 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tlstest "github.com/tricksterproxy/trickster/pkg/util/testing/tls"
 )
 
@@ -89,6 +94,154 @@ func TestLoadConfigurationFileFailures(t *testing.T) {
 			"../../testdata/test.invalid-pcf-name.conf",
 			`invalid collapsed_forwarding name: INVALID`,
 		},
+		{ // Case 8
+			"../../testdata/test.invalid-cache-ttl-clamp.conf",
+			`MinTTLSecs can't be larger than MaxTTLSecs`,
+		},
+		{ // Case 9
+			"../../testdata/test.invalid-cache-sync-mode.conf",
+			`invalid filesystem sync_mode`,
+		},
+		{ // Case 10
+			"../../testdata/test.invalid-cluster-self.conf",
+			`cluster self must be one of the cluster peers`,
+		},
+		{ // Case 11
+			"../../testdata/test.invalid-cluster-discovery-dns.conf",
+			`cluster discovery_dns must be in host:port format`,
+		},
+		{ // Case 12
+			"../../testdata/test.invalid-cache-tiered.conf",
+			`tiered l2_cache_type must be one of 'filesystem', 'bbolt', 'badger', 'logstore', 'redis', 'memcached', 'cassandra', 's3', 'dynamodb', 'gcs' or 'azureblob'`,
+		},
+		{ // Case 13
+			"../../testdata/test.invalid-clock-skew-tolerance.conf",
+			`clock_skew_tolerance_ms can't be negative in origin config test`,
+		},
+		{ // Case 14
+			"../../testdata/test.invalid-cache-compression-codec.conf",
+			`compression_codec must be one of 'snappy', 'gzip' or 'none': lz4`,
+		},
+		{ // Case 15
+			"../../testdata/test.invalid-cache-encryption.conf",
+			`invalid encryption config in cache test: encryption active_key_id must be one of the configured keys`,
+		},
+		{ // Case 16
+			"../../testdata/test.invalid-cache-encryption-memory.conf",
+			`encryption is not supported by the memory cache test`,
+		},
+		{ // Case 17
+			"../../testdata/test.invalid-cache-dynamodb.conf",
+			`dynamodb table must be provided`,
+		},
+		{ // Case 18
+			"../../testdata/test.invalid-heatmap.conf",
+			`heatmap window_secs must be positive in origin config test`,
+		},
+		{ // Case 19
+			"../../testdata/test.invalid-cache-gcs.conf",
+			`gcs bucket must be provided`,
+		},
+		{ // Case 20
+			"../../testdata/test.invalid-cache-azureblob.conf",
+			`azure blob container must be provided`,
+		},
+		{ // Case 21
+			"../../testdata/test.invalid-cache-warming.conf",
+			`invalid warming config in cache test: origin other does not use the cache`,
+		},
+		{ // Case 22
+			"../../testdata/test.invalid-feature-flag.conf",
+			`invalid feature flag fast_forward: origin other is not configured`,
+		},
+		{ // Case 23
+			"../../testdata/test.invalid-template-param.conf",
+			`undefined template parameter host in origin config test`,
+		},
+		{ // Case 24
+			"../../testdata/test.invalid-cache-eviction-policy.conf",
+			`invalid eviction_policy: mru in cache default`,
+		},
+		{ // Case 25
+			"../../testdata/test.invalid-metrics-authorizer.conf",
+			`invalid authorizer name [other] provided in metrics config`,
+		},
+		{ // Case 26
+			"../../testdata/test.invalid-cache-redis-pipeline.conf",
+			`invalid pipeline_batch_size in cache default: must be greater than 0`,
+		},
+		{ // Case 27
+			"../../testdata/test.invalid-cache-memcached.conf",
+			`memcached servers must be host:port addresses`,
+		},
+		{ // Case 28
+			"../../testdata/test.invalid-cache-quota.conf",
+			`cache_quota requires a memory, filesystem or bbolt cache in origin config test`,
+		},
+		{ // Case 29
+			"../../testdata/test.invalid-prometheus-api.conf",
+			`prometheus_api requires an influxdb or clickhouse origin in origin config test`,
+		},
+		{ // Case 30
+			"../../testdata/test.invalid-prometheus-remote-write.conf",
+			`remote_write min_backoff_ms must be positive, and must not exceed max_backoff_ms in origin config test`,
+		},
+		{ // Case 31
+			"../../testdata/test.invalid-prometheus-relabel.conf",
+			`invalid action 'replace' for relabel rule invalid in origin config test`,
+		},
+		{ // Case 32
+			"../../testdata/test.invalid-cache-access-queue.conf",
+			`invalid access_batch_size: 0 in cache default`,
+		},
+		{ // Case 33
+			"../../testdata/test.invalid-badger-gc-ratio.conf",
+			`badger value_log_gc_ratio must be greater than 0 and less than 1 in cache default`,
+		},
+		{ // Case 34
+			"../../testdata/test.invalid-gap-fill.conf",
+			`invalid gap_fill: interpolate in origin config test`,
+		},
+		{ // Case 35
+			"../../testdata/test.invalid-bbolt-bucket-prefix.conf",
+			`bbolt bucket for cache key prefix [shared] is used by another origin in origin config test2`,
+		},
+		{ // Case 36
+			"../../testdata/test.invalid-negative-cache-jitter.conf",
+			`invalid negative cache config in default: jitter_pct must be between 0 and 100`,
+		},
+		{ // Case 37
+			"../../testdata/test.invalid-path-negative-cache-name.conf",
+			`invalid negative cache name foo in path /series of origin config default`,
+		},
+		{ // Case 38
+			"../../testdata/test.invalid-cache-etcd.conf",
+			`etcd endpoints must be http or https URLs`,
+		},
+		{ // Case 39
+			"../../testdata/test.invalid-cache-cassandra.conf",
+			`cassandra read_consistency must not be 'any'`,
+		},
+		{ // Case 40
+			"../../testdata/test.invalid-victoriametrics.conf",
+			`victoriametrics latency_offset_secs must not be negative in origin config test`,
+		},
+		{ // Case 41
+			"../../testdata/test.invalid-graphite.conf",
+			`graphite step_secs must be greater than 0 in origin config test`,
+		},
+		{ // Case 42
+			"../../testdata/test.invalid-cache-logstore.conf",
+			`logstore compact_dead_ratio must be greater than 0 and less than 1 in cache test`,
+		},
+		{ // Case 43
+			"../../testdata/test.invalid-cache-migration.conf",
+			`migration old_cache_type and new_cache_type must be different in cache test`,
+		},
+		{ // Case 44
+			"../../testdata/test.invalid-opentsdb.conf",
+			`opentsdb step_secs must be greater than 0 in origin config test`,
+		},
 	}
 
 	for i, test := range tests {
@@ -147,6 +300,18 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 38821, got %d", conf.Frontend.TLSListenPort)
 	}
 
+	if len(conf.Frontend.ListenAddresses) != 2 || conf.Frontend.ListenAddresses[1] != "::1" {
+		t.Errorf("expected %v, got %v", []string{"127.0.0.1", "::1"}, conf.Frontend.ListenAddresses)
+	}
+
+	if len(conf.Frontend.TLSListenAddresses) != 1 || conf.Frontend.TLSListenAddresses[0] != "::1" {
+		t.Errorf("expected %v, got %v", []string{"::1"}, conf.Frontend.TLSListenAddresses)
+	}
+
+	if conf.Frontend.AddressFamily != "tcp6" {
+		t.Errorf("expected tcp6, got %s", conf.Frontend.AddressFamily)
+	}
+
 	// Test Metrics Server
 	if conf.Metrics.ListenPort != 57822 {
 		t.Errorf("expected 57821, got %d", conf.Metrics.ListenPort)
@@ -156,6 +321,26 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test, got %s", conf.Metrics.ListenAddress)
 	}
 
+	if conf.Metrics.AddressFamily != "tcp4" {
+		t.Errorf("expected tcp4, got %s", conf.Metrics.AddressFamily)
+	}
+
+	if !conf.Metrics.ServeTLS() {
+		t.Errorf("expected metrics tls")
+	}
+
+	if conf.Metrics.TLSPolicy == nil || conf.Metrics.TLSPolicy.Name != "test" {
+		t.Errorf("expected metrics tls policy test")
+	}
+
+	if conf.Metrics.Authorizer == nil || conf.Metrics.Authorizer.Name != "test" {
+		t.Errorf("expected metrics authorizer test")
+	}
+
+	if !conf.Metrics.ServeAdmin || !conf.Metrics.FrontendAdminDisabled {
+		t.Errorf("expected metrics admin routes")
+	}
+
 	// Test Logging
 	if conf.Logging.LogLevel != "test_log_level" {
 		t.Errorf("expected test_log_level, got %s", conf.Logging.LogLevel)
@@ -165,6 +350,90 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test_file, got %s", conf.Logging.LogFile)
 	}
 
+	// Test Memory
+	if conf.Memory.LimitBytes != 536870912 {
+		t.Errorf("expected %d, got %d", 536870912, conf.Memory.LimitBytes)
+	}
+
+	if conf.Memory.GCPercent != 50 {
+		t.Errorf("expected %d, got %d", 50, conf.Memory.GCPercent)
+	}
+
+	if conf.Memory.WatermarkRatio != 0.75 {
+		t.Errorf("expected %f, got %f", 0.75, conf.Memory.WatermarkRatio)
+	}
+
+	if conf.Memory.CheckInterval != 250*time.Millisecond {
+		t.Errorf("expected %s, got %s", 250*time.Millisecond, conf.Memory.CheckInterval)
+	}
+
+	if conf.Memory.CgroupLimitRatio != d.DefaultMemoryCgroupLimitRatio {
+		t.Errorf("expected %f, got %f", d.DefaultMemoryCgroupLimitRatio, conf.Memory.CgroupLimitRatio)
+	}
+
+	// Test Feature Flags
+	if ff, ok := conf.FeatureFlags["fast_json_decode"]; !ok {
+		t.Error("expected fast_json_decode feature flag")
+	} else if !ff.Enabled || ff.Percentage != 25 || len(ff.Origins) != 1 {
+		t.Errorf("unexpected feature flag %v", ff)
+	}
+
+	if ff, ok := conf.FeatureFlags["fast_forward"]; !ok {
+		t.Error("expected fast_forward feature flag")
+	} else if ff.Enabled || ff.Percentage != 100 {
+		t.Errorf("unexpected feature flag %v", ff)
+	}
+
+	// Test Cluster
+	if conf.Cluster.Self != "http://trickster-0.example.com:8480" {
+		t.Errorf("expected %s, got %s", "http://trickster-0.example.com:8480", conf.Cluster.Self)
+	}
+
+	if len(conf.Cluster.Peers) != 2 {
+		t.Errorf("expected %d, got %d", 2, len(conf.Cluster.Peers))
+	}
+
+	if conf.Cluster.VirtualNodes != 64 {
+		t.Errorf("expected %d, got %d", 64, conf.Cluster.VirtualNodes)
+	}
+
+	if conf.Cluster.Timeout != 30*time.Second {
+		t.Errorf("expected %s, got %s", 30*time.Second, conf.Cluster.Timeout)
+	}
+
+	if conf.Cluster.Failover != 5*time.Second {
+		t.Errorf("expected %s, got %s", 5*time.Second, conf.Cluster.Failover)
+	}
+
+	if conf.Cluster.GossipInterval != 2*time.Second {
+		t.Errorf("expected %s, got %s", 2*time.Second, conf.Cluster.GossipInterval)
+	}
+
+	if conf.Cluster.MemberTimeout != 20*time.Second {
+		t.Errorf("expected %s, got %s", 20*time.Second, conf.Cluster.MemberTimeout)
+	}
+
+	if conf.Cluster.Discovery() != "static" {
+		t.Errorf("expected %s, got %s", "static", conf.Cluster.Discovery())
+	}
+
+	// Test Invalidation
+	if conf.Invalidation.SharedSecret != "test_invalidation_secret" {
+		t.Errorf("expected %s, got %s", "test_invalidation_secret", conf.Invalidation.SharedSecret)
+	}
+
+	if conf.Invalidation.MaxSkew != time.Minute {
+		t.Errorf("expected %s, got %s", time.Minute, conf.Invalidation.MaxSkew)
+	}
+
+	if conf.Main.InvalidationHandlerPath != d.DefaultInvalidationHandlerPath {
+		t.Errorf("expected %s, got %s", d.DefaultInvalidationHandlerPath, conf.Main.InvalidationHandlerPath)
+	}
+
+	if conf.Main.HeatmapHandlerPath != d.DefaultHeatmapHandlerPath {
+		t.Errorf("expected %s, got %s", d.DefaultHeatmapHandlerPath, conf.Main.HeatmapHandlerPath)
+	}
+
 	// Test Origins
 
 	o, ok := conf.Origins["test"]
@@ -177,6 +446,14 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test_type, got %s", o.OriginType)
 	}
 
+	if o.UpstreamType != "test_upstream_type" {
+		t.Errorf("expected test_upstream_type, got %s", o.UpstreamType)
+	}
+
+	if o.MaxHops != 4 {
+		t.Errorf("expected %d, got %d", 4, o.MaxHops)
+	}
+
 	if o.CacheName != "test" {
 		t.Errorf("expected test, got %s", o.CacheName)
 	}
@@ -209,6 +486,34 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 301, got %d", o.BackfillToleranceSecs)
 	}
 
+	if o.GapFill != timeseries.GapFillCarryForward {
+		t.Errorf("expected %s, got %s", timeseries.GapFillCarryForward, o.GapFill)
+	}
+
+	if o.GapFillMax != 2*time.Minute {
+		t.Errorf("expected %s, got %s", 2*time.Minute, o.GapFillMax)
+	}
+
+	if o.RevisionHeader != "X-Data-Revision" {
+		t.Errorf("expected %s, got %s", "X-Data-Revision", o.RevisionHeader)
+	}
+
+	if !o.ExplainHeaderEnabled {
+		t.Errorf("expected %t, got %t", true, o.ExplainHeaderEnabled)
+	}
+
+	if !o.RequestCollapsingEnabled {
+		t.Errorf("expected %t, got %t", true, o.RequestCollapsingEnabled)
+	}
+
+	if o.CacheGeneration != 3 {
+		t.Errorf("expected %d, got %d", 3, o.CacheGeneration)
+	}
+
+	if o.ClockSkewTolerance != 1500*time.Millisecond {
+		t.Errorf("expected %s, got %s", 1500*time.Millisecond, o.ClockSkewTolerance)
+	}
+
 	if o.TimeoutSecs != 37 {
 		t.Errorf("expected 37, got %d", o.TimeoutSecs)
 	}
@@ -259,136 +564,894 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test_client_key got %s", o.TLS.ClientKeyPath)
 	}
 
-	// Test Caches
+	if o.TLS.CABundlePath != "../../testdata/test.02.cert.pem" {
+		t.Errorf("expected ../../testdata/test.02.cert.pem got %s", o.TLS.CABundlePath)
+	}
+
+	if len(o.TLS.SPKIPins) != 1 {
+		t.Errorf("expected %d got %d", 1, len(o.TLS.SPKIPins))
+	}
+
+	if o.TLS.ServerName != "origin.example.com" {
+		t.Errorf("expected origin.example.com got %s", o.TLS.ServerName)
+	}
+
+	if o.TLS.Policy == nil || o.TLS.Policy.Name != "fips" {
+		t.Errorf("expected tls policy %s for origin %s", "fips", "test")
+	}
+
+	if o.DNS.CacheTTL != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.DNS.CacheTTL)
+	}
+
+	if o.DNS.NegativeTTLSecs != 2 {
+		t.Errorf("expected %d got %d", 2, o.DNS.NegativeTTLSecs)
+	}
+
+	if o.DNS.ReResolveOnError {
+		t.Errorf("expected false got %t", o.DNS.ReResolveOnError)
+	}
+
+	if !o.DNS.ServeStale {
+		t.Errorf("expected true got %t", o.DNS.ServeStale)
+	}
+
+	if o.DNS.Strategy != "round_robin" {
+		t.Errorf("expected round_robin got %s", o.DNS.Strategy)
+	}
+
+	if o.ErrorBudget.ErrorRateThreshold != 0.25 {
+		t.Errorf("expected %f got %f", 0.25, o.ErrorBudget.ErrorRateThreshold)
+	}
+
+	if o.ErrorBudget.LatencyThreshold != 1500*time.Millisecond {
+		t.Errorf("expected %s got %s", 1500*time.Millisecond, o.ErrorBudget.LatencyThreshold)
+	}
+
+	if o.ErrorBudget.Window != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.ErrorBudget.Window)
+	}
+
+	if o.ErrorBudget.MinRequests != 10 {
+		t.Errorf("expected %d got %d", 10, o.ErrorBudget.MinRequests)
+	}
+
+	if o.ErrorBudget.TTLStretchFactor != 2.5 {
+		t.Errorf("expected %f got %f", 2.5, o.ErrorBudget.TTLStretchFactor)
+	}
+
+	if o.ErrorBudget.MaxStretchedTTL != 10*time.Minute {
+		t.Errorf("expected %s got %s", 10*time.Minute, o.ErrorBudget.MaxStretchedTTL)
+	}
+
+	if o.SLO.HitRatioTarget != 0.95 {
+		t.Errorf("expected %f got %f", 0.95, o.SLO.HitRatioTarget)
+	}
+
+	if o.SLO.LatencyTarget != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.SLO.LatencyTarget)
+	}
+
+	if o.SLO.LatencyObjective != 0.999 {
+		t.Errorf("expected %f got %f", 0.999, o.SLO.LatencyObjective)
+	}
+
+	if o.SLO.Period != 7*24*time.Hour {
+		t.Errorf("expected %s got %s", 7*24*time.Hour, o.SLO.Period)
+	}
+
+	if len(o.SLO.BurnRateWindows) != 2 || o.SLO.BurnRateWindows[1] != time.Hour {
+		t.Errorf("unexpected burn rate windows %v", o.SLO.BurnRateWindows)
+	}
+
+	if o.Heatmap.MaxSamples != 500 {
+		t.Errorf("expected %d got %d", 500, o.Heatmap.MaxSamples)
+	}
+
+	if o.Heatmap.Window != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, o.Heatmap.Window)
+	}
+
+	if !o.QuarantinePanicCacheKeys {
+		t.Errorf("expected %t got %t", true, o.QuarantinePanicCacheKeys)
+	}
+
+	if o.PanicRecoveryDisabled {
+		t.Errorf("expected %t got %t", false, o.PanicRecoveryDisabled)
+	}
+
+	if !o.FastJSONDecode {
+		t.Errorf("expected %t got %t", true, o.FastJSONDecode)
+	}
+
+	if o.FastJSONMinBytes != 2048 {
+		t.Errorf("expected %d got %d", 2048, o.FastJSONMinBytes)
+	}
+
+	if o.Capture.Path != "/tmp/trickster-test.capture.jsonl" {
+		t.Errorf("expected %s got %s", "/tmp/trickster-test.capture.jsonl", o.Capture.Path)
+	}
+
+	if len(o.Capture.RedactHeaders) != 2 || o.Capture.RedactHeaders[1] != "X-Test-Secret" {
+		t.Errorf("unexpected redact headers %v", o.Capture.RedactHeaders)
+	}
+
+	if len(o.Capture.RedactParams) != 1 || o.Capture.RedactParams[0] != "api_key" {
+		t.Errorf("unexpected redact params %v", o.Capture.RedactParams)
+	}
+
+	if !o.Compression.Enabled {
+		t.Errorf("expected %t got %t", true, o.Compression.Enabled)
+	}
+
+	if o.Compression.Level != 3 {
+		t.Errorf("expected %d got %d", 3, o.Compression.Level)
+	}
+
+	if o.Compression.MinSizeBytes != 2048 {
+		t.Errorf("expected %d got %d", 2048, o.Compression.MinSizeBytes)
+	}
+
+	if o.Compression.ParallelMinSizeBytes != 0 {
+		t.Errorf("expected %d got %d", 0, o.Compression.ParallelMinSizeBytes)
+	}
+
+	if o.Retention.Window != 30*24*time.Hour {
+		t.Errorf("expected %s got %s", 30*24*time.Hour, o.Retention.Window)
+	}
+
+	if o.Retention.TrimInterval != 600*time.Second {
+		t.Errorf("expected %s got %s", 600*time.Second, o.Retention.TrimInterval)
+	}
+
+	if len(o.Replication.Peers) != 1 || o.Replication.Peers[0] != "https://trickster.peer.example.com:8480" {
+		t.Errorf("unexpected replication peers %v", o.Replication.Peers)
+	}
+
+	if o.Replication.SharedSecret != "test-secret" {
+		t.Errorf("expected %s got %s", "test-secret", o.Replication.SharedSecret)
+	}
+
+	if o.Replication.Interval != time.Second {
+		t.Errorf("expected %s got %s", time.Second, o.Replication.Interval)
+	}
+
+	if o.Replication.Timeout != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, o.Replication.Timeout)
+	}
+
+	if o.Replication.MaxPending != 500 {
+		t.Errorf("expected %d got %d", 500, o.Replication.MaxPending)
+	}
+
+	if o.Ingest.Source != "kafka" {
+		t.Errorf("expected %s got %s", "kafka", o.Ingest.Source)
+	}
+
+	if len(o.Ingest.Brokers) != 2 || o.Ingest.Brokers[1] != "kafka-2.example.com:9092" {
+		t.Errorf("unexpected ingest brokers %v", o.Ingest.Brokers)
+	}
+
+	if o.Ingest.Topic != "trickster-ingest" {
+		t.Errorf("expected %s got %s", "trickster-ingest", o.Ingest.Topic)
+	}
+
+	if o.Ingest.ClientID != "test-client" {
+		t.Errorf("expected %s got %s", "test-client", o.Ingest.ClientID)
+	}
+
+	if o.Ingest.Username != "test-user" {
+		t.Errorf("expected %s got %s", "test-user", o.Ingest.Username)
+	}
+
+	if o.Ingest.Password != "test-password" {
+		t.Errorf("expected %s got %s", "test-password", o.Ingest.Password)
+	}
+
+	if o.Ingest.QoS != 0 {
+		t.Errorf("expected %d got %d", 0, o.Ingest.QoS)
+	}
+
+	if o.Ingest.StartOffset != "earliest" {
+		t.Errorf("expected %s got %s", "earliest", o.Ingest.StartOffset)
+	}
+
+	if o.Ingest.ReconnectInterval != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, o.Ingest.ReconnectInterval)
+	}
+
+	if o.Ingest.MaxMessageBytes != 1048576 {
+		t.Errorf("expected %d got %d", 1048576, o.Ingest.MaxMessageBytes)
+	}
+
+	if o.Prometheus.LookbackDelta != 10*time.Minute {
+		t.Errorf("expected %s got %s", 10*time.Minute, o.Prometheus.LookbackDelta)
+	}
+
+	if len(o.Prometheus.ReplicaLabels) != 2 || o.Prometheus.ReplicaLabels[1] != "prometheus_replica" {
+		t.Errorf("unexpected replica labels %v", o.Prometheus.ReplicaLabels)
+	}
+
+	if !o.Prometheus.RemoteReadCacheEnabled {
+		t.Errorf("expected %t got %t", true, o.Prometheus.RemoteReadCacheEnabled)
+	}
+
+	if o.VictoriaMetrics.LatencyOffset != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, o.VictoriaMetrics.LatencyOffset)
+	}
+
+	if o.VictoriaMetrics.DenyPartialResponse {
+		t.Errorf("expected %t got %t", false, o.VictoriaMetrics.DenyPartialResponse)
+	}
+
+	if o.Graphite.Step != 10*time.Second {
+		t.Errorf("expected %s got %s", 10*time.Second, o.Graphite.Step)
+	}
+
+	if o.OpenTSDB.Step != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.OpenTSDB.Step)
+	}
+
+	if p, ok := o.Paths["/series-GET-HEAD"]; !ok {
+		t.Errorf("unable to find path: %s", "/series")
+	} else if !p.DownstreamCachingHeaders {
+		t.Errorf("expected %t got %t", true, p.DownstreamCachingHeaders)
+	} else if len(p.CacheTags) != 2 || p.CacheTags[0] != "tenant-a" {
+		t.Errorf("unexpected cache tags %v", p.CacheTags)
+	} else if p.MaxObjectSizeBytes != 65536 {
+		t.Errorf("expected %d got %d", 65536, p.MaxObjectSizeBytes)
+	} else if p.NegativeCacheName != "errors" || p.NegativeCache == nil {
+		t.Errorf("expected negative cache %s got %s", "errors", p.NegativeCacheName)
+	} else if nc := p.NegativeCache; nc.TTLs[502] != time.Second || nc.DNSErrorTTL != 30*time.Second ||
+		nc.ConnectErrorTTL != 2*time.Second || nc.JitterPct != 10 {
+		t.Errorf("unexpected negative cache %v", nc)
+	}
+
+	if o.NegativeCache == nil || o.NegativeCache.TTLs[404] != 5*time.Second {
+		t.Errorf("unexpected negative cache %v", o.NegativeCache)
+	}
+
+	dq, ok := o.Prometheus.DerivedQueries["test"]
+	if !ok {
+		t.Errorf("unable to find derived query: %s", "test")
+	} else {
+		if dq.Query != "sum(up)" {
+			t.Errorf("expected %s got %s", "sum(up)", dq.Query)
+		}
+		if dq.Step != 30*time.Second {
+			t.Errorf("expected %s got %s", 30*time.Second, dq.Step)
+		}
+		if dq.RangeSecs != 3600 {
+			t.Errorf("expected %d got %d", 3600, dq.RangeSecs)
+		}
+		if dq.IntervalSecs != 60 {
+			t.Errorf("expected %d got %d", 60, dq.IntervalSecs)
+		}
+	}
+
+	if q, _ := o.Prometheus.RewriteQuery("sum(up)"); q != "job:up:sum" {
+		t.Errorf("expected %s got %s", "job:up:sum", q)
+	}
+
+	if len(o.UpstreamHeaderDenyList) != 2 {
+		t.Errorf("expected %d got %d", 2, len(o.UpstreamHeaderDenyList))
+	}
+
+	if o.UpstreamHeaderFilter.Allowed("authorization") {
+		t.Errorf("expected authorization header to be filtered")
+	}
+
+	if o.SecurityHeaders == nil {
+		t.Errorf("expected security headers profile %s", "test")
+	} else {
+		if v := o.SecurityHeaders.ResponseHeaders.Get("Strict-Transport-Security"); v !=
+			"max-age=31536000; includeSubDomains" {
+			t.Errorf("expected %s got %s", "max-age=31536000; includeSubDomains", v)
+		}
+		if v := o.SecurityHeaders.ResponseHeaders.Get("X-Content-Type-Options"); v != "nosniff" {
+			t.Errorf("expected %s got %s", "nosniff", v)
+		}
+		if v := o.SecurityHeaders.ResponseHeaders.Get("Referrer-Policy"); v != "no-referrer" {
+			t.Errorf("expected %s got %s", "no-referrer", v)
+		}
+	}
+
+	if o.Authorizer == nil {
+		t.Errorf("expected authorizer %s", "test")
+	} else {
+		if o.Authorizer.Timeout != 500*time.Millisecond {
+			t.Errorf("expected %s got %s", 500*time.Millisecond, o.Authorizer.Timeout)
+		}
+		if !o.Authorizer.FailureModeAllow {
+			t.Errorf("expected %t got %t", true, o.Authorizer.FailureModeAllow)
+		}
+		if o.Authorizer.DecisionCacheTTL != 30*time.Second {
+			t.Errorf("expected %s got %s", 30*time.Second, o.Authorizer.DecisionCacheTTL)
+		}
+		if len(o.Authorizer.UpstreamHeaders) != 1 || o.Authorizer.UpstreamHeaders[0] != "X-User" {
+			t.Errorf("unexpected upstream headers %v", o.Authorizer.UpstreamHeaders)
+		}
+		if len(o.Authorizer.RequestHeaders) != 2 {
+			t.Errorf("expected %d got %d", 2, len(o.Authorizer.RequestHeaders))
+		}
+	}
+
+	if conf.Frontend.SecurityHeadersName != "test" {
+		t.Errorf("expected %s got %s", "test", conf.Frontend.SecurityHeadersName)
+	}
+
+	if conf.Frontend.ForwardedHeadersPolicy != "sanitize" {
+		t.Errorf("expected %s got %s", "sanitize", conf.Frontend.ForwardedHeadersPolicy)
+	}
+
+	if len(conf.Frontend.TrustedProxyNets) != 2 {
+		t.Errorf("expected %d got %d", 2, len(conf.Frontend.TrustedProxyNets))
+	}
+
+	if conf.Frontend.IdentityMode != "trusted_headers" {
+		t.Errorf("expected %s got %s", "trusted_headers", conf.Frontend.IdentityMode)
+	}
+
+	if conf.Frontend.IdentityUserHeader != "X-Auth-Request-User" {
+		t.Errorf("expected %s got %s", "X-Auth-Request-User", conf.Frontend.IdentityUserHeader)
+	}
+
+	if conf.Frontend.IdentityGroupsHeader != "X-Forwarded-Groups" {
+		t.Errorf("expected %s got %s", "X-Forwarded-Groups", conf.Frontend.IdentityGroupsHeader)
+	}
+
+	if len(conf.Frontend.IdentityTrustedPeers) != 1 {
+		t.Errorf("expected %d got %d", 1, len(conf.Frontend.IdentityTrustedPeers))
+	}
+
+	if conf.Frontend.IdentityResolver == nil {
+		t.Error("expected non-nil identity resolver")
+	}
+
+	if conf.Frontend.TLSPolicy == nil || conf.Frontend.TLSPolicy.Name != "test" {
+		t.Errorf("expected frontend tls policy %s", "test")
+	} else if len(conf.Frontend.TLSPolicy.Curves) != 1 {
+		t.Errorf("expected %d got %d", 1, len(conf.Frontend.TLSPolicy.Curves))
+	}
+
+	// Test Caches
+
+	c, ok := conf.Caches["test"]
+	if !ok {
+		t.Errorf("unable to find cache config: %s", "test")
+		return
+	}
+
+	if c.CacheType != "redis" {
+		t.Errorf("expected redis, got %s", c.CacheType)
+	}
+
+	if c.Index.ReapIntervalSecs != 4 {
+		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
+	}
+
+	if c.Index.FlushIntervalSecs != 6 {
+		t.Errorf("expected 6, got %d", c.Index.FlushIntervalSecs)
+	}
+
+	if c.Index.ForecastInterval != 30*time.Second {
+		t.Errorf("expected %s, got %s", 30*time.Second, c.Index.ForecastInterval)
+	}
+
+	if c.Index.MaxSizeBytes != 536870913 {
+		t.Errorf("expected 536870913, got %d", c.Index.MaxSizeBytes)
+	}
+
+	if c.Index.MaxSizeBackoffBytes != 16777217 {
+		t.Errorf("expected 16777217, got %d", c.Index.MaxSizeBackoffBytes)
+	}
+
+	if c.Index.MaxSizeObjects != 80 {
+		t.Errorf("expected 80, got %d", c.Index.MaxSizeObjects)
+	}
+
+	if c.Index.MaxSizeBackoffObjects != 20 {
+		t.Errorf("expected 20, got %d", c.Index.MaxSizeBackoffObjects)
+	}
+
+	if c.Index.EvictionPolicy != "arc" {
+		t.Errorf("expected arc, got %s", c.Index.EvictionPolicy)
+	}
+
+	if c.Index.AccessQueueSize != 500 {
+		t.Errorf("expected 500, got %d", c.Index.AccessQueueSize)
+	}
+
+	if c.Index.AccessBatchSize != 50 {
+		t.Errorf("expected 50, got %d", c.Index.AccessBatchSize)
+	}
+
+	if c.Index.AccessFlushInterval != 250*time.Millisecond {
+		t.Errorf("expected %s, got %s", 250*time.Millisecond, c.Index.AccessFlushInterval)
+	}
+
+	if c.MaxTTL != 86400*time.Second {
+		t.Errorf("expected %s, got %s", 86400*time.Second, c.MaxTTL)
+	}
+
+	if c.MinTTL != 5*time.Second {
+		t.Errorf("expected %s, got %s", 5*time.Second, c.MinTTL)
+	}
+
+	if c.MaxObjectSizeBytes != 1048576 {
+		t.Errorf("expected %d, got %d", 1048576, c.MaxObjectSizeBytes)
+	}
+
+	if c.CompressionCodec != "snappy" {
+		t.Errorf("expected snappy, got %s", c.CompressionCodec)
+	}
+
+	if c.Encryption.ActiveKeyID != "k2" {
+		t.Errorf("expected k2, got %s", c.Encryption.ActiveKeyID)
+	}
+
+	if len(c.Encryption.Ciphers) != 2 {
+		t.Errorf("expected %d, got %d", 2, len(c.Encryption.Ciphers))
+	}
+
+	if c.Index.ReapIntervalSecs != 4 {
+		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
+	}
+
+	if c.Redis.ClientType != "test_redis_type" {
+		t.Errorf("expected test_redis_type, got %s", c.Redis.ClientType)
+	}
+
+	if c.Redis.Protocol != "test_protocol" {
+		t.Errorf("expected test_protocol, got %s", c.Redis.Protocol)
+	}
+
+	if c.Redis.Endpoint != "test_endpoint" {
+		t.Errorf("expected test_endpoint, got %s", c.Redis.Endpoint)
+	}
+
+	if c.Redis.SentinelMaster != "test_master" {
+		t.Errorf("expected test_master, got %s", c.Redis.SentinelMaster)
+	}
+
+	if c.Redis.Password != "test_password" {
+		t.Errorf("expected test_password, got %s", c.Redis.Password)
+	}
+
+	if c.Redis.DB != 42 {
+		t.Errorf("expected 42, got %d", c.Redis.DB)
+	}
+
+	if c.Redis.MaxRetries != 6 {
+		t.Errorf("expected 6, got %d", c.Redis.MaxRetries)
+	}
+
+	if c.Redis.MinRetryBackoffMS != 9 {
+		t.Errorf("expected 9, got %d", c.Redis.MinRetryBackoffMS)
+	}
+
+	if c.Redis.MaxRetryBackoffMS != 513 {
+		t.Errorf("expected 513, got %d", c.Redis.MaxRetryBackoffMS)
+	}
+
+	if c.Redis.DialTimeoutMS != 5001 {
+		t.Errorf("expected 5001, got %d", c.Redis.DialTimeoutMS)
+	}
+
+	if c.Redis.ReadTimeoutMS != 3001 {
+		t.Errorf("expected 3001, got %d", c.Redis.ReadTimeoutMS)
+	}
+
+	if c.Redis.WriteTimeoutMS != 3002 {
+		t.Errorf("expected 3002, got %d", c.Redis.WriteTimeoutMS)
+	}
+
+	if c.Redis.PoolSize != 21 {
+		t.Errorf("expected 21, got %d", c.Redis.PoolSize)
+	}
+
+	if c.Redis.MinIdleConns != 5 {
+		t.Errorf("expected 5, got %d", c.Redis.PoolSize)
+	}
+
+	if c.Redis.MaxConnAgeMS != 2000 {
+		t.Errorf("expected 2000, got %d", c.Redis.MaxConnAgeMS)
+	}
+
+	if c.Redis.PoolTimeoutMS != 4001 {
+		t.Errorf("expected 4001, got %d", c.Redis.PoolTimeoutMS)
+	}
+
+	if c.Redis.IdleTimeoutMS != 300001 {
+		t.Errorf("expected 300001, got %d", c.Redis.IdleTimeoutMS)
+	}
+
+	if c.Redis.IdleCheckFrequencyMS != 60001 {
+		t.Errorf("expected 60001, got %d", c.Redis.IdleCheckFrequencyMS)
+	}
+
+	if c.Redis.PipelineBatchSize != 250 {
+		t.Errorf("expected 250, got %d", c.Redis.PipelineBatchSize)
+	}
+
+	if c.Redis.ClientCacheMaxObjects != 1000 {
+		t.Errorf("expected 1000, got %d", c.Redis.ClientCacheMaxObjects)
+	}
+
+	if c.Redis.ClientCacheTTLMS != 2000 {
+		t.Errorf("expected 2000, got %d", c.Redis.ClientCacheTTLMS)
+	}
+
+	if c.Filesystem.CachePath != "test_cache_path" {
+		t.Errorf("expected test_cache_path, got %s", c.Filesystem.CachePath)
+	}
+
+	if c.Filesystem.SyncMode != "periodic" {
+		t.Errorf("expected periodic, got %s", c.Filesystem.SyncMode)
+	}
+
+	if c.Filesystem.FilePerm != 0640 {
+		t.Errorf("expected %s, got %s", os.FileMode(0640), c.Filesystem.FilePerm)
+	}
+
+	if c.Filesystem.DirPerm != 0750 {
+		t.Errorf("expected %s, got %s", os.FileMode(0750), c.Filesystem.DirPerm)
+	}
+
+	if c.Filesystem.SyncInterval != 500*time.Millisecond {
+		t.Errorf("expected %s, got %s", 500*time.Millisecond, c.Filesystem.SyncInterval)
+	}
+
+	if c.Filesystem.BatchMaxBytes != 4096 {
+		t.Errorf("expected 4096, got %d", c.Filesystem.BatchMaxBytes)
+	}
+
+	if c.Filesystem.BatchInterval != 20*time.Millisecond {
+		t.Errorf("expected %s, got %s", 20*time.Millisecond, c.Filesystem.BatchInterval)
+	}
+
+	if c.Filesystem.ReconcileMode != "always" {
+		t.Errorf("expected always, got %s", c.Filesystem.ReconcileMode)
+	}
+
+	if c.Warming.TopN != 50 || c.Warming.StatePath != "test_warming.json" ||
+		c.Warming.Concurrency != 2 {
+		t.Errorf("unexpected warming options %v", c.Warming)
+	}
+
+	if c.Warming.Interval != 600*time.Second {
+		t.Errorf("expected %s, got %s", 600*time.Second, c.Warming.Interval)
+	}
+
+	if len(c.Warming.Queries["test"]) != 1 {
+		t.Errorf("expected 1 warming query, got %d", len(c.Warming.Queries["test"]))
+	}
+
+	if c.BBolt.Filename != "test_filename" {
+		t.Errorf("expected test_filename, got %s", c.BBolt.Filename)
+	}
+
+	if c.BBolt.Bucket != "test_bucket" {
+		t.Errorf("expected test_bucket, got %s", c.BBolt.Bucket)
+	}
+
+	if !c.BBolt.BucketPerOrigin {
+		t.Errorf("expected %t got %t", true, c.BBolt.BucketPerOrigin)
+	}
+
+	if c.Badger.Directory != "test_directory" {
+		t.Errorf("expected test_directory, got %s", c.Badger.Directory)
+	}
+
+	if c.Badger.ValueDirectory != "test_value_directory" {
+		t.Errorf("expected test_value_directory, got %s", c.Badger.ValueDirectory)
+	}
+
+	if c.Badger.ValueLogGCInterval != time.Minute {
+		t.Errorf("expected %s, got %s", time.Minute, c.Badger.ValueLogGCInterval)
+	}
+
+	if c.Badger.ValueLogGCRatio != 0.7 {
+		t.Errorf("expected 0.7, got %f", c.Badger.ValueLogGCRatio)
+	}
+
+	if c.Badger.ValueLogFileSizeBytes != 134217728 {
+		t.Errorf("expected 134217728, got %d", c.Badger.ValueLogFileSizeBytes)
+	}
+
+	if c.S3.Endpoint != "http://minio:9000" {
+		t.Errorf("expected http://minio:9000, got %s", c.S3.Endpoint)
+	}
+
+	if c.S3.Bucket != "test_s3_bucket" {
+		t.Errorf("expected test_s3_bucket, got %s", c.S3.Bucket)
+	}
+
+	if c.S3.Prefix != "test_prefix/" {
+		t.Errorf("expected test_prefix/, got %s", c.S3.Prefix)
+	}
+
+	if c.S3.Region != "test-region" {
+		t.Errorf("expected test-region, got %s", c.S3.Region)
+	}
+
+	if c.S3.AccessKeyID != "test_access_key" {
+		t.Errorf("expected test_access_key, got %s", c.S3.AccessKeyID)
+	}
+
+	if c.S3.SecretAccessKey != "test_secret_key" {
+		t.Errorf("expected test_secret_key, got %s", c.S3.SecretAccessKey)
+	}
+
+	if c.S3.SessionToken != "test_session_token" {
+		t.Errorf("expected test_session_token, got %s", c.S3.SessionToken)
+	}
+
+	if !c.S3.PathStyle {
+		t.Errorf("expected %t got %t", true, c.S3.PathStyle)
+	}
+
+	if c.S3.PartSizeBytes != 10485760 {
+		t.Errorf("expected %d got %d", 10485760, c.S3.PartSizeBytes)
+	}
+
+	if c.S3.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.S3.Timeout)
+	}
+
+	if c.DynamoDB.Endpoint != "http://dynamodb:8000" {
+		t.Errorf("expected http://dynamodb:8000, got %s", c.DynamoDB.Endpoint)
+	}
+
+	if c.DynamoDB.Table != "test_dynamodb_table" {
+		t.Errorf("expected test_dynamodb_table, got %s", c.DynamoDB.Table)
+	}
+
+	if c.DynamoDB.Region != "test-region" {
+		t.Errorf("expected test-region, got %s", c.DynamoDB.Region)
+	}
+
+	if c.DynamoDB.KeyAttribute != "test_key" {
+		t.Errorf("expected test_key, got %s", c.DynamoDB.KeyAttribute)
+	}
+
+	if c.DynamoDB.TTLAttribute != "test_ttl" {
+		t.Errorf("expected test_ttl, got %s", c.DynamoDB.TTLAttribute)
+	}
+
+	if c.DynamoDB.AccessKeyID != "test_access_key" {
+		t.Errorf("expected test_access_key, got %s", c.DynamoDB.AccessKeyID)
+	}
+
+	if c.DynamoDB.SecretAccessKey != "test_secret_key" {
+		t.Errorf("expected test_secret_key, got %s", c.DynamoDB.SecretAccessKey)
+	}
+
+	if c.DynamoDB.SessionToken != "test_session_token" {
+		t.Errorf("expected test_session_token, got %s", c.DynamoDB.SessionToken)
+	}
+
+	if c.DynamoDB.ReadCapacityUnits != 50 {
+		t.Errorf("expected %d got %d", 50, c.DynamoDB.ReadCapacityUnits)
+	}
+
+	if c.DynamoDB.WriteCapacityUnits != 100 {
+		t.Errorf("expected %d got %d", 100, c.DynamoDB.WriteCapacityUnits)
+	}
+
+	if c.DynamoDB.BatchSize != 10 {
+		t.Errorf("expected %d got %d", 10, c.DynamoDB.BatchSize)
+	}
+
+	if c.DynamoDB.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.DynamoDB.Timeout)
+	}
+
+	if c.GCS.Endpoint != "http://fake-gcs:4443" {
+		t.Errorf("expected http://fake-gcs:4443, got %s", c.GCS.Endpoint)
+	}
+
+	if c.GCS.Bucket != "test_gcs_bucket" {
+		t.Errorf("expected test_gcs_bucket, got %s", c.GCS.Bucket)
+	}
+
+	if c.GCS.Prefix != "test_prefix/" {
+		t.Errorf("expected test_prefix/, got %s", c.GCS.Prefix)
+	}
+
+	if c.GCS.CredentialsFile != "test_credentials.json" {
+		t.Errorf("expected test_credentials.json, got %s", c.GCS.CredentialsFile)
+	}
+
+	if c.GCS.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.GCS.Timeout)
+	}
+
+	if c.AzureBlob.Endpoint != "http://azurite:10000/devstoreaccount1" {
+		t.Errorf("expected http://azurite:10000/devstoreaccount1, got %s", c.AzureBlob.Endpoint)
+	}
+
+	if c.AzureBlob.AccountName != "devstoreaccount1" {
+		t.Errorf("expected devstoreaccount1, got %s", c.AzureBlob.AccountName)
+	}
+
+	if c.AzureBlob.AccountKey != "test_account_key" {
+		t.Errorf("expected test_account_key, got %s", c.AzureBlob.AccountKey)
+	}
+
+	if c.AzureBlob.SASToken != "test_sas_token" {
+		t.Errorf("expected test_sas_token, got %s", c.AzureBlob.SASToken)
+	}
+
+	if c.AzureBlob.Container != "test_container" {
+		t.Errorf("expected test_container, got %s", c.AzureBlob.Container)
+	}
+
+	if c.AzureBlob.Prefix != "test_prefix/" {
+		t.Errorf("expected test_prefix/, got %s", c.AzureBlob.Prefix)
+	}
+
+	if c.AzureBlob.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.AzureBlob.Timeout)
+	}
+
+	if len(c.Memcached.Servers) != 2 || c.Memcached.Servers[1] != "memcached2:11211" {
+		t.Errorf("expected [memcached1:11211 memcached2:11211], got %v", c.Memcached.Servers)
+	}
+
+	if c.Memcached.VirtualNodes != 40 {
+		t.Errorf("expected 40, got %d", c.Memcached.VirtualNodes)
+	}
+
+	if c.Memcached.Username != "test_username" {
+		t.Errorf("expected test_username, got %s", c.Memcached.Username)
+	}
+
+	if c.Memcached.Password != "test_password" {
+		t.Errorf("expected test_password, got %s", c.Memcached.Password)
+	}
+
+	if !c.Memcached.UseTLS || !c.Memcached.InsecureSkipVerify {
+		t.Errorf("expected true, got %t %t", c.Memcached.UseTLS, c.Memcached.InsecureSkipVerify)
+	}
+
+	if len(c.Memcached.CertificateAuthorityPaths) != 1 ||
+		c.Memcached.CertificateAuthorityPaths[0] != "test_ca.pem" {
+		t.Errorf("expected [test_ca.pem], got %v", c.Memcached.CertificateAuthorityPaths)
+	}
 
-	c, ok := conf.Caches["test"]
-	if !ok {
-		t.Errorf("unable to find cache config: %s", "test")
-		return
+	if c.Memcached.ServerName != "test_server_name" {
+		t.Errorf("expected test_server_name, got %s", c.Memcached.ServerName)
 	}
 
-	if c.CacheType != "redis" {
-		t.Errorf("expected redis, got %s", c.CacheType)
+	if c.Memcached.ClientCertPath != "test_client.pem" {
+		t.Errorf("expected test_client.pem, got %s", c.Memcached.ClientCertPath)
 	}
 
-	if c.Index.ReapIntervalSecs != 4 {
-		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
+	if c.Memcached.ClientKeyPath != "test_client.key" {
+		t.Errorf("expected test_client.key, got %s", c.Memcached.ClientKeyPath)
 	}
 
-	if c.Index.FlushIntervalSecs != 6 {
-		t.Errorf("expected 6, got %d", c.Index.FlushIntervalSecs)
+	if c.Memcached.MaxIdleConns != 8 {
+		t.Errorf("expected 8, got %d", c.Memcached.MaxIdleConns)
 	}
 
-	if c.Index.MaxSizeBytes != 536870913 {
-		t.Errorf("expected 536870913, got %d", c.Index.MaxSizeBytes)
+	if c.Memcached.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.Memcached.Timeout)
 	}
 
-	if c.Index.MaxSizeBackoffBytes != 16777217 {
-		t.Errorf("expected 16777217, got %d", c.Index.MaxSizeBackoffBytes)
+	if len(c.Etcd.Endpoints) != 2 || c.Etcd.Endpoints[1] != "http://etcd2:2379" {
+		t.Errorf("expected [http://etcd1:2379 http://etcd2:2379], got %v", c.Etcd.Endpoints)
 	}
 
-	if c.Index.MaxSizeObjects != 80 {
-		t.Errorf("expected 80, got %d", c.Index.MaxSizeObjects)
+	if c.Etcd.KeyPrefix != "/test/cache/" || c.Etcd.LockPrefix != "/test/locks/" {
+		t.Errorf("expected /test/cache/ /test/locks/, got %s %s", c.Etcd.KeyPrefix, c.Etcd.LockPrefix)
 	}
 
-	if c.Index.MaxSizeBackoffObjects != 20 {
-		t.Errorf("expected 20, got %d", c.Index.MaxSizeBackoffObjects)
+	if c.Etcd.Username != "test_username" || c.Etcd.Password != "test_password" {
+		t.Errorf("expected test_username test_password, got %s %s", c.Etcd.Username, c.Etcd.Password)
 	}
 
-	if c.Index.ReapIntervalSecs != 4 {
-		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
+	if c.Etcd.MaxValueBytes != 32768 {
+		t.Errorf("expected 32768, got %d", c.Etcd.MaxValueBytes)
 	}
 
-	if c.Redis.ClientType != "test_redis_type" {
-		t.Errorf("expected test_redis_type, got %s", c.Redis.ClientType)
+	if !c.Etcd.DistributedLocks {
+		t.Errorf("expected true, got %t", c.Etcd.DistributedLocks)
 	}
 
-	if c.Redis.Protocol != "test_protocol" {
-		t.Errorf("expected test_protocol, got %s", c.Redis.Protocol)
+	if c.Etcd.LockTTL != 15*time.Second || c.Etcd.LockTimeout != time.Second {
+		t.Errorf("expected %s %s got %s %s", 15*time.Second, time.Second, c.Etcd.LockTTL, c.Etcd.LockTimeout)
 	}
 
-	if c.Redis.Endpoint != "test_endpoint" {
-		t.Errorf("expected test_endpoint, got %s", c.Redis.Endpoint)
+	if c.Etcd.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.Etcd.Timeout)
 	}
 
-	if c.Redis.SentinelMaster != "test_master" {
-		t.Errorf("expected test_master, got %s", c.Redis.SentinelMaster)
+	if len(c.Cassandra.Hosts) != 2 || c.Cassandra.Hosts[1] != "cassandra2:9042" {
+		t.Errorf("expected [cassandra1:9042 cassandra2:9042], got %v", c.Cassandra.Hosts)
 	}
 
-	if c.Redis.Password != "test_password" {
-		t.Errorf("expected test_password, got %s", c.Redis.Password)
+	if c.Cassandra.Keyspace != "test_keyspace" || c.Cassandra.Table != "test_table" {
+		t.Errorf("expected test_keyspace test_table, got %s %s", c.Cassandra.Keyspace, c.Cassandra.Table)
 	}
 
-	if c.Redis.DB != 42 {
-		t.Errorf("expected 42, got %d", c.Redis.DB)
+	if c.Cassandra.ReadConsistency != "local_quorum" || c.Cassandra.WriteConsistency != "one" {
+		t.Errorf("expected local_quorum one, got %s %s", c.Cassandra.ReadConsistency,
+			c.Cassandra.WriteConsistency)
 	}
 
-	if c.Redis.MaxRetries != 6 {
-		t.Errorf("expected 6, got %d", c.Redis.MaxRetries)
+	if c.Cassandra.TokenAware || c.Cassandra.LocalDC != "test_dc" {
+		t.Errorf("expected false test_dc, got %t %s", c.Cassandra.TokenAware, c.Cassandra.LocalDC)
 	}
 
-	if c.Redis.MinRetryBackoffMS != 9 {
-		t.Errorf("expected 9, got %d", c.Redis.MinRetryBackoffMS)
+	if c.Cassandra.Username != "test_username" || c.Cassandra.Password != "test_password" {
+		t.Errorf("expected test_username test_password, got %s %s", c.Cassandra.Username,
+			c.Cassandra.Password)
 	}
 
-	if c.Redis.MaxRetryBackoffMS != 513 {
-		t.Errorf("expected 513, got %d", c.Redis.MaxRetryBackoffMS)
+	if !c.Cassandra.UseTLS || !c.Cassandra.InsecureSkipVerify {
+		t.Errorf("expected true, got %t %t", c.Cassandra.UseTLS, c.Cassandra.InsecureSkipVerify)
 	}
 
-	if c.Redis.DialTimeoutMS != 5001 {
-		t.Errorf("expected 5001, got %d", c.Redis.DialTimeoutMS)
+	if len(c.Cassandra.CertificateAuthorityPaths) != 1 ||
+		c.Cassandra.CertificateAuthorityPaths[0] != "test_ca.pem" {
+		t.Errorf("expected [test_ca.pem], got %v", c.Cassandra.CertificateAuthorityPaths)
 	}
 
-	if c.Redis.ReadTimeoutMS != 3001 {
-		t.Errorf("expected 3001, got %d", c.Redis.ReadTimeoutMS)
+	if c.Cassandra.ServerName != "test_server_name" {
+		t.Errorf("expected test_server_name, got %s", c.Cassandra.ServerName)
 	}
 
-	if c.Redis.WriteTimeoutMS != 3002 {
-		t.Errorf("expected 3002, got %d", c.Redis.WriteTimeoutMS)
+	if c.Cassandra.ClientCertPath != "test_client.pem" || c.Cassandra.ClientKeyPath != "test_client.key" {
+		t.Errorf("expected test_client.pem test_client.key, got %s %s", c.Cassandra.ClientCertPath,
+			c.Cassandra.ClientKeyPath)
 	}
 
-	if c.Redis.PoolSize != 21 {
-		t.Errorf("expected 21, got %d", c.Redis.PoolSize)
+	if c.Cassandra.MaxIdleConns != 8 {
+		t.Errorf("expected 8, got %d", c.Cassandra.MaxIdleConns)
 	}
 
-	if c.Redis.MinIdleConns != 5 {
-		t.Errorf("expected 5, got %d", c.Redis.PoolSize)
+	if c.Cassandra.Timeout != 2500*time.Millisecond {
+		t.Errorf("expected %s got %s", 2500*time.Millisecond, c.Cassandra.Timeout)
 	}
 
-	if c.Redis.MaxConnAgeMS != 2000 {
-		t.Errorf("expected 2000, got %d", c.Redis.MaxConnAgeMS)
+	if c.Logstore.Directory != "/tmp/test_logstore" {
+		t.Errorf("expected /tmp/test_logstore, got %s", c.Logstore.Directory)
 	}
 
-	if c.Redis.PoolTimeoutMS != 4001 {
-		t.Errorf("expected 4001, got %d", c.Redis.PoolTimeoutMS)
+	if c.Logstore.SegmentSizeBytes != 64<<20 || c.Logstore.CompactInterval != 30*time.Second {
+		t.Errorf("expected %d %s got %d %s", 64<<20, 30*time.Second,
+			c.Logstore.SegmentSizeBytes, c.Logstore.CompactInterval)
 	}
 
-	if c.Redis.IdleTimeoutMS != 300001 {
-		t.Errorf("expected 300001, got %d", c.Redis.IdleTimeoutMS)
+	if c.Logstore.CompactDeadRatio != 0.25 || !c.Logstore.SyncWrites {
+		t.Errorf("expected 0.25 true, got %f %t", c.Logstore.CompactDeadRatio, c.Logstore.SyncWrites)
 	}
 
-	if c.Redis.IdleCheckFrequencyMS != 60001 {
-		t.Errorf("expected 60001, got %d", c.Redis.IdleCheckFrequencyMS)
+	if c.Tiered.L2CacheType != "bbolt" {
+		t.Errorf("expected bbolt, got %s", c.Tiered.L2CacheType)
 	}
 
-	if c.Filesystem.CachePath != "test_cache_path" {
-		t.Errorf("expected test_cache_path, got %s", c.Filesystem.CachePath)
+	if c.Tiered.L1MaxSizeBytes != 1048576 {
+		t.Errorf("expected %d got %d", 1048576, c.Tiered.L1MaxSizeBytes)
 	}
 
-	if c.BBolt.Filename != "test_filename" {
-		t.Errorf("expected test_filename, got %s", c.BBolt.Filename)
+	if c.Tiered.L1MaxSizeObjects != 500 {
+		t.Errorf("expected %d got %d", 500, c.Tiered.L1MaxSizeObjects)
 	}
 
-	if c.BBolt.Bucket != "test_bucket" {
-		t.Errorf("expected test_bucket, got %s", c.BBolt.Bucket)
+	if c.Tiered.L1PromotionTTL != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, c.Tiered.L1PromotionTTL)
 	}
 
-	if c.Badger.Directory != "test_directory" {
-		t.Errorf("expected test_directory, got %s", c.Badger.Directory)
+	if c.Migration.OldCacheType != "bbolt" || c.Migration.NewCacheType != "logstore" {
+		t.Errorf("expected bbolt logstore, got %s %s", c.Migration.OldCacheType,
+			c.Migration.NewCacheType)
 	}
 
-	if c.Badger.ValueDirectory != "test_value_directory" {
-		t.Errorf("expected test_value_directory, got %s", c.Badger.ValueDirectory)
+	if c.Migration.BackfillTTL != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, c.Migration.BackfillTTL)
 	}
 }
 
@@ -424,6 +1487,10 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", d.DefaultMetricsListenAddress, conf.Metrics.ListenAddress)
 	}
 
+	if conf.Metrics.AddressFamily != d.DefaultAddressFamily {
+		t.Errorf("expected '%s', got '%s'", d.DefaultAddressFamily, conf.Metrics.AddressFamily)
+	}
+
 	// Test Logging
 	if conf.Logging.LogLevel != d.DefaultLogLevel {
 		t.Errorf("expected %s, got %s", d.DefaultLogLevel, conf.Logging.LogLevel)
@@ -433,6 +1500,15 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected '%s', got '%s'", d.DefaultLogFile, conf.Logging.LogFile)
 	}
 
+	// Test Memory
+	if conf.Memory.WatermarkRatio != d.DefaultMemoryWatermarkRatio {
+		t.Errorf("expected %f, got %f", d.DefaultMemoryWatermarkRatio, conf.Memory.WatermarkRatio)
+	}
+
+	if conf.Memory.CheckInterval != d.DefaultMemoryCheckIntervalMS*time.Millisecond {
+		t.Errorf("expected %s, got %s", d.DefaultMemoryCheckIntervalMS*time.Millisecond, conf.Memory.CheckInterval)
+	}
+
 	// Test Origins
 
 	o, ok := conf.Origins["test"]
@@ -473,6 +1549,10 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected %d, got %d", d.DefaultBackfillToleranceSecs, o.BackfillToleranceSecs)
 	}
 
+	if o.GapFill != d.DefaultGapFill {
+		t.Errorf("expected %s, got %s", d.DefaultGapFill, o.GapFill)
+	}
+
 	if o.TimeoutSecs != d.DefaultOriginTimeoutSecs {
 		t.Errorf("expected %d, got %d", d.DefaultOriginTimeoutSecs, o.TimeoutSecs)
 	}
@@ -503,6 +1583,10 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected %d, got %d", d.DefaultCacheIndexFlush, c.Index.FlushIntervalSecs)
 	}
 
+	if c.Index.ForecastIntervalSecs != d.DefaultCacheIndexForecast {
+		t.Errorf("expected %d, got %d", d.DefaultCacheIndexForecast, c.Index.ForecastIntervalSecs)
+	}
+
 	if c.Index.MaxSizeBytes != d.DefaultCacheMaxSizeBytes {
 		t.Errorf("expected %d, got %d", d.DefaultCacheMaxSizeBytes, c.Index.MaxSizeBytes)
 	}
@@ -519,6 +1603,10 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected %d, got %d", d.DefaultMaxSizeBackoffObjects, c.Index.MaxSizeBackoffObjects)
 	}
 
+	if c.Index.EvictionPolicy != d.DefaultCacheIndexEvictionPolicy {
+		t.Errorf("expected %s, got %s", d.DefaultCacheIndexEvictionPolicy, c.Index.EvictionPolicy)
+	}
+
 	if c.Index.ReapIntervalSecs != 3 {
 		t.Errorf("expected 3, got %d", c.Index.ReapIntervalSecs)
 	}
@@ -599,6 +1687,10 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected /tmp/trickster, got %s", c.Filesystem.CachePath)
 	}
 
+	if c.Filesystem.SyncMode != "none" {
+		t.Errorf("expected none, got %s", c.Filesystem.SyncMode)
+	}
+
 	if c.BBolt.Filename != "trickster.db" {
 		t.Errorf("expected trickster.db, got %s", c.BBolt.Filename)
 	}
@@ -614,6 +1706,132 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 	if c.Badger.ValueDirectory != "/tmp/trickster" {
 		t.Errorf("expected /tmp/trickster, got %s", c.Badger.ValueDirectory)
 	}
+
+	if c.Badger.ValueLogGCRatio != d.DefaultBadgerValueLogGCRatio {
+		t.Errorf("expected %f, got %f", d.DefaultBadgerValueLogGCRatio, c.Badger.ValueLogGCRatio)
+	}
+
+	if c.S3.Prefix != "trickster/" {
+		t.Errorf("expected trickster/, got %s", c.S3.Prefix)
+	}
+
+	if c.S3.Region != "us-east-1" {
+		t.Errorf("expected us-east-1, got %s", c.S3.Region)
+	}
+
+	if c.S3.PartSizeBytes != 8388608 {
+		t.Errorf("expected %d got %d", 8388608, c.S3.PartSizeBytes)
+	}
+
+	if c.S3.Timeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, c.S3.Timeout)
+	}
+
+	if c.DynamoDB.Region != "us-east-1" {
+		t.Errorf("expected us-east-1, got %s", c.DynamoDB.Region)
+	}
+
+	if c.DynamoDB.KeyAttribute != "cache_key" {
+		t.Errorf("expected cache_key, got %s", c.DynamoDB.KeyAttribute)
+	}
+
+	if c.DynamoDB.TTLAttribute != "expires_at" {
+		t.Errorf("expected expires_at, got %s", c.DynamoDB.TTLAttribute)
+	}
+
+	if c.DynamoDB.BatchSize != 25 {
+		t.Errorf("expected %d got %d", 25, c.DynamoDB.BatchSize)
+	}
+
+	if c.DynamoDB.Timeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, c.DynamoDB.Timeout)
+	}
+
+	if c.GCS.Endpoint != "https://storage.googleapis.com" {
+		t.Errorf("expected https://storage.googleapis.com, got %s", c.GCS.Endpoint)
+	}
+
+	if c.GCS.Prefix != "trickster/" {
+		t.Errorf("expected trickster/, got %s", c.GCS.Prefix)
+	}
+
+	if c.GCS.Timeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, c.GCS.Timeout)
+	}
+
+	if c.AzureBlob.Prefix != "trickster/" {
+		t.Errorf("expected trickster/, got %s", c.AzureBlob.Prefix)
+	}
+
+	if c.AzureBlob.Timeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, c.AzureBlob.Timeout)
+	}
+
+	if len(c.Memcached.Servers) != 1 || c.Memcached.Servers[0] != "memcached:11211" {
+		t.Errorf("expected [memcached:11211], got %v", c.Memcached.Servers)
+	}
+
+	if c.Memcached.VirtualNodes != 160 {
+		t.Errorf("expected 160, got %d", c.Memcached.VirtualNodes)
+	}
+
+	if c.Memcached.Timeout != time.Second {
+		t.Errorf("expected %s got %s", time.Second, c.Memcached.Timeout)
+	}
+
+	if len(c.Etcd.Endpoints) != 1 || c.Etcd.Endpoints[0] != "http://etcd:2379" {
+		t.Errorf("expected [http://etcd:2379], got %v", c.Etcd.Endpoints)
+	}
+
+	if c.Etcd.MaxValueBytes != 65536 || c.Etcd.DistributedLocks {
+		t.Errorf("expected 65536 false, got %d %t", c.Etcd.MaxValueBytes, c.Etcd.DistributedLocks)
+	}
+
+	if c.Etcd.LockTTL != 30*time.Second || c.Etcd.LockTimeout != 5*time.Second ||
+		c.Etcd.Timeout != 2*time.Second {
+		t.Errorf("unexpected durations %s %s %s", c.Etcd.LockTTL, c.Etcd.LockTimeout, c.Etcd.Timeout)
+	}
+
+	if len(c.Cassandra.Hosts) != 1 || c.Cassandra.Hosts[0] != "cassandra:9042" {
+		t.Errorf("expected [cassandra:9042], got %v", c.Cassandra.Hosts)
+	}
+
+	if c.Cassandra.Keyspace != "trickster" || c.Cassandra.Table != "cache" {
+		t.Errorf("expected trickster cache, got %s %s", c.Cassandra.Keyspace, c.Cassandra.Table)
+	}
+
+	if c.Cassandra.ReadConsistency != "local_one" || c.Cassandra.WriteConsistency != "local_one" ||
+		!c.Cassandra.TokenAware {
+		t.Errorf("expected local_one local_one true, got %s %s %t", c.Cassandra.ReadConsistency,
+			c.Cassandra.WriteConsistency, c.Cassandra.TokenAware)
+	}
+
+	if c.Cassandra.Timeout != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, c.Cassandra.Timeout)
+	}
+
+	if c.Logstore.SegmentSizeBytes != 512<<20 || c.Logstore.CompactInterval != time.Minute ||
+		c.Logstore.CompactDeadRatio != 0.5 || c.Logstore.SyncWrites {
+		t.Errorf("unexpected logstore options %d %s %f %t", c.Logstore.SegmentSizeBytes,
+			c.Logstore.CompactInterval, c.Logstore.CompactDeadRatio, c.Logstore.SyncWrites)
+	}
+
+	if c.Tiered.L2CacheType != "filesystem" {
+		t.Errorf("expected filesystem, got %s", c.Tiered.L2CacheType)
+	}
+
+	if c.Tiered.L1MaxSizeBytes != 67108864 {
+		t.Errorf("expected %d got %d", 67108864, c.Tiered.L1MaxSizeBytes)
+	}
+
+	if c.Tiered.L1PromotionTTL != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, c.Tiered.L1PromotionTTL)
+	}
+
+	if c.Migration.OldCacheType != "" || c.Migration.BackfillTTL != 0 {
+		t.Errorf("unexpected migration options %s %s", c.Migration.OldCacheType,
+			c.Migration.BackfillTTL)
+	}
 }
 
 func TestLoadConfigurationVersion(t *testing.T) {
@@ -629,6 +1847,70 @@ func TestLoadConfigurationVersion(t *testing.T) {
 	}
 }
 
+func TestLoadConfigurationTemplates(t *testing.T) {
+	a := []string{"-config", "../../testdata/test.templates.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conf.Origins) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(conf.Origins))
+	}
+
+	o, ok := conf.Origins["prom-a"]
+	if !ok {
+		t.Fatal("expected origin prom-a")
+	}
+	if o.OriginType != "prometheus" {
+		t.Errorf("expected %s got %s", "prometheus", o.OriginType)
+	}
+	if o.OriginURL != "http://prom-a.example.com:9090" {
+		t.Errorf("expected %s got %s", "http://prom-a.example.com:9090", o.OriginURL)
+	}
+	if o.CacheKeyPrefix != "tmpl-prom-a" {
+		t.Errorf("expected %s got %s", "tmpl-prom-a", o.CacheKeyPrefix)
+	}
+	if o.TimeoutSecs != 10 {
+		t.Errorf("expected %d got %d", 10, o.TimeoutSecs)
+	}
+	var found bool
+	for _, p := range o.Paths {
+		if p.Path != "/" {
+			continue
+		}
+		found = true
+		if p.ResponseHeaders["X-Origin"] != "prom-a" {
+			t.Errorf("expected %s got %s", "prom-a", p.ResponseHeaders["X-Origin"])
+		}
+	}
+	if !found {
+		t.Error("expected templated path /")
+	}
+
+	o = conf.Origins["prom-b"]
+	if o.TimeoutSecs != 30 {
+		t.Errorf("expected %d got %d", 30, o.TimeoutSecs)
+	}
+	if o.CacheKeyPrefix != "tmpl-prom-b" {
+		t.Errorf("expected %s got %s", "tmpl-prom-b", o.CacheKeyPrefix)
+	}
+
+	if len(conf.Main.includedFiles) != 1 {
+		t.Errorf("expected %d got %d", 1, len(conf.Main.includedFiles))
+	}
+	if conf.IsStale() {
+		t.Error("expected config not to be stale")
+	}
+	for f := range conf.Main.includedFiles {
+		conf.Main.includedFiles[f] = time.Time{}
+	}
+	conf.Main.configRateLimitTime = time.Time{}
+	if !conf.IsStale() {
+		t.Error("expected config to be stale")
+	}
+}
+
 func TestLoadConfigurationBadPath(t *testing.T) {
 	const badPath = "/afeas/aasdvasvasdf48/ag4a4gas"
 
@@ -640,6 +1922,50 @@ func TestLoadConfigurationBadPath(t *testing.T) {
 	}
 }
 
+func TestLoadConfigurationRemote(t *testing.T) {
+
+	_, tml := emptyTestConfig()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/trickster.conf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(tml))
+	}))
+	defer ts.Close()
+
+	c, _, err := Load("trickster-test", "0", []string{"-config", ts.URL + "/trickster.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ConfigFilePath() != ts.URL+"/trickster.conf" {
+		t.Errorf("unexpected config path %s", c.ConfigFilePath())
+	}
+	if _, ok := c.Origins["test"]; !ok {
+		t.Error("expected origin from remote config")
+	}
+
+	// the signature is required when a public key is provided
+	pub, _, _ := ed25519.GenerateKey(nil)
+	kf, err := ioutil.TempFile("", "trickster-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(kf.Name())
+	kf.WriteString(base64.StdEncoding.EncodeToString(pub))
+	kf.Close()
+
+	for _, a := range [][]string{
+		{"-config", ts.URL + "/trickster.conf", "-config-public-key", kf.Name()},
+		{"-config", ts.URL + "/trickster.conf", "-config-public-key", kf.Name() + ".missing"},
+		{"-config", ts.URL + "/missing.conf"},
+	} {
+		if _, _, err = Load("trickster-test", "0", a); err == nil {
+			t.Errorf("expected error for %v", a)
+		}
+	}
+}
+
 func TestLoadConfigurationBadUrl(t *testing.T) {
 	const badURL = ":httap:]/]/example.com9091"
 	a := []string{"-origin-url", badURL}
@@ -658,6 +1984,142 @@ func TestLoadConfigurationBadArg(t *testing.T) {
 	}
 }
 
+func TestLoadConfigurationCacheQuotas(t *testing.T) {
+
+	a := []string{"-config", "../../testdata/test.cache-quota.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// origins with a cache_quota are namespaced by name unless they set a cache_key_prefix
+	if o := conf.Origins["test"]; o.CacheKeyPrefix != "test" {
+		t.Errorf("expected %s got %s", "test", o.CacheKeyPrefix)
+	}
+
+	if o := conf.Origins["test3"]; o.CacheKeyPrefix != "prometheus:9090" {
+		t.Errorf("expected %s got %s", "prometheus:9090", o.CacheKeyPrefix)
+	}
+
+	quotas := conf.Caches["test"].Index.Quotas
+	if len(quotas) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(quotas))
+	}
+
+	if q, ok := quotas["test"]; !ok || q.MaxSizeBytes != 1048576 || q.MaxSizeObjects != 1000 {
+		t.Errorf("unexpected quota for namespace test: %v", q)
+	}
+
+	if q, ok := quotas["test2-prefix"]; !ok || q.MaxSizeBytes != 0 || q.MaxSizeObjects != 500 {
+		t.Errorf("unexpected quota for namespace test2-prefix: %v", q)
+	}
+
+}
+
+func TestLoadConfigurationBBoltBucketPerOrigin(t *testing.T) {
+
+	a := []string{"-config", "../../testdata/test.bbolt-bucket-per-origin.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// origins on a bbolt cache with bucket_per_origin are namespaced by name unless they
+	// set a cache_key_prefix
+	if o := conf.Origins["test"]; o.CacheKeyPrefix != "test" {
+		t.Errorf("expected %s got %s", "test", o.CacheKeyPrefix)
+	}
+
+	if o := conf.Origins["test3"]; o.CacheKeyPrefix != "3" {
+		t.Errorf("expected %s got %s", "3", o.CacheKeyPrefix)
+	}
+
+	buckets := conf.Caches["bolt"].BBolt.OriginBuckets
+	if len(buckets) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(buckets))
+	}
+
+	if b := buckets["test"]; b != "trickster.test" {
+		t.Errorf("expected %s got %s", "trickster.test", b)
+	}
+
+	if b := buckets["test2-prefix"]; b != "trickster.test2" {
+		t.Errorf("expected %s got %s", "trickster.test2", b)
+	}
+
+}
+
+func TestLoadConfigurationPrometheusAPI(t *testing.T) {
+
+	a := []string{"-config", "../../testdata/test.prometheus-api.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := conf.Origins["influx"]
+	if !o.PrometheusAPI.Enabled() {
+		t.Fatal("expected prometheus_api to be enabled")
+	}
+
+	tmpl, vals := o.PrometheusAPI.Match(`cpu_usage_idle{host="web01"}`)
+	if tmpl == nil {
+		t.Fatal("expected template to match")
+	}
+
+	if tmpl.Name != "cpu" {
+		t.Errorf("expected %s got %s", "cpu", tmpl.Name)
+	}
+
+	if vals["host"] != "web01" {
+		t.Errorf("expected %s got %s", "web01", vals["host"])
+	}
+
+	if tmpl.Params["db"] != "telegraf" {
+		t.Errorf("expected %s got %s", "telegraf", tmpl.Params["db"])
+	}
+
+}
+
+func TestLoadConfigurationPrometheusRemoteWrite(t *testing.T) {
+
+	a := []string{"-config", "../../testdata/test.prometheus-remote-write.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw := conf.Origins["prom"].Prometheus.RemoteWrite
+	if !rw.Enabled || !rw.WAL {
+		t.Error("expected remote_write buffering with wal")
+	}
+
+	if rw.MaxBatchRequests != 100 {
+		t.Errorf("expected %d got %d", 100, rw.MaxBatchRequests)
+	}
+
+	if rw.FlushInterval != 500*time.Millisecond {
+		t.Errorf("expected %s got %s", 500*time.Millisecond, rw.FlushInterval)
+	}
+
+	if rw.MaxBufferedRequests != d.DefaultRemoteWriteMaxBufferedRequests {
+		t.Errorf("expected %d got %d", d.DefaultRemoteWriteMaxBufferedRequests,
+			rw.MaxBufferedRequests)
+	}
+
+	rr, ok := rw.RelabelRules["drop-debug"]
+	if !ok {
+		t.Fatal("expected relabel rule drop-debug")
+	}
+	if rr.Separator != d.DefaultRelabelSeparator {
+		t.Errorf("expected %s got %s", d.DefaultRelabelSeparator, rr.Separator)
+	}
+	if !rw.Relabels() || rw.Drop(map[string]string{"__name__": "debug_queue"}) != "drop-debug" {
+		t.Error("expected relabel rule to drop series")
+	}
+
+}
+
 func TestLoadConfigurationWarning1(t *testing.T) {
 
 	a := []string{"-config", "../../testdata/test.warning1.conf"}
@@ -25,8 +25,22 @@ type Options struct {
 	ListenAddress string `toml:"listen_address"`
 	// ListenPort is TCP Port from which the Reload API is available at ReloadHandlerPath
 	ListenPort int `toml:"listen_port"`
+	// ListenAddresses provides multiple IP addresses for the Reload API listener. When set,
+	// it is used instead of ListenAddress
+	ListenAddresses []string `toml:"listen_addresses"`
+	// AddressFamily is the address family of the Reload API listener: 'tcp4', 'tcp6' or 'dual'
+	AddressFamily string `toml:"address_family"`
 	// ReloadHandlerPath provides the path to register the Config Reload Handler
 	HandlerPath string `toml:"handler_path"`
+	// RulesHandlerPath provides the path to register the Runtime Rules Update Handler, which
+	// swaps POSTed rules, request rewriters and origin paths into the running routes
+	RulesHandlerPath string `toml:"rules_handler_path"`
+	// FlagsHandlerPath provides the path to register the Feature Flags Handler, which lists
+	// the running feature flags, and applies feature flag updates POSTed to it
+	FlagsHandlerPath string `toml:"flags_handler_path"`
+	// CompactHandlerPath provides the path to register the Cache Compaction Handler, which
+	// compacts the storage of the caches that support it when POSTed to
+	CompactHandlerPath string `toml:"compact_handler_path"`
 	// DrainTimeoutSecs provides the duration to wait for all sessions to drain before closing
 	// old resources following a reload
 	DrainTimeoutSecs int `toml:"drain_timeout_secs"`
@@ -35,15 +49,23 @@ type Options struct {
 	// This prevents a bad actor from stating the config file with millions of concurrent requets
 	// The rate limit does not apply to SIGHUP-based reload requests
 	RateLimitSecs int `toml:"rate_limit_secs"`
+	// RemotePollIntervalSecs provides the interval at which a config loaded from a remote
+	// config source is polled for changes, which are reloaded when found. 0 disables polling
+	RemotePollIntervalSecs int `toml:"remote_poll_interval_secs"`
 }
 
 // NewOptions returns a new Options references with Default Values set
 func NewOptions() *Options {
 	return &Options{
-		ListenAddress:    defaults.DefaultReloadAddress,
-		ListenPort:       defaults.DefaultReloadPort,
-		HandlerPath:      defaults.DefaultReloadHandlerPath,
-		DrainTimeoutSecs: defaults.DefaultDrainTimeoutSecs,
-		RateLimitSecs:    defaults.DefaultRateLimitSecs,
+		ListenAddress:          defaults.DefaultReloadAddress,
+		ListenPort:             defaults.DefaultReloadPort,
+		AddressFamily:          defaults.DefaultAddressFamily,
+		HandlerPath:            defaults.DefaultReloadHandlerPath,
+		RulesHandlerPath:       defaults.DefaultRulesHandlerPath,
+		FlagsHandlerPath:       defaults.DefaultFlagsHandlerPath,
+		CompactHandlerPath:     defaults.DefaultCompactHandlerPath,
+		DrainTimeoutSecs:       defaults.DefaultDrainTimeoutSecs,
+		RateLimitSecs:          defaults.DefaultRateLimitSecs,
+		RemotePollIntervalSecs: defaults.DefaultRemotePollIntervalSecs,
 	}
 }
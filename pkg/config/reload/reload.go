@@ -29,3 +29,7 @@ import (
 // or gracefully over an existing running Config
 type ReloaderFunc func(oldConf *config.Config, wg *sync.WaitGroup, log *log.Logger,
 	caches map[string]cache.Cache, args []string, errorsFatal bool) error
+
+// UpdaterFunc describes a function that swaps the routes of an updated Trickster config
+// into the live router, without a full config reload
+type UpdaterFunc func(conf *config.Config) error
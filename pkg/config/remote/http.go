@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+// defaultS3Region is the region used to sign S3 requests when none is provided
+const defaultS3Region = "us-east-1"
+
+// httpGetter gets the config from an HTTP(S) server, using the ETag of the response
+// as its version
+type httpGetter struct {
+	client *http.Client
+	base   *url.URL
+}
+
+func (g *httpGetter) get(name, version string) ([]byte, string, error) {
+	u := *g.base
+	u.Path = name
+	u.RawPath = ""
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if version != "" {
+		r.Header.Set(headers.NameIfNoneMatch, version)
+	}
+	resp, err := g.client.Do(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return readResponse(resp, u.String())
+}
+
+// s3Getter gets the config from an object in an S3 bucket, using the ETag of the object
+// as its version. The location is formatted as s3://bucket/key, and accepts region and
+// endpoint query parameters. Credentials are read from the AWS environment variables
+type s3Getter struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	region   string
+	creds    sigv4.Credentials
+}
+
+func newS3Getter(client *http.Client, u *url.URL) *s3Getter {
+	g := &s3Getter{client: client, bucket: u.Host, region: u.Query().Get("region"),
+		creds: sigv4.NewCredentials("", "", "")}
+	if g.region == "" {
+		g.region = os.Getenv("AWS_REGION")
+	}
+	if g.region == "" {
+		g.region = defaultS3Region
+	}
+	if e := u.Query().Get("endpoint"); e != "" {
+		// custom endpoints, such as MinIO, address the bucket in the path
+		g.endpoint, _ = url.Parse(e)
+	}
+	if g.endpoint == nil || g.endpoint.Host == "" {
+		g.endpoint = &url.URL{Scheme: "https", Host: g.bucket + ".s3." + g.region + ".amazonaws.com"}
+	} else {
+		g.endpoint.Path += "/" + g.bucket
+	}
+	return g
+}
+
+func (g *s3Getter) get(name, version string) ([]byte, string, error) {
+	path := g.endpoint.Path + "/"
+	u := &url.URL{Scheme: g.endpoint.Scheme, Host: g.endpoint.Host, Path: path + name,
+		RawPath: sigv4.URIEncode(path, false) + sigv4.URIEncode(name, false)}
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if version != "" {
+		r.Header.Set(headers.NameIfNoneMatch, version)
+	}
+	sigv4.Sign(r, nil, g.creds, g.region, "s3", time.Now())
+	resp, err := g.client.Do(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return readResponse(resp, "s3://"+g.bucket+"/"+name)
+}
+
+// readResponse returns the body and ETag of a successful response, ErrNotModified
+// for a 304 response, or an error for any other status
+func readResponse(resp *http.Response, location string) ([]byte, string, error) {
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", ErrNotModified
+	default:
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, "", fmt.Errorf("remote config %s responded with status %d",
+			location, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	return b, resp.Header.Get(headers.NameETag), nil
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// headerConsulIndex is the Consul response header carrying the modify index of the key
+const headerConsulIndex = "X-Consul-Index"
+
+// headerConsulToken is the Consul request header carrying the ACL token
+const headerConsulToken = "X-Consul-Token"
+
+// kvBaseURL returns the base URL of the key-value store's HTTP API. A scheme suffixed
+// with +https, such as etcd+https, is served over TLS
+func kvBaseURL(u *url.URL) *url.URL {
+	b := &url.URL{Scheme: "http", Host: u.Host}
+	if strings.HasSuffix(u.Scheme, "+https") {
+		b.Scheme = "https"
+	}
+	return b
+}
+
+// etcdGetter gets the config from an etcd key through the etcd v3 JSON gateway, using the
+// mod revision of the key as its version
+type etcdGetter struct {
+	client *http.Client
+	base   *url.URL
+}
+
+type etcdRangeRequest struct {
+	Key string `json:"key"`
+}
+
+type etcdRangeResponse struct {
+	KVs []struct {
+		Value       string `json:"value"`
+		ModRevision string `json:"mod_revision"`
+	} `json:"kvs"`
+}
+
+func (g *etcdGetter) get(name, version string) ([]byte, string, error) {
+	body, _ := json.Marshal(etcdRangeRequest{Key: base64.StdEncoding.EncodeToString([]byte(name))})
+	u := *g.base
+	u.Path = "/v3/kv/range"
+	resp, err := g.client.Post(u.String(), headers.ValueApplicationJSON, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	b, _, err := readResponse(resp, "etcd key "+name)
+	if err != nil {
+		return nil, "", err
+	}
+	var rr etcdRangeResponse
+	if err = json.Unmarshal(b, &rr); err != nil {
+		return nil, "", err
+	}
+	if len(rr.KVs) == 0 {
+		return nil, "", fmt.Errorf("remote config etcd key %s not found", name)
+	}
+	if version != "" && rr.KVs[0].ModRevision == version {
+		return nil, "", ErrNotModified
+	}
+	v, err := base64.StdEncoding.DecodeString(rr.KVs[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	return v, rr.KVs[0].ModRevision, nil
+}
+
+// consulGetter gets the config from a Consul key, using the modify index of the key as its
+// version. The ACL token is read from the CONSUL_HTTP_TOKEN environment variable
+type consulGetter struct {
+	client *http.Client
+	base   *url.URL
+}
+
+func (g *consulGetter) get(name, version string) ([]byte, string, error) {
+	u := *g.base
+	u.Path = "/v1/kv/" + name
+	u.RawQuery = "raw"
+	r, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if t := os.Getenv("CONSUL_HTTP_TOKEN"); t != "" {
+		r.Header.Set(headerConsulToken, t)
+	}
+	resp, err := g.client.Do(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("remote config consul key %s not found", name)
+	}
+	index := resp.Header.Get(headerConsulIndex)
+	if version != "" && index == version {
+		resp.Body.Close()
+		return nil, "", ErrNotModified
+	}
+	b, _, err := readResponse(resp, "consul key "+name)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, index, nil
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package remote fetches the Trickster configuration from a central config source, such as
+// an HTTP(S) server, an S3 bucket, or an etcd or Consul key, for polling by fleets of Tricksters
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SignatureSuffix is appended to the location of the config to form the location of its
+// detached signature
+const SignatureSuffix = ".sig"
+
+// fetchTimeout is the timeout of each request to the config source
+const fetchTimeout = 10 * time.Second
+
+// maxDocumentBytes is the largest config document or signature that will be read
+const maxDocumentBytes = 16 << 20
+
+// ErrNotModified is returned by Fetch when the config has not changed since the provided version
+var ErrNotModified = errors.New("remote config not modified")
+
+// ErrInvalidSignature is returned when the config does not match its detached signature
+var ErrInvalidSignature = errors.New("remote config signature verification failed")
+
+// ErrInvalidPublicKey is returned when the public key is not an Ed25519 key
+var ErrInvalidPublicKey = errors.New("config public key must be an Ed25519 key")
+
+// getter retrieves the document at the named location of a config source, returning it
+// with its version. When the version matches the one provided, it may return ErrNotModified
+type getter interface {
+	get(name, version string) ([]byte, string, error)
+}
+
+// Source is a remote location of the Trickster configuration
+type Source struct {
+	location  string
+	name      string
+	publicKey ed25519.PublicKey
+	getter    getter
+}
+
+// IsRemote returns true if the config path is the URL of a supported remote config source
+func IsRemote(path string) bool {
+	i := strings.Index(path, "://")
+	if i < 1 {
+		return false
+	}
+	switch path[:i] {
+	case "http", "https", "s3", "etcd", "etcd+https", "consul", "consul+https":
+		return true
+	}
+	return false
+}
+
+// New returns a Source for the config at the provided location. When publicKey is set,
+// every fetched config must be accompanied by a valid detached signature
+func New(location string, publicKey ed25519.PublicKey) (*Source, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid remote config location %s: missing host", location)
+	}
+	client := &http.Client{Timeout: fetchTimeout}
+	s := &Source{location: location, name: u.Path, publicKey: publicKey}
+	switch u.Scheme {
+	case "http", "https":
+		s.getter = &httpGetter{client: client, base: u}
+	case "s3":
+		s.name = strings.TrimPrefix(u.Path, "/")
+		s.getter = newS3Getter(client, u)
+	case "etcd", "etcd+https":
+		s.getter = &etcdGetter{client: client, base: kvBaseURL(u)}
+	case "consul", "consul+https":
+		s.name = strings.TrimPrefix(u.Path, "/")
+		s.getter = &consulGetter{client: client, base: kvBaseURL(u)}
+	default:
+		return nil, fmt.Errorf("unsupported remote config scheme: %s", u.Scheme)
+	}
+	if s.name == "" || s.name == "/" {
+		return nil, fmt.Errorf("invalid remote config location %s: missing path", location)
+	}
+	return s, nil
+}
+
+// Fetch returns the config and its version, or ErrNotModified when the version of the
+// config matches the one provided. An empty version always fetches the config
+func (s *Source) Fetch(version string) ([]byte, string, error) {
+	b, v, err := s.getter.get(s.name, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if v == "" {
+		v = contentVersion(b)
+	}
+	if version != "" && v == version {
+		return nil, "", ErrNotModified
+	}
+	if s.publicKey != nil {
+		sig, _, err := s.getter.get(s.name+SignatureSuffix, "")
+		if err != nil {
+			return nil, "", fmt.Errorf("could not fetch remote config signature: %s", err.Error())
+		}
+		if err = Verify(s.publicKey, b, sig); err != nil {
+			return nil, "", err
+		}
+	}
+	return b, v, nil
+}
+
+// String returns the location of the config
+func (s *Source) String() string {
+	return s.location
+}
+
+// Verify returns nil if the base64-encoded Ed25519 signature is valid for the document
+func Verify(publicKey ed25519.PublicKey, doc, sig []byte) error {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(publicKey, doc, b) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// LoadPublicKey reads an Ed25519 public key from the file, which holds either a PEM-encoded
+// PKIX public key, or the base64 encoding of the raw 32-byte key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if p, _ := pem.Decode(b); p != nil {
+		k, err := x509.ParsePKIXPublicKey(p.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pk, ok := k.(ed25519.PublicKey); ok {
+			return pk, nil
+		}
+		return nil, ErrInvalidPublicKey
+	}
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(k) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(k), nil
+}
+
+// contentVersion returns the version of a config whose source does not provide one
+func contentVersion(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
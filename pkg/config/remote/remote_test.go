@@ -0,0 +1,286 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package remote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+const testConf = "[origins]\n  [origins.default]\n  origin_type = 'rpc'\n"
+
+func TestIsRemote(t *testing.T) {
+	tests := map[string]bool{
+		"/etc/trickster/trickster.conf":         false,
+		"trickster.conf":                        false,
+		"http://config/trickster.conf":          true,
+		"https://config/trickster.conf":         true,
+		"s3://bucket/trickster.conf":            true,
+		"etcd://127.0.0.1:2379/trickster":       true,
+		"etcd+https://127.0.0.1:2379/trickster": true,
+		"consul://127.0.0.1:8500/trickster":     true,
+		"ftp://config/trickster.conf":           false,
+		"://trickster.conf":                     false,
+	}
+	for k, v := range tests {
+		if IsRemote(k) != v {
+			t.Errorf("expected %t for %s", v, k)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, l := range []string{"ftp://config/trickster.conf", "http:///trickster.conf",
+		"http://config/", "consul://127.0.0.1:8500/", "%"} {
+		if _, err := New(l, nil); err == nil {
+			t.Errorf("expected error for %s", l)
+		}
+	}
+	s, err := New("s3://bucket/trickster.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != "s3://bucket/trickster.conf" {
+		t.Errorf("unexpected location %s", s.String())
+	}
+}
+
+func TestFetchHTTP(t *testing.T) {
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(testConf)))
+
+	var etag string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/trickster.conf":
+			if etag != "" {
+				if r.Header.Get(headers.NameIfNoneMatch) == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set(headers.NameETag, etag)
+			}
+			w.Write([]byte(testConf))
+		case "/trickster.conf" + SignatureSuffix:
+			w.Write([]byte(sig + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// without an etag, the version is derived from the content
+	s, _ := New(ts.URL+"/trickster.conf", pub)
+	b, v, err := s.Fetch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testConf || v != contentVersion(b) {
+		t.Errorf("unexpected fetch %s %s", string(b), v)
+	}
+	if _, _, err = s.Fetch(v); err != ErrNotModified {
+		t.Errorf("expected %v got %v", ErrNotModified, err)
+	}
+
+	etag = `"v1"`
+	_, v, err = s.Fetch("previous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != etag {
+		t.Errorf("expected %s got %s", etag, v)
+	}
+	if _, _, err = s.Fetch(etag); err != ErrNotModified {
+		t.Errorf("expected %v got %v", ErrNotModified, err)
+	}
+
+	// a different key fails verification
+	pub2, _, _ := ed25519.GenerateKey(nil)
+	s, _ = New(ts.URL+"/trickster.conf", pub2)
+	if _, _, err = s.Fetch(""); err != ErrInvalidSignature {
+		t.Errorf("expected %v got %v", ErrInvalidSignature, err)
+	}
+
+	// a missing signature fails when a key is set, and is not required otherwise
+	s, _ = New(ts.URL+"/trickster.conf"+SignatureSuffix, pub)
+	if _, _, err = s.Fetch(""); err == nil {
+		t.Error("expected error for missing signature")
+	}
+	s, _ = New(ts.URL+"/missing.conf", nil)
+	if _, _, err = s.Fetch(""); err == nil ||
+		!strings.Contains(err.Error(), "responded with status 404") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFetchS3(t *testing.T) {
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "trickster")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "trickster")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/configs/edge/trickster.conf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/s3/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get(headers.NameIfNoneMatch) == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(headers.NameETag, `"v1"`)
+		w.Write([]byte(testConf))
+	}))
+	defer ts.Close()
+
+	s, err := New("s3://configs/edge/trickster.conf?region=eu-west-1&endpoint="+ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, v, err := s.Fetch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testConf || v != `"v1"` {
+		t.Errorf("unexpected fetch %s %s", string(b), v)
+	}
+	if _, _, err = s.Fetch(v); err != ErrNotModified {
+		t.Errorf("expected %v got %v", ErrNotModified, err)
+	}
+}
+
+func TestFetchEtcd(t *testing.T) {
+
+	rev := "7"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req etcdRangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		k, _ := base64.StdEncoding.DecodeString(req.Key)
+		if r.URL.Path != "/v3/kv/range" || string(k) != "/trickster/edge" {
+			w.Write([]byte(`{"header":{}}`))
+			return
+		}
+		w.Write([]byte(`{"kvs":[{"value":"` + base64.StdEncoding.EncodeToString([]byte(testConf)) +
+			`","mod_revision":"` + rev + `"}]}`))
+	}))
+	defer ts.Close()
+
+	u := strings.Replace(ts.URL, "http://", "etcd://", 1)
+	s, _ := New(u+"/trickster/edge", nil)
+	b, v, err := s.Fetch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testConf || v != rev {
+		t.Errorf("unexpected fetch %s %s", string(b), v)
+	}
+	if _, _, err = s.Fetch(rev); err != ErrNotModified {
+		t.Errorf("expected %v got %v", ErrNotModified, err)
+	}
+
+	s, _ = New(u+"/trickster/missing", nil)
+	if _, _, err = s.Fetch(""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFetchConsul(t *testing.T) {
+
+	os.Setenv("CONSUL_HTTP_TOKEN", "trickster")
+	defer os.Unsetenv("CONSUL_HTTP_TOKEN")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/trickster/edge" || r.Header.Get(headerConsulToken) != "trickster" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(headerConsulIndex, "42")
+		w.Write([]byte(testConf))
+	}))
+	defer ts.Close()
+
+	u := strings.Replace(ts.URL, "http://", "consul://", 1)
+	s, _ := New(u+"/trickster/edge", nil)
+	b, v, err := s.Fetch("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testConf || v != "42" {
+		t.Errorf("unexpected fetch %s %s", string(b), v)
+	}
+	if _, _, err = s.Fetch("42"); err != ErrNotModified {
+		t.Errorf("expected %v got %v", ErrNotModified, err)
+	}
+
+	s, _ = New(u+"/trickster/missing", nil)
+	if _, _, err = s.Fetch(""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+
+	pub, _, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+
+	dir, err := ioutil.TempDir("", "trickster-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"key.pem": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		"key.b64": []byte(base64.StdEncoding.EncodeToString(pub) + "\n"),
+	}
+	for k, v := range files {
+		ioutil.WriteFile(dir+"/"+k, v, 0600)
+		pk, err := LoadPublicKey(dir + "/" + k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pub, pk) {
+			t.Errorf("unexpected key from %s", k)
+		}
+	}
+
+	ioutil.WriteFile(dir+"/key.bad", []byte("not a key"), 0600)
+	if _, err = LoadPublicKey(dir + "/key.bad"); err != ErrInvalidPublicKey {
+		t.Errorf("expected %v got %v", ErrInvalidPublicKey, err)
+	}
+	if _, err = LoadPublicKey(dir + "/key.missing"); err == nil {
+		t.Error("expected error for missing key file")
+	}
+}
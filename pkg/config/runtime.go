@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	rewriter "github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrNoRuntimeRules is returned when a runtime rules update provides nothing to update
+var ErrNoRuntimeRules = errors.New("no rules, request rewriters or origin paths were provided")
+
+// runtimeSections are the top-level config sections that can be updated at runtime
+var runtimeSections = map[string]bool{
+	"rules":             true,
+	"request_rewriters": true,
+	"origins":           true,
+}
+
+// ApplyRuntimeRules returns a copy of the Config with the rules, request rewriters and origin
+// paths in the provided TOML document swapped in. Provided sections replace their running
+// counterparts entirely, while an origin's paths are only replaced when provided for that origin.
+// The copy shares everything else with the subject Config, including the origins' runtime
+// resources, so its routes can replace the running routes without a full config reload
+func (c *Config) ApplyRuntimeRules(tml string) (*Config, error) {
+
+	rc := &Config{}
+	md, err := toml.Decode(tml, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := md.Keys()
+	if len(keys) == 0 {
+		return nil, ErrNoRuntimeRules
+	}
+	for _, key := range keys {
+		if !runtimeSections[key[0]] || (key[0] == "origins" && len(key) > 2 && key[2] != "paths") {
+			return nil, fmt.Errorf("only rules, request rewriters and origin paths can be "+
+				"updated at runtime, found [%s]", key.String())
+		}
+	}
+	for k := range rc.Origins {
+		if _, ok := c.Origins[k]; !ok {
+			return nil, fmt.Errorf("invalid origin name [%s] provided in runtime rules", k)
+		}
+	}
+
+	nc := *c
+	nc.LoaderWarnings = nil
+
+	if md.IsDefined("rules") {
+		nc.Rules = rc.Rules
+	}
+
+	if md.IsDefined("request_rewriters") {
+		nc.RequestRewriters = rc.RequestRewriters
+		if nc.CompiledRewriters, err = rewriter.ProcessConfigs(nc.RequestRewriters); err != nil {
+			return nil, err
+		}
+	}
+
+	nc.Origins = make(map[string]*origins.Options, len(c.Origins))
+	for k, o := range c.Origins {
+		no := *o
+		no.Paths = make(map[string]*po.Options)
+		if v, ok := rc.Origins[k]; ok && md.IsDefined("origins", k, "paths") {
+			if err = nc.processPathConfigs(k, v, &no, &md); err != nil {
+				return nil, err
+			}
+		} else {
+			for l, p := range o.Paths {
+				np := *p
+				no.Paths[l] = &np
+			}
+		}
+
+		// the origin's rewriters and rule are resolved again, since they may have been replaced
+		if no.ReqRewriterName != "" {
+			ri, ok := nc.CompiledRewriters[no.ReqRewriterName]
+			if !ok {
+				return nil, fmt.Errorf("invalid rewriter name %s in origin config %s",
+					no.ReqRewriterName, k)
+			}
+			no.ReqRewriter = ri
+		}
+		for l, p := range no.Paths {
+			if p.ReqRewriterName == "" {
+				continue
+			}
+			ri, ok := nc.CompiledRewriters[p.ReqRewriterName]
+			if !ok {
+				return nil, fmt.Errorf("invalid rewriter name %s in path %s of origin config %s",
+					p.ReqRewriterName, l, k)
+			}
+			p.ReqRewriter = ri
+		}
+		if no.OriginType == "rule" {
+			r, ok := nc.Rules[no.RuleName]
+			if !ok {
+				return nil, fmt.Errorf("invalid rule name [%s] provided in origin config [%s]",
+					no.RuleName, k)
+			}
+			r.Name = no.RuleName
+			no.RuleOptions = r
+		}
+
+		nc.Origins[k] = &no
+	}
+
+	return &nc, nil
+}
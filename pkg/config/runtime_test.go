@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyRuntimeRules(t *testing.T) {
+
+	c, _ := emptyTestConfig()
+
+	_, err := c.ApplyRuntimeRules("")
+	if err != ErrNoRuntimeRules {
+		t.Errorf("expected %v got %v", ErrNoRuntimeRules, err)
+	}
+
+	_, err = c.ApplyRuntimeRules("[[")
+	if err == nil {
+		t.Error("expected toml parsing error")
+	}
+
+	_, err = c.ApplyRuntimeRules("[frontend]\nlisten_port = 8480\n")
+	if err == nil || !strings.Contains(err.Error(), "[frontend]") {
+		t.Error("expected error for frontend section", err)
+	}
+
+	_, err = c.ApplyRuntimeRules("[origins.test]\norigin_url = 'http://127.0.0.1'\n")
+	if err == nil || !strings.Contains(err.Error(), "[origins.test.origin_url]") {
+		t.Error("expected error for origin url", err)
+	}
+
+	_, err = c.ApplyRuntimeRules(strings.Replace(testPaths, "origins.test", "origins.invalid", -1))
+	if err == nil || !strings.Contains(err.Error(), "invalid origin name") {
+		t.Error("expected error for invalid origin name", err)
+	}
+
+	nc, err := c.ApplyRuntimeRules(testRewriter + testPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nc == c || nc.Origins["test"] == c.Origins["test"] {
+		t.Error("expected a copy of the config")
+	}
+	if _, ok := c.CompiledRewriters["example"]; ok {
+		t.Error("expected running config rewriters to be unchanged")
+	}
+	p, ok := nc.Origins["test"].Paths["/-GET-HEAD"]
+	if !ok {
+		t.Fatal("expected updated path")
+	}
+	if p.ReqRewriter == nil {
+		t.Error("expected path rewriter to be resolved")
+	}
+	if _, ok = c.Origins["test"].Paths["/-GET-HEAD"]; ok {
+		t.Error("expected running config paths to be unchanged")
+	}
+
+	// the paths are kept when only the rewriters are updated, so they must still resolve
+	_, err = nc.ApplyRuntimeRules(strings.Replace(testRewriter, "example", "other", -1))
+	if err == nil || !strings.Contains(err.Error(), "invalid rewriter name") {
+		t.Error("expected error for invalid rewriter name", err)
+	}
+
+	nc.Origins["test"].OriginType = "rule"
+	nc.Origins["test"].RuleName = "example"
+	nc2, err := nc.ApplyRuntimeRules(testRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nc2.Origins["test"].RuleOptions != nc2.Rules["example"] {
+		t.Error("expected rule to be resolved")
+	}
+	if _, ok = nc2.Origins["test"].Paths["/-GET-HEAD"]; !ok {
+		t.Error("expected paths to be carried over")
+	}
+
+	_, err = nc2.ApplyRuntimeRules(strings.Replace(testRule, "example", "other", -1))
+	if err == nil || !strings.Contains(err.Error(), "invalid rule name") {
+		t.Error("expected error for invalid rule name", err)
+	}
+
+}
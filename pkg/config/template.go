@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// templateParam matches a ${param} placeholder in an origin template's string values
+var templateParam = regexp.MustCompile(`\$\{([A-Za-z0-9_\-]+)\}`)
+
+// expandTemplates merges the files listed in the TOML document's includes into the document,
+// and instantiates each origin that references an origin template. It returns the expanded
+// document and the paths of the included files, or the document unchanged when it uses neither.
+// The files are resolved relative to dir, and includes are an error when dir is empty
+func expandTemplates(tml, dir string, allowIncludes bool) (string, []string, error) {
+	doc := make(map[string]interface{})
+	if _, err := toml.Decode(tml, &doc); err != nil {
+		return "", nil, err
+	}
+
+	_, hasIncludes := doc["includes"]
+	if !hasIncludes && !usesTemplates(doc) {
+		return tml, nil, nil
+	}
+
+	var included []string
+	if hasIncludes {
+		if !allowIncludes {
+			return "", nil, fmt.Errorf("includes are only supported by config files")
+		}
+		var err error
+		if included, err = mergeIncludes(doc, dir); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := instantiateOrigins(doc); err != nil {
+		return "", nil, err
+	}
+	delete(doc, "origin_templates")
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), included, nil
+}
+
+func usesTemplates(doc map[string]interface{}) bool {
+	if _, ok := doc["origin_templates"]; ok {
+		return true
+	}
+	origins, _ := doc["origins"].(map[string]interface{})
+	for _, v := range origins {
+		if o, ok := v.(map[string]interface{}); ok {
+			if _, ok := o["template"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeIncludes merges the files matching each of the document's include patterns into
+// the document, in order, without overwriting any of the document's own values
+func mergeIncludes(doc map[string]interface{}, dir string) ([]string, error) {
+	patterns, ok := doc["includes"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("includes must be a list of file paths")
+	}
+	delete(doc, "includes")
+	included := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		pattern, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("includes must be a list of file paths")
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match include %s", p)
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			inc := make(map[string]interface{})
+			if _, err := toml.DecodeFile(f, &inc); err != nil {
+				return nil, fmt.Errorf("invalid include %s: %s", f, err.Error())
+			}
+			if _, ok := inc["includes"]; ok {
+				return nil, fmt.Errorf("invalid include %s: included files can't have includes", f)
+			}
+			mergeTables(doc, inc)
+			included = append(included, f)
+		}
+	}
+	return included, nil
+}
+
+// instantiateOrigins merges the origin template referenced by each origin into the origin,
+// without overwriting any of the origin's own values
+func instantiateOrigins(doc map[string]interface{}) error {
+	templates, _ := doc["origin_templates"].(map[string]interface{})
+	origins, _ := doc["origins"].(map[string]interface{})
+	for k, v := range origins {
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tn, ok := o["template"]
+		if !ok {
+			continue
+		}
+		name, _ := tn.(string)
+		t, ok := templates[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid template %v in origin config %s", tn, k)
+		}
+		params := map[string]string{"name": k}
+		if tp, ok := o["template_params"].(map[string]interface{}); ok {
+			for pk, pv := range tp {
+				params[pk] = fmt.Sprintf("%v", pv)
+			}
+		}
+		delete(o, "template")
+		delete(o, "template_params")
+		st, err := substituteParams(t, params)
+		if err != nil {
+			return fmt.Errorf("%s in origin config %s", err.Error(), k)
+		}
+		mergeTables(o, st.(map[string]interface{}))
+	}
+	return nil
+}
+
+// substituteParams returns a copy of the template value, with the ${param} placeholders of its
+// string values replaced by the provided parameters
+func substituteParams(v interface{}, params map[string]string) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		var err error
+		s := templateParam.ReplaceAllStringFunc(t, func(m string) string {
+			p := m[2 : len(m)-1]
+			if pv, ok := params[p]; ok {
+				return pv
+			}
+			err = fmt.Errorf("undefined template parameter %s", p)
+			return m
+		})
+		return s, err
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, mv := range t {
+			sv, err := substituteParams(mv, params)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = sv
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, lv := range t {
+			sv, err := substituteParams(lv, params)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = sv
+		}
+		return l, nil
+	case []map[string]interface{}:
+		l := make([]map[string]interface{}, len(t))
+		for i, lv := range t {
+			sv, err := substituteParams(lv, params)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = sv.(map[string]interface{})
+		}
+		return l, nil
+	}
+	return v, nil
+}
+
+// mergeTables merges the values of src into dst, recursing into the tables present in both,
+// without overwriting any values in dst
+func mergeTables(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		dv, ok := dst[k]
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+		dm, ok1 := dv.(map[string]interface{})
+		sm, ok2 := sv.(map[string]interface{})
+		if ok1 && ok2 {
+			mergeTables(dm, sm)
+		}
+	}
+}
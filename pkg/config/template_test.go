@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTemplatedConfig = `
+[origin_templates]
+  [origin_templates.prom]
+  origin_type = 'prometheus'
+  origin_url = 'http://${host}:9090/${name}'
+
+[origins]
+  [origins.a]
+  template = 'prom'
+  [origins.a.template_params]
+  host = 'a.example.com'
+`
+
+func TestExpandTemplates(t *testing.T) {
+
+	// a document without templates or includes passes through unchanged
+	const plain = "[origins]\n  [origins.a]\n  origin_url = 'http://${host}'\n"
+	tml, included, err := expandTemplates(plain, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tml != plain {
+		t.Errorf("expected %s got %s", plain, tml)
+	}
+	if included != nil {
+		t.Errorf("expected nil got %v", included)
+	}
+
+	tml, _, err = expandTemplates(testTemplatedConfig, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tml, `"http://a.example.com:9090/a"`) {
+		t.Errorf("expected expanded origin_url in %s", tml)
+	}
+	for _, k := range []string{"origin_templates", "template_params", "template ="} {
+		if strings.Contains(tml, k) {
+			t.Errorf("unexpected %s in %s", k, tml)
+		}
+	}
+
+	_, _, err = expandTemplates(strings.Replace(testTemplatedConfig,
+		"template = 'prom'", "template = 'other'", 1), "", false)
+	if err == nil || err.Error() != "invalid template other in origin config a" {
+		t.Errorf("expected error for invalid template, got %v", err)
+	}
+
+	_, _, err = expandTemplates("includes = [ 'x.conf' ]", "", false)
+	if err == nil {
+		t.Error("expected error for includes from a remote config")
+	}
+
+	_, _, err = expandTemplates("includes = [ 'nonexistent.*.conf' ]", "../../testdata", true)
+	if err == nil || err.Error() != "no files match include nonexistent.*.conf" {
+		t.Errorf("expected error for unmatched include, got %v", err)
+	}
+
+	_, _, err = expandTemplates("[[", "", false)
+	if err == nil {
+		t.Error("expected error for invalid toml")
+	}
+}
+
+func TestMergeTables(t *testing.T) {
+	dst := map[string]interface{}{
+		"a": "1",
+		"t": map[string]interface{}{"x": "1"},
+	}
+	src := map[string]interface{}{
+		"a": "2",
+		"b": "2",
+		"t": map[string]interface{}{"x": "2", "y": "2"},
+	}
+	mergeTables(dst, src)
+	if dst["a"] != "1" || dst["b"] != "2" {
+		t.Errorf("unexpected merge result %v", dst)
+	}
+	tm := dst["t"].(map[string]interface{})
+	if tm["x"] != "1" || tm["y"] != "2" {
+		t.Errorf("unexpected merge result %v", tm)
+	}
+}
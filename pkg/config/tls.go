@@ -18,10 +18,34 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 
 	origins "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/selfsigned"
 )
 
+// devCert is the self-signed certificate served in -dev-tls mode. It is generated once
+// per process, so clients that have accepted it continue to do so across config reloads
+var devCert *tls.Certificate
+var devCertLock sync.Mutex
+
+func devCertificate() (tls.Certificate, error) {
+	devCertLock.Lock()
+	defer devCertLock.Unlock()
+	if devCert == nil {
+		cert, err := selfsigned.Certificate(selfsigned.DefaultHosts, selfsigned.DefaultValidity)
+		if err != nil {
+			return cert, err
+		}
+		devCert = &cert
+	}
+	return *devCert, nil
+}
+
 // TLSCertConfig returns the crypto/tls configuration object with a list of name-bound
 // certs derived from the running config
 func (c *Config) TLSCertConfig() (*tls.Config, error) {
@@ -37,11 +61,13 @@ func (c *Config) TLSCertConfig() (*tls.Config, error) {
 	}
 
 	l := len(to)
-	if l == 0 {
+	if l == 0 && !c.Frontend.DevTLS && !c.ACME.Enabled() {
 		return nil, nil
 	}
 
 	tlsConfig := &tls.Config{Certificates: make([]tls.Certificate, l), NextProtos: []string{"h2"}}
+	c.Frontend.TLSPolicy.Apply(tlsConfig)
+	tlsConfig.SessionTicketsDisabled = c.Frontend.TLSSessionTicketsDisabled
 
 	for i, tc := range to {
 		tlsConfig.Certificates[i], err = tls.LoadX509KeyPair(tc.TLS.FullChainCertPath, tc.TLS.PrivateKeyPath)
@@ -50,6 +76,80 @@ func (c *Config) TLSCertConfig() (*tls.Config, error) {
 		}
 	}
 
+	// ACME certificates are served once they have been obtained, and are loaded again
+	// each time one is renewed
+	if c.ACME.Enabled() {
+		for _, k := range c.ACME.DomainNames() {
+			cert, err := tls.LoadX509KeyPair(c.ACME.CertificatePath(k), c.ACME.PrivateKeyPath(k))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+		}
+	}
+
+	// the dev cert is listed last, so any origin certificate matching the client's
+	// requested server name is preferred over it
+	if c.Frontend.DevTLS {
+		cert, err := devCertificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	// clients may authenticate with a certificate issued by one of the client CA's,
+	// which is verified before the request is handled
+	if len(c.Frontend.TLSClientCAPaths) > 0 {
+		tlsConfig.ClientCAs, err = clientCAPool(c.Frontend.TLSClientCAPaths)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+
+}
+
+// MetricsTLSConfig returns the TLS configuration of the metrics listener,
+// or nil if the metrics listener does not serve TLS
+func (c *Config) MetricsTLSConfig() (*tls.Config, error) {
+	if !c.Metrics.ServeTLS() {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.Metrics.TLSCertificatePath, c.Metrics.TLSPrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	c.Metrics.TLSPolicy.Apply(tlsConfig)
+	// unlike the frontend, the metrics listener requires a client certificate
+	// when client CA's are configured, as a means of authentication
+	if len(c.Metrics.TLSClientCAPaths) > 0 {
+		tlsConfig.ClientCAs, err = clientCAPool(c.Metrics.TLSClientCAPaths)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
 	return tlsConfig, nil
+}
 
+// clientCAPool returns a certificate pool of the CA certificates in the provided files
+func clientCAPool(paths []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in tls client ca file: %s", path)
+		}
+	}
+	return pool, nil
 }
@@ -17,8 +17,13 @@
 package config
 
 import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	acme "github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/tls/options"
 	tlstest "github.com/tricksterproxy/trickster/pkg/util/testing/tls"
 )
@@ -46,6 +51,17 @@ func TestTLSCertConfig(t *testing.T) {
 		t.Error(err)
 	}
 
+	// test dev tls with 0 origins configured
+	config.Frontend.DevTLS = true
+	n, err = config.TLSCertConfig()
+	if err != nil {
+		t.Error(err)
+	}
+	if n == nil || len(n.Certificates) != 1 {
+		t.Error("expected config with 1 cert")
+	}
+	config.Frontend.DevTLS = false
+
 	tls01, closer01, err01 := tlsConfig("")
 	if closer01 != nil {
 		defer closer01()
@@ -62,6 +78,29 @@ func TestTLSCertConfig(t *testing.T) {
 		t.Error(err)
 	}
 
+	// test client certificate verification
+	config.Frontend.TLSClientCAPaths = []string{tls01.FullChainCertPath}
+	n, err = config.TLSCertConfig()
+	if err != nil {
+		t.Error(err)
+	}
+	if n == nil || n.ClientCAs == nil || n.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Error("expected client certificate verification")
+	}
+
+	config.Frontend.TLSClientCAPaths = []string{tls01.PrivateKeyPath}
+	_, err = config.TLSCertConfig()
+	if err == nil {
+		t.Error("expected error for client ca file with no certificates")
+	}
+
+	config.Frontend.TLSClientCAPaths = []string{"/nonexistent/ca.pem"}
+	_, err = config.TLSCertConfig()
+	if err == nil {
+		t.Error("expected error for missing client ca file")
+	}
+	config.Frontend.TLSClientCAPaths = nil
+
 	// test config with key file that has invalid key data
 	expectedErr := "tls: failed to find any PEM data in key input"
 	tls05, closer05, err05 := tlsConfig("invalid-key")
@@ -94,6 +133,153 @@ func TestTLSCertConfig(t *testing.T) {
 
 }
 
+func TestTLSCertConfigACME(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "trickster-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := NewConfig()
+	config.Frontend.ServeTLS = true
+	config.ACME.StoragePath = dir
+	config.ACME.Domains = map[string]*acme.DomainOptions{
+		"metrics": {Names: []string{"*.metrics.example.com"}},
+		"other":   {Names: []string{"other.example.com"}},
+	}
+
+	// certificates that have not been obtained yet are skipped
+	n, err := config.TLSCertConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == nil || len(n.Certificates) != 0 {
+		t.Error("expected config with 0 certs")
+	}
+
+	kb, cb, err := tlstest.GetTestKeyAndCert(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(config.ACME.CertificatePath("metrics")), 0700)
+	ioutil.WriteFile(config.ACME.CertificatePath("metrics"), cb, 0600)
+	ioutil.WriteFile(config.ACME.PrivateKeyPath("metrics"), kb, 0600)
+
+	n, err = config.TLSCertConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == nil || len(n.Certificates) != 1 {
+		t.Error("expected config with 1 cert")
+	}
+
+	ioutil.WriteFile(config.ACME.PrivateKeyPath("metrics"), []byte("invalid"), 0600)
+	_, err = config.TLSCertConfig()
+	if err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
+
+func TestMetricsTLSConfig(t *testing.T) {
+
+	config := NewConfig()
+
+	// the metrics listener does not serve tls by default
+	n, err := config.MetricsTLSConfig()
+	if n != nil || err != nil {
+		t.Errorf("expected nil config and error, got %v %v", n, err)
+	}
+
+	tls01, closer01, err01 := tlsConfig("")
+	if closer01 != nil {
+		defer closer01()
+	}
+	if err01 != nil {
+		t.Fatal(err01)
+	}
+
+	config.Metrics.TLSCertificatePath = tls01.FullChainCertPath
+	config.Metrics.TLSPrivateKeyPath = tls01.PrivateKeyPath
+	n, err = config.MetricsTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Certificates) != 1 {
+		t.Errorf("expected %d got %d", 1, len(n.Certificates))
+	}
+	if n.ClientAuth != tls.NoClientCert {
+		t.Errorf("expected %d got %d", tls.NoClientCert, n.ClientAuth)
+	}
+
+	// client certificates are required when client CA's are configured
+	config.Metrics.TLSClientCAPaths = []string{tls01.FullChainCertPath}
+	n, err = config.MetricsTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("expected %d got %d", tls.RequireAndVerifyClientCert, n.ClientAuth)
+	}
+
+	config.Metrics.TLSClientCAPaths = []string{tls01.PrivateKeyPath}
+	_, err = config.MetricsTLSConfig()
+	if err == nil {
+		t.Error("expected error for client ca file without certificates")
+	}
+
+	config.Metrics.TLSPrivateKeyPath = "/nonexistent/key.pem"
+	_, err = config.MetricsTLSConfig()
+	if err == nil {
+		t.Error("expected error for missing private key")
+	}
+}
+
+func TestProcessMetricsConfig(t *testing.T) {
+
+	config := NewConfig()
+	if err := config.processMetricsConfig(); err != nil {
+		t.Error(err)
+	}
+
+	config.Metrics.TLSCertificatePath = "cert.pem"
+	if err := config.processMetricsConfig(); err != ErrInvalidMetricsTLS {
+		t.Errorf("expected %v got %v", ErrInvalidMetricsTLS, err)
+	}
+
+	config.Metrics.TLSCertificatePath = ""
+	config.Metrics.TLSClientCAPaths = []string{"ca.pem"}
+	if err := config.processMetricsConfig(); err != ErrInvalidMetricsTLS {
+		t.Errorf("expected %v got %v", ErrInvalidMetricsTLS, err)
+	}
+
+	config.Metrics.TLSClientCAPaths = nil
+	config.Metrics.FrontendAdminDisabled = true
+	if err := config.processMetricsConfig(); err != nil {
+		t.Error(err)
+	}
+	if len(config.LoaderWarnings) != 1 {
+		t.Errorf("expected %d got %d", 1, len(config.LoaderWarnings))
+	}
+
+	config.Metrics.ServePurge = true
+	if err := config.processMetricsConfig(); err != ErrUnauthenticatedPurge {
+		t.Errorf("expected %v got %v", ErrUnauthenticatedPurge, err)
+	}
+
+	config.Metrics.ServePurge = false
+	config.Metrics.ServeAdmin = true
+	if err := config.processMetricsConfig(); err != ErrUnauthenticatedAdmin {
+		t.Errorf("expected %v got %v", ErrUnauthenticatedAdmin, err)
+	}
+
+	config.Metrics.ServePurge = true
+	config.Metrics.AuthorizerName = "admin"
+	if err := config.processMetricsConfig(); err != nil {
+		t.Error(err)
+	}
+}
+
 func tlsConfig(condition string) (*options.Options, func(), error) {
 
 	kf, cf, closer, err := tlstest.GetTestKeyAndCertFiles(condition)
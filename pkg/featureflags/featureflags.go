@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package featureflags gates Trickster's behaviors by percentage of requests and by origin,
+// so that a risky behavior can be rolled out incrementally, and rolled back instantly
+package featureflags
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tricksterproxy/trickster/pkg/featureflags/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Names of the behaviors that can be gated by a feature flag
+const (
+	FastJSONDecode = options.FastJSONDecode
+	FastForward    = options.FastForward
+)
+
+// flag is a feature flag prepared for evaluation
+type flag struct {
+	options *options.Options
+	origins map[string]bool
+}
+
+// flags holds the running feature flags, as a map[string]*flag that is replaced, never modified
+var flags atomic.Value
+
+// updateLock serializes the updates of the running feature flags
+var updateLock sync.Mutex
+
+func init() {
+	flags.Store(map[string]*flag{})
+}
+
+func newFlag(o *options.Options) *flag {
+	f := &flag{options: o.Clone()}
+	if len(o.Origins) > 0 {
+		f.origins = make(map[string]bool, len(o.Origins))
+		for _, n := range o.Origins {
+			f.origins[n] = true
+		}
+	}
+	return f
+}
+
+// Apply replaces the running feature flags with the provided flags
+func Apply(mo map[string]*options.Options) {
+	m := make(map[string]*flag, len(mo))
+	for k, o := range mo {
+		m[k] = newFlag(o)
+	}
+	updateLock.Lock()
+	flags.Store(m)
+	updateLock.Unlock()
+}
+
+// Update replaces the running feature flags of the same names as the provided flags,
+// and keeps the rest
+func Update(mo map[string]*options.Options) {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+	cm := flags.Load().(map[string]*flag)
+	m := make(map[string]*flag, len(cm)+len(mo))
+	for k, f := range cm {
+		m[k] = f
+	}
+	for k, o := range mo {
+		m[k] = newFlag(o)
+	}
+	flags.Store(m)
+}
+
+// Flags returns a copy of the running feature flags
+func Flags() map[string]*options.Options {
+	cm := flags.Load().(map[string]*flag)
+	mo := make(map[string]*options.Options, len(cm))
+	for k, f := range cm {
+		mo[k] = f.options.Clone()
+	}
+	return mo
+}
+
+// Enabled returns true if the named behavior is to be used for a request to the named origin.
+// When a flag applies to the request, key consistently places it in or out of the flag's
+// percentage, and an empty key places it randomly. Otherwise, the configured value is returned
+func Enabled(name, originName, key string, configured bool) bool {
+	f, ok := flags.Load().(map[string]*flag)[name]
+	if !ok || (f.origins != nil && !f.origins[originName]) {
+		return configured
+	}
+	v := f.options.Enabled && inPercentage(name, key, f.options.Percentage)
+	result := "off"
+	if v {
+		result = "on"
+	}
+	metrics.RuntimeFeatureFlagEvaluations.WithLabelValues(name, originName, result).Inc()
+	return v
+}
+
+// inPercentage returns true if the key falls within the percentage of keys for the named flag
+func inPercentage(name, key string, percentage int) bool {
+	if percentage >= 100 {
+		return true
+	}
+	if percentage <= 0 {
+		return false
+	}
+	if key == "" {
+		return rand.Intn(100) < percentage
+	}
+	// the flag name is hashed with the key, so that each flag selects different keys
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32()%100) < percentage
+}
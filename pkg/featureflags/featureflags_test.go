@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package featureflags
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/featureflags/options"
+)
+
+func testFlag(name string, enabled bool, percentage int, origins ...string) *options.Options {
+	o := options.NewOptions()
+	o.Name = name
+	o.Enabled = enabled
+	o.Percentage = percentage
+	o.Origins = origins
+	return o
+}
+
+func TestEnabled(t *testing.T) {
+
+	defer Apply(nil)
+
+	// with no flag, the configured value is used
+	if !Enabled(FastJSONDecode, "prom1", "key", true) {
+		t.Error("expected configured value")
+	}
+	if Enabled(FastJSONDecode, "prom1", "key", false) {
+		t.Error("expected configured value")
+	}
+
+	Apply(map[string]*options.Options{
+		FastJSONDecode: testFlag(FastJSONDecode, true, 100, "prom1"),
+		FastForward:    testFlag(FastForward, false, 100),
+	})
+
+	if !Enabled(FastJSONDecode, "prom1", "key", false) {
+		t.Error("expected flag to turn the behavior on")
+	}
+	// the flag does not apply to other origins
+	if Enabled(FastJSONDecode, "prom2", "key", false) {
+		t.Error("expected configured value")
+	}
+	// a disabled flag turns the behavior off
+	if Enabled(FastForward, "prom2", "key", true) {
+		t.Error("expected flag to turn the behavior off")
+	}
+}
+
+func TestEnabledPercentage(t *testing.T) {
+
+	defer Apply(nil)
+
+	Apply(map[string]*options.Options{
+		FastJSONDecode: testFlag(FastJSONDecode, true, 25),
+	})
+
+	var on int
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		v := Enabled(FastJSONDecode, "prom1", key, false)
+		if v {
+			on++
+		}
+		// a key is consistently in or out of the percentage
+		if Enabled(FastJSONDecode, "prom1", key, false) != v {
+			t.Fatalf("inconsistent evaluation for key %s", key)
+		}
+	}
+	if on < 2250 || on > 2750 {
+		t.Errorf("expected about %d got %d", 2500, on)
+	}
+
+	on = 0
+	for i := 0; i < 10000; i++ {
+		if Enabled(FastJSONDecode, "prom1", "", false) {
+			on++
+		}
+	}
+	if on < 2250 || on > 2750 {
+		t.Errorf("expected about %d got %d", 2500, on)
+	}
+
+	Update(map[string]*options.Options{
+		FastJSONDecode: testFlag(FastJSONDecode, true, 0),
+	})
+	for i := 0; i < 100; i++ {
+		if Enabled(FastJSONDecode, "prom1", strconv.Itoa(i), true) {
+			t.Fatal("expected flag to turn the behavior off")
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+
+	defer Apply(nil)
+
+	Apply(map[string]*options.Options{
+		FastJSONDecode: testFlag(FastJSONDecode, true, 50),
+	})
+	Update(map[string]*options.Options{
+		FastForward: testFlag(FastForward, false, 100),
+	})
+
+	mo := Flags()
+	if len(mo) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(mo))
+	}
+	if mo[FastJSONDecode].Percentage != 50 {
+		t.Errorf("expected %d got %d", 50, mo[FastJSONDecode].Percentage)
+	}
+	if mo[FastForward].Enabled {
+		t.Errorf("expected %t got %t", false, mo[FastForward].Enabled)
+	}
+
+	// the returned flags are copies
+	mo[FastJSONDecode].Percentage = 10
+	if Flags()[FastJSONDecode].Percentage != 50 {
+		t.Error("expected the running flag to be unchanged")
+	}
+
+	Apply(nil)
+	if len(Flags()) != 0 {
+		t.Errorf("expected %d got %d", 0, len(Flags()))
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the options of the feature flags that gate Trickster's
+// behaviors by percentage of requests and by origin
+package options
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/util/strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Names of the behaviors that can be gated by a feature flag
+const (
+	// FastJSONDecode gates the reflection-free decoding of Prometheus and InfluxDB timeseries
+	FastJSONDecode = "fast_json_decode"
+	// FastForward gates the fast forwarding of timeseries queries to the current time
+	FastForward = "fast_forward"
+)
+
+// Names is the set of behaviors that can be gated by a feature flag
+var Names = map[string]bool{
+	FastJSONDecode: true,
+	FastForward:    true,
+}
+
+// ErrInvalidPercentage is returned when a feature flag percentage is not between 0 and 100
+var ErrInvalidPercentage = errors.New("invalid feature flag percentage")
+
+// Options is a feature flag, which overrides whether its behavior is used, for a percentage of
+// the requests of some or all origins. Requests to which the flag does not apply use the
+// behavior as configured
+type Options struct {
+	// Name is the Name of the flag, taken from the Key in the feature_flags map
+	Name string `toml:"-"`
+	// Enabled indicates whether the behavior is used for the requests to which the flag applies.
+	// Setting Enabled to false turns the behavior off for those requests
+	Enabled bool `toml:"enabled"`
+	// Percentage is the percentage of the requests, from 0 to 100, for which the behavior is
+	// used when the flag is enabled. A request is consistently in or out of the percentage
+	Percentage int `toml:"percentage"`
+	// Origins provides the names of the origins to which the flag applies. When empty,
+	// the flag applies to all origins
+	Origins []string `toml:"origins"`
+}
+
+// NewOptions returns a new *Options with the default values
+func NewOptions() *Options {
+	return &Options{
+		Enabled:    defaults.DefaultFeatureFlagEnabled,
+		Percentage: defaults.DefaultFeatureFlagPercentage,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Name:       o.Name,
+		Enabled:    o.Enabled,
+		Percentage: o.Percentage,
+		Origins:    strings.CloneList(o.Origins),
+	}
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if _, ok := Names[o.Name]; !ok {
+		return fmt.Errorf("unknown feature flag %s", o.Name)
+	}
+	if o.Percentage < 0 || o.Percentage > 100 {
+		return ErrInvalidPercentage
+	}
+	return nil
+}
+
+// ProcessFeatureFlagOptions applies defaults to and validates the provided feature flags,
+// whose keys in the metadata are under the provided key
+func ProcessFeatureFlagOptions(mo map[string]*Options, metadata *toml.MetaData,
+	key string) error {
+	for k, v := range mo {
+		if v == nil {
+			v = NewOptions()
+			mo[k] = v
+		} else if metadata != nil {
+			if !metadata.IsDefined(key, k, "enabled") {
+				v.Enabled = defaults.DefaultFeatureFlagEnabled
+			}
+			if !metadata.IsDefined(key, k, "percentage") {
+				v.Percentage = defaults.DefaultFeatureFlagPercentage
+			}
+		}
+		v.Name = k
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
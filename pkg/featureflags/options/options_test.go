@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+const testFlags = `
+[feature_flags]
+  [feature_flags.fast_json_decode]
+  percentage = 25
+  origins = ['prom1']
+  [feature_flags.fast_forward]
+  enabled = false
+`
+
+type testConfig struct {
+	FeatureFlags map[string]*Options `toml:"feature_flags"`
+}
+
+func TestProcessFeatureFlagOptions(t *testing.T) {
+
+	c := &testConfig{}
+	md, err := toml.Decode(testFlags, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ProcessFeatureFlagOptions(c.FeatureFlags, &md, "feature_flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := c.FeatureFlags[FastJSONDecode]
+	if o.Name != FastJSONDecode {
+		t.Errorf("expected %s got %s", FastJSONDecode, o.Name)
+	}
+	if !o.Enabled {
+		t.Errorf("expected %t got %t", true, o.Enabled)
+	}
+	if o.Percentage != 25 {
+		t.Errorf("expected %d got %d", 25, o.Percentage)
+	}
+	if len(o.Origins) != 1 || o.Origins[0] != "prom1" {
+		t.Errorf("unexpected origins %v", o.Origins)
+	}
+
+	o = c.FeatureFlags[FastForward]
+	if o.Enabled {
+		t.Errorf("expected %t got %t", false, o.Enabled)
+	}
+	if o.Percentage != 100 {
+		t.Errorf("expected %d got %d", 100, o.Percentage)
+	}
+
+	c.FeatureFlags["unknown"] = nil
+	err = ProcessFeatureFlagOptions(c.FeatureFlags, nil, "feature_flags")
+	if err == nil || err.Error() != "unknown feature flag unknown" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	o := NewOptions()
+	o.Name = FastForward
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	o.Percentage = 101
+	if err := o.Validate(); err != ErrInvalidPercentage {
+		t.Errorf("expected %v got %v", ErrInvalidPercentage, err)
+	}
+
+	o.Percentage = -1
+	if err := o.Validate(); err != ErrInvalidPercentage {
+		t.Errorf("expected %v got %v", ErrInvalidPercentage, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	o := NewOptions()
+	o.Name = FastJSONDecode
+	o.Origins = []string{"prom1", "prom2"}
+	o.Percentage = 10
+
+	o2 := o.Clone()
+	if o2.Name != o.Name || o2.Enabled != o.Enabled || o2.Percentage != o.Percentage ||
+		len(o2.Origins) != 2 {
+		t.Errorf("clone mismatch: %v", o2)
+	}
+
+	o2.Origins[0] = "changed"
+	if o.Origins[0] != "prom1" {
+		t.Error("clone shares the origins list")
+	}
+}
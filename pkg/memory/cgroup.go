@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// cgroupLimitPaths are the cgroup v2 and v1 memory limit files, in order of preference
+var cgroupLimitPaths = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// unlimitedCgroup is the threshold above which a cgroup v1 limit indicates no limit
+const unlimitedCgroup = 1 << 62
+
+// cgroupLimit returns the memory limit of the process's cgroup, or 0 if there is none
+func cgroupLimit() int64 {
+	for _, p := range cgroupLimitPaths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		s := strings.TrimSpace(string(b))
+		if s == "max" {
+			return 0
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v <= 0 || v >= unlimitedCgroup {
+			return 0
+		}
+		return v
+	}
+	return 0
+}
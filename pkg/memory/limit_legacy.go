@@ -0,0 +1,26 @@
+//go:build !go1.19
+// +build !go1.19
+
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+// setMemoryLimit returns false, since runtimes prior to Go 1.19 do not support a soft
+// memory limit, which the Governor instead enforces by forcing collection
+func setMemoryLimit(b int64) bool {
+	return false
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memory applies Trickster's soft memory limit and garbage collection settings,
+// and tracks whether memory use is above the watermark at which Trickster sheds memory
+package memory
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/memory/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// defaultGCPercent is the runtime's garbage collection target percentage when GOGC is unset
+const defaultGCPercent = 100
+
+// pressure is 1 while memory use is above the watermark
+var pressure int32
+
+// evictionRatio is the active Governor's PressureEvictionRatio
+var evictionRatio = struct {
+	sync.RWMutex
+	value float64
+}{}
+
+// UnderPressure returns true while memory use is above the watermark
+func UnderPressure() bool {
+	return atomic.LoadInt32(&pressure) == 1
+}
+
+// PressureEvictionBytes returns the number of bytes that a memory cache of the provided
+// size should evict, which is 0 unless memory use is above the watermark
+func PressureEvictionBytes(cacheSize int64) int64 {
+	if !UnderPressure() || cacheSize <= 0 {
+		return 0
+	}
+	evictionRatio.RLock()
+	r := evictionRatio.value
+	evictionRatio.RUnlock()
+	return int64(float64(cacheSize) * r)
+}
+
+func setPressure(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	if atomic.SwapInt32(&pressure, v) != v {
+		metrics.RuntimeMemoryPressure.Set(float64(v))
+	}
+}
+
+// Governor applies the memory options to the runtime and monitors memory use
+type Governor struct {
+	options   *options.Options
+	limit     int64
+	watermark int64
+	native    bool
+	quit      chan struct{}
+	log       *tl.Logger
+}
+
+// Start applies the memory options to the runtime, and begins monitoring memory use
+// against the watermark when there is a soft memory limit
+func Start(o *options.Options, log *tl.Logger) *Governor {
+	g := &Governor{options: o, log: log, quit: make(chan struct{})}
+
+	evictionRatio.Lock()
+	evictionRatio.value = o.PressureEvictionRatio
+	evictionRatio.Unlock()
+
+	// the GOGC and GOMEMLIMIT environment variables take precedence over the config,
+	// otherwise any settings from a previous config are reverted to the runtime defaults
+	if os.Getenv("GOGC") == "" {
+		if o.GCPercent != 0 {
+			debug.SetGCPercent(o.GCPercent)
+		} else {
+			debug.SetGCPercent(defaultGCPercent)
+		}
+	}
+
+	g.limit = o.LimitBytes
+	var source = "config"
+	if g.limit == 0 && o.CgroupLimitRatio > 0 {
+		if cl := cgroupLimit(); cl > 0 {
+			g.limit = int64(float64(cl) * o.CgroupLimitRatio)
+			source = "cgroup"
+		}
+	}
+	if os.Getenv("GOMEMLIMIT") == "" {
+		if g.limit > 0 {
+			g.native = setMemoryLimit(g.limit)
+		} else {
+			setMemoryLimit(math.MaxInt64)
+		}
+	}
+	metrics.RuntimeMemoryLimit.Set(float64(g.limit))
+
+	if g.limit == 0 {
+		setPressure(false)
+		return g
+	}
+
+	g.watermark = int64(float64(g.limit) * o.WatermarkRatio)
+	log.Info("applying soft memory limit", tl.Pairs{"limitBytes": g.limit, "source": source,
+		"watermarkBytes": g.watermark, "nativeLimit": g.native})
+	go g.monitor()
+	return g
+}
+
+// Limit returns the soft memory limit in bytes, or 0 if there is none
+func (g *Governor) Limit() int64 {
+	return g.limit
+}
+
+// Stop ends the monitoring of memory use
+func (g *Governor) Stop() {
+	if g == nil || g.quit == nil {
+		return
+	}
+	select {
+	case <-g.quit:
+	default:
+		close(g.quit)
+	}
+}
+
+func (g *Governor) monitor() {
+	t := time.NewTicker(g.options.CheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-g.quit:
+			return
+		case <-t.C:
+			g.check(inUse())
+		}
+	}
+}
+
+// check updates the pressure state for the provided memory use
+func (g *Governor) check(used int64) {
+	wasUnder := UnderPressure()
+	isUnder := used > g.watermark
+	setPressure(isUnder)
+	if isUnder && !wasUnder {
+		g.log.Warn("memory use is above watermark, shedding memory",
+			tl.Pairs{"usedBytes": used, "watermarkBytes": g.watermark, "limitBytes": g.limit})
+	} else if !isUnder && wasUnder {
+		g.log.Info("memory use is below watermark",
+			tl.Pairs{"usedBytes": used, "watermarkBytes": g.watermark})
+	}
+	// without native soft limit support, collection is forced when the limit is exceeded
+	if !g.native && used > g.limit {
+		debug.FreeOSMemory()
+	}
+}
+
+// inUse returns the memory obtained from the OS that has not been returned to it,
+// which is the measure the runtime's soft memory limit applies to
+func inUse() int64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.Sys - ms.HeapReleased)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/memory/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestGovernorCheck(t *testing.T) {
+
+	defer setPressure(false)
+
+	o := options.NewOptions()
+	o.LimitBytes = 1000
+	g := Start(o, tl.ConsoleLogger("error"))
+	defer g.Stop()
+
+	if g.Limit() != 1000 {
+		t.Errorf("expected %d got %d", 1000, g.Limit())
+	}
+
+	g.check(800)
+	if UnderPressure() {
+		t.Error("expected no memory pressure below the watermark")
+	}
+	if PressureEvictionBytes(100) != 0 {
+		t.Errorf("expected %d got %d", 0, PressureEvictionBytes(100))
+	}
+
+	g.check(900)
+	if !UnderPressure() {
+		t.Error("expected memory pressure above the watermark")
+	}
+	if PressureEvictionBytes(100) != 20 {
+		t.Errorf("expected %d got %d", 20, PressureEvictionBytes(100))
+	}
+
+	g.check(100)
+	if UnderPressure() {
+		t.Error("expected no memory pressure below the watermark")
+	}
+
+	g.Stop()
+	g.Stop()
+
+	o = options.NewOptions()
+	o.CgroupLimitRatio = 0
+	g = Start(o, tl.ConsoleLogger("error"))
+	if g.Limit() != 0 {
+		t.Errorf("expected %d got %d", 0, g.Limit())
+	}
+	g.Stop()
+
+}
+
+func TestCgroupLimit(t *testing.T) {
+
+	dir, err := ioutil.TempDir("/tmp", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orig := cgroupLimitPaths
+	defer func() { cgroupLimitPaths = orig }()
+
+	v2 := filepath.Join(dir, "memory.max")
+	v1 := filepath.Join(dir, "memory.limit_in_bytes")
+	cgroupLimitPaths = []string{v2, v1}
+
+	if l := cgroupLimit(); l != 0 {
+		t.Errorf("expected %d got %d", 0, l)
+	}
+
+	ioutil.WriteFile(v1, []byte("9223372036854771712\n"), 0600)
+	if l := cgroupLimit(); l != 0 {
+		t.Errorf("expected %d got %d", 0, l)
+	}
+
+	ioutil.WriteFile(v1, []byte("536870912\n"), 0600)
+	if l := cgroupLimit(); l != 536870912 {
+		t.Errorf("expected %d got %d", 536870912, l)
+	}
+
+	ioutil.WriteFile(v2, []byte("max\n"), 0600)
+	if l := cgroupLimit(); l != 0 {
+		t.Errorf("expected %d got %d", 0, l)
+	}
+
+	ioutil.WriteFile(v2, []byte("1073741824\n"), 0600)
+	if l := cgroupLimit(); l != 1073741824 {
+		t.Errorf("expected %d got %d", 1073741824, l)
+	}
+
+	o := options.NewOptions()
+	o.CgroupLimitRatio = 0.5
+	g := Start(o, tl.ConsoleLogger("error"))
+	defer g.Stop()
+	if g.Limit() != 536870912 {
+		t.Errorf("expected %d got %d", 536870912, g.Limit())
+	}
+
+}
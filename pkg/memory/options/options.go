@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the soft memory limit and garbage collection options
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures Trickster's soft memory limit, garbage collection, and the
+// memory use watermark at which Trickster begins shedding memory
+type Options struct {
+	// LimitBytes is the soft memory limit, as with GOMEMLIMIT. When 0, the limit is
+	// derived from any detected cgroup memory limit
+	LimitBytes int64 `toml:"limit_bytes"`
+	// CgroupLimitRatio is the fraction of a detected cgroup memory limit that is used as the
+	// soft memory limit when LimitBytes is 0. A value of 0 disables cgroup limit detection
+	CgroupLimitRatio float64 `toml:"cgroup_limit_ratio"`
+	// GCPercent is the garbage collection target percentage, as with GOGC. When 0, the
+	// runtime's setting is unchanged, and -1 disables collection until the soft limit is reached
+	GCPercent int `toml:"gc_percent"`
+	// WatermarkRatio is the fraction of the soft memory limit above which Trickster is under
+	// memory pressure. While under pressure, memory caches proactively evict objects, and
+	// cache misses are streamed to the client rather than buffered for caching
+	WatermarkRatio float64 `toml:"watermark_ratio"`
+	// PressureEvictionRatio is the fraction of each memory cache's size that is evicted during
+	// each reap while under memory pressure
+	PressureEvictionRatio float64 `toml:"pressure_eviction_ratio"`
+	// CheckIntervalMS is the interval at which memory use is compared to the watermark
+	CheckIntervalMS int `toml:"check_interval_ms"`
+
+	// CheckInterval is the parsed value of CheckIntervalMS
+	CheckInterval time.Duration `toml:"-"`
+}
+
+// Errors returned by Validate
+var (
+	ErrInvalidLimitBytes            = errors.New("memory limit_bytes must be 0 or greater")
+	ErrInvalidCgroupLimitRatio      = errors.New("memory cgroup_limit_ratio must be between 0 and 1")
+	ErrInvalidGCPercent             = errors.New("memory gc_percent must be -1 or greater")
+	ErrInvalidWatermarkRatio        = errors.New("memory watermark_ratio must be greater than 0 and no more than 1")
+	ErrInvalidPressureEvictionRatio = errors.New("memory pressure_eviction_ratio must be greater than 0 and no more than 1")
+	ErrInvalidCheckInterval         = errors.New("memory check_interval_ms must be greater than 0")
+)
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		CgroupLimitRatio:      d.DefaultMemoryCgroupLimitRatio,
+		WatermarkRatio:        d.DefaultMemoryWatermarkRatio,
+		PressureEvictionRatio: d.DefaultMemoryPressureEvictionRatio,
+		CheckIntervalMS:       d.DefaultMemoryCheckIntervalMS,
+		CheckInterval:         d.DefaultMemoryCheckIntervalMS * time.Millisecond,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		LimitBytes:            o.LimitBytes,
+		CgroupLimitRatio:      o.CgroupLimitRatio,
+		GCPercent:             o.GCPercent,
+		WatermarkRatio:        o.WatermarkRatio,
+		PressureEvictionRatio: o.PressureEvictionRatio,
+		CheckIntervalMS:       o.CheckIntervalMS,
+		CheckInterval:         o.CheckInterval,
+	}
+}
+
+// Validate returns an error if any of the options are out of range
+func (o *Options) Validate() error {
+	if o.LimitBytes < 0 {
+		return ErrInvalidLimitBytes
+	}
+	if o.CgroupLimitRatio < 0 || o.CgroupLimitRatio > 1 {
+		return ErrInvalidCgroupLimitRatio
+	}
+	if o.GCPercent < -1 {
+		return ErrInvalidGCPercent
+	}
+	if o.WatermarkRatio <= 0 || o.WatermarkRatio > 1 {
+		return ErrInvalidWatermarkRatio
+	}
+	if o.PressureEvictionRatio <= 0 || o.PressureEvictionRatio > 1 {
+		return ErrInvalidPressureEvictionRatio
+	}
+	if o.CheckIntervalMS <= 0 {
+		return ErrInvalidCheckInterval
+	}
+	return nil
+}
+
+// SetDurations sets the parsed duration fields from their configured values
+func (o *Options) SetDurations() {
+	o.CheckInterval = time.Duration(o.CheckIntervalMS) * time.Millisecond
+}
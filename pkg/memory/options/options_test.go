@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+
+	o := NewOptions()
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		f   func(*Options)
+		err error
+	}{
+		{func(o *Options) { o.LimitBytes = -1 }, ErrInvalidLimitBytes},
+		{func(o *Options) { o.CgroupLimitRatio = 1.5 }, ErrInvalidCgroupLimitRatio},
+		{func(o *Options) { o.GCPercent = -2 }, ErrInvalidGCPercent},
+		{func(o *Options) { o.WatermarkRatio = 0 }, ErrInvalidWatermarkRatio},
+		{func(o *Options) { o.PressureEvictionRatio = 2 }, ErrInvalidPressureEvictionRatio},
+		{func(o *Options) { o.CheckIntervalMS = 0 }, ErrInvalidCheckInterval},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		test.f(o)
+		if err := o.Validate(); err != test.err {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+		}
+	}
+
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.LimitBytes = 1024
+	o.CheckIntervalMS = 500
+	o.SetDurations()
+	o2 := o.Clone()
+	if o2.LimitBytes != 1024 || o2.CheckInterval != 500*time.Millisecond {
+		t.Errorf("unexpected clone %v", o2)
+	}
+}
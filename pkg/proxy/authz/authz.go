@@ -0,0 +1,258 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package authz provides a client for external authorization services that
+// allow or deny requests to an origin, in the style of Envoy's ext_authz
+package authz
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Headers that convey the request's context to the authorization service
+const (
+	// HeaderOriginName provides the name of the origin the request is routed to
+	HeaderOriginName = "X-Trickster-Origin"
+	// HeaderClientAddress provides the address of the originating client
+	HeaderClientAddress = "X-Trickster-Client-Address"
+	// HeaderClientSubject provides the subject of the client's verified TLS certificate
+	HeaderClientSubject = "X-Trickster-Client-Subject"
+	// HeaderForwardedHost provides the Host requested by the client
+	HeaderForwardedHost = "X-Forwarded-Host"
+	// HeaderUser provides the name of the user, when the request's identity is known
+	HeaderUser = "X-Trickster-User"
+	// HeaderGroups provides the comma-separated groups of the user, when the request's identity is known
+	HeaderGroups = "X-Trickster-Groups"
+)
+
+// maxDeniedBodySize is the largest authorization service response body passed to a denied client
+const maxDeniedBodySize = 64 * 1024
+
+// Decision is the outcome of an authorization check
+type Decision struct {
+	// Allowed is true when the request may proceed to the origin
+	Allowed bool
+	// StatusCode is the authorization service's response status
+	StatusCode int
+	// Header holds the headers to add to the upstream request when Allowed,
+	// or to the client response when denied
+	Header http.Header
+	// Body is the response body to send to the client when denied
+	Body []byte
+}
+
+type entry struct {
+	decision *Decision
+	expires  time.Time
+}
+
+// Authorizer checks requests against an external authorization service,
+// caching the decisions for the configured TTL
+type Authorizer struct {
+	originName string
+	options    *options.Options
+	client     *http.Client
+	decisions  map[string]*entry
+	mtx        sync.Mutex
+}
+
+// New returns a new Authorizer for the named origin
+func New(originName string, o *options.Options) *Authorizer {
+	return &Authorizer{
+		originName: originName,
+		options:    o,
+		client: &http.Client{
+			Timeout: o.Timeout,
+			// redirects are passed back to the client as a denial
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		decisions: make(map[string]*entry),
+	}
+}
+
+// Options returns the Authorizer's options
+func (a *Authorizer) Options() *options.Options {
+	return a.options
+}
+
+// Check returns the authorization decision for the provided request. An error
+// is returned only when the authorization service could not provide a decision
+func (a *Authorizer) Check(r *http.Request) (*Decision, error) {
+
+	ar := a.authzRequest(r)
+	key := cacheKey(ar)
+
+	if a.options.DecisionCacheTTL > 0 {
+		a.mtx.Lock()
+		e, ok := a.decisions[key]
+		a.mtx.Unlock()
+		if ok && time.Now().Before(e.expires) {
+			a.observe(e.decision, "hit")
+			return e.decision, nil
+		}
+	}
+
+	resp, err := a.client.Do(ar.WithContext(r.Context()))
+	if err != nil {
+		metrics.ProxyAuthorizerDecisions.WithLabelValues(a.originName,
+			a.options.Name, "error", "miss").Inc()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	d := &Decision{
+		Allowed:    resp.StatusCode >= 200 && resp.StatusCode < 300,
+		StatusCode: resp.StatusCode,
+	}
+	if d.Allowed {
+		d.Header = filterHeaders(resp.Header, a.options.UpstreamHeaders)
+		io.Copy(ioutil.Discard, resp.Body)
+	} else {
+		d.Header = filterHeaders(resp.Header, a.options.ClientHeaders)
+		d.Body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxDeniedBodySize))
+		if err != nil {
+			metrics.ProxyAuthorizerDecisions.WithLabelValues(a.originName,
+				a.options.Name, "error", "miss").Inc()
+			return nil, err
+		}
+	}
+	a.observe(d, "miss")
+
+	if a.options.DecisionCacheTTL > 0 {
+		a.store(key, &entry{decision: d, expires: time.Now().Add(a.options.DecisionCacheTTL)})
+	}
+
+	return d, nil
+}
+
+func (a *Authorizer) observe(d *Decision, cache string) {
+	decision := "deny"
+	if d.Allowed {
+		decision = "allow"
+	}
+	metrics.ProxyAuthorizerDecisions.WithLabelValues(a.originName,
+		a.options.Name, decision, cache).Inc()
+}
+
+// store caches the decision, first sweeping expired decisions when the cache is full.
+// If the cache is still full, the decision is not cached
+func (a *Authorizer) store(key string, e *entry) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if len(a.decisions) >= a.options.DecisionCacheMaxEntries {
+		now := time.Now()
+		for k, v := range a.decisions {
+			if !now.Before(v.expires) {
+				delete(a.decisions, k)
+			}
+		}
+		if len(a.decisions) >= a.options.DecisionCacheMaxEntries {
+			return
+		}
+	}
+	a.decisions[key] = e
+}
+
+// authzRequest returns the request made to the authorization service on behalf of
+// the client request. It has the same method and path, the allowed client headers,
+// and headers describing the request's identity
+func (a *Authorizer) authzRequest(r *http.Request) *http.Request {
+
+	u := strings.TrimSuffix(a.options.URL, "/") + r.URL.Path
+	if r.URL.RawQuery != "" {
+		u += "?" + r.URL.RawQuery
+	}
+	// the URL was validated during config loading, and the client request's
+	// path and query have already been parsed, so this will not fail
+	ar, _ := http.NewRequest(r.Method, u, nil)
+
+	for _, h := range a.options.RequestHeaders {
+		h = http.CanonicalHeaderKey(h)
+		if v, ok := r.Header[h]; ok {
+			ar.Header[h] = v
+		}
+	}
+
+	ar.Header.Set(HeaderOriginName, a.originName)
+	ar.Header.Set(HeaderForwardedHost, r.Host)
+	ar.Header.Set(HeaderClientAddress, clientAddress(r))
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		ar.Header.Set(HeaderClientSubject, r.TLS.VerifiedChains[0][0].Subject.String())
+	}
+	if id := context.Identity(r.Context()); id != nil {
+		ar.Header.Set(HeaderUser, id.User)
+		if len(id.Groups) > 0 {
+			ar.Header.Set(HeaderGroups, id.GroupsString())
+		}
+	}
+
+	return ar
+}
+
+func clientAddress(r *http.Request) string {
+	if addr := context.ClientAddress(r.Context()); addr != "" {
+		return addr
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// cacheKey returns the decision cache key for the authorization request, which
+// includes everything that is passed to the authorization service
+func cacheKey(ar *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(ar.Method)
+	sb.WriteByte(0)
+	sb.WriteString(ar.URL.RequestURI())
+	names := make([]string, 0, len(ar.Header))
+	for k := range ar.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(strings.Join(ar.Header[k], "\x00"))
+	}
+	return sb.String()
+}
+
+func filterHeaders(h http.Header, names []string) http.Header {
+	out := make(http.Header)
+	for _, n := range names {
+		n = http.CanonicalHeaderKey(n)
+		if v, ok := h[n]; ok {
+			out[n] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package authz
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/identity"
+)
+
+func newTestAuthorizer(url string) *Authorizer {
+	o := options.NewOptions()
+	o.Name = "test"
+	o.URL = url
+	o.UpstreamHeaders = []string{"X-User"}
+	return New("test-origin", o)
+}
+
+func TestCheck(t *testing.T) {
+
+	var calls int32
+	var lastReq *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		lastReq = r
+		switch r.Header.Get("Authorization") {
+		case "good":
+			w.Header().Set("X-User", "alice")
+			w.Header().Set("X-Internal", "secret")
+			w.WriteHeader(http.StatusOK)
+		case "redirect":
+			w.Header().Set("Location", "https://login.example.com/")
+			w.WriteHeader(http.StatusFound)
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.Header().Set("X-Internal", "secret")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("denied"))
+		}
+	}))
+	defer ts.Close()
+
+	a := newTestAuthorizer(ts.URL + "/authz/")
+
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/api/v1/query?query=up", nil)
+	r.Header.Set("Authorization", "good")
+	r.Header.Set("X-Other", "ignored")
+	r = r.WithContext(context.WithClientAddress(r.Context(), "192.0.2.1"))
+
+	d, err := a.Check(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Allowed {
+		t.Error("expected request to be allowed")
+	}
+	if d.Header.Get("X-User") != "alice" {
+		t.Errorf("expected %s got %s", "alice", d.Header.Get("X-User"))
+	}
+	if _, ok := d.Header["X-Internal"]; ok {
+		t.Error("expected unlisted upstream header to be filtered")
+	}
+
+	if lastReq.URL.Path != "/authz/api/v1/query" || lastReq.URL.RawQuery != "query=up" {
+		t.Errorf("unexpected authz request uri %s", lastReq.URL.RequestURI())
+	}
+	if lastReq.Header.Get("X-Other") != "" {
+		t.Error("expected unlisted request header to be filtered")
+	}
+	if lastReq.Header.Get(HeaderClientAddress) != "192.0.2.1" {
+		t.Errorf("expected %s got %s", "192.0.2.1", lastReq.Header.Get(HeaderClientAddress))
+	}
+	if lastReq.Header.Get(HeaderOriginName) != "test-origin" {
+		t.Errorf("expected %s got %s", "test-origin", lastReq.Header.Get(HeaderOriginName))
+	}
+	if lastReq.Header.Get(HeaderForwardedHost) != "trickster" {
+		t.Errorf("expected %s got %s", "trickster", lastReq.Header.Get(HeaderForwardedHost))
+	}
+	if lastReq.Header.Get(HeaderUser) != "" {
+		t.Error("expected no user header for request without an identity")
+	}
+
+	// the decision should now be cached
+	if _, err = a.Check(r); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	r.Header.Set("Authorization", "bad")
+	d, err = a.Check(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed {
+		t.Error("expected request to be denied")
+	}
+	if d.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d got %d", http.StatusUnauthorized, d.StatusCode)
+	}
+	if string(d.Body) != "denied" {
+		t.Errorf("expected %s got %s", "denied", string(d.Body))
+	}
+	if d.Header.Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("expected %s got %s", "Bearer", d.Header.Get("WWW-Authenticate"))
+	}
+	if _, ok := d.Header["X-Internal"]; ok {
+		t.Error("expected unlisted client header to be filtered")
+	}
+
+	r.Header.Set("Authorization", "redirect")
+	d, err = a.Check(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.StatusCode != http.StatusFound ||
+		d.Header.Get("Location") != "https://login.example.com/" {
+		t.Errorf("expected redirect to be passed to the client, got %d %v", d.StatusCode, d.Header)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+}
+
+func TestCheckClientSubject(t *testing.T) {
+
+	var subject string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.Header.Get(HeaderClientSubject)
+	}))
+	defer ts.Close()
+
+	a := newTestAuthorizer(ts.URL)
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{
+		{{Subject: pkix.Name{CommonName: "client.example.com"}}},
+	}}
+
+	if _, err := a.Check(r); err != nil {
+		t.Fatal(err)
+	}
+	if subject != "CN=client.example.com" {
+		t.Errorf("expected %s got %s", "CN=client.example.com", subject)
+	}
+}
+
+func TestCheckIdentity(t *testing.T) {
+
+	var user, groups string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = r.Header.Get(HeaderUser)
+		groups = r.Header.Get(HeaderGroups)
+	}))
+	defer ts.Close()
+
+	a := newTestAuthorizer(ts.URL)
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	r = r.WithContext(context.WithIdentity(r.Context(),
+		&identity.Identity{User: "alice", Groups: []string{"admins", "interns"}}))
+
+	if _, err := a.Check(r); err != nil {
+		t.Fatal(err)
+	}
+	if user != "alice" {
+		t.Errorf("expected %s got %s", "alice", user)
+	}
+	if groups != "admins,interns" {
+		t.Errorf("expected %s got %s", "admins,interns", groups)
+	}
+}
+
+func TestCheckError(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	a := newTestAuthorizer(ts.URL)
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	if _, err := a.Check(r); err == nil {
+		t.Error("expected error for unreachable authorization service")
+	}
+	if len(a.decisions) != 0 {
+		t.Errorf("expected %d got %d", 0, len(a.decisions))
+	}
+}
+
+func TestStore(t *testing.T) {
+
+	a := newTestAuthorizer("http://authz")
+	a.options.DecisionCacheMaxEntries = 2
+
+	a.store("expired", &entry{decision: &Decision{}, expires: time.Now().Add(-time.Second)})
+	a.store("1", &entry{decision: &Decision{}, expires: time.Now().Add(time.Minute)})
+	// the cache is full, so the expired decision is swept to make room
+	a.store("2", &entry{decision: &Decision{}, expires: time.Now().Add(time.Minute)})
+	if _, ok := a.decisions["expired"]; ok {
+		t.Error("expected expired decision to be swept")
+	}
+	// the cache is full of unexpired decisions, so this one is not stored
+	a.store("3", &entry{decision: &Decision{}, expires: time.Now().Add(time.Minute)})
+	if _, ok := a.decisions["3"]; ok {
+		t.Error("expected decision not to be stored")
+	}
+	if len(a.decisions) != 2 {
+		t.Errorf("expected %d got %d", 2, len(a.decisions))
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides named profiles of external authorization
+// services that can be attached to an origin
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/util/strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrInvalidURL is returned when the authorization service URL is missing or is not an absolute http(s) URL
+var ErrInvalidURL = errors.New("invalid authorizer url")
+
+// ErrInvalidTimeout is returned when the authorization service timeout is not a positive value
+var ErrInvalidTimeout = errors.New("invalid authorizer timeout_ms")
+
+// ErrInvalidDecisionCache is returned when the decision cache TTL or size is a negative value
+var ErrInvalidDecisionCache = errors.New("invalid authorizer decision cache configuration")
+
+// ErrInvalidStatusOnError is returned when the status on error is not a valid HTTP status code
+var ErrInvalidStatusOnError = errors.New("invalid authorizer status_on_error")
+
+// Options is a named profile of an external authorization service that is
+// consulted to allow or deny each request, in the style of Envoy's ext_authz HTTP service
+type Options struct {
+	// Name is the Name of the profile, taken from the Key in the authorizers map
+	Name string `toml:"-"`
+	// URL is the base URL of the authorization service. The client request's path is appended to it
+	URL string `toml:"url"`
+	// TimeoutMS is the maximum time to wait for a decision from the authorization service
+	TimeoutMS int `toml:"timeout_ms"`
+	// RequestHeaders provides the client request headers that are passed to the authorization service
+	RequestHeaders []string `toml:"request_headers"`
+	// UpstreamHeaders provides the authorization service response headers that are
+	// added to the request to the origin when the request is allowed
+	UpstreamHeaders []string `toml:"upstream_headers"`
+	// ClientHeaders provides the authorization service response headers that are
+	// passed to the client when the request is denied
+	ClientHeaders []string `toml:"client_headers"`
+	// FailureModeAllow, when true, allows requests when the authorization service can't be reached
+	FailureModeAllow bool `toml:"failure_mode_allow"`
+	// StatusOnError is the response status sent to the client when the authorization
+	// service can't be reached and FailureModeAllow is false
+	StatusOnError int `toml:"status_on_error"`
+	// DecisionCacheTTLSecs is how long an allow or deny decision is cached; 0 disables the decision cache
+	DecisionCacheTTLSecs int `toml:"decision_cache_ttl_secs"`
+	// DecisionCacheMaxEntries is the maximum number of decisions held in the cache
+	DecisionCacheMaxEntries int `toml:"decision_cache_max_entries"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+	// DecisionCacheTTL is the time.Duration representation of DecisionCacheTTLSecs
+	DecisionCacheTTL time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default values
+func NewOptions() *Options {
+	return &Options{
+		TimeoutMS:               defaults.DefaultAuthorizerTimeoutMS,
+		Timeout:                 time.Duration(defaults.DefaultAuthorizerTimeoutMS) * time.Millisecond,
+		RequestHeaders:          defaults.DefaultAuthorizerRequestHeaders(),
+		ClientHeaders:           defaults.DefaultAuthorizerClientHeaders(),
+		StatusOnError:           defaults.DefaultAuthorizerStatusOnError,
+		DecisionCacheTTLSecs:    defaults.DefaultAuthorizerDecisionCacheTTLSecs,
+		DecisionCacheTTL:        time.Duration(defaults.DefaultAuthorizerDecisionCacheTTLSecs) * time.Second,
+		DecisionCacheMaxEntries: defaults.DefaultAuthorizerDecisionCacheMaxEntries,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Name:                    o.Name,
+		URL:                     o.URL,
+		TimeoutMS:               o.TimeoutMS,
+		RequestHeaders:          strings.CloneList(o.RequestHeaders),
+		UpstreamHeaders:         strings.CloneList(o.UpstreamHeaders),
+		ClientHeaders:           strings.CloneList(o.ClientHeaders),
+		FailureModeAllow:        o.FailureModeAllow,
+		StatusOnError:           o.StatusOnError,
+		DecisionCacheTTLSecs:    o.DecisionCacheTTLSecs,
+		DecisionCacheMaxEntries: o.DecisionCacheMaxEntries,
+		Timeout:                 o.Timeout,
+		DecisionCacheTTL:        o.DecisionCacheTTL,
+	}
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	u, err := url.Parse(o.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if o.TimeoutMS <= 0 {
+		return ErrInvalidTimeout
+	}
+	if o.DecisionCacheTTLSecs < 0 || o.DecisionCacheMaxEntries < 0 {
+		return ErrInvalidDecisionCache
+	}
+	if o.StatusOnError < 100 || o.StatusOnError > 599 {
+		return ErrInvalidStatusOnError
+	}
+	return nil
+}
+
+// ProcessAuthorizerOptions applies defaults to and validates the provided authorizer profiles
+func ProcessAuthorizerOptions(mo map[string]*Options, metadata *toml.MetaData) error {
+	for k, v := range mo {
+		if v == nil {
+			v = NewOptions()
+			mo[k] = v
+		} else if metadata != nil {
+			if !metadata.IsDefined("authorizers", k, "timeout_ms") {
+				v.TimeoutMS = defaults.DefaultAuthorizerTimeoutMS
+			}
+			if !metadata.IsDefined("authorizers", k, "request_headers") {
+				v.RequestHeaders = defaults.DefaultAuthorizerRequestHeaders()
+			}
+			if !metadata.IsDefined("authorizers", k, "client_headers") {
+				v.ClientHeaders = defaults.DefaultAuthorizerClientHeaders()
+			}
+			if !metadata.IsDefined("authorizers", k, "status_on_error") {
+				v.StatusOnError = defaults.DefaultAuthorizerStatusOnError
+			}
+			if !metadata.IsDefined("authorizers", k, "decision_cache_ttl_secs") {
+				v.DecisionCacheTTLSecs = defaults.DefaultAuthorizerDecisionCacheTTLSecs
+			}
+			if !metadata.IsDefined("authorizers", k, "decision_cache_max_entries") {
+				v.DecisionCacheMaxEntries = defaults.DefaultAuthorizerDecisionCacheMaxEntries
+			}
+		}
+		v.Name = k
+		if err := v.Validate(); err != nil {
+			return err
+		}
+		v.Timeout = time.Duration(v.TimeoutMS) * time.Millisecond
+		v.DecisionCacheTTL = time.Duration(v.DecisionCacheTTLSecs) * time.Second
+	}
+	return nil
+}
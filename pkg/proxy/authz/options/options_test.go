@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const testProfile = `
+[authorizers]
+  [authorizers.test]
+  url = 'http://authz.example.com:8080/check'
+  timeout_ms = 250
+  upstream_headers = ['X-User']
+  decision_cache_ttl_secs = 0
+  [authorizers.test2]
+  url = 'https://authz.example.com'
+`
+
+type testConfig struct {
+	Authorizers map[string]*Options `toml:"authorizers"`
+}
+
+func TestProcessAuthorizerOptions(t *testing.T) {
+
+	c := &testConfig{}
+	md, err := toml.Decode(testProfile, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ProcessAuthorizerOptions(c.Authorizers, &md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := c.Authorizers["test"]
+	if o.Name != "test" {
+		t.Errorf("expected %s got %s", "test", o.Name)
+	}
+	if o.Timeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.Timeout)
+	}
+	if o.DecisionCacheTTL != 0 {
+		t.Errorf("expected %d got %d", 0, o.DecisionCacheTTL)
+	}
+	if len(o.UpstreamHeaders) != 1 || o.UpstreamHeaders[0] != "X-User" {
+		t.Errorf("unexpected upstream headers %v", o.UpstreamHeaders)
+	}
+
+	o = c.Authorizers["test2"]
+	if o.Timeout != time.Second {
+		t.Errorf("expected %s got %s", time.Second, o.Timeout)
+	}
+	if o.DecisionCacheTTL != 10*time.Second {
+		t.Errorf("expected %s got %s", 10*time.Second, o.DecisionCacheTTL)
+	}
+	if o.StatusOnError != 403 {
+		t.Errorf("expected %d got %d", 403, o.StatusOnError)
+	}
+	if len(o.RequestHeaders) != 2 {
+		t.Errorf("expected %d got %d", 2, len(o.RequestHeaders))
+	}
+
+	o2 := o.Clone()
+	if o2.URL != o.URL || o2.DecisionCacheMaxEntries != o.DecisionCacheMaxEntries {
+		t.Error("clone mismatch")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		modify   func(*Options)
+		expected error
+	}{
+		{func(o *Options) {}, nil},
+		{func(o *Options) { o.URL = "" }, ErrInvalidURL},
+		{func(o *Options) { o.URL = "/check" }, ErrInvalidURL},
+		{func(o *Options) { o.URL = "ftp://authz" }, ErrInvalidURL},
+		{func(o *Options) { o.TimeoutMS = 0 }, ErrInvalidTimeout},
+		{func(o *Options) { o.DecisionCacheTTLSecs = -1 }, ErrInvalidDecisionCache},
+		{func(o *Options) { o.DecisionCacheMaxEntries = -1 }, ErrInvalidDecisionCache},
+		{func(o *Options) { o.StatusOnError = 0 }, ErrInvalidStatusOnError},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		o.URL = "http://authz"
+		test.modify(o)
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package capture records an origin's client requests and upstream interactions
+// to a file, with sensitive values redacted, and replays them for deterministic
+// reproduction of a user's traffic against any version of Trickster
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of captured entries
+const (
+	// KindClient is a request received by Trickster from a client
+	KindClient = "client"
+	// KindUpstream is a request made by Trickster to the origin, along with its response
+	KindUpstream = "upstream"
+)
+
+// Redacted is the value that replaces any sensitive value in a capture
+const Redacted = "REDACTED"
+
+// Entry is a single captured request, stored as one line of JSON in the capture file
+type Entry struct {
+	Kind     string      `json:"kind"`
+	Origin   string      `json:"origin"`
+	Time     time.Time   `json:"time"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Response *Response   `json:"response,omitempty"`
+}
+
+// Response is the captured upstream response to an Entry's request
+type Response struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// sanitizer redacts the configured headers and parameters from captured values
+type sanitizer struct {
+	headers map[string]bool
+	params  map[string]bool
+}
+
+func newSanitizer(headers, params []string) *sanitizer {
+	s := &sanitizer{headers: make(map[string]bool), params: make(map[string]bool)}
+	for _, h := range headers {
+		s.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range params {
+		s.params[p] = true
+	}
+	return s
+}
+
+// header returns a copy of h with the values of any redacted headers replaced
+func (s *sanitizer) header(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if s.headers[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// values redacts any redacted parameters in v, returning true if any were changed
+func (s *sanitizer) values(v url.Values) bool {
+	var changed bool
+	for k := range v {
+		if s.params[k] {
+			v[k] = []string{Redacted}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// url returns the string form of u with its credentials and any redacted parameters replaced
+func (s *sanitizer) url(u *url.URL) string {
+	u2 := *u
+	if u2.User != nil {
+		u2.User = url.User(Redacted)
+	}
+	if u2.RawQuery != "" {
+		v := u2.Query()
+		if s.values(v) {
+			u2.RawQuery = v.Encode()
+		}
+	}
+	return u2.String()
+}
+
+// body returns b with any redacted parameters replaced, when b is a url-encoded form
+func (s *sanitizer) body(h http.Header, b []byte) []byte {
+	if len(b) == 0 || !strings.HasPrefix(h.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return b
+	}
+	v, err := url.ParseQuery(string(b))
+	if err != nil || !s.values(v) {
+		return b
+	}
+	return []byte(v.Encode())
+}
+
+// marshal returns the JSON-lines encoding of the entry
+func (e *Entry) marshal() ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capture
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture/options"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Query", r.URL.Query().Get("query"))
+		w.Write([]byte("response to " + r.URL.Query().Get("query")))
+	}))
+	defer origin.Close()
+
+	dir, err := ioutil.TempDir("/tmp", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := options.NewOptions()
+	o.Path = filepath.Join(dir, "capture.jsonl")
+	rec, err := New("test", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rec.Transport(nil)}
+
+	cr := httptest.NewRequest(http.MethodGet, "http://trickster/api/v1/query?query=up&api_key=secret", nil)
+	cr.Header.Set("Authorization", "Bearer secret")
+	if err := rec.RecordClient(cr); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(origin.URL + "/api/v1/query?query=up&api_key=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "response to up" {
+		t.Errorf("expected %s got %s", "response to up", string(b))
+	}
+
+	raw, err := ioutil.ReadFile(o.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret") {
+		t.Errorf("expected sensitive values to be redacted: %s", string(raw))
+	}
+
+	entries, err := Load(o.Path, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(entries))
+	}
+	if entries[0].Kind != KindClient || entries[1].Kind != KindUpstream {
+		t.Errorf("unexpected entry kinds %s, %s", entries[0].Kind, entries[1].Kind)
+	}
+
+	rp := NewReplayer(entries)
+	if rp.Len() != 1 {
+		t.Errorf("expected %d got %d", 1, rp.Len())
+	}
+
+	// redacted parameters match regardless of their replayed values
+	w := httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://replay/api/v1/query?api_key=other&query=up", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "response to up" {
+		t.Errorf("expected %s got %s", "response to up", w.Body.String())
+	}
+	if w.Header().Get("X-Query") != "up" {
+		t.Errorf("expected %s got %s", "up", w.Header().Get("X-Query"))
+	}
+
+	w = httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://replay/api/v1/query?query=down", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected %d got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	entries, err = Load(o.Path, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected %d got %d", 0, len(entries))
+	}
+
+}
+
+func TestDrive(t *testing.T) {
+
+	var auth, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("Authorization"), r.URL.RequestURI()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	entries := []*Entry{
+		{Kind: KindClient, Method: http.MethodGet, URL: "/api/v1/query?query=up",
+			Header: http.Header{"Authorization": []string{Redacted}}},
+		{Kind: KindUpstream, Method: http.MethodGet, URL: "http://origin/api/v1/query?query=up"},
+	}
+
+	results, err := Drive(context.Background(), nil, ts.URL+"/prom1/", entries, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(results))
+	}
+	if results[0].StatusCode != http.StatusAccepted {
+		t.Errorf("expected %d got %d", http.StatusAccepted, results[0].StatusCode)
+	}
+	if path != "/prom1/api/v1/query?query=up" {
+		t.Errorf("expected %s got %s", "/prom1/api/v1/query?query=up", path)
+	}
+	if auth != "" {
+		t.Errorf("expected redacted header to be omitted, got %s", auth)
+	}
+
+}
+
+func TestSanitizeFormBody(t *testing.T) {
+	s := newSanitizer(nil, []string{"p"})
+	h := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+	b := s.body(h, []byte("q=select&p=secret"))
+	if string(b) != "p=REDACTED&q=select" {
+		t.Errorf("expected %s got %s", "p=REDACTED&q=select", string(b))
+	}
+	b = s.body(http.Header{}, []byte("p=secret"))
+	if string(b) != "p=secret" {
+		t.Errorf("expected %s got %s", "p=secret", string(b))
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the traffic capture options for an origin
+package options
+
+import (
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	strutil "github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+// Options configures the recording of an origin's client requests and
+// upstream interactions to a capture file, for later replay
+type Options struct {
+	// Path is the file to which captured interactions are appended. Empty disables capture
+	Path string `toml:"path"`
+	// RedactHeaders is the list of request and response headers whose values are redacted
+	RedactHeaders []string `toml:"redact_headers"`
+	// RedactParams is the list of URL query parameters whose values are redacted
+	RedactParams []string `toml:"redact_params"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		RedactHeaders: d.DefaultCaptureRedactHeaders(),
+		RedactParams:  d.DefaultCaptureRedactParams(),
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Path:          o.Path,
+		RedactHeaders: strutil.CloneList(o.RedactHeaders),
+		RedactParams:  strutil.CloneList(o.RedactParams),
+	}
+}
+
+// Enabled returns true if a capture path is configured
+func (o *Options) Enabled() bool {
+	return o != nil && o.Path != ""
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capture
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture/options"
+)
+
+// writer appends entries to a capture file. Origins configured with the same
+// path share a writer, so that their entries are interleaved in time order
+type writer struct {
+	mtx  sync.Mutex
+	file *os.File
+}
+
+var writers = make(map[string]*writer)
+var writersMtx sync.Mutex
+
+func openWriter(path string) (*writer, error) {
+	writersMtx.Lock()
+	defer writersMtx.Unlock()
+	if w, ok := writers[path]; ok {
+		return w, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{file: f}
+	writers[path] = w
+	return w, nil
+}
+
+func (w *writer) write(e *Entry) error {
+	b, err := e.marshal()
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	_, err = w.file.Write(b)
+	w.mtx.Unlock()
+	return err
+}
+
+// Recorder captures an origin's client requests and upstream interactions
+type Recorder struct {
+	origin    string
+	w         *writer
+	sanitizer *sanitizer
+}
+
+// New returns a Recorder that appends the named origin's interactions to the
+// capture file in the provided options
+func New(originName string, o *options.Options) (*Recorder, error) {
+	w, err := openWriter(o.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		origin:    originName,
+		w:         w,
+		sanitizer: newSanitizer(o.RedactHeaders, o.RedactParams),
+	}, nil
+}
+
+// readBody reads and returns the body of the request, and restores it for further readers
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, err
+}
+
+// RecordClient captures the client request, restoring its body for the next handler
+func (rec *Recorder) RecordClient(r *http.Request) error {
+	if rec == nil {
+		return nil
+	}
+	b, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	u := *r.URL
+	u.Scheme, u.Host = "", ""
+	return rec.w.write(&Entry{
+		Kind:   KindClient,
+		Origin: rec.origin,
+		Time:   time.Now(),
+		Method: r.Method,
+		URL:    rec.sanitizer.url(&u),
+		Header: rec.sanitizer.header(r.Header),
+		Body:   rec.sanitizer.body(r.Header, b),
+	})
+}
+
+// Transport returns an http.RoundTripper that captures each upstream request and
+// response made through the next RoundTripper
+func (rec *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{rec: rec, next: next}
+}
+
+type transport struct {
+	rec  *Recorder
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	r = r.Clone(r.Context())
+	b, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		Kind:   KindUpstream,
+		Origin: t.rec.origin,
+		Time:   start,
+		Method: r.Method,
+		URL:    t.rec.sanitizer.url(r.URL),
+		Header: t.rec.sanitizer.header(r.Header),
+		Body:   t.rec.sanitizer.body(r.Header, b),
+		Response: &Response{
+			StatusCode: resp.StatusCode,
+			Header:     t.rec.sanitizer.header(resp.Header),
+		},
+	}
+	// upgraded connections (e.g., WebSockets) are streamed, so only their handshake is captured
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.Body != nil {
+		rb, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(rb))
+		e.Response.Body = rb
+	}
+	// a failure to capture must never fail the request itself
+	t.rec.w.write(e)
+	return resp, nil
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Load reads the entries from the capture file at path, optionally limited to the named origin
+func Load(path, originName string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := make([]*Entry, 0)
+	dec := json.NewDecoder(f)
+	for {
+		e := &Entry{}
+		if err := dec.Decode(e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if originName == "" || e.Origin == originName {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Replayer is an http.Handler that serves as the origin by responding to each request
+// with the captured upstream response for the matching request. Responses to repeated
+// requests are served in the order they were captured, and the last is repeated once
+// they are exhausted
+type Replayer struct {
+	mtx       sync.Mutex
+	responses map[string][]*Response
+	served    map[string]int
+	redacted  map[string]bool
+}
+
+// NewReplayer returns a Replayer for the upstream entries in the provided list
+func NewReplayer(entries []*Entry) *Replayer {
+	rp := &Replayer{
+		responses: make(map[string][]*Response),
+		served:    make(map[string]int),
+		redacted:  make(map[string]bool),
+	}
+	// learn which parameters were redacted so they match regardless of their replayed values
+	for _, e := range entries {
+		if e.Kind != KindUpstream || e.Response == nil {
+			continue
+		}
+		if u, err := url.Parse(e.URL); err == nil {
+			for k, v := range u.Query() {
+				if len(v) == 1 && v[0] == Redacted {
+					rp.redacted[k] = true
+				}
+			}
+		}
+	}
+	for _, e := range entries {
+		if e.Kind != KindUpstream || e.Response == nil {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		k := rp.key(e.Method, u, e.Body)
+		rp.responses[k] = append(rp.responses[k], e.Response)
+	}
+	return rp
+}
+
+// Len returns the number of distinct requests the Replayer can respond to
+func (rp *Replayer) Len() int {
+	return len(rp.responses)
+}
+
+func (rp *Replayer) key(method string, u *url.URL, body []byte) string {
+	v := u.Query()
+	for k := range v {
+		if rp.redacted[k] {
+			v[k] = []string{Redacted}
+		}
+	}
+	return method + " " + u.Path + "?" + v.Encode() + "\n" + string(body)
+}
+
+func (rp *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := readBody(r)
+	k := rp.key(r.Method, r.URL, b)
+	rp.mtx.Lock()
+	l, ok := rp.responses[k]
+	if !ok {
+		rp.mtx.Unlock()
+		http.Error(w, "no captured response for "+r.Method+" "+r.URL.RequestURI(),
+			http.StatusNotImplemented)
+		return
+	}
+	i := rp.served[k]
+	if i < len(l)-1 {
+		rp.served[k] = i + 1
+	}
+	resp := l[i]
+	rp.mtx.Unlock()
+
+	h := w.Header()
+	for k, v := range resp.Header {
+		h[k] = v
+	}
+	h.Del("Content-Length")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// Result is the outcome of a single client request issued by Drive
+type Result struct {
+	Entry      *Entry
+	StatusCode int
+	Err        error
+}
+
+// Drive re-issues the captured client requests in the provided list against the Trickster
+// at the target base URL, preserving the captured pacing between requests divided by speed.
+// A speed of 0 issues the requests back-to-back
+func Drive(ctx context.Context, client *http.Client, target string,
+	entries []*Entry, speed float64) ([]*Result, error) {
+
+	base, err := url.Parse(strings.TrimSuffix(target, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	results := make([]*Result, 0, len(entries))
+	var prev time.Time
+	for _, e := range entries {
+		if e.Kind != KindClient {
+			continue
+		}
+		if speed > 0 && !prev.IsZero() && e.Time.After(prev) {
+			select {
+			case <-time.After(time.Duration(float64(e.Time.Sub(prev)) / speed)):
+			case <-ctx.Done():
+				return results, ctx.Err()
+			}
+		}
+		prev = e.Time
+		results = append(results, drive(ctx, client, base, e))
+	}
+	return results, nil
+}
+
+func drive(ctx context.Context, client *http.Client, base *url.URL, e *Entry) *Result {
+	res := &Result{Entry: e}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	u.Scheme, u.Host = base.Scheme, base.Host
+	u.Path = base.Path + u.Path
+	r, err := http.NewRequestWithContext(ctx, e.Method, u.String(), bytes.NewReader(e.Body))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	for k, v := range e.Header {
+		// redacted credentials are omitted, rather than sent as the placeholder value
+		if len(v) == 1 && v[0] == Redacted {
+			continue
+		}
+		r.Header[k] = v
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	return res
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/identity"
+)
+
+// WithIdentity returns a copy of the provided context that also includes
+// the identity of the user making the request
+func WithIdentity(ctx context.Context, id *identity.Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// Identity returns the identity of the user associated with the request, or nil if there is none
+func Identity(ctx context.Context) *identity.Identity {
+	if ctx == nil {
+		return nil
+	}
+	if id, ok := ctx.Value(identityKey).(*identity.Identity); ok {
+		return id
+	}
+	return nil
+}
@@ -22,4 +22,8 @@ const (
 	resourcesKey contextKey = iota
 	hopsKey
 	healthCheckKey
+	clientAddressKey
+	identityKey
+	explainKey
+	upstreamErrorKey
 )
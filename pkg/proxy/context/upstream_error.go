@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package context
+
+import (
+	"context"
+)
+
+// WithUpstreamError returns a copy of the provided context that also includes the
+// error that prevented the request from receiving a response from the origin
+func WithUpstreamError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, upstreamErrorKey, err)
+}
+
+// UpstreamError returns the error that prevented the request from receiving a response
+// from the origin, or nil if there was none
+func UpstreamError(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	v := ctx.Value(upstreamErrorKey)
+	if v != nil {
+		if err, ok := v.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
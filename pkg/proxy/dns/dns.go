@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dns provides a caching hostname resolver and dialer for the
+// upstream connections made by origin clients
+package dns
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/dns/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// ErrNoAddresses is returned when a hostname resolves to an empty address list
+var ErrNoAddresses = errors.New("no addresses found for host")
+
+type lookupFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+type entry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// Resolver is a caching hostname resolver for an origin's upstream connections
+type Resolver struct {
+	originName string
+	options    *options.Options
+	lookup     lookupFunc
+	dial       dialFunc
+	entries    map[string]*entry
+	mtx        sync.Mutex
+	counter    uint32
+}
+
+// NewResolver returns a new Resolver for the named origin, which dials
+// resolved addresses using the provided dialer
+func NewResolver(originName string, o *options.Options, dialer *net.Dialer) *Resolver {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return &Resolver{
+		originName: originName,
+		options:    o,
+		lookup:     net.DefaultResolver.LookupIPAddr,
+		dial:       dialer.DialContext,
+		entries:    make(map[string]*entry),
+	}
+}
+
+// Resolve returns the addresses for the provided host, using the cache when possible
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+
+	now := time.Now()
+
+	r.mtx.Lock()
+	e, ok := r.entries[host]
+	r.mtx.Unlock()
+
+	if ok && now.Before(e.expires) {
+		if e.err != nil {
+			metrics.ProxyDNSCacheEvents.WithLabelValues(r.originName, "negative_hit").Inc()
+			return nil, e.err
+		}
+		metrics.ProxyDNSCacheEvents.WithLabelValues(r.originName, "hit").Inc()
+		return e.addrs, nil
+	}
+
+	metrics.ProxyDNSCacheEvents.WithLabelValues(r.originName, "miss").Inc()
+	addrs, err := r.resolve(ctx, host)
+	if err != nil {
+		// a transient resolution failure should not take down an origin whose
+		// previously-resolved addresses are likely still valid
+		if ok && e.err == nil && r.options.ServeStale {
+			metrics.ProxyDNSCacheEvents.WithLabelValues(r.originName, "stale").Inc()
+			return e.addrs, nil
+		}
+		if r.options.NegativeTTL > 0 {
+			r.store(host, &entry{err: err, expires: now.Add(r.options.NegativeTTL)})
+		}
+		return nil, err
+	}
+
+	r.store(host, &entry{addrs: addrs, expires: now.Add(r.options.CacheTTL)})
+	return addrs, nil
+}
+
+// resolve performs an uncached lookup of the host and records the result
+func (r *Resolver) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	start := time.Now()
+	addrs, err := r.lookup(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = ErrNoAddresses
+	}
+	st := "ok"
+	if err != nil {
+		st = "error"
+		metrics.ProxyDNSLookupFailures.WithLabelValues(r.originName).Inc()
+	}
+	metrics.ProxyDNSLookupDuration.WithLabelValues(r.originName, st).
+		Observe(time.Since(start).Seconds())
+	return addrs, err
+}
+
+func (r *Resolver) store(host string, e *entry) {
+	r.mtx.Lock()
+	r.entries[host] = e
+	r.mtx.Unlock()
+}
+
+// Invalidate removes any cached resolution for the provided host
+func (r *Resolver) Invalidate(host string) {
+	r.mtx.Lock()
+	delete(r.entries, host)
+	r.mtx.Unlock()
+}
+
+// DialContext connects to the address on the named network, resolving the
+// address's hostname through the cache. It is suitable for use as an
+// http.Transport's DialContext
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// IP literals do not need resolution
+	if net.ParseIP(host) != nil {
+		return r.dial(ctx, network, address)
+	}
+
+	addrs, err := r.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := r.dialAny(ctx, network, port, addrs)
+	if err == nil || !r.options.ReResolveOnError {
+		return conn, err
+	}
+
+	// none of the cached addresses could be dialed, so the cache may be
+	// holding onto addresses that have since moved; try a fresh resolution
+	metrics.ProxyDNSCacheEvents.WithLabelValues(r.originName, "re_resolve").Inc()
+	r.Invalidate(host)
+	addrs, rerr := r.resolve(ctx, host)
+	if rerr != nil {
+		return nil, err
+	}
+	r.store(host, &entry{addrs: addrs, expires: time.Now().Add(r.options.CacheTTL)})
+	return r.dialAny(ctx, network, port, addrs)
+}
+
+// dialAny dials each address in the order provided by the resolution
+// strategy and returns the first successful connection
+func (r *Resolver) dialAny(ctx context.Context, network, port string,
+	addrs []net.IPAddr) (net.Conn, error) {
+	var err error
+	for _, a := range r.order(addrs) {
+		var conn net.Conn
+		conn, err = r.dial(ctx, network, net.JoinHostPort(a.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// order returns a copy of the addresses arranged according to the resolution strategy
+func (r *Resolver) order(addrs []net.IPAddr) []net.IPAddr {
+	out := make([]net.IPAddr, len(addrs))
+	switch r.options.Strategy {
+	case options.StrategyRoundRobin:
+		n := int(atomic.AddUint32(&r.counter, 1)-1) % len(addrs)
+		copy(out, addrs[n:])
+		copy(out[len(addrs)-n:], addrs[:n])
+	case options.StrategyRandom:
+		for i, j := range rand.Perm(len(addrs)) {
+			out[i] = addrs[j]
+		}
+	default:
+		copy(out, addrs)
+	}
+	return out
+}
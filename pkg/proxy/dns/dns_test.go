@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/dns/options"
+)
+
+var errTestLookup = errors.New("test lookup failure")
+
+type testLookup struct {
+	addrs []net.IPAddr
+	err   error
+	calls int
+}
+
+func (tl *testLookup) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	tl.calls++
+	return tl.addrs, tl.err
+}
+
+func testResolver(tl *testLookup) *Resolver {
+	o := options.NewOptions()
+	o.CacheTTLSecs = 60
+	o.CacheTTL = time.Minute
+	o.NegativeTTL = time.Minute
+	r := NewResolver("test", o, nil)
+	r.lookup = tl.lookup
+	return r
+}
+
+func TestResolve(t *testing.T) {
+
+	tl := &testLookup{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}}
+	r := testResolver(tl)
+
+	for i := 0; i < 3; i++ {
+		addrs, err := r.Resolve(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 1 {
+			t.Errorf("expected %d got %d", 1, len(addrs))
+		}
+	}
+
+	if tl.calls != 1 {
+		t.Errorf("expected %d got %d", 1, tl.calls)
+	}
+
+	r.Invalidate("example.com")
+	r.Resolve(context.Background(), "example.com")
+	if tl.calls != 2 {
+		t.Errorf("expected %d got %d", 2, tl.calls)
+	}
+}
+
+func TestResolveNegative(t *testing.T) {
+
+	tl := &testLookup{err: errTestLookup}
+	r := testResolver(tl)
+
+	for i := 0; i < 2; i++ {
+		_, err := r.Resolve(context.Background(), "example.com")
+		if err != errTestLookup {
+			t.Errorf("expected %v got %v", errTestLookup, err)
+		}
+	}
+
+	if tl.calls != 1 {
+		t.Errorf("expected %d got %d", 1, tl.calls)
+	}
+
+	tl.err = nil
+	r.Invalidate("example.com")
+	_, err := r.Resolve(context.Background(), "example.com")
+	if err != ErrNoAddresses {
+		t.Errorf("expected %v got %v", ErrNoAddresses, err)
+	}
+}
+
+func TestResolveServeStale(t *testing.T) {
+
+	tl := &testLookup{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}}
+	r := testResolver(tl)
+	r.options.CacheTTL = -time.Second
+
+	r.Resolve(context.Background(), "example.com")
+
+	tl.err = errTestLookup
+	addrs, err := r.Resolve(context.Background(), "example.com")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(addrs) != 1 {
+		t.Errorf("expected %d got %d", 1, len(addrs))
+	}
+
+	r.options.ServeStale = false
+	_, err = r.Resolve(context.Background(), "example.com")
+	if err != errTestLookup {
+		t.Errorf("expected %v got %v", errTestLookup, err)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	// the first address is not listening, so the dialer should move to the next
+	tl := &testLookup{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.2")},
+		{IP: net.ParseIP("127.0.0.1")}}}
+	r := testResolver(tl)
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != l.Addr().String() {
+			return nil, errTestLookup
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	conn, err := r.DialContext(context.Background(), "tcp", "example.com:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// IP literals bypass the resolver
+	conn, err = r.DialContext(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if tl.calls != 1 {
+		t.Errorf("expected %d got %d", 1, tl.calls)
+	}
+
+	_, err = r.DialContext(context.Background(), "tcp", "example.com")
+	if err == nil {
+		t.Error("expected error for missing port")
+	}
+}
+
+func TestDialContextReResolve(t *testing.T) {
+
+	tl := &testLookup{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}}}
+	r := testResolver(tl)
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errTestLookup
+	}
+
+	_, err := r.DialContext(context.Background(), "tcp", "example.com:80")
+	if err != errTestLookup {
+		t.Errorf("expected %v got %v", errTestLookup, err)
+	}
+	if tl.calls != 2 {
+		t.Errorf("expected %d got %d", 2, tl.calls)
+	}
+
+	r.options.ReResolveOnError = false
+	r.DialContext(context.Background(), "tcp", "example.com:80")
+	if tl.calls != 2 {
+		t.Errorf("expected %d got %d", 2, tl.calls)
+	}
+}
+
+func TestOrder(t *testing.T) {
+
+	addrs := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.2")},
+		{IP: net.ParseIP("127.0.0.3")}}
+	r := testResolver(&testLookup{})
+
+	out := r.order(addrs)
+	if !out[0].IP.Equal(addrs[0].IP) {
+		t.Errorf("expected %s got %s", addrs[0].String(), out[0].String())
+	}
+
+	r.options.Strategy = options.StrategyRoundRobin
+	for i := 0; i < 4; i++ {
+		out = r.order(addrs)
+		if !out[0].IP.Equal(addrs[i%3].IP) {
+			t.Errorf("expected %s got %s", addrs[i%3].String(), out[0].String())
+		}
+		if len(out) != 3 {
+			t.Errorf("expected %d got %d", 3, len(out))
+		}
+	}
+
+	r.options.Strategy = options.StrategyRandom
+	out = r.order(addrs)
+	if len(out) != 3 {
+		t.Errorf("expected %d got %d", 3, len(out))
+	}
+}
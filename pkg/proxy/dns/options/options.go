@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the DNS resolution options for an origin
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Strategy names for selecting which resolved address is dialed first
+const (
+	// StrategyFirst always dials the addresses in the order provided by the resolver
+	StrategyFirst = "first"
+	// StrategyRoundRobin rotates the first-dialed address on each new connection
+	StrategyRoundRobin = "round_robin"
+	// StrategyRandom dials the addresses in a random order
+	StrategyRandom = "random"
+)
+
+// ErrInvalidStrategy is returned when the configured resolution strategy is unknown
+var ErrInvalidStrategy = errors.New("invalid dns resolution strategy")
+
+var strategies = map[string]bool{
+	StrategyFirst:      true,
+	StrategyRoundRobin: true,
+	StrategyRandom:     true,
+}
+
+// Options is a collection of DNS resolution configurations for an origin's hostname
+type Options struct {
+	// CacheTTLSecs specifies how long a successful hostname resolution is cached.
+	// A value of 0 disables the DNS cache and uses the system resolver for each new connection
+	CacheTTLSecs int `toml:"cache_ttl_secs"`
+	// NegativeTTLSecs specifies how long a failed hostname resolution is cached before retrying
+	NegativeTTLSecs int `toml:"negative_ttl_secs"`
+	// ReResolveOnError, when true, discards the cached addresses and performs a fresh
+	// resolution when none of the cached addresses can be dialed
+	ReResolveOnError bool `toml:"re_resolve_on_error"`
+	// ServeStale, when true, continues to use expired cached addresses when a
+	// re-resolution of the hostname fails
+	ServeStale bool `toml:"serve_stale"`
+	// Strategy selects the order in which resolved addresses are dialed:
+	// 'first', 'round_robin' or 'random'
+	Strategy string `toml:"strategy"`
+
+	// CacheTTL is the time.Duration representation of CacheTTLSecs
+	CacheTTL time.Duration `toml:"-"`
+	// NegativeTTL is the time.Duration representation of NegativeTTLSecs
+	NegativeTTL time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		CacheTTLSecs:     d.DefaultDNSCacheTTLSecs,
+		CacheTTL:         time.Duration(d.DefaultDNSCacheTTLSecs) * time.Second,
+		NegativeTTLSecs:  d.DefaultDNSNegativeTTLSecs,
+		NegativeTTL:      time.Duration(d.DefaultDNSNegativeTTLSecs) * time.Second,
+		ReResolveOnError: d.DefaultDNSReResolveOnError,
+		ServeStale:       d.DefaultDNSServeStale,
+		Strategy:         d.DefaultDNSStrategy,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		CacheTTLSecs:     o.CacheTTLSecs,
+		CacheTTL:         o.CacheTTL,
+		NegativeTTLSecs:  o.NegativeTTLSecs,
+		NegativeTTL:      o.NegativeTTL,
+		ReResolveOnError: o.ReResolveOnError,
+		ServeStale:       o.ServeStale,
+		Strategy:         o.Strategy,
+	}
+}
+
+// Enabled returns true if the DNS cache is enabled in the Options
+func (o *Options) Enabled() bool {
+	return o != nil && o.CacheTTLSecs > 0
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if _, ok := strategies[o.Strategy]; !ok {
+		return ErrInvalidStrategy
+	}
+	if o.CacheTTLSecs < 0 || o.NegativeTTLSecs < 0 {
+		return errors.New("dns ttl values must not be negative")
+	}
+	return nil
+}
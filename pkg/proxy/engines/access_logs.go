@@ -19,6 +19,7 @@ package engines
 import (
 	"net/http"
 
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
@@ -39,11 +40,23 @@ func logUpstreamRequest(log *tl.Logger, originName, originType, handlerName, met
 }
 
 func logDownstreamRequest(log *tl.Logger, r *http.Request) {
-	log.Debug("downtream request",
-		tl.Pairs{
-			"uri":       r.RequestURI,
-			"method":    r.Method,
-			"userAgent": r.UserAgent(),
-			"clientIP":  r.RemoteAddr,
-		})
+	p := tl.Pairs{
+		"uri":       r.RequestURI,
+		"method":    r.Method,
+		"userAgent": r.UserAgent(),
+		"clientIP":  clientAddress(r),
+	}
+	if id := tc.Identity(r.Context()); id != nil {
+		p["user"] = id.User
+	}
+	log.Debug("downtream request", p)
+}
+
+// clientAddress returns the originating client address resolved by the forwarded
+// headers policy, or the request's RemoteAddr when one was not resolved
+func clientAddress(r *http.Request) string {
+	if addr := tc.ClientAddress(r.Context()); addr != "" {
+		return addr
+	}
+	return r.RemoteAddr
 }
@@ -26,11 +26,15 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	tpe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/ranges/byterange"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 
 	"github.com/golang/snappy"
 	"go.opentelemetry.io/otel/api/kv"
@@ -100,9 +104,14 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 
 		if inflate {
 			rsc.Logger.Debug("decompressing cached data", tl.Pairs{"cacheKey": key})
-			b, err := snappy.Decode(nil, bytes)
-			if err == nil {
-				bytes = b
+			// the decoded document is copied during unmarshal, so it can be decoded into a pooled buffer
+			if n, err := snappy.DecodedLen(bytes); err == nil {
+				buf := buffers.Get(n)
+				defer buffers.Put(buf)
+				b, err := snappy.Decode(buf.Bytes()[:n], bytes)
+				if err == nil {
+					bytes = b
+				}
 			}
 		}
 		_, err = d.UnmarshalMsg(bytes)
@@ -172,9 +181,25 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 	h.Del(headers.NameTransferEncoding)
 	h.Del(headers.NameContentRange)
 	h.Del(headers.NameTricksterResult)
+	h.Del(headers.NameTricksterUpstreamResult)
 	ce := h.Get(headers.NameContentEncoding)
+	// documents written outside of a path's request, such as those rewritten by
+	// invalidation or retention, keep their existing tags
+	var tags []string
+	if rsc.PathConfig != nil {
+		tags = cacheTags(rsc.PathConfig, h)
+	}
 	d.headerLock.Unlock()
 
+	if exceedsMaxObjectSize(c, rsc, d.Size(), false) {
+		return tpe.ErrObjectTooLarge
+	}
+
+	// pad the ttl so the object does not expire prematurely on nodes whose clocks are behind
+	if rsc.OriginConfig != nil {
+		ttl += rsc.OriginConfig.ClockSkewTolerance
+	}
+
 	var bytes []byte
 	var err error
 	var compress bool
@@ -204,11 +229,19 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 			}
 		}
 
-		return mc.StoreReference(key, d, ttl)
+		err = mc.StoreReference(key, d, ttl)
+		if err == nil {
+			setCacheTags(c, key, tags)
+		}
+		return err
 	}
 
 	// for non-memory, we have to seralize the document to a byte slice to store
-	bytes, err = d.MarshalMsg(nil)
+	// using pooled buffers, since the cache does not retain the slice after Store returns.
+	// the first byte is reserved for the compression bit
+	buf := buffers.Get(d.Msgsize() + 1)
+	defer buffers.Put(buf)
+	bytes, err = d.MarshalMsg(append(buf.Bytes(), 0))
 	if err != nil {
 		rsc.Logger.Error("error marshaling cache document", tl.Pairs{
 			"cacheKey": key,
@@ -218,9 +251,12 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 
 	if compress {
 		rsc.Logger.Debug("compressing cache data", tl.Pairs{"cacheKey": key})
-		bytes = append([]byte{1}, snappy.Encode(nil, bytes)...)
-	} else {
-		bytes = append([]byte{0}, bytes...)
+		n := snappy.MaxEncodedLen(len(bytes)-1) + 1
+		cbuf := buffers.Get(n)
+		defer buffers.Put(cbuf)
+		b := cbuf.Bytes()[:n]
+		b[0] = 1
+		bytes = b[:len(snappy.Encode(b[1:], bytes[1:]))+1]
 	}
 
 	err = c.Store(key, bytes, ttl)
@@ -234,6 +270,7 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		}
 		return err
 	}
+	setCacheTags(c, key, tags)
 	if span != nil {
 		span.AddEvent(
 			ctx,
@@ -245,6 +282,43 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 
 }
 
+// maxObjectSize returns the largest size of an object that is cached for the request's path,
+// which is the path's max object size when it has one, and otherwise the origin's
+func maxObjectSize(rsc *request.Resources) int {
+	if rsc.PathConfig != nil && rsc.PathConfig.MaxObjectSizeBytes > 0 {
+		return rsc.PathConfig.MaxObjectSizeBytes
+	}
+	return rsc.OriginConfig.MaxObjectSizeBytes
+}
+
+// exceedsMaxObjectSize returns true if an object of the provided size may not be written to the
+// cache, due to the cache's or path's max object size, or when withOrigin is true, the origin's.
+// Each object that is too large is counted by the limit that it exceeded
+func exceedsMaxObjectSize(c cache.Cache, rsc *request.Resources, size int, withOrigin bool) bool {
+	var limit string
+	if rsc.PathConfig != nil && rsc.PathConfig.MaxObjectSizeBytes > 0 {
+		if size > rsc.PathConfig.MaxObjectSizeBytes {
+			limit = "path"
+		}
+	} else if withOrigin && rsc.OriginConfig != nil && rsc.OriginConfig.MaxObjectSizeBytes > 0 &&
+		size > rsc.OriginConfig.MaxObjectSizeBytes {
+		limit = "origin"
+	}
+	cc := c.Configuration()
+	if limit == "" && cc.MaxObjectSizeBytes > 0 && size > cc.MaxObjectSizeBytes {
+		limit = "cache"
+	}
+	if limit == "" {
+		return false
+	}
+	var originName string
+	if rsc.OriginConfig != nil {
+		originName = rsc.OriginConfig.Name
+	}
+	metrics.CacheOversizedObjects.WithLabelValues(cc.Name, originName, limit).Inc()
+	return true
+}
+
 // DocumentFromHTTPResponse returns an HTTPDocument from the provided HTTP Response and Body
 func DocumentFromHTTPResponse(resp *http.Response, body []byte, cp *CachingPolicy, log *tl.Logger) *HTTPDocument {
 	d := &HTTPDocument{}
@@ -275,3 +349,42 @@ func DocumentFromHTTPResponse(resp *http.Response, body []byte, cp *CachingPolic
 
 	return d
 }
+
+// cacheTags returns the cache tags for a document, which are the tags configured for the
+// path, and those provided by the origin in the Surrogate-Key (space-separated) and
+// Cache-Tag (comma-separated) response headers
+func cacheTags(pc *po.Options, h http.Header) []string {
+	tags := append([]string{}, pc.CacheTags...)
+	for _, v := range h[headers.NameSurrogateKey] {
+		tags = append(tags, strings.Fields(v)...)
+	}
+	for _, v := range h[headers.NameCacheTag] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	if len(tags) < 2 {
+		return tags
+	}
+	seen := make(map[string]bool, len(tags))
+	out := tags[:0]
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// setCacheTags associates the cached object with its tags, when the cache supports tagging
+func setCacheTags(c cache.Cache, key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if tg, ok := c.(cache.Tagger); ok {
+		tg.SetTags(key, tags)
+	}
+}
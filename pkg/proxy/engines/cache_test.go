@@ -28,6 +28,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache"
 	co "github.com/tricksterproxy/trickster/pkg/cache/options"
 	"github.com/tricksterproxy/trickster/pkg/cache/registration"
 	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
@@ -35,7 +36,9 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/config"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	tpe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/ranges/byterange"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -439,6 +442,53 @@ func TestQueryCache(t *testing.T) {
 
 }
 
+func TestWriteCacheTags(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	c, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+
+	resp := &http.Response{}
+	resp.Header = make(http.Header)
+	resp.StatusCode = 200
+	resp.Header.Set(headers.NameSurrogateKey, "tenant-a  series-1")
+	resp.Header.Set(headers.NameCacheTag, "series-1, dashboard-7")
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+
+	pc := po.NewOptions()
+	pc.CacheTags = []string{"tenant-a"}
+
+	ctx := tc.WithResources(context.Background(), &request.Resources{OriginConfig: conf.Origins["default"],
+		PathConfig: pc, Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	err = WriteCache(ctx, c, "testKey", d, time.Duration(60)*time.Second, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	tg := c.(cache.Tagger)
+	for _, tag := range []string{"tenant-a", "series-1", "dashboard-7"} {
+		keys, _ := tg.TaggedKeys(tag)
+		if len(keys) != 1 || keys[0] != "testKey" {
+			t.Errorf("expected %v got %v for tag %s", []string{"testKey"}, keys, tag)
+		}
+	}
+
+	expected := []string{"tenant-a", "series-1", "dashboard-7"}
+	tags := cacheTags(pc, resp.Header)
+	if len(tags) != len(expected) {
+		t.Errorf("expected %v got %v", expected, tags)
+	}
+}
+
 // Mock Cache for testing error conditions
 type testCache struct {
 	configuration *co.Options
@@ -466,3 +516,62 @@ func (tc *testCache) Close() error                              { return errTest
 func (tc *testCache) Configuration() *co.Options                { return tc.configuration }
 func (tc *testCache) Locker() locks.NamedLocker                 { return tc.locker }
 func (tc *testCache) SetLocker(l locks.NamedLocker)             { tc.locker = l }
+
+func TestWriteCacheMaxObjectSize(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, testLogger)
+	defer registration.CloseCaches(caches)
+	c, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+
+	resp := &http.Response{}
+	resp.Header = make(http.Header)
+	resp.StatusCode = 200
+	d := DocumentFromHTTPResponse(resp, []byte(strings.Repeat("a", 1024)), nil, testLogger)
+
+	pc := po.NewOptions()
+	rsc := &request.Resources{OriginConfig: conf.Origins["default"],
+		PathConfig: pc, Tracer: tu.NewTestTracer(), Logger: testLogger}
+	ctx := tc.WithResources(context.Background(), rsc)
+
+	// the origin's limit does not apply to the cache writer
+	rsc.OriginConfig.MaxObjectSizeBytes = 512
+	err = WriteCache(ctx, c, "testKey", d, time.Duration(60)*time.Second, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if !exceedsMaxObjectSize(c, rsc, d.Size(), true) {
+		t.Error("expected the object to exceed the origin's limit")
+	}
+
+	// the path's limit overrides the origin's
+	pc.MaxObjectSizeBytes = 4096
+	if exceedsMaxObjectSize(c, rsc, d.Size(), true) {
+		t.Error("expected the path's limit to override the origin's")
+	}
+
+	pc.MaxObjectSizeBytes = 512
+	err = WriteCache(ctx, c, "testKey2", d, time.Duration(60)*time.Second, nil)
+	if err != tpe.ErrObjectTooLarge {
+		t.Errorf("expected %v got %v", tpe.ErrObjectTooLarge, err)
+	}
+
+	pc.MaxObjectSizeBytes = 0
+	c.Configuration().MaxObjectSizeBytes = 512
+	defer func() { c.Configuration().MaxObjectSizeBytes = 0 }()
+	err = WriteCache(ctx, c, "testKey3", d, time.Duration(60)*time.Second, nil)
+	if err != tpe.ErrObjectTooLarge {
+		t.Errorf("expected %v got %v", tpe.ErrObjectTooLarge, err)
+	}
+
+	if _, _, err := c.Retrieve("testKey3", false); err == nil {
+		t.Error("expected oversized object not to be cached")
+	}
+}
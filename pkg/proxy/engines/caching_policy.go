@@ -37,6 +37,7 @@ type CachingPolicy struct {
 	CanRevalidate        bool `msg:"can_revalidate"`
 	MustRevalidate       bool `msg:"must_revalidate"`
 	IsNegativeCache      bool `msg:"is_negative_cache"`
+	IsStale              bool `msg:"is_stale"`
 	IsClientConditional  bool `msg:"-"`
 	IsClientFresh        bool `msg:"-"`
 	HasIfModifiedSince   bool `msg:"-"`
@@ -73,6 +74,7 @@ func (cp *CachingPolicy) Clone() *CachingPolicy {
 		LocalDate:             cp.LocalDate,
 		ETag:                  cp.ETag,
 		IsNegativeCache:       cp.IsNegativeCache,
+		IsStale:               cp.IsStale,
 		IfNoneMatchValue:      cp.IfNoneMatchValue,
 		IfModifiedSinceTime:   cp.IfModifiedSinceTime,
 		IfUnmodifiedSinceTime: cp.IfUnmodifiedSinceTime,
@@ -125,7 +127,9 @@ func (cp *CachingPolicy) Merge(src *CachingPolicy) {
 	cp.ETag = src.ETag
 
 	// request policies (e.g., IfModifiedSince) are intentionally omitted,
-	// assuming a response policy is always merged into a request policy
+	// assuming a response policy is always merged into a request policy.
+	// IsStale is also omitted, so that the soft-purge mark of a cached object
+	// is cleared when the object is next revalidated or refreshed
 
 }
 
@@ -145,10 +149,10 @@ func (cp *CachingPolicy) String() string {
 	return fmt.Sprintf(`{ "is_fresh":%t, "no_cache":%t, "no_transform":%t, 
 	"freshness_lifetime":%d, "can_revalidate":%t, "must_revalidate":%t,`+
 		` "last_modified":%d, "expires":%d, "date":%d, "local_date":%d, "etag":"%s", "if_none_match":"%s"`+
-		` "if_modified_since":%d, "if_unmodified_since":%d, "is_negative_cache":%t }`,
+		` "if_modified_since":%d, "if_unmodified_since":%d, "is_negative_cache":%t, "is_stale":%t }`,
 		cp.IsFresh, cp.NoCache, cp.NoTransform, cp.FreshnessLifetime, cp.CanRevalidate, cp.MustRevalidate,
 		cp.LastModified.Unix(), cp.Expires.Unix(), cp.Date.Unix(), cp.LocalDate.Unix(), cp.ETag,
-		cp.IfNoneMatchValue, cp.IfModifiedSinceTime.Unix(), cp.IfUnmodifiedSinceTime.Unix(), cp.IsNegativeCache)
+		cp.IfNoneMatchValue, cp.IfModifiedSinceTime.Unix(), cp.IfUnmodifiedSinceTime.Unix(), cp.IsNegativeCache, cp.IsStale)
 }
 
 // GetResponseCachingPolicy examines HTTP response headers for caching headers
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// annotateCanonical adds the provided details to the request's canonical log line
+func annotateCanonical(r *http.Request, p tl.Pairs) {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.Canonical == nil {
+		return
+	}
+	for k, v := range p {
+		rsc.Canonical[k] = v
+	}
+}
+
+// logCanonical logs the request's canonical log line, which narrates how the request was
+// handled from routing through the final response. It is logged at most once per request.
+func logCanonical(r *http.Request, rsc *request.Resources, engine string,
+	cacheStatus status.LookupStatus, statusCode int, ffStatus string, elapsed float64,
+	extents timeseries.ExtentList) {
+
+	if rsc == nil || rsc.Canonical == nil || rsc.Logger == nil {
+		return
+	}
+
+	p := rsc.Canonical
+	rsc.Canonical = nil
+
+	p["engine"] = engine
+	p["method"] = r.Method
+	p["uri"] = r.URL.RequestURI()
+	p["clientIP"] = clientAddress(r)
+	if id := tc.Identity(r.Context()); id != nil {
+		p["user"] = id.User
+	}
+	p["cacheStatus"] = cacheStatus.String()
+	p["code"] = statusCode
+	p["durationMS"] = int(elapsed * 1000)
+	if oc := rsc.OriginConfig; oc != nil {
+		p["originName"] = oc.Name
+		p["originType"] = oc.OriginType
+	}
+	if pc := rsc.PathConfig; pc != nil {
+		p["path"] = pc.Path
+		p["handlerName"] = pc.HandlerName
+	}
+	if ffStatus != "" {
+		p["fastForward"] = ffStatus
+	}
+	if len(extents) > 0 {
+		p["extentsFetched"] = extents.String()
+	}
+
+	rsc.Logger.Canonical("request completed", p)
+}
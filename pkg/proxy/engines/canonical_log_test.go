@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestCanonicalLogDPC(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	rsc.OriginConfig.FastForwardDisable = true
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(6) * time.Hour), End: end}
+
+	r.URL.Path = "/prometheus/api/v1/query_range"
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	p := tl.Pairs{}
+	rsc.Canonical = p
+	client.QueryRangeHandler(w, r)
+
+	if rsc.Canonical != nil {
+		t.Error("expected canonical pairs to be cleared once logged")
+	}
+	if p["engine"] != "DeltaProxyCache" {
+		t.Errorf("expected %s got %v", "DeltaProxyCache", p["engine"])
+	}
+	if p["cacheStatus"] != status.LookupStatusKeyMiss.String() {
+		t.Errorf("expected %s got %v", status.LookupStatusKeyMiss.String(), p["cacheStatus"])
+	}
+	if _, ok := p["cacheKey"]; !ok {
+		t.Error("expected cacheKey in canonical pairs")
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	// extend the request so that a delta is fetched and merged with the cached extent
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Add(-time.Hour).Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	p = tl.Pairs{}
+	rsc.Canonical = p
+	client.QueryRangeHandler(httptest.NewRecorder(), r)
+
+	if p["cacheStatus"] != status.LookupStatusPartialHit.String() {
+		t.Errorf("expected %s got %v", status.LookupStatusPartialHit.String(), p["cacheStatus"])
+	}
+	if _, ok := p["deltaRequests"]; !ok {
+		t.Error("expected deltaRequests in canonical pairs")
+	}
+	if p["deltasMerged"] != 1 {
+		t.Errorf("expected %d got %v", 1, p["deltasMerged"])
+	}
+
+}
+
+func TestLogCanonicalNil(t *testing.T) {
+	// it should not panic when the request has no resources
+	r := httptest.NewRequest("GET", "http://127.0.0.1/", nil)
+	annotateCanonical(r, tl.Pairs{"test": "value"})
+	logCanonical(r, nil, "test", status.LookupStatusHit, 200, "", 0, nil)
+}
@@ -826,6 +826,18 @@ func (me *MatrixEnvelope) SeriesCount() int {
 	return len(me.Data.Result)
 }
 
+// SeriesLabels returns the label set of each Series in the Timeseries
+func (me *MatrixEnvelope) SeriesLabels() []map[string]string {
+	labels := make([]map[string]string, len(me.Data.Result))
+	for i, s := range me.Data.Result {
+		labels[i] = make(map[string]string, len(s.Metric))
+		for k, v := range s.Metric {
+			labels[i][string(k)] = string(v)
+		}
+	}
+	return labels
+}
+
 // ValueCount returns the count of all values across all Series in the Timeseries object
 func (me *MatrixEnvelope) ValueCount() int {
 	c := 0
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// defaultClockSkewLimit is the offset from an origin's clock beyond which a warning is
+// logged, when the origin has no clock skew tolerance
+const defaultClockSkewLimit = time.Minute
+
+// dateResolution is the resolution of the HTTP Date header, by which an origin's clock
+// may appear to be offset even when it is not
+const dateResolution = time.Second
+
+// observeClockSkew records the offset of the local clock from the Date header of an origin's
+// response, and warns when the offset is beyond the origin's clock skew tolerance
+func observeClockSkew(rsc *request.Resources, h http.Header) {
+	date := h.Get(headers.NameDate)
+	if date == "" {
+		return
+	}
+	d, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	oc := rsc.OriginConfig
+	offset := time.Since(d)
+	metrics.ProxyOriginClockSkew.WithLabelValues(oc.Name, oc.OriginType).Set(offset.Seconds())
+
+	limit := defaultClockSkewLimit
+	if oc.ClockSkewTolerance > 0 {
+		limit = oc.ClockSkewTolerance + dateResolution
+	}
+	if time.Duration(math.Abs(float64(offset))) <= limit {
+		return
+	}
+	metrics.ProxyOriginClockSkewWarnings.WithLabelValues(oc.Name, oc.OriginType).Inc()
+	rsc.Logger.WarnOnce("clockoffset."+oc.Name,
+		"clock offset between trickster host and origin is high and may cause data anomalies",
+		tl.Pairs{
+			"originName":    oc.Name,
+			"tricksterTime": strconv.FormatInt(d.Add(offset).Unix(), 10),
+			"originTime":    strconv.FormatInt(d.Unix(), 10),
+			"offset":        strconv.FormatInt(int64(offset.Seconds()), 10) + "s",
+			"tolerance":     oc.ClockSkewTolerance.String(),
+		})
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+func TestObserveClockSkew(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.Name = "clock-skew-test"
+	oc.OriginType = "test"
+	oc.ClockSkewTolerance = 30 * time.Second
+
+	warnings := func() float64 {
+		var m dto.Metric
+		err := metrics.ProxyOriginClockSkewWarnings.WithLabelValues(oc.Name, oc.OriginType).Write(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+
+	skew := func() float64 {
+		var m dto.Metric
+		err := metrics.ProxyOriginClockSkew.WithLabelValues(oc.Name, oc.OriginType).Write(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.GetGauge().GetValue()
+	}
+
+	logger := tl.ConsoleLogger("error")
+	rsc := request.NewResources(oc, nil, nil, nil, nil, nil, logger)
+	key := "clockoffset." + oc.Name
+
+	// no date header
+	observeClockSkew(rsc, http.Header{})
+	if warnings() != 0 {
+		t.Errorf("expected %d got %f", 0, warnings())
+	}
+
+	// unparsable date header
+	observeClockSkew(rsc, http.Header{headers.NameDate: []string{"trickster"}})
+	if warnings() != 0 {
+		t.Errorf("expected %d got %f", 0, warnings())
+	}
+
+	// within the tolerance
+	h := http.Header{}
+	h.Set(headers.NameDate, time.Now().Add(-20*time.Second).Format(http.TimeFormat))
+	observeClockSkew(rsc, h)
+	if warnings() != 0 {
+		t.Errorf("expected %d got %f", 0, warnings())
+	}
+	if s := skew(); s < 19 || s > 22 {
+		t.Errorf("expected skew near %d got %f", 20, s)
+	}
+	if logger.HasWarnedOnce(key) {
+		t.Errorf("expected %t got %t", false, true)
+	}
+
+	// origin clock ahead, beyond the tolerance
+	h.Set(headers.NameDate, time.Now().Add(2*time.Minute).Format(http.TimeFormat))
+	observeClockSkew(rsc, h)
+	if warnings() != 1 {
+		t.Errorf("expected %d got %f", 1, warnings())
+	}
+	if s := skew(); s > -118 || s < -121 {
+		t.Errorf("expected skew near %d got %f", -120, s)
+	}
+	if !logger.HasWarnedOnce(key) {
+		t.Errorf("expected %t got %t", true, false)
+	}
+
+	// without a tolerance, the default limit applies
+	oc.ClockSkewTolerance = 0
+	h.Set(headers.NameDate, time.Now().Add(-45*time.Second).Format(http.TimeFormat))
+	observeClockSkew(rsc, h)
+	if warnings() != 1 {
+		t.Errorf("expected %d got %f", 1, warnings())
+	}
+
+}
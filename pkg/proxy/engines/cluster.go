@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// forwardToPeer serves the request from the cluster peer that owns key, and returns false if
+// the request should be served locally instead
+func forwardToPeer(w http.ResponseWriter, r *http.Request, key string) bool {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.OriginConfig == nil || rsc.OriginConfig.Cluster == nil {
+		return false
+	}
+	if !rsc.OriginConfig.Cluster.Forward(w, r, key) {
+		return false
+	}
+	annotateCanonical(r, tl.Pairs{"decision": "peer: served by the cluster peer that owns the key",
+		"peer": rsc.OriginConfig.Cluster.Owner(key)})
+	return true
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster"
+	clo "github.com/tricksterproxy/trickster/pkg/cluster/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+func TestDeltaProxyCacheRequestClusterPeer(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameTricksterResult, "engine=DeltaProxyCache; status=hit")
+		w.Write([]byte("from peer"))
+	}))
+	defer peer.Close()
+
+	// the peer is the only member of the ring, so it owns every key
+	o := clo.NewOptions()
+	o.Self = "http://127.0.0.1:1"
+	o.Peers = []string{peer.URL}
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.Cluster = cluster.New(o, "/trickster/cluster/gossip")
+
+	step := time.Duration(300) * time.Second
+	end := time.Now()
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s", int(step.Seconds()),
+		end.Add(-time.Hour).Unix(), end.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "from peer" {
+		t.Errorf("expected %s got %s", "from peer", string(b))
+	}
+
+	// a request forwarded by a peer is served locally
+	w = httptest.NewRecorder()
+	r.Header.Set(headers.NameTricksterPeer, peer.URL)
+	client.QueryRangeHandler(w, r)
+	err = testResultHeaderPartMatch(w.Result().Header, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestObjectProxyCacheRequestClusterPeer(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from peer"))
+	}))
+	defer peer.Close()
+
+	o := clo.NewOptions()
+	o.Self = "http://127.0.0.1:1"
+	o.Peers = []string{peer.URL}
+	rsc.OriginConfig.Cluster = cluster.New(o, "/trickster/cluster/gossip")
+
+	// the harness clears RequestURI, which is required to forward the request
+	r.RequestURI = r.URL.RequestURI()
+	w := httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r)
+	b, _ := ioutil.ReadAll(w.Result().Body)
+	if string(b) != "from peer" {
+		t.Errorf("expected %s got %s", "from peer", string(b))
+	}
+
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// collapseHeaders are the request headers, in addition to the path's cache key headers, that
+// distinguish otherwise identical upstream requests, since the origin may respond to them
+// differently
+var collapseHeaders = []string{headers.NameAuthorization, "Cookie", headers.NameAccept,
+	headers.NameAcceptEncoding}
+
+// inflightFetch is an upstream fetch whose response is shared with the identical requests
+// that arrive while it is in flight
+type inflightFetch struct {
+	done      chan struct{}
+	followers int
+	// body and resp are set before done is closed, when the fetch has followers. resp is
+	// nil if the fetch did not complete
+	body    []byte
+	resp    *http.Response
+	elapsed time.Duration
+}
+
+// inflightFetches are the collapsible upstream fetches in flight, keyed by collapse key
+var inflightFetches = struct {
+	sync.Mutex
+	m map[string]*inflightFetch
+}{m: make(map[string]*inflightFetch)}
+
+// collapseKey returns the key that identifies the upstream request among concurrent fetches,
+// and false if the request's origin does not collapse requests
+func (pr *proxyRequest) collapseKey() (string, bool) {
+
+	r := pr.upstreamRequest
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.OriginConfig == nil || !rsc.OriginConfig.RequestCollapsingEnabled ||
+		IsWebSocketUpgrade(r) {
+		return "", false
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	hn := collapseHeaders
+	if rsc.PathConfig != nil && len(rsc.PathConfig.CacheKeyHeaders) > 0 {
+		hn = append(append([]string{}, hn...), rsc.PathConfig.CacheKeyHeaders...)
+	}
+	parts := make([]string, 0, len(hn)+4)
+	parts = append(parts, rsc.OriginConfig.Name, r.Method, r.URL.String())
+	for _, h := range hn {
+		parts = append(parts, strings.Join(r.Header[http.CanonicalHeaderKey(h)], ","))
+	}
+	parts = append(parts, string(body))
+	return md5.Checksum(strings.Join(parts, "\n")), true
+}
+
+// fetchCollapsed fetches the upstream request, unless an identical request is already being
+// fetched, in which case it waits for and returns a copy of that fetch's response
+func (pr *proxyRequest) fetchCollapsed(key string) (*bytes.Buffer, *http.Response, time.Duration) {
+
+	inflightFetches.Lock()
+	if f, ok := inflightFetches.m[key]; ok {
+		f.followers++
+		inflightFetches.Unlock()
+		<-f.done
+		if f.resp == nil {
+			return pr.fetchBuffer()
+		}
+		oc := request.GetResources(pr.upstreamRequest).OriginConfig
+		metrics.ProxyCollapsedRequests.WithLabelValues(oc.Name, oc.OriginType).Inc()
+		buf := buffers.Get(len(f.body))
+		buf.Write(f.body)
+		resp := *f.resp
+		resp.Header = f.resp.Header.Clone()
+		return buf, &resp, f.elapsed
+	}
+	f := &inflightFetch{done: make(chan struct{})}
+	inflightFetches.m[key] = f
+	inflightFetches.Unlock()
+
+	var buf *bytes.Buffer
+	var resp *http.Response
+	var elapsed time.Duration
+
+	// the fetch is removed before its response is shared, so that no follower can join it
+	// after it is shared, and followers are released even if the fetch panics
+	defer func() {
+		inflightFetches.Lock()
+		delete(inflightFetches.m, key)
+		followers := f.followers
+		inflightFetches.Unlock()
+		if followers > 0 && resp != nil {
+			f.body = append([]byte(nil), buf.Bytes()...)
+			r := *resp
+			r.Header = resp.Header.Clone()
+			f.resp = &r
+			f.elapsed = elapsed
+		}
+		close(f.done)
+	}()
+
+	buf, resp, elapsed = pr.fetchBuffer()
+	return buf, resp, elapsed
+}
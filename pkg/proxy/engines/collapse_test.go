@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newCollapseTestRequest returns a proxyRequest for the url, whose origin collapses requests
+func newCollapseTestRequest(rsc *request.Resources, method, url string,
+	body []byte) *proxyRequest {
+	r := httptest.NewRequest(method, url, bytes.NewReader(body))
+	r = r.WithContext(tc.WithResources(r.Context(), rsc))
+	return newProxyRequest(r, httptest.NewRecorder())
+}
+
+func TestFetchCollapsed(t *testing.T) {
+
+	ts, _, r, _, err := tu.NewTestInstance("", nil, 200, "", nil, "rpc", "/", "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginConfig.RequestCollapsingEnabled = true
+	rsc.OriginConfig.HTTPClient = http.DefaultClient
+
+	// the origin holds each response until it is released, so that requests overlap
+	var hits int32
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("X-Test", "value")
+		w.Write([]byte("collapsed body"))
+	}))
+	defer origin.Close()
+
+	collapsed := func() float64 {
+		var m dto.Metric
+		metrics.ProxyCollapsedRequests.WithLabelValues(rsc.OriginConfig.Name,
+			rsc.OriginConfig.OriginType).Write(&m)
+		return m.GetCounter().GetValue()
+	}
+	before := collapsed()
+
+	const n = 5
+	key, ok := newCollapseTestRequest(rsc, http.MethodGet, origin.URL+"/query?q=1", nil).collapseKey()
+	if !ok {
+		t.Fatal("expected request to be collapsible")
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pr := newCollapseTestRequest(rsc, http.MethodGet, origin.URL+"/query?q=1", nil)
+			buf, resp, _ := pr.FetchBuffer()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Test") != "value" {
+				t.Errorf("unexpected response %d %v", resp.StatusCode, resp.Header)
+			}
+			bodies[i] = append([]byte(nil), buf.Bytes()...)
+		}(i)
+	}
+
+	// wait for each request to join the fetch in flight before the origin responds
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		inflightFetches.Lock()
+		f, ok := inflightFetches.m[key]
+		joined := ok && f.followers == n-1
+		inflightFetches.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for requests to collapse")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if h := atomic.LoadInt32(&hits); h != 1 {
+		t.Errorf("expected %d upstream request got %d", 1, h)
+	}
+	for i, b := range bodies {
+		if string(b) != "collapsed body" {
+			t.Errorf("unexpected body for request %d: %s", i, string(b))
+		}
+	}
+	if c := collapsed() - before; c != n-1 {
+		t.Errorf("expected %d collapsed requests got %f", n-1, c)
+	}
+
+	// requests that are not in flight together are fetched separately
+	pr := newCollapseTestRequest(rsc, http.MethodGet, origin.URL+"/query?q=1", nil)
+	pr.FetchBuffer()
+	if h := atomic.LoadInt32(&hits); h != 2 {
+		t.Errorf("expected %d upstream requests got %d", 2, h)
+	}
+	if len(inflightFetches.m) != 0 {
+		t.Errorf("expected no fetches in flight got %d", len(inflightFetches.m))
+	}
+}
+
+func TestCollapseKey(t *testing.T) {
+
+	ts, _, r, _, err := tu.NewTestInstance("", nil, 200, "", nil, "rpc", "/", "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	rsc := request.GetResources(r)
+
+	const u = "http://127.0.0.1/query?q=1"
+	if _, ok := newCollapseTestRequest(rsc, http.MethodGet, u, nil).collapseKey(); ok {
+		t.Error("expected request not to be collapsible")
+	}
+	rsc.OriginConfig.RequestCollapsingEnabled = true
+
+	key := func(method, url string, body []byte, h http.Header) string {
+		pr := newCollapseTestRequest(rsc, method, url, body)
+		for k, v := range h {
+			pr.upstreamRequest.Header[k] = v
+		}
+		k, _ := pr.collapseKey()
+		return k
+	}
+
+	k := key(http.MethodGet, u, nil, nil)
+	if k2 := key(http.MethodGet, u, nil, http.Header{"User-Agent": {"other"}}); k2 != k {
+		t.Error("expected requests from different user agents to collapse")
+	}
+	if k2 := key(http.MethodGet, u, nil, http.Header{"Authorization": {"other"}}); k2 == k {
+		t.Error("expected requests with different credentials not to collapse")
+	}
+	if k2 := key(http.MethodGet, u+"&q2=2", nil, nil); k2 == k {
+		t.Error("expected requests for different urls not to collapse")
+	}
+
+	rsc.PathConfig.CacheKeyHeaders = []string{"X-Scope-OrgID"}
+	k = key(http.MethodGet, u, nil, nil)
+	if k2 := key(http.MethodGet, u, nil, http.Header{"X-Scope-Orgid": {"tenant"}}); k2 == k {
+		t.Error("expected requests with different cache key headers not to collapse")
+	}
+
+	// the body is part of the key, and remains readable by the fetch
+	pr := newCollapseTestRequest(rsc, http.MethodPost, u, []byte("a=1"))
+	k, _ = pr.collapseKey()
+	if k2 := key(http.MethodPost, u, []byte("a=2"), nil); k2 == k {
+		t.Error("expected requests with different bodies not to collapse")
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(pr.upstreamRequest.Body)
+	if b.String() != "a=1" {
+		t.Errorf("expected %s got %s", "a=1", b.String())
+	}
+}
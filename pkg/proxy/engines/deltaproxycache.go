@@ -19,12 +19,15 @@ package engines
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	tc "github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	tpe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
@@ -33,6 +36,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 
@@ -62,23 +66,31 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	locker := cache.Locker()
 
 	client := rsc.OriginClient.(origins.TimeseriesClient)
+	explain := isExplainRequest(r, oc)
 
 	trq, err := client.ParseTimeRangeQuery(r)
 	if err != nil {
 		// err may simply mean incompatible query (e.g., non-select), so just proxy
-		DoProxy(w, r, true)
+		proxyOrExplain(w, r, nil, explain, "proxy: not a cacheable timeseries query")
 		return
 	}
 
 	var cacheStatus status.LookupStatus
 
 	pr := newProxyRequest(r, w)
-	trq.FastForwardDisable = oc.FastForwardDisable || trq.FastForwardDisable
+	// a request from another Trickster is fast forwarded by that Trickster, which caches
+	// the response, so the fast forward data must not be included in it. the statement keeps
+	// each query consistently in or out of a fast forward feature flag's percentage
+	trq.FastForwardDisable = trq.FastForwardDisable ||
+		!featureflags.Enabled(featureflags.FastForward, oc.Name, trq.Statement, !oc.FastForwardDisable) ||
+		r.Header.Get(headers.NameTricksterHops) != ""
 	trq.NormalizeExtent()
 
 	// this is used to ensure the head of the cache respects the BackFill Tolerance
 	bf := timeseries.Extent{Start: time.Unix(0, 0), End: trq.Extent.End}
-	bt := trq.GetBackfillTolerance(oc.BackfillTolerance)
+	// the clock skew tolerance extends the backfill tolerance, so that data which is not yet
+	// complete at the origin, due to an offset between the clocks, is not cached
+	bt := trq.GetBackfillTolerance(oc.BackfillTolerance) + oc.ClockSkewTolerance
 
 	if !trq.IsOffset && bt > 0 {
 		bf.End = bf.End.Add(-bt)
@@ -88,26 +100,50 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 
 	OldestRetainedTimestamp := time.Time{}
 	if oc.TimeseriesEvictionMethod == evictionmethods.EvictionMethodOldest {
-		OldestRetainedTimestamp = now.Truncate(trq.Step).Add(-(trq.Step * oc.TimeseriesRetention))
+		OldestRetainedTimestamp = trq.TruncateToStep(now).Add(-(trq.Step * oc.TimeseriesRetention))
 		if trq.Extent.End.Before(OldestRetainedTimestamp) {
 			pr.Logger.Debug("timerange end is too early to consider caching",
 				tl.Pairs{"oldestRetainedTimestamp": OldestRetainedTimestamp,
 					"step": trq.Step, "retention": oc.TimeseriesRetention})
-			DoProxy(w, r, true)
+			proxyOrExplain(w, r, trq, explain, "proxy: timerange end is too early to cache")
 			return
 		}
 		if trq.Extent.Start.After(bf.End) {
 			pr.Logger.Debug("timerange is too new to cache due to backfill tolerance",
 				tl.Pairs{"backFillToleranceSecs": bt,
 					"newestRetainedTimestamp": bf.End, "queryStart": trq.Extent.Start})
-			DoProxy(w, r, true)
+			proxyOrExplain(w, r, trq, explain, "proxy: timerange is too new to cache")
 			return
 		}
 	}
 
+	// the retention window bounds the age of cached data regardless of the eviction method
+	retainedSince := oc.RetentionTrimmer.Oldest()
+	if !retainedSince.IsZero() && trq.Extent.End.Before(retainedSince) {
+		pr.Logger.Debug("timerange end is older than the retention window",
+			tl.Pairs{"retainedSince": retainedSince, "queryEnd": trq.Extent.End})
+		proxyOrExplain(w, r, trq, explain, "proxy: timerange end is older than the retention window")
+		return
+	}
+
 	client.SetExtent(pr.upstreamRequest, trq, &trq.Extent)
 	key := oc.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey(trq.TemplateURL, "")
+
+	// an explained request is answered from this Trickster's cache, even if another
+	// cluster peer owns the timeseries, and is not fetched from the origin
+	if explain {
+		explainTimeseries(w, r, pr, client, trq, key, bf.End)
+		return
+	}
+
+	// timeseries owned by another cluster peer are served from the owner's cache
+	if forwardToPeer(w, r, key) {
+		return
+	}
+
 	pr.cacheLock, _ = locker.RAcquire(key)
+	annotateCanonical(r, tl.Pairs{"cacheKey": key, "requestedExtent": trq.Extent.String(),
+		"step": trq.Step.String()})
 
 	// this is used to determine if Fast Forward should be activated for this request
 	normalizedNow := &timeseries.TimeRangeQuery{
@@ -175,6 +211,28 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 					Respond(w, doc.StatusCode, h, doc.Body)
 					return // fetchTimeseries logs the error
 				}
+			} else if doc.isSoftPurged() {
+				// soft-purged timeseries are refetched in full, and the stale copy is only
+				// served when the origin is unable to provide a fresh one
+				fts, fdoc, fe, ferr := fetchTimeseries(pr, trq, client)
+				if ferr != nil && fdoc.StatusCode > 0 &&
+					fdoc.StatusCode < http.StatusInternalServerError {
+					pr.cacheLock.RRelease()
+					h := fdoc.SafeHeaderClone()
+					recordDPCResult(r, status.LookupStatusProxyError, fdoc.StatusCode,
+						r.URL.Path, "", fe.Seconds(), nil, h)
+					Respond(w, fdoc.StatusCode, h, fdoc.Body)
+					return // fetchTimeseries logs the error
+				}
+				if ferr != nil {
+					pr.Logger.Warn("serving soft-purged timeseries after upstream failure",
+						tl.Pairs{"cacheKey": key, "upstreamStatus": fdoc.StatusCode})
+					annotateCanonical(r, tl.Pairs{"decision": "phit: stale-if-error"})
+					cacheStatus = status.LookupStatusPartialHit
+				} else {
+					cts, doc, elapsed = fts, fdoc, fe
+					cacheStatus = status.LookupStatusKeyMiss
+				}
 			} else {
 				if oc.TimeseriesEvictionMethod == evictionmethods.EvictionMethodLRU {
 					el := cts.Extents()
@@ -185,6 +243,7 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 							pr.cacheLock.RRelease()
 							go pr.Logger.Debug("timerange end is too early to consider caching",
 								tl.Pairs{"step": trq.Step, "retention": oc.TimeseriesRetention})
+							annotateCanonical(r, tl.Pairs{"decision": "proxy: timerange end is too early to cache"})
 							DoProxy(w, r, true)
 							return
 						}
@@ -197,6 +256,7 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 									"queryStart":              trq.Extent.Start,
 								},
 							)
+							annotateCanonical(r, tl.Pairs{"decision": "proxy: timerange not cached due to backfill tolerance"})
 							DoProxy(w, r, true)
 							return
 						}
@@ -211,6 +271,7 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	var missRanges timeseries.ExtentList
 	if cacheStatus == status.LookupStatusPartialHit {
 		missRanges = trq.CalculateDeltas(cts.Extents())
+		annotateCanonical(r, tl.Pairs{"cachedExtents": timeseries.ExtentList(cts.Extents()).String()})
 	}
 
 	if len(missRanges) == 0 && cacheStatus == status.LookupStatusPartialHit {
@@ -295,43 +356,77 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	wg := sync.WaitGroup{}
 	appendLock := sync.Mutex{}
 	uncachedValueCount := 0
+	// deltaResults records the outcome of each delta request for the canonical log line
+	deltaResults := make([]string, len(missRanges))
+
+	// the origin's data revision as of the cached timeseries, which is compared to that of each
+	// upstream response to detect when the origin has revised previously-cached data
+	var cachedRevision, revision string
+	if oc.RevisionHeader != "" && cacheStatus == status.LookupStatusPartialHit {
+		doc.headerLock.Lock()
+		cachedRevision = http.Header(doc.Headers).Get(oc.RevisionHeader)
+		doc.headerLock.Unlock()
+	}
+
+	// fetchExtent fetches the extent from the upstream origin, and returns its timeseries, which
+	// is nil when the upstream response is unusable, and the upstream response status code
+	fetchExtent := func(e timeseries.Extent, rq *proxyRequest,
+		spanName string) (timeseries.Timeseries, int) {
+		rq.upstreamRequest = rq.WithContext(tctx.WithResources(
+			trace.ContextWithSpan(context.Background(), span),
+			request.NewResources(oc, pc, cc, cache, client, rsc.Tracer, pr.Logger)))
+		// the fetched extent may be padded to refresh cached points preceding the gap
+		pe := trq.PadExtent(e)
+		client.SetExtent(rq.upstreamRequest, trq, &pe)
+
+		ctxMR, spanMR := tspan.NewChildSpan(rq.upstreamRequest.Context(), rsc.Tracer, spanName)
+		if spanMR != nil {
+			rq.upstreamRequest = rq.upstreamRequest.WithContext(ctxMR)
+			defer spanMR.End()
+		}
+
+		// the body is only needed until it is unmarshaled, so it is read into a pooled buffer
+		buf, resp, _ := rq.FetchBuffer()
+		defer buffers.Put(buf)
+		if resp.StatusCode != http.StatusOK || buf.Len() == 0 {
+			return nil, resp.StatusCode
+		}
+		nts, err := client.UnmarshalTimeseries(buf.Bytes())
+		if err != nil {
+			pr.Logger.Error("proxy object unmarshaling failed",
+				tl.Pairs{"body": buf.String()})
+			return nil, resp.StatusCode
+		}
+		doc.headerLock.Lock()
+		headers.Merge(doc.Headers, resp.Header)
+		doc.headerLock.Unlock()
+		nts.SetStep(trq.Step)
+		nts.SetExtents([]timeseries.Extent{e})
+		if cachedRevision != "" {
+			if v := resp.Header.Get(oc.RevisionHeader); v != "" && v != cachedRevision {
+				appendLock.Lock()
+				revision = v
+				appendLock.Unlock()
+			}
+		}
+		return nts, resp.StatusCode
+	}
 
 	// iterate each time range that the client needs and fetch from the upstream origin
 	for i := range missRanges {
 		wg.Add(1)
 		// This fetches the gaps from the origin and adds their datasets to the merge list
-		go func(e *timeseries.Extent, rq *proxyRequest) {
+		go func(i int, e timeseries.Extent, rq *proxyRequest) {
 			defer wg.Done()
-			rq.upstreamRequest = rq.WithContext(tctx.WithResources(
-				trace.ContextWithSpan(context.Background(), span),
-				request.NewResources(oc, pc, cc, cache, client, rsc.Tracer, pr.Logger)))
-			client.SetExtent(rq.upstreamRequest, trq, e)
-
-			ctxMR, spanMR := tspan.NewChildSpan(rq.upstreamRequest.Context(), rsc.Tracer, "FetchRange")
-			if spanMR != nil {
-				rq.upstreamRequest = rq.upstreamRequest.WithContext(ctxMR)
-				defer spanMR.End()
-			}
-
-			body, resp, _ := rq.Fetch()
-			if resp.StatusCode == http.StatusOK && len(body) > 0 {
-				nts, err := client.UnmarshalTimeseries(body)
-				if err != nil {
-					pr.Logger.Error("proxy object unmarshaling failed",
-						tl.Pairs{"body": string(body)})
-					return
-				}
-				doc.headerLock.Lock()
-				headers.Merge(doc.Headers, resp.Header)
-				doc.headerLock.Unlock()
+			nts, sc := fetchExtent(e, rq, "FetchRange")
+			deltaResults[i] = e.String() + ":" + strconv.Itoa(sc)
+			if nts != nil {
 				uncachedValueCount += nts.ValueCount()
-				nts.SetStep(trq.Step)
-				nts.SetExtents([]timeseries.Extent{*e})
 				appendLock.Lock()
 				mts = append(mts, nts)
 				appendLock.Unlock()
 			}
-		}(&missRanges[i], pr.Clone())
+		}(i, missRanges[i], pr.Clone())
 	}
 
 	var hasFastForwardData bool
@@ -379,6 +474,56 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		elapsed = time.Since(now)
 		cts.Merge(true, mts...)
 	}
+	if len(missRanges) > 0 {
+		annotateCanonical(r, tl.Pairs{
+			"deltaRequests": strings.Join(deltaResults, ";"),
+			"deltasMerged":  len(mts),
+		})
+	}
+
+	// when the origin reports that it revised its data since the timeseries was cached, the
+	// revised data is removed from the timeseries and its requested portion is re-fetched
+	if revision != "" {
+		since := revisedSince(revision)
+		re := trq.Extent
+		if since.After(re.Start) {
+			re.Start = time.Unix(0, since.UnixNano()/int64(trq.Step)*int64(trq.Step))
+		}
+		var fts timeseries.Timeseries
+		ok := re.Start.After(re.End)
+		if !ok {
+			fts, _ = fetchExtent(re, pr.Clone(), "FetchRevisedRange")
+			ok = fts != nil
+		}
+		if ok {
+			if fts != nil {
+				uncachedValueCount += fts.ValueCount()
+			}
+			if kept, excluded := excludeRange(cts, since, time.Time{}); excluded {
+				if kept == nil {
+					kept = fts
+				} else if fts != nil {
+					kept.Merge(true, fts)
+				}
+				if kept != nil {
+					cts = kept
+				}
+			} else if fts != nil {
+				cts.Merge(true, fts)
+			}
+			elapsed = time.Since(now)
+			annotateCanonical(r, tl.Pairs{"dataRevision": revision, "revisedExtent": re.String()})
+		} else {
+			// the cached data is kept, and the previous revision is restored so that the
+			// revision is detected again by a subsequent request
+			pr.Logger.Warn("could not refetch revised timeseries",
+				tl.Pairs{"cacheKey": key, "dataRevision": revision})
+			doc.headerLock.Lock()
+			http.Header(doc.Headers).Set(oc.RevisionHeader, cachedRevision)
+			doc.headerLock.Unlock()
+		}
+	}
+	annotateCanonical(r, tl.Pairs{"cacheWrite": writeLock != nil})
 
 	// cts is the cacheable time series, rts is the user's response timeseries
 	rts := cts.Clone()
@@ -395,6 +540,9 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			default:
 				cts.CropToRange(timeseries.Extent{End: bf.End, Start: OldestRetainedTimestamp})
 			}
+			if !retainedSince.IsZero() {
+				cts.CropToRange(timeseries.Extent{End: bf.End, Start: retainedSince})
+			}
 			// Don't cache datasets with empty extents
 			// (everything was cropped so there is nothing to cache)
 			if len(cts.Extents()) > 0 {
@@ -411,7 +559,12 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 					}
 					doc.Body = cdata
 				}
-				if err := WriteCache(ctx, cache, key, doc, oc.TimeseriesTTL, oc.CompressableTypes); err != nil {
+				ttl := oc.Budget.Stretch(oc.TimeseriesTTL)
+				err := WriteCache(ctx, cache, key, doc, ttl, oc.CompressableTypes)
+				if err == tpe.ErrObjectTooLarge {
+					pr.Logger.Debug("timeseries exceeds max object size, not cached",
+						tl.Pairs{"originName": oc.Name, "cacheKey": key})
+				} else if err != nil {
 					pr.Logger.Error("error writing object to cache",
 						tl.Pairs{
 							"originName": oc.Name,
@@ -420,6 +573,9 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 							"detail":     err.Error(),
 						},
 					)
+				} else {
+					oc.RetentionTrimmer.Track(key, ttl)
+					oc.Replicator.Enqueue(key, ttl)
 				}
 			}
 		}()
@@ -441,6 +597,14 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			oc.OriginType, "cached", r.URL.Path).Add(float64(cachedValueCount))
 	}
 
+	// fill the missing steps of the response's series, such as at the seams between cached and
+	// freshly-fetched extents. This applies only to the response, and not to the cached data
+	if oc.GapFill != "" && oc.GapFill != timeseries.GapFillNone {
+		if gf, ok := rts.(timeseries.GapFiller); ok {
+			gf.FillGaps(oc.GapFill, trq.Step, oc.GapFillMax)
+		}
+	}
+
 	// Merge Fast Forward data if present. This must be done after the Downstream Crop since
 	// the cropped extent was normalized to stepboundaries and would remove fast forward data
 	// If the fast forward data point is older (e.g. cached) than the last datapoint in the
@@ -455,6 +619,22 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	rh := doc.SafeHeaderClone()
 	sc := doc.StatusCode
 
+	if pc != nil && pc.DownstreamCachingHeaders {
+		// data older than the backfill tolerance is immutable and can be cached downstream
+		// for the full TimeseriesTTL; otherwise, it is fresh until the next step boundary
+		ttl := oc.TimeseriesTTL
+		if !trq.IsOffset && !trq.Extent.End.Before(normalizedNow.Extent.End.Add(-bt)) {
+			ttl = trq.Step - now.Sub(normalizedNow.Extent.End)
+			if hasFastForwardData && oc.FastForwardTTL < ttl {
+				ttl = oc.FastForwardTTL
+			}
+			if ttl > oc.TimeseriesTTL {
+				ttl = oc.TimeseriesTTL
+			}
+		}
+		setDownstreamCachingHeaders(r, rh, pc, sc, ttl, rdata)
+	}
+
 	// Respond to the user. Using the response headers from a Delta Response,
 	// so as to not map conflict with cacheData on WriteCache
 	logDeltaRoutine(pr.Logger, dpStatus)
@@ -513,6 +693,19 @@ func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
 	return ts, d, elapsed, nil
 }
 
+// revisedSince returns the time at and after which the origin's data was revised, per a data
+// revision header value that is an RFC 3339 or Unix epoch seconds watermark. The zero time is
+// returned for any other value, since an opaque revision identifier may mean any data was revised
+func revisedSince(v string) time.Time {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(i, 0)
+	}
+	return time.Time{}
+}
+
 func recordDPCResult(r *http.Request, cacheStatus status.LookupStatus, httpStatus int, path,
 	ffStatus string, elapsed float64, needed []timeseries.Extent, header http.Header) {
 	recordResults(r, "DeltaProxyCache", cacheStatus, httpStatus, path, ffStatus, elapsed,
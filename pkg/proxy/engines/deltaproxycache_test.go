@@ -21,11 +21,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -153,6 +156,203 @@ func TestDeltaProxyCacheRequestMissThenHit(t *testing.T) {
 	}
 }
 
+func TestDeltaProxyCacheRequestSoftPurge(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+
+	oc.FastForwardDisable = true
+	step := time.Duration(300) * time.Second
+
+	now := time.Now()
+	end := now.Add(-time.Duration(12) * time.Hour)
+
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+	extn := timeseries.Extent{Start: extr.Start.Truncate(step), End: extr.End.Truncate(step)}
+
+	expected, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency, extn.Start, extn.End, step)
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	fetch := func(cacheStatus string) {
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err = testStringMatch(string(bodyBytes), expected); err != nil {
+			t.Error(err)
+		}
+		if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+			t.Error(err)
+		}
+		if err = testResultHeaderPartMatch(resp.Header,
+			map[string]string{"status": cacheStatus}); err != nil {
+			t.Error(err)
+		}
+		// Give time for the object to be written to cache in a separate goroutine from response
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	softPurge := InvalidationFunc(oc, rsc.CacheClient, client, rsc.Logger)
+
+	fetch("kmiss")
+	if n, err := softPurge(&invalidation.Request{Soft: true}); err != nil || n != 1 {
+		t.Errorf("expected %d got %d: %v", 1, n, err)
+	}
+
+	// the soft-purged timeseries is refetched in full, which clears the mark
+	fetch("kmiss")
+	fetch("hit")
+
+	if n, err := softPurge(&invalidation.Request{Soft: true}); err != nil || n != 1 {
+		t.Errorf("expected %d got %d: %v", 1, n, err)
+	}
+
+	// the soft-purged timeseries is served when the origin can't be reached
+	ts.Close()
+	fetch("hit")
+}
+
+// revisionTransport sets a data revision header on each upstream response, and records the
+// start of each upstream request's time range
+type revisionTransport struct {
+	revision string
+	starts   []string
+	lock     sync.Mutex
+}
+
+func (rt *revisionTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	rt.lock.Lock()
+	rt.starts = append(rt.starts, r.URL.Query().Get(upStart))
+	resp.Header.Set("X-Data-Revision", rt.revision)
+	rt.lock.Unlock()
+	return resp, nil
+}
+
+func TestDeltaProxyCacheRequestRevisedData(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+
+	oc.FastForwardDisable = true
+	oc.RevisionHeader = "X-Data-Revision"
+	rt := &revisionTransport{revision: "1"}
+	oc.HTTPClient.Transport = rt
+
+	step := time.Duration(300) * time.Second
+
+	now := time.Now()
+	end := now.Add(-time.Duration(12) * time.Hour)
+
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+	extn := timeseries.Extent{Start: normalizeTime(extr.Start, step), End: normalizeTime(extr.End, step)}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+
+	fetch := func(cacheStatus string) []string {
+		u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+			int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+		r.URL = u
+		expected, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency, extn.Start, extn.End, step)
+		rt.starts = nil
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err = testStringMatch(string(bodyBytes), expected); err != nil {
+			t.Error(err)
+		}
+		if err = testResultHeaderPartMatch(resp.Header,
+			map[string]string{"status": cacheStatus}); err != nil {
+			t.Error(err)
+		}
+		// Give time for the object to be written to cache in a separate goroutine from response
+		time.Sleep(time.Millisecond * 10)
+		return rt.starts
+	}
+
+	fetch("kmiss")
+
+	// the revision is unchanged, so only the delta is fetched
+	extr.End = extr.End.Add(time.Hour)
+	extn.End = normalizeTime(extr.End, step)
+	if starts := fetch("phit"); len(starts) != 1 {
+		t.Errorf("expected %d got %d", 1, len(starts))
+	}
+
+	// the data since the watermark was revised, and is re-fetched along with the delta
+	revised := extn.Start.Add(time.Duration(6) * time.Hour)
+	rt.revision = revised.Format(time.RFC3339)
+	extr.End = extr.End.Add(time.Hour)
+	extn.End = normalizeTime(extr.End, step)
+	starts := fetch("phit")
+	if len(starts) != 2 {
+		t.Errorf("expected %d got %d", 2, len(starts))
+	} else if starts[1] != strconv.FormatInt(revised.Unix(), 10) {
+		t.Errorf("expected %d got %s", revised.Unix(), starts[1])
+	}
+
+	fetch("hit")
+
+	// an opaque revision re-fetches the entire requested range
+	rt.revision = "abc"
+	extr.End = extr.End.Add(time.Hour)
+	extn.End = normalizeTime(extr.End, step)
+	starts = fetch("phit")
+	if len(starts) != 2 {
+		t.Errorf("expected %d got %d", 2, len(starts))
+	} else if starts[1] != strconv.FormatInt(extn.Start.Unix(), 10) {
+		t.Errorf("expected %d got %s", extn.Start.Unix(), starts[1])
+	}
+}
+
+func TestRevisedSince(t *testing.T) {
+
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"2020-06-01T12:00:00Z", time.Unix(1591012800, 0)},
+		{"1591012800", time.Unix(1591012800, 0)},
+		{"r42-abc", time.Time{}},
+		{"", time.Time{}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if v := revisedSince(test.value); !v.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+}
+
 func TestDeltaProxyCacheRequestAllItemsTooNew(t *testing.T) {
 
 	ts, w, r, rsc, err := setupTestHarnessDPC()
@@ -968,6 +1168,52 @@ func TestDeltaProxyCacheRequestFastForward(t *testing.T) {
 	}
 }
 
+func TestDeltaProxyCacheRequestFastForwardFromTrickster(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc.CacheConfig.CacheType = "test"
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+
+	client.InstantCacheKey = "test-dpc-ff-hops-key-instant"
+	client.RangeCacheKey = "test-dpc-ff-hops-key-range"
+
+	oc.FastForwardDisable = false
+
+	step := time.Duration(300) * time.Second
+	now := time.Now()
+	client.fftime = now.Truncate(oc.FastForwardTTL)
+	extr := timeseries.Extent{Start: now.Add(-time.Duration(12) * time.Hour), End: now}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("instantKey=%s&rangeKey=%s&step=%d&start=%d&end=%d&query=%s",
+		client.InstantCacheKey, client.RangeCacheKey,
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	// requests from another Trickster are not fast forwarded, since that Trickster does so
+	r.Header.Set(headers.NameTricksterHops, "trickster-edge")
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"ffstatus": "off"})
+	if err != nil {
+		t.Error(err)
+	}
+
+}
+
 func TestDeltaProxyCacheRequestFastForwardUrlError(t *testing.T) {
 
 	ts, w, r, rsc, err := setupTestHarnessDPC()
@@ -56,6 +56,11 @@ type HTTPDocument struct {
 	headerLock       sync.Mutex
 }
 
+// isSoftPurged returns true if the document was marked stale by a soft purge
+func (d *HTTPDocument) isSoftPurged() bool {
+	return d != nil && d.CachingPolicy != nil && d.CachingPolicy.IsStale
+}
+
 // SafeHeaderClone returns a threadsafe copy of the Document Header
 func (d *HTTPDocument) SafeHeaderClone() http.Header {
 	d.headerLock.Lock()
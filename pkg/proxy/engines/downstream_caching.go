@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// setDownstreamCachingHeaders sets Cache-Control, Expires and ETag headers on a response
+// to the downstream client, based on the remaining freshness (ttl) of the served object,
+// so that a CDN or browser in front of Trickster can cache the response appropriately.
+// It is a no-op unless the path is configured with downstream_caching_headers
+func setDownstreamCachingHeaders(r *http.Request, h http.Header, pc *po.Options,
+	code int, ttl time.Duration, body []byte) {
+
+	if pc == nil || !pc.DownstreamCachingHeaders || h == nil {
+		return
+	}
+	if code != http.StatusNotModified && (code < 200 || code > 299) {
+		return
+	}
+
+	ttl = ttl.Truncate(time.Second)
+	if ttl <= 0 {
+		h.Set(headers.NameCacheControl, headers.ValueNoCache)
+		h.Del(headers.NameExpires)
+	} else {
+		scope := headers.ValuePublic
+		if r != nil && r.Header.Get(headers.NameAuthorization) != "" {
+			scope = headers.ValuePrivate
+		}
+		h.Set(headers.NameCacheControl, scope+", "+headers.ValueMaxAge+"="+
+			strconv.Itoa(int(ttl.Seconds())))
+		h.Set(headers.NameExpires, time.Now().Add(ttl).UTC().Format(http.TimeFormat))
+	}
+
+	if h.Get(headers.NameETag) == "" && len(body) > 0 {
+		h.Set(headers.NameETag, `"`+md5.Checksum(string(body))+`"`)
+	}
+}
+
+// remainingFreshness returns the duration for which an object described by the
+// provided CachingPolicy will remain fresh, capped at max
+func remainingFreshness(cp *CachingPolicy, max time.Duration) time.Duration {
+	if cp == nil || cp.NoCache || cp.FreshnessLifetime <= 0 {
+		return 0
+	}
+	ttl := time.Until(cp.LocalDate.Add(time.Duration(cp.FreshnessLifetime) * time.Second))
+	if max > 0 && ttl > max {
+		ttl = max
+	}
+	return ttl
+}
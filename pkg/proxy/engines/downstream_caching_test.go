@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+func TestSetDownstreamCachingHeaders(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	pc := po.NewOptions()
+
+	// disabled by default
+	h := http.Header{}
+	setDownstreamCachingHeaders(r, h, pc, http.StatusOK, time.Minute, []byte("test"))
+	if len(h) != 0 {
+		t.Errorf("expected empty header, got %v", h)
+	}
+
+	pc.DownstreamCachingHeaders = true
+	setDownstreamCachingHeaders(r, h, pc, http.StatusOK, time.Minute, []byte("test"))
+	if v := h.Get(headers.NameCacheControl); v != "public, max-age=60" {
+		t.Errorf("expected %s got %s", "public, max-age=60", v)
+	}
+	if _, err := http.ParseTime(h.Get(headers.NameExpires)); err != nil {
+		t.Error(err)
+	}
+	if v := h.Get(headers.NameETag); !strings.HasPrefix(v, `"`) || len(v) != 34 {
+		t.Errorf("unexpected etag %s", v)
+	}
+
+	// an upstream-provided etag is preserved
+	h = http.Header{headers.NameETag: []string{`"abc"`}}
+	r.Header.Set(headers.NameAuthorization, "Basic dGVzdDp0ZXN0")
+	setDownstreamCachingHeaders(r, h, pc, http.StatusOK, time.Minute, []byte("test"))
+	if v := h.Get(headers.NameCacheControl); v != "private, max-age=60" {
+		t.Errorf("expected %s got %s", "private, max-age=60", v)
+	}
+	if v := h.Get(headers.NameETag); v != `"abc"` {
+		t.Errorf("expected %s got %s", `"abc"`, v)
+	}
+
+	h = http.Header{headers.NameExpires: []string{"test"}}
+	setDownstreamCachingHeaders(r, h, pc, http.StatusOK, 0, nil)
+	if v := h.Get(headers.NameCacheControl); v != headers.ValueNoCache {
+		t.Errorf("expected %s got %s", headers.ValueNoCache, v)
+	}
+	if v := h.Get(headers.NameExpires); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+
+	// error responses are not decorated
+	h = http.Header{}
+	setDownstreamCachingHeaders(r, h, pc, http.StatusBadGateway, time.Minute, []byte("test"))
+	if len(h) != 0 {
+		t.Errorf("expected empty header, got %v", h)
+	}
+
+}
+
+func TestRemainingFreshness(t *testing.T) {
+
+	if d := remainingFreshness(nil, time.Hour); d != 0 {
+		t.Errorf("expected %d got %d", 0, d)
+	}
+
+	cp := &CachingPolicy{LocalDate: time.Now().Add(-30 * time.Second), FreshnessLifetime: 90}
+	d := remainingFreshness(cp, time.Hour)
+	if d <= 55*time.Second || d > 60*time.Second {
+		t.Errorf("expected ~%s got %s", time.Minute, d)
+	}
+
+	if d = remainingFreshness(cp, 15*time.Second); d != 15*time.Second {
+		t.Errorf("expected %s got %s", 15*time.Second, d)
+	}
+
+	cp.NoCache = true
+	if d = remainingFreshness(cp, time.Hour); d != 0 {
+		t.Errorf("expected %d got %d", 0, d)
+	}
+
+}
+
+func TestObjectProxyCacheDownstreamCachingHeaders(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.DownstreamCachingHeaders = true
+	oc := rsc.OriginConfig
+	oc.MaxTTLSecs = 15
+	oc.MaxTTL = time.Duration(oc.MaxTTLSecs) * time.Second
+
+	w, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameCacheControl); v != "public, max-age=15" {
+		t.Errorf("expected %s got %s", "public, max-age=15", v)
+	}
+
+	w, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := w.Header().Get(headers.NameCacheControl); !strings.HasPrefix(v, "public, max-age=1") {
+		t.Errorf("unexpected cache-control header %s", v)
+	}
+	if v := w.Header().Get(headers.NameETag); v == "" {
+		t.Error("expected non-empty etag")
+	}
+
+}
+
+func TestDeltaProxyCacheDownstreamCachingHeaders(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	rsc.PathConfig.DownstreamCachingHeaders = true
+
+	oc.FastForwardDisable = true
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), end.Add(-time.Hour).Unix(), end.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+
+	expected := fmt.Sprintf("public, max-age=%d", int(oc.TimeseriesTTL.Seconds()))
+	if v := resp.Header.Get(headers.NameCacheControl); v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+
+	// a range ending now should only be fresh until the next step boundary
+	end = time.Now()
+	r.URL.Path = "/prometheus/api/v1/query_range"
+	r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), end.Add(-time.Hour).Unix(), end.Unix(), queryReturnsOKNoLatency)
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+
+	v := resp.Header.Get(headers.NameCacheControl)
+	var maxAge int
+	if _, err := fmt.Sscanf(v, "public, max-age=%d", &maxAge); err != nil {
+		if v != headers.ValueNoCache {
+			t.Errorf("unexpected cache-control header %s", v)
+		}
+	} else if maxAge > int(step.Seconds()) {
+		t.Errorf("expected max-age <= %d got %d", int(step.Seconds()), maxAge)
+	}
+
+}
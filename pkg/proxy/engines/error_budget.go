@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errorbudget"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// observeUpstream records the outcome of an upstream request against the origin's
+// error budget, and logs any resulting change in the origin's state
+func observeUpstream(rsc *request.Resources, statusCode int, latency time.Duration) {
+	oc := rsc.OriginConfig
+	switch oc.Budget.Observe(statusCode, latency) {
+	case errorbudget.TransitionDegraded:
+		rsc.Logger.Warn("origin exceeded error budget, stretching cache ttls",
+			tl.Pairs{"originName": oc.Name})
+	case errorbudget.TransitionRecovered:
+		rsc.Logger.Info("origin recovered, restoring cache ttls",
+			tl.Pairs{"originName": oc.Name})
+	}
+}
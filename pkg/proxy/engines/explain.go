@@ -0,0 +1,218 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	tc "github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Explanation describes how a timeseries request would be served from the cache right now,
+// without fetching anything from the origin or writing anything to the cache
+type Explanation struct {
+	Origin string `json:"origin"`
+	// Decision is the reason the request would be proxied to the origin without caching, if so
+	Decision string `json:"decision,omitempty"`
+	Key      string `json:"key,omitempty"`
+	// CacheStatus is the cache lookup status the request would be served with
+	CacheStatus     string             `json:"cache_status,omitempty"`
+	RequestedExtent *timeseries.Extent `json:"requested_extent,omitempty"`
+	Step            string             `json:"step,omitempty"`
+	// BackfillEnd is the time after which the fetched data would not be cached
+	BackfillEnd *time.Time `json:"backfill_end,omitempty"`
+	// Object describes the cached object, when the key is cached
+	Object        *tc.ObjectStats     `json:"object,omitempty"`
+	CachedExtents []*ExplainedExtent  `json:"cached_extents,omitempty"`
+	FetchExtents  []timeseries.Extent `json:"fetch_extents,omitempty"`
+}
+
+// ExplainedExtent describes a cached extent of a timeseries
+type ExplainedExtent struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// IdleSecs is the time since the extent was last used, which is tracked only by
+	// memory caches of origins that use the 'lru' timeseries eviction method
+	IdleSecs int64 `json:"idle_secs,omitempty"`
+}
+
+// isExplainRequest returns true if the request asks how its timeseries would be served
+// from the cache, rather than for the timeseries
+func isExplainRequest(r *http.Request, oc *oo.Options) bool {
+	return tctx.ExplainFlag(r.Context()) ||
+		(oc.ExplainHeaderEnabled && r.Header.Get(headers.NameTricksterExplain) != "")
+}
+
+// proxyOrExplain proxies the request to the origin without caching it, or when explaining
+// the request, responds with the decision to do so
+func proxyOrExplain(w http.ResponseWriter, r *http.Request, trq *timeseries.TimeRangeQuery,
+	explain bool, decision string) {
+	annotateCanonical(r, tl.Pairs{"decision": decision})
+	if !explain {
+		DoProxy(w, r, true)
+		return
+	}
+	ex := &Explanation{Origin: request.GetResources(r).OriginConfig.Name, Decision: decision}
+	if trq != nil {
+		ex.RequestedExtent = &trq.Extent
+		ex.Step = trq.Step.String()
+	}
+	respondExplanation(w, ex)
+}
+
+// explainTimeseries responds with the cached extents of the timeseries request's cache key,
+// and the extents that would be fetched from the origin to serve the request right now
+func explainTimeseries(w http.ResponseWriter, r *http.Request, pr *proxyRequest,
+	client origins.TimeseriesClient, trq *timeseries.TimeRangeQuery, key string,
+	backfillEnd time.Time) {
+
+	rsc := request.GetResources(r)
+	oc := rsc.OriginConfig
+	cache := rsc.CacheClient
+
+	ex := &Explanation{
+		Origin:          oc.Name,
+		Key:             key,
+		RequestedExtent: &trq.Extent,
+		Step:            trq.Step.String(),
+		BackfillEnd:     &backfillEnd,
+	}
+
+	pr.cacheLock, _ = cache.Locker().RAcquire(key)
+	doc, cacheStatus, _, err := QueryCache(r.Context(), cache, key, nil)
+	pr.cacheLock.RRelease()
+
+	var cts timeseries.Timeseries
+	if cacheStatus == status.LookupStatusKeyMiss && err == tc.ErrKNF {
+		doc = nil
+	} else if doc != nil {
+		if ci, ok := cache.(tc.Inspector); ok {
+			ex.Object = ci.InspectObject(key)
+		}
+		if ex.Object == nil {
+			ex.Object = &tc.ObjectStats{Key: key, Size: int64(doc.Size())}
+		}
+		if rsc.CacheConfig.CacheType == "memory" {
+			cts = doc.timeseries
+		} else if cts, err = client.UnmarshalTimeseries(doc.Body); err != nil {
+			cts = nil
+		}
+	}
+
+	// the cached object is refetched in full when it is missing, unreadable or soft-purged
+	if cts == nil || doc.isSoftPurged() {
+		ex.CacheStatus = status.LookupStatusKeyMiss.String()
+		ex.FetchExtents = []timeseries.Extent{trq.Extent}
+		respondExplanation(w, ex)
+		return
+	}
+
+	el := cts.Extents()
+	ex.CachedExtents = make([]*ExplainedExtent, len(el))
+	now := time.Now()
+	for i, e := range el {
+		ex.CachedExtents[i] = &ExplainedExtent{Start: e.Start, End: e.End}
+		if !e.LastUsed.IsZero() {
+			ex.CachedExtents[i].IdleSecs = int64(now.Sub(e.LastUsed).Seconds())
+		}
+	}
+
+	if oc.TimeseriesEvictionMethod == evictionmethods.EvictionMethodLRU && len(el) > 0 {
+		if tsc := cts.TimestampCount(); tsc > 0 && tsc >= oc.TimeseriesRetentionFactor {
+			if trq.Extent.End.Before(el[0].Start) {
+				ex.Decision = "proxy: timerange end is too early to cache"
+			} else if trq.Extent.Start.After(el[len(el)-1].End) {
+				ex.Decision = "proxy: timerange not cached due to backfill tolerance"
+			}
+			if ex.Decision != "" {
+				respondExplanation(w, ex)
+				return
+			}
+		}
+	}
+
+	ex.FetchExtents = trq.CalculateDeltas(el)
+	switch {
+	case len(ex.FetchExtents) == 0:
+		ex.CacheStatus = status.LookupStatusHit.String()
+	case len(ex.FetchExtents) == 1 && ex.FetchExtents[0].Start.Equal(trq.Extent.Start) &&
+		ex.FetchExtents[0].End.Equal(trq.Extent.End):
+		ex.CacheStatus = status.LookupStatusRangeMiss.String()
+	default:
+		ex.CacheStatus = status.LookupStatusPartialHit.String()
+	}
+	respondExplanation(w, ex)
+}
+
+func respondExplanation(w http.ResponseWriter, ex *Explanation) {
+	b, err := json.Marshal(ex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// ExtentsHandleFunc returns a handler that explains how the timeseries query in its 'uri'
+// parameter, a path and query string as it is requested of the origin named by its 'origin'
+// parameter, would be served from the cache right now. The query is served through the
+// origin's routes with the handler request's headers, but is not fetched from the origin
+func ExtentsHandleFunc(ocs map[string]*oo.Options, clients origins.Origins) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qp := r.URL.Query()
+		name := qp.Get("origin")
+		oc, ok := ocs[name]
+		if !ok || oc.Router == nil {
+			http.Error(w, "unknown origin "+name, http.StatusNotFound)
+			return
+		}
+		client, ok := clients[name].(origins.TimeseriesClient)
+		if !ok {
+			http.Error(w, "origin "+name+" is not a timeseries origin", http.StatusBadRequest)
+			return
+		}
+		uri := qp.Get("uri")
+		er, err := http.NewRequest(http.MethodGet, uri, nil)
+		if uri == "" || err != nil {
+			http.Error(w, "invalid uri "+uri, http.StatusBadRequest)
+			return
+		}
+		// only timeseries queries are explained, so that nothing is proxied to the origin
+		if _, err = client.ParseTimeRangeQuery(er); err != nil {
+			http.Error(w, "not a timeseries query: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		er.Header = r.Header.Clone()
+		er = er.WithContext(tctx.WithExplainFlag(context.Background(), true))
+		oc.Router.ServeHTTP(w, er)
+	}
+}
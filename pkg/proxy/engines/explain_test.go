@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestDeltaProxyCacheRequestExplain(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	rsc.CacheConfig.CacheType = "test"
+
+	client.RangeCacheKey = "test-range-key-explain"
+	client.InstantCacheKey = "test-instant-key-explain"
+
+	oc.FastForwardDisable = true
+	oc.ExplainHeaderEnabled = true
+
+	step := time.Duration(300) * time.Second
+	end := normalizeTime(time.Now().Add(-time.Duration(12)*time.Hour), step)
+	start := end.Add(-time.Duration(6) * time.Hour)
+
+	setQuery := func(start, end time.Time) {
+		r.URL.Path = "/prometheus/api/v1/query_range"
+		r.URL.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s",
+			int(step.Seconds()), start.Unix(), end.Unix(), queryReturnsOKNoLatency,
+			client.RangeCacheKey, client.InstantCacheKey)
+	}
+
+	explain := func() *Explanation {
+		r.Header.Set(headers.NameTricksterExplain, "1")
+		defer r.Header.Del(headers.NameTricksterExplain)
+		w := httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+		}
+		ex := &Explanation{}
+		if err := json.Unmarshal(w.Body.Bytes(), ex); err != nil {
+			t.Fatal(err)
+		}
+		return ex
+	}
+
+	// an uncached timeseries would be fetched in full, and explaining it does not cache it
+	setQuery(start, end)
+	ex := explain()
+	if ex.CacheStatus != "kmiss" || len(ex.FetchExtents) != 1 || ex.Object != nil ||
+		!ex.FetchExtents[0].Start.Equal(start) || !ex.FetchExtents[0].End.Equal(end) {
+		t.Errorf("unexpected explanation %v", ex)
+	}
+	if ex = explain(); ex.CacheStatus != "kmiss" {
+		t.Errorf("expected %s got %s", "kmiss", ex.CacheStatus)
+	}
+
+	client.QueryRangeHandler(w, r)
+	if err = testResultHeaderPartMatch(w.Result().Header,
+		map[string]string{"status": "kmiss"}); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	// a timeseries extended by an hour would fetch only that hour
+	setQuery(start, end.Add(time.Hour))
+	ex = explain()
+	if ex.CacheStatus != "phit" || ex.Key == "" || ex.Object == nil || ex.Object.Size == 0 {
+		t.Errorf("unexpected explanation %v", ex)
+	}
+	if len(ex.CachedExtents) != 1 || !ex.CachedExtents[0].Start.Equal(start) ||
+		!ex.CachedExtents[0].End.Equal(end) {
+		t.Errorf("unexpected cached extents %v", ex.CachedExtents)
+	}
+	expected := timeseries.Extent{Start: end.Add(step), End: end.Add(time.Hour)}
+	if len(ex.FetchExtents) != 1 || !ex.FetchExtents[0].Start.Equal(expected.Start) ||
+		!ex.FetchExtents[0].End.Equal(expected.End) {
+		t.Errorf("expected %s got %v", expected.String(), ex.FetchExtents)
+	}
+
+	setQuery(start, end)
+	if ex = explain(); ex.CacheStatus != "hit" || len(ex.FetchExtents) != 0 {
+		t.Errorf("unexpected explanation %v", ex)
+	}
+
+	// requests that would be proxied report the decision
+	setQuery(time.Unix(0, 0), time.Unix(1800, 0))
+	if ex = explain(); ex.Decision != "proxy: timerange end is too early to cache" {
+		t.Errorf("unexpected decision %s", ex.Decision)
+	}
+
+	// the header is ignored by origins that do not enable it
+	oc.ExplainHeaderEnabled = false
+	setQuery(start, end)
+	r.Header.Set(headers.NameTricksterExplain, "1")
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	if err = testResultHeaderPartMatch(w.Result().Header,
+		map[string]string{"status": "hit"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExtentsHandleFunc(t *testing.T) {
+
+	var explained *http.Request
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+		explained = r
+		w.Write([]byte("ok"))
+	})
+
+	oc := oo.NewOptions()
+	oc.Router = router
+	ocs := map[string]*oo.Options{"test": oc, "other": oo.NewOptions()}
+	clients := origins.Origins{"test": &TestClient{}}
+
+	h := ExtentsHandleFunc(ocs, clients)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://0/trickster/extents?origin=test&uri="+
+		"%2Fapi%2Fv1%2Fquery_range%3Fquery%3Dup%26start%3D1000%26end%3D4600%26step%3D60", nil)
+	r.Header.Set("X-Test", "1")
+	h(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if explained == nil || !tctx.ExplainFlag(explained.Context()) ||
+		explained.Header.Get("X-Test") != "1" {
+		t.Error("expected an explained request with the handler request's headers")
+	}
+
+	tests := []struct {
+		uri  string
+		code int
+	}{
+		{"/trickster/extents?origin=unknown", http.StatusNotFound},
+		{"/trickster/extents?origin=other", http.StatusNotFound},
+		{"/trickster/extents?origin=test", http.StatusBadRequest},
+		{"/trickster/extents?origin=test&uri=%2Fapi%2Fv1%2Fquery_range%3Fstep%3D60", http.StatusBadRequest},
+	}
+	for i, test := range tests {
+		w = httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "http://0"+test.uri, nil))
+		if w.Code != test.code {
+			t.Errorf("test %d expected %d got %d", i, test.code, w.Code)
+		}
+	}
+
+	oc2 := oo.NewOptions()
+	oc2.Router = router
+	ocs["other"] = oc2
+	clients["other"] = nil
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "http://0/trickster/extents?origin=other", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
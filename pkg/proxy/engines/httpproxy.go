@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -28,9 +27,11 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
@@ -52,6 +53,10 @@ const HTTPBlockSize = 32 * 1024
 // DoProxy proxies an inbound request to its corresponding upstream origin with no caching features
 func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 
+	if rw, ok := w.(http.ResponseWriter); ok && IsWebSocketUpgrade(r) {
+		return WebSocketProxy(rw, r)
+	}
+
 	rsc := request.GetResources(r)
 	oc := rsc.OriginConfig
 
@@ -87,7 +92,7 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 			cacheStatusCode = setStatusHeader(resp.StatusCode, resp.Header)
 			writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
 			// Check if we know the content length and if it is less than our max object size.
-			if contentLength != 0 && contentLength < int64(oc.MaxObjectSizeBytes) {
+			if contentLength != 0 && contentLength < int64(maxObjectSize(rsc)) {
 				pcf := NewPCF(resp, contentLength)
 				reqs.Store(key, pcf)
 				// Blocks until server completes
@@ -151,13 +156,29 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 
 	var rc io.ReadCloser
 
+	// the Upgrade and Connection headers are hop-by-hop, but are retained for WebSockets
+	// so the origin can complete the opening handshake
+	isWebSocket := IsWebSocketUpgrade(r)
+	upgrade := r.Header.Get(headers.NameUpgrade)
+
+	// remove any client headers the origin is not configured to receive
+	// before Trickster adds its own forwarding and path-configured headers
+	oc.UpstreamHeaderFilter.Apply(r.Header)
 	headers.AddForwardingHeaders(r, oc.ForwardedHeaders)
+	if isWebSocket {
+		r.Header.Set(headers.NameConnection, "Upgrade")
+		r.Header.Set(headers.NameUpgrade, upgrade)
+	}
 
 	if pc != nil {
 		headers.UpdateHeaders(r.Header, pc.RequestHeaders)
-		qp, _, _ := params.GetRequestValues(r)
-		params.UpdateParams(qp, pc.RequestParams)
-		params.SetRequestValues(r, qp)
+		// the body of a POST is only rewritten when there are params to update, since
+		// bodies that aren't forms (e.g., remote_read protobufs) don't survive the rewrite
+		if len(pc.RequestParams) > 0 {
+			qp, _, _ := params.GetRequestValues(r)
+			params.UpdateParams(qp, pc.RequestParams)
+			params.SetRequestValues(r, qp)
+		}
 	}
 
 	r.Close = false
@@ -178,13 +199,31 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	// clear the Host header before proxying or it will be forwarded upstream
 	r.Host = ""
 
-	resp, err := oc.HTTPClient.Do(r)
+	var resp *http.Response
+	var err error
+	start := time.Now()
+	if isWebSocket && oc.HTTPClient.Transport != nil {
+		// WebSocket connections are long-lived, so the client timeout doesn't apply, and the
+		// Transport is used directly so the upgraded connection is returned as the body
+		resp, err = oc.HTTPClient.Transport.RoundTrip(r)
+	} else {
+		resp, err = oc.HTTPClient.Do(r)
+	}
+	if !isWebSocket {
+		// the latency observed is the time to the first response byte
+		if err != nil {
+			observeUpstream(rsc, 0, time.Since(start))
+		} else {
+			observeUpstream(rsc, resp.StatusCode, time.Since(start))
+		}
+	}
 	if err != nil {
 		rsc.Logger.Error("error downloading url", log.Pairs{"url": r.URL.String(), "detail": err.Error()})
 		// if there is an err and the response is nil, the server could not be reached
 		// so make a 502 for the downstream response
 		if resp == nil {
-			resp = &http.Response{StatusCode: http.StatusBadGateway, Request: r, Header: make(http.Header)}
+			resp = &http.Response{StatusCode: http.StatusBadGateway,
+				Request: r.WithContext(tc.WithUpstreamError(r.Context(), err)), Header: make(http.Header)}
 		}
 
 		if pc != nil {
@@ -212,22 +251,7 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		resp.ContentLength = originalLen
 	}
 
-	// warn if the clock between trickster and the origin is off by more than 1 minute
-	if date := resp.Header.Get(headers.NameDate); date != "" {
-		d, err := http.ParseTime(date)
-		if err == nil {
-			if offset := time.Since(d); time.Duration(math.Abs(float64(offset))) > time.Minute {
-				rsc.Logger.WarnOnce("clockoffset."+oc.Name,
-					"clock offset between trickster host and origin is high and may cause data anomalies",
-					log.Pairs{
-						"originName":    oc.Name,
-						"tricksterTime": strconv.FormatInt(d.Add(offset).Unix(), 10),
-						"originTime":    strconv.FormatInt(d.Unix(), 10),
-						"offset":        strconv.FormatInt(int64(offset.Seconds()), 10) + "s",
-					})
-			}
-		}
-	}
+	observeClockSkew(rsc, resp.Header)
 
 	hasCustomResponseBody := false
 	resp.Header.Del(headers.NameContentLength)
@@ -241,13 +265,28 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		// Since we are not responding with the actual upstream response body, close it here
 		resp.Body.Close()
 		rc = ioutil.NopCloser(bytes.NewReader(pc.ResponseBodyBytes))
-	} else {
+	} else if isWebSocket {
+		// the body of an upgraded response is the connection, and must remain writable
 		rc = resp.Body
+	} else {
+		rc = &fetchedBodyCounter{ReadCloser: resp.Body, oc: oc}
 	}
 
 	return rc, resp, originalLen
 }
 
+// fetchedBodyCounter counts the bytes read from an origin's response body
+type fetchedBodyCounter struct {
+	io.ReadCloser
+	oc *oo.Options
+}
+
+func (c *fetchedBodyCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	metrics.ObserveOriginFetchedBytes(c.oc.Name, c.oc.OriginType, float64(n))
+	return n, err
+}
+
 // Respond sends an HTTP Response down to the requesting client
 func Respond(w io.Writer, code int, header http.Header, body []byte) {
 	PrepareResponseWriter(w, code, header)
@@ -280,5 +319,11 @@ func recordResults(r *http.Request, engine string, cacheStatus status.LookupStat
 				r.Method, status, httpStatus, path).Observe(elapsed)
 		}
 	}
+	if oc != nil {
+		d := time.Duration(elapsed * float64(time.Second))
+		oc.SLOTracker.Observe(cacheStatus, d)
+		oc.HeatmapRecorder.Observe(cacheStatus, d)
+	}
 	headers.SetResultsHeader(header, engine, status, ffStatus, extents)
+	logCanonical(r, rsc, engine, cacheStatus, statusCode, ffStatus, elapsed, extents)
 }
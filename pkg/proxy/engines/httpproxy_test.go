@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/tricksterproxy/trickster/pkg/config"
 	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
@@ -31,6 +32,7 @@ import (
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
 
@@ -303,3 +305,83 @@ func TestPrepareFetchReaderErr(t *testing.T) {
 		t.Errorf("expected 0 got %d", i)
 	}
 }
+
+func TestPrepareFetchReaderBinaryBody(t *testing.T) {
+
+	var body []byte
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(200)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url",
+		s.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+	pc := &po.Options{
+		Path:            "/",
+		RequestHeaders:  map[string]string{},
+		ResponseHeaders: map[string]string{},
+	}
+
+	// a POST body that isn't a form is forwarded as-is when the path has no params to update
+	r := httptest.NewRequest(http.MethodPost, s.URL, bytes.NewReader([]byte{0x0a, 0x00, 0xff}))
+	r.Header.Set(headers.NameContentType, "application/x-protobuf")
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+	rc, _, _ := PrepareFetchReader(r)
+	if rc != nil {
+		rc.Close()
+	}
+	if !bytes.Equal(body, []byte{0x0a, 0x00, 0xff}) {
+		t.Errorf("expected %v got %v", []byte{0x0a, 0x00, 0xff}, body)
+	}
+}
+
+func TestDoProxyFetchedBytes(t *testing.T) {
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte("trickster"))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url",
+		s.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oc := conf.Origins["default"]
+	oc.Name = "fetched-bytes-test"
+	pc := &po.Options{
+		Path:            "/",
+		RequestHeaders:  map[string]string{},
+		ResponseHeaders: map[string]string{},
+	}
+
+	oc.HTTPClient = http.DefaultClient
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", s.URL, nil)
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+	DoProxy(w, r, true)
+	if w.Body.String() != "trickster" {
+		t.Errorf("expected %s got %s", "trickster", w.Body.String())
+	}
+
+	var m dto.Metric
+	metrics.ProxyOriginFetchedBytes.WithLabelValues(oc.Name, oc.OriginType).Write(&m)
+	if v := m.GetCounter().GetValue(); v != 9 {
+		t.Errorf("expected %d got %f", 9, v)
+	}
+
+}
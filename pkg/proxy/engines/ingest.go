@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// IngestMergeFunc returns an ingest.MergeFunc that merges the pre-computed timeseries
+// consumed from the origin's ingestion source into its cached objects
+func IngestMergeFunc(oc *oo.Options, c cache.Cache, client origins.TimeseriesClient,
+	logger *tl.Logger) ingest.MergeFunc {
+	rsc := request.NewResources(oc, nil, c.Configuration(), c, client, nil, logger)
+	ctx := tctx.WithResources(context.Background(), rsc)
+	return func(key string, m *ingest.Message) error {
+		return mergeIngested(ctx, rsc, key, m)
+	}
+}
+
+// mergeIngested merges the timeseries in the ingested message into the object cached at key.
+// The message's extents replace those derived from the payload, since the payload of a sparse
+// timeseries does not otherwise indicate the time range that it fully covers
+func mergeIngested(ctx context.Context, rsc *request.Resources, key string,
+	m *ingest.Message) error {
+
+	client := rsc.OriginClient.(origins.TimeseriesClient)
+
+	rts, err := client.UnmarshalTimeseries(m.Payload)
+	if err != nil {
+		return err
+	}
+	if m.StepMS > 0 {
+		rts.SetStep(m.Step())
+	}
+	if rts.Step() <= 0 {
+		return errors.New("ingested timeseries has no step")
+	}
+	rts.SetExtents(m.Extents.Compress(rts.Step()))
+
+	rd := &HTTPDocument{
+		StatusCode:  http.StatusOK,
+		Status:      "200 " + http.StatusText(http.StatusOK),
+		ContentType: m.ContentType,
+		Headers:     http.Header{headers.NameContentType: []string{m.ContentType}},
+		Body:        m.Payload,
+	}
+	return mergeTimeseries(ctx, rsc, key, rd, rts, m.TTL())
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+	rpo "github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestIngestMergeFunc(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	rsc.CacheConfig.CacheType = "test"
+	oc.FastForwardDisable = true
+
+	// the replicator is used to capture the key and document of a cached query
+	oc.Replication = &rpo.Options{Peers: []string{"http://127.0.0.1"}, IntervalMS: 1,
+		TimeoutMS: 1000, MaxPending: 10}
+	oc.Replication.SetDurations()
+	oc.Replicator = replication.New(oc.Name, oc.OriginType, oc.CacheKeyPrefix,
+		"/trickster/replication/"+oc.Name, oc.Replication)
+
+	step := time.Duration(300) * time.Second
+	now := time.Now().Truncate(step)
+
+	query := func(start, end time.Time) {
+		w := httptest.NewRecorder()
+		u := r.URL
+		u.Path = "/prometheus/api/v1/query_range"
+		u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s", int(step.Seconds()),
+			start.Unix(), end.Unix(), queryReturnsOKNoLatency)
+		client.QueryRangeHandler(w, r)
+		ioutil.ReadAll(w.Result().Body)
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	query(now.Add(-6*time.Hour), now.Add(-5*time.Hour))
+	var key string
+	var cached []byte
+	oc.Replicator.Push(func(k string) []byte {
+		key = k
+		doc, _, _, _ := QueryCache(r.Context(), rsc.CacheClient, k, nil)
+		if doc != nil {
+			cached = doc.Body
+		}
+		return nil
+	})
+	if len(cached) == 0 {
+		t.Fatal("expected cached document")
+	}
+
+	// the cached object is replaced with a different extent of the same query, and the
+	// previous extent is ingested into it as a pre-computed timeseries
+	rsc.CacheClient.Remove(key)
+	query(now.Add(-2*time.Hour), now.Add(-1*time.Hour))
+
+	m := &ingest.Message{
+		TTLMS:       int64(time.Hour / time.Millisecond),
+		StepMS:      int64(step / time.Millisecond),
+		Extents:     timeseries.ExtentList{{Start: now.Add(-6 * time.Hour), End: now.Add(-5 * time.Hour)}},
+		ContentType: "application/json",
+		Payload:     cached,
+	}
+	merge := IngestMergeFunc(oc, rsc.CacheClient, client, rsc.Logger)
+	if err := merge(key, m); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cts, err := client.UnmarshalTimeseries(doc.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := timeseries.ExtentList{
+		{Start: now.Add(-6 * time.Hour), End: now.Add(-5 * time.Hour)},
+		{Start: now.Add(-2 * time.Hour), End: now.Add(-1 * time.Hour)},
+	}
+	if s := timeseries.ExtentList(cts.Extents()).String(); s != expected.String() {
+		t.Errorf("expected %s got %s", expected.String(), s)
+	}
+
+	// a timeseries of a key that is not cached is stored as-is
+	if err := merge(key+".new", m); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, _, err = QueryCache(r.Context(), rsc.CacheClient, key+".new", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.StatusCode != 200 || doc.ContentType != "application/json" {
+		t.Errorf("unexpected document status %d content type %s", doc.StatusCode, doc.ContentType)
+	}
+
+	// invalid payloads are rejected
+	m.Payload = []byte("invalid")
+	if err := merge(key, m); err == nil {
+		t.Error("expected error for invalid payload")
+	}
+
+}
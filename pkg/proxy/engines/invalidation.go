@@ -0,0 +1,400 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// InvalidationFunc returns an invalidation.InvalidateFunc that removes, or marks stale, the
+// origin's cached objects described by invalidation requests
+func InvalidationFunc(oc *oo.Options, c cache.Cache, client origins.Client,
+	logger *tl.Logger) invalidation.InvalidateFunc {
+	rsc := request.NewResources(oc, nil, c.Configuration(), c, client, nil, logger)
+	ctx := tctx.WithResources(context.Background(), rsc)
+	return func(r *invalidation.Request) (int, error) {
+		return invalidate(ctx, rsc, r)
+	}
+}
+
+// invalidate removes the cached objects selected by the request, or marks them stale when the
+// request is soft, and returns the number of objects that were invalidated
+func invalidate(ctx context.Context, rsc *request.Resources, r *invalidation.Request) (int, error) {
+
+	c := rsc.CacheClient
+	oc := rsc.OriginConfig
+
+	keys, err := invalidationKeys(rsc, r)
+	if err != nil {
+		return 0, err
+	}
+
+	var tc origins.TimeseriesClient
+	if r.Filtered() {
+		var ok bool
+		if tc, ok = rsc.OriginClient.(origins.TimeseriesClient); !ok {
+			return 0, fmt.Errorf("origin type %s does not cache timeseries", oc.OriginType)
+		}
+	}
+
+	var n int
+	for _, key := range keys {
+		if tc != nil && !timeseriesMatches(ctx, rsc, tc, key, r) {
+			continue
+		}
+		lock, _ := c.Locker().Acquire(key)
+		switch {
+		case r.Soft:
+			if !markStale(ctx, rsc, key) {
+				lock.Release()
+				continue
+			}
+		case r.Partial:
+			if !removeTimeRange(ctx, rsc, tc, key, r) {
+				lock.Release()
+				continue
+			}
+		default:
+			c.Remove(key)
+		}
+		lock.Release()
+		n++
+	}
+	rsc.Logger.Info("cache objects invalidated",
+		tl.Pairs{"originName": oc.Name, "count": n, "mode": r.Mode()})
+	return n, nil
+}
+
+// markStale rewrites the object cached at key with a caching policy marking it stale, so
+// that it is revalidated on its next access, and returns false if no object could be marked.
+// The caller must hold the write lock for the key
+func markStale(ctx context.Context, rsc *request.Resources, key string) bool {
+
+	oc := rsc.OriginConfig
+
+	doc, lookupStatus, _, err := QueryCache(ctx, rsc.CacheClient, key, nil)
+	if err != nil || doc == nil || lookupStatus == status.LookupStatusKeyMiss {
+		return false
+	}
+
+	// the memory cache returns the stored reference, which may be in use by concurrent
+	// requests, so the marked object is a copy
+	var cp *CachingPolicy
+	if doc.CachingPolicy != nil {
+		cp = doc.CachingPolicy.Clone()
+	} else {
+		cp = &CachingPolicy{}
+	}
+	cp.IsFresh = false
+	cp.IsStale = true
+
+	d := &HTTPDocument{
+		StatusCode:       doc.StatusCode,
+		Status:           doc.Status,
+		Headers:          doc.SafeHeaderClone(),
+		Body:             doc.Body,
+		ContentLength:    doc.ContentLength,
+		ContentType:      doc.ContentType,
+		CachingPolicy:    cp,
+		Ranges:           doc.Ranges,
+		StoredRangeParts: doc.StoredRangeParts,
+		timeseries:       doc.timeseries,
+	}
+
+	// the object is retained for as long as it would be when written by its proxy engine
+	var ttl time.Duration
+	isTimeseries := strings.HasPrefix(key, oc.CacheKeyPrefix+".dpc.")
+	if isTimeseries {
+		ttl = oc.Budget.Stretch(oc.TimeseriesTTL)
+	} else {
+		ttl = oc.Budget.Retention(cp.TTL(oc.RevalidationFactor, oc.MaxTTL))
+	}
+	if err := WriteCache(ctx, rsc.CacheClient, key, d, ttl, oc.CompressableTypes); err != nil {
+		rsc.Logger.Error("error writing object to cache",
+			tl.Pairs{"originName": oc.Name, "cacheKey": key, "detail": err.Error()})
+		return false
+	}
+	if isTimeseries {
+		oc.RetentionTrimmer.Track(key, ttl)
+	}
+	return true
+}
+
+// removeTimeRange removes the data within the request's time range from the timeseries
+// cached at key, and returns false if no cached data was removed. A timeseries left without
+// data is removed. The caller must hold the write lock for the key
+func removeTimeRange(ctx context.Context, rsc *request.Resources,
+	client origins.TimeseriesClient, key string, r *invalidation.Request) bool {
+
+	c := rsc.CacheClient
+	oc := rsc.OriginConfig
+
+	doc, lookupStatus, _, err := QueryCache(ctx, c, key, nil)
+	if err != nil || doc == nil || lookupStatus == status.LookupStatusKeyMiss {
+		return false
+	}
+
+	var cts timeseries.Timeseries
+	if rsc.CacheConfig.CacheType == "memory" {
+		if doc.timeseries == nil {
+			return false
+		}
+		cts = doc.timeseries
+	} else {
+		if cts, err = client.UnmarshalTimeseries(doc.Body); err != nil {
+			return false
+		}
+	}
+
+	kept, ok := excludeRange(cts, r.Start(), r.End())
+	if !ok {
+		return false
+	}
+	if kept == nil || len(kept.Extents()) == 0 {
+		c.Remove(key)
+		return true
+	}
+
+	// the memory cache returns the stored reference, which may be in use by concurrent
+	// requests, so the rewritten object is a copy
+	d := &HTTPDocument{
+		StatusCode:    doc.StatusCode,
+		Status:        doc.Status,
+		Headers:       doc.SafeHeaderClone(),
+		ContentType:   doc.ContentType,
+		CachingPolicy: doc.CachingPolicy,
+	}
+	if rsc.CacheConfig.CacheType == "memory" {
+		d.timeseries = kept
+	} else if d.Body, err = client.MarshalTimeseries(kept); err != nil {
+		return false
+	}
+
+	ttl := oc.Budget.Stretch(oc.TimeseriesTTL)
+	if err := WriteCache(ctx, c, key, d, ttl, oc.CompressableTypes); err != nil {
+		rsc.Logger.Error("error writing object to cache",
+			tl.Pairs{"originName": oc.Name, "cacheKey": key, "detail": err.Error()})
+		return false
+	}
+	oc.RetentionTrimmer.Track(key, ttl)
+	return true
+}
+
+// excludeRange returns a copy of the timeseries without the data in the provided range, and
+// false if the range contains no data to exclude. A zero start or end leaves the range open on
+// that side. The returned timeseries is nil when no data remains
+func excludeRange(cts timeseries.Timeseries, start, end time.Time) (timeseries.Timeseries, bool) {
+
+	el := cts.Extents()
+	if len(el) == 0 {
+		return nil, false
+	}
+	first, last := el[0].Start, el[len(el)-1].End
+
+	// the removed range is widened to the step boundaries within it, and the timeseries is
+	// rebuilt from copies cropped to the data before and after it. When the step was not
+	// cached with the timeseries, the range is removed exactly
+	step := cts.Step()
+	if step <= 0 {
+		step = time.Nanosecond
+	}
+	if start.IsZero() || start.Before(first) {
+		start = first
+	}
+	if end.IsZero() || end.After(last) {
+		end = last
+	}
+	before := time.Unix(0, start.UnixNano()/int64(step)*int64(step))
+	if !before.Before(start) {
+		before = before.Add(-step)
+	}
+	after := time.Unix(0, end.UnixNano()/int64(step)*int64(step)).Add(step)
+	if !after.After(before.Add(step)) {
+		// there are no step boundaries within the range
+		return nil, false
+	}
+
+	var kept timeseries.Timeseries
+	if !before.Before(first) {
+		kept = cts.Clone()
+		kept.CropToRange(timeseries.Extent{Start: first, End: before})
+	}
+	if !after.After(last) {
+		ts := cts.Clone()
+		ts.CropToRange(timeseries.Extent{Start: after, End: last})
+		if kept == nil {
+			kept = ts
+		} else {
+			kept.Merge(true, ts)
+		}
+	}
+	return kept, true
+}
+
+// invalidationKeys returns the cache keys of the objects selected by the request's keys,
+// prefixes or tags, or all of the origin's objects when none are provided
+func invalidationKeys(rsc *request.Resources, r *invalidation.Request) ([]string, error) {
+
+	c := rsc.CacheClient
+	oc := rsc.OriginConfig
+
+	if len(r.Keys) > 0 {
+		keys := make([]string, len(r.Keys))
+		for i, k := range r.Keys {
+			keys[i] = oc.CacheKeyPrefix + k
+		}
+		return keys, nil
+	}
+
+	if len(r.Tags) > 0 {
+		tg, ok := c.(cache.Tagger)
+		if !ok {
+			return nil, fmt.Errorf("cache type %s can't invalidate objects by tag",
+				rsc.CacheConfig.CacheType)
+		}
+		// tags are shared by all origins using the cache, so only the origin's keys are selected
+		prefix := oc.CacheKeyPrefix + "."
+		var keys []string
+		seen := make(map[string]bool)
+		for _, t := range r.Tags {
+			k, err := tg.TaggedKeys(t)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range k {
+				if !seen[key] && strings.HasPrefix(key, prefix) {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		return keys, nil
+	}
+
+	l, ok := c.(cache.Lister)
+	if !ok {
+		return nil, fmt.Errorf("cache type %s can only invalidate objects by key",
+			rsc.CacheConfig.CacheType)
+	}
+	prefixes := r.Prefixes
+	if len(prefixes) == 0 {
+		// each of the origin's cache keys is the origin's prefix followed by a '.'
+		prefixes = []string{"."}
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	for _, p := range prefixes {
+		k, err := l.Keys(oc.CacheKeyPrefix + p)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range k {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// timeseriesMatches returns true if the object cached at key is a timeseries having a
+// series with each of the request's labels, and cached data within its time range
+func timeseriesMatches(ctx context.Context, rsc *request.Resources,
+	client origins.TimeseriesClient, key string, r *invalidation.Request) bool {
+
+	if !strings.HasPrefix(key, rsc.OriginConfig.CacheKeyPrefix+".dpc.") {
+		return false
+	}
+
+	ts, err := readTimeseries(ctx, rsc, client, key)
+	if err != nil {
+		return false
+	}
+
+	start, end := r.Start(), r.End()
+	if !start.IsZero() || !end.IsZero() {
+		var overlaps bool
+		for _, e := range ts.Extents() {
+			if (start.IsZero() || !e.End.Before(start)) && (end.IsZero() || !e.Start.After(end)) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			return false
+		}
+	}
+
+	if len(r.Labels) == 0 {
+		return true
+	}
+	lt, ok := ts.(timeseries.Labeler)
+	if !ok {
+		return false
+	}
+	for _, sl := range lt.SeriesLabels() {
+		if labelsMatch(sl, r.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// readTimeseries returns the timeseries cached at key
+func readTimeseries(ctx context.Context, rsc *request.Resources,
+	client origins.TimeseriesClient, key string) (timeseries.Timeseries, error) {
+
+	lock, _ := rsc.CacheClient.Locker().RAcquire(key)
+	defer lock.RRelease()
+
+	doc, _, _, err := QueryCache(ctx, rsc.CacheClient, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rsc.CacheConfig.CacheType == "memory" {
+		if doc.timeseries == nil {
+			return nil, errors.New("cached object is not a timeseries")
+		}
+		return doc.timeseries, nil
+	}
+	return client.UnmarshalTimeseries(doc.Body)
+}
+
+// labelsMatch returns true if the series labels include each of the selector's labels
+func labelsMatch(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
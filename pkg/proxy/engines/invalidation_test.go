@@ -0,0 +1,320 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestInvalidationFunc(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	c := rsc.CacheClient
+	now := time.Unix(1577836800, 0)
+
+	// store writes a timeseries of a single series with the provided job label, cached
+	// for the hour ending at end
+	store := func(key, job string, end time.Time) {
+		me := &MatrixEnvelope{
+			Status: "success",
+			Data: MatrixData{
+				ResultType: "matrix",
+				Result: model.Matrix{&model.SampleStream{
+					Metric: model.Metric{"__name__": "up", "job": model.LabelValue(job)},
+					Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(end.Unix()), Value: 1}},
+				}},
+			},
+			ExtentList: timeseries.ExtentList{{Start: end.Add(-time.Hour), End: end}},
+		}
+		d := &HTTPDocument{StatusCode: http.StatusOK}
+		if rsc.CacheConfig.CacheType == "memory" {
+			d.timeseries = me
+		} else {
+			d.Body, _ = client.MarshalTimeseries(me)
+		}
+		if err := WriteCache(r.Context(), c, oc.CacheKeyPrefix+key, d, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(key string) bool {
+		_, _, _, err := QueryCache(r.Context(), c, oc.CacheKeyPrefix+key, nil)
+		return err == nil
+	}
+	seed := func() {
+		store(".dpc.a", "node", now)
+		store(".dpc.b", "api", now)
+		store(".dpc.c", "node", now.Add(-24*time.Hour))
+		if err := WriteCache(r.Context(), c, oc.CacheKeyPrefix+".opc.a",
+			&HTTPDocument{StatusCode: http.StatusOK, Body: []byte("object")}, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		cacheType string
+		req       *invalidation.Request
+		expected  []string
+	}{
+		{"memory", &invalidation.Request{Keys: []string{".dpc.a", ".opc.a"}}, []string{".dpc.a", ".opc.a"}},
+		{"memory", &invalidation.Request{Prefixes: []string{".dpc."}}, []string{".dpc.a", ".dpc.b", ".dpc.c"}},
+		{"memory", &invalidation.Request{}, []string{".dpc.a", ".dpc.b", ".dpc.c", ".opc.a"}},
+		{"memory", &invalidation.Request{Labels: map[string]string{"job": "node"}}, []string{".dpc.a", ".dpc.c"}},
+		{"memory", &invalidation.Request{Labels: map[string]string{"job": "node"},
+			StartMS: now.Add(-2*time.Hour).Unix() * 1000}, []string{".dpc.a"}},
+		{"memory", &invalidation.Request{EndMS: now.Add(-12*time.Hour).Unix() * 1000}, []string{".dpc.c"}},
+		{"memory", &invalidation.Request{Labels: map[string]string{"job": "db"}}, nil},
+		{"test", &invalidation.Request{Labels: map[string]string{"__name__": "up", "job": "api"}},
+			[]string{".dpc.b"}},
+	}
+
+	for i, test := range tests {
+		rsc.CacheConfig.CacheType = test.cacheType
+		seed()
+		n, err := InvalidationFunc(oc, c, client, rsc.Logger)(test.req)
+		if err != nil {
+			t.Fatalf("test %d: %s", i, err)
+		}
+		if n != len(test.expected) {
+			t.Errorf("test %d: expected %d got %d", i, len(test.expected), n)
+		}
+		removed := make(map[string]bool)
+		for _, k := range test.expected {
+			removed[k] = true
+		}
+		for _, k := range []string{".dpc.a", ".dpc.b", ".dpc.c", ".opc.a"} {
+			if exists(k) == removed[k] {
+				t.Errorf("test %d: expected key %s removed to be %t", i, k, removed[k])
+			}
+		}
+	}
+
+	// soft invalidation marks existing objects stale rather than removing them
+	for _, cacheType := range []string{"memory", "test"} {
+		rsc.CacheConfig.CacheType = cacheType
+		seed()
+		c.Remove(oc.CacheKeyPrefix + ".dpc.missing")
+		n, err := InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+			Keys: []string{".dpc.a", ".opc.a", ".dpc.missing"}, Soft: true})
+		if err != nil || n != 2 {
+			t.Errorf("%s: expected %d got %d: %v", cacheType, 2, n, err)
+			for _, k := range []string{".dpc.a", ".dpc.b"} {
+				cts, err := readTimeseries(r.Context(), rsc, client, oc.CacheKeyPrefix+k)
+				t.Log(k, cts, err)
+				if cts != nil {
+					t.Log(cts.Extents(), cts.Step())
+				}
+			}
+		}
+		for _, k := range []string{".dpc.a", ".dpc.b", ".opc.a"} {
+			d, _, _, err := QueryCache(r.Context(), c, oc.CacheKeyPrefix+k, nil)
+			if err != nil {
+				t.Errorf("%s: expected key %s to exist: %v", cacheType, k, err)
+				continue
+			}
+			if d.isSoftPurged() != (k != ".dpc.b") {
+				t.Errorf("%s: unexpected soft purge mark for %s", cacheType, k)
+			}
+		}
+		if exists(".dpc.missing") {
+			t.Errorf("%s: expected key %s to not exist", cacheType, ".dpc.missing")
+		}
+	}
+
+	// tags select the origin's objects associated with any of them, and are kept when the
+	// objects are marked stale
+	rsc.CacheConfig.CacheType = "memory"
+	seed()
+	tg := c.(cache.Tagger)
+	tg.SetTags(oc.CacheKeyPrefix+".dpc.a", []string{"tenant-a"})
+	tg.SetTags(oc.CacheKeyPrefix+".opc.a", []string{"tenant-a", "tenant-b"})
+	tg.SetTags(oc.CacheKeyPrefix+".dpc.b", []string{"tenant-b"})
+	if err := WriteCache(r.Context(), c, "other.opc.a",
+		&HTTPDocument{StatusCode: http.StatusOK, Body: []byte("object")}, time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	tg.SetTags("other.opc.a", []string{"tenant-a"})
+	n, err := InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+		Tags: []string{"tenant-a"}, Soft: true})
+	if err != nil || n != 2 {
+		t.Errorf("expected %d got %d: %v", 2, n, err)
+	}
+	n, err = InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+		Tags: []string{"tenant-a", "tenant-b"}})
+	if err != nil || n != 3 {
+		t.Errorf("expected %d got %d: %v", 3, n, err)
+	}
+	for _, k := range []string{".dpc.a", ".dpc.b", ".opc.a"} {
+		if exists(k) {
+			t.Errorf("expected key %s removed", k)
+		}
+	}
+	if !exists(".dpc.c") {
+		t.Errorf("expected key %s to exist", ".dpc.c")
+	}
+	if _, _, _, err := QueryCache(r.Context(), c, "other.opc.a", nil); err != nil {
+		t.Errorf("expected key %s to exist: %v", "other.opc.a", err)
+	}
+
+	// caches that can't list their keys can only invalidate by key
+	tc := &testCache{configuration: &co.Options{CacheType: "test"}, locker: locks.NewNamedLocker()}
+	f := InvalidationFunc(oc, tc, client, rsc.Logger)
+	if n, err := f(&invalidation.Request{Keys: []string{".dpc.a"}}); err != nil || n != 1 {
+		t.Errorf("expected %d got %d: %v", 1, n, err)
+	}
+	if _, err := f(&invalidation.Request{Prefixes: []string{".dpc."}}); err == nil {
+		t.Error("expected error for cache without key listing")
+	}
+	if _, err := f(&invalidation.Request{Tags: []string{"tenant-a"}}); err == nil {
+		t.Error("expected error for cache without tagging")
+	}
+
+	// timeseries filters require a timeseries origin
+	rsc2 := request.NewResources(oc, nil, c.Configuration(), c, nil, nil, rsc.Logger)
+	if _, err := invalidate(r.Context(), rsc2,
+		&invalidation.Request{Labels: map[string]string{"job": "node"}}); err == nil {
+		t.Error("expected error for non-timeseries origin")
+	}
+}
+
+func TestInvalidationFuncPartial(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	c := rsc.CacheClient
+	now := time.Unix(1577836800, 0)
+	step := time.Minute
+
+	// seed caches a timeseries with a value at each step of the hour ending at now
+	seed := func(key string) {
+		values := make([]model.SamplePair, 0, 61)
+		for ts := now.Add(-time.Hour); !ts.After(now); ts = ts.Add(step) {
+			values = append(values, model.SamplePair{Timestamp: model.TimeFromUnix(ts.Unix()), Value: 1})
+		}
+		me := &MatrixEnvelope{
+			Status: "success",
+			Data: MatrixData{
+				ResultType: "matrix",
+				Result: model.Matrix{&model.SampleStream{
+					Metric: model.Metric{"__name__": "up", "job": "node"},
+					Values: values,
+				}},
+			},
+			ExtentList:   timeseries.ExtentList{{Start: now.Add(-time.Hour), End: now}},
+			StepDuration: step,
+		}
+		d := &HTTPDocument{StatusCode: http.StatusOK}
+		if rsc.CacheConfig.CacheType == "memory" {
+			d.timeseries = me
+		} else {
+			d.Body, _ = client.MarshalTimeseries(me)
+		}
+		if err := WriteCache(r.Context(), c, oc.CacheKeyPrefix+key, d, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, cacheType := range []string{"memory", "test"} {
+		rsc.CacheConfig.CacheType = cacheType
+		// the memory cache removes keys from its index asynchronously, so each cache type
+		// uses its own keys
+		prefix := ".dpc." + cacheType + "."
+		a, b := prefix+"a", prefix+"b"
+		seed(a)
+		seed(b)
+
+		// the range is widened to the step boundaries within it
+		n, err := InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+			Keys:    []string{a},
+			StartMS: now.Add(-30*time.Minute+10*time.Second).Unix() * 1000,
+			EndMS:   now.Add(-20*time.Minute).Unix() * 1000,
+			Partial: true,
+		})
+		if err != nil || n != 1 {
+			t.Errorf("%s: expected %d got %d: %v", cacheType, 1, n, err)
+		}
+		cts, err := readTimeseries(r.Context(), rsc, client, oc.CacheKeyPrefix+a)
+		if err != nil {
+			t.Fatalf("%s: %v", cacheType, err)
+		}
+		expected := timeseries.ExtentList{
+			{Start: now.Add(-time.Hour), End: now.Add(-30 * time.Minute)},
+			{Start: now.Add(-19 * time.Minute), End: now},
+		}
+		if el := cts.Extents(); el.String() != expected.String() {
+			t.Errorf("%s: expected %s got %s", cacheType, expected, el)
+		}
+		if v := cts.ValueCount(); v != 51 {
+			t.Errorf("%s: expected %d got %d", cacheType, 51, v)
+		}
+
+		// a timeseries left without data is removed, and one without data in the range
+		// is not invalidated
+		n, err = InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+			Prefixes: []string{prefix},
+			StartMS:  now.Add(-2*time.Hour).Unix() * 1000,
+			EndMS:    now.Add(-45*time.Minute).Unix() * 1000,
+			Partial:  true,
+		})
+		if err != nil || n != 2 {
+			t.Errorf("%s: expected %d got %d: %v", cacheType, 2, n, err)
+		}
+		n, err = InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+			Keys:    []string{b},
+			StartMS: now.Add(-time.Hour).Unix() * 1000,
+			Partial: true,
+		})
+		if err != nil || n != 1 {
+			t.Errorf("%s: expected %d got %d: %v", cacheType, 1, n, err)
+		}
+		if _, _, _, err = QueryCache(r.Context(), c, oc.CacheKeyPrefix+b, nil); err == nil {
+			t.Errorf("%s: expected key %s removed", cacheType, b)
+		}
+		n, err = InvalidationFunc(oc, c, client, rsc.Logger)(&invalidation.Request{
+			Keys:    []string{a},
+			StartMS: now.Add(-3*time.Hour).Unix() * 1000,
+			EndMS:   now.Add(-2*time.Hour).Unix() * 1000,
+			Partial: true,
+		})
+		if err != nil || n != 0 {
+			t.Errorf("%s: expected %d got %d: %v", cacheType, 0, n, err)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/util/md5"
@@ -39,6 +40,7 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 
 	rsc := request.GetResources(pr.Request)
 	pc := rsc.PathConfig
+	extra += keyEpoch(rsc)
 
 	if pc == nil {
 		return md5.Checksum(pr.URL.Path + extra)
@@ -134,6 +136,23 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 	return md5.Checksum(pr.URL.Path + "." + strings.Join(vals, "") + extra)
 }
 
+// keyEpoch returns the suffix that places the derived cache keys of the request's origin in
+// the key space of its client's KeyVersion and its configured cache_generation. It is empty
+// while both are 0, so that keys derived before either was set remain valid
+func keyEpoch(rsc *request.Resources) string {
+	var v, g int
+	if kv, ok := rsc.OriginClient.(origins.KeyVersioner); ok {
+		v = kv.KeyVersion()
+	}
+	if rsc.OriginConfig != nil {
+		g = rsc.OriginConfig.CacheGeneration
+	}
+	if v == 0 && g == 0 {
+		return ""
+	}
+	return ".v" + strconv.Itoa(v) + ".g" + strconv.Itoa(g)
+}
+
 func deepSearch(document map[string]interface{}, key string) (string, error) {
 
 	if key == "" {
@@ -270,6 +270,73 @@ func TestDeriveCacheKeyNoPathConfig(t *testing.T) {
 
 }
 
+type testKeyVersionClient struct {
+	*TestClient
+	version int
+}
+
+func (c *testKeyVersionClient) KeyVersion() int {
+	return c.version
+}
+
+func TestDeriveCacheKeyEpoch(t *testing.T) {
+
+	cfg := &oo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:           "/",
+				CacheKeyParams: []string{"query", "step", "time"},
+			},
+		},
+	}
+	client := &testKeyVersionClient{TestClient: &TestClient{config: cfg}}
+
+	deriveKey := func() string {
+		tr := httptest.NewRequest("GET", "http://127.0.0.1/?query=12345&step=300&time=0", nil)
+		tr = tr.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, cfg.Paths["root"], nil, nil, client, nil, tl.ConsoleLogger("error"))))
+		return newProxyRequest(tr, nil).DeriveCacheKey(nil, "")
+	}
+
+	// a zero version and generation must not change the keys derived before either existed
+	k0 := deriveKey()
+	if k0 != "a299b8fe19ff8e15f50b6d10f415452a" {
+		t.Errorf("unexpected cache key: %s", k0)
+	}
+
+	cfg.CacheGeneration = 1
+	k1 := deriveKey()
+	if k1 == k0 {
+		t.Error("expected a new cache key for generation 1")
+	}
+
+	client.version = 1
+	k2 := deriveKey()
+	if k2 == k1 || k2 == k0 {
+		t.Error("expected a new cache key for key version 1")
+	}
+
+	if k := deriveKey(); k != k2 {
+		t.Errorf("expected %s got %s", k2, k)
+	}
+
+}
+
+func TestKeyEpoch(t *testing.T) {
+
+	rsc := request.NewResources(&oo.Options{}, nil, nil, nil, nil, nil, tl.ConsoleLogger("error"))
+	if s := keyEpoch(rsc); s != "" {
+		t.Errorf("expected empty epoch got %s", s)
+	}
+
+	rsc.OriginConfig.CacheGeneration = 2
+	rsc.OriginClient = &testKeyVersionClient{TestClient: &TestClient{}, version: 1}
+	if s := keyEpoch(rsc); s != ".v1.g2" {
+		t.Errorf("expected %s got %s", ".v1.g2", s)
+	}
+
+}
+
 func TestDeriveCacheKeyNilURL(t *testing.T) {
 
 	_, w, r, _, _ := tu.NewTestInstance("", nil, 0, "", nil, "rpc",
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/dns"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// negativeCache returns the negative cache that applies to the request, which is its
+// path's negative cache when one is configured, and otherwise its origin's
+func negativeCache(rsc *request.Resources) *negative.Cache {
+	if rsc == nil {
+		return nil
+	}
+	if rsc.PathConfig != nil && rsc.PathConfig.NegativeCache != nil {
+		return rsc.PathConfig.NegativeCache
+	}
+	if rsc.OriginConfig != nil {
+		return rsc.OriginConfig.NegativeCache
+	}
+	return nil
+}
+
+// upstreamErrorTTL returns the negative cache TTL for a response that was generated
+// because the request could not be completed by the origin, and false if the response
+// came from the origin or its error is not negatively cached
+func upstreamErrorTTL(nc *negative.Cache, resp *http.Response) (time.Duration, bool) {
+	if nc == nil || resp.Request == nil {
+		return 0, false
+	}
+	err := tc.UpstreamError(resp.Request.Context())
+	if err == nil {
+		return 0, false
+	}
+	var de *net.DNSError
+	if errors.As(err, &de) || errors.Is(err, dns.ErrNoAddresses) {
+		return nc.DNSErrorTTL, nc.DNSErrorTTL > 0
+	}
+	return nc.ConnectErrorTTL, nc.ConnectErrorTTL > 0
+}
+
+// getResponseCachingPolicy returns the CachingPolicy for the upstream response, applying
+// the negative cache that applies to the request, and its jitter
+func getResponseCachingPolicy(rsc *request.Resources, resp *http.Response) *CachingPolicy {
+	nc := negativeCache(rsc)
+	if nc == nil {
+		return GetResponseCachingPolicy(resp.StatusCode, nil, resp.Header)
+	}
+	ttls := nc.TTLs
+	if d, ok := upstreamErrorTTL(nc, resp); ok {
+		ttls = map[int]time.Duration{resp.StatusCode: d}
+	}
+	cp := GetResponseCachingPolicy(resp.StatusCode, ttls, resp.Header)
+	if cp.IsNegativeCache && nc.JitterPct > 0 {
+		d := nc.Jitter(cp.Expires.Sub(cp.LocalDate))
+		cp.FreshnessLifetime = int(d.Seconds())
+		cp.Expires = cp.LocalDate.Add(d)
+	}
+	return cp
+}
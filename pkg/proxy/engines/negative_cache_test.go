@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/dns"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+func TestNegativeCache(t *testing.T) {
+
+	if nc := negativeCache(nil); nc != nil {
+		t.Error("expected nil negative cache")
+	}
+
+	onc := &negative.Cache{}
+	pnc := &negative.Cache{}
+	oc := oo.NewOptions()
+	oc.NegativeCache = onc
+	pc := po.NewOptions()
+	rsc := request.NewResources(oc, pc, nil, nil, nil, nil, nil)
+
+	if nc := negativeCache(rsc); nc != onc {
+		t.Error("expected origin negative cache")
+	}
+
+	pc.NegativeCache = pnc
+	if nc := negativeCache(rsc); nc != pnc {
+		t.Error("expected path negative cache")
+	}
+}
+
+func TestUpstreamErrorTTL(t *testing.T) {
+
+	nc := &negative.Cache{DNSErrorTTL: time.Second, ConnectErrorTTL: 2 * time.Second}
+
+	errResponse := func(err error) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		return &http.Response{StatusCode: http.StatusBadGateway,
+			Request: r.WithContext(tc.WithUpstreamError(r.Context(), err))}
+	}
+
+	tests := []struct {
+		resp     *http.Response
+		nc       *negative.Cache
+		expected time.Duration
+		ok       bool
+	}{
+		{ // 0 - a response from the origin
+			resp: &http.Response{StatusCode: http.StatusBadGateway,
+				Request: httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)},
+			nc: nc,
+		},
+		{ // 1 - hostname resolution failure
+			resp:     errResponse(fmt.Errorf("dial: %w", &net.DNSError{Err: "no such host", IsNotFound: true})),
+			nc:       nc,
+			expected: time.Second,
+			ok:       true,
+		},
+		{ // 2 - hostname with no addresses
+			resp:     errResponse(dns.ErrNoAddresses),
+			nc:       nc,
+			expected: time.Second,
+			ok:       true,
+		},
+		{ // 3 - connection failure
+			resp:     errResponse(errors.New("connection refused")),
+			nc:       nc,
+			expected: 2 * time.Second,
+			ok:       true,
+		},
+		{ // 4 - connection failures are not negatively cached
+			resp: errResponse(errors.New("connection refused")),
+			nc:   &negative.Cache{DNSErrorTTL: time.Second},
+		},
+		{ // 5 - no negative cache
+			resp: errResponse(errors.New("connection refused")),
+		},
+		{ // 6 - no request
+			resp: &http.Response{StatusCode: http.StatusBadGateway},
+			nc:   nc,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			d, ok := upstreamErrorTTL(test.nc, test.resp)
+			if ok != test.ok {
+				t.Errorf("expected %t got %t", test.ok, ok)
+			}
+			if d != test.expected {
+				t.Errorf("expected %s got %s", test.expected, d)
+			}
+		})
+	}
+}
+
+func TestGetResponseCachingPolicyJitter(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.NegativeCache = &negative.Cache{TTLs: map[int]time.Duration{404: 100 * time.Second},
+		JitterPct: 50}
+	rsc := request.NewResources(oc, nil, nil, nil, nil, nil, nil)
+	resp := &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}}
+
+	ttls := make(map[time.Duration]bool)
+	for i := 0; i < 10; i++ {
+		cp := getResponseCachingPolicy(rsc, resp)
+		if !cp.IsNegativeCache {
+			t.Fatal("expected negative cache policy")
+		}
+		d := cp.Expires.Sub(cp.LocalDate)
+		if d < 50*time.Second || d > 100*time.Second {
+			t.Errorf("expected ttl between %s and %s got %s", 50*time.Second, 100*time.Second, d)
+		}
+		if cp.FreshnessLifetime != int(d.Seconds()) {
+			t.Errorf("expected %d got %d", int(d.Seconds()), cp.FreshnessLifetime)
+		}
+		ttls[d] = true
+	}
+	if len(ttls) < 2 {
+		t.Error("expected jittered ttls")
+	}
+
+	// responses that are not negatively cached are unaffected
+	resp.StatusCode = http.StatusOK
+	if cp := getResponseCachingPolicy(rsc, resp); cp.IsNegativeCache || cp.FreshnessLifetime != -1 {
+		t.Errorf("expected %d got %d", -1, cp.FreshnessLifetime)
+	}
+}
+
+func TestObjectProxyCacheRequestNegativeCacheConnectError(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	// the origin can no longer be reached
+	ts.Close()
+
+	pc := po.NewOptions()
+	pc.NegativeCache = &negative.Cache{TTLs: map[int]time.Duration{},
+		ConnectErrorTTL: 30 * time.Second}
+	cfg := rsc.OriginConfig
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.OriginClient, nil, rsc.Logger)))
+
+	_, e := testFetchOPC(r, http.StatusBadGateway, "", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the connection failure is served from the negative cache
+	_, e = testFetchOPC(r, http.StatusBadGateway, "", map[string]string{"status": "nchit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/memory"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
@@ -215,10 +216,34 @@ func handleCacheRevalidationResponse(pr *proxyRequest) error {
 	}
 
 	pr.revalidation = RevalStatusFailed
+	if handleStaleIfError(pr) {
+		return nil
+	}
 	pr.cacheStatus = status.LookupStatusKeyMiss
 	return handleAllWrites(pr)
 }
 
+// handleStaleIfError serves a soft-purged cache document in place of the upstream
+// response when the origin could not provide a fresh copy, and returns true if it did
+func handleStaleIfError(pr *proxyRequest) bool {
+	if !pr.cacheDocument.isSoftPurged() || (pr.upstreamResponse != nil &&
+		pr.upstreamResponse.StatusCode > 0 &&
+		pr.upstreamResponse.StatusCode < http.StatusInternalServerError) {
+		return false
+	}
+	var sc int
+	if pr.upstreamResponse != nil {
+		sc = pr.upstreamResponse.StatusCode
+	}
+	pr.Logger.Warn("serving soft-purged object after upstream failure",
+		log.Pairs{"cacheKey": pr.key, "upstreamStatus": sc})
+	annotateCanonical(pr.Request, log.Pairs{"decision": "hit: stale-if-error"})
+	pr.writeToCache = false
+	pr.cacheStatus = status.LookupStatusHit
+	handleTrueCacheHit(pr)
+	return true
+}
+
 func handleTrueCacheHit(pr *proxyRequest) error {
 
 	d := pr.cacheDocument
@@ -255,9 +280,11 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 	rsc := request.GetResources(pr.Request)
 	pc := rsc.PathConfig
 
-	// if a we're using PCF, handle that separately
+	// if a we're using PCF, handle that separately. soft-purged objects are not
+	// eligible, since PCF streams the upstream response before it can be vetted
 	if !methods.HasBody(pr.Method) && !pr.wantsRanges && pc != nil &&
-		pc.CollapsedForwardingType == forwarding.CFTypeProgressive {
+		pc.CollapsedForwardingType == forwarding.CFTypeProgressive &&
+		!pr.cacheDocument.isSoftPurged() {
 		if err := handlePCF(pr); err != errors.ErrPCFContentLength {
 			// if err is nil, or something else, we'll proceed.
 			return err
@@ -266,6 +293,9 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 
 	pr.prepareUpstreamRequests()
 	handleUpstreamTransactions(pr)
+	if handleStaleIfError(pr) {
+		return nil
+	}
 	return handleAllWrites(pr)
 }
 
@@ -279,7 +309,6 @@ func handleUpstreamTransactions(pr *proxyRequest) error {
 func handlePCF(pr *proxyRequest) error {
 
 	rsc := request.GetResources(pr.Request)
-	oc := rsc.OriginConfig
 
 	pr.isPCF = true
 	pcfResult, pcfExists := reqs.Load(pr.key)
@@ -308,13 +337,12 @@ func handlePCF(pr *proxyRequest) error {
 	pr.writeResponseHeader()
 	pr.responseWriter = PrepareResponseWriter(pr.responseWriter, resp.StatusCode, resp.Header)
 	// Check if we know the content length and if it is less than our max object size.
-	if contentLength > 0 && contentLength < int64(oc.MaxObjectSizeBytes) {
+	if contentLength > 0 && contentLength < int64(maxObjectSize(rsc)) {
 		pcf := NewPCF(resp, contentLength)
 		reqs.Store(pr.key, pcf)
 		// Blocks until server completes
 
-		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.OriginConfig.NegativeCache, pr.upstreamResponse.Header))
+		pr.cachingPolicy.Merge(getResponseCachingPolicy(rsc, pr.upstreamResponse))
 		pr.determineCacheability()
 
 		go func() {
@@ -393,6 +421,12 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 
 	pr.key = oc.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey(nil, "")
+	annotateCanonical(r, log.Pairs{"cacheKey": pr.key})
+
+	// objects owned by another cluster peer are served from the owner's cache
+	if rw, ok := w.(http.ResponseWriter); ok && forwardToPeer(rw, r, pr.key) {
+		return nil, status.LookupStatusPeer
+	}
 
 	// if a PCF entry exists, or the client requested no-cache for this object, proxy out to it
 	pcfResult, pcfExists := reqs.Load(pr.key)
@@ -401,6 +435,7 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	if pr.isPCF || pr.cachingPolicy.NoCache {
 		if pr.cachingPolicy.NoCache {
 			cc.Remove(pr.key)
+			annotateCanonical(r, log.Pairs{"decision": "proxy: client requested no-cache"})
 			return nil, status.LookupStatusProxyOnly
 		}
 		pcf := pcfResult.(ProgressiveCollapseForwarder)
@@ -420,6 +455,17 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	var err error
 	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err =
 		QueryCache(pr.upstreamRequest.Context(), cc, pr.key, pr.wantedRanges)
+
+	// while under memory pressure, misses are streamed to the client rather than buffered for caching
+	if (err == cache.ErrKNF || pr.cacheStatus == status.LookupStatusKeyMiss) && memory.UnderPressure() {
+		if pr.hasReadLock {
+			pr.cacheLock.RRelease()
+			pr.hasReadLock = false
+		}
+		annotateCanonical(r, log.Pairs{"decision": "proxy: memory pressure"})
+		return nil, status.LookupStatusProxyOnly
+	}
+
 	if err == nil || err == cache.ErrKNF {
 		if f, ok := cacheResponseHandlers[pr.cacheStatus]; ok {
 			f(pr)
@@ -444,6 +490,10 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 		return nil, status.LookupStatusRevalidated
 	}
 
+	if len(pr.neededRanges) > 0 {
+		annotateCanonical(r, log.Pairs{"rangesFetched": pr.neededRanges.String()})
+	}
+
 	// newProxyRequest sets pr.started to time.Now()
 	pr.elapsed = time.Since(pr.started)
 	el := float64(pr.elapsed.Milliseconds()) / 1000.0
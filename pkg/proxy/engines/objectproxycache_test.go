@@ -33,6 +33,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
@@ -788,6 +789,49 @@ func TestObjectProxyCacheRevalidated(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheSoftPurge(t *testing.T) {
+
+	hdrs := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	softPurge := InvalidationFunc(rsc.OriginConfig, rsc.CacheClient, rsc.OriginClient, rsc.Logger)
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	if n, err := softPurge(&invalidation.Request{Soft: true}); err != nil || n != 1 {
+		t.Errorf("expected %d got %d: %v", 1, n, err)
+	}
+
+	// the soft-purged object is refreshed on its next access, which clears the mark
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	if n, err := softPurge(&invalidation.Request{Soft: true}); err != nil || n != 1 {
+		t.Errorf("expected %d got %d: %v", 1, n, err)
+	}
+
+	// the soft-purged object is served when the origin can't be reached
+	ts.Close()
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestObjectProxyCacheRequestNegativeCache(t *testing.T) {
 
 	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
@@ -810,7 +854,7 @@ func TestObjectProxyCacheRequestNegativeCache(t *testing.T) {
 	}
 
 	// request again, should still cache miss, but this time, Negative Cache 404's for 30s
-	cfg.NegativeCache[404] = time.Second * 30
+	cfg.NegativeCache.TTLs[404] = time.Second * 30
 
 	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
 	for _, err = range e {
@@ -28,11 +28,13 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache/status"
 	"github.com/tricksterproxy/trickster/pkg/locks"
 	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	tpe "github.com/tricksterproxy/trickster/pkg/proxy/errors"
 	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/ranges/byterange"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tspan "github.com/tricksterproxy/trickster/pkg/tracing/span"
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 
 	"go.opentelemetry.io/otel/api/kv"
@@ -146,6 +148,23 @@ func (pr *proxyRequest) Clone() *proxyRequest {
 // Fetch makes an HTTP request to the provided Origin URL, bypassing the Cache, and returns the
 // response and elapsed time to the caller.
 func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
+	buf, resp, elapsed := pr.FetchBuffer()
+	return buffers.Bytes(buf), resp, elapsed
+}
+
+// FetchBuffer makes an HTTP request to the provided Origin URL, bypassing the Cache, and
+// returns the response body in a pooled buffer, which the caller must return with buffers.Put
+// once the body is no longer referenced, along with the response and elapsed time. When the
+// origin collapses requests, concurrent identical requests share a single upstream fetch
+func (pr *proxyRequest) FetchBuffer() (*bytes.Buffer, *http.Response, time.Duration) {
+	if key, ok := pr.collapseKey(); ok {
+		return pr.fetchCollapsed(key)
+	}
+	return pr.fetchBuffer()
+}
+
+// fetchBuffer makes the HTTP request to the Origin for FetchBuffer
+func (pr *proxyRequest) fetchBuffer() (*bytes.Buffer, *http.Response, time.Duration) {
 
 	rsc := request.GetResources(pr.upstreamRequest)
 	oc := rsc.OriginConfig
@@ -157,26 +176,29 @@ func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
 	}
 
 	start := time.Now()
-	reader, resp, _ := PrepareFetchReader(pr.upstreamRequest)
+	reader, resp, contentLength := PrepareFetchReader(pr.upstreamRequest)
 
-	var body []byte
+	var buf *bytes.Buffer
 	var err error
 	if reader != nil {
-		body, err = ioutil.ReadAll(reader)
+		buf, err = buffers.ReadAll(reader, contentLength)
 		resp.Body.Close()
+	} else {
+		buf = buffers.Get(0)
 	}
 	if err != nil {
 		pr.Logger.Error("error reading body from http response",
 			tl.Pairs{"url": pr.URL.String(), "detail": err.Error()})
-		return []byte{}, resp, 0
+		buf.Reset()
+		return buf, resp, 0
 	}
 
 	elapsed := time.Since(start) // includes any time required to decompress the document for deserialization
 
 	go logUpstreamRequest(pr.Logger, oc.Name, oc.OriginType, handlerName,
-		pr.Method, pr.URL.String(), pr.UserAgent(), resp.StatusCode, len(body), elapsed.Seconds())
+		pr.Method, pr.URL.String(), pr.UserAgent(), resp.StatusCode, buf.Len(), elapsed.Seconds())
 
-	return body, resp, elapsed
+	return buf, resp, elapsed
 }
 
 func (pr *proxyRequest) prepareRevalidationRequest() {
@@ -329,7 +351,19 @@ func (pr *proxyRequest) checkCacheFreshness() bool {
 	if pr.cachingPolicy == nil {
 		return false
 	}
-	cp.IsFresh = !cp.LocalDate.Add(time.Duration(cp.FreshnessLifetime) * time.Second).Before(time.Now())
+	// soft-purged objects are stale regardless of their freshness lifetime
+	if pr.cacheDocument.isSoftPurged() {
+		cp.IsFresh = false
+		return false
+	}
+	// while the origin is degraded, its cached objects remain fresh for a stretched lifetime
+	lifetime := time.Duration(cp.FreshnessLifetime) * time.Second
+	if rsc := request.GetResources(pr.Request); rsc != nil && rsc.OriginConfig != nil {
+		lifetime = rsc.OriginConfig.Budget.Stretch(lifetime)
+		// objects stored by a node whose clock is ahead must not appear to expire prematurely
+		lifetime += rsc.OriginConfig.ClockSkewTolerance
+	}
+	cp.IsFresh = !cp.LocalDate.Add(lifetime).Before(time.Now())
 	return cp.IsFresh
 }
 
@@ -362,6 +396,15 @@ func (pr *proxyRequest) stripConditionalHeaders() {
 
 func (pr *proxyRequest) writeResponseHeader() {
 	headers.SetResultsHeader(pr.upstreamResponse.Header, "ObjectProxyCache", pr.cacheStatus.String(), "", nil)
+	if rsc := request.GetResources(pr.Request); rsc != nil && rsc.PathConfig != nil &&
+		rsc.PathConfig.DownstreamCachingHeaders {
+		var max time.Duration
+		if rsc.OriginConfig != nil {
+			max = rsc.OriginConfig.MaxTTL
+		}
+		setDownstreamCachingHeaders(pr.Request, pr.upstreamResponse.Header, rsc.PathConfig,
+			pr.upstreamResponse.StatusCode, remainingFreshness(pr.cachingPolicy, max), pr.responseBody)
+	}
 }
 
 func (pr *proxyRequest) setBodyWriter() {
@@ -465,14 +508,22 @@ func (pr *proxyRequest) store() error {
 	rsc := request.GetResources(pr.Request)
 	oc := rsc.OriginConfig
 
+	// oversized objects are proxied without being cached, by the object's own limits, before
+	// the cache's limit is applied when the object is written
+	if exceedsMaxObjectSize(rsc.CacheClient, rsc, d.Size(), true) {
+		return tpe.ErrObjectTooLarge
+	}
+
 	rf := oc.RevalidationFactor
 	if rsc.AlternateCacheTTL > 0 {
 		rf = 1
 	}
 
 	d.CachingPolicy = pr.cachingPolicy
+	// when the origin has an error budget, objects are retained beyond their normal TTL,
+	// so they can continue to be served if the origin becomes degraded
 	err := WriteCache(pr.upstreamRequest.Context(), rsc.CacheClient, pr.key, d,
-		pr.cachingPolicy.TTL(rf, oc.MaxTTL), oc.CompressableTypes)
+		oc.Budget.Retention(pr.cachingPolicy.TTL(rf, oc.MaxTTL)), oc.CompressableTypes)
 	if err != nil {
 		return err
 	}
@@ -725,8 +776,7 @@ func (pr *proxyRequest) reconstituteResponses() {
 	// now we merge the caching policy of the new upstreams
 	if pr.upstreamResponse.StatusCode != http.StatusNotModified {
 		rsc := request.GetResources(pr.Request)
-		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.OriginConfig.NegativeCache, pr.upstreamResponse.Header))
+		pr.cachingPolicy.Merge(getResponseCachingPolicy(rsc, pr.upstreamResponse))
 
 	}
 
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// ReplicationReadFunc returns a replication.ReadFunc that encodes the origin's cached
+// timeseries objects for replication to its peers
+func ReplicationReadFunc(oc *oo.Options, c cache.Cache, client origins.TimeseriesClient,
+	logger *tl.Logger) replication.ReadFunc {
+	rsc := request.NewResources(oc, nil, c.Configuration(), c, client, nil, logger)
+	ctx := tctx.WithResources(context.Background(), rsc)
+	return func(key string) []byte {
+		return readReplica(ctx, rsc, key)
+	}
+}
+
+// ReplicationMergeFunc returns a replication.MergeFunc that merges the timeseries objects
+// replicated by the origin's peers into its cached objects
+func ReplicationMergeFunc(oc *oo.Options, c cache.Cache, client origins.TimeseriesClient,
+	logger *tl.Logger) replication.MergeFunc {
+	rsc := request.NewResources(oc, nil, c.Configuration(), c, client, nil, logger)
+	ctx := tctx.WithResources(context.Background(), rsc)
+	return func(key string, document []byte, ttl time.Duration) error {
+		return mergeReplica(ctx, rsc, key, document, ttl)
+	}
+}
+
+// readReplica returns the cached document at key, with the timeseries encoded in its body,
+// as MessagePack
+func readReplica(ctx context.Context, rsc *request.Resources, key string) []byte {
+
+	c := rsc.CacheClient
+	client := rsc.OriginClient.(origins.TimeseriesClient)
+
+	lock, _ := c.Locker().RAcquire(key)
+	defer lock.RRelease()
+
+	doc, _, _, err := QueryCache(ctx, c, key, nil)
+	if err != nil || doc == nil {
+		return nil
+	}
+
+	d := &HTTPDocument{
+		StatusCode:    doc.StatusCode,
+		Status:        doc.Status,
+		Headers:       doc.SafeHeaderClone(),
+		ContentType:   doc.ContentType,
+		CachingPolicy: doc.CachingPolicy,
+		Body:          doc.Body,
+	}
+	if rsc.CacheConfig.CacheType == "memory" {
+		if doc.timeseries == nil {
+			return nil
+		}
+		d.Body, err = client.MarshalTimeseries(doc.timeseries)
+		if err != nil {
+			return nil
+		}
+	}
+
+	b, err := d.MarshalMsg(nil)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// mergeReplica merges the timeseries in the replicated document into the object cached at key.
+// Merging is a union of the series' values and extents, so as long as the peers proxy the same
+// upstream data, the cached object converges to the same result regardless of the order in
+// which the replicas arrive, or how often.
+func mergeReplica(ctx context.Context, rsc *request.Resources, key string,
+	document []byte, ttl time.Duration) error {
+
+	client := rsc.OriginClient.(origins.TimeseriesClient)
+
+	rd := &HTTPDocument{}
+	if _, err := rd.UnmarshalMsg(document); err != nil {
+		return err
+	}
+	rts, err := client.UnmarshalTimeseries(rd.Body)
+	if err != nil {
+		return err
+	}
+	if len(rts.Extents()) == 0 {
+		return errors.New("replicated timeseries has no extents")
+	}
+	// replicas are not enqueued for replication, so they do not echo between the peers
+	return mergeTimeseries(ctx, rsc, key, rd, rts, ttl)
+}
+
+// mergeTimeseries merges rts into the timeseries cached at key, and writes the result to the
+// cache for up to ttl. If nothing is cached at key, rd is cached with rts as its timeseries.
+func mergeTimeseries(ctx context.Context, rsc *request.Resources, key string,
+	rd *HTTPDocument, rts timeseries.Timeseries, ttl time.Duration) error {
+
+	c := rsc.CacheClient
+	oc := rsc.OriginConfig
+	client := rsc.OriginClient.(origins.TimeseriesClient)
+
+	lock, _ := c.Locker().Acquire(key)
+	defer lock.Release()
+
+	var cts timeseries.Timeseries
+	doc, _, _, err := QueryCache(ctx, c, key, nil)
+	if err == nil && doc != nil {
+		if rsc.CacheConfig.CacheType == "memory" {
+			if doc.timeseries != nil {
+				// the cached reference may be in use by a request, so it is merged as a copy
+				cts = doc.timeseries.Clone()
+			}
+		} else {
+			cts, _ = client.UnmarshalTimeseries(doc.Body)
+		}
+	}
+	if cts == nil {
+		// the object is not cached locally (or can't be read), so rts is stored as-is
+		doc, cts = rd, rts
+	} else {
+		cts.Merge(true, rts)
+	}
+
+	if oldest := oc.RetentionTrimmer.Oldest(); !oldest.IsZero() {
+		el := cts.Extents()
+		cts.CropToRange(timeseries.Extent{Start: oldest, End: el[len(el)-1].End})
+		if len(cts.Extents()) == 0 {
+			return nil
+		}
+	}
+
+	if rsc.CacheConfig.CacheType == "memory" {
+		doc.timeseries = cts
+	} else {
+		doc.Body, err = client.MarshalTimeseries(cts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the provided TTL is honored, but not beyond the origin's own timeseries TTL
+	if ttl > oc.TimeseriesTTL {
+		ttl = oc.TimeseriesTTL
+	}
+	if err = WriteCache(ctx, c, key, doc, ttl, oc.CompressableTypes); err != nil {
+		rsc.Logger.Error("error writing object to cache",
+			tl.Pairs{"originName": oc.Name, "cacheKey": key, "detail": err.Error()})
+		return err
+	}
+	oc.RetentionTrimmer.Track(key, ttl)
+	return nil
+}
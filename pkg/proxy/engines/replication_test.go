@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+	rpo "github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestDeltaProxyCacheRequestReplication(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	rsc.CacheConfig.CacheType = "test"
+	oc.FastForwardDisable = true
+
+	oc.Replication = &rpo.Options{Peers: []string{"http://127.0.0.1"}, IntervalMS: 1,
+		TimeoutMS: 1000, MaxPending: 10}
+	oc.Replication.SetDurations()
+	oc.Replicator = replication.New(oc.Name, oc.OriginType, oc.CacheKeyPrefix,
+		"/trickster/replication/"+oc.Name, oc.Replication)
+
+	step := time.Duration(300) * time.Second
+	now := time.Now().Truncate(step)
+
+	query := func(start, end time.Time) {
+		w := httptest.NewRecorder()
+		u := r.URL
+		u.Path = "/prometheus/api/v1/query_range"
+		u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s", int(step.Seconds()),
+			start.Unix(), end.Unix(), queryReturnsOKNoLatency)
+		client.QueryRangeHandler(w, r)
+		ioutil.ReadAll(w.Result().Body)
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// a cache write enqueues the object for replication
+	query(now.Add(-6*time.Hour), now.Add(-5*time.Hour))
+	if n := oc.Replicator.Len(); n != 1 {
+		t.Fatalf("expected %d got %d", 1, n)
+	}
+
+	// capture the replica that would be sent to the peers
+	var key string
+	var replica []byte
+	read := ReplicationReadFunc(oc, rsc.CacheClient, client, rsc.Logger)
+	oc.Replicator.Push(func(k string) []byte {
+		key = k
+		replica = read(k)
+		return nil
+	})
+	if len(replica) == 0 {
+		t.Fatal("expected replica document")
+	}
+
+	// the local object is replaced with a different extent of the same query, and the replica
+	// is merged into it as if it was received from a peer
+	rsc.CacheClient.Remove(key)
+	query(now.Add(-2*time.Hour), now.Add(-1*time.Hour))
+
+	merge := ReplicationMergeFunc(oc, rsc.CacheClient, client, rsc.Logger)
+	if err := merge(key, replica, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cts, err := client.UnmarshalTimeseries(doc.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el := timeseries.ExtentList(cts.Extents())
+	expected := timeseries.ExtentList{
+		{Start: now.Add(-6 * time.Hour), End: now.Add(-5 * time.Hour)},
+		{Start: now.Add(-2 * time.Hour), End: now.Add(-1 * time.Hour)},
+	}
+	if el.String() != expected.String() {
+		t.Errorf("expected %s got %s", expected.String(), el.String())
+	}
+
+	// merging the same replica again does not change the object
+	if err := merge(key, replica, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, _, _ = QueryCache(r.Context(), rsc.CacheClient, key, nil)
+	cts, _ = client.UnmarshalTimeseries(doc.Body)
+	if s := timeseries.ExtentList(cts.Extents()).String(); s != expected.String() {
+		t.Errorf("expected %s got %s", expected.String(), s)
+	}
+
+	// a received replica is not replicated again
+	if n := oc.Replicator.Len(); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	// a replica of a key that is not cached locally is stored as-is
+	if err := merge(key+".new", replica, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = QueryCache(r.Context(), rsc.CacheClient, key+".new", nil); err != nil {
+		t.Error(err)
+	}
+
+	// invalid replicas are rejected
+	if err := merge(key, []byte("invalid"), time.Hour); err == nil {
+		t.Error("expected error for invalid replica")
+	}
+
+}
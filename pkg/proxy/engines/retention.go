@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// RetentionTrimFunc returns a retention.TrimFunc that truncates the origin's cached
+// timeseries objects to its retention window
+func RetentionTrimFunc(oc *oo.Options, c cache.Cache, client origins.TimeseriesClient,
+	logger *tl.Logger) retention.TrimFunc {
+	rsc := request.NewResources(oc, nil, c.Configuration(), c, client, nil, logger)
+	ctx := tctx.WithResources(context.Background(), rsc)
+	return func(key string, oldest time.Time, ttl time.Duration) retention.Result {
+		return trimTimeseries(ctx, rsc, key, oldest, ttl)
+	}
+}
+
+func trimTimeseries(ctx context.Context, rsc *request.Resources, key string,
+	oldest time.Time, ttl time.Duration) retention.Result {
+
+	c := rsc.CacheClient
+	client := rsc.OriginClient.(origins.TimeseriesClient)
+
+	lock, _ := c.Locker().Acquire(key)
+	defer lock.Release()
+
+	doc, lookupStatus, _, err := QueryCache(ctx, c, key, nil)
+	if lookupStatus == status.LookupStatusKeyMiss || doc == nil {
+		return retention.ResultMissing
+	}
+	if err != nil {
+		return retention.ResultUnchanged
+	}
+
+	var cts timeseries.Timeseries
+	if rsc.CacheConfig.CacheType == "memory" {
+		if doc.timeseries == nil {
+			return retention.ResultMissing
+		}
+		// the cached reference may be in use by a request, so it is cropped as a copy
+		cts = doc.timeseries.Clone()
+	} else {
+		cts, err = client.UnmarshalTimeseries(doc.Body)
+		if err != nil {
+			return retention.ResultUnchanged
+		}
+	}
+
+	el := cts.Extents()
+	if len(el) == 0 || !el[0].Start.Before(oldest) {
+		return retention.ResultUnchanged
+	}
+
+	cts.CropToRange(timeseries.Extent{Start: oldest, End: el[len(el)-1].End})
+	if len(cts.Extents()) == 0 {
+		c.Remove(key)
+		return retention.ResultRemoved
+	}
+
+	if rsc.CacheConfig.CacheType == "memory" {
+		doc.timeseries = cts
+	} else {
+		doc.Body, err = client.MarshalTimeseries(cts)
+		if err != nil {
+			return retention.ResultUnchanged
+		}
+	}
+	if err := WriteCache(ctx, c, key, doc, ttl, rsc.OriginConfig.CompressableTypes); err != nil {
+		rsc.Logger.Error("error writing object to cache",
+			tl.Pairs{"originName": rsc.OriginConfig.Name, "cacheKey": key, "detail": err.Error()})
+		return retention.ResultUnchanged
+	}
+	return retention.ResultTruncated
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention"
+	rto "github.com/tricksterproxy/trickster/pkg/proxy/retention/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestDeltaProxyCacheRequestRetentionWindow(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	rsc.CacheConfig.CacheType = "test"
+	oc.FastForwardDisable = true
+
+	oc.Retention = &rto.Options{WindowDays: 1, TrimIntervalSecs: 1}
+	oc.Retention.SetDurations()
+	oc.RetentionTrimmer = retention.New(oc.Name, oc.OriginType, oc.Retention)
+
+	step := time.Duration(300) * time.Second
+	now := time.Now()
+
+	// a timerange that crosses the start of the retention window is cached from the start of the window
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s", int(step.Seconds()),
+		now.Add(-36*time.Hour).Unix(), now.Add(-12*time.Hour).Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	ioutil.ReadAll(resp.Body)
+
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	if n := oc.RetentionTrimmer.Len(); n != 1 {
+		t.Fatalf("expected %d got %d", 1, n)
+	}
+
+	// capture the tracked key while trimming to a shorter window
+	var key string
+	var result retention.Result
+	trim := RetentionTrimFunc(oc, rsc.CacheClient, client, rsc.Logger)
+	recordTrim := func(k string, oldest time.Time, ttl time.Duration) retention.Result {
+		key = k
+		result = trim(k, oldest, ttl)
+		return result
+	}
+
+	oc.Retention.Window = 18 * time.Hour
+	oc.RetentionTrimmer.Trim(recordTrim)
+	if result != retention.ResultTruncated {
+		t.Errorf("expected %d got %d", retention.ResultTruncated, result)
+	}
+
+	doc, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cts, err := client.UnmarshalTimeseries(doc.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el := timeseries.ExtentList(cts.Extents())
+	if len(el) == 0 || el[0].Start.Before(now.Add(-18*time.Hour)) {
+		t.Errorf("expected extents starting after %s, got %s", now.Add(-18*time.Hour), el.String())
+	}
+
+	// when all of the cached data is older than the window, the object is removed
+	oc.Retention.Window = 6 * time.Hour
+	oc.RetentionTrimmer.Trim(recordTrim)
+	if result != retention.ResultRemoved {
+		t.Errorf("expected %d got %d", retention.ResultRemoved, result)
+	}
+	if n := oc.RetentionTrimmer.Len(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+}
+
+func TestDeltaProxyCacheRequestOlderThanRetentionWindow(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.OriginClient.(*TestClient)
+	oc := rsc.OriginConfig
+	oc.FastForwardDisable = true
+
+	oc.Retention = &rto.Options{WindowDays: 1, TrimIntervalSecs: 1}
+	oc.Retention.SetDurations()
+	oc.RetentionTrimmer = retention.New(oc.Name, oc.OriginType, oc.Retention)
+
+	step := time.Duration(300) * time.Second
+	now := time.Now()
+
+	// a timerange that ends before the retention window is proxied without caching
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s", int(step.Seconds()),
+		now.Add(-48*time.Hour).Unix(), now.Add(-36*time.Hour).Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	ioutil.ReadAll(resp.Body)
+
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "proxy-only"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	if n := oc.RetentionTrimmer.Len(); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// WarmingReplayFunc returns a warming.ReplayFunc that serves each replayed query through
+// its origin's routes, exactly as if a client had requested it, so the response is cached
+// under the key that the client's query uses. Timeseries queries are shifted to end now,
+// keeping their duration, so the most recent data is warmed
+func WarmingReplayFunc(ocs map[string]*oo.Options, clients origins.Origins,
+	logger *tl.Logger) warming.ReplayFunc {
+	return func(originName, uri string) (int, error) {
+		code, err := replayWarmingQuery(ocs[originName], clients[originName], uri, time.Now())
+		if err != nil || code < 200 || code >= 300 {
+			pairs := tl.Pairs{"originName": originName, "uri": uri, "status": code}
+			if err != nil {
+				pairs["detail"] = err.Error()
+			}
+			logger.Debug("cache warming query failed", pairs)
+		}
+		return code, err
+	}
+}
+
+func replayWarmingQuery(oc *oo.Options, client origins.Client, uri string,
+	now time.Time) (int, error) {
+
+	if oc == nil || oc.Router == nil {
+		return 0, fmt.Errorf("origin is not registered")
+	}
+
+	r, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	r = r.WithContext(warming.WithReplay(context.Background()))
+
+	if tc, ok := client.(origins.TimeseriesClient); ok {
+		// queries that are not timeseries, such as instantaneous queries, are replayed as-is
+		if trq, err := tc.ParseTimeRangeQuery(r); err == nil && !trq.Extent.End.IsZero() {
+			d := trq.Extent.End.Sub(trq.Extent.Start)
+			tc.SetExtent(r, trq, &timeseries.Extent{Start: now.Add(-d), End: now})
+		}
+	}
+
+	w := &warmingResponseWriter{header: make(http.Header)}
+	oc.Router.ServeHTTP(w, r)
+	return w.status, nil
+}
+
+// warmingResponseWriter is an http.ResponseWriter that retains only the response status
+type warmingResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *warmingResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *warmingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *warmingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestWarmingReplayFunc(t *testing.T) {
+
+	var replayed *http.Request
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, r *http.Request) {
+		replayed = r
+		w.Write([]byte("ok"))
+	})
+
+	oc := oo.NewOptions()
+	oc.Router = router
+	ocs := map[string]*oo.Options{"test": oc}
+	clients := origins.Origins{"test": &TestClient{}}
+
+	replay := WarmingReplayFunc(ocs, clients, tl.ConsoleLogger("error"))
+
+	// a timeseries query is shifted to end now, keeping its duration
+	code, err := replay("test", "/api/v1/query_range?query=up&start=1000&end=4600&step=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, code)
+	}
+	if !warming.IsReplay(replayed.Context()) {
+		t.Error("expected replay context")
+	}
+	v := replayed.URL.Query()
+	start, _ := strconv.ParseInt(v.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(v.Get("end"), 10, 64)
+	if end-start != 3600 || time.Since(time.Unix(end, 0)) > time.Minute {
+		t.Errorf("unexpected extent %d-%d", start, end)
+	}
+
+	// other queries are replayed as-is
+	code, _ = replay("test", "/api/v1/query_range?step=60")
+	if code != http.StatusOK || replayed.URL.RawQuery != "step=60" {
+		t.Errorf("unexpected replay %d %s", code, replayed.URL.RawQuery)
+	}
+
+	if code, _ = replay("test", "/missing"); code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, code)
+	}
+
+	if _, err = replay("unknown", "/api/v1/query_range"); err == nil {
+		t.Error("expected error for unknown origin")
+	}
+}
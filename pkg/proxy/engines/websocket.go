@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// IsWebSocketUpgrade returns true if the request is a WebSocket opening handshake
+func IsWebSocketUpgrade(r *http.Request) bool {
+	if r == nil || !strings.EqualFold(r.Header.Get(headers.NameUpgrade), "websocket") {
+		return false
+	}
+	for _, v := range strings.Split(r.Header.Get(headers.NameConnection), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketProxy proxies a WebSocket connection to the upstream origin. Once the origin accepts
+// the upgrade, the client connection is hijacked and data is relayed in both directions until
+// either side closes the connection
+func WebSocketProxy(w http.ResponseWriter, r *http.Request) *http.Response {
+
+	rsc := request.GetResources(r)
+	oc := rsc.OriginConfig
+	start := time.Now()
+
+	reader, resp, _ := PrepareFetchReader(r)
+	cacheStatusCode := setStatusHeader(resp.StatusCode, resp.Header)
+
+	upstream, ok := reader.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		// the origin declined the upgrade, so pass its response through
+		writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
+		if reader != nil {
+			io.Copy(writer, reader)
+			reader.Close()
+		}
+		recordResults(r, "WebSocketProxy", cacheStatusCode, resp.StatusCode,
+			r.URL.Path, "", time.Since(start).Seconds(), nil, resp.Header)
+		return resp
+	}
+	defer upstream.Close()
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		rsc.Logger.Error("websocket upgrade failed",
+			tl.Pairs{"originName": oc.Name, "detail": "response writer does not support hijacking"})
+		resp.StatusCode = http.StatusInternalServerError
+		Respond(w, resp.StatusCode, nil, nil)
+		return resp
+	}
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		rsc.Logger.Error("websocket upgrade failed",
+			tl.Pairs{"originName": oc.Name, "detail": err.Error()})
+		return resp
+	}
+	defer conn.Close()
+
+	// relay the origin's handshake response to the client
+	bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.Header.Write(bufrw)
+	bufrw.WriteString("\r\n")
+	if err = bufrw.Flush(); err != nil {
+		return resp
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// the client's reader may hold data received along with the handshake
+		io.Copy(upstream, bufrw)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	// when either side closes, the deferred closes end the other direction
+	<-done
+
+	recordResults(r, "WebSocketProxy", cacheStatusCode, resp.StatusCode,
+		r.URL.Path, "", time.Since(start).Seconds(), nil, resp.Header)
+	return resp
+}
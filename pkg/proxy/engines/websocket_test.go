@@ -0,0 +1,209 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+// wsOpText is the WebSocket text frame opcode, per RFC 6455
+const wsOpText = 0x1
+
+// wsFrame returns a single WebSocket frame, masked when mask is provided
+func wsFrame(fin bool, opcode byte, payload []byte, mask []byte) []byte {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	b := []byte{b0}
+	var mb byte
+	if mask != nil {
+		mb = 0x80
+	}
+	switch l := len(payload); {
+	case l < 126:
+		b = append(b, mb|byte(l))
+	case l <= 0xffff:
+		b = append(b, mb|126, 0, 0)
+		binary.BigEndian.PutUint16(b[2:], uint16(l))
+	default:
+		b = append(b, mb|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[2:], uint64(l))
+	}
+	p := make([]byte, len(payload))
+	copy(p, payload)
+	if mask != nil {
+		b = append(b, mask...)
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+	return append(b, p...)
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		upgrade, connection string
+		expected            bool
+	}{
+		{"websocket", "Upgrade", true},
+		{"WebSocket", "keep-alive, upgrade", true},
+		{"", "Upgrade", false},
+		{"websocket", "keep-alive", false},
+		{"h2c", "Upgrade", false},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		r.Header.Set(headers.NameUpgrade, test.upgrade)
+		r.Header.Set(headers.NameConnection, test.connection)
+		if v := IsWebSocketUpgrade(r); v != test.expected {
+			t.Errorf("expected %t got %t for %s %s", test.expected, v, test.upgrade, test.connection)
+		}
+	}
+	if IsWebSocketUpgrade(nil) {
+		t.Error("expected false")
+	}
+}
+
+func TestWebSocketProxy(t *testing.T) {
+
+	// the upstream completes the handshake, sends a message, then echoes the client's frames
+	us := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsWebSocketUpgrade(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, bufrw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n" +
+			"Connection: Upgrade\r\nSec-WebSocket-Accept: test\r\n\r\n")
+		bufrw.Write(wsFrame(true, wsOpText, []byte("hello"), nil))
+		bufrw.Flush()
+		b := make([]byte, 64)
+		n, _ := bufrw.Read(b)
+		conn.Write(b[:n])
+	}))
+	defer us.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", us.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	oc := conf.Origins["default"]
+	oc.HTTPClient = &http.Client{Transport: &http.Transport{}, Timeout: time.Millisecond}
+
+	ps := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = "http"
+		r.URL.Host = us.Listener.Addr().String()
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(oc, nil, nil, nil, &TestClient{}, nil, testLogger)))
+		DoProxy(w, r, true)
+	}))
+	defer ps.Close()
+
+	conn, err := net.Dial("tcp", ps.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGVzdA==\r\nSec-WebSocket-Version: 13\r\n\r\n", ps.Listener.Addr().String())
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected %d got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if v := resp.Header.Get("Sec-WebSocket-Accept"); v != "test" {
+		t.Errorf("expected %s got %s", "test", v)
+	}
+
+	// the client timeout must not apply to the upgraded connection
+	time.Sleep(10 * time.Millisecond)
+
+	expected := wsFrame(true, wsOpText, []byte("hello"), nil)
+	b := make([]byte, len(expected))
+	if _, err = io.ReadFull(br, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected %v got %v", expected, b)
+	}
+
+	echo := wsFrame(true, wsOpText, []byte("echo"), []byte{1, 2, 3, 4})
+	conn.Write(echo)
+	b = make([]byte, len(echo))
+	if _, err = io.ReadFull(br, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, echo) {
+		t.Errorf("expected %v got %v", echo, b)
+	}
+}
+
+func TestWebSocketProxyDeclined(t *testing.T) {
+
+	es := tu.NewTestServer(http.StatusForbidden, "denied", nil)
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-origin-type", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	oc := conf.Origins["default"]
+	oc.HTTPClient = http.DefaultClient
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, es.URL, nil)
+	r.Header.Set(headers.NameUpgrade, "websocket")
+	r.Header.Set(headers.NameConnection, "Upgrade")
+	r = r.WithContext(tc.WithResources(r.Context(),
+		request.NewResources(oc, nil, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+	DoProxy(w, r, true)
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "denied" {
+		t.Errorf("expected %s got %s", "denied", string(b))
+	}
+}
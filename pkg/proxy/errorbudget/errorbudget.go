@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package errorbudget tracks the health of an origin's upstream responses, and stretches
+// the freshness of its cached objects while the origin exceeds its error budget
+package errorbudget
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errorbudget/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Transition describes a change in an origin's error budget state
+type Transition int
+
+const (
+	// TransitionNone indicates the origin's state did not change
+	TransitionNone Transition = iota
+	// TransitionDegraded indicates the origin exceeded its error budget
+	TransitionDegraded
+	// TransitionRecovered indicates the origin returned to within its error budget
+	TransitionRecovered
+)
+
+// Budget measures an origin's upstream error rate and latency over fixed windows. At the end
+// of each window, the origin is marked degraded if either exceeds its threshold, and restored
+// once a window is measured within both thresholds.
+type Budget struct {
+	originName string
+	originType string
+	options    *options.Options
+
+	mtx         sync.Mutex
+	windowStart time.Time
+	requests    int
+	errors      int
+	latency     time.Duration
+	degraded    bool
+	now         func() time.Time
+}
+
+// New returns a new Budget for the named origin
+func New(originName, originType string, o *options.Options) *Budget {
+	b := &Budget{
+		originName: originName,
+		originType: originType,
+		options:    o,
+		now:        time.Now,
+	}
+	b.windowStart = b.now()
+	metrics.ProxyOriginDegraded.WithLabelValues(originName, originType).Set(0)
+	return b
+}
+
+// Observe records the outcome of an upstream request, and returns any resulting change in
+// the origin's state. A statusCode of 0 indicates that the request failed without a response
+func (b *Budget) Observe(statusCode int, latency time.Duration) Transition {
+	if b == nil {
+		return TransitionNone
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	t := b.roll()
+	b.requests++
+	b.latency += latency
+	if statusCode == 0 || statusCode >= http.StatusInternalServerError {
+		b.errors++
+	}
+	return t
+}
+
+// roll evaluates and resets the window if it has elapsed. The caller must hold the lock
+func (b *Budget) roll() Transition {
+	now := b.now()
+	if now.Sub(b.windowStart) < b.options.Window {
+		return TransitionNone
+	}
+	t := TransitionNone
+	if b.requests > 0 {
+		exceeded := b.exceeded()
+		switch {
+		case exceeded && !b.degraded && b.requests >= b.options.MinRequests:
+			t = TransitionDegraded
+			b.degraded = true
+			metrics.ProxyOriginDegraded.WithLabelValues(b.originName, b.originType).Set(1)
+		case !exceeded && b.degraded:
+			t = TransitionRecovered
+			b.degraded = false
+			metrics.ProxyOriginDegraded.WithLabelValues(b.originName, b.originType).Set(0)
+		}
+	}
+	b.windowStart = now
+	b.requests = 0
+	b.errors = 0
+	b.latency = 0
+	return t
+}
+
+func (b *Budget) exceeded() bool {
+	o := b.options
+	if o.ErrorRateThreshold > 0 &&
+		float64(b.errors)/float64(b.requests) > o.ErrorRateThreshold {
+		return true
+	}
+	return o.LatencyThreshold > 0 &&
+		b.latency/time.Duration(b.requests) > o.LatencyThreshold
+}
+
+// Degraded returns true if the origin has exceeded its error budget
+func (b *Budget) Degraded() bool {
+	if b == nil {
+		return false
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.degraded
+}
+
+// Stretch returns the provided freshness lifetime, stretched within the configured
+// bounds if the origin is degraded
+func (b *Budget) Stretch(d time.Duration) time.Duration {
+	if !b.Degraded() {
+		return d
+	}
+	return b.stretch(d)
+}
+
+// Retention returns the provided cache TTL, stretched within the configured bounds,
+// regardless of the origin's state. Cached objects are retained for the stretched TTL,
+// so that they remain available to be served if the origin becomes degraded
+func (b *Budget) Retention(d time.Duration) time.Duration {
+	if b == nil {
+		return d
+	}
+	return b.stretch(d)
+}
+
+func (b *Budget) stretch(d time.Duration) time.Duration {
+	s := time.Duration(float64(d) * b.options.TTLStretchFactor)
+	if s > b.options.MaxStretchedTTL {
+		s = b.options.MaxStretchedTTL
+	}
+	if s < d {
+		return d
+	}
+	return s
+}
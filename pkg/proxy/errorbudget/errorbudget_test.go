@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package errorbudget
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errorbudget/options"
+)
+
+func testBudget(now *time.Time) *Budget {
+	o := options.NewOptions()
+	o.ErrorRateThreshold = 0.5
+	o.LatencyThresholdMS = 1000
+	o.MinRequests = 2
+	o.SetDurations()
+	b := New("test", "test", o)
+	b.now = func() time.Time { return *now }
+	b.windowStart = *now
+	return b
+}
+
+func TestBudgetErrorRate(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	b := testBudget(&now)
+
+	b.Observe(200, time.Millisecond)
+	b.Observe(0, time.Millisecond)
+	b.Observe(503, time.Millisecond)
+
+	now = now.Add(time.Minute)
+	if tr := b.Observe(200, time.Millisecond); tr != TransitionDegraded {
+		t.Errorf("expected %d got %d", TransitionDegraded, tr)
+	}
+	if !b.Degraded() {
+		t.Error("expected budget to be degraded")
+	}
+
+	if d := b.Stretch(time.Minute); d != 4*time.Minute {
+		t.Errorf("expected %s got %s", 4*time.Minute, d)
+	}
+	if d := b.Stretch(time.Hour); d != time.Hour {
+		t.Errorf("expected %s got %s", time.Hour, d)
+	}
+
+	now = now.Add(time.Minute)
+	if tr := b.Observe(200, time.Millisecond); tr != TransitionRecovered {
+		t.Errorf("expected %d got %d", TransitionRecovered, tr)
+	}
+	if b.Degraded() {
+		t.Error("expected budget to be recovered")
+	}
+	if d := b.Stretch(time.Minute); d != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, d)
+	}
+
+}
+
+func TestBudgetLatency(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	b := testBudget(&now)
+
+	b.Observe(200, 2*time.Second)
+	b.Observe(200, 2*time.Second)
+
+	now = now.Add(time.Minute)
+	if tr := b.Observe(200, time.Millisecond); tr != TransitionDegraded {
+		t.Errorf("expected %d got %d", TransitionDegraded, tr)
+	}
+
+}
+
+func TestBudgetMinRequests(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	b := testBudget(&now)
+
+	b.Observe(502, time.Millisecond)
+
+	now = now.Add(time.Minute)
+	if tr := b.Observe(200, time.Millisecond); tr != TransitionNone {
+		t.Errorf("expected %d got %d", TransitionNone, tr)
+	}
+
+}
+
+func TestBudgetNil(t *testing.T) {
+
+	var b *Budget
+	if tr := b.Observe(500, time.Second); tr != TransitionNone {
+		t.Errorf("expected %d got %d", TransitionNone, tr)
+	}
+	if b.Degraded() {
+		t.Error("expected nil budget to not be degraded")
+	}
+	if d := b.Stretch(time.Minute); d != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, d)
+	}
+	if d := b.Retention(time.Minute); d != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, d)
+	}
+
+}
+
+func TestRetention(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	b := testBudget(&now)
+	if d := b.Retention(time.Minute); d != 4*time.Minute {
+		t.Errorf("expected %s got %s", 4*time.Minute, d)
+	}
+
+}
+
+func TestOptionsValidate(t *testing.T) {
+
+	o := options.NewOptions()
+	if o.Enabled() {
+		t.Error("expected default options to be disabled")
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	o2 := o.Clone()
+	o2.ErrorRateThreshold = 2
+	if err := o2.Validate(); err == nil {
+		t.Error("expected error for invalid error rate threshold")
+	}
+
+	o2 = o.Clone()
+	o2.TTLStretchFactor = 0.5
+	if err := o2.Validate(); err == nil {
+		t.Error("expected error for invalid ttl stretch factor")
+	}
+
+	o2 = o.Clone()
+	o2.WindowSecs = 0
+	if err := o2.Validate(); err == nil {
+		t.Error("expected error for invalid window")
+	}
+
+	o2 = o.Clone()
+	o2.MinRequests = -1
+	if err := o2.Validate(); err == nil {
+		t.Error("expected error for negative min requests")
+	}
+
+}
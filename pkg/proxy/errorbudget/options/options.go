@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the error budget options for an origin
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options is a collection of error budget configurations for an origin. When the origin's
+// error rate or latency exceeds a threshold, the origin is considered degraded, and the
+// freshness of its cached objects is temporarily stretched until it recovers
+type Options struct {
+	// ErrorRateThreshold is the fraction (0.0 to 1.0) of upstream requests in a window that may
+	// fail (with a 5xx status or transport error) before the origin is considered degraded.
+	// 0 disables the error rate threshold
+	ErrorRateThreshold float64 `toml:"error_rate_threshold"`
+	// LatencyThresholdMS is the mean upstream response latency, in milliseconds, over a window
+	// above which the origin is considered degraded. 0 disables the latency threshold
+	LatencyThresholdMS int `toml:"latency_threshold_ms"`
+	// WindowSecs is the period over which the error rate and latency are measured
+	WindowSecs int `toml:"window_secs"`
+	// MinRequests is the minimum number of upstream requests in a window before the
+	// origin can be considered degraded
+	MinRequests int `toml:"min_requests"`
+	// TTLStretchFactor is the multiplier applied to cache freshness lifetimes while degraded
+	TTLStretchFactor float64 `toml:"ttl_stretch_factor"`
+	// MaxStretchedTTLSecs is the upper bound of any stretched freshness lifetime
+	MaxStretchedTTLSecs int `toml:"max_stretched_ttl_secs"`
+
+	// Window is the time.Duration representation of WindowSecs
+	Window time.Duration `toml:"-"`
+	// LatencyThreshold is the time.Duration representation of LatencyThresholdMS
+	LatencyThreshold time.Duration `toml:"-"`
+	// MaxStretchedTTL is the time.Duration representation of MaxStretchedTTLSecs
+	MaxStretchedTTL time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		WindowSecs:          d.DefaultErrorBudgetWindowSecs,
+		Window:              time.Duration(d.DefaultErrorBudgetWindowSecs) * time.Second,
+		MinRequests:         d.DefaultErrorBudgetMinRequests,
+		TTLStretchFactor:    d.DefaultErrorBudgetTTLStretchFactor,
+		MaxStretchedTTLSecs: d.DefaultErrorBudgetMaxStretchedTTLSecs,
+		MaxStretchedTTL:     time.Duration(d.DefaultErrorBudgetMaxStretchedTTLSecs) * time.Second,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		ErrorRateThreshold:  o.ErrorRateThreshold,
+		LatencyThresholdMS:  o.LatencyThresholdMS,
+		WindowSecs:          o.WindowSecs,
+		MinRequests:         o.MinRequests,
+		TTLStretchFactor:    o.TTLStretchFactor,
+		MaxStretchedTTLSecs: o.MaxStretchedTTLSecs,
+		Window:              o.Window,
+		LatencyThreshold:    o.LatencyThreshold,
+		MaxStretchedTTL:     o.MaxStretchedTTL,
+	}
+}
+
+// Enabled returns true if either error budget threshold is configured
+func (o *Options) Enabled() bool {
+	return o != nil && (o.ErrorRateThreshold > 0 || o.LatencyThresholdMS > 0)
+}
+
+// SetDurations sets the time.Duration representations of the Options' seconds-based values
+func (o *Options) SetDurations() {
+	o.Window = time.Duration(o.WindowSecs) * time.Second
+	o.LatencyThreshold = time.Duration(o.LatencyThresholdMS) * time.Millisecond
+	o.MaxStretchedTTL = time.Duration(o.MaxStretchedTTLSecs) * time.Second
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.ErrorRateThreshold < 0 || o.ErrorRateThreshold > 1 {
+		return errors.New("error budget error_rate_threshold must be between 0 and 1")
+	}
+	if o.LatencyThresholdMS < 0 || o.MinRequests < 0 || o.MaxStretchedTTLSecs < 0 {
+		return errors.New("error budget values must not be negative")
+	}
+	if o.WindowSecs <= 0 {
+		return errors.New("error budget window_secs must be greater than 0")
+	}
+	if o.TTLStretchFactor < 1 {
+		return errors.New("error budget ttl_stretch_factor must be at least 1")
+	}
+	return nil
+}
@@ -48,6 +48,10 @@ var ErrNotSelectStatement = errors.New("not a select statement")
 // ErrNotTimeRangeQuery indicates an error that the time series request does not contain a query
 var ErrNotTimeRangeQuery = errors.New("not a time range query")
 
+// ErrRelativeTimeModifier indicates an error that the time series request uses a time modifier
+// that is relative to the requested time range, so partial results cannot be merged
+var ErrRelativeTimeModifier = errors.New("time modifier is relative to the requested time range")
+
 // ErrNoRanges indicates an error that the range request does not contain any usable ranges
 var ErrNoRanges = errors.New("no usable ranges")
 
@@ -60,12 +64,24 @@ var ErrNilListener = errors.New("nil listener")
 // ErrNoSuchListener indicates an error that the provided listener name is unknown
 var ErrNoSuchListener = errors.New("no such listener")
 
+// ErrListenerClosed indicates an error that the listener has been closed
+var ErrListenerClosed = errors.New("listener closed")
+
 // ErrDrainTimeout indicates an error that the connection drain took longer than the requested timeout
 var ErrDrainTimeout = errors.New("timed out draining")
 
 // ErrPCFContentLength indicates that a response's content length does not permit PCF
 var ErrPCFContentLength = errors.New("content length does not permit PCF")
 
+// ErrObjectTooLarge indicates that an object was not cached because it exceeds a max object size
+var ErrObjectTooLarge = errors.New("object exceeds max object size")
+
+// ErrNilResources indicates that a request has no Resources attached
+var ErrNilResources = errors.New("nil request resources")
+
+// ErrNotTimeseriesClient indicates that an origin client does not support time series requests
+var ErrNotTimeseriesClient = errors.New("origin client is not a timeseries client")
+
 // MissingURLParam returns a Formatted Error
 func MissingURLParam(param string) error {
 	return fmt.Errorf("missing URL parameter: [%s]", param)
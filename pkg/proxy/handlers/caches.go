@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/metrics"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// maxCacheStatsTopN is the largest number of top objects that can be requested per cache
+const maxCacheStatsTopN = 1000
+
+// CacheStatus is the document returned by the Cache Statistics Handler for each cache
+type CacheStatus struct {
+	Name      string `json:"name"`
+	CacheType string `json:"cache_type"`
+	// Hits and Misses are the number of the cache's lookups that found and did not find
+	// the requested object since Trickster started
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// HitRatio is Hits divided by the total number of lookups
+	HitRatio float64 `json:"hit_ratio"`
+	// Index describes the cache's objects, and is omitted for caches that can't describe them
+	Index *cache.Stats `json:"index,omitempty"`
+}
+
+// CacheStatsHandleFunc responds to the HTTP request with the statistics of each cache, or of
+// the cache named by the request's cache parameter. The top parameter sets the number of the
+// largest and most-accessed objects reported for each indexed cache
+func CacheStatsHandleFunc(caches map[string]cache.Cache) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qp := r.URL.Query()
+		topN := d.DefaultCacheStatsTopN
+		if v := qp.Get("top"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > maxCacheStatsTopN {
+				http.Error(w, "invalid top value", http.StatusBadRequest)
+				return
+			}
+			topN = n
+		}
+		names := make([]string, 0, len(caches))
+		if name := qp.Get("cache"); name != "" {
+			if _, ok := caches[name]; !ok {
+				http.Error(w, "unknown cache "+name, http.StatusNotFound)
+				return
+			}
+			names = append(names, name)
+		} else {
+			for k := range caches {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+		}
+		statuses := make([]*CacheStatus, 0, len(names))
+		for _, name := range names {
+			c := caches[name]
+			cs := &CacheStatus{Name: name, CacheType: c.Configuration().CacheType}
+			cs.Hits, cs.Misses = metrics.Lookups(name)
+			if n := cs.Hits + cs.Misses; n > 0 {
+				cs.HitRatio = float64(cs.Hits) / float64(n)
+			}
+			if ci, ok := c.(cache.Inspector); ok {
+				cs.Index = ci.Inspect(topN)
+			}
+			statuses = append(statuses, cs)
+		}
+		b, err := json.Marshal(map[string][]*CacheStatus{"caches": statuses})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
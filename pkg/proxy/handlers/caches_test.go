@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/registration"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+func TestCacheStatsHandler(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-origin-url", "http://1.2.3.4", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+
+	c := caches["default"]
+	c.Store("test-stats-key", []byte("test-value"), time.Minute)
+	c.Retrieve("test-stats-key", false)
+	c.Retrieve("test-stats-missing", false)
+
+	h := CacheStatsHandleFunc(caches)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "http://0/trickster/caches?top=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	doc := map[string][]*CacheStatus{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	statuses := doc["caches"]
+	if len(statuses) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(statuses))
+	}
+	cs := statuses[0]
+	if cs.Name != "default" || cs.CacheType != "memory" {
+		t.Errorf("unexpected cache %s/%s", cs.Name, cs.CacheType)
+	}
+	if cs.Hits < 1 || cs.Misses < 1 || cs.HitRatio <= 0 || cs.HitRatio >= 1 {
+		t.Errorf("unexpected lookups %d/%d/%f", cs.Hits, cs.Misses, cs.HitRatio)
+	}
+	if cs.Index == nil || cs.Index.Objects != 1 || len(cs.Index.Largest) != 1 ||
+		cs.Index.Largest[0].Key != "test-stats-key" || len(cs.Index.Hottest) != 1 {
+		t.Errorf("unexpected index stats %v", cs.Index)
+	}
+
+	tests := []struct {
+		url      string
+		expected int
+	}{
+		{"http://0/trickster/caches?cache=default", http.StatusOK},
+		{"http://0/trickster/caches?cache=unknown", http.StatusNotFound},
+		{"http://0/trickster/caches?top=-1", http.StatusBadRequest},
+		{"http://0/trickster/caches?top=x", http.StatusBadRequest},
+	}
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", test.url, nil))
+		if w.Code != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, w.Code)
+		}
+	}
+
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+)
+
+// ClusterStatus is the document returned by the Cluster Status Handler
+type ClusterStatus struct {
+	// Cluster describes the peers and their shares of the cache key space, and is omitted
+	// when clustering is not enabled
+	Cluster *cluster.Status `json:"cluster,omitempty"`
+	// Replication describes the replication of each replicated origin to its peers
+	Replication []*replication.Status `json:"replication"`
+}
+
+// ClusterHandleFunc responds to the HTTP request with the status of the cluster peers
+// and of each origin's replication
+func ClusterHandleFunc(conf *config.Config) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cs := &ClusterStatus{Replication: make([]*replication.Status, 0)}
+		if conf.Resources != nil && conf.Resources.Cluster != nil {
+			cs.Cluster = conf.Resources.Cluster.Status()
+		}
+		for _, o := range conf.Origins {
+			if o.Replicator != nil {
+				cs.Replication = append(cs.Replication, o.Replicator.Status())
+			}
+		}
+		sort.Slice(cs.Replication, func(i, j int) bool {
+			return cs.Replication[i].Origin < cs.Replication[j].Origin
+		})
+		b, err := json.Marshal(cs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cluster"
+	co "github.com/tricksterproxy/trickster/pkg/cluster/options"
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+	ro "github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
+)
+
+func TestClusterHandler(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-origin-type", "prometheus", "-origin-url", "http://0/"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	h := ClusterHandleFunc(conf)
+
+	// without clustering or replication, the status is empty
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "http://0/trickster/cluster", nil))
+	if w.Code != 200 {
+		t.Errorf("expected 200 got %d.", w.Code)
+	}
+	if s := w.Body.String(); s != `{"replication":[]}` {
+		t.Errorf("unexpected status %s", s)
+	}
+
+	o := co.NewOptions()
+	o.Self = "http://trickster-0:8480"
+	o.Peers = []string{"http://trickster-0:8480", "http://trickster-1:8480"}
+	conf.Resources.Cluster = cluster.New(o, "/trickster/cluster/gossip")
+	rpo := &ro.Options{Peers: []string{"http://trickster-west:8480"}, MaxPending: 10}
+	conf.Origins["default"].Replicator = replication.New("default", "prometheus", "",
+		"/trickster/replication/default", rpo)
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "http://0/trickster/cluster", nil))
+	cs := &ClusterStatus{}
+	if err = json.NewDecoder(w.Body).Decode(cs); err != nil {
+		t.Fatal(err)
+	}
+	if cs.Cluster == nil || cs.Cluster.Self != "http://trickster-0:8480" || len(cs.Cluster.Peers) != 2 {
+		t.Errorf("unexpected cluster status %+v", cs.Cluster)
+	}
+	if len(cs.Replication) != 1 || cs.Replication[0].Origin != "default" ||
+		len(cs.Replication[0].Peers) != 1 {
+		t.Errorf("unexpected replication status %+v", cs.Replication)
+	}
+
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// CompactionResult is the document returned by the Cache Compaction Handler for each cache
+type CompactionResult struct {
+	Name string `json:"name"`
+	// BytesBefore and BytesAfter are the size of the cache's storage before and after compaction
+	BytesBefore int64  `json:"bytes_before"`
+	BytesAfter  int64  `json:"bytes_after"`
+	Error       string `json:"error,omitempty"`
+}
+
+// CompactHandleFunc compacts the storage of each cache that supports compaction, or of the
+// cache named by the request's cache parameter, when the request is POSTed, and responds
+// with the size of each compacted cache before and after compaction
+func CompactHandleFunc(caches map[string]cache.Cache) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		names := make([]string, 0, len(caches))
+		if name := r.URL.Query().Get("cache"); name != "" {
+			c, ok := caches[name]
+			if !ok {
+				http.Error(w, "unknown cache "+name, http.StatusNotFound)
+				return
+			}
+			if _, ok = c.(cache.Compactor); !ok {
+				http.Error(w, "cache "+name+" does not support compaction", http.StatusBadRequest)
+				return
+			}
+			names = append(names, name)
+		} else {
+			for k, c := range caches {
+				if _, ok := c.(cache.Compactor); ok {
+					names = append(names, k)
+				}
+			}
+			sort.Strings(names)
+		}
+		code := http.StatusOK
+		results := make([]*CompactionResult, 0, len(names))
+		for _, name := range names {
+			cr := &CompactionResult{Name: name}
+			var err error
+			cr.BytesBefore, cr.BytesAfter, err = caches[name].(cache.Compactor).Compact()
+			if err != nil {
+				cr.Error = err.Error()
+				code = http.StatusInternalServerError
+			}
+			results = append(results, cr)
+		}
+		b, err := json.Marshal(map[string][]*CompactionResult{"caches": results})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(code)
+		w.Write(b)
+	}
+}
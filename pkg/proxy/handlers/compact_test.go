@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+)
+
+type testCompactor struct {
+	cache.Cache
+	err error
+}
+
+func (c *testCompactor) Compact() (int64, int64, error) {
+	if c.err != nil {
+		return 100, 100, c.err
+	}
+	return 100, 10, nil
+}
+
+func TestCompactHandler(t *testing.T) {
+
+	caches := map[string]cache.Cache{
+		"b": &testCompactor{},
+		"a": &testCompactor{},
+		"c": &testCompactor{err: errors.New("test error")},
+		"m": &testCacheWithoutCompaction{},
+	}
+	h := CompactHandleFunc(caches)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "http://0/trickster/compact?cache=a", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	expected := `{"caches":[{"name":"a","bytes_before":100,"bytes_after":10}]}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// every cache that supports compaction is compacted, and failures are reported
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "http://0/trickster/compact", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, w.Code)
+	}
+	doc := map[string][]*CompactionResult{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	results := doc["caches"]
+	if len(results) != 3 || results[0].Name != "a" || results[1].Name != "b" ||
+		results[2].Error != "test error" {
+		t.Errorf("unexpected results %s", w.Body.String())
+	}
+
+	tests := []struct {
+		method, uri string
+		code        int
+	}{
+		{http.MethodGet, "http://0/trickster/compact", http.StatusMethodNotAllowed},
+		{http.MethodPost, "http://0/trickster/compact?cache=x", http.StatusNotFound},
+		{http.MethodPost, "http://0/trickster/compact?cache=m", http.StatusBadRequest},
+	}
+	for i, test := range tests {
+		w = httptest.NewRecorder()
+		h(w, httptest.NewRequest(test.method, test.uri, nil))
+		if w.Code != test.code {
+			t.Errorf("test %d expected %d got %d", i, test.code, w.Code)
+		}
+	}
+}
+
+type testCacheWithoutCompaction struct {
+	cache.Cache
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
+	ffo "github.com/tricksterproxy/trickster/pkg/featureflags/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+
+	"github.com/BurntSushi/toml"
+)
+
+// maxFlagsBytes is the largest feature flags update body that is accepted
+const maxFlagsBytes = 1 << 16
+
+// flagsDocument is the TOML document of feature flags that is listed and POSTed
+type flagsDocument struct {
+	FeatureFlags map[string]*ffo.Options `toml:"feature_flags"`
+}
+
+// FeatureFlagsHandleFunc responds to a GET with the running feature flags as TOML, and applies
+// the feature flags POSTed to it as TOML, replacing any running flags of the same names.
+// Updates take effect immediately, and last until the next configuration reload
+func FeatureFlagsHandleFunc(conf *config.Config,
+	log *tl.Logger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		switch r.Method {
+		case http.MethodGet:
+			buf := &bytes.Buffer{}
+			if err := toml.NewEncoder(buf).Encode(flagsDocument{FeatureFlags: featureflags.Flags()}); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(buf.Bytes())
+			return
+		case http.MethodPost:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if conf == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("feature flags NOT updated: no running configuration"))
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxFlagsBytes+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("feature flags NOT updated: " + err.Error()))
+			return
+		}
+		if len(body) > maxFlagsBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		doc := &flagsDocument{}
+		md, err := toml.Decode(string(body), doc)
+		if err == nil {
+			err = ffo.ProcessFeatureFlagOptions(doc.FeatureFlags, &md, "feature_flags")
+		}
+		if err == nil {
+			err = conf.ValidateFeatureFlags(doc.FeatureFlags)
+		}
+		if err != nil {
+			log.Warn("feature flags update failed", tl.Pairs{"detail": err.Error()})
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("feature flags NOT updated: " + err.Error()))
+			return
+		}
+
+		featureflags.Update(doc.FeatureFlags)
+		for k, v := range doc.FeatureFlags {
+			log.Info("feature flag updated", tl.Pairs{"flag": k, "enabled": v.Enabled,
+				"percentage": v.Percentage, "origins": v.Origins})
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("feature flags updated"))
+	}
+}
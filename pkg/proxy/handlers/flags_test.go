@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const testFeatureFlags = `
+[feature_flags]
+  [feature_flags.fast_json_decode]
+  percentage = 10
+  origins = ['test']
+`
+
+func TestFeatureFlagsHandleFunc(t *testing.T) {
+
+	defer featureflags.Apply(nil)
+
+	cfg, _, _ := config.Load("testing", "testing",
+		[]string{"-config", "../../../testdata/test.empty.conf"})
+	log := tl.ConsoleLogger("error")
+	f := FeatureFlagsHandleFunc(cfg, log)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/", nil)
+	f(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader("[feature_flags.unknown]\n"))
+	f(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader("[feature_flags.fast_forward]\norigins = ['missing']\n"))
+	f(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	if len(featureflags.Flags()) != 0 {
+		t.Error("expected invalid feature flags not to be applied")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(strings.Repeat(" ", maxFlagsBytes+1)))
+	f(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testFeatureFlags))
+	f(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	fo, ok := featureflags.Flags()[featureflags.FastJSONDecode]
+	if !ok {
+		t.Fatal("expected feature flag to be applied")
+	}
+	if !fo.Enabled || fo.Percentage != 10 {
+		t.Errorf("unexpected feature flag %v", fo)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	f(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "[feature_flags.fast_json_decode]") ||
+		!strings.Contains(w.Body.String(), "percentage = 10") {
+		t.Errorf("unexpected feature flags listing %s", w.Body.String())
+	}
+
+	f = FeatureFlagsHandleFunc(nil, log)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testFeatureFlags))
+	f(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+}
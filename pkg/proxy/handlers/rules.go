@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/config/reload"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// maxRulesBytes is the largest runtime rules update body that is accepted
+const maxRulesBytes = 1 << 20
+
+// RulesUpdateHandleFunc validates the rules, request rewriters and origin paths POSTed to it as
+// TOML, and swaps them into the live router over the running configuration, without a full
+// configuration reload. Each update builds on the last, until the next configuration reload
+func RulesUpdateHandleFunc(f reload.UpdaterFunc, conf *config.Config,
+	log *tl.Logger) func(http.ResponseWriter, *http.Request) {
+	current := conf
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if conf == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("rules NOT updated: no running configuration"))
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRulesBytes+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("rules NOT updated: " + err.Error()))
+			return
+		}
+		if len(body) > maxRulesBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		conf.Main.ReloaderLock.Lock()
+		defer conf.Main.ReloaderLock.Unlock()
+
+		// a configuration reloaded since this handler was registered must not be swapped out
+		if conf.Resources != nil && conf.Resources.BackgroundQuitChan != nil {
+			select {
+			case <-conf.Resources.BackgroundQuitChan:
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte("rules NOT updated: the configuration has been reloaded"))
+				return
+			default:
+			}
+		}
+
+		nc, err := current.ApplyRuntimeRules(string(body))
+		if err == nil {
+			err = f(nc)
+		}
+		if err != nil {
+			log.Warn("runtime rules update failed", tl.Pairs{"detail": err.Error()})
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("rules NOT updated: " + err.Error()))
+			return
+		}
+		current = nc
+		log.Info("runtime rules update applied", tl.Pairs{"source": "rulesEndpoint"})
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("rules updated"))
+	}
+}
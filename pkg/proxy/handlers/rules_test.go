@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+const testRulesPaths = `
+[origins.test.paths]
+  [origins.test.paths.root]
+  path = '/'
+  match_type = 'prefix'
+  handler = 'proxycache'
+`
+
+func TestRulesUpdateHandleFunc(t *testing.T) {
+
+	cfg, _, _ := config.Load("testing", "testing",
+		[]string{"-config", "../../../testdata/test.empty.conf"})
+	log := tl.ConsoleLogger("error")
+
+	var updated *config.Config
+	var updateErr error
+	f := RulesUpdateHandleFunc(func(nc *config.Config) error {
+		if updateErr != nil {
+			return updateErr
+		}
+		updated = nc
+		return nil
+	}, cfg, log)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	f(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("[frontend]"))
+	f(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	if updated != nil {
+		t.Error("expected invalid rules not to be applied")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/",
+		strings.NewReader(strings.Repeat(" ", maxRulesBytes+1)))
+	f(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	updateErr = errors.New("test error")
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testRulesPaths))
+	f(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "test error") {
+		t.Errorf("expected update error in response, got %s", w.Body.String())
+	}
+
+	updateErr = nil
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testRulesPaths))
+	f(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if updated == nil {
+		t.Fatal("expected rules to be applied")
+	}
+	if _, ok := updated.Origins["test"].Paths["/-GET-HEAD"]; !ok {
+		t.Error("expected updated path")
+	}
+
+	// each update builds on the last
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("[rules]\n"))
+	f(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if _, ok := updated.Origins["test"].Paths["/-GET-HEAD"]; !ok {
+		t.Error("expected path to be carried over")
+	}
+
+	close(cfg.Resources.BackgroundQuitChan)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testRulesPaths))
+	f(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected %d got %d", http.StatusConflict, w.Code)
+	}
+
+	f = RulesUpdateHandleFunc(nil, nil, log)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testRulesPaths))
+	f(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Filter is a compiled set of header names that are allowed or denied
+// when forwarding a client request to an upstream origin
+type Filter struct {
+	allow         map[string]bool
+	allowPrefixes []string
+	deny          map[string]bool
+	denyPrefixes  []string
+}
+
+// NewFilter returns a Filter for the provided allow and deny lists. A name
+// ending in '*' matches any header name that begins with the preceding text.
+// When the allow list is empty, all headers not denied are forwarded.
+func NewFilter(allow, deny []string) *Filter {
+	f := &Filter{}
+	f.allow, f.allowPrefixes = compileFilterNames(allow)
+	f.deny, f.denyPrefixes = compileFilterNames(deny)
+	return f
+}
+
+func compileFilterNames(names []string) (map[string]bool, []string) {
+	m := make(map[string]bool)
+	prefixes := make([]string, 0)
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		if strings.HasSuffix(n, "*") {
+			prefixes = append(prefixes, strings.ToLower(strings.TrimSuffix(n, "*")))
+			continue
+		}
+		m[http.CanonicalHeaderKey(n)] = true
+	}
+	return m, prefixes
+}
+
+func matchFilterName(name string, m map[string]bool, prefixes []string) bool {
+	if _, ok := m[name]; ok {
+		return true
+	}
+	if len(prefixes) > 0 {
+		ln := strings.ToLower(name)
+		for _, p := range prefixes {
+			if strings.HasPrefix(ln, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsEmpty returns true if the Filter has no allow or deny entries
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.allow) == 0 && len(f.allowPrefixes) == 0 &&
+		len(f.deny) == 0 && len(f.denyPrefixes) == 0)
+}
+
+// Allowed returns true if the header name may be forwarded upstream. The Trickster hop
+// header is always allowed, so that proxy loops between Tricksters can be detected
+func (f *Filter) Allowed(name string) bool {
+	if f == nil {
+		return true
+	}
+	name = http.CanonicalHeaderKey(name)
+	if name == NameTricksterHops {
+		return true
+	}
+	if (len(f.allow) > 0 || len(f.allowPrefixes) > 0) &&
+		!matchFilterName(name, f.allow, f.allowPrefixes) {
+		return false
+	}
+	return !matchFilterName(name, f.deny, f.denyPrefixes)
+}
+
+// Apply removes any headers from h that are not permitted by the Filter
+func (f *Filter) Apply(h http.Header) {
+	if h == nil || f.IsEmpty() {
+		return
+	}
+	for k := range h {
+		if !f.Allowed(k) {
+			delete(h, k)
+		}
+	}
+}
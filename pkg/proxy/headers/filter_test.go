@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFilterApply(t *testing.T) {
+
+	h := http.Header{
+		"Cookie":          {"session=1"},
+		"Authorization":   {"Bearer x"},
+		"X-Internal-User": {"alice"},
+		"Accept":          {"*/*"},
+		"Accept-Encoding": {"gzip"},
+	}
+
+	f := NewFilter(nil, []string{"cookie", "x-internal-*"})
+	f.Apply(h)
+
+	if _, ok := h["Cookie"]; ok {
+		t.Error("expected Cookie to be removed")
+	}
+	if _, ok := h["X-Internal-User"]; ok {
+		t.Error("expected X-Internal-User to be removed")
+	}
+	if len(h) != 3 {
+		t.Errorf("expected %d got %d", 3, len(h))
+	}
+
+	f = NewFilter([]string{"Accept*", "Authorization"}, []string{"Authorization"})
+	f.Apply(h)
+
+	if len(h) != 2 {
+		t.Errorf("expected %d got %d", 2, len(h))
+	}
+	if _, ok := h["Accept-Encoding"]; !ok {
+		t.Error("expected Accept-Encoding to be retained")
+	}
+
+	// the hop header is forwarded regardless of the lists
+	h.Set(NameTricksterHops, "trickster-edge")
+	f.Apply(h)
+	if _, ok := h[NameTricksterHops]; !ok {
+		t.Error("expected X-Trickster-Hops to be retained")
+	}
+
+}
+
+func TestFilterIsEmpty(t *testing.T) {
+
+	var f *Filter
+	if !f.IsEmpty() {
+		t.Error("expected true")
+	}
+	if !f.Allowed("Cookie") {
+		t.Error("expected true")
+	}
+	f.Apply(http.Header{})
+
+	f = NewFilter([]string{""}, nil)
+	if !f.IsEmpty() {
+		t.Error("expected true")
+	}
+
+	f = NewFilter(nil, []string{"Cookie"})
+	if f.IsEmpty() {
+		t.Error("expected false")
+	}
+
+}
@@ -32,6 +32,10 @@ const (
 
 	// ValueApplicationJSON represents the HTTP Header Value of "application/json"
 	ValueApplicationJSON = "application/json"
+	// ValueApplicationMsgPack represents the HTTP Header Value of "application/msgpack"
+	ValueApplicationMsgPack = "application/msgpack"
+	// ValueGzip represents the HTTP Header Value of "gzip"
+	ValueGzip = "gzip"
 	// ValueMaxAge represents the HTTP Header Value of "max-age"
 	ValueMaxAge = "max-age"
 	// ValueMultipartFormData represents the HTTP Header Value of "multipart/form-data"
@@ -80,6 +84,30 @@ const (
 	NameContentRange = "Content-Range"
 	// NameTricksterResult represents the HTTP Header Name of "X-Trickster-Result"
 	NameTricksterResult = "X-Trickster-Result"
+	// NameTricksterQueryRewrite represents the HTTP Header Name of "X-Trickster-Query-Rewrite"
+	NameTricksterQueryRewrite = "X-Trickster-Query-Rewrite"
+	// NameTricksterExplain represents the HTTP Header Name of "X-Trickster-Explain"
+	NameTricksterExplain = "X-Trickster-Explain"
+	// NameTricksterErrorID represents the HTTP Header Name of "X-Trickster-Error-Id"
+	NameTricksterErrorID = "X-Trickster-Error-Id"
+	// NameTricksterPeer represents the HTTP Header Name of "X-Trickster-Peer"
+	NameTricksterPeer = "X-Trickster-Peer"
+	// NameTricksterHops represents the HTTP Header Name of "X-Trickster-Hops"
+	NameTricksterHops = "X-Trickster-Hops"
+	// NameTricksterUpstreamResult represents the HTTP Header Name of "X-Trickster-Upstream-Result"
+	NameTricksterUpstreamResult = "X-Trickster-Upstream-Result"
+	// NameTricksterReplicationKey represents the HTTP Header Name of "X-Trickster-Replication-Key"
+	NameTricksterReplicationKey = "X-Trickster-Replication-Key"
+	// NameTricksterReplicationTTL represents the HTTP Header Name of "X-Trickster-Replication-Ttl"
+	NameTricksterReplicationTTL = "X-Trickster-Replication-Ttl"
+	// NameTricksterReplicationSecret represents the HTTP Header Name of "X-Trickster-Replication-Secret"
+	NameTricksterReplicationSecret = "X-Trickster-Replication-Secret"
+	// NameTricksterTimestamp represents the HTTP Header Name of "X-Trickster-Timestamp"
+	NameTricksterTimestamp = "X-Trickster-Timestamp"
+	// NameTricksterSignature represents the HTTP Header Name of "X-Trickster-Signature"
+	NameTricksterSignature = "X-Trickster-Signature"
+	// NameAccept represents the HTTP Header Name of "Accept"
+	NameAccept = "Accept"
 	// NameAcceptEncoding represents the HTTP Header Name of "Accept-Encoding"
 	NameAcceptEncoding = "Accept-Encoding"
 	// NameSetCookie represents the HTTP Header Name of "Set-Cookie"
@@ -122,6 +150,12 @@ const (
 	NameTrailer = "Trailer"
 	// NameUpgrade represents the HTTP Header Name of "Upgrade"
 	NameUpgrade = "Upgrade"
+	// NameVary represents the HTTP Header Name of "Vary"
+	NameVary = "Vary"
+	// NameSurrogateKey represents the HTTP Header Name of "Surrogate-Key"
+	NameSurrogateKey = "Surrogate-Key"
+	// NameCacheTag represents the HTTP Header Name of "Cache-Tag"
+	NameCacheTag = "Cache-Tag"
 )
 
 // Merge merges the source http.Header map into destination map.
@@ -199,6 +233,32 @@ func ExtractHeader(headers http.Header, header string) (string, bool) {
 	return "", false
 }
 
+// AcceptsEncoding returns true if the Accept-Encoding header values permit the provided
+// content coding, either by name or by wildcard, with a non-zero quality value
+func AcceptsEncoding(h http.Header, coding string) bool {
+	var wildcard bool
+	for _, v := range h[NameAcceptEncoding] {
+		for _, part := range strings.Split(v, ",") {
+			name := part
+			var q string
+			if i := strings.Index(part, ";"); i >= 0 {
+				name = part[:i]
+				q = strings.TrimSpace(part[i+1:])
+			}
+			// q=0 means the coding is explicitly not acceptable
+			accepted := !strings.HasPrefix(q, "q=") || strings.Trim(q[2:], "0.") != ""
+			name = strings.TrimSpace(name)
+			if strings.EqualFold(name, coding) {
+				return accepted
+			}
+			if name == "*" {
+				wildcard = accepted
+			}
+		}
+	}
+	return wildcard
+}
+
 // String returns the string representation of the headers as if
 // they were transmitted over the wire (Header1: value1\nHeader2: value2\n\n)
 func String(h http.Header) string {
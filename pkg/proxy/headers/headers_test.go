@@ -189,3 +189,33 @@ func TestLogString(t *testing.T) {
 	}
 
 }
+
+func TestAcceptsEncoding(t *testing.T) {
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP;q=0.5", true},
+		{"br, deflate", false},
+		{"gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"*", true},
+		{"*;q=0, gzip", true},
+		{"gzip;q=0, *", false},
+		{"br, *;q=0", false},
+	}
+
+	for _, test := range tests {
+		h := http.Header{}
+		if test.value != "" {
+			h.Set(NameAcceptEncoding, test.value)
+		}
+		if v := AcceptsEncoding(h, ValueGzip); v != test.expected {
+			t.Errorf("%s: expected %t got %t", test.value, test.expected, v)
+		}
+	}
+
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides named profiles of security-related response
+// headers that can be attached to the frontend listeners or to an origin
+package options
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/util/strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrInvalidHSTSMaxAge is returned when the HSTS Max Age is a negative value
+var ErrInvalidHSTSMaxAge = errors.New("invalid hsts_max_age_secs")
+
+// Options is a named profile of security headers to inject into responses
+type Options struct {
+	// Name is the Name of the profile, taken from the Key in the security_headers map
+	Name string `toml:"-"`
+	// HSTSMaxAgeSecs sets the max-age of the Strict-Transport-Security header; 0 omits the header
+	HSTSMaxAgeSecs int `toml:"hsts_max_age_secs"`
+	// HSTSIncludeSubdomains adds the includeSubDomains directive to the Strict-Transport-Security header
+	HSTSIncludeSubdomains bool `toml:"hsts_include_subdomains"`
+	// HSTSPreload adds the preload directive to the Strict-Transport-Security header
+	HSTSPreload bool `toml:"hsts_preload"`
+	// ContentTypeNoSniff sets the X-Content-Type-Options header to 'nosniff'
+	ContentTypeNoSniff bool `toml:"content_type_nosniff"`
+	// FrameOptions provides the value of the X-Frame-Options header
+	FrameOptions string `toml:"frame_options"`
+	// ContentSecurityPolicy provides the value of the Content-Security-Policy header
+	ContentSecurityPolicy string `toml:"content_security_policy"`
+	// ReferrerPolicy provides the value of the Referrer-Policy header
+	ReferrerPolicy string `toml:"referrer_policy"`
+	// Headers provides any additional headers to set on the response
+	Headers map[string]string `toml:"headers"`
+	// Override, when true, replaces any same-named headers already set on the response
+	// (e.g., by the origin); otherwise, only missing headers are added
+	Override bool `toml:"override"`
+
+	// ResponseHeaders is the compiled set of headers represented by the profile
+	ResponseHeaders http.Header `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default values
+func NewOptions() *Options {
+	return &Options{
+		ContentTypeNoSniff: defaults.DefaultSecurityHeadersContentTypeNoSniff,
+		ReferrerPolicy:     defaults.DefaultSecurityHeadersReferrerPolicy,
+		Headers:            make(map[string]string),
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	var h http.Header
+	if o.ResponseHeaders != nil {
+		h = o.ResponseHeaders.Clone()
+	}
+	return &Options{
+		Name:                  o.Name,
+		HSTSMaxAgeSecs:        o.HSTSMaxAgeSecs,
+		HSTSIncludeSubdomains: o.HSTSIncludeSubdomains,
+		HSTSPreload:           o.HSTSPreload,
+		ContentTypeNoSniff:    o.ContentTypeNoSniff,
+		FrameOptions:          o.FrameOptions,
+		ContentSecurityPolicy: o.ContentSecurityPolicy,
+		ReferrerPolicy:        o.ReferrerPolicy,
+		Headers:               strings.CloneMap(o.Headers),
+		Override:              o.Override,
+		ResponseHeaders:       h,
+	}
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.HSTSMaxAgeSecs < 0 {
+		return ErrInvalidHSTSMaxAge
+	}
+	return nil
+}
+
+// ProcessSecurityHeadersOptions applies defaults to, validates and compiles
+// the response headers of the provided security header profiles
+func ProcessSecurityHeadersOptions(mo map[string]*Options, metadata *toml.MetaData) error {
+	for k, v := range mo {
+		if v == nil {
+			v = NewOptions()
+			mo[k] = v
+		} else if metadata != nil {
+			if !metadata.IsDefined("security_headers", k, "content_type_nosniff") {
+				v.ContentTypeNoSniff = defaults.DefaultSecurityHeadersContentTypeNoSniff
+			}
+			if !metadata.IsDefined("security_headers", k, "referrer_policy") {
+				v.ReferrerPolicy = defaults.DefaultSecurityHeadersReferrerPolicy
+			}
+		}
+		v.Name = k
+		if err := v.Validate(); err != nil {
+			return err
+		}
+		v.compile()
+	}
+	return nil
+}
+
+func (o *Options) compile() {
+	h := make(http.Header)
+	if o.HSTSMaxAgeSecs > 0 {
+		s := "max-age=" + strconv.Itoa(o.HSTSMaxAgeSecs)
+		if o.HSTSIncludeSubdomains {
+			s += "; includeSubDomains"
+		}
+		if o.HSTSPreload {
+			s += "; preload"
+		}
+		h.Set("Strict-Transport-Security", s)
+	}
+	if o.ContentTypeNoSniff {
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+	if o.FrameOptions != "" {
+		h.Set("X-Frame-Options", o.FrameOptions)
+	}
+	if o.ContentSecurityPolicy != "" {
+		h.Set("Content-Security-Policy", o.ContentSecurityPolicy)
+	}
+	if o.ReferrerPolicy != "" {
+		h.Set("Referrer-Policy", o.ReferrerPolicy)
+	}
+	for k, v := range o.Headers {
+		h.Set(k, v)
+	}
+	o.ResponseHeaders = h
+}
+
+// Apply sets the profile's headers in the provided header map
+func (o *Options) Apply(h http.Header) {
+	if o == nil || h == nil {
+		return
+	}
+	for k, v := range o.ResponseHeaders {
+		if _, ok := h[k]; ok && !o.Override {
+			continue
+		}
+		h[k] = v
+	}
+}
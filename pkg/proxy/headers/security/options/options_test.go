@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+const testProfile = `
+[security_headers]
+  [security_headers.test]
+  hsts_max_age_secs = 600
+  hsts_preload = true
+  frame_options = 'DENY'
+  override = true
+    [security_headers.test.headers]
+    'X-Test' = 'trickster'
+  [security_headers.test2]
+  content_type_nosniff = false
+  referrer_policy = ''
+`
+
+type testConfig struct {
+	SecurityHeaders map[string]*Options `toml:"security_headers"`
+}
+
+func TestProcessSecurityHeadersOptions(t *testing.T) {
+
+	c := &testConfig{}
+	md, err := toml.Decode(testProfile, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ProcessSecurityHeadersOptions(c.SecurityHeaders, &md)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o := c.SecurityHeaders["test"]
+	if o.Name != "test" {
+		t.Errorf("expected %s got %s", "test", o.Name)
+	}
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=600; preload",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Test":                    "trickster",
+	}
+	if len(o.ResponseHeaders) != len(expected) {
+		t.Errorf("expected %d got %d", len(expected), len(o.ResponseHeaders))
+	}
+	for k, v := range expected {
+		if o.ResponseHeaders.Get(k) != v {
+			t.Errorf("expected %s got %s", v, o.ResponseHeaders.Get(k))
+		}
+	}
+
+	if len(c.SecurityHeaders["test2"].ResponseHeaders) != 0 {
+		t.Errorf("expected %d got %d", 0, len(c.SecurityHeaders["test2"].ResponseHeaders))
+	}
+
+	c.SecurityHeaders["test"].HSTSMaxAgeSecs = -1
+	err = ProcessSecurityHeadersOptions(c.SecurityHeaders, &md)
+	if err != ErrInvalidHSTSMaxAge {
+		t.Errorf("expected %v got %v", ErrInvalidHSTSMaxAge, err)
+	}
+
+}
+
+func TestApply(t *testing.T) {
+
+	o := NewOptions()
+	o.compile()
+
+	h := http.Header{"Referrer-Policy": {"origin"}}
+	o.Apply(h)
+	if h.Get("Referrer-Policy") != "origin" {
+		t.Errorf("expected %s got %s", "origin", h.Get("Referrer-Policy"))
+	}
+	if h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("expected %s got %s", "nosniff", h.Get("X-Content-Type-Options"))
+	}
+
+	o.Override = true
+	o.Apply(h)
+	if h.Get("Referrer-Policy") != "strict-origin-when-cross-origin" {
+		t.Errorf("expected %s got %s", "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	}
+
+	var o2 *Options
+	o2.Apply(h)
+
+}
+
+func TestClone(t *testing.T) {
+
+	o := NewOptions()
+	o.Headers["X-Test"] = "1"
+	o.compile()
+
+	o2 := o.Clone()
+	o2.Headers["X-Test"] = "2"
+	o2.ResponseHeaders.Set("X-Test", "2")
+
+	if o.Headers["X-Test"] != "1" || o.ResponseHeaders.Get("X-Test") != "1" {
+		t.Error("expected clone to be independent")
+	}
+
+}
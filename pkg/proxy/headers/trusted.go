@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ForwardedPolicyAppend trusts any inbound forwarding headers, and appends
+	// Trickster's hop to them when proxying upstream
+	ForwardedPolicyAppend = "append"
+	// ForwardedPolicySanitize only trusts inbound forwarding headers when received
+	// from a trusted proxy, and strips them otherwise
+	ForwardedPolicySanitize = "sanitize"
+	// ForwardedPolicyReplace always strips inbound forwarding headers, so that
+	// the forwarding chain starts with the connected client
+	ForwardedPolicyReplace = "replace"
+)
+
+var forwardedPolicies = map[string]bool{
+	ForwardedPolicyAppend:   true,
+	ForwardedPolicySanitize: true,
+	ForwardedPolicyReplace:  true,
+}
+
+// IsValidForwardedPolicy returns true if the input is a valid Forwarded Headers Policy name
+func IsValidForwardedPolicy(input string) bool {
+	return forwardedPolicies[input]
+}
+
+// TrustedProxies is a list of networks from which inbound forwarding headers are trusted
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies returns a TrustedProxies list from the provided IP Addresses and CIDRs
+func ParseTrustedProxies(input []string) (TrustedProxies, error) {
+	tp := make(TrustedProxies, 0, len(input))
+	for _, s := range input {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %s", s)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy address: %s", s)
+		}
+		tp = append(tp, n)
+	}
+	return tp, nil
+}
+
+// Contains returns true if the provided address (with or without a port) is a trusted proxy
+func (tp TrustedProxies) Contains(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(normalizeAddress(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyForwardedPolicy applies the Forwarded Headers Policy to the inbound request's forwarding
+// headers, and returns the address of the originating client. When the policy is sanitize and the
+// request was received from a trusted proxy, the client is the nearest untrusted hop in the
+// forwarding chain, and any hops preceding it are removed, since they may have been spoofed.
+// Otherwise, the client is the connected peer, since the append policy passes the forwarding
+// chain upstream without verifying it
+func ApplyForwardedPolicy(r *http.Request, policy string, tp TrustedProxies) string {
+	if r == nil {
+		return ""
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if r.Header == nil {
+		return client
+	}
+	switch policy {
+	case ForwardedPolicyReplace:
+		StripForwardingHeaders(r.Header)
+	case ForwardedPolicySanitize:
+		if !tp.Contains(client) {
+			StripForwardingHeaders(r.Header)
+			return client
+		}
+		hops := HopsFromHeader(r.Header)
+		i := len(hops) - 1
+		for ; i >= 0; i-- {
+			client = hops[i].RemoteAddr
+			if !tp.Contains(client) {
+				break
+			}
+		}
+		if i < 0 {
+			i = 0
+		}
+		if len(hops) > 0 {
+			trimHops(r.Header, hops[i:])
+		}
+	}
+	return client
+}
+
+// trimHops rewrites the forwarding chain in the header to include only the provided hops.
+// Both the Forwarded and X-Forwarded-* headers are rewritten, so that neither can carry
+// hops that were not verified. When the hops were parsed from a Forwarded header, the
+// X-Forwarded-* headers are rebuilt from them, since the client may have sent its own
+func trimHops(h http.Header, hops Hops) {
+	addrs := make([]string, len(hops))
+	for i, hop := range hops {
+		addrs[i] = hop.RemoteAddr
+	}
+	h.Set(NameXForwardedFor, strings.Join(addrs, ", "))
+	if _, ok := h[NameForwarded]; !ok {
+		return
+	}
+	parts := make([]string, len(hops))
+	for i, hop := range hops {
+		// parsed hops carry the Forwarded proto value as the Protocol
+		h2 := *hop
+		if h2.Scheme == "" {
+			h2.Scheme = h2.Protocol
+		}
+		parts[i] = h2.String(false)
+	}
+	h.Set(NameForwarded, strings.Join(parts, ", "))
+	// the nearest proxy's hop describes the originating client's request
+	for k, v := range map[string]string{NameXForwardedProto: hops[0].Protocol,
+		NameXForwardedHost: hops[0].Host, NameXForwardedServer: hops[0].Server} {
+		if v == "" {
+			h.Del(k)
+			continue
+		}
+		h.Set(k, v)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsValidForwardedPolicy(t *testing.T) {
+
+	if IsValidForwardedPolicy("fail") {
+		t.Error("expected false")
+	}
+
+	if !IsValidForwardedPolicy(ForwardedPolicySanitize) {
+		t.Error("expected true")
+	}
+
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+
+	tp, err := ParseTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"10.1.2.3:8480", true},
+		{"127.0.0.1", true},
+		{"127.0.0.2", false},
+		{"[::1]:8480", true},
+		{`["::1"]`, true},
+		{"trickster", false},
+	}
+
+	for i, test := range tests {
+		if v := tp.Contains(test.addr); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/99"})
+	if err == nil {
+		t.Error("expected error for invalid cidr")
+	}
+
+	_, err = ParseTrustedProxies([]string{"trickster"})
+	if err == nil {
+		t.Error("expected error for invalid address")
+	}
+
+}
+
+func TestApplyForwardedPolicy(t *testing.T) {
+
+	if s := ApplyForwardedPolicy(nil, ForwardedPolicyAppend, nil); s != "" {
+		t.Errorf("expected empty string got %s", s)
+	}
+
+	tp, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+
+	newRequest := func(remoteAddr, xff string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		r.RemoteAddr = remoteAddr
+		if xff != "" {
+			r.Header.Set(NameXForwardedFor, xff)
+			r.Header.Set(NameXForwardedProto, "https")
+		}
+		return r
+	}
+
+	tests := []struct {
+		policy, remoteAddr, xff string
+		expectedClient          string
+		expectedXFF             string
+	}{
+		// append passes the chain upstream, but does not trust it for the client address
+		{ForwardedPolicyAppend, "5.6.7.8:1234", "1.1.1.1, 2.2.2.2", "5.6.7.8", "1.1.1.1, 2.2.2.2"},
+		{ForwardedPolicyAppend, "5.6.7.8:1234", "", "5.6.7.8", ""},
+		// replace never trusts clients
+		{ForwardedPolicyReplace, "10.0.0.1:1234", "1.1.1.1", "10.0.0.1", ""},
+		// sanitize strips headers from untrusted clients
+		{ForwardedPolicySanitize, "5.6.7.8:1234", "1.1.1.1", "5.6.7.8", ""},
+		// and uses the nearest untrusted hop from trusted clients, removing any spoofed hops
+		{ForwardedPolicySanitize, "10.0.0.1:1234", "9.9.9.9, 1.1.1.1, 10.0.0.2",
+			"1.1.1.1", "1.1.1.1, 10.0.0.2"},
+		{ForwardedPolicySanitize, "10.0.0.1:1234", "1.1.1.1", "1.1.1.1", "1.1.1.1"},
+		{ForwardedPolicySanitize, "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3", "10.0.0.3, 10.0.0.2"},
+		{ForwardedPolicySanitize, "10.0.0.1:1234", "", "10.0.0.1", ""},
+	}
+
+	for i, test := range tests {
+		r := newRequest(test.remoteAddr, test.xff)
+		client := ApplyForwardedPolicy(r, test.policy, tp)
+		if client != test.expectedClient {
+			t.Errorf("test %d: expected %s got %s", i, test.expectedClient, client)
+		}
+		if v := r.Header.Get(NameXForwardedFor); v != test.expectedXFF {
+			t.Errorf("test %d: expected %s got %s", i, test.expectedXFF, v)
+		}
+	}
+
+	// standard Forwarded headers are trimmed the same way
+	r := newRequest("10.0.0.1:1234", "")
+	r.Header.Set(NameForwarded, "for=9.9.9.9, for=1.1.1.1;proto=https, for=10.0.0.2")
+	client := ApplyForwardedPolicy(r, ForwardedPolicySanitize, tp)
+	if client != "1.1.1.1" {
+		t.Errorf("expected %s got %s", "1.1.1.1", client)
+	}
+	expected := "for=1.1.1.1;proto=https, for=10.0.0.2"
+	if v := r.Header.Get(NameForwarded); v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+
+	// when the client sends both header families, the X-Forwarded-* headers are rebuilt
+	// from the trusted Forwarded chain, rather than passing the client's values through
+	r = newRequest("10.0.0.1:1234", "9.9.9.9, 1.1.1.1")
+	r.Header.Set(NameXForwardedProto, "spoofed")
+	r.Header.Set(NameXForwardedHost, "spoofed.example.com")
+	r.Header.Set(NameForwarded, "for=9.9.9.9;proto=ftp, for=1.1.1.1;proto=https, for=10.0.0.2")
+	if client = ApplyForwardedPolicy(r, ForwardedPolicySanitize, tp); client != "1.1.1.1" {
+		t.Errorf("expected %s got %s", "1.1.1.1", client)
+	}
+	if v := r.Header.Get(NameForwarded); v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+	if v := r.Header.Get(NameXForwardedFor); v != "1.1.1.1, 10.0.0.2" {
+		t.Errorf("expected %s got %s", "1.1.1.1, 10.0.0.2", v)
+	}
+	if v := r.Header.Get(NameXForwardedProto); v != "https" {
+		t.Errorf("expected %s got %s", "https", v)
+	}
+	if v, ok := r.Header[NameXForwardedHost]; ok {
+		t.Errorf("expected no %s header got %s", NameXForwardedHost, v)
+	}
+
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package heatmap retains the latency and cache lookup status of an origin's recent requests,
+// and serves them as a compact JSON document for rendering a latency heatmap, so that a
+// dashboard owner can see whether slow responses are cache misses or slow cache hits
+package heatmap
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/heatmap/options"
+)
+
+// sample is the outcome of a single request
+type sample struct {
+	time    time.Time
+	latency time.Duration
+	status  status.LookupStatus
+}
+
+// Recorder retains the samples of an origin's most recent requests in a ring
+type Recorder struct {
+	originName string
+	originType string
+	options    *options.Options
+
+	mtx     sync.Mutex
+	samples []sample
+	head    int
+	full    bool
+	now     func() time.Time
+}
+
+// Document is the JSON document served by the heatmap handler. Samples are listed oldest
+// first as parallel arrays, so the i-th sample is described by the i-th member of Times,
+// LatenciesMS and Statuses, which keeps the document compact
+type Document struct {
+	Origin     string `json:"origin"`
+	OriginType string `json:"origin_type"`
+	// StatusNames lists the cache lookup statuses of the samples, which are referenced in
+	// Statuses by their index
+	StatusNames []string `json:"status_names"`
+	// Times are the times the requests completed, in milliseconds since the epoch
+	Times []int64 `json:"t"`
+	// LatenciesMS are the response times of the requests, in milliseconds
+	LatenciesMS []float64 `json:"latency_ms"`
+	// Statuses are the indexes in StatusNames of the requests' cache lookup statuses
+	Statuses []int `json:"status"`
+}
+
+// New returns a new Recorder for the named origin
+func New(originName, originType string, o *options.Options) *Recorder {
+	return &Recorder{
+		originName: originName,
+		originType: originType,
+		options:    o,
+		samples:    make([]sample, o.MaxSamples),
+		now:        time.Now,
+	}
+}
+
+// Observe records the cache lookup status and response time of a request
+func (r *Recorder) Observe(cacheStatus status.LookupStatus, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mtx.Lock()
+	r.samples[r.head] = sample{time: r.now(), latency: elapsed, status: cacheStatus}
+	r.head++
+	if r.head == len(r.samples) {
+		r.head = 0
+		r.full = true
+	}
+	r.mtx.Unlock()
+}
+
+// Document returns the samples that completed after since and within the Window
+func (r *Recorder) Document(since time.Time) *Document {
+	d := &Document{
+		Origin:      r.originName,
+		OriginType:  r.originType,
+		StatusNames: make([]string, 0),
+		Times:       make([]int64, 0),
+		LatenciesMS: make([]float64, 0),
+		Statuses:    make([]int, 0),
+	}
+	indexes := make(map[status.LookupStatus]int)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if cutoff := r.now().Add(-r.options.Window); cutoff.After(since) {
+		since = cutoff
+	}
+	start, n := 0, r.head
+	if r.full {
+		start, n = r.head, len(r.samples)
+	}
+	for i := 0; i < n; i++ {
+		s := r.samples[(start+i)%len(r.samples)]
+		if !s.time.After(since) {
+			continue
+		}
+		idx, ok := indexes[s.status]
+		if !ok {
+			idx = len(d.StatusNames)
+			indexes[s.status] = idx
+			d.StatusNames = append(d.StatusNames, s.status.String())
+		}
+		d.Times = append(d.Times, s.time.UnixNano()/int64(time.Millisecond))
+		d.LatenciesMS = append(d.LatenciesMS,
+			math.Round(float64(s.latency)/float64(time.Millisecond)*1000)/1000)
+		d.Statuses = append(d.Statuses, idx)
+	}
+	return d
+}
+
+// Handler returns the handler that responds with the Recorder's Document. The optional since
+// query parameter, in milliseconds since the epoch, limits the Document to newer samples, so
+// that a dashboard can poll for only the samples it has not yet rendered
+func (r *Recorder) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var since time.Time
+		if v := req.URL.Query().Get("since"); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid since parameter: "+v, http.StatusBadRequest)
+				return
+			}
+			since = time.Unix(0, ms*int64(time.Millisecond))
+		}
+		b, err := json.Marshal(r.Document(since))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	})
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package heatmap
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/heatmap/options"
+)
+
+func testRecorder(maxSamples int, now *time.Time) *Recorder {
+	o := options.NewOptions()
+	o.MaxSamples = maxSamples
+	o.WindowSecs = 60
+	o.SetDurations()
+	r := New("test", "prometheus", o)
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.Observe(status.LookupStatusHit, time.Second)
+}
+
+func TestRecorder(t *testing.T) {
+
+	now := time.Unix(1577836800, 0)
+	r := testRecorder(3, &now)
+
+	d := r.Document(time.Time{})
+	if len(d.Times) != 0 || len(d.StatusNames) != 0 {
+		t.Errorf("expected empty document got %v", d)
+	}
+
+	r.Observe(status.LookupStatusKeyMiss, 250*time.Millisecond)
+	now = now.Add(time.Second)
+	r.Observe(status.LookupStatusHit, 1500*time.Microsecond)
+	now = now.Add(time.Second)
+	r.Observe(status.LookupStatusKeyMiss, 300*time.Millisecond)
+
+	d = r.Document(time.Time{})
+	if d.Origin != "test" || d.OriginType != "prometheus" {
+		t.Errorf("unexpected origin %s %s", d.Origin, d.OriginType)
+	}
+	if !reflect.DeepEqual(d.StatusNames, []string{"kmiss", "hit"}) {
+		t.Errorf("unexpected status names %v", d.StatusNames)
+	}
+	if !reflect.DeepEqual(d.Times, []int64{1577836800000, 1577836801000, 1577836802000}) {
+		t.Errorf("unexpected times %v", d.Times)
+	}
+	if !reflect.DeepEqual(d.LatenciesMS, []float64{250, 1.5, 300}) {
+		t.Errorf("unexpected latencies %v", d.LatenciesMS)
+	}
+	if !reflect.DeepEqual(d.Statuses, []int{0, 1, 0}) {
+		t.Errorf("unexpected statuses %v", d.Statuses)
+	}
+
+	// the oldest sample is replaced once the ring is full
+	now = now.Add(time.Second)
+	r.Observe(status.LookupStatusPartialHit, 50*time.Millisecond)
+	d = r.Document(time.Time{})
+	if !reflect.DeepEqual(d.Times, []int64{1577836801000, 1577836802000, 1577836803000}) {
+		t.Errorf("unexpected times %v", d.Times)
+	}
+	if !reflect.DeepEqual(d.StatusNames, []string{"hit", "kmiss", "phit"}) {
+		t.Errorf("unexpected status names %v", d.StatusNames)
+	}
+
+	// only samples after since are included
+	d = r.Document(time.Unix(0, 1577836802000*int64(time.Millisecond)))
+	if !reflect.DeepEqual(d.Times, []int64{1577836803000}) {
+		t.Errorf("unexpected times %v", d.Times)
+	}
+
+	// samples older than the window are excluded
+	now = now.Add(59 * time.Second)
+	d = r.Document(time.Time{})
+	if !reflect.DeepEqual(d.Times, []int64{1577836803000}) {
+		t.Errorf("unexpected times %v", d.Times)
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	now := time.Unix(1577836800, 0)
+	r := testRecorder(10, &now)
+	r.Observe(status.LookupStatusHit, 10*time.Millisecond)
+	now = now.Add(time.Second)
+	r.Observe(status.LookupStatusKeyMiss, 200*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"http://trickster/trickster/heatmap/test?since="+strconv.FormatInt(1577836800000, 10), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if v := w.Header().Get("Content-Type"); v != "application/json" {
+		t.Errorf("unexpected content type %s", v)
+	}
+	var d Document
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Times) != 1 || d.StatusNames[d.Statuses[0]] != "kmiss" || d.LatenciesMS[0] != 200 {
+		t.Errorf("unexpected document %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"http://trickster/trickster/heatmap/test?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestOptions(t *testing.T) {
+
+	o := options.NewOptions()
+	if !o.Enabled() {
+		t.Error("expected heatmap to be enabled by default")
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o.Window != 15*time.Minute {
+		t.Errorf("expected %s got %s", 15*time.Minute, o.Window)
+	}
+	if o2 := o.Clone(); !reflect.DeepEqual(o, o2) {
+		t.Error("clone mismatch")
+	}
+
+	o.MaxSamples = 0
+	if o.Enabled() {
+		t.Error("expected heatmap to be disabled")
+	}
+
+	tests := []func(*options.Options){
+		func(o *options.Options) { o.MaxSamples = -1 },
+		func(o *options.Options) { o.WindowSecs = 0 },
+	}
+	for i, f := range tests {
+		o := options.NewOptions()
+		f(o)
+		if err := o.Validate(); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the latency heatmap options for an origin
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the sampling of an origin's recent requests, which are served by the
+// heatmap handler for rendering a latency heatmap
+type Options struct {
+	// MaxSamples is the number of recent requests retained. 0 disables the heatmap
+	MaxSamples int `toml:"max_samples"`
+	// WindowSecs is the maximum age of the retained samples
+	WindowSecs int `toml:"window_secs"`
+
+	// Window is the time.Duration representation of WindowSecs
+	Window time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		MaxSamples: d.DefaultHeatmapMaxSamples,
+		WindowSecs: d.DefaultHeatmapWindowSecs,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		MaxSamples: o.MaxSamples,
+		WindowSecs: o.WindowSecs,
+		Window:     o.Window,
+	}
+}
+
+// Enabled returns true if samples are retained
+func (o *Options) Enabled() bool {
+	return o != nil && o.MaxSamples > 0
+}
+
+// SetDurations sets the time.Duration representations of the Options' seconds-based values
+func (o *Options) SetDurations() {
+	o.Window = time.Duration(o.WindowSecs) * time.Second
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.MaxSamples < 0 {
+		return errors.New("heatmap max_samples must not be negative")
+	}
+	if o.WindowSecs <= 0 {
+		return errors.New("heatmap window_secs must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package identity resolves the identity of the user making a request,
+// as asserted by a trusted upstream proxy such as an SSO gateway
+package identity
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// ModeTrustedHeaders trusts identity headers injected by an upstream proxy, when
+// the request is received from a trusted proxy address or mTLS peer
+const ModeTrustedHeaders = "trusted_headers"
+
+// IsValidMode returns true if the input is a valid identity mode name. An empty mode disables identity
+func IsValidMode(input string) bool {
+	return input == "" || input == ModeTrustedHeaders
+}
+
+// Identity describes the user making a request
+type Identity struct {
+	// User is the name of the user
+	User string
+	// Groups are the groups the user belongs to
+	Groups []string
+}
+
+// Resolver resolves request identities from the headers of trusted upstream proxies
+type Resolver struct {
+	userHeader     string
+	groupsHeader   string
+	trustedProxies headers.TrustedProxies
+	trustedPeers   map[string]bool
+}
+
+// NewResolver returns a new Resolver that trusts the provided identity headers when the request
+// is received from one of the trusted proxy networks, or from an mTLS peer whose verified client
+// certificate has a Common Name or DNS Subject Alternative Name in trustedPeers
+func NewResolver(userHeader, groupsHeader string, trustedProxies headers.TrustedProxies,
+	trustedPeers []string) *Resolver {
+	tp := make(map[string]bool, len(trustedPeers))
+	for _, p := range trustedPeers {
+		tp[strings.ToLower(p)] = true
+	}
+	return &Resolver{
+		userHeader:     http.CanonicalHeaderKey(userHeader),
+		groupsHeader:   http.CanonicalHeaderKey(groupsHeader),
+		trustedProxies: trustedProxies,
+		trustedPeers:   tp,
+	}
+}
+
+// Resolve returns the identity asserted by the request's headers, or nil if the request was not
+// received from a trusted source or does not identify a user. Identity headers on requests from
+// untrusted sources are removed, so they can't be spoofed to origins or authorizers
+func (rv *Resolver) Resolve(r *http.Request) *Identity {
+	if r == nil || r.Header == nil {
+		return nil
+	}
+	if !rv.trusted(r) {
+		r.Header.Del(rv.userHeader)
+		r.Header.Del(rv.groupsHeader)
+		return nil
+	}
+	user := strings.TrimSpace(r.Header.Get(rv.userHeader))
+	if user == "" {
+		return nil
+	}
+	return &Identity{User: user, Groups: parseGroups(r.Header.Values(rv.groupsHeader))}
+}
+
+func (rv *Resolver) trusted(r *http.Request) bool {
+	if rv.trustedProxies.Contains(r.RemoteAddr) {
+		return true
+	}
+	if len(rv.trustedPeers) == 0 || r.TLS == nil ||
+		len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if rv.trustedPeers[strings.ToLower(cert.Subject.CommonName)] {
+		return true
+	}
+	for _, n := range cert.DNSNames {
+		if rv.trustedPeers[strings.ToLower(n)] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGroups returns the groups from the provided comma-separated header values
+func parseGroups(values []string) []string {
+	var groups []string
+	for _, v := range values {
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+// GroupsString returns the identity's groups as a comma-separated list
+func (i *Identity) GroupsString() string {
+	return strings.Join(i.Groups, ",")
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package identity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+func testResolver(t *testing.T) *Resolver {
+	tp, err := headers.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewResolver("X-Auth-Request-User", "X-Auth-Request-Groups", tp,
+		[]string{"SSO.example.com"})
+}
+
+func testRequest(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	r.RemoteAddr = remoteAddr
+	r.Header.Set("X-Auth-Request-User", "alice")
+	r.Header.Add("X-Auth-Request-Groups", "admins, interns")
+	r.Header.Add("X-Auth-Request-Groups", "oncall,")
+	return r
+}
+
+func TestIsValidMode(t *testing.T) {
+	if !IsValidMode("") || !IsValidMode(ModeTrustedHeaders) {
+		t.Error("expected valid mode")
+	}
+	if IsValidMode("x") {
+		t.Error("expected invalid mode")
+	}
+}
+
+func TestResolveTrustedProxy(t *testing.T) {
+
+	rv := testResolver(t)
+	id := rv.Resolve(testRequest("10.1.2.3:51234"))
+	if id == nil {
+		t.Fatal("expected identity")
+	}
+	if id.User != "alice" {
+		t.Errorf("expected %s got %s", "alice", id.User)
+	}
+	if s := id.GroupsString(); s != "admins,interns,oncall" {
+		t.Errorf("expected %s got %s", "admins,interns,oncall", s)
+	}
+
+	r := testRequest("10.1.2.3:51234")
+	r.Header.Del("X-Auth-Request-User")
+	if rv.Resolve(r) != nil {
+		t.Error("expected nil identity for request without a user")
+	}
+
+	if rv.Resolve(nil) != nil {
+		t.Error("expected nil identity for nil request")
+	}
+}
+
+func TestResolveUntrusted(t *testing.T) {
+
+	rv := testResolver(t)
+	r := testRequest("192.0.2.1:51234")
+	if rv.Resolve(r) != nil {
+		t.Error("expected nil identity for untrusted request")
+	}
+	if r.Header.Get("X-Auth-Request-User") != "" || r.Header.Get("X-Auth-Request-Groups") != "" {
+		t.Error("expected identity headers to be removed from untrusted request")
+	}
+}
+
+func TestResolveTrustedPeer(t *testing.T) {
+
+	rv := testResolver(t)
+
+	tests := []struct {
+		cert     *x509.Certificate
+		expected bool
+	}{
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "sso.example.com"}}, true},
+		{&x509.Certificate{DNSNames: []string{"other.example.com", "sso.example.com"}}, true},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "other.example.com"}}, false},
+	}
+
+	for i, test := range tests {
+		r := testRequest("192.0.2.1:51234")
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{test.cert}}}
+		if id := rv.Resolve(r); (id != nil) != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, id != nil)
+		}
+	}
+
+	// an unverified peer certificate is not trusted
+	r := testRequest("192.0.2.1:51234")
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tests[0].cert}}
+	if rv.Resolve(r) != nil {
+		t.Error("expected nil identity for unverified peer")
+	}
+}
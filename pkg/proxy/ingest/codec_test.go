@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+
+	e := &encoder{}
+	e.int8(-1)
+	e.int16(-2)
+	e.int32(-3)
+	e.int64(-4)
+	e.string("test")
+	e.bytes([]byte("value"))
+	e.bytes(nil)
+	e.int16(-1) // null string
+	e.int32(2)  // array length
+	e.varint(-300)
+	e.varint(3)
+	e.WriteString("abc")
+	e.varint(-1) // null varbytes
+
+	d := &decoder{b: e.Bytes()}
+	if v := d.int8(); v != -1 {
+		t.Errorf("expected %d got %d", -1, v)
+	}
+	if v := d.int16(); v != -2 {
+		t.Errorf("expected %d got %d", -2, v)
+	}
+	if v := d.int32(); v != -3 {
+		t.Errorf("expected %d got %d", -3, v)
+	}
+	if v := d.int64(); v != -4 {
+		t.Errorf("expected %d got %d", -4, v)
+	}
+	if v := d.string(); v != "test" {
+		t.Errorf("expected %s got %s", "test", v)
+	}
+	if v := d.bytes(); !bytes.Equal(v, []byte("value")) {
+		t.Errorf("expected %s got %s", "value", string(v))
+	}
+	if v := d.bytes(); v != nil {
+		t.Errorf("expected nil got %s", string(v))
+	}
+	if v := d.string(); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+	if v := d.arrayLen(); v != 2 {
+		t.Errorf("expected %d got %d", 2, v)
+	}
+	if v := d.varint(); v != -300 {
+		t.Errorf("expected %d got %d", -300, v)
+	}
+	if v := d.varbytes(); !bytes.Equal(v, []byte("abc")) {
+		t.Errorf("expected %s got %s", "abc", string(v))
+	}
+	if v := d.varbytes(); v != nil {
+		t.Errorf("expected nil got %s", string(v))
+	}
+	if d.err != nil {
+		t.Error(d.err)
+	}
+	if len(d.b) != 0 {
+		t.Errorf("expected %d remaining bytes got %d", 0, len(d.b))
+	}
+}
+
+func TestDecoderShortBuffer(t *testing.T) {
+
+	d := &decoder{b: []byte{0, 0}}
+	if v := d.int32(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+	if d.err != errShortBuffer {
+		t.Errorf("expected %v got %v", errShortBuffer, d.err)
+	}
+	// once failed, all reads return zero values
+	if v := d.int8(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+	if v := d.int16(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+	if v := d.int64(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+	if v := d.varint(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+
+	// array lengths that exceed the remaining bytes are corrupt
+	e := &encoder{}
+	e.int32(10)
+	d = &decoder{b: e.Bytes()}
+	if n := d.arrayLen(); n != 0 || d.err != errShortBuffer {
+		t.Errorf("expected corrupt array length, got %d %v", n, d.err)
+	}
+
+	d = &decoder{b: []byte{0x80}}
+	if v := d.varint(); v != 0 || d.err != errShortBuffer {
+		t.Errorf("expected truncated varint, got %d %v", v, d.err)
+	}
+}
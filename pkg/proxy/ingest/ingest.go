@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ingest populates an origin's cache with pre-computed timeseries consumed from a
+// Kafka topic or MQTT topic, so that batch pipelines which already compute popular aggregates
+// can push them into the cache ahead of the queries that would request them
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// dpcKeyPrefix is the namespace of the delta proxy cache's timeseries objects
+const dpcKeyPrefix = ".dpc."
+
+// Message is a pre-computed timeseries to be written into the cache
+type Message struct {
+	// Key is the cache key of the timeseries, as logged in the cacheKey field of the canonical
+	// log, optionally including the origin's cache key prefix
+	Key string `json:"key"`
+	// TTLMS is how long the timeseries is cached, which is capped at the origin's timeseries_ttl_ms
+	TTLMS int64 `json:"ttl_ms"`
+	// StepMS is the step of the timeseries, which is required when the payload does not provide it
+	StepMS int64 `json:"step_ms,omitempty"`
+	// Extents are the time ranges that the payload fully covers
+	Extents timeseries.ExtentList `json:"extents"`
+	// ContentType is the content type of the payload, as returned by the origin
+	ContentType string `json:"content_type,omitempty"`
+	// Payload is the timeseries in the origin's response format
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ParseMessage returns the Message encoded in b, or an error if it is invalid. Only
+// timeseries objects are ingested, so keys outside of the delta proxy cache's namespace
+// are rejected
+func ParseMessage(b []byte) (*Message, error) {
+	m := &Message{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if i := strings.Index(m.Key, dpcKeyPrefix); i > 0 {
+		m.Key = m.Key[i:]
+	}
+	if !strings.HasPrefix(m.Key, dpcKeyPrefix) || len(m.Key) == len(dpcKeyPrefix) {
+		return nil, errors.New("invalid ingest key")
+	}
+	if m.TTLMS <= 0 {
+		return nil, errors.New("invalid ingest ttl_ms")
+	}
+	if m.StepMS < 0 {
+		return nil, errors.New("invalid ingest step_ms")
+	}
+	if len(m.Extents) == 0 {
+		return nil, errors.New("ingest extents must be provided")
+	}
+	for _, e := range m.Extents {
+		if e.Start.IsZero() || e.End.Before(e.Start) {
+			return nil, errors.New("invalid ingest extent")
+		}
+	}
+	if len(m.Payload) == 0 || string(m.Payload) == "null" {
+		return nil, errors.New("ingest payload must be provided")
+	}
+	if m.ContentType == "" {
+		m.ContentType = "application/json"
+	}
+	return m, nil
+}
+
+// TTL returns the time.Duration representation of TTLMS
+func (m *Message) TTL() time.Duration {
+	return time.Duration(m.TTLMS) * time.Millisecond
+}
+
+// Step returns the time.Duration representation of StepMS
+func (m *Message) Step() time.Duration {
+	return time.Duration(m.StepMS) * time.Millisecond
+}
+
+// MergeFunc merges an ingested timeseries into the object cached at key
+type MergeFunc func(key string, m *Message) error
+
+// ErrorFunc reports the failed connections to the source, and the messages that could not be
+// merged into the cache
+type ErrorFunc func(err error)
+
+// source consumes the messages of a broker, calling handle with the value of each message or
+// with the error that caused a message to be discarded. consume returns nil when quit is
+// closed, or the error that interrupted the consumption
+type source interface {
+	consume(quit <-chan struct{}, handle func([]byte, error)) error
+}
+
+// Ingestor consumes the pre-computed timeseries of an origin from its ingestion source and
+// merges them into the origin's cache
+type Ingestor struct {
+	originName string
+	originType string
+	keyPrefix  string
+	options    *options.Options
+	source     source
+}
+
+// New returns a new Ingestor for the named origin. keyPrefix is the origin's cache key
+// prefix, which is prepended to the ingested keys
+func New(originName, originType, keyPrefix string, o *options.Options) *Ingestor {
+	clientID := o.ClientID
+	if clientID == "" {
+		clientID = "trickster-" + runtime.Server + "-" + originName
+	}
+	ig := &Ingestor{
+		originName: originName,
+		originType: originType,
+		keyPrefix:  keyPrefix,
+		options:    o,
+	}
+	switch o.Source {
+	case options.SourceKafka:
+		ig.source = newKafkaSource(o, clientID)
+	case options.SourceMQTT:
+		ig.source = newMQTTSource(o, clientID)
+	}
+	return ig
+}
+
+// Run consumes the source's messages until quit is closed, reconnecting to the brokers
+// after each ReconnectInterval when the connection fails
+func (ig *Ingestor) Run(merge MergeFunc, report ErrorFunc, quit <-chan struct{}) {
+	handle := func(b []byte, err error) {
+		ig.handle(merge, report, b, err)
+	}
+	for {
+		err := ig.source.consume(quit, handle)
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		if err != nil {
+			metrics.ProxyIngestSourceErrors.WithLabelValues(ig.originName, ig.originType,
+				ig.options.Source).Inc()
+			report(fmt.Errorf("%s source connection failed: %s", ig.options.Source, err.Error()))
+		}
+		select {
+		case <-quit:
+			return
+		case <-time.After(ig.options.ReconnectInterval):
+		}
+	}
+}
+
+// handle merges a consumed message into the cache. Invalid messages are only counted, since
+// a misbehaving producer could otherwise flood the report
+func (ig *Ingestor) handle(merge MergeFunc, report ErrorFunc, b []byte, err error) {
+	var m *Message
+	if err == nil {
+		m, err = ParseMessage(b)
+	}
+	if err != nil {
+		metrics.ProxyIngestMessages.WithLabelValues(ig.originName, ig.originType,
+			"invalid").Inc()
+		return
+	}
+	if err = merge(ig.keyPrefix+m.Key, m); err != nil {
+		metrics.ProxyIngestMessages.WithLabelValues(ig.originName, ig.originType,
+			"failed").Inc()
+		report(fmt.Errorf("merge of key %s failed: %s", m.Key, err.Error()))
+		return
+	}
+	metrics.ProxyIngestMessages.WithLabelValues(ig.originName, ig.originType,
+		"success").Inc()
+}
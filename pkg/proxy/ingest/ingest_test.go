@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+const testMessage = `{"key":"test.dpc.abc","ttl_ms":60000,"step_ms":15000,` +
+	`"extents":[{"start":"2020-01-01T00:00:00Z","end":"2020-01-01T01:00:00Z"}],"payload":{"status":"success"}}`
+
+func TestParseMessage(t *testing.T) {
+
+	m, err := ParseMessage([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Key != ".dpc.abc" {
+		t.Errorf("expected %s got %s", ".dpc.abc", m.Key)
+	}
+	if m.TTL() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, m.TTL())
+	}
+	if m.Step() != 15*time.Second {
+		t.Errorf("expected %s got %s", 15*time.Second, m.Step())
+	}
+	if m.ContentType != "application/json" {
+		t.Errorf("expected %s got %s", "application/json", m.ContentType)
+	}
+	if len(m.Extents) != 1 || m.Extents[0].End.Sub(m.Extents[0].Start) != time.Hour {
+		t.Errorf("unexpected extents %s", m.Extents.String())
+	}
+	if string(m.Payload) != `{"status":"success"}` {
+		t.Errorf("unexpected payload %s", string(m.Payload))
+	}
+
+	tests := []struct {
+		name, from, to string
+	}{
+		{"key", `"key":"test.dpc.abc"`, `"key":"abc"`},
+		{"empty key", `"key":"test.dpc.abc"`, `"key":".dpc."`},
+		{"ttl", `"ttl_ms":60000`, `"ttl_ms":0`},
+		{"step", `"step_ms":15000`, `"step_ms":-1`},
+		{"extents", `[{"start":"2020-01-01T00:00:00Z","end":"2020-01-01T01:00:00Z"}]`, `[]`},
+		{"extent", `"end":"2020-01-01T01:00:00Z"`, `"end":"2019-01-01T01:00:00Z"`},
+		{"payload", `{"status":"success"}`, `null`},
+		{"json", `{"key"`, `{"key`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseMessage([]byte(strings.Replace(testMessage, test.from, test.to, 1)))
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	o := options.NewOptions()
+	o.Source = options.SourceKafka
+	ig := New("test", "prometheus", "prefix", o)
+	ks, ok := ig.source.(*kafkaSource)
+	if !ok {
+		t.Fatal("expected kafka source")
+	}
+	if expected := "trickster-" + runtime.Server + "-test"; ks.clientID != expected {
+		t.Errorf("expected %s got %s", expected, ks.clientID)
+	}
+
+	o.Source = options.SourceMQTT
+	o.ClientID = "test-client"
+	ig = New("test", "prometheus", "prefix", o)
+	ms, ok := ig.source.(*mqttSource)
+	if !ok {
+		t.Fatal("expected mqtt source")
+	}
+	if ms.clientID != "test-client" {
+		t.Errorf("expected %s got %s", "test-client", ms.clientID)
+	}
+}
+
+// testSource delivers its messages on the first consume, and fails on the second
+type testSource struct {
+	messages [][]byte
+	calls    int
+}
+
+func (ts *testSource) consume(quit <-chan struct{}, handle func([]byte, error)) error {
+	ts.calls++
+	if ts.calls > 1 {
+		return errors.New("test error")
+	}
+	for _, m := range ts.messages {
+		handle(m, nil)
+	}
+	handle(nil, errMessageTooLarge)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+
+	o := options.NewOptions()
+	o.Source = options.SourceMQTT
+	o.ReconnectInterval = time.Millisecond
+	ig := New("test", "prometheus", "prefix", o)
+	ts := &testSource{messages: [][]byte{
+		[]byte(testMessage),
+		[]byte(strings.Replace(testMessage, "abc", "fail", 1)),
+		[]byte("invalid"),
+	}}
+	ig.source = ts
+
+	var keys []string
+	merge := func(key string, m *Message) error {
+		if strings.HasSuffix(key, "fail") {
+			return errors.New("test merge error")
+		}
+		keys = append(keys, key)
+		return nil
+	}
+
+	quit := make(chan struct{})
+	var reported []string
+	report := func(err error) {
+		reported = append(reported, err.Error())
+		if len(reported) == 2 {
+			close(quit)
+		}
+	}
+
+	ig.Run(merge, report, quit)
+
+	if len(keys) != 1 || keys[0] != "prefix.dpc.abc" {
+		t.Errorf("unexpected merged keys %v", keys)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(reported))
+	}
+	if reported[0] != "merge of key .dpc.fail failed: test merge error" {
+		t.Errorf("unexpected report %s", reported[0])
+	}
+	if reported[1] != "mqtt source connection failed: test error" {
+		t.Errorf("unexpected report %s", reported[1])
+	}
+}
@@ -0,0 +1,482 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+)
+
+// Kafka API keys and the versions used by the source. These are the oldest versions that
+// are supported by both old (0.11) and current brokers, and that use record batches
+const (
+	apiFetch       = 1
+	apiListOffsets = 2
+	apiMetadata    = 3
+
+	versionFetch       = 4
+	versionListOffsets = 1
+	versionMetadata    = 1
+)
+
+// Kafka error codes that are handled by the source
+const (
+	errCodeNone             = 0
+	errCodeOffsetOutOfRange = 1
+)
+
+// Special timestamps of a ListOffsets request
+const (
+	offsetLatest   = -1
+	offsetEarliest = -2
+)
+
+// Record batch attributes
+const (
+	compressionMask = 0x07
+	compressionNone = 0
+	compressionGzip = 1
+	controlBatch    = 0x20
+)
+
+// fetchMaxWait is how long a broker waits for new messages before responding to a fetch
+const fetchMaxWait = 500 * time.Millisecond
+
+// requestTimeout is the time allowed for a broker to respond, in addition to fetchMaxWait
+const requestTimeout = 30 * time.Second
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// errUnsupportedCompression is reported for record batches compressed with other than gzip
+var errUnsupportedCompression = errors.New("unsupported kafka compression codec")
+
+// errUnsupportedMagic is reported for messages produced in the legacy message set format
+var errUnsupportedMagic = errors.New("unsupported kafka message format")
+
+// errMessageTooLarge is reported for messages larger than the configured max_message_bytes
+var errMessageTooLarge = errors.New("message exceeds max_message_bytes")
+
+// kafkaSource consumes the messages of every partition of a Kafka topic. It does not join a
+// consumer group, since each Trickster must consume every message to populate its own cache.
+// The consumed offsets are retained across reconnections, but not across restarts.
+type kafkaSource struct {
+	options  *options.Options
+	clientID string
+	dialer   *net.Dialer
+	// offsets is the next offset to fetch for each partition
+	offsets map[int32]int64
+}
+
+func newKafkaSource(o *options.Options, clientID string) *kafkaSource {
+	return &kafkaSource{
+		options:  o,
+		clientID: clientID,
+		dialer:   &net.Dialer{Timeout: 10 * time.Second},
+		offsets:  make(map[int32]int64),
+	}
+}
+
+// kafkaConn is a connection to a single Kafka broker
+type kafkaConn struct {
+	conn          net.Conn
+	clientID      string
+	correlationID int32
+	maxBytes      int
+}
+
+// partitionLeader is a partition of the topic and the address of its leader
+type partitionLeader struct {
+	partition int32
+	leader    string
+}
+
+func (ks *kafkaSource) connect(addr string) (*kafkaConn, error) {
+	conn, err := ks.dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// a fetch response is limited to max_bytes, except that it always includes the first
+	// record batch, which may be up to max_message_bytes on its own
+	return &kafkaConn{conn: conn, clientID: ks.clientID,
+		maxBytes: 2*ks.options.MaxMessageBytes + 1<<20}, nil
+}
+
+func (ks *kafkaSource) consume(quit <-chan struct{}, handle func([]byte, error)) error {
+
+	leaders, err := ks.metadata()
+	if err != nil {
+		return err
+	}
+
+	conns := make(map[string]*kafkaConn)
+	defer func() {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}()
+
+	// partitions are grouped by their leader, which serves all of their fetches
+	byLeader := make(map[string][]int32)
+	for _, pl := range leaders {
+		byLeader[pl.leader] = append(byLeader[pl.leader], pl.partition)
+	}
+	for addr := range byLeader {
+		c, err := ks.connect(addr)
+		if err != nil {
+			return err
+		}
+		conns[addr] = c
+	}
+
+	for {
+		select {
+		case <-quit:
+			return nil
+		default:
+		}
+		for addr, partitions := range byLeader {
+			c := conns[addr]
+			if err = ks.listOffsets(c, partitions); err != nil {
+				return err
+			}
+			if err = ks.fetch(c, partitions, handle); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// metadata returns the leader of each of the topic's partitions, from the first broker
+// that responds
+func (ks *kafkaSource) metadata() ([]partitionLeader, error) {
+	var err error
+	for _, addr := range ks.options.Brokers {
+		var c *kafkaConn
+		if c, err = ks.connect(addr); err != nil {
+			continue
+		}
+		var leaders []partitionLeader
+		leaders, err = c.metadata(ks.options.Topic)
+		c.conn.Close()
+		if err == nil {
+			return leaders, nil
+		}
+	}
+	return nil, err
+}
+
+// listOffsets requests the start offset of the partitions that do not have an offset yet
+func (ks *kafkaSource) listOffsets(c *kafkaConn, partitions []int32) error {
+	ts := int64(offsetLatest)
+	if ks.options.StartOffset == options.StartOffsetEarliest {
+		ts = offsetEarliest
+	}
+	missing := make([]int32, 0, len(partitions))
+	for _, p := range partitions {
+		if _, ok := ks.offsets[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	offsets, err := c.listOffsets(ks.options.Topic, missing, ts)
+	if err != nil {
+		return err
+	}
+	for p, o := range offsets {
+		ks.offsets[p] = o
+	}
+	return nil
+}
+
+// fetch consumes the next messages of the partitions from their leader
+func (ks *kafkaSource) fetch(c *kafkaConn, partitions []int32, handle func([]byte, error)) error {
+	e := &encoder{}
+	e.int32(-1) // replica_id
+	e.int32(int32(fetchMaxWait / time.Millisecond))
+	e.int32(1) // min_bytes
+	e.int32(int32(ks.options.MaxMessageBytes))
+	e.int8(0) // isolation_level: read_uncommitted
+	e.int32(1)
+	e.string(ks.options.Topic)
+	e.int32(int32(len(partitions)))
+	for _, p := range partitions {
+		e.int32(p)
+		e.int64(ks.offsets[p])
+		e.int32(int32(ks.options.MaxMessageBytes))
+	}
+
+	d, err := c.roundTrip(apiFetch, versionFetch, e.Bytes(), fetchMaxWait)
+	if err != nil {
+		return err
+	}
+
+	d.int32() // throttle_time_ms
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			p := d.int32()
+			code := d.int16()
+			d.int64() // high_watermark
+			d.int64() // last_stable_offset
+			for k, a := 0, d.arrayLen(); k < a && d.err == nil; k++ {
+				d.int64() // producer_id
+				d.int64() // first_offset
+			}
+			records := d.bytes()
+			if d.err != nil {
+				break
+			}
+			switch code {
+			case errCodeNone:
+			case errCodeOffsetOutOfRange:
+				// the partition's messages were deleted before they were consumed,
+				// so consumption restarts from the configured start offset
+				delete(ks.offsets, p)
+				continue
+			default:
+				return fmt.Errorf("kafka fetch of partition %d failed with error code %d", p, code)
+			}
+			ks.offsets[p] = readRecordBatches(records, ks.offsets[p],
+				ks.options.MaxMessageBytes, handle)
+		}
+	}
+	return d.err
+}
+
+// readRecordBatches calls handle with the value of each record at or after offset, and returns
+// the offset following the last complete record batch. A fetch response may end with a
+// partial batch, which is fetched again from its start on the next fetch.
+func readRecordBatches(b []byte, offset int64, maxBytes int, handle func([]byte, error)) int64 {
+	for len(b) >= 12 {
+		baseOffset := int64(binary.BigEndian.Uint64(b))
+		n := int(int32(binary.BigEndian.Uint32(b[8:])))
+		if n < 0 || len(b) < 12+n {
+			break
+		}
+		batch := b[12 : 12+n]
+		b = b[12+n:]
+
+		// legacy message sets share the offset and length prefix of record batches
+		if len(batch) < 49 || batch[4] != 2 {
+			if baseOffset >= offset {
+				handle(nil, errUnsupportedMagic)
+				offset = baseOffset + 1
+			}
+			continue
+		}
+
+		next := baseOffset + int64(int32(binary.BigEndian.Uint32(batch[11:]))) + 1
+		if next <= offset {
+			continue
+		}
+		if crc32.Checksum(batch[9:], castagnoli) != binary.BigEndian.Uint32(batch[5:]) {
+			handle(nil, errors.New("kafka record batch failed crc check"))
+			offset = next
+			continue
+		}
+
+		attributes := binary.BigEndian.Uint16(batch[9:])
+		if attributes&controlBatch != 0 {
+			offset = next
+			continue
+		}
+
+		count := int(int32(binary.BigEndian.Uint32(batch[45:])))
+		records := batch[49:]
+		switch attributes & compressionMask {
+		case compressionNone:
+		case compressionGzip:
+			zr, err := gzip.NewReader(bytes.NewReader(records))
+			if err != nil {
+				handle(nil, err)
+				offset = next
+				continue
+			}
+			records, err = ioutil.ReadAll(io.LimitReader(zr, int64(maxBytes)*int64(count+1)))
+			if err != nil {
+				handle(nil, err)
+				offset = next
+				continue
+			}
+		default:
+			handle(nil, errUnsupportedCompression)
+			offset = next
+			continue
+		}
+
+		d := &decoder{b: records}
+		for i := 0; i < count && d.err == nil; i++ {
+			r := &decoder{b: d.next(int(d.varint()))}
+			r.int8()   // attributes
+			r.varint() // timestamp_delta
+			ro := baseOffset + r.varint()
+			r.varbytes() // key
+			v := r.varbytes()
+			if r.err != nil || d.err != nil {
+				handle(nil, errors.New("invalid kafka record"))
+				break
+			}
+			if ro < offset {
+				continue
+			}
+			if len(v) > maxBytes {
+				handle(nil, errMessageTooLarge)
+				continue
+			}
+			handle(v, nil)
+		}
+		offset = next
+	}
+	return offset
+}
+
+// roundTrip sends a request to the broker and returns a decoder of its response body
+func (c *kafkaConn) roundTrip(apiKey, apiVersion int16, body []byte,
+	wait time.Duration) (*decoder, error) {
+	c.correlationID++
+	e := &encoder{}
+	e.int32(0) // size, which is set below
+	e.int16(apiKey)
+	e.int16(apiVersion)
+	e.int32(c.correlationID)
+	e.string(c.clientID)
+	e.Write(body)
+	b := e.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+
+	c.conn.SetDeadline(time.Now().Add(wait + requestTimeout))
+	if _, err := c.conn.Write(b); err != nil {
+		return nil, err
+	}
+	var h [8]byte
+	if _, err := io.ReadFull(c.conn, h[:]); err != nil {
+		return nil, err
+	}
+	n := int(int32(binary.BigEndian.Uint32(h[:])))
+	if n < 4 || n > c.maxBytes {
+		return nil, errors.New("invalid kafka response size " + strconv.Itoa(n))
+	}
+	if id := int32(binary.BigEndian.Uint32(h[4:])); id != c.correlationID {
+		return nil, fmt.Errorf("kafka correlation id mismatch: expected %d got %d",
+			c.correlationID, id)
+	}
+	resp := make([]byte, n-4)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	return &decoder{b: resp}, nil
+}
+
+// metadata returns the leader of each of the topic's partitions
+func (c *kafkaConn) metadata(topic string) ([]partitionLeader, error) {
+	e := &encoder{}
+	e.int32(1)
+	e.string(topic)
+	d, err := c.roundTrip(apiMetadata, versionMetadata, e.Bytes(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	brokers := make(map[int32]string)
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+
+	var leaders []partitionLeader
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		if code != errCodeNone && d.err == nil {
+			return nil, fmt.Errorf("kafka metadata for topic %s failed with error code %d", name, code)
+		}
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			code = d.int16()
+			p := d.int32()
+			leader := d.int32()
+			for k, r := 0, d.arrayLen(); k < r && d.err == nil; k++ {
+				d.int32() // replica_nodes
+			}
+			for k, r := 0, d.arrayLen(); k < r && d.err == nil; k++ {
+				d.int32() // isr_nodes
+			}
+			addr, ok := brokers[leader]
+			if d.err == nil && (code != errCodeNone || !ok) {
+				return nil, fmt.Errorf("kafka partition %d of topic %s has no leader", p, name)
+			}
+			leaders = append(leaders, partitionLeader{partition: p, leader: addr})
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(leaders) == 0 {
+		return nil, fmt.Errorf("kafka topic %s has no partitions", topic)
+	}
+	return leaders, nil
+}
+
+// listOffsets returns the offset of each of the partitions at the provided timestamp
+func (c *kafkaConn) listOffsets(topic string, partitions []int32, ts int64) (map[int32]int64, error) {
+	e := &encoder{}
+	e.int32(-1) // replica_id
+	e.int32(1)
+	e.string(topic)
+	e.int32(int32(len(partitions)))
+	for _, p := range partitions {
+		e.int32(p)
+		e.int64(ts)
+	}
+	d, err := c.roundTrip(apiListOffsets, versionListOffsets, e.Bytes(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			p := d.int32()
+			code := d.int16()
+			d.int64() // timestamp
+			o := d.int64()
+			if code != errCodeNone && d.err == nil {
+				return nil, fmt.Errorf("kafka offsets of partition %d failed with error code %d", p, code)
+			}
+			offsets[p] = o
+		}
+	}
+	return offsets, d.err
+}
@@ -0,0 +1,393 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+)
+
+// recordBatch returns a v2 record batch of values, starting at baseOffset
+func recordBatch(baseOffset int64, attributes int16, values ...[]byte) []byte {
+	records := &encoder{}
+	for i, v := range values {
+		r := &encoder{}
+		r.int8(0)
+		r.varint(0)
+		r.varint(int64(i))
+		r.varint(-1)
+		r.varint(int64(len(v)))
+		r.Write(v)
+		r.varint(0)
+		records.varint(int64(r.Len()))
+		records.Write(r.Bytes())
+	}
+	rb := records.Bytes()
+	if attributes&compressionMask == compressionGzip {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		zw.Write(rb)
+		zw.Close()
+		rb = buf.Bytes()
+	}
+
+	b := &encoder{}
+	b.int16(attributes)
+	b.int32(int32(len(values) - 1))
+	b.int64(0)  // first_timestamp
+	b.int64(0)  // max_timestamp
+	b.int64(-1) // producer_id
+	b.int16(-1) // producer_epoch
+	b.int32(-1) // base_sequence
+	b.int32(int32(len(values)))
+	b.Write(rb)
+
+	e := &encoder{}
+	e.int64(baseOffset)
+	e.int32(int32(9 + b.Len()))
+	e.int32(0) // partition_leader_epoch
+	e.int8(2)
+	e.int32(int32(crc32.Checksum(b.Bytes(), castagnoli)))
+	e.Write(b.Bytes())
+	return e.Bytes()
+}
+
+func TestReadRecordBatches(t *testing.T) {
+
+	var values []string
+	var errs []error
+	handle := func(b []byte, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		values = append(values, string(b))
+	}
+
+	b := &bytes.Buffer{}
+	b.Write(recordBatch(0, compressionNone, []byte("a"), []byte("b"), []byte("c")))
+	b.Write(recordBatch(3, compressionGzip, []byte("d"), []byte("e")))
+	b.Write(recordBatch(5, controlBatch, []byte("control")))
+	b.Write(recordBatch(6, 2, []byte("snappy")))
+	b.Write(recordBatch(7, compressionNone, []byte("too large")))
+	corrupt := recordBatch(8, compressionNone, []byte("corrupt"))
+	corrupt[len(corrupt)-2] = 'x'
+	b.Write(corrupt)
+	legacy := recordBatch(9, compressionNone, []byte("legacy"))
+	legacy[16] = 1
+	b.Write(legacy)
+	b.Write(recordBatch(10, compressionNone, []byte("f")))
+	// a partial batch is fetched again from its start
+	b.Write(recordBatch(11, compressionNone, []byte("partial"))[:20])
+
+	next := readRecordBatches(b.Bytes(), 1, 8, handle)
+	if next != 11 {
+		t.Errorf("expected %d got %d", 11, next)
+	}
+	expected := "b,c,d,e,f"
+	if s := joinStrings(values); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected %d got %d: %v", 4, len(errs), errs)
+	}
+	if errs[0] != errUnsupportedCompression || errs[1] != errMessageTooLarge ||
+		errs[3] != errUnsupportedMagic {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	// batches before the offset are skipped
+	values = nil
+	if next = readRecordBatches(b.Bytes(), 11, 8, handle); next != 11 {
+		t.Errorf("expected %d got %d", 11, next)
+	}
+	if len(values) != 0 {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func joinStrings(s []string) string {
+	b := &bytes.Buffer{}
+	for i, v := range s {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// testKafkaBroker serves the Metadata, ListOffsets and Fetch requests of a single topic
+type testKafkaBroker struct {
+	ln         net.Listener
+	topic      string
+	topicError int16
+	mtx        sync.Mutex
+	partitions map[int32][][]byte
+	// outOfRange is the partitions whose next fetch fails with OFFSET_OUT_OF_RANGE
+	outOfRange map[int32]bool
+}
+
+func newTestKafkaBroker(t *testing.T, topic string) *testKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb := &testKafkaBroker{ln: ln, topic: topic, partitions: make(map[int32][][]byte),
+		outOfRange: make(map[int32]bool)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go kb.serve(conn)
+		}
+	}()
+	return kb
+}
+
+func (kb *testKafkaBroker) produce(p int32, values ...string) {
+	kb.mtx.Lock()
+	for _, v := range values {
+		kb.partitions[p] = append(kb.partitions[p], []byte(v))
+	}
+	kb.mtx.Unlock()
+}
+
+func (kb *testKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var h [4]byte
+		if _, err := io.ReadFull(conn, h[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(h[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := &decoder{b: req}
+		apiKey := d.int16()
+		d.int16() // api_version
+		correlationID := d.int32()
+		d.string() // client_id
+
+		e := &encoder{}
+		e.int32(0)
+		e.int32(correlationID)
+		switch apiKey {
+		case apiMetadata:
+			kb.metadata(e)
+		case apiListOffsets:
+			kb.listOffsets(d, e)
+		case apiFetch:
+			kb.fetch(d, e)
+		default:
+			return
+		}
+		b := e.Bytes()
+		binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+		if _, err := conn.Write(b); err != nil {
+			return
+		}
+	}
+}
+
+func (kb *testKafkaBroker) metadata(e *encoder) {
+	host, port, _ := net.SplitHostPort(kb.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	e.int32(1)
+	e.int32(0)
+	e.string(host)
+	e.int32(int32(p))
+	e.int16(-1) // rack
+	e.int32(0)  // controller_id
+	e.int32(1)
+	e.int16(kb.topicError)
+	e.string(kb.topic)
+	e.int8(0)
+	e.int32(2)
+	for i := int32(0); i < 2; i++ {
+		e.int16(0)
+		e.int32(i)
+		e.int32(0) // leader
+		e.int32(1)
+		e.int32(0)
+		e.int32(1)
+		e.int32(0)
+	}
+}
+
+func (kb *testKafkaBroker) listOffsets(d *decoder, e *encoder) {
+	kb.mtx.Lock()
+	defer kb.mtx.Unlock()
+	d.int32() // replica_id
+	d.arrayLen()
+	topic := d.string()
+	n := d.arrayLen()
+	e.int32(1)
+	e.string(topic)
+	e.int32(int32(n))
+	for i := 0; i < n; i++ {
+		p := d.int32()
+		ts := d.int64()
+		e.int32(p)
+		e.int16(0)
+		e.int64(-1)
+		if ts == offsetEarliest {
+			e.int64(0)
+		} else {
+			e.int64(int64(len(kb.partitions[p])))
+		}
+	}
+}
+
+func (kb *testKafkaBroker) fetch(d *decoder, e *encoder) {
+	kb.mtx.Lock()
+	defer kb.mtx.Unlock()
+	d.next(17) // replica_id, max_wait_ms, min_bytes, max_bytes, isolation_level
+	d.arrayLen()
+	topic := d.string()
+	n := d.arrayLen()
+	e.int32(0) // throttle_time_ms
+	e.int32(1)
+	e.string(topic)
+	e.int32(int32(n))
+	var empty bool
+	for i := 0; i < n; i++ {
+		p := d.int32()
+		offset := d.int64()
+		d.int32() // partition_max_bytes
+		e.int32(p)
+		if kb.outOfRange[p] {
+			delete(kb.outOfRange, p)
+			e.int16(errCodeOffsetOutOfRange)
+		} else {
+			e.int16(0)
+		}
+		e.int64(int64(len(kb.partitions[p])))
+		e.int64(int64(len(kb.partitions[p])))
+		e.int32(0) // aborted_transactions
+		if int(offset) < len(kb.partitions[p]) {
+			e.bytes(recordBatch(offset, compressionNone, kb.partitions[p][offset:]...))
+		} else {
+			e.int32(0)
+			empty = true
+		}
+	}
+	if empty {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testKafkaOptions(kb *testKafkaBroker, startOffset string) *options.Options {
+	o := options.NewOptions()
+	o.Source = options.SourceKafka
+	o.Brokers = []string{"127.0.0.1:1", kb.ln.Addr().String()}
+	o.Topic = kb.topic
+	o.StartOffset = startOffset
+	return o
+}
+
+// consumeUntil consumes from the source until n values are received, and returns them
+func consumeUntil(t *testing.T, s source, n int) []string {
+	var values []string
+	quit := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.consume(quit, func(b []byte, err error) {
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			values = append(values, string(b))
+			if len(values) == n {
+				close(quit)
+			}
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out consuming messages")
+	}
+	return values
+}
+
+func TestKafkaSource(t *testing.T) {
+
+	kb := newTestKafkaBroker(t, "test")
+	defer kb.ln.Close()
+	kb.produce(0, "a", "b")
+	kb.produce(1, "c")
+	kb.outOfRange[1] = true
+
+	ks := newKafkaSource(testKafkaOptions(kb, options.StartOffsetEarliest), "test")
+	values := consumeUntil(t, ks, 3)
+	if len(values) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(values))
+	}
+	if ks.offsets[0] != 2 || ks.offsets[1] != 1 {
+		t.Errorf("unexpected offsets %v", ks.offsets)
+	}
+
+	// the offsets are retained across consumption sessions
+	kb.produce(1, "d")
+	values = consumeUntil(t, ks, 1)
+	if len(values) != 1 || values[0] != "d" {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	// the latest start offset consumes only new messages
+	ks = newKafkaSource(testKafkaOptions(kb, options.StartOffsetLatest), "test")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		kb.produce(0, "e")
+	}()
+	values = consumeUntil(t, ks, 1)
+	if len(values) != 1 || values[0] != "e" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestKafkaSourceErrors(t *testing.T) {
+
+	kb := newTestKafkaBroker(t, "test")
+	kb.topicError = 3
+	ks := newKafkaSource(testKafkaOptions(kb, options.StartOffsetLatest), "test")
+	if err := ks.consume(make(chan struct{}), nil); err == nil {
+		t.Error("expected error for unknown topic")
+	}
+
+	kb.ln.Close()
+	if err := ks.consume(make(chan struct{}), nil); err == nil {
+		t.Error("expected error for unavailable brokers")
+	}
+}
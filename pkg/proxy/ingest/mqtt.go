@@ -0,0 +1,303 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+	mqttProtocolLvl = 4
+)
+
+// mqttKeepAlive is the keep alive interval sent to the broker. A PINGREQ is sent at half the
+// interval, and the connection is considered failed when nothing is received for 1.5 intervals
+const mqttKeepAlive = 60 * time.Second
+
+// mqttSubscribeID is the packet identifier of the subscription
+const mqttSubscribeID = 1
+
+// mqttSource subscribes to an MQTT topic filter on the first broker that accepts the
+// connection. It uses a clean session, so messages published while it is disconnected
+// are not delivered.
+type mqttSource struct {
+	options  *options.Options
+	clientID string
+	dialer   *net.Dialer
+}
+
+func newMQTTSource(o *options.Options, clientID string) *mqttSource {
+	return &mqttSource{
+		options:  o,
+		clientID: clientID,
+		dialer:   &net.Dialer{Timeout: 10 * time.Second},
+	}
+}
+
+// mqttConn is a connection to an MQTT broker. Writes are serialized, since PINGREQ
+// packets are written concurrently with the acknowledgement of received messages
+type mqttConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mtx  sync.Mutex
+}
+
+func (ms *mqttSource) consume(quit <-chan struct{}, handle func([]byte, error)) error {
+	c, err := ms.connect()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-quit:
+			c.write(mqttDisconnect<<4, nil)
+		case <-done:
+		}
+		c.conn.Close()
+	}()
+	go c.ping(done)
+
+	if err = ms.subscribe(c); err != nil {
+		return err
+	}
+
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(mqttKeepAlive * 3 / 2))
+		header, n, err := c.readHeader()
+		if err != nil {
+			select {
+			case <-quit:
+				// the connection was closed after the DISCONNECT
+				return nil
+			default:
+			}
+			return err
+		}
+		if header>>4 != mqttPublish {
+			if _, err = c.r.Discard(n); err != nil {
+				return err
+			}
+			continue
+		}
+		if n > ms.options.MaxMessageBytes+65542 {
+			// the topic name and packet identifier take up to 65537 additional bytes
+			if _, err = c.r.Discard(n); err != nil {
+				return err
+			}
+			handle(nil, errMessageTooLarge)
+			continue
+		}
+		b := make([]byte, n)
+		if _, err = io.ReadFull(c.r, b); err != nil {
+			return err
+		}
+		d := &decoder{b: b}
+		d.string() // topic
+		if qos := (header >> 1) & 3; qos > 0 {
+			id := d.int16()
+			if d.err == nil {
+				e := &encoder{}
+				e.int16(id)
+				if err = c.write(mqttPuback<<4, e.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+		if d.err != nil {
+			handle(nil, errors.New("invalid mqtt publish packet"))
+			continue
+		}
+		if len(d.b) > ms.options.MaxMessageBytes {
+			handle(nil, errMessageTooLarge)
+			continue
+		}
+		handle(d.b, nil)
+	}
+}
+
+// connect returns a connection to the first broker that accepts it
+func (ms *mqttSource) connect() (*mqttConn, error) {
+	var err error
+	for _, addr := range ms.options.Brokers {
+		var conn net.Conn
+		if conn, err = ms.dialer.Dial("tcp", addr); err != nil {
+			continue
+		}
+		c := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+		if err = ms.handshake(c); err != nil {
+			conn.Close()
+			continue
+		}
+		return c, nil
+	}
+	return nil, err
+}
+
+// handshake sends the CONNECT packet and reads the broker's CONNACK
+func (ms *mqttSource) handshake(c *mqttConn) error {
+	var flags int8 = 0x02 // clean session
+	if ms.options.Username != "" {
+		flags |= int8(-0x80)
+		if ms.options.Password != "" {
+			flags |= 0x40
+		}
+	}
+	e := &encoder{}
+	e.string("MQTT")
+	e.int8(mqttProtocolLvl)
+	e.int8(flags)
+	e.int16(int16(mqttKeepAlive / time.Second))
+	e.string(ms.clientID)
+	if ms.options.Username != "" {
+		e.string(ms.options.Username)
+		if ms.options.Password != "" {
+			e.string(ms.options.Password)
+		}
+	}
+
+	c.conn.SetDeadline(time.Now().Add(requestTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if err := c.write(mqttConnect<<4, e.Bytes()); err != nil {
+		return err
+	}
+	header, n, err := c.readHeader()
+	if err != nil {
+		return err
+	}
+	if header>>4 != mqttConnack || n != 2 {
+		return errors.New("invalid mqtt connack packet")
+	}
+	var b [2]byte
+	if _, err = io.ReadFull(c.r, b[:]); err != nil {
+		return err
+	}
+	if b[1] != 0 {
+		return fmt.Errorf("mqtt connection refused with return code %d", b[1])
+	}
+	return nil
+}
+
+// subscribe sends the SUBSCRIBE packet for the topic filter, and reads the broker's SUBACK.
+// Messages received before the SUBACK are not possible with a clean session
+func (ms *mqttSource) subscribe(c *mqttConn) error {
+	e := &encoder{}
+	e.int16(mqttSubscribeID)
+	e.string(ms.options.Topic)
+	e.int8(int8(ms.options.QoS))
+
+	c.conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	if err := c.write(mqttSubscribe<<4|0x02, e.Bytes()); err != nil {
+		return err
+	}
+	header, n, err := c.readHeader()
+	if err != nil {
+		return err
+	}
+	if header>>4 != mqttSuback || n != 3 {
+		return errors.New("invalid mqtt suback packet")
+	}
+	var b [3]byte
+	if _, err = io.ReadFull(c.r, b[:]); err != nil {
+		return err
+	}
+	if b[2]&0x80 != 0 {
+		return fmt.Errorf("mqtt subscription to %s was refused", ms.options.Topic)
+	}
+	return nil
+}
+
+// ping sends a PINGREQ at half the keep alive interval until done is closed
+func (c *mqttConn) ping(done <-chan struct{}) {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if c.write(mqttPingreq<<4, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// write sends a packet with the provided fixed header byte and remaining content
+func (c *mqttConn) write(header byte, b []byte) error {
+	e := &encoder{}
+	e.WriteByte(header)
+	n := len(b)
+	for {
+		v := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			v |= 0x80
+		}
+		e.WriteByte(v)
+		if n == 0 {
+			break
+		}
+	}
+	e.Write(b)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_, err := c.conn.Write(e.Bytes())
+	return err
+}
+
+// readHeader reads the fixed header of a packet, returning its first byte and the length of
+// the remainder of the packet
+func (c *mqttConn) readHeader() (byte, int, error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	var n, shift int
+	for i := 0; i < 4; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return header, n, nil
+		}
+		shift += 7
+	}
+	return 0, 0, errors.New("invalid mqtt remaining length")
+}
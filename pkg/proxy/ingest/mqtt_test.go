@@ -0,0 +1,241 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+)
+
+// testMQTTBroker accepts a single connection and subscription, then publishes its messages
+type testMQTTBroker struct {
+	ln         net.Listener
+	connackRC  byte
+	subackRC   byte
+	messages   [][]byte
+	clientID   string
+	username   string
+	password   string
+	topic      string
+	acked      chan int16
+	disconnect chan struct{}
+}
+
+func newTestMQTTBroker(t *testing.T, connackRC, subackRC byte,
+	messages ...[]byte) *testMQTTBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &testMQTTBroker{ln: ln, connackRC: connackRC, subackRC: subackRC, messages: messages,
+		acked: make(chan int16, len(messages)), disconnect: make(chan struct{})}
+	go mb.serve()
+	return mb
+}
+
+func (mb *testMQTTBroker) serve() {
+	conn, err := mb.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+
+	// CONNECT
+	_, n, err := c.readHeader()
+	if err != nil {
+		return
+	}
+	b := make([]byte, n)
+	io.ReadFull(c.r, b)
+	d := &decoder{b: b}
+	d.string() // protocol name
+	d.int8()   // protocol level
+	flags := d.int8()
+	d.int16() // keep alive
+	mb.clientID = d.string()
+	if flags&int8(-0x80) != 0 {
+		mb.username = d.string()
+	}
+	if flags&0x40 != 0 {
+		mb.password = d.string()
+	}
+	c.write(mqttConnack<<4, []byte{0, mb.connackRC})
+	if mb.connackRC != 0 {
+		return
+	}
+
+	// SUBSCRIBE
+	if _, n, err = c.readHeader(); err != nil {
+		return
+	}
+	b = make([]byte, n)
+	io.ReadFull(c.r, b)
+	d = &decoder{b: b}
+	id := d.int16()
+	mb.topic = d.string()
+	qos := d.int8()
+	rc := byte(qos)
+	if mb.subackRC != 0 {
+		rc = mb.subackRC
+	}
+	e := &encoder{}
+	e.int16(id)
+	e.WriteByte(rc)
+	c.write(mqttSuback<<4, e.Bytes())
+
+	// a PINGRESP is ignored by the subscriber
+	c.write(mqttPingresp<<4, nil)
+
+	for i, m := range mb.messages {
+		e = &encoder{}
+		e.string(mb.topic)
+		header := byte(mqttPublish << 4)
+		if qos > 0 {
+			header |= byte(qos) << 1
+			e.int16(int16(i + 1))
+		}
+		e.Write(m)
+		c.write(header, e.Bytes())
+	}
+
+	for {
+		header, n, err := c.readHeader()
+		if err != nil {
+			return
+		}
+		b = make([]byte, n)
+		io.ReadFull(c.r, b)
+		switch header >> 4 {
+		case mqttPuback:
+			d = &decoder{b: b}
+			mb.acked <- d.int16()
+		case mqttDisconnect:
+			close(mb.disconnect)
+			return
+		}
+	}
+}
+
+func testMQTTOptions(mb *testMQTTBroker) *options.Options {
+	o := options.NewOptions()
+	o.Source = options.SourceMQTT
+	o.Brokers = []string{"127.0.0.1:1", mb.ln.Addr().String()}
+	o.Topic = "trickster/#"
+	o.Username = "user"
+	o.Password = "pass"
+	o.MaxMessageBytes = 8
+	return o
+}
+
+func TestMQTTSource(t *testing.T) {
+
+	mb := newTestMQTTBroker(t, 0, 0, []byte("a"), bytes.Repeat([]byte("x"), 9), []byte("b"))
+	defer mb.ln.Close()
+
+	var errs []error
+	ms := newMQTTSource(testMQTTOptions(mb), "test-client")
+	var values []string
+	quit := make(chan struct{})
+	err := ms.consume(quit, func(b []byte, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		values = append(values, string(b))
+		if len(values) == 2 {
+			close(quit)
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if joinStrings(values) != "a,b" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if len(errs) != 1 || errs[0] != errMessageTooLarge {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if mb.clientID != "test-client" || mb.username != "user" || mb.password != "pass" {
+		t.Errorf("unexpected connect %s %s %s", mb.clientID, mb.username, mb.password)
+	}
+	if mb.topic != "trickster/#" {
+		t.Errorf("expected %s got %s", "trickster/#", mb.topic)
+	}
+	// each QoS 1 message is acknowledged, including the oversized one
+	for i := int16(1); i <= 3; i++ {
+		if id := <-mb.acked; id != i {
+			t.Errorf("expected %d got %d", i, id)
+		}
+	}
+	<-mb.disconnect
+}
+
+func TestMQTTSourceQoS0(t *testing.T) {
+
+	mb := newTestMQTTBroker(t, 0, 0, []byte("a"))
+	defer mb.ln.Close()
+
+	o := testMQTTOptions(mb)
+	o.QoS = 0
+	o.Username = ""
+	ms := newMQTTSource(o, "test-client")
+	quit := make(chan struct{})
+	err := ms.consume(quit, func(b []byte, err error) {
+		if string(b) != "a" {
+			t.Errorf("expected %s got %s", "a", string(b))
+		}
+		close(quit)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	<-mb.disconnect
+	if len(mb.acked) != 0 {
+		t.Errorf("expected no acknowledgements, got %d", len(mb.acked))
+	}
+}
+
+func TestMQTTSourceErrors(t *testing.T) {
+
+	mb := newTestMQTTBroker(t, 5, 0)
+	ms := newMQTTSource(testMQTTOptions(mb), "test-client")
+	if err := ms.consume(make(chan struct{}), nil); err == nil {
+		t.Error("expected error for refused connection")
+	}
+	mb.ln.Close()
+
+	mb = newTestMQTTBroker(t, 0, 0x80)
+	ms = newMQTTSource(testMQTTOptions(mb), "test-client")
+	if err := ms.consume(make(chan struct{}), nil); err == nil {
+		t.Error("expected error for refused subscription")
+	}
+	mb.ln.Close()
+
+	mb = newTestMQTTBroker(t, 0, 0)
+	ms = newMQTTSource(testMQTTOptions(mb), "test-client")
+	mb.ln.Close()
+	if err := ms.consume(make(chan struct{}), nil); err == nil {
+		t.Error("expected error for unavailable brokers")
+	}
+}
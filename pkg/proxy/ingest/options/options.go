@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the cache ingestion options for an origin
+package options
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Source types of the ingestion listener
+const (
+	// SourceKafka consumes the messages of a Kafka topic
+	SourceKafka = "kafka"
+	// SourceMQTT consumes the messages published to an MQTT topic
+	SourceMQTT = "mqtt"
+)
+
+// Start offsets of a Kafka source
+const (
+	// StartOffsetLatest consumes only the messages produced after the listener connects
+	StartOffsetLatest = "latest"
+	// StartOffsetEarliest consumes all of the messages retained by the topic
+	StartOffsetEarliest = "earliest"
+)
+
+// Options configures the ingestion of pre-computed timeseries into an origin's cache, from
+// the messages of a Kafka topic or MQTT topic
+type Options struct {
+	// Source is the type of message broker the messages are consumed from ('kafka' or 'mqtt').
+	// An empty Source disables ingestion
+	Source string `toml:"source"`
+	// Brokers is the list of host:port addresses of the brokers. Kafka brokers are used to
+	// discover the leaders of the topic's partitions, while MQTT brokers are tried in order
+	Brokers []string `toml:"brokers"`
+	// Topic is the Kafka topic, or the MQTT topic filter, that the messages are consumed from
+	Topic string `toml:"topic"`
+	// ClientID identifies the listener to the brokers. The default is derived from the server
+	// name and the origin name, so that each Trickster has a distinct MQTT client identifier
+	ClientID string `toml:"client_id"`
+	// Username is the MQTT username
+	Username string `toml:"username"`
+	// Password is the MQTT password
+	Password string `toml:"password"`
+	// QoS is the MQTT quality of service of the subscription (0 or 1)
+	QoS int `toml:"qos"`
+	// StartOffset is the offset of each Kafka partition that is consumed from when the listener
+	// starts ('latest' or 'earliest')
+	StartOffset string `toml:"start_offset"`
+	// ReconnectIntervalMS is the wait before reconnecting to the brokers after the connection fails
+	ReconnectIntervalMS int `toml:"reconnect_interval_ms"`
+	// MaxMessageBytes is the size limit of a message. Larger messages are discarded
+	MaxMessageBytes int `toml:"max_message_bytes"`
+
+	// ReconnectInterval is the time.Duration representation of ReconnectIntervalMS
+	ReconnectInterval time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		QoS:                 d.DefaultIngestQoS,
+		StartOffset:         d.DefaultIngestStartOffset,
+		ReconnectIntervalMS: d.DefaultIngestReconnectIntervalMS,
+		MaxMessageBytes:     d.DefaultIngestMaxMessageBytes,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	var brokers []string
+	if o.Brokers != nil {
+		brokers = make([]string, len(o.Brokers))
+		copy(brokers, o.Brokers)
+	}
+	return &Options{
+		Source:              o.Source,
+		Brokers:             brokers,
+		Topic:               o.Topic,
+		ClientID:            o.ClientID,
+		Username:            o.Username,
+		Password:            o.Password,
+		QoS:                 o.QoS,
+		StartOffset:         o.StartOffset,
+		ReconnectIntervalMS: o.ReconnectIntervalMS,
+		MaxMessageBytes:     o.MaxMessageBytes,
+		ReconnectInterval:   o.ReconnectInterval,
+	}
+}
+
+// Enabled returns true if an ingestion source is configured
+func (o *Options) Enabled() bool {
+	return o != nil && o.Source != ""
+}
+
+// SetDurations sets the time.Duration representations of the Options' millisecond-based values
+func (o *Options) SetDurations() {
+	o.ReconnectInterval = time.Duration(o.ReconnectIntervalMS) * time.Millisecond
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if !o.Enabled() {
+		return nil
+	}
+	if o.Source != SourceKafka && o.Source != SourceMQTT {
+		return errors.New("ingest source must be 'kafka' or 'mqtt'")
+	}
+	if len(o.Brokers) == 0 {
+		return errors.New("ingest brokers must be provided")
+	}
+	for _, b := range o.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			return errors.New("ingest brokers must be host:port addresses")
+		}
+	}
+	if o.Topic == "" {
+		return errors.New("ingest topic must be provided")
+	}
+	if o.QoS != 0 && o.QoS != 1 {
+		return errors.New("ingest qos must be 0 or 1")
+	}
+	if o.StartOffset != StartOffsetLatest && o.StartOffset != StartOffsetEarliest {
+		return errors.New("ingest start_offset must be 'latest' or 'earliest'")
+	}
+	if o.ReconnectIntervalMS <= 0 {
+		return errors.New("ingest reconnect_interval_ms must be positive")
+	}
+	if o.MaxMessageBytes <= 0 {
+		return errors.New("ingest max_message_bytes must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testOptions() *Options {
+	o := NewOptions()
+	o.Source = SourceKafka
+	o.Brokers = []string{"kafka:9092"}
+	o.Topic = "trickster"
+	return o
+}
+
+func TestValidate(t *testing.T) {
+
+	if err := NewOptions().Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if err := testOptions().Validate(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []func(*Options){
+		func(o *Options) { o.Source = "amqp" },
+		func(o *Options) { o.Brokers = nil },
+		func(o *Options) { o.Brokers = []string{"kafka"} },
+		func(o *Options) { o.Topic = "" },
+		func(o *Options) { o.QoS = 2 },
+		func(o *Options) { o.StartOffset = "newest" },
+		func(o *Options) { o.ReconnectIntervalMS = 0 },
+		func(o *Options) { o.MaxMessageBytes = 0 },
+	}
+
+	for i, f := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			o := testOptions()
+			f(o)
+			if err := o.Validate(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+}
+
+func TestClone(t *testing.T) {
+	o := testOptions()
+	o.ReconnectIntervalMS = 500
+	o.SetDurations()
+	o2 := o.Clone()
+	o2.Brokers[0] = "changed:9092"
+	if o2.Source != SourceKafka || o2.Topic != "trickster" ||
+		o2.ReconnectInterval != 500*time.Millisecond {
+		t.Errorf("unexpected clone %v", o2)
+	}
+	if o.Brokers[0] != "kafka:9092" {
+		t.Error("expected cloned brokers to be independent")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	var o *Options
+	if o.Enabled() {
+		t.Error("expected false")
+	}
+	if !testOptions().Enabled() {
+		t.Error("expected true")
+	}
+}
@@ -0,0 +1,313 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package invalidation provides a webhook through which signed requests, such as those of
+// CI/CD or data correction pipelines, invalidate an origin's cached objects by key, by key
+// prefix, by cache tag, or by the labels and time range of cached timeseries, and a purge
+// endpoint through which operators invalidate them by key or key prefix
+package invalidation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// maxRequestBytes is the largest invalidation request body that is accepted
+const maxRequestBytes = 1 << 20
+
+// signaturePrefix prefixes the hex-encoded HMAC-SHA256 signature of a request
+const signaturePrefix = "sha256="
+
+// Request describes the cached objects of an origin that are to be invalidated. Keys and
+// Prefixes are relative to the origin's cache key prefix. When none of Keys, Prefixes or Tags
+// are provided, all of the origin's cached objects are selected. When Labels, StartMS or EndMS are provided, only the
+// selected timeseries having a matching series within the time range are invalidated, and
+// when Partial is set, only their data within the time range is removed.
+type Request struct {
+	// Origin is the name of the origin whose cached objects are invalidated
+	Origin string `json:"origin"`
+	// Keys are the cache keys of the objects to invalidate
+	Keys []string `json:"keys,omitempty"`
+	// Prefixes select the objects whose cache keys begin with any of the prefixes
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Tags select the objects associated with any of the cache tags
+	Tags []string `json:"tags,omitempty"`
+	// Labels selects the timeseries having a series with each of the labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// StartMS and EndMS select the timeseries with cached data in the time range, in
+	// milliseconds since the epoch. Either may be omitted to leave that end of the range open
+	StartMS int64 `json:"start_ms,omitempty"`
+	EndMS   int64 `json:"end_ms,omitempty"`
+	// Soft marks the selected objects stale rather than removing them, so they are
+	// revalidated on their next access, and can still be served if the origin fails
+	Soft bool `json:"soft,omitempty"`
+	// Partial removes only the data within the time range from the selected timeseries,
+	// leaving the rest of their cached data in place
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Mode returns the invalidation mode of the request, which is soft, partial or hard
+func (r *Request) Mode() string {
+	if r.Soft {
+		return "soft"
+	}
+	if r.Partial {
+		return "partial"
+	}
+	return "hard"
+}
+
+// Filtered returns true if the selected objects are filtered by label or time range
+func (r *Request) Filtered() bool {
+	return len(r.Labels) > 0 || r.StartMS > 0 || r.EndMS > 0
+}
+
+// Start returns the start of the request's time range, or the zero time if it is open
+func (r *Request) Start() time.Time {
+	if r.StartMS <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, r.StartMS*int64(time.Millisecond))
+}
+
+// End returns the end of the request's time range, or the zero time if it is open
+func (r *Request) End() time.Time {
+	if r.EndMS <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, r.EndMS*int64(time.Millisecond))
+}
+
+// ParseRequest decodes and validates a JSON-encoded invalidation request
+func ParseRequest(b []byte) (*Request, error) {
+	r := &Request{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParsePurgeRequest returns the validated invalidation request described by the query
+// parameters of a purge request. The origin parameter names the origin, and the key and prefix
+// parameters, which may be repeated, select its cached objects. When neither is provided, all
+// of the origin's cached objects are selected. Purged objects are removed unless soft is true
+func ParsePurgeRequest(v url.Values) (*Request, error) {
+	r := &Request{
+		Origin:   v.Get("origin"),
+		Keys:     v["key"],
+		Prefixes: v["prefix"],
+	}
+	if s := v.Get("soft"); s != "" {
+		soft, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.New("invalid soft value")
+		}
+		r.Soft = soft
+	}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// validate returns an error if the request is incomplete or contradictory
+func (r *Request) validate() error {
+	if r.Origin == "" {
+		return errors.New("missing origin")
+	}
+	if (len(r.Keys) > 0 && len(r.Prefixes) > 0) || (len(r.Tags) > 0 &&
+		(len(r.Keys) > 0 || len(r.Prefixes) > 0)) {
+		return errors.New("keys, prefixes and tags are mutually exclusive")
+	}
+	for _, k := range r.Keys {
+		if k == "" {
+			return errors.New("invalid empty key")
+		}
+	}
+	for _, t := range r.Tags {
+		if t == "" {
+			return errors.New("invalid empty tag")
+		}
+	}
+	if r.StartMS < 0 || r.EndMS < 0 || (r.EndMS > 0 && r.StartMS > r.EndMS) {
+		return errors.New("invalid time range")
+	}
+	if r.Partial {
+		if r.Soft {
+			return errors.New("partial and soft are mutually exclusive")
+		}
+		if r.StartMS == 0 && r.EndMS == 0 {
+			return errors.New("partial invalidation requires a time range")
+		}
+	}
+	return nil
+}
+
+// Result describes the outcome of an invalidation request
+type Result struct {
+	Origin string `json:"origin"`
+	// Invalidated is the number of cached objects that were invalidated
+	Invalidated int `json:"invalidated"`
+	// Mode is soft when the objects were marked stale, partial when their data within the
+	// time range was removed, and hard when they were removed
+	Mode string `json:"mode"`
+}
+
+// InvalidateFunc invalidates the origin's cached objects described by the request, and
+// returns the number of objects that were invalidated
+type InvalidateFunc func(r *Request) (int, error)
+
+type origin struct {
+	originType string
+	invalidate InvalidateFunc
+}
+
+// Invalidator verifies the signatures of invalidation requests, and passes them to the
+// InvalidateFunc of the requested origin
+type Invalidator struct {
+	options *options.Options
+	origins map[string]origin
+	now     func() time.Time
+}
+
+// New returns a new Invalidator
+func New(o *options.Options) *Invalidator {
+	return &Invalidator{
+		options: o,
+		origins: make(map[string]origin),
+		now:     time.Now,
+	}
+}
+
+// Register sets the function that invalidates the named origin's cached objects. Origins
+// must be registered before the Handler serves requests
+func (iv *Invalidator) Register(originName, originType string, f InvalidateFunc) {
+	iv.origins[originName] = origin{originType: originType, invalidate: f}
+}
+
+// Sign returns the value of the signature header of a request with the provided timestamp
+// header value and body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns an error if the request's signature is not valid for the body, or its
+// timestamp is not within the maximum skew of the current time
+func (iv *Invalidator) verify(r *http.Request, body []byte) error {
+	ts := r.Header.Get(headers.NameTricksterTimestamp)
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	skew := iv.now().Sub(time.Unix(secs, 0))
+	if skew > iv.options.MaxSkew || skew < -iv.options.MaxSkew {
+		return errors.New("timestamp is outside of the allowed skew")
+	}
+	if !hmac.Equal([]byte(r.Header.Get(headers.NameTricksterSignature)),
+		[]byte(Sign(iv.options.SharedSecret, ts, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Handler returns an http.Handler that invalidates the cached objects described by signed
+// invalidation requests
+func (iv *Invalidator) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestBytes+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxRequestBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		// the signature is verified before anything else about the request is trusted
+		if err = iv.verify(r, body); err != nil {
+			metrics.ProxyInvalidationRequests.WithLabelValues("", "", "unauthorized").Inc()
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		req, err := ParseRequest(body)
+		if err != nil {
+			metrics.ProxyInvalidationRequests.WithLabelValues("", "", "invalid").Inc()
+			http.Error(w, "invalid invalidation request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		iv.invalidate(w, req)
+	})
+}
+
+// PurgeHandler returns an http.Handler that invalidates the cached objects described by the
+// query parameters of purge requests. It does not verify the requests, so it must only be
+// served to clients that are authenticated by other means
+func (iv *Invalidator) PurgeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := ParsePurgeRequest(r.URL.Query())
+		if err != nil {
+			metrics.ProxyInvalidationRequests.WithLabelValues("", "", "invalid").Inc()
+			http.Error(w, "invalid purge request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		iv.invalidate(w, req)
+	})
+}
+
+// invalidate passes the request to the InvalidateFunc of its origin, and writes the Result
+func (iv *Invalidator) invalidate(w http.ResponseWriter, req *Request) {
+	o, ok := iv.origins[req.Origin]
+	if !ok {
+		metrics.ProxyInvalidationRequests.WithLabelValues("", "", "invalid").Inc()
+		http.Error(w, fmt.Sprintf("unknown origin %s", req.Origin), http.StatusNotFound)
+		return
+	}
+	n, err := o.invalidate(req)
+	if n > 0 {
+		metrics.ProxyInvalidatedObjects.WithLabelValues(req.Origin, o.originType,
+			req.Mode()).Add(float64(n))
+	}
+	if err != nil {
+		metrics.ProxyInvalidationRequests.WithLabelValues(req.Origin, o.originType, "failed").Inc()
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	metrics.ProxyInvalidationRequests.WithLabelValues(req.Origin, o.originType, "success").Inc()
+	b, _ := json.Marshal(&Result{Origin: req.Origin, Invalidated: n, Mode: req.Mode()})
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Write(b)
+}
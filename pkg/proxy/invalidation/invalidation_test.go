@@ -0,0 +1,282 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invalidation
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation/options"
+)
+
+const testSecret = "secret"
+
+func testInvalidator(now time.Time) *Invalidator {
+	o := &options.Options{SharedSecret: testSecret, MaxSkewSecs: 60}
+	o.SetDurations()
+	iv := New(o)
+	iv.now = func() time.Time { return now }
+	return iv
+}
+
+func signedRequest(secret string, ts time.Time, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/trickster/invalidate", strings.NewReader(body))
+	t := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(headers.NameTricksterTimestamp, t)
+	r.Header.Set(headers.NameTricksterSignature, Sign(secret, t, []byte(body)))
+	return r
+}
+
+func TestParseRequest(t *testing.T) {
+
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{`{"origin":"test"}`, true},
+		{`{"origin":"test","keys":[".dpc.a"]}`, true},
+		{`{"origin":"test","prefixes":[".dpc."],"labels":{"job":"node"}}`, true},
+		{`{"origin":"test","start_ms":1000,"end_ms":2000}`, true},
+		{`{"origin":"test","start_ms":1000}`, true},
+		{`{"keys":[".dpc.a"]}`, false},
+		{`{"origin":"test","keys":[".dpc.a"],"prefixes":[".dpc."]}`, false},
+		{`{"origin":"test","keys":[""]}`, false},
+		{`{"origin":"test","tags":["tenant-a"]}`, true},
+		{`{"origin":"test","tags":["tenant-a"],"prefixes":[".dpc."]}`, false},
+		{`{"origin":"test","tags":[""]}`, false},
+		{`{"origin":"test","start_ms":2000,"end_ms":1000}`, false},
+		{`{"origin":"test","start_ms":-1}`, false},
+		{`{"origin":"test","start_ms":1000,"partial":true}`, true},
+		{`{"origin":"test","partial":true}`, false},
+		{`{"origin":"test","start_ms":1000,"partial":true,"soft":true}`, false},
+		{`invalid`, false},
+	}
+
+	for i, test := range tests {
+		_, err := ParseRequest([]byte(test.body))
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid %t got error %v", i, test.valid, err)
+		}
+	}
+}
+
+func TestRequestMode(t *testing.T) {
+	tests := []struct {
+		r        *Request
+		expected string
+	}{
+		{&Request{}, "hard"},
+		{&Request{Soft: true}, "soft"},
+		{&Request{Partial: true}, "partial"},
+	}
+	for i, test := range tests {
+		if m := test.r.Mode(); m != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, m)
+		}
+	}
+}
+
+func TestRequestTimeRange(t *testing.T) {
+
+	r := &Request{}
+	if r.Filtered() || !r.Start().IsZero() || !r.End().IsZero() {
+		t.Error("expected unfiltered request with an open time range")
+	}
+
+	r = &Request{StartMS: 1000, EndMS: 2000}
+	if !r.Filtered() {
+		t.Error("expected filtered request")
+	}
+	if !r.Start().Equal(time.Unix(1, 0)) || !r.End().Equal(time.Unix(2, 0)) {
+		t.Errorf("unexpected time range %s to %s", r.Start(), r.End())
+	}
+
+	r = &Request{Labels: map[string]string{"job": "node"}}
+	if !r.Filtered() {
+		t.Error("expected filtered request")
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	now := time.Now()
+	iv := testInvalidator(now)
+
+	var received *Request
+	var result int
+	var resultErr error
+	iv.Register("test", "prometheus", func(r *Request) (int, error) {
+		received = r
+		return result, resultErr
+	})
+	h := iv.Handler()
+
+	// a valid request is passed to the origin's InvalidateFunc
+	result = 2
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(testSecret, now, `{"origin":"test","keys":[".dpc.a",".dpc.b"]}`))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if received == nil || len(received.Keys) != 2 {
+		t.Errorf("unexpected request %v", received)
+	}
+	expected := `{"origin":"test","invalidated":2,"mode":"hard"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// a soft invalidation reports its mode
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(testSecret, now, `{"origin":"test","prefixes":[".opc."],"soft":true}`))
+	if received == nil || !received.Soft {
+		t.Errorf("unexpected request %v", received)
+	}
+	expected = `{"origin":"test","invalidated":2,"mode":"soft"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// a failed invalidation is reported
+	resultErr = errors.New("test error")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(testSecret, now, `{"origin":"test"}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	tests := []struct {
+		r        *http.Request
+		expected int
+	}{
+		// signed with a different secret
+		{signedRequest("other", now, `{"origin":"test"}`), http.StatusUnauthorized},
+		// signed too long ago, or too far in the future
+		{signedRequest(testSecret, now.Add(-2*time.Minute), `{"origin":"test"}`), http.StatusUnauthorized},
+		{signedRequest(testSecret, now.Add(2*time.Minute), `{"origin":"test"}`), http.StatusUnauthorized},
+		{signedRequest(testSecret, now, `{"origin":"unknown"}`), http.StatusNotFound},
+		{signedRequest(testSecret, now, `{"origin":""}`), http.StatusBadRequest},
+		{signedRequest(testSecret, now, strings.Repeat(" ", maxRequestBytes+1)),
+			http.StatusRequestEntityTooLarge},
+	}
+
+	// the body is altered after it was signed
+	r := signedRequest(testSecret, now, `{"origin":"test","keys":[".dpc.a"]}`)
+	r.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"origin":"test"}`))).Body
+	tests = append(tests, struct {
+		r        *http.Request
+		expected int
+	}{r, http.StatusUnauthorized})
+
+	// the timestamp is missing
+	r = signedRequest(testSecret, now, `{"origin":"test"}`)
+	r.Header.Del(headers.NameTricksterTimestamp)
+	tests = append(tests, struct {
+		r        *http.Request
+		expected int
+	}{r, http.StatusUnauthorized})
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.r)
+		if w.Code != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, w.Code)
+		}
+	}
+}
+
+func TestParsePurgeRequest(t *testing.T) {
+
+	r, err := ParsePurgeRequest(url.Values{"origin": {"test"}, "prefix": {".dpc.", ".opc."}})
+	if err != nil {
+		t.Error(err)
+	} else if r.Origin != "test" || len(r.Prefixes) != 2 || r.Soft {
+		t.Errorf("unexpected request %v", r)
+	}
+
+	r, err = ParsePurgeRequest(url.Values{"origin": {"test"}, "key": {".dpc.a"}, "soft": {"true"}})
+	if err != nil {
+		t.Error(err)
+	} else if len(r.Keys) != 1 || r.Mode() != "soft" {
+		t.Errorf("unexpected request %v", r)
+	}
+
+	tests := []url.Values{
+		{"key": {".dpc.a"}},
+		{"origin": {"test"}, "key": {".dpc.a"}, "prefix": {".dpc."}},
+		{"origin": {"test"}, "key": {""}},
+		{"origin": {"test"}, "soft": {"sometimes"}},
+	}
+	for i, v := range tests {
+		if _, err := ParsePurgeRequest(v); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
+
+func TestPurgeHandler(t *testing.T) {
+
+	iv := testInvalidator(time.Now())
+	var received *Request
+	iv.Register("test", "prometheus", func(r *Request) (int, error) {
+		received = r
+		return 3, nil
+	})
+	h := iv.PurgeHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+		"/trickster/purge?origin=test&prefix=.dpc.", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if received == nil || len(received.Prefixes) != 1 || received.Prefixes[0] != ".dpc." {
+		t.Errorf("unexpected request %v", received)
+	}
+	expected := `{"origin":"test","invalidated":3,"mode":"hard"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/trickster/purge?origin=unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/trickster/purge?key=.dpc.a", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1577836800.{}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=fb3cd23aa4650f6a5fa5da8475709bf246f09163720d52e447c3482eb13c65e5"
+	if s := Sign(testSecret, "1577836800", []byte("{}")); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+}
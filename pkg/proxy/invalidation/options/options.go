@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the cache invalidation webhook options
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the webhook through which signed requests invalidate cached objects
+type Options struct {
+	// SharedSecret is the HMAC-SHA256 key with which invalidation requests are signed. An empty
+	// secret disables the webhook
+	SharedSecret string `toml:"shared_secret"`
+	// MaxSkewSecs is the maximum difference between the signed timestamp of an invalidation
+	// request and the current time, beyond which the request is rejected as a possible replay
+	MaxSkewSecs int `toml:"max_skew_secs"`
+
+	// MaxSkew is the time.Duration representation of MaxSkewSecs
+	MaxSkew time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{MaxSkewSecs: d.DefaultInvalidationMaxSkewSecs}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		SharedSecret: o.SharedSecret,
+		MaxSkewSecs:  o.MaxSkewSecs,
+		MaxSkew:      o.MaxSkew,
+	}
+}
+
+// Enabled returns true if a shared secret is configured
+func (o *Options) Enabled() bool {
+	return o != nil && o.SharedSecret != ""
+}
+
+// SetDurations sets the time.Duration representations of the Options' second-based values
+func (o *Options) SetDurations() {
+	o.MaxSkew = time.Duration(o.MaxSkewSecs) * time.Second
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.MaxSkewSecs <= 0 {
+		return errors.New("invalidation max_skew_secs must be positive")
+	}
+	return nil
+}
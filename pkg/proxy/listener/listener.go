@@ -19,10 +19,10 @@ package listener
 import (
 	"context"
 	"crypto/tls"
-	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -93,16 +93,60 @@ func (l *Listener) RouteSwapper() *ph.SwitchHandler {
 // ListenerGroup is a collection of listeners
 type ListenerGroup struct {
 	members       map[string]*Listener
+	inherited     map[string][]net.Listener
 	listenersLock sync.Mutex
 }
 
+// Network returns the network name passed to net.Listen for the provided listener address
+// family. 'tcp4' and 'tcp6' bind to a single family, while 'dual' (or any other value) binds
+// to both where the host supports it
+func Network(family string) string {
+	switch family {
+	case "tcp4", "tcp6":
+		return family
+	}
+	return "tcp"
+}
+
+// Addresses returns the listen addresses when provided, otherwise the single address
+func Addresses(address string, addresses []string) []string {
+	if len(addresses) > 0 {
+		return addresses
+	}
+	return []string{address}
+}
+
 // NewListenerGroup returns a new ListenerGroup
 func NewListenerGroup() *ListenerGroup {
 	return &ListenerGroup{
-		members: make(map[string]*Listener),
+		members:   make(map[string]*Listener),
+		inherited: make(map[string][]net.Listener),
 	}
 }
 
+// Inherit provides pre-bound sockets (e.g., from systemd socket activation) to be used by
+// the next start of the named listener, in place of binding new sockets
+func (lg *ListenerGroup) Inherit(listenerName string, listeners []net.Listener) {
+	if len(listeners) == 0 {
+		return
+	}
+	lg.listenersLock.Lock()
+	lg.inherited[listenerName] = listeners
+	lg.listenersLock.Unlock()
+}
+
+// inheritedListeners returns and removes any inherited sockets for the named listener
+func (lg *ListenerGroup) inheritedListeners(listenerName string) []net.Listener {
+	lg.listenersLock.Lock()
+	defer lg.listenersLock.Unlock()
+	ls, ok := lg.inherited[listenerName]
+	if !ok {
+		return nil
+	}
+	delete(lg.inherited, listenerName)
+	return ls
+}
+
 // NewListener creates a new network listener which obeys to the configuration max
 // connection limit, monitors connections with prometheus metrics, and is able
 // to be gracefully drained
@@ -117,39 +161,63 @@ func NewListenerGroup() *ListenerGroup {
 // which observes the connections to set a gauge with the current number of
 // connections (with operates with sampling through scrapes), and a set of
 // counter metrics for connections accepted, rejected and closed.
-func NewListener(listenAddress string, listenPort, connectionsLimit int,
+//
+// When multiple listen addresses are provided, a socket is bound to each, and their
+// connections are accepted through the single returned listener.
+func NewListener(network string, listenAddresses []string, listenPort, connectionsLimit int,
 	tlsConfig *tls.Config, drainTimeout time.Duration, log *tl.Logger) (net.Listener, error) {
 
-	var listener net.Listener
-	var err error
+	if len(listenAddresses) == 0 {
+		listenAddresses = []string{""}
+	}
 
-	listenerType := "http"
+	listeners := make([]net.Listener, 0, len(listenAddresses))
+	for _, a := range listenAddresses {
+		l, err := net.Listen(network, net.JoinHostPort(a, strconv.Itoa(listenPort)))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			// so we can exit one level above, this usually means that the port is in use
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
 
+	listenerType := "http"
 	if tlsConfig != nil {
 		listenerType = "https"
-		listener, err = tls.Listen("tcp", fmt.Sprintf("%s:%d", listenAddress, listenPort), tlsConfig)
-	} else {
-		listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", listenAddress, listenPort))
-	}
-	if err != nil {
-		// so we can exit one level above, this usually means that the port is in use
-		return nil, err
-	}
-
-	if connectionsLimit > 0 {
-		listener = netutil.LimitListener(listener, connectionsLimit)
-		metrics.ProxyMaxConnections.Set(float64(connectionsLimit))
 	}
 
 	log.Debug("starting proxy listener", tl.Pairs{
 		"connectionsLimit": connectionsLimit,
 		"scheme":           listenerType,
-		"address":          listenAddress,
+		"network":          network,
+		"addresses":        listenAddresses,
 		"port":             listenPort,
 	})
 
-	return listener, nil
+	return WrapListeners(listeners, connectionsLimit, tlsConfig), nil
+
+}
+
+// WrapListeners accepts connections from the provided bound sockets through a single
+// listener that obeys the connection limit and, when a TLS config is provided, serves TLS
+func WrapListeners(listeners []net.Listener, connectionsLimit int,
+	tlsConfig *tls.Config) net.Listener {
 
+	listener := newMultiListener(listeners)
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	if connectionsLimit > 0 {
+		listener = netutil.LimitListener(listener, connectionsLimit)
+		metrics.ProxyMaxConnections.Set(float64(connectionsLimit))
+	}
+
+	return listener
 }
 
 // Get returns the listener if it exists
@@ -164,14 +232,17 @@ func (lg *ListenerGroup) Get(name string) *Listener {
 }
 
 // StartListener starts a new HTTP listener and adds it to the listener group
-func (lg *ListenerGroup) StartListener(listenerName, address string, port int, connectionsLimit int,
+func (lg *ListenerGroup) StartListener(listenerName, network string, addresses []string,
+	port int, connectionsLimit int,
 	tlsConfig *tls.Config, router http.Handler, wg *sync.WaitGroup, tracers tracing.Tracers,
 	exitOnError bool, drainTimeout time.Duration, log *tl.Logger) error {
 	if wg != nil {
 		defer wg.Done()
 	}
 	l := &Listener{routeSwapper: ph.NewSwitchHandler(router), exitOnError: exitOnError}
-	if tlsConfig != nil && len(tlsConfig.Certificates) > 0 {
+	// the swapper is used even when there are no certificates yet, so that certificates
+	// obtained later, such as from an ACME certificate authority, can be swapped in
+	if tlsConfig != nil {
 		l.tlsConfig = tlsConfig
 		l.tlsSwapper = sw.NewSwapper(tlsConfig.Certificates)
 		// Replace the normal GetCertificate function in the TLS config with lg.tlsSwapper's,
@@ -181,7 +252,14 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 	}
 
 	var err error
-	l.Listener, err = NewListener(address, port, connectionsLimit, tlsConfig, drainTimeout, log)
+	if inherited := lg.inheritedListeners(listenerName); len(inherited) > 0 {
+		l.Listener = WrapListeners(inherited, connectionsLimit, tlsConfig)
+		log.Info("using inherited sockets for listener",
+			tl.Pairs{"name": listenerName, "sockets": len(inherited)})
+	} else {
+		l.Listener, err = NewListener(network, addresses, port, connectionsLimit,
+			tlsConfig, drainTimeout, log)
+	}
 	if err != nil {
 		log.Error("http listener startup failed", tl.Pairs{"name": listenerName, "detail": err})
 		if exitOnError {
@@ -190,7 +268,7 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 		return err
 	}
 	log.Info("http listener starting",
-		tl.Pairs{"name": listenerName, "port": port, "address": address})
+		tl.Pairs{"name": listenerName, "port": port, "network": network, "addresses": addresses})
 
 	lg.listenersLock.Lock()
 	lg.members[listenerName] = l
@@ -236,12 +314,13 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 }
 
 // StartListenerRouter starts a new HTTP listener with a new router, and adds it to the listener group
-func (lg *ListenerGroup) StartListenerRouter(listenerName, address string, port int, connectionsLimit int,
+func (lg *ListenerGroup) StartListenerRouter(listenerName, network string, addresses []string,
+	port int, connectionsLimit int,
 	tlsConfig *tls.Config, path string, handler http.Handler, wg *sync.WaitGroup,
 	tracers tracing.Tracers, exitOnError bool, drainTimeout time.Duration, log *tl.Logger) error {
 	router := http.NewServeMux()
 	router.Handle(path, handler)
-	return lg.StartListener(listenerName, address, port, connectionsLimit,
+	return lg.StartListener(listenerName, network, addresses, port, connectionsLimit,
 		tlsConfig, router, wg, tracers, exitOnError, drainTimeout, log)
 }
 
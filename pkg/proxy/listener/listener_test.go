@@ -62,7 +62,7 @@ func TestListeners(t *testing.T) {
 		}
 
 		err = testLG.StartListener("httpListener",
-			"", 0, 20, tc, http.NewServeMux(), wg, trs, false, 0, tl.ConsoleLogger("info"))
+			"tcp", nil, 0, 20, tc, http.NewServeMux(), wg, trs, false, 0, tl.ConsoleLogger("info"))
 	}()
 
 	time.Sleep(time.Millisecond * 300)
@@ -76,7 +76,7 @@ func TestListeners(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		err = testLG.StartListenerRouter("httpListener2",
-			"", 0, 20, nil, "/", http.HandlerFunc(handlers.HandleLocalResponse), wg,
+			"tcp", nil, 0, 20, nil, "/", http.HandlerFunc(handlers.HandleLocalResponse), wg,
 			nil, false, 0, tl.ConsoleLogger("info"))
 	}()
 	time.Sleep(time.Millisecond * 300)
@@ -89,7 +89,7 @@ func TestListeners(t *testing.T) {
 
 	wg.Add(1)
 	err = testLG.StartListener("testBadPort",
-		"", -31, 20, nil, http.NewServeMux(), wg, trs, false, 0, tl.ConsoleLogger("info"))
+		"tcp", nil, -31, 20, nil, http.NewServeMux(), wg, trs, false, 0, tl.ConsoleLogger("info"))
 	if err == nil {
 		t.Error("expected invalid port error")
 	}
@@ -107,13 +107,130 @@ func TestUpdateRouter(t *testing.T) {
 
 func TestNewListenerErr(t *testing.T) {
 	config.NewConfig()
-	l, err := NewListener("-", 0, 0, nil, 0, tl.ConsoleLogger("error"))
+	l, err := NewListener("tcp", []string{"-"}, 0, 0, nil, 0, tl.ConsoleLogger("error"))
 	if err == nil {
 		l.Close()
 		t.Errorf("expected error: %s", `listen tcp: lookup -: no such host`)
 	}
 }
 
+func TestNetwork(t *testing.T) {
+	tests := map[string]string{"tcp4": "tcp4", "tcp6": "tcp6", "dual": "tcp", "": "tcp"}
+	for family, expected := range tests {
+		if n := Network(family); n != expected {
+			t.Errorf("expected %s got %s", expected, n)
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	a := Addresses("127.0.0.1", nil)
+	if len(a) != 1 || a[0] != "127.0.0.1" {
+		t.Errorf("expected %v got %v", []string{"127.0.0.1"}, a)
+	}
+	a = Addresses("127.0.0.1", []string{"127.0.0.2", "::1"})
+	if len(a) != 2 || a[1] != "::1" {
+		t.Errorf("expected %v got %v", []string{"127.0.0.2", "::1"}, a)
+	}
+}
+
+func TestNewListenerMultipleAddresses(t *testing.T) {
+
+	l, err := NewListener("tcp4", []string{"127.0.0.1", "127.0.0.2"}, 34004, 0, nil, 0,
+		tl.ConsoleLogger("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	for _, a := range []string{"127.0.0.1", "127.0.0.2"} {
+		resp, err := http.Get(fmt.Sprintf("http://%s:%d/", a, 34004))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		resp.Body.Close()
+	}
+
+	l.Close()
+	if _, err := l.Accept(); err != errors.ErrListenerClosed {
+		t.Errorf("expected %v got %v", errors.ErrListenerClosed, err)
+	}
+
+	// all addresses are released, so they can be bound again
+	l, err = NewListener("tcp4", []string{"127.0.0.1", "127.0.0.2"}, 34004, 0, nil, 0,
+		tl.ConsoleLogger("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestNewListenerMultipleAddressesErr(t *testing.T) {
+	l, err := NewListener("tcp", []string{"127.0.0.1", "-"}, 34005, 0, nil, 0, tl.ConsoleLogger("error"))
+	if err == nil {
+		l.Close()
+		t.Fatal("expected error for invalid address")
+	}
+	// the first address should have been released
+	l, err = NewListener("tcp", []string{"127.0.0.1"}, 34005, 0, nil, 0, tl.ConsoleLogger("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestNewListenerIPv6(t *testing.T) {
+	l, err := NewListener("tcp6", []string{"::1"}, 0, 0, nil, 0, tl.ConsoleLogger("error"))
+	if err != nil {
+		t.Skipf("ipv6 is unavailable: %s", err)
+	}
+	defer l.Close()
+	if a, ok := l.Addr().(*net.TCPAddr); !ok || a.IP.To4() != nil {
+		t.Errorf("expected ipv6 address, got %s", l.Addr())
+	}
+}
+
+func TestInherit(t *testing.T) {
+
+	testLG := NewListenerGroup()
+	testLG.Inherit("httpListener", nil)
+	if len(testLG.inherited) != 0 {
+		t.Errorf("expected %d got %d", 0, len(testLG.inherited))
+	}
+
+	inherited, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLG.Inherit("httpListener", []net.Listener{inherited})
+
+	// the configured port is ignored in favor of the inherited socket
+	go testLG.StartListener("httpListener", "tcp", nil, -31, 0, nil,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}), nil, nil, false, 0, tl.ConsoleLogger("error"))
+	time.Sleep(time.Millisecond * 300)
+
+	resp, err := http.Get("http://" + inherited.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected %d got %d", 200, resp.StatusCode)
+	}
+
+	// inherited sockets are only used once
+	if len(testLG.inheritedListeners("httpListener")) != 0 {
+		t.Error("expected inherited sockets to be consumed")
+	}
+
+	testLG.Get("httpListener").Close()
+}
+
 func TestNewListenerTLS(t *testing.T) {
 
 	c := config.NewConfig()
@@ -139,7 +256,7 @@ func TestNewListenerTLS(t *testing.T) {
 		t.Error(err)
 	}
 
-	l, err := NewListener("", 0, 0, tlsConfig, 0, tl.ConsoleLogger("error"))
+	l, err := NewListener("tcp", nil, 0, 0, tlsConfig, 0, tl.ConsoleLogger("error"))
 	if err != nil {
 		t.Error(err)
 	} else {
@@ -196,7 +313,7 @@ func TestListenerConnectionLimitWorks(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.Name, func(t *testing.T) {
-			l, err := NewListener("", tc.ListenPort, tc.ConnectionsLimit, nil, 0, tl.ConsoleLogger("error"))
+			l, err := NewListener("tcp", nil, tc.ListenPort, tc.ConnectionsLimit, nil, 0, tl.ConsoleLogger("error"))
 			if err != nil {
 				t.Fatal(err)
 			} else {
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"net"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+)
+
+// multiListener accepts connections from several bound sockets, so that one http.Server
+// can serve each of a listener's configured addresses
+type multiListener struct {
+	listeners []net.Listener
+	accepts   chan accepted
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// newMultiListener returns a listener that accepts from all of the provided listeners.
+// When only one is provided, it is returned as-is
+func newMultiListener(listeners []net.Listener) net.Listener {
+	if len(listeners) == 1 {
+		return listeners[0]
+	}
+	ml := &multiListener{
+		listeners: listeners,
+		accepts:   make(chan accepted),
+		done:      make(chan struct{}),
+	}
+	for _, l := range listeners {
+		go ml.accept(l)
+	}
+	return ml
+}
+
+func (ml *multiListener) accept(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		select {
+		case ml.accepts <- accepted{conn: c, err: err}:
+		case <-ml.done:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener.Accept
+func (ml *multiListener) Accept() (net.Conn, error) {
+	select {
+	case a := <-ml.accepts:
+		return a.conn, a.err
+	case <-ml.done:
+		return nil, errors.ErrListenerClosed
+	}
+}
+
+// Close implements net.Listener.Close, closing all of the underlying listeners
+func (ml *multiListener) Close() error {
+	var err error
+	ml.closeOnce.Do(func() {
+		close(ml.done)
+		for _, l := range ml.listeners {
+			if lerr := l.Close(); lerr != nil && err == nil {
+				err = lerr
+			}
+		}
+	})
+	return err
+}
+
+// Addr implements net.Listener.Addr, returning the address of the first listener
+func (ml *multiListener) Addr() net.Addr {
+	return ml.listeners[0].Addr()
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// responseFormat enumerates the ClickHouse output formats (as set by a query's FORMAT clause)
+// that are handled by Trickster
+type responseFormat int
+
+const (
+	formatUnsupported = responseFormat(iota)
+	formatJSON
+	formatJSONCompact
+	formatJSONEachRow
+	formatPassThrough
+)
+
+// chFormats maps the upper-cased ClickHouse FORMAT names to their responseFormat. JSON variants
+// are cached and merged as time series, while the binary formats are cached as whole objects
+var chFormats = map[string]responseFormat{
+	"JSON":        formatJSON,
+	"JSONCOMPACT": formatJSONCompact,
+	"JSONEACHROW": formatJSONEachRow,
+	"ARROW":       formatPassThrough,
+	"ARROWSTREAM": formatPassThrough,
+	"NATIVE":      formatPassThrough,
+}
+
+// isJSON returns true if the format is one of the JSON formats that can be merged
+func (f responseFormat) isJSON() bool {
+	return f == formatJSON || f == formatJSONCompact || f == formatJSONEachRow
+}
+
+// formatFromParts returns the responseFormat of a query split into parts by findParts
+func formatFromParts(parts []string) responseFormat {
+	size := len(parts)
+	if size < 2 || sup(parts[size-2]) != "FORMAT" {
+		return formatUnsupported
+	}
+	return chFormats[sup(parts[size-1])]
+}
+
+// getQueryFormat returns the responseFormat requested by the provided raw query
+func getQueryFormat(query string) responseFormat {
+	return formatFromParts(findParts(query))
+}
+
+// orderedRow is a ClickHouse data row that marshals to a JSON object with its
+// keys in the order of the response's field definitions
+type orderedRow struct {
+	meta   []FieldDefinition
+	values ResponseValue
+}
+
+func (or orderedRow) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, fd := range or.meta {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(fd.Name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(or.values[fd.Name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// compactResponse is the JSON document structure for ClickHouse JSONCompact query results
+type compactResponse struct {
+	Meta    []FieldDefinition `json:"meta"`
+	RawData [][]interface{}   `json:"data"`
+	Rows    int               `json:"rows"`
+}
+
+// convertFormat converts a ClickHouse JSON format response body into the provided format
+func convertFormat(b []byte, f responseFormat) ([]byte, error) {
+	rsp := &Response{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	// retain the original numeric representations
+	d.UseNumber()
+	if err := d.Decode(rsp); err != nil {
+		return nil, err
+	}
+	switch f {
+	case formatJSONCompact:
+		crsp := &compactResponse{Meta: rsp.Meta, RawData: make([][]interface{}, len(rsp.RawData)),
+			Rows: len(rsp.RawData)}
+		for i, rv := range rsp.RawData {
+			row := make([]interface{}, len(rsp.Meta))
+			for j, fd := range rsp.Meta {
+				row[j] = rv[fd.Name]
+			}
+			crsp.RawData[i] = row
+		}
+		return json.Marshal(crsp)
+	case formatJSONEachRow:
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		for _, rv := range rsp.RawData {
+			if err := enc.Encode(orderedRow{meta: rsp.Meta, values: rv}); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	return b, nil
+}
+
+// formatWriter buffers a JSON response from the DeltaProxyCache engine, so it can be
+// re-serialized into the client's requested format once the response is complete
+type formatWriter struct {
+	http.ResponseWriter
+	format     responseFormat
+	statusCode int
+	body       *bytes.Buffer
+}
+
+func newFormatWriter(w http.ResponseWriter, f responseFormat) *formatWriter {
+	return &formatWriter{ResponseWriter: w, format: f, statusCode: http.StatusOK,
+		body: &bytes.Buffer{}}
+}
+
+// WriteHeader records the status code until the response is flushed
+func (fw *formatWriter) WriteHeader(code int) {
+	fw.statusCode = code
+}
+
+// Write buffers the response body until the response is flushed
+func (fw *formatWriter) Write(b []byte) (int, error) {
+	return fw.body.Write(b)
+}
+
+// flush writes the buffered response to the underlying ResponseWriter, converted to the
+// requested format when the response is a successful JSON document. Anything else, such
+// as an error or a response that is already in the requested format, is passed through
+func (fw *formatWriter) flush() {
+	b := fw.body.Bytes()
+	h := fw.ResponseWriter.Header()
+	if fw.statusCode == http.StatusOK &&
+		strings.HasPrefix(h.Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		if data, err := convertFormat(b, fw.format); err == nil {
+			b = data
+			h.Del(headers.NameContentLength)
+		}
+	}
+	fw.ResponseWriter.WriteHeader(fw.statusCode)
+	fw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testFormatBody = `{"meta":[{"name":"t","type":"UInt64"},{"name":"cnt","type":"UInt64"},` +
+	`{"name":"host","type":"String"}],"data":[{"t":"1516665600000","cnt":12,"host":"a"},` +
+	`{"host":"b","cnt":1.5,"t":"1516665660000"}],"rows":2}`
+
+func TestGetQueryFormat(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected responseFormat
+	}{
+		{"SELECT 1", formatUnsupported},
+		{"SELECT t FROM test_table FORMAT JSON", formatJSON},
+		{"SELECT t FROM test_table FORMAT JSONCompact", formatJSONCompact},
+		{"SELECT t FROM test_table format JSONEachRow", formatJSONEachRow},
+		{"SELECT t FROM test_table FORMAT Arrow", formatPassThrough},
+		{"SELECT t FROM test_table FORMAT Native", formatPassThrough},
+		{"SELECT t FROM test_table FORMAT TSV", formatUnsupported},
+	}
+	for _, test := range tests {
+		if f := getQueryFormat(test.query); f != test.expected {
+			t.Errorf("expected %d got %d for %s", test.expected, f, test.query)
+		}
+	}
+}
+
+func TestParseRawQueryFormats(t *testing.T) {
+	for _, f := range []string{"JSON", "JSONCompact", "JSONEachRow"} {
+		trq := &timeseries.TimeRangeQuery{}
+		err := parseRawQuery(`SELECT toStartOfMinute(datetime) t, cnt FROM test_table `+
+			`WHERE datetime >= 1589904000 AND datetime < 1589997600 FORMAT `+f, trq)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.HasSuffix(trq.Statement, "FORMAT JSON") {
+			t.Errorf("expected statement ending with FORMAT JSON got %s", trq.Statement)
+		}
+	}
+}
+
+func TestConvertFormat(t *testing.T) {
+
+	b, err := convertFormat([]byte(testFormatBody), formatJSONCompact)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"meta":[{"name":"t","type":"UInt64"},{"name":"cnt","type":"UInt64"},` +
+		`{"name":"host","type":"String"}],"data":[["1516665600000",12,"a"],["1516665660000",1.5,"b"]],"rows":2}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	b, err = convertFormat([]byte(testFormatBody), formatJSONEachRow)
+	if err != nil {
+		t.Error(err)
+	}
+	expected = `{"t":"1516665600000","cnt":12,"host":"a"}` + "\n" +
+		`{"t":"1516665660000","cnt":1.5,"host":"b"}` + "\n"
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	_, err = convertFormat([]byte("x"), formatJSONCompact)
+	if err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
+
+func TestFormatWriter(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	fw := newFormatWriter(w, formatJSONCompact)
+	fw.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	fw.Header().Set(headers.NameContentLength, "1")
+	fw.WriteHeader(http.StatusOK)
+	fw.Write([]byte(testFormatBody))
+	fw.flush()
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(b), `"data":[["1516665600000",12,"a"]`) {
+		t.Errorf("expected JSONCompact data got %s", string(b))
+	}
+	if resp.Header.Get(headers.NameContentLength) != "" {
+		t.Errorf("expected empty content length got %s", resp.Header.Get(headers.NameContentLength))
+	}
+
+	// error responses are passed through
+	w = httptest.NewRecorder()
+	fw = newFormatWriter(w, formatJSONCompact)
+	fw.WriteHeader(http.StatusBadRequest)
+	fw.Write([]byte("bad request"))
+	fw.flush()
+	resp = w.Result()
+	b, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if string(b) != "bad request" {
+		t.Errorf("expected %s got %s", "bad request", string(b))
+	}
+}
+
+func TestQueryHandlerPassThroughFormat(t *testing.T) {
+
+	qs := url.Values(map[string][]string{"query": {
+		`SELECT toStartOfMinute(datetime) t, cnt FROM test_table ` +
+			`WHERE datetime >= 1589904000 AND datetime < 1589997600 FORMAT Arrow`}}).Encode()
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, "arrow", nil, "clickhouse", "/?"+qs, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	client.QueryHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "arrow" {
+		t.Errorf("expected %s got %s", "arrow", string(b))
+	}
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.HasPrefix(v, "engine=ObjectProxyCache") {
+		t.Errorf("expected ObjectProxyCache engine got %s", v)
+	}
+}
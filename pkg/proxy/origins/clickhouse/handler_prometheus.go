@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+)
+
+// PrometheusQueryRangeHandler serves the Prometheus query_range API by translating the PromQL
+// expression into a ClickHouse query with the origin's prometheus_api templates. The ClickHouse
+// query is processed through the delta proxy cache, and its results are converted to a matrix
+func (c *Client) PrometheusQueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+
+	pr, err := promapi.ParseRequest(r)
+	if err != nil {
+		promapi.WriteError(w, http.StatusBadRequest, promapi.ErrorTypeBadData, err)
+		return
+	}
+
+	q, t, err := promapi.Translate(c.config.PrometheusAPI, pr)
+	if err != nil {
+		promapi.WriteError(w, http.StatusBadRequest, promapi.ErrorTypeBadData, err)
+		return
+	}
+
+	// templates may omit the FORMAT clause, since results are always cached and merged as JSON
+	if !getQueryFormat(q).isJSON() {
+		q += " FORMAT JSON"
+	}
+
+	v := url.Values{}
+	for k, p := range t.Params {
+		v.Set(k, p)
+	}
+	v.Set(upQuery, q)
+
+	r.Method = http.MethodGet
+	r.Body = nil
+	r.ContentLength = 0
+	r.Header.Del(headers.NameContentType)
+	r.URL.Path = "/"
+	r.URL.RawQuery = v.Encode()
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+
+	pw := promapi.NewWriter(w, seriesFromResponse)
+	engines.DeltaProxyCacheRequest(pw, r)
+	pw.Flush()
+}
+
+// isNumericType returns true if values of the ClickHouse type are numbers
+func isNumericType(t string) bool {
+	for _, p := range []string{"Nullable(", "LowCardinality("} {
+		if strings.HasPrefix(t, p) {
+			t = t[len(p):]
+		}
+	}
+	for _, p := range []string{"Int", "UInt", "Float", "Decimal"} {
+		if strings.HasPrefix(t, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesFromResponse converts a ClickHouse JSON response into Prometheus Series. The first
+// column is the timestamp, and each other numeric column becomes a Series for each distinct
+// combination of the remaining columns, which are its labels. The Series is named for the column
+func seriesFromResponse(b []byte) ([]*promapi.Series, error) {
+	re := &ResultsEnvelope{}
+	if err := json.Unmarshal(b, re); err != nil {
+		return nil, err
+	}
+	if len(re.Meta) == 0 {
+		return []*promapi.Series{}, nil
+	}
+
+	var valueCols, labelCols []string
+	for _, fd := range re.Meta[1:] {
+		if isNumericType(fd.Type) {
+			valueCols = append(valueCols, fd.Name)
+		} else {
+			labelCols = append(labelCols, fd.Name)
+		}
+	}
+
+	out := make([]*promapi.Series, 0)
+	lookup := make(map[string]*promapi.Series)
+	for _, p := range re.Data {
+		for _, rv := range p.Values {
+			var sb strings.Builder
+			labels := make(map[string]string, len(labelCols)+1)
+			for _, l := range labelCols {
+				s, _ := rv[l].(string)
+				labels[l] = s
+				sb.WriteString(l + "\xff" + s + "\xff")
+			}
+			lk := sb.String()
+			for _, vc := range valueCols {
+				f, ok := numericValue(rv[vc])
+				if !ok {
+					continue
+				}
+				k := vc + "\xff" + lk
+				s, ok := lookup[k]
+				if !ok {
+					s = &promapi.Series{Labels: make(map[string]string, len(labels)+1)}
+					for l, v := range labels {
+						s.Labels[l] = v
+					}
+					s.Labels["__name__"] = vc
+					lookup[k] = s
+					out = append(out, s)
+				}
+				s.Points = append(s.Points, promapi.Point{Timestamp: p.Timestamp, Value: f})
+			}
+		}
+	}
+	return out, nil
+}
+
+// numericValue returns the float64 value of a ClickHouse JSON number, which is quoted for 64-bit types
+func numericValue(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
+	pao "github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testPrometheusAPIBody = `{"meta":[{"name":"t","type":"UInt64"},{"name":"host","type":"String"},` +
+	`{"name":"load","type":"Float64"},{"name":"cnt","type":"UInt64"}],"data":[` +
+	`{"t":"1516665600000","host":"a","load":0.5,"cnt":"2"},` +
+	`{"t":"1516665660000","host":"a","load":1.5,"cnt":"3"},` +
+	`{"t":"1516665600000","host":"b","load":2,"cnt":null}],"rows":3}`
+
+func testPrometheusAPIPathConfigs(client *Client) func(*oo.Options) map[string]*po.Options {
+	return func(oc *oo.Options) map[string]*po.Options {
+		oc.PrometheusAPI = pao.NewOptions()
+		oc.PrometheusAPI.Templates["load"] = &pao.TemplateOptions{
+			Match: `system_load`,
+			Query: `SELECT (intDiv(toUInt32(ts), ${step_secs}) * ${step_secs}) * 1000 AS t, host, ` +
+				`avg(load) AS load, count() AS cnt FROM system.load ` +
+				`WHERE ts BETWEEN toDateTime(${start}) AND toDateTime(${end}) GROUP BY t, host ORDER BY t`,
+		}
+		oc.PrometheusAPI.Compile()
+		return client.DefaultPathConfigs(oc)
+	}
+}
+
+func TestPrometheusQueryRangeHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", testPrometheusAPIPathConfigs(client), 200,
+		testPrometheusAPIBody, map[string]string{"Content-Type": "application/json"}, "clickhouse",
+		promapi.QueryRangePath+"?query=system_load&start=1516665600&end=1516665660&step=60", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	pc, ok := client.config.Paths[promapi.QueryRangePath]
+	if !ok {
+		t.Fatalf("expected to find path named: %s", promapi.QueryRangePath)
+	}
+	rsc.PathConfig = pc
+
+	client.PrometheusQueryRangeHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	expected := `{"status":"success","data":{"resultType":"matrix","result":[` +
+		`{"metric":{"__name__":"cnt","host":"a"},"values":[[1516665600,"2"],[1516665660,"3"]]},` +
+		`{"metric":{"__name__":"load","host":"a"},"values":[[1516665600,"0.5"],[1516665660,"1.5"]]},` +
+		`{"metric":{"__name__":"load","host":"b"},"values":[[1516665600,"2"]]}]}}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	if q := r.URL.Query().Get(upQuery); !strings.HasSuffix(q, "FORMAT JSON") {
+		t.Errorf("expected query to request JSON, got %s", q)
+	}
+}
+
+func TestPrometheusQueryRangeHandlerBadRequest(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, _, err := tu.NewTestInstance("", testPrometheusAPIPathConfigs(client), 200, "{}", nil,
+		"clickhouse", promapi.QueryRangePath+"?query=system_load&start=1516665600&end=1516665660", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	client.config = request.GetResources(r).OriginConfig
+
+	client.PrometheusQueryRangeHandler(w, r)
+	if w.Code != 400 {
+		t.Errorf("expected 400 got %d", w.Code)
+	}
+}
+
+func TestIsNumericType(t *testing.T) {
+	tests := []struct {
+		t        string
+		expected bool
+	}{
+		{"UInt64", true},
+		{"Nullable(Float64)", true},
+		{"Decimal(9, 2)", true},
+		{"String", false},
+		{"LowCardinality(String)", false},
+		{"DateTime", false},
+	}
+	for _, test := range tests {
+		if v := isNumericType(test.t); v != test.expected {
+			t.Errorf("expected %t got %t for %s", test.expected, v, test.t)
+		}
+	}
+}
@@ -34,5 +34,16 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
-	engines.DeltaProxyCacheRequest(w, r)
+
+	switch f := getQueryFormat(r.URL.Query().Get(upQuery)); f {
+	case formatPassThrough:
+		// binary formats can't be merged, so their responses are cached whole, keyed by the query
+		engines.ObjectProxyCacheRequest(w, r)
+	case formatJSONCompact, formatJSONEachRow:
+		fw := newFormatWriter(w, f)
+		engines.DeltaProxyCacheRequest(fw, r)
+		fw.flush()
+	default:
+		engines.DeltaProxyCacheRequest(w, r)
+	}
 }
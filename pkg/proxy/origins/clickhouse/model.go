@@ -49,6 +49,9 @@ type Response struct {
 	Meta    []FieldDefinition `json:"meta"`
 	RawData []ResponseValue   `json:"data"`
 	Rows    int               `json:"rows"`
+	// StepDuration and ExtentList are only present in cached documents
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
 }
 
 // ResultsEnvelope is the ClickHouse document structure optimized for time series manipulation
@@ -145,8 +148,10 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 		ttf = toSec
 	}
 	rsp := &Response{
-		Meta:    re.Meta,
-		RawData: make([]ResponseValue, 0, len(re.Data)),
+		Meta:         re.Meta,
+		RawData:      make([]ResponseValue, 0, len(re.Data)),
+		StepDuration: re.StepDuration,
+		ExtentList:   re.ExtentList,
 	}
 	rows := 0
 	for _, p := range re.Data {
@@ -176,6 +181,8 @@ func (re *ResultsEnvelope) UnmarshalJSON(b []byte) error {
 	re.isCounted = false
 	re.Meta = response.Meta
 	re.Data = make([]Point, 0, len(response.RawData))
+	re.StepDuration = response.StepDuration
+	re.ExtentList = response.ExtentList
 
 	if len(response.RawData) == 0 {
 		return nil // No data points, we're done
@@ -203,6 +203,33 @@ func TestMarshalTimeseries(t *testing.T) {
 	}
 }
 
+func TestMarshalTimeseriesExtents(t *testing.T) {
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(testJSON1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	el := timeseries.ExtentList{timeseries.Extent{Start: time.Unix(1577836800, 0),
+		End: time.Unix(1577836860, 0)}}
+	ts.SetExtents(el)
+	ts.SetStep(time.Minute)
+
+	b, err := client.MarshalTimeseries(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2, err := client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts2.Extents().String() != el.String() {
+		t.Errorf("expected %s got %s", el.String(), ts2.Extents().String())
+	}
+	if ts2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, ts2.Step())
+	}
+}
+
 func TestUnmarshalValidJSON(t *testing.T) {
 	re := ResultsEnvelope{}
 	err := re.UnmarshalJSON([]byte(testJSON1))
@@ -91,9 +91,12 @@ func parseRawQuery(query string, trq *timeseries.TimeRangeQuery) error {
 	if size < 4 {
 		return fmt.Errorf("unrecognized query format")
 	}
-	if sup(parts[size-2]+" "+parts[size-1]) != "FORMAT JSON" {
+	if !formatFromParts(parts).isJSON() {
 		return fmt.Errorf("non JSON formats not supported")
 	}
+	// all JSON formats are requested from the origin, cached and merged as JSON,
+	// and are converted back to the requested format when responding to the client
+	parts[size-1] = "JSON"
 
 	var tsColumn, tsAlias string
 	var startTime, endTime, whereStart int
@@ -252,7 +255,7 @@ func findRange(parts []string, column string, alias string) (int, int, []string,
 			}
 		}
 
-		tf := srm(srm(srm(p, "toDateTime("), "toDate("), ")")
+		tf := strings.TrimLeft(srm(srm(srm(p, "toDateTime("), "toDate("), ")"), "(")
 		tfSize := len(tf)
 		tl := strings.Index(tf, column)
 		if tl == 0 {
@@ -268,6 +271,7 @@ func findRange(parts []string, column string, alias string) (int, int, []string,
 		}
 
 		tl = len(actColumn)
+		opening, closing := unbalancedParens(p)
 		if tl < tfSize && tf[tl] == '>' {
 			if tl < tfSize+1 && tf[tl+1] == '=' {
 				tl++
@@ -276,16 +280,21 @@ func findRange(parts []string, column string, alias string) (int, int, []string,
 			if err != nil {
 				return st, et, nil, column, err
 			}
-			wc = append(wc, actColumn+" >= "+tkTimestamp1)
+			wc = append(wc, opening+actColumn+" >= "+tkTimestamp1+closing)
 		} else if tl < tfSize && tf[tl] == '<' {
-			if tl < tfSize+1 && tf[tl+1] == '=' {
+			inclusive := tl < tfSize+1 && tf[tl+1] == '='
+			if inclusive {
 				tl++
 			}
 			et, err = parseTime(tf[tl+1:])
 			if err != nil {
 				return st, et, nil, column, err
 			}
-			wc = append(wc, actColumn+" < "+tkTimestamp2)
+			// an exclusive end time does not include the bucket that it falls on
+			if !inclusive {
+				et--
+			}
+			wc = append(wc, opening+actColumn+" < "+tkTimestamp2+closing)
 		} else {
 			wc = append(wc, p)
 		}
@@ -296,6 +305,16 @@ func findRange(parts []string, column string, alias string) (int, int, []string,
 	return st, et, wc, actColumn, nil
 }
 
+// unbalancedParens returns the opening or closing parentheses left unbalanced in a where
+// clause part, so they are retained when the part is replaced with a tokenized time condition
+func unbalancedParens(p string) (string, string) {
+	n := strings.Count(p, ")") - strings.Count(p, "(")
+	if n < 0 {
+		return strings.Repeat("(", -n), ""
+	}
+	return "", strings.Repeat(")", n)
+}
+
 func findParts(query string) []string {
 	bytes := []byte(strings.TrimSpace(query))
 	size := len(bytes)
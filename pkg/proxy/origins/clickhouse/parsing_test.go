@@ -94,6 +94,26 @@ func TestGoodQueries(t *testing.T) {
 
 }
 
+func TestTokenizedQueryRoundTrip(t *testing.T) {
+	query := `SELECT (intDiv(toUInt32(datetime), 60) * 60) * 1000 AS t, count() as cnt ` +
+		`FROM test_db.test_table WHERE (datetime >= 1589904000 AND datetime < 1589997660) AND x = 1 ` +
+		`GROUP BY t FORMAT JSON`
+	trq := &timeseries.TimeRangeQuery{}
+	err := parseRawQuery(query, trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Statement != `SELECT (intDiv(toUInt32(datetime),60)*60)*1000 AS t,count() as cnt `+
+		`FROM test_db.test_table WHERE (datetime >= <$TIMESTAMP1$> AND datetime < <$TIMESTAMP2$>) `+
+		`AND x=1 GROUP BY t FORMAT JSON` {
+		t.Errorf("tokenized statement did not match query: %s", trq.Statement)
+	}
+	trq.NormalizeExtent()
+	if trq.Extent.End != time.Unix(1589997600, 0) {
+		t.Errorf("expected end time of 1589997600, got %d", trq.Extent.End.Unix())
+	}
+}
+
 func TestBadQueries(t *testing.T) {
 	test := func(run string, query string, es string) {
 		t.Run(run, func(t *testing.T) {
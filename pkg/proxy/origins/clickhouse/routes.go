@@ -22,6 +22,7 @@ import (
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
 )
 
 func (c *Client) registerHandlers() {
@@ -32,6 +33,7 @@ func (c *Client) registerHandlers() {
 	c.handlers["health"] = http.HandlerFunc(c.HealthHandler)
 	c.handlers["query"] = http.HandlerFunc(c.QueryHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	c.handlers["prometheus_query_range"] = http.HandlerFunc(c.PrometheusQueryRangeHandler)
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -54,5 +56,18 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			CacheKeyParams: []string{"query", "database"},
 		},
 	}
+
+	// the Prometheus-compatible API is only served when templates are configured to translate its queries
+	if oc != nil && oc.PrometheusAPI.Enabled() {
+		paths[promapi.QueryRangePath] = &po.Options{
+			Path:           promapi.QueryRangePath,
+			HandlerName:    "prometheus_query_range",
+			Methods:        []string{http.MethodGet, http.MethodPost},
+			MatchType:      matching.PathMatchTypeExact,
+			MatchTypeName:  "exact",
+			CacheKeyParams: []string{"query", "database"},
+		}
+	}
+
 	return paths
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/tricksterproxy/trickster/pkg/cache"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 )
 
 // Client is the primary interface for interoperating with Trickster and upstream TSDB's
@@ -43,3 +44,18 @@ type Client interface {
 	// Cache returns a handle to the Cache instance used by the Client
 	Cache() cache.Cache
 }
+
+// BackgroundClient is implemented by Clients that run scheduled work, such as
+// pre-populating the cache, for the lifetime of the running configuration
+type BackgroundClient interface {
+	// StartBackgroundTasks starts the Client's background tasks, which exit once quit is closed
+	StartBackgroundTasks(quit <-chan struct{}, log *tl.Logger)
+}
+
+// KeyVersioner is implemented by Clients whose cached objects depend on provider logic, such as
+// how responses are keyed, transformed or merged. The KeyVersion is embedded in the Client's
+// derived cache keys, so that when it is incremented for an incompatible change to that logic,
+// an upgraded Trickster does not read objects cached by an earlier version
+type KeyVersioner interface {
+	KeyVersion() int
+}
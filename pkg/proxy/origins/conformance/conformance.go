@@ -0,0 +1,378 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package conformance provides a reusable test kit that validates a Time Series
+// Provider implementation against Trickster's range, delta, merge and fast forward
+// expectations, using a mock origin that is driven by the Provider's own query parsing
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	co "github.com/tricksterproxy/trickster/pkg/cache/options"
+	cr "github.com/tricksterproxy/trickster/pkg/cache/registration"
+	tc "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// ErrSkipped is returned by a Scenario that does not apply to the Provider under test
+var ErrSkipped = errors.New("scenario skipped")
+
+// Provider describes a Time Series Provider implementation to be validated by the kit
+type Provider struct {
+	// Name identifies the Provider in the results
+	Name string
+	// OriginType is the origin_type used to configure the Provider
+	OriginType string
+	// NewClient returns a new Client for the Provider, and is the same function
+	// that the router uses when registering the OriginType
+	NewClient func(string, *oo.Options, http.Handler, cache.Cache) (origins.Client, error)
+	// RangeRequest returns a downstream request for the provided Extent and Step, which
+	// must be routable by one of the Provider's default paths to a delta proxy cache handler
+	RangeRequest func(timeseries.Extent, time.Duration) *http.Request
+	// WriteRange writes the mock origin's response to an upstream time range query,
+	// with one datapoint per Step at every aligned timestamp in the query's Extent
+	WriteRange func(http.ResponseWriter, *timeseries.TimeRangeQuery)
+	// WriteInstant writes the mock origin's response to an upstream Fast Forward request
+	// that is not a time range query. It is only required when the Provider supports
+	// Fast Forward, and its Fast Forward requests cannot be parsed as time range queries
+	WriteInstant func(http.ResponseWriter, *http.Request)
+}
+
+// Scenario is a single conformance check run against a Provider
+type Scenario struct {
+	// Name is the short name of the Scenario
+	Name string
+	// Description summarizes what the Scenario validates
+	Description string
+
+	run func(*harness) error
+}
+
+// Result is the outcome of running a Scenario against a Provider
+type Result struct {
+	Provider string
+	Scenario string
+	Skipped  bool
+	Err      error
+}
+
+// Passed returns true if the Scenario ran and did not fail
+func (r Result) Passed() bool {
+	return r.Err == nil && !r.Skipped
+}
+
+// Scenarios returns the full matrix of conformance Scenarios, in the order they are run
+func Scenarios() []Scenario {
+	return []Scenario{
+		{Name: "parse", run: scenarioParse,
+			Description: "the Extent and Step of a range request are parsed"},
+		{Name: "set_extent", run: scenarioSetExtent,
+			Description: "a range request is rewritten to a new Extent"},
+		{Name: "unmarshal", run: scenarioUnmarshal,
+			Description: "an origin response unmarshals with one timestamp per Step"},
+		{Name: "marshal_roundtrip", run: scenarioMarshalRoundtrip,
+			Description: "a Timeseries survives a marshal and unmarshal cycle"},
+		{Name: "merge_adjacent", run: scenarioMergeAdjacent,
+			Description: "adjacent Timeseries merge into one contiguous Extent"},
+		{Name: "merge_overlap", run: scenarioMergeOverlap,
+			Description: "overlapping Timeseries merge without duplicating timestamps"},
+		{Name: "crop", run: scenarioCrop,
+			Description: "a Timeseries crops to a range within its Extent"},
+		{Name: "clone", run: scenarioClone,
+			Description: "a cloned Timeseries is independent of its source"},
+		{Name: "delta_miss_hit", run: scenarioDeltaMissHit,
+			Description: "a repeated request is served from cache without an upstream fetch"},
+		{Name: "delta_partial_hit", run: scenarioDeltaPartialHit,
+			Description: "an extended request fetches only the uncached delta from the origin"},
+		{Name: "fast_forward", run: scenarioFastForward,
+			Description: "a request ending now is fast forwarded with instantaneous data"},
+	}
+}
+
+// Run runs the named Scenarios, or all Scenarios when none are named, against the Provider
+func Run(p *Provider, names ...string) []Result {
+	scenarios := Scenarios()
+	if len(names) > 0 {
+		selected := make([]Scenario, 0, len(names))
+		for _, n := range names {
+			for _, s := range scenarios {
+				if s.Name == n {
+					selected = append(selected, s)
+				}
+			}
+		}
+		scenarios = selected
+	}
+	results := make([]Result, 0, len(scenarios))
+	for _, s := range scenarios {
+		results = append(results, runScenario(p, s))
+	}
+	return results
+}
+
+// Test runs every Scenario against the Provider as a subtest of t
+func Test(t *testing.T, p *Provider) {
+	for _, s := range Scenarios() {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			r := runScenario(p, s)
+			if r.Skipped {
+				t.Skip(r.Err)
+			}
+			if r.Err != nil {
+				t.Error(r.Err)
+			}
+		})
+	}
+}
+
+func runScenario(p *Provider, s Scenario) Result {
+	r := Result{Provider: p.Name, Scenario: s.Name}
+	h, err := newHarness(p)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer h.close()
+	r.Err = s.run(h)
+	if r.Err == ErrSkipped {
+		r.Skipped = true
+	}
+	return r
+}
+
+// harness is a Provider's client wired to a fresh memory cache and a mock origin
+type harness struct {
+	provider *Provider
+	client   origins.TimeseriesClient
+	options  *oo.Options
+	paths    map[string]*po.Options
+	cache    cache.Cache
+	logger   *tl.Logger
+	origin   *httptest.Server
+	step     time.Duration
+
+	mtx     sync.Mutex
+	fetched timeseries.ExtentList
+}
+
+func newHarness(p *Provider) (*harness, error) {
+
+	if p == nil || p.NewClient == nil || p.RangeRequest == nil || p.WriteRange == nil {
+		return nil, errors.New("provider must define NewClient, RangeRequest and WriteRange")
+	}
+
+	h := &harness{provider: p, logger: tl.ConsoleLogger("error"), step: time.Minute}
+	h.origin = httptest.NewServer(http.HandlerFunc(h.serveOrigin))
+
+	u, _ := url.Parse(h.origin.URL)
+	o := oo.NewOptions()
+	o.Name = p.Name
+	o.OriginType = p.OriginType
+	o.OriginURL = h.origin.URL
+	o.Scheme = u.Scheme
+	o.Host = u.Host
+
+	h.cache = cr.NewCache("conformance", co.NewOptions(), h.logger)
+	c, err := p.NewClient(o.Name, o, http.NewServeMux(), h.cache)
+	if err != nil {
+		h.close()
+		return nil, err
+	}
+	tsc, ok := c.(origins.TimeseriesClient)
+	if !ok {
+		h.close()
+		return nil, fmt.Errorf("provider %s client is not a TimeseriesClient", p.Name)
+	}
+	h.client = tsc
+	h.options = o
+	o.HTTPClient = c.HTTPClient()
+
+	h.paths = c.DefaultPathConfigs(o)
+	handlers := c.Handlers()
+	for _, pc := range h.paths {
+		pc.Handler = handlers[pc.HandlerName]
+	}
+
+	return h, nil
+}
+
+func (h *harness) close() {
+	h.origin.Close()
+	h.cache.Close()
+}
+
+// pathConfig returns the default path that routes the provided url path
+func (h *harness) pathConfig(path string) *po.Options {
+	var match *po.Options
+	for _, pc := range h.paths {
+		if pc.Path == path {
+			return pc
+		}
+		if pc.MatchType == matching.PathMatchTypePrefix && strings.HasPrefix(path, pc.Path) &&
+			(match == nil || len(pc.Path) > len(match.Path)) {
+			match = pc
+		}
+	}
+	return match
+}
+
+// withResources attaches the harness resources to the request, as the router would
+func (h *harness) withResources(r *http.Request) *http.Request {
+	rsc := request.NewResources(h.options, h.pathConfig(r.URL.Path),
+		h.cache.Configuration(), h.cache, h.client, nil, h.logger)
+	rsc.Canonical = tl.Pairs{}
+	return r.WithContext(tc.WithResources(r.Context(), rsc))
+}
+
+// serveOrigin is the mock origin, which uses the client's own parser to learn
+// what was requested, and records the Extent of each upstream range query
+func (h *harness) serveOrigin(w http.ResponseWriter, r *http.Request) {
+	trq, err := h.client.ParseTimeRangeQuery(h.withResources(r))
+	if err != nil {
+		if h.provider.WriteInstant != nil {
+			h.provider.WriteInstant(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if trq.Step <= 0 {
+		trq.Step = h.step
+	}
+	trq.NormalizeExtent()
+	h.mtx.Lock()
+	h.fetched = append(h.fetched, trq.Extent)
+	h.mtx.Unlock()
+	h.provider.WriteRange(w, trq)
+}
+
+// upstreamFetches returns the Extents requested from the mock origin, and resets the list
+func (h *harness) upstreamFetches() timeseries.ExtentList {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	f := h.fetched
+	h.fetched = nil
+	return f
+}
+
+// request returns a downstream range request for the Extent, with resources attached
+func (h *harness) request(e timeseries.Extent) *http.Request {
+	return h.withResources(h.provider.RangeRequest(e, h.step))
+}
+
+// parse parses and normalizes the request's time range query
+func (h *harness) parse(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+	trq, err := h.client.ParseTimeRangeQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	trq.NormalizeExtent()
+	return trq, nil
+}
+
+// fetch requests the Extent directly from the mock origin and unmarshals the response,
+// setting its Step and Extents in the same way as the delta proxy cache
+func (h *harness) fetch(e timeseries.Extent) (timeseries.Timeseries, error) {
+	r := h.request(e)
+	trq, err := h.parse(r)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(h.origin.URL)
+	r = r.Clone(context.Background())
+	r.RequestURI = ""
+	r.URL.Scheme = u.Scheme
+	r.URL.Host = u.Host
+	h.client.SetExtent(r, trq, &e)
+	resp, err := h.client.HTTPClient().Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mock origin responded %d: %s", resp.StatusCode, string(body))
+	}
+	ts, err := h.client.UnmarshalTimeseries(body)
+	if err != nil {
+		return nil, err
+	}
+	ts.SetStep(trq.Step)
+	ts.SetExtents(timeseries.ExtentList{e})
+	return ts, nil
+}
+
+// serve sends the request through the Provider's handler, returning the response
+// body and the cache status reported in the X-Trickster-Result header
+func (h *harness) serve(r *http.Request) ([]byte, string, string, error) {
+	rsc := request.GetResources(r)
+	if rsc == nil || rsc.PathConfig == nil || rsc.PathConfig.Handler == nil {
+		return nil, "", "", fmt.Errorf("no handler routes %s", r.URL.Path)
+	}
+	w := httptest.NewRecorder()
+	rsc.PathConfig.Handler.ServeHTTP(w, r)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("handler responded %d: %s", resp.StatusCode, string(body))
+	}
+	result := resp.Header.Get(headers.NameTricksterResult)
+	return body, resultPart(result, "status"), resultPart(result, "ffstatus"), nil
+}
+
+// resultPart returns the named part of an X-Trickster-Result header value
+func resultPart(result, name string) string {
+	for _, p := range strings.Split(result, "; ") {
+		if strings.HasPrefix(p, name+"=") {
+			return p[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+// window returns an aligned Extent of n Steps ending at end
+func (h *harness) window(end time.Time, n int) timeseries.Extent {
+	end = end.Truncate(h.step)
+	return timeseries.Extent{Start: end.Add(-time.Duration(n) * h.step), End: end}
+}
+
+// points returns the number of Steps in the Extent, inclusive of its End
+func (h *harness) points(e timeseries.Extent) int {
+	return int(e.End.Sub(e.Start)/h.step) + 1
+}
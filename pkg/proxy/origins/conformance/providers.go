@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/graphite"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/irondb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/opentsdb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/victoriametrics"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+
+	mockprom "github.com/tricksterproxy/mockster/pkg/mocks/prometheus"
+)
+
+// Providers returns fixtures for each of Trickster's built-in Time Series Providers
+func Providers() []*Provider {
+	return []*Provider{Prometheus(), VictoriaMetrics(), InfluxDB(), ClickHouse(), IRONdb(), Graphite(),
+		OpenTSDB()}
+}
+
+// Lookup returns the built-in Provider fixture with the provided name, or nil
+func Lookup(name string) *Provider {
+	for _, p := range Providers() {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// mockTimestamps returns each Step-aligned timestamp in the query's Extent, inclusive of its End
+func mockTimestamps(trq *timeseries.TimeRangeQuery) []time.Time {
+	start := trq.TruncateToStep(trq.Extent.Start)
+	if start.Before(trq.Extent.Start) {
+		start = start.Add(trq.Step)
+	}
+	ts := make([]time.Time, 0, int(trq.Extent.End.Sub(start)/trq.Step)+1)
+	for t := start; !t.After(trq.Extent.End); t = t.Add(trq.Step) {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// mockValue returns a repeatable value for the timestamp
+func mockValue(t time.Time) int64 {
+	return t.Unix() % 997
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	json.NewEncoder(w).Encode(v)
+}
+
+const promQuery = "conformance_series{series_count=2}"
+
+// Prometheus returns the conformance fixture for the Prometheus Provider
+func Prometheus() *Provider {
+	return &Provider{
+		Name:       "prometheus",
+		OriginType: "prometheus",
+		NewClient:  prometheus.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			v := url.Values{"query": {promQuery}, "start": {strconv.FormatInt(e.Start.Unix(), 10)},
+				"end":  {strconv.FormatInt(e.End.Unix(), 10)},
+				"step": {strconv.Itoa(int(step.Seconds()))}}
+			return httptest.NewRequest(http.MethodGet, "http://trickster/api/v1/query_range?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			if len(ts) == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body, _, _ := mockprom.GetTimeSeriesData(promQuery, ts[0], ts[len(ts)-1], trq.Step)
+			w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+			fmt.Fprint(w, body)
+		},
+		WriteInstant: func(w http.ResponseWriter, r *http.Request) {
+			body, _, _ := mockprom.GetInstantData(promQuery, time.Now())
+			w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+			fmt.Fprint(w, body)
+		},
+	}
+}
+
+// VictoriaMetrics returns the conformance fixture for the VictoriaMetrics Provider, which
+// serves the Prometheus API
+func VictoriaMetrics() *Provider {
+	p := Prometheus()
+	p.Name = "victoriametrics"
+	p.OriginType = "victoriametrics"
+	p.NewClient = victoriametrics.NewClient
+	return p
+}
+
+// InfluxDB returns the conformance fixture for the InfluxDB Provider
+func InfluxDB() *Provider {
+	return &Provider{
+		Name:       "influxdb",
+		OriginType: "influxdb",
+		NewClient:  influxdb.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			q := fmt.Sprintf(`SELECT mean("value") FROM "conformance" WHERE time >= %dms AND time <= %dms `+
+				`GROUP BY time(%ds) fill(null)`, e.Start.Unix()*1000, e.End.Unix()*1000, int(step.Seconds()))
+			v := url.Values{"q": {q}, "db": {"conformance"}, "epoch": {"ms"}}
+			return httptest.NewRequest(http.MethodGet, "http://trickster/query?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			values := make([][]interface{}, len(ts))
+			for i, t := range ts {
+				values[i] = []interface{}{t.Unix() * 1000, mockValue(t)}
+			}
+			writeJSON(w, map[string]interface{}{"results": []interface{}{
+				map[string]interface{}{"statement_id": 0, "series": []interface{}{
+					map[string]interface{}{"name": "conformance",
+						"columns": []string{"time", "mean"}, "values": values},
+				}},
+			}})
+		},
+	}
+}
+
+// ClickHouse returns the conformance fixture for the ClickHouse Provider
+func ClickHouse() *Provider {
+	return &Provider{
+		Name:       "clickhouse",
+		OriginType: "clickhouse",
+		NewClient:  clickhouse.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			s := int(step.Seconds())
+			q := fmt.Sprintf("SELECT (intDiv(toUInt32(datetime), %d) * %d) * 1000 AS t, count() AS cnt "+
+				"FROM conformance.series WHERE datetime BETWEEN %d AND %d GROUP BY t ORDER BY t FORMAT JSON",
+				s, s, e.Start.Unix(), e.End.Unix())
+			v := url.Values{"query": {q}}
+			return httptest.NewRequest(http.MethodGet, "http://trickster/?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			data := make([]map[string]string, len(ts))
+			for i, t := range ts {
+				data[i] = map[string]string{"t": strconv.FormatInt(t.Unix()*1000, 10),
+					"cnt": strconv.FormatInt(mockValue(t), 10)}
+			}
+			writeJSON(w, map[string]interface{}{
+				"meta": []map[string]string{{"name": "t", "type": "UInt64"}, {"name": "cnt", "type": "UInt64"}},
+				"data": data, "rows": len(data)})
+		},
+	}
+}
+
+// IRONdb returns the conformance fixture for the IRONdb Provider's rollup API
+func IRONdb() *Provider {
+	return &Provider{
+		Name:       "irondb",
+		OriginType: "irondb",
+		NewClient:  irondb.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			v := url.Values{"start_ts": {strconv.FormatInt(e.Start.Unix(), 10)},
+				"end_ts":      {strconv.FormatInt(e.End.Unix(), 10)},
+				"rollup_span": {fmt.Sprintf("%ds", int(step.Seconds()))}, "type": {"average"}}
+			return httptest.NewRequest(http.MethodGet,
+				"http://trickster/rollup/00112233-4455-6677-8899-aabbccddeeff/conformance?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			data := make([][]interface{}, len(ts))
+			for i, t := range ts {
+				data[i] = []interface{}{t.Unix(), mockValue(t)}
+			}
+			writeJSON(w, data)
+		},
+	}
+}
+
+// Graphite returns the conformance fixture for the Graphite Provider's render API
+func Graphite() *Provider {
+	return &Provider{
+		Name:       "graphite",
+		OriginType: "graphite",
+		NewClient:  graphite.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			// the render API has no step, so it is the step_secs of the origin
+			v := url.Values{"target": {"conformance.series"}, "format": {"json"},
+				"from":  {strconv.FormatInt(e.Start.Unix(), 10)},
+				"until": {strconv.FormatInt(e.End.Unix(), 10)}}
+			return httptest.NewRequest(http.MethodGet, "http://trickster/render?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			data := make([][]int64, len(ts))
+			for i, t := range ts {
+				data[i] = []int64{mockValue(t), t.Unix()}
+			}
+			writeJSON(w, []interface{}{
+				map[string]interface{}{"target": "conformance.series", "datapoints": data}})
+		},
+	}
+}
+
+// OpenTSDB returns the conformance fixture for the OpenTSDB Provider
+func OpenTSDB() *Provider {
+	return &Provider{
+		Name:       "opentsdb",
+		OriginType: "opentsdb",
+		NewClient:  opentsdb.NewClient,
+		RangeRequest: func(e timeseries.Extent, step time.Duration) *http.Request {
+			// the step of a downsampled query is its downsampling interval
+			v := url.Values{"m": {"sum:" + strconv.Itoa(int(step.Seconds())) + "s-avg:conformance.series"},
+				"start": {strconv.FormatInt(e.Start.Unix(), 10)},
+				"end":   {strconv.FormatInt(e.End.Unix(), 10)}}
+			return httptest.NewRequest(http.MethodGet, "http://trickster/api/query?"+v.Encode(), nil)
+		},
+		WriteRange: func(w http.ResponseWriter, trq *timeseries.TimeRangeQuery) {
+			ts := mockTimestamps(trq)
+			dps := make(map[string]int64, len(ts))
+			for _, t := range ts {
+				dps[strconv.FormatInt(t.Unix(), 10)] = mockValue(t)
+			}
+			writeJSON(w, []interface{}{map[string]interface{}{"metric": "conformance.series",
+				"tags": map[string]string{}, "aggregateTags": []string{}, "dps": dps}})
+		},
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conformance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// windowSteps is the number of Steps spanned by the primary test window
+const windowSteps = 360
+
+// baseEnd returns the end of the primary test window, far enough in the past
+// that backfill tolerance and fast forward do not apply, while the window
+// remains within the default timeseries retention
+func baseEnd() time.Time {
+	return time.Now().Add(-4 * time.Hour)
+}
+
+func equalExtents(a, b timeseries.Extent) bool {
+	return a.Start.Equal(b.Start) && a.End.Equal(b.End)
+}
+
+func checkExtent(what string, got, want timeseries.Extent) error {
+	if !equalExtents(got, want) {
+		return fmt.Errorf("%s: expected extent %s got %s", what, want, got)
+	}
+	return nil
+}
+
+func checkExtents(what string, got timeseries.ExtentList, want timeseries.Extent) error {
+	if len(got) != 1 {
+		return fmt.Errorf("%s: expected extents %s got %s", what, want, got)
+	}
+	return checkExtent(what, got[0], want)
+}
+
+func checkCount(what string, got, want int) error {
+	if got != want {
+		return fmt.Errorf("%s: expected %d timestamps got %d", what, want, got)
+	}
+	return nil
+}
+
+func scenarioParse(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	trq, err := h.parse(h.request(e))
+	if err != nil {
+		return err
+	}
+	if trq.Step != h.step {
+		return fmt.Errorf("expected step %s got %s", h.step, trq.Step)
+	}
+	return checkExtent("parsed", trq.Extent, e)
+}
+
+func scenarioSetExtent(h *harness) error {
+	r := h.request(h.window(baseEnd(), windowSteps))
+	trq, err := h.parse(r)
+	if err != nil {
+		return err
+	}
+	e := h.window(baseEnd().Add(-24*time.Hour), windowSteps/2)
+	h.client.SetExtent(r, trq, &e)
+	trq, err = h.parse(r)
+	if err != nil {
+		return err
+	}
+	return checkExtent("rewritten", trq.Extent, e)
+}
+
+func scenarioUnmarshal(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	ts, err := h.fetch(e)
+	if err != nil {
+		return err
+	}
+	if ts.SeriesCount() < 1 {
+		return fmt.Errorf("expected at least one series got %d", ts.SeriesCount())
+	}
+	return checkCount("unmarshaled", ts.TimestampCount(), h.points(e))
+}
+
+func scenarioMarshalRoundtrip(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	ts, err := h.fetch(e)
+	if err != nil {
+		return err
+	}
+	b, err := h.client.MarshalTimeseries(ts)
+	if err != nil {
+		return err
+	}
+	ts2, err := h.client.UnmarshalTimeseries(b)
+	if err != nil {
+		return err
+	}
+	if err = checkExtents("roundtrip", ts2.Extents(), e); err != nil {
+		return err
+	}
+	if ts2.Step() != ts.Step() {
+		return fmt.Errorf("roundtrip: expected step %s got %s", ts.Step(), ts2.Step())
+	}
+	if ts2.SeriesCount() != ts.SeriesCount() {
+		return fmt.Errorf("roundtrip: expected %d series got %d", ts.SeriesCount(), ts2.SeriesCount())
+	}
+	if ts2.ValueCount() != ts.ValueCount() {
+		return fmt.Errorf("roundtrip: expected %d values got %d", ts.ValueCount(), ts2.ValueCount())
+	}
+	return checkCount("roundtrip", ts2.TimestampCount(), ts.TimestampCount())
+}
+
+func scenarioMergeAdjacent(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	mid := e.Start.Add(windowSteps / 2 * h.step)
+	ts1, err := h.fetch(timeseries.Extent{Start: e.Start, End: mid})
+	if err != nil {
+		return err
+	}
+	ts2, err := h.fetch(timeseries.Extent{Start: mid.Add(h.step), End: e.End})
+	if err != nil {
+		return err
+	}
+	ts1.Merge(true, ts2)
+	if err = checkExtents("merged", ts1.Extents(), e); err != nil {
+		return err
+	}
+	return checkCount("merged", ts1.TimestampCount(), h.points(e))
+}
+
+func scenarioMergeOverlap(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	ts1, err := h.fetch(timeseries.Extent{Start: e.Start, End: e.End.Add(-windowSteps / 3 * h.step)})
+	if err != nil {
+		return err
+	}
+	ts2, err := h.fetch(timeseries.Extent{Start: e.Start.Add(windowSteps / 3 * h.step), End: e.End})
+	if err != nil {
+		return err
+	}
+	ts1.Merge(true, ts2)
+	return checkCount("merged", ts1.TimestampCount(), h.points(e))
+}
+
+func scenarioCrop(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	ts, err := h.fetch(e)
+	if err != nil {
+		return err
+	}
+	c := timeseries.Extent{Start: e.Start.Add(windowSteps / 4 * h.step),
+		End: e.End.Add(-windowSteps / 4 * h.step)}
+	ts.CropToRange(c)
+	if err = checkExtents("cropped", ts.Extents(), c); err != nil {
+		return err
+	}
+	return checkCount("cropped", ts.TimestampCount(), h.points(c))
+}
+
+func scenarioClone(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	ts, err := h.fetch(e)
+	if err != nil {
+		return err
+	}
+	ts2 := ts.Clone()
+	if err = checkCount("cloned", ts2.TimestampCount(), h.points(e)); err != nil {
+		return err
+	}
+	ts2.CropToRange(timeseries.Extent{Start: e.Start, End: e.Start.Add(windowSteps / 2 * h.step)})
+	if err = checkExtents("source", ts.Extents(), e); err != nil {
+		return err
+	}
+	return checkCount("source", ts.TimestampCount(), h.points(e))
+}
+
+func scenarioDeltaMissHit(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	body, status, _, err := h.serve(h.request(e))
+	if err != nil {
+		return err
+	}
+	if status != "kmiss" {
+		return fmt.Errorf("first request: expected status kmiss got %s", status)
+	}
+	if err = checkExtents("first request fetch", h.upstreamFetches(), e); err != nil {
+		return err
+	}
+	if err = h.checkBody("first request", body, e); err != nil {
+		return err
+	}
+	body, status, _, err = h.serve(h.request(e))
+	if err != nil {
+		return err
+	}
+	if status != "hit" {
+		return fmt.Errorf("repeated request: expected status hit got %s", status)
+	}
+	if f := h.upstreamFetches(); len(f) > 0 {
+		return fmt.Errorf("repeated request: expected no upstream fetches got %s", f)
+	}
+	return h.checkBody("repeated request", body, e)
+}
+
+func scenarioDeltaPartialHit(h *harness) error {
+	e := h.window(baseEnd(), windowSteps)
+	if _, _, _, err := h.serve(h.request(e)); err != nil {
+		return err
+	}
+	h.upstreamFetches()
+	e2 := timeseries.Extent{Start: e.Start, End: e.End.Add(windowSteps / 6 * h.step)}
+	trq, err := h.parse(h.request(e2))
+	if err != nil {
+		return err
+	}
+	body, status, _, err := h.serve(h.request(e2))
+	if err != nil {
+		return err
+	}
+	if status != "phit" {
+		return fmt.Errorf("extended request: expected status phit got %s", status)
+	}
+	// the fetched delta may be padded with cached data when the query requires it
+	delta := trq.PadExtent(timeseries.Extent{Start: e.End.Add(h.step), End: e2.End})
+	if err = checkExtents("extended request fetch", h.upstreamFetches(), delta); err != nil {
+		return err
+	}
+	return h.checkBody("extended request", body, e2)
+}
+
+func scenarioFastForward(h *harness) error {
+	if h.options.FastForwardDisable {
+		return ErrSkipped
+	}
+	e := h.window(time.Now(), windowSteps)
+	_, _, ffStatus, err := h.serve(h.request(e))
+	if err != nil {
+		return err
+	}
+	if ffStatus != "miss" {
+		return fmt.Errorf("expected fast forward status miss got %s", ffStatus)
+	}
+	return nil
+}
+
+// checkBody verifies that a client response covers every timestamp in the Extent
+func (h *harness) checkBody(what string, body []byte, e timeseries.Extent) error {
+	ts, err := h.client.UnmarshalTimeseries(body)
+	if err != nil {
+		return fmt.Errorf("%s: %s", what, err.Error())
+	}
+	return checkCount(what, ts.TimestampCount(), h.points(e))
+}
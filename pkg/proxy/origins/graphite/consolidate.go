@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// consolidate reduces each series of the envelope to no more than the provided
+// number of datapoints, by averaging consecutive datapoints, as graphite-web does
+// when a render request includes maxDataPoints. The step of each series is the
+// interval between its datapoints, or the provided step when it has only one.
+func (se *SeriesEnvelope) consolidate(maxDataPoints int, step int64) {
+	if maxDataPoints < 1 || len(se.Series) == 0 {
+		return
+	}
+
+	type window struct {
+		start, end, step int64
+		values           []*float64
+	}
+
+	windows := make([]*window, len(se.Series))
+	var minStart, maxEnd int64
+	for i, s := range se.Series {
+		if len(s.Datapoints) == 0 {
+			continue
+		}
+		w := &window{start: s.Datapoints[0].Timestamp, step: s.step(step)}
+		last := s.Datapoints[len(s.Datapoints)-1].Timestamp
+		w.end = last + w.step
+		w.values = make([]*float64, (last-w.start)/w.step+1)
+		for _, p := range s.Datapoints {
+			if p.Value == "" {
+				continue
+			}
+			if f, err := strconv.ParseFloat(string(p.Value), 64); err == nil {
+				w.values[(p.Timestamp-w.start)/w.step] = &f
+			}
+		}
+		if maxEnd == 0 || w.start < minStart {
+			minStart = w.start
+		}
+		if w.end > maxEnd {
+			maxEnd = w.end
+		}
+		windows[i] = w
+	}
+
+	timeRange := float64(maxEnd - minStart)
+	for i, s := range se.Series {
+		w := windows[i]
+		if w == nil {
+			continue
+		}
+		values := w.values
+		valuesPerPoint := int64(len(values))
+		secondsPerPoint := w.step
+		if maxDataPoints > 1 {
+			numberOfDataPoints := timeRange / float64(w.step)
+			if float64(maxDataPoints) >= numberOfDataPoints {
+				continue
+			}
+			valuesPerPoint = int64(math.Ceil(numberOfDataPoints / float64(maxDataPoints)))
+			secondsPerPoint = valuesPerPoint * w.step
+			// the start is nudged over so that the consolidation bands align
+			// with each request, removing the jitter seen when refreshing
+			nudge := secondsPerPoint + (w.start % w.step) - (w.start % secondsPerPoint)
+			w.start += nudge
+			if lose := int(nudge/w.step) - 1; lose > 0 {
+				if lose > len(values) {
+					lose = len(values)
+				}
+				values = values[lose:]
+			}
+		}
+		pts := make(Points, 0, int64(len(values))/valuesPerPoint+1)
+		for j := 0; j < len(values); j += int(valuesPerPoint) {
+			t := w.start + int64(len(pts))*secondsPerPoint
+			if t > w.end {
+				break
+			}
+			k := j + int(valuesPerPoint)
+			if k > len(values) {
+				k = len(values)
+			}
+			pts = append(pts, Point{Value: average(values[j:k]), Timestamp: t})
+		}
+		s.Datapoints = pts
+	}
+}
+
+// step returns the smallest interval between the datapoints of the
+// series, or the provided step if it has less than two datapoints
+func (s *Series) step(step int64) int64 {
+	var d int64
+	for i := 1; i < len(s.Datapoints); i++ {
+		if v := s.Datapoints[i].Timestamp - s.Datapoints[i-1].Timestamp; v > 0 && (d == 0 || v < d) {
+			d = v
+		}
+	}
+	if d == 0 {
+		return step
+	}
+	return d
+}
+
+// average returns the average of the non-null values, or an empty
+// value if all of the values are null
+func average(values []*float64) json.Number {
+	var sum float64
+	var n int
+	for _, v := range values {
+		if v != nil {
+			sum += *v
+			n++
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	return json.Number(strconv.FormatFloat(sum/float64(n), 'f', -1, 64))
+}
+
+// consolidateWriter buffers a JSON render response from the DeltaProxyCache engine, so
+// it can be consolidated to the client's maxDataPoints once the response is complete
+type consolidateWriter struct {
+	http.ResponseWriter
+	maxDataPoints int
+	step          int64
+	statusCode    int
+	body          *bytes.Buffer
+}
+
+func newConsolidateWriter(w http.ResponseWriter, maxDataPoints int, step int64) *consolidateWriter {
+	return &consolidateWriter{ResponseWriter: w, maxDataPoints: maxDataPoints, step: step,
+		statusCode: http.StatusOK, body: &bytes.Buffer{}}
+}
+
+// WriteHeader records the status code until the response is flushed
+func (cw *consolidateWriter) WriteHeader(code int) {
+	cw.statusCode = code
+}
+
+// Write buffers the response body until the response is flushed
+func (cw *consolidateWriter) Write(b []byte) (int, error) {
+	return cw.body.Write(b)
+}
+
+// flush writes the buffered response to the underlying ResponseWriter, consolidated
+// to the maxDataPoints when the response is a successful JSON document. Anything
+// else, such as an upstream error page, is passed through unmodified
+func (cw *consolidateWriter) flush() {
+	b := cw.body.Bytes()
+	h := cw.ResponseWriter.Header()
+	if cw.statusCode == http.StatusOK &&
+		strings.HasPrefix(h.Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		se := &SeriesEnvelope{}
+		if err := json.Unmarshal(b, se); err == nil {
+			se.consolidate(cw.maxDataPoints, cw.step)
+			if data, err := json.Marshal(se); err == nil {
+				b = data
+				h.Del(headers.NameContentLength)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	cw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+const testTenMinutes = `[{"target":"a","datapoints":[[1,1577836800],[2,1577836860],` +
+	`[3,1577836920],[4,1577836980],[5,1577837040],[6,1577837100],[7,1577837160],` +
+	`[8,1577837220],[9,1577837280],[10,1577837340]]}]`
+
+func TestConsolidate(t *testing.T) {
+
+	tests := []struct {
+		data          string
+		maxDataPoints int
+		expected      string
+	}{
+		// the bands are nudged to multiples of the seconds per point
+		{testTenMinutes, 3, `[{"target":"a","datapoints":[[5.5,1577837040],[9,1577837280]]}]`},
+		{testTenMinutes, 1, `[{"target":"a","datapoints":[[5.5,1577836800]]}]`},
+		{testTenMinutes, 10, testTenMinutes},
+		{`[{"target":"a","datapoints":[[null,1577836800],[null,1577836860],[1,1577836920],[null,1577836980]]}]`, 1,
+			`[{"target":"a","datapoints":[[1,1577836800]]}]`},
+		{`[{"target":"a","datapoints":[[null,1577836800],[null,1577836860]]}]`, 1,
+			`[{"target":"a","datapoints":[[null,1577836800]]}]`},
+		// a single datapoint uses the configured step
+		{`[{"target":"a","datapoints":[[1,1577836800]]},{"target":"b","datapoints":[]}]`, 1,
+			`[{"target":"a","datapoints":[[1,1577836800]]},{"target":"b","datapoints":[]}]`},
+		{`[]`, 1, `[]`},
+	}
+
+	for i, test := range tests {
+		se := testEnvelope(t, test.data)
+		se.consolidate(test.maxDataPoints, 60)
+		client := &Client{}
+		b, err := client.MarshalTimeseries(se)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("%d: expected %s got %s", i, test.expected, string(b))
+		}
+	}
+
+}
+
+func TestConsolidateWriter(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	cw := newConsolidateWriter(w, 1, 60)
+	cw.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	cw.Header().Set(headers.NameContentLength, "1000")
+	cw.WriteHeader(http.StatusOK)
+	cw.Write([]byte(testTenMinutes))
+	cw.flush()
+
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	const expected = `[{"target":"a","datapoints":[[5.5,1577836800]]}]`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+	if v := resp.Header.Get(headers.NameContentLength); v != "" {
+		t.Errorf("expected empty content length got %s", v)
+	}
+
+	// errors are passed through unmodified
+	w = httptest.NewRecorder()
+	cw = newConsolidateWriter(w, 1, 60)
+	cw.WriteHeader(http.StatusBadRequest)
+	cw.Write([]byte("error"))
+	cw.flush()
+
+	resp = w.Result()
+	b, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadRequest || string(b) != "error" {
+		t.Errorf("expected %d %s got %d %s", http.StatusBadRequest, "error", resp.StatusCode, string(b))
+	}
+
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// RenderHandler handles requests to the render API and processes JSON render requests
+// through the delta proxy cache. graphite-web consolidates the datapoints of a request
+// with a maxDataPoints into bands that depend on the requested range, which can't be
+// merged across partial hits. So the maxDataPoints is removed from the upstream request,
+// and the datapoints are consolidated by Trickster before responding to the client.
+func (c *Client) RenderHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+
+	v, _, _ := params.GetRequestValues(r)
+	s := v.Get(upMaxDataPoints)
+	if s == "" {
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+
+	mdp, err := strconv.Atoi(s)
+	if err != nil || mdp < 1 {
+		c.ProxyHandler(w, r)
+		return
+	}
+
+	// targets that set their own consolidation function are consolidated by the origin
+	for _, t := range v[upTarget] {
+		if strings.Contains(t, "consolidateBy") {
+			c.ProxyHandler(w, r)
+			return
+		}
+	}
+
+	v.Del(upMaxDataPoints)
+	params.SetRequestValues(r, v)
+
+	cw := newConsolidateWriter(w, mdp, int64(c.step.Seconds()))
+	engines.DeltaProxyCacheRequest(cw, r)
+	cw.flush()
+}
+
+// renderHandlerDeriveCacheKey calculates a cache key from the path, the credentials
+// and all of the query parameters other than those of the time range, including each
+// of the targets, in the order they were requested
+func (c *Client) renderHandlerDeriveCacheKey(path string, qp url.Values,
+	h http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+	var sb strings.Builder
+	sb.WriteString(path)
+	if v := h.Get(headers.NameAuthorization); v != "" {
+		sb.WriteString("." + headers.NameAuthorization + "." + v)
+	}
+	keys := make([]string, 0, len(qp))
+	for k := range qp {
+		switch k {
+		case upFrom, upUntil, upNow, upTZ, upMaxDataPoints:
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString("." + k + "." + strings.Join(qp[k], ","))
+	}
+	sb.WriteString(extra)
+	return md5.Checksum(sb.String()), body
+}
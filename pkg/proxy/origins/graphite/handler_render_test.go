@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestRenderHandler(t *testing.T) {
+
+	ts0 := time.Now().Add(-7 * time.Minute).Truncate(time.Minute).Unix()
+	body := `[{"target":"a.b","datapoints":[[1,` + strconv.FormatInt(ts0, 10) +
+		`],[3,` + strconv.FormatInt(ts0+60, 10) + `]]}]`
+
+	client := &Client{name: "test", step: time.Minute}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, body, map[string]string{headers.NameContentType: headers.ValueApplicationJSON},
+		"graphite", "/render?target=a.b&format=json&from=-10min&until=-5min", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	rsc.PathConfig = client.config.Paths["/"+mnRender]
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	client.RenderHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(string(bodyBytes), `[3,`+strconv.FormatInt(ts0+60, 10)+`]`) {
+		t.Errorf("expected render datapoints got %s.", bodyBytes)
+	}
+
+	// a wider time range is a partial hit, and a maxDataPoints is consolidated by Trickster
+	r, _ = http.NewRequest(http.MethodPost, ts.URL+"/render",
+		strings.NewReader("target=a.b&format=json&from=-15min&until=-5min&maxDataPoints=1"))
+	r.Header.Set(headers.NameContentType, "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	r = r.WithContext(ctx)
+
+	client.RenderHandler(w, r)
+	resp = w.Result()
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=phit") {
+		t.Errorf("expected partial hit got %s", v)
+	}
+	bodyBytes, _ = ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(bodyBytes), `"datapoints":[[2,`) {
+		t.Errorf("expected consolidated datapoints got %s.", bodyBytes)
+	}
+
+	// targets with their own consolidation function are proxied
+	for _, q := range []string{"maxDataPoints=1&target=consolidateBy(a.b,'max')", "maxDataPoints=abc"} {
+		r, _ = http.NewRequest(http.MethodGet, ts.URL+"/render?format=json&target=a.b&"+q, nil)
+		w = httptest.NewRecorder()
+		r = r.WithContext(ctx)
+
+		client.RenderHandler(w, r)
+		resp = w.Result()
+		if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "engine=HTTPProxy") {
+			t.Errorf("expected proxy got %s", v)
+		}
+	}
+
+}
+
+func TestRenderHandlerDeriveCacheKey(t *testing.T) {
+
+	client := &Client{name: "test"}
+	const path = "/render"
+
+	k1, _ := client.renderHandlerDeriveCacheKey(path, url.Values{"target": {"a", "b"},
+		"from": {"-1d"}, "until": {"now"}, "format": {"json"}}, http.Header{}, nil, "")
+	k2, _ := client.renderHandlerDeriveCacheKey(path, url.Values{"target": {"a", "b"},
+		"from": {"-7d"}, "tz": {"UTC"}, "maxDataPoints": {"100"}, "format": {"json"}}, http.Header{}, nil, "")
+	if k1 != k2 {
+		t.Errorf("expected %s got %s", k1, k2)
+	}
+
+	k2, _ = client.renderHandlerDeriveCacheKey(path, url.Values{"target": {"b", "a"},
+		"format": {"json"}}, http.Header{}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for differently ordered targets")
+	}
+
+	k2, _ = client.renderHandlerDeriveCacheKey(path, url.Values{"target": {"a", "b"},
+		"format": {"json"}}, http.Header{"Authorization": {"Basic dGVzdDp0ZXN0"}}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for different credentials")
+	}
+
+}
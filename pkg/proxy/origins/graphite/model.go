@@ -0,0 +1,369 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// SeriesEnvelope values represent a time series data response from the
+// Graphite render API in the JSON format
+type SeriesEnvelope struct {
+	Series       []*Series
+	ExtentList   timeseries.ExtentList
+	StepDuration time.Duration
+}
+
+// Series values represent the datapoints of a single render target
+type Series struct {
+	Target     string          `json:"target"`
+	Tags       json.RawMessage `json:"tags,omitempty"`
+	Datapoints Points          `json:"datapoints"`
+}
+
+// Point values represent a single datapoint of a series, which is
+// encoded as a [value, timestamp] pair. The value is kept as received,
+// and is empty when the datapoint is null
+type Point struct {
+	Value     json.Number
+	Timestamp int64
+}
+
+// Points values represent a slice of Point values
+type Points []Point
+
+// envelope is the JSON representation of a SeriesEnvelope when it is written to
+// the cache. Render responses are a list of series, so they are wrapped in an
+// object that includes the extents and step
+type envelope struct {
+	Series       []*Series             `json:"series"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+	StepDuration string                `json:"step,omitempty"`
+}
+
+// MarshalJSON encodes a series envelope value into a JSON byte slice.
+func (se *SeriesEnvelope) MarshalJSON() ([]byte, error) {
+	series := se.Series
+	if series == nil {
+		series = []*Series{}
+	}
+	if len(se.ExtentList) == 0 && se.StepDuration == 0 {
+		return json.Marshal(series)
+	}
+	e := envelope{Series: series, ExtentList: se.ExtentList}
+	if se.StepDuration != 0 {
+		e.StepDuration = se.StepDuration.String()
+	}
+	return json.Marshal(e)
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this series envelope value.
+func (se *SeriesEnvelope) UnmarshalJSON(b []byte) error {
+	se.ExtentList = nil
+	se.StepDuration = 0
+	se.Series = nil
+
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, &se.Series)
+	}
+
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return err
+	}
+	if e.Series == nil {
+		return fmt.Errorf("unable to unmarshal Graphite response: missing series")
+	}
+	se.Series = e.Series
+	se.ExtentList = e.ExtentList
+	if e.StepDuration != "" {
+		d, err := time.ParseDuration(e.StepDuration)
+		if err != nil {
+			return err
+		}
+		se.StepDuration = d
+	}
+	return nil
+}
+
+// MarshalJSON encodes a point value into a JSON byte slice.
+func (p Point) MarshalJSON() ([]byte, error) {
+	v := "null"
+	if p.Value != "" {
+		v = string(p.Value)
+	}
+	return []byte("[" + v + "," + strconv.FormatInt(p.Timestamp, 10) + "]"), nil
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this point value.
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var v []json.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("unable to unmarshal Graphite datapoint: %s", string(b))
+	}
+	var n json.Number
+	if err := json.Unmarshal(v[0], &n); err != nil {
+		return fmt.Errorf("unable to unmarshal Graphite datapoint: %s", err.Error())
+	}
+	var ts json.Number
+	if err := json.Unmarshal(v[1], &ts); err != nil {
+		return fmt.Errorf("unable to unmarshal Graphite datapoint: %s", err.Error())
+	}
+	t, err := ts.Int64()
+	if err != nil {
+		f, err := ts.Float64()
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal Graphite datapoint: %s", err.Error())
+		}
+		t = int64(f)
+	}
+	p.Value = n
+	p.Timestamp = t
+	return nil
+}
+
+// Step returns the step for the Timeseries.
+func (se *SeriesEnvelope) Step() time.Duration {
+	return se.StepDuration
+}
+
+// SetStep sets the step for the Timeseries.
+func (se *SeriesEnvelope) SetStep(step time.Duration) {
+	se.StepDuration = step
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent
+// list.
+func (se *SeriesEnvelope) SetExtents(extents timeseries.ExtentList) {
+	se.ExtentList = extents
+}
+
+// Extents returns the Timeseries's extent list.
+func (se *SeriesEnvelope) Extents() timeseries.ExtentList {
+	return se.ExtentList
+}
+
+// SeriesCount returns the number of individual series in the Timeseries value.
+func (se *SeriesEnvelope) SeriesCount() int {
+	return len(se.Series)
+}
+
+// ValueCount returns the count of all data values across all Series in the
+// Timeseries value.
+func (se *SeriesEnvelope) ValueCount() int {
+	c := 0
+	for _, s := range se.Series {
+		c += len(s.Datapoints)
+	}
+	return c
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries.
+func (se *SeriesEnvelope) TimestampCount() int {
+	return len(se.timestamps())
+}
+
+func (se *SeriesEnvelope) timestamps() map[int64]struct{} {
+	ts := map[int64]struct{}{}
+	for _, s := range se.Series {
+		for _, p := range s.Datapoints {
+			ts[p.Timestamp] = struct{}{}
+		}
+	}
+	return ts
+}
+
+// seriesKeys returns the keys used to match the series of the envelope with
+// those of another. Render targets can share a name (e.g., when aliased), so
+// the key includes the name's occurrence in the envelope as well
+func (se *SeriesEnvelope) seriesKeys() []string {
+	counts := make(map[string]int, len(se.Series))
+	keys := make([]string, len(se.Series))
+	for i, s := range se.Series {
+		k := s.Target + "\x00" + string(s.Tags)
+		keys[i] = k + "\x00" + strconv.Itoa(counts[k])
+		counts[k]++
+	}
+	return keys
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the
+// order provided) and optionally sorts the merged Timeseries. The series are
+// matched by their target name and tags.
+func (se *SeriesEnvelope) Merge(sort bool,
+	collection ...timeseries.Timeseries) {
+	sm := make(map[string]*Series, len(se.Series))
+	for i, k := range se.seriesKeys() {
+		sm[k] = se.Series[i]
+	}
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		se2, ok := ts.(*SeriesEnvelope)
+		if !ok {
+			continue
+		}
+		for i, k := range se2.seriesKeys() {
+			s2 := se2.Series[i]
+			s, ok := sm[k]
+			if !ok {
+				s = s2.clone()
+				sm[k] = s
+				se.Series = append(se.Series, s)
+				continue
+			}
+			s.Datapoints = append(s.Datapoints, s2.Datapoints...)
+		}
+		se.ExtentList = append(se.ExtentList, se2.ExtentList...)
+	}
+
+	se.ExtentList = se.ExtentList.Compress(se.StepDuration)
+	if sort {
+		se.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries.
+func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
+	b := &SeriesEnvelope{
+		Series:       make([]*Series, len(se.Series)),
+		ExtentList:   se.ExtentList.Clone(),
+		StepDuration: se.StepDuration,
+	}
+	for i, s := range se.Series {
+		b.Series[i] = s.clone()
+	}
+	return b
+}
+
+func (s *Series) clone() *Series {
+	s2 := *s
+	s2.Datapoints = make(Points, len(s.Datapoints))
+	copy(s2.Datapoints, s.Datapoints)
+	return &s2
+}
+
+// CropToRange crops down a Timeseries value to the provided Extent.
+func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
+	start, end := e.Start.Unix(), e.End.Unix()
+	for _, s := range se.Series {
+		pts := make(Points, 0, len(s.Datapoints))
+		for _, p := range s.Datapoints {
+			if p.Timestamp >= start && p.Timestamp <= end {
+				pts = append(pts, p)
+			}
+		}
+		s.Datapoints = pts
+	}
+	se.ExtentList = se.ExtentList.Crop(e)
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided
+// count, by evicting elements using a least-recently-used methodology. Any
+// timestamps newer than the provided time are removed before sizing, in order
+// to support backfill tolerance. The provided extent will be marked as used
+// during crop.
+func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
+	lur timeseries.Extent) {
+	// The Series has no extents or no room for any values, so it is emptied.
+	if len(se.ExtentList) < 1 || sz < 1 {
+		for _, s := range se.Series {
+			s.Datapoints = Points{}
+		}
+		se.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed.
+	if se.ExtentList[len(se.ExtentList)-1].End.After(t) {
+		se.CropToRange(timeseries.Extent{Start: se.ExtentList[0].Start, End: t})
+	}
+
+	ts := se.timestamps()
+	if len(ts) <= sz {
+		return
+	}
+
+	tsl := make([]int64, 0, len(ts))
+	for k := range ts {
+		tsl = append(tsl, k)
+	}
+	sort.Slice(tsl, func(i, j int) bool { return tsl[i] < tsl[j] })
+	tsl = tsl[len(tsl)-sz:]
+	e := timeseries.Extent{Start: time.Unix(tsl[0], 0), End: time.Unix(tsl[len(tsl)-1], 0)}
+	se.CropToRange(e)
+	se.ExtentList = timeseries.ExtentList{e}
+}
+
+// Sort sorts all data in the Timeseries chronologically by their timestamp,
+// and removes duplicate datapoints by retaining the most recently merged value
+func (se *SeriesEnvelope) Sort() {
+	for _, s := range se.Series {
+		s.sort()
+	}
+}
+
+func (s *Series) sort() {
+	sort.SliceStable(s.Datapoints, func(i, j int) bool {
+		return s.Datapoints[i].Timestamp < s.Datapoints[j].Timestamp
+	})
+	pts := make(Points, 0, len(s.Datapoints))
+	for i, p := range s.Datapoints {
+		if i+1 < len(s.Datapoints) && p.Timestamp == s.Datapoints[i+1].Timestamp {
+			continue
+		}
+		pts = append(pts, p)
+	}
+	s.Datapoints = pts
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (se *SeriesEnvelope) Size() int {
+	c := (len(se.ExtentList) * 72) + // time.Time (24) * 3
+		24 + 8 // .StepDuration, .Series
+	for _, s := range se.Series {
+		c += len(s.Target) + len(s.Tags)
+		for _, p := range s.Datapoints {
+			c += 24 + len(p.Value) // timestamp (8) + value (16) + value data
+		}
+	}
+	return c
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob for cache storage.
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries value.
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	se := &SeriesEnvelope{}
+	err := json.Unmarshal(data, se)
+	return se, err
+}
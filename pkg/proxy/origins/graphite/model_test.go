@@ -0,0 +1,245 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const testRender = `[` +
+	`{"target":"a.b","tags":{"name":"a.b"},"datapoints":[[1,1577836800],[null,1577836860],[2.5,1577836920]]},` +
+	`{"target":"c.d","tags":{"name":"c.d"},"datapoints":[[3,1577836800]]}` +
+	`]`
+
+func testEnvelope(t *testing.T, data string) *SeriesEnvelope {
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts.(*SeriesEnvelope)
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	se := testEnvelope(t, testRender)
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+	if se.Series[0].Target != "a.b" || se.Series[1].Target != "c.d" {
+		t.Errorf("unexpected series %v", se.Series)
+	}
+	if se.ValueCount() != 4 || se.TimestampCount() != 3 {
+		t.Errorf("expected %d, %d got %d, %d", 4, 3, se.ValueCount(), se.TimestampCount())
+	}
+	if p := se.Series[0].Datapoints[1]; p.Value != "" || p.Timestamp != 1577836860 {
+		t.Errorf("expected null value at %d got %s at %d", 1577836860, p.Value, p.Timestamp)
+	}
+
+	// timestamps are truncated to seconds, and out-of-range values are retained
+	se = testEnvelope(t, `[{"target":"a","datapoints":[[1e9999,1577836800.0]]}]`)
+	if p := se.Series[0].Datapoints[0]; p.Value != "1e9999" || p.Timestamp != 1577836800 {
+		t.Errorf("expected %s at %d got %s at %d", "1e9999", 1577836800, p.Value, p.Timestamp)
+	}
+
+	client := &Client{}
+	for _, data := range []string{`[`, `{}`, `{"series":[],"step":"abc"}`,
+		`[{"target":"a","datapoints":[[1]]}]`, `[{"target":"a","datapoints":[["a",1]]}]`,
+		`[{"target":"a","datapoints":[[1,"a"]]}]`, `[{"target":"a","datapoints":[1]}]`} {
+		if _, err := client.UnmarshalTimeseries([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	se := testEnvelope(t, testRender)
+	b, err := client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testRender {
+		t.Errorf("expected %s got %s", testRender, string(b))
+	}
+
+	// the extents and step are retained for the cache
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	b, err = client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se2 := testEnvelope(t, string(b))
+	if se2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, se2.Step())
+	}
+	if se2.Extents().String() != se.Extents().String() {
+		t.Errorf("expected %s got %s", se.Extents(), se2.Extents())
+	}
+	if se2.ValueCount() != 4 {
+		t.Errorf("unexpected series %s", string(b))
+	}
+
+	b, err = client.MarshalTimeseries(&SeriesEnvelope{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[]` {
+		t.Errorf("expected %s got %s", `[]`, string(b))
+	}
+
+}
+
+func TestMerge(t *testing.T) {
+
+	// cached data for the first two minutes
+	se := testEnvelope(t, testRender)
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+
+	// the delta for the following minutes, which includes a new series, and
+	// a series with the same target name
+	se2 := testEnvelope(t, `[`+
+		`{"target":"a.b","tags":{"name":"a.b"},"datapoints":[[5,1577836920],[6,1577836980]]},`+
+		`{"target":"c.d","tags":{"name":"c.d"},"datapoints":[[7,1577836980]]},`+
+		`{"target":"c.d","tags":{"name":"c.d"},"datapoints":[[8,1577836980]]}]`)
+	se2.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836980, 0), End: time.Unix(1577836980, 0)}})
+
+	se.Merge(true, se2, nil)
+
+	if se.SeriesCount() != 3 {
+		t.Errorf("expected %d got %d", 3, se.SeriesCount())
+	}
+	if len(se.Series[0].Datapoints) != 4 {
+		t.Fatalf("expected %d got %d", 4, len(se.Series[0].Datapoints))
+	}
+	if v := se.Series[0].Datapoints[2].Value; v != "5" {
+		t.Errorf("expected merged value to be retained, got %s", v)
+	}
+	if len(se.Series[1].Datapoints) != 2 || se.Series[2].Datapoints[0].Value != "8" {
+		t.Errorf("unexpected series %v", se.Series[1:])
+	}
+	expected := timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836980, 0)}}
+	if se.Extents().String() != expected.String() {
+		t.Errorf("expected %s got %s", expected, se.Extents())
+	}
+
+}
+
+func TestSort(t *testing.T) {
+
+	se := testEnvelope(t, `[{"target":"a","datapoints":[[3,1577836920],[1,1577836800],[2,1577836860],[4,1577836800]]}]`)
+	se.Sort()
+
+	pts := se.Series[0].Datapoints
+	if len(pts) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(pts))
+	}
+	if pts[0].Value != "4" || pts[1].Timestamp != 1577836860 || pts[2].Timestamp != 1577836920 {
+		t.Errorf("expected datapoints to be sorted by time, got %v", pts)
+	}
+
+}
+
+func TestClone(t *testing.T) {
+
+	se := testEnvelope(t, testRender)
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	se2 := se.Clone().(*SeriesEnvelope)
+
+	if se2.SeriesCount() != se.SeriesCount() || se2.ValueCount() != se.ValueCount() ||
+		se2.Step() != se.Step() || se2.Extents().String() != se.Extents().String() {
+		t.Error("expected clone to match")
+	}
+
+	se2.Series[0].Datapoints = se2.Series[0].Datapoints[:1]
+	se2.Series[1].Target = "changed"
+	if len(se.Series[0].Datapoints) != 3 || se.Series[1].Target != "c.d" {
+		t.Error("expected clone to be independent")
+	}
+
+}
+
+func TestCropToRange(t *testing.T) {
+
+	se := testEnvelope(t, testRender)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	e := timeseries.Extent{Start: time.Unix(1577836860, 0), End: time.Unix(1577836980, 0)}
+	se.CropToRange(e)
+
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+	if len(se.Series[1].Datapoints) != 0 {
+		t.Errorf("expected %d got %d", 0, len(se.Series[1].Datapoints))
+	}
+
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Unix(1577837000, 0)
+	se := testEnvelope(t, testRender)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+
+	se.CropToSize(3, now, timeseries.Extent{})
+	if se.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, se.ValueCount())
+	}
+
+	se.CropToSize(1, now, timeseries.Extent{})
+	if se.ValueCount() != 1 || se.TimestampCount() != 1 {
+		t.Errorf("expected %d got %d", 1, se.ValueCount())
+	}
+	expected := timeseries.ExtentList{{Start: time.Unix(1577836920, 0), End: time.Unix(1577836920, 0)}}
+	if se.Extents().String() != expected.String() {
+		t.Errorf("expected %s got %s", expected, se.Extents())
+	}
+
+	// values newer than the backfill tolerance are removed
+	se = testEnvelope(t, testRender)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	se.CropToSize(10, time.Unix(1577836800, 0), timeseries.Extent{})
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+
+	se.CropToSize(0, now, timeseries.Extent{})
+	if se.ValueCount() != 0 || len(se.Extents()) != 0 {
+		t.Errorf("expected empty timeseries got %d values", se.ValueCount())
+	}
+
+}
+
+func TestSize(t *testing.T) {
+
+	se := testEnvelope(t, testRender)
+	if se.Size() <= se.ValueCount()*24 {
+		t.Errorf("expected size greater than %d got %d", se.ValueCount()*24, se.Size())
+	}
+
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides configurations that are specific to the Graphite Origin Type
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrInvalidStep is returned when the step_secs is not positive
+var ErrInvalidStep = errors.New("graphite step_secs must be greater than 0")
+
+// Options is a collection of Graphite-specific origin configurations
+type Options struct {
+	// StepSecs is the seconds per point of the finest retention of the origin's storage
+	// schemas. The render API has no step parameter, so it is used as the step of the
+	// cached render requests, to align their extents to the origin's datapoints
+	StepSecs int `toml:"step_secs"`
+
+	// Step is the time.Duration representation of StepSecs
+	Step time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		StepSecs: d.DefaultGraphiteStepSecs,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	return &o2
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.StepSecs < 1 {
+		return ErrInvalidStep
+	}
+	return nil
+}
+
+// SetDurations populates the synthesized time.Duration values from their *Secs counterparts
+func (o *Options) SetDurations() {
+	o.Step = time.Duration(o.StepSecs) * time.Second
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, o.Step)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.StepSecs = 10
+	o.SetDurations()
+	o2 := o.Clone()
+	if o2 == o {
+		t.Error("expected distinct pointers")
+	}
+	if o2.StepSecs != 10 || o2.Step != 10*time.Second {
+		t.Errorf("expected %d/%s got %d/%s", 10, 10*time.Second, o2.StepSecs, o2.Step)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	o := NewOptions()
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	o.StepSecs = 0
+	if err := o.Validate(); err != ErrInvalidStep {
+		t.Errorf("expected %v got %v", ErrInvalidStep, err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// Graphite API path segments
+const (
+	mnRender  = "render"
+	mnMetrics = "metrics"
+	mnFind    = "find"
+)
+
+// Common Graphite URL Parameter Names
+const (
+	upTarget        = "target"
+	upFrom          = "from"
+	upUntil         = "until"
+	upNow           = "now"
+	upTZ            = "tz"
+	upFormat        = "format"
+	upJSONP         = "jsonp"
+	upNoCache       = "noCache"
+	upMaxDataPoints = "maxDataPoints"
+	upQuery         = "query"
+	upWildcards     = "wildcards"
+)
+
+// Graphite defaults for omitted render parameters
+const (
+	defaultFrom  = "-24h"
+	defaultUntil = "now"
+	formatJSON   = "json"
+)
+
+// SetExtent will change the upstream request query to use the provided Extent
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	if extent == nil || r == nil {
+		return
+	}
+	v, _, _ := params.GetRequestValues(r)
+	// Graphite returns the datapoints after the from time, through the until
+	// time, so from is moved back by a second to include the extent's start
+	v.Set(upFrom, strconv.FormatInt(extent.Start.Unix()-1, 10))
+	v.Set(upUntil, strconv.FormatInt(extent.End.Unix(), 10))
+	params.SetRequestValues(r, v)
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request.
+// Only JSON render requests are cacheable, since the other formats (e.g., png or csv) can't
+// be merged across partial hits.
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	v, _, _ := params.GetRequestValues(r)
+
+	targets := v[upTarget]
+	if len(targets) == 0 {
+		return nil, errors.MissingURLParam(upTarget)
+	}
+	if v.Get(upFormat) != formatJSON || v.Get(upJSONP) != "" || isTrue(v.Get(upNoCache)) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: strings.Join(targets, "\n"),
+		Step:      c.step,
+		// Graphite stores its datapoints at multiples of their
+		// seconds per point since the epoch
+		AlignToEpoch: true,
+	}
+
+	loc := time.UTC
+	if s := v.Get(upTZ); s != "" {
+		l, err := time.LoadLocation(s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse time zone %s: %s", s, err.Error())
+		}
+		loc = l
+	}
+
+	now := time.Now()
+	var err error
+	if s := v.Get(upNow); s != "" {
+		if now, err = parseTime(s, now, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	s := v.Get(upFrom)
+	if s == "" {
+		s = defaultFrom
+	}
+	if trq.Extent.Start, err = parseTime(s, now, loc); err != nil {
+		return nil, err
+	}
+	// Graphite returns the datapoints after the from time
+	trq.Extent.Start = trq.Extent.Start.Add(time.Second)
+
+	if s = v.Get(upUntil); s == "" {
+		s = defaultUntil
+	}
+	if trq.Extent.End, err = parseTime(s, now, loc); err != nil {
+		return nil, err
+	}
+
+	if !trq.Extent.Start.Before(trq.Extent.End) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	return trq, nil
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseTime parses a Graphite time string, which is a Unix epoch timestamp, an
+// absolute HH:MM_YYYYMMDD or YYYYMMDD time, or a time relative to now (e.g., "now",
+// "-1h" or "now-7d"). Absolute dates are in the provided time zone. Other time
+// references (e.g., "midnight" or "yesterday") are not supported
+func parseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	v := strings.ToLower(strings.NewReplacer("_", "", ",", "", " ", "").Replace(s))
+	if isDigits(v) && !isDate(v) {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+		}
+		return time.Unix(n, 0), nil
+	}
+	if len(v) == 13 && strings.Contains(v, ":") {
+		t, err := time.ParseInLocation("15:0420060102", v, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+		}
+		return t, nil
+	}
+
+	ref, offset := v, ""
+	if i := strings.IndexAny(v, "+-"); i >= 0 {
+		ref, offset = v[:i], v[i:]
+	}
+
+	var t time.Time
+	switch {
+	case ref == "" || ref == "now":
+		t = now
+	case isDate(ref):
+		var err error
+		if t, err = time.ParseInLocation("20060102", ref, loc); err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+		}
+	default:
+		return time.Time{}, fmt.Errorf("unable to parse time %s", s)
+	}
+
+	d, err := parseOffset(offset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+	}
+	return t.Add(d), nil
+}
+
+// isDate returns true if the provided string is a YYYYMMDD date, which
+// Graphite distinguishes from an epoch timestamp of the same length
+func isDate(s string) bool {
+	if len(s) != 8 || !isDigits(s) {
+		return false
+	}
+	y, _ := strconv.Atoi(s[:4])
+	m, _ := strconv.Atoi(s[4:6])
+	d, _ := strconv.Atoi(s[6:])
+	return y > 1900 && m < 13 && d < 32
+}
+
+// parseOffset parses a Graphite time offset (e.g., "-1h" or "+1d12h"), where each
+// unit is identified by its prefix, as in "5min", "30s" or "2weeks"
+func parseOffset(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	sign := time.Duration(1)
+	switch s[0] {
+	case '-':
+		sign = -1
+		fallthrough
+	case '+':
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid offset")
+	}
+	var d time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if i < 0 {
+			i = len(s)
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, err
+		}
+		s = s[i:]
+		j := strings.IndexFunc(s, func(r rune) bool { return r < 'a' || r > 'z' })
+		if j < 0 {
+			j = len(s)
+		}
+		u, err := offsetUnit(s[:j])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * u
+		s = s[j:]
+	}
+	return sign * d, nil
+}
+
+// offsetUnit returns the duration of the provided Graphite offset unit, where
+// months are 30 days and years are 365 days
+func offsetUnit(u string) (time.Duration, error) {
+	switch {
+	case strings.HasPrefix(u, "s"):
+		return time.Second, nil
+	case strings.HasPrefix(u, "min"):
+		return time.Minute, nil
+	case strings.HasPrefix(u, "h"):
+		return time.Hour, nil
+	case strings.HasPrefix(u, "d"):
+		return 24 * time.Hour, nil
+	case strings.HasPrefix(u, "w"):
+		return 7 * 24 * time.Hour, nil
+	case strings.HasPrefix(u, "mon"):
+		return 30 * 24 * time.Hour, nil
+	case strings.HasPrefix(u, "y"):
+		return 365 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid offset unit %s", u)
+}
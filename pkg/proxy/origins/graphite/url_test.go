@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphite
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{}
+	r, _ := http.NewRequest(http.MethodGet,
+		"http://0/render?target=a.b&from=-1d&until=now&format=json", nil)
+	e := &timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}
+	client.SetExtent(r, nil, e)
+
+	expected := "format=json&from=1577836799&target=a.b&until=1577840400"
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+	// nil extents are ignored
+	client.SetExtent(r, nil, nil)
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+	// form values are set in the request body
+	r, _ = http.NewRequest(http.MethodPost, "http://0/render",
+		strings.NewReader("target=a.b&format=json"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client.SetExtent(r, nil, e)
+	r.PostForm = nil
+	r.ParseForm()
+	if v := r.PostForm.Get(upFrom); v != "1577836799" {
+		t.Errorf("expected %s got %s", "1577836799", v)
+	}
+
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{step: time.Minute}
+	tests := []struct {
+		query      string
+		start, end int64
+		expectErr  bool
+	}{
+		{"target=a.b&format=json&from=1577836800&until=1577923200", 1577836801, 1577923200, false},
+		{"target=a.b&target=c.d&format=json&from=1577836800&until=20200101%2B1d",
+			1577836801, 1577923200, false},
+		{"target=a.b&format=json&from=00:00_20200101&until=20200102", 1577836801, 1577923200, false},
+		{"target=a.b&format=json&from=-1d&until=now&now=1577923200", 1577836801, 1577923200, false},
+		{"target=a.b&format=json&from=00:00_20200101&until=00:00_20200102&tz=America/New_York",
+			1577854801, 1577941200, false},
+		{"format=json&from=-1d", 0, 0, true},
+		{"target=a.b&from=-1d", 0, 0, true},
+		{"target=a.b&format=png&from=-1d", 0, 0, true},
+		{"target=a.b&format=json&from=-1d&jsonp=cb", 0, 0, true},
+		{"target=a.b&format=json&from=-1d&noCache=true", 0, 0, true},
+		{"target=a.b&format=json&from=midnight", 0, 0, true},
+		{"target=a.b&format=json&until=yesterday", 0, 0, true},
+		{"target=a.b&format=json&from=-1d&tz=Invalid/Zone", 0, 0, true},
+		{"target=a.b&format=json&from=-1d&now=abc", 0, 0, true},
+		{"target=a.b&format=json&from=now&until=-1d", 0, 0, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://0/render?"+test.query, nil)
+			trq, err := client.ParseTimeRangeQuery(r)
+			if test.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trq.Extent.Start.Unix() != test.start {
+				t.Errorf("expected %d got %d", test.start, trq.Extent.Start.Unix())
+			}
+			if trq.Extent.End.Unix() != test.end {
+				t.Errorf("expected %d got %d", test.end, trq.Extent.End.Unix())
+			}
+			if trq.Step != time.Minute {
+				t.Errorf("expected %s got %s", time.Minute, trq.Step)
+			}
+			if !trq.AlignToEpoch {
+				t.Error("expected epoch alignment")
+			}
+		})
+	}
+
+	// relative times default to the previous day, from which the datapoints follow
+	r, _ := http.NewRequest(http.MethodPost, "http://0/render",
+		strings.NewReader(url.Values{"target": {"a.b"}, "format": {"json"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := trq.Extent.End.Sub(trq.Extent.Start); d != 24*time.Hour-time.Second {
+		t.Errorf("expected %s got %s", 24*time.Hour-time.Second, d)
+	}
+	if trq.Statement != "a.b" {
+		t.Errorf("expected %s got %s", "a.b", trq.Statement)
+	}
+
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Unix(1577836800, 0)
+	tests := []struct {
+		s         string
+		expected  time.Time
+		expectErr bool
+	}{
+		{"now", now, false},
+		{" now ", now, false},
+		{"1577836800", now, false},
+		{"-30d", now.Add(-30 * 24 * time.Hour), false},
+		{"now-5min", now.Add(-5 * time.Minute), false},
+		{"+1h", now.Add(time.Hour), false},
+		{"-1d12h", now.Add(-36 * time.Hour), false},
+		{"-2weeks", now.Add(-14 * 24 * time.Hour), false},
+		{"-1mon", now.Add(-30 * 24 * time.Hour), false},
+		{"-1y", now.Add(-365 * 24 * time.Hour), false},
+		{"-10seconds", now.Add(-10 * time.Second), false},
+		{"20200101", now, false},
+		{"20200102-1d", now, false},
+		{"00:00_20200101", now, false},
+		{"-1m", time.Time{}, true},
+		{"-", time.Time{}, true},
+		{"-h", time.Time{}, true},
+		{"-1", time.Time{}, true},
+		{"midnight", time.Time{}, true},
+		{"25:00_20200101", time.Time{}, true},
+		{"99999999999999999999", time.Time{}, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v, err := parseTime(test.s, now, time.UTC)
+			if test.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !v.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+
+}
+
+func TestIsDate(t *testing.T) {
+	if !isDate("20200101") {
+		t.Error("expected date")
+	}
+	// an 8-digit timestamp that can't be a date is an epoch timestamp
+	if isDate("99999999") {
+		t.Error("expected timestamp")
+	}
+}
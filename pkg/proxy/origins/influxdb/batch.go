@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+)
+
+// splitStatements splits an InfluxQL query into its semicolon-separated statements.
+// Semicolons within quoted strings and identifiers are ignored, and empty statements
+// are discarded
+func splitStatements(q string) []string {
+	statements := make([]string, 0, 1)
+	var quote byte
+	var escaped bool
+	start := 0
+	add := func(end int) {
+		if s := strings.TrimSpace(q[start:end]); s != "" {
+			statements = append(statements, s)
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(q); i++ {
+		b := q[i]
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\':
+			escaped = true
+		case quote != 0:
+			if b == quote {
+				quote = 0
+			}
+		case b == '\'' || b == '"':
+			quote = b
+		case b == ';':
+			add(i)
+		}
+	}
+	add(len(q))
+	return statements
+}
+
+// allSelects returns true if each of the statements is a SELECT
+func allSelects(statements []string) bool {
+	for _, s := range statements {
+		if !strings.HasPrefix(strings.ToLower(s), "select ") {
+			return false
+		}
+	}
+	return true
+}
+
+// batchQueryHandler handles a multi-statement query of SELECT statements by processing each
+// statement as its own request through the delta proxy cache, concurrently, and reassembling
+// the results in order
+func (c *Client) batchQueryHandler(w http.ResponseWriter, r *http.Request,
+	v url.Values, statements []string, f responseFormat) {
+
+	rsc := request.GetResources(r)
+	responses := make([]*bufferedResponseWriter, len(statements))
+	wg := sync.WaitGroup{}
+	for i, s := range statements {
+		sv := url.Values(http.Header(v).Clone())
+		sv.Set(upQuery, s)
+		sr := r.Clone(r.Context())
+		if rsc != nil {
+			src := rsc.Clone()
+			src.TimeRangeQuery = nil
+			sr = request.SetResources(sr, src)
+		}
+		params.SetRequestValues(sr, sv)
+		responses[i] = newBufferedResponseWriter()
+		wg.Add(1)
+		go func(bw *bufferedResponseWriter, sr *http.Request) {
+			engines.DeltaProxyCacheRequest(bw, sr)
+			wg.Done()
+		}(responses[i], sr)
+	}
+	wg.Wait()
+
+	se := &SeriesEnvelope{Results: make([]Result, 0, len(statements))}
+	for i, bw := range responses {
+		// an unsuccessful statement fails the batch, with its response passed through
+		if bw.statusCode != http.StatusOK {
+			engines.Respond(w, bw.statusCode, bw.header, bw.body.Bytes())
+			return
+		}
+		sse := &SeriesEnvelope{}
+		if err := json.Unmarshal(bw.body.Bytes(), sse); err != nil {
+			engines.Respond(w, http.StatusBadGateway, nil, []byte(err.Error()))
+			return
+		}
+		if sse.Err != "" && se.Err == "" {
+			se.Err = sse.Err
+		}
+		for _, res := range sse.Results {
+			res.StatementID = i
+			se.Results = append(se.Results, res)
+		}
+	}
+
+	b, err := se.marshalFormat(f)
+	if err != nil {
+		engines.Respond(w, http.StatusInternalServerError, nil, []byte(err.Error()))
+		return
+	}
+	h := responses[0].header
+	h.Set(headers.NameContentType, f.ContentType())
+	h.Del(headers.NameContentLength)
+	engines.Respond(w, http.StatusOK, h, b)
+}
+
+// bufferedResponseWriter is an http.ResponseWriter that retains the response in memory
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       *bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header), statusCode: http.StatusOK,
+		body: &bytes.Buffer{}}
+}
+
+// Header returns the response headers
+func (bw *bufferedResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+// WriteHeader records the response status code
+func (bw *bufferedResponseWriter) WriteHeader(code int) {
+	bw.statusCode = code
+}
+
+// Write buffers the response body
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return bw.body.Write(b)
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		q        string
+		expected []string
+	}{
+		{`SELECT a FROM b`, []string{`SELECT a FROM b`}},
+		{`SELECT a FROM b; SELECT c FROM d;`, []string{`SELECT a FROM b`, `SELECT c FROM d`}},
+		{`SELECT a FROM b WHERE c = 'x;y'; SELECT "e;f" FROM g`,
+			[]string{`SELECT a FROM b WHERE c = 'x;y'`, `SELECT "e;f" FROM g`}},
+		{`SELECT a FROM b WHERE c = 'x\';y'`, []string{`SELECT a FROM b WHERE c = 'x\';y'`}},
+		{` ; `, []string{}},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			s := splitStatements(test.q)
+			if !reflect.DeepEqual(s, test.expected) {
+				t.Errorf("expected %v got %v", test.expected, s)
+			}
+		})
+	}
+}
+
+func testBatchInstance(t *testing.T, q string) (*Client, []byte, int) {
+	const body = `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],` +
+		`"values":[[1000,1.5]]}]}]}`
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, body, nil,
+		"influxdb", "/query?"+url.Values{"q": {q}, "epoch": {"ms"}}.Encode(), "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	client.QueryHandler(w, r)
+
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return client, b, resp.StatusCode
+}
+
+func TestBatchQueryHandler(t *testing.T) {
+
+	_, b, code := testBatchInstance(t, "select test1; select test2")
+	if code != 200 {
+		t.Errorf("expected 200 got %d.", code)
+	}
+	se := &SeriesEnvelope{}
+	if err := json.Unmarshal(b, se); err != nil {
+		t.Fatal(err)
+	}
+	if len(se.Results) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(se.Results))
+	}
+	for i, res := range se.Results {
+		if res.StatementID != i {
+			t.Errorf("expected %d got %d", i, res.StatementID)
+		}
+		if len(res.Series) != 1 {
+			t.Errorf("expected %d got %d", 1, len(res.Series))
+		}
+	}
+
+	// batches with non-select statements are proxied unmodified
+	_, b, _ = testBatchInstance(t, "select test1; drop measurement test2")
+	se = &SeriesEnvelope{}
+	if err := json.Unmarshal(b, se); err != nil {
+		t.Fatal(err)
+	}
+	if len(se.Results) != 1 {
+		t.Errorf("expected %d got %d", 1, len(se.Results))
+	}
+}
+
+func TestBatchQueryHandlerProxiesUnmodified(t *testing.T) {
+
+	var path, accept string
+	us := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		accept = r.Header.Get(headers.NameAccept)
+		w.Header().Set(headers.NameContentType, "text/csv")
+		w.Write([]byte("name,tags,time,value\n"))
+	}))
+	defer us.Close()
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil,
+		"influxdb", "/query?"+url.Values{"q": {"select test1; drop measurement test2"}}.Encode(),
+		"debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(us.URL + "/influx")
+	r.Header.Set(headers.NameAccept, "text/csv")
+
+	client.QueryHandler(w, r)
+
+	if w.Result().StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", w.Result().StatusCode)
+	}
+	if path != "/influx/query" {
+		t.Errorf("expected %s got %s", "/influx/query", path)
+	}
+	if accept != "text/csv" {
+		t.Errorf("expected %s got %s", "text/csv", accept)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/util/fastjson"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// unmarshalSeries decodes a Series document into se without reflection. It returns an error for
+// any document it does not fully understand, in which case the caller should decode a new
+// SeriesEnvelope with encoding/json
+func unmarshalSeries(data []byte, se *SeriesEnvelope) error {
+	s := fastjson.NewScanner(data)
+	s.Object(func(key string) {
+		switch {
+		case strings.EqualFold(key, "results"):
+			se.Results = scanResults(s)
+		case strings.EqualFold(key, "error"):
+			se.Err = s.String()
+		case strings.EqualFold(key, "step"):
+			s.Unmarshal(&se.StepDuration)
+		case strings.EqualFold(key, "extents"):
+			s.Unmarshal(&se.ExtentList)
+		default:
+			s.Skip()
+		}
+	})
+	s.End()
+	return s.Err()
+}
+
+func scanResults(s *fastjson.Scanner) []Result {
+	if s.Null() {
+		return nil
+	}
+	results := []Result{}
+	s.Array(func() {
+		var r Result
+		s.Object(func(key string) {
+			switch {
+			case strings.EqualFold(key, "statement_id"):
+				s.Unmarshal(&r.StatementID)
+			case strings.EqualFold(key, "series"):
+				r.Series = scanRows(s)
+			case strings.EqualFold(key, "error"):
+				r.Err = s.String()
+			default:
+				s.Skip()
+			}
+		})
+		results = append(results, r)
+	})
+	return results
+}
+
+func scanRows(s *fastjson.Scanner) []models.Row {
+	if s.Null() {
+		return nil
+	}
+	rows := []models.Row{}
+	s.Array(func() {
+		var row models.Row
+		s.Object(func(key string) {
+			switch {
+			case strings.EqualFold(key, "name"):
+				row.Name = s.String()
+			case strings.EqualFold(key, "tags"):
+				s.Unmarshal(&row.Tags)
+			case strings.EqualFold(key, "columns"):
+				s.Unmarshal(&row.Columns)
+			case strings.EqualFold(key, "values"):
+				row.Values = scanValues(s)
+			case strings.EqualFold(key, "partial"):
+				row.Partial = s.Bool()
+			default:
+				s.Skip()
+			}
+		})
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+func scanValues(s *fastjson.Scanner) [][]interface{} {
+	if s.Null() {
+		return nil
+	}
+	values := [][]interface{}{}
+	s.Array(func() {
+		if s.Null() {
+			values = append(values, nil)
+			return
+		}
+		var v []interface{}
+		if len(values) > 0 {
+			// rows of a series share their column count
+			v = make([]interface{}, 0, len(values[len(values)-1]))
+		} else {
+			v = []interface{}{}
+		}
+		s.Array(func() {
+			v = append(v, s.Interface())
+		})
+		values = append(values, v)
+	})
+	return values
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+// testSeriesDocument returns a Series document with the provided number of series and points
+func testSeriesDocument(series, points int) []byte {
+	sb := &strings.Builder{}
+	sb.WriteString(`{"results":[{"statement_id":0,"series":[`)
+	for i := 0; i < series; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(sb, `{"name":"cpu","tags":{"host":"host-%d"},"columns":["time","usage_idle","usage_user"],"values":[`, i)
+		for j := 0; j < points; j++ {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(sb, `[%d,%d.5,%d]`, 1577836800000+j*15000, i*j, j)
+		}
+		sb.WriteString(`]}`)
+	}
+	sb.WriteString(`]}]}`)
+	return []byte(sb.String())
+}
+
+func TestUnmarshalSeries(t *testing.T) {
+
+	tests := []string{
+		string(testSeriesDocument(3, 10)),
+		`{"results":[]}`,
+		`{"results":null,"error":"e"}`,
+		`{"Results":[{"statement_id":1,"error":"e","series":[{"name":"aé","partial":true,` +
+			`"values":[[1,"x",true,null,{"k":[1]}],null,[]]}]},{"series":null}]}`,
+		`{"results":[{"series":[{"values":[[1,2]]}]}],` +
+			`"extents":[{"start":"2020-01-01T00:00:00Z","end":"2020-01-01T01:00:00Z"}],"step":15000000000}`,
+	}
+
+	for i, test := range tests {
+		se := &SeriesEnvelope{}
+		err := unmarshalSeries([]byte(test), se)
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+			continue
+		}
+		expected := &SeriesEnvelope{}
+		json.Unmarshal([]byte(test), expected)
+		if se.Err != expected.Err || se.StepDuration != expected.StepDuration ||
+			!reflect.DeepEqual(se.ExtentList, expected.ExtentList) ||
+			!reflect.DeepEqual(se.Results, expected.Results) {
+			t.Errorf("test %d: expected %v got %v", i, expected.Results, se.Results)
+		}
+	}
+
+}
+
+func TestUnmarshalSeriesFails(t *testing.T) {
+
+	tests := []string{
+		`null`,
+		`{"error":null}`,
+		`{"results":[{"statement_id":"1"}]}`,
+		`{"results":[{"series":[{"values":[[1,]]}]}]}`,
+		`{"results":[{"series":[{"partial":1}]}]}`,
+		`{} {}`,
+	}
+
+	for i, test := range tests {
+		if err := unmarshalSeries([]byte(test), &SeriesEnvelope{}); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+}
+
+func TestUnmarshalTimeseriesFastJSON(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.FastJSONDecode = true
+	oc.FastJSONMinBytes = 0
+	client := &Client{config: oc}
+
+	ts, err := client.UnmarshalTimeseries(testSeriesDocument(2, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ts.ValueCount(); v != 10 {
+		t.Errorf("expected %d got %d", 10, v)
+	}
+
+	// documents the fast decoder does not support fall back to encoding/json
+	_, err = client.UnmarshalTimeseries([]byte(`{"results":[{"statement_id":"1"}]}`))
+	if err == nil {
+		t.Error("expected error")
+	}
+
+}
+
+func benchmarkUnmarshalSeries(b *testing.B, series, points int, fast bool) {
+	data := testSeriesDocument(series, points)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		se := &SeriesEnvelope{}
+		var err error
+		if fast {
+			err = unmarshalSeries(data, se)
+		} else {
+			err = json.Unmarshal(data, se)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// the benchmarks span document sizes from ~1KB to ~20MB, to compare the decoders at
+// the sizes around fast_json_min_bytes, as well as for large dashboards
+func BenchmarkUnmarshalSeries(b *testing.B) {
+	sizes := []struct{ series, points int }{{1, 10}, {2, 60}, {10, 240}, {100, 1440}, {500, 1440}}
+	for _, size := range sizes {
+		for _, fast := range []bool{false, true} {
+			name := fmt.Sprintf("series=%d/points=%d/fast=%t", size.series, size.points, fast)
+			b.Run(name, func(b *testing.B) {
+				benchmarkUnmarshalSeries(b, size.series, size.points, fast)
+			})
+		}
+	}
+}
@@ -0,0 +1,595 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/sort/times"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+// Annotations that carry the step and extents of a cached FluxResponse. They are only
+// written while the FluxResponse has a step or extents, which are cleared before it is
+// returned to the client
+const (
+	annotationStep    = "#trickster-step"
+	annotationExtents = "#trickster-extents"
+)
+
+// Flux table columns with special handling
+const (
+	fluxColumnResult = "result"
+	fluxColumnTable  = "table"
+	fluxColumnTime   = "_time"
+	fluxColumnStart  = "_start"
+	fluxColumnStop   = "_stop"
+)
+
+// errInvalidAnnotatedCSV indicates that a Flux query response is not annotated CSV that
+// can be cached, such as an error response or one without a group annotation
+var errInvalidAnnotatedCSV = errors.New("invalid annotated csv")
+
+// FluxResponse represents the annotated CSV response to a Flux query. The rows of each
+// table are kept as they were returned by InfluxDB, and are merged across responses by
+// the table's group key
+type FluxResponse struct {
+	tables       []*fluxTable
+	stepDuration time.Duration
+	extentList   timeseries.ExtentList
+
+	timestamps map[time.Time]bool // tracks unique timestamps in the tables
+	tslist     times.Times
+	isSorted   bool // tracks if the tables are currently sorted
+	isCounted  bool // tracks if timestamps slice is up-to-date
+
+	updateLock sync.Mutex
+}
+
+// fluxSchema is the annotation rows and header row shared by a block of tables
+type fluxSchema struct {
+	annotations   [][]string
+	header        []string
+	key           string
+	group         []bool
+	defaultResult string
+	resultIndex   int
+	tableIndex    int
+	timeIndex     int
+	startIndex    int
+	stopIndex     int
+}
+
+// fluxTable is a table of a FluxResponse. Its key identifies the table across responses
+// by its result, schema and group key, excluding the _start and _stop columns, since those
+// are the bounds of the range() that returned it
+type fluxTable struct {
+	schema *fluxSchema
+	result string
+	key    string
+	rows   []fluxRow
+}
+
+// fluxRow is a row of a fluxTable, with its cells excluding the annotation column
+type fluxRow struct {
+	t     time.Time
+	cells []string
+}
+
+// isAnnotatedCSV returns true if the response body is annotated CSV, rather than JSON. A
+// Flux query with no results returns an empty response
+func isAnnotatedCSV(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) == 0 || data[0] == '#' || data[0] == ','
+}
+
+func newFluxSchema(annotations [][]string, header []string) (*fluxSchema, error) {
+	if len(header) == 0 || header[0] != "" {
+		return nil, errInvalidAnnotatedCSV
+	}
+	s := &fluxSchema{
+		annotations: annotations,
+		header:      header[1:],
+		resultIndex: str.IndexOfString(header[1:], fluxColumnResult),
+		tableIndex:  str.IndexOfString(header[1:], fluxColumnTable),
+		timeIndex:   str.IndexOfString(header[1:], fluxColumnTime),
+		startIndex:  str.IndexOfString(header[1:], fluxColumnStart),
+		stopIndex:   str.IndexOfString(header[1:], fluxColumnStop),
+	}
+	if s.resultIndex < 0 || s.tableIndex < 0 || s.timeIndex < 0 {
+		return nil, errInvalidAnnotatedCSV
+	}
+	var b strings.Builder
+	for _, a := range annotations {
+		if len(a) != len(header) {
+			return nil, errInvalidAnnotatedCSV
+		}
+		switch a[0] {
+		case "#group":
+			s.group = make([]bool, len(s.header))
+			for i, v := range a[1:] {
+				s.group[i] = v == "true"
+			}
+		case "#default":
+			s.defaultResult = a[1+s.resultIndex]
+		}
+		b.WriteString(strings.Join(a, ","))
+		b.WriteString("\n")
+	}
+	if s.group == nil {
+		return nil, errInvalidAnnotatedCSV
+	}
+	b.WriteString(strings.Join(header, ","))
+	s.key = b.String()
+	return s, nil
+}
+
+// tableKey returns the key of the table that the row belongs to
+func (s *fluxSchema) tableKey(result string, cells []string) string {
+	var b strings.Builder
+	b.WriteString(result)
+	b.WriteString("\n")
+	b.WriteString(s.key)
+	for i, v := range cells {
+		if s.group[i] && i != s.resultIndex && i != s.tableIndex &&
+			i != s.startIndex && i != s.stopIndex {
+			b.WriteString("\n")
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+// UnmarshalFluxResponse converts an annotated CSV Flux query response into a FluxResponse
+func UnmarshalFluxResponse(data []byte) (*FluxResponse, error) {
+	fr := &FluxResponse{}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+
+	var s *fluxSchema
+	var annotations [][]string
+	tables := make(map[string]*fluxTable)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(rec[0], "#") {
+			switch rec[0] {
+			case annotationStep:
+				if len(rec) != 2 {
+					return nil, errInvalidAnnotatedCSV
+				}
+				i, err := strconv.ParseInt(rec[1], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				fr.stepDuration = time.Duration(i)
+			case annotationExtents:
+				if len(rec)%2 != 1 {
+					return nil, errInvalidAnnotatedCSV
+				}
+				for i := 1; i < len(rec); i += 2 {
+					start, err := strconv.ParseInt(rec[i], 10, 64)
+					if err != nil {
+						return nil, err
+					}
+					end, err := strconv.ParseInt(rec[i+1], 10, 64)
+					if err != nil {
+						return nil, err
+					}
+					fr.extentList = append(fr.extentList,
+						timeseries.Extent{Start: time.Unix(0, start), End: time.Unix(0, end)})
+				}
+			default:
+				// annotations following the rows of a block begin the next block
+				s = nil
+				annotations = append(annotations, rec)
+			}
+			continue
+		}
+		if s == nil {
+			if s, err = newFluxSchema(annotations, rec); err != nil {
+				return nil, err
+			}
+			annotations = nil
+			continue
+		}
+		if len(rec) != len(s.header)+1 {
+			return nil, errInvalidAnnotatedCSV
+		}
+		cells := rec[1:]
+		t, err := time.Parse(time.RFC3339Nano, cells[s.timeIndex])
+		if err != nil {
+			return nil, err
+		}
+		result := cells[s.resultIndex]
+		if result == "" {
+			result = s.defaultResult
+		}
+		// table ids are unique within each result
+		id := result + "\n" + cells[s.tableIndex]
+		ft, ok := tables[id]
+		if !ok {
+			ft = &fluxTable{schema: s, result: result, key: s.tableKey(result, cells)}
+			tables[id] = ft
+			fr.tables = append(fr.tables, ft)
+		}
+		// the time is in the local time zone, like the times of the extents it is compared to
+		ft.rows = append(ft.rows, fluxRow{t: time.Unix(0, t.UnixNano()), cells: cells})
+	}
+	if annotations != nil {
+		return nil, errInvalidAnnotatedCSV
+	}
+	return fr, nil
+}
+
+// MarshalCSV converts the FluxResponse into annotated CSV. Consecutive tables that share a
+// schema are written in the same block, and the tables of each result are renumbered in
+// order. The _start and _stop columns are set to the first and last timestamps in the
+// response, so that they are the same regardless of which extents were cached
+func (fr *FluxResponse) MarshalCSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.UseCRLF = true
+
+	if fr.stepDuration > 0 {
+		w.Write([]string{annotationStep, strconv.FormatInt(int64(fr.stepDuration), 10)})
+	}
+	if len(fr.extentList) > 0 {
+		rec := make([]string, 1, 1+len(fr.extentList)*2)
+		rec[0] = annotationExtents
+		for _, e := range fr.extentList {
+			rec = append(rec, strconv.FormatInt(e.Start.UnixNano(), 10),
+				strconv.FormatInt(e.End.UnixNano(), 10))
+		}
+		w.Write(rec)
+	}
+
+	var start, stop time.Time
+	for _, ft := range fr.tables {
+		for _, row := range ft.rows {
+			if start.IsZero() || row.t.Before(start) {
+				start = row.t
+			}
+			if row.t.After(stop) {
+				stop = row.t
+			}
+		}
+	}
+	starts := start.UTC().Format(time.RFC3339Nano)
+	stops := stop.UTC().Format(time.RFC3339Nano)
+
+	var s *fluxSchema
+	ids := make(map[string]int)
+	for _, ft := range fr.tables {
+		if len(ft.rows) == 0 {
+			continue
+		}
+		if s == nil || ft.schema.key != s.key {
+			if s != nil {
+				w.Flush()
+				buf.WriteString("\r\n")
+			}
+			s = ft.schema
+			for _, a := range s.annotations {
+				w.Write(a)
+			}
+			w.Write(append([]string{""}, s.header...))
+		}
+		id := strconv.Itoa(ids[ft.result])
+		ids[ft.result]++
+		for _, row := range ft.rows {
+			rec := make([]string, 1+len(row.cells))
+			copy(rec[1:], row.cells)
+			rec[1+s.tableIndex] = id
+			if s.startIndex >= 0 {
+				rec[1+s.startIndex] = starts
+			}
+			if s.stopIndex >= 0 {
+				rec[1+s.stopIndex] = stops
+			}
+			if err := w.Write(rec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	buf.WriteString("\r\n")
+	return buf.Bytes(), w.Error()
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (fr *FluxResponse) SetExtents(extents timeseries.ExtentList) {
+	el := make(timeseries.ExtentList, len(extents))
+	copy(el, extents)
+	fr.extentList = el
+	fr.isCounted = false
+}
+
+// Extents returns the Timeseries's ExentList
+func (fr *FluxResponse) Extents() timeseries.ExtentList {
+	return fr.extentList
+}
+
+// Step returns the step for the Timeseries
+func (fr *FluxResponse) Step() time.Duration {
+	return fr.stepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (fr *FluxResponse) SetStep(step time.Duration) {
+	fr.stepDuration = step
+}
+
+// ValueCount returns the count of all rows across all tables in the Timeseries
+func (fr *FluxResponse) ValueCount() int {
+	c := 0
+	for _, ft := range fr.tables {
+		c += len(ft.rows)
+	}
+	return c
+}
+
+// SeriesCount returns the count of all tables in the Timeseries
+func (fr *FluxResponse) SeriesCount() int {
+	return len(fr.tables)
+}
+
+// SeriesLabels returns the group key of each table in the Timeseries, excluding the
+// result, table, _start and _stop columns
+func (fr *FluxResponse) SeriesLabels() []map[string]string {
+	labels := make([]map[string]string, 0, len(fr.tables))
+	for _, ft := range fr.tables {
+		s := ft.schema
+		l := make(map[string]string)
+		if len(ft.rows) > 0 {
+			for i, v := range ft.rows[0].cells {
+				if s.group[i] && i != s.resultIndex && i != s.tableIndex &&
+					i != s.startIndex && i != s.stopIndex {
+					l[s.header[i]] = v
+				}
+			}
+		}
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+// TimestampCount returns the count of unique timestamps across all tables in the Timeseries
+func (fr *FluxResponse) TimestampCount() int {
+	fr.updateTimestamps()
+	return len(fr.timestamps)
+}
+
+func (fr *FluxResponse) updateTimestamps() {
+	if fr.isCounted && fr.timestamps != nil {
+		return
+	}
+	m := make(map[time.Time]bool)
+	for _, ft := range fr.tables {
+		for _, row := range ft.rows {
+			m[row.t] = true
+		}
+	}
+	fr.timestamps = m
+	fr.tslist = times.FromMap(m)
+	fr.isCounted = true
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries
+// (in the order provided) and optionally sorts the merged Timeseries
+func (fr *FluxResponse) Merge(sort bool, collection ...timeseries.Timeseries) {
+	fr.updateLock.Lock()
+	defer fr.updateLock.Unlock()
+
+	tables := make(map[string]*fluxTable, len(fr.tables))
+	for _, ft := range fr.tables {
+		tables[ft.key] = ft
+	}
+
+	for _, ts := range collection {
+		fr2, ok := ts.(*FluxResponse)
+		if !ok || fr2 == nil {
+			continue
+		}
+		for _, ft2 := range fr2.tables {
+			if ft, ok := tables[ft2.key]; ok {
+				ft.rows = append(ft.rows, ft2.rows...)
+				continue
+			}
+			ft := &fluxTable{schema: ft2.schema, result: ft2.result, key: ft2.key,
+				rows: append([]fluxRow(nil), ft2.rows...)}
+			tables[ft.key] = ft
+			fr.tables = append(fr.tables, ft)
+		}
+		fr.extentList = append(fr.extentList, fr2.extentList...)
+	}
+
+	fr.extentList = fr.extentList.Compress(fr.stepDuration)
+	fr.isSorted = false
+	fr.isCounted = false
+	if sort {
+		fr.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (fr *FluxResponse) Clone() timeseries.Timeseries {
+	fr.updateLock.Lock()
+	defer fr.updateLock.Unlock()
+	clone := &FluxResponse{
+		tables:       make([]*fluxTable, len(fr.tables)),
+		stepDuration: fr.stepDuration,
+		extentList:   fr.extentList.Clone(),
+		isSorted:     fr.isSorted,
+	}
+	// the schemas and the cells of each row are not modified once parsed, so they are shared
+	for i, ft := range fr.tables {
+		clone.tables[i] = &fluxTable{schema: ft.schema, result: ft.result, key: ft.key,
+			rows: append([]fluxRow(nil), ft.rows...)}
+	}
+	return clone
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided
+// Extent (inclusive)
+func (fr *FluxResponse) CropToRange(e timeseries.Extent) {
+	fr.isCounted = false
+	if len(fr.extentList) == 0 || fr.extentList.OutsideOf(e) {
+		fr.tables = nil
+		fr.extentList = timeseries.ExtentList{}
+		return
+	}
+	tables := fr.tables[:0]
+	for _, ft := range fr.tables {
+		rows := ft.rows[:0]
+		for _, row := range ft.rows {
+			if e.Includes(row.t) {
+				rows = append(rows, row)
+			}
+		}
+		ft.rows = rows
+		if len(rows) > 0 {
+			tables = append(tables, ft)
+		}
+	}
+	fr.tables = tables
+	fr.extentList = fr.extentList.Crop(e)
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. The time parameter limits the upper extent to the provided time,
+// in order to support backfill tolerance
+func (fr *FluxResponse) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+	fr.isCounted = false
+	fr.isSorted = false
+	x := len(fr.extentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		fr.tables = nil
+		fr.extentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if fr.extentList[x-1].End.After(t) {
+		fr.CropToRange(timeseries.Extent{Start: fr.extentList[0].Start, End: t})
+	}
+
+	tc := fr.TimestampCount()
+	if len(fr.tables) == 0 || tc <= sz {
+		return
+	}
+
+	el := timeseries.ExtentListLRU(fr.extentList).UpdateLastUsed(lur, fr.stepDuration)
+	sort.Sort(el)
+
+	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(fr.stepDuration) {
+			if _, ok := fr.timestamps[ts]; ok {
+				removals[ts] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	for _, ft := range fr.tables {
+		rows := ft.rows[:0]
+		for _, row := range ft.rows {
+			if _, ok := removals[row.t]; !ok {
+				rows = append(rows, row)
+			}
+		}
+		ft.rows = rows
+	}
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(fr.stepDuration)
+			}
+		}
+	}
+
+	fr.extentList = timeseries.ExtentList(el).Compress(fr.stepDuration)
+	fr.isCounted = false
+	fr.Sort()
+}
+
+// Sort sorts the rows of each table chronologically by their timestamp. When tables have
+// more than one row with the same timestamp, the first row is kept
+func (fr *FluxResponse) Sort() {
+	if fr.isSorted {
+		return
+	}
+	for _, ft := range fr.tables {
+		sort.SliceStable(ft.rows, func(i, j int) bool { return ft.rows[i].t.Before(ft.rows[j].t) })
+		rows := ft.rows[:0]
+		for _, row := range ft.rows {
+			if len(rows) == 0 || !row.t.Equal(rows[len(rows)-1].t) {
+				rows = append(rows, row)
+			}
+		}
+		ft.rows = rows
+	}
+	sort.Sort(fr.extentList)
+	fr.isCounted = false
+	fr.updateTimestamps()
+	fr.isSorted = true
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (fr *FluxResponse) Size() int {
+	c := 24 + // .stepDuration
+		fr.extentList.Size() +
+		(25 * len(fr.timestamps)) + // time.Time (24) + bool(1)
+		(24 * len(fr.tslist)) + // time.Time (24)
+		2 // .isSorted + .isCounted
+	for _, ft := range fr.tables {
+		c += len(ft.result) + len(ft.key) + 8 // .schema
+		for _, row := range ft.rows {
+			c += 24 // .t
+			for _, v := range row.cells {
+				c += len(v)
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const testFluxAnnotations = "#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339," +
+	"dateTime:RFC3339,double,string,string\r\n" +
+	"#group,false,false,true,true,false,false,true,true\r\n" +
+	"#default,_result,,,,,,,\r\n" +
+	",result,table,_start,_stop,_time,_value,_field,host\r\n"
+
+// testFluxCSV returns an annotated CSV response with a table for each host, having a row
+// for each minute in the inclusive range of the provided epoch minutes
+func testFluxCSV(start, end int, hosts ...string) string {
+	var b strings.Builder
+	b.WriteString(testFluxAnnotations)
+	rs := time.Unix(int64(start)*60, 0).UTC().Format(time.RFC3339Nano)
+	re := time.Unix(int64(end)*60, 0).UTC().Format(time.RFC3339Nano)
+	for i, h := range hosts {
+		for m := start; m <= end; m++ {
+			t := time.Unix(int64(m)*60, 0).UTC().Format(time.RFC3339Nano)
+			fmt.Fprintf(&b, ",,%d,%s,%s,%s,%d,usage,%s\r\n", i, rs, re, t, m, h)
+		}
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func minute(m int) time.Time {
+	return time.Unix(int64(m)*60, 0)
+}
+
+func TestIsAnnotatedCSV(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected bool
+	}{
+		{testFluxCSV(1, 2, "a"), true},
+		{",result,table,_time\r\n", true},
+		{"\r\n", true},
+		{`{"results":[]}`, false},
+	}
+	for i, test := range tests {
+		if v := isAnnotatedCSV([]byte(test.data)); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+}
+
+func TestUnmarshalFluxResponse(t *testing.T) {
+
+	fr, err := UnmarshalFluxResponse([]byte(testFluxCSV(1, 3, "a", "b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, fr.SeriesCount())
+	}
+	if fr.ValueCount() != 6 {
+		t.Errorf("expected %d got %d", 6, fr.ValueCount())
+	}
+	if fr.TimestampCount() != 3 {
+		t.Errorf("expected %d got %d", 3, fr.TimestampCount())
+	}
+	labels := fr.SeriesLabels()
+	if len(labels) != 2 || labels[1]["host"] != "b" || labels[1]["_field"] != "usage" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if _, ok := labels[0]["_start"]; ok {
+		t.Errorf("unexpected label %s", "_start")
+	}
+
+	// the response is returned as it was received
+	b, err := fr.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testFluxCSV(1, 3, "a", "b") {
+		t.Errorf("expected %s got %s", testFluxCSV(1, 3, "a", "b"), string(b))
+	}
+
+	// an empty response has no tables
+	fr, err = UnmarshalFluxResponse([]byte("\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.SeriesCount() != 0 {
+		t.Errorf("expected %d got %d", 0, fr.SeriesCount())
+	}
+
+	tests := []string{
+		// an error response
+		"#datatype,string,string\r\n#group,true,true\r\n#default,,\r\n,error,reference\r\n" +
+			",failed to execute query,\r\n",
+		// no group annotation
+		",result,table,_time,_value\r\n,,0,1970-01-01T00:01:00Z,1\r\n",
+		// a row that does not match the header
+		testFluxAnnotations + ",,0,1970-01-01T00:01:00Z,1\r\n",
+		// a row without a time
+		testFluxAnnotations + ",,0,,,,1,usage,a\r\n",
+	}
+	for i, test := range tests {
+		if _, err = UnmarshalFluxResponse([]byte(test)); err == nil {
+			t.Errorf("test %d: expected error for invalid response", i)
+		}
+	}
+}
+
+func TestFluxResponseCacheAnnotations(t *testing.T) {
+	fr, err := UnmarshalFluxResponse([]byte(testFluxCSV(1, 3, "a")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.SetStep(time.Minute)
+	fr.SetExtents(timeseries.ExtentList{{Start: minute(1), End: minute(3)}})
+
+	b, err := fr.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), annotationStep+",60000000000\r\n"+annotationExtents+
+		",60000000000,180000000000\r\n#datatype") {
+		t.Errorf("unexpected cache annotations in %s", string(b))
+	}
+
+	fr2, err := UnmarshalFluxResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, fr2.Step())
+	}
+	if len(fr2.Extents()) != 1 || !fr2.Extents()[0].Start.Equal(minute(1)) ||
+		!fr2.Extents()[0].End.Equal(minute(3)) {
+		t.Errorf("unexpected extents %s", fr2.Extents())
+	}
+	if fr2.ValueCount() != 3 {
+		t.Errorf("expected %d got %d", 3, fr2.ValueCount())
+	}
+
+	// the cache annotations are not included in the response to the client
+	fr2.SetStep(0)
+	fr2.SetExtents(nil)
+	if b, _ = fr2.MarshalCSV(); string(b) != testFluxCSV(1, 3, "a") {
+		t.Errorf("expected %s got %s", testFluxCSV(1, 3, "a"), string(b))
+	}
+}
+
+func TestFluxResponseMerge(t *testing.T) {
+	fr1, _ := UnmarshalFluxResponse([]byte(testFluxCSV(1, 3, "a")))
+	fr1.SetStep(time.Minute)
+	fr1.SetExtents(timeseries.ExtentList{{Start: minute(1), End: minute(3)}})
+
+	// the tables of each host are merged, despite their different _start and _stop values
+	fr2, _ := UnmarshalFluxResponse([]byte(testFluxCSV(3, 5, "a", "b")))
+	fr2.SetExtents(timeseries.ExtentList{{Start: minute(3), End: minute(5)}})
+
+	fr1.Merge(true, fr2)
+
+	if fr1.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, fr1.SeriesCount())
+	}
+	// minute 3 of host a is only kept once
+	if fr1.ValueCount() != 8 {
+		t.Errorf("expected %d got %d", 8, fr1.ValueCount())
+	}
+	if len(fr1.Extents()) != 1 || !fr1.Extents()[0].End.Equal(minute(5)) {
+		t.Errorf("unexpected extents %s", fr1.Extents())
+	}
+
+	fr1.SetStep(0)
+	fr1.SetExtents(nil)
+	b, _ := fr1.MarshalCSV()
+	expected := testFluxAnnotations
+	for i, h := range []string{"a", "b"} {
+		start := 1
+		if h == "b" {
+			start = 3
+		}
+		for m := start; m <= 5; m++ {
+			expected += fmt.Sprintf(",,%d,1970-01-01T00:01:00Z,1970-01-01T00:05:00Z,%s,%d,usage,%s\r\n",
+				i, minute(m).UTC().Format(time.RFC3339), m, h)
+		}
+	}
+	expected += "\r\n"
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+}
+
+func TestFluxResponseSchemaBlocks(t *testing.T) {
+	other := strings.Replace(strings.Replace(testFluxCSV(1, 2, "c"), "double", "long", 1),
+		",,0,", ",,1,", -1)
+	fr, err := UnmarshalFluxResponse([]byte(testFluxCSV(1, 2, "a") + other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, fr.SeriesCount())
+	}
+	// the tables with different schemas are written in separate blocks
+	b, _ := fr.MarshalCSV()
+	expected := testFluxCSV(1, 2, "a") + other
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+}
+
+func TestFluxResponseCropToRange(t *testing.T) {
+	fr, _ := UnmarshalFluxResponse([]byte(testFluxCSV(1, 5, "a", "b")))
+	fr.SetExtents(timeseries.ExtentList{{Start: minute(1), End: minute(5)}})
+
+	fr.CropToRange(timeseries.Extent{Start: minute(2), End: minute(3)})
+	if fr.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, fr.ValueCount())
+	}
+	if !fr.Extents()[0].Start.Equal(minute(2)) || !fr.Extents()[0].End.Equal(minute(3)) {
+		t.Errorf("unexpected extents %s", fr.Extents())
+	}
+
+	fr.CropToRange(timeseries.Extent{Start: minute(10), End: minute(20)})
+	if fr.SeriesCount() != 0 || len(fr.Extents()) != 0 {
+		t.Errorf("expected empty timeseries got %d tables", fr.SeriesCount())
+	}
+}
+
+func TestFluxResponseCropToSize(t *testing.T) {
+	fr, _ := UnmarshalFluxResponse([]byte(testFluxCSV(1, 5, "a")))
+	fr.SetStep(time.Minute)
+	fr.SetExtents(timeseries.ExtentList{{Start: minute(1), End: minute(5)}})
+
+	fr.CropToSize(3, minute(10), timeseries.Extent{Start: minute(4), End: minute(5)})
+	if fr.ValueCount() != 3 {
+		t.Errorf("expected %d got %d", 3, fr.ValueCount())
+	}
+	if !fr.Extents()[0].Start.Equal(minute(3)) {
+		t.Errorf("unexpected extents %s", fr.Extents())
+	}
+
+	// the backfill tolerance crops the newest points
+	fr.CropToSize(10, minute(4), timeseries.Extent{})
+	if fr.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, fr.ValueCount())
+	}
+}
+
+func TestFluxResponseClone(t *testing.T) {
+	fr, _ := UnmarshalFluxResponse([]byte(testFluxCSV(1, 5, "a")))
+	fr.SetStep(time.Minute)
+	fr.SetExtents(timeseries.ExtentList{{Start: minute(1), End: minute(5)}})
+
+	clone := fr.Clone()
+	clone.CropToRange(timeseries.Extent{Start: minute(1), End: minute(2)})
+	if fr.ValueCount() != 5 {
+		t.Errorf("expected %d got %d", 5, fr.ValueCount())
+	}
+	if clone.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, clone.ValueCount())
+	}
+	if clone.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, clone.Step())
+	}
+	if fr.Size() <= 0 {
+		t.Errorf("expected positive size got %d", fr.Size())
+	}
+}
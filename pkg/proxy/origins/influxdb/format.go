@@ -0,0 +1,292 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// responseFormat enumerates the response body formats the InfluxDB HTTP API can return
+type responseFormat int
+
+const (
+	formatJSON = responseFormat(iota)
+	formatCSV
+	formatMsgpack
+)
+
+// Content Types of the supported InfluxDB response formats
+const (
+	mimeCSV     = "application/csv"
+	mimeTextCSV = "text/csv"
+	mimeMsgpack = "application/x-msgpack"
+)
+
+// getResponseFormat returns the response format requested by the provided Accept header,
+// using the same precedence as InfluxDB, where the first supported media type wins
+func getResponseFormat(h http.Header) responseFormat {
+	for _, v := range strings.Split(h.Get(headers.NameAccept), ",") {
+		if i := strings.Index(v, ";"); i >= 0 {
+			v = v[:i]
+		}
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case mimeCSV, mimeTextCSV:
+			return formatCSV
+		case mimeMsgpack:
+			return formatMsgpack
+		case headers.ValueApplicationJSON:
+			return formatJSON
+		}
+	}
+	return formatJSON
+}
+
+// ContentType returns the Content-Type header value for the response format
+func (f responseFormat) ContentType() string {
+	switch f {
+	case formatCSV:
+		return mimeCSV
+	case formatMsgpack:
+		return mimeMsgpack
+	}
+	return headers.ValueApplicationJSON
+}
+
+// MarshalCSV converts the SeriesEnvelope into the CSV format returned by InfluxDB, where
+// each row is prefixed by its series name and tags, and a new header is written whenever
+// the statement or columns change
+func (se *SeriesEnvelope) MarshalCSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	if se.Err != "" {
+		w.Write([]string{"error"})
+		w.Write([]string{se.Err})
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+
+	var row, columns []string
+	statementID := -1
+	for _, r := range se.Results {
+		if len(r.Series) == 0 {
+			continue
+		}
+		for _, s := range r.Series {
+			if r.StatementID != statementID || !stringsEqual(columns, s.Columns) {
+				if statementID >= 0 {
+					w.Flush()
+					buf.WriteString("\n")
+				}
+				statementID = r.StatementID
+				columns = s.Columns
+				row = make([]string, 2+len(s.Columns))
+				row[0] = "name"
+				row[1] = "tags"
+				copy(row[2:], s.Columns)
+				if err := w.Write(row); err != nil {
+					return nil, err
+				}
+			}
+			row[0] = s.Name
+			row[1] = ""
+			if len(s.Tags) > 0 {
+				// the hash key is the comma-separated list of tag pairs, with a leading comma
+				if hk := models.NewTags(s.Tags).HashKey(); len(hk) > 0 {
+					row[1] = string(hk[1:])
+				}
+			}
+			for _, values := range s.Values {
+				for j := range row[2:] {
+					row[j+2] = ""
+					if j < len(values) {
+						row[j+2] = formatCSVValue(values[j])
+					}
+				}
+				if err := w.Write(row); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// marshalFormat serializes the SeriesEnvelope in the provided response format
+func (se *SeriesEnvelope) marshalFormat(f responseFormat) ([]byte, error) {
+	switch f {
+	case formatCSV:
+		return se.MarshalCSV()
+	case formatMsgpack:
+		return se.MarshalMsgpack()
+	}
+	return json.Marshal(se)
+}
+
+func formatCSVValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case json.Number:
+		return t.String()
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return ""
+}
+
+// MarshalMsgpack converts the SeriesEnvelope into the MessagePack format returned by InfluxDB
+func (se *SeriesEnvelope) MarshalMsgpack() ([]byte, error) {
+	b := msgp.AppendMapHeader(nil, 1)
+	if se.Err != "" {
+		b = msgp.AppendString(b, "error")
+		return msgp.AppendString(b, se.Err), nil
+	}
+
+	var err error
+	b = msgp.AppendString(b, "results")
+	b = msgp.AppendArrayHeader(b, uint32(len(se.Results)))
+	for _, r := range se.Results {
+		if r.Err != "" {
+			b = msgp.AppendMapHeader(b, 1)
+			b = msgp.AppendString(b, "error")
+			b = msgp.AppendString(b, r.Err)
+			continue
+		}
+		b = msgp.AppendMapHeader(b, 2)
+		b = msgp.AppendString(b, "statement_id")
+		b = msgp.AppendInt(b, r.StatementID)
+		b = msgp.AppendString(b, "series")
+		b = msgp.AppendArrayHeader(b, uint32(len(r.Series)))
+		for _, s := range r.Series {
+			sz := uint32(2)
+			if s.Name != "" {
+				sz++
+			}
+			if len(s.Tags) > 0 {
+				sz++
+			}
+			b = msgp.AppendMapHeader(b, sz)
+			if s.Name != "" {
+				b = msgp.AppendString(b, "name")
+				b = msgp.AppendString(b, s.Name)
+			}
+			if len(s.Tags) > 0 {
+				b = msgp.AppendString(b, "tags")
+				b = msgp.AppendMapStrStr(b, s.Tags)
+			}
+			b = msgp.AppendString(b, "columns")
+			b = msgp.AppendArrayHeader(b, uint32(len(s.Columns)))
+			for _, c := range s.Columns {
+				b = msgp.AppendString(b, c)
+			}
+			// JSON decodes all numbers as float64, so the epoch timestamps are restored as
+			// integers. Field values keep their JSON type, since a whole-number value does
+			// not indicate an integer field
+			ti := str.IndexOfString(s.Columns, "time")
+			b = msgp.AppendString(b, "values")
+			b = msgp.AppendArrayHeader(b, uint32(len(s.Values)))
+			for _, values := range s.Values {
+				b = msgp.AppendArrayHeader(b, uint32(len(values)))
+				for j, v := range values {
+					if f, ok := v.(float64); ok && j == ti {
+						v = int64(f)
+					}
+					if b, err = msgp.AppendIntf(b, v); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return b, nil
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatWriter buffers a JSON response from the DeltaProxyCache engine, so it can be
+// re-serialized into the client's requested format once the response is complete
+type formatWriter struct {
+	http.ResponseWriter
+	format     responseFormat
+	statusCode int
+	body       *bytes.Buffer
+}
+
+func newFormatWriter(w http.ResponseWriter, f responseFormat) *formatWriter {
+	return &formatWriter{ResponseWriter: w, format: f, statusCode: http.StatusOK,
+		body: &bytes.Buffer{}}
+}
+
+// WriteHeader records the status code until the response is flushed
+func (fw *formatWriter) WriteHeader(code int) {
+	fw.statusCode = code
+}
+
+// Write buffers the response body until the response is flushed
+func (fw *formatWriter) Write(b []byte) (int, error) {
+	return fw.body.Write(b)
+}
+
+// flush writes the buffered response to the underlying ResponseWriter, converted to the
+// requested format when the response is a successful JSON document. Anything else,
+// such as an upstream error page, is passed through unmodified
+func (fw *formatWriter) flush() {
+	b := fw.body.Bytes()
+	h := fw.ResponseWriter.Header()
+	if fw.statusCode == http.StatusOK &&
+		strings.HasPrefix(h.Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		se := &SeriesEnvelope{}
+		if err := json.Unmarshal(b, se); err == nil {
+			if data, err := se.marshalFormat(fw.format); err == nil {
+				b = data
+				h.Set(headers.NameContentType, fw.format.ContentType())
+				h.Del(headers.NameContentLength)
+			}
+		}
+	}
+	fw.ResponseWriter.WriteHeader(fw.statusCode)
+	fw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const testFormatBody = `{"results":[{"statement_id":0,"series":[` +
+	`{"name":"cpu","tags":{"host":"a","region":"us"},"columns":["time","value"],"values":[[1000,1.5],[2000,null]]},` +
+	`{"name":"cpu","tags":{"host":"b","region":"us"},"columns":["time","value"],"values":[[1000,2]]}]},` +
+	`{"statement_id":1,"series":[{"name":"mem","columns":["time","used","free"],"values":[[1000,"x",true]]}]}]}`
+
+const testFormatCSV = "name,tags,time,value\n" +
+	"cpu,\"host=a,region=us\",1000,1.5\n" +
+	"cpu,\"host=a,region=us\",2000,\n" +
+	"cpu,\"host=b,region=us\",1000,2\n" +
+	"\n" +
+	"name,tags,time,used,free\n" +
+	"mem,,1000,x,true\n"
+
+func TestGetResponseFormat(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected responseFormat
+	}{
+		{"", formatJSON},
+		{"application/json", formatJSON},
+		{"application/csv", formatCSV},
+		{"text/csv; charset=utf-8", formatCSV},
+		{"application/x-msgpack", formatMsgpack},
+		{"text/html, application/x-msgpack, application/json", formatMsgpack},
+		{"application/json, application/csv", formatJSON},
+	}
+	for _, test := range tests {
+		h := http.Header{headers.NameAccept: []string{test.accept}}
+		if f := getResponseFormat(h); f != test.expected {
+			t.Errorf("expected %d got %d for %s", test.expected, f, test.accept)
+		}
+	}
+}
+
+func TestMarshalCSV(t *testing.T) {
+	se := &SeriesEnvelope{}
+	if err := json.Unmarshal([]byte(testFormatBody), se); err != nil {
+		t.Fatal(err)
+	}
+	b, err := se.MarshalCSV()
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != testFormatCSV {
+		t.Errorf("expected %s got %s", testFormatCSV, string(b))
+	}
+
+	se = &SeriesEnvelope{Err: "test error"}
+	b, _ = se.MarshalCSV()
+	if string(b) != "error\ntest error\n" {
+		t.Errorf("expected %s got %s", "error\ntest error\n", string(b))
+	}
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	se := &SeriesEnvelope{}
+	if err := json.Unmarshal([]byte(testFormatBody), se); err != nil {
+		t.Fatal(err)
+	}
+	b, err := se.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, _, err := msgp.ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := i.(map[string]interface{})["results"].([]interface{})
+	if len(results) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(results))
+	}
+	series := results[0].(map[string]interface{})["series"].([]interface{})
+	s := series[0].(map[string]interface{})
+	if s["name"] != "cpu" {
+		t.Errorf("expected %s got %s", "cpu", s["name"])
+	}
+	if tags := s["tags"].(map[string]interface{}); tags["host"] != "a" {
+		t.Errorf("expected %s got %s", "a", tags["host"])
+	}
+	values := s["values"].([]interface{})
+	row := values[0].([]interface{})
+	if row[0] != int64(1000) {
+		t.Errorf("expected %d got %v", 1000, row[0])
+	}
+	if row[1] != 1.5 {
+		t.Errorf("expected %f got %v", 1.5, row[1])
+	}
+	if row = values[1].([]interface{}); row[1] != nil {
+		t.Errorf("expected nil got %v", row[1])
+	}
+	// whole-number field values remain floats
+	s = series[1].(map[string]interface{})
+	if row = s["values"].([]interface{})[0].([]interface{}); row[1] != float64(2) {
+		t.Errorf("expected %v got %v (%T)", float64(2), row[1], row[1])
+	}
+
+	se = &SeriesEnvelope{Err: "test error"}
+	b, _ = se.MarshalMsgpack()
+	i, _, _ = msgp.ReadIntfBytes(b)
+	if e := i.(map[string]interface{})["error"]; e != "test error" {
+		t.Errorf("expected %s got %s", "test error", e)
+	}
+}
+
+func TestQueryHandlerFormats(t *testing.T) {
+
+	tests := []struct {
+		accept, contentType string
+	}{
+		{"application/csv", mimeCSV},
+		{"application/x-msgpack", mimeMsgpack},
+		{"application/json", headers.ValueApplicationJSON},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			client := &Client{name: "test"}
+			ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, testFormatBody,
+				map[string]string{headers.NameContentType: headers.ValueApplicationJSON},
+				"influxdb", "/query?q=select%20test", "debug")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ts.Close()
+			rsc := request.GetResources(r)
+			rsc.OriginClient = client
+			client.config = rsc.OriginConfig
+			client.webClient = hc
+			client.config.HTTPClient = hc
+			client.baseUpstreamURL, _ = url.Parse(ts.URL)
+			r.Header.Set(headers.NameAccept, test.accept)
+
+			client.QueryHandler(w, r)
+
+			resp := w.Result()
+			if resp.StatusCode != 200 {
+				t.Errorf("expected 200 got %d.", resp.StatusCode)
+			}
+			if ct := resp.Header.Get(headers.NameContentType); ct != test.contentType {
+				t.Errorf("expected %s got %s", test.contentType, ct)
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			if test.contentType == mimeCSV && string(b) != testFormatCSV {
+				t.Errorf("expected %s got %s", testFormatCSV, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+// fluxQuery is the body of a request to the Flux query API
+type fluxQuery struct {
+	Query   string          `json:"query"`
+	Type    string          `json:"type,omitempty"`
+	Dialect json.RawMessage `json:"dialect,omitempty"`
+}
+
+// fluxDialect describes the CSV format of a Flux query's response
+type fluxDialect struct {
+	Header         *bool    `json:"header"`
+	Delimiter      string   `json:"delimiter"`
+	Annotations    []string `json:"annotations"`
+	CommentPrefix  string   `json:"commentPrefix"`
+	DateTimeFormat string   `json:"dateTimeFormat"`
+}
+
+var reFluxRange, reFluxWindow, reFluxTime, reFluxDuration *regexp.Regexp
+
+func init() {
+	reFluxRange = regexp.MustCompile(`(^|[^\w.])range\s*\(`)
+	reFluxWindow = regexp.MustCompile(`(^|[^\w.])aggregateWindow\s*\(`)
+	reFluxTime = regexp.MustCompile(`^time\(\s*v:\s*"(?P<value>[^"]+)"\s*\)$`)
+	reFluxDuration = regexp.MustCompile(`([0-9]+)(ns|us|µs|ms|mo|s|m|h|d|w|y)`)
+}
+
+// FluxHandler handles Flux queries to the InfluxDB 2.x query API, and processes those that
+// request an annotated CSV response through the delta proxy cache
+func (c *Client) FluxHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	engines.DeltaProxyCacheRequest(w, r)
+}
+
+// isFluxRequest returns true if the request is to the Flux query API
+func isFluxRequest(r *http.Request) bool {
+	return r != nil && r.URL != nil && strings.HasSuffix(r.URL.Path, "/"+mnFluxQuery)
+}
+
+// parseFluxTimeRangeQuery parses a TimeRangeQuery from a Flux query request. Only queries
+// that window their data with a single aggregateWindow() over a single range(), and that
+// request an annotated CSV response including the group annotation, can be cached
+func parseFluxTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	if !strings.HasPrefix(r.Header.Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	// any other body fields, such as params or now, change the results of the query
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	for k := range fields {
+		if k != "query" && k != "type" && k != "dialect" {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+	fq := &fluxQuery{}
+	if err = json.Unmarshal(b, fq); err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	if fq.Query == "" {
+		return nil, errors.MissingRequestParam("query")
+	}
+	if (fq.Type != "" && fq.Type != "flux") || !isAnnotatedCSVDialect(fq.Dialect) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq := &timeseries.TimeRangeQuery{AlignToEpoch: true}
+	trq.Statement, trq.Extent, trq.Step, trq.TimestampFieldName, err =
+		parseFluxQuery(fq.Query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// the template URL carries the tokenized query and its dialect, for the cache key
+	trq.TemplateURL = urls.Clone(r.URL)
+	qt := trq.TemplateURL.Query()
+	qt.Set(upFluxQuery, trq.Statement)
+	qt.Set(upFluxType, fq.Type)
+	d := &bytes.Buffer{}
+	json.Compact(d, fq.Dialect)
+	qt.Set(upFluxDialect, d.String())
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, nil
+}
+
+// isAnnotatedCSVDialect returns true if the dialect requests a comma-delimited CSV response
+// with a header row and the group annotation, which identifies each table across responses
+func isAnnotatedCSVDialect(b json.RawMessage) bool {
+	if len(b) == 0 {
+		return false
+	}
+	d := &fluxDialect{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return false
+	}
+	return (d.Header == nil || *d.Header) &&
+		(d.Delimiter == "" || d.Delimiter == ",") &&
+		(d.CommentPrefix == "" || d.CommentPrefix == "#") &&
+		(d.DateTimeFormat == "" || d.DateTimeFormat == "RFC3339" ||
+			d.DateTimeFormat == "RFC3339Nano") &&
+		str.IndexOfString(d.Annotations, "group") >= 0
+}
+
+// parseFluxQuery returns the Flux query with the arguments of its range() tokenized, along
+// with its time range, the every interval of its aggregateWindow() and the window bound
+// that each point is stamped with
+func parseFluxQuery(q string, now time.Time) (string, timeseries.Extent, time.Duration,
+	string, error) {
+
+	var e timeseries.Extent
+	open, end, err := findFluxCall(q, reFluxRange)
+	if err != nil {
+		return "", e, 0, "", err
+	}
+	args := parseFluxArgs(q[open+1 : end])
+	v, ok := args["start"]
+	if !ok {
+		return "", e, 0, "", errors.ErrNotTimeRangeQuery
+	}
+	if e.Start, err = parseFluxTime(v, now); err != nil {
+		return "", e, 0, "", err
+	}
+	e.End = now
+	if v, ok = args["stop"]; ok {
+		if e.End, err = parseFluxTime(v, now); err != nil {
+			return "", e, 0, "", err
+		}
+	}
+	statement := q[:open+1] + tkRange + q[end:]
+
+	wo, we, err := findFluxCall(q, reFluxWindow)
+	if err != nil {
+		return "", e, 0, "", errors.ErrStepParse
+	}
+	args = parseFluxArgs(q[wo+1 : we])
+	// windows that are offset from the epoch, or that overlap, can't be merged by timestamp
+	if _, ok = args["offset"]; ok {
+		return "", e, 0, "", errors.ErrStepParse
+	}
+	step, err := parseFluxDuration(args["every"])
+	if err != nil || step <= 0 {
+		return "", e, 0, "", errors.ErrStepParse
+	}
+	if v, ok = args["period"]; ok && v != args["every"] {
+		return "", e, 0, "", errors.ErrStepParse
+	}
+	timeSrc := "_stop"
+	if v, ok = args["timeSrc"]; ok {
+		timeSrc, _ = strconv.Unquote(v)
+		if timeSrc != "_start" && timeSrc != "_stop" {
+			return "", e, 0, "", errors.ErrStepParse
+		}
+	}
+	// the window at one end of the range is stamped with the range bound, so the extent
+	// of the points is one step inside of it
+	if timeSrc == "_start" {
+		e.End = e.End.Add(-step)
+	} else {
+		e.Start = e.Start.Add(step)
+	}
+	return statement, e, step, timeSrc, nil
+}
+
+// interpolateFluxQuery replaces the range() token in the Flux query with the provided
+// Extent. Each point is stamped with the stop of its window unless the timeSrc is _start,
+// so the range is extended by one step to include the window of the first or last point
+func interpolateFluxQuery(template string, trq *timeseries.TimeRangeQuery,
+	extent *timeseries.Extent) string {
+	start, stop := extent.Start, extent.End
+	if trq.TimestampFieldName == "_start" {
+		stop = stop.Add(trq.Step)
+	} else {
+		start = start.Add(-trq.Step)
+	}
+	return strings.Replace(template, tkRange, "start: "+start.UTC().Format(time.RFC3339Nano)+
+		", stop: "+stop.UTC().Format(time.RFC3339Nano), -1)
+}
+
+// setFluxExtent replaces the body of the Flux query request with the query for the
+// provided Extent
+func setFluxExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	t := trq.TemplateURL.Query()
+	fq := &fluxQuery{
+		Query:   interpolateFluxQuery(trq.Statement, trq, extent),
+		Type:    t.Get(upFluxType),
+		Dialect: json.RawMessage(t.Get(upFluxDialect)),
+	}
+	b, err := json.Marshal(fq)
+	if err != nil {
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Del(headers.NameContentLength)
+}
+
+// findFluxCall returns the indexes of the opening and closing parentheses of the only
+// function call in the Flux query that is matched by the provided expression
+func findFluxCall(q string, re *regexp.Regexp) (int, int, error) {
+	locs := re.FindAllStringIndex(q, -1)
+	if len(locs) != 1 {
+		return 0, 0, errors.ErrNotTimeRangeQuery
+	}
+	open := locs[0][1] - 1
+	var depth int
+	var quoted, escaped bool
+	for i := open; i < len(q); i++ {
+		b := q[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && b == '\\':
+			escaped = true
+		case b == '"':
+			quoted = !quoted
+		case quoted:
+		case b == '(':
+			depth++
+		case b == ')':
+			depth--
+			if depth == 0 {
+				return open, i, nil
+			}
+		}
+	}
+	return 0, 0, errors.ErrNotTimeRangeQuery
+}
+
+// parseFluxArgs returns the named arguments of a Flux function call by name
+func parseFluxArgs(s string) map[string]string {
+	args := make(map[string]string)
+	add := func(arg string) {
+		if i := strings.Index(arg, ":"); i > 0 {
+			args[strings.TrimSpace(arg[:i])] = strings.TrimSpace(arg[i+1:])
+		}
+	}
+	var depth, start int
+	var quoted, escaped bool
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && b == '\\':
+			escaped = true
+		case b == '"':
+			quoted = !quoted
+		case quoted:
+		case b == '(' || b == '[' || b == '{':
+			depth++
+		case b == ')' || b == ']' || b == '}':
+			depth--
+		case b == ',' && depth == 0:
+			add(s[start:i])
+			start = i + 1
+		}
+	}
+	add(s[start:])
+	return args
+}
+
+// parseFluxTime returns the time of a Flux range() bound, which may be an RFC3339 time,
+// a time() conversion of one, a duration relative to now, or a Unix timestamp in seconds
+func parseFluxTime(v string, now time.Time) (time.Time, error) {
+	if v == "now()" {
+		return now, nil
+	}
+	if m := reFluxTime.FindStringSubmatch(v); len(m) == 2 {
+		v = m[1]
+	} else if d, err := parseFluxDuration(v); err == nil {
+		return now.Add(d), nil
+	} else if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(i, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return t, errors.ErrNotTimeRangeQuery
+	}
+	return t, nil
+}
+
+// parseFluxDuration returns the time.Duration of a Flux duration literal, such as 1h30m.
+// Calendar durations of months or years have no fixed length, and are not supported
+func parseFluxDuration(s string) (time.Duration, error) {
+	var neg bool
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	}
+	m := reFluxDuration.FindAllStringSubmatch(s, -1)
+	var d time.Duration
+	var n int
+	for _, p := range m {
+		n += len(p[0])
+		v, err := strconv.ParseInt(p[1], 10, 64)
+		if err != nil {
+			return 0, errors.ErrStepParse
+		}
+		var u time.Duration
+		switch p[2] {
+		case "ns":
+			u = time.Nanosecond
+		case "us", "µs":
+			u = time.Microsecond
+		case "ms":
+			u = time.Millisecond
+		case "s":
+			u = time.Second
+		case "m":
+			u = time.Minute
+		case "h":
+			u = time.Hour
+		case "d":
+			u = 24 * time.Hour
+		case "w":
+			u = 7 * 24 * time.Hour
+		default:
+			return 0, errors.ErrStepParse
+		}
+		d += time.Duration(v) * u
+	}
+	if len(m) == 0 || n != len(s) {
+		return 0, errors.ErrStepParse
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,355 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testFluxDialect = `{"header":true,"delimiter":",","annotations":["datatype","group","default"]}`
+
+func testFluxBody(q, dialect string) string {
+	return `{"query":` + strconv.Quote(q) + `,"type":"flux","dialect":` + dialect + `}`
+}
+
+func TestParseFluxDuration(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected time.Duration
+		err      bool
+	}{
+		{"1m", time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"60000ms", time.Minute, false},
+		{"-6h", -6 * time.Hour, false},
+		{"2d", 48 * time.Hour, false},
+		{"1mo", 0, true},
+		{"1y", 0, true},
+		{"1m30", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		d, err := parseFluxDuration(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t got %v", test.s, test.err, err)
+		}
+		if d != test.expected {
+			t.Errorf("%s: expected %s got %s", test.s, test.expected, d)
+		}
+	}
+}
+
+func TestParseFluxTime(t *testing.T) {
+	now := time.Unix(3600, 0)
+	tests := []struct {
+		v        string
+		expected time.Time
+		err      bool
+	}{
+		{"now()", now, false},
+		{"-1h", time.Unix(0, 0), false},
+		{"1970-01-01T00:30:00Z", time.Unix(1800, 0), false},
+		{`time(v: "1970-01-01T00:30:00Z")`, time.Unix(1800, 0), false},
+		{"1800", time.Unix(1800, 0), false},
+		{"v.timeRangeStart", time.Time{}, true},
+	}
+	for _, test := range tests {
+		v, err := parseFluxTime(test.v, now)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t got %v", test.v, test.err, err)
+		}
+		if !test.err && !v.Equal(test.expected) {
+			t.Errorf("%s: expected %s got %s", test.v, test.expected, v)
+		}
+	}
+}
+
+func TestParseFluxQuery(t *testing.T) {
+
+	now := time.Unix(7200, 0)
+	const window = `|> aggregateWindow(every: 1m, fn: (column, tables=<-) => tables |> mean(column: column))`
+
+	q := `from(bucket: "b") |> range(start: -1h) |> filter(fn: (r) => r._measurement == "cpu") ` + window
+	s, e, step, timeSrc, err := parseFluxQuery(q, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `from(bucket: "b") |> range(` + tkRange + `) |> filter(fn: (r) => r._measurement == "cpu") ` +
+		window
+	if s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+	if step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, step)
+	}
+	if timeSrc != "_stop" {
+		t.Errorf("expected %s got %s", "_stop", timeSrc)
+	}
+	// the first window of the range is stamped with its stop
+	if !e.Start.Equal(time.Unix(3660, 0)) || !e.End.Equal(now) {
+		t.Errorf("unexpected extent %s", e)
+	}
+
+	q = `from(bucket: "b") |> range(start: 1970-01-01T01:00:00Z, stop: time(v: "1970-01-01T01:30:00Z"))` +
+		` |> aggregateWindow(every: 5m, fn: last, timeSrc: "_start")`
+	_, e, step, timeSrc, err = parseFluxQuery(q, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step != 5*time.Minute || timeSrc != "_start" {
+		t.Errorf("unexpected step %s or timeSrc %s", step, timeSrc)
+	}
+	// the last window of the range is stamped with its start
+	if !e.Start.Equal(time.Unix(3600, 0)) || !e.End.Equal(time.Unix(5100, 0)) {
+		t.Errorf("unexpected extent %s", e)
+	}
+
+	tests := []struct {
+		q   string
+		err error
+	}{
+		{`from(bucket: "b") |> filter(fn: (r) => true) ` + window, errors.ErrNotTimeRangeQuery},
+		{`from(bucket: "b") |> range(stop: -1h) ` + window, errors.ErrNotTimeRangeQuery},
+		{`from(bucket: "b") |> range(start: v.timeRangeStart) ` + window, errors.ErrNotTimeRangeQuery},
+		{`union(tables: [from(bucket: "a") |> range(start: -1h), from(bucket: "b") |> range(start: -1h)])` +
+			window, errors.ErrNotTimeRangeQuery},
+		{`from(bucket: "b") |> range(start: -1h) |> mean()`, errors.ErrStepParse},
+		{`from(bucket: "b") |> range(start: -1h) |> aggregateWindow(every: 1mo, fn: mean)`,
+			errors.ErrStepParse},
+		{`from(bucket: "b") |> range(start: -1h) |> aggregateWindow(every: 1m, offset: 10s, fn: mean)`,
+			errors.ErrStepParse},
+		{`from(bucket: "b") |> range(start: -1h) |> aggregateWindow(every: 1m, period: 5m, fn: mean)`,
+			errors.ErrStepParse},
+	}
+	for i, test := range tests {
+		if _, _, _, _, err = parseFluxQuery(test.q, now); err != test.err {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestParseFluxTimeRangeQuery(t *testing.T) {
+
+	const q = `from(bucket: "b") |> range(start: -1h) |> aggregateWindow(every: 1m, fn: mean)`
+
+	r, _ := http.NewRequest(http.MethodPost, "http://blah.com/api/v2/query?org=test",
+		bytes.NewBufferString(testFluxBody(q, testFluxDialect)))
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+
+	client := &Client{}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+	if !trq.AlignToEpoch {
+		t.Error("expected extent aligned to the epoch")
+	}
+	v := trq.TemplateURL.Query()
+	if v.Get(upOrg) != "test" || v.Get(upFluxQuery) != trq.Statement ||
+		v.Get(upFluxDialect) != testFluxDialect {
+		t.Errorf("unexpected template url %s", trq.TemplateURL)
+	}
+	// the request body is left intact to proxy the request if it can't be cached
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != testFluxBody(q, testFluxDialect) {
+		t.Errorf("expected %s got %s", testFluxBody(q, testFluxDialect), string(b))
+	}
+
+	tests := []struct {
+		body, contentType string
+	}{
+		// a raw flux query can't request annotations
+		{q, "application/vnd.flux"},
+		{testFluxBody(q, `{"annotations":["datatype"]}`), headers.ValueApplicationJSON},
+		{testFluxBody(q, `{"header":false,"annotations":["group"]}`), headers.ValueApplicationJSON},
+		{testFluxBody(q, `{"delimiter":";","annotations":["group"]}`), headers.ValueApplicationJSON},
+		{`{"query":` + strconv.Quote(q) + `}`, headers.ValueApplicationJSON},
+		{`{"query":` + strconv.Quote(q) + `,"dialect":` + testFluxDialect + `,"now":"2020-01-01T00:00:00Z"}`,
+			headers.ValueApplicationJSON},
+		{`{"query":` + strconv.Quote(q) + `,"dialect":` + testFluxDialect + `,"type":"sql"}`,
+			headers.ValueApplicationJSON},
+		{`{"dialect":` + testFluxDialect + `}`, headers.ValueApplicationJSON},
+		{`{`, headers.ValueApplicationJSON},
+	}
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodPost, "http://blah.com/api/v2/query",
+			bytes.NewBufferString(test.body))
+		r.Header.Set(headers.NameContentType, test.contentType)
+		if _, err := client.ParseTimeRangeQuery(r); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
+
+func TestSetFluxExtent(t *testing.T) {
+
+	const q = `from(bucket: "b") |> range(start: -1h) |> aggregateWindow(every: 1m, fn: mean)`
+
+	r, _ := http.NewRequest(http.MethodPost, "http://blah.com/api/v2/query",
+		bytes.NewBufferString(testFluxBody(q, testFluxDialect)))
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+
+	client := &Client{}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &timeseries.Extent{Start: time.Unix(3600, 0), End: time.Unix(7200, 0)}
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	if r.ContentLength != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), r.ContentLength)
+	}
+	fq := &fluxQuery{}
+	if err = json.Unmarshal(b, fq); err != nil {
+		t.Fatal(err)
+	}
+	// the range includes the window that is stamped with the start of the extent
+	expected := `from(bucket: "b") |> range(start: 1970-01-01T00:59:00Z, stop: 1970-01-01T02:00:00Z)` +
+		` |> aggregateWindow(every: 1m, fn: mean)`
+	if fq.Query != expected {
+		t.Errorf("expected %s got %s", expected, fq.Query)
+	}
+	if fq.Type != "flux" || string(fq.Dialect) != testFluxDialect {
+		t.Errorf("unexpected type %s or dialect %s", fq.Type, string(fq.Dialect))
+	}
+}
+
+func TestFluxHandler(t *testing.T) {
+
+	var mtx sync.Mutex
+	var ranges []string
+	reRange := regexp.MustCompile(`range\(start: (\S+), stop: (\S+)\)`)
+
+	// the upstream returns a point for each window of the requested range, stamped with its stop
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		fq := &fluxQuery{}
+		json.Unmarshal(b, fq)
+		m := reRange.FindStringSubmatch(fq.Query)
+		if len(m) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mtx.Lock()
+		ranges = append(ranges, m[1]+"/"+m[2])
+		mtx.Unlock()
+		start, _ := time.Parse(time.RFC3339, m[1])
+		stop, _ := time.Parse(time.RFC3339, m[2])
+		w.Header().Set(headers.NameContentType, "text/csv; charset=utf-8")
+		w.Write([]byte(testFluxCSV(int(start.Unix()/60)+1, int(stop.Unix()/60), "a", "b")))
+	}))
+	defer up.Close()
+
+	// the range is an hour in the past, to be outside of the backfill tolerance
+	base := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil,
+		"influxdb", "/"+mnFluxQuery, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(up.URL)
+	u := r.URL
+
+	query := func(start, stop time.Time) (*http.Response, string) {
+		q := fmt.Sprintf(`from(bucket: "b") |> range(start: %s, stop: %s)`+
+			` |> aggregateWindow(every: 1m, fn: mean)`,
+			start.UTC().Format(time.RFC3339), stop.UTC().Format(time.RFC3339))
+		body := testFluxBody(q, testFluxDialect)
+		r.URL = urls.Clone(u)
+		r.Method = http.MethodPost
+		r.Body = ioutil.NopCloser(strings.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+
+		w := httptest.NewRecorder()
+		client.FluxHandler(w, r)
+		resp := w.Result()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+
+	m := int(base.Unix() / 60)
+
+	resp, b := query(base, base.Add(10*time.Minute))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d got %d: %s", http.StatusOK, resp.StatusCode, b)
+	}
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=kmiss") {
+		t.Errorf("expected kmiss got %s", v)
+	}
+	if b != testFluxCSV(m+1, m+10, "a", "b") {
+		t.Errorf("expected %s got %s", testFluxCSV(m+1, m+10, "a", "b"), b)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	// the second range is partially cached, so only its uncached windows are fetched
+	resp, b = query(base.Add(5*time.Minute), base.Add(20*time.Minute))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected %d got %d: %s", http.StatusOK, resp.StatusCode, b)
+	}
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=phit") {
+		t.Errorf("expected phit got %s", v)
+	}
+	if b != testFluxCSV(m+6, m+20, "a", "b") {
+		t.Errorf("expected %s got %s", testFluxCSV(m+6, m+20, "a", "b"), b)
+	}
+
+	expected := []string{
+		base.UTC().Format(time.RFC3339) + "/" + base.Add(10*time.Minute).UTC().Format(time.RFC3339),
+		base.Add(10*time.Minute).UTC().Format(time.RFC3339) + "/" +
+			base.Add(20*time.Minute).UTC().Format(time.RFC3339),
+	}
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("expected %s got %s", expected[i], ranges[i])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+// upEpoch is the URL Parameter that sets the precision of the timestamps in InfluxDB responses
+const upEpoch = "epoch"
+
+// PrometheusQueryRangeHandler serves the Prometheus query_range API by translating the PromQL
+// expression into an InfluxQL query with the origin's prometheus_api templates. The InfluxQL
+// query is processed through the delta proxy cache, and its results are converted to a matrix
+func (c *Client) PrometheusQueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+
+	pr, err := promapi.ParseRequest(r)
+	if err != nil {
+		promapi.WriteError(w, http.StatusBadRequest, promapi.ErrorTypeBadData, err)
+		return
+	}
+
+	q, t, err := promapi.Translate(c.config.PrometheusAPI, pr)
+	if err != nil {
+		promapi.WriteError(w, http.StatusBadRequest, promapi.ErrorTypeBadData, err)
+		return
+	}
+
+	v := url.Values{}
+	for k, p := range t.Params {
+		v.Set(k, p)
+	}
+	v.Set(upQuery, q)
+	v.Set(upEpoch, "ms")
+
+	r.Method = http.MethodGet
+	r.Body = nil
+	r.ContentLength = 0
+	r.Header.Del(headers.NameContentType)
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+	r.URL.Path = "/" + mnQuery
+	r.URL.RawQuery = v.Encode()
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+
+	pw := promapi.NewWriter(w, seriesFromResponse)
+	engines.DeltaProxyCacheRequest(pw, r)
+	pw.Flush()
+}
+
+// seriesFromResponse converts an InfluxDB JSON response into Prometheus Series. Each value
+// column of each InfluxDB series becomes a Series labeled with the series' tags and named for
+// its measurement. When there are several value columns, the column name is the field label
+func seriesFromResponse(b []byte) ([]*promapi.Series, error) {
+	se := &SeriesEnvelope{}
+	if err := json.Unmarshal(b, se); err != nil {
+		return nil, err
+	}
+	if se.Err != "" {
+		return nil, fmt.Errorf("origin returned an error: %s", se.Err)
+	}
+	out := make([]*promapi.Series, 0)
+	for _, res := range se.Results {
+		if res.Err != "" {
+			return nil, fmt.Errorf("origin returned an error: %s", res.Err)
+		}
+		for _, row := range res.Series {
+			ti := str.IndexOfString(row.Columns, "time")
+			if ti < 0 {
+				continue
+			}
+			for i, col := range row.Columns {
+				if i == ti {
+					continue
+				}
+				s := &promapi.Series{Labels: make(map[string]string, len(row.Tags)+2)}
+				for k, v := range row.Tags {
+					s.Labels[k] = v
+				}
+				s.Labels["__name__"] = row.Name
+				if len(row.Columns) > 2 {
+					s.Labels["field"] = col
+				}
+				for _, vals := range row.Values {
+					if len(vals) <= i || len(vals) <= ti {
+						continue
+					}
+					ts, ok := vals[ti].(float64)
+					if !ok {
+						continue
+					}
+					f, ok := vals[i].(float64)
+					if !ok {
+						continue
+					}
+					s.Points = append(s.Points, promapi.Point{
+						Timestamp: time.Unix(0, int64(ts)*int64(time.Millisecond)), Value: f})
+				}
+				out = append(out, s)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
+	pao "github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testInfluxResponse = `{"results":[{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"a1"},` +
+	`"columns":["time","mean"],"values":[[1577836800000,0.5],[1577836860000,null],[1577836920000,1.5]]}]}]}`
+
+func testPrometheusAPIPathConfigs(client *Client) func(*oo.Options) map[string]*po.Options {
+	return func(oc *oo.Options) map[string]*po.Options {
+		oc.PrometheusAPI = pao.NewOptions()
+		oc.PrometheusAPI.Templates["cpu"] = &pao.TemplateOptions{
+			Match: `cpu_usage\{host="(?P<host>[a-z0-9]+)"\}`,
+			Query: `SELECT mean("usage") FROM "cpu" WHERE "host" = '${host}' AND ` +
+				`time >= ${start_ms}ms AND time <= ${end_ms}ms GROUP BY time(${step}), "host"`,
+			Params: map[string]string{"db": "telegraf"},
+		}
+		oc.PrometheusAPI.Compile()
+		return client.DefaultPathConfigs(oc)
+	}
+}
+
+func TestPrometheusQueryRangeHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", testPrometheusAPIPathConfigs(client), 200,
+		testInfluxResponse, map[string]string{"Content-Type": "application/json"}, "influxdb",
+		promapi.QueryRangePath+`?query=cpu_usage{host="a1"}&start=1577836800&end=1577836920&step=60`, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+
+	pc, ok := client.config.Paths[promapi.QueryRangePath]
+	if !ok {
+		t.Fatalf("expected to find path named: %s", promapi.QueryRangePath)
+	}
+	rsc.PathConfig = pc
+
+	client.PrometheusQueryRangeHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	expected := `{"status":"success","data":{"resultType":"matrix","result":[` +
+		`{"metric":{"__name__":"cpu","host":"a1"},"values":[[1577836800,"0.5"],[1577836920,"1.5"]]}]}}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+
+	if r.URL.Query().Get(upDB) != "telegraf" {
+		t.Errorf("expected %s got %s", "telegraf", r.URL.Query().Get(upDB))
+	}
+}
+
+func TestPrometheusQueryRangeHandlerBadRequest(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, _, err := tu.NewTestInstance("", testPrometheusAPIPathConfigs(client), 200, "{}", nil, "influxdb",
+		promapi.QueryRangePath+`?query=up&start=1577836800&end=1577836920&step=60`, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	client.config = request.GetResources(r).OriginConfig
+
+	client.PrometheusQueryRangeHandler(w, r)
+	if w.Code != 400 {
+		t.Errorf("expected 400 got %d", w.Code)
+	}
+
+	expected := `{"status":"error","errorType":"bad_data","error":"no prometheus_api template matches the query"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+}
+
+func TestSeriesFromResponse(t *testing.T) {
+
+	s, err := seriesFromResponse([]byte(`{"results":[{"statement_id":0,"series":[{"name":"cpu",` +
+		`"columns":["time","min","max"],"values":[[60000,1,2]]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(s))
+	}
+	if s[1].Labels["field"] != "max" || s[1].Points[0].Value != 2 || s[1].Points[0].Timestamp.Unix() != 60 {
+		t.Errorf("unexpected series %v", s[1])
+	}
+
+	_, err = seriesFromResponse([]byte(`{"results":[{"statement_id":0,"error":"database not found"}]}`))
+	if err == nil {
+		t.Error("expected error for failed statement")
+	}
+
+	_, err = seriesFromResponse([]byte(`{`))
+	if err == nil {
+		t.Error("expected error for invalid document")
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
 	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
@@ -33,7 +34,7 @@ import (
 // QueryHandler handles timeseries requests for InfluxDB and processes them through the delta proxy cache
 func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 
-	_, s, _ := params.GetRequestValues(r)
+	v, s, _ := params.GetRequestValues(r)
 
 	s = strings.Replace(strings.ToLower(s), "%20", "+", -1)
 	// if it's not a select statement, just proxy it instead
@@ -42,13 +43,46 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a multi-statement query with any statement other than a SELECT is proxied unmodified,
+	// so this is checked before the request is prepared for the delta proxy cache
+	statements := splitStatements(v.Get(upQuery))
+	if len(statements) > 1 && !allSelects(statements) {
+		c.ProxyHandler(w, r)
+		return
+	}
+
 	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+
+	// CSV and MessagePack responses are cached and merged as JSON, and are converted
+	// to the requested format only when responding to the client
+	f := getResponseFormat(r.Header)
+	if f != formatJSON {
+		r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+	}
+
+	// multi-statement queries are split so that each statement is cached separately
+	if len(statements) > 1 {
+		c.batchQueryHandler(w, r, v, statements, f)
+		return
+	}
+
+	if f != formatJSON {
+		fw := newFormatWriter(w, f)
+		engines.DeltaProxyCacheRequest(fw, r)
+		fw.flush()
+		return
+	}
+
 	engines.DeltaProxyCacheRequest(w, r)
 }
 
 // ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
 func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
 
+	if isFluxRequest(r) {
+		return parseFluxTimeRangeQuery(r)
+	}
+
 	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
 
 	v, _, _ := params.GetRequestValues(r)
@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
 	"github.com/tricksterproxy/trickster/pkg/sort/times"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 
@@ -49,15 +50,30 @@ type Result struct {
 	Err         string       `json:"error,omitempty"`
 }
 
-// MarshalTimeseries converts a Timeseries into a JSON blob
+// MarshalTimeseries converts a Timeseries into a JSON blob, or into annotated CSV when
+// it is the response to a Flux query
 func (c Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	if fr, ok := ts.(*FluxResponse); ok {
+		return fr.MarshalCSV()
+	}
 	// Marshal the Envelope back to a json object for Cache Storage
 	return json.Marshal(ts)
 }
 
-// UnmarshalTimeseries converts a JSON blob into a Timeseries
+// UnmarshalTimeseries converts a JSON blob, or the annotated CSV response to a Flux
+// query, into a Timeseries
 func (c Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	if isAnnotatedCSV(data) {
+		return UnmarshalFluxResponse(data)
+	}
 	se := &SeriesEnvelope{}
+	if c.config != nil && len(data) >= c.config.FastJSONMinBytes &&
+		featureflags.Enabled(featureflags.FastJSONDecode, c.name, "", c.config.FastJSONDecode) {
+		if unmarshalSeries(data, se) == nil {
+			return se, nil
+		}
+		se = &SeriesEnvelope{}
+	}
 	err := json.Unmarshal(data, se)
 	return se, err
 }
@@ -22,6 +22,7 @@ import (
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi"
 )
 
 func (c *Client) registerHandlers() {
@@ -31,7 +32,9 @@ func (c *Client) registerHandlers() {
 	// and are able to be referenced by name (map key) in Config Files
 	c.handlers["health"] = http.HandlerFunc(c.HealthHandler)
 	c.handlers["query"] = http.HandlerFunc(c.QueryHandler)
+	c.handlers["flux"] = http.HandlerFunc(c.FluxHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	c.handlers["prometheus_query_range"] = http.HandlerFunc(c.PrometheusQueryRangeHandler)
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -69,6 +72,15 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			MatchTypeName:   "exact",
 			MatchType:       matching.PathMatchTypeExact,
 		},
+		"/" + mnFluxQuery: {
+			Path:            "/" + mnFluxQuery,
+			HandlerName:     "flux",
+			Methods:         []string{http.MethodPost},
+			CacheKeyParams:  []string{upOrg, upOrgID, upFluxQuery, upFluxType, upFluxDialect},
+			CacheKeyHeaders: []string{},
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		},
 		"/": {
 			Path:          "/",
 			HandlerName:   "proxy",
@@ -77,5 +89,19 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 			MatchTypeName: "prefix",
 		},
 	}
+
+	// the Prometheus-compatible API is only served when templates are configured to translate its queries
+	if oc.PrometheusAPI.Enabled() {
+		paths[promapi.QueryRangePath] = &po.Options{
+			Path:            promapi.QueryRangePath,
+			HandlerName:     "prometheus_query_range",
+			Methods:         []string{http.MethodGet, http.MethodPost},
+			CacheKeyParams:  []string{upDB, upQuery, "u", "p"},
+			CacheKeyHeaders: []string{},
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		}
+	}
+
 	return paths
 }
@@ -55,7 +55,7 @@ func TestDefaultPathConfigs(t *testing.T) {
 		t.Errorf("expected to find path named: %s", "/")
 	}
 
-	const expectedLen = 2
+	const expectedLen = 3
 	if len(client.config.Paths) != expectedLen {
 		t.Errorf("expected ordered length to be: %d", expectedLen)
 	}
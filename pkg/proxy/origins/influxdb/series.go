@@ -93,6 +93,23 @@ func (se *SeriesEnvelope) SeriesCount() int {
 	return len(se.Results)
 }
 
+// SeriesLabels returns the tags of each Series in the Timeseries, including the
+// measurement name as the _measurement label
+func (se *SeriesEnvelope) SeriesLabels() []map[string]string {
+	var labels []map[string]string
+	for _, r := range se.Results {
+		for _, s := range r.Series {
+			l := make(map[string]string, len(s.Tags)+1)
+			for k, v := range s.Tags {
+				l[k] = v
+			}
+			l["_measurement"] = s.Name
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
 // Step returns the step for the Timeseries
 func (se *SeriesEnvelope) Step() time.Duration {
 	return se.StepDuration
@@ -198,6 +215,55 @@ func (se *SeriesEnvelope) Merge(sort bool, collection ...timeseries.Timeseries)
 	}
 }
 
+// FillGaps fills the missing steps between the values of each series per the mode, and
+// returns the number of values that were added
+func (se *SeriesEnvelope) FillGaps(mode string, step, maxInterval time.Duration) int {
+	if step <= 0 || mode == timeseries.GapFillNone {
+		return 0
+	}
+	se.Sort()
+	var n int
+	for i := range se.Results {
+		for j, s := range se.Results[i].Series {
+			ti := str.IndexOfString(s.Columns, "time")
+			if ti == -1 || len(s.Values) < 2 {
+				continue
+			}
+			values := make([][]interface{}, 0, len(s.Values))
+			for k, v := range s.Values {
+				if k > 0 {
+					prev := s.Values[k-1]
+					pt, ok1 := prev[ti].(float64)
+					ct, ok2 := v[ti].(float64)
+					if ok1 && ok2 {
+						for _, t := range timeseries.GapTimes(msTime(pt), msTime(ct),
+							step, maxInterval, mode) {
+							row := make([]interface{}, len(v))
+							if mode == timeseries.GapFillCarryForward {
+								copy(row, prev)
+							}
+							row[ti] = float64(t.UnixNano() / int64(time.Millisecond))
+							values = append(values, row)
+						}
+					}
+				}
+				values = append(values, v)
+			}
+			n += len(values) - len(s.Values)
+			se.Results[i].Series[j].Values = values
+		}
+	}
+	if n > 0 {
+		se.isCounted = false
+	}
+	return n
+}
+
+// msTime returns the time of an epoch milliseconds timestamp
+func msTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
+
 // Clone returns a perfect copy of the base Timeseries
 func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
 	se.updateLock.Lock()
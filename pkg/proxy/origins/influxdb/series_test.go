@@ -155,6 +155,44 @@ func TestSeriesCount(t *testing.T) {
 	}
 }
 
+func TestSeriesLabels(t *testing.T) {
+	se := &SeriesEnvelope{
+		Results: []Result{
+			{
+				Series: []models.Row{
+					{
+						Name:    "a",
+						Columns: []string{"time", "units"},
+						Tags:    map[string]string{"tagName1": "tagValue1"},
+						Values: [][]interface{}{
+							{float64(10000), 1.5},
+						},
+					},
+				},
+			},
+			{
+				Series: []models.Row{
+					{
+						Name:    "b",
+						Columns: []string{"time", "units"},
+						Values: [][]interface{}{
+							{float64(10000), 1.5},
+						},
+					},
+				},
+			},
+		},
+	}
+	labels := se.SeriesLabels()
+	if len(labels) != 2 {
+		t.Fatalf("expected 2 got %d.", len(labels))
+	}
+	if labels[0]["_measurement"] != "a" || labels[0]["tagName1"] != "tagValue1" ||
+		labels[1]["_measurement"] != "b" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+}
+
 func TestValueCount(t *testing.T) {
 	se := &SeriesEnvelope{
 		Results: []Result{
@@ -1588,3 +1626,55 @@ func TestTags(t *testing.T) {
 		t.Error("expected empty string")
 	}
 }
+
+func TestFillGaps(t *testing.T) {
+
+	newSeries := func() *SeriesEnvelope {
+		return &SeriesEnvelope{
+			Results: []Result{
+				{
+					Series: []models.Row{
+						{
+							Name:    "a",
+							Columns: []string{"time", "value"},
+							Values: [][]interface{}{
+								{float64(10000), 1.0},
+								{float64(50000), 5.0},
+								{float64(60000), 6.0},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	se := newSeries()
+	if n := se.FillGaps(timeseries.GapFillNone, testStep, 0); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+	if n := se.FillGaps(timeseries.GapFillCarryForward, testStep, 0); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+	expected := [][]interface{}{
+		{float64(10000), 1.0},
+		{float64(20000), 1.0},
+		{float64(30000), 1.0},
+		{float64(40000), 1.0},
+		{float64(50000), 5.0},
+		{float64(60000), 6.0},
+	}
+	if !reflect.DeepEqual(se.Results[0].Series[0].Values, expected) {
+		t.Errorf("mismatch\nexpected=%v\n     got=%v", expected, se.Results[0].Series[0].Values)
+	}
+
+	se = newSeries()
+	if n := se.FillGaps(timeseries.GapFillNull, testStep, 0); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+	if v := se.Results[0].Series[0].Values[1]; v[0] != float64(20000) || v[1] != nil {
+		t.Errorf("unexpected row %v", v)
+	}
+
+}
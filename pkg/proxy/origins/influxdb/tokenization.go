@@ -32,7 +32,8 @@ import (
 
 // Tokens for String Interpolation
 const (
-	tkTime = "<$TIME_TOKEN$>"
+	tkTime  = "<$TIME_TOKEN$>"
+	tkRange = "<$RANGE_TOKEN$>"
 )
 
 var reTime1, reTime2, reStep *regexp.Regexp
@@ -25,7 +25,8 @@ import (
 
 // Upstream Endpoints
 const (
-	mnQuery = "query"
+	mnQuery     = "query"
+	mnFluxQuery = "api/v2/query"
 )
 
 // Common URL Parameter Names
@@ -34,8 +35,22 @@ const (
 	upDB    = "db"
 )
 
+// Flux Query API URL Parameter Names, and the names under which the fields of a Flux
+// query's request body are carried in the template URL
+const (
+	upOrg         = "org"
+	upOrgID       = "orgID"
+	upFluxQuery   = "query"
+	upFluxType    = "type"
+	upFluxDialect = "dialect"
+)
+
 // SetExtent will change the upstream request query to use the provided Extent
 func (c Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	if isFluxRequest(r) {
+		setFluxExtent(r, trq, extent)
+		return
+	}
 	v, _, _ := params.GetRequestValues(r)
 	// the TemplateURL in the TimeRangeQuery will always have URL Query Params, even for POSTs
 	// For POST, ParseTimeRangeQuery extracts the params from the original request body and
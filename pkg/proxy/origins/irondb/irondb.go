@@ -30,6 +30,11 @@ import (
 )
 
 var _ origins.Client = (*Client)(nil)
+var _ origins.KeyVersioner = (*Client)(nil)
+
+// cacheKeyVersion must be incremented whenever a change to this provider makes the objects
+// it cached with earlier versions incompatible (e.g., a change to how they are keyed or merged).
+const cacheKeyVersion = 1
 
 // IRONdb API path segments.
 const (
@@ -135,6 +140,11 @@ func (c *Client) Cache() cache.Cache {
 	return c.cache
 }
 
+// KeyVersion returns the version of the Client's cache key space.
+func (c *Client) KeyVersion() int {
+	return cacheKeyVersion
+}
+
 // Name returns the name of the origin Configuration proxied by the Client.
 func (c *Client) Name() string {
 	return c.name
@@ -40,6 +40,18 @@ func (c *Client) registerHandlers() {
 	c.handlers[mnState] = http.HandlerFunc(c.StateHandler)
 	c.handlers[mnCAQL] = http.HandlerFunc(c.CAQLHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	// the default paths reference handlers by their function names,
+	// so those are registered alongside the endpoint names
+	c.handlers["RawHandler"] = c.handlers[mnRaw]
+	c.handlers["RollupHandler"] = c.handlers[mnRollup]
+	c.handlers["FetchHandler"] = c.handlers[mnFetch]
+	c.handlers["TextHandler"] = c.handlers[mnRead]
+	c.handlers["HistogramHandler"] = c.handlers[mnHistogram]
+	c.handlers["FindHandler"] = c.handlers[mnFind]
+	c.handlers["StateHandler"] = c.handlers[mnState]
+	c.handlers["CAQLHandler"] = c.handlers[mnCAQL]
+	c.handlers["CAQLPubHandler"] = c.handlers[mnCAQL]
+	c.handlers["ProxyHandler"] = c.handlers["proxy"]
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -19,6 +19,7 @@ package irondb
 import (
 	"testing"
 
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
@@ -29,6 +30,11 @@ func TestRegisterHandlers(t *testing.T) {
 	if _, ok := c.handlers[mnCAQL]; !ok {
 		t.Errorf("expected to find handler named: %s", mnCAQL)
 	}
+	for _, p := range c.DefaultPathConfigs(oo.NewOptions()) {
+		if _, ok := c.handlers[p.HandlerName]; !ok {
+			t.Errorf("expected to find handler named: %s", p.HandlerName)
+		}
+	}
 }
 
 func TestHandlers(t *testing.T) {
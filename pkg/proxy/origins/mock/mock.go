@@ -0,0 +1,250 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mock provides a Mock Origin that serves synthetic time series responses in
+// the Prometheus, InfluxDB and ClickHouse formats, with configurable series counts,
+// latency and failure injection, for evaluating and load testing Trickster without a TSDB
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// Origin is an http.Handler that simulates a time series database
+type Origin struct {
+	options *Options
+	// the origin type's Trickster client is used to parse the time range of inbound queries
+	client origins.TimeseriesClient
+
+	rnd    *rand.Rand
+	rndMtx sync.Mutex
+}
+
+// New returns a new Mock Origin using the provided Options
+func New(o *Options) (*Origin, error) {
+	if o == nil {
+		o = NewOptions()
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	oc := oo.NewOptions()
+	oc.Name = "mock"
+	oc.OriginType = o.OriginType
+
+	var c origins.Client
+	var err error
+	switch o.OriginType {
+	case "prometheus":
+		c, err = prometheus.NewClient(oc.Name, oc, nil, nil)
+	case "influxdb":
+		c, err = influxdb.NewClient(oc.Name, oc, nil, nil)
+	case "clickhouse":
+		c, err = clickhouse.NewClient(oc.Name, oc, nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Origin{options: o, client: c.(origins.TimeseriesClient),
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+// Options returns the Options of the Mock Origin
+func (m *Origin) Options() *Options {
+	return m.options
+}
+
+// ServeHTTP delays the response by the configured latency, injects failures at the configured
+// rate, and otherwise responds with synthetic data in the format of the origin type
+func (m *Origin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	m.rndMtx.Lock()
+	delay := m.options.Latency
+	if m.options.LatencyJitter > 0 {
+		delay += time.Duration(m.rnd.Int63n(int64(m.options.LatencyJitter)))
+	}
+	fail := m.options.FailureRate > 0 && m.rnd.Float64() < m.options.FailureRate
+	m.rndMtx.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if fail {
+		w.WriteHeader(m.options.FailureStatus)
+		fmt.Fprintln(w, "mock origin injected failure")
+		return
+	}
+
+	switch m.options.OriginType {
+	case "prometheus":
+		m.servePrometheus(w, r)
+	case "influxdb":
+		m.serveInfluxDB(w, r)
+	case "clickhouse":
+		m.serveClickHouse(w, r)
+	}
+}
+
+// timestamps returns each Step-aligned timestamp in the query's Extent, inclusive of its End
+func timestamps(trq *timeseries.TimeRangeQuery) []time.Time {
+	if trq.Step <= 0 {
+		return nil
+	}
+	start := trq.Extent.Start.Truncate(trq.Step)
+	if start.Before(trq.Extent.Start) {
+		start = start.Add(trq.Step)
+	}
+	ts := make([]time.Time, 0, int(trq.Extent.End.Sub(start)/trq.Step)+1)
+	for t := start; !t.After(trq.Extent.End); t = t.Add(trq.Step) {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// value returns a repeatable value between 0 and 99 for the series at time t
+func value(series int, t time.Time) int64 {
+	return (t.Unix()/60*7919 + int64(series+1)*104729) % 100
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+type jsonObject map[string]interface{}
+
+func (m *Origin) servePrometheus(w http.ResponseWriter, r *http.Request) {
+
+	if strings.HasSuffix(r.URL.Path, "/query_range") {
+		trq, err := m.client.ParseTimeRangeQuery(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, jsonObject{"status": "error",
+				"errorType": "bad_data", "error": err.Error()})
+			return
+		}
+		result := make([]jsonObject, m.options.SeriesCount)
+		ts := timestamps(trq)
+		for i := range result {
+			values := make([][]interface{}, len(ts))
+			for j, t := range ts {
+				values[j] = []interface{}{t.Unix(), strconv.FormatInt(value(i, t), 10)}
+			}
+			result[i] = jsonObject{"metric": jsonObject{"__name__": "mock", "series_id": strconv.Itoa(i)},
+				"values": values}
+		}
+		writeJSON(w, http.StatusOK, jsonObject{"status": "success",
+			"data": jsonObject{"resultType": "matrix", "result": result}})
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/query") {
+		t := time.Now()
+		if v := r.FormValue("time"); v != "" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				t = time.Unix(int64(f), 0)
+			}
+		}
+		result := make([]jsonObject, m.options.SeriesCount)
+		for i := range result {
+			result[i] = jsonObject{"metric": jsonObject{"__name__": "mock", "series_id": strconv.Itoa(i)},
+				"value": []interface{}{t.Unix(), strconv.FormatInt(value(i, t), 10)}}
+		}
+		writeJSON(w, http.StatusOK, jsonObject{"status": "success",
+			"data": jsonObject{"resultType": "vector", "result": result}})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jsonObject{"status": "success", "data": []interface{}{}})
+}
+
+func (m *Origin) serveInfluxDB(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path == "/ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	trq, err := m.client.ParseTimeRangeQuery(r)
+	if err != nil {
+		// non-timeseries statements receive an empty result
+		writeJSON(w, http.StatusOK, jsonObject{"results": []jsonObject{{"statement_id": 0}}})
+		return
+	}
+
+	epoch := r.FormValue("epoch")
+	ts := timestamps(trq)
+	series := make([]jsonObject, m.options.SeriesCount)
+	for i := range series {
+		values := make([][]interface{}, len(ts))
+		for j, t := range ts {
+			var tv interface{}
+			switch epoch {
+			case "":
+				tv = t.UTC().Format(time.RFC3339)
+			case "s":
+				tv = t.Unix()
+			default:
+				tv = t.Unix() * 1000
+			}
+			values[j] = []interface{}{tv, value(i, t)}
+		}
+		series[i] = jsonObject{"name": "mock", "tags": jsonObject{"series_id": strconv.Itoa(i)},
+			"columns": []string{"time", "value"}, "values": values}
+	}
+	writeJSON(w, http.StatusOK, jsonObject{"results": []jsonObject{{"statement_id": 0, "series": series}}})
+}
+
+func (m *Origin) serveClickHouse(w http.ResponseWriter, r *http.Request) {
+
+	meta := []jsonObject{{"name": "t", "type": "UInt64"},
+		{"name": "series_id", "type": "String"}, {"name": "value", "type": "UInt64"}}
+
+	trq, err := m.client.ParseTimeRangeQuery(r)
+	if err != nil {
+		// non-timeseries queries, including health checks, receive an empty result
+		writeJSON(w, http.StatusOK, jsonObject{"meta": meta, "data": []jsonObject{}, "rows": 0})
+		return
+	}
+
+	ts := timestamps(trq)
+	data := make([]jsonObject, 0, len(ts)*m.options.SeriesCount)
+	for _, t := range ts {
+		for i := 0; i < m.options.SeriesCount; i++ {
+			data = append(data, jsonObject{"t": strconv.FormatInt(t.Unix()*1000, 10),
+				"series_id": strconv.Itoa(i), "value": strconv.FormatInt(value(i, t), 10)})
+		}
+	}
+	writeJSON(w, http.StatusOK, jsonObject{"meta": meta, "data": data, "rows": len(data)})
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const testStart, testEnd = 1589904000, 1589907600
+
+func testServe(t *testing.T, o *Options, path string, v url.Values) (int, []byte) {
+	m, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://mock"+path+"?"+v.Encode(), nil))
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, b
+}
+
+func testTimeseries(t *testing.T, m *Origin, b []byte) timeseries.Timeseries {
+	ts, err := m.client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestPrometheus(t *testing.T) {
+
+	o := NewOptions()
+	o.SeriesCount = 3
+	m, _ := New(o)
+
+	code, b := testServe(t, o, "/api/v1/query_range", url.Values{"query": {"up"},
+		"start": {fmt.Sprint(testStart)}, "end": {fmt.Sprint(testEnd)}, "step": {"60"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, code)
+	}
+	ts := testTimeseries(t, m, b)
+	if ts.SeriesCount() != 3 {
+		t.Errorf("expected %d got %d", 3, ts.SeriesCount())
+	}
+	if ts.TimestampCount() != 61 {
+		t.Errorf("expected %d got %d", 61, ts.TimestampCount())
+	}
+
+	code, b = testServe(t, o, "/api/v1/query", url.Values{"query": {"up"}})
+	if code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, code)
+	}
+	if _, err := m.client.UnmarshalInstantaneous(b); err != nil {
+		t.Error(err)
+	}
+
+	code, _ = testServe(t, o, "/api/v1/query_range", url.Values{"query": {"up"}})
+	if code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, code)
+	}
+
+}
+
+func TestInfluxDB(t *testing.T) {
+
+	o := NewOptions()
+	o.OriginType = "influxdb"
+	o.SeriesCount = 2
+	m, _ := New(o)
+
+	q := fmt.Sprintf(`SELECT mean("value") FROM "mock" WHERE time >= %dms AND time <= %dms GROUP BY time(1m)`,
+		testStart*1000, testEnd*1000)
+	code, b := testServe(t, o, "/query", url.Values{"q": {q}, "epoch": {"ms"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, code)
+	}
+	ts := testTimeseries(t, m, b)
+	if ts.ValueCount() != 122 {
+		t.Errorf("expected %d got %d", 122, ts.ValueCount())
+	}
+	if ts.TimestampCount() != 61 {
+		t.Errorf("expected %d got %d", 61, ts.TimestampCount())
+	}
+
+	code, _ = testServe(t, o, "/ping", nil)
+	if code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, code)
+	}
+
+}
+
+func TestClickHouse(t *testing.T) {
+
+	o := NewOptions()
+	o.OriginType = "clickhouse"
+	o.SeriesCount = 2
+	m, _ := New(o)
+
+	q := fmt.Sprintf("SELECT (intDiv(toUInt32(datetime), 60) * 60) * 1000 AS t, count() AS cnt "+
+		"FROM mock WHERE datetime BETWEEN %d AND %d GROUP BY t ORDER BY t FORMAT JSON", testStart, testEnd)
+	code, b := testServe(t, o, "/", url.Values{"query": {q}})
+	if code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, code)
+	}
+	ts := testTimeseries(t, m, b)
+	if ts.TimestampCount() != 61 {
+		t.Errorf("expected %d got %d", 61, ts.TimestampCount())
+	}
+	re := ts.(*clickhouse.ResultsEnvelope)
+	if len(re.Data[0].Values) != 2 {
+		t.Errorf("expected %d got %d", 2, len(re.Data[0].Values))
+	}
+
+}
+
+func TestFailureInjection(t *testing.T) {
+
+	o := NewOptions()
+	o.FailureRate = 1
+	o.FailureStatus = http.StatusBadGateway
+	o.Latency = 10 * time.Millisecond
+
+	start := time.Now()
+	code, _ := testServe(t, o, "/api/v1/query", url.Values{"query": {"up"}})
+	if code != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, code)
+	}
+	if time.Since(start) < o.Latency {
+		t.Errorf("expected response to be delayed by at least %s", o.Latency)
+	}
+
+}
+
+func TestNew(t *testing.T) {
+
+	if _, err := New(nil); err != nil {
+		t.Error(err)
+	}
+
+	o := NewOptions()
+	o.OriginType = "irondb"
+	if _, err := New(o); err != ErrInvalidOriginType {
+		t.Errorf("expected %v got %v", ErrInvalidOriginType, err)
+	}
+
+}
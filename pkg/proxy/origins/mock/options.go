@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mock
+
+import (
+	"errors"
+	"time"
+)
+
+// Options defines the behavior of a Mock Origin
+type Options struct {
+	// OriginType is the type of origin to simulate (prometheus, influxdb or clickhouse)
+	OriginType string
+	// SeriesCount is the number of series included in each time series response
+	SeriesCount int
+	// Latency is the minimum delay before each response is written
+	Latency time.Duration
+	// LatencyJitter is the maximum random delay added to Latency
+	LatencyJitter time.Duration
+	// FailureRate is the fraction of requests, between 0 and 1, that fail with FailureStatus
+	FailureRate float64
+	// FailureStatus is the HTTP status code of injected failures
+	FailureStatus int
+}
+
+// ErrInvalidOriginType is returned when the origin type cannot be simulated
+var ErrInvalidOriginType = errors.New("invalid mock origin type")
+
+// ErrInvalidSeriesCount is returned when the series count is less than 1
+var ErrInvalidSeriesCount = errors.New("series count must be at least 1")
+
+// ErrInvalidLatency is returned when the latency or latency jitter are negative
+var ErrInvalidLatency = errors.New("latency and latency jitter must not be negative")
+
+// ErrInvalidFailureRate is returned when the failure rate is not between 0 and 1
+var ErrInvalidFailureRate = errors.New("failure rate must be between 0 and 1")
+
+// ErrInvalidFailureStatus is returned when the failure status is not an HTTP error code
+var ErrInvalidFailureStatus = errors.New("failure status must be between 400 and 599")
+
+// NewOptions returns a new Options reference with default values
+func NewOptions() *Options {
+	return &Options{
+		OriginType:    "prometheus",
+		SeriesCount:   1,
+		FailureStatus: 503,
+	}
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	switch o.OriginType {
+	case "prometheus", "influxdb", "clickhouse":
+	default:
+		return ErrInvalidOriginType
+	}
+	if o.SeriesCount < 1 {
+		return ErrInvalidSeriesCount
+	}
+	if o.Latency < 0 || o.LatencyJitter < 0 {
+		return ErrInvalidLatency
+	}
+	if o.FailureRate < 0 || o.FailureRate > 1 {
+		return ErrInvalidFailureRate
+	}
+	if o.FailureStatus < 400 || o.FailureStatus > 599 {
+		return ErrInvalidFailureStatus
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mock
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+
+	o := NewOptions()
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		modify   func(*Options)
+		expected error
+	}{
+		{func(o *Options) { o.OriginType = "irondb" }, ErrInvalidOriginType},
+		{func(o *Options) { o.SeriesCount = 0 }, ErrInvalidSeriesCount},
+		{func(o *Options) { o.LatencyJitter = -1 }, ErrInvalidLatency},
+		{func(o *Options) { o.FailureRate = 1.5 }, ErrInvalidFailureRate},
+		{func(o *Options) { o.FailureStatus = 200 }, ErrInvalidFailureStatus},
+	}
+
+	for i, test := range tests {
+		o := NewOptions()
+		test.modify(o)
+		if err := o.Validate(); err != test.expected {
+			t.Errorf("test %d: expected %v got %v", i, test.expected, err)
+		}
+	}
+
+}
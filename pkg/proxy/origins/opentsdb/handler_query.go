@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// QueryHandler handles requests to /api/query, using either GET requests or POST requests
+// with a JSON query body, and processes them through the delta proxy cache
+func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	setJSONContentType(r)
+	engines.DeltaProxyCacheRequest(w, r)
+}
+
+// setJSONContentType sets the content type of a request with a body to JSON, which is the
+// only body OpenTSDB accepts, so that clients that set another content type (or none) do
+// not have their body parsed as a form when its cache key is derived
+func setJSONContentType(r *http.Request) {
+	if methods.HasBody(r.Method) {
+		r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	}
+}
+
+// queryHandlerDeriveCacheKey calculates a cache key from the path, the credentials, all of
+// the query parameters other than those of the time range, including each of the sub
+// queries in the order they were requested, and the query body without its time range
+func (c *Client) queryHandlerDeriveCacheKey(path string, qp url.Values,
+	h http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+	var sb strings.Builder
+	sb.WriteString(path)
+	if v := h.Get(headers.NameAuthorization); v != "" {
+		sb.WriteString("." + headers.NameAuthorization + "." + v)
+	}
+	keys := make([]string, 0, len(qp))
+	for k := range qp {
+		switch k {
+		case upStart, upEnd, upTZ:
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString("." + k + "." + strings.Join(qp[k], ","))
+	}
+	if body != nil {
+		if b, err := ioutil.ReadAll(body); err == nil {
+			body = ioutil.NopCloser(bytes.NewReader(b))
+			if qb, err := decodeQueryBody(b); err == nil {
+				delete(qb, qbStart)
+				delete(qb, qbEnd)
+				delete(qb, qbTimezone)
+				// maps are marshaled with sorted keys, so equivalent bodies have the same key
+				if b, err = json.Marshal(qb); err == nil {
+					sb.Write(b)
+				}
+			}
+		}
+	}
+	sb.WriteString(extra)
+	return md5.Checksum(sb.String()), body
+}
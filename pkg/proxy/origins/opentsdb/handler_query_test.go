@@ -0,0 +1,168 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestQueryHandler(t *testing.T) {
+
+	ts0 := time.Now().Add(-7 * time.Minute).Truncate(time.Minute).Unix()
+	body := `[{"metric":"a.b","tags":{},"aggregateTags":[],"dps":{"` + strconv.FormatInt(ts0, 10) +
+		`":1,"` + strconv.FormatInt(ts0+60, 10) + `":3}}]`
+
+	client := &Client{name: "test", step: time.Minute}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, body, map[string]string{headers.NameContentType: headers.ValueApplicationJSON},
+		"opentsdb", "/api/query?m=sum:1m-avg:a.b&start=10m-ago&end=5m-ago", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	rsc.PathConfig = client.config.Paths["/"+mnAPI+"/"+mnQuery]
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	client.QueryHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(string(bodyBytes), `"`+strconv.FormatInt(ts0+60, 10)+`":3`) {
+		t.Errorf("expected query datapoints got %s.", bodyBytes)
+	}
+
+	// a wider time range is a partial hit
+	r, _ = http.NewRequest(http.MethodGet,
+		ts.URL+"/api/query?m=sum:1m-avg:a.b&start=15m-ago&end=5m-ago", nil)
+	w = httptest.NewRecorder()
+	r = r.WithContext(ctx)
+
+	client.QueryHandler(w, r)
+	resp = w.Result()
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=phit") {
+		t.Errorf("expected partial hit got %s", v)
+	}
+
+	// query bodies are cached by their sub queries, even without a JSON content type
+	for i, start := range []string{"10m-ago", "15m-ago"} {
+		r, _ = http.NewRequest(http.MethodPost, ts.URL+"/api/query",
+			strings.NewReader(`{"start":"`+start+`","end":"5m-ago","queries":[{"aggregator":"sum",`+
+				`"metric":"a.b","downsample":"1m-avg"}]}`))
+		r.Header.Set(headers.NameContentType, "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		r = r.WithContext(ctx)
+
+		client.QueryHandler(w, r)
+		resp = w.Result()
+		if v := r.Header.Get(headers.NameContentType); v != headers.ValueApplicationJSON {
+			t.Errorf("expected %s got %s", headers.ValueApplicationJSON, v)
+		}
+		if i == 0 {
+			continue
+		}
+		if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=phit") {
+			t.Errorf("expected partial hit got %s", v)
+		}
+	}
+
+	// queries that request summaries are proxied
+	r, _ = http.NewRequest(http.MethodGet,
+		ts.URL+"/api/query?m=sum:1m-avg:a.b&start=10m-ago&show_summary=true", nil)
+	w = httptest.NewRecorder()
+	r = r.WithContext(ctx)
+
+	client.QueryHandler(w, r)
+	resp = w.Result()
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "engine=HTTPProxy") {
+		t.Errorf("expected proxy got %s", v)
+	}
+
+}
+
+func TestQueryHandlerDeriveCacheKey(t *testing.T) {
+
+	client := &Client{name: "test"}
+	const path = "/api/query"
+
+	k1, _ := client.queryHandlerDeriveCacheKey(path, url.Values{"m": {"sum:a", "sum:b"},
+		"start": {"1d-ago"}, "end": {"now"}}, http.Header{}, nil, "")
+	k2, _ := client.queryHandlerDeriveCacheKey(path, url.Values{"m": {"sum:a", "sum:b"},
+		"start": {"7d-ago"}, "tz": {"UTC"}}, http.Header{}, nil, "")
+	if k1 != k2 {
+		t.Errorf("expected %s got %s", k1, k2)
+	}
+
+	k2, _ = client.queryHandlerDeriveCacheKey(path, url.Values{"m": {"sum:b", "sum:a"}},
+		http.Header{}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for differently ordered sub queries")
+	}
+
+	k2, _ = client.queryHandlerDeriveCacheKey(path, url.Values{"m": {"sum:a", "sum:b"}},
+		http.Header{"Authorization": {"Basic dGVzdDp0ZXN0"}}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for different credentials")
+	}
+
+	// equivalent query bodies have the same key, and the body can be read again
+	b1 := `{"start":1577836800,"queries":[{"metric":"a","aggregator":"sum"}]}`
+	k1, body := client.queryHandlerDeriveCacheKey(path, nil, http.Header{},
+		ioutil.NopCloser(strings.NewReader(b1)), "")
+	if b, _ := ioutil.ReadAll(body); string(b) != b1 {
+		t.Errorf("expected %s got %s", b1, string(b))
+	}
+	k2, _ = client.queryHandlerDeriveCacheKey(path, nil, http.Header{},
+		ioutil.NopCloser(strings.NewReader(
+			`{"queries":[{"aggregator":"sum","metric":"a"}],"start":"1h-ago","end":"now"}`)), "")
+	if k1 != k2 {
+		t.Errorf("expected %s got %s", k1, k2)
+	}
+
+	k2, _ = client.queryHandlerDeriveCacheKey(path, nil, http.Header{},
+		ioutil.NopCloser(strings.NewReader(
+			`{"queries":[{"aggregator":"max","metric":"a"}],"start":"1h-ago"}`)), "")
+	if k1 == k2 {
+		t.Error("expected different keys for different query bodies")
+	}
+
+}
@@ -0,0 +1,491 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// SeriesEnvelope values represent a time series data response from the
+// OpenTSDB query API
+type SeriesEnvelope struct {
+	Series       []*Series
+	ExtentList   timeseries.ExtentList
+	StepDuration time.Duration
+}
+
+// Series values represent the datapoints of a single series of a sub query
+type Series struct {
+	Metric            string            `json:"metric"`
+	Tags              map[string]string `json:"tags"`
+	AggregateTags     []string          `json:"aggregateTags"`
+	Query             json.RawMessage   `json:"query,omitempty"`
+	TSUIDs            []string          `json:"tsuids,omitempty"`
+	Annotations       []json.RawMessage `json:"annotations,omitempty"`
+	GlobalAnnotations []json.RawMessage `json:"globalAnnotations,omitempty"`
+	DPS               Points            `json:"dps"`
+}
+
+// Point values represent a single datapoint of a series. The timestamp is in seconds, or
+// in milliseconds when the query requests millisecond resolution, and the value is
+// kept as received, since OpenTSDB may encode it as a number, null or "NaN"
+type Point struct {
+	Timestamp int64
+	Value     json.RawMessage
+}
+
+// Points values represent the datapoints of a series, which are encoded as a
+// JSON object of values keyed by their timestamps (the "dps" map)
+type Points []Point
+
+// envelope is the JSON representation of a SeriesEnvelope when it is written to
+// the cache. Query responses are a list of series, so they are wrapped in an
+// object that includes the extents and step
+type envelope struct {
+	Series       []*Series             `json:"series"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+	StepDuration string                `json:"step,omitempty"`
+}
+
+// MarshalJSON encodes a series envelope value into a JSON byte slice.
+func (se *SeriesEnvelope) MarshalJSON() ([]byte, error) {
+	series := se.Series
+	if series == nil {
+		series = []*Series{}
+	}
+	if len(se.ExtentList) == 0 && se.StepDuration == 0 {
+		return json.Marshal(series)
+	}
+	e := envelope{Series: series, ExtentList: se.ExtentList}
+	if se.StepDuration != 0 {
+		e.StepDuration = se.StepDuration.String()
+	}
+	return json.Marshal(e)
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this series envelope value.
+func (se *SeriesEnvelope) UnmarshalJSON(b []byte) error {
+	se.ExtentList = nil
+	se.StepDuration = 0
+	se.Series = nil
+
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, &se.Series)
+	}
+
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return err
+	}
+	if e.Series == nil {
+		return fmt.Errorf("unable to unmarshal OpenTSDB response: missing series")
+	}
+	se.Series = e.Series
+	se.ExtentList = e.ExtentList
+	if e.StepDuration != "" {
+		d, err := time.ParseDuration(e.StepDuration)
+		if err != nil {
+			return err
+		}
+		se.StepDuration = d
+	}
+	return nil
+}
+
+// MarshalJSON encodes the points into a JSON object keyed by their timestamps,
+// in the order of the points
+func (pts Points) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range pts {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		buf.WriteString(strconv.FormatInt(p.Timestamp, 10))
+		buf.WriteString(`":`)
+		if len(p.Value) == 0 {
+			buf.WriteString("null")
+		} else {
+			buf.Write(p.Value)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON object of values keyed by their timestamps into
+// the points, in the order they were encoded
+func (pts *Points) UnmarshalJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return fmt.Errorf("unable to unmarshal OpenTSDB datapoints: expected object")
+	}
+	out := make(Points, 0, 64)
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal OpenTSDB datapoints: %s", err.Error())
+		}
+		k, _ := t.(string)
+		ts, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal OpenTSDB datapoint timestamp %s", k)
+		}
+		var v json.RawMessage
+		if err = d.Decode(&v); err != nil {
+			return fmt.Errorf("unable to unmarshal OpenTSDB datapoints: %s", err.Error())
+		}
+		out = append(out, Point{Timestamp: ts, Value: v})
+	}
+	*pts = out
+	return nil
+}
+
+// Step returns the step for the Timeseries.
+func (se *SeriesEnvelope) Step() time.Duration {
+	return se.StepDuration
+}
+
+// SetStep sets the step for the Timeseries.
+func (se *SeriesEnvelope) SetStep(step time.Duration) {
+	se.StepDuration = step
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent
+// list.
+func (se *SeriesEnvelope) SetExtents(extents timeseries.ExtentList) {
+	se.ExtentList = extents
+}
+
+// Extents returns the Timeseries's extent list.
+func (se *SeriesEnvelope) Extents() timeseries.ExtentList {
+	return se.ExtentList
+}
+
+// SeriesCount returns the number of individual series in the Timeseries value.
+func (se *SeriesEnvelope) SeriesCount() int {
+	return len(se.Series)
+}
+
+// ValueCount returns the count of all data values across all Series in the
+// Timeseries value.
+func (se *SeriesEnvelope) ValueCount() int {
+	c := 0
+	for _, s := range se.Series {
+		c += len(s.DPS)
+	}
+	return c
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries.
+func (se *SeriesEnvelope) TimestampCount() int {
+	return len(se.timestamps())
+}
+
+func (se *SeriesEnvelope) timestamps() map[int64]struct{} {
+	ts := map[int64]struct{}{}
+	for _, s := range se.Series {
+		for _, p := range s.DPS {
+			ts[p.Timestamp] = struct{}{}
+		}
+	}
+	return ts
+}
+
+// milliseconds returns true if the timestamps of the envelope's datapoints are in
+// milliseconds, which OpenTSDB distinguishes from seconds by their number of digits
+func (se *SeriesEnvelope) milliseconds() bool {
+	for _, s := range se.Series {
+		if len(s.DPS) > 0 {
+			return s.DPS[0].Timestamp > maxSecondsTimestamp
+		}
+	}
+	return false
+}
+
+// timestamp returns the datapoint timestamp of the provided time
+func timestamp(t time.Time, ms bool) int64 {
+	if ms {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return t.Unix()
+}
+
+// timeOf returns the time of the provided datapoint timestamp
+func timeOf(ts int64, ms bool) time.Time {
+	if ms {
+		return time.Unix(0, ts*int64(time.Millisecond))
+	}
+	return time.Unix(ts, 0)
+}
+
+// seriesKeys returns the keys used to match the series of the envelope with
+// those of another. Sub queries can return series of the same metric and tags
+// (e.g., with different aggregators), so the key includes the series'
+// occurrence in the envelope as well
+func (se *SeriesEnvelope) seriesKeys() []string {
+	counts := make(map[string]int, len(se.Series))
+	keys := make([]string, len(se.Series))
+	for i, s := range se.Series {
+		var sb strings.Builder
+		sb.WriteString(s.Metric)
+		tags := make([]string, 0, len(s.Tags))
+		for k, v := range s.Tags {
+			tags = append(tags, k+"="+v)
+		}
+		sort.Strings(tags)
+		sb.WriteString("\x00" + strings.Join(tags, ","))
+		at := append([]string{}, s.AggregateTags...)
+		sort.Strings(at)
+		sb.WriteString("\x00" + strings.Join(at, ","))
+		k := sb.String()
+		keys[i] = k + "\x00" + strconv.Itoa(counts[k])
+		counts[k]++
+	}
+	return keys
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the
+// order provided) and optionally sorts the merged Timeseries. The series are
+// matched by their metric, tags and aggregated tags, and their datapoints are
+// merged by timestamp, with the most recently merged value retained
+func (se *SeriesEnvelope) Merge(sort bool,
+	collection ...timeseries.Timeseries) {
+	sm := make(map[string]*Series, len(se.Series))
+	for i, k := range se.seriesKeys() {
+		sm[k] = se.Series[i]
+	}
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		se2, ok := ts.(*SeriesEnvelope)
+		if !ok {
+			continue
+		}
+		for i, k := range se2.seriesKeys() {
+			s2 := se2.Series[i]
+			s, ok := sm[k]
+			if !ok {
+				s = s2.clone()
+				sm[k] = s
+				se.Series = append(se.Series, s)
+				continue
+			}
+			s.DPS = append(s.DPS, s2.DPS...)
+			s.TSUIDs = mergeStrings(s.TSUIDs, s2.TSUIDs)
+			s.Annotations = mergeRaw(s.Annotations, s2.Annotations)
+			s.GlobalAnnotations = mergeRaw(s.GlobalAnnotations, s2.GlobalAnnotations)
+		}
+		se.ExtentList = append(se.ExtentList, se2.ExtentList...)
+	}
+
+	se.ExtentList = se.ExtentList.Compress(se.StepDuration)
+	if sort {
+		se.Sort()
+	}
+}
+
+// mergeStrings appends the values of b that are not in a
+func mergeStrings(a, b []string) []string {
+	for _, v := range b {
+		found := false
+		for _, v2 := range a {
+			if v == v2 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// mergeRaw appends the JSON values of b that are not in a
+func mergeRaw(a, b []json.RawMessage) []json.RawMessage {
+	for _, v := range b {
+		found := false
+		for _, v2 := range a {
+			if bytes.Equal(v, v2) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// Clone returns a perfect copy of the base Timeseries.
+func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
+	b := &SeriesEnvelope{
+		Series:       make([]*Series, len(se.Series)),
+		ExtentList:   se.ExtentList.Clone(),
+		StepDuration: se.StepDuration,
+	}
+	for i, s := range se.Series {
+		b.Series[i] = s.clone()
+	}
+	return b
+}
+
+func (s *Series) clone() *Series {
+	s2 := *s
+	if s.Tags != nil {
+		s2.Tags = make(map[string]string, len(s.Tags))
+		for k, v := range s.Tags {
+			s2.Tags[k] = v
+		}
+	}
+	s2.AggregateTags = append([]string(nil), s.AggregateTags...)
+	s2.TSUIDs = append([]string(nil), s.TSUIDs...)
+	s2.Annotations = append([]json.RawMessage(nil), s.Annotations...)
+	s2.GlobalAnnotations = append([]json.RawMessage(nil), s.GlobalAnnotations...)
+	s2.DPS = make(Points, len(s.DPS))
+	copy(s2.DPS, s.DPS)
+	return &s2
+}
+
+// CropToRange crops down a Timeseries value to the provided Extent.
+func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
+	ms := se.milliseconds()
+	start, end := timestamp(e.Start, ms), timestamp(e.End, ms)
+	for _, s := range se.Series {
+		pts := make(Points, 0, len(s.DPS))
+		for _, p := range s.DPS {
+			if p.Timestamp >= start && p.Timestamp <= end {
+				pts = append(pts, p)
+			}
+		}
+		s.DPS = pts
+	}
+	se.ExtentList = se.ExtentList.Crop(e)
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided
+// count, by evicting elements using a least-recently-used methodology. Any
+// timestamps newer than the provided time are removed before sizing, in order
+// to support backfill tolerance. The provided extent will be marked as used
+// during crop.
+func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
+	lur timeseries.Extent) {
+	// The Series has no extents or no room for any values, so it is emptied.
+	if len(se.ExtentList) < 1 || sz < 1 {
+		for _, s := range se.Series {
+			s.DPS = Points{}
+		}
+		se.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed.
+	if se.ExtentList[len(se.ExtentList)-1].End.After(t) {
+		se.CropToRange(timeseries.Extent{Start: se.ExtentList[0].Start, End: t})
+	}
+
+	ts := se.timestamps()
+	if len(ts) <= sz {
+		return
+	}
+
+	tsl := make([]int64, 0, len(ts))
+	for k := range ts {
+		tsl = append(tsl, k)
+	}
+	sort.Slice(tsl, func(i, j int) bool { return tsl[i] < tsl[j] })
+	tsl = tsl[len(tsl)-sz:]
+	ms := se.milliseconds()
+	e := timeseries.Extent{Start: timeOf(tsl[0], ms), End: timeOf(tsl[len(tsl)-1], ms)}
+	se.CropToRange(e)
+	se.ExtentList = timeseries.ExtentList{e}
+}
+
+// Sort sorts all data in the Timeseries chronologically by their timestamp,
+// and removes duplicate datapoints by retaining the most recently merged value
+func (se *SeriesEnvelope) Sort() {
+	for _, s := range se.Series {
+		s.sort()
+	}
+}
+
+func (s *Series) sort() {
+	sort.SliceStable(s.DPS, func(i, j int) bool {
+		return s.DPS[i].Timestamp < s.DPS[j].Timestamp
+	})
+	pts := make(Points, 0, len(s.DPS))
+	for i, p := range s.DPS {
+		if i+1 < len(s.DPS) && p.Timestamp == s.DPS[i+1].Timestamp {
+			continue
+		}
+		pts = append(pts, p)
+	}
+	s.DPS = pts
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (se *SeriesEnvelope) Size() int {
+	c := (len(se.ExtentList) * 72) + // time.Time (24) * 3
+		24 + 8 // .StepDuration, .Series
+	for _, s := range se.Series {
+		c += len(s.Metric) + len(s.Query)
+		for k, v := range s.Tags {
+			c += len(k) + len(v)
+		}
+		for _, v := range s.AggregateTags {
+			c += len(v)
+		}
+		for _, v := range s.TSUIDs {
+			c += len(v)
+		}
+		for _, v := range s.Annotations {
+			c += len(v)
+		}
+		for _, v := range s.GlobalAnnotations {
+			c += len(v)
+		}
+		for _, p := range s.DPS {
+			c += 32 + len(p.Value) // timestamp (8) + value (24) + value data
+		}
+	}
+	return c
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob for cache storage.
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries value.
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	se := &SeriesEnvelope{}
+	err := json.Unmarshal(data, se)
+	return se, err
+}
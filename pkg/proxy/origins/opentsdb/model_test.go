@@ -0,0 +1,248 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const testQuery = `[` +
+	`{"metric":"sys.cpu","tags":{"host":"a"},"aggregateTags":[],"dps":{"1577836800":1,"1577836860":null,"1577836920":2.5}},` +
+	`{"metric":"sys.cpu","tags":{},"aggregateTags":["host"],"dps":{"1577836800":"NaN"}}` +
+	`]`
+
+func testEnvelope(t *testing.T, data string) *SeriesEnvelope {
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts.(*SeriesEnvelope)
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	se := testEnvelope(t, testQuery)
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+	if se.Series[0].Tags["host"] != "a" || se.Series[1].AggregateTags[0] != "host" {
+		t.Errorf("unexpected series %v", se.Series)
+	}
+	if se.ValueCount() != 4 || se.TimestampCount() != 3 {
+		t.Errorf("expected %d, %d got %d, %d", 4, 3, se.ValueCount(), se.TimestampCount())
+	}
+	if p := se.Series[0].DPS[1]; string(p.Value) != "null" || p.Timestamp != 1577836860 {
+		t.Errorf("expected null value at %d got %s at %d", 1577836860, p.Value, p.Timestamp)
+	}
+	if se.milliseconds() {
+		t.Error("expected second resolution")
+	}
+
+	se = testEnvelope(t, `[{"metric":"a","dps":{"1577836800500":1}}]`)
+	if !se.milliseconds() {
+		t.Error("expected millisecond resolution")
+	}
+
+	client := &Client{}
+	for _, data := range []string{`[`, `{}`, `{"series":[],"step":"abc"}`,
+		`[{"metric":"a","dps":[]}]`, `[{"metric":"a","dps":{"a":1}}]`,
+		`[{"metric":"a","dps":{"1":}}]`, `[{"metric":"a","dps":{"1":1`} {
+		if _, err := client.UnmarshalTimeseries([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	se := testEnvelope(t, testQuery)
+	b, err := client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testQuery {
+		t.Errorf("expected %s got %s", testQuery, string(b))
+	}
+
+	// the extents and step are retained for the cache
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	b, err = client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se2 := testEnvelope(t, string(b))
+	if se2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, se2.Step())
+	}
+	if se2.Extents().String() != se.Extents().String() {
+		t.Errorf("expected %s got %s", se.Extents(), se2.Extents())
+	}
+	if se2.ValueCount() != 4 {
+		t.Errorf("unexpected series %s", string(b))
+	}
+
+	b, err = client.MarshalTimeseries(&SeriesEnvelope{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `[]` {
+		t.Errorf("expected %s got %s", `[]`, string(b))
+	}
+
+}
+
+func TestMerge(t *testing.T) {
+
+	// cached data for the first two minutes
+	se := testEnvelope(t, testQuery)
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+
+	// the delta for the following minutes, which revises the null datapoint, and
+	// includes a new series and an annotation
+	se2 := testEnvelope(t, `[`+
+		`{"metric":"sys.cpu","tags":{"host":"a"},"aggregateTags":[],"annotations":[{"startTime":1577836980}],`+
+		`"dps":{"1577836860":4,"1577836980":5}},`+
+		`{"metric":"sys.cpu","tags":{"host":"b"},"aggregateTags":[],"dps":{"1577836980":6}}]`)
+	se2.SetStep(time.Minute)
+	se2.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836980, 0), End: time.Unix(1577836980, 0)}})
+
+	se.Merge(true, se2)
+
+	if se.SeriesCount() != 3 {
+		t.Errorf("expected %d got %d", 3, se.SeriesCount())
+	}
+	expected := `{"1577836800":1,"1577836860":4,"1577836920":2.5,"1577836980":5}`
+	if b, _ := se.Series[0].DPS.MarshalJSON(); string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+	if len(se.Series[0].Annotations) != 1 {
+		t.Errorf("expected %d got %d", 1, len(se.Series[0].Annotations))
+	}
+	if len(se.ExtentList) != 1 || !se.ExtentList[0].End.Equal(time.Unix(1577836980, 0)) {
+		t.Errorf("unexpected extents %s", se.ExtentList)
+	}
+
+	// merging the same delta again does not duplicate its annotations or datapoints
+	se.Merge(true, se2)
+	if len(se.Series[0].Annotations) != 1 || se.ValueCount() != 6 {
+		t.Errorf("expected %d, %d got %d, %d", 1, 6, len(se.Series[0].Annotations), se.ValueCount())
+	}
+
+}
+
+func TestSeriesKeys(t *testing.T) {
+	// series of the same metric and tags from different sub queries are matched by occurrence
+	se := testEnvelope(t, `[{"metric":"a","tags":{"x":"1","y":"2"},"aggregateTags":["z"],"dps":{}},`+
+		`{"metric":"a","tags":{"y":"2","x":"1"},"aggregateTags":["z"],"dps":{}}]`)
+	keys := se.seriesKeys()
+	if keys[0] == keys[1] || !strings.HasPrefix(keys[1], strings.TrimSuffix(keys[0], "0")) {
+		t.Errorf("unexpected keys %q", keys)
+	}
+}
+
+func TestCropToRange(t *testing.T) {
+
+	se := testEnvelope(t, testQuery)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	e := timeseries.Extent{Start: time.Unix(1577836860, 0), End: time.Unix(1577836920, 0)}
+	se.CropToRange(e)
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+	if se.ExtentList.String() != (timeseries.ExtentList{e}).String() {
+		t.Errorf("expected %s got %s", timeseries.ExtentList{e}, se.ExtentList)
+	}
+
+	// millisecond timestamps are cropped in milliseconds
+	se = testEnvelope(t, `[{"metric":"a","dps":{"1577836800500":1,"1577836860000":2,"1577836920500":3}}]`)
+	se.CropToRange(timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)})
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Unix(1577836980, 0)
+	se := testEnvelope(t, testQuery)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+
+	se.CropToSize(2, now, timeseries.Extent{})
+	if se.TimestampCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.TimestampCount())
+	}
+	if !se.ExtentList[0].Start.Equal(time.Unix(1577836860, 0)) {
+		t.Errorf("expected %d got %d", 1577836860, se.ExtentList[0].Start.Unix())
+	}
+
+	// datapoints after the backfill tolerance are removed
+	se = testEnvelope(t, testQuery)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	se.CropToSize(10, time.Unix(1577836860, 0), timeseries.Extent{})
+	if se.TimestampCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.TimestampCount())
+	}
+
+	se.CropToSize(0, now, timeseries.Extent{})
+	if se.ValueCount() != 0 || len(se.ExtentList) != 0 {
+		t.Errorf("expected empty timeseries got %d values", se.ValueCount())
+	}
+
+}
+
+func TestClone(t *testing.T) {
+
+	se := testEnvelope(t, testQuery)
+	se.SetStep(time.Minute)
+	se.Series[0].TSUIDs = []string{"000001"}
+	se2 := se.Clone().(*SeriesEnvelope)
+	se2.Series[0].Tags["host"] = "b"
+	se2.Series[0].TSUIDs[0] = "000002"
+	se2.CropToRange(timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577836800, 0)})
+
+	if se.Series[0].Tags["host"] != "a" || se.Series[0].TSUIDs[0] != "000001" {
+		t.Error("expected the clone to be independent of its source")
+	}
+	if se.ValueCount() != 4 || se2.ValueCount() != 2 {
+		t.Errorf("expected %d, %d got %d, %d", 4, 2, se.ValueCount(), se2.ValueCount())
+	}
+	if se2.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, se2.Step())
+	}
+
+}
+
+func TestSize(t *testing.T) {
+	se := testEnvelope(t, testQuery)
+	if se.Size() <= 0 {
+		t.Errorf("expected positive size got %d", se.Size())
+	}
+	if se.Size() >= testEnvelope(t, `[`+strings.TrimPrefix(testQuery, `[`)[:len(testQuery)-2]+`,`+
+		`{"metric":"sys.mem","tags":{},"aggregateTags":[],"dps":{"1577836800":1}}]`).Size() {
+		t.Error("expected larger size for more series")
+	}
+}
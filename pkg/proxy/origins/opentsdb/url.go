@@ -0,0 +1,476 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// OpenTSDB API path segments
+const (
+	mnAPI     = "api"
+	mnQuery   = "query"
+	mnSuggest = "suggest"
+)
+
+// Common OpenTSDB URL Parameter Names
+const (
+	upStart             = "start"
+	upEnd               = "end"
+	upMetric            = "m"
+	upTSUID             = "tsuid"
+	upTZ                = "tz"
+	upArrays            = "arrays"
+	upShowSummary       = "show_summary"
+	upShowStats         = "show_stats"
+	upGlobalAnnotations = "global_annotations"
+	upUseCalendar       = "use_calendar"
+	upType              = "type"
+	upQ                 = "q"
+	upMax               = "max"
+)
+
+// OpenTSDB Query Body Field Names
+const (
+	qbStart             = "start"
+	qbEnd               = "end"
+	qbTimezone          = "timezone"
+	qbQueries           = "queries"
+	qbDownsample        = "downsample"
+	qbRate              = "rate"
+	qbDelete            = "delete"
+	qbArrays            = "arrays"
+	qbShowSummary       = "showSummary"
+	qbShowStats         = "showStats"
+	qbGlobalAnnotations = "globalAnnotations"
+	qbUseCalendar       = "useCalendar"
+)
+
+// OpenTSDB treats absolute timestamps of more than 10 digits as milliseconds
+const maxSecondsTimestamp = 9999999999
+
+// reDownsample matches a downsampling specification (e.g., "1m-avg" or "1h-sum-zero"),
+// capturing its interval, unit and calendar flag
+var reDownsample = regexp.MustCompile(`^(\d+)(ms|s|m|h|d|w|n|y|all)(c?)-[a-z0-9]+(-[a-z]+)?$`)
+
+// subQuery is the part of each of a request's sub queries that determines how it is cached
+type subQuery struct {
+	downsample string
+	rate       bool
+}
+
+// SetExtent will change the upstream request query to use the provided Extent
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	if extent == nil || r == nil {
+		return
+	}
+	var err error
+	if trq == nil {
+		if trq, err = c.ParseTimeRangeQuery(r); err != nil {
+			return
+		}
+	}
+	// the end time is inclusive, and is extended through the step that begins at the
+	// extent's end, so that the downsampling interval at the end of the extent is complete
+	start := extent.Start.UnixNano() / int64(time.Millisecond)
+	end := extent.End.Add(trq.Step).UnixNano()/int64(time.Millisecond) - 1
+
+	if !methods.HasBody(r.Method) {
+		v := r.URL.Query()
+		v.Set(upStart, strconv.FormatInt(start, 10))
+		v.Set(upEnd, strconv.FormatInt(end, 10))
+		r.URL.RawQuery = v.Encode()
+		return
+	}
+
+	// the statement of a query body is the body without its time range, since the
+	// bodies of the requests cloned for each extent share their original reader
+	qb, err := decodeQueryBody([]byte(trq.Statement))
+	if err != nil {
+		return
+	}
+	qb[qbStart] = start
+	qb[qbEnd] = end
+	b, err := json.Marshal(qb)
+	if err != nil {
+		return
+	}
+	r.ContentLength = int64(len(b))
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request.
+// Queries that can't be merged across partial hits, such as those that downsample all of
+// their datapoints into one, or that request the summary or statistics of the query, are
+// not time range queries
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	var start, end, tz, statement string
+	var queries []subQuery
+	var err error
+
+	if methods.HasBody(r.Method) {
+		start, end, tz, statement, queries, err = parseQueryBody(r)
+	} else {
+		start, end, tz, statement, queries, err = parseQueryValues(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: statement,
+		// OpenTSDB aligns downsampled datapoints to multiples
+		// of the downsampling interval since the epoch
+		AlignToEpoch: true,
+	}
+
+	var rate bool
+	for _, q := range queries {
+		if q.downsample != "" {
+			d, err := parseDownsample(q.downsample)
+			if err != nil {
+				return nil, err
+			}
+			trq.Step = lcm(trq.Step, d)
+		}
+		rate = rate || q.rate
+	}
+	if trq.Step == 0 {
+		trq.Step = c.step
+	}
+	// the rate of the first datapoint of a range can't be calculated, so an uncached
+	// extent is fetched with the preceding step, to replace its cached datapoints
+	if rate {
+		trq.ExtentPadding = trq.Step
+	}
+
+	loc := time.UTC
+	if tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse time zone %s: %s", tz, err.Error())
+		}
+		loc = l
+	}
+
+	now := time.Now()
+	if start == "" {
+		return nil, errors.MissingURLParam(upStart)
+	}
+	if trq.Extent.Start, err = parseTime(start, now, loc); err != nil {
+		return nil, err
+	}
+	trq.Extent.End = now
+	if end != "" {
+		if trq.Extent.End, err = parseTime(end, now, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	if !trq.Extent.Start.Before(trq.Extent.End) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	return trq, nil
+}
+
+// parseQueryValues parses the time range, time zone and sub queries of a GET query, whose
+// statement is its metric and tsuid sub queries, in the order they were requested
+func parseQueryValues(r *http.Request) (string, string, string, string, []subQuery, error) {
+	v := r.URL.Query()
+	if len(v[upMetric]) == 0 && len(v[upTSUID]) == 0 {
+		return "", "", "", "", nil, errors.MissingURLParam(upMetric)
+	}
+	for _, p := range []string{upArrays, upShowSummary, upShowStats, upGlobalAnnotations,
+		upUseCalendar} {
+		if isTrue(v.Get(p)) {
+			return "", "", "", "", nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+	queries := make([]subQuery, 0, len(v[upMetric])+len(v[upTSUID]))
+	for _, m := range v[upMetric] {
+		q, err := parseMetricQuery(m)
+		if err != nil {
+			return "", "", "", "", nil, err
+		}
+		queries = append(queries, q)
+	}
+	// tsuid sub queries are an aggregator and a list of tsuids, and aren't downsampled
+	for range v[upTSUID] {
+		queries = append(queries, subQuery{})
+	}
+	statement := strings.Join(append(append([]string{}, v[upMetric]...), v[upTSUID]...), "\n")
+	return v.Get(upStart), v.Get(upEnd), v.Get(upTZ), statement, queries, nil
+}
+
+// parseQueryBody parses the time range, time zone and sub queries of a POST query, whose
+// statement is the query body without its time range
+func parseQueryBody(r *http.Request) (string, string, string, string, []subQuery, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", "", "", "", nil, errors.ParseRequestBody(err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	qb, err := decodeQueryBody(b)
+	if err != nil {
+		return "", "", "", "", nil, errors.ParseRequestBody(err)
+	}
+	for _, f := range []string{qbDelete, qbArrays, qbShowSummary, qbShowStats,
+		qbGlobalAnnotations, qbUseCalendar} {
+		if v, ok := qb[f].(bool); ok && v {
+			return "", "", "", "", nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+
+	qs, ok := qb[qbQueries].([]interface{})
+	if !ok || len(qs) == 0 {
+		return "", "", "", "", nil, errors.MissingRequestParam(qbQueries)
+	}
+	queries := make([]subQuery, len(qs))
+	for i, q := range qs {
+		m, ok := q.(map[string]interface{})
+		if !ok {
+			return "", "", "", "", nil, errors.ParseRequestBody(fmt.Errorf("invalid sub query"))
+		}
+		queries[i].downsample, _ = m[qbDownsample].(string)
+		queries[i].rate, _ = m[qbRate].(bool)
+	}
+
+	start, end := jsonTime(qb[qbStart]), jsonTime(qb[qbEnd])
+	tz, _ := qb[qbTimezone].(string)
+	delete(qb, qbStart)
+	delete(qb, qbEnd)
+	statement, err := json.Marshal(qb)
+	if err != nil {
+		return "", "", "", "", nil, err
+	}
+	return start, end, tz, string(statement), queries, nil
+}
+
+// decodeQueryBody decodes a query body, retaining its numbers as they were written
+func decodeQueryBody(b []byte) (map[string]interface{}, error) {
+	qb := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&qb); err != nil {
+		return nil, err
+	}
+	return qb, nil
+}
+
+// jsonTime returns the string representation of a query body time, which
+// is either a string or a number
+func jsonTime(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	return ""
+}
+
+// parseMetricQuery parses a metric sub query (e.g., "sum:rate:1m-avg:sys.cpu{host=a}"),
+// which is an aggregator, optional rate, downsampling and other options, and the metric
+// and its tags, separated by colons that are not within the braces of the rate options
+// or tags
+func parseMetricQuery(s string) (subQuery, error) {
+	var q subQuery
+	parts := make([]string, 0, 4)
+	var depth, i int
+	for j, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, s[i:j])
+				i = j + 1
+			}
+		}
+	}
+	parts = append(parts, s[i:])
+	if len(parts) < 2 || parts[0] == "" || parts[len(parts)-1] == "" {
+		return q, fmt.Errorf("unable to parse metric query %s", s)
+	}
+	for _, p := range parts[1 : len(parts)-1] {
+		switch {
+		case p == qbRate || strings.HasPrefix(p, qbRate+"{"):
+			q.rate = true
+		case strings.Contains(p, "-"):
+			q.downsample = p
+		}
+	}
+	return q, nil
+}
+
+// parseDownsample returns the interval of a downsampling specification. Specifications
+// whose intervals are not fixed, such as calendar, monthly or yearly intervals, or that
+// downsample the entire range into one datapoint, are not time range queries
+func parseDownsample(s string) (time.Duration, error) {
+	m := reDownsample.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("unable to parse downsample %s", s)
+	}
+	if m[3] != "" {
+		return 0, errors.ErrNotTimeRangeQuery
+	}
+	var u time.Duration
+	switch m[2] {
+	case "ms":
+		u = time.Millisecond
+	case "s":
+		u = time.Second
+	case "m":
+		u = time.Minute
+	case "h":
+		u = time.Hour
+	case "d":
+		u = 24 * time.Hour
+	case "w":
+		u = 7 * 24 * time.Hour
+	default:
+		return 0, errors.ErrNotTimeRangeQuery
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("unable to parse downsample %s", s)
+	}
+	return time.Duration(n) * u, nil
+}
+
+// lcm returns the least common multiple of two durations, where a zero duration is ignored
+func lcm(a, b time.Duration) time.Duration {
+	if a == 0 {
+		return b
+	}
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+// parseTime parses an OpenTSDB time string, which is a Unix epoch timestamp in seconds
+// or milliseconds, an absolute yyyy/MM/dd-HH:mm:ss date in the provided time zone, or
+// a time relative to now (e.g., "1h-ago")
+func parseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "now" {
+		return now, nil
+	}
+
+	if strings.HasSuffix(v, "-ago") {
+		d, err := parseDuration(strings.TrimSuffix(v, "-ago"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+		}
+		return now.Add(-d), nil
+	}
+
+	if i := strings.IndexByte(v, '.'); i > 0 && isDigits(v[:i]) && isDigits(v[i+1:]) {
+		// seconds with a fractional millisecond part
+		ms := (v[i+1:] + "000")[:3]
+		v = v[:i] + ms
+	} else if isDigits(v) && len(v) <= 10 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+		}
+		return time.Unix(n, 0), nil
+	}
+	if isDigits(v) {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || len(v) > 13 {
+			return time.Time{}, fmt.Errorf("unable to parse time %s", s)
+		}
+		return time.Unix(0, n*int64(time.Millisecond)), nil
+	}
+
+	for _, layout := range []string{"2006/01/02-15:04:05", "2006/01/02 15:04:05",
+		"2006/01/02-15:04", "2006/01/02 15:04", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %s", s)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseDuration parses an OpenTSDB relative time duration (e.g., "5m" or "2w"),
+// where months are 30 days and years are 365 days
+func parseDuration(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var u time.Duration
+	switch s[i:] {
+	case "ms":
+		u = time.Millisecond
+	case "s":
+		u = time.Second
+	case "m":
+		u = time.Minute
+	case "h":
+		u = time.Hour
+	case "d":
+		u = 24 * time.Hour
+	case "w":
+		u = 7 * 24 * time.Hour
+	case "n":
+		u = 30 * 24 * time.Hour
+	case "y":
+		u = 365 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid duration unit %s", s[i:])
+	}
+	return time.Duration(n) * u, nil
+}
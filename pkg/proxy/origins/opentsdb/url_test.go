@@ -0,0 +1,346 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{step: time.Minute}
+	r, _ := http.NewRequest(http.MethodGet,
+		"http://0/api/query?m=sum:1m-avg:sys.cpu&start=1h-ago", nil)
+	e := &timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}
+	client.SetExtent(r, nil, e)
+
+	expected := "end=1577840459999&m=sum%3A1m-avg%3Asys.cpu&start=1577836800000"
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+	// nil extents are ignored
+	client.SetExtent(r, nil, nil)
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+	// the body is rebuilt from the statement of the time range query
+	r, _ = http.NewRequest(http.MethodPost, "http://0/api/query",
+		strings.NewReader(`{"start":"1h-ago","queries":[{"aggregator":"sum","metric":"sys.cpu",`+
+			`"downsample":"5m-avg"}],"msResolution":true}`))
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	expected = `{"end":1577840699999,"msResolution":true,"queries":[{"aggregator":"sum",` +
+		`"downsample":"5m-avg","metric":"sys.cpu"}],"start":1577836800000}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+	if r.ContentLength != int64(len(expected)) {
+		t.Errorf("expected %d got %d", len(expected), r.ContentLength)
+	}
+
+	// requests that are not time range queries are unchanged
+	r, _ = http.NewRequest(http.MethodGet, "http://0/api/query?start=1h-ago", nil)
+	client.SetExtent(r, nil, e)
+	if r.URL.RawQuery != "start=1h-ago" {
+		t.Errorf("expected %s got %s", "start=1h-ago", r.URL.RawQuery)
+	}
+
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{step: time.Minute}
+	tests := []struct {
+		query         string
+		start, end    int64
+		step, padding time.Duration
+		err           error
+	}{
+		{"m=sum:sys.cpu&start=1577836800&end=1577923200",
+			1577836800, 1577923200, time.Minute, 0, nil},
+		{"m=sum:15s-avg:sys.cpu&m=max:rate:1m-max-zero:sys.mem{host=a:b}&start=1577836800000&end=1577923200",
+			1577836800, 1577923200, time.Minute, time.Minute, nil},
+		{"m=sum:rate{counter,,1000}:5m-avg:sys.cpu&m=sum:3m-avg:sys.mem&tsuid=sum:000001&start=2020/01/01-00:00&end=2020/01/02",
+			1577836800, 1577923200, 15 * time.Minute, 15 * time.Minute, nil},
+		{"m=sum:sys.cpu&start=2020/01/01-00:00:00&end=2020/01/02%2000:00&tz=America/New_York",
+			1577854800, 1577941200, time.Minute, 0, nil},
+		{"tsuid=sum:000001&start=1577836800.5&end=1577923200", 1577836800, 1577923200, time.Minute, 0, nil},
+		{"m=sum:sys.cpu", 0, 0, 0, 0, errors.MissingURLParam(upStart)},
+		{"start=1h-ago", 0, 0, 0, 0, errors.MissingURLParam(upMetric)},
+		{"m=sum:sys.cpu&start=1h-ago&show_summary=true", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{"m=sum:sys.cpu&start=1h-ago&arrays=true", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{"m=sum:1dc-avg:sys.cpu&start=1h-ago", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{"m=sum:1n-avg:sys.cpu&start=1h-ago", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{"m=sum:0all-avg:sys.cpu&start=1h-ago", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{"m=sum:sys.cpu&start=1577923200&end=1577836800", 0, 0, 0, 0, errors.ErrNotTimeRangeQuery},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://0/api/query?"+test.query, nil)
+		trq, err := client.ParseTimeRangeQuery(r)
+		if test.err != nil {
+			if err == nil || err.Error() != test.err.Error() {
+				t.Errorf("test %d: expected error %v got %v", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		if trq.Extent.Start.Unix() != test.start || trq.Extent.End.Unix() != test.end {
+			t.Errorf("test %d: expected %d-%d got %d-%d", i, test.start, test.end,
+				trq.Extent.Start.Unix(), trq.Extent.End.Unix())
+		}
+		if trq.Step != test.step || trq.ExtentPadding != test.padding {
+			t.Errorf("test %d: expected %s, %s got %s, %s", i, test.step, test.padding,
+				trq.Step, trq.ExtentPadding)
+		}
+		if !trq.AlignToEpoch {
+			t.Errorf("test %d: expected epoch alignment", i)
+		}
+	}
+
+	// the start and end are not part of the statement
+	r1, _ := http.NewRequest(http.MethodGet, "http://0/api/query?m=sum:sys.cpu&start=1h-ago", nil)
+	r2, _ := http.NewRequest(http.MethodGet, "http://0/api/query?start=2h-ago&end=1h-ago&m=sum:sys.cpu", nil)
+	trq1, _ := client.ParseTimeRangeQuery(r1)
+	trq2, _ := client.ParseTimeRangeQuery(r2)
+	if trq1.Statement != trq2.Statement {
+		t.Errorf("expected %s got %s", trq1.Statement, trq2.Statement)
+	}
+
+	_, err := client.ParseTimeRangeQuery(&http.Request{Method: http.MethodGet,
+		URL: &url.URL{RawQuery: "m=sum:sys.cpu&start=1h-ago&tz=invalid/zone"}})
+	if err == nil {
+		t.Error("expected error for invalid time zone")
+	}
+
+}
+
+func TestParseTimeRangeQueryBody(t *testing.T) {
+
+	client := &Client{step: time.Minute}
+	tests := []struct {
+		body       string
+		start, end int64
+		step       time.Duration
+		err        bool
+	}{
+		{`{"start":1577836800,"end":"1577923200","queries":[{"metric":"sys.cpu","downsample":"2m-avg","rate":true}]}`,
+			1577836800, 1577923200, 2 * time.Minute, false},
+		{`{"start":"2020/01/01","end":"2020/01/02","timezone":"UTC","queries":[{"tsuids":["000001"]}]}`,
+			1577836800, 1577923200, time.Minute, false},
+		{`{"start":1577836800,"queries":[{"metric":"sys.cpu"}],"showSummary":true}`, 0, 0, 0, true},
+		{`{"start":1577836800,"queries":[{"metric":"sys.cpu"}],"delete":true}`, 0, 0, 0, true},
+		{`{"start":1577836800,"queries":[]}`, 0, 0, 0, true},
+		{`{"start":1577836800,"queries":["sys.cpu"]}`, 0, 0, 0, true},
+		{`{"queries":[{"metric":"sys.cpu"}]}`, 0, 0, 0, true},
+		{`{"start":1577836800,"queries":[{"metric":"sys.cpu","downsample":"avg"}]}`, 0, 0, 0, true},
+		{`[`, 0, 0, 0, true},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodPost, "http://0/api/query", strings.NewReader(test.body))
+		trq, err := client.ParseTimeRangeQuery(r)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		if trq.Extent.Start.Unix() != test.start || trq.Extent.End.Unix() != test.end {
+			t.Errorf("test %d: expected %d-%d got %d-%d", i, test.start, test.end,
+				trq.Extent.Start.Unix(), trq.Extent.End.Unix())
+		}
+		if trq.Step != test.step {
+			t.Errorf("test %d: expected %s got %s", i, test.step, trq.Step)
+		}
+		if strings.Contains(trq.Statement, `"start"`) || strings.Contains(trq.Statement, `"end"`) {
+			t.Errorf("test %d: unexpected time range in statement %s", i, trq.Statement)
+		}
+		// the body can be read again by the upstream request
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != test.body {
+			t.Errorf("test %d: expected %s got %s", i, test.body, string(b))
+		}
+	}
+
+}
+
+func TestParseMetricQuery(t *testing.T) {
+
+	tests := []struct {
+		query      string
+		downsample string
+		rate       bool
+		expectErr  bool
+	}{
+		{"sum:sys.cpu", "", false, false},
+		{"sum:rate:sys.cpu{host=a}", "", true, false},
+		{"sum:rate{counter,100,0}:1h-avg-nan:sys.cpu{host=a,dc=b}{type=c:d}", "1h-avg-nan", true, false},
+		{"sum:explicitTags:30s-p99:sys.cpu{host=web-01}", "30s-p99", false, false},
+		{"sys.cpu", "", false, true},
+		{"sum:", "", false, true},
+		{":sys.cpu", "", false, true},
+	}
+
+	for i, test := range tests {
+		q, err := parseMetricQuery(test.query)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		if q.downsample != test.downsample || q.rate != test.rate {
+			t.Errorf("test %d: expected %s, %t got %s, %t", i, test.downsample, test.rate,
+				q.downsample, q.rate)
+		}
+	}
+
+}
+
+func TestParseDownsample(t *testing.T) {
+
+	tests := []struct {
+		downsample string
+		expected   time.Duration
+		err        error
+	}{
+		{"500ms-avg", 500 * time.Millisecond, nil},
+		{"30s-sum-zero", 30 * time.Second, nil},
+		{"5m-max", 5 * time.Minute, nil},
+		{"1h-p99", time.Hour, nil},
+		{"1d-count", 24 * time.Hour, nil},
+		{"2w-avg", 14 * 24 * time.Hour, nil},
+		{"1dc-avg", 0, errors.ErrNotTimeRangeQuery},
+		{"1n-avg", 0, errors.ErrNotTimeRangeQuery},
+		{"1y-avg", 0, errors.ErrNotTimeRangeQuery},
+		{"0all-avg", 0, errors.ErrNotTimeRangeQuery},
+		{"0m-avg", 0, nil},
+		{"avg", 0, nil},
+	}
+
+	for i, test := range tests {
+		d, err := parseDownsample(test.downsample)
+		if test.expected == 0 {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			} else if test.err != nil && err != test.err {
+				t.Errorf("test %d: expected error %v got %v", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		if d != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, d)
+		}
+	}
+
+}
+
+func TestLCM(t *testing.T) {
+	tests := []struct {
+		a, b, expected time.Duration
+	}{
+		{0, time.Minute, time.Minute},
+		{time.Minute, time.Minute, time.Minute},
+		{15 * time.Second, time.Minute, time.Minute},
+		{2 * time.Minute, 3 * time.Minute, 6 * time.Minute},
+		{500 * time.Millisecond, 3 * time.Second, 3 * time.Second},
+	}
+	for i, test := range tests {
+		if v := lcm(test.a, test.b); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Unix(1577923200, 0)
+	ny, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		value     string
+		loc       *time.Location
+		expected  int64
+		expectErr bool
+	}{
+		{"now", time.UTC, 1577923200000, false},
+		{"1d-ago", time.UTC, 1577836800000, false},
+		{"30s-ago", time.UTC, 1577923170000, false},
+		{"500ms-ago", time.UTC, 1577923199500, false},
+		{"1w-ago", time.UTC, 1577318400000, false},
+		{"1n-ago", time.UTC, 1575331200000, false},
+		{"1y-ago", time.UTC, 1546387200000, false},
+		{"1577836800", time.UTC, 1577836800000, false},
+		{"1577836800500", time.UTC, 1577836800500, false},
+		{"1577836800.5", time.UTC, 1577836800500, false},
+		{"1577836800.123", time.UTC, 1577836800123, false},
+		{"2020/01/01-00:00:00", time.UTC, 1577836800000, false},
+		{"2020/01/01 00:00", time.UTC, 1577836800000, false},
+		{"2020/01/01", ny, 1577854800000, false},
+		{"-ago", time.UTC, 0, true},
+		{"1x-ago", time.UTC, 0, true},
+		{"15778368000000", time.UTC, 0, true},
+		{"2020-01-01", time.UTC, 0, true},
+		{"yesterday", time.UTC, 0, true},
+	}
+
+	for i, test := range tests {
+		v, err := parseTime(test.value, now, test.loc)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+			continue
+		}
+		if ms := v.UnixNano() / int64(time.Millisecond); ms != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, ms)
+		}
+	}
+
+}
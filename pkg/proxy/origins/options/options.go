@@ -22,11 +22,38 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/evictionmethods"
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
+	qo "github.com/tricksterproxy/trickster/pkg/cache/quota/options"
+	"github.com/tricksterproxy/trickster/pkg/cluster"
 	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	azo "github.com/tricksterproxy/trickster/pkg/proxy/authz/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture"
+	capo "github.com/tricksterproxy/trickster/pkg/proxy/capture/options"
+	dns "github.com/tricksterproxy/trickster/pkg/proxy/dns/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/errorbudget"
+	ebo "github.com/tricksterproxy/trickster/pkg/proxy/errorbudget/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	sh "github.com/tricksterproxy/trickster/pkg/proxy/headers/security/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/heatmap"
+	hmo "github.com/tricksterproxy/trickster/pkg/proxy/heatmap/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest"
+	ipo "github.com/tricksterproxy/trickster/pkg/proxy/ingest/options"
+	gro "github.com/tricksterproxy/trickster/pkg/proxy/origins/graphite/options"
+	oto "github.com/tricksterproxy/trickster/pkg/proxy/origins/opentsdb/options"
+	prometheus "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
 	rule "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
+	vmo "github.com/tricksterproxy/trickster/pkg/proxy/origins/victoriametrics/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	pao "github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
+	rpo "github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention"
+	rto "github.com/tricksterproxy/trickster/pkg/proxy/retention/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/slo"
+	sloo "github.com/tricksterproxy/trickster/pkg/proxy/slo/options"
 	to "github.com/tricksterproxy/trickster/pkg/proxy/tls/options"
+	co "github.com/tricksterproxy/trickster/pkg/util/compress/options"
 
 	"github.com/gorilla/mux"
 )
@@ -55,6 +82,9 @@ type Options struct {
 	CacheName string `toml:"cache_name"`
 	// CacheKeyPrefix defines the cache key prefix the origin will use when writing objects to the cache
 	CacheKeyPrefix string `toml:"cache_key_prefix"`
+	// CacheGeneration is embedded in the origin's derived cache keys. Incrementing it moves the
+	// origin to a new key space, which invalidates all of its cached objects without affecting other origins
+	CacheGeneration int `toml:"cache_generation"`
 	// HealthCheckUpstreamPath provides the URL path for the upstream health check
 	HealthCheckUpstreamPath string `toml:"health_check_upstream_path"`
 	// HealthCheckVerb provides the HTTP verb to use when making an upstream health check
@@ -74,6 +104,26 @@ type Options struct {
 	// number of seconds from being cached this allows propagation of upstream backfill operations
 	// that modify recently-served data
 	BackfillToleranceSecs int64 `toml:"backfill_tolerance_secs"`
+	// GapFill selects how the missing steps between the values of each series are filled in
+	// timeseries responses. Options are 'none', 'null' and 'carry_forward'
+	GapFill string `toml:"gap_fill"`
+	// GapFillMaxSecs is the longest interval in seconds over which a value is carried forward
+	// when GapFill is 'carry_forward'. 0 carries values forward over any interval
+	GapFillMaxSecs int `toml:"gap_fill_max_secs"`
+	// RevisionHeader is the name of a response header in which the origin reports the revision of
+	// its data, or a watermark time at and after which its data was revised (e.g., by a backfill).
+	// When the value changes between fetches, the affected cached timeseries extents are re-fetched
+	RevisionHeader string `toml:"revision_header"`
+	// ExplainHeaderEnabled, when true, answers timeseries requests that include an
+	// X-Trickster-Explain header with a description of how they would be served from the cache
+	ExplainHeaderEnabled bool `toml:"explain_header_enabled"`
+	// RequestCollapsingEnabled, when true, collapses concurrent identical upstream fetches for
+	// timeseries into a single fetch, whose response is shared by each of the requests
+	RequestCollapsingEnabled bool `toml:"request_collapsing_enabled"`
+	// ClockSkewToleranceMS is how far the local clock may be offset from the origin's and from
+	// other Tricksters sharing the cache, before the offset causes premature expirations or
+	// gaps in cached timeseries. It is also the threshold for warning of the offset
+	ClockSkewToleranceMS int `toml:"clock_skew_tolerance_ms"`
 	// PathList is a list of Path Options that control the behavior of the given paths when requested
 	Paths map[string]*po.Options `toml:"paths"`
 	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Origin
@@ -97,15 +147,63 @@ type Options struct {
 	// RuleName provides the name of the rule config to be used by this origin.
 	// This is only effective if the Origin Type is 'rule'
 	RuleName string `toml:"rule_name"`
+	// UpstreamType provides the origin type of the origin behind the upstream Trickster (e.g.,
+	// 'prometheus'). This is only effective if the Origin Type is 'trickster'
+	UpstreamType string `toml:"upstream_type"`
+	// MaxHops is the maximum number of Tricksters a request may pass through before it is
+	// rejected as a proxy loop. This is only effective if the Origin Type is 'trickster'
+	MaxHops int `toml:"max_hops"`
 	// ReqRewriterName is the name of a configured Rewriter that will modify the request prior to
 	// processing by the origin client
 	ReqRewriterName string `toml:"req_rewriter_name"`
 
 	// TLS is the TLS Configuration for the Frontend and Backend
 	TLS *to.Options `toml:"tls"`
+	// DNS is the hostname resolution and caching configuration for upstream connections
+	DNS *dns.Options `toml:"dns"`
+	// ErrorBudget is the configuration for stretching cache TTLs while the origin is degraded
+	ErrorBudget *ebo.Options `toml:"error_budget"`
+	// SLO is the configuration of the origin's service level objectives
+	SLO *sloo.Options `toml:"slo"`
+	// Heatmap is the configuration for sampling the origin's requests for its latency heatmap
+	Heatmap *hmo.Options `toml:"heatmap"`
+	// Capture is the configuration for recording the origin's traffic for later replay
+	Capture *capo.Options `toml:"capture"`
+	// Compression is the configuration for gzip-encoding the origin's responses to clients
+	Compression *co.Options `toml:"compression"`
+	// Retention is the configuration for the maximum age of the origin's cached timeseries data
+	Retention *rto.Options `toml:"retention"`
+	// CacheQuota is the configuration for the origin's namespace within a cache it shares with other origins
+	CacheQuota *qo.Options `toml:"cache_quota"`
+	// Replication is the configuration for replicating the origin's cached timeseries to peer clusters
+	Replication *rpo.Options `toml:"replication"`
+	// Ingest is the configuration for populating the origin's cache with pre-computed timeseries
+	// consumed from a Kafka or MQTT topic
+	Ingest *ipo.Options `toml:"ingest"`
+	// Prometheus provides configurations that are specific to the Prometheus Origin Type
+	Prometheus *prometheus.Options `toml:"prometheus"`
+	// PrometheusAPI is the configuration for serving a Prometheus-compatible query_range API
+	// from an origin whose native query language is not PromQL
+	PrometheusAPI *pao.Options `toml:"prometheus_api"`
+	// VictoriaMetrics provides configurations that are specific to the VictoriaMetrics Origin Type
+	VictoriaMetrics *vmo.Options `toml:"victoriametrics"`
+	// Graphite provides configurations that are specific to the Graphite Origin Type
+	Graphite *gro.Options `toml:"graphite"`
+	// OpenTSDB provides configurations that are specific to the OpenTSDB Origin Type
+	OpenTSDB *oto.Options `toml:"opentsdb"`
 
 	// ForwardedHeaders indicates the class of 'Forwarded' header to attach to upstream requests
 	ForwardedHeaders string `toml:"forwarded_headers"`
+	// UpstreamHeaderAllowList, when not empty, limits the client request headers forwarded to the origin
+	// to only those in the list. Names ending in '*' match by prefix
+	UpstreamHeaderAllowList []string `toml:"upstream_header_allowlist"`
+	// UpstreamHeaderDenyList provides client request headers that are never forwarded to the origin.
+	// Names ending in '*' match by prefix
+	UpstreamHeaderDenyList []string `toml:"upstream_header_denylist"`
+	// SecurityHeadersName is the name of the security headers profile applied to this origin's responses
+	SecurityHeadersName string `toml:"security_headers_name"`
+	// AuthorizerName is the name of the external authorizer that allows or denies requests to this origin
+	AuthorizerName string `toml:"authorizer_name"`
 
 	// IsDefault indicates if this is the d.Default origin for any request not matching a configured route
 	IsDefault bool `toml:"is_default"`
@@ -123,6 +221,17 @@ type Options struct {
 	// expects a multipart response	// this optimizes Trickster to request as few bytes as possible when
 	// fronting origins that only support single range requests
 	DearticulateUpstreamRanges bool `toml:"dearticulate_upstream_ranges"`
+	// PanicRecoveryDisabled, when true, allows a panic while handling a request to reach the
+	// HTTP server, rather than being recovered and answered with a 500 and an error ID
+	PanicRecoveryDisabled bool `toml:"panic_recovery_disabled"`
+	// QuarantinePanicCacheKeys, when true, removes the cache object of a request that panicked,
+	// so that a malformed cached object can not repeatedly fail subsequent requests
+	QuarantinePanicCacheKeys bool `toml:"quarantine_panic_cache_keys"`
+	// FastJSONDecode, when true, decodes timeseries documents of at least FastJSONMinBytes with a
+	// reflection-free decoder, falling back to encoding/json for any document it does not support
+	FastJSONDecode bool `toml:"fast_json_decode"`
+	// FastJSONMinBytes is the minimum size of a timeseries document for it to use the fast decoder
+	FastJSONMinBytes int `toml:"fast_json_min_bytes"`
 
 	// Synthesized Configurations
 	// These configurations are parsed versions of those defined above, and are what Trickster uses internally
@@ -135,6 +244,10 @@ type Options struct {
 	Timeout time.Duration `toml:"-"`
 	// BackfillTolerance is the time.Duration representation of BackfillToleranceSecs
 	BackfillTolerance time.Duration `toml:"-"`
+	// GapFillMax is the time.Duration representation of GapFillMaxSecs
+	GapFillMax time.Duration `toml:"-"`
+	// ClockSkewTolerance is the time.Duration representation of ClockSkewToleranceMS
+	ClockSkewTolerance time.Duration `toml:"-"`
 	// ValueRetention is the time.Duration representation of ValueRetentionSecs
 	ValueRetention time.Duration `toml:"-"`
 	// Scheme is the layer 7 protocol indicator (e.g. 'http'), derived from OriginURL
@@ -145,8 +258,8 @@ type Options struct {
 	// PathPrefix provides any prefix added to the front of the requested path when constructing the upstream
 	// request url, derived from OriginURL
 	PathPrefix string `toml:"-"`
-	// NegativeCache provides a map for the negative cache, with TTLs converted to time.Durations
-	NegativeCache map[int]time.Duration `toml:"-"`
+	// NegativeCache is the negative cache named by NegativeCacheName, with TTLs converted to time.Durations
+	NegativeCache *negative.Cache `toml:"-"`
 	// TimeseriesRetention when subtracted from time.Now() represents the oldest allowable timestamp in a
 	// timeseries when EvictionMethod is 'oldest'
 	TimeseriesRetention time.Duration `toml:"-"`
@@ -162,8 +275,30 @@ type Options struct {
 	MaxTTL time.Duration `toml:"-"`
 	// HTTPClient is the Client used by trickster to communicate with this origin
 	HTTPClient *http.Client `toml:"-"`
+	// Budget tracks the origin's upstream health against its ErrorBudget options
+	Budget *errorbudget.Budget `toml:"-"`
+	// SLOTracker measures the origin's requests against its SLO options
+	SLOTracker *slo.Tracker `toml:"-"`
+	// HeatmapRecorder samples the origin's requests according to its Heatmap options
+	HeatmapRecorder *heatmap.Recorder `toml:"-"`
+	// CaptureRecorder records the origin's traffic according to its Capture options
+	CaptureRecorder *capture.Recorder `toml:"-"`
+	// RetentionTrimmer truncates the origin's cached timeseries according to its Retention options
+	RetentionTrimmer *retention.Trimmer `toml:"-"`
+	// Cluster routes the origin's requests to the cluster peer that owns their cache key
+	Cluster *cluster.Cluster `toml:"-"`
+	// Replicator replicates the origin's cached timeseries according to its Replication options
+	Replicator *replication.Replicator `toml:"-"`
+	// Ingestor consumes the origin's pre-computed timeseries according to its Ingest options
+	Ingestor *ingest.Ingestor `toml:"-"`
 	// CompressableTypes is the map version of CompressableTypeList for fast lookup
 	CompressableTypes map[string]bool `toml:"-"`
+	// UpstreamHeaderFilter is the compiled filter of UpstreamHeaderAllowList and UpstreamHeaderDenyList
+	UpstreamHeaderFilter *headers.Filter `toml:"-"`
+	// SecurityHeaders is the reference to the security headers profile as indicated by SecurityHeadersName
+	SecurityHeaders *sh.Options `toml:"-"`
+	// Authorizer is the reference to the external authorizer options as indicated by AuthorizerName
+	Authorizer *azo.Options `toml:"-"`
 	// RuleOptions is the reference to the Rule Options as indicated by RuleName
 	RuleOptions *rule.Options `toml:"-"`
 	// ReqRewriter is the rewriter handler as indicated by RuleName
@@ -175,22 +310,42 @@ func NewOptions() *Options {
 	return &Options{
 		BackfillTolerance:            d.DefaultBackfillToleranceSecs,
 		BackfillToleranceSecs:        d.DefaultBackfillToleranceSecs,
+		GapFill:                      d.DefaultGapFill,
+		GapFillMaxSecs:               d.DefaultGapFillMaxSecs,
+		GapFillMax:                   time.Duration(d.DefaultGapFillMaxSecs) * time.Second,
 		CacheKeyPrefix:               "",
 		CacheName:                    d.DefaultOriginCacheName,
 		CompressableTypeList:         d.DefaultCompressableTypes(),
+		DNS:                          dns.NewOptions(),
+		ErrorBudget:                  ebo.NewOptions(),
+		SLO:                          sloo.NewOptions(),
+		Heatmap:                      hmo.NewOptions(),
+		Capture:                      capo.NewOptions(),
+		Compression:                  co.NewOptions(),
+		Retention:                    rto.NewOptions(),
+		CacheQuota:                   qo.NewOptions(),
+		Replication:                  rpo.NewOptions(),
+		Ingest:                       ipo.NewOptions(),
+		Prometheus:                   prometheus.NewOptions(),
+		PrometheusAPI:                pao.NewOptions(),
+		VictoriaMetrics:              vmo.NewOptions(),
+		Graphite:                     gro.NewOptions(),
+		OpenTSDB:                     oto.NewOptions(),
 		FastForwardTTL:               d.DefaultFastForwardTTLSecs * time.Second,
 		FastForwardTTLSecs:           d.DefaultFastForwardTTLSecs,
 		ForwardedHeaders:             d.DefaultForwardedHeaders,
 		HealthCheckHeaders:           make(map[string]string),
 		HealthCheckQuery:             d.DefaultHealthCheckQuery,
 		HealthCheckUpstreamPath:      d.DefaultHealthCheckPath,
+		FastJSONMinBytes:             d.DefaultFastJSONMinBytes,
 		HealthCheckVerb:              d.DefaultHealthCheckVerb,
 		KeepAliveTimeoutSecs:         d.DefaultKeepAliveTimeoutSecs,
 		MaxIdleConns:                 d.DefaultMaxIdleConns,
 		MaxObjectSizeBytes:           d.DefaultMaxObjectSizeBytes,
+		MaxHops:                      d.DefaultMaxHops,
 		MaxTTL:                       d.DefaultMaxTTLSecs * time.Second,
 		MaxTTLSecs:                   d.DefaultMaxTTLSecs,
-		NegativeCache:                make(map[int]time.Duration),
+		NegativeCache:                &negative.Cache{TTLs: make(map[int]time.Duration)},
 		NegativeCacheName:            d.DefaultOriginNegativeCacheName,
 		Paths:                        make(map[string]*po.Options),
 		RevalidationFactor:           d.DefaultRevalidationFactor,
@@ -214,12 +369,30 @@ func (oc *Options) Clone() *Options {
 	o.DearticulateUpstreamRanges = oc.DearticulateUpstreamRanges
 	o.BackfillTolerance = oc.BackfillTolerance
 	o.BackfillToleranceSecs = oc.BackfillToleranceSecs
+	o.GapFill = oc.GapFill
+	o.GapFillMaxSecs = oc.GapFillMaxSecs
+	o.GapFillMax = oc.GapFillMax
+	o.RevisionHeader = oc.RevisionHeader
+	o.ExplainHeaderEnabled = oc.ExplainHeaderEnabled
+	o.RequestCollapsingEnabled = oc.RequestCollapsingEnabled
+	o.ClockSkewToleranceMS = oc.ClockSkewToleranceMS
+	o.ClockSkewTolerance = oc.ClockSkewTolerance
 	o.CacheName = oc.CacheName
 	o.CacheKeyPrefix = oc.CacheKeyPrefix
+	o.CacheGeneration = oc.CacheGeneration
 	o.FastForwardDisable = oc.FastForwardDisable
 	o.FastForwardTTL = oc.FastForwardTTL
 	o.FastForwardTTLSecs = oc.FastForwardTTLSecs
 	o.ForwardedHeaders = oc.ForwardedHeaders
+	o.UpstreamHeaderFilter = oc.UpstreamHeaderFilter
+	o.SecurityHeadersName = oc.SecurityHeadersName
+	if oc.SecurityHeaders != nil {
+		o.SecurityHeaders = oc.SecurityHeaders.Clone()
+	}
+	o.AuthorizerName = oc.AuthorizerName
+	if oc.Authorizer != nil {
+		o.Authorizer = oc.Authorizer.Clone()
+	}
 	o.HealthCheckUpstreamPath = oc.HealthCheckUpstreamPath
 	o.HealthCheckVerb = oc.HealthCheckVerb
 	o.HealthCheckQuery = oc.HealthCheckQuery
@@ -232,12 +405,18 @@ func (oc *Options) Clone() *Options {
 	o.MaxTTL = oc.MaxTTL
 	o.MaxObjectSizeBytes = oc.MaxObjectSizeBytes
 	o.MultipartRangesDisabled = oc.MultipartRangesDisabled
+	o.PanicRecoveryDisabled = oc.PanicRecoveryDisabled
+	o.QuarantinePanicCacheKeys = oc.QuarantinePanicCacheKeys
+	o.FastJSONDecode = oc.FastJSONDecode
+	o.FastJSONMinBytes = oc.FastJSONMinBytes
 	o.OriginType = oc.OriginType
 	o.OriginURL = oc.OriginURL
 	o.PathPrefix = oc.PathPrefix
 	o.ReqRewriterName = oc.ReqRewriterName
 	o.RevalidationFactor = oc.RevalidationFactor
 	o.RuleName = oc.RuleName
+	o.UpstreamType = oc.UpstreamType
+	o.MaxHops = oc.MaxHops
 	o.Scheme = oc.Scheme
 	o.Timeout = oc.Timeout
 	o.TimeoutSecs = oc.TimeoutSecs
@@ -266,6 +445,16 @@ func (oc *Options) Clone() *Options {
 		copy(o.CompressableTypeList, oc.CompressableTypeList)
 	}
 
+	if oc.UpstreamHeaderAllowList != nil {
+		o.UpstreamHeaderAllowList = make([]string, len(oc.UpstreamHeaderAllowList))
+		copy(o.UpstreamHeaderAllowList, oc.UpstreamHeaderAllowList)
+	}
+
+	if oc.UpstreamHeaderDenyList != nil {
+		o.UpstreamHeaderDenyList = make([]string, len(oc.UpstreamHeaderDenyList))
+		copy(o.UpstreamHeaderDenyList, oc.UpstreamHeaderDenyList)
+	}
+
 	if oc.CompressableTypes != nil {
 		o.CompressableTypes = make(map[string]bool)
 		for k := range oc.CompressableTypes {
@@ -284,17 +473,63 @@ func (oc *Options) Clone() *Options {
 	}
 
 	o.NegativeCacheName = oc.NegativeCacheName
-	if oc.NegativeCache != nil {
-		m := make(map[int]time.Duration)
-		for c, t := range oc.NegativeCache {
-			m[c] = t
-		}
-		o.NegativeCache = m
-	}
+	o.NegativeCache = oc.NegativeCache.Clone()
 
 	if oc.TLS != nil {
 		o.TLS = oc.TLS.Clone()
 	}
+
+	if oc.DNS != nil {
+		o.DNS = oc.DNS.Clone()
+	}
+
+	if oc.ErrorBudget != nil {
+		o.ErrorBudget = oc.ErrorBudget.Clone()
+	}
+	if oc.SLO != nil {
+		o.SLO = oc.SLO.Clone()
+	}
+	if oc.Heatmap != nil {
+		o.Heatmap = oc.Heatmap.Clone()
+	}
+	if oc.Capture != nil {
+		o.Capture = oc.Capture.Clone()
+	}
+	if oc.Compression != nil {
+		o.Compression = oc.Compression.Clone()
+	}
+	if oc.Retention != nil {
+		o.Retention = oc.Retention.Clone()
+	}
+	if oc.CacheQuota != nil {
+		o.CacheQuota = oc.CacheQuota.Clone()
+	}
+	if oc.Replication != nil {
+		o.Replication = oc.Replication.Clone()
+	}
+	if oc.Ingest != nil {
+		o.Ingest = oc.Ingest.Clone()
+	}
+
+	if oc.PrometheusAPI != nil {
+		o.PrometheusAPI = oc.PrometheusAPI.Clone()
+	}
+
+	if oc.Prometheus != nil {
+		o.Prometheus = oc.Prometheus.Clone()
+	}
+
+	if oc.VictoriaMetrics != nil {
+		o.VictoriaMetrics = oc.VictoriaMetrics.Clone()
+	}
+
+	if oc.Graphite != nil {
+		o.Graphite = oc.Graphite.Clone()
+	}
+
+	if oc.OpenTSDB != nil {
+		o.OpenTSDB = oc.OpenTSDB.Clone()
+	}
 	o.RequireTLS = oc.RequireTLS
 
 	if oc.FastForwardPath != nil {
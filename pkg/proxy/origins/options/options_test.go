@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
 	ro "github.com/tricksterproxy/trickster/pkg/proxy/origins/rule/options"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
 )
@@ -39,7 +40,7 @@ func TestClone(t *testing.T) {
 	o.CompressableTypes = map[string]bool{"test": true}
 	o.HealthCheckHeaders = map[string]string{"test": "test"}
 	o.Paths = map[string]*po.Options{"test": p}
-	o.NegativeCache = map[int]time.Duration{1: 1}
+	o.NegativeCache = &negative.Cache{TTLs: map[int]time.Duration{1: 1}}
 	o.FastForwardPath = p
 	o.RuleOptions = &ro.Options{}
 	o2 := o.Clone()
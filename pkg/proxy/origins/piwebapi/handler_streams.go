@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+)
+
+// StreamsHandler handles requests for stream and streamset data and processes the
+// recorded, interpolated and summary data requests through the delta proxy cache.
+// Requests to other stream endpoints (e.g., value or plot) are proxied by the engine
+// when their time range can't be parsed
+func (c *Client) StreamsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.ProxyHandler(w, r)
+		return
+	}
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	engines.DeltaProxyCacheRequest(w, r)
+}
+
+// streamsHandlerDeriveCacheKey calculates a cache key from the path, the
+// credentials and all of the query parameters other than the time range,
+// including each of the webIds of a streamset request
+func (c *Client) streamsHandlerDeriveCacheKey(path string, params url.Values,
+	h http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(path))
+	if v := h.Get(headers.NameAuthorization); v != "" {
+		sb.WriteString("." + headers.NameAuthorization + "." + v)
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if strings.EqualFold(k, upStartTime) || strings.EqualFold(k, upEndTime) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString("." + strings.ToLower(k) + "." + strings.Join(params[k], ","))
+	}
+	sb.WriteString(extra)
+	return md5.Checksum(sb.String()), body
+}
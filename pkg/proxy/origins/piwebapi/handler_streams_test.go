@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const testRecorded = `{"Links":{},"Items":[` +
+	`{"Timestamp":"2020-01-01T00:00:00Z","Value":1.5,"UnitsAbbreviation":"m","Good":true},` +
+	`{"Timestamp":"2020-01-01T00:30:00Z","Value":2.5,"UnitsAbbreviation":"m","Good":true}` +
+	`],"UnitsAbbreviation":"m"}`
+
+func TestStreamsHandler(t *testing.T) {
+
+	body := `{"Links":{},"Items":[{"Timestamp":"` +
+		time.Now().Add(-7*time.Minute).UTC().Format(time.RFC3339) +
+		`","Value":2.5,"UnitsAbbreviation":"m","Good":true}],"UnitsAbbreviation":"m"}`
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs,
+		200, body, nil, "piwebapi", "/streams/F1AbEtest/recorded"+
+			"?startTime=*-10m&endTime=*-5m", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL)
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	client.StreamsHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(string(bodyBytes), `"Value":2.5`) {
+		t.Errorf("expected recorded values got %s.", bodyBytes)
+	}
+
+	// a wider time range is a partial hit
+	r, _ = http.NewRequest(http.MethodGet, ts.URL+"/streams/F1AbEtest/recorded"+
+		"?startTime=*-15m&endTime=*-5m", nil)
+	w = httptest.NewRecorder()
+	r = r.WithContext(ctx)
+
+	client.StreamsHandler(w, r)
+	resp = w.Result()
+	if v := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(v, "status=phit") {
+		t.Errorf("expected partial hit got %s", v)
+	}
+
+	// writes are proxied
+	r, _ = http.NewRequest(http.MethodPost, ts.URL+"/streams/F1AbEtest/value", nil)
+	w = httptest.NewRecorder()
+	r = r.WithContext(ctx)
+
+	client.StreamsHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+}
+
+func TestStreamsHandlerDeriveCacheKey(t *testing.T) {
+
+	client := &Client{name: "test"}
+	const path = "/streamsets/recorded"
+
+	k1, _ := client.streamsHandlerDeriveCacheKey(path, url.Values{"webId": {"a", "b"},
+		"startTime": {"*-1d"}, "endTime": {"*"}}, http.Header{}, nil, "")
+	k2, _ := client.streamsHandlerDeriveCacheKey(path, url.Values{"webId": {"a", "b"},
+		"starttime": {"*-30d"}}, http.Header{}, nil, "")
+	if k1 != k2 {
+		t.Errorf("expected %s got %s", k1, k2)
+	}
+
+	k2, _ = client.streamsHandlerDeriveCacheKey(path, url.Values{"webId": {"a", "c"}},
+		http.Header{}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for different webIds")
+	}
+
+	k2, _ = client.streamsHandlerDeriveCacheKey(path, url.Values{"webId": {"a", "b"}},
+		http.Header{"Authorization": {"Basic dGVzdDp0ZXN0"}}, nil, "")
+	if k1 == k2 {
+		t.Error("expected different keys for different credentials")
+	}
+
+}
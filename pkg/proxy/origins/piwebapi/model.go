@@ -0,0 +1,400 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// SeriesEnvelope values represent a time series data response from the
+// PI Web API, for either a single stream or a streamset
+type SeriesEnvelope struct {
+	Links        json.RawMessage
+	Series       []*Series
+	StreamSet    bool
+	ExtentList   timeseries.ExtentList
+	StepDuration time.Duration
+}
+
+// Series values represent the data of a single PI Web API stream
+type Series struct {
+	WebID             string          `json:"WebId,omitempty"`
+	Name              string          `json:"Name,omitempty"`
+	Path              string          `json:"Path,omitempty"`
+	Links             json.RawMessage `json:"Links,omitempty"`
+	Points            Points          `json:"Items"`
+	UnitsAbbreviation string          `json:"UnitsAbbreviation,omitempty"`
+}
+
+// Point values represent a single recorded, interpolated or summary value of a
+// stream. The value is kept as received, so that every field the PI Web API
+// returns (e.g., Good, Questionable or UnitsAbbreviation) is cached unchanged
+type Point struct {
+	Time time.Time
+	// Type is the summary type of summary values, and empty for all others
+	Type string
+	raw  json.RawMessage
+}
+
+// Points values represent a slice of Point values
+type Points []Point
+
+// envelope is the JSON representation of a SeriesEnvelope, which includes the
+// extents and step when the SeriesEnvelope is written to the cache
+type envelope struct {
+	Links             json.RawMessage       `json:"Links,omitempty"`
+	Items             interface{}           `json:"Items"`
+	UnitsAbbreviation string                `json:"UnitsAbbreviation,omitempty"`
+	ExtentList        timeseries.ExtentList `json:"extents,omitempty"`
+	StepDuration      string                `json:"step,omitempty"`
+}
+
+// MarshalJSON encodes a series envelope value into a JSON byte slice.
+func (se *SeriesEnvelope) MarshalJSON() ([]byte, error) {
+	e := envelope{Links: se.Links, ExtentList: se.ExtentList}
+	if se.StepDuration != 0 {
+		e.StepDuration = se.StepDuration.String()
+	}
+	switch {
+	case se.StreamSet:
+		e.Items = se.Series
+	case len(se.Series) > 0:
+		e.Items = se.Series[0].Points
+		e.UnitsAbbreviation = se.Series[0].UnitsAbbreviation
+	default:
+		e.Items = Points{}
+	}
+	return json.Marshal(e)
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this series envelope value.
+func (se *SeriesEnvelope) UnmarshalJSON(b []byte) error {
+	var e struct {
+		Links             json.RawMessage       `json:"Links"`
+		Items             json.RawMessage       `json:"Items"`
+		UnitsAbbreviation string                `json:"UnitsAbbreviation"`
+		ExtentList        timeseries.ExtentList `json:"extents"`
+		StepDuration      string                `json:"step"`
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return err
+	}
+
+	se.Links = e.Links
+	se.ExtentList = e.ExtentList
+	se.StepDuration = 0
+	if e.StepDuration != "" {
+		d, err := time.ParseDuration(e.StepDuration)
+		if err != nil {
+			return err
+		}
+		se.StepDuration = d
+	}
+
+	if len(e.Items) == 0 {
+		return fmt.Errorf("unable to unmarshal PI Web API response: missing Items")
+	}
+
+	// the Items of a streamset are the streams, each having their own Items
+	var probe []struct {
+		Items json.RawMessage `json:"Items"`
+	}
+	if err := json.Unmarshal(e.Items, &probe); err != nil {
+		return err
+	}
+	se.StreamSet = len(probe) > 0 && len(probe[0].Items) > 0
+	if se.StreamSet {
+		se.Series = nil
+		return json.Unmarshal(e.Items, &se.Series)
+	}
+
+	s := &Series{UnitsAbbreviation: e.UnitsAbbreviation}
+	se.Series = []*Series{s}
+	return json.Unmarshal(e.Items, &s.Points)
+}
+
+// MarshalJSON encodes a point value into a JSON byte slice.
+func (p Point) MarshalJSON() ([]byte, error) {
+	if len(p.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return p.raw, nil
+}
+
+// UnmarshalJSON decodes a JSON byte slice into this point value.
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Type      string          `json:"Type"`
+		Timestamp string          `json:"Timestamp"`
+		Value     json.RawMessage `json:"Value"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	// summary values nest the timestamped value under the summary type
+	if v.Type != "" {
+		var sv struct {
+			Timestamp string `json:"Timestamp"`
+		}
+		if err := json.Unmarshal(v.Value, &sv); err != nil {
+			return err
+		}
+		v.Timestamp = sv.Timestamp
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, v.Timestamp)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal PI Web API value: %s", err.Error())
+	}
+
+	p.Time = t
+	p.Type = v.Type
+	p.raw = append(json.RawMessage{}, b...)
+	return nil
+}
+
+// Step returns the step for the Timeseries.
+func (se *SeriesEnvelope) Step() time.Duration {
+	return se.StepDuration
+}
+
+// SetStep sets the step for the Timeseries.
+func (se *SeriesEnvelope) SetStep(step time.Duration) {
+	se.StepDuration = step
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent
+// list.
+func (se *SeriesEnvelope) SetExtents(extents timeseries.ExtentList) {
+	se.ExtentList = extents
+}
+
+// Extents returns the Timeseries's extent list.
+func (se *SeriesEnvelope) Extents() timeseries.ExtentList {
+	return se.ExtentList
+}
+
+// SeriesCount returns the number of individual series in the Timeseries value.
+func (se *SeriesEnvelope) SeriesCount() int {
+	return len(se.Series)
+}
+
+// ValueCount returns the count of all data values across all Series in the
+// Timeseries value.
+func (se *SeriesEnvelope) ValueCount() int {
+	c := 0
+	for _, s := range se.Series {
+		c += len(s.Points)
+	}
+	return c
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries.
+func (se *SeriesEnvelope) TimestampCount() int {
+	return len(se.timestamps())
+}
+
+func (se *SeriesEnvelope) timestamps() map[int64]struct{} {
+	ts := map[int64]struct{}{}
+	for _, s := range se.Series {
+		for _, p := range s.Points {
+			ts[p.Time.UnixNano()] = struct{}{}
+		}
+	}
+	return ts
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the
+// order provided) and optionally sorts the merged Timeseries. The streams of
+// a streamset are matched by their WebId.
+func (se *SeriesEnvelope) Merge(sort bool,
+	collection ...timeseries.Timeseries) {
+	wm := make(map[string]*Series, len(se.Series))
+	for _, s := range se.Series {
+		wm[s.WebID] = s
+	}
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		se2, ok := ts.(*SeriesEnvelope)
+		if !ok {
+			continue
+		}
+		for _, s2 := range se2.Series {
+			s, ok := wm[s2.WebID]
+			if !ok {
+				s = s2.clone()
+				wm[s.WebID] = s
+				se.Series = append(se.Series, s)
+				continue
+			}
+			s.Points = append(s.Points, s2.Points...)
+			if len(s2.Links) > 0 {
+				s.Links = s2.Links
+			}
+			if s2.UnitsAbbreviation != "" {
+				s.UnitsAbbreviation = s2.UnitsAbbreviation
+			}
+		}
+		se.StreamSet = se.StreamSet || se2.StreamSet
+		se.ExtentList = append(se.ExtentList, se2.ExtentList...)
+	}
+
+	se.ExtentList = se.ExtentList.Compress(se.StepDuration)
+	if sort {
+		se.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries.
+func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
+	b := &SeriesEnvelope{
+		Links:        se.Links,
+		Series:       make([]*Series, len(se.Series)),
+		StreamSet:    se.StreamSet,
+		ExtentList:   se.ExtentList.Clone(),
+		StepDuration: se.StepDuration,
+	}
+	for i, s := range se.Series {
+		b.Series[i] = s.clone()
+	}
+	return b
+}
+
+func (s *Series) clone() *Series {
+	s2 := *s
+	s2.Points = make(Points, len(s.Points))
+	copy(s2.Points, s.Points)
+	return &s2
+}
+
+// CropToRange crops down a Timeseries value to the provided Extent.
+func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
+	for _, s := range se.Series {
+		pts := make(Points, 0, len(s.Points))
+		for _, p := range s.Points {
+			if !p.Time.Before(e.Start) && !p.Time.After(e.End) {
+				pts = append(pts, p)
+			}
+		}
+		s.Points = pts
+	}
+	se.ExtentList = se.ExtentList.Crop(e)
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided
+// count, by evicting elements using a least-recently-used methodology. Any
+// timestamps newer than the provided time are removed before sizing, in order
+// to support backfill tolerance. The provided extent will be marked as used
+// during crop.
+func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
+	lur timeseries.Extent) {
+	// The Series has no extents or no room for any values, so it is emptied.
+	if len(se.ExtentList) < 1 || sz < 1 {
+		for _, s := range se.Series {
+			s.Points = Points{}
+		}
+		se.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed.
+	if se.ExtentList[len(se.ExtentList)-1].End.After(t) {
+		se.CropToRange(timeseries.Extent{Start: se.ExtentList[0].Start, End: t})
+	}
+
+	ts := se.timestamps()
+	if len(ts) <= sz {
+		return
+	}
+
+	tsl := make([]int64, 0, len(ts))
+	for k := range ts {
+		tsl = append(tsl, k)
+	}
+	sort.Slice(tsl, func(i, j int) bool { return tsl[i] < tsl[j] })
+	tsl = tsl[len(tsl)-sz:]
+	e := timeseries.Extent{Start: time.Unix(0, tsl[0]), End: time.Unix(0, tsl[len(tsl)-1])}
+	se.CropToRange(e)
+	se.ExtentList = timeseries.ExtentList{e}
+}
+
+// Sort sorts all data in the Timeseries chronologically by their timestamp,
+// grouping summary values by their type, and removes duplicate values by
+// retaining the most recently merged value
+func (se *SeriesEnvelope) Sort() {
+	for _, s := range se.Series {
+		s.sort()
+	}
+}
+
+func (s *Series) sort() {
+	rank := map[string]int{}
+	for _, p := range s.Points {
+		if _, ok := rank[p.Type]; !ok {
+			rank[p.Type] = len(rank)
+		}
+	}
+	sort.SliceStable(s.Points, func(i, j int) bool {
+		if s.Points[i].Type != s.Points[j].Type {
+			return rank[s.Points[i].Type] < rank[s.Points[j].Type]
+		}
+		return s.Points[i].Time.Before(s.Points[j].Time)
+	})
+	pts := make(Points, 0, len(s.Points))
+	for i, p := range s.Points {
+		if i+1 < len(s.Points) && p.Type == s.Points[i+1].Type &&
+			p.Time.Equal(s.Points[i+1].Time) {
+			continue
+		}
+		pts = append(pts, p)
+	}
+	s.Points = pts
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (se *SeriesEnvelope) Size() int {
+	c := len(se.Links) +
+		(len(se.ExtentList) * 72) + // time.Time (24) * 3
+		24 + 8 // .StepDuration, .Series
+	for _, s := range se.Series {
+		c += len(s.WebID) + len(s.Name) + len(s.Path) + len(s.Links) + len(s.UnitsAbbreviation)
+		for _, p := range s.Points {
+			c += 24 + len(p.Type) + len(p.raw) // time.Time (24) + type + value
+		}
+	}
+	return c
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob for cache storage.
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries value.
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	se := &SeriesEnvelope{}
+	err := json.Unmarshal(data, se)
+	return se, err
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const testStreamSet = `{"Links":{},"Items":[` +
+	`{"WebId":"a","Name":"flow","Path":"\\\\pi\\flow","Items":[` +
+	`{"Timestamp":"2020-01-01T00:00:00Z","Value":1,"Good":true},` +
+	`{"Timestamp":"2020-01-01T01:00:00Z","Value":2,"Good":true}],"UnitsAbbreviation":"m3"},` +
+	`{"WebId":"b","Name":"state","Items":[` +
+	`{"Timestamp":"2020-01-01T00:00:00Z","Value":{"Name":"On","Value":1,"IsSystem":false},"Good":true}]}` +
+	`]}`
+
+const testSummary = `{"Links":{},"Items":[` +
+	`{"Type":"Average","Value":{"Timestamp":"2020-01-01T00:00:00Z","Value":1.5,"Good":true}},` +
+	`{"Type":"Average","Value":{"Timestamp":"2020-01-02T00:00:00Z","Value":2.5,"Good":true}},` +
+	`{"Type":"Maximum","Value":{"Timestamp":"2020-01-01T00:00:00Z","Value":3,"Good":true}},` +
+	`{"Type":"Maximum","Value":{"Timestamp":"2020-01-02T00:00:00Z","Value":4,"Good":true}}` +
+	`]}`
+
+func testEnvelope(t *testing.T, data string) *SeriesEnvelope {
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts.(*SeriesEnvelope)
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	se := testEnvelope(t, testRecorded)
+	if se.StreamSet {
+		t.Error("expected stream")
+	}
+	if se.SeriesCount() != 1 {
+		t.Errorf("expected %d got %d", 1, se.SeriesCount())
+	}
+	if se.Series[0].UnitsAbbreviation != "m" {
+		t.Errorf("expected %s got %s", "m", se.Series[0].UnitsAbbreviation)
+	}
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+
+	se = testEnvelope(t, testStreamSet)
+	if !se.StreamSet {
+		t.Error("expected streamset")
+	}
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+	if se.Series[0].WebID != "a" || se.Series[1].Name != "state" {
+		t.Errorf("unexpected series %v", se.Series)
+	}
+	if se.ValueCount() != 3 || se.TimestampCount() != 2 {
+		t.Errorf("expected %d, %d got %d, %d", 3, 2, se.ValueCount(), se.TimestampCount())
+	}
+
+	se = testEnvelope(t, testSummary)
+	if se.Series[0].Points[2].Type != "Maximum" {
+		t.Errorf("expected %s got %s", "Maximum", se.Series[0].Points[2].Type)
+	}
+	if se.Series[0].Points[3].Time.Unix() != 1577923200 {
+		t.Errorf("expected %d got %d", 1577923200, se.Series[0].Points[3].Time.Unix())
+	}
+
+	client := &Client{}
+	for _, data := range []string{`{`, `{"Links":{}}`, `{"Items":{}}`,
+		`{"Items":[{"Timestamp":"abc"}]}`, `{"Items":[{"Type":"Average","Value":1}]}`,
+		`{"Items":[],"step":"abc"}`} {
+		if _, err := client.UnmarshalTimeseries([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	for _, data := range []string{testRecorded, testStreamSet, testSummary} {
+		se := testEnvelope(t, data)
+		b, err := client.MarshalTimeseries(se)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != data {
+			t.Errorf("expected %s got %s", data, string(b))
+		}
+	}
+
+	// the extents and step are retained for the cache
+	se := testEnvelope(t, testStreamSet)
+	se.SetStep(time.Hour)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+	b, err := client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se2 := testEnvelope(t, string(b))
+	if se2.Step() != time.Hour {
+		t.Errorf("expected %s got %s", time.Hour, se2.Step())
+	}
+	if se2.Extents().String() != se.Extents().String() {
+		t.Errorf("expected %s got %s", se.Extents(), se2.Extents())
+	}
+	if !se2.StreamSet || se2.ValueCount() != 3 {
+		t.Errorf("unexpected streamset %s", string(b))
+	}
+
+	b, err = client.MarshalTimeseries(&SeriesEnvelope{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Items":[]}` {
+		t.Errorf("expected %s got %s", `{"Items":[]}`, string(b))
+	}
+
+}
+
+func TestMerge(t *testing.T) {
+
+	// cached data for the first hour of a streamset
+	se := testEnvelope(t, testStreamSet)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+
+	// the delta for the second hour, which includes a new stream and an updated value
+	se2 := testEnvelope(t, `{"Items":[`+
+		`{"WebId":"a","Items":[`+
+		`{"Timestamp":"2020-01-01T01:00:00Z","Value":5,"Good":true},`+
+		`{"Timestamp":"2020-01-01T02:00:00Z","Value":3,"Good":true}],"UnitsAbbreviation":"m3"},`+
+		`{"WebId":"c","Items":[{"Timestamp":"2020-01-01T02:00:00Z","Value":7}]}]}`)
+	se2.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577840400, 0), End: time.Unix(1577844000, 0)}})
+
+	se.Merge(true, se2, nil)
+
+	if se.SeriesCount() != 3 {
+		t.Errorf("expected %d got %d", 3, se.SeriesCount())
+	}
+	if len(se.Series[0].Points) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(se.Series[0].Points))
+	}
+	if !strings.Contains(string(se.Series[0].Points[1].raw), `"Value":5`) {
+		t.Errorf("expected merged value to be retained, got %s", se.Series[0].Points[1].raw)
+	}
+	expected := timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577844000, 0)}}
+	if se.Extents().String() != expected.String() {
+		t.Errorf("expected %s got %s", expected, se.Extents())
+	}
+
+}
+
+func TestSort(t *testing.T) {
+
+	se := testEnvelope(t, `{"Items":[`+
+		`{"Type":"Maximum","Value":{"Timestamp":"2020-01-02T00:00:00Z","Value":4}},`+
+		`{"Type":"Average","Value":{"Timestamp":"2020-01-02T00:00:00Z","Value":2.5}},`+
+		`{"Type":"Maximum","Value":{"Timestamp":"2020-01-01T00:00:00Z","Value":3}},`+
+		`{"Type":"Average","Value":{"Timestamp":"2020-01-01T00:00:00Z","Value":1.5}},`+
+		`{"Type":"Maximum","Value":{"Timestamp":"2020-01-01T00:00:00Z","Value":3}}`+
+		`]}`)
+	se.Sort()
+
+	pts := se.Series[0].Points
+	if len(pts) != 4 {
+		t.Fatalf("expected %d got %d", 4, len(pts))
+	}
+	if pts[0].Type != "Maximum" || pts[1].Type != "Maximum" || pts[2].Type != "Average" {
+		t.Errorf("expected values to be grouped by type")
+	}
+	if !pts[0].Time.Before(pts[1].Time) || !pts[2].Time.Before(pts[3].Time) {
+		t.Errorf("expected values to be sorted by time")
+	}
+
+}
+
+func TestClone(t *testing.T) {
+
+	se := testEnvelope(t, testStreamSet)
+	se.SetStep(time.Hour)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+	se2 := se.Clone().(*SeriesEnvelope)
+
+	if se2.SeriesCount() != se.SeriesCount() || se2.ValueCount() != se.ValueCount() ||
+		se2.Step() != se.Step() || se2.Extents().String() != se.Extents().String() || !se2.StreamSet {
+		t.Error("expected clone to match")
+	}
+
+	se2.Series[0].Points = se2.Series[0].Points[:1]
+	se2.Series[1].Name = "changed"
+	if len(se.Series[0].Points) != 2 || se.Series[1].Name != "state" {
+		t.Error("expected clone to be independent")
+	}
+
+}
+
+func TestCropToRange(t *testing.T) {
+
+	se := testEnvelope(t, testStreamSet)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+	e := timeseries.Extent{Start: time.Unix(1577840400, 0), End: time.Unix(1577844000, 0)}
+	se.CropToRange(e)
+
+	if se.ValueCount() != 1 {
+		t.Errorf("expected %d got %d", 1, se.ValueCount())
+	}
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+	if len(se.Series[1].Points) != 0 {
+		t.Errorf("expected %d got %d", 0, len(se.Series[1].Points))
+	}
+
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Unix(1577844000, 0)
+	se := testEnvelope(t, testStreamSet)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+
+	se.CropToSize(2, now, timeseries.Extent{})
+	if se.ValueCount() != 3 {
+		t.Errorf("expected %d got %d", 3, se.ValueCount())
+	}
+
+	se.CropToSize(1, now, timeseries.Extent{})
+	if se.ValueCount() != 1 || se.TimestampCount() != 1 {
+		t.Errorf("expected %d got %d", 1, se.ValueCount())
+	}
+	expected := timeseries.ExtentList{{Start: time.Unix(1577840400, 0), End: time.Unix(1577840400, 0)}}
+	if se.Extents().String() != expected.String() {
+		t.Errorf("expected %s got %s", expected, se.Extents())
+	}
+
+	// values newer than the backfill tolerance are removed
+	se = testEnvelope(t, testStreamSet)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}})
+	se.CropToSize(10, time.Unix(1577836800, 0), timeseries.Extent{})
+	if se.ValueCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.ValueCount())
+	}
+
+	se.CropToSize(0, now, timeseries.Extent{})
+	if se.ValueCount() != 0 || len(se.Extents()) != 0 {
+		t.Errorf("expected empty timeseries got %d values", se.ValueCount())
+	}
+
+}
+
+func TestSize(t *testing.T) {
+
+	se := testEnvelope(t, testRecorded)
+	if se.Size() <= len(testRecorded) {
+		t.Errorf("expected size greater than %d got %d", len(testRecorded), se.Size())
+	}
+
+}
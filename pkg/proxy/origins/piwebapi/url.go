@@ -0,0 +1,272 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// PI Web API path segments
+const (
+	mnStreams      = "streams"
+	mnStreamSets   = "streamsets"
+	epRecorded     = "recorded"
+	epInterpolated = "interpolated"
+	epSummary      = "summary"
+)
+
+// Common PI Web API URL Parameter Names
+const (
+	upStartTime       = "startTime"
+	upEndTime         = "endTime"
+	upInterval        = "interval"
+	upSummaryDuration = "summaryDuration"
+)
+
+// PI Web API defaults for omitted stream data parameters
+const (
+	defaultStartTime = "*-1d"
+	defaultEndTime   = "*"
+	defaultInterval  = time.Hour
+	// recordedStep is the nominal step of recorded data, which has no interval,
+	// and is used by the delta proxy cache to align and retain the cached range
+	recordedStep = time.Second
+)
+
+var durationUnits = map[string]time.Duration{
+	"s":       time.Second,
+	"sec":     time.Second,
+	"second":  time.Second,
+	"seconds": time.Second,
+	"m":       time.Minute,
+	"min":     time.Minute,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"h":       time.Hour,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"d":       24 * time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"w":       7 * 24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+// SetExtent will change the upstream request query to use the provided Extent
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if extent == nil || r == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	setParam(q, upStartTime, formatTime(extent.Start))
+	setParam(q, upEndTime, formatTime(extent.End))
+	r.URL.RawQuery = q.Encode()
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request.
+// Only the recorded, interpolated and summary (with a summaryDuration) endpoints of streams
+// and streamsets return data that can be merged across partial hits.
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	if r.Method != http.MethodGet {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	q := r.URL.Query()
+	trq := &timeseries.TimeRangeQuery{Statement: r.URL.Path}
+
+	switch streamEndpoint(r.URL.Path) {
+	case epRecorded:
+		trq.Step = recordedStep
+	case epInterpolated:
+		trq.Step = defaultInterval
+		if v := getParam(q, upInterval); v != "" {
+			d, err := parseDuration(v)
+			if err != nil {
+				return nil, err
+			}
+			trq.Step = d
+		}
+	case epSummary:
+		// without a summaryDuration, the summary is calculated over the whole requested
+		// range and can't be merged with the summaries of other ranges
+		v := getParam(q, upSummaryDuration)
+		if v == "" {
+			return nil, errors.MissingURLParam(upSummaryDuration)
+		}
+		d, err := parseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		trq.Step = d
+	default:
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	if trq.Step < 0 {
+		return nil, errors.ErrStepParse
+	}
+
+	now := time.Now()
+	var err error
+
+	v := getParam(q, upStartTime)
+	if v == "" {
+		v = defaultStartTime
+	}
+	if trq.Extent.Start, err = parseTime(v, now); err != nil {
+		return nil, err
+	}
+
+	if v = getParam(q, upEndTime); v == "" {
+		v = defaultEndTime
+	}
+	if trq.Extent.End, err = parseTime(v, now); err != nil {
+		return nil, err
+	}
+
+	// PI Web API returns descending data when the start time is after the end time
+	if trq.Extent.Start.After(trq.Extent.End) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	return trq, nil
+}
+
+// streamEndpoint returns the stream data endpoint of the provided streams or
+// streamsets path, or an empty string if the path is not for stream data
+func streamEndpoint(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range parts {
+		if p = strings.ToLower(p); p != mnStreams && p != mnStreamSets {
+			continue
+		}
+		rest := parts[i+1:]
+		// the webId path segment is optional for streamsets, which
+		// also accept the webIds of the streams as query parameters
+		if len(rest) == 2 || (len(rest) == 1 && p == mnStreamSets) {
+			return strings.ToLower(rest[len(rest)-1])
+		}
+		return ""
+	}
+	return ""
+}
+
+// getParam returns the value of the named query parameter, which PI Web API
+// matches case-insensitively
+func getParam(q url.Values, name string) string {
+	if v := q.Get(name); v != "" {
+		return v
+	}
+	for k := range q {
+		if strings.EqualFold(k, name) {
+			return q.Get(k)
+		}
+	}
+	return ""
+}
+
+// setParam sets the named query parameter, replacing any value set under
+// a different case
+func setParam(q url.Values, name, value string) {
+	for k := range q {
+		if strings.EqualFold(k, name) {
+			q.Del(k)
+		}
+	}
+	q.Set(name, value)
+}
+
+// formatTime returns the PI Web API representation of the provided time
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime parses a PI Web API time string, which is either an ISO 8601
+// timestamp or a time relative to now (e.g., "*" or "*-30d"). Times relative
+// to the PI Server's time zone (e.g., "t" or "y") are not supported
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "*") {
+		if len(s) == 1 {
+			return now, nil
+		}
+		if s[1] != '+' && s[1] != '-' {
+			return time.Time{}, fmt.Errorf("unable to parse time %s", s)
+		}
+		d, err := parseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse time %s: %s", s, err.Error())
+	}
+	return t, nil
+}
+
+// parseDuration parses a PI Web API time span (e.g., "1h", "30m" or "-1d+12h")
+func parseDuration(s string) (time.Duration, error) {
+	v := strings.ToLower(strings.Replace(s, " ", "", -1))
+	if v == "" {
+		return 0, fmt.Errorf("unable to parse duration %s", s)
+	}
+	var d time.Duration
+	for v != "" {
+		sign := 1.0
+		switch v[0] {
+		case '-':
+			sign = -1
+			fallthrough
+		case '+':
+			v = v[1:]
+		}
+		i := strings.IndexFunc(v, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i < 1 {
+			return 0, fmt.Errorf("unable to parse duration %s", s)
+		}
+		n, err := strconv.ParseFloat(v[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse duration %s: %s", s, err.Error())
+		}
+		v = v[i:]
+		j := strings.IndexFunc(v, func(r rune) bool { return r < 'a' || r > 'z' })
+		if j < 0 {
+			j = len(v)
+		}
+		u, ok := durationUnits[v[:j]]
+		if !ok {
+			return 0, fmt.Errorf("unable to parse duration %s", s)
+		}
+		d += time.Duration(sign * n * float64(u))
+		v = v[j:]
+	}
+	return d, nil
+}
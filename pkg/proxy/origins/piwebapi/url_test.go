@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package piwebapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{}
+	r, _ := http.NewRequest(http.MethodGet,
+		"http://0/streams/a/recorded?starttime=*-1d&endTime=*&maxCount=10", nil)
+	e := &timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}
+	client.SetExtent(r, nil, e)
+
+	expected := "endTime=2020-01-01T01%3A00%3A00Z&maxCount=10&startTime=2020-01-01T00%3A00%3A00Z"
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+	// nil extents are ignored
+	client.SetExtent(r, nil, nil)
+	if r.URL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, r.URL.RawQuery)
+	}
+
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{}
+	tests := []struct {
+		path, query string
+		start, end  int64
+		step        time.Duration
+		expectErr   bool
+	}{
+		{"/streams/a/recorded", "startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z",
+			1577836800, 1577923200, time.Second, false},
+		{"/streams/a/interpolated", "starttime=2020-01-01T00:00:00Z&endtime=2020-01-02T00:00:00Z",
+			1577836800, 1577923200, time.Hour, false},
+		{"/streams/a/interpolated", "startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z&interval=15m",
+			1577836800, 1577923200, 15 * time.Minute, false},
+		{"/streamsets/recorded", "webId=a&webId=b&startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z",
+			1577836800, 1577923200, time.Second, false},
+		{"/streamsets/a/summary", "startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z&summaryDuration=1d",
+			1577836800, 1577923200, 24 * time.Hour, false},
+		// the summary of the whole range can't be merged
+		{"/streams/a/summary", "startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z",
+			0, 0, 0, true},
+		{"/streams/a/plot", "startTime=2020-01-01T00:00:00Z&endTime=2020-01-02T00:00:00Z",
+			0, 0, 0, true},
+		{"/streams/a/value", "", 0, 0, 0, true},
+		{"/streams/a/interpolated", "interval=abc", 0, 0, 0, true},
+		{"/streams/a/interpolated", "interval=-1h", 0, 0, 0, true},
+		{"/streams/a/summary", "summaryDuration=abc", 0, 0, 0, true},
+		{"/streams/a/recorded", "startTime=t", 0, 0, 0, true},
+		{"/streams/a/recorded", "endTime=y", 0, 0, 0, true},
+		{"/streams/a/recorded", "startTime=*&endTime=*-1d", 0, 0, 0, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://0"+test.path+"?"+test.query, nil)
+			trq, err := client.ParseTimeRangeQuery(r)
+			if test.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trq.Extent.Start.Unix() != test.start {
+				t.Errorf("expected %d got %d", test.start, trq.Extent.Start.Unix())
+			}
+			if trq.Extent.End.Unix() != test.end {
+				t.Errorf("expected %d got %d", test.end, trq.Extent.End.Unix())
+			}
+			if trq.Step != test.step {
+				t.Errorf("expected %s got %s", test.step, trq.Step)
+			}
+		})
+	}
+
+	// relative times default to the previous day
+	r, _ := http.NewRequest(http.MethodGet, "http://0/streams/a/recorded", nil)
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := trq.Extent.End.Sub(trq.Extent.Start); d != 24*time.Hour {
+		t.Errorf("expected %s got %s", 24*time.Hour, d)
+	}
+
+	r.Method = http.MethodPost
+	if _, err = client.ParseTimeRangeQuery(r); err == nil {
+		t.Error("expected error")
+	}
+
+}
+
+func TestStreamEndpoint(t *testing.T) {
+
+	tests := []struct {
+		path, expected string
+	}{
+		{"/streams/a/recorded", epRecorded},
+		{"/piwebapi/streams/a/Interpolated", epInterpolated},
+		{"/streamsets/a/summary", epSummary},
+		{"/streamsets/recorded", epRecorded},
+		{"/streams/recorded", ""},
+		{"/streams/a/b/recorded", ""},
+		{"/points/a/recorded", ""},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if v := streamEndpoint(test.path); v != test.expected {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+
+}
+
+func TestGetParam(t *testing.T) {
+	q := url.Values{"StartTime": {"*-1d"}}
+	if v := getParam(q, upStartTime); v != "*-1d" {
+		t.Errorf("expected %s got %s", "*-1d", v)
+	}
+	if v := getParam(q, upEndTime); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Unix(1577836800, 0)
+	tests := []struct {
+		s         string
+		expected  time.Time
+		expectErr bool
+	}{
+		{"*", now, false},
+		{" * ", now, false},
+		{"*-30d", now.Add(-30 * 24 * time.Hour), false},
+		{"*+1h", now.Add(time.Hour), false},
+		{"*-1d+12h", now.Add(-12 * time.Hour), false},
+		{"2020-01-01T00:00:00Z", now, false},
+		{"2020-01-01T02:00:00.5+02:00", now.Add(500 * time.Millisecond), false},
+		{"*1d", time.Time{}, true},
+		{"*-1x", time.Time{}, true},
+		{"t", time.Time{}, true},
+		{"2020-01-01", time.Time{}, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v, err := parseTime(test.s, now)
+			if test.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !v.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+
+}
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		s         string
+		expected  time.Duration
+		expectErr bool
+	}{
+		{"1h", time.Hour, false},
+		{"+30m", 30 * time.Minute, false},
+		{"1.5h", 90 * time.Minute, false},
+		{"2 days", 48 * time.Hour, false},
+		{"1w", 7 * 24 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"15S", 15 * time.Second, false},
+		{"", 0, true},
+		{"h", 0, true},
+		{"1", 0, true},
+		{"1mo", 0, true},
+		{"1..5h", 0, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v, err := parseDuration(test.s)
+			if test.expectErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != test.expected {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+		})
+	}
+
+}
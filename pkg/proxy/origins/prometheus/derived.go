@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// StartBackgroundTasks starts the evaluation loop of each of the origin's derived queries,
+// and the forwarding of its buffered remote_write requests
+func (c *Client) StartBackgroundTasks(quit <-chan struct{}, log *tl.Logger) {
+	if c.remoteWriter != nil {
+		go c.remoteWriter.run(quit, log)
+	}
+	if c.config == nil || c.config.Prometheus == nil || c.cache == nil {
+		return
+	}
+	for _, dq := range c.config.Prometheus.DerivedQueries {
+		go c.runDerivedQuery(dq, quit, log)
+	}
+}
+
+func (c *Client) runDerivedQuery(dq *pro.DerivedQueryOptions, quit <-chan struct{}, log *tl.Logger) {
+	log.Info("starting derived query", tl.Pairs{"originName": c.name,
+		"derivedQueryName": dq.Name, "interval": dq.Interval.String()})
+	c.evaluateDerivedQuery(dq, time.Now(), log)
+	t := time.NewTicker(dq.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-quit:
+			log.Debug("stopping derived query", tl.Pairs{"originName": c.name,
+				"derivedQueryName": dq.Name})
+			return
+		case now := <-t.C:
+			c.evaluateDerivedQuery(dq, now, log)
+		}
+	}
+}
+
+// evaluateDerivedQuery runs the derived query through the Delta Proxy Cache exactly as
+// if a client had requested it via GET, so the results are cached under the same key
+// that a matching client query will use
+func (c *Client) evaluateDerivedQuery(dq *pro.DerivedQueryOptions, now time.Time,
+	log *tl.Logger) int {
+
+	pc := c.pathConfig(APIPath + mnQueryRange)
+	if pc == nil {
+		return 0
+	}
+
+	end := now.Truncate(dq.Step)
+	v := url.Values{
+		upQuery: []string{dq.Query},
+		upStart: []string{strconv.FormatInt(end.Add(-dq.Range).Unix(), 10)},
+		upEnd:   []string{strconv.FormatInt(end.Unix(), 10)},
+		upStep:  []string{strconv.Itoa(dq.StepSecs)},
+	}
+
+	r, err := http.NewRequest(http.MethodGet, APIPath+mnQueryRange+"?"+v.Encode(), nil)
+	if err != nil {
+		return 0
+	}
+	r = request.SetResources(r, request.NewResources(c.config, pc,
+		c.cache.Configuration(), c.cache, c.self(), nil, log))
+
+	w := &discardResponseWriter{header: make(http.Header)}
+	c.QueryRangeHandler(w, r)
+
+	if w.status != http.StatusOK {
+		log.Warn("derived query evaluation failed", tl.Pairs{"originName": c.name,
+			"derivedQueryName": dq.Name, "status": w.status})
+	}
+	return w.status
+}
+
+// pathConfig returns the registered path config for the provided path
+func (c *Client) pathConfig(path string) *po.Options {
+	for _, p := range c.config.Paths {
+		if p.Path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// discardResponseWriter is an http.ResponseWriter that retains only the response status
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func TestEvaluateDerivedQuery(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 200, "", nil, "promsim", APIPath+mnQueryRange, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.cache = rsc.CacheClient
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.baseUpstreamURL, _ = url.Parse(ts.URL + "/prometheus")
+
+	dq := pro.NewDerivedQueryOptions()
+	dq.Name = "test"
+	dq.Query = "sum(rate(requests_total[5m]))"
+	client.config.Prometheus.DerivedQueries["test"] = dq
+	client.config.Prometheus.SetDurations()
+
+	now := time.Now()
+	if status := client.evaluateDerivedQuery(dq, now, rsc.Logger); status != 200 {
+		t.Errorf("expected %d got %d", 200, status)
+	}
+
+	// a client query matching the derived query should be served from the cache
+	end := now.Truncate(dq.Step)
+	v := url.Values{
+		upQuery: []string{dq.Query},
+		upStart: []string{strconv.FormatInt(end.Add(-dq.Range).Unix(), 10)},
+		upEnd:   []string{strconv.FormatInt(end.Unix(), 10)},
+		upStep:  []string{strconv.Itoa(dq.StepSecs)},
+	}
+	w := httptest.NewRecorder()
+	r = httptest.NewRequest("GET", ts.URL+APIPath+mnQueryRange+"?"+v.Encode(), nil)
+	r = request.SetResources(r, rsc)
+	client.QueryRangeHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected %d got %d", 200, resp.StatusCode)
+	}
+	if h := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(h, "status=hit") {
+		t.Errorf("expected cache hit got %s", h)
+	}
+
+	// without a query_range path config, there is nothing to evaluate
+	client.config.Paths = nil
+	if status := client.evaluateDerivedQuery(dq, now, rsc.Logger); status != 0 {
+		t.Errorf("expected %d got %d", 0, status)
+	}
+
+}
+
+func TestStartBackgroundTasks(t *testing.T) {
+
+	client := &Client{name: "test"}
+	// no config should be a no-op
+	client.StartBackgroundTasks(make(chan struct{}), tl.ConsoleLogger("error"))
+
+}
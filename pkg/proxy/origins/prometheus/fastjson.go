@@ -0,0 +1,172 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/util/fastjson"
+
+	"github.com/prometheus/common/model"
+)
+
+var errInvalidTime = errors.New("invalid time")
+
+// unmarshalMatrix decodes a Matrix document into me without reflection. It returns an error for
+// any document it does not fully understand, in which case the caller should decode a new
+// MatrixEnvelope with encoding/json
+func unmarshalMatrix(data []byte, me *MatrixEnvelope) error {
+	s := fastjson.NewScanner(data)
+	s.Object(func(key string) {
+		switch {
+		case strings.EqualFold(key, "status"):
+			me.Status = s.String()
+		case strings.EqualFold(key, "data"):
+			s.Object(func(key string) {
+				switch {
+				case strings.EqualFold(key, "resultType"):
+					me.Data.ResultType = s.String()
+				case strings.EqualFold(key, "result"):
+					me.Data.Result = scanMatrix(s)
+				default:
+					s.Skip()
+				}
+			})
+		case strings.EqualFold(key, "extents"):
+			s.Unmarshal(&me.ExtentList)
+		case strings.EqualFold(key, "step"):
+			s.Unmarshal(&me.StepDuration)
+		default:
+			s.Skip()
+		}
+	})
+	s.End()
+	return s.Err()
+}
+
+func scanMatrix(s *fastjson.Scanner) model.Matrix {
+	if s.Null() {
+		return nil
+	}
+	m := model.Matrix{}
+	s.Array(func() {
+		ss := &model.SampleStream{}
+		s.Object(func(key string) {
+			switch {
+			case strings.EqualFold(key, "metric"):
+				ss.Metric = scanMetric(s)
+			case strings.EqualFold(key, "values"):
+				ss.Values = scanSamplePairs(s)
+			default:
+				s.Skip()
+			}
+		})
+		m = append(m, ss)
+	})
+	return m
+}
+
+func scanMetric(s *fastjson.Scanner) model.Metric {
+	if s.Null() {
+		return nil
+	}
+	m := model.Metric{}
+	s.Object(func(key string) {
+		m[model.LabelName(key)] = model.LabelValue(s.String())
+	})
+	return m
+}
+
+func scanSamplePairs(s *fastjson.Scanner) []model.SamplePair {
+	if s.Null() {
+		return nil
+	}
+	sps := []model.SamplePair{}
+	s.Array(func() {
+		var sp model.SamplePair
+		var n int
+		s.Array(func() {
+			switch n {
+			case 0:
+				t, err := parseSampleTime(s.Number())
+				if err != nil {
+					s.Fail(err)
+				}
+				sp.Timestamp = t
+			case 1:
+				sp.Value = model.SampleValue(parseSampleValue(s))
+			default:
+				s.Skip()
+			}
+			n++
+		})
+		sps = append(sps, sp)
+	})
+	return sps
+}
+
+// parseSampleValue consumes a quoted sample value and returns it as model.SampleValue's
+// UnmarshalJSON would, without unescaping the string
+func parseSampleValue(s *fastjson.Scanner) float64 {
+	b := s.RawString()
+	if b == nil {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(b[1:len(b)-1]), 64)
+	if err != nil {
+		s.Fail(err)
+	}
+	return f
+}
+
+// parseSampleTime parses a timestamp as model.Time's UnmarshalJSON would, without allocating
+func parseSampleTime(b []byte) (model.Time, error) {
+	i := bytes.IndexByte(b, '.')
+	if i < 0 {
+		v, err := strconv.ParseInt(string(b), 10, 64)
+		return model.Time(v * 1000), err
+	}
+	if bytes.IndexByte(b[i+1:], '.') >= 0 {
+		return 0, errInvalidTime
+	}
+	v, err := strconv.ParseInt(string(b[:i]), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	v *= 1000
+	var va int64
+	if frac := b[i+1:]; len(frac) > 0 {
+		if len(frac) > 3 {
+			frac = frac[:3]
+		}
+		va, err = strconv.ParseInt(string(frac), 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		for j := len(frac); j < 3; j++ {
+			va *= 10
+		}
+	}
+	// retains the sign of values like -0.1, whose integer part is 0
+	if i > 0 && b[0] == '-' && v+va > 0 {
+		return model.Time(v+va) * -1, nil
+	}
+	return model.Time(v + va), nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
+	ffo "github.com/tricksterproxy/trickster/pkg/featureflags/options"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+
+	"github.com/prometheus/common/model"
+)
+
+// testMatrixDocument returns a Matrix document with the provided number of series and points
+func testMatrixDocument(series, points int) []byte {
+	sb := &strings.Builder{}
+	sb.WriteString(`{"status":"success","data":{"resultType":"matrix","result":[`)
+	for i := 0; i < series; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(sb, `{"metric":{"__name__":"up","instance":"host-%d:9100","job":"node"},"values":[`, i)
+		for j := 0; j < points; j++ {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(sb, `[%d.%d,"%d.25"]`, 1577836800+j*15, j%1000, i*j)
+		}
+		sb.WriteString(`]}`)
+	}
+	sb.WriteString(`]}}`)
+	return []byte(sb.String())
+}
+
+func TestUnmarshalMatrix(t *testing.T) {
+
+	tests := []string{
+		string(testMatrixDocument(3, 10)),
+		`{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+		`{"status":"success","data":{"resultType":"matrix","result":null}}`,
+		`{"Status":"success","warnings":["w"],"data":{"ResultType":"matrix","result":` +
+			`[{"metric":{"a":"é\"b"},"values":[[1.5,"NaN"],[-0.1,"+Inf"],[3,"1e3", 4]]},` +
+			`{"values":null},{"metric":{}}]}}`,
+		`{"status":"success","data":{"result":[{"values":[[1.12345,"1"]]}]},` +
+			`"extents":[{"start":"2020-01-01T00:00:00Z","end":"2020-01-01T01:00:00Z"}],"step":15000000000}`,
+	}
+
+	for i, test := range tests {
+		me := &MatrixEnvelope{}
+		err := unmarshalMatrix([]byte(test), me)
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+			continue
+		}
+		expected := &MatrixEnvelope{}
+		json.Unmarshal([]byte(test), expected)
+		if me.Status != expected.Status || me.Data.ResultType != expected.Data.ResultType ||
+			me.StepDuration != expected.StepDuration ||
+			!reflect.DeepEqual(me.ExtentList, expected.ExtentList) ||
+			me.Data.Result.String() != expected.Data.Result.String() ||
+			(me.Data.Result == nil) != (expected.Data.Result == nil) {
+			t.Errorf("test %d: expected %v got %v", i, expected, me)
+		}
+	}
+
+}
+
+func TestUnmarshalMatrixFails(t *testing.T) {
+
+	tests := []string{
+		`null`,
+		`{"status":null}`,
+		`{"data":{"result":[{"values":[[1,1.5]]}]}}`,
+		`{"data":{"result":[{"values":[[1.2.3,"1"]]}]}}`,
+		`{"data":{"result":[{"values":[["1","1"]]}]}}`,
+		`{"data":{"result":[{"values":[[1,"x"]]}]}}`,
+		`{"data":{"result":[null]}}`,
+		`{"data":{}} {}`,
+	}
+
+	for i, test := range tests {
+		if err := unmarshalMatrix([]byte(test), &MatrixEnvelope{}); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+}
+
+func TestParseSampleTime(t *testing.T) {
+
+	tests := []string{"0", "1577836800", "1577836800.5", "1577836800.123456", "1577836800.",
+		"-1", "-0.1", "-1.25", "1.-5", ".5", "1e3", "1.2.3", "x"}
+
+	for _, test := range tests {
+		var expected model.Time
+		expectedErr := expected.UnmarshalJSON([]byte(test))
+		v, err := parseSampleTime([]byte(test))
+		if (err != nil) != (expectedErr != nil) {
+			t.Errorf("%s: expected error %v got %v", test, expectedErr, err)
+			continue
+		}
+		if err == nil && v != expected {
+			t.Errorf("%s: expected %d got %d", test, expected, v)
+		}
+	}
+
+}
+
+func TestUnmarshalTimeseriesFastJSON(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.FastJSONDecode = true
+	oc.FastJSONMinBytes = 0
+	client := &Client{config: oc}
+
+	b := testMatrixDocument(2, 5)
+	ts, err := client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ts.ValueCount(); v != 10 {
+		t.Errorf("expected %d got %d", 10, v)
+	}
+
+	// documents the fast decoder does not support fall back to encoding/json
+	_, err = client.UnmarshalTimeseries([]byte(`{"data":{"result":[{"values":[[1,1.5]]}]}}`))
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	// a disabled feature flag rolls the origin back to encoding/json
+	client.name = "test"
+	defer featureflags.Apply(nil)
+	fo := ffo.NewOptions()
+	fo.Enabled = false
+	fo.Origins = []string{"test"}
+	featureflags.Apply(map[string]*ffo.Options{featureflags.FastJSONDecode: fo})
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ts.ValueCount(); v != 10 {
+		t.Errorf("expected %d got %d", 10, v)
+	}
+
+}
+
+func benchmarkUnmarshalMatrix(b *testing.B, series, points int, fast bool) {
+	data := testMatrixDocument(series, points)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		me := &MatrixEnvelope{}
+		var err error
+		if fast {
+			err = unmarshalMatrix(data, me)
+		} else {
+			err = json.Unmarshal(data, me)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// the benchmarks span document sizes from ~1KB to ~20MB, to compare the decoders at
+// the sizes around fast_json_min_bytes, as well as for large dashboards
+func BenchmarkUnmarshalMatrix(b *testing.B) {
+	sizes := []struct{ series, points int }{{1, 10}, {2, 60}, {10, 240}, {100, 1440}, {1000, 1440}}
+	for _, size := range sizes {
+		for _, fast := range []bool{false, true} {
+			name := fmt.Sprintf("series=%d/points=%d/fast=%t", size.series, size.points, fast)
+			b.Run(name, func(b *testing.B) {
+				benchmarkUnmarshalMatrix(b, size.series, size.points, fast)
+			})
+		}
+	}
+}
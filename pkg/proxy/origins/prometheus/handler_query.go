@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/params"
 	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
 )
@@ -39,6 +40,15 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	r.URL = u
 	params.SetRequestValues(r, qp)
+	c.rewriteQuery(w, r)
+	acceptJSON(r)
 
 	engines.ObjectProxyCacheRequest(w, r)
 }
+
+// acceptJSON requests a JSON response from the origin. Responses are cached and merged as
+// JSON, which is the only format the Prometheus query API returns, so other formats the
+// client may accept, such as protobuf, are not requested of the origin
+func acceptJSON(r *http.Request) {
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+}
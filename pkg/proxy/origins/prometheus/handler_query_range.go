@@ -27,5 +27,7 @@ import (
 // Prometheus and processes them through the delta proxy cache
 func (c *Client) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
 	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	c.rewriteQuery(w, r)
+	acceptJSON(r)
 	engines.DeltaProxyCacheRequest(w, r)
 }
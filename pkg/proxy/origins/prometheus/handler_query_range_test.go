@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
 )
@@ -42,8 +43,14 @@ func TestQueryRangeHandler(t *testing.T) {
 		t.Error(err)
 	}
 
+	r.Header.Set(headers.NameAccept, "application/x-protobuf, application/json;q=0.5")
 	client.QueryRangeHandler(w, r)
 
+	// it should request JSON from the origin
+	if v := r.Header.Get(headers.NameAccept); v != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, v)
+	}
+
 	resp := w.Result()
 
 	// it should return 200 OK
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	"github.com/tricksterproxy/trickster/pkg/util/md5"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+)
+
+// contentTypeReadResponse is the Content-Type of a SAMPLES remote_read response
+const contentTypeReadResponse = "application/x-protobuf"
+
+// RemoteReadHandler serves remote_read requests from the cache, fetching from the origin only
+// the time ranges of each query's samples that are not already cached. Requests that can't
+// be cached (e.g., those with several queries or that accept only streamed responses) are
+// proxied directly, as are all requests when the origin's remote_read cache is not enabled
+func (c *Client) RemoteReadHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = urls.BuildUpstreamURL(r, c.baseUpstreamURL)
+	rsc := request.GetResources(r)
+	if rsc == nil || c.cache == nil || c.config.Prometheus == nil ||
+		!c.config.Prometheus.RemoteReadCacheEnabled {
+		engines.DoProxy(w, r, true)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRemoteReadBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if len(body) > maxRemoteReadBytes ||
+		r.Header.Get(headers.NameContentEncoding) != "snappy" {
+		engines.DoProxy(w, r, true)
+		return
+	}
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		engines.DoProxy(w, r, true)
+		return
+	}
+	q, err := parseReadRequest(b)
+	if err != nil || q.end < q.start {
+		engines.DoProxy(w, r, true)
+		return
+	}
+	c.serveRemoteRead(w, r, q)
+}
+
+// remoteReadKey returns the cache key of the remote_read query's document. The query's time
+// range is not part of the key, so that every time range of the query shares its cached samples
+func (c *Client) remoteReadKey(r *http.Request, q *readQuery) string {
+	var buf bytes.Buffer
+	buf.WriteString(r.URL.Path)
+	buf.WriteString(r.Header.Get(headers.NameAuthorization))
+	for _, m := range q.matchers {
+		buf.Write(m)
+	}
+	buf.Write(q.hints)
+	fmt.Fprintf(&buf, ".v%d.g%d", cacheKeyVersion, c.config.CacheGeneration)
+	return c.config.CacheKeyPrefix + ".rr." + md5.Checksum(buf.String())
+}
+
+// retrieveReadDocument returns the query's cached document, and its series. A document that
+// can't be retrieved or decoded is treated as a key miss
+func (c *Client) retrieveReadDocument(key string) (*readDocument, readResult) {
+	doc := &readDocument{}
+	rr := make(readResult)
+	b, _, err := c.cache.Retrieve(key, false)
+	if err != nil || len(b) == 0 {
+		return doc, rr
+	}
+	if err = json.Unmarshal(b, doc); err != nil {
+		return &readDocument{}, rr
+	}
+	if err = rr.parseQueryResult(doc.Result); err != nil {
+		return &readDocument{}, make(readResult)
+	}
+	return doc, rr
+}
+
+// fetchReadRange fetches the query's samples in the inclusive time range from the origin,
+// and merges them into the readResult
+func (c *Client) fetchReadRange(r *http.Request, q *readQuery, e timeseries.Extent,
+	rr readResult, mtx *sync.Mutex) error {
+	rsc := request.GetResources(r).Clone()
+	rsc.Canonical = nil
+	body := snappy.Encode(nil, encodeReadRequest(q, timeMS(e.Start), timeMS(e.End)))
+	fr, err := http.NewRequestWithContext(tctx.WithResources(r.Context(), rsc),
+		http.MethodPost, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	fr.Header = r.Header.Clone()
+	fr.Header.Del(headers.NameContentLength)
+	fr.ContentLength = int64(len(body))
+	rc, resp, _ := engines.PrepareFetchReader(fr)
+	if rc != nil {
+		defer rc.Close()
+	}
+	if resp.StatusCode != http.StatusOK || rc == nil {
+		return fmt.Errorf("remote_read fetch failed with status %d", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if b, err = snappy.Decode(nil, b); err != nil {
+		return err
+	}
+	fetched := make(readResult)
+	if err = fetched.parseReadResponse(b); err != nil {
+		return err
+	}
+	mtx.Lock()
+	for _, s := range fetched {
+		rr.merge(s)
+	}
+	mtx.Unlock()
+	return nil
+}
+
+// serveRemoteRead responds to the remote_read query with its cached samples, after fetching
+// the time ranges that are not cached from the origin and caching their samples
+func (c *Client) serveRemoteRead(w http.ResponseWriter, r *http.Request, q *readQuery) {
+	oc := c.config
+	key := c.remoteReadKey(r, q)
+	doc, rr := c.retrieveReadDocument(key)
+
+	cacheStatus := status.LookupStatusHit
+	misses := missingExtents(doc.Extents, q.start, q.end)
+	if len(doc.Extents) == 0 {
+		cacheStatus = status.LookupStatusKeyMiss
+	} else if len(misses) > 0 {
+		cacheStatus = status.LookupStatusPartialHit
+	}
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	errs := make([]error, len(misses))
+	for i := range misses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.fetchReadRange(r, q, misses[i], rr, &mtx)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		// the origin's response to the full request is proxied, so its error reaches the client
+		if err != nil {
+			engines.DoProxy(w, r, true)
+			return
+		}
+	}
+
+	if len(misses) > 0 {
+		// samples newer than the backfill tolerance may still change, so they are not cached
+		cutoff := timeMS(time.Now().Add(-(oc.BackfillTolerance + oc.ClockSkewTolerance)))
+		extents := doc.Extents
+		for _, e := range misses {
+			if timeMS(e.Start) > cutoff {
+				continue
+			}
+			if timeMS(e.End) > cutoff {
+				e.End = msTime(cutoff)
+			}
+			extents = append(extents, e)
+		}
+		if len(extents) > len(doc.Extents) {
+			doc.Extents = extents.Compress(time.Millisecond)
+			doc.Result = rr.encodeQueryResult(math.MinInt64, cutoff)
+			if b, err := json.Marshal(doc); err == nil && len(b) <= oc.MaxObjectSizeBytes {
+				c.cache.Store(key, b, oc.TimeseriesTTL)
+			}
+		}
+	}
+
+	h := w.Header()
+	h.Set(headers.NameContentType, contentTypeReadResponse)
+	h.Set(headers.NameContentEncoding, "snappy")
+	headers.SetResultsHeader(h, "RemoteReadCache", cacheStatus.String(), "", misses)
+	w.WriteHeader(http.StatusOK)
+	w.Write(snappy.Encode(nil, rr.encodeReadResponse(q.start, q.end)))
+}
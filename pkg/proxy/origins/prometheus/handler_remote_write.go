@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// RemoteWriteHandler buffers remote_write requests to be forwarded to the origin in batches,
+// and acknowledges them once they are buffered. Series dropped by the origin's relabel rules
+// are removed from each request before it is buffered. When the origin's remote_write buffering is
+// not enabled, the requests are proxied directly
+func (c *Client) RemoteWriteHandler(w http.ResponseWriter, r *http.Request) {
+	if c.remoteWriter == nil {
+		c.ProxyHandler(w, r)
+		return
+	}
+	var log *tl.Logger
+	if rsc := request.GetResources(r); rsc != nil {
+		log = rsc.Logger
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRemoteWriteBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRemoteWriteBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	e := &writeEntry{Path: r.URL.Path, Header: make(http.Header), Body: body}
+	for _, k := range remoteWriteHeaders {
+		if v := r.Header.Get(k); v != "" {
+			e.Header.Set(k, v)
+		}
+	}
+	if c.remoteWriter.options.Relabels() {
+		// the series of version 2 requests reference a symbol table, and aren't filtered
+		if !mergeable(e) {
+			http.Error(w, "relabel rules require version 1 remote_write requests",
+				http.StatusUnsupportedMediaType)
+			return
+		}
+		if e.Body, err = c.remoteWriter.relabel(e.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// every series was dropped, so there is nothing to forward
+		if e.Body == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	// a 503 is retried by the agent, which holds the request until the buffer has room
+	if err = c.remoteWriter.enqueue(e, log); err != nil {
+		w.Header().Set(headerRetryAfter,
+			strconv.Itoa(int(c.remoteWriter.options.FlushInterval.Seconds())+1))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -17,6 +17,7 @@
 package prometheus
 
 import (
+	"math"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -88,6 +89,96 @@ func (me *MatrixEnvelope) Merge(sort bool, collection ...timeseries.Timeseries)
 	}
 }
 
+// DedupReplicas removes the provided replica labels from each series, and combines the series
+// that are then identical, so that the series of HA replicas are merged as one. Where the
+// combined series have a value at the same timestamp, the value of the first series is kept
+func (me *MatrixEnvelope) DedupReplicas(labels []string) {
+	if len(labels) == 0 || len(me.Data.Result) == 0 {
+		return
+	}
+	series := make(map[string]*model.SampleStream, len(me.Data.Result))
+	result := make(model.Matrix, 0, len(me.Data.Result))
+	var combined bool
+	for _, s := range me.Data.Result {
+		var metric model.Metric
+		for _, l := range labels {
+			if _, ok := s.Metric[model.LabelName(l)]; !ok {
+				continue
+			}
+			if metric == nil {
+				metric = s.Metric.Clone()
+			}
+			delete(metric, model.LabelName(l))
+		}
+		if metric != nil {
+			s = &model.SampleStream{Metric: metric, Values: s.Values}
+		}
+		name := s.Metric.String()
+		s1, ok := series[name]
+		if !ok {
+			series[name] = s
+			result = append(result, s)
+			continue
+		}
+		ts := make(map[model.Time]bool, len(s1.Values))
+		for _, sp := range s1.Values {
+			ts[sp.Timestamp] = true
+		}
+		values := make([]model.SamplePair, len(s1.Values), len(s1.Values)+len(s.Values))
+		copy(values, s1.Values)
+		for _, sp := range s.Values {
+			if !ts[sp.Timestamp] {
+				values = append(values, sp)
+			}
+		}
+		s1.Values = values
+		combined = true
+	}
+	me.Data.Result = result
+	if combined {
+		me.isSorted = false
+		me.isCounted = false
+		me.Sort()
+	}
+}
+
+// FillGaps fills the missing steps between the values of each series per the mode, and
+// returns the number of values that were added
+func (me *MatrixEnvelope) FillGaps(mode string, step, maxInterval time.Duration) int {
+	if step <= 0 || mode == timeseries.GapFillNone {
+		return 0
+	}
+	me.Sort()
+	var n int
+	for _, s := range me.Data.Result {
+		if len(s.Values) < 2 {
+			continue
+		}
+		values := make([]model.SamplePair, 0, len(s.Values))
+		for i, sp := range s.Values {
+			if i > 0 {
+				prev := s.Values[i-1]
+				for _, t := range timeseries.GapTimes(prev.Timestamp.Time(), sp.Timestamp.Time(),
+					step, maxInterval, mode) {
+					v := model.SampleValue(math.NaN())
+					if mode == timeseries.GapFillCarryForward {
+						v = prev.Value
+					}
+					values = append(values, model.SamplePair{Timestamp: model.TimeFromUnixNano(t.UnixNano()),
+						Value: v})
+				}
+			}
+			values = append(values, sp)
+		}
+		n += len(values) - len(s.Values)
+		s.Values = values
+	}
+	if n > 0 {
+		me.isCounted = false
+	}
+	return n
+}
+
 // Clone returns a perfect copy of the base Timeseries
 func (me *MatrixEnvelope) Clone() timeseries.Timeseries {
 	resMe := &MatrixEnvelope{
@@ -295,29 +386,21 @@ func (me *MatrixEnvelope) Sort() {
 	}
 
 	tsm := map[time.Time]bool{}
-	wg := sync.WaitGroup{}
-	mtx := sync.Mutex{}
 
 	for i, s := range me.Data.Result { // []SampleStream
 		m := make(map[time.Time]model.SamplePair)
 		keys := make(times.Times, 0, len(m))
 
-		for _, v := range s.Values { // []SamplePair
-			wg.Add(1)
-			go func(sp model.SamplePair) {
-				t := sp.Timestamp.Time()
-				mtx.Lock()
-				if _, ok := m[t]; !ok {
-					keys = append(keys, t)
-					m[t] = sp
-				}
-				tsm[t] = true
-				m[t] = sp
-				mtx.Unlock()
-				wg.Done()
-			}(v)
+		// values are processed in order, so when a timestamp is duplicated, the value
+		// appended most recently (e.g., freshly fetched data merged into the cache) wins
+		for _, sp := range s.Values { // []SamplePair
+			t := sp.Timestamp.Time()
+			if _, ok := m[t]; !ok {
+				keys = append(keys, t)
+			}
+			tsm[t] = true
+			m[t] = sp
 		}
-		wg.Wait()
 		sort.Sort(keys)
 		sm := make([]model.SamplePair, 0, len(keys))
 		for _, key := range keys {
@@ -382,6 +465,19 @@ func (me *MatrixEnvelope) SeriesCount() int {
 	return len(me.Data.Result)
 }
 
+// SeriesLabels returns the label set of each Series in the Timeseries, including the
+// metric name as the __name__ label
+func (me *MatrixEnvelope) SeriesLabels() []map[string]string {
+	labels := make([]map[string]string, len(me.Data.Result))
+	for i, s := range me.Data.Result {
+		labels[i] = make(map[string]string, len(s.Metric))
+		for k, v := range s.Metric {
+			labels[i][string(k)] = string(v)
+		}
+	}
+	return labels
+}
+
 // ValueCount returns the count of all values across all Series in the Timeseries object
 func (me *MatrixEnvelope) ValueCount() int {
 	c := 0
@@ -17,6 +17,7 @@
 package prometheus
 
 import (
+	"math"
 	"reflect"
 	"strconv"
 	"testing"
@@ -1457,8 +1458,8 @@ func TestSort(t *testing.T) {
 								{Timestamp: 1544004200000, Value: 1.5},
 								{Timestamp: 1544004600000, Value: 1.5},
 								{Timestamp: 1544004800000, Value: 1.5},
-								{Timestamp: 1544004000000, Value: 1.5},
-								{Timestamp: 1544004000000, Value: 1.5}, // sort should also dupe kill
+								{Timestamp: 1544004000000, Value: 1.0},
+								{Timestamp: 1544004000000, Value: 1.5}, // sort should also dupe kill, last value wins
 							},
 						},
 						&model.SampleStream{
@@ -1581,6 +1582,31 @@ func TestSeriesCount(t *testing.T) {
 	}
 }
 
+func TestSeriesLabels(t *testing.T) {
+	me := &MatrixEnvelope{
+		Data: MatrixData{
+			ResultType: "matrix",
+			Result: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "d", "job": "node"},
+					Values: []model.SamplePair{{Timestamp: 99000, Value: 1.5}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "e"},
+					Values: []model.SamplePair{{Timestamp: 99000, Value: 1.5}},
+				},
+			},
+		},
+	}
+	labels := me.SeriesLabels()
+	if len(labels) != 2 {
+		t.Fatalf("expected 2 got %d.", len(labels))
+	}
+	if labels[0]["__name__"] != "d" || labels[0]["job"] != "node" || labels[1]["__name__"] != "e" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+}
+
 func TestValueCount(t *testing.T) {
 	me := &MatrixEnvelope{
 		Data: MatrixData{
@@ -1707,3 +1733,132 @@ func TestSize(t *testing.T) {
 		t.Errorf("expected %d got %d", expected, i)
 	}
 }
+
+func TestDedupReplicas(t *testing.T) {
+
+	me := &MatrixEnvelope{
+		Data: MatrixData{
+			ResultType: "matrix",
+			Result: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "a", "replica": "0"},
+					Values: []model.SamplePair{
+						{Timestamp: 1000, Value: 1},
+						{Timestamp: 3000, Value: 3},
+					},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "a", "replica": "1"},
+					Values: []model.SamplePair{
+						{Timestamp: 1000, Value: 1.1},
+						{Timestamp: 2000, Value: 2.1},
+						{Timestamp: 3000, Value: 3.1},
+					},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "b"},
+					Values: []model.SamplePair{{Timestamp: 1000, Value: 1}},
+				},
+			},
+		},
+	}
+	metric := me.Data.Result[0].Metric
+
+	me.DedupReplicas(nil)
+	if len(me.Data.Result) != 3 {
+		t.Errorf("expected %d got %d", 3, len(me.Data.Result))
+	}
+
+	// the series of the second replica fills the gap in that of the first
+	me.DedupReplicas([]string{"replica"})
+	expected := model.Matrix{
+		&model.SampleStream{
+			Metric: model.Metric{"__name__": "a"},
+			Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 1},
+				{Timestamp: 2000, Value: 2.1},
+				{Timestamp: 3000, Value: 3},
+			},
+		},
+		&model.SampleStream{
+			Metric: model.Metric{"__name__": "b"},
+			Values: []model.SamplePair{{Timestamp: 1000, Value: 1}},
+		},
+	}
+	if !reflect.DeepEqual(me.Data.Result, expected) {
+		t.Errorf("mismatch\nexpected=%v\n     got=%v", expected, me.Data.Result)
+	}
+	if me.TimestampCount() != 3 {
+		t.Errorf("expected %d got %d", 3, me.TimestampCount())
+	}
+
+	// the labels of the original series are not modified
+	if _, ok := metric["replica"]; !ok {
+		t.Error("expected replica label in original metric")
+	}
+
+}
+
+func TestFillGaps(t *testing.T) {
+
+	newMatrix := func() *MatrixEnvelope {
+		return &MatrixEnvelope{
+			Data: MatrixData{
+				ResultType: "matrix",
+				Result: model.Matrix{
+					&model.SampleStream{
+						Metric: model.Metric{"__name__": "a"},
+						Values: []model.SamplePair{
+							{Timestamp: 10000, Value: 1},
+							{Timestamp: 50000, Value: 5},
+							{Timestamp: 60000, Value: 6},
+						},
+					},
+					&model.SampleStream{
+						Metric: model.Metric{"__name__": "b"},
+						Values: []model.SamplePair{{Timestamp: 10000, Value: 1}},
+					},
+				},
+			},
+		}
+	}
+	step := 10 * time.Second
+
+	me := newMatrix()
+	if n := me.FillGaps(timeseries.GapFillNone, step, 0); n != 0 {
+		t.Errorf("expected %d got %d", 0, n)
+	}
+
+	if n := me.FillGaps(timeseries.GapFillCarryForward, step, 20*time.Second); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	expected := []model.SamplePair{
+		{Timestamp: 10000, Value: 1},
+		{Timestamp: 20000, Value: 1},
+		{Timestamp: 30000, Value: 1},
+		{Timestamp: 50000, Value: 5},
+		{Timestamp: 60000, Value: 6},
+	}
+	if !reflect.DeepEqual(me.Data.Result[0].Values, expected) {
+		t.Errorf("mismatch\nexpected=%v\n     got=%v", expected, me.Data.Result[0].Values)
+	}
+	if me.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, me.TimestampCount())
+	}
+
+	// a series with a single value has no gaps
+	if len(me.Data.Result[1].Values) != 1 {
+		t.Errorf("expected %d got %d", 1, len(me.Data.Result[1].Values))
+	}
+
+	me = newMatrix()
+	if n := me.FillGaps(timeseries.GapFillNull, step, 20*time.Second); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+	for _, sp := range me.Data.Result[0].Values[1:4] {
+		if !math.IsNaN(float64(sp.Value)) {
+			t.Errorf("expected NaN got %v", sp.Value)
+		}
+	}
+
+}
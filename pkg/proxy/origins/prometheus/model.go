@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/featureflags"
 	"github.com/tricksterproxy/trickster/pkg/sort/times"
 	"github.com/tricksterproxy/trickster/pkg/timeseries"
 
@@ -66,7 +67,18 @@ func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
 // UnmarshalTimeseries converts a JSON blob into a Timeseries
 func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
 	me := &MatrixEnvelope{}
+	if c.config != nil && len(data) >= c.config.FastJSONMinBytes &&
+		featureflags.Enabled(featureflags.FastJSONDecode, c.name, "", c.config.FastJSONDecode) {
+		if unmarshalMatrix(data, me) == nil {
+			c.dedupReplicas(me)
+			return me, nil
+		}
+		me = &MatrixEnvelope{}
+	}
 	err := json.Unmarshal(data, &me)
+	if err == nil {
+		c.dedupReplicas(me)
+	}
 	return me, err
 }
 
@@ -77,7 +89,16 @@ func (c *Client) UnmarshalInstantaneous(data []byte) (timeseries.Timeseries, err
 	if err != nil {
 		return nil, err
 	}
-	return ve.ToMatrix(), nil
+	me := ve.ToMatrix()
+	c.dedupReplicas(me)
+	return me, nil
+}
+
+// dedupReplicas removes the origin's replica labels from the Timeseries
+func (c *Client) dedupReplicas(me *MatrixEnvelope) {
+	if c.config != nil && c.config.Prometheus != nil {
+		me.DedupReplicas(c.config.Prometheus.ReplicaLabels)
+	}
 }
 
 // ToMatrix converts a VectorEnvelope to a MatrixEnvelope
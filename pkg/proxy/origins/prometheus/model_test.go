@@ -19,6 +19,8 @@ package prometheus
 import (
 	"testing"
 
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+
 	"github.com/prometheus/common/model"
 )
 
@@ -64,6 +66,31 @@ func TestMarshalTimeseries(t *testing.T) {
 
 }
 
+func TestUnmarshalTimeseriesReplicaLabels(t *testing.T) {
+
+	bytes := []byte(`{"status":"","data":{"resultType":"matrix",` +
+		`"result":[{"metric":{"__name__":"a","replica":"0"},"values":[[99,"1.5"],[299,"1.5"]]},` +
+		`{"metric":{"__name__":"a","replica":"1"},"values":[[99,"1.5"],[199,"1.5"],[299,"1.5"]]}]}}`)
+	client := &Client{config: oo.NewOptions()}
+	client.config.Prometheus.ReplicaLabels = []string{"replica"}
+	ts, err := client.UnmarshalTimeseries(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	me := ts.(*MatrixEnvelope)
+	if len(me.Data.Result) != 1 {
+		t.Fatalf(`expected 1. got %d`, len(me.Data.Result))
+	}
+	if me.Data.Result[0].Metric.String() != "a" {
+		t.Errorf(`expected a. got %s`, me.Data.Result[0].Metric.String())
+	}
+	if len(me.Data.Result[0].Values) != 3 {
+		t.Errorf(`expected 3. got %d`, len(me.Data.Result[0].Values))
+	}
+
+}
+
 func TestUnmarshalTimeseries(t *testing.T) {
 
 	bytes := []byte(`{"status":"","data":{"resultType":"matrix",` +
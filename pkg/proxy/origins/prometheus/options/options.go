@@ -0,0 +1,430 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides configurations that are specific to the Prometheus Origin Type
+package options
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options is a collection of Prometheus-specific origin configurations
+type Options struct {
+	// LookbackDeltaSecs is the origin's query lookback delta in seconds. Delta fetches re-fetch
+	// this much (or the query's largest range selector, if longer) of the already-cached data
+	// preceding each uncached extent, so points at extent seams are refreshed; 0 disables padding
+	LookbackDeltaSecs int `toml:"lookback_delta_secs"`
+	// ReplicaLabels are the names of the labels that distinguish the series of the HA replicas
+	// serving the origin. They are removed from timeseries results, and the series that are
+	// then identical are combined, so that cached data doesn't depend on the replica that served it
+	ReplicaLabels []string `toml:"replica_labels"`
+	// DerivedQueries is a map of named queries that Trickster evaluates against the origin
+	// on a schedule, so their results are already cached when a matching client query arrives
+	DerivedQueries map[string]*DerivedQueryOptions `toml:"derived_queries"`
+	// QueryRewrites is a map of named substitutions of raw query expressions with cheaper
+	// equivalents (e.g., recording rule metrics), applied before cache keying and origin fetch
+	QueryRewrites map[string]*QueryRewriteOptions `toml:"query_rewrites"`
+	// RemoteWrite configures the buffering and batching of remote_write requests forwarded
+	// to the origin
+	RemoteWrite *RemoteWriteOptions `toml:"remote_write"`
+	// RemoteReadCacheEnabled caches the samples of the origin's remote_read responses, so that
+	// only the time ranges of each query that are not already cached are fetched from the origin
+	RemoteReadCacheEnabled bool `toml:"remote_read_cache_enabled"`
+
+	// LookbackDelta is the time.Duration representation of LookbackDeltaSecs
+	LookbackDelta time.Duration `toml:"-"`
+
+	// queryRewriteOrder is the list of QueryRewrites, sorted by name
+	queryRewriteOrder []*QueryRewriteOptions
+}
+
+// QueryRewriteOptions describes the substitution of a query expression pattern
+type QueryRewriteOptions struct {
+	// Match is the regular expression matched against the client's query expression
+	Match string `toml:"match"`
+	// Replacement is the substitute for the text matched by Match, and may reference
+	// capture groups (e.g., $1) from the Match expression
+	Replacement string `toml:"replacement"`
+
+	// Name is the Name of the query rewrite, taken from the Key in the QueryRewrites map
+	Name string `toml:"-"`
+	// Regexp is the compiled version of Match
+	Regexp *regexp.Regexp `toml:"-"`
+}
+
+// RemoteWriteOptions describes how remote_write requests are buffered before they are
+// forwarded to the origin
+type RemoteWriteOptions struct {
+	// Enabled buffers the origin's remote_write requests, rather than proxying them directly
+	Enabled bool `toml:"enabled"`
+	// MaxBufferedRequests is the number of requests that are buffered before further
+	// requests are rejected with a 503, so that agents retry them later
+	MaxBufferedRequests int `toml:"max_buffered_requests"`
+	// MaxBatchRequests is the number of buffered requests that are combined into each
+	// request to the origin
+	MaxBatchRequests int `toml:"max_batch_requests"`
+	// FlushIntervalMS is the interval in milliseconds at which buffered requests are
+	// forwarded, when fewer than MaxBatchRequests are buffered
+	FlushIntervalMS int `toml:"flush_interval_ms"`
+	// MinBackoffMS is the delay in milliseconds before a failed forward is first retried
+	MinBackoffMS int `toml:"min_backoff_ms"`
+	// MaxBackoffMS is the maximum delay in milliseconds between retries of a failed forward
+	MaxBackoffMS int `toml:"max_backoff_ms"`
+	// WAL writes each buffered request to the origin's cache before it is acknowledged, so
+	// requests that are not yet forwarded survive a restart
+	WAL bool `toml:"wal"`
+	// WALTTLSecs is how long a request written to the cache is retained before it is forwarded
+	WALTTLSecs int `toml:"wal_ttl_secs"`
+	// RelabelRules is a map of named rules that drop or keep the series of remote_write
+	// requests by their labels, before the requests are buffered
+	RelabelRules map[string]*RelabelRuleOptions `toml:"relabel_rules"`
+
+	// FlushInterval is the time.Duration representation of FlushIntervalMS
+	FlushInterval time.Duration `toml:"-"`
+	// MinBackoff is the time.Duration representation of MinBackoffMS
+	MinBackoff time.Duration `toml:"-"`
+	// MaxBackoff is the time.Duration representation of MaxBackoffMS
+	MaxBackoff time.Duration `toml:"-"`
+	// WALTTL is the time.Duration representation of WALTTLSecs
+	WALTTL time.Duration `toml:"-"`
+
+	// relabelOrder is the list of RelabelRules, sorted by name
+	relabelOrder []*RelabelRuleOptions
+}
+
+// Relabel rule actions
+const (
+	// RelabelActionDrop drops the series whose joined source label values match the regex
+	RelabelActionDrop = "drop"
+	// RelabelActionKeep drops the series whose joined source label values do not match the regex
+	RelabelActionKeep = "keep"
+)
+
+// RelabelRuleOptions describes a rule that drops or keeps series by their labels, in the
+// manner of a Prometheus relabel config
+type RelabelRuleOptions struct {
+	// Action is 'drop' or 'keep'
+	Action string `toml:"action"`
+	// SourceLabels are the names of the labels whose values are joined with Separator and
+	// matched against Regex. A label that is not present has an empty value
+	SourceLabels []string `toml:"source_labels"`
+	// Separator joins the values of the SourceLabels
+	Separator string `toml:"separator"`
+	// Regex is the regular expression that must match the entire joined value
+	Regex string `toml:"regex"`
+
+	// Name is the Name of the relabel rule, taken from the Key in the RelabelRules map
+	Name string `toml:"-"`
+	// Regexp is the compiled, anchored version of Regex
+	Regexp *regexp.Regexp `toml:"-"`
+}
+
+// DerivedQueryOptions describes a PromQL expression that is evaluated on a schedule
+type DerivedQueryOptions struct {
+	// Query is the PromQL expression to evaluate. Client queries must match it exactly
+	// (along with the step) to be served from the pre-aggregated results
+	Query string `toml:"query"`
+	// StepSecs is the query resolution step width in seconds
+	StepSecs int `toml:"step_secs"`
+	// RangeSecs is the width of the time range, ending now, that is kept warm in the cache
+	RangeSecs int `toml:"range_secs"`
+	// IntervalSecs is how often the query is evaluated
+	IntervalSecs int `toml:"interval_secs"`
+
+	// Name is the Name of the derived query, taken from the Key in the DerivedQueries map
+	Name string `toml:"-"`
+	// Step is the time.Duration representation of StepSecs
+	Step time.Duration `toml:"-"`
+	// Range is the time.Duration representation of RangeSecs
+	Range time.Duration `toml:"-"`
+	// Interval is the time.Duration representation of IntervalSecs
+	Interval time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		LookbackDeltaSecs: d.DefaultPrometheusLookbackDeltaSecs,
+		LookbackDelta:     time.Duration(d.DefaultPrometheusLookbackDeltaSecs) * time.Second,
+		DerivedQueries:    make(map[string]*DerivedQueryOptions),
+		QueryRewrites:     make(map[string]*QueryRewriteOptions),
+		RemoteWrite:       NewRemoteWriteOptions(),
+	}
+}
+
+// NewRemoteWriteOptions returns a new *RemoteWriteOptions with the default settings
+func NewRemoteWriteOptions() *RemoteWriteOptions {
+	o := &RemoteWriteOptions{
+		MaxBufferedRequests: d.DefaultRemoteWriteMaxBufferedRequests,
+		MaxBatchRequests:    d.DefaultRemoteWriteMaxBatchRequests,
+		FlushIntervalMS:     d.DefaultRemoteWriteFlushIntervalMS,
+		MinBackoffMS:        d.DefaultRemoteWriteMinBackoffMS,
+		MaxBackoffMS:        d.DefaultRemoteWriteMaxBackoffMS,
+		WALTTLSecs:          d.DefaultRemoteWriteWALTTLSecs,
+		RelabelRules:        make(map[string]*RelabelRuleOptions),
+	}
+	o.SetDurations()
+	return o
+}
+
+// NewRelabelRuleOptions returns a new *RelabelRuleOptions with the default settings
+func NewRelabelRuleOptions() *RelabelRuleOptions {
+	return &RelabelRuleOptions{
+		Separator: d.DefaultRelabelSeparator,
+		Regex:     d.DefaultRelabelRegex,
+	}
+}
+
+// NewDerivedQueryOptions returns a new *DerivedQueryOptions with the default settings
+func NewDerivedQueryOptions() *DerivedQueryOptions {
+	return &DerivedQueryOptions{
+		StepSecs:     d.DefaultDerivedQueryStepSecs,
+		RangeSecs:    d.DefaultDerivedQueryRangeSecs,
+		IntervalSecs: d.DefaultDerivedQueryIntervalSecs,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := NewOptions()
+	o2.LookbackDeltaSecs = o.LookbackDeltaSecs
+	o2.LookbackDelta = o.LookbackDelta
+	if o.ReplicaLabels != nil {
+		o2.ReplicaLabels = append([]string(nil), o.ReplicaLabels...)
+	}
+	for k, v := range o.DerivedQueries {
+		o2.DerivedQueries[k] = v.Clone()
+	}
+	for k, v := range o.QueryRewrites {
+		o2.QueryRewrites[k] = v.Clone()
+	}
+	o2.sortQueryRewrites()
+	if o.RemoteWrite != nil {
+		o2.RemoteWrite = o.RemoteWrite.Clone()
+	}
+	o2.RemoteReadCacheEnabled = o.RemoteReadCacheEnabled
+	return o2
+}
+
+// Clone returns an exact copy of the subject *RemoteWriteOptions
+func (o *RemoteWriteOptions) Clone() *RemoteWriteOptions {
+	o2 := *o
+	o2.RelabelRules = make(map[string]*RelabelRuleOptions, len(o.RelabelRules))
+	for k, v := range o.RelabelRules {
+		o2.RelabelRules[k] = v.Clone()
+	}
+	o2.sortRelabelRules()
+	return &o2
+}
+
+// Clone returns an exact copy of the subject *RelabelRuleOptions
+func (o *RelabelRuleOptions) Clone() *RelabelRuleOptions {
+	return &RelabelRuleOptions{
+		Action:       o.Action,
+		SourceLabels: append([]string(nil), o.SourceLabels...),
+		Separator:    o.Separator,
+		Regex:        o.Regex,
+		Name:         o.Name,
+		Regexp:       o.Regexp,
+	}
+}
+
+// Compile compiles the Regex of each of the RelabelRules and determines the order in
+// which they are applied
+func (o *RemoteWriteOptions) Compile() error {
+	for k, v := range o.RelabelRules {
+		v.Name = k
+		if v.Action != RelabelActionDrop && v.Action != RelabelActionKeep {
+			return fmt.Errorf("invalid action '%s' for relabel rule %s", v.Action, k)
+		}
+		if len(v.SourceLabels) == 0 {
+			return fmt.Errorf("missing source_labels for relabel rule %s", k)
+		}
+		re, err := regexp.Compile("^(?:" + v.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex for relabel rule %s: %s", k, err.Error())
+		}
+		v.Regexp = re
+	}
+	o.sortRelabelRules()
+	return nil
+}
+
+func (o *RemoteWriteOptions) sortRelabelRules() {
+	o.relabelOrder = make([]*RelabelRuleOptions, 0, len(o.RelabelRules))
+	for _, v := range o.RelabelRules {
+		if v.Regexp != nil {
+			o.relabelOrder = append(o.relabelOrder, v)
+		}
+	}
+	sort.Slice(o.relabelOrder, func(i, j int) bool {
+		return o.relabelOrder[i].Name < o.relabelOrder[j].Name
+	})
+}
+
+// Relabels returns true if any relabel rules are configured
+func (o *RemoteWriteOptions) Relabels() bool {
+	return o != nil && len(o.relabelOrder) > 0
+}
+
+// Drop applies the RelabelRules, in order of their names, to the labels of a series, and
+// returns the name of the first rule that drops the series, or an empty string if it is kept
+func (o *RemoteWriteOptions) Drop(labels map[string]string) string {
+	if o == nil {
+		return ""
+	}
+	for _, v := range o.relabelOrder {
+		vals := make([]string, len(v.SourceLabels))
+		for i, l := range v.SourceLabels {
+			vals[i] = labels[l]
+		}
+		if v.Regexp.MatchString(strings.Join(vals, v.Separator)) == (v.Action == RelabelActionDrop) {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// Validate returns an error if the RemoteWriteOptions contain invalid values
+func (o *RemoteWriteOptions) Validate() error {
+	if o.MaxBufferedRequests <= 0 || o.MaxBatchRequests <= 0 || o.FlushIntervalMS <= 0 {
+		return errors.New("remote_write max_buffered_requests, max_batch_requests and " +
+			"flush_interval_ms must be positive")
+	}
+	if o.MinBackoffMS <= 0 || o.MaxBackoffMS < o.MinBackoffMS {
+		return errors.New("remote_write min_backoff_ms must be positive, " +
+			"and must not exceed max_backoff_ms")
+	}
+	if o.WAL && o.WALTTLSecs <= 0 {
+		return errors.New("remote_write wal_ttl_secs must be positive")
+	}
+	return nil
+}
+
+// SetDurations populates the synthesized time.Duration values from their *MS and *Secs
+// counterparts
+func (o *RemoteWriteOptions) SetDurations() {
+	o.FlushInterval = time.Duration(o.FlushIntervalMS) * time.Millisecond
+	o.MinBackoff = time.Duration(o.MinBackoffMS) * time.Millisecond
+	o.MaxBackoff = time.Duration(o.MaxBackoffMS) * time.Millisecond
+	o.WALTTL = time.Duration(o.WALTTLSecs) * time.Second
+}
+
+// Clone returns an exact copy of the subject *QueryRewriteOptions
+func (o *QueryRewriteOptions) Clone() *QueryRewriteOptions {
+	return &QueryRewriteOptions{
+		Match:       o.Match,
+		Replacement: o.Replacement,
+		Name:        o.Name,
+		Regexp:      o.Regexp,
+	}
+}
+
+// Compile compiles the Match expression of each of the QueryRewrites and
+// determines the order in which they are applied
+func (o *Options) Compile() error {
+	for k, v := range o.QueryRewrites {
+		v.Name = k
+		if v.Match == "" {
+			return fmt.Errorf("missing match for query rewrite %s", k)
+		}
+		re, err := regexp.Compile(v.Match)
+		if err != nil {
+			return fmt.Errorf("invalid match for query rewrite %s: %s", k, err.Error())
+		}
+		v.Regexp = re
+	}
+	o.sortQueryRewrites()
+	return nil
+}
+
+func (o *Options) sortQueryRewrites() {
+	o.queryRewriteOrder = make([]*QueryRewriteOptions, 0, len(o.QueryRewrites))
+	for _, v := range o.QueryRewrites {
+		if v.Regexp != nil {
+			o.queryRewriteOrder = append(o.queryRewriteOrder, v)
+		}
+	}
+	sort.Slice(o.queryRewriteOrder, func(i, j int) bool {
+		return o.queryRewriteOrder[i].Name < o.queryRewriteOrder[j].Name
+	})
+}
+
+// RewriteQuery applies the QueryRewrites, in order of their names, to the provided
+// query expression and returns the result along with the names of the rewrites applied
+func (o *Options) RewriteQuery(query string) (string, []string) {
+	if o == nil || len(o.queryRewriteOrder) == 0 {
+		return query, nil
+	}
+	var applied []string
+	for _, v := range o.queryRewriteOrder {
+		if !v.Regexp.MatchString(query) {
+			continue
+		}
+		query = v.Regexp.ReplaceAllString(query, v.Replacement)
+		applied = append(applied, v.Name)
+	}
+	return query, applied
+}
+
+// Clone returns an exact copy of the subject *DerivedQueryOptions
+func (o *DerivedQueryOptions) Clone() *DerivedQueryOptions {
+	return &DerivedQueryOptions{
+		Query:        o.Query,
+		StepSecs:     o.StepSecs,
+		RangeSecs:    o.RangeSecs,
+		IntervalSecs: o.IntervalSecs,
+		Name:         o.Name,
+		Step:         o.Step,
+		Range:        o.Range,
+		Interval:     o.Interval,
+	}
+}
+
+// Validate returns an error if the DerivedQueryOptions contain invalid values
+func (o *DerivedQueryOptions) Validate() error {
+	if o.Query == "" {
+		return fmt.Errorf("missing query for derived query %s", o.Name)
+	}
+	if o.StepSecs <= 0 || o.RangeSecs <= 0 || o.IntervalSecs <= 0 {
+		return fmt.Errorf("step_secs, range_secs and interval_secs must be positive for derived query %s",
+			o.Name)
+	}
+	if o.RangeSecs < o.StepSecs {
+		return fmt.Errorf("range_secs must be at least step_secs for derived query %s", o.Name)
+	}
+	return nil
+}
+
+// SetDurations populates the synthesized time.Duration values from their *Secs counterparts
+func (o *Options) SetDurations() {
+	o.LookbackDelta = time.Duration(o.LookbackDeltaSecs) * time.Second
+	for _, dq := range o.DerivedQueries {
+		dq.Step = time.Duration(dq.StepSecs) * time.Second
+		dq.Range = time.Duration(dq.RangeSecs) * time.Second
+		dq.Interval = time.Duration(dq.IntervalSecs) * time.Second
+	}
+	if o.RemoteWrite != nil {
+		o.RemoteWrite.SetDurations()
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+
+	o := NewOptions()
+	dq := NewDerivedQueryOptions()
+	dq.Query = "up"
+	o.DerivedQueries["test"] = dq
+	o.SetDurations()
+
+	o2 := o.Clone()
+	dq2, ok := o2.DerivedQueries["test"]
+	if !ok {
+		t.Fatal("expected derived query in clone")
+	}
+	if dq2 == dq {
+		t.Error("expected distinct derived query pointers")
+	}
+	if dq2.Query != "up" || dq2.Step != time.Minute {
+		t.Errorf("expected %s/%s got %s/%s", "up", time.Minute, dq2.Query, dq2.Step)
+	}
+
+}
+
+func TestValidate(t *testing.T) {
+
+	dq := NewDerivedQueryOptions()
+	dq.Name = "test"
+	if err := dq.Validate(); err == nil {
+		t.Error("expected error for missing query")
+	}
+
+	dq.Query = "up"
+	if err := dq.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	dq.IntervalSecs = 0
+	if err := dq.Validate(); err == nil {
+		t.Error("expected error for invalid interval")
+	}
+
+	dq.IntervalSecs = 60
+	dq.RangeSecs = 30
+	if err := dq.Validate(); err == nil {
+		t.Error("expected error for range smaller than step")
+	}
+
+}
+
+func TestRemoteWriteOptions(t *testing.T) {
+
+	o := NewOptions()
+	o.RemoteWrite.Enabled = true
+	o.RemoteWrite.FlushIntervalMS = 250
+	o.RemoteReadCacheEnabled = true
+	o.SetDurations()
+	if o.RemoteWrite.FlushInterval != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.RemoteWrite.FlushInterval)
+	}
+
+	o2 := o.Clone()
+	if o2.RemoteWrite == o.RemoteWrite {
+		t.Error("expected distinct remote_write pointers")
+	}
+	if !o2.RemoteWrite.Enabled || o2.RemoteWrite.FlushInterval != 250*time.Millisecond {
+		t.Errorf("unexpected clone %v", o2.RemoteWrite)
+	}
+	if !o2.RemoteReadCacheEnabled {
+		t.Errorf("expected %t got %t", true, o2.RemoteReadCacheEnabled)
+	}
+
+	rw := NewRemoteWriteOptions()
+	if err := rw.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	rw.MaxBatchRequests = 0
+	if err := rw.Validate(); err == nil {
+		t.Error("expected error for invalid max_batch_requests")
+	}
+
+	rw.MaxBatchRequests = 10
+	rw.MaxBackoffMS = rw.MinBackoffMS - 1
+	if err := rw.Validate(); err == nil {
+		t.Error("expected error for max_backoff_ms smaller than min_backoff_ms")
+	}
+
+	rw.MaxBackoffMS = rw.MinBackoffMS
+	rw.WAL = true
+	rw.WALTTLSecs = 0
+	if err := rw.Validate(); err == nil {
+		t.Error("expected error for invalid wal_ttl_secs")
+	}
+
+}
+
+func TestRelabelRules(t *testing.T) {
+
+	rw := NewRemoteWriteOptions()
+	if rw.Relabels() || rw.Drop(map[string]string{"__name__": "up"}) != "" {
+		t.Error("expected no relabel rules")
+	}
+
+	keep := NewRelabelRuleOptions()
+	keep.Action = RelabelActionKeep
+	keep.SourceLabels = []string{"env", "cluster"}
+	keep.Regex = "prod;.+"
+	rw.RelabelRules["b"] = keep
+	drop := NewRelabelRuleOptions()
+	drop.Action = RelabelActionDrop
+	drop.SourceLabels = []string{"__name__"}
+	drop.Regex = "debug_.*"
+	rw.RelabelRules["a"] = drop
+	if err := rw.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	rw = rw.Clone()
+	tests := []struct {
+		labels   map[string]string
+		expected string
+	}{
+		{map[string]string{"__name__": "up", "env": "prod", "cluster": "east"}, ""},
+		{map[string]string{"__name__": "debug_up", "env": "prod", "cluster": "east"}, "a"},
+		{map[string]string{"__name__": "up", "env": "dev", "cluster": "east"}, "b"},
+		// the regex is anchored, and a missing label has an empty value
+		{map[string]string{"__name__": "up", "env": "preprod", "cluster": "east"}, "b"},
+		{map[string]string{"__name__": "up", "env": "prod"}, "b"},
+	}
+	for i, test := range tests {
+		if rule := rw.Drop(test.labels); rule != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, rule)
+		}
+	}
+
+	drop = rw.RelabelRules["a"]
+	drop.Action = "replace"
+	if err := rw.Compile(); err == nil {
+		t.Error("expected error for invalid action")
+	}
+	drop.Action = RelabelActionDrop
+	drop.SourceLabels = nil
+	if err := rw.Compile(); err == nil {
+		t.Error("expected error for missing source_labels")
+	}
+	drop.SourceLabels = []string{"__name__"}
+	drop.Regex = "("
+	if err := rw.Compile(); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+
+}
+
+func TestRewriteQuery(t *testing.T) {
+
+	o := NewOptions()
+	q, applied := o.RewriteQuery("up")
+	if q != "up" || applied != nil {
+		t.Errorf("expected %s got %s", "up", q)
+	}
+
+	o.QueryRewrites["b"] = &QueryRewriteOptions{Match: `rate\((\w+)\[5m\]\)`, Replacement: "${1}:rate5m"}
+	o.QueryRewrites["a"] = &QueryRewriteOptions{Match: `http_`, Replacement: ""}
+	if err := o.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, applied = o.Clone().RewriteQuery("sum(rate(http_requests_total[5m]))")
+	if q != "sum(requests_total:rate5m)" {
+		t.Errorf("expected %s got %s", "sum(requests_total:rate5m)", q)
+	}
+	if len(applied) != 2 || applied[0] != "a" || applied[1] != "b" {
+		t.Errorf("expected %v got %v", []string{"a", "b"}, applied)
+	}
+
+	o.QueryRewrites["c"] = &QueryRewriteOptions{Match: `(`}
+	if err := o.Compile(); err == nil {
+		t.Error("expected error for invalid match")
+	}
+
+	o.QueryRewrites["c"] = &QueryRewriteOptions{}
+	if err := o.Compile(); err == nil {
+		t.Error("expected error for missing match")
+	}
+
+}
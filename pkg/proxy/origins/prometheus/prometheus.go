@@ -38,6 +38,11 @@ import (
 )
 
 var _ origins.Client = (*Client)(nil)
+var _ origins.KeyVersioner = (*Client)(nil)
+
+// cacheKeyVersion must be incremented whenever a change to this provider makes the objects
+// it cached with earlier versions incompatible (e.g., a change to how they are keyed or merged)
+const cacheKeyVersion = 1
 
 // Prometheus API
 const (
@@ -78,6 +83,8 @@ type Client struct {
 	healthHeaders      http.Header
 	healthMethod       string
 	router             http.Handler
+	remoteWriter       *remoteWriter
+	originClient       origins.Client
 }
 
 // NewClient returns a new Client Instance
@@ -85,8 +92,13 @@ func NewClient(name string, oc *oo.Options, router http.Handler,
 	cache cache.Cache) (origins.Client, error) {
 	c, err := proxy.NewHTTPClient(oc)
 	bur := urls.FromParts(oc.Scheme, oc.Host, oc.PathPrefix, "", "")
-	return &Client{name: name, config: oc, router: router, cache: cache,
-		webClient: c, baseUpstreamURL: bur}, err
+	client := &Client{name: name, config: oc, router: router, cache: cache,
+		webClient: c, baseUpstreamURL: bur}
+	if oc.Prometheus != nil && oc.Prometheus.RemoteWrite != nil &&
+		oc.Prometheus.RemoteWrite.Enabled {
+		client.remoteWriter = newRemoteWriter(client, oc.Prometheus.RemoteWrite)
+	}
+	return client, err
 }
 
 // SetCache sets the Cache object the client will use for caching origin content
@@ -109,6 +121,11 @@ func (c *Client) Name() string {
 	return c.name
 }
 
+// KeyVersion returns the version of the Client's cache key space
+func (c *Client) KeyVersion() int {
+	return cacheKeyVersion
+}
+
 // Cache returns and handle to the Cache instance used by the Client
 func (c *Client) Cache() cache.Cache {
 	return c.cache
@@ -119,6 +136,29 @@ func (c *Client) Router() http.Handler {
 	return c.router
 }
 
+// SetOriginClient sets the Client that is registered for the origin, when it is a Client
+// of another origin type that embeds this one, so that requests made by background tasks
+// are processed with that Client's implementation of the TimeseriesClient interface
+func (c *Client) SetOriginClient(oc origins.Client) {
+	c.originClient = oc
+}
+
+// self returns the Client that is registered for the origin
+func (c *Client) self() origins.Client {
+	if c.originClient != nil {
+		return c.originClient
+	}
+	return c
+}
+
+// backfillTolerance returns the configured Backfill Tolerance for the Client's origin
+func (c *Client) backfillTolerance() time.Duration {
+	if c.config == nil {
+		return 0
+	}
+	return c.config.BackfillTolerance
+}
+
 // parseTime converts a query time URL parameter to time.Time.
 // Copied from https://github.com/prometheus/prometheus/blob/master/web/api/v1/api.go
 func parseTime(s string) (time.Time, error) {
@@ -186,9 +226,31 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		return nil, errors.MissingURLParam(upStep)
 	}
 
-	if strings.Contains(trq.Statement, " offset ") {
-		trq.IsOffset = true
+	qm := parseQueryModifiers(trq.Statement)
+	if qm.isRelative() {
+		// @ start() and @ end() resolve against the requested range, so results for
+		// partial ranges can't be merged with one another
+		return nil, errors.ErrRelativeTimeModifier
+	}
+
+	// pad delta fetches by the lookback delta or the largest range selector, since those
+	// determine how far back a late-arriving sample can change an already-cached point
+	if c.config != nil && c.config.Prometheus != nil && c.config.Prometheus.LookbackDelta > 0 {
+		trq.ExtentPadding = c.config.Prometheus.LookbackDelta
+		if qm.maxRange > trq.ExtentPadding {
+			trq.ExtentPadding = qm.maxRange
+		}
+	}
+
+	if qm.hasOffset || qm.hasAtTimestamp {
 		trq.FastForwardDisable = true
+		if qm.minOffset < 0 {
+			// a negative offset reads data from after each step's timestamp, so the newest
+			// points must be held back from the cache by at least the size of the offset
+			trq.BackfillTolerance = trq.GetBackfillTolerance(c.backfillTolerance()) - qm.minOffset
+		} else {
+			trq.IsOffset = true
+		}
 	}
 
 	if strings.Contains(trq.Statement, timeseries.FastForwardUserDisableFlag) {
@@ -385,7 +385,7 @@ func TestParseTimeRangeQueryWithOffset(t *testing.T) {
 		Host:   "blah.com",
 		Path:   "/",
 		RawQuery: url.Values(map[string][]string{
-			"query": {`up and has offset `},
+			"query": {`up and has offset 5m`},
 			"start": {strconv.Itoa(int(time.Now().Add(time.Duration(-6) * time.Hour).Unix()))},
 			"end":   {strconv.Itoa(int(time.Now().Unix()))},
 			"step":  {"15"},
@@ -404,6 +404,49 @@ func TestParseTimeRangeQueryWithOffset(t *testing.T) {
 
 }
 
+func TestParseTimeRangeQueryExtentPadding(t *testing.T) {
+	qp := url.Values(map[string][]string{
+		"query": {`up`},
+		"start": {strconv.Itoa(int(time.Now().Add(time.Duration(-6) * time.Hour).Unix()))},
+		"end":   {strconv.Itoa(int(time.Now().Unix()))},
+		"step":  {"15"},
+	})
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: "blah.com", Path: "/"}}
+	client := &Client{config: oo.NewOptions()}
+
+	tests := []struct {
+		query    string
+		expected time.Duration
+	}{
+		{`up`, 5 * time.Minute},
+		{`rate(up[1m])`, 5 * time.Minute},
+		{`rate(up[1h])`, time.Hour},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			qp.Set(upQuery, test.query)
+			req.URL.RawQuery = qp.Encode()
+			res, err := client.ParseTimeRangeQuery(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.ExtentPadding != test.expected {
+				t.Errorf("expected %s got %s", test.expected, res.ExtentPadding)
+			}
+		})
+	}
+
+	client.config.Prometheus.LookbackDelta = 0
+	res, err := client.ParseTimeRangeQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExtentPadding != 0 {
+		t.Errorf("expected %s got %s", time.Duration(0), res.ExtentPadding)
+	}
+}
+
 func TestSetCache(t *testing.T) {
 	c, err := NewClient("test", oo.NewOptions(), nil, nil)
 	if err != nil {
@@ -415,6 +458,18 @@ func TestSetCache(t *testing.T) {
 	}
 }
 
+func TestSetOriginClient(t *testing.T) {
+	c := &Client{name: "test"}
+	if c.self() != c {
+		t.Error("expected the client to be its own origin client")
+	}
+	oc := &Client{name: "outer"}
+	c.SetOriginClient(oc)
+	if c.self() != oc {
+		t.Error("expected the provided origin client")
+	}
+}
+
 func TestRouter(t *testing.T) {
 	c, err := NewClient("test", oo.NewOptions(), nil, nil)
 	if err != nil {
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	tt "github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
+)
+
+// queryModifiers describes the constructs in a PromQL expression that affect
+// which points in time the expression reads when it is evaluated
+type queryModifiers struct {
+	// hasOffset is true if the expression contains at least one offset modifier
+	hasOffset bool
+	// minOffset is the smallest offset in the expression (negative offsets look forward)
+	minOffset time.Duration
+	// maxOffset is the largest offset in the expression
+	maxOffset time.Duration
+	// hasAtTimestamp is true if the expression pins a selector to a fixed time with '@ <timestamp>'
+	hasAtTimestamp bool
+	// hasAtStartEnd is true if the expression uses '@ start()' or '@ end()', which are
+	// relative to the time range of the request
+	hasAtStartEnd bool
+	// hasSubquery is true if the expression contains a subquery
+	hasSubquery bool
+	// maxRange is the largest range selector or subquery range in the expression
+	maxRange time.Duration
+}
+
+// parseQueryModifiers scans the PromQL expression for offset and @ modifiers,
+// range selectors and subqueries, ignoring the contents of string literals and comments
+func parseQueryModifiers(q string) *queryModifiers {
+	qm := &queryModifiers{}
+	l := len(q)
+	for i := 0; i < l; i++ {
+		switch c := q[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			i = skipString(q, i)
+		case c == '#':
+			for i < l && q[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			j := strings.IndexByte(q[i:], ']')
+			if j < 0 {
+				return qm
+			}
+			qm.parseRange(q[i+1 : i+j])
+			i += j
+		case c == '@':
+			i = qm.parseAt(q, i+1)
+		case isIdentStart(c):
+			j := i
+			for j < l && isIdentChar(q[j]) {
+				j++
+			}
+			if strings.EqualFold(q[i:j], "offset") {
+				j = qm.parseOffset(q, j)
+			}
+			i = j - 1
+		}
+	}
+	return qm
+}
+
+// isRelative returns true if the expression's results depend on the requested
+// time range, rather than only on the evaluation timestamp of each step
+func (qm *queryModifiers) isRelative() bool {
+	return qm.hasAtStartEnd
+}
+
+func (qm *queryModifiers) parseRange(s string) {
+	parts := strings.SplitN(s, ":", 2)
+	d, err := parsePromDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return
+	}
+	if len(parts) == 2 {
+		qm.hasSubquery = true
+	}
+	if d > qm.maxRange {
+		qm.maxRange = d
+	}
+}
+
+func (qm *queryModifiers) parseAt(q string, i int) int {
+	i = skipSpace(q, i)
+	rest := q[i:]
+	if strings.HasPrefix(rest, "start()") || strings.HasPrefix(rest, "end()") {
+		qm.hasAtStartEnd = true
+		return i + strings.IndexByte(rest, ')')
+	}
+	j := i
+	for j < len(q) && (isDigit(q[j]) || q[j] == '.' || q[j] == '-' || q[j] == '+' ||
+		q[j] == 'e' || q[j] == 'E') {
+		j++
+	}
+	if _, err := strconv.ParseFloat(q[i:j], 64); err == nil {
+		qm.hasAtTimestamp = true
+	}
+	return j - 1
+}
+
+func (qm *queryModifiers) parseOffset(q string, i int) int {
+	i = skipSpace(q, i)
+	neg := false
+	if i < len(q) && (q[i] == '-' || q[i] == '+') {
+		neg = q[i] == '-'
+		i = skipSpace(q, i+1)
+	}
+	j := i
+	for j < len(q) && (isDigit(q[j]) || isIdentChar(q[j])) {
+		j++
+	}
+	d, err := parsePromDuration(q[i:j])
+	if err != nil {
+		return i
+	}
+	if neg {
+		d = -d
+	}
+	if !qm.hasOffset || d < qm.minOffset {
+		qm.minOffset = d
+	}
+	if !qm.hasOffset || d > qm.maxOffset {
+		qm.maxOffset = d
+	}
+	qm.hasOffset = true
+	return j
+}
+
+// parsePromDuration parses a PromQL duration, which may combine several units (e.g., 1h30m)
+func parsePromDuration(s string) (time.Duration, error) {
+	if s == "" || !isDigit(s[0]) {
+		return tt.ParseDuration(s)
+	}
+	var d time.Duration
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		j := i
+		for j < len(s) && !isDigit(s[j]) {
+			j++
+		}
+		if i == 0 {
+			return tt.ParseDuration(s)
+		}
+		d2, err := tt.ParseDuration(s[:j])
+		if err != nil {
+			return 0, err
+		}
+		d += d2
+		s = s[j:]
+	}
+	return d, nil
+}
+
+func skipString(q string, i int) int {
+	quote := q[i]
+	for i++; i < len(q); i++ {
+		if q[i] == '\\' && quote != '`' {
+			i++
+			continue
+		}
+		if q[i] == quote {
+			return i
+		}
+	}
+	return i
+}
+
+func skipSpace(q string, i int) int {
+	for i < len(q) && (q[i] == ' ' || q[i] == '\t' || q[i] == '\n' || q[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == ':'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
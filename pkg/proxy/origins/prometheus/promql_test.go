@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/errors"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+func TestParseQueryModifiers(t *testing.T) {
+
+	tests := []struct {
+		query          string
+		hasOffset      bool
+		minOffset      time.Duration
+		maxOffset      time.Duration
+		hasAtTimestamp bool
+		hasAtStartEnd  bool
+		hasSubquery    bool
+		maxRange       time.Duration
+	}{
+		{query: `up`},
+		{query: `up{job="a offset 5m"}`},
+		{query: `offset_total{offset="x"}`},
+		{query: "up\n\tOFFSET 1h", hasOffset: true, minOffset: time.Hour, maxOffset: time.Hour},
+		{query: `rate(x[5m] offset -30m) / rate(x[1h30m] offset 1d)`, hasOffset: true,
+			minOffset: -30 * time.Minute, maxOffset: 24 * time.Hour, maxRange: 90 * time.Minute},
+		{query: `max_over_time(rate(x[5m])[1h:1m])`, hasSubquery: true, maxRange: time.Hour},
+		{query: `max_over_time(rate(x[5m])[2h:])`, hasSubquery: true, maxRange: 2 * time.Hour},
+		{query: `up @ 1609746000`, hasAtTimestamp: true},
+		{query: `rate(x[5m] @ end())`, hasAtStartEnd: true, maxRange: 5 * time.Minute},
+		{query: `up @ start() # offset 5m`, hasAtStartEnd: true},
+		{query: `label_replace(up, "a", "[1h]", "b", "offset 3h")`},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			qm := parseQueryModifiers(test.query)
+			if qm.hasOffset != test.hasOffset {
+				t.Errorf("expected %t got %t", test.hasOffset, qm.hasOffset)
+			}
+			if qm.minOffset != test.minOffset {
+				t.Errorf("expected %s got %s", test.minOffset, qm.minOffset)
+			}
+			if qm.maxOffset != test.maxOffset {
+				t.Errorf("expected %s got %s", test.maxOffset, qm.maxOffset)
+			}
+			if qm.hasAtTimestamp != test.hasAtTimestamp {
+				t.Errorf("expected %t got %t", test.hasAtTimestamp, qm.hasAtTimestamp)
+			}
+			if qm.hasAtStartEnd != test.hasAtStartEnd {
+				t.Errorf("expected %t got %t", test.hasAtStartEnd, qm.hasAtStartEnd)
+			}
+			if qm.hasSubquery != test.hasSubquery {
+				t.Errorf("expected %t got %t", test.hasSubquery, qm.hasSubquery)
+			}
+			if qm.maxRange != test.maxRange {
+				t.Errorf("expected %s got %s", test.maxRange, qm.maxRange)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeQueryWithModifiers(t *testing.T) {
+
+	newRequest := func(query string) *http.Request {
+		return &http.Request{URL: &url.URL{
+			Scheme: "https",
+			Host:   "blah.com",
+			Path:   "/",
+			RawQuery: url.Values(map[string][]string{
+				"query": {query},
+				"start": {strconv.Itoa(int(time.Now().Add(time.Duration(-6) * time.Hour).Unix()))},
+				"end":   {strconv.Itoa(int(time.Now().Unix()))},
+				"step":  {"15"},
+			}).Encode(),
+		}}
+	}
+
+	oc := oo.NewOptions()
+	oc.BackfillTolerance = time.Minute
+	client := &Client{config: oc}
+
+	_, err := client.ParseTimeRangeQuery(newRequest(`rate(x[5m] @ start())`))
+	if err != errors.ErrRelativeTimeModifier {
+		t.Errorf("expected %v got %v", errors.ErrRelativeTimeModifier, err)
+	}
+
+	trq, err := client.ParseTimeRangeQuery(newRequest(`up offset -10m`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.IsOffset || !trq.FastForwardDisable {
+		t.Errorf("expected false/true got %t/%t", trq.IsOffset, trq.FastForwardDisable)
+	}
+	if trq.BackfillTolerance != 11*time.Minute {
+		t.Errorf("expected %s got %s", 11*time.Minute, trq.BackfillTolerance)
+	}
+
+	trq, err = client.ParseTimeRangeQuery(newRequest(`up @ 1609746000`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trq.IsOffset || !trq.FastForwardDisable {
+		t.Errorf("expected true/true got %t/%t", trq.IsOffset, trq.FastForwardDisable)
+	}
+
+	trq, err = client.ParseTimeRangeQuery(newRequest(`sum(rate(x{path="/offset 5m"}[5m]))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.IsOffset || trq.FastForwardDisable {
+		t.Errorf("expected false/false got %t/%t", trq.IsOffset, trq.FastForwardDisable)
+	}
+
+}
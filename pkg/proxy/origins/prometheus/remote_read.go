@@ -0,0 +1,417 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+const (
+	mnRead = "read"
+
+	// readResponseTypeSamples is the SAMPLES response type of a remote_read request, for which
+	// the origin responds with a snappy-compressed ReadResponse. It is the default when a
+	// request does not list its accepted response types
+	readResponseTypeSamples = 0
+
+	// maxRemoteReadBytes is the largest remote_read request body that is read for caching
+	maxRemoteReadBytes = 1 << 20
+)
+
+// errUncacheableRead is returned when a remote_read request can't be served from the cache,
+// because it has more than one query, or does not accept the SAMPLES response type
+var errUncacheableRead = errors.New("uncacheable remote_read request")
+
+// errInvalidReadRequest is returned when a remote_read request is not a valid ReadRequest
+var errInvalidReadRequest = errors.New("invalid remote_read request encoding")
+
+// errInvalidReadResponse is returned when a remote_read response is not a valid ReadResponse
+var errInvalidReadResponse = errors.New("invalid remote_read response encoding")
+
+// readQuery is the Query of a remote_read ReadRequest
+type readQuery struct {
+	// start and end are the query's inclusive time range, in milliseconds
+	start, end int64
+	// matchers are the encodings of the query's LabelMatchers
+	matchers [][]byte
+	// hints is the encoding of the query's ReadHints, without their time range, which is
+	// nil when the query has no hints
+	hints []byte
+}
+
+// parseReadRequest returns the query of a protobuf-encoded ReadRequest, or errUncacheableRead
+// when the request has more than one query or does not accept the SAMPLES response type
+func parseReadRequest(b []byte) (*readQuery, error) {
+	var q *readQuery
+	var n int
+	var types []uint64
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return nil, err
+		}
+		i = f.end
+		switch {
+		// ReadRequest field 1 is a repeated Query
+		case f.num == 1 && f.wire == wireBytes:
+			n++
+			if q, err = parseReadQuery(f.data); err != nil {
+				return nil, err
+			}
+		// field 2 is the repeated accepted ResponseType, which may be packed
+		case f.num == 2 && f.wire == wireVarint:
+			types = append(types, f.value)
+		case f.num == 2 && f.wire == wireBytes:
+			for j := 0; j < len(f.data); {
+				v, l := binary.Uvarint(f.data[j:])
+				if l <= 0 {
+					return nil, errInvalidReadRequest
+				}
+				types = append(types, v)
+				j += l
+			}
+		}
+	}
+	if n != 1 {
+		return nil, errUncacheableRead
+	}
+	if len(types) == 0 {
+		return q, nil
+	}
+	for _, t := range types {
+		if t == readResponseTypeSamples {
+			return q, nil
+		}
+	}
+	return nil, errUncacheableRead
+}
+
+// parseReadQuery returns the readQuery of a protobuf-encoded Query message
+func parseReadQuery(b []byte) (*readQuery, error) {
+	q := &readQuery{}
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return nil, err
+		}
+		i = f.end
+		switch {
+		case f.num == 1 && f.wire == wireVarint:
+			q.start = int64(f.value)
+		case f.num == 2 && f.wire == wireVarint:
+			q.end = int64(f.value)
+		case f.num == 3 && f.wire == wireBytes:
+			q.matchers = append(q.matchers, f.data)
+		case f.num == 4 && f.wire == wireBytes:
+			// ReadHints fields 3 and 4 are its time range, which is set for each fetch
+			q.hints = make([]byte, 0, len(f.data))
+			for j := 0; j < len(f.data); {
+				hf, err := nextField(f.data, j)
+				if err != nil {
+					return nil, err
+				}
+				if hf.num != 3 && hf.num != 4 {
+					q.hints = append(q.hints, f.data[j:hf.end]...)
+				}
+				j = hf.end
+			}
+		}
+	}
+	return q, nil
+}
+
+// encodeReadRequest returns the protobuf encoding of a ReadRequest for the query over the
+// provided time range, which accepts only the SAMPLES response type
+func encodeReadRequest(q *readQuery, start, end int64) []byte {
+	var qb []byte
+	qb = appendVarintField(qb, 1, uint64(start))
+	qb = appendVarintField(qb, 2, uint64(end))
+	for _, m := range q.matchers {
+		qb = appendBytesField(qb, 3, m)
+	}
+	if q.hints != nil {
+		hb := append([]byte(nil), q.hints...)
+		hb = appendVarintField(hb, 3, uint64(start))
+		hb = appendVarintField(hb, 4, uint64(end))
+		qb = appendBytesField(qb, 4, hb)
+	}
+	b := appendBytesField(nil, 1, qb)
+	return appendBytesField(b, 2, []byte{readResponseTypeSamples})
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendVarintField(b []byte, num, v uint64) []byte {
+	return appendUvarint(appendUvarint(b, num<<3|wireVarint), v)
+}
+
+func appendBytesField(b []byte, num uint64, data []byte) []byte {
+	b = appendUvarint(appendUvarint(b, num<<3|wireBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+// readSample is a Sample of a remote_read TimeSeries. The value is retained as the
+// bits of its float64 encoding
+type readSample struct {
+	t int64
+	v uint64
+}
+
+// readSeries is a TimeSeries of a remote_read QueryResult
+type readSeries struct {
+	// labels is the encoding of the series' Label fields, which identifies the series
+	labels []byte
+	// names and values are the series' label names and values, in their encoded order
+	names, values []string
+	samples       []readSample
+}
+
+// readResult is the set of series of a remote_read QueryResult, keyed by their labels
+type readResult map[string]*readSeries
+
+// parseReadResponse merges the series of the protobuf-encoded ReadResponse into the readResult
+func (rr readResult) parseReadResponse(b []byte) error {
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return errInvalidReadResponse
+		}
+		i = f.end
+		// ReadResponse field 1 is a repeated QueryResult
+		if f.num != 1 || f.wire != wireBytes {
+			continue
+		}
+		if err := rr.parseQueryResult(f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseQueryResult merges the series of the protobuf-encoded QueryResult into the readResult
+func (rr readResult) parseQueryResult(b []byte) error {
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return errInvalidReadResponse
+		}
+		i = f.end
+		// QueryResult field 1 is a repeated TimeSeries
+		if f.num != 1 || f.wire != wireBytes {
+			continue
+		}
+		s, err := parseReadSeries(f.data)
+		if err != nil {
+			return err
+		}
+		rr.merge(s)
+	}
+	return nil
+}
+
+// parseReadSeries returns the readSeries of a protobuf-encoded TimeSeries. Fields other
+// than its labels and samples (e.g., exemplars) are not retained
+func parseReadSeries(b []byte) (*readSeries, error) {
+	s := &readSeries{}
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return nil, errInvalidReadResponse
+		}
+		if f.wire != wireBytes {
+			i = f.end
+			continue
+		}
+		switch f.num {
+		// TimeSeries field 1 is a repeated Label, with a name (1) and a value (2)
+		case 1:
+			s.labels = append(s.labels, b[i:f.end]...)
+			var name, value string
+			for j := 0; j < len(f.data); {
+				lf, err := nextField(f.data, j)
+				if err != nil {
+					return nil, errInvalidReadResponse
+				}
+				j = lf.end
+				switch {
+				case lf.num == 1 && lf.wire == wireBytes:
+					name = string(lf.data)
+				case lf.num == 2 && lf.wire == wireBytes:
+					value = string(lf.data)
+				}
+			}
+			s.names = append(s.names, name)
+			s.values = append(s.values, value)
+		// field 2 is a repeated Sample, with a double value (1) and a timestamp (2)
+		case 2:
+			var p readSample
+			for j := 0; j < len(f.data); {
+				sf, err := nextField(f.data, j)
+				if err != nil {
+					return nil, errInvalidReadResponse
+				}
+				j = sf.end
+				switch {
+				case sf.num == 1 && sf.wire == wireFixed64:
+					p.v = sf.value
+				case sf.num == 2 && sf.wire == wireVarint:
+					p.t = int64(sf.value)
+				}
+			}
+			s.samples = append(s.samples, p)
+		}
+		i = f.end
+	}
+	return s, nil
+}
+
+// merge adds the series' samples to the readResult, replacing the samples that have the
+// same timestamps, and sorts the series' samples by timestamp
+func (rr readResult) merge(s *readSeries) {
+	k := string(s.labels)
+	e, ok := rr[k]
+	if !ok {
+		e = &readSeries{labels: s.labels, names: s.names, values: s.values}
+		rr[k] = e
+	}
+	if len(e.samples) == 0 {
+		e.samples = append(e.samples, s.samples...)
+	} else {
+		m := make(map[int64]uint64, len(e.samples)+len(s.samples))
+		for _, p := range e.samples {
+			m[p.t] = p.v
+		}
+		for _, p := range s.samples {
+			m[p.t] = p.v
+		}
+		e.samples = e.samples[:0]
+		for t, v := range m {
+			e.samples = append(e.samples, readSample{t: t, v: v})
+		}
+	}
+	sort.Slice(e.samples, func(i, j int) bool { return e.samples[i].t < e.samples[j].t })
+}
+
+// series returns the readResult's series, sorted by their labels
+func (rr readResult) series() []*readSeries {
+	out := make([]*readSeries, 0, len(rr))
+	for _, s := range rr {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		for k := 0; k < len(a.names) && k < len(b.names); k++ {
+			if a.names[k] != b.names[k] {
+				return a.names[k] < b.names[k]
+			}
+			if a.values[k] != b.values[k] {
+				return a.values[k] < b.values[k]
+			}
+		}
+		if len(a.names) != len(b.names) {
+			return len(a.names) < len(b.names)
+		}
+		return bytes.Compare(a.labels, b.labels) < 0
+	})
+	return out
+}
+
+// encodeQueryResult returns the protobuf encoding of a QueryResult with the readResult's
+// samples that are within the inclusive time range. Series without samples in the time
+// range are omitted
+func (rr readResult) encodeQueryResult(start, end int64) []byte {
+	var b, sb, pb []byte
+	for _, s := range rr.series() {
+		sb = append(sb[:0], s.labels...)
+		n := 0
+		for _, p := range s.samples {
+			if p.t < start || p.t > end {
+				continue
+			}
+			n++
+			pb = append(pb[:0], 1<<3|wireFixed64)
+			pb = append(pb, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint64(pb[1:], p.v)
+			pb = appendVarintField(pb, 2, uint64(p.t))
+			sb = appendBytesField(sb, 2, pb)
+		}
+		if n > 0 {
+			b = appendBytesField(b, 1, sb)
+		}
+	}
+	return b
+}
+
+// encodeReadResponse returns the protobuf encoding of a ReadResponse with the readResult's
+// samples that are within the inclusive time range
+func (rr readResult) encodeReadResponse(start, end int64) []byte {
+	return appendBytesField(nil, 1, rr.encodeQueryResult(start, end))
+}
+
+// readDocument is a remote_read query's cached series, and the extents of time for which
+// it has every sample
+type readDocument struct {
+	Extents timeseries.ExtentList `json:"extents"`
+	// Result is the protobuf encoding of a QueryResult with the cached series
+	Result []byte `json:"result"`
+}
+
+// msTime returns the time of the millisecond timestamp
+func msTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// timeMS returns the millisecond timestamp of the time
+func timeMS(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// missingExtents returns the extents of the inclusive time range, in milliseconds, that are
+// not in the provided list of extents
+func missingExtents(have timeseries.ExtentList, start, end int64) timeseries.ExtentList {
+	have = have.Clone()
+	sort.Sort(have)
+	var misses timeseries.ExtentList
+	cur := start
+	for _, e := range have {
+		s, t := timeMS(e.Start), timeMS(e.End)
+		if t < cur {
+			continue
+		}
+		if s > end {
+			break
+		}
+		if s > cur {
+			misses = append(misses, timeseries.Extent{Start: msTime(cur), End: msTime(s - 1)})
+		}
+		cur = t + 1
+	}
+	if cur <= end {
+		misses = append(misses, timeseries.Extent{Start: msTime(cur), End: msTime(end)})
+	}
+	return misses
+}
@@ -0,0 +1,286 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+
+	tctx "github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+func testReadLabel(name, value string) []byte {
+	return appendBytesField(nil, 1,
+		appendBytesField(appendBytesField(nil, 1, []byte(name)), 2, []byte(value)))
+}
+
+// testReadSeries returns a series with the label, and a sample every second of the time range
+func testReadSeries(value string, start, end int64) *readSeries {
+	s := &readSeries{labels: testReadLabel("job", value),
+		names: []string{"job"}, values: []string{value}}
+	for t := start - start%1000; t <= end; t += 1000 {
+		if t >= start {
+			s.samples = append(s.samples, readSample{t: t, v: math.Float64bits(float64(t))})
+		}
+	}
+	return s
+}
+
+func TestParseReadRequest(t *testing.T) {
+
+	matcher := appendBytesField(appendVarintField(nil, 1, 0), 2, []byte("__name__"))
+	hints := appendVarintField(nil, 1, 15000)
+	q := &readQuery{matchers: [][]byte{matcher}, hints: hints}
+
+	b := encodeReadRequest(q, 1000, 2000)
+	q2, err := parseReadRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q2.start != 1000 || q2.end != 2000 {
+		t.Errorf("expected %d-%d got %d-%d", 1000, 2000, q2.start, q2.end)
+	}
+	if len(q2.matchers) != 1 || !bytes.Equal(q2.matchers[0], matcher) {
+		t.Errorf("unexpected matchers %v", q2.matchers)
+	}
+	// the time range of the hints is removed
+	if !bytes.Equal(q2.hints, hints) {
+		t.Errorf("expected %v got %v", hints, q2.hints)
+	}
+
+	// requests with several queries aren't cacheable
+	qb := appendVarintField(nil, 1, 1000)
+	b = appendBytesField(appendBytesField(nil, 1, qb), 1, qb)
+	if _, err = parseReadRequest(b); err != errUncacheableRead {
+		t.Errorf("expected %v got %v", errUncacheableRead, err)
+	}
+
+	// nor are requests that accept only streamed chunks
+	b = appendVarintField(appendBytesField(nil, 1, qb), 2, 1)
+	if _, err = parseReadRequest(b); err != errUncacheableRead {
+		t.Errorf("expected %v got %v", errUncacheableRead, err)
+	}
+
+	// a packed list of accepted response types that includes samples is cacheable
+	b = appendBytesField(appendBytesField(nil, 1, qb), 2, []byte{1, 0})
+	if _, err = parseReadRequest(b); err != nil {
+		t.Error(err)
+	}
+
+	if _, err = parseReadRequest([]byte{0x0a, 0x05}); err == nil {
+		t.Error("expected error for truncated request")
+	}
+
+}
+
+func TestReadResult(t *testing.T) {
+
+	rr := make(readResult)
+	rr.merge(testReadSeries("b", 0, 5000))
+	rr.merge(testReadSeries("a", 3000, 4000))
+	rr.merge(testReadSeries("b", 3000, 8000))
+
+	s := rr.series()
+	if len(s) != 2 || s[0].values[0] != "a" || s[1].values[0] != "b" {
+		t.Fatalf("unexpected series %v", s)
+	}
+	// the overlapping samples are not duplicated
+	if len(s[1].samples) != 9 {
+		t.Errorf("expected %d got %d", 9, len(s[1].samples))
+	}
+
+	rr2 := make(readResult)
+	if err := rr2.parseReadResponse(rr.encodeReadResponse(4000, 6000)); err != nil {
+		t.Fatal(err)
+	}
+	s = rr2.series()
+	if len(s) != 2 || len(s[0].samples) != 1 || len(s[1].samples) != 3 {
+		t.Fatalf("unexpected series %v", s)
+	}
+	if s[1].samples[0].t != 4000 || math.Float64frombits(s[1].samples[0].v) != 4000 {
+		t.Errorf("unexpected sample %v", s[1].samples[0])
+	}
+
+	// series without samples in the time range are omitted
+	rr2 = make(readResult)
+	if err := rr2.parseReadResponse(rr.encodeReadResponse(7000, 9000)); err != nil {
+		t.Fatal(err)
+	}
+	if len(rr2) != 1 {
+		t.Errorf("expected %d got %d", 1, len(rr2))
+	}
+
+	if err := rr2.parseReadResponse([]byte{0x0a, 0x05}); err != errInvalidReadResponse {
+		t.Errorf("expected %v got %v", errInvalidReadResponse, err)
+	}
+
+}
+
+func TestMissingExtents(t *testing.T) {
+
+	have := timeseries.ExtentList{
+		{Start: msTime(5000), End: msTime(6000)},
+		{Start: msTime(1000), End: msTime(2000)},
+	}
+	tests := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 10000, "0-999,2001-4999,6001-10000"},
+		{1000, 2000, ""},
+		{1500, 5500, "2001-4999"},
+		{7000, 8000, "7000-8000"},
+	}
+	for _, test := range tests {
+		var parts []string
+		for _, e := range missingExtents(have, test.start, test.end) {
+			parts = append(parts, strconv.FormatInt(timeMS(e.Start), 10)+"-"+
+				strconv.FormatInt(timeMS(e.End), 10))
+		}
+		if s := strings.Join(parts, ","); s != test.expected {
+			t.Errorf("expected %s got %s", test.expected, s)
+		}
+	}
+
+}
+
+// remoteReadOrigin responds to each remote_read request with the samples of its series in
+// the requested time range, and records the time ranges it was requested
+type remoteReadOrigin struct {
+	mtx    sync.Mutex
+	ranges []string
+}
+
+func (o *remoteReadOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	b, err := snappy.Decode(nil, b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	q, err := parseReadRequest(b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	o.mtx.Lock()
+	o.ranges = append(o.ranges, strconv.FormatInt(q.start, 10)+"-"+strconv.FormatInt(q.end, 10))
+	o.mtx.Unlock()
+	rr := make(readResult)
+	rr.merge(testReadSeries("a", q.start, q.end))
+	w.Header().Set(headers.NameContentEncoding, "snappy")
+	w.Write(snappy.Encode(nil, rr.encodeReadResponse(q.start, q.end)))
+}
+
+func TestRemoteReadHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 200, "", nil, "prometheus", APIPath+mnRead, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.config.HTTPClient = hc
+	client.config.Prometheus.RemoteReadCacheEnabled = true
+	client.cache = rsc.CacheClient
+	client.webClient = hc
+
+	origin := &remoteReadOrigin{}
+	us := httptest.NewServer(origin)
+	defer us.Close()
+	client.baseUpstreamURL, _ = url.Parse(us.URL)
+
+	tests := []struct {
+		start, end int64
+		status     string
+		fetched    string
+		samples    int
+	}{
+		{0, 10000, "kmiss", "0-10000", 11},
+		{5000, 20000, "phit", "10001-20000", 16},
+		{2000, 15000, "hit", "", 14},
+	}
+
+	for i, test := range tests {
+		origin.ranges = nil
+		body := snappy.Encode(nil, encodeReadRequest(&readQuery{}, test.start, test.end))
+		req := httptest.NewRequest(http.MethodPost, APIPath+mnRead, bytes.NewReader(body))
+		req.Header.Set(headers.NameContentEncoding, "snappy")
+		req = req.WithContext(tctx.WithResources(req.Context(), rsc))
+		w := httptest.NewRecorder()
+		client.RemoteReadHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("test %d: expected %d got %d", i, http.StatusOK, w.Code)
+		}
+		if v := w.Header().Get(headers.NameTricksterResult); !strings.Contains(v,
+			"status="+test.status) {
+			t.Errorf("test %d: expected status %s got %s", i, test.status, v)
+		}
+		if s := strings.Join(origin.ranges, ","); s != test.fetched {
+			t.Errorf("test %d: expected fetched %s got %s", i, test.fetched, s)
+		}
+		b, err := snappy.Decode(nil, w.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := make(readResult)
+		if err = rr.parseReadResponse(b); err != nil {
+			t.Fatal(err)
+		}
+		if len(rr) != 1 {
+			t.Fatalf("test %d: expected %d got %d", i, 1, len(rr))
+		}
+		if n := len(rr.series()[0].samples); n != test.samples {
+			t.Errorf("test %d: expected %d got %d", i, test.samples, n)
+		}
+	}
+
+	// samples within the backfill tolerance are not cached
+	client.config.BackfillTolerance = time.Minute
+	now := timeMS(time.Now())
+	origin.ranges = nil
+	for i := 0; i < 2; i++ {
+		body := snappy.Encode(nil, encodeReadRequest(&readQuery{}, now-5000, now))
+		req := httptest.NewRequest(http.MethodPost, APIPath+mnRead, bytes.NewReader(body))
+		req.Header.Set(headers.NameContentEncoding, "snappy")
+		req = req.WithContext(tctx.WithResources(req.Context(), rsc))
+		client.RemoteReadHandler(httptest.NewRecorder(), req)
+	}
+	if len(origin.ranges) != 2 {
+		t.Errorf("expected %d got %d", 2, len(origin.ranges))
+	}
+
+}
@@ -0,0 +1,406 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/urls"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+const (
+	mnWrite = "write"
+
+	// headerRemoteWriteVersion is the header with which agents declare the remote_write
+	// protocol version of a request
+	headerRemoteWriteVersion = "X-Prometheus-Remote-Write-Version"
+	headerUserAgent          = "User-Agent"
+	headerRetryAfter         = "Retry-After"
+
+	// contentTypeWriteRequest is the content type of a version 1 remote_write request
+	contentTypeWriteRequest = "application/x-protobuf"
+	// contentTypeWriteRequestProto is the explicit form of contentTypeWriteRequest
+	contentTypeWriteRequestProto = "application/x-protobuf;proto=prometheus.WriteRequest"
+
+	// maxRemoteWriteBytes is the largest remote_write request body that is buffered
+	maxRemoteWriteBytes = 32 << 20
+)
+
+// remoteWriteHeaders are the request headers that are buffered and forwarded with each
+// remote_write request
+var remoteWriteHeaders = []string{headers.NameContentType, headers.NameContentEncoding,
+	headerRemoteWriteVersion, headerUserAgent, headers.NameAuthorization}
+
+var errRemoteWriteBufferFull = errors.New("remote_write buffer is full")
+
+// writeEntry is a buffered remote_write request
+type writeEntry struct {
+	Path   string      `json:"path"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+
+	seq uint64
+}
+
+// walState is the range of sequence numbers of the requests in the write-ahead log
+type walState struct {
+	First uint64 `json:"first"`
+	Next  uint64 `json:"next"`
+}
+
+// remoteWriter buffers an origin's remote_write requests, and forwards them in batches,
+// retrying those that fail until the origin accepts them
+type remoteWriter struct {
+	client  *Client
+	options *pro.RemoteWriteOptions
+
+	mtx       sync.Mutex
+	pending   []*writeEntry
+	wal       walState
+	recovered bool
+	notify    chan struct{}
+	// sleep waits for the duration, and returns false if quit was closed first
+	sleep func(d time.Duration, quit <-chan struct{}) bool
+}
+
+func newRemoteWriter(c *Client, o *pro.RemoteWriteOptions) *remoteWriter {
+	return &remoteWriter{
+		client:  c,
+		options: o,
+		notify:  make(chan struct{}, 1),
+		sleep:   sleep,
+	}
+}
+
+func sleep(d time.Duration, quit <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-quit:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// walCache returns the cache that holds the write-ahead log, or nil if it is not enabled
+func (rw *remoteWriter) walCache() cache.Cache {
+	if !rw.options.WAL {
+		return nil
+	}
+	return rw.client.cache
+}
+
+func (rw *remoteWriter) walKey(suffix string) string {
+	return rw.client.config.CacheKeyPrefix + ".rw." + suffix
+}
+
+// recover loads the requests that were written to the write-ahead log, but not forwarded,
+// by a previous remoteWriter. It must be called with the mutex held
+func (rw *remoteWriter) recover(log *tl.Logger) {
+	if rw.recovered {
+		return
+	}
+	rw.recovered = true
+	c := rw.walCache()
+	if c == nil {
+		return
+	}
+	b, _, err := c.Retrieve(rw.walKey("state"), false)
+	if err != nil || json.Unmarshal(b, &rw.wal) != nil {
+		rw.wal = walState{}
+		return
+	}
+	for seq := rw.wal.First; seq < rw.wal.Next; seq++ {
+		b, _, err := c.Retrieve(rw.walKey(strconv.FormatUint(seq, 10)), false)
+		if err != nil {
+			continue
+		}
+		e := &writeEntry{}
+		if json.Unmarshal(b, e) != nil {
+			continue
+		}
+		e.seq = seq
+		rw.pending = append(rw.pending, e)
+	}
+	if len(rw.pending) > 0 && log != nil {
+		log.Info("recovered buffered remote_write requests",
+			tl.Pairs{"originName": rw.client.name, "requests": len(rw.pending)})
+	}
+	rw.setBuffered()
+}
+
+func (rw *remoteWriter) storeState(c cache.Cache) error {
+	b, _ := json.Marshal(rw.wal)
+	return c.Store(rw.walKey("state"), b, rw.options.WALTTL)
+}
+
+func (rw *remoteWriter) setBuffered() {
+	metrics.ProxyRemoteWriteBuffered.WithLabelValues(rw.client.name,
+		rw.client.config.OriginType).Set(float64(len(rw.pending)))
+}
+
+func (rw *remoteWriter) count(result string, n int) {
+	metrics.ProxyRemoteWriteRequests.WithLabelValues(rw.client.name,
+		rw.client.config.OriginType, result).Add(float64(n))
+}
+
+// enqueue buffers the remote_write request, writing it to the write-ahead log when enabled
+func (rw *remoteWriter) enqueue(e *writeEntry, log *tl.Logger) error {
+	rw.mtx.Lock()
+	rw.recover(log)
+	if len(rw.pending) >= rw.options.MaxBufferedRequests {
+		rw.mtx.Unlock()
+		rw.count("rejected", 1)
+		return errRemoteWriteBufferFull
+	}
+	if c := rw.walCache(); c != nil {
+		b, _ := json.Marshal(e)
+		e.seq = rw.wal.Next
+		if err := c.Store(rw.walKey(strconv.FormatUint(e.seq, 10)), b,
+			rw.options.WALTTL); err != nil {
+			rw.mtx.Unlock()
+			rw.count("rejected", 1)
+			return err
+		}
+		rw.wal.Next++
+		if err := rw.storeState(c); err != nil {
+			rw.wal.Next--
+			c.Remove(rw.walKey(strconv.FormatUint(e.seq, 10)))
+			rw.mtx.Unlock()
+			rw.count("rejected", 1)
+			return err
+		}
+	}
+	rw.pending = append(rw.pending, e)
+	n := len(rw.pending)
+	rw.setBuffered()
+	rw.mtx.Unlock()
+	rw.count("buffered", 1)
+	if n >= rw.options.MaxBatchRequests {
+		select {
+		case rw.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// nextBatch returns the oldest buffered requests that can be forwarded together
+func (rw *remoteWriter) nextBatch() []*writeEntry {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	if len(rw.pending) == 0 {
+		return nil
+	}
+	batch := []*writeEntry{rw.pending[0]}
+	if !mergeable(rw.pending[0]) {
+		return batch
+	}
+	for _, e := range rw.pending[1:] {
+		if len(batch) >= rw.options.MaxBatchRequests || !mergeable(e) ||
+			e.Path != batch[0].Path || !sameHeaders(e.Header, batch[0].Header) {
+			break
+		}
+		batch = append(batch, e)
+	}
+	return batch
+}
+
+// ack removes the forwarded requests from the buffer and the write-ahead log
+func (rw *remoteWriter) ack(batch []*writeEntry) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	rw.pending = rw.pending[len(batch):]
+	rw.setBuffered()
+	c := rw.walCache()
+	if c == nil {
+		return
+	}
+	keys := make([]string, len(batch))
+	for i, e := range batch {
+		keys[i] = rw.walKey(strconv.FormatUint(e.seq, 10))
+	}
+	c.BulkRemove(keys)
+	if len(rw.pending) > 0 {
+		rw.wal.First = rw.pending[0].seq
+	} else {
+		rw.wal.First = rw.wal.Next
+	}
+	rw.storeState(c)
+}
+
+// mergeable returns true if the request is a version 1 remote_write request, whose
+// protobuf encoding can be concatenated with that of others to combine their series
+func mergeable(e *writeEntry) bool {
+	if e.Header.Get(headers.NameContentEncoding) != "snappy" {
+		return false
+	}
+	switch e.Header.Get(headers.NameContentType) {
+	case contentTypeWriteRequest, contentTypeWriteRequestProto:
+		return true
+	}
+	return false
+}
+
+func sameHeaders(h1, h2 http.Header) bool {
+	for _, k := range remoteWriteHeaders {
+		if h1.Get(k) != h2.Get(k) {
+			return false
+		}
+	}
+	return true
+}
+
+// batchBody returns the body of the request that forwards the batch. The repeated timeseries
+// and metadata fields of concatenated WriteRequest messages are combined when decoded, so the
+// decompressed bodies are concatenated and compressed again
+func batchBody(batch []*writeEntry) ([]byte, error) {
+	if len(batch) == 1 {
+		return batch[0].Body, nil
+	}
+	var buf bytes.Buffer
+	for _, e := range batch {
+		b, err := snappy.Decode(nil, e.Body)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	return snappy.Encode(nil, buf.Bytes()), nil
+}
+
+// errRemoteWriteRejected is returned when the origin rejects a forwarded batch as invalid,
+// in which case it is not retried
+type errRemoteWriteRejected struct {
+	status int
+	body   string
+}
+
+func (e *errRemoteWriteRejected) Error() string {
+	return fmt.Sprintf("origin rejected remote_write request with status %d: %s",
+		e.status, e.body)
+}
+
+// forward sends the body of a batch, whose first request is e, to the origin
+func (rw *remoteWriter) forward(e *writeEntry, body []byte) error {
+	u := urls.Clone(rw.client.baseUpstreamURL)
+	u.Path += e.Path
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, k := range remoteWriteHeaders {
+		if v := e.Header.Get(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	resp, err := rw.client.webClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+		return &errRemoteWriteRejected{status: resp.StatusCode, body: string(b)}
+	}
+	return fmt.Errorf("origin responded to remote_write request with status %d", resp.StatusCode)
+}
+
+// flush forwards batches of buffered requests until the buffer is empty, retrying failed
+// forwards with backoff. It returns false if quit was closed first
+func (rw *remoteWriter) flush(quit <-chan struct{}, log *tl.Logger) bool {
+	backoff := rw.options.MinBackoff
+	for {
+		batch := rw.nextBatch()
+		if len(batch) == 0 {
+			return true
+		}
+		body, err := batchBody(batch)
+		if err != nil {
+			// a body that can't be decoded is forwarded alone, for the origin to reject
+			batch = batch[:1]
+			body = batch[0].Body
+		}
+		err = rw.forward(batch[0], body)
+		if err == nil {
+			rw.count("forwarded", len(batch))
+			rw.ack(batch)
+			backoff = rw.options.MinBackoff
+			continue
+		}
+		if rerr, ok := err.(*errRemoteWriteRejected); ok {
+			log.Warn("origin rejected buffered remote_write request",
+				tl.Pairs{"originName": rw.client.name, "status": rerr.status,
+					"detail": rerr.body})
+			rw.count("dropped", len(batch))
+			rw.ack(batch)
+			continue
+		}
+		log.Debug("remote_write forward failed, retrying",
+			tl.Pairs{"originName": rw.client.name, "detail": err.Error(),
+				"backoff": backoff.String()})
+		rw.count("retried", len(batch))
+		if !rw.sleep(backoff, quit) {
+			return false
+		}
+		backoff *= 2
+		if backoff > rw.options.MaxBackoff {
+			backoff = rw.options.MaxBackoff
+		}
+	}
+}
+
+// run forwards the buffered requests each flush interval, or as soon as a full batch is
+// buffered, until quit is closed
+func (rw *remoteWriter) run(quit <-chan struct{}, log *tl.Logger) {
+	rw.mtx.Lock()
+	rw.recover(log)
+	rw.mtx.Unlock()
+	t := time.NewTicker(rw.options.FlushInterval)
+	defer t.Stop()
+	for {
+		if !rw.flush(quit, log) {
+			return
+		}
+		select {
+		case <-quit:
+			return
+		case <-t.C:
+		case <-rw.notify:
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/golang/snappy"
+
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// protobuf wire types used by the remote_write WriteRequest
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errInvalidWriteRequest is returned when a remote_write body is not a valid protobuf message
+var errInvalidWriteRequest = errors.New("invalid remote_write request encoding")
+
+// protoField is a single field of a protobuf message
+type protoField struct {
+	num  uint64
+	wire uint64
+	// data is the payload of a length-delimited field
+	data []byte
+	// value is the payload of a varint or fixed64 field
+	value uint64
+	// end is the offset of the byte following the field
+	end int
+}
+
+// nextField reads the protobuf field beginning at offset i of b
+func nextField(b []byte, i int) (*protoField, error) {
+	tag, n := binary.Uvarint(b[i:])
+	if n <= 0 {
+		return nil, errInvalidWriteRequest
+	}
+	i += n
+	f := &protoField{num: tag >> 3, wire: tag & 7}
+	switch f.wire {
+	case wireVarint:
+		if f.value, n = binary.Uvarint(b[i:]); n <= 0 {
+			return nil, errInvalidWriteRequest
+		}
+		i += n
+	case wireFixed64:
+		if len(b)-i < 8 {
+			return nil, errInvalidWriteRequest
+		}
+		f.value = binary.LittleEndian.Uint64(b[i:])
+		i += 8
+	case wireFixed32:
+		i += 4
+	case wireBytes:
+		l, n := binary.Uvarint(b[i:])
+		if n <= 0 || l > uint64(len(b)-i-n) {
+			return nil, errInvalidWriteRequest
+		}
+		i += n
+		f.data = b[i : i+int(l)]
+		i += int(l)
+	default:
+		return nil, errInvalidWriteRequest
+	}
+	if i > len(b) {
+		return nil, errInvalidWriteRequest
+	}
+	f.end = i
+	return f, nil
+}
+
+// seriesLabels returns the labels of a protobuf-encoded TimeSeries message
+func seriesLabels(b []byte) (map[string]string, error) {
+	labels := make(map[string]string)
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return nil, err
+		}
+		i = f.end
+		// TimeSeries field 1 is a repeated Label, with a name (1) and a value (2)
+		if f.num != 1 || f.wire != wireBytes {
+			continue
+		}
+		var name, value string
+		for j := 0; j < len(f.data); {
+			lf, err := nextField(f.data, j)
+			if err != nil {
+				return nil, err
+			}
+			j = lf.end
+			if lf.wire != wireBytes {
+				continue
+			}
+			switch lf.num {
+			case 1:
+				name = string(lf.data)
+			case 2:
+				value = string(lf.data)
+			}
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// filterWriteRequest applies the relabel rules to the series of a protobuf-encoded
+// version 1 WriteRequest. It returns the encoding of the WriteRequest without the dropped
+// series, and the number of series dropped by each rule. Other fields are retained as-is
+func filterWriteRequest(b []byte, o *pro.RemoteWriteOptions) ([]byte, map[string]int, error) {
+	dropped := make(map[string]int)
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		f, err := nextField(b, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		// WriteRequest field 1 is a repeated TimeSeries
+		if f.num == 1 && f.wire == wireBytes {
+			labels, err := seriesLabels(f.data)
+			if err != nil {
+				return nil, nil, err
+			}
+			if rule := o.Drop(labels); rule != "" {
+				dropped[rule]++
+				i = f.end
+				continue
+			}
+		}
+		out = append(out, b[i:f.end]...)
+		i = f.end
+	}
+	return out, dropped, nil
+}
+
+// relabel applies the relabel rules to a snappy-compressed version 1 remote_write body.
+// It returns the body without the dropped series, or nil when every series is dropped
+func (rw *remoteWriter) relabel(body []byte) ([]byte, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+	out, dropped, err := filterWriteRequest(b, rw.options)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) == 0 {
+		return body, nil
+	}
+	for rule, n := range dropped {
+		metrics.ProxyRemoteWriteDroppedSeries.WithLabelValues(rw.client.name,
+			rw.client.config.OriginType, rule).Add(float64(n))
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return snappy.Encode(nil, out), nil
+}
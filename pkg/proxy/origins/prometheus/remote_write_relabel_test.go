@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+)
+
+func protoBytes(num uint64, data []byte) []byte {
+	b := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(b, num<<3|wireBytes)
+	n += binary.PutUvarint(b[n:], uint64(len(data)))
+	return append(b[:n], data...)
+}
+
+// testSeries returns the protobuf encoding of a TimeSeries with the labels and a sample
+func testSeries(labels ...string) []byte {
+	var ts []byte
+	for i := 0; i+1 < len(labels); i += 2 {
+		ts = append(ts, protoBytes(1, append(protoBytes(1, []byte(labels[i])),
+			protoBytes(2, []byte(labels[i+1]))...))...)
+	}
+	// a Sample with a fixed64 value (1) and a varint timestamp (2)
+	sample := []byte{1<<3 | wireFixed64, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 2 << 3, 0x96, 0x01}
+	return protoBytes(1, append(ts, protoBytes(2, sample)...))
+}
+
+func testRelabelOptions(t *testing.T) *pro.RemoteWriteOptions {
+	o := pro.NewRemoteWriteOptions()
+	rr := pro.NewRelabelRuleOptions()
+	rr.Action = pro.RelabelActionDrop
+	rr.SourceLabels = []string{"__name__"}
+	rr.Regex = "debug_.*"
+	o.RelabelRules["drop-debug"] = rr
+	if err := o.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestFilterWriteRequest(t *testing.T) {
+
+	o := testRelabelOptions(t)
+	up := testSeries("__name__", "up", "job", "a")
+	debug := testSeries("__name__", "debug_queue", "job", "a")
+	// a MetricMetadata (3) is retained
+	md := protoBytes(3, protoBytes(2, []byte("up")))
+
+	b := append(append(append([]byte{}, up...), debug...), md...)
+	out, dropped, err := filterWriteRequest(b, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := string(up) + string(md); string(out) != expected {
+		t.Errorf("expected %x got %x", expected, out)
+	}
+	if dropped["drop-debug"] != 1 {
+		t.Errorf("expected %d got %d", 1, dropped["drop-debug"])
+	}
+
+	if _, _, err = filterWriteRequest(up[:len(up)-1], o); err == nil {
+		t.Error("expected error for truncated request")
+	}
+	// wire type 3 (start group) is not used by the WriteRequest
+	if _, _, err = filterWriteRequest([]byte{1<<3 | 3}, o); err == nil {
+		t.Error("expected error for invalid wire type")
+	}
+
+}
+
+func TestRemoteWriteHandlerRelabel(t *testing.T) {
+
+	client, _, closer := newRemoteWriteTestClient(t, &remoteWriteOrigin{})
+	defer closer()
+	client.remoteWriter.options = testRelabelOptions(t)
+
+	up := testSeries("__name__", "up")
+	debug := testSeries("__name__", "debug_queue")
+
+	w := httptest.NewRecorder()
+	client.RemoteWriteHandler(w, remoteWriteRequest(string(up)+string(debug)))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	if len(client.remoteWriter.pending) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(client.remoteWriter.pending))
+	}
+	b, err := snappy.Decode(nil, client.remoteWriter.pending[0].Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(up) {
+		t.Errorf("expected %x got %x", up, b)
+	}
+
+	// a request whose series are all dropped is acknowledged without being buffered
+	w = httptest.NewRecorder()
+	client.RemoteWriteHandler(w, remoteWriteRequest(string(debug)))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+	}
+	if len(client.remoteWriter.pending) != 1 {
+		t.Errorf("expected %d got %d", 1, len(client.remoteWriter.pending))
+	}
+
+	w = httptest.NewRecorder()
+	client.RemoteWriteHandler(w, remoteWriteRequest("\x0a\x05"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	r := remoteWriteRequest(string(up))
+	r.Header.Set(headers.NameContentType, "application/x-protobuf;proto=io.prometheus.write.v2.Request")
+	w = httptest.NewRecorder()
+	client.RemoteWriteHandler(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected %d got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+
+}
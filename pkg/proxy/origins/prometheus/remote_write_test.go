@@ -0,0 +1,266 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+// remoteWriteOrigin records the remote_write requests it receives, and responds to each
+// with the next of its status codes, or a 204 once they are exhausted
+type remoteWriteOrigin struct {
+	mtx      sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (o *remoteWriteOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	b, _ := ioutil.ReadAll(r.Body)
+	o.bodies = append(o.bodies, b)
+	o.headers = append(o.headers, r.Header)
+	code := http.StatusNoContent
+	if len(o.statuses) > 0 {
+		code = o.statuses[0]
+		o.statuses = o.statuses[1:]
+	}
+	w.WriteHeader(code)
+}
+
+func newRemoteWriteTestClient(t *testing.T, origin http.Handler) (*Client, *httptest.Server,
+	func()) {
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("",
+		client.DefaultPathConfigs, 204, "", nil, "prometheus", APIPath+mnWrite, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc := request.GetResources(r)
+	client.config = rsc.OriginConfig
+	client.cache = rsc.CacheClient
+	client.webClient = hc
+	client.config.Prometheus.RemoteWrite.Enabled = true
+	client.config.Prometheus.RemoteWrite.MaxBufferedRequests = 3
+	client.config.Prometheus.RemoteWrite.MaxBatchRequests = 2
+	client.config.Prometheus.SetDurations()
+	client.remoteWriter = newRemoteWriter(client, client.config.Prometheus.RemoteWrite)
+	us := httptest.NewServer(origin)
+	client.baseUpstreamURL, _ = url.Parse(us.URL + "/prometheus")
+	return client, us, func() { us.Close(); ts.Close() }
+}
+
+func remoteWriteRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, APIPath+mnWrite,
+		bytes.NewReader(snappy.Encode(nil, []byte(body))))
+	r.Header.Set(headers.NameContentType, contentTypeWriteRequest)
+	r.Header.Set(headers.NameContentEncoding, "snappy")
+	r.Header.Set(headerRemoteWriteVersion, "0.1.0")
+	return r
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+
+	origin := &remoteWriteOrigin{}
+	client, _, closer := newRemoteWriteTestClient(t, origin)
+	defer closer()
+	log := tl.ConsoleLogger("error")
+
+	for _, body := range []string{"a", "b", "c"} {
+		w := httptest.NewRecorder()
+		client.RemoteWriteHandler(w, remoteWriteRequest(body))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected %d got %d", http.StatusNoContent, w.Code)
+		}
+	}
+
+	// the buffer is full
+	w := httptest.NewRecorder()
+	client.RemoteWriteHandler(w, remoteWriteRequest("d"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get(headerRetryAfter) == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	if !client.remoteWriter.flush(make(chan struct{}), log) {
+		t.Fatal("expected flush to complete")
+	}
+
+	// the first two requests are combined, and the third is forwarded alone
+	if len(origin.bodies) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(origin.bodies))
+	}
+	expected := []string{"ab", "c"}
+	for i, b := range origin.bodies {
+		d, err := snappy.Decode(nil, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != expected[i] {
+			t.Errorf("expected %s got %s", expected[i], string(d))
+		}
+	}
+	if v := origin.headers[0].Get(headerRemoteWriteVersion); v != "0.1.0" {
+		t.Errorf("expected %s got %s", "0.1.0", v)
+	}
+	if len(client.remoteWriter.pending) != 0 {
+		t.Errorf("expected %d got %d", 0, len(client.remoteWriter.pending))
+	}
+
+}
+
+func TestRemoteWriteRetry(t *testing.T) {
+
+	origin := &remoteWriteOrigin{statuses: []int{http.StatusServiceUnavailable,
+		http.StatusTooManyRequests, http.StatusBadRequest}}
+	client, _, closer := newRemoteWriteTestClient(t, origin)
+	defer closer()
+	log := tl.ConsoleLogger("error")
+	rw := client.remoteWriter
+
+	var backoffs []time.Duration
+	rw.sleep = func(d time.Duration, quit <-chan struct{}) bool {
+		backoffs = append(backoffs, d)
+		return true
+	}
+
+	rw.enqueue(&writeEntry{Path: APIPath + mnWrite, Header: make(http.Header),
+		Body: []byte("a")}, log)
+	rw.enqueue(&writeEntry{Path: APIPath + mnWrite, Header: make(http.Header),
+		Body: []byte("b")}, log)
+
+	// the first request is retried after a 503 and a 429, then dropped after a 400,
+	// and the second is forwarded
+	if !rw.flush(make(chan struct{}), log) {
+		t.Fatal("expected flush to complete")
+	}
+	if len(origin.bodies) != 4 {
+		t.Errorf("expected %d got %d", 4, len(origin.bodies))
+	}
+	if len(backoffs) != 2 || backoffs[0] != rw.options.MinBackoff ||
+		backoffs[1] != 2*rw.options.MinBackoff {
+		t.Errorf("unexpected backoffs %v", backoffs)
+	}
+	if string(origin.bodies[3]) != "b" {
+		t.Errorf("expected %s got %s", "b", string(origin.bodies[3]))
+	}
+
+	// a quit during the backoff stops the flush, and the request remains buffered
+	origin.statuses = []int{http.StatusBadGateway}
+	rw.sleep = func(d time.Duration, quit <-chan struct{}) bool { return false }
+	rw.enqueue(&writeEntry{Path: APIPath + mnWrite, Header: make(http.Header),
+		Body: []byte("c")}, log)
+	if rw.flush(make(chan struct{}), log) {
+		t.Error("expected flush to be interrupted")
+	}
+	if len(rw.pending) != 1 {
+		t.Errorf("expected %d got %d", 1, len(rw.pending))
+	}
+
+}
+
+func TestRemoteWriteBatch(t *testing.T) {
+
+	rw := &remoteWriter{options: pro.NewRemoteWriteOptions()}
+	h := http.Header{headers.NameContentType: {contentTypeWriteRequest},
+		headers.NameContentEncoding: {"snappy"}}
+	h2 := http.Header{headers.NameContentType: {"application/x-protobuf;proto=io.prometheus.write.v2.Request"},
+		headers.NameContentEncoding: {"snappy"}}
+	rw.pending = []*writeEntry{
+		{Path: "/api/v1/write", Header: h},
+		{Path: "/api/v1/write", Header: h},
+		{Path: "/api/v1/write", Header: h2},
+		{Path: "/api/v1/write", Header: h2},
+	}
+
+	// version 2 requests reference their own symbol tables, so they aren't combined
+	if n := len(rw.nextBatch()); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	rw.pending = rw.pending[2:]
+	if n := len(rw.nextBatch()); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	if _, err := batchBody([]*writeEntry{{Body: []byte("invalid")},
+		{Body: []byte("invalid")}}); err == nil {
+		t.Error("expected error for invalid snappy body")
+	}
+
+}
+
+func TestRemoteWriteWAL(t *testing.T) {
+
+	client, _, closer := newRemoteWriteTestClient(t, &remoteWriteOrigin{})
+	defer closer()
+	log := tl.ConsoleLogger("error")
+	client.config.Prometheus.RemoteWrite.WAL = true
+
+	rw := newRemoteWriter(client, client.config.Prometheus.RemoteWrite)
+	for _, body := range []string{"a", "b", "c"} {
+		if err := rw.enqueue(&writeEntry{Path: APIPath + mnWrite, Header: make(http.Header),
+			Body: []byte(body)}, log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rw.ack(rw.pending[:1])
+
+	// a new writer, as after a restart, recovers the requests that were not forwarded
+	rw = newRemoteWriter(client, client.config.Prometheus.RemoteWrite)
+	rw.mtx.Lock()
+	rw.recover(log)
+	rw.mtx.Unlock()
+	if len(rw.pending) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(rw.pending))
+	}
+	if string(rw.pending[0].Body) != "b" || string(rw.pending[1].Body) != "c" {
+		t.Errorf("unexpected requests %s %s", rw.pending[0].Body, rw.pending[1].Body)
+	}
+
+	if !rw.flush(make(chan struct{}), log) {
+		t.Fatal("expected flush to complete")
+	}
+	rw = newRemoteWriter(client, client.config.Prometheus.RemoteWrite)
+	rw.mtx.Lock()
+	rw.recover(log)
+	rw.mtx.Unlock()
+	if len(rw.pending) != 0 {
+		t.Errorf("expected %d got %d", 0, len(rw.pending))
+	}
+	if rw.wal.First != 3 || rw.wal.Next != 3 {
+		t.Errorf("unexpected wal state %v", rw.wal)
+	}
+
+}
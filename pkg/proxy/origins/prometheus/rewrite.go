@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+)
+
+// rewriteQuery substitutes the request's query expression using the origin's configured
+// query rewrites, and notes any applied rewrites in the response headers
+func (c *Client) rewriteQuery(w http.ResponseWriter, r *http.Request) {
+	if c.config == nil || c.config.Prometheus == nil || len(c.config.Prometheus.QueryRewrites) == 0 {
+		return
+	}
+	qp, _, _ := params.GetRequestValues(r)
+	q := qp.Get(upQuery)
+	if q == "" {
+		return
+	}
+	q2, applied := c.config.Prometheus.RewriteQuery(q)
+	if len(applied) == 0 || q2 == q {
+		return
+	}
+	qp.Set(upQuery, q2)
+	params.SetRequestValues(r, qp)
+	w.Header().Set(headers.NameTricksterQueryRewrite, strings.Join(applied, ", "))
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	pro "github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus/options"
+)
+
+func TestRewriteQuery(t *testing.T) {
+
+	oc := oo.NewOptions()
+	client := &Client{name: "test", config: oc}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET",
+		"http://0/api/v1/query?query=sum(rate(http_requests_total[5m]))+by+(job)&time=0", nil)
+
+	// no rewrites configured
+	client.rewriteQuery(w, r)
+	if v := w.Header().Get(headers.NameTricksterQueryRewrite); v != "" {
+		t.Errorf("expected empty header got %s", v)
+	}
+
+	oc.Prometheus.QueryRewrites["job_rate"] = &pro.QueryRewriteOptions{
+		Match:       `^sum\(rate\(http_requests_total\[5m\]\)\) by \(job\)$`,
+		Replacement: "job:http_requests:rate5m",
+	}
+	if err := oc.Prometheus.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.rewriteQuery(w, r)
+	if v := r.URL.Query().Get(upQuery); v != "job:http_requests:rate5m" {
+		t.Errorf("expected %s got %s", "job:http_requests:rate5m", v)
+	}
+	if v := w.Header().Get(headers.NameTricksterQueryRewrite); v != "job_rate" {
+		t.Errorf("expected %s got %s", "job_rate", v)
+	}
+	if v := r.URL.Query().Get(upTime); v != "0" {
+		t.Errorf("expected %s got %s", "0", v)
+	}
+
+}
@@ -37,6 +37,8 @@ func (c *Client) registerHandlers() {
 	c.handlers["series"] = http.HandlerFunc(c.SeriesHandler)
 	c.handlers["proxycache"] = http.HandlerFunc(c.ObjectProxyCacheHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	c.handlers["remote_write"] = http.HandlerFunc(c.RemoteWriteHandler)
+	c.handlers["remote_read"] = http.HandlerFunc(c.RemoteReadHandler)
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -212,6 +214,27 @@ func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
 		},
 	}
 
+	if oc != nil && oc.Prometheus != nil && oc.Prometheus.RemoteWrite != nil &&
+		oc.Prometheus.RemoteWrite.Enabled {
+		paths[APIPath+mnWrite] = &po.Options{
+			Path:          APIPath + mnWrite,
+			HandlerName:   "remote_write",
+			Methods:       []string{http.MethodPost},
+			MatchType:     matching.PathMatchTypeExact,
+			MatchTypeName: "exact",
+		}
+	}
+
+	if oc != nil && oc.Prometheus != nil && oc.Prometheus.RemoteReadCacheEnabled {
+		paths[APIPath+mnRead] = &po.Options{
+			Path:          APIPath + mnRead,
+			HandlerName:   "remote_read",
+			Methods:       []string{http.MethodPost},
+			MatchType:     matching.PathMatchTypeExact,
+			MatchTypeName: "exact",
+		}
+	}
+
 	oc.FastForwardPath = paths[APIPath+mnQuery].Clone()
 
 	return paths
@@ -64,4 +64,21 @@ func TestDefaultPathConfigs(t *testing.T) {
 		t.Errorf("expected ordered length to be: %d got %d", expectedLen, len(dpc))
 	}
 
+	// the remote_write path is only registered when buffering is enabled
+	client.config.Prometheus.RemoteWrite.Enabled = true
+	dpc = client.DefaultPathConfigs(client.config)
+	if p, ok := dpc[APIPath+mnWrite]; !ok || p.HandlerName != "remote_write" {
+		t.Errorf("expected to find path named: %s", APIPath+mnWrite)
+	}
+
+	// the remote_read path is only registered when its cache is enabled
+	if _, ok := dpc[APIPath+mnRead]; ok {
+		t.Errorf("unexpected path named: %s", APIPath+mnRead)
+	}
+	client.config.Prometheus.RemoteReadCacheEnabled = true
+	dpc = client.DefaultPathConfigs(client.config)
+	if p, ok := dpc[APIPath+mnRead]; !ok || p.HandlerName != "remote_read" {
+		t.Errorf("expected to find path named: %s", APIPath+mnRead)
+	}
+
 }
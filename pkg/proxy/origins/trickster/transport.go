@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trickster
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Transport is the http.RoundTripper used to reach an upstream Trickster. It adds this
+// Trickster's server name to the hop header of each request, and rejects requests that have
+// already passed through this Trickster, or through MaxHops Tricksters, as proxy loops.
+type Transport struct {
+	originName string
+	next       http.RoundTripper
+	maxHops    int
+}
+
+// NewTransport returns a Transport for the named origin that sends requests using next
+func NewTransport(originName string, next http.RoundTripper, maxHops int) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{originName: originName, next: next, maxHops: maxHops}
+}
+
+// RoundTrip executes the request against the upstream Trickster. Since the upstream's
+// X-Trickster-Result header would be replaced by this Trickster's own, it is moved to the
+// X-Trickster-Upstream-Result header, ahead of the results of any Tricksters further upstream.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	hops := ParseHops(r.Header.Get(headers.NameTricksterHops))
+	if len(hops) >= t.maxHops || contains(hops, runtime.Server) {
+		metrics.ProxyLoopsDetected.WithLabelValues(t.originName, "trickster").Inc()
+		return loopDetected(r, hops), nil
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set(headers.NameTricksterHops, strings.Join(append(hops, runtime.Server), ", "))
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if v := resp.Header.Get(headers.NameTricksterResult); v != "" {
+		resp.Header[headers.NameTricksterUpstreamResult] =
+			append([]string{v}, resp.Header[headers.NameTricksterUpstreamResult]...)
+		resp.Header.Del(headers.NameTricksterResult)
+	}
+	return resp, nil
+}
+
+// ParseHops returns the server names listed in a hop header value
+func ParseHops(v string) []string {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	hops := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			hops = append(hops, p)
+		}
+	}
+	return hops
+}
+
+func contains(hops []string, name string) bool {
+	for _, h := range hops {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loopDetected returns the response to a request that would loop between Tricksters
+func loopDetected(r *http.Request, hops []string) *http.Response {
+	body := "proxy loop detected: request has passed through " +
+		strconv.Itoa(len(hops)) + " Tricksters: " + strings.Join(hops, ", ")
+	return &http.Response{
+		Status:        strconv.Itoa(http.StatusLoopDetected) + " " + http.StatusText(http.StatusLoopDetected),
+		StatusCode:    http.StatusLoopDetected,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Header:        http.Header{headers.NameContentType: []string{headers.ValueTextPlain}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trickster
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/runtime"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	server := runtime.Server
+	runtime.Server = "trickster-edge"
+	defer func() { runtime.Server = server }()
+
+	var hops string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops = r.Header.Get(headers.NameTricksterHops)
+		w.Header().Set(headers.NameTricksterResult, "engine=DeltaProxyCache; status=hit")
+		w.Header().Set(headers.NameTricksterUpstreamResult, "engine=DeltaProxyCache; status=kmiss")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tr := NewTransport("test", nil, 3)
+	r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	r.Header.Set(headers.NameTricksterHops, "trickster-client")
+	resp, err := tr.RoundTrip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if hops != "trickster-client, trickster-edge" {
+		t.Errorf("expected %s got %s", "trickster-client, trickster-edge", hops)
+	}
+	// the original request is not modified
+	if v := r.Header.Get(headers.NameTricksterHops); v != "trickster-client" {
+		t.Errorf("expected %s got %s", "trickster-client", v)
+	}
+	if v := resp.Header.Get(headers.NameTricksterResult); v != "" {
+		t.Errorf("expected empty result header got %s", v)
+	}
+	ur := resp.Header[headers.NameTricksterUpstreamResult]
+	if len(ur) != 2 || ur[0] != "engine=DeltaProxyCache; status=hit" ||
+		ur[1] != "engine=DeltaProxyCache; status=kmiss" {
+		t.Errorf("unexpected upstream results %v", ur)
+	}
+
+}
+
+func TestRoundTripLoop(t *testing.T) {
+
+	server := runtime.Server
+	runtime.Server = "trickster-edge"
+	defer func() { runtime.Server = server }()
+
+	var called bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer ts.Close()
+
+	tr := NewTransport("test", nil, 3)
+	tests := []string{
+		"trickster-edge",
+		"trickster-a, trickster-edge, trickster-b",
+		"trickster-a, trickster-b, trickster-c",
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		r.Header.Set(headers.NameTricksterHops, test)
+		resp, err := tr.RoundTrip(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusLoopDetected {
+			t.Errorf("expected %d got %d", http.StatusLoopDetected, resp.StatusCode)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if len(b) == 0 {
+			t.Error("expected response body")
+		}
+	}
+	if called {
+		t.Error("expected looping requests to not be sent upstream")
+	}
+
+}
+
+func TestParseHops(t *testing.T) {
+	if h := ParseHops(""); h != nil {
+		t.Errorf("expected nil got %v", h)
+	}
+	h := ParseHops(" a,b , ,c")
+	if len(h) != 3 || h[0] != "a" || h[1] != "b" || h[2] != "c" {
+		t.Errorf("unexpected hops %v", h)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package trickster provides the upstream Trickster Origin Type, for hierarchical deployments
+// in which a Trickster (e.g., at the edge) proxies to another Trickster (e.g., regional) that
+// in turn proxies to the origin
+package trickster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/graphite"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/irondb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/opentsdb"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/piwebapi"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/victoriametrics"
+)
+
+// ErrInvalidMaxHops is returned when the origin's max_hops is less than 1
+var ErrInvalidMaxHops = errors.New("max_hops must be greater than 0")
+
+// NewClient returns a new Client for an origin that is another Trickster. Since the upstream
+// Trickster serves the API of the origin behind it, the returned Client is that of the
+// UpstreamType, with an HTTP Transport that tracks the Tricksters each request has passed
+// through, in order to reject proxy loops, and that preserves the upstream Trickster's
+// result header in the response
+func NewClient(name string, oc *oo.Options, router http.Handler,
+	c cache.Cache) (origins.Client, error) {
+
+	if oc.MaxHops < 1 {
+		return nil, ErrInvalidMaxHops
+	}
+
+	ut := strings.ToLower(oc.UpstreamType)
+	if ut == "" {
+		ut = d.DefaultUpstreamType
+	}
+
+	var client origins.Client
+	var err error
+	switch ut {
+	case "prometheus":
+		client, err = prometheus.NewClient(name, oc, router, c)
+	case "influxdb":
+		client, err = influxdb.NewClient(name, oc, router, c)
+	case "irondb":
+		client, err = irondb.NewClient(name, oc, router, c)
+	case "clickhouse":
+		client, err = clickhouse.NewClient(name, oc, router, c)
+	case "piwebapi":
+		client, err = piwebapi.NewClient(name, oc, router, c)
+	case "victoriametrics":
+		client, err = victoriametrics.NewClient(name, oc, router, c)
+	case "graphite":
+		client, err = graphite.NewClient(name, oc, router, c)
+	case "opentsdb":
+		client, err = opentsdb.NewClient(name, oc, router, c)
+	case "rpc", "reverseproxycache":
+		client, err = reverseproxycache.NewClient(name, oc, router, c)
+	default:
+		return nil, fmt.Errorf("invalid upstream_type [%s] provided in origin config [%s]",
+			oc.UpstreamType, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hc := client.HTTPClient()
+	hc.Transport = NewTransport(name, hc.Transport, oc.MaxHops)
+	return client, nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trickster
+
+import (
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
+)
+
+func TestNewClient(t *testing.T) {
+
+	oc := oo.NewOptions()
+	oc.OriginType = "trickster"
+	oc.UpstreamType = "prometheus"
+
+	c, err := NewClient("test", oc, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*prometheus.Client); !ok {
+		t.Errorf("expected prometheus client got %T", c)
+	}
+	if _, ok := c.(origins.TimeseriesClient); !ok {
+		t.Error("expected timeseries client")
+	}
+	if _, ok := c.HTTPClient().Transport.(*Transport); !ok {
+		t.Errorf("expected trickster transport got %T", c.HTTPClient().Transport)
+	}
+
+	oc.UpstreamType = "rpc"
+	c, err = NewClient("test", oc, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*reverseproxycache.Client); !ok {
+		t.Errorf("expected reverseproxycache client got %T", c)
+	}
+
+	// the upstream type defaults to reverseproxycache
+	oc.UpstreamType = ""
+	c, err = NewClient("test", oc, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*reverseproxycache.Client); !ok {
+		t.Errorf("expected reverseproxycache client got %T", c)
+	}
+
+	oc.UpstreamType = "rule"
+	if _, err = NewClient("test", oc, nil, nil); err == nil {
+		t.Error("expected error for invalid upstream type")
+	}
+
+	oc.UpstreamType = "prometheus"
+	oc.MaxHops = 0
+	if _, err = NewClient("test", oc, nil, nil); err != ErrInvalidMaxHops {
+		t.Errorf("expected %v got %v", ErrInvalidMaxHops, err)
+	}
+
+}
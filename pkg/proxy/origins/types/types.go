@@ -34,6 +34,16 @@ const (
 	OriginTypeIronDB
 	// OriginTypeClickHouse represents the ClickHouse origin type
 	OriginTypeClickHouse
+	// OriginTypeTrickster represents the upstream Trickster origin type
+	OriginTypeTrickster
+	// OriginTypePIWebAPI represents the PI Web API origin type
+	OriginTypePIWebAPI
+	// OriginTypeVictoriaMetrics represents the VictoriaMetrics origin type
+	OriginTypeVictoriaMetrics
+	// OriginTypeGraphite represents the Graphite origin type
+	OriginTypeGraphite
+	// OriginTypeOpenTSDB represents the OpenTSDB origin type
+	OriginTypeOpenTSDB
 )
 
 // Names is a map of OriginTypes keyed by string name
@@ -45,6 +55,11 @@ var Names = map[string]OriginType{
 	"influxdb":          OriginTypeInfluxDB,
 	"irondb":            OriginTypeIronDB,
 	"clickhouse":        OriginTypeClickHouse,
+	"trickster":         OriginTypeTrickster,
+	"piwebapi":          OriginTypePIWebAPI,
+	"victoriametrics":   OriginTypeVictoriaMetrics,
+	"graphite":          OriginTypeGraphite,
+	"opentsdb":          OriginTypeOpenTSDB,
 }
 
 // Values is a map of OriginTypes valued by string name
@@ -53,6 +53,11 @@ func TestIsValidOriginType(t *testing.T) {
 		{"invalid", false},
 		{"influxdb", true},
 		{"irondb", true},
+		{"trickster", true},
+		{"piwebapi", true},
+		{"victoriametrics", true},
+		{"graphite", true},
+		{"opentsdb", true},
 	}
 
 	for i, test := range tests {
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+)
+
+// ExportHandler handles calls to /api/v1/export, which exports the raw samples of the matched
+// series. Exports whose time range ends before the backfill tolerance (or the origin's latency
+// offset) are immutable and processed through the object proxy cache, and the rest are proxied
+func (c *Client) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if c.exportCacheable(r) {
+		c.ObjectProxyCacheHandler(w, r)
+		return
+	}
+	c.ProxyHandler(w, r)
+}
+
+// exportCacheable returns true if the export request's end time is old enough that
+// samples will no longer be written within its time range
+func (c *Client) exportCacheable(r *http.Request) bool {
+	qp, _, _ := params.GetRequestValues(r)
+	v := qp.Get(upEnd)
+	if v == "" {
+		return false
+	}
+	end, err := parseTime(v)
+	if err != nil {
+		return false
+	}
+	var bt time.Duration
+	if oc := c.Configuration(); oc != nil {
+		bt = oc.BackfillTolerance
+	}
+	if lo := c.latencyOffset(qp); lo > bt {
+		bt = lo
+	}
+	return end.Before(time.Now().Add(-bt))
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tu "github.com/tricksterproxy/trickster/pkg/util/testing"
+)
+
+const exportBody = `{"metric":{"__name__":"up"},"values":[1],"timestamps":[1600000000000]}`
+
+func TestExportHandler(t *testing.T) {
+
+	var client *Client
+	ts, _, r, _, err := tu.NewTestInstance("", func(oc *oo.Options) map[string]*po.Options {
+		c, _ := NewClient("test", oc, nil, nil)
+		client = c.(*Client)
+		return client.DefaultPathConfigs(oc)
+	}, 200, exportBody, nil, "victoriametrics", prometheus.APIPath+mnExport, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	rsc.OriginConfig.HTTPClient = client.HTTPClient()
+	client.SetCache(rsc.CacheClient)
+
+	tests := []struct {
+		end    string
+		engine string
+	}{
+		{"1600000000", "engine=ObjectProxyCache"},
+		{strconv.FormatInt(time.Now().Add(-10*time.Second).Unix(), 10), "engine=HTTPProxy"},
+		{"", "engine=HTTPProxy"},
+		{"-", "engine=HTTPProxy"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v := url.Values{upMatch: {"up"}, upStart: {"1599990000"}}
+			if test.end != "" {
+				v.Set(upEnd, test.end)
+			}
+			req := httptest.NewRequest("GET", ts.URL+prometheus.APIPath+mnExport+"?"+v.Encode(), nil)
+			req = req.WithContext(r.Context())
+			w := httptest.NewRecorder()
+			client.ExportHandler(w, req)
+			resp := w.Result()
+			if resp.StatusCode != 200 {
+				t.Errorf("expected 200 got %d", resp.StatusCode)
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			if string(b) != exportBody {
+				t.Errorf("expected %s got %s", exportBody, string(b))
+			}
+			if h := resp.Header.Get(headers.NameTricksterResult); !strings.HasPrefix(h, test.engine) {
+				t.Errorf("expected %s got %s", test.engine, h)
+			}
+		})
+	}
+}
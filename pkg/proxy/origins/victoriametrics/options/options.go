@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides configurations that are specific to the VictoriaMetrics Origin Type
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// ErrInvalidLatencyOffset is returned when the latency_offset_secs is negative
+var ErrInvalidLatencyOffset = errors.New("victoriametrics latency_offset_secs must not be negative")
+
+// Options is a collection of VictoriaMetrics-specific origin configurations
+type Options struct {
+	// LatencyOffsetSecs is the origin's -search.latencyOffset in seconds. VictoriaMetrics omits
+	// the datapoints of a range query that are newer than this, so they are not cached until they
+	// are older than it, regardless of a shorter backfill_tolerance_secs
+	LatencyOffsetSecs int `toml:"latency_offset_secs"`
+	// DenyPartialResponse requests that the origin fail a query when some of its vmstorage
+	// nodes are unavailable, rather than respond with partial results that would be cached
+	DenyPartialResponse bool `toml:"deny_partial_response"`
+
+	// LatencyOffset is the time.Duration representation of LatencyOffsetSecs
+	LatencyOffset time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		LatencyOffsetSecs:   d.DefaultVictoriaMetricsLatencyOffsetSecs,
+		DenyPartialResponse: d.DefaultVictoriaMetricsDenyPartialResponse,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := *o
+	return &o2
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.LatencyOffsetSecs < 0 {
+		return ErrInvalidLatencyOffset
+	}
+	return nil
+}
+
+// SetDurations populates the synthesized time.Duration values from their *Secs counterparts
+func (o *Options) SetDurations() {
+	o.LatencyOffset = time.Duration(o.LatencyOffsetSecs) * time.Second
+}
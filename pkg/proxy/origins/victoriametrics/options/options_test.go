@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	o := NewOptions()
+	if o.LatencyOffset != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, o.LatencyOffset)
+	}
+	if !o.DenyPartialResponse {
+		t.Errorf("expected %t got %t", true, o.DenyPartialResponse)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := NewOptions()
+	o.LatencyOffsetSecs = 60
+	o.SetDurations()
+	o2 := o.Clone()
+	if o2 == o {
+		t.Error("expected distinct pointers")
+	}
+	if o2.LatencyOffsetSecs != 60 || o2.LatencyOffset != time.Minute {
+		t.Errorf("expected %d/%s got %d/%s", 60, time.Minute, o2.LatencyOffsetSecs, o2.LatencyOffset)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	o := NewOptions()
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	o.LatencyOffsetSecs = -1
+	if err := o.Validate(); err != ErrInvalidLatencyOffset {
+		t.Errorf("expected %v got %v", ErrInvalidLatencyOffset, err)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"net/http"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
+	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+)
+
+func (c *Client) registerHandlers() {
+	c.handlersRegistered = true
+	c.handlers = make(map[string]http.Handler)
+	// VictoriaMetrics supports each of the Prometheus handlers, along with
+	// those of its own API extensions
+	for k, v := range c.Client.Handlers() {
+		c.handlers[k] = v
+	}
+	c.handlers[mnExport] = http.HandlerFunc(c.ExportHandler)
+}
+
+// Handlers returns a map of the HTTP Handlers the client has registered
+func (c *Client) Handlers() map[string]http.Handler {
+	if !c.handlersRegistered {
+		c.registerHandlers()
+	}
+	return c.handlers
+}
+
+// DefaultPathConfigs returns the default PathConfigs for the given OriginType
+func (c *Client) DefaultPathConfigs(oc *oo.Options) map[string]*po.Options {
+
+	paths := c.Client.DefaultPathConfigs(oc)
+
+	// the relabeling params filter the series of any query, and round_digits changes its values
+	for _, p := range []string{mnQueryRange, mnQuery, mnSeries, mnLabels, mnLabel + "/"} {
+		if pc, ok := paths[prometheus.APIPath+p]; ok {
+			pc.CacheKeyParams = append(pc.CacheKeyParams, relabelParams...)
+		}
+	}
+	for _, p := range []string{mnQueryRange, mnQuery} {
+		if pc, ok := paths[prometheus.APIPath+p]; ok {
+			pc.CacheKeyParams = append(pc.CacheKeyParams, upRoundDigits)
+		}
+	}
+	// VictoriaMetrics uses the step of an instant query as the window of its rollup
+	// functions that don't provide one
+	if pc, ok := paths[prometheus.APIPath+mnQuery]; ok {
+		pc.CacheKeyParams = append(pc.CacheKeyParams, upStep)
+	}
+
+	// the export path prefix includes the /export/csv and /export/native formats
+	paths[prometheus.APIPath+mnExport] = &po.Options{
+		Path:        prometheus.APIPath + mnExport,
+		HandlerName: mnExport,
+		Methods:     []string{http.MethodGet, http.MethodPost},
+		CacheKeyParams: append([]string{upMatch, upStart, upEnd, upFormat, upReduceMemUsage,
+			upMaxRowsPerLine}, relabelParams...),
+		CacheKeyHeaders: []string{},
+		MatchTypeName:   "prefix",
+		MatchType:       matching.PathMatchTypePrefix,
+	}
+
+	oc.FastForwardPath = paths[prometheus.APIPath+mnQuery].Clone()
+
+	return paths
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+)
+
+func TestHandlers(t *testing.T) {
+	c := newTestClient(t)
+	m := c.Handlers()
+	for _, n := range []string{mnQueryRange, mnQuery, mnExport, "proxycache", "proxy"} {
+		if _, ok := m[n]; !ok {
+			t.Errorf("expected to find handler named: %s", n)
+		}
+	}
+}
+
+func hasParam(params []string, p string) bool {
+	for _, v := range params {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDefaultPathConfigs(t *testing.T) {
+
+	c := newTestClient(t)
+	oc := c.Configuration()
+	dpc := c.DefaultPathConfigs(oc)
+
+	pc, ok := dpc[prometheus.APIPath+mnQueryRange]
+	if !ok {
+		t.Fatalf("expected to find path named: %s", prometheus.APIPath+mnQueryRange)
+	}
+	for _, p := range []string{"query", upStep, upExtraLabel, upExtraFilters, upRoundDigits} {
+		if !hasParam(pc.CacheKeyParams, p) {
+			t.Errorf("expected cache key param %s in %v", p, pc.CacheKeyParams)
+		}
+	}
+
+	pc, ok = dpc[prometheus.APIPath+mnQuery]
+	if !ok {
+		t.Fatalf("expected to find path named: %s", prometheus.APIPath+mnQuery)
+	}
+	if !hasParam(pc.CacheKeyParams, upStep) || !hasParam(pc.CacheKeyParams, upExtraLabel) {
+		t.Errorf("expected cache key params %s and %s in %v", upStep, upExtraLabel, pc.CacheKeyParams)
+	}
+	if oc.FastForwardPath == nil || !hasParam(oc.FastForwardPath.CacheKeyParams, upExtraFilters) {
+		t.Error("expected the fast forward path to include the relabeling params")
+	}
+
+	pc, ok = dpc[prometheus.APIPath+mnExport]
+	if !ok {
+		t.Fatalf("expected to find path named: %s", prometheus.APIPath+mnExport)
+	}
+	if pc.HandlerName != mnExport {
+		t.Errorf("expected %s got %s", mnExport, pc.HandlerName)
+	}
+	if !hasParam(pc.CacheKeyParams, upMatch) || !hasParam(pc.CacheKeyParams, upExtraLabel) {
+		t.Errorf("expected cache key params %s and %s in %v", upMatch, upExtraLabel, pc.CacheKeyParams)
+	}
+}
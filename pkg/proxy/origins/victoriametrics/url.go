@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+// SetExtent will change the upstream request query to use the provided Extent. The Extent is
+// widened to step boundaries, as VictoriaMetrics does itself for cacheable queries, so that the
+// result's timestamps are aligned to the step whether or not the origin's cache is enabled
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	e := *extent
+	if trq != nil && trq.Step > 0 {
+		e.Start = trq.TruncateToStep(e.Start)
+		if end := trq.TruncateToStep(e.End); end.Before(e.End) {
+			e.End = end.Add(trq.Step)
+		}
+	}
+	c.Client.SetExtent(r, trq, &e)
+	c.denyPartialResponse(r)
+}
+
+// FastForwardRequest returns an *http.Request crafted to collect Fast Forward
+// data from the Origin, based on the provided HTTP Request
+func (c *Client) FastForwardRequest(r *http.Request) (*http.Request, error) {
+	nr, err := c.Client.FastForwardRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	c.denyPartialResponse(nr)
+	return nr, nil
+}
+
+// denyPartialResponse requests that the origin fail the request rather than respond
+// with the partial results of an unavailable vmstorage node, when so configured
+func (c *Client) denyPartialResponse(r *http.Request) {
+	if c.options == nil || !c.options.DenyPartialResponse {
+		return
+	}
+	v, _, isBody := params.GetRequestValues(r)
+	v.Set(upDenyPartialResponse, "1")
+	params.SetRequestValues(r, v)
+	if isBody {
+		r.PostForm = v
+	}
+}
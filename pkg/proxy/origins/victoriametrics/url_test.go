@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	c := newTestClient(t)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://vm/api/v1/query_range?query=up&step=420", nil)
+	trq := &timeseries.TimeRangeQuery{Step: 7 * time.Minute, AlignToEpoch: true}
+	e := &timeseries.Extent{Start: time.Unix(1600000000, 0), End: time.Unix(1600003600, 0)}
+	c.SetExtent(r, trq, e)
+
+	const expected = "deny_partial_response=1&end=1600003860&query=up&start=1599999660&step=420"
+	if r.URL.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot [%s]", expected, r.URL.RawQuery)
+	}
+	// the provided extent is not modified
+	if e.Start.Unix() != 1600000000 || e.End.Unix() != 1600003600 {
+		t.Errorf("expected extent %d-%d got %d-%d", 1600000000, 1600003600, e.Start.Unix(), e.End.Unix())
+	}
+
+	c.options.DenyPartialResponse = false
+	r, _ = http.NewRequest(http.MethodPost, "http://vm/api/v1/query_range",
+		strings.NewReader("query=up&step=420"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.SetExtent(r, trq, e)
+	if r.PostForm.Get(upDenyPartialResponse) != "" {
+		t.Errorf("expected no %s got %s", upDenyPartialResponse, r.PostForm.Encode())
+	}
+}
+
+func TestFastForwardRequest(t *testing.T) {
+
+	c := newTestClient(t)
+
+	r, _ := http.NewRequest(http.MethodGet,
+		"http://vm/api/v1/query_range?query=up&start=1&end=1&step=1&extra_label=env%3Dprod", nil)
+	r2, err := c.FastForwardRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.URL.Path != "/api/v1/query" {
+		t.Errorf("expected %s got %s", "/api/v1/query", r2.URL.Path)
+	}
+	const expected = "deny_partial_response=1&extra_label=env%3Dprod&query=up"
+	if r2.URL.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot [%s]", expected, r2.URL.RawQuery)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package victoriametrics provides the VictoriaMetrics Origin Type, which extends
+// the Prometheus Origin Type with VictoriaMetrics' extensions to the Prometheus API
+package victoriametrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
+	vmo "github.com/tricksterproxy/trickster/pkg/proxy/origins/victoriametrics/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	tt "github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
+	"github.com/tricksterproxy/trickster/pkg/timeseries"
+)
+
+var _ origins.Client = (*Client)(nil)
+var _ origins.TimeseriesClient = (*Client)(nil)
+var _ origins.KeyVersioner = (*Client)(nil)
+
+// ErrNoCache is returned when a range query requests that the origin's cache be bypassed,
+// so that the query is proxied rather than served from Trickster's cache
+var ErrNoCache = errors.New("nocache requested")
+
+// VictoriaMetrics API
+const (
+	mnQueryRange = "query_range"
+	mnQuery      = "query"
+	mnSeries     = "series"
+	mnLabels     = "labels"
+	mnLabel      = "label"
+	mnExport     = "export"
+)
+
+// URL Parameter Names
+const (
+	upStart               = "start"
+	upEnd                 = "end"
+	upStep                = "step"
+	upMatch               = "match[]"
+	upNoCache             = "nocache"
+	upLatencyOffset       = "latency_offset"
+	upDenyPartialResponse = "deny_partial_response"
+	upRoundDigits         = "round_digits"
+	upExtraLabel          = "extra_label"
+	upExtraFilters        = "extra_filters[]"
+	upFormat              = "format"
+	upReduceMemUsage      = "reduce_mem_usage"
+	upMaxRowsPerLine      = "max_rows_per_line"
+)
+
+// relabelParams are the parameters with which VictoriaMetrics adds label filters to every
+// series selector of a query, so they must be part of the cache key of any query they accompany
+var relabelParams = []string{upExtraLabel, upExtraFilters}
+
+// defaultStep is the step VictoriaMetrics uses for a range query that does not provide one
+const defaultStep = 5 * time.Minute
+
+// Client Implements Proxy Client Interface
+type Client struct {
+	*prometheus.Client
+	options            *vmo.Options
+	handlers           map[string]http.Handler
+	handlersRegistered bool
+}
+
+// NewClient returns a new Client Instance
+func NewClient(name string, oc *oo.Options, router http.Handler,
+	cache cache.Cache) (origins.Client, error) {
+	pc, err := prometheus.NewClient(name, oc, router, cache)
+	vo := oc.VictoriaMetrics
+	if vo == nil {
+		vo = vmo.NewOptions()
+	}
+	client := &Client{Client: pc.(*prometheus.Client), options: vo}
+	client.Client.SetOriginClient(client)
+	return client, err
+}
+
+// parseTime converts a time URL parameter, which VictoriaMetrics accepts as Unix seconds
+// (with optional fractional seconds), RFC3339 or 'now', to time.Time
+func parseTime(s string) (time.Time, error) {
+	if s == "now" {
+		return time.Now(), nil
+	}
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		s, ns := math.Modf(t)
+		ns = math.Round(ns*1000) / 1000
+		return time.Unix(int64(s), int64(ns*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// parseDuration parses a duration URL parameter, which can be float64 seconds or
+// durations like 1d, 5m, etc.
+func parseDuration(input string) (time.Duration, error) {
+	v, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		return tt.ParseDuration(input)
+	}
+	return time.Duration(v * float64(time.Second)), nil
+}
+
+// isTrue returns true if the URL parameter value enables a VictoriaMetrics boolean flag
+func isTrue(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// latencyOffset returns the period, ending now, for which the origin omits datapoints from
+// range query results, as requested by the query or per the configured -search.latencyOffset
+func (c *Client) latencyOffset(qp url.Values) time.Duration {
+	if v := qp.Get(upLatencyOffset); v != "" {
+		if d, err := parseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return c.options.LatencyOffset
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request.
+// Unlike Prometheus, VictoriaMetrics defaults the step to 5m and the end to now, aligns results
+// to the step since the epoch, and omits datapoints newer than its latency offset, which is
+// applied as a minimum backfill tolerance to the queries that end within it
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	qp, _, isBody := params.GetRequestValues(r)
+	if isTrue(qp.Get(upNoCache)) {
+		return nil, ErrNoCache
+	}
+
+	// the defaults are only parsed, and not set in the client's request, since the fetches
+	// for the query set the end explicitly, and the origin applies the same default step
+	pv := make(url.Values, len(qp))
+	for k, v := range qp {
+		pv[k] = v
+	}
+	var defaulted bool
+	if pv.Get(upStep) == "" {
+		pv.Set(upStep, strconv.Itoa(int(defaultStep.Seconds())))
+		defaulted = true
+	}
+	if v := pv.Get(upEnd); v == "" || v == "now" {
+		pv.Set(upEnd, strconv.FormatInt(time.Now().Unix(), 10))
+		defaulted = true
+	}
+	if pv.Get(upStart) == "now" {
+		pv.Set(upStart, strconv.FormatInt(time.Now().Unix(), 10))
+		defaulted = true
+	}
+	pr := r
+	if defaulted {
+		pr = r.Clone(r.Context())
+		params.SetRequestValues(pr, pv)
+		if isBody {
+			pr.PostForm = pv
+		}
+	}
+
+	trq, err := c.Client.ParseTimeRangeQuery(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	// VictoriaMetrics aligns the start and end of cacheable range queries to multiples of the
+	// step since the epoch, and the timestamps of their results with them
+	trq.AlignToEpoch = true
+
+	var dbt time.Duration
+	if oc := c.Configuration(); oc != nil {
+		dbt = oc.BackfillTolerance
+	}
+	// the backfill tolerance is applied to the end of every query, so it is only raised to
+	// the latency offset for the queries whose end is within it
+	lo := c.latencyOffset(qp)
+	if lo > trq.GetBackfillTolerance(dbt) && trq.Extent.End.After(time.Now().Add(-lo)) {
+		trq.BackfillTolerance = lo
+	}
+
+	return trq, nil
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package victoriametrics
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/config"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+func newTestClient(t *testing.T) *Client {
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", "http://vm:8428", "-origin-type", "victoriametrics", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	c, err := NewClient("test", conf.Origins["default"], nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Client)
+}
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient("test", oo.NewOptions(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, ok := c.(*Client)
+	if !ok {
+		t.Fatalf("expected *Client got %T", c)
+	}
+	if client.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", client.Name())
+	}
+	if client.options == nil || !client.options.DenyPartialResponse {
+		t.Error("expected default victoriametrics options")
+	}
+
+	oc := oo.NewOptions()
+	oc.VictoriaMetrics = nil
+	c, _ = NewClient("test", oc, nil, nil)
+	if c.(*Client).options == nil {
+		t.Error("expected default victoriametrics options")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		err      bool
+	}{
+		{"1600000000", 1600000000, false},
+		{"1600000000.5", 1600000000, false},
+		{"2020-09-13T12:26:40Z", 1600000000, false},
+		{"-", 0, true},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			tm, err := parseTime(test.input)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t got %v", test.err, err)
+			}
+			if !test.err && tm.Unix() != test.expected {
+				t.Errorf("expected %d got %d", test.expected, tm.Unix())
+			}
+		})
+	}
+	if tm, _ := parseTime("now"); time.Since(tm) > time.Minute {
+		t.Errorf("expected now got %s", tm)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"1m", time.Minute},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			d, err := parseDuration(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != test.expected {
+				t.Errorf("expected %s got %s", test.expected, d)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	c := newTestClient(t)
+
+	v := url.Values{"query": {"up"}, "start": {"1600000000"}, "end": {"1600003600"},
+		"step": {"420"}}
+	r, _ := http.NewRequest(http.MethodGet, "http://vm/api/v1/query_range?"+v.Encode(), nil)
+	trq, err := c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Step != 7*time.Minute {
+		t.Errorf("expected %s got %s", 7*time.Minute, trq.Step)
+	}
+	if !trq.AlignToEpoch {
+		t.Error("expected epoch alignment")
+	}
+	trq.NormalizeExtent()
+	if trq.Extent.Start.Unix() != 1599999660 || trq.Extent.End.Unix() != 1600003440 {
+		t.Errorf("expected extent %d-%d got %d-%d", 1599999660, 1600003440,
+			trq.Extent.Start.Unix(), trq.Extent.End.Unix())
+	}
+	// the end of the query is long past the latency offset
+	if trq.BackfillTolerance != 0 {
+		t.Errorf("expected %s got %s", time.Duration(0), trq.BackfillTolerance)
+	}
+}
+
+func TestParseTimeRangeQueryDefaults(t *testing.T) {
+
+	c := newTestClient(t)
+
+	v := url.Values{"query": {"up"}, "start": {strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)}}
+	r, _ := http.NewRequest(http.MethodPost, "http://vm/api/v1/query_range",
+		strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	trq, err := c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Step != defaultStep {
+		t.Errorf("expected %s got %s", defaultStep, trq.Step)
+	}
+	if time.Since(trq.Extent.End) > time.Minute {
+		t.Errorf("expected end of now got %s", trq.Extent.End)
+	}
+	if trq.BackfillTolerance != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, trq.BackfillTolerance)
+	}
+	// the client's request is not changed by the defaults
+	if err = r.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	if r.PostForm.Get(upStep) != "" || r.PostForm.Get(upEnd) != "" {
+		t.Errorf("expected unmodified request got %s", r.PostForm.Encode())
+	}
+
+	v.Set(upLatencyOffset, "2m")
+	r, _ = http.NewRequest(http.MethodGet, "http://vm/api/v1/query_range?"+v.Encode(), nil)
+	trq, err = c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.BackfillTolerance != 2*time.Minute {
+		t.Errorf("expected %s got %s", 2*time.Minute, trq.BackfillTolerance)
+	}
+
+	// a longer configured backfill tolerance is not reduced
+	c.Configuration().BackfillTolerance = 5 * time.Minute
+	trq, err = c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.BackfillTolerance != 0 {
+		t.Errorf("expected %s got %s", time.Duration(0), trq.BackfillTolerance)
+	}
+}
+
+func TestParseTimeRangeQueryNoCache(t *testing.T) {
+	c := newTestClient(t)
+	v := url.Values{"query": {"up"}, "start": {"1600000000"}, "end": {"1600003600"},
+		"step": {"60"}, "nocache": {"1"}}
+	r, _ := http.NewRequest(http.MethodGet, "http://vm/api/v1/query_range?"+v.Encode(), nil)
+	if _, err := c.ParseTimeRangeQuery(r); err != ErrNoCache {
+		t.Errorf("expected %v got %v", ErrNoCache, err)
+	}
+}
+
+func TestParseTimeRangeQueryMissingStart(t *testing.T) {
+	c := newTestClient(t)
+	r, _ := http.NewRequest(http.MethodGet, "http://vm/api/v1/query_range?query=up", nil)
+	if _, err := c.ParseTimeRangeQuery(r); err == nil {
+		t.Error("expected error for missing start")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"net/http"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/key"
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
@@ -81,6 +82,21 @@ type Options struct {
 
 	// NoMetrics, when set to true, disables metrics decoration for the path
 	NoMetrics bool `toml:"no_metrics"`
+	// DownstreamCachingHeaders, when set to true, emits Cache-Control, Expires and ETag headers
+	// on responses for this path, calculated from the object's remaining cache freshness
+	DownstreamCachingHeaders bool `toml:"downstream_caching_headers"`
+	// CacheTags is the list of tags associated with objects cached for this path, in addition
+	// to those provided in the origin's Surrogate-Key and Cache-Tag response headers
+	CacheTags []string `toml:"cache_tags"`
+	// MaxObjectSizeBytes is the largest size of an object that is cached for this path, in place
+	// of the origin's max_object_size_bytes. Larger objects are proxied without being cached.
+	// When 0, the origin's and cache's limits apply
+	MaxObjectSizeBytes int `toml:"max_object_size_bytes"`
+	// NegativeCacheName is the name of the negative cache used for this path, in place of the
+	// origin's negative cache. When empty, the origin's negative cache applies
+	NegativeCacheName string `toml:"negative_cache_name"`
+	// NegativeCache is the negative cache named by NegativeCacheName, with TTLs converted to time.Durations
+	NegativeCache *negative.Cache `toml:"-"`
 	// HasCustomResponseBody is a boolean indicating if the response body is custom
 	// this flag allows an empty string response to be configured as a return value
 	HasCustomResponseBody bool `toml:"-"`
@@ -99,6 +115,7 @@ func NewOptions() *Options {
 		CacheKeyParams:          make([]string, 0),
 		CacheKeyHeaders:         make([]string, 0),
 		CacheKeyFormFields:      make([]string, 0),
+		CacheTags:               make([]string, 0),
 		Custom:                  make([]string, 0),
 		RequestHeaders:          make(map[string]string),
 		RequestParams:           make(map[string]string),
@@ -112,32 +129,38 @@ func (o *Options) Clone() *Options {
 	c := &Options{
 		Path: o.Path,
 		//		OriginConfig:            o.OriginConfig,
-		MatchTypeName:           o.MatchTypeName,
-		MatchType:               o.MatchType,
-		HandlerName:             o.HandlerName,
-		Handler:                 o.Handler,
-		RequestHeaders:          ts.CloneMap(o.RequestHeaders),
-		RequestParams:           ts.CloneMap(o.RequestParams),
-		ReqRewriter:             o.ReqRewriter,
-		ReqRewriterName:         o.ReqRewriterName,
-		ResponseHeaders:         ts.CloneMap(o.ResponseHeaders),
-		ResponseBody:            o.ResponseBody,
-		ResponseBodyBytes:       o.ResponseBodyBytes,
-		CollapsedForwardingName: o.CollapsedForwardingName,
-		CollapsedForwardingType: o.CollapsedForwardingType,
-		NoMetrics:               o.NoMetrics,
-		HasCustomResponseBody:   o.HasCustomResponseBody,
-		Methods:                 make([]string, len(o.Methods)),
-		CacheKeyParams:          make([]string, len(o.CacheKeyParams)),
-		CacheKeyHeaders:         make([]string, len(o.CacheKeyHeaders)),
-		CacheKeyFormFields:      make([]string, len(o.CacheKeyFormFields)),
-		Custom:                  make([]string, len(o.Custom)),
-		KeyHasher:               o.KeyHasher,
+		MatchTypeName:            o.MatchTypeName,
+		MatchType:                o.MatchType,
+		HandlerName:              o.HandlerName,
+		Handler:                  o.Handler,
+		RequestHeaders:           ts.CloneMap(o.RequestHeaders),
+		RequestParams:            ts.CloneMap(o.RequestParams),
+		ReqRewriter:              o.ReqRewriter,
+		ReqRewriterName:          o.ReqRewriterName,
+		ResponseHeaders:          ts.CloneMap(o.ResponseHeaders),
+		ResponseBody:             o.ResponseBody,
+		ResponseBodyBytes:        o.ResponseBodyBytes,
+		CollapsedForwardingName:  o.CollapsedForwardingName,
+		CollapsedForwardingType:  o.CollapsedForwardingType,
+		NoMetrics:                o.NoMetrics,
+		DownstreamCachingHeaders: o.DownstreamCachingHeaders,
+		MaxObjectSizeBytes:       o.MaxObjectSizeBytes,
+		NegativeCacheName:        o.NegativeCacheName,
+		NegativeCache:            o.NegativeCache.Clone(),
+		HasCustomResponseBody:    o.HasCustomResponseBody,
+		Methods:                  make([]string, len(o.Methods)),
+		CacheKeyParams:           make([]string, len(o.CacheKeyParams)),
+		CacheKeyHeaders:          make([]string, len(o.CacheKeyHeaders)),
+		CacheKeyFormFields:       make([]string, len(o.CacheKeyFormFields)),
+		CacheTags:                make([]string, len(o.CacheTags)),
+		Custom:                   make([]string, len(o.Custom)),
+		KeyHasher:                o.KeyHasher,
 	}
 	copy(c.Methods, o.Methods)
 	copy(c.CacheKeyParams, o.CacheKeyParams)
 	copy(c.CacheKeyHeaders, o.CacheKeyHeaders)
 	copy(c.CacheKeyFormFields, o.CacheKeyFormFields)
+	copy(c.CacheTags, o.CacheTags)
 	copy(c.Custom, o.Custom)
 	return c
 }
@@ -180,6 +203,15 @@ func (o *Options) Merge(o2 *Options) {
 			o.ResponseBodyBytes = o2.ResponseBodyBytes
 		case "no_metrics":
 			o.NoMetrics = o2.NoMetrics
+		case "downstream_caching_headers":
+			o.DownstreamCachingHeaders = o2.DownstreamCachingHeaders
+		case "cache_tags":
+			o.CacheTags = o2.CacheTags
+		case "max_object_size_bytes":
+			o.MaxObjectSizeBytes = o2.MaxObjectSizeBytes
+		case "negative_cache_name":
+			o.NegativeCacheName = o2.NegativeCacheName
+			o.NegativeCache = o2.NegativeCache
 		case "collapsed_forwarding":
 			o.CollapsedForwardingName = o2.CollapsedForwardingName
 			o.CollapsedForwardingType = o2.CollapsedForwardingType
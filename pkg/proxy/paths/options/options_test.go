@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/tricksterproxy/trickster/pkg/cache/negative"
 	"github.com/tricksterproxy/trickster/pkg/proxy/forwarding"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 )
@@ -61,7 +62,9 @@ func TestPathMerge(t *testing.T) {
 	pc2.Custom = []string{"path", "match_type", "handler", "methods",
 		"cache_key_params", "cache_key_headers", "cache_key_form_fields",
 		"request_headers", "request_params", "response_headers",
-		"response_code", "response_body", "no_metrics", "collapsed_forwarding"}
+		"response_code", "response_body", "no_metrics", "collapsed_forwarding",
+		"downstream_caching_headers", "cache_tags", "max_object_size_bytes",
+		"negative_cache_name"}
 
 	expectedPath := "testPath"
 	expectedHandlerName := "testHandler"
@@ -79,6 +82,11 @@ func TestPathMerge(t *testing.T) {
 	pc2.ResponseCode = 404
 	pc2.ResponseBody = "trickster"
 	pc2.NoMetrics = true
+	pc2.DownstreamCachingHeaders = true
+	pc2.CacheTags = []string{"tenant-a"}
+	pc2.MaxObjectSizeBytes = 1024
+	pc2.NegativeCacheName = "test"
+	pc2.NegativeCache = &negative.Cache{JitterPct: 10}
 	pc2.CollapsedForwardingName = "progressive"
 	pc2.CollapsedForwardingType = forwarding.CFTypeProgressive
 
@@ -108,6 +116,18 @@ func TestPathMerge(t *testing.T) {
 		t.Errorf("expected %d got %d", 1, len(pc.CacheKeyFormFields))
 	}
 
+	if len(pc.CacheTags) != 1 {
+		t.Errorf("expected %d got %d", 1, len(pc.CacheTags))
+	}
+
+	if pc.MaxObjectSizeBytes != 1024 {
+		t.Errorf("expected %d got %d", 1024, pc.MaxObjectSizeBytes)
+	}
+
+	if pc.NegativeCacheName != "test" || pc.NegativeCache == nil || pc.NegativeCache.JitterPct != 10 {
+		t.Errorf("expected negative cache %s got %s", "test", pc.NegativeCacheName)
+	}
+
 	if len(pc.RequestHeaders) != 1 {
 		t.Errorf("expected %d got %d", 1, len(pc.RequestHeaders))
 	}
@@ -136,6 +156,10 @@ func TestPathMerge(t *testing.T) {
 		t.Errorf("expected %t got %t", true, pc.NoMetrics)
 	}
 
+	if !pc.DownstreamCachingHeaders {
+		t.Errorf("expected %t got %t", true, pc.DownstreamCachingHeaders)
+	}
+
 	if pc.CollapsedForwardingName != "progressive" ||
 		pc.CollapsedForwardingType != forwarding.CFTypeProgressive {
 		t.Errorf("expected %s got %s", "progressive", pc.CollapsedForwardingName)
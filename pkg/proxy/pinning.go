@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoSPKIPinMatch is returned when no certificate in the upstream origin's chain matches a configured SPKI pin
+var ErrNoSPKIPinMatch = errors.New("no certificate in the upstream chain matches a configured spki pin")
+
+const spkiPinPrefix = "sha256/"
+
+// spkiPinVerifier returns a tls.Config VerifyPeerCertificate function that requires at least one
+// certificate in the upstream origin's chain to match one of the provided SPKI pins. Pins are
+// base64-encoded SHA-256 hashes of a certificate's Subject Public Key Info, optionally prefixed
+// with 'sha256/'
+func spkiPinVerifier(pins []string) (func([][]byte, [][]*x509.Certificate) error, error) {
+	m := make(map[[sha256.Size]byte]bool, len(pins))
+	for _, p := range pins {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(p), spkiPinPrefix))
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid spki pin: %s", p)
+		}
+		var h [sha256.Size]byte
+		copy(h[:], b)
+		m[h] = true
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		// when the chain was verified, the pin may match any certificate in a verified chain,
+		// including the trusted root; otherwise (e.g., insecure_skip_verify), only the
+		// certificates presented by the origin are considered
+		for _, chain := range verifiedChains {
+			for _, c := range chain {
+				if m[sha256.Sum256(c.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+		}
+		if len(verifiedChains) == 0 {
+			for _, raw := range rawCerts {
+				c, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				if m[sha256.Sum256(c.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+		}
+		return ErrNoSPKIPinMatch
+	}, nil
+}
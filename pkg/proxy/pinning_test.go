@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+)
+
+func TestSPKIPinVerifier(t *testing.T) {
+
+	_, err := spkiPinVerifier([]string{"invalid"})
+	if err == nil {
+		t.Error("expected error for invalid pin")
+	}
+
+	_, err = spkiPinVerifier([]string{base64.StdEncoding.EncodeToString([]byte("short"))})
+	if err == nil {
+		t.Error("expected error for invalid pin length")
+	}
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cert := ts.Certificate()
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+	badSum := sha256.Sum256([]byte("trickster"))
+	badPin := base64.StdEncoding.EncodeToString(badSum[:])
+
+	// write the test server's certificate to a dedicated CA bundle
+	f, err := ioutil.TempFile("", "trickster-ca-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	f.Close()
+
+	tests := []struct {
+		caBundle   string
+		serverName string
+		pins       []string
+		skipVerify bool
+		expectErr  bool
+	}{
+		// pinned and verified with the dedicated CA bundle
+		{f.Name(), "example.com", []string{badPin, pin}, false, false},
+		// pinned without chain verification
+		{"", "", []string{pin}, true, false},
+		// pin mismatch
+		{"", "", []string{badPin}, true, true},
+		// server name does not match the certificate
+		{f.Name(), "trickster.invalid", nil, false, true},
+		// the test server's certificate is not in the system CA's
+		{"", "", nil, false, true},
+	}
+
+	for i, test := range tests {
+		oc := oo.NewOptions()
+		oc.TLS.CABundlePath = test.caBundle
+		oc.TLS.ServerName = test.serverName
+		oc.TLS.SPKIPins = test.pins
+		oc.TLS.InsecureSkipVerify = test.skipVerify
+		c, err := NewHTTPClient(oc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Get(ts.URL)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if test.expectErr && err == nil {
+			t.Errorf("test %d: expected error", i)
+		} else if !test.expectErr && err != nil {
+			t.Errorf("test %d: %s", i, err)
+		}
+	}
+
+	oc := oo.NewOptions()
+	oc.TLS.SPKIPins = []string{"invalid"}
+	_, err = NewHTTPClient(oc)
+	if err == nil {
+		t.Error("expected error for invalid pin")
+	}
+
+	oc.TLS.SPKIPins = nil
+	oc.TLS.CABundlePath = f.Name() + ".invalid"
+	_, err = NewHTTPClient(oc)
+	if err == nil {
+		t.Error("expected error for invalid ca bundle path")
+	}
+
+}
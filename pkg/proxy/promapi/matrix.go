@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package promapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// Prometheus API error types
+const (
+	ErrorTypeBadData   = "bad_data"
+	ErrorTypeExecution = "execution"
+	ErrorTypeInternal  = "internal"
+)
+
+// Point is a single value of a Series
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Series is a labeled series of Points, converted from an origin's native response
+type Series struct {
+	Labels map[string]string
+	Points []Point
+}
+
+// ConvertFunc converts an origin's native JSON response body into Series
+type ConvertFunc func([]byte) ([]*Series, error)
+
+type matrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"`
+}
+
+type envelope struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type matrixData struct {
+	ResultType string          `json:"resultType"`
+	Result     []*matrixSeries `json:"result"`
+}
+
+// labelsKey returns a string that uniquely identifies a label set, for sorting
+func labelsKey(l map[string]string) string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + "\xff" + l[k] + "\xff")
+	}
+	return sb.String()
+}
+
+// MarshalMatrix returns a Prometheus query_range response document for the provided Series,
+// which are sorted by their labels, and whose Points are sorted by time
+func MarshalMatrix(series []*Series) ([]byte, error) {
+	sort.SliceStable(series, func(i, j int) bool {
+		return labelsKey(series[i].Labels) < labelsKey(series[j].Labels)
+	})
+	md := &matrixData{ResultType: "matrix", Result: make([]*matrixSeries, 0, len(series))}
+	for _, s := range series {
+		sort.SliceStable(s.Points, func(i, j int) bool {
+			return s.Points[i].Timestamp.Before(s.Points[j].Timestamp)
+		})
+		ms := &matrixSeries{Metric: s.Labels, Values: make([][2]interface{}, len(s.Points))}
+		if ms.Metric == nil {
+			ms.Metric = map[string]string{}
+		}
+		for i, p := range s.Points {
+			ms.Values[i] = [2]interface{}{
+				float64(p.Timestamp.UnixNano()/int64(time.Millisecond)) / 1000,
+				strconv.FormatFloat(p.Value, 'f', -1, 64),
+			}
+		}
+		md.Result = append(md.Result, ms)
+	}
+	return json.Marshal(&envelope{Status: "success", Data: md})
+}
+
+// WriteError writes a Prometheus API error document to the ResponseWriter
+func WriteError(w http.ResponseWriter, code int, errorType string, err error) {
+	b, _ := json.Marshal(&envelope{Status: "error", ErrorType: errorType, Error: err.Error()})
+	h := w.Header()
+	h.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	h.Del(headers.NameContentLength)
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// Writer buffers an origin's native JSON response, so it can be converted into a
+// Prometheus query_range response once the response is complete
+type Writer struct {
+	http.ResponseWriter
+	convert    ConvertFunc
+	statusCode int
+	body       *bytes.Buffer
+}
+
+// NewWriter returns a new Writer that converts the buffered response with the ConvertFunc
+func NewWriter(w http.ResponseWriter, convert ConvertFunc) *Writer {
+	return &Writer{ResponseWriter: w, convert: convert, statusCode: http.StatusOK,
+		body: &bytes.Buffer{}}
+}
+
+// WriteHeader records the status code until the response is flushed
+func (pw *Writer) WriteHeader(code int) {
+	pw.statusCode = code
+}
+
+// Write buffers the response body until the response is flushed
+func (pw *Writer) Write(b []byte) (int, error) {
+	return pw.body.Write(b)
+}
+
+// Flush writes the buffered response to the underlying ResponseWriter as a Prometheus
+// query_range response. An unsuccessful or unconvertible origin response is written
+// as a Prometheus API error document that includes the origin's response body
+func (pw *Writer) Flush() {
+	b := pw.body.Bytes()
+	if pw.statusCode != http.StatusOK {
+		WriteError(pw.ResponseWriter, pw.statusCode, ErrorTypeExecution,
+			fmt.Errorf("origin returned status %d: %s", pw.statusCode, bytes.TrimSpace(b)))
+		return
+	}
+	series, err := pw.convert(b)
+	if err == nil {
+		b, err = MarshalMatrix(series)
+	}
+	if err != nil {
+		WriteError(pw.ResponseWriter, http.StatusBadGateway, ErrorTypeInternal, err)
+		return
+	}
+	h := pw.ResponseWriter.Header()
+	h.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	h.Del(headers.NameContentLength)
+	pw.ResponseWriter.WriteHeader(http.StatusOK)
+	pw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package promapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMarshalMatrix(t *testing.T) {
+
+	series := []*Series{
+		{Labels: map[string]string{"__name__": "cpu", "host": "b"},
+			Points: []Point{{time.Unix(60, 0), 2}, {time.Unix(0, 0), 1.5}}},
+		{Labels: map[string]string{"__name__": "cpu", "host": "a"},
+			Points: []Point{{time.Unix(0, 500000000), 3}}},
+		{},
+	}
+
+	b, err := MarshalMatrix(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"status":"success","data":{"resultType":"matrix","result":[` +
+		`{"metric":{},"values":[]},` +
+		`{"metric":{"__name__":"cpu","host":"a"},"values":[[0.5,"3"]]},` +
+		`{"metric":{"__name__":"cpu","host":"b"},"values":[[0,"1.5"],[60,"2"]]}]}}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, string(b))
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, http.StatusBadRequest, ErrorTypeBadData, errors.New("test"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	expected := `{"status":"error","errorType":"bad_data","error":"test"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+}
+
+func TestWriter(t *testing.T) {
+
+	convert := func(b []byte) ([]*Series, error) {
+		if string(b) == "bad" {
+			return nil, errors.New("unable to convert")
+		}
+		return []*Series{{Labels: map[string]string{"a": string(b)}}}, nil
+	}
+
+	w := httptest.NewRecorder()
+	pw := NewWriter(w, convert)
+	pw.WriteHeader(http.StatusOK)
+	pw.Write([]byte("b"))
+	pw.Flush()
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	expected := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"a":"b"},"values":[]}]}}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	pw = NewWriter(w, convert)
+	pw.Write([]byte("bad"))
+	pw.Flush()
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	pw = NewWriter(w, convert)
+	pw.WriteHeader(http.StatusBadRequest)
+	pw.Write([]byte("error parsing query\n"))
+	pw.Flush()
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	expected = `{"status":"error","errorType":"execution","error":"origin returned status 400: error parsing query"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the Prometheus-compatible API options for an origin
+// whose native query language is not PromQL
+package options
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Options configures the Prometheus-compatible query_range API that an origin serves by
+// translating PromQL expressions into its native query language
+type Options struct {
+	// Templates is a map of named translations from PromQL expressions to native queries
+	Templates map[string]*TemplateOptions `toml:"templates"`
+
+	// templateOrder is the list of Templates, sorted by name
+	templateOrder []*TemplateOptions
+}
+
+// TemplateOptions describes the translation of a PromQL expression pattern to a native query
+type TemplateOptions struct {
+	// Match is the regular expression that must match the client's entire PromQL expression.
+	// Its named capture groups may be referenced in Query as ${name}
+	Match string `toml:"match"`
+	// Query is the native query sent to the origin. In addition to the named capture groups
+	// of Match, it may reference ${start}, ${end}, ${start_ms}, ${end_ms}, ${step} and ${step_secs}
+	Query string `toml:"query"`
+	// Params are additional URL query parameters sent to the origin with the native query
+	Params map[string]string `toml:"params"`
+
+	// Name is the Name of the template, taken from the Key in the Templates map
+	Name string `toml:"-"`
+	// Regexp is the compiled version of Match, anchored to the whole expression
+	Regexp *regexp.Regexp `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		Templates: make(map[string]*TemplateOptions),
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := NewOptions()
+	for k, v := range o.Templates {
+		o2.Templates[k] = v.Clone()
+	}
+	o2.sortTemplates()
+	return o2
+}
+
+// Clone returns an exact copy of the subject *TemplateOptions
+func (o *TemplateOptions) Clone() *TemplateOptions {
+	var p map[string]string
+	if o.Params != nil {
+		p = make(map[string]string, len(o.Params))
+		for k, v := range o.Params {
+			p[k] = v
+		}
+	}
+	return &TemplateOptions{
+		Match:  o.Match,
+		Query:  o.Query,
+		Params: p,
+		Name:   o.Name,
+		Regexp: o.Regexp,
+	}
+}
+
+// Enabled returns true if any templates are configured
+func (o *Options) Enabled() bool {
+	return o != nil && len(o.Templates) > 0
+}
+
+// Compile compiles the Match expression of each of the Templates and
+// determines the order in which they are tried
+func (o *Options) Compile() error {
+	for k, v := range o.Templates {
+		v.Name = k
+		if v.Match == "" {
+			return fmt.Errorf("missing match for prometheus_api template %s", k)
+		}
+		if v.Query == "" {
+			return fmt.Errorf("missing query for prometheus_api template %s", k)
+		}
+		re, err := regexp.Compile(`^(?:` + v.Match + `)$`)
+		if err != nil {
+			return fmt.Errorf("invalid match for prometheus_api template %s: %s", k, err.Error())
+		}
+		v.Regexp = re
+	}
+	o.sortTemplates()
+	return nil
+}
+
+func (o *Options) sortTemplates() {
+	o.templateOrder = make([]*TemplateOptions, 0, len(o.Templates))
+	for _, v := range o.Templates {
+		if v.Regexp != nil {
+			o.templateOrder = append(o.templateOrder, v)
+		}
+	}
+	sort.Slice(o.templateOrder, func(i, j int) bool {
+		return o.templateOrder[i].Name < o.templateOrder[j].Name
+	})
+}
+
+// Match returns the first template, in order of their names, whose Match expression matches
+// the provided PromQL expression, along with the values of its named capture groups
+func (o *Options) Match(query string) (*TemplateOptions, map[string]string) {
+	if o == nil {
+		return nil, nil
+	}
+	for _, v := range o.templateOrder {
+		m := v.Regexp.FindStringSubmatch(query)
+		if m == nil {
+			continue
+		}
+		vals := make(map[string]string)
+		for i, name := range v.Regexp.SubexpNames() {
+			if i > 0 && name != "" {
+				vals[name] = m[i]
+			}
+		}
+		return v, vals
+	}
+	return nil, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestCompileAndMatch(t *testing.T) {
+
+	o := NewOptions()
+	if o.Enabled() {
+		t.Error("expected false")
+	}
+
+	o.Templates["b_cpu"] = &TemplateOptions{
+		Match: `cpu_usage\{host="(?P<host>[^"]+)"\}`,
+		Query: `SELECT mean("usage") FROM "cpu" WHERE "host" = '${host}'`,
+	}
+	o.Templates["a_cpu"] = &TemplateOptions{
+		Match:  `cpu_usage`,
+		Query:  `SELECT mean("usage") FROM "cpu"`,
+		Params: map[string]string{"db": "telegraf"},
+	}
+
+	if err := o.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Enabled() {
+		t.Error("expected true")
+	}
+
+	tmpl, vals := o.Match(`cpu_usage{host="a"}`)
+	if tmpl == nil || tmpl.Name != "b_cpu" {
+		t.Fatalf("expected template %s got %v", "b_cpu", tmpl)
+	}
+	if vals["host"] != "a" {
+		t.Errorf("expected %s got %s", "a", vals["host"])
+	}
+
+	// templates must match the whole expression
+	if tmpl, _ = o.Match(`rate(cpu_usage[5m])`); tmpl != nil {
+		t.Errorf("expected no match got %s", tmpl.Name)
+	}
+
+	o2 := o.Clone()
+	if tmpl, _ = o2.Match(`cpu_usage`); tmpl == nil || tmpl.Name != "a_cpu" ||
+		tmpl.Params["db"] != "telegraf" {
+		t.Errorf("unexpected match %v", tmpl)
+	}
+
+	var o3 *Options
+	if tmpl, _ = o3.Match(`cpu_usage`); tmpl != nil {
+		t.Error("expected no match")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	tests := []struct {
+		tmpl     *TemplateOptions
+		expected string
+	}{
+		{&TemplateOptions{Query: "q"}, "missing match for prometheus_api template test"},
+		{&TemplateOptions{Match: "m"}, "missing query for prometheus_api template test"},
+		{&TemplateOptions{Match: "(", Query: "q"},
+			"invalid match for prometheus_api template test: error parsing regexp: missing closing ): `^(?:()$`"},
+	}
+
+	for _, test := range tests {
+		o := NewOptions()
+		o.Templates["test"] = test.tmpl
+		err := o.Compile()
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected error `%s` got `%v`", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package promapi serves a Prometheus-compatible query_range API from origins whose native
+// query language is not PromQL, by translating PromQL expressions into native queries with
+// the origin's configured templates and converting the results into a Prometheus matrix
+package promapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/params"
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/timeconv"
+)
+
+// QueryRangePath is the path of the Prometheus query_range API
+const QueryRangePath = "/api/v1/query_range"
+
+// Prometheus query_range URL Parameter Names
+const (
+	upQuery = "query"
+	upStart = "start"
+	upEnd   = "end"
+	upStep  = "step"
+)
+
+// ErrNoTemplate is returned when no template matches a PromQL expression
+var ErrNoTemplate = errors.New("no prometheus_api template matches the query")
+
+// Request is a parsed Prometheus query_range request
+type Request struct {
+	Query string
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// ParseRequest parses a Prometheus query_range request from the URL query or form body
+func ParseRequest(r *http.Request) (*Request, error) {
+
+	v, _, _ := params.GetRequestValues(r)
+
+	pr := &Request{Query: v.Get(upQuery)}
+	if pr.Query == "" {
+		return nil, fmt.Errorf("missing URL parameter: [%s]", upQuery)
+	}
+
+	var err error
+	if pr.Start, err = parseTime(upStart, v.Get(upStart)); err != nil {
+		return nil, err
+	}
+	if pr.End, err = parseTime(upEnd, v.Get(upEnd)); err != nil {
+		return nil, err
+	}
+	if pr.End.Before(pr.Start) {
+		return nil, errors.New("end timestamp must not be before start time")
+	}
+
+	s := v.Get(upStep)
+	if s == "" {
+		return nil, fmt.Errorf("missing URL parameter: [%s]", upStep)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		pr.Step = time.Duration(f * float64(time.Second))
+	} else if pr.Step, err = timeconv.ParseDuration(s); err != nil {
+		return nil, fmt.Errorf("cannot parse %q to a valid duration", s)
+	}
+	if pr.Step <= 0 {
+		return nil, errors.New("zero or negative query resolution step widths are not accepted")
+	}
+
+	return pr, nil
+}
+
+// parseTime converts a query time URL parameter, in Unix seconds or RFC3339 format, to time.Time
+func parseTime(name, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing URL parameter: [%s]", name)
+	}
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		s, ns := math.Modf(t)
+		ns = math.Round(ns*1000) / 1000
+		return time.Unix(int64(s), int64(ns*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// Translate returns the native query for the Request, from the first of the origin's templates
+// that matches its PromQL expression, along with the matching template
+func Translate(o *options.Options, pr *Request) (string, *options.TemplateOptions, error) {
+	t, vals := o.Match(strings.TrimSpace(pr.Query))
+	if t == nil {
+		return "", nil, ErrNoTemplate
+	}
+
+	step := strconv.FormatInt(int64(pr.Step/time.Second), 10) + "s"
+	if pr.Step%time.Second != 0 {
+		step = strconv.FormatInt(int64(pr.Step/time.Millisecond), 10) + "ms"
+	}
+
+	tokens := map[string]string{
+		"start":     strconv.FormatInt(pr.Start.Unix(), 10),
+		"end":       strconv.FormatInt(pr.End.Unix(), 10),
+		"start_ms":  strconv.FormatInt(pr.Start.UnixNano()/int64(time.Millisecond), 10),
+		"end_ms":    strconv.FormatInt(pr.End.UnixNano()/int64(time.Millisecond), 10),
+		"step":      step,
+		"step_secs": strconv.FormatInt(int64(pr.Step/time.Second), 10),
+	}
+	// named capture groups take precedence over the built-in tokens
+	for k, v := range vals {
+		tokens[k] = v
+	}
+
+	q := t.Query
+	for k, v := range tokens {
+		q = strings.Replace(q, "${"+k+"}", v, -1)
+	}
+	return q, t, nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package promapi
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/promapi/options"
+)
+
+func TestParseRequest(t *testing.T) {
+
+	r := httptest.NewRequest("GET",
+		"http://0/api/v1/query_range?query=up&start=1577836800&end=2020-01-01T01:00:00Z&step=1m", nil)
+	pr, err := ParseRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Query != "up" {
+		t.Errorf("expected %s got %s", "up", pr.Query)
+	}
+	if pr.Start.Unix() != 1577836800 {
+		t.Errorf("expected %d got %d", 1577836800, pr.Start.Unix())
+	}
+	if pr.End.Unix() != 1577840400 {
+		t.Errorf("expected %d got %d", 1577840400, pr.End.Unix())
+	}
+	if pr.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, pr.Step)
+	}
+
+	tests := []struct {
+		query, expected string
+	}{
+		{"start=1&end=2&step=1", "missing URL parameter: [query]"},
+		{"query=up&end=2&step=1", "missing URL parameter: [start]"},
+		{"query=up&start=x&end=2&step=1", `cannot parse "x" to a valid timestamp`},
+		{"query=up&start=2&end=1&step=1", "end timestamp must not be before start time"},
+		{"query=up&start=1&end=2", "missing URL parameter: [step]"},
+		{"query=up&start=1&end=2&step=x", `cannot parse "x" to a valid duration`},
+		{"query=up&start=1&end=2&step=0", "zero or negative query resolution step widths are not accepted"},
+	}
+
+	for _, test := range tests {
+		r = httptest.NewRequest("GET", "http://0/api/v1/query_range?"+test.query, nil)
+		_, err = ParseRequest(r)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected error `%s` got `%v`", test.expected, err)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+
+	o := options.NewOptions()
+	o.Templates["cpu"] = &options.TemplateOptions{
+		Match: `cpu_usage\{host="(?P<host>[a-z0-9.-]+)"\}`,
+		Query: `SELECT mean("usage") FROM "cpu" WHERE "host" = '${host}' AND ` +
+			`time >= ${start_ms}ms AND time <= ${end_ms}ms GROUP BY time(${step})`,
+	}
+	o.Templates["load"] = &options.TemplateOptions{
+		Match: `load1`,
+		Query: `SELECT t, avg(load) FROM load WHERE t BETWEEN ${start} AND ${end} ` +
+			`GROUP BY intDiv(t, ${step_secs}) * ${step_secs} AS t`,
+	}
+	if err := o.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &Request{Query: ` cpu_usage{host="a1"}`, Start: time.Unix(1577836800, 0),
+		End: time.Unix(1577840400, 0), Step: time.Minute}
+
+	q, tmpl, err := Translate(o, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Name != "cpu" {
+		t.Errorf("expected %s got %s", "cpu", tmpl.Name)
+	}
+	expected := `SELECT mean("usage") FROM "cpu" WHERE "host" = 'a1' AND ` +
+		`time >= 1577836800000ms AND time <= 1577840400000ms GROUP BY time(60s)`
+	if q != expected {
+		t.Errorf("expected %s got %s", expected, q)
+	}
+
+	pr.Query = "load1"
+	pr.Step = 1500 * time.Millisecond
+	q, _, err = Translate(o, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `SELECT t, avg(load) FROM load WHERE t BETWEEN 1577836800 AND 1577840400 ` +
+		`GROUP BY intDiv(t, 1) * 1 AS t`
+	if q != expected {
+		t.Errorf("expected %s got %s", expected, q)
+	}
+
+	pr.Query = `cpu_usage{host="a'1"}`
+	if _, _, err = Translate(o, pr); err != ErrNoTemplate {
+		t.Errorf("expected error `%v` got `%v`", ErrNoTemplate, err)
+	}
+}
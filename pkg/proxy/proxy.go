@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tricksterproxy/trickster/pkg/proxy/dns"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 )
 
@@ -41,6 +42,7 @@ func NewHTTPClient(oc *oo.Options) (*http.Client, error) {
 
 	if oc.TLS != nil {
 		TLSConfig = &tls.Config{InsecureSkipVerify: oc.TLS.InsecureSkipVerify}
+		oc.TLS.Policy.Apply(TLSConfig)
 
 		if oc.TLS.ClientCertPath != "" && oc.TLS.ClientKeyPath != "" {
 			// load client cert
@@ -51,16 +53,35 @@ func NewHTTPClient(oc *oo.Options) (*http.Client, error) {
 			TLSConfig.Certificates = []tls.Certificate{cert}
 		}
 
-		if oc.TLS.CertificateAuthorityPaths != nil && len(oc.TLS.CertificateAuthorityPaths) > 0 {
+		TLSConfig.ServerName = oc.TLS.ServerName
 
-			// credit snippet to https://forfuncsake.github.io/post/2017/08/trust-extra-ca-cert-in-go-app/
-			// Get the SystemCertPool, continue with an empty pool on error
-			rootCAs, _ := x509.SystemCertPool()
-			if rootCAs == nil {
+		if len(oc.TLS.SPKIPins) > 0 {
+			v, err := spkiPinVerifier(oc.TLS.SPKIPins)
+			if err != nil {
+				return nil, err
+			}
+			TLSConfig.VerifyPeerCertificate = v
+		}
+
+		if oc.TLS.CABundlePath != "" ||
+			(oc.TLS.CertificateAuthorityPaths != nil && len(oc.TLS.CertificateAuthorityPaths) > 0) {
+
+			var rootCAs *x509.CertPool
+			paths := oc.TLS.CertificateAuthorityPaths
+			if oc.TLS.CABundlePath != "" {
+				// a dedicated CA bundle replaces the system CA's for this origin
 				rootCAs = x509.NewCertPool()
+				paths = append([]string{oc.TLS.CABundlePath}, paths...)
+			} else {
+				// credit snippet to https://forfuncsake.github.io/post/2017/08/trust-extra-ca-cert-in-go-app/
+				// Get the SystemCertPool, continue with an empty pool on error
+				rootCAs, _ = x509.SystemCertPool()
+				if rootCAs == nil {
+					rootCAs = x509.NewCertPool()
+				}
 			}
 
-			for _, path := range oc.TLS.CertificateAuthorityPaths {
+			for _, path := range paths {
 				// Read in the cert file
 				certs, err := ioutil.ReadFile(path)
 				if err != nil {
@@ -77,13 +98,20 @@ func NewHTTPClient(oc *oo.Options) (*http.Client, error) {
 		}
 	}
 
+	dialer := &net.Dialer{KeepAlive: time.Duration(oc.KeepAliveTimeoutSecs) * time.Second}
+	dial := dialer.DialContext
+	// when the DNS cache is enabled, upstream hostnames are resolved through it
+	if oc.DNS.Enabled() {
+		dial = dns.NewResolver(oc.Name, oc.DNS, dialer).DialContext
+	}
+
 	return &http.Client{
 		Timeout: oc.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 		Transport: &http.Transport{
-			Dial:                (&net.Dialer{KeepAlive: time.Duration(oc.KeepAliveTimeoutSecs) * time.Second}).Dial,
+			DialContext:         dial,
 			MaxIdleConns:        oc.MaxIdleConns,
 			MaxIdleConnsPerHost: oc.MaxIdleConns,
 			TLSClientConfig:     TLSConfig,
@@ -52,6 +52,14 @@ func TestNewHTTPClient(t *testing.T) {
 		t.Error(err)
 	}
 
+	// test good originconfig, dns cache enabled
+	oc.DNS.CacheTTLSecs = 30
+	_, err = NewHTTPClient(oc)
+	if err != nil {
+		t.Error(err)
+	}
+	oc.DNS.CacheTTLSecs = 0
+
 	// test good originconfig, 1 good CA
 	oc.TLS.CertificateAuthorityPaths = []string{caFile}
 	_, err = NewHTTPClient(oc)
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the cache replication options for an origin
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the asynchronous replication of an origin's cached timeseries to the
+// same origin on peer Trickster clusters
+type Options struct {
+	// Peers is the list of base URLs (e.g., https://trickster.eu.example.com:8480) of the peer
+	// Trickster clusters that cached timeseries are replicated to. An empty list disables replication
+	Peers []string `toml:"peers"`
+	// SharedSecret authenticates replication requests between peers. When set, inbound
+	// replication requests that do not provide the same secret are rejected
+	SharedSecret string `toml:"shared_secret"`
+	// IntervalMS is the interval between pushes of newly-written objects to the peers
+	IntervalMS int `toml:"interval_ms"`
+	// TimeoutMS is the maximum duration of a single replication request to a peer
+	TimeoutMS int `toml:"timeout_ms"`
+	// MaxPending is the maximum number of objects awaiting replication. Objects written while
+	// the queue is full are not replicated until they are written again
+	MaxPending int `toml:"max_pending"`
+
+	// Interval is the time.Duration representation of IntervalMS
+	Interval time.Duration `toml:"-"`
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		IntervalMS: d.DefaultReplicationIntervalMS,
+		TimeoutMS:  d.DefaultReplicationTimeoutMS,
+		MaxPending: d.DefaultReplicationMaxPending,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	var peers []string
+	if o.Peers != nil {
+		peers = make([]string, len(o.Peers))
+		copy(peers, o.Peers)
+	}
+	return &Options{
+		Peers:        peers,
+		SharedSecret: o.SharedSecret,
+		IntervalMS:   o.IntervalMS,
+		TimeoutMS:    o.TimeoutMS,
+		MaxPending:   o.MaxPending,
+		Interval:     o.Interval,
+		Timeout:      o.Timeout,
+	}
+}
+
+// Enabled returns true if any replication peers are configured
+func (o *Options) Enabled() bool {
+	return o != nil && len(o.Peers) > 0
+}
+
+// SetDurations sets the time.Duration representations of the Options' millisecond-based values
+func (o *Options) SetDurations() {
+	o.Interval = time.Duration(o.IntervalMS) * time.Millisecond
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	for _, p := range o.Peers {
+		u, err := url.Parse(p)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("replication peers must be absolute http or https URLs")
+		}
+	}
+	if o.IntervalMS <= 0 {
+		return errors.New("replication interval_ms must be positive")
+	}
+	if o.TimeoutMS <= 0 {
+		return errors.New("replication timeout_ms must be positive")
+	}
+	if o.MaxPending <= 0 {
+		return errors.New("replication max_pending must be positive")
+	}
+	return nil
+}
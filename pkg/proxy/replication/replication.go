@@ -0,0 +1,330 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package replication asynchronously replicates an origin's cached timeseries to the same
+// origin on peer Trickster clusters. Each peer merges the replicated extents into its own
+// cached copy of the object, so clusters proxying the same data warm each other's caches
+package replication
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// maxDocumentBytes is the largest replicated document that is accepted from a peer
+const maxDocumentBytes = 64 << 20
+
+// ReadFunc returns the replicable encoding of the object cached at key, or nil if the
+// object is no longer in the cache
+type ReadFunc func(key string) []byte
+
+// MergeFunc merges a document replicated from a peer into the object cached at key, and
+// stores the result for ttl
+type MergeFunc func(key string, document []byte, ttl time.Duration) error
+
+// Replicator tracks the timeseries objects that an origin writes to its cache, and
+// periodically pushes them to the origin's replication peers. It also accepts the objects
+// pushed by the peers, which are merged into the cache without being replicated again.
+type Replicator struct {
+	originName string
+	originType string
+	keyPrefix  string
+	path       string
+	options    *options.Options
+	client     *http.Client
+
+	mtx     sync.Mutex
+	pending map[string]pendingObject
+	peers   map[string]*peerState
+	now     func() time.Time
+}
+
+// pendingObject is an object awaiting replication
+type pendingObject struct {
+	// expires is when the object expires from the cache, after which it is not replicated
+	expires time.Time
+	// queued is when the object was first written without having been replicated since
+	queued time.Time
+}
+
+// peerState records the outcome of the replication attempts to a peer
+type peerState struct {
+	lastSuccess time.Time
+	lastError   string
+}
+
+// Status describes the replication of an origin's cached timeseries to its peers
+type Status struct {
+	Origin string `json:"origin"`
+	// Pending is the number of objects awaiting replication
+	Pending int `json:"pending"`
+	// LagMS is how long the oldest object awaiting replication has been waiting
+	LagMS int64        `json:"lag_ms"`
+	Peers []PeerStatus `json:"peers"`
+}
+
+// PeerStatus describes the replication to a peer
+type PeerStatus struct {
+	URL string `json:"url"`
+	// LastSuccessMS is how long ago an object was last replicated to the peer, or -1 if none has been
+	LastSuccessMS int64  `json:"last_success_ms_ago"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// New returns a new Replicator for the named origin. keyPrefix is the origin's cache key
+// prefix, which is not replicated since peers may proxy different upstream hosts, and path is
+// the path of the origin's replication handler, which must be the same on each peer
+func New(originName, originType, keyPrefix, path string, o *options.Options) *Replicator {
+	return &Replicator{
+		originName: originName,
+		originType: originType,
+		keyPrefix:  keyPrefix,
+		path:       path,
+		options:    o,
+		client:     &http.Client{Timeout: o.Timeout},
+		pending:    make(map[string]pendingObject),
+		peers:      make(map[string]*peerState, len(o.Peers)),
+		now:        time.Now,
+	}
+}
+
+// Path returns the path of the origin's replication handler
+func (rp *Replicator) Path() string {
+	return rp.path
+}
+
+// Enqueue records that the object at key was written to the cache with the provided ttl,
+// so that it is pushed to the peers on the next interval
+func (rp *Replicator) Enqueue(key string, ttl time.Duration) {
+	if rp == nil {
+		return
+	}
+	rp.mtx.Lock()
+	now := rp.now()
+	if po, ok := rp.pending[key]; ok {
+		po.expires = now.Add(ttl)
+		rp.pending[key] = po
+	} else if len(rp.pending) < rp.options.MaxPending {
+		rp.pending[key] = pendingObject{expires: now.Add(ttl), queued: now}
+	} else {
+		metrics.ProxyReplicationObjects.WithLabelValues(rp.originName, rp.originType,
+			"sent", "dropped").Inc()
+	}
+	rp.mtx.Unlock()
+}
+
+// Len returns the number of objects awaiting replication
+func (rp *Replicator) Len() int {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+	return len(rp.pending)
+}
+
+// Run calls Push on each Interval until quit is closed
+func (rp *Replicator) Run(read ReadFunc, quit <-chan struct{}) {
+	ticker := time.NewTicker(rp.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			rp.Push(read)
+		}
+	}
+}
+
+// Push sends each of the objects awaiting replication to the peers. Objects that could not
+// be sent to every peer are retried on the next Push, unless they have since expired. Since
+// peers merge what they receive, sending an object more than once is harmless.
+func (rp *Replicator) Push(read ReadFunc) {
+	rp.mtx.Lock()
+	pending := rp.pending
+	rp.pending = make(map[string]pendingObject, len(pending))
+	rp.mtx.Unlock()
+
+	for k, po := range pending {
+		now := rp.now()
+		if !po.expires.After(now) {
+			continue
+		}
+		doc := read(k)
+		if doc == nil {
+			continue
+		}
+		var failed bool
+		for _, p := range rp.options.Peers {
+			if err := rp.send(p, k, doc, po.expires.Sub(now)); err != nil {
+				metrics.ProxyReplicationObjects.WithLabelValues(rp.originName, rp.originType,
+					"sent", "failed").Inc()
+				rp.record(p, err)
+				failed = true
+				continue
+			}
+			rp.record(p, nil)
+			metrics.ProxyReplicationObjects.WithLabelValues(rp.originName, rp.originType,
+				"sent", "success").Inc()
+		}
+		if failed {
+			rp.retry(k, po)
+		}
+	}
+}
+
+// retry re-queues key for the next Push. If it was written again in the meantime, the
+// newer expiration is kept along with the original queued time
+func (rp *Replicator) retry(key string, po pendingObject) {
+	rp.mtx.Lock()
+	if cur, ok := rp.pending[key]; ok {
+		cur.queued = po.queued
+		rp.pending[key] = cur
+	} else if len(rp.pending) < rp.options.MaxPending {
+		rp.pending[key] = po
+	}
+	rp.mtx.Unlock()
+}
+
+// record stores the outcome of a replication attempt to peer
+func (rp *Replicator) record(peer string, err error) {
+	rp.mtx.Lock()
+	ps, ok := rp.peers[peer]
+	if !ok {
+		ps = &peerState{}
+		rp.peers[peer] = ps
+	}
+	if err != nil {
+		ps.lastError = err.Error()
+	} else {
+		ps.lastSuccess = rp.now()
+		ps.lastError = ""
+	}
+	rp.mtx.Unlock()
+}
+
+// Status returns the current replication status of the origin
+func (rp *Replicator) Status() *Status {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+	now := rp.now()
+	s := &Status{
+		Origin:  rp.originName,
+		Pending: len(rp.pending),
+		Peers:   make([]PeerStatus, 0, len(rp.options.Peers)),
+	}
+	for _, po := range rp.pending {
+		if lag := int64(now.Sub(po.queued) / time.Millisecond); lag > s.LagMS {
+			s.LagMS = lag
+		}
+	}
+	for _, p := range rp.options.Peers {
+		st := PeerStatus{URL: p, LastSuccessMS: -1}
+		if ps, ok := rp.peers[p]; ok {
+			if !ps.lastSuccess.IsZero() {
+				st.LastSuccessMS = int64(now.Sub(ps.lastSuccess) / time.Millisecond)
+			}
+			st.LastError = ps.lastError
+		}
+		s.Peers = append(s.Peers, st)
+	}
+	return s
+}
+
+func (rp *Replicator) send(peer, key string, doc []byte, ttl time.Duration) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(peer, "/")+rp.path,
+		bytes.NewReader(doc))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(headers.NameContentType, headers.ValueApplicationMsgPack)
+	req.Header.Set(headers.NameTricksterReplicationKey, strings.TrimPrefix(key, rp.keyPrefix))
+	req.Header.Set(headers.NameTricksterReplicationTTL, strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	if rp.options.SharedSecret != "" {
+		req.Header.Set(headers.NameTricksterReplicationSecret, rp.options.SharedSecret)
+	}
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("peer %s responded with status %d", peer, resp.StatusCode)
+	}
+	return nil
+}
+
+// Handler returns an http.Handler that accepts the objects pushed by the peers and merges
+// them into the cache using merge
+func (rp *Replicator) Handler(merge MergeFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rp.options.SharedSecret != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get(headers.NameTricksterReplicationSecret)),
+				[]byte(rp.options.SharedSecret)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		key, ttl, err := parseRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc, err := ioutil.ReadAll(io.LimitReader(r.Body, maxDocumentBytes+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(doc) > maxDocumentBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err = merge(rp.keyPrefix+key, doc, ttl); err != nil {
+			metrics.ProxyReplicationObjects.WithLabelValues(rp.originName, rp.originType,
+				"received", "failed").Inc()
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		metrics.ProxyReplicationObjects.WithLabelValues(rp.originName, rp.originType,
+			"received", "success").Inc()
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseRequest returns the key suffix and ttl of a replication request. Only timeseries
+// objects are replicated, so keys outside of the delta proxy cache's namespace are rejected
+func parseRequest(r *http.Request) (string, time.Duration, error) {
+	key := r.Header.Get(headers.NameTricksterReplicationKey)
+	if !strings.HasPrefix(key, ".dpc.") || len(key) == len(".dpc.") {
+		return "", 0, errors.New("invalid replication key")
+	}
+	ms, err := strconv.ParseInt(r.Header.Get(headers.NameTricksterReplicationTTL), 10, 64)
+	if err != nil || ms <= 0 {
+		return "", 0, errors.New("invalid replication ttl")
+	}
+	return key, time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replication
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication/options"
+)
+
+func testReplicator(now time.Time, peers ...string) *Replicator {
+	o := &options.Options{Peers: peers, SharedSecret: "secret", IntervalMS: 1,
+		TimeoutMS: 1000, MaxPending: 3}
+	o.SetDurations()
+	rp := New("test", "test", "prefix", "/trickster/replication/test", o)
+	rp.now = func() time.Time { return now }
+	return rp
+}
+
+type testMerge struct {
+	key string
+	doc []byte
+	ttl time.Duration
+	err error
+}
+
+func (m *testMerge) merge(key string, doc []byte, ttl time.Duration) error {
+	m.key, m.doc, m.ttl = key, doc, ttl
+	return m.err
+}
+
+func TestNilReplicator(t *testing.T) {
+	var rp *Replicator
+	rp.Enqueue("test", time.Second) // should not panic
+}
+
+func TestEnqueue(t *testing.T) {
+
+	rp := testReplicator(time.Now())
+	rp.Enqueue("a", time.Hour)
+	rp.Enqueue("b", time.Hour)
+	rp.Enqueue("c", time.Hour)
+	// the queue is full, so new keys are dropped but queued keys are updated
+	rp.Enqueue("d", time.Hour)
+	rp.Enqueue("a", 2*time.Hour)
+
+	if rp.Len() != 3 {
+		t.Errorf("expected %d got %d", 3, rp.Len())
+	}
+	if _, ok := rp.pending["d"]; ok {
+		t.Error("expected key d to be dropped")
+	}
+	if d := rp.pending["a"].expires.Sub(rp.now()); d != 2*time.Hour {
+		t.Errorf("expected %s got %s", 2*time.Hour, d)
+	}
+
+}
+
+func TestPush(t *testing.T) {
+
+	now := time.Now()
+	m := &testMerge{}
+	peer := testReplicator(now)
+	ts := httptest.NewServer(peer.Handler(m.merge))
+	defer ts.Close()
+
+	rp := testReplicator(now, ts.URL)
+	rp.Enqueue("prefix.dpc.test", time.Hour)
+	rp.Enqueue("prefix.dpc.missing", time.Hour)
+	rp.Enqueue("prefix.dpc.expired", 0)
+
+	read := func(key string) []byte {
+		if key == "prefix.dpc.test" {
+			return []byte("document")
+		}
+		if key == "prefix.dpc.expired" {
+			t.Error("expected expired key to not be read")
+		}
+		return nil
+	}
+	rp.Push(read)
+
+	if m.key != "prefix.dpc.test" {
+		t.Errorf("expected %s got %s", "prefix.dpc.test", m.key)
+	}
+	if string(m.doc) != "document" {
+		t.Errorf("expected %s got %s", "document", string(m.doc))
+	}
+	if m.ttl != time.Hour {
+		t.Errorf("expected %s got %s", time.Hour, m.ttl)
+	}
+	if rp.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, rp.Len())
+	}
+
+	// objects that fail to replicate are retried on the next push
+	m.err = errors.New("test")
+	rp.Enqueue("prefix.dpc.test", time.Hour)
+	rp.Push(read)
+	if rp.Len() != 1 {
+		t.Errorf("expected %d got %d", 1, rp.Len())
+	}
+
+	m.err = nil
+	m.key = ""
+	rp.Push(read)
+	if m.key != "prefix.dpc.test" {
+		t.Errorf("expected %s got %s", "prefix.dpc.test", m.key)
+	}
+	if rp.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, rp.Len())
+	}
+
+}
+
+func TestHandler(t *testing.T) {
+
+	m := &testMerge{}
+	h := testReplicator(time.Now()).Handler(m.merge)
+
+	tests := []struct {
+		secret, key, ttl string
+		code             int
+	}{
+		{"secret", ".dpc.test", "60000", http.StatusNoContent},
+		{"wrong", ".dpc.test", "60000", http.StatusUnauthorized},
+		{"", ".dpc.test", "60000", http.StatusUnauthorized},
+		{"secret", ".opc.test", "60000", http.StatusBadRequest},
+		{"secret", ".dpc.", "60000", http.StatusBadRequest},
+		{"secret", ".dpc.test", "0", http.StatusBadRequest},
+		{"secret", ".dpc.test", "x", http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/trickster/replication/test",
+			bytes.NewReader([]byte("document")))
+		r.Header.Set(headers.NameTricksterReplicationSecret, test.secret)
+		r.Header.Set(headers.NameTricksterReplicationKey, test.key)
+		r.Header.Set(headers.NameTricksterReplicationTTL, test.ttl)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("test %d: expected %d got %d", i, test.code, w.Code)
+		}
+	}
+
+	// the local key prefix is applied to the replicated key
+	if m.key != "prefix.dpc.test" {
+		t.Errorf("expected %s got %s", "prefix.dpc.test", m.key)
+	}
+	if m.ttl != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, m.ttl)
+	}
+
+	// merge failures are reported to the peer
+	m.err = errors.New("test")
+	r := httptest.NewRequest(http.MethodPost, "/trickster/replication/test",
+		bytes.NewReader([]byte("document")))
+	r.Header.Set(headers.NameTricksterReplicationSecret, "secret")
+	r.Header.Set(headers.NameTricksterReplicationKey, ".dpc.test")
+	r.Header.Set(headers.NameTricksterReplicationTTL, "60000")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	b, _ := ioutil.ReadAll(w.Body)
+	if len(b) == 0 {
+		t.Error("expected error detail in response body")
+	}
+
+}
+
+func TestRun(t *testing.T) {
+
+	rp := testReplicator(time.Now())
+	rp.Enqueue("test", time.Hour)
+
+	quit := make(chan struct{})
+	read := make(chan bool, 1)
+	go rp.Run(func(key string) []byte {
+		select {
+		case read <- true:
+		default:
+		}
+		return nil
+	}, quit)
+
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Error("expected push to run")
+	}
+	close(quit)
+
+}
+
+func TestStatus(t *testing.T) {
+
+	now := time.Now()
+	m := &testMerge{err: errors.New("test")}
+	peer := testReplicator(now)
+	ts := httptest.NewServer(peer.Handler(m.merge))
+	defer ts.Close()
+
+	rp := testReplicator(now, ts.URL)
+	s := rp.Status()
+	if s.Origin != "test" || s.Pending != 0 || s.LagMS != 0 {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if len(s.Peers) != 1 || s.Peers[0].LastSuccessMS != -1 || s.Peers[0].LastError != "" {
+		t.Errorf("unexpected peer status %+v", s.Peers)
+	}
+
+	read := func(key string) []byte { return []byte("document") }
+
+	// lag is measured from when the object was first queued, across retries and re-writes
+	rp.Enqueue("prefix.dpc.test", time.Hour)
+	rp.now = func() time.Time { return now.Add(5 * time.Second) }
+	rp.Push(read)
+	rp.Enqueue("prefix.dpc.test", time.Hour)
+	s = rp.Status()
+	if s.Pending != 1 || s.LagMS != 5000 {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if s.Peers[0].LastSuccessMS != -1 || s.Peers[0].LastError == "" {
+		t.Errorf("unexpected peer status %+v", s.Peers)
+	}
+
+	m.err = nil
+	rp.Push(read)
+	rp.now = func() time.Time { return now.Add(7 * time.Second) }
+	s = rp.Status()
+	if s.Pending != 0 || s.LagMS != 0 {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if s.Peers[0].LastSuccessMS != 2000 || s.Peers[0].LastError != "" {
+		t.Errorf("unexpected peer status %+v", s.Peers)
+	}
+
+}
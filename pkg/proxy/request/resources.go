@@ -44,10 +44,20 @@ type Resources struct {
 	TimeRangeQuery    *timeseries.TimeRangeQuery
 	Tracer            *tracing.Tracer
 	Logger            *tl.Logger
+	// Canonical collects the details of how the request was handled, which are logged as
+	// a single summarizing event when the request completes. It is nil for subrequests.
+	Canonical tl.Pairs
 }
 
 // Clone returns an exact copy of the subject Resources collection
 func (r Resources) Clone() *Resources {
+	var canonical tl.Pairs
+	if r.Canonical != nil {
+		canonical = make(tl.Pairs, len(r.Canonical))
+		for k, v := range r.Canonical {
+			canonical[k] = v
+		}
+	}
 	return &Resources{
 		OriginConfig:      r.OriginConfig,
 		PathConfig:        r.PathConfig,
@@ -59,6 +69,7 @@ func (r Resources) Clone() *Resources {
 		TimeRangeQuery:    r.TimeRangeQuery,
 		Tracer:            r.Tracer,
 		Logger:            r.Logger,
+		Canonical:         canonical,
 	}
 }
 
@@ -29,10 +29,18 @@ import (
 func TestNewAndCloneResources(t *testing.T) {
 	r := NewResources(nil, nil, nil, nil, nil, nil, tl.ConsoleLogger("error"))
 	r.AlternateCacheTTL = time.Duration(1) * time.Second
+	r.Canonical = tl.Pairs{"cacheKey": "test"}
 	r2 := r.Clone()
 	if r2.AlternateCacheTTL != r.AlternateCacheTTL {
 		t.Errorf("expected %s got %s", r.AlternateCacheTTL.String(), r2.AlternateCacheTTL.String())
 	}
+	if r2.Canonical["cacheKey"] != "test" {
+		t.Errorf("expected %s got %v", "test", r2.Canonical["cacheKey"])
+	}
+	r2.Canonical["cacheKey"] = "test2"
+	if r.Canonical["cacheKey"] != "test" {
+		t.Error("expected cloned canonical pairs to be independent")
+	}
 }
 
 func TestGetAndSetResources(t *testing.T) {
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the timeseries retention window options for an origin
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the maximum age of an origin's cached timeseries data, which is
+// enforced regardless of how recently the data was accessed
+type Options struct {
+	// WindowDays is the number of days of timeseries data to keep in the cache. Older
+	// extents are truncated from cached objects. 0 disables the retention window
+	WindowDays int `toml:"window_days"`
+	// TrimIntervalSecs is the interval between passes of the background trimmer, which
+	// truncates cached objects that are not otherwise written to
+	TrimIntervalSecs int `toml:"trim_interval_secs"`
+
+	// Window is the time.Duration representation of WindowDays
+	Window time.Duration `toml:"-"`
+	// TrimInterval is the time.Duration representation of TrimIntervalSecs
+	TrimInterval time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		TrimIntervalSecs: d.DefaultRetentionTrimIntervalSecs,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		WindowDays:       o.WindowDays,
+		TrimIntervalSecs: o.TrimIntervalSecs,
+		Window:           o.Window,
+		TrimInterval:     o.TrimInterval,
+	}
+}
+
+// Enabled returns true if a retention window is configured
+func (o *Options) Enabled() bool {
+	return o != nil && o.WindowDays > 0
+}
+
+// SetDurations sets the time.Duration representations of the Options' day- and seconds-based values
+func (o *Options) SetDurations() {
+	o.Window = time.Duration(o.WindowDays) * 24 * time.Hour
+	o.TrimInterval = time.Duration(o.TrimIntervalSecs) * time.Second
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.WindowDays < 0 {
+		return errors.New("retention window_days must not be negative")
+	}
+	if o.TrimIntervalSecs <= 0 {
+		return errors.New("retention trim_interval_secs must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package retention enforces an origin's timeseries retention window, truncating cached
+// extents that are older than the window regardless of how recently they were accessed
+package retention
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Result describes the outcome of trimming a cached object to the retention window
+type Result int
+
+const (
+	// ResultUnchanged indicates the object had no data older than the retention window
+	ResultUnchanged Result = iota
+	// ResultTruncated indicates the object's data older than the retention window was removed
+	ResultTruncated
+	// ResultRemoved indicates all of the object's data was older than the retention window,
+	// so the object was removed from the cache
+	ResultRemoved
+	// ResultMissing indicates the object is no longer in the cache
+	ResultMissing
+)
+
+// TrimFunc truncates the cached object at key to data newer than oldest, and re-stores it
+// for the remaining ttl
+type TrimFunc func(key string, oldest time.Time, ttl time.Duration) Result
+
+// Trimmer tracks the timeseries objects that an origin writes to its cache, and periodically
+// truncates them to the origin's retention window. Objects are also truncated each time the
+// origin writes them, so the Trimmer only needs to visit objects that are no longer queried.
+type Trimmer struct {
+	originName string
+	originType string
+	options    *options.Options
+
+	mtx  sync.Mutex
+	keys map[string]time.Time
+	now  func() time.Time
+}
+
+// New returns a new Trimmer for the named origin
+func New(originName, originType string, o *options.Options) *Trimmer {
+	return &Trimmer{
+		originName: originName,
+		originType: originType,
+		options:    o,
+		keys:       make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// Oldest returns the oldest timestamp that may be retained in the cache, or the zero time
+// if the Trimmer is nil
+func (t *Trimmer) Oldest() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.now().Add(-t.options.Window)
+}
+
+// Track records that the object at key was written to the cache with the provided ttl
+func (t *Trimmer) Track(key string, ttl time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	t.keys[key] = t.now().Add(ttl)
+	t.mtx.Unlock()
+}
+
+// Len returns the number of objects being tracked
+func (t *Trimmer) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.keys)
+}
+
+// Run calls Trim on each TrimInterval until quit is closed
+func (t *Trimmer) Run(trim TrimFunc, quit <-chan struct{}) {
+	ticker := time.NewTicker(t.options.TrimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			t.Trim(trim)
+		}
+	}
+}
+
+// Trim makes a single pass through the tracked objects, truncating each to the retention window
+func (t *Trimmer) Trim(trim TrimFunc) {
+	now := t.now()
+	oldest := now.Add(-t.options.Window)
+
+	// the key list is copied so the lock is not held while objects are rewritten
+	t.mtx.Lock()
+	keys := make(map[string]time.Time, len(t.keys))
+	for k, exp := range t.keys {
+		if !exp.After(now) {
+			delete(t.keys, k)
+			continue
+		}
+		keys[k] = exp
+	}
+	t.mtx.Unlock()
+
+	for k, exp := range keys {
+		switch trim(k, oldest, exp.Sub(now)) {
+		case ResultTruncated:
+			metrics.ProxyRetentionTrims.WithLabelValues(t.originName, t.originType, "truncated").Inc()
+		case ResultRemoved:
+			metrics.ProxyRetentionTrims.WithLabelValues(t.originName, t.originType, "removed").Inc()
+			t.forget(k, exp)
+		case ResultMissing:
+			t.forget(k, exp)
+		}
+	}
+}
+
+// forget stops tracking key, unless it was rewritten since exp was read
+func (t *Trimmer) forget(key string, exp time.Time) {
+	t.mtx.Lock()
+	if t.keys[key].Equal(exp) {
+		delete(t.keys, key)
+	}
+	t.mtx.Unlock()
+}
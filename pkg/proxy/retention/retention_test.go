@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention/options"
+)
+
+func testTrimmer(now time.Time) *Trimmer {
+	o := &options.Options{WindowDays: 30, TrimIntervalSecs: 1}
+	o.SetDurations()
+	t := New("test", "test", o)
+	t.now = func() time.Time { return now }
+	return t
+}
+
+func TestNilTrimmer(t *testing.T) {
+	var tr *Trimmer
+	if !tr.Oldest().IsZero() {
+		t.Errorf("expected zero time, got %s", tr.Oldest())
+	}
+	tr.Track("test", time.Second) // should not panic
+}
+
+func TestOldest(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tr := testTrimmer(now)
+	expected := now.Add(-30 * 24 * time.Hour)
+	if !tr.Oldest().Equal(expected) {
+		t.Errorf("expected %s got %s", expected, tr.Oldest())
+	}
+}
+
+func TestTrim(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	tr := testTrimmer(now)
+
+	tr.Track("unchanged", time.Hour)
+	tr.Track("truncated", time.Hour)
+	tr.Track("removed", time.Hour)
+	tr.Track("missing", time.Hour)
+	tr.Track("expired", 0)
+
+	if tr.Len() != 5 {
+		t.Errorf("expected %d got %d", 5, tr.Len())
+	}
+
+	results := map[string]Result{
+		"unchanged": ResultUnchanged,
+		"truncated": ResultTruncated,
+		"removed":   ResultRemoved,
+		"missing":   ResultMissing,
+	}
+
+	visited := make(map[string]time.Duration)
+	tr.Trim(func(key string, oldest time.Time, ttl time.Duration) Result {
+		if !oldest.Equal(tr.Oldest()) {
+			t.Errorf("expected %s got %s", tr.Oldest(), oldest)
+		}
+		visited[key] = ttl
+		return results[key]
+	})
+
+	if _, ok := visited["expired"]; ok {
+		t.Error("expected expired key to not be trimmed")
+	}
+	if visited["truncated"] != time.Hour {
+		t.Errorf("expected %s got %s", time.Hour, visited["truncated"])
+	}
+
+	// only the unchanged and truncated objects remain tracked
+	if tr.Len() != 2 {
+		t.Errorf("expected %d got %d", 2, tr.Len())
+	}
+
+}
+
+func TestTrimRetracked(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	tr := testTrimmer(now)
+	tr.Track("test", time.Hour)
+
+	// a key that is rewritten while it is being trimmed remains tracked
+	tr.Trim(func(key string, oldest time.Time, ttl time.Duration) Result {
+		tr.Track(key, 2*time.Hour)
+		return ResultMissing
+	})
+
+	if tr.Len() != 1 {
+		t.Errorf("expected %d got %d", 1, tr.Len())
+	}
+
+}
+
+func TestRun(t *testing.T) {
+
+	tr := testTrimmer(time.Now())
+	tr.options.TrimInterval = time.Millisecond
+	tr.Track("test", time.Hour)
+
+	quit := make(chan struct{})
+	trimmed := make(chan bool, 1)
+	go tr.Run(func(key string, oldest time.Time, ttl time.Duration) Result {
+		select {
+		case trimmed <- true:
+		default:
+		}
+		return ResultUnchanged
+	}, quit)
+
+	select {
+	case <-trimmed:
+	case <-time.After(time.Second):
+		t.Error("expected trim to run")
+	}
+	close(quit)
+
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the service level objective options for an origin
+package options
+
+import (
+	"errors"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options is a collection of service level objectives for an origin, against which
+// Trickster reports burn rates and the remaining error budget
+type Options struct {
+	// HitRatioTarget is the fraction (0.0 to 1.0) of cacheable requests that should be served
+	// from the cache. 0 disables the hit ratio objective
+	HitRatioTarget float64 `toml:"hit_ratio_target"`
+	// LatencyTargetMS is the response time, in milliseconds, within which LatencyObjective of
+	// requests should be served. 0 disables the latency objective
+	LatencyTargetMS int `toml:"latency_target_ms"`
+	// LatencyObjective is the fraction (0.0 to 1.0) of requests that should be served within
+	// LatencyTargetMS (e.g., 0.99 for a p99 latency objective)
+	LatencyObjective float64 `toml:"latency_objective"`
+	// PeriodSecs is the compliance period over which the remaining error budget is measured.
+	// It must be a multiple of 3600
+	PeriodSecs int `toml:"period_secs"`
+	// BurnRateWindowsSecs provides the windows over which burn rates are reported.
+	// Each must be a multiple of 60, no longer than 1 day and no longer than the period
+	BurnRateWindowsSecs []int `toml:"burn_rate_windows_secs"`
+
+	// LatencyTarget is the time.Duration representation of LatencyTargetMS
+	LatencyTarget time.Duration `toml:"-"`
+	// Period is the time.Duration representation of PeriodSecs
+	Period time.Duration `toml:"-"`
+	// BurnRateWindows is the time.Duration representation of BurnRateWindowsSecs
+	BurnRateWindows []time.Duration `toml:"-"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		LatencyObjective:    d.DefaultSLOLatencyObjective,
+		PeriodSecs:          d.DefaultSLOPeriodSecs,
+		BurnRateWindowsSecs: d.DefaultSLOBurnRateWindowsSecs(),
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	var ws []int
+	if o.BurnRateWindowsSecs != nil {
+		ws = make([]int, len(o.BurnRateWindowsSecs))
+		copy(ws, o.BurnRateWindowsSecs)
+	}
+	var w []time.Duration
+	if o.BurnRateWindows != nil {
+		w = make([]time.Duration, len(o.BurnRateWindows))
+		copy(w, o.BurnRateWindows)
+	}
+	return &Options{
+		HitRatioTarget:      o.HitRatioTarget,
+		LatencyTargetMS:     o.LatencyTargetMS,
+		LatencyObjective:    o.LatencyObjective,
+		PeriodSecs:          o.PeriodSecs,
+		BurnRateWindowsSecs: ws,
+		LatencyTarget:       o.LatencyTarget,
+		Period:              o.Period,
+		BurnRateWindows:     w,
+	}
+}
+
+// Enabled returns true if either objective is configured
+func (o *Options) Enabled() bool {
+	return o != nil && (o.HitRatioTarget > 0 || o.LatencyTargetMS > 0)
+}
+
+// SetDurations sets the time.Duration representations of the Options' seconds-based values
+func (o *Options) SetDurations() {
+	o.LatencyTarget = time.Duration(o.LatencyTargetMS) * time.Millisecond
+	o.Period = time.Duration(o.PeriodSecs) * time.Second
+	o.BurnRateWindows = make([]time.Duration, len(o.BurnRateWindowsSecs))
+	for i, w := range o.BurnRateWindowsSecs {
+		o.BurnRateWindows[i] = time.Duration(w) * time.Second
+	}
+}
+
+// Validate returns an error if the Options contain invalid values
+func (o *Options) Validate() error {
+	if o.HitRatioTarget < 0 || o.HitRatioTarget >= 1 {
+		return errors.New("slo hit_ratio_target must be at least 0 and less than 1")
+	}
+	if o.LatencyTargetMS < 0 {
+		return errors.New("slo latency_target_ms must not be negative")
+	}
+	if o.LatencyObjective <= 0 || o.LatencyObjective >= 1 {
+		return errors.New("slo latency_objective must be greater than 0 and less than 1")
+	}
+	if o.PeriodSecs <= 0 || o.PeriodSecs%3600 != 0 {
+		return errors.New("slo period_secs must be a positive multiple of 3600")
+	}
+	for _, w := range o.BurnRateWindowsSecs {
+		if w <= 0 || w%60 != 0 || w > 86400 || w > o.PeriodSecs {
+			return errors.New("slo burn_rate_windows_secs must be positive multiples of 60, " +
+				"no longer than 86400 or period_secs")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package slo tracks an origin's cache hit ratio and latency against its service level
+// objectives, and reports precomputed burn rates and remaining error budgets as metrics
+package slo
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/slo/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Names of the objectives, as used in metric labels
+const (
+	ObjectiveHitRatio = "hit_ratio"
+	ObjectiveLatency  = "latency"
+)
+
+// refreshInterval is the minimum time between updates of the reported metrics
+const refreshInterval = 10 * time.Second
+
+// counts are the outcomes of the requests observed during a bucket of time
+type counts struct {
+	cacheable int64
+	misses    int64
+	requests  int64
+	slow      int64
+}
+
+func (c *counts) add(c2 *counts) {
+	c.cacheable += c2.cacheable
+	c.misses += c2.misses
+	c.requests += c2.requests
+	c.slow += c2.slow
+}
+
+// ring is a circular list of fixed-width time buckets of counts
+type ring struct {
+	width     time.Duration
+	buckets   []counts
+	head      int
+	headStart time.Time
+}
+
+func newRing(width time.Duration, size int, now time.Time) *ring {
+	return &ring{width: width, buckets: make([]counts, size), headStart: now.Truncate(width)}
+}
+
+// advance moves the head of the ring to the bucket containing now, clearing any expired buckets
+func (r *ring) advance(now time.Time) {
+	steps := int(now.Sub(r.headStart) / r.width)
+	if steps <= 0 {
+		return
+	}
+	if steps >= len(r.buckets) {
+		for i := range r.buckets {
+			r.buckets[i] = counts{}
+		}
+	} else {
+		for i := 0; i < steps; i++ {
+			r.head = (r.head + 1) % len(r.buckets)
+			r.buckets[r.head] = counts{}
+		}
+	}
+	r.headStart = r.headStart.Add(time.Duration(steps) * r.width)
+}
+
+// sum returns the total counts of the n most recent buckets
+func (r *ring) sum(n int) counts {
+	var c counts
+	if n > len(r.buckets) {
+		n = len(r.buckets)
+	}
+	for i := 0; i < n; i++ {
+		c.add(&r.buckets[(r.head-i+len(r.buckets))%len(r.buckets)])
+	}
+	return c
+}
+
+// Tracker measures an origin's requests against its service level objectives. Burn rates are
+// measured over the configured windows using per-minute buckets, and the remaining error budget
+// is measured over the compliance period using hourly buckets
+type Tracker struct {
+	originName string
+	originType string
+	options    *options.Options
+
+	mtx         sync.Mutex
+	minutes     *ring
+	hours       *ring
+	lastRefresh time.Time
+	now         func() time.Time
+}
+
+// New returns a new Tracker for the named origin
+func New(originName, originType string, o *options.Options) *Tracker {
+	t := &Tracker{
+		originName: originName,
+		originType: originType,
+		options:    o,
+		now:        time.Now,
+	}
+	var maxWindow time.Duration
+	for _, w := range o.BurnRateWindows {
+		if w > maxWindow {
+			maxWindow = w
+		}
+	}
+	now := t.now()
+	t.minutes = newRing(time.Minute, int(maxWindow/time.Minute), now)
+	t.hours = newRing(time.Hour, int(o.Period/time.Hour), now)
+	t.report()
+	return t
+}
+
+// Observe records the cache lookup status and response time of a request
+func (t *Tracker) Observe(cacheStatus status.LookupStatus, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	var c counts
+	switch cacheStatus {
+	case status.LookupStatusProxyOnly:
+		// uncacheable requests do not count towards the hit ratio
+	case status.LookupStatusHit, status.LookupStatusPartialHit, status.LookupStatusRevalidated,
+		status.LookupStatusNegativeCacheHit, status.LookupStatusProxyHit:
+		c.cacheable = 1
+	default:
+		c.cacheable = 1
+		c.misses = 1
+	}
+	c.requests = 1
+	if elapsed > t.options.LatencyTarget {
+		c.slow = 1
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+	t.minutes.advance(now)
+	t.hours.advance(now)
+	if len(t.minutes.buckets) > 0 {
+		t.minutes.buckets[t.minutes.head].add(&c)
+	}
+	t.hours.buckets[t.hours.head].add(&c)
+	if now.Sub(t.lastRefresh) >= refreshInterval {
+		t.lastRefresh = now
+		t.report()
+	}
+}
+
+// report updates the metrics with the current burn rates and remaining error budgets.
+// The caller must hold the lock
+func (t *Tracker) report() {
+	o := t.options
+	for _, w := range o.BurnRateWindows {
+		c := t.minutes.sum(int(w / time.Minute))
+		label := windowLabel(w)
+		if o.HitRatioTarget > 0 {
+			metrics.ProxySLOBurnRate.WithLabelValues(t.originName, t.originType,
+				ObjectiveHitRatio, label).Set(burnRate(c.misses, c.cacheable, o.HitRatioTarget))
+		}
+		if o.LatencyTargetMS > 0 {
+			metrics.ProxySLOBurnRate.WithLabelValues(t.originName, t.originType,
+				ObjectiveLatency, label).Set(burnRate(c.slow, c.requests, o.LatencyObjective))
+		}
+	}
+	c := t.hours.sum(len(t.hours.buckets))
+	if o.HitRatioTarget > 0 {
+		metrics.ProxySLOErrorBudgetRemaining.WithLabelValues(t.originName, t.originType,
+			ObjectiveHitRatio).Set(budgetRemaining(c.misses, c.cacheable, o.HitRatioTarget))
+	}
+	if o.LatencyTargetMS > 0 {
+		metrics.ProxySLOErrorBudgetRemaining.WithLabelValues(t.originName, t.originType,
+			ObjectiveLatency).Set(budgetRemaining(c.slow, c.requests, o.LatencyObjective))
+	}
+}
+
+// burnRate returns the rate at which the error budget is consumed, relative to the rate
+// that would exactly exhaust it by the end of the compliance period
+func burnRate(bad, total int64, target float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return (float64(bad) / float64(total)) / (1 - target)
+}
+
+// budgetRemaining returns the fraction of the error budget that is unspent. It is negative
+// when the budget has been overspent
+func budgetRemaining(bad, total int64, target float64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(bad)/((1-target)*float64(total))
+}
+
+// windowLabel returns the metric label for the window (e.g., '5m', '1h')
+func windowLabel(w time.Duration) string {
+	if w%time.Hour == 0 {
+		return strconv.Itoa(int(w/time.Hour)) + "h"
+	}
+	return strconv.Itoa(int(w/time.Minute)) + "m"
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/proxy/slo/options"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func testTracker(name string, now *time.Time) *Tracker {
+	o := options.NewOptions()
+	o.HitRatioTarget = 0.9
+	o.LatencyTargetMS = 100
+	o.PeriodSecs = 7200
+	o.BurnRateWindowsSecs = []int{120, 3600}
+	o.SetDurations()
+	t := New(name, "test", o)
+	t.now = func() time.Time { return *now }
+	t.minutes.headStart = *now
+	t.hours.headStart = *now
+	return t
+}
+
+func gaugeValue(t *testing.T, name, objective, window string) float64 {
+	var m dto.Metric
+	var err error
+	if window == "" {
+		err = metrics.ProxySLOErrorBudgetRemaining.WithLabelValues(name, "test", objective).Write(&m)
+	} else {
+		err = metrics.ProxySLOBurnRate.WithLabelValues(name, "test", objective, window).Write(&m)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func approx(f1, f2 float64) bool {
+	return math.Abs(f1-f2) < 0.0001
+}
+
+func TestTracker(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	tr := testTracker("slo-test", &now)
+
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, ""); v != 1 {
+		t.Errorf("expected %d got %f", 1, v)
+	}
+
+	// 8 hits and 2 misses, with 1 slow request. Uncacheable requests are excluded from the hit ratio
+	for i := 0; i < 8; i++ {
+		tr.Observe(status.LookupStatusHit, time.Millisecond)
+	}
+	tr.Observe(status.LookupStatusKeyMiss, time.Millisecond)
+	tr.Observe(status.LookupStatusKeyMiss, time.Second)
+	tr.Observe(status.LookupStatusProxyOnly, time.Millisecond)
+	tr.Observe(status.LookupStatusProxyOnly, time.Millisecond)
+
+	// the metrics are refreshed on the first request after the refresh interval
+	now = now.Add(refreshInterval)
+	tr.Observe(status.LookupStatusHit, time.Millisecond)
+
+	// 2 misses of 11 cacheable requests burns a 10% budget at 1.8x the sustainable rate
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, "2m"); !approx(v, 20.0/11) {
+		t.Errorf("expected %f got %f", 20.0/11, v)
+	}
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, ""); !approx(v, 1-20.0/11) {
+		t.Errorf("expected %f got %f", 1-20.0/11, v)
+	}
+	// 1 of 13 requests was slow against a 1% budget
+	if v := gaugeValue(t, "slo-test", ObjectiveLatency, "1h"); !approx(v, 100.0/13) {
+		t.Errorf("expected %f got %f", 100.0/13, v)
+	}
+
+	// the metrics are not refreshed until the refresh interval has elapsed
+	now = now.Add(time.Second)
+	tr.Observe(status.LookupStatusKeyMiss, time.Millisecond)
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, "2m"); !approx(v, 20.0/11) {
+		t.Errorf("expected %f got %f", 20.0/11, v)
+	}
+
+	// after the short window has passed, only new requests are included in it
+	now = now.Add(3 * time.Minute)
+	tr.Observe(status.LookupStatusHit, time.Millisecond)
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, "2m"); v != 0 {
+		t.Errorf("expected %d got %f", 0, v)
+	}
+	// 3 misses of 13 cacheable requests in the long window
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, "1h"); !approx(v, 30.0/13) {
+		t.Errorf("expected %f got %f", 30.0/13, v)
+	}
+
+	// after the period has passed, the budget is restored
+	now = now.Add(3 * time.Hour)
+	tr.Observe(status.LookupStatusHit, time.Millisecond)
+	if v := gaugeValue(t, "slo-test", ObjectiveHitRatio, ""); v != 1 {
+		t.Errorf("expected %d got %f", 1, v)
+	}
+	if v := gaugeValue(t, "slo-test", ObjectiveLatency, ""); v != 1 {
+		t.Errorf("expected %d got %f", 1, v)
+	}
+
+	var nilTracker *Tracker
+	nilTracker.Observe(status.LookupStatusHit, time.Millisecond)
+}
+
+func TestWindowLabel(t *testing.T) {
+	if s := windowLabel(5 * time.Minute); s != "5m" {
+		t.Errorf("expected %s got %s", "5m", s)
+	}
+	if s := windowLabel(6 * time.Hour); s != "6h" {
+		t.Errorf("expected %s got %s", "6h", s)
+	}
+	if s := windowLabel(90 * time.Minute); s != "90m" {
+		t.Errorf("expected %s got %s", "90m", s)
+	}
+}
+
+func TestOptions(t *testing.T) {
+
+	o := options.NewOptions()
+	if o.Enabled() {
+		t.Error("expected slo to be disabled by default")
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if len(o.BurnRateWindows) != 3 || o.BurnRateWindows[0] != 5*time.Minute {
+		t.Errorf("unexpected burn rate windows %v", o.BurnRateWindows)
+	}
+
+	o.HitRatioTarget = 0.95
+	if !o.Enabled() {
+		t.Error("expected slo to be enabled")
+	}
+	o2 := o.Clone()
+	if o2.HitRatioTarget != 0.95 || len(o2.BurnRateWindowsSecs) != 3 {
+		t.Error("clone mismatch")
+	}
+
+	tests := []func(*options.Options){
+		func(o *options.Options) { o.HitRatioTarget = 1 },
+		func(o *options.Options) { o.LatencyTargetMS = -1 },
+		func(o *options.Options) { o.LatencyObjective = 0 },
+		func(o *options.Options) { o.PeriodSecs = 3601 },
+		func(o *options.Options) { o.BurnRateWindowsSecs = []int{90} },
+		func(o *options.Options) { o.BurnRateWindowsSecs = []int{172800} },
+		func(o *options.Options) { o.PeriodSecs = 3600; o.BurnRateWindowsSecs = []int{7200} },
+	}
+	for i, f := range tests {
+		o := options.NewOptions()
+		f(o)
+		if err := o.Validate(); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package acme obtains and renews the TLS listener's certificates from an ACME (RFC 8555)
+// certificate authority, proving control of their DNS names with DNS-01 challenges that are
+// published by pluggable DNS providers. As DNS-01 needs no inbound connection, certificates,
+// including wildcard certificates, can be obtained for listeners behind a firewall
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// issueLock serializes certificate orders, so the Managers of an outgoing and an incoming
+// config do not both order a certificate that is due for renewal
+var issueLock sync.Mutex
+
+// Manager obtains the configured certificates, and renews them before they expire. The
+// certificates are stored as files in the storage path, from which the TLS listener loads them
+type Manager struct {
+	options *options.Options
+	logger  *tl.Logger
+
+	// newProvider returns the DNS provider of a domain, and is replaced in tests
+	newProvider func(*options.DomainOptions, time.Duration) (Provider, error)
+	now         func() time.Time
+}
+
+// New returns a new Manager of the certificates in the provided options
+func New(o *options.Options, logger *tl.Logger) *Manager {
+	return &Manager{options: o, logger: logger, newProvider: NewProvider, now: time.Now}
+}
+
+// Run obtains any certificates that are missing or due for renewal, and checks them again
+// on each CheckInterval until quit is closed, or on each RetryInterval while an order is
+// failing. onUpdate is called after any certificate is stored, so it can be loaded by
+// the TLS listener
+func (m *Manager) Run(onUpdate func(), quit <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		updated, err := m.Renew(ctx)
+		if updated > 0 && ctx.Err() == nil {
+			onUpdate()
+		}
+		wait := m.options.CheckInterval
+		if err != nil {
+			wait = m.options.RetryInterval
+		}
+		if sleep(ctx, wait) != nil {
+			return
+		}
+	}
+}
+
+// Renew obtains each certificate that is missing or due for renewal, and returns the number
+// of certificates that were stored. A failed order is logged, and does not prevent the
+// orders of the other certificates, with the last error returned
+func (m *Manager) Renew(ctx context.Context) (int, error) {
+	var updated int
+	var lastErr error
+	for _, k := range m.options.DomainNames() {
+		do := m.options.Domains[k]
+		ok, err := m.renew(ctx, do)
+		if ctx.Err() != nil {
+			return updated, ctx.Err()
+		}
+		if err != nil {
+			m.logger.Error("acme certificate order failed",
+				tl.Pairs{"domain": k, "names": strings.Join(do.Names, ","), "detail": err.Error()})
+			lastErr = err
+			continue
+		}
+		if ok {
+			updated++
+			m.logger.Info("acme certificate stored",
+				tl.Pairs{"domain": k, "names": strings.Join(do.Names, ",")})
+		}
+	}
+	return updated, lastErr
+}
+
+// renew obtains the domain's certificate if it is missing or due for renewal, and returns
+// true when a certificate was stored
+func (m *Manager) renew(ctx context.Context, do *options.DomainOptions) (bool, error) {
+	issueLock.Lock()
+	defer issueLock.Unlock()
+	if !m.due(do) {
+		return false, nil
+	}
+	p, err := m.newProvider(do, m.options.Timeout)
+	if err != nil {
+		return false, err
+	}
+	chain, key, err := m.obtain(ctx, do, p)
+	if err != nil {
+		return false, err
+	}
+	return true, m.store(do.Name, chain, key)
+}
+
+// due returns true if the domain's stored certificate is missing, unreadable, does not
+// match the configured names, or expires within RenewBefore
+func (m *Manager) due(do *options.DomainOptions) bool {
+	cert, err := tls.LoadX509KeyPair(m.options.CertificatePath(do.Name),
+		m.options.PrivateKeyPath(do.Name))
+	if err != nil || len(cert.Certificate) == 0 {
+		return true
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	if !sameNames(leaf.DNSNames, do.Names) {
+		return true
+	}
+	return m.now().Add(m.options.RenewBefore).After(leaf.NotAfter)
+}
+
+// sameNames returns true if the lists hold the same DNS names, in any order or case
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a2 := make([]string, len(a))
+	b2 := make([]string, len(b))
+	for i := range a {
+		a2[i] = strings.ToLower(a[i])
+		b2[i] = strings.ToLower(b[i])
+	}
+	sort.Strings(a2)
+	sort.Strings(b2)
+	for i := range a2 {
+		if a2[i] != b2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// obtain orders a certificate for the domain's names, answering the DNS-01 challenge of
+// each authorization with the provider, and returns the PEM-encoded chain and private key
+func (m *Manager) obtain(ctx context.Context, do *options.DomainOptions,
+	p Provider) ([]byte, []byte, error) {
+
+	accountKey, err := m.accountKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := newClient(m.options.DirectoryURL, accountKey, m.options.Timeout)
+	if err = c.discover(ctx); err != nil {
+		return nil, nil, err
+	}
+	if err = c.register(ctx, m.options.Email); err != nil {
+		return nil, nil, err
+	}
+	o, err := c.newOrder(ctx, do.Names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a wildcard name and its base name share a challenge record, so the values of all
+	// of the challenges are gathered by record before any record is published
+	type pendingAuthz struct {
+		url string
+		ch  *challenge
+	}
+	var pending []pendingAuthz
+	records := make(map[string][]string)
+	var names []string
+	tp := thumbprint(&accountKey.PublicKey)
+	for _, u := range o.Authorizations {
+		a, err := c.authorization(ctx, u)
+		if err != nil {
+			return nil, nil, err
+		}
+		if a.Status == statusValid {
+			continue
+		}
+		var ch *challenge
+		for i := range a.Challenges {
+			if a.Challenges[i].Type == challengeType {
+				ch = &a.Challenges[i]
+				break
+			}
+		}
+		if ch == nil {
+			return nil, nil, fmt.Errorf("acme server offered no %s challenge for %s",
+				challengeType, a.Identifier.Value)
+		}
+		name := challengeRecord(a.Identifier.Value)
+		if _, ok := records[name]; !ok {
+			names = append(names, name)
+		}
+		records[name] = append(records[name], challengeValue(ch.Token, tp))
+		pending = append(pending, pendingAuthz{url: u, ch: ch})
+	}
+
+	// the records are removed once the authorizations are complete, even when the context
+	// has been canceled, so that challenge records are not left behind
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.Background(), m.options.Timeout)
+		defer cancel()
+		for _, name := range names {
+			if err := p.CleanUp(cctx, name, records[name]); err != nil {
+				m.logger.Warn("acme challenge record cleanup failed",
+					tl.Pairs{"record": name, "detail": err.Error()})
+			}
+		}
+	}()
+	for i, name := range names {
+		if err = p.Present(ctx, name, records[name]); err != nil {
+			// only the records that were published are removed
+			names = names[:i]
+			return nil, nil, err
+		}
+	}
+	if len(names) > 0 {
+		if err = sleep(ctx, m.options.PropagationDelay); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, pa := range pending {
+		if err = c.accept(ctx, pa.ch); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, pa := range pending {
+		if err = c.waitAuthorization(ctx, pa.url); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: do.Names[0]},
+		DNSNames: do.Names,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o, err = c.finalize(ctx, o, csr); err != nil {
+		return nil, nil, err
+	}
+	chain, err := c.certificate(ctx, o.Certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+	// the chain is verified to be usable with the key before it replaces a working certificate
+	if _, err = tls.X509KeyPair(chain, keyPEM); err != nil {
+		return nil, nil, err
+	}
+	return chain, keyPEM, nil
+}
+
+// accountKey returns the ACME account's private key, generating and storing it if it
+// does not exist
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	path := m.options.AccountKeyPath()
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no private key found in acme account key file: " + path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: kb})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// store writes the domain's certificate chain and private key, which the TLS listener
+// loads once both are written
+func (m *Manager) store(name string, chain, key []byte) error {
+	if err := writeFile(m.options.PrivateKeyPath(name), key); err != nil {
+		return err
+	}
+	return writeFile(m.options.CertificatePath(name), chain)
+}
+
+// writeFile atomically replaces the file with the data, readable only by its owner
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,550 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+var testLogger = tl.ConsoleLogger("error")
+
+// testProvider records the challenge records it is asked to publish
+type testProvider struct {
+	mtx        sync.Mutex
+	records    map[string][]string
+	presented  int
+	cleanedUp  int
+	presentErr error
+}
+
+func newTestProvider() *testProvider {
+	return &testProvider{records: make(map[string][]string)}
+}
+
+func (p *testProvider) Present(ctx context.Context, fqdn string, values []string) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.presentErr != nil {
+		return p.presentErr
+	}
+	p.presented++
+	p.records[fqdn] = append(p.records[fqdn], values...)
+	return nil
+}
+
+func (p *testProvider) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.cleanedUp++
+	delete(p.records, fqdn)
+	return nil
+}
+
+func (p *testProvider) has(fqdn, value string) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, v := range p.records[fqdn] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type testAuthz struct {
+	identifier string
+	wildcard   bool
+	token      string
+	status     string
+}
+
+// testACMEServer is a minimal ACME certificate authority that validates dns-01 challenges
+// against the records of a testProvider
+type testACMEServer struct {
+	*httptest.Server
+	t        *testing.T
+	provider *testProvider
+	caKey    *ecdsa.PrivateKey
+	caCert   *x509.Certificate
+	validity time.Duration
+
+	mtx          sync.Mutex
+	nonce        int
+	nonces       map[string]bool
+	accounts     map[string]*ecdsa.PublicKey
+	authzs       []*testAuthz
+	issued       int
+	certificate  []byte
+	badNonceOnce bool
+}
+
+func newTestACMEServer(t *testing.T, p *testProvider) *testACMEServer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour * 365),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(der)
+	s := &testACMEServer{t: t, provider: p, caKey: caKey, caCert: caCert,
+		validity: 90 * 24 * time.Hour, nonces: make(map[string]bool),
+		accounts: make(map[string]*ecdsa.PublicKey)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *testACMEServer) newNonce(w http.ResponseWriter) {
+	s.nonce++
+	n := fmt.Sprintf("nonce-%d", s.nonce)
+	s.nonces[n] = true
+	w.Header().Set(headerReplayNonce, n)
+}
+
+func (s *testACMEServer) problem(w http.ResponseWriter, status int, typ string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{Type: typ, Detail: typ, Status: status})
+}
+
+// verify checks the JWS signature and nonce of the request, and returns its payload
+// and account URL
+func (s *testACMEServer) verify(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if r.Header.Get("Content-Type") != contentTypeJOSE {
+		s.problem(w, http.StatusUnsupportedMediaType, "urn:ietf:params:acme:error:malformed")
+		return nil, "", false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:malformed")
+		return nil, "", false
+	}
+	enc := base64.RawURLEncoding
+	ph, _ := enc.DecodeString(jws.Protected)
+	var protected struct {
+		Alg   string `json:"alg"`
+		Nonce string `json:"nonce"`
+		URL   string `json:"url"`
+		KID   string `json:"kid"`
+		JWK   *struct {
+			X string `json:"x"`
+			Y string `json:"y"`
+		} `json:"jwk"`
+	}
+	if err := json.Unmarshal(ph, &protected); err != nil || protected.Alg != "ES256" ||
+		protected.URL != s.URL+r.URL.Path {
+		s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:malformed")
+		return nil, "", false
+	}
+	if s.badNonceOnce || !s.nonces[protected.Nonce] {
+		s.badNonceOnce = false
+		s.problem(w, http.StatusBadRequest, errorBadNonce)
+		return nil, "", false
+	}
+	delete(s.nonces, protected.Nonce)
+	var pub *ecdsa.PublicKey
+	var kid string
+	if protected.JWK != nil {
+		x, _ := enc.DecodeString(protected.JWK.X)
+		y, _ := enc.DecodeString(protected.JWK.Y)
+		pub = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x),
+			Y: new(big.Int).SetBytes(y)}
+	} else {
+		kid = protected.KID
+		pub = s.accounts[kid]
+	}
+	sig, _ := enc.DecodeString(jws.Signature)
+	h := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if pub == nil || len(sig) != 64 || !ecdsa.Verify(pub, h[:],
+		new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		s.problem(w, http.StatusUnauthorized, "urn:ietf:params:acme:error:unauthorized")
+		return nil, "", false
+	}
+	if kid == "" && r.URL.Path != "/account" {
+		s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:malformed")
+		return nil, "", false
+	}
+	if protected.JWK != nil {
+		b, _ := x509.MarshalPKIXPublicKey(pub)
+		kid = s.URL + "/account/" + fmt.Sprintf("%x", sha256.Sum256(b))[:8]
+		s.accounts[kid] = pub
+	}
+	payload, _ := enc.DecodeString(jws.Payload)
+	return payload, kid, true
+}
+
+func (s *testACMEServer) authzJSON(i int) map[string]interface{} {
+	a := s.authzs[i]
+	return map[string]interface{}{
+		"status":     a.status,
+		"identifier": identifier{Type: "dns", Value: a.identifier},
+		"wildcard":   a.wildcard,
+		"challenges": []challenge{
+			{Type: "http-01", URL: fmt.Sprintf("%s/http/%d", s.URL, i), Token: "http-token"},
+			{Type: challengeType, URL: fmt.Sprintf("%s/challenge/%d", s.URL, i), Token: a.token,
+				Status: a.status},
+		},
+	}
+}
+
+func (s *testACMEServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.newNonce(w)
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(directory{NewNonce: s.URL + "/nonce",
+			NewAccount: s.URL + "/account", NewOrder: s.URL + "/order"})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+	payload, kid, ok := s.verify(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Retry-After", "0")
+	var i int
+	switch {
+	case r.URL.Path == "/account":
+		w.Header().Set("Location", kid)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case r.URL.Path == "/order":
+		var req struct {
+			Identifiers []identifier `json:"identifiers"`
+		}
+		json.Unmarshal(payload, &req)
+		s.authzs = s.authzs[:0]
+		urls := make([]string, len(req.Identifiers))
+		for j, id := range req.Identifiers {
+			s.authzs = append(s.authzs, &testAuthz{
+				identifier: strings.TrimPrefix(id.Value, "*."),
+				wildcard:   strings.HasPrefix(id.Value, "*."),
+				token:      fmt.Sprintf("token-%d-%d", s.issued, j),
+				status:     statusPending,
+			})
+			urls[j] = fmt.Sprintf("%s/authz/%d", s.URL, j)
+		}
+		w.Header().Set("Location", s.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order{Status: statusPending, Identifiers: req.Identifiers,
+			Authorizations: urls, Finalize: s.URL + "/finalize/1"})
+	case fmtScan(r.URL.Path, "/authz/%d", &i):
+		json.NewEncoder(w).Encode(s.authzJSON(i))
+	case fmtScan(r.URL.Path, "/challenge/%d", &i):
+		a := s.authzs[i]
+		if string(payload) != "{}" {
+			s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:malformed")
+			return
+		}
+		// the challenge is validated against the published records
+		a.status = statusInvalid
+		value := challengeValue(a.token, thumbprint(s.accounts[kid]))
+		if s.provider.has("_acme-challenge."+a.identifier, value) {
+			a.status = statusValid
+		}
+		json.NewEncoder(w).Encode(challenge{Type: challengeType, Status: statusProcessing})
+	case r.URL.Path == "/finalize/1":
+		for _, a := range s.authzs {
+			if a.status != statusValid {
+				s.problem(w, http.StatusForbidden, "urn:ietf:params:acme:error:orderNotReady")
+				return
+			}
+		}
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || csr.CheckSignature() != nil {
+			s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:badCSR")
+			return
+		}
+		s.issued++
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(s.issued + 1)),
+			Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(s.validity),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, tmpl, s.caCert, csr.PublicKey, s.caKey)
+		if err != nil {
+			s.t.Error(err)
+		}
+		s.certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+		s.certificate = append(s.certificate,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw})...)
+		// the order is processing on the first response, and valid when it is polled
+		json.NewEncoder(w).Encode(order{Status: statusProcessing})
+	case r.URL.Path == "/order/1":
+		json.NewEncoder(w).Encode(order{Status: statusValid, Certificate: s.URL + "/certificate/1"})
+	case r.URL.Path == "/certificate/1":
+		w.Header().Set("Content-Type", contentTypePEMChain)
+		w.Write(s.certificate)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func fmtScan(path, format string, i *int) bool {
+	n, err := fmt.Sscanf(path, format, i)
+	return err == nil && n == 1
+}
+
+func testStoragePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "trickster-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func testOptions(t *testing.T, directoryURL string) *options.Options {
+	o := options.NewOptions()
+	o.DirectoryURL = directoryURL
+	o.StoragePath = testStoragePath(t)
+	o.PropagationDelaySecs = 0
+	o.Domains = map[string]*options.DomainOptions{
+		"metrics": {Names: []string{"*.metrics.example.com", "metrics.example.com"},
+			Provider: options.ProviderRoute53},
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o.SetDurations()
+	return o
+}
+
+func testManager(o *options.Options, p Provider) *Manager {
+	m := New(o, testLogger)
+	m.newProvider = func(*options.DomainOptions, time.Duration) (Provider, error) {
+		return p, nil
+	}
+	return m
+}
+
+func TestManagerRenew(t *testing.T) {
+
+	p := newTestProvider()
+	s := newTestACMEServer(t, p)
+	defer s.Close()
+	s.badNonceOnce = true
+
+	o := testOptions(t, s.URL+"/directory")
+	m := testManager(o, p)
+
+	n, err := m.Renew(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	// the wildcard and base names share a challenge record, which is published once
+	if p.presented != 1 || p.cleanedUp != 1 || len(p.records) != 0 {
+		t.Errorf("unexpected challenge records: presented %d, cleaned up %d, remaining %v",
+			p.presented, p.cleanedUp, p.records)
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.CertificatePath("metrics"), o.PrivateKeyPath("metrics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 2 {
+		t.Errorf("expected %d got %d", 2, len(cert.Certificate))
+	}
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if !sameNames(leaf.DNSNames, o.Domains["metrics"].Names) {
+		t.Errorf("unexpected certificate names %v", leaf.DNSNames)
+	}
+
+	// the stored certificate is not due for renewal
+	if n, err = m.Renew(context.Background()); err != nil || n != 0 {
+		t.Errorf("expected no renewal got %d %v", n, err)
+	}
+
+	// the certificate is renewed within RenewBefore of its expiration, with the same account
+	m.now = func() time.Time { return time.Now().Add(61 * 24 * time.Hour) }
+	if n, err = m.Renew(context.Background()); err != nil || n != 1 {
+		t.Errorf("expected renewal got %d %v", n, err)
+	}
+	if s.issued != 2 || len(s.accounts) != 1 {
+		t.Errorf("expected %d certificates for %d account got %d for %d", 2, 1,
+			s.issued, len(s.accounts))
+	}
+
+	// a change to the configured names orders a new certificate
+	m.now = time.Now
+	o.Domains["metrics"].Names = []string{"metrics.example.com"}
+	if n, err = m.Renew(context.Background()); err != nil || n != 1 {
+		t.Errorf("expected renewal got %d %v", n, err)
+	}
+}
+
+func TestManagerRenewFailure(t *testing.T) {
+
+	p := newTestProvider()
+	s := newTestACMEServer(t, p)
+	defer s.Close()
+
+	o := testOptions(t, s.URL+"/directory")
+
+	// the records could not be published
+	p.presentErr = errors.New("test error")
+	m := testManager(o, p)
+	if n, err := m.Renew(context.Background()); err == nil || n != 0 {
+		t.Errorf("expected error got %d %v", n, err)
+	}
+	if p.cleanedUp != 0 {
+		t.Errorf("expected %d got %d", 0, p.cleanedUp)
+	}
+
+	// the records are published, but not where the certificate authority looks for them
+	p.presentErr = nil
+	m = testManager(o, &wrongRecordProvider{p})
+	if n, err := m.Renew(context.Background()); err == nil || n != 0 {
+		t.Errorf("expected error got %d %v", n, err)
+	}
+	if p.cleanedUp != 1 {
+		t.Errorf("expected %d got %d", 1, p.cleanedUp)
+	}
+
+	if _, err := ioutil.ReadFile(o.CertificatePath("metrics")); err == nil {
+		t.Error("expected no stored certificate")
+	}
+
+	// the certificate authority is not reachable
+	s.Close()
+	m = testManager(o, p)
+	if n, err := m.Renew(context.Background()); err == nil || n != 0 {
+		t.Errorf("expected error got %d %v", n, err)
+	}
+}
+
+// wrongRecordProvider publishes the challenge records under the wrong name
+type wrongRecordProvider struct {
+	*testProvider
+}
+
+func (p *wrongRecordProvider) Present(ctx context.Context, fqdn string, values []string) error {
+	return p.testProvider.Present(ctx, "wrong."+fqdn, values)
+}
+
+func (p *wrongRecordProvider) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	return p.testProvider.CleanUp(ctx, "wrong."+fqdn, values)
+}
+
+func TestManagerRun(t *testing.T) {
+
+	p := newTestProvider()
+	s := newTestACMEServer(t, p)
+	defer s.Close()
+
+	m := testManager(testOptions(t, s.URL+"/directory"), p)
+
+	updated := make(chan struct{}, 1)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		m.Run(func() { updated <- struct{}{} }, quit)
+		close(done)
+	}()
+
+	select {
+	case <-updated:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected certificate update")
+	}
+
+	close(quit)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return after quit is closed")
+	}
+}
+
+func TestAccountKey(t *testing.T) {
+
+	o := options.NewOptions()
+	o.StoragePath = testStoragePath(t)
+	m := New(o, testLogger)
+
+	k1, err := m.accountKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := m.accountKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumbprint(&k1.PublicKey) != thumbprint(&k2.PublicKey) {
+		t.Error("expected the stored account key to be reused")
+	}
+
+	if err = ioutil.WriteFile(o.AccountKeyPath(), []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.accountKey(); err == nil {
+		t.Error("expected error for invalid account key")
+	}
+}
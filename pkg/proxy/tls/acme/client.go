@@ -0,0 +1,430 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// contentTypeJOSE is the content type of the JWS-signed requests to the ACME server
+	contentTypeJOSE = "application/jose+json"
+	// contentTypePEMChain is the content type of a downloaded certificate chain
+	contentTypePEMChain = "application/pem-certificate-chain"
+	// headerReplayNonce is the response header carrying the nonce of the next request
+	headerReplayNonce = "Replay-Nonce"
+	// errorBadNonce is the problem type of a request whose nonce was rejected, which is retried
+	errorBadNonce = "urn:ietf:params:acme:error:badNonce"
+	// maxPollInterval is the longest wait between polls of a pending order or authorization
+	maxPollInterval = 10 * time.Second
+)
+
+// the statuses of ACME orders, authorizations and challenges
+const (
+	statusPending    = "pending"
+	statusReady      = "ready"
+	statusProcessing = "processing"
+	statusValid      = "valid"
+	statusInvalid    = "invalid"
+)
+
+var errNoNonce = errors.New("acme server did not provide a nonce")
+
+// directory is the ACME server's directory of resource URLs
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+// problem is an RFC 7807 problem document returned by the ACME server
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *problem) Error() string {
+	return fmt.Sprintf("acme: %s: %s", p.Type, p.Detail)
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// order is an ACME order for a certificate
+type order struct {
+	URL            string       `json:"-"`
+	Status         string       `json:"status"`
+	Identifiers    []identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *problem     `json:"error"`
+}
+
+// authorization is the proof of control of an identifier that an order requires
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Wildcard   bool        `json:"wildcard"`
+	Challenges []challenge `json:"challenges"`
+}
+
+// challenge is a means of proving control of an authorization's identifier
+type challenge struct {
+	Type   string   `json:"type"`
+	URL    string   `json:"url"`
+	Token  string   `json:"token"`
+	Status string   `json:"status"`
+	Error  *problem `json:"error"`
+}
+
+// client is an ACME (RFC 8555) client, which signs its requests with the account key
+type client struct {
+	directoryURL string
+	key          *ecdsa.PrivateKey
+	http         *http.Client
+
+	dir   *directory
+	kid   string
+	nonce string
+}
+
+func newClient(directoryURL string, key *ecdsa.PrivateKey, timeout time.Duration) *client {
+	return &client{directoryURL: directoryURL, key: key, http: &http.Client{Timeout: timeout}}
+}
+
+// discover fetches the ACME server's directory
+func (c *client) discover(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.directoryURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("acme directory request failed: status %d", resp.StatusCode)
+	}
+	dir := &directory{}
+	if err = json.NewDecoder(resp.Body).Decode(dir); err != nil {
+		return err
+	}
+	if dir.NewNonce == "" || dir.NewAccount == "" || dir.NewOrder == "" {
+		return errors.New("acme directory is missing required resources")
+	}
+	c.dir = dir
+	return nil
+}
+
+// register creates the ACME account of the client's key, or looks up the existing
+// account, and keeps its URL as the key ID of subsequent requests
+func (c *client) register(ctx context.Context, email string) error {
+	req := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		req["contact"] = []string{"mailto:" + email}
+	}
+	resp, err := c.post(ctx, c.dir.NewAccount, req, nil)
+	if err != nil {
+		return err
+	}
+	c.kid = resp.Header.Get("Location")
+	if c.kid == "" {
+		return errors.New("acme server did not provide an account url")
+	}
+	return nil
+}
+
+// newOrder places an order for a certificate with the provided DNS names
+func (c *client) newOrder(ctx context.Context, names []string) (*order, error) {
+	ids := make([]identifier, len(names))
+	for i, n := range names {
+		ids[i] = identifier{Type: "dns", Value: n}
+	}
+	req := map[string]interface{}{"identifiers": ids}
+	o := &order{}
+	resp, err := c.post(ctx, c.dir.NewOrder, req, o)
+	if err != nil {
+		return nil, err
+	}
+	if o.URL = resp.Header.Get("Location"); o.URL == "" {
+		return nil, errors.New("acme server did not provide an order url")
+	}
+	return o, nil
+}
+
+// authorization fetches the authorization at the url
+func (c *client) authorization(ctx context.Context, url string) (*authorization, error) {
+	a := &authorization{}
+	_, err := c.post(ctx, url, nil, a)
+	return a, err
+}
+
+// accept tells the ACME server that the challenge is ready to be validated
+func (c *client) accept(ctx context.Context, ch *challenge) error {
+	_, err := c.post(ctx, ch.URL, struct{}{}, nil)
+	return err
+}
+
+// waitAuthorization polls the authorization until it is no longer pending
+func (c *client) waitAuthorization(ctx context.Context, url string) error {
+	for {
+		a := &authorization{}
+		resp, err := c.post(ctx, url, nil, a)
+		if err != nil {
+			return err
+		}
+		switch a.Status {
+		case statusValid:
+			return nil
+		case statusPending, statusProcessing:
+		default:
+			for _, ch := range a.Challenges {
+				if ch.Error != nil {
+					return fmt.Errorf("acme authorization of %s is %s: %v",
+						a.Identifier.Value, a.Status, ch.Error)
+				}
+			}
+			return fmt.Errorf("acme authorization of %s is %s", a.Identifier.Value, a.Status)
+		}
+		if err = sleep(ctx, retryAfter(resp)); err != nil {
+			return err
+		}
+	}
+}
+
+// finalize submits the DER-encoded certificate signing request of the order, and polls
+// the order until the certificate is issued
+func (c *client) finalize(ctx context.Context, o *order, csr []byte) (*order, error) {
+	req := map[string]string{"csr": base64.RawURLEncoding.EncodeToString(csr)}
+	o2 := &order{}
+	resp, err := c.post(ctx, o.Finalize, req, o2)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch o2.Status {
+		case statusValid:
+			if o2.Certificate == "" {
+				return nil, errors.New("acme server did not provide a certificate url")
+			}
+			return o2, nil
+		case statusPending, statusReady, statusProcessing:
+		default:
+			if o2.Error != nil {
+				return nil, fmt.Errorf("acme order is %s: %v", o2.Status, o2.Error)
+			}
+			return nil, fmt.Errorf("acme order is %s", o2.Status)
+		}
+		if err = sleep(ctx, retryAfter(resp)); err != nil {
+			return nil, err
+		}
+		o2 = &order{}
+		if resp, err = c.post(ctx, o.URL, nil, o2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// certificate downloads the PEM-encoded certificate chain of a valid order
+func (c *client) certificate(ctx context.Context, url string) ([]byte, error) {
+	resp, err := c.postRaw(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// post sends the JWS-signed payload to the url, and decodes the JSON response into v,
+// when v is not nil. A nil payload sends a POST-as-GET request
+func (c *client) post(ctx context.Context, url string, payload, v interface{}) (*http.Response, error) {
+	resp, err := c.postRaw(ctx, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// postRaw sends the JWS-signed payload to the url, retrying once when the nonce is
+// rejected, and returns the response when it is successful
+func (c *client) postRaw(ctx context.Context, url string, payload interface{}) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if c.nonce == "" {
+			if err := c.fetchNonce(ctx); err != nil {
+				return nil, err
+			}
+		}
+		jws, err := c.sign(url, body)
+		if err != nil {
+			return nil, err
+		}
+		c.nonce = ""
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jws))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentTypeJOSE)
+		req.Header.Set("Accept", contentTypePEMChain+", application/json")
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		c.nonce = resp.Header.Get(headerReplayNonce)
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+		p := &problem{}
+		err = json.NewDecoder(resp.Body).Decode(p)
+		resp.Body.Close()
+		if err != nil || p.Type == "" {
+			return nil, fmt.Errorf("acme request to %s failed: status %d", url, resp.StatusCode)
+		}
+		if p.Type != errorBadNonce || attempt > 0 {
+			return nil, p
+		}
+	}
+}
+
+// fetchNonce gets a fresh nonce from the ACME server
+func (c *client) fetchNonce(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.dir.NewNonce, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if c.nonce = resp.Header.Get(headerReplayNonce); c.nonce == "" {
+		return errNoNonce
+	}
+	return nil
+}
+
+// sign returns the flattened JWS serialization of the payload, signed with ES256. The
+// account's public key is embedded until the account URL is known, after which it is
+// referenced by the account URL
+func (c *client) sign(url string, payload []byte) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = jwk(&c.key.PublicKey)
+	}
+	ph, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.RawURLEncoding
+	p := enc.EncodeToString(ph)
+	// a POST-as-GET request has an empty payload, rather than an encoded empty object
+	pl := ""
+	if payload != nil {
+		pl = enc.EncodeToString(payload)
+	}
+	h := sha256.Sum256([]byte(p + "." + pl))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, h[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := append(coordinate(r), coordinate(s)...)
+	return json.Marshal(map[string]string{
+		"protected": p,
+		"payload":   pl,
+		"signature": enc.EncodeToString(sig),
+	})
+}
+
+// jwk returns the JSON Web Key of the P-256 public key, with its members in the
+// lexicographic order required to compute its thumbprint
+func jwk(pub *ecdsa.PublicKey) json.RawMessage {
+	enc := base64.RawURLEncoding
+	return json.RawMessage(fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		enc.EncodeToString(coordinate(pub.X)), enc.EncodeToString(coordinate(pub.Y))))
+}
+
+// coordinate returns the 32-byte big-endian representation of a P-256 curve coordinate
+// or signature value
+func coordinate(n *big.Int) []byte {
+	b := make([]byte, 32)
+	nb := n.Bytes()
+	copy(b[32-len(nb):], nb)
+	return b
+}
+
+// thumbprint returns the RFC 7638 thumbprint of the account's public key
+func thumbprint(pub *ecdsa.PublicKey) string {
+	h := sha256.Sum256(jwk(pub))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// retryAfter returns the wait before a pending resource is polled again, from the
+// response's Retry-After header when it is provided
+func retryAfter(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+		d := time.Duration(s) * time.Second
+		if d > maxPollInterval {
+			return maxPollInterval
+		}
+		return d
+	}
+	return time.Second
+}
+
+// sleep waits for the duration, or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+// cloudflareEndpoint is the base URL of the Cloudflare v4 API
+const cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+// cloudflareMinTTLSecs is the lowest TTL of a record in a Cloudflare zone
+const cloudflareMinTTLSecs = 120
+
+// cloudflare is the Provider of zones hosted by Cloudflare, whose API requests are
+// authorized with an API token
+type cloudflare struct {
+	endpoint string
+	zoneID   string
+	token    string
+	client   *http.Client
+}
+
+func newCloudflare(do *options.DomainOptions, c *http.Client) *cloudflare {
+	return &cloudflare{endpoint: cloudflareEndpoint, zoneID: do.ZoneID, token: do.APIToken,
+		client: c}
+}
+
+// cloudflareResponse is the envelope of each Cloudflare API response
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cloudflareZone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// Present creates a TXT record for each of the values. Cloudflare has no API to query
+// the propagation of a record, so its propagation is left to the configured delay
+func (p *cloudflare) Present(ctx context.Context, fqdn string, values []string) error {
+	zoneID, err := p.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		r := cloudflareRecord{Type: "TXT", Name: fqdn, Content: v, TTL: cloudflareMinTTLSecs}
+		if err = p.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", nil, r, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanUp deletes the TXT records at the name having any of the values
+func (p *cloudflare) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	zoneID, err := p.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	var records []cloudflareRecord
+	q := url.Values{"type": {"TXT"}, "name": {fqdn}}
+	if err = p.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records", q, nil, &records); err != nil {
+		return err
+	}
+	for _, r := range records {
+		for _, v := range values {
+			if r.Content == v {
+				if err = p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID,
+					nil, nil, nil); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// zone returns the configured zone ID, or the ID of the zone whose name is the longest
+// suffix of the record name
+func (p *cloudflare) zone(ctx context.Context, fqdn string) (string, error) {
+	if p.zoneID != "" {
+		return p.zoneID, nil
+	}
+	for _, name := range zoneCandidates(fqdn) {
+		var zones []cloudflareZone
+		if err := p.do(ctx, http.MethodGet, "/zones", url.Values{"name": {name}}, nil,
+			&zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no cloudflare zone found for %s", fqdn)
+}
+
+// do sends the request to the Cloudflare API, and decodes the response's result into v
+func (p *cloudflare) do(ctx context.Context, method, path string, q url.Values,
+	body interface{}, v interface{}) error {
+	u := p.endpoint + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	cr := &cloudflareResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(cr); err != nil {
+		return fmt.Errorf("cloudflare request failed: status %d", resp.StatusCode)
+	}
+	if !cr.Success {
+		if len(cr.Errors) > 0 {
+			return fmt.Errorf("cloudflare request failed: %d: %s", cr.Errors[0].Code,
+				cr.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare request failed: status %d", resp.StatusCode)
+	}
+	if v != nil {
+		return json.Unmarshal(cr.Result, v)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+func TestCloudflare(t *testing.T) {
+
+	records := make(map[string]cloudflareRecord)
+	var id int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"success":false,"errors":[{"code":10000,` +
+				`"message":"Authentication error"}],"result":null}`))
+			return
+		}
+		var result interface{}
+		switch {
+		case r.URL.Path == "/zones":
+			zones := []cloudflareZone{}
+			if r.URL.Query().Get("name") == "example.com" {
+				zones = append(zones, cloudflareZone{ID: "zone1", Name: "example.com"})
+			}
+			result = zones
+		case r.URL.Path == "/zones/zone1/dns_records" && r.Method == http.MethodPost:
+			rec := cloudflareRecord{}
+			json.NewDecoder(r.Body).Decode(&rec)
+			id++
+			rec.ID = fmt.Sprintf("record%d", id)
+			records[rec.ID] = rec
+			result = rec
+		case r.URL.Path == "/zones/zone1/dns_records" && r.Method == http.MethodGet:
+			list := []cloudflareRecord{}
+			for _, rec := range records {
+				if rec.Type == r.URL.Query().Get("type") && rec.Name == r.URL.Query().Get("name") {
+					list = append(list, rec)
+				}
+			}
+			result = list
+		case strings.HasPrefix(r.URL.Path, "/zones/zone1/dns_records/") &&
+			r.Method == http.MethodDelete:
+			delete(records, strings.TrimPrefix(r.URL.Path, "/zones/zone1/dns_records/"))
+			result = map[string]string{}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"success":false,"errors":[{"code":7003,` +
+				`"message":"Could not route"}],"result":null}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []string{},
+			"result": result})
+	}))
+	defer s.Close()
+
+	p := newCloudflare(&options.DomainOptions{APIToken: "test-token"}, s.Client())
+	p.endpoint = s.URL
+	ctx := context.Background()
+	fqdn := "_acme-challenge.metrics.example.com"
+
+	if err := p.Present(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(records))
+	}
+	for _, rec := range records {
+		if rec.Type != "TXT" || rec.Name != fqdn || !strings.HasPrefix(rec.Content, "value") {
+			t.Errorf("unexpected record %v", rec)
+		}
+	}
+
+	// a record that was not published by Present is kept
+	records["other"] = cloudflareRecord{ID: "other", Type: "TXT", Name: fqdn, Content: "other"}
+	if err := p.CleanUp(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := records["other"]; !ok || len(records) != 1 {
+		t.Errorf("unexpected records %v", records)
+	}
+
+	if err := p.Present(ctx, "_acme-challenge.example.org", []string{"value1"}); err == nil {
+		t.Error("expected error for missing zone")
+	}
+
+	p.token = "other-token"
+	err := p.Present(ctx, fqdn, []string{"value1"})
+	if err == nil || !strings.Contains(err.Error(), "Authentication error") {
+		t.Errorf("expected authentication error got %v", err)
+	}
+
+	p.token = "test-token"
+	p.zoneID = "zone2"
+	if err = p.Present(ctx, fqdn, []string{"value1"}); err == nil {
+		t.Error("expected error for unknown zone")
+	}
+}
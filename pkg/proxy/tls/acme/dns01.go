@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+const (
+	// challengeType is the type of the ACME challenges answered with a DNS TXT record
+	challengeType = "dns-01"
+	// challengeLabel is the label prepended to an identifier to form its challenge record name
+	challengeLabel = "_acme-challenge."
+	// recordTTLSecs is the TTL of the challenge records
+	recordTTLSecs = 60
+)
+
+// changePollInterval is the wait between polls of a DNS provider's pending record change
+var changePollInterval = 2 * time.Second
+
+// Provider publishes and removes the TXT records of DNS-01 challenges in the zone hosting
+// a domain's names. Since a wildcard name and its base name share a challenge record, each
+// record can hold several values
+type Provider interface {
+	// Present publishes a TXT record with the values at the fully-qualified name
+	Present(ctx context.Context, fqdn string, values []string) error
+	// CleanUp removes the values published by Present from the TXT record at the name
+	CleanUp(ctx context.Context, fqdn string, values []string) error
+}
+
+// NewProvider returns the Provider of the domain's configured DNS provider
+func NewProvider(do *options.DomainOptions, timeout time.Duration) (Provider, error) {
+	c := &http.Client{Timeout: timeout}
+	switch do.Provider {
+	case options.ProviderRoute53:
+		return newRoute53(do, c), nil
+	case options.ProviderCloudflare:
+		return newCloudflare(do, c), nil
+	case options.ProviderGCP:
+		return newGCP(do, c)
+	}
+	return nil, fmt.Errorf("invalid dns provider [%s]", do.Provider)
+}
+
+// challengeRecord returns the name of the TXT record of the identifier's DNS-01 challenge.
+// The wildcard label of a wildcard identifier is not part of the record name
+func challengeRecord(identifier string) string {
+	return challengeLabel + strings.TrimPrefix(identifier, "*.")
+}
+
+// challengeValue returns the TXT record value of a DNS-01 challenge, which is the digest
+// of the key authorization formed from the challenge token and the account key thumbprint
+func challengeValue(token, thumbprint string) string {
+	h := sha256.Sum256([]byte(token + "." + thumbprint))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// zoneCandidates returns the names that may be the apex of the zone hosting the record,
+// from the most to the least specific, ending with the registrable two-label name
+func zoneCandidates(fqdn string) []string {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	candidates := make([]string, 0, len(labels))
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	return candidates
+}
+
+// quoteTXT returns the value as the quoted character-string of a TXT record
+func quoteTXT(value string) string {
+	return `"` + value + `"`
+}
+
+// waitChange polls the change status until done returns true, or the context is done
+func waitChange(ctx context.Context, done func() (bool, error)) error {
+	for {
+		ok, err := done()
+		if err != nil || ok {
+			return err
+		}
+		if err = sleep(ctx, changePollInterval); err != nil {
+			return err
+		}
+	}
+}
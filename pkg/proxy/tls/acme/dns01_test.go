@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+func TestChallengeRecord(t *testing.T) {
+	tests := []struct {
+		identifier, expected string
+	}{
+		{"metrics.example.com", "_acme-challenge.metrics.example.com"},
+		{"*.metrics.example.com", "_acme-challenge.metrics.example.com"},
+	}
+	for i, test := range tests {
+		if v := challengeRecord(test.identifier); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
+
+func TestChallengeValue(t *testing.T) {
+	// the token and thumbprint are taken from the examples of RFC 8555
+	v := challengeValue("evaGxfADs6pSRb2LAv9IZf17Dt3juxGJ-PCt92wr-oA",
+		"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0")
+	expected := "xJgiYqv4IvEUkgnDxBIiCeaa_vmftPMmQkEtfvHfT7k"
+	if v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+}
+
+func TestZoneCandidates(t *testing.T) {
+	v := zoneCandidates("_acme-challenge.metrics.example.com.")
+	expected := []string{"_acme-challenge.metrics.example.com", "metrics.example.com",
+		"example.com"}
+	if strings.Join(v, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v got %v", expected, v)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+
+	do := &options.DomainOptions{Provider: options.ProviderRoute53}
+	p, err := NewProvider(do, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := p.(*route53); !ok {
+		t.Errorf("expected route53 provider got %T", p)
+	}
+
+	do = &options.DomainOptions{Provider: options.ProviderCloudflare, APIToken: "test"}
+	p, err = NewProvider(do, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := p.(*cloudflare); !ok {
+		t.Errorf("expected cloudflare provider got %T", p)
+	}
+
+	do = &options.DomainOptions{Provider: options.ProviderGCP, Project: "test",
+		CredentialsPath: testGCPCredentials(t, "http://127.0.0.1/token")}
+	p, err = NewProvider(do, time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := p.(*gcp); !ok {
+		t.Errorf("expected gcp provider got %T", p)
+	}
+
+	do.CredentialsPath = "/nonexistent/credentials.json"
+	if _, err = NewProvider(do, time.Second); err == nil {
+		t.Error("expected error for missing credentials file")
+	}
+
+	do = &options.DomainOptions{Provider: "unknown"}
+	if _, err = NewProvider(do, time.Second); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+const (
+	// gcpEndpoint is the base URL of the Cloud DNS v1 API
+	gcpEndpoint = "https://dns.googleapis.com/dns/v1"
+	// gcpScope is the OAuth 2.0 scope that permits changes to Cloud DNS records
+	gcpScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+	// gcpGrantType is the OAuth 2.0 grant type of a service account's signed assertion
+	gcpGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+var errInvalidGCPKey = errors.New("gcp credentials do not contain an RSA private key")
+
+// gcpCredentials is a Google Cloud service account key file
+type gcpCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// gcp is the Provider of Google Cloud DNS managed zones, whose API requests are authorized
+// with access tokens obtained with a service account key
+type gcp struct {
+	endpoint string
+	project  string
+	zone     string
+	email    string
+	tokenURI string
+	key      *rsa.PrivateKey
+	client   *http.Client
+
+	mtx    sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newGCP(do *options.DomainOptions, c *http.Client) (*gcp, error) {
+	b, err := ioutil.ReadFile(do.CredentialsPath)
+	if err != nil {
+		return nil, err
+	}
+	creds := &gcpCredentials{}
+	if err = json.Unmarshal(b, creds); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return nil, errInvalidGCPKey
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if k, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, errInvalidGCPKey
+		}
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok || creds.ClientEmail == "" || creds.TokenURI == "" {
+		return nil, errInvalidGCPKey
+	}
+	return &gcp{endpoint: gcpEndpoint, project: do.Project, zone: do.ZoneID,
+		email: creds.ClientEmail, tokenURI: creds.TokenURI, key: key, client: c}, nil
+}
+
+type gcpManagedZone struct {
+	Name       string `json:"name"`
+	DNSName    string `json:"dnsName"`
+	Visibility string `json:"visibility"`
+}
+
+type gcpRecordSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	RRDatas []string `json:"rrdatas"`
+}
+
+type gcpChange struct {
+	ID        string          `json:"id,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Additions []*gcpRecordSet `json:"additions,omitempty"`
+	Deletions []*gcpRecordSet `json:"deletions,omitempty"`
+}
+
+// gcpError is the error document of a failed Google Cloud API request
+type gcpError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Present adds the values to the TXT record, and waits for the change to be applied
+func (p *gcp) Present(ctx context.Context, fqdn string, values []string) error {
+	return p.change(ctx, fqdn, values, nil)
+}
+
+// CleanUp removes the values from the TXT record, keeping any other values
+func (p *gcp) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	return p.change(ctx, fqdn, nil, values)
+}
+
+// change replaces the TXT record with one that has the added values and not the removed
+// values, since Cloud DNS changes apply to whole record sets
+func (p *gcp) change(ctx context.Context, fqdn string, add, remove []string) error {
+	zone, err := p.managedZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	path := "/projects/" + url.PathEscape(p.project) + "/managedZones/" + url.PathEscape(zone)
+	var existing struct {
+		RRSets []*gcpRecordSet `json:"rrsets"`
+	}
+	q := url.Values{"name": {fqdn + "."}, "type": {"TXT"}}
+	if err = p.do(ctx, http.MethodGet, path+"/rrsets", q, nil, &existing); err != nil {
+		return err
+	}
+	c := &gcpChange{Deletions: existing.RRSets}
+	rrs := &gcpRecordSet{Name: fqdn + ".", Type: "TXT", TTL: recordTTLSecs}
+	for _, e := range existing.RRSets {
+		for _, v := range e.RRDatas {
+			if !containsTXT(remove, v) && !containsTXT(add, v) {
+				rrs.RRDatas = append(rrs.RRDatas, v)
+			}
+		}
+	}
+	for _, v := range add {
+		rrs.RRDatas = append(rrs.RRDatas, quoteTXT(v))
+	}
+	if len(rrs.RRDatas) > 0 {
+		c.Additions = []*gcpRecordSet{rrs}
+	}
+	if len(c.Additions) == 0 && len(c.Deletions) == 0 {
+		return nil
+	}
+	if err = p.do(ctx, http.MethodPost, path+"/changes", nil, c, c); err != nil {
+		return err
+	}
+	return waitChange(ctx, func() (bool, error) {
+		if c.Status == "done" {
+			return true, nil
+		}
+		return false, p.do(ctx, http.MethodGet, path+"/changes/"+url.PathEscape(c.ID), nil, nil, c)
+	})
+}
+
+// containsTXT returns true if the quoted TXT record value is one of the unquoted values
+func containsTXT(values []string, quoted string) bool {
+	for _, v := range values {
+		if quoteTXT(v) == quoted {
+			return true
+		}
+	}
+	return false
+}
+
+// managedZone returns the configured managed zone name, or the name of the public managed
+// zone whose DNS name is the longest suffix of the record name
+func (p *gcp) managedZone(ctx context.Context, fqdn string) (string, error) {
+	if p.zone != "" {
+		return p.zone, nil
+	}
+	for _, name := range zoneCandidates(fqdn) {
+		var resp struct {
+			ManagedZones []gcpManagedZone `json:"managedZones"`
+		}
+		if err := p.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(p.project)+"/managedZones",
+			url.Values{"dnsName": {name + "."}}, nil, &resp); err != nil {
+			return "", err
+		}
+		for _, z := range resp.ManagedZones {
+			if z.Visibility != "private" {
+				return z.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no gcp managed zone found for %s", fqdn)
+}
+
+// accessToken returns a cached access token, or obtains a new one with a signed assertion
+// of the service account
+func (p *gcp) accessToken(ctx context.Context) (string, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	now := time.Now()
+	if p.token != "" && now.Before(p.expiry) {
+		return p.token, nil
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   p.email,
+		"scope": gcpScope,
+		"aud":   p.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+	h := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {gcpGrantType},
+		"assertion": {unsigned + "." + enc.EncodeToString(sig)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURI,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcp token request failed: status %d", resp.StatusCode)
+	}
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", errors.New("gcp token request did not provide an access token")
+	}
+	// the token is renewed a minute early, so it does not expire during a request
+	p.token = tr.AccessToken
+	p.expiry = now.Add(time.Duration(tr.ExpiresIn)*time.Second - time.Minute)
+	return p.token, nil
+}
+
+// do sends the authorized request to the Cloud DNS API, and decodes the JSON response into v
+func (p *gcp) do(ctx context.Context, method, path string, q url.Values, body interface{},
+	v interface{}) error {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	u := p.endpoint + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var b []byte
+	if body != nil {
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := &gcpError{}
+		if json.NewDecoder(resp.Body).Decode(e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("gcp request failed: %d: %s", e.Error.Code, e.Error.Message)
+		}
+		return fmt.Errorf("gcp request failed: status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
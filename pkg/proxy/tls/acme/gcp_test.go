@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+var testGCPKey *rsa.PrivateKey
+
+// testGCPCredentials writes a service account key file with the token URI, and returns its path
+func testGCPCredentials(t *testing.T, tokenURI string) string {
+	if testGCPKey == nil {
+		var err error
+		if testGCPKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kb, err := x509.MarshalPKCS8PrivateKey(testGCPKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(gcpCredentials{
+		ClientEmail: "trickster@test.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kb})),
+		TokenURI:    tokenURI,
+	})
+	path := filepath.Join(testStoragePath(t), "credentials.json")
+	if err = ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGCP(t *testing.T) {
+
+	changePollInterval = time.Millisecond
+	defer func() { changePollInterval = 2 * time.Second }()
+
+	var tokens int
+	var rrset *gcpRecordSet
+	s := httptest.NewServer(nil)
+	defer s.Close()
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			// the assertion is signed by the service account key
+			r.ParseForm()
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if r.Form.Get("grant_type") != gcpGrantType ||
+				rsa.VerifyPKCS1v15(&testGCPKey.PublicKey, crypto.SHA256, h[:], sig) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tokens++
+			w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":401,"message":"invalid credentials"}}`))
+			return
+		}
+		base := "/projects/test-project/managedZones"
+		switch {
+		case r.URL.Path == base:
+			zones := []gcpManagedZone{}
+			if r.URL.Query().Get("dnsName") == "example.com." {
+				zones = append(zones, gcpManagedZone{Name: "internal", DNSName: "example.com.",
+					Visibility: "private"}, gcpManagedZone{Name: "example-com",
+					DNSName: "example.com.", Visibility: "public"})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"managedZones": zones})
+		case r.URL.Path == base+"/example-com/rrsets":
+			sets := []*gcpRecordSet{}
+			if rrset != nil {
+				sets = append(sets, rrset)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"rrsets": sets})
+		case r.URL.Path == base+"/example-com/changes":
+			c := &gcpChange{}
+			json.NewDecoder(r.Body).Decode(c)
+			if len(c.Deletions) > 0 && (rrset == nil ||
+				strings.Join(c.Deletions[0].RRDatas, ",") != strings.Join(rrset.RRDatas, ",")) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`{"error":{"code":412,"message":"deletion mismatch"}}`))
+				return
+			}
+			rrset = nil
+			if len(c.Additions) > 0 {
+				rrset = c.Additions[0]
+			}
+			c.ID = "1"
+			c.Status = "pending"
+			json.NewEncoder(w).Encode(c)
+		case r.URL.Path == base+"/example-com/changes/1":
+			json.NewEncoder(w).Encode(gcpChange{ID: "1", Status: "done"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	p, err := newGCP(&options.DomainOptions{Project: "test-project",
+		CredentialsPath: testGCPCredentials(t, s.URL+"/token")}, s.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.endpoint = s.URL
+	ctx := context.Background()
+	fqdn := "_acme-challenge.metrics.example.com"
+
+	// an existing record's values are kept
+	rrset = &gcpRecordSet{Name: fqdn + ".", Type: "TXT", TTL: 300, RRDatas: []string{`"other"`}}
+	if err = p.Present(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if rrset == nil || strings.Join(rrset.RRDatas, ",") != `"other","value1","value2"` {
+		t.Errorf("unexpected record set %v", rrset)
+	}
+
+	if err = p.CleanUp(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if rrset == nil || strings.Join(rrset.RRDatas, ",") != `"other"` {
+		t.Errorf("unexpected record set %v", rrset)
+	}
+
+	// the record is deleted once it has no values
+	if err = p.CleanUp(ctx, fqdn, []string{"other"}); err != nil {
+		t.Fatal(err)
+	}
+	if rrset != nil {
+		t.Errorf("unexpected record set %v", rrset)
+	}
+
+	// the access token is reused until it expires
+	if tokens != 1 {
+		t.Errorf("expected %d got %d", 1, tokens)
+	}
+
+	if err = p.Present(ctx, "_acme-challenge.example.org", []string{"value1"}); err == nil {
+		t.Error("expected error for missing zone")
+	}
+
+	p.token = "other-token"
+	err = p.Present(ctx, fqdn, []string{"value1"})
+	if err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("expected credentials error got %v", err)
+	}
+
+	// a new token can not be obtained
+	p.token = ""
+	p.tokenURI = s.URL + "/invalid"
+	if err = p.Present(ctx, fqdn, []string{"value1"}); err == nil {
+		t.Error("expected error for failed token request")
+	}
+}
+
+func TestNewGCPInvalidKey(t *testing.T) {
+	path := filepath.Join(testStoragePath(t), "credentials.json")
+	ioutil.WriteFile(path, []byte(`{"client_email":"test","private_key":"invalid",`+
+		`"token_uri":"http://127.0.0.1/token"}`), 0600)
+	if _, err := newGCP(&options.DomainOptions{CredentialsPath: path}, http.DefaultClient); err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
@@ -0,0 +1,334 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the options of the ACME certificates managed for the TLS listener
+package options
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+	str "github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+const (
+	// ProviderRoute53 is the DNS provider name of AWS Route 53
+	ProviderRoute53 = "route53"
+	// ProviderCloudflare is the DNS provider name of Cloudflare
+	ProviderCloudflare = "cloudflare"
+	// ProviderGCP is the DNS provider name of Google Cloud DNS
+	ProviderGCP = "gcp"
+)
+
+// ErrMissingStoragePath is returned when domains are configured without a storage path
+var ErrMissingStoragePath = errors.New("acme storage_path must be provided")
+
+// ErrInvalidDirectoryURL is returned when the directory URL is not an http or https URL
+var ErrInvalidDirectoryURL = errors.New("acme directory_url must be an http or https URL")
+
+// ErrInvalidIntervals is returned when any of the durations is not positive
+var ErrInvalidIntervals = errors.New("acme renew_before_secs, check_interval_secs, " +
+	"retry_interval_secs and timeout_ms must be greater than 0")
+
+// ErrInvalidPropagationDelay is returned when the propagation delay is negative
+var ErrInvalidPropagationDelay = errors.New("acme propagation_delay_secs must not be negative")
+
+// reDomainKey matches the names of the domains map, which are also used as file names
+var reDomainKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// reDNSName matches a DNS name, optionally with a wildcard leftmost label
+var reDNSName = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+` +
+	`[A-Za-z]{2,63}$`)
+
+// Options configures the certificates that are obtained from an ACME (RFC 8555) certificate
+// authority with DNS-01 challenges, and served by the TLS listener
+type Options struct {
+	// DirectoryURL is the URL of the certificate authority's ACME directory
+	DirectoryURL string `toml:"directory_url"`
+	// Email is the contact address of the ACME account, to which the certificate authority
+	// sends expiration notices
+	Email string `toml:"email"`
+	// StoragePath is the directory in which the account key and the certificates are stored,
+	// so they are reused across restarts
+	StoragePath string `toml:"storage_path"`
+	// RenewBeforeSecs is the time before a certificate's expiration at which it is renewed
+	RenewBeforeSecs int `toml:"renew_before_secs"`
+	// CheckIntervalSecs is the interval between checks of whether certificates are due for renewal
+	CheckIntervalSecs int `toml:"check_interval_secs"`
+	// RetryIntervalSecs is the wait before a failed certificate order is retried
+	RetryIntervalSecs int `toml:"retry_interval_secs"`
+	// PropagationDelaySecs is the wait between publishing the DNS-01 challenge records and
+	// asking the certificate authority to validate them
+	PropagationDelaySecs int `toml:"propagation_delay_secs"`
+	// TimeoutMS is the timeout of each request to the ACME and DNS provider APIs
+	TimeoutMS int `toml:"timeout_ms"`
+	// Domains is a map of the certificates to obtain, each with the DNS provider that
+	// publishes its challenge records
+	Domains map[string]*DomainOptions `toml:"domains"`
+
+	// RenewBefore is the time.Duration representation of RenewBeforeSecs
+	RenewBefore time.Duration `toml:"-"`
+	// CheckInterval is the time.Duration representation of CheckIntervalSecs
+	CheckInterval time.Duration `toml:"-"`
+	// RetryInterval is the time.Duration representation of RetryIntervalSecs
+	RetryInterval time.Duration `toml:"-"`
+	// PropagationDelay is the time.Duration representation of PropagationDelaySecs
+	PropagationDelay time.Duration `toml:"-"`
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `toml:"-"`
+}
+
+// DomainOptions is a certificate obtained for a list of DNS names, whose DNS-01 challenges
+// are answered by the named DNS provider
+type DomainOptions struct {
+	// Name is the Name of the certificate, taken from the Key in the domains map
+	Name string `toml:"-"`
+	// Names are the DNS names of the certificate, such as '*.metrics.example.com'
+	Names []string `toml:"names"`
+	// Provider is the DNS provider hosting the names' zone: 'route53', 'cloudflare' or 'gcp'
+	Provider string `toml:"provider"`
+	// ZoneID is the provider's identifier of the zone: the Route 53 hosted zone ID, the
+	// Cloudflare zone ID or the Cloud DNS managed zone name. When empty, the zone is looked up
+	ZoneID string `toml:"zone_id"`
+	// AccessKeyID is the AWS access key of the Route 53 provider. When empty, the
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used
+	AccessKeyID string `toml:"access_key_id"`
+	// SecretAccessKey is the AWS secret key of the Route 53 provider
+	SecretAccessKey string `toml:"secret_access_key"`
+	// SessionToken is the AWS session token of temporary Route 53 provider credentials
+	SessionToken string `toml:"session_token"`
+	// APIToken is the Cloudflare API token, which must have the Zone.DNS edit permission
+	APIToken string `toml:"api_token"`
+	// Project is the Google Cloud project of the Cloud DNS managed zone
+	Project string `toml:"project"`
+	// CredentialsPath is the path of the Google Cloud service account key file
+	CredentialsPath string `toml:"credentials_path"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	o := &Options{
+		DirectoryURL:         d.DefaultACMEDirectoryURL,
+		RenewBeforeSecs:      d.DefaultACMERenewBeforeSecs,
+		CheckIntervalSecs:    d.DefaultACMECheckIntervalSecs,
+		RetryIntervalSecs:    d.DefaultACMERetryIntervalSecs,
+		PropagationDelaySecs: d.DefaultACMEPropagationDelaySecs,
+		TimeoutMS:            d.DefaultACMETimeoutMS,
+	}
+	o.SetDurations()
+	return o
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	o2 := &Options{
+		DirectoryURL:         o.DirectoryURL,
+		Email:                o.Email,
+		StoragePath:          o.StoragePath,
+		RenewBeforeSecs:      o.RenewBeforeSecs,
+		CheckIntervalSecs:    o.CheckIntervalSecs,
+		RetryIntervalSecs:    o.RetryIntervalSecs,
+		PropagationDelaySecs: o.PropagationDelaySecs,
+		TimeoutMS:            o.TimeoutMS,
+		RenewBefore:          o.RenewBefore,
+		CheckInterval:        o.CheckInterval,
+		RetryInterval:        o.RetryInterval,
+		PropagationDelay:     o.PropagationDelay,
+		Timeout:              o.Timeout,
+	}
+	if o.Domains != nil {
+		o2.Domains = make(map[string]*DomainOptions, len(o.Domains))
+		for k, v := range o.Domains {
+			o2.Domains[k] = v.Clone()
+		}
+	}
+	return o2
+}
+
+// Clone returns an exact copy of the subject *DomainOptions
+func (do *DomainOptions) Clone() *DomainOptions {
+	return &DomainOptions{
+		Name:            do.Name,
+		Names:           str.CloneList(do.Names),
+		Provider:        do.Provider,
+		ZoneID:          do.ZoneID,
+		AccessKeyID:     do.AccessKeyID,
+		SecretAccessKey: do.SecretAccessKey,
+		SessionToken:    do.SessionToken,
+		APIToken:        do.APIToken,
+		Project:         do.Project,
+		CredentialsPath: do.CredentialsPath,
+	}
+}
+
+// Equal returns true if all TOML-exposed option members are equal
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	if o.DirectoryURL != o2.DirectoryURL ||
+		o.Email != o2.Email ||
+		o.StoragePath != o2.StoragePath ||
+		o.RenewBeforeSecs != o2.RenewBeforeSecs ||
+		o.CheckIntervalSecs != o2.CheckIntervalSecs ||
+		o.RetryIntervalSecs != o2.RetryIntervalSecs ||
+		o.PropagationDelaySecs != o2.PropagationDelaySecs ||
+		o.TimeoutMS != o2.TimeoutMS ||
+		len(o.Domains) != len(o2.Domains) {
+		return false
+	}
+	for k, v := range o.Domains {
+		if v2, ok := o2.Domains[k]; !ok || !v.Equal(v2) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if all TOML-exposed option members are equal
+func (do *DomainOptions) Equal(do2 *DomainOptions) bool {
+	return str.Equal(do.Names, do2.Names) &&
+		do.Provider == do2.Provider &&
+		do.ZoneID == do2.ZoneID &&
+		do.AccessKeyID == do2.AccessKeyID &&
+		do.SecretAccessKey == do2.SecretAccessKey &&
+		do.SessionToken == do2.SessionToken &&
+		do.APIToken == do2.APIToken &&
+		do.Project == do2.Project &&
+		do.CredentialsPath == do2.CredentialsPath
+}
+
+// Enabled returns true if any certificates are configured
+func (o *Options) Enabled() bool {
+	return o != nil && len(o.Domains) > 0
+}
+
+// SetDurations sets the time.Duration representations of the second-based values
+func (o *Options) SetDurations() {
+	o.RenewBefore = time.Duration(o.RenewBeforeSecs) * time.Second
+	o.CheckInterval = time.Duration(o.CheckIntervalSecs) * time.Second
+	o.RetryInterval = time.Duration(o.RetryIntervalSecs) * time.Second
+	o.PropagationDelay = time.Duration(o.PropagationDelaySecs) * time.Second
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+}
+
+// Validate returns an error if the Options contain invalid values. The Name of each
+// DomainOptions is set to its key in the domains map
+func (o *Options) Validate() error {
+	if !o.Enabled() {
+		return nil
+	}
+	if o.StoragePath == "" {
+		return ErrMissingStoragePath
+	}
+	u, err := url.Parse(o.DirectoryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidDirectoryURL
+	}
+	if o.RenewBeforeSecs <= 0 || o.CheckIntervalSecs <= 0 || o.RetryIntervalSecs <= 0 ||
+		o.TimeoutMS <= 0 {
+		return ErrInvalidIntervals
+	}
+	if o.PropagationDelaySecs < 0 {
+		return ErrInvalidPropagationDelay
+	}
+	// a DNS name can only be managed by one certificate, or the certificates
+	// would be served interchangeably
+	owners := make(map[string]string)
+	for _, k := range o.DomainNames() {
+		do := o.Domains[k]
+		if do == nil {
+			return fmt.Errorf("acme domain [%s] has no names", k)
+		}
+		do.Name = k
+		if !reDomainKey.MatchString(k) {
+			return fmt.Errorf("invalid acme domain name [%s]", k)
+		}
+		if err := do.Validate(); err != nil {
+			return err
+		}
+		for _, n := range do.Names {
+			n = strings.ToLower(n)
+			if owner, ok := owners[n]; ok {
+				return fmt.Errorf("dns name [%s] is provided in acme domains [%s] and [%s]",
+					n, owner, k)
+			}
+			owners[n] = k
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the DomainOptions contain invalid values
+func (do *DomainOptions) Validate() error {
+	if len(do.Names) == 0 {
+		return fmt.Errorf("acme domain [%s] has no names", do.Name)
+	}
+	for _, n := range do.Names {
+		if !reDNSName.MatchString(n) {
+			return fmt.Errorf("invalid dns name [%s] provided in acme domain [%s]", n, do.Name)
+		}
+	}
+	switch do.Provider {
+	case ProviderRoute53:
+	case ProviderCloudflare:
+		if do.APIToken == "" {
+			return fmt.Errorf("acme domain [%s] requires an api_token for the %s provider",
+				do.Name, do.Provider)
+		}
+	case ProviderGCP:
+		if do.Project == "" || do.CredentialsPath == "" {
+			return fmt.Errorf("acme domain [%s] requires a project and credentials_path "+
+				"for the %s provider", do.Name, do.Provider)
+		}
+	default:
+		return fmt.Errorf("invalid dns provider [%s] provided in acme domain [%s]",
+			do.Provider, do.Name)
+	}
+	return nil
+}
+
+// DomainNames returns the sorted keys of the domains map
+func (o *Options) DomainNames() []string {
+	names := make([]string, 0, len(o.Domains))
+	for k := range o.Domains {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AccountKeyPath returns the path of the file holding the ACME account's private key
+func (o *Options) AccountKeyPath() string {
+	return filepath.Join(o.StoragePath, "account.key")
+}
+
+// CertificatePath returns the path of the file holding the named domain's certificate chain
+func (o *Options) CertificatePath(name string) string {
+	return filepath.Join(o.StoragePath, "certificates", name+".crt")
+}
+
+// PrivateKeyPath returns the path of the file holding the named domain's private key
+func (o *Options) PrivateKeyPath(name string) string {
+	return filepath.Join(o.StoragePath, "certificates", name+".key")
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const testOptions = `
+[acme]
+email = 'ops@example.com'
+storage_path = '/var/lib/trickster/acme'
+renew_before_secs = 86400
+  [acme.domains.metrics]
+  names = ['*.metrics.example.com', 'metrics.example.com']
+  provider = 'route53'
+  [acme.domains.dashboards]
+  names = ['dashboards.example.com']
+  provider = 'cloudflare'
+  api_token = 'test-token'
+`
+
+type testConfig struct {
+	ACME *Options `toml:"acme"`
+}
+
+func TestValidate(t *testing.T) {
+
+	c := &testConfig{ACME: NewOptions()}
+	if _, err := toml.Decode(testOptions, c); err != nil {
+		t.Fatal(err)
+	}
+	o := c.ACME
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o.SetDurations()
+
+	if !o.Enabled() {
+		t.Error("expected enabled options")
+	}
+	if o.DirectoryURL != "https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("unexpected directory url %s", o.DirectoryURL)
+	}
+	if o.RenewBefore != 24*time.Hour {
+		t.Errorf("expected %s got %s", 24*time.Hour, o.RenewBefore)
+	}
+	if o.CheckInterval != 12*time.Hour {
+		t.Errorf("expected %s got %s", 12*time.Hour, o.CheckInterval)
+	}
+	if o.Domains["metrics"].Name != "metrics" {
+		t.Errorf("expected %s got %s", "metrics", o.Domains["metrics"].Name)
+	}
+	if names := o.DomainNames(); len(names) != 2 || names[0] != "dashboards" {
+		t.Errorf("unexpected domain names %v", names)
+	}
+	if p := o.CertificatePath("metrics"); p != filepath.Join("/var/lib/trickster/acme",
+		"certificates", "metrics.crt") {
+		t.Errorf("unexpected certificate path %s", p)
+	}
+
+	tests := []func(o *Options){
+		func(o *Options) { o.StoragePath = "" },
+		func(o *Options) { o.DirectoryURL = "ftp://example.com" },
+		func(o *Options) { o.CheckIntervalSecs = 0 },
+		func(o *Options) { o.PropagationDelaySecs = -1 },
+		func(o *Options) { o.Domains["metrics"].Names = nil },
+		func(o *Options) { o.Domains["metrics"].Names = []string{"*.*.example.com"} },
+		func(o *Options) { o.Domains["metrics"].Provider = "unknown" },
+		func(o *Options) { o.Domains["dashboards"].APIToken = "" },
+		func(o *Options) { o.Domains["dashboards"].Provider = ProviderGCP },
+		func(o *Options) { o.Domains["dashboards"].Names = []string{"Metrics.example.com"} },
+		func(o *Options) { o.Domains["../metrics"] = o.Domains["metrics"].Clone() },
+	}
+	for i, test := range tests {
+		o2 := o.Clone()
+		test(o2)
+		if err := o2.Validate(); err == nil {
+			t.Errorf("test %d: expected error for invalid options", i)
+		}
+	}
+
+	// options without domains are not validated, as they are not used
+	o2 := NewOptions()
+	o2.StoragePath = ""
+	if err := o2.Validate(); err != nil || o2.Enabled() {
+		t.Errorf("expected valid, disabled options got %v", err)
+	}
+}
+
+func TestEqual(t *testing.T) {
+
+	c := &testConfig{ACME: NewOptions()}
+	if _, err := toml.Decode(testOptions, c); err != nil {
+		t.Fatal(err)
+	}
+	o := c.ACME
+	o2 := o.Clone()
+	if !o.Equal(o2) {
+		t.Error("expected clone to be equal")
+	}
+	o2.Domains["metrics"].Names = []string{"metrics.example.com"}
+	if o.Equal(o2) {
+		t.Error("expected options with different names to differ")
+	}
+	o2 = o.Clone()
+	delete(o2.Domains, "metrics")
+	if o.Equal(o2) {
+		t.Error("expected options with different domains to differ")
+	}
+	var o3 *Options
+	if o.Equal(o3) || !o3.Equal(nil) {
+		t.Error("unexpected result comparing nil options")
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+	"github.com/tricksterproxy/trickster/pkg/util/sigv4"
+)
+
+const (
+	// route53Endpoint is the base URL of the Route 53 API, which is global
+	route53Endpoint = "https://route53.amazonaws.com/2013-04-01"
+	// route53Region is the region in which Route 53 API requests are signed
+	route53Region = "us-east-1"
+	// route53Namespace is the XML namespace of the Route 53 API documents
+	route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+// route53 is the Provider of zones hosted by AWS Route 53, whose API requests are signed
+// with AWS Signature Version 4
+type route53 struct {
+	endpoint string
+	zoneID   string
+	creds    sigv4.Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newRoute53(do *options.DomainOptions, c *http.Client) *route53 {
+	return &route53{
+		endpoint: route53Endpoint,
+		zoneID:   do.ZoneID,
+		creds:    sigv4.NewCredentials(do.AccessKeyID, do.SecretAccessKey, do.SessionToken),
+		client:   c,
+		now:      time.Now,
+	}
+}
+
+type route53HostedZone struct {
+	ID     string `xml:"Id"`
+	Name   string `xml:"Name"`
+	Config struct {
+		PrivateZone bool `xml:"PrivateZone"`
+	} `xml:"Config"`
+}
+
+type route53ListHostedZonesByNameResponse struct {
+	HostedZones []route53HostedZone `xml:"HostedZones>HostedZone"`
+}
+
+type route53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+type route53ResourceRecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	TTL             int                     `xml:"TTL"`
+	ResourceRecords []route53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Change struct {
+	Action            string                   `xml:"Action"`
+	ResourceRecordSet route53ResourceRecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeResourceRecordSetsRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53ChangeInfo struct {
+	ID     string `xml:"ChangeInfo>Id"`
+	Status string `xml:"ChangeInfo>Status"`
+}
+
+// route53Error is the error document of a failed Route 53 API request
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Present upserts the TXT record with the values, and waits for the change to reach
+// all of the zone's name servers
+func (p *route53) Present(ctx context.Context, fqdn string, values []string) error {
+	return p.change(ctx, "UPSERT", fqdn, values)
+}
+
+// CleanUp deletes the TXT record with the values
+func (p *route53) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	return p.change(ctx, "DELETE", fqdn, values)
+}
+
+func (p *route53) change(ctx context.Context, action, fqdn string, values []string) error {
+	zoneID, err := p.zone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	rrs := route53ResourceRecordSet{Name: fqdn + ".", Type: "TXT", TTL: recordTTLSecs,
+		ResourceRecords: make([]route53ResourceRecord, len(values))}
+	for i, v := range values {
+		rrs.ResourceRecords[i].Value = quoteTXT(v)
+	}
+	body, err := xml.Marshal(route53ChangeResourceRecordSetsRequest{Xmlns: route53Namespace,
+		Changes: []route53Change{{Action: action, ResourceRecordSet: rrs}}})
+	if err != nil {
+		return err
+	}
+	ci := &route53ChangeInfo{}
+	if err = p.do(ctx, http.MethodPost, "/hostedzone/"+zoneID+"/rrset", nil, body, ci); err != nil {
+		return err
+	}
+	if action == "DELETE" {
+		return nil
+	}
+	return waitChange(ctx, func() (bool, error) {
+		if ci.Status == "INSYNC" {
+			return true, nil
+		}
+		id := strings.TrimPrefix(ci.ID, "/change/")
+		return false, p.do(ctx, http.MethodGet, "/change/"+id, nil, nil, ci)
+	})
+}
+
+// zone returns the configured hosted zone ID, or the ID of the public hosted zone whose
+// name is the longest suffix of the record name
+func (p *route53) zone(ctx context.Context, fqdn string) (string, error) {
+	if p.zoneID != "" {
+		return p.zoneID, nil
+	}
+	for _, name := range zoneCandidates(fqdn) {
+		resp := &route53ListHostedZonesByNameResponse{}
+		q := url.Values{"dnsname": {name}, "maxitems": {"1"}}
+		if err := p.do(ctx, http.MethodGet, "/hostedzonesbyname", q, nil, resp); err != nil {
+			return "", err
+		}
+		for _, z := range resp.HostedZones {
+			if strings.EqualFold(strings.TrimSuffix(z.Name, "."), name) && !z.Config.PrivateZone {
+				return strings.TrimPrefix(z.ID, "/hostedzone/"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no route53 hosted zone found for %s", fqdn)
+}
+
+// do sends the signed request to the Route 53 API, and decodes the XML response into v
+func (p *route53) do(ctx context.Context, method, path string, q url.Values, body []byte,
+	v interface{}) error {
+	u := p.endpoint + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+	sigv4.Sign(req, body, p.creds, route53Region, "route53", p.now())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &route53Error{}
+		if xml.Unmarshal(b, e) == nil && e.Code != "" {
+			return fmt.Errorf("route53 request failed: %s: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("route53 request failed: status %d", resp.StatusCode)
+	}
+	return xml.Unmarshal(b, v)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acme
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/tls/acme/options"
+)
+
+func TestRoute53(t *testing.T) {
+
+	changePollInterval = time.Millisecond
+	defer func() { changePollInterval = 2 * time.Second }()
+
+	var changes []route53ChangeResourceRecordSetsRequest
+	var polls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := r.Header.Get("Authorization"); !strings.HasPrefix(a,
+			"AWS4-HMAC-SHA256 Credential=test-key/") ||
+			!strings.Contains(a, "/us-east-1/route53/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Code>SignatureDoesNotMatch</Code>` +
+				`<Message>bad signature</Message></Error></ErrorResponse>`))
+			return
+		}
+		switch {
+		case r.URL.Path == "/hostedzonesbyname":
+			// the zones are listed in order from the requested name
+			name := r.URL.Query().Get("dnsname")
+			zone := "example.com."
+			if name != "example.com" {
+				zone = "example.net."
+			}
+			w.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones><HostedZone>` +
+				`<Id>/hostedzone/Z123</Id><Name>` + zone + `</Name><Config>` +
+				`<PrivateZone>false</PrivateZone></Config></HostedZone></HostedZones>` +
+				`</ListHostedZonesByNameResponse>`))
+		case r.URL.Path == "/hostedzone/Z123/rrset" && r.Method == http.MethodPost:
+			b, _ := ioutil.ReadAll(r.Body)
+			c := route53ChangeResourceRecordSetsRequest{}
+			if err := xml.Unmarshal(b, &c); err != nil {
+				t.Error(err)
+			}
+			changes = append(changes, c)
+			w.Write([]byte(`<ChangeResourceRecordSetsResponse><ChangeInfo>` +
+				`<Id>/change/C1</Id><Status>PENDING</Status></ChangeInfo>` +
+				`</ChangeResourceRecordSetsResponse>`))
+		case r.URL.Path == "/change/C1":
+			polls++
+			status := "PENDING"
+			if polls > 1 {
+				status = "INSYNC"
+			}
+			w.Write([]byte(`<GetChangeResponse><ChangeInfo><Id>/change/C1</Id><Status>` +
+				status + `</Status></ChangeInfo></GetChangeResponse>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	p := newRoute53(&options.DomainOptions{AccessKeyID: "test-key", SecretAccessKey: "test-secret"},
+		s.Client())
+	p.endpoint = s.URL
+	ctx := context.Background()
+	fqdn := "_acme-challenge.metrics.example.com"
+
+	if err := p.Present(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 {
+		t.Errorf("expected %d got %d", 2, polls)
+	}
+	if err := p.CleanUp(ctx, fqdn, []string{"value1", "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(changes))
+	}
+	for i, action := range []string{"UPSERT", "DELETE"} {
+		c := changes[i].Changes[0]
+		if c.Action != action {
+			t.Errorf("expected %s got %s", action, c.Action)
+		}
+		rrs := c.ResourceRecordSet
+		if rrs.Name != fqdn+"." || rrs.Type != "TXT" || len(rrs.ResourceRecords) != 2 ||
+			rrs.ResourceRecords[1].Value != `"value2"` {
+			t.Errorf("unexpected record set %v", rrs)
+		}
+	}
+
+	// no zone is found
+	if err := p.Present(ctx, "_acme-challenge.example.org", []string{"value1"}); err == nil {
+		t.Error("expected error for missing hosted zone")
+	}
+
+	// the request is rejected
+	p.creds.AccessKeyID = "other-key"
+	err := p.Present(ctx, fqdn, []string{"value1"})
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("expected signature error got %v", err)
+	}
+
+	// a configured zone is not looked up
+	p.creds.AccessKeyID = "test-key"
+	p.zoneID = "Z999"
+	if err = p.Present(ctx, fqdn, []string{"value1"}); err == nil {
+		t.Error("expected error for unknown zone")
+	}
+}
@@ -19,6 +19,7 @@ package options
 import (
 	"io/ioutil"
 
+	tp "github.com/tricksterproxy/trickster/pkg/proxy/tls/policy/options"
 	"github.com/tricksterproxy/trickster/pkg/util/strings"
 )
 
@@ -38,10 +39,25 @@ type Options struct {
 	// CertificateAuthorities provides a list of custom Certificate Authorities for the upstream origin
 	// which are considered in addition to any system CA's by the Trickster HTTPS Client
 	CertificateAuthorityPaths []string `toml:"certificate_authority_paths"`
+	// CABundlePath provides the path to a dedicated bundle of Certificate Authorities for the upstream
+	// origin. When set, only these CA's (and any CertificateAuthorityPaths) are trusted, and system CA's are not
+	CABundlePath string `toml:"ca_bundle_path"`
+	// SPKIPins provides a list of base64-encoded SHA-256 hashes of the Subject Public Key Info of
+	// certificates in the upstream origin's chain. When set, at least one certificate must match a pin
+	SPKIPins []string `toml:"spki_pins"`
+	// ServerName overrides the server name used to verify the upstream origin's certificate,
+	// and sent in the TLS Server Name Indication extension
+	ServerName string `toml:"server_name"`
 	// ClientCertPath provides the path to the Client Certificate when using Mutual Authorization
 	ClientCertPath string `toml:"client_cert_path"`
 	// ClientKeyPath provides the path to the Client Key when using Mutual Authorization
 	ClientKeyPath string `toml:"client_key_path"`
+	// PolicyName is the name of the TLS policy profile applied to the HTTPS Client
+	// when connecting to the upstream origin
+	PolicyName string `toml:"policy_name"`
+
+	// Policy is the TLS policy profile represented by PolicyName
+	Policy *tp.Options `toml:"-"`
 }
 
 // NewOptions will return a *Options with the default settings
@@ -61,14 +77,25 @@ func (o *Options) Clone() *Options {
 		copy(caps, o.CertificateAuthorityPaths)
 	}
 
+	var pins []string
+	if o.SPKIPins != nil {
+		pins = make([]string, len(o.SPKIPins))
+		copy(pins, o.SPKIPins)
+	}
+
 	return &Options{
 		FullChainCertPath:         o.FullChainCertPath,
 		PrivateKeyPath:            o.PrivateKeyPath,
 		ServeTLS:                  o.ServeTLS,
 		InsecureSkipVerify:        o.InsecureSkipVerify,
 		CertificateAuthorityPaths: caps,
+		CABundlePath:              o.CABundlePath,
+		SPKIPins:                  pins,
+		ServerName:                o.ServerName,
 		ClientCertPath:            o.ClientCertPath,
 		ClientKeyPath:             o.ClientKeyPath,
+		PolicyName:                o.PolicyName,
+		Policy:                    o.Policy,
 	}
 }
 
@@ -78,8 +105,12 @@ func (o *Options) Equal(o2 *Options) bool {
 		o.PrivateKeyPath == o2.PrivateKeyPath &&
 		o.InsecureSkipVerify == o2.InsecureSkipVerify &&
 		strings.Equal(o.CertificateAuthorityPaths, o2.CertificateAuthorityPaths) &&
+		o.CABundlePath == o2.CABundlePath &&
+		strings.Equal(o.SPKIPins, o2.SPKIPins) &&
+		o.ServerName == o2.ServerName &&
 		o.ClientCertPath == o2.ClientCertPath &&
-		o.ClientKeyPath == o2.ClientKeyPath
+		o.ClientKeyPath == o2.ClientKeyPath &&
+		o.PolicyName == o2.PolicyName
 }
 
 // Validate returns true if the TLS Options are validated
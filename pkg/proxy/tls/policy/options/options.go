@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides named TLS policy profiles that control the protocol
+// versions, cipher suites and curves used by the frontend listener and upstream clients
+package options
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+
+	"github.com/tricksterproxy/trickster/pkg/util/strings"
+)
+
+const (
+	// PolicyModern is the name of the built-in modern TLS policy, which requires TLS 1.3
+	PolicyModern = "modern"
+	// PolicyIntermediate is the name of the built-in intermediate TLS policy,
+	// which permits TLS 1.2 with forward-secret AEAD cipher suites
+	PolicyIntermediate = "intermediate"
+	// PolicyFIPS is the name of the built-in fips TLS policy, which limits TLS 1.2
+	// to FIPS 140-2 approved cipher suites and curves
+	PolicyFIPS = "fips"
+)
+
+// Options is a named TLS policy profile
+type Options struct {
+	// Name is the Name of the profile, taken from the Key in the tls_policies map
+	Name string `toml:"-"`
+	// MinVersion is the minimum TLS version permitted ('1.0', '1.1', '1.2' or '1.3')
+	MinVersion string `toml:"min_version"`
+	// MaxVersion is the maximum TLS version permitted; empty uses the maximum supported version
+	MaxVersion string `toml:"max_version"`
+	// CipherSuites is the list of permitted TLS 1.0-1.2 cipher suites, by their IANA names
+	// (e.g., 'TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256'). TLS 1.3 cipher suites are not configurable
+	CipherSuites []string `toml:"cipher_suites"`
+	// Curves is the list of permitted elliptic curves for key exchange, in order of preference
+	// ('X25519', 'P256', 'P384' or 'P521')
+	Curves []string `toml:"curves"`
+
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+	curves       []tls.CurveID
+}
+
+var versionNames = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var curveNames = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+var cipherSuiteNames map[string]uint16
+
+func init() {
+	cipherSuiteNames = make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		cipherSuiteNames[cs.Name] = cs.ID
+	}
+}
+
+// NewOptions returns a new *Options with the default values
+func NewOptions() *Options {
+	return &Options{
+		MinVersion:   "1.2",
+		CipherSuites: make([]string, 0),
+		Curves:       make([]string, 0),
+	}
+}
+
+// BuiltinPolicies returns the built-in TLS policy profiles
+func BuiltinPolicies() map[string]*Options {
+	return map[string]*Options{
+		PolicyModern: {
+			Name:       PolicyModern,
+			MinVersion: "1.3",
+			Curves:     []string{"X25519", "P256", "P384"},
+		},
+		PolicyIntermediate: {
+			Name:       PolicyIntermediate,
+			MinVersion: "1.2",
+			CipherSuites: []string{
+				"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+				"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+				"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+				"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+				"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+			},
+			Curves: []string{"X25519", "P256", "P384"},
+		},
+		PolicyFIPS: {
+			Name:       PolicyFIPS,
+			MinVersion: "1.2",
+			CipherSuites: []string{
+				"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+				"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+				"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+			},
+			Curves: []string{"P256", "P384"},
+		},
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	c := &Options{
+		Name:       o.Name,
+		MinVersion: o.MinVersion,
+		MaxVersion: o.MaxVersion,
+		minVersion: o.minVersion,
+		maxVersion: o.maxVersion,
+	}
+	if o.CipherSuites != nil {
+		c.CipherSuites = make([]string, len(o.CipherSuites))
+		copy(c.CipherSuites, o.CipherSuites)
+	}
+	if o.Curves != nil {
+		c.Curves = make([]string, len(o.Curves))
+		copy(c.Curves, o.Curves)
+	}
+	if o.cipherSuites != nil {
+		c.cipherSuites = make([]uint16, len(o.cipherSuites))
+		copy(c.cipherSuites, o.cipherSuites)
+	}
+	if o.curves != nil {
+		c.curves = make([]tls.CurveID, len(o.curves))
+		copy(c.curves, o.curves)
+	}
+	return c
+}
+
+// Equal returns true if all TOML-exposed option members are equal
+func (o *Options) Equal(o2 *Options) bool {
+	if o == nil || o2 == nil {
+		return o == o2
+	}
+	return o.MinVersion == o2.MinVersion &&
+		o.MaxVersion == o2.MaxVersion &&
+		strings.Equal(o.CipherSuites, o2.CipherSuites) &&
+		strings.Equal(o.Curves, o2.Curves)
+}
+
+// Validate returns an error if the Options are invalid, and otherwise
+// compiles the configured names into their crypto/tls values
+func (o *Options) Validate() error {
+	var ok bool
+	if o.MinVersion == "" {
+		o.minVersion = 0
+	} else if o.minVersion, ok = versionNames[o.MinVersion]; !ok {
+		return fmt.Errorf("invalid min_version [%s] in tls policy [%s]", o.MinVersion, o.Name)
+	}
+	if o.MaxVersion == "" {
+		o.maxVersion = 0
+	} else if o.maxVersion, ok = versionNames[o.MaxVersion]; !ok {
+		return fmt.Errorf("invalid max_version [%s] in tls policy [%s]", o.MaxVersion, o.Name)
+	}
+	if o.maxVersion != 0 && o.maxVersion < o.minVersion {
+		return fmt.Errorf("max_version is less than min_version in tls policy [%s]", o.Name)
+	}
+	o.cipherSuites = nil
+	if len(o.CipherSuites) > 0 {
+		o.cipherSuites = make([]uint16, len(o.CipherSuites))
+		for i, name := range o.CipherSuites {
+			if o.cipherSuites[i], ok = cipherSuiteNames[name]; !ok {
+				return fmt.Errorf("invalid cipher suite [%s] in tls policy [%s]", name, o.Name)
+			}
+		}
+	}
+	o.curves = nil
+	if len(o.Curves) > 0 {
+		o.curves = make([]tls.CurveID, len(o.Curves))
+		for i, name := range o.Curves {
+			if o.curves[i], ok = curveNames[name]; !ok {
+				return fmt.Errorf("invalid curve [%s] in tls policy [%s]", name, o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply sets the policy's versions, cipher suites and curves on the provided tls.Config
+func (o *Options) Apply(c *tls.Config) {
+	if o == nil || c == nil {
+		return
+	}
+	c.MinVersion = o.minVersion
+	c.MaxVersion = o.maxVersion
+	c.CipherSuites = o.cipherSuites
+	c.CurvePreferences = o.curves
+}
+
+// ProcessTLSPolicyOptions adds the built-in profiles to the provided TLS policy profiles,
+// unless a profile of the same name is already configured, and validates each profile
+func ProcessTLSPolicyOptions(mo map[string]*Options) error {
+	for k, v := range BuiltinPolicies() {
+		if _, ok := mo[k]; !ok {
+			mo[k] = v
+		}
+	}
+	// validate in a stable order so that errors are deterministic
+	keys := make([]string, 0, len(mo))
+	for k := range mo {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := mo[k]
+		if v == nil {
+			v = NewOptions()
+			mo[k] = v
+		}
+		v.Name = k
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestProcessTLSPolicyOptions(t *testing.T) {
+
+	mo := map[string]*Options{
+		"custom": {
+			MinVersion:   "1.2",
+			MaxVersion:   "1.3",
+			CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			Curves:       []string{"P256"},
+		},
+		"empty": nil,
+	}
+
+	err := ProcessTLSPolicyOptions(mo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{PolicyModern, PolicyIntermediate, PolicyFIPS, "custom", "empty"} {
+		if o, ok := mo[k]; !ok || o.Name != k {
+			t.Errorf("expected policy %s", k)
+		}
+	}
+
+	c := &tls.Config{}
+	mo["custom"].Apply(c)
+	if c.MinVersion != tls.VersionTLS12 || c.MaxVersion != tls.VersionTLS13 {
+		t.Errorf("unexpected versions %x %x", c.MinVersion, c.MaxVersion)
+	}
+	if len(c.CipherSuites) != 1 || c.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites %v", c.CipherSuites)
+	}
+	if len(c.CurvePreferences) != 1 || c.CurvePreferences[0] != tls.CurveP256 {
+		t.Errorf("unexpected curves %v", c.CurvePreferences)
+	}
+
+	c = &tls.Config{}
+	mo[PolicyModern].Apply(c)
+	if c.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected %x got %x", tls.VersionTLS13, c.MinVersion)
+	}
+
+	// a configured profile replaces the built-in profile of the same name
+	mo = map[string]*Options{PolicyModern: {MinVersion: "1.2"}}
+	err = ProcessTLSPolicyOptions(mo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mo[PolicyModern].MinVersion != "1.2" {
+		t.Errorf("expected %s got %s", "1.2", mo[PolicyModern].MinVersion)
+	}
+
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []*Options{
+		{MinVersion: "2.0"},
+		{MaxVersion: "2.0"},
+		{MinVersion: "1.3", MaxVersion: "1.2"},
+		{CipherSuites: []string{"TLS_NOT_A_CIPHER"}},
+		{Curves: []string{"P1"}},
+	}
+
+	for i, o := range tests {
+		if err := o.Validate(); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+}
+
+func TestCloneEqual(t *testing.T) {
+
+	o := BuiltinPolicies()[PolicyFIPS]
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	o2 := o.Clone()
+	if !o.Equal(o2) {
+		t.Error("expected true")
+	}
+
+	c1, c2 := &tls.Config{}, &tls.Config{}
+	o.Apply(c1)
+	o2.Apply(c2)
+	if len(c1.CipherSuites) != len(c2.CipherSuites) || c1.MinVersion != c2.MinVersion {
+		t.Error("expected cloned policy to apply identically")
+	}
+
+	o2.Curves = []string{"P521"}
+	if o.Equal(o2) {
+		t.Error("expected false")
+	}
+
+	var o3 *Options
+	if o3.Equal(o) || !o3.Equal(nil) {
+		t.Error("unexpected nil equality result")
+	}
+
+	// nil policies are a no-op
+	o3.Apply(c1)
+
+}
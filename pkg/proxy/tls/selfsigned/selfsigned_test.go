@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selfsigned
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestCertificate(t *testing.T) {
+
+	cert, err := Certificate(DefaultHosts, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(x.DNSNames) != 1 || x.DNSNames[0] != "localhost" {
+		t.Errorf("expected %v got %v", []string{"localhost"}, x.DNSNames)
+	}
+
+	if len(x.IPAddresses) != 2 {
+		t.Errorf("expected %d got %d", 2, len(x.IPAddresses))
+	}
+
+	for _, h := range DefaultHosts {
+		if err := x.VerifyHostname(h); err != nil {
+			t.Error(err)
+		}
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(x)
+	if _, err := x.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: pool}); err != nil {
+		t.Error(err)
+	}
+
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tickets manages the TLS session ticket keys used by the frontend listener
+package tickets
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// keyPrefix is the cache key prefix under which shared session ticket keys are stored
+const keyPrefix = "trickster.tls.session_ticket_key."
+
+// Rotator rotates the session ticket keys of a tls.Config on a fixed interval. When a
+// cache is provided, the keys are stored in and loaded from the cache, so that all
+// Trickster replicas sharing the cache can resume each other's TLS sessions.
+//
+// Each key is bound to an epoch (the number of whole rotation intervals since the
+// Unix epoch), so that replicas agree on which key is current without coordination.
+// The current epoch's key encrypts new tickets, and the prior epoch's key remains
+// available for decryption, so a ticket is valid for at most two rotation intervals.
+type Rotator struct {
+	config   *tls.Config
+	interval time.Duration
+	cache    cache.Cache
+	log      *tl.Logger
+
+	mtx         sync.Mutex
+	keys        map[int64][32]byte
+	nextRefresh int64
+	now         func() time.Time
+}
+
+// NewRotator returns a new Rotator for the provided tls.Config. The cache may be nil,
+// in which case keys are generated and held locally.
+func NewRotator(config *tls.Config, interval time.Duration, c cache.Cache,
+	log *tl.Logger) *Rotator {
+	return &Rotator{
+		config:   config,
+		interval: interval,
+		cache:    c,
+		log:      log,
+		keys:     make(map[int64][32]byte),
+		now:      time.Now,
+	}
+}
+
+// Attach loads the initial session ticket keys into the Rotator's tls.Config and
+// installs a GetConfigForClient hook that rotates the keys as client handshakes arrive
+func (r *Rotator) Attach() error {
+	if err := r.refresh(); err != nil {
+		return err
+	}
+	r.config.GetConfigForClient = r.getConfigForClient
+	return nil
+}
+
+func (r *Rotator) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if r.now().UnixNano() >= atomic.LoadInt64(&r.nextRefresh) {
+		if err := r.refresh(); err != nil {
+			// the previously-loaded keys remain in use, and the refresh is retried
+			// on the next handshake
+			r.log.Error("unable to rotate tls session ticket keys", tl.Pairs{"detail": err})
+		}
+	}
+	// returning a nil config tells crypto/tls to use the listener's config
+	return nil, nil
+}
+
+func (r *Rotator) refresh() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	if now.UnixNano() < atomic.LoadInt64(&r.nextRefresh) {
+		// another handshake refreshed the keys while this one waited on the lock
+		return nil
+	}
+
+	epoch := now.UnixNano() / int64(r.interval)
+	current, err := r.key(epoch, true)
+	if err != nil {
+		return err
+	}
+	keys := [][32]byte{current}
+	if prior, err := r.key(epoch-1, false); err == nil {
+		keys = append(keys, prior)
+	}
+	r.config.SetSessionTicketKeys(keys)
+
+	for e := range r.keys {
+		if e < epoch-1 {
+			delete(r.keys, e)
+		}
+	}
+
+	next := (epoch + 1) * int64(r.interval)
+	if r.cache != nil {
+		// re-read shared keys several times per interval so that replicas that raced
+		// to generate the same epoch's key converge on the one that was stored last
+		if n := now.UnixNano() + int64(r.interval/4); n < next {
+			next = n
+		}
+	}
+	atomic.StoreInt64(&r.nextRefresh, next)
+	return nil
+}
+
+// key returns the session ticket key for the provided epoch, preferring the shared
+// key in the cache over one held locally. When create is true and no key exists,
+// a new key is generated and, when a cache is configured, stored in the cache.
+func (r *Rotator) key(epoch int64, create bool) ([32]byte, error) {
+	var k [32]byte
+	ck := keyPrefix + strconv.FormatInt(int64(r.interval/time.Second), 10) +
+		"." + strconv.FormatInt(epoch, 10)
+	if r.cache != nil {
+		if b, _, err := r.cache.Retrieve(ck, false); err == nil && len(b) == len(k) {
+			copy(k[:], b)
+			r.keys[epoch] = k
+			return k, nil
+		}
+	}
+	if k, ok := r.keys[epoch]; ok {
+		return k, nil
+	}
+	if !create {
+		return k, cache.ErrKNF
+	}
+	if _, err := rand.Read(k[:]); err != nil {
+		return k, err
+	}
+	r.keys[epoch] = k
+	if r.cache != nil {
+		// the key must outlive the epoch following its own, during which it is used
+		// to decrypt tickets
+		if err := r.cache.Store(ck, k[:], 2*r.interval); err != nil {
+			r.log.Warn("unable to share tls session ticket key", tl.Pairs{"detail": err})
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tickets
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/options"
+	"github.com/tricksterproxy/trickster/pkg/cache/status"
+	"github.com/tricksterproxy/trickster/pkg/locks"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// testCache is a minimal map-backed cache.Cache for sharing keys between test Rotators
+type testCache map[string][]byte
+
+func (c testCache) Connect() error { return nil }
+func (c testCache) Store(k string, d []byte, ttl time.Duration) error {
+	c[k] = d
+	return nil
+}
+func (c testCache) Retrieve(k string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if d, ok := c[k]; ok {
+		return d, status.LookupStatusHit, nil
+	}
+	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+}
+func (c testCache) SetTTL(string, time.Duration)    {}
+func (c testCache) Remove(k string)                 { delete(c, k) }
+func (c testCache) BulkRemove([]string)             {}
+func (c testCache) Close() error                    { return nil }
+func (c testCache) Configuration() *options.Options { return nil }
+func (c testCache) Locker() locks.NamedLocker       { return nil }
+func (c testCache) SetLocker(locks.NamedLocker)     {}
+
+func testRotator(c cache.Cache, now *time.Time) *Rotator {
+	r := NewRotator(&tls.Config{}, time.Hour, c, tl.ConsoleLogger("error"))
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestRotatorLocal(t *testing.T) {
+
+	now := time.Unix(7200, 0)
+	r := testRotator(nil, &now)
+	if err := r.Attach(); err != nil {
+		t.Fatal(err)
+	}
+	if r.config.GetConfigForClient == nil {
+		t.Error("expected GetConfigForClient to be set")
+	}
+	if len(r.keys) != 1 {
+		t.Errorf("expected %d got %d", 1, len(r.keys))
+	}
+	k1 := r.keys[2]
+
+	// still in the same epoch, so the keys should not change
+	now = now.Add(30 * time.Minute)
+	r.getConfigForClient(nil)
+	if len(r.keys) != 1 || r.keys[2] != k1 {
+		t.Error("expected keys to be unchanged")
+	}
+
+	// the next epoch should generate a new key and retain the prior one
+	now = now.Add(time.Hour)
+	r.getConfigForClient(nil)
+	if len(r.keys) != 2 {
+		t.Errorf("expected %d got %d", 2, len(r.keys))
+	}
+	if r.keys[2] != k1 {
+		t.Error("expected prior key to be retained")
+	}
+	if r.keys[3] == k1 {
+		t.Error("expected a new key for the current epoch")
+	}
+
+	// two epochs later, the original key should be pruned
+	now = now.Add(2 * time.Hour)
+	r.getConfigForClient(nil)
+	if _, ok := r.keys[2]; ok {
+		t.Error("expected expired key to be pruned")
+	}
+
+}
+
+func TestRotatorShared(t *testing.T) {
+
+	now := time.Unix(7200, 0)
+	c := testCache{}
+
+	r1 := testRotator(c, &now)
+	if err := r1.Attach(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 1 {
+		t.Errorf("expected %d got %d", 1, len(c))
+	}
+
+	r2 := testRotator(c, &now)
+	if err := r2.Attach(); err != nil {
+		t.Fatal(err)
+	}
+	if r1.keys[2] != r2.keys[2] {
+		t.Error("expected replicas to share the session ticket key")
+	}
+
+	// simulate a replica that raced r1 and overwrote its key in the cache
+	for k := range c {
+		c[k] = make([]byte, 32)
+	}
+	now = now.Add(20 * time.Minute)
+	r1.getConfigForClient(nil)
+	if r1.keys[2] != [32]byte{} {
+		t.Error("expected rotator to converge on the cached key")
+	}
+
+}
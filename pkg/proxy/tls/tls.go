@@ -19,8 +19,8 @@ package tls
 
 import "github.com/tricksterproxy/trickster/pkg/config"
 
-// OptionsChanged will return true if the TLS options for any origin
-// is different between configs
+// OptionsChanged will return true if the TLS options for any origin,
+// or the ACME certificates, are different between configs
 func OptionsChanged(conf, oldConf *config.Config) bool {
 
 	if conf == nil {
@@ -30,6 +30,10 @@ func OptionsChanged(conf, oldConf *config.Config) bool {
 		return true
 	}
 
+	if !conf.ACME.Equal(oldConf.ACME) {
+		return true
+	}
+
 	for k, v := range oldConf.Origins {
 		if v.TLS != nil && v.TLS.ServeTLS {
 			if o, ok := conf.Origins[k]; !ok ||
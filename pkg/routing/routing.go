@@ -25,20 +25,38 @@ import (
 	"strings"
 
 	"github.com/tricksterproxy/trickster/pkg/cache"
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
+	"github.com/tricksterproxy/trickster/pkg/cluster"
+	co "github.com/tricksterproxy/trickster/pkg/cluster/options"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz"
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture"
+	"github.com/tricksterproxy/trickster/pkg/proxy/engines"
+	"github.com/tricksterproxy/trickster/pkg/proxy/errorbudget"
+	"github.com/tricksterproxy/trickster/pkg/proxy/heatmap"
+	"github.com/tricksterproxy/trickster/pkg/proxy/ingest"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
 	"github.com/tricksterproxy/trickster/pkg/proxy/methods"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/clickhouse"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/graphite"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/influxdb"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/irondb"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/opentsdb"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/piwebapi"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/prometheus"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/rule"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/trickster"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/types"
+	"github.com/tricksterproxy/trickster/pkg/proxy/origins/victoriametrics"
 	"github.com/tricksterproxy/trickster/pkg/proxy/paths/matching"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/replication"
 	"github.com/tricksterproxy/trickster/pkg/proxy/request/rewriter"
+	"github.com/tricksterproxy/trickster/pkg/proxy/retention"
+	"github.com/tricksterproxy/trickster/pkg/proxy/slo"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	"github.com/tricksterproxy/trickster/pkg/util/middleware"
@@ -70,6 +88,34 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 	var clients = origins.Origins{"frontend": tlo}
 	var err error
 
+	// every origin's requests are routed among the same cluster peers
+	var cl *cluster.Cluster
+	if conf.Cluster.Enabled() && !dryRun {
+		if conf.Resources != nil && conf.Resources.Cluster != nil {
+			// the routes are replacing those of a running config, which keeps its cluster
+			cl = conf.Resources.Cluster
+		} else {
+			cl = cluster.New(conf.Cluster,
+				strings.Replace(conf.Main.ClusterHandlerPath+"/gossip", "//", "/", -1))
+			log.Info("cluster peering enabled", tl.Pairs{"self": cl.Self(),
+				"discovery": conf.Cluster.Discovery(), "peers": strings.Join(cl.Peers(), ",")})
+		}
+		registerGossipRoute(router, cl, log)
+	}
+	if conf.Resources != nil {
+		conf.Resources.Cluster = cl
+	}
+
+	// the invalidation route is registered ahead of the origins' path routes, which may include
+	// '/', and the origins are registered with the invalidator once their clients are created
+	var iv *invalidation.Invalidator
+	if conf.Invalidation.Enabled() && !dryRun {
+		iv = invalidation.New(conf.Invalidation)
+		log.Debug("registering cache invalidation handler path",
+			tl.Pairs{"path": conf.Main.InvalidationHandlerPath})
+		router.Handle(conf.Main.InvalidationHandlerPath, iv.Handler()).Methods(http.MethodPost)
+	}
+
 	defaultOrigin := ""
 	var ndo *oo.Options // points to the origin config named "default"
 	var cdo *oo.Options // points to the origin config with IsDefault set to true
@@ -83,6 +129,8 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 					k, o.OriginType)
 		}
 
+		o.Cluster = cl
+
 		// Ensure only one default origin exists
 		if o.IsDefault {
 			if cdo != nil {
@@ -134,9 +182,47 @@ func RegisterProxyRoutes(conf *config.Config, router *mux.Router,
 		return nil, err
 	}
 
+	registerInvalidationOrigins(iv, conf, clients, caches, log)
+
 	return clients, nil
 }
 
+// RegisterPurgeRoute registers the cache purge handler of the provided origin clients with the
+// router, for serving on a listener that authenticates its clients
+func RegisterPurgeRoute(conf *config.Config, router *mux.Router, clients origins.Origins,
+	caches map[string]cache.Cache, log *tl.Logger) {
+	iv := invalidation.New(conf.Invalidation)
+	registerInvalidationOrigins(iv, conf, clients, caches, log)
+	log.Debug("registering cache purge handler path",
+		tl.Pairs{"path": conf.Main.PurgeHandlerPath})
+	router.Handle(conf.Main.PurgeHandlerPath, iv.PurgeHandler()).
+		Methods(http.MethodPost, http.MethodDelete)
+}
+
+// registerInvalidationOrigins registers each origin whose cached objects can be invalidated
+// through the invalidation webhook, if it is enabled
+func registerInvalidationOrigins(iv *invalidation.Invalidator, conf *config.Config,
+	clients origins.Origins, caches map[string]cache.Cache, log *tl.Logger) {
+	if iv == nil {
+		return
+	}
+	for k, client := range clients {
+		o, ok := conf.Origins[k]
+		if !ok {
+			continue
+		}
+		// rule origins route requests to other origins, and cache nothing themselves
+		if _, ok = client.(*rule.Client); ok {
+			continue
+		}
+		c, ok := caches[o.CacheName]
+		if !ok {
+			continue
+		}
+		iv.Register(k, o.OriginType, engines.InvalidationFunc(o, c, client, log))
+	}
+}
+
 // This ensures that rule clients are fully loaded, which can't be done
 // until all origins are processed, so the rule's destination origin names
 // can be mapped to their respective clients
@@ -185,10 +271,20 @@ func registerOriginRoutes(router *mux.Router, conf *config.Config, k string,
 		client, err = irondb.NewClient(k, o, mux.NewRouter(), c)
 	case "clickhouse":
 		client, err = clickhouse.NewClient(k, o, mux.NewRouter(), c)
+	case "piwebapi":
+		client, err = piwebapi.NewClient(k, o, mux.NewRouter(), c)
+	case "victoriametrics":
+		client, err = victoriametrics.NewClient(k, o, mux.NewRouter(), c)
+	case "graphite":
+		client, err = graphite.NewClient(k, o, mux.NewRouter(), c)
+	case "opentsdb":
+		client, err = opentsdb.NewClient(k, o, mux.NewRouter(), c)
 	case "rpc", "reverseproxycache":
 		client, err = reverseproxycache.NewClient(k, o, mux.NewRouter(), c)
 	case "rule":
 		client, err = rule.NewClient(k, o, mux.NewRouter(), clients)
+	case "trickster":
+		client, err = trickster.NewClient(k, o, mux.NewRouter(), c)
 	}
 	if err != nil {
 		return nil, err
@@ -196,14 +292,125 @@ func registerOriginRoutes(router *mux.Router, conf *config.Config, k string,
 
 	if client != nil && !dryRun {
 		o.HTTPClient = client.HTTPClient()
+		// runtime resources already present were carried over from the running config,
+		// whose routes are being replaced by a runtime rules update, and are kept
+		if o.ErrorBudget.Enabled() && o.Budget == nil {
+			o.Budget = errorbudget.New(k, o.OriginType, o.ErrorBudget)
+		}
+		if o.SLO.Enabled() && o.SLOTracker == nil {
+			o.SLOTracker = slo.New(k, o.OriginType, o.SLO)
+		}
+		if o.Heatmap.Enabled() && o.HeatmapRecorder == nil {
+			o.HeatmapRecorder = heatmap.New(k, o.OriginType, o.Heatmap)
+		}
+		if o.Retention.Enabled() && o.RetentionTrimmer == nil {
+			o.RetentionTrimmer = retention.New(k, o.OriginType, o.Retention)
+		}
+		if o.Replication.Enabled() && o.Replicator == nil {
+			o.Replicator = replication.New(k, o.OriginType, o.CacheKeyPrefix,
+				strings.Replace(conf.Main.ReplicationHandlerPath+"/"+k, "//", "/", -1), o.Replication)
+		}
+		if o.Ingest.Enabled() && o.Ingestor == nil {
+			o.Ingestor = ingest.New(k, o.OriginType, o.CacheKeyPrefix, o.Ingest)
+		}
+		if o.Capture.Enabled() {
+			if o.CaptureRecorder == nil {
+				o.CaptureRecorder, err = capture.New(k, o.Capture)
+				if err != nil {
+					return nil, err
+				}
+			}
+			o.HTTPClient.Transport = o.CaptureRecorder.Transport(o.HTTPClient.Transport)
+		}
 		clients[k] = client
 		defaultPaths := client.DefaultPathConfigs(o)
+		// the health and heatmap routes may be served on the metrics listener instead
+		healthHandlerPath, heatmapHandlerPath := conf.Main.HealthHandlerPath, conf.Main.HeatmapHandlerPath
+		if conf.Metrics != nil && conf.Metrics.FrontendAdminDisabled {
+			healthHandlerPath, heatmapHandlerPath = "", ""
+		}
+		// the replication and heatmap routes are registered ahead of the path routes, which
+		// may include '/'
+		registerReplicationRoute(router, client, o, c, log)
+		registerHeatmapRoute(router, o, heatmapHandlerPath, log)
 		registerPathRoutes(router, client.Handlers(), client, o, c, defaultPaths,
-			tracers, conf.Main.HealthHandlerPath, log)
+			tracers, healthHandlerPath, log)
 	}
 	return clients, nil
 }
 
+// RegisterHealthRoutes registers the health and heatmap routes of the provided origin clients
+// with the router, for serving on a listener other than the frontend listeners
+func RegisterHealthRoutes(conf *config.Config, router *mux.Router, clients origins.Origins,
+	tracers tracing.Tracers, log *tl.Logger) {
+	for k, o := range conf.Origins {
+		client, ok := clients[k]
+		if !ok {
+			continue
+		}
+		var tr *tracing.Tracer
+		if t, ok := tracers[o.TracingConfigName]; ok {
+			tr = t
+		}
+		registerHeatmapRoute(router, o, conf.Main.HeatmapHandlerPath, log)
+		registerHealthRoute(router, client.Handlers(), client, o, tr,
+			conf.Main.HealthHandlerPath, log)
+	}
+}
+
+// registerHealthRoute registers the origin's health handler, if it has one
+func registerHealthRoute(router *mux.Router, handlers map[string]http.Handler,
+	client origins.Client, oo *oo.Options, tr *tracing.Tracer,
+	healthHandlerPath string, log *tl.Logger) {
+	if h, ok := handlers["health"]; ok &&
+		oo.HealthCheckUpstreamPath != "" && oo.HealthCheckVerb != "" && healthHandlerPath != "" {
+		hp := strings.Replace(healthHandlerPath+"/"+oo.Name, "//", "/", -1)
+		log.Debug("registering health handler path",
+			tl.Pairs{"path": hp, "originName": oo.Name,
+				"upstreamPath": oo.HealthCheckUpstreamPath,
+				"upstreamVerb": oo.HealthCheckVerb})
+		router.PathPrefix(hp).
+			Handler(middleware.WithResourcesContext(client, oo, nil, nil, tr, log, h)).
+			Methods(methods.CacheableHTTPMethods()...)
+	}
+}
+
+// registerGossipRoute registers the handler through which the cluster peers exchange
+// membership, when the peers are discovered rather than configured statically
+func registerGossipRoute(router *mux.Router, cl *cluster.Cluster, log *tl.Logger) {
+	if cl.Discovery() == co.DiscoveryStatic {
+		return
+	}
+	log.Debug("registering cluster gossip handler path", tl.Pairs{"path": cl.GossipPath()})
+	router.Handle(cl.GossipPath(), cl.GossipHandler()).Methods(http.MethodPost)
+}
+
+// registerReplicationRoute registers the handler that merges the cached timeseries replicated
+// by the origin's peers, if the origin is replicated
+func registerReplicationRoute(router *mux.Router, client origins.Client, o *oo.Options,
+	c cache.Cache, log *tl.Logger) {
+	tc, ok := client.(origins.TimeseriesClient)
+	if !ok || o.Replicator == nil {
+		return
+	}
+	log.Debug("registering replication handler path",
+		tl.Pairs{"path": o.Replicator.Path(), "originName": o.Name})
+	router.Handle(o.Replicator.Path(),
+		o.Replicator.Handler(engines.ReplicationMergeFunc(o, c, tc, log))).Methods(http.MethodPost)
+}
+
+// registerHeatmapRoute registers the handler that serves the origin's latency heatmap samples,
+// if the origin is sampled
+func registerHeatmapRoute(router *mux.Router, o *oo.Options, heatmapHandlerPath string,
+	log *tl.Logger) {
+	if o.HeatmapRecorder == nil || heatmapHandlerPath == "" {
+		return
+	}
+	hp := strings.Replace(heatmapHandlerPath+"/"+o.Name, "//", "/", -1)
+	log.Debug("registering heatmap handler path", tl.Pairs{"path": hp, "originName": o.Name})
+	router.Handle(hp, o.HeatmapRecorder.Handler()).Methods(http.MethodGet)
+}
+
 // registerPathRoutes will take the provided default paths map,
 // merge it with any path data in the provided originconfig, and then register
 // the path routes to the appropriate handler from the provided handlers map
@@ -224,6 +431,18 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		}
 	}
 
+	// the external authorizer and its decision cache are shared by all of the origin's paths
+	var az *authz.Authorizer
+	if oo.Authorizer != nil {
+		az = authz.New(oo.Name, oo.Authorizer)
+	}
+
+	// the cache's warmer records the origin's popular queries, for replay after a restart
+	var warmer *warming.Warmer
+	if c != nil {
+		warmer = c.Configuration().Warmer
+	}
+
 	decorate := func(po *po.Options) http.Handler {
 		// default base route is the path handler
 		h := po.Handler
@@ -231,6 +450,8 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		if tr != nil {
 			h = middleware.Trace(tr, h)
 		}
+		// recover from any panic in the handler, within the request's context
+		h = middleware.Recover(oo, po.Path, log, h)
 		// add Origin, Cache, and Path Configs to the HTTP Request's context
 		h = middleware.WithResourcesContext(client, oo, c, po, tr, log, h)
 		// attach any request rewriters
@@ -240,25 +461,27 @@ func registerPathRoutes(router *mux.Router, handlers map[string]http.Handler,
 		if len(po.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(po.ReqRewriter, h)
 		}
+		// record authorized requests toward the cache's most requested queries
+		h = middleware.Warming(warmer, oo.Name, h)
+		// check the request with the origin's external authorizer
+		h = middleware.Authorize(az, log, h)
+		// record the client request as it was received, for later replay
+		h = middleware.Capture(oo.CaptureRecorder, oo.Name, log, h)
 		// decorate frontend prometheus metrics
 		if !po.NoMetrics {
 			h = middleware.Decorate(oo.Name, oo.OriginType, po.Path, h)
 		}
+		// gzip-encode responses for clients that accept it
+		h = middleware.Compress(oo.Compression, oo.CompressableTypes, h)
+		// attach the origin's security response headers profile
+		if oo.SecurityHeaders != nil {
+			h = middleware.SecurityHeaders(oo.SecurityHeaders, h)
+		}
 		return h
 	}
 
 	// now we'll go ahead and register the health handler
-	if h, ok := handlers["health"]; ok &&
-		oo.HealthCheckUpstreamPath != "" && oo.HealthCheckVerb != "" && healthHandlerPath != "" {
-		hp := strings.Replace(healthHandlerPath+"/"+oo.Name, "//", "/", -1)
-		log.Debug("registering health handler path",
-			tl.Pairs{"path": hp, "originName": oo.Name,
-				"upstreamPath": oo.HealthCheckUpstreamPath,
-				"upstreamVerb": oo.HealthCheckVerb})
-		router.PathPrefix(hp).
-			Handler(middleware.WithResourcesContext(client, oo, nil, nil, tr, log, h)).
-			Methods(methods.CacheableHTTPMethods()...)
-	}
+	registerHealthRoute(router, handlers, client, oo, tr, healthHandlerPath, log)
 
 	// This takes the default paths, named like '/api/v1/query' and morphs the name
 	// into what the router wants, with methods like '/api/v1/query-GET-HEAD', to help
@@ -17,21 +17,31 @@
 package routing
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/cache/registration"
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
 	"github.com/tricksterproxy/trickster/pkg/config"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/proxy/invalidation"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins"
 	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/reverseproxycache"
 	"github.com/tricksterproxy/trickster/pkg/proxy/origins/rule"
 	po "github.com/tricksterproxy/trickster/pkg/proxy/paths/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
 	"github.com/tricksterproxy/trickster/pkg/tracing"
 	"github.com/tricksterproxy/trickster/pkg/tracing/exporters/zipkin"
 	to "github.com/tricksterproxy/trickster/pkg/tracing/options"
+	"github.com/tricksterproxy/trickster/pkg/util/compress/gzip"
 	tl "github.com/tricksterproxy/trickster/pkg/util/log"
 	tlstest "github.com/tricksterproxy/trickster/pkg/util/testing/tls"
 
@@ -186,6 +196,55 @@ func TestRegisterProxyRoutesClickHouse(t *testing.T) {
 
 }
 
+func TestRegisterProxyRoutesPIWebAPI(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1/piwebapi", "-origin-type", "piwebapi"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := proxyClients["default"].(origins.TimeseriesClient); !ok {
+		t.Error("expected timeseries client")
+	}
+
+}
+
+func TestRegisterProxyRoutesTrickster(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "trickster"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Origins["default"].UpstreamType = "prometheus"
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	proxyClients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := proxyClients["default"].(origins.TimeseriesClient); !ok {
+		t.Error("expected timeseries client")
+	}
+
+	conf.Origins["default"].UpstreamType = "invalid"
+	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("info"), false)
+	if err == nil {
+		t.Error("expected error for invalid upstream type")
+	}
+
+}
+
 func TestRegisterProxyRoutesIRONdb(t *testing.T) {
 
 	conf, _, err := config.Load("trickster", "test",
@@ -385,3 +444,527 @@ func TestValidateRuleClients(t *testing.T) {
 	}
 
 }
+
+func TestRegisterPathRoutesRecoversPanics(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oo := conf.Origins["default"]
+	rpc, _ := reverseproxycache.NewClient("test", oo, mux.NewRouter(), nil)
+	dpc := rpc.DefaultPathConfigs(oo)
+	handlers := map[string]http.Handler{"proxycache": http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("test panic")
+	})}
+
+	router := mux.NewRouter()
+	registerPathRoutes(router, handlers, rpc, oo, nil, dpc, nil, "", tl.ConsoleLogger("error"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://trickster/default/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, w.Code)
+	}
+	if w.Header().Get(headers.NameTricksterErrorID) == "" {
+		t.Error("expected error id header")
+	}
+
+	// a quarantined cache key is removed from the cache
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	c := caches["default"]
+	c.Store("test-key", []byte("malformed"), time.Minute)
+	oo.QuarantinePanicCacheKeys = true
+	handlers["proxycache"] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request.GetResources(r).Canonical["cacheKey"] = "test-key"
+		panic("test panic")
+	})
+	router = mux.NewRouter()
+	registerPathRoutes(router, handlers, rpc, oo, c, dpc, nil, "", tl.ConsoleLogger("error"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://trickster/default/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d got %d", http.StatusInternalServerError, w.Code)
+	}
+	if _, _, err := c.Retrieve("test-key", false); err == nil {
+		t.Error("expected quarantined cache key to be removed")
+	}
+
+	oo.PanicRecoveryDisabled = true
+	router = mux.NewRouter()
+	registerPathRoutes(router, handlers, rpc, oo, nil, dpc, nil, "", tl.ConsoleLogger("error"))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic when recovery is disabled")
+			}
+		}()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://trickster/default/", nil))
+	}()
+
+}
+
+func TestRegisterPathRoutesCompresses(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oo := conf.Origins["default"]
+	oo.Compression.Enabled = true
+	oo.Compression.ParallelMinSizeBytes = 1 << 20
+	rpc, _ := reverseproxycache.NewClient("test", oo, mux.NewRouter(), nil)
+	dpc := rpc.DefaultPathConfigs(oo)
+
+	var body []byte
+	var flush bool
+	handlers := map[string]http.Handler{"proxycache": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameETag, `"test-etag"`)
+		w.Write(body[:len(body)/2])
+		if flush {
+			w.(http.Flusher).Flush()
+		}
+		w.Write(body[len(body)/2:])
+	})}
+
+	router := mux.NewRouter()
+	registerPathRoutes(router, handlers, rpc, oo, nil, dpc, nil, "", tl.ConsoleLogger("error"))
+
+	tests := []struct {
+		size           int
+		acceptEncoding string
+		flush          bool
+		expectGzip     bool
+	}{
+		{4096, "gzip, deflate", false, true},
+		{2 << 20, "gzip", false, true},
+		{4096, "", false, false},
+		{4096, "gzip;q=0", false, false},
+		{100, "gzip", false, false},
+		{4096, "gzip", true, false},
+	}
+
+	for i, test := range tests {
+		body = bytes.Repeat([]byte(`{"value":"1"},`), test.size/14)
+		flush = test.flush
+		r := httptest.NewRequest(http.MethodGet, "http://trickster/default/", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set(headers.NameAcceptEncoding, test.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Header().Get(headers.NameVary) != headers.NameAcceptEncoding {
+			t.Errorf("test %d: expected Vary header %s got %s", i, headers.NameAcceptEncoding,
+				w.Header().Get(headers.NameVary))
+		}
+
+		b := w.Body.Bytes()
+		if !test.expectGzip {
+			if w.Header().Get(headers.NameContentEncoding) != "" {
+				t.Errorf("test %d: expected no content encoding", i)
+			}
+			if !bytes.Equal(b, body) {
+				t.Errorf("test %d: body mismatch", i)
+			}
+			continue
+		}
+
+		if w.Header().Get(headers.NameContentEncoding) != headers.ValueGzip {
+			t.Errorf("test %d: expected content encoding %s got %s", i, headers.ValueGzip,
+				w.Header().Get(headers.NameContentEncoding))
+		}
+		if w.Header().Get(headers.NameETag) != `W/"test-etag"` {
+			t.Errorf("test %d: expected weak etag got %s", i, w.Header().Get(headers.NameETag))
+		}
+		b, err = gzip.Inflate(b)
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+		}
+		if !bytes.Equal(b, body) {
+			t.Errorf("test %d: inflated body mismatch", i)
+		}
+	}
+
+}
+
+func TestRegisterProxyRoutesReplication(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Origins["default"].Replication.Peers = []string{"http://127.0.0.1:8480"}
+	conf.Origins["default"].Replication.SharedSecret = "test"
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Origins["default"].Replicator == nil {
+		t.Fatal("expected replicator")
+	}
+
+	// the replication route is matched ahead of the default origin's paths
+	r := httptest.NewRequest(http.MethodPost, "http://trickster/trickster/replication/default", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d got %d", http.StatusUnauthorized, w.Code)
+	}
+
+}
+
+func TestRegisterProxyRoutesHeatmap(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Origins["default"].HeatmapRecorder == nil {
+		t.Fatal("expected heatmap recorder")
+	}
+
+	// the heatmap route is matched ahead of the default origin's paths
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/trickster/heatmap/default", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if !strings.HasPrefix(w.Body.String(), `{"origin":"default"`) {
+		t.Errorf("unexpected heatmap document %s", w.Body.String())
+	}
+
+}
+
+func TestRegisterHealthRoutes(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Metrics.FrontendAdminDisabled = true
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	clients, err := RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the health and heatmap routes are not registered with the frontend router
+	var rm mux.RouteMatch
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/trickster/health/default", nil)
+	if router.Match(r, &rm) && strings.HasPrefix(pathTemplate(rm.Route), "/trickster/health") {
+		t.Error("unexpected health route on frontend router")
+	}
+
+	ar := mux.NewRouter()
+	RegisterHealthRoutes(conf, ar, clients, nil, tl.ConsoleLogger("error"))
+
+	rm = mux.RouteMatch{}
+	if !ar.Match(r, &rm) || pathTemplate(rm.Route) != "/trickster/health/default" {
+		t.Error("expected health route")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "http://trickster/trickster/heatmap/default", nil)
+	w := httptest.NewRecorder()
+	ar.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+}
+
+func pathTemplate(r *mux.Route) string {
+	if r == nil {
+		return ""
+	}
+	s, _ := r.GetPathTemplate()
+	return s
+}
+
+func TestRegisterProxyRoutesIngest(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Origins["default"].Ingest.Source = "mqtt"
+	conf.Origins["default"].Ingest.Brokers = []string{"127.0.0.1:1883"}
+	conf.Origins["default"].Ingest.Topic = "test"
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Origins["default"].Ingestor == nil {
+		t.Error("expected ingestor")
+	}
+
+}
+
+func TestRegisterProxyRoutesInvalidation(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Invalidation.SharedSecret = "test"
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"origin":"default"}`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, conf.Main.InvalidationHandlerPath, bytes.NewReader(body))
+	r.Header.Set(headers.NameTricksterTimestamp, ts)
+	r.Header.Set(headers.NameTricksterSignature, invalidation.Sign("test", ts, body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	expected := `{"origin":"default","invalidated":0,"mode":"hard"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+}
+
+func TestRegisterPurgeRoute(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	clients, err := RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil,
+		tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	RegisterPurgeRoute(conf, router, clients, caches, tl.ConsoleLogger("error"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+		conf.Main.PurgeHandlerPath+"?origin=default&prefix=.dpc.", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	expected := `{"origin":"default","invalidated":0,"mode":"hard"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %s got %s", expected, w.Body.String())
+	}
+
+	// purges are not accepted by GET, so they can't be triggered by prefetching or crawling
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		conf.Main.PurgeHandlerPath+"?origin=default", nil))
+	if w.Code == http.StatusOK {
+		t.Errorf("expected non-%d got %d", http.StatusOK, w.Code)
+	}
+
+}
+
+func TestRegisterProxyRoutesCluster(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Cluster.Self = "http://trickster-0:8480"
+	conf.Cluster.Peers = []string{"http://trickster-0:8480", "http://trickster-1:8480"}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl := conf.Origins["default"].Cluster
+	if cl == nil {
+		t.Fatal("expected cluster")
+	}
+	if cl.Self() != "http://trickster-0:8480" {
+		t.Errorf("expected %s got %s", "http://trickster-0:8480", cl.Self())
+	}
+	if conf.Resources.Cluster != cl {
+		t.Error("expected cluster in config resources")
+	}
+
+}
+
+func TestRegisterProxyRoutesClusterGossip(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Cluster.Self = "http://trickster-0:8480"
+	conf.Cluster.Seeds = []string{"http://trickster-1:8480"}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(conf, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the gossip handler is routed ahead of the default origin
+	r := httptest.NewRequest(http.MethodPost, "http://0/trickster/cluster/gossip",
+		bytes.NewReader([]byte(`{"from":"http://trickster-1:8480"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if p := conf.Resources.Cluster.Peers(); len(p) != 2 {
+		t.Errorf("unexpected peers %v", p)
+	}
+
+}
+
+func TestRegisterProxyRoutesRuntimeRules(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Cluster.Self = "http://trickster-0:8480"
+	conf.Cluster.Peers = []string{"http://trickster-0:8480", "http://trickster-1:8480"}
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	_, err = RegisterProxyRoutes(conf, mux.NewRouter(), caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nc, err := conf.ApplyRuntimeRules(`
+[origins.default.paths]
+  [origins.default.paths.blocked]
+  path = '/blocked'
+  handler = 'localresponse'
+  response_code = 429
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(nc, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the running config's runtime resources are kept by the updated routes
+	if conf.Origins["default"].HeatmapRecorder == nil {
+		t.Fatal("expected heatmap recorder")
+	}
+	if nc.Origins["default"].HeatmapRecorder != conf.Origins["default"].HeatmapRecorder {
+		t.Error("expected heatmap recorder to be carried over")
+	}
+	if nc.Origins["default"].Cluster != conf.Origins["default"].Cluster {
+		t.Error("expected cluster to be carried over")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/blocked", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected %d got %d", http.StatusTooManyRequests, w.Code)
+	}
+
+}
+
+func TestRegisterProxyRoutesWarming(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-origin-type", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Caches["default"].Warming.TopN = 5
+
+	caches := registration.LoadCachesFromConfig(conf, tl.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+
+	nc, err := conf.ApplyRuntimeRules(`
+[origins.default.paths]
+  [origins.default.paths.blocked]
+  path = '/blocked'
+  handler = 'localresponse'
+  response_code = 429
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	_, err = RegisterProxyRoutes(nc, router, caches, nil, tl.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://trickster/default/blocked?x=1", nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	// replays and non-GET requests are not recorded
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/default/blocked?x=2", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r.WithContext(warming.WithReplay(r.Context())))
+	r = httptest.NewRequest(http.MethodPost, "http://trickster/default/blocked?x=3", nil)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	top := caches["default"].Configuration().Warmer.Top()
+	if len(top) != 1 || top[0].Origin != "default" || top[0].URI != "/blocked?x=1" ||
+		top[0].Hits != 2 {
+		t.Errorf("unexpected recorded queries %v", top)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeseries
+
+import "time"
+
+// Gap fill modes, which determine how the missing steps between the values of a Series are
+// filled in a response
+const (
+	// GapFillNone leaves the missing steps empty
+	GapFillNone = "none"
+	// GapFillNull fills the missing steps with null values
+	GapFillNull = "null"
+	// GapFillCarryForward fills the missing steps with the value that precedes them
+	GapFillCarryForward = "carry_forward"
+)
+
+// GapFillModes is the set of supported gap fill mode names
+var GapFillModes = map[string]bool{
+	GapFillNone:         true,
+	GapFillNull:         true,
+	GapFillCarryForward: true,
+}
+
+// GapFiller is implemented by Timeseries that can fill the missing steps between the values
+// of their Series. Steps before the first value and after the last value of a Series are not
+// filled, since the Series may not exist at those times
+type GapFiller interface {
+	// FillGaps fills the missing steps of each Series per the mode. With GapFillCarryForward,
+	// a value is carried forward to the steps no more than maxInterval after it, or to all
+	// missing steps when maxInterval is 0. It returns the number of values that were added
+	FillGaps(mode string, step, maxInterval time.Duration) int
+}
+
+// GapTimes returns the step-aligned times that are missing between t1 and t2, which are
+// to be filled per the mode and maxInterval
+func GapTimes(t1, t2 time.Time, step, maxInterval time.Duration, mode string) []time.Time {
+	if step <= 0 || mode == GapFillNone || !t2.After(t1.Add(step)) {
+		return nil
+	}
+	var out []time.Time
+	for t := t1.Add(step); t.Before(t2); t = t.Add(step) {
+		if mode == GapFillCarryForward && maxInterval > 0 && t.Sub(t1) > maxInterval {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeseries
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGapTimes(t *testing.T) {
+
+	t1 := time.Unix(0, 0)
+	step := 10 * time.Second
+
+	tests := []struct {
+		t2          time.Time
+		maxInterval time.Duration
+		mode        string
+		expected    int
+	}{
+		{t1.Add(50 * time.Second), 0, GapFillNone, 0},
+		{t1.Add(50 * time.Second), 0, GapFillNull, 4},
+		{t1.Add(10 * time.Second), 0, GapFillNull, 0},
+		{t1.Add(50 * time.Second), 0, GapFillCarryForward, 4},
+		{t1.Add(50 * time.Second), 20 * time.Second, GapFillCarryForward, 2},
+		// maxInterval only limits carrying values forward
+		{t1.Add(50 * time.Second), 20 * time.Second, GapFillNull, 4},
+	}
+
+	for i, test := range tests {
+		out := GapTimes(t1, test.t2, step, test.maxInterval, test.mode)
+		if len(out) != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, len(out))
+			continue
+		}
+		for j, v := range out {
+			if !v.Equal(t1.Add(time.Duration(j+1) * step)) {
+				t.Errorf("test %d: unexpected time %s", i, v)
+			}
+		}
+	}
+
+	if out := GapTimes(t1, t1.Add(time.Minute), 0, 0, GapFillNull); out != nil {
+		t.Errorf("expected nil got %v", out)
+	}
+
+}
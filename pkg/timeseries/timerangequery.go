@@ -44,6 +44,12 @@ type TimeRangeQuery struct {
 	IsOffset bool
 	// BackfillTolerance can be updated to override the overall backfill tolerance per query
 	BackfillTolerance time.Duration
+	// ExtentPadding is the amount of already-cached time preceding an uncached extent that is
+	// re-fetched along with it, so that points computed from incomplete data at the seam are replaced
+	ExtentPadding time.Duration
+	// AlignToEpoch aligns the Extent to multiples of the Step since the Unix epoch, rather than
+	// since the zero time, for origins that align range query results to the epoch themselves
+	AlignToEpoch bool
 }
 
 // Clone returns an exact copy of a TimeRangeQuery
@@ -55,6 +61,8 @@ func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 		IsOffset:           trq.IsOffset,
 		TimestampFieldName: trq.TimestampFieldName,
 		FastForwardDisable: trq.FastForwardDisable,
+		ExtentPadding:      trq.ExtentPadding,
+		AlignToEpoch:       trq.AlignToEpoch,
 	}
 
 	if trq.TemplateURL != nil {
@@ -70,11 +78,40 @@ func (trq *TimeRangeQuery) NormalizeExtent() {
 		if !trq.IsOffset && trq.Extent.End.After(time.Now()) {
 			trq.Extent.End = time.Now()
 		}
-		trq.Extent.Start = trq.Extent.Start.Truncate(trq.Step)
-		trq.Extent.End = trq.Extent.End.Truncate(trq.Step)
+		trq.Extent.Start = trq.TruncateToStep(trq.Extent.Start)
+		trq.Extent.End = trq.TruncateToStep(trq.Extent.End)
 	}
 }
 
+// TruncateToStep returns the result of rounding t down to a multiple of the Step
+func (trq *TimeRangeQuery) TruncateToStep(t time.Time) time.Time {
+	if trq.Step <= 0 {
+		return t
+	}
+	if trq.AlignToEpoch {
+		return time.Unix(0, t.UnixNano()-t.UnixNano()%int64(trq.Step)).In(t.Location())
+	}
+	return t.Truncate(trq.Step)
+}
+
+// PadExtent returns a copy of the provided extent with its Start moved earlier by the
+// ExtentPadding, aligned to the Step. The padded Start never precedes the query's Extent,
+// and extents beginning at the query's Start are not padded, since there is nothing
+// cached in the query range before them
+func (trq *TimeRangeQuery) PadExtent(e Extent) Extent {
+	if trq.ExtentPadding <= 0 || !e.Start.After(trq.Extent.Start) {
+		return e
+	}
+	start := e.Start.Add(-trq.ExtentPadding)
+	if trq.Step > 0 {
+		start = trq.TruncateToStep(start)
+	}
+	if start.Before(trq.Extent.Start) {
+		start = trq.Extent.Start
+	}
+	return Extent{Start: start, End: e.End, LastUsed: e.LastUsed}
+}
+
 // CalculateDeltas provides a list of extents that are not in a cached timeseries,
 // when provided a list of extents that are cached.
 func (trq *TimeRangeQuery) CalculateDeltas(have ExtentList) ExtentList {
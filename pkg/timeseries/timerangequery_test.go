@@ -142,6 +142,58 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestTruncateToStep(t *testing.T) {
+
+	ts := time.Unix(1000000, 0)
+	trq := &TimeRangeQuery{Step: 7 * time.Minute}
+
+	// the zero time is not a multiple of 7m from the Unix epoch
+	if got := trq.TruncateToStep(ts); !got.Equal(ts.Truncate(trq.Step)) {
+		t.Errorf("expected %s got %s", ts.Truncate(trq.Step), got)
+	}
+
+	trq.AlignToEpoch = true
+	if got := trq.TruncateToStep(ts); got.Unix() != 999600 {
+		t.Errorf("expected %d got %d", 999600, got.Unix())
+	}
+
+	trq.Step = 0
+	if got := trq.TruncateToStep(ts); !got.Equal(ts) {
+		t.Errorf("expected %s got %s", ts, got)
+	}
+}
+
+func TestPadExtent(t *testing.T) {
+
+	trq := &TimeRangeQuery{Extent: Extent{Start: time.Unix(0, 0), End: time.Unix(3600, 0)},
+		Step: time.Duration(60) * time.Second}
+
+	tests := []struct {
+		padding       time.Duration
+		start, end    int64
+		expectedStart int64
+	}{
+		{0, 1800, 3600, 1800},
+		{300 * time.Second, 0, 3600, 0},
+		{300 * time.Second, 1800, 3600, 1500},
+		{290 * time.Second, 1800, 3600, 1500},
+		{300 * time.Second, 120, 3600, 0},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq.ExtentPadding = test.padding
+			e := trq.PadExtent(Extent{Start: time.Unix(test.start, 0), End: time.Unix(test.end, 0)})
+			if e.Start.Unix() != test.expectedStart {
+				t.Errorf("expected %d got %d", test.expectedStart, e.Start.Unix())
+			}
+			if e.End.Unix() != test.end {
+				t.Errorf("expected %d got %d", test.end, e.End.Unix())
+			}
+		})
+	}
+}
+
 func TestStringTRQ(t *testing.T) {
 
 	const expected = `{ "statement": "1234", "step": "5s", "extent": "5-10" }`
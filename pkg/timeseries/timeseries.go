@@ -55,3 +55,10 @@ type Timeseries interface {
 	// Size returns the approximate memory byte size of the timeseries object
 	Size() int
 }
+
+// Labeler is implemented by Timeseries whose individual Series are identified by a set of
+// labels, so that cached Timeseries can be selected by label
+type Labeler interface {
+	// SeriesLabels returns the label set of each Series in the Timeseries
+	SeriesLabels() []map[string]string
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package buffers provides size-classed pools of reusable byte buffers, so that
+// large response bodies can be read and serialized without allocating new
+// multi-megabyte slices for every request
+package buffers
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"sync"
+)
+
+const (
+	// minClassBits is the log2 of the smallest size class (4KB)
+	minClassBits = 12
+	// maxClassBits is the log2 of the largest size class (64MB). Larger buffers are not pooled
+	maxClassBits = 26
+)
+
+var pools [maxClassBits - minClassBits + 1]sync.Pool
+
+// classFor returns the index of the smallest size class that can hold n bytes,
+// or -1 if n is larger than the largest size class
+func classFor(n int) int {
+	if n <= 1<<minClassBits {
+		return 0
+	}
+	c := bits.Len(uint(n-1)) - minClassBits
+	if c > maxClassBits-minClassBits {
+		return -1
+	}
+	return c
+}
+
+// Get returns an empty buffer from the pool, with a capacity of at least sizeHint bytes.
+// The buffer should be returned with Put once its contents are no longer referenced
+func Get(sizeHint int) *bytes.Buffer {
+	c := classFor(sizeHint)
+	if c < 0 {
+		return bytes.NewBuffer(make([]byte, 0, sizeHint))
+	}
+	if b, ok := pools[c].Get().(*bytes.Buffer); ok {
+		return b
+	}
+	return bytes.NewBuffer(make([]byte, 0, 1<<(c+minClassBits)))
+}
+
+// Put resets the buffer and returns it to the pool for its capacity. Neither the buffer
+// nor any slice of its contents may be used after it is returned
+func Put(b *bytes.Buffer) {
+	if b == nil {
+		return
+	}
+	cp := b.Cap()
+	if cp < 1<<minClassBits {
+		return
+	}
+	// a buffer is pooled in the largest class it fully satisfies
+	c := bits.Len(uint(cp)) - 1 - minClassBits
+	if c > maxClassBits-minClassBits {
+		return
+	}
+	b.Reset()
+	pools[c].Put(b)
+}
+
+// ReadAll reads from r until EOF into a pooled buffer, which is sized by sizeHint,
+// such as a response's Content-Length, when it is greater than 0
+func ReadAll(r io.Reader, sizeHint int64) (*bytes.Buffer, error) {
+	var n int
+	if sizeHint > 0 && sizeHint < 1<<maxClassBits {
+		// spare capacity lets ReadFrom detect EOF without growing the buffer
+		n = int(sizeHint) + bytes.MinRead
+	}
+	b := Get(n)
+	_, err := b.ReadFrom(r)
+	return b, err
+}
+
+// Bytes returns a copy of the buffer's contents in a slice of exactly their length,
+// and returns the buffer to the pool
+func Bytes(b *bytes.Buffer) []byte {
+	out := make([]byte, b.Len())
+	copy(out, b.Bytes())
+	Put(b)
+	return out
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package buffers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassFor(t *testing.T) {
+
+	tests := []struct {
+		n, expected int
+	}{
+		{0, 0},
+		{1, 0},
+		{4096, 0},
+		{4097, 1},
+		{8192, 1},
+		{1 << 20, 8},
+		{1 << maxClassBits, maxClassBits - minClassBits},
+		{1<<maxClassBits + 1, -1},
+	}
+
+	for i, test := range tests {
+		if c := classFor(test.n); c != test.expected {
+			t.Errorf("test %d: expected %d got %d", i, test.expected, c)
+		}
+	}
+
+}
+
+func TestGetPut(t *testing.T) {
+
+	b := Get(5000)
+	if b.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, b.Len())
+	}
+	if b.Cap() < 5000 {
+		t.Errorf("expected capacity of at least %d got %d", 5000, b.Cap())
+	}
+
+	b.WriteString("trickster")
+	Put(b)
+	if b.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, b.Len())
+	}
+
+	b = Get(1<<maxClassBits + 1)
+	if b.Cap() < 1<<maxClassBits+1 {
+		t.Errorf("expected capacity of at least %d got %d", 1<<maxClassBits+1, b.Cap())
+	}
+
+	// these should not panic
+	Put(b)
+	Put(nil)
+	Put(bytes.NewBuffer(make([]byte, 0, 16)))
+
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("test error")
+}
+
+func TestReadAll(t *testing.T) {
+
+	const expected = "trickster"
+
+	b, err := ReadAll(strings.NewReader(expected), int64(len(expected)))
+	if err != nil {
+		t.Error(err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected %s got %s", expected, b.String())
+	}
+	Put(b)
+
+	b, err = ReadAll(strings.NewReader(expected), -1)
+	if err != nil {
+		t.Error(err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected %s got %s", expected, b.String())
+	}
+	Put(b)
+
+	_, err = ReadAll(errReader{}, 0)
+	if err == nil {
+		t.Error("expected error")
+	}
+
+}
+
+func TestBytes(t *testing.T) {
+
+	const expected = "trickster"
+
+	b := Get(len(expected))
+	b.WriteString(expected)
+
+	out := Bytes(b)
+	if string(out) != expected {
+		t.Errorf("expected %s got %s", expected, string(out))
+	}
+	if cap(out) != len(expected) {
+		t.Errorf("expected %d got %d", len(expected), cap(out))
+	}
+
+	// the returned slice must not share memory with the pooled buffer
+	b = Get(len(expected))
+	b.WriteString("xxxxxxxxx")
+	if string(out) != expected {
+		t.Errorf("expected %s got %s", expected, string(out))
+	}
+
+}
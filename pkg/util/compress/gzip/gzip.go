@@ -19,8 +19,25 @@ package gzip
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
+	"runtime"
+	"sync"
+
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
+)
+
+// blockSize is the size of the blocks of input that are compressed concurrently by DeflateParallel
+const blockSize = 1 << 18
+
+var (
+	gzipWriters  [gzip.BestCompression + 1]sync.Pool
+	flateWriters [flate.BestCompression + 1]sync.Pool
 )
 
 // Inflate returns the inflated version of a gzip-deflated byte slice
@@ -31,3 +48,112 @@ func Inflate(in []byte) ([]byte, error) {
 	}
 	return ioutil.ReadAll(gr)
 }
+
+// Deflate writes the gzip-deflated version of in to w, using a pooled encoder of the provided
+// level, which must be between gzip.BestSpeed and gzip.BestCompression
+func Deflate(w io.Writer, in []byte, level int) error {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+	gw, ok := gzipWriters[level].Get().(*gzip.Writer)
+	if ok {
+		gw.Reset(w)
+	} else {
+		gw, _ = gzip.NewWriterLevel(w, level)
+	}
+	defer gzipWriters[level].Put(gw)
+	if _, err := gw.Write(in); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// DeflateParallel writes the gzip-deflated version of in to w, like Deflate, but compresses
+// the input in blocks on multiple CPUs when it is larger than a single block. Since each block
+// is compressed independently, the output is slightly larger than that of Deflate
+func DeflateParallel(w io.Writer, in []byte, level int) error {
+	n := (len(in) + blockSize - 1) / blockSize
+	if n < 2 || runtime.GOMAXPROCS(0) < 2 {
+		return Deflate(w, in, level)
+	}
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+
+	blocks := make([]*bytes.Buffer, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	var crc uint32
+
+	wg.Add(n + 1)
+	go func() {
+		crc = crc32.ChecksumIEEE(in)
+		wg.Done()
+	}()
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := i * blockSize
+			end := start + blockSize
+			if end > len(in) {
+				end = len(in)
+			}
+			blocks[i] = buffers.Get(end - start)
+			fw, ok := flateWriters[level].Get().(*flate.Writer)
+			if ok {
+				fw.Reset(blocks[i])
+			} else {
+				fw, _ = flate.NewWriter(blocks[i], level)
+			}
+			defer flateWriters[level].Put(fw)
+			if _, errs[i] = fw.Write(in[start:end]); errs[i] != nil {
+				return
+			}
+			// all but the last block end with a sync flush rather than a final block,
+			// so that the concatenated blocks form a single deflate stream
+			if i < n-1 {
+				errs[i] = fw.Flush()
+			} else {
+				errs[i] = fw.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		for _, b := range blocks {
+			buffers.Put(b)
+		}
+	}()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// the header and trailer are those written by gzip.Writer, as described in RFC 1952
+	header := [10]byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+	switch level {
+	case gzip.BestCompression:
+		header[8] = 2
+	case gzip.BestSpeed:
+		header[8] = 4
+	}
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		if _, err := w.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], crc)
+	binary.LittleEndian.PutUint32(trailer[4:], uint32(len(in)))
+	_, err := w.Write(trailer[:])
+	return err
+}
@@ -17,10 +17,22 @@
 package gzip
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
+	"runtime"
 	"testing"
 )
 
+// testBody returns a compressable body of approximately n bytes
+func testBody(n int) []byte {
+	b := &bytes.Buffer{}
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(b, `[%d,"%d.%d"],`, 1577836800+i*15, i%97, i%13)
+	}
+	return b.Bytes()
+}
+
 func TestInflate(t *testing.T) {
 	const expected = "this is the inflated text string"
 	c, err := ioutil.ReadFile("../../../../testdata/gzip_test.txt.gz")
@@ -41,3 +53,62 @@ func TestInflate(t *testing.T) {
 	}
 
 }
+
+func TestDeflate(t *testing.T) {
+
+	// ensures the parallel path is exercised on single-CPU hosts
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for _, size := range []int{0, 100, blockSize*5 + 17} {
+		in := testBody(size)
+		for _, parallel := range []bool{false, true} {
+			for _, level := range []int{1, 6, 9} {
+				w := &bytes.Buffer{}
+				var err error
+				if parallel {
+					err = DeflateParallel(w, in, level)
+				} else {
+					err = Deflate(w, in, level)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, err := Inflate(w.Bytes())
+				if err != nil {
+					t.Fatalf("size %d parallel %t level %d: %s", size, parallel, level, err)
+				}
+				if !bytes.Equal(in, out) {
+					t.Errorf("size %d parallel %t level %d: inflated body does not match", size, parallel, level)
+				}
+			}
+		}
+	}
+
+	if err := Deflate(&bytes.Buffer{}, nil, 10); err == nil {
+		t.Error("expected error for invalid level")
+	}
+
+	if err := DeflateParallel(&bytes.Buffer{}, testBody(blockSize*2), 0); err == nil {
+		t.Error("expected error for invalid level")
+	}
+
+}
+
+func BenchmarkDeflate(b *testing.B) {
+	in := testBody(8 << 20)
+	for _, parallel := range []bool{false, true} {
+		b.Run(fmt.Sprintf("parallel=%t", parallel), func(b *testing.B) {
+			b.SetBytes(int64(len(in)))
+			b.ReportAllocs()
+			w := &bytes.Buffer{}
+			for i := 0; i < b.N; i++ {
+				w.Reset()
+				if parallel {
+					DeflateParallel(w, in, 6)
+				} else {
+					Deflate(w, in, 6)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the response compression options for an origin
+package options
+
+import (
+	"compress/gzip"
+	"errors"
+
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
+)
+
+// Options configures the gzip encoding of an origin's responses to clients
+type Options struct {
+	// Enabled, when true, gzip-encodes responses of a compressable content type
+	// to clients that accept it
+	Enabled bool `toml:"enabled"`
+	// Level is the gzip compression level, from 1 (fastest) to 9 (smallest)
+	Level int `toml:"level"`
+	// MinSizeBytes is the minimum size of a response body for it to be compressed
+	MinSizeBytes int `toml:"min_size_bytes"`
+	// ParallelMinSizeBytes is the minimum size of a response body for it to be compressed in
+	// blocks on multiple CPUs. 0 disables parallel compression
+	ParallelMinSizeBytes int `toml:"parallel_min_size_bytes"`
+}
+
+// NewOptions returns a new *Options with the default settings
+func NewOptions() *Options {
+	return &Options{
+		Level:                d.DefaultCompressionLevel,
+		MinSizeBytes:         d.DefaultCompressionMinSizeBytes,
+		ParallelMinSizeBytes: d.DefaultCompressionParallelMinSizeBytes,
+	}
+}
+
+// Clone returns an exact copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Enabled:              o.Enabled,
+		Level:                o.Level,
+		MinSizeBytes:         o.MinSizeBytes,
+		ParallelMinSizeBytes: o.ParallelMinSizeBytes,
+	}
+}
+
+// Validate returns an error if the Options are invalid
+func (o *Options) Validate() error {
+	if o.Level < gzip.BestSpeed || o.Level > gzip.BestCompression {
+		return errors.New("compression level must be between 1 and 9")
+	}
+	if o.MinSizeBytes < 0 || o.ParallelMinSizeBytes < 0 {
+		return errors.New("compression sizes must not be negative")
+	}
+	return nil
+}
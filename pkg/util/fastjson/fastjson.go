@@ -0,0 +1,402 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fastjson provides a reflection-free JSON scanner, used to decode large
+// timeseries documents faster than encoding/json. Decoders built with it return
+// an error for any input they do not fully understand, so that callers can fall
+// back to encoding/json, which remains the reference implementation
+package fastjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	litNull  = []byte("null")
+	litTrue  = []byte("true")
+	litFalse = []byte("false")
+)
+
+// Scanner reads JSON values from a byte slice. The first error encountered is
+// retained and returned by Err, and all subsequent reads are no-ops
+type Scanner struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// NewScanner returns a Scanner reading from data
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{data: data}
+}
+
+// Err returns the first error encountered by the Scanner
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Fail records err as the Scanner's error, unless an error has already been recorded
+func (s *Scanner) Fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *Scanner) fail() {
+	if s.err == nil {
+		s.err = fmt.Errorf("fastjson: unexpected input at offset %d", s.pos)
+	}
+}
+
+func (s *Scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// Peek returns the first byte of the next value without consuming it,
+// or 0 if the input is exhausted or the Scanner has failed
+func (s *Scanner) Peek() byte {
+	if s.err != nil {
+		return 0
+	}
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return 0
+	}
+	return s.data[s.pos]
+}
+
+func (s *Scanner) expect(c byte) bool {
+	if s.Peek() != c {
+		s.fail()
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *Scanner) literal(lit []byte) bool {
+	if s.err != nil {
+		return false
+	}
+	s.skipSpace()
+	if !bytes.HasPrefix(s.data[s.pos:], lit) {
+		return false
+	}
+	s.pos += len(lit)
+	return true
+}
+
+// Null consumes a null literal and returns true, or returns false
+// without consuming anything if the next value is not null
+func (s *Scanner) Null() bool {
+	return s.literal(litNull)
+}
+
+// End verifies that nothing but whitespace follows the last value
+func (s *Scanner) End() {
+	if s.err != nil {
+		return
+	}
+	s.skipSpace()
+	if s.pos != len(s.data) {
+		s.fail()
+	}
+}
+
+// Object iterates the members of an object, calling fn with each key while the
+// Scanner is positioned at the member's value, which fn must consume
+func (s *Scanner) Object(fn func(key string)) {
+	if !s.expect('{') {
+		return
+	}
+	if s.Peek() == '}' {
+		s.pos++
+		return
+	}
+	for s.err == nil {
+		key := s.String()
+		if !s.expect(':') {
+			return
+		}
+		fn(key)
+		switch s.Peek() {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return
+		default:
+			s.fail()
+		}
+	}
+}
+
+// Array iterates the elements of an array, calling fn while the Scanner is
+// positioned at each element, which fn must consume
+func (s *Scanner) Array(fn func()) {
+	if !s.expect('[') {
+		return
+	}
+	if s.Peek() == ']' {
+		s.pos++
+		return
+	}
+	for s.err == nil {
+		fn()
+		switch s.Peek() {
+		case ',':
+			s.pos++
+		case ']':
+			s.pos++
+			return
+		default:
+			s.fail()
+		}
+	}
+}
+
+// scanString advances past a string and returns its raw bytes, including the quotes,
+// and whether they must be passed through encoding/json to be decoded
+func (s *Scanner) scanString() ([]byte, bool) {
+	if !s.expect('"') {
+		return nil, false
+	}
+	start := s.pos - 1
+	var complex bool
+	for i := s.pos; i < len(s.data); i++ {
+		switch c := s.data[i]; {
+		case c == '\\':
+			complex = true
+			i++
+			if i < len(s.data) && s.data[i] == 'u' {
+				if !isHex4(s.data[i+1:]) {
+					s.pos = i
+					s.fail()
+					return nil, false
+				}
+				i += 4
+			} else if i >= len(s.data) || !isEscape(s.data[i]) {
+				s.pos = i
+				s.fail()
+				return nil, false
+			}
+		case c == '"':
+			s.pos = i + 1
+			return s.data[start:s.pos], complex
+		case c < 0x20:
+			s.pos = i
+			s.fail()
+			return nil, false
+		case c >= utf8.RuneSelf:
+			// encoding/json replaces invalid UTF-8 sequences
+			complex = true
+		}
+	}
+	s.pos = len(s.data)
+	s.fail()
+	return nil, false
+}
+
+func isEscape(c byte) bool {
+	switch c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		return true
+	}
+	return false
+}
+
+func isHex4(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	for _, c := range b[:4] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// RawString consumes a string and returns its raw bytes, including the quotes and
+// any escape sequences
+func (s *Scanner) RawString() []byte {
+	b, _ := s.scanString()
+	return b
+}
+
+// String consumes a string and returns its decoded value
+func (s *Scanner) String() string {
+	b, complex := s.scanString()
+	if b == nil {
+		return ""
+	}
+	if !complex || utf8.Valid(b) && bytes.IndexByte(b, '\\') < 0 {
+		return string(b[1 : len(b)-1])
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		s.Fail(err)
+	}
+	return v
+}
+
+// Number consumes a number and returns its raw bytes
+func (s *Scanner) Number() []byte {
+	if s.err != nil {
+		return nil
+	}
+	s.skipSpace()
+	start := s.pos
+	if s.pos < len(s.data) && s.data[s.pos] == '-' {
+		s.pos++
+	}
+	switch {
+	case s.pos < len(s.data) && s.data[s.pos] == '0':
+		s.pos++
+	case !s.digits():
+		s.fail()
+		return nil
+	}
+	if s.pos < len(s.data) && s.data[s.pos] == '.' {
+		s.pos++
+		if !s.digits() {
+			s.fail()
+			return nil
+		}
+	}
+	if s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		s.pos++
+		if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+			s.pos++
+		}
+		if !s.digits() {
+			s.fail()
+			return nil
+		}
+	}
+	return s.data[start:s.pos]
+}
+
+// digits advances past a run of decimal digits and returns false if there were none
+func (s *Scanner) digits() bool {
+	start := s.pos
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	return s.pos > start
+}
+
+// Float64 consumes a number and returns its value
+func (s *Scanner) Float64() float64 {
+	b := s.Number()
+	if b == nil {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		s.Fail(err)
+	}
+	return f
+}
+
+// Bool consumes a boolean and returns its value
+func (s *Scanner) Bool() bool {
+	if s.literal(litTrue) {
+		return true
+	}
+	if !s.literal(litFalse) {
+		s.fail()
+	}
+	return false
+}
+
+// Interface consumes any value and returns it as encoding/json would
+// when decoding into an interface{}
+func (s *Scanner) Interface() interface{} {
+	switch s.Peek() {
+	case '"':
+		return s.String()
+	case 't', 'f':
+		return s.Bool()
+	case 'n':
+		if !s.Null() {
+			s.fail()
+		}
+		return nil
+	case '{', '[':
+		var v interface{}
+		s.Unmarshal(&v)
+		return v
+	}
+	return s.Float64()
+}
+
+// Skip consumes the next value
+func (s *Scanner) Skip() {
+	switch s.Peek() {
+	case '{':
+		s.Object(func(string) { s.Skip() })
+	case '[':
+		s.Array(s.Skip)
+	case '"':
+		s.scanString()
+	case 't', 'f':
+		s.Bool()
+	case 'n':
+		if !s.Null() {
+			s.fail()
+		}
+	default:
+		s.Number()
+	}
+}
+
+// Raw consumes the next value and returns its raw bytes
+func (s *Scanner) Raw() []byte {
+	if s.err != nil {
+		return nil
+	}
+	s.skipSpace()
+	start := s.pos
+	s.Skip()
+	if s.err != nil {
+		return nil
+	}
+	return s.data[start:s.pos]
+}
+
+// Unmarshal consumes the next value and decodes it into v using encoding/json,
+// for members that are not worth decoding by hand
+func (s *Scanner) Unmarshal(v interface{}) {
+	b := s.Raw()
+	if b == nil {
+		return
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		s.Fail(err)
+	}
+}
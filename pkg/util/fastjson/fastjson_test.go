@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fastjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScannerObject(t *testing.T) {
+
+	s := NewScanner([]byte(` {"a": "x", "b" : [1, -2.5e3, true, false, null], "c": {"d": "é\n"}, "e": {}} `))
+	var a, d string
+	var b []interface{}
+	s.Object(func(key string) {
+		switch key {
+		case "a":
+			a = s.String()
+		case "b":
+			b = []interface{}{}
+			s.Array(func() {
+				b = append(b, s.Interface())
+			})
+		case "c":
+			s.Object(func(string) {
+				d = s.String()
+			})
+		default:
+			s.Skip()
+		}
+	})
+	s.End()
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	if a != "x" {
+		t.Errorf("expected %s got %s", "x", a)
+	}
+	if d != "é\n" {
+		t.Errorf("expected %s got %s", "é\n", d)
+	}
+	expected := []interface{}{float64(1), float64(-2500), true, false, nil}
+	if !reflect.DeepEqual(b, expected) {
+		t.Errorf("expected %v got %v", expected, b)
+	}
+
+}
+
+func TestScannerInvalid(t *testing.T) {
+
+	tests := []string{
+		``,
+		`{`,
+		`{"a" 1}`,
+		`{"a": 1,}`,
+		`{"a": 1} x`,
+		`[1 2]`,
+		`[01]`,
+		`[+1]`,
+		`[1.]`,
+		`[1e]`,
+		`["a`,
+		"[\"\x01\"]",
+		`[tru]`,
+		`[nul]`,
+		`["\x"]`,
+		`["\u12"]`,
+	}
+
+	for i, test := range tests {
+		s := NewScanner([]byte(test))
+		s.Skip()
+		s.End()
+		if s.Err() == nil {
+			t.Errorf("test %d: expected error for %s", i, test)
+		}
+		// every input rejected by the Scanner must also be rejected by encoding/json
+		var v interface{}
+		if json.Unmarshal([]byte(test), &v) == nil {
+			t.Errorf("test %d: encoding/json accepted %s", i, test)
+		}
+	}
+
+}
+
+func TestScannerInterface(t *testing.T) {
+
+	tests := []string{
+		`"trickster"`,
+		"\"invalid \xff utf-8\"",
+		`"\u00e9\ud83d\ude00\/"`,
+		`12.75`,
+		`-0`,
+		`1E+2`,
+		`true`,
+		`null`,
+		`{"a":[1,{"b":null}]}`,
+		`[]`,
+	}
+
+	for i, test := range tests {
+		s := NewScanner([]byte(test))
+		v := s.Interface()
+		s.End()
+		if s.Err() != nil {
+			t.Errorf("test %d: %s", i, s.Err())
+			continue
+		}
+		var expected interface{}
+		json.Unmarshal([]byte(test), &expected)
+		if !reflect.DeepEqual(v, expected) {
+			t.Errorf("test %d: expected %v got %v", i, expected, v)
+		}
+	}
+
+}
+
+func TestScannerRaw(t *testing.T) {
+
+	s := NewScanner([]byte(`{"a": [1, "]"], "b": 2}`))
+	var raw string
+	var b int
+	s.Object(func(key string) {
+		if key == "a" {
+			raw = string(s.Raw())
+			return
+		}
+		s.Unmarshal(&b)
+	})
+	s.End()
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	if raw != `[1, "]"]` {
+		t.Errorf("expected %s got %s", `[1, "]"]`, raw)
+	}
+	if b != 2 {
+		t.Errorf("expected %d got %d", 2, b)
+	}
+
+	s = NewScanner([]byte(`"a\"b"`))
+	if r := string(s.RawString()); r != `"a\"b"` {
+		t.Errorf("expected %s got %s", `"a\"b"`, r)
+	}
+
+	s = NewScanner([]byte(`"x"`))
+	s.Unmarshal(&b)
+	if s.Err() == nil {
+		t.Error("expected error")
+	}
+
+}
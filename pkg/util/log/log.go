@@ -26,6 +26,7 @@ import (
 	"sync"
 
 	"github.com/tricksterproxy/trickster/pkg/config"
+	d "github.com/tricksterproxy/trickster/pkg/config/defaults"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -40,6 +41,8 @@ type Logger struct {
 	closer     io.Closer
 	level      string
 
+	canonicalLevel string
+
 	onceMutex      *sync.Mutex
 	onceRanEntries map[string]bool
 }
@@ -95,6 +98,7 @@ func ConsoleLogger(logLevel string) *Logger {
 		}),
 	)
 	l.SetLogLevel(logLevel)
+	l.SetCanonicalLogLevel(d.DefaultCanonicalLogLevel)
 	return l
 }
 
@@ -120,6 +124,11 @@ func (tl *Logger) SetLogLevel(logLevel string) {
 	}
 }
 
+// SetCanonicalLogLevel sets the level at which Canonical events are logged
+func (tl *Logger) SetCanonicalLogLevel(logLevel string) {
+	tl.canonicalLevel = strings.ToLower(logLevel)
+}
+
 // New returns a Logger for the provided logging configuration. The
 // returned Logger will write to files distinguished from other Loggers by the
 // instance string.
@@ -155,6 +164,7 @@ func New(conf *config.Config) *Logger {
 	)
 
 	l.SetLogLevel(conf.Logging.LogLevel)
+	l.SetCanonicalLogLevel(conf.Logging.CanonicalLogLevel)
 
 	if c, ok := wr.(io.Closer); ok && c != nil {
 		l.closer = c
@@ -237,6 +247,24 @@ func (tl *Logger) Debug(event string, detail Pairs) {
 	level.Debug(tl.logger).Log(mapToArray(event, detail)...)
 }
 
+// Canonical sends an event to the Logger at the configured canonical log level,
+// which summarizes the full handling of a single request
+func (tl *Logger) Canonical(event string, detail Pairs) {
+	switch tl.canonicalLevel {
+	case "none":
+	case "trace":
+		tl.Trace(event, detail)
+	case "debug":
+		tl.Debug(event, detail)
+	case "warn":
+		tl.Warn(event, detail)
+	case "error":
+		tl.Error(event, detail)
+	default:
+		tl.Info(event, detail)
+	}
+}
+
 // Trace sends a "TRACE" event to the Logger
 func (tl *Logger) Trace(event string, detail Pairs) {
 	// go-kit/log/level does not support Trace, so implemented separately here
@@ -274,3 +274,26 @@ func TestSetLogLevel(t *testing.T) {
 	}
 
 }
+
+func TestNewLoggerCanonical_LogFile(t *testing.T) {
+	fileName := "out.canonical.log"
+	// it should log canonical events at the configured canonical level
+	conf := config.NewConfig()
+	conf.Main = &config.MainConfig{InstanceID: 0}
+	conf.Logging = &config.LoggingConfig{LogFile: fileName, LogLevel: "info", CanonicalLogLevel: "none"}
+	log := New(conf)
+	defer log.Close()
+	log.Canonical("test entry", Pairs{"testKey": "testVal"})
+	if _, err := os.Stat(fileName); err == nil {
+		t.Errorf("expected no log file for canonical level none")
+	}
+	for _, l := range []string{"trace", "debug", "info", "warn", "error"} {
+		log.SetCanonicalLogLevel(l)
+		log.Canonical("test entry", Pairs{"testKey": "testVal"})
+	}
+	if _, err := os.Stat(fileName); err != nil {
+		t.Errorf(err.Error())
+	}
+	log.Close()
+	os.Remove(fileName)
+}
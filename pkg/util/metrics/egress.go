@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "sync"
+
+// egress holds the bytes fetched from and served for each origin since startup, from which
+// the egress avoided gauges are computed, since the counters can't be read back
+type egress struct {
+	fetched float64
+	served  float64
+}
+
+var egressLock sync.Mutex
+var egressByOrigin = make(map[[2]string]*egress)
+
+// ObserveOriginFetchedBytes records n response body bytes fetched from the origin
+func ObserveOriginFetchedBytes(originName, originType string, n float64) {
+	if n <= 0 {
+		return
+	}
+	ProxyOriginFetchedBytes.WithLabelValues(originName, originType).Add(n)
+	observeEgress(originName, originType, n, 0)
+}
+
+// ObserveClientServedBytes records n response body bytes served to a client for the origin
+func ObserveClientServedBytes(originName, originType string, n float64) {
+	if n <= 0 {
+		return
+	}
+	ProxyClientServedBytes.WithLabelValues(originName, originType).Add(n)
+	observeEgress(originName, originType, 0, n)
+}
+
+// observeEgress adds the fetched and served bytes to the origin's totals, and updates
+// its egress avoided gauges
+func observeEgress(originName, originType string, fetched, served float64) {
+	k := [2]string{originName, originType}
+	egressLock.Lock()
+	e, ok := egressByOrigin[k]
+	if !ok {
+		e = &egress{}
+		egressByOrigin[k] = e
+	}
+	e.fetched += fetched
+	e.served += served
+	var avoided, ratio float64
+	if e.served > e.fetched {
+		avoided = e.served - e.fetched
+		ratio = avoided / e.served
+	}
+	ProxyOriginEgressAvoided.WithLabelValues(originName, originType).Set(avoided)
+	ProxyOriginEgressAvoidedRatio.WithLabelValues(originName, originType).Set(ratio)
+	egressLock.Unlock()
+}
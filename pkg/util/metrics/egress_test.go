@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveEgress(t *testing.T) {
+
+	const name, typ = "egress-test", "test"
+
+	gauges := func() (float64, float64) {
+		var m1, m2 dto.Metric
+		ProxyOriginEgressAvoided.WithLabelValues(name, typ).Write(&m1)
+		ProxyOriginEgressAvoidedRatio.WithLabelValues(name, typ).Write(&m2)
+		return m1.GetGauge().GetValue(), m2.GetGauge().GetValue()
+	}
+
+	// nothing is avoided while more is fetched than served
+	ObserveOriginFetchedBytes(name, typ, 100)
+	ObserveClientServedBytes(name, typ, 50)
+	if avoided, ratio := gauges(); avoided != 0 || ratio != 0 {
+		t.Errorf("expected %d and %d got %f and %f", 0, 0, avoided, ratio)
+	}
+
+	ObserveClientServedBytes(name, typ, 350)
+	if avoided, ratio := gauges(); avoided != 300 || ratio != 0.75 {
+		t.Errorf("expected %d and %f got %f and %f", 300, 0.75, avoided, ratio)
+	}
+
+	// zero and negative byte counts are ignored
+	ObserveOriginFetchedBytes(name, typ, 0)
+	ObserveClientServedBytes(name, typ, -1)
+
+	var m dto.Metric
+	ProxyClientServedBytes.WithLabelValues(name, typ).Write(&m)
+	if v := m.GetCounter().GetValue(); v != 400 {
+		t.Errorf("expected %d got %f", 400, v)
+	}
+	ProxyOriginFetchedBytes.WithLabelValues(name, typ).Write(&m)
+	if v := m.GetCounter().GetValue(); v != 100 {
+		t.Errorf("expected %d got %f", 100, v)
+	}
+
+}
@@ -31,6 +31,7 @@ const (
 	configSubsystem   = "config"
 	buildSubsystem    = "build"
 	frontendSubsystem = "frontend"
+	runtimeSubsystem  = "runtime"
 )
 
 // Default histogram buckets used by trickster
@@ -47,6 +48,15 @@ var LastReloadSuccessful prometheus.Gauge
 // LastReloadSuccessfulTimestamp gauge is the epoch time of the most recent successful config load
 var LastReloadSuccessfulTimestamp prometheus.Gauge
 
+// ConfigGeneration gauge is the number of configurations that have been applied since Trickster started
+var ConfigGeneration prometheus.Gauge
+
+// ConfigReloads is a Counter of configuration reload attempts, by result
+var ConfigReloads *prometheus.CounterVec
+
+// ConfigLastReloadChanges is a Gauge of the items added, removed or changed by the last configuration reload
+var ConfigLastReloadChanges *prometheus.GaugeVec
+
 // FrontendRequestStatus is a Counter of front end requests that have been processed with their status
 var FrontendRequestStatus *prometheus.CounterVec
 
@@ -56,6 +66,9 @@ var FrontendRequestDuration *prometheus.HistogramVec
 // FrontendRequestWrittenBytes is a Counter of bytes written for front end requests
 var FrontendRequestWrittenBytes *prometheus.CounterVec
 
+// FrontendPanics is a Counter of panics recovered while handling front end requests
+var FrontendPanics *prometheus.CounterVec
+
 // ProxyRequestStatus is a Counter of downstream client requests handled by Trickster
 var ProxyRequestStatus *prometheus.CounterVec
 
@@ -86,6 +99,124 @@ var CacheMaxObjects *prometheus.GaugeVec
 // CacheMaxBytes is a Gauge for the Trickster cache's Max Object Threshold for triggering an eviction exercise
 var CacheMaxBytes *prometheus.GaugeVec
 
+// CacheIngestRate is a Gauge of the rate at which bytes are written to the Trickster cache
+var CacheIngestRate *prometheus.GaugeVec
+
+// CacheRemovalRate is a Gauge of the rate at which bytes are removed from the Trickster cache
+var CacheRemovalRate *prometheus.GaugeVec
+
+// CacheTimeToFull is a Gauge of the projected time until the Trickster cache reaches its max size
+var CacheTimeToFull *prometheus.GaugeVec
+
+// CacheNamespaceObjects is a Gauge representing the number of objects in an origin's cache namespace
+var CacheNamespaceObjects *prometheus.GaugeVec
+
+// CacheNamespaceBytes is a Gauge representing the number of bytes in an origin's cache namespace
+var CacheNamespaceBytes *prometheus.GaugeVec
+
+// CacheProjectedHitRetention is a Gauge of the projected share of the Trickster cache's hits
+// that would be retained if its max size were reduced to a fraction of the current size
+var CacheProjectedHitRetention *prometheus.GaugeVec
+
+// CacheOversizedObjects is a Counter of objects that were not cached because they exceeded a max object size
+var CacheOversizedObjects *prometheus.CounterVec
+
+// CacheWarmingQueries is a Counter of queries replayed against an origin to warm the Trickster cache
+var CacheWarmingQueries *prometheus.CounterVec
+
+// ProxyDNSLookupDuration is a Histogram of time required in seconds to resolve an origin hostname
+var ProxyDNSLookupDuration *prometheus.HistogramVec
+
+// ProxyDNSLookupFailures is a Counter of failed origin hostname resolutions
+var ProxyDNSLookupFailures *prometheus.CounterVec
+
+// ProxyDNSCacheEvents is a Counter of events performed on an origin's DNS cache
+var ProxyDNSCacheEvents *prometheus.CounterVec
+
+// ProxyAuthorizerDecisions is a Counter of external authorization decisions made for an origin
+var ProxyAuthorizerDecisions *prometheus.CounterVec
+
+// ProxySLOBurnRate is a Gauge of the rate at which an origin's SLO error budget is consumed over a window
+var ProxySLOBurnRate *prometheus.GaugeVec
+
+// ProxySLOErrorBudgetRemaining is a Gauge of the fraction of an origin's SLO error budget that is unspent
+var ProxySLOErrorBudgetRemaining *prometheus.GaugeVec
+
+// ProxyOriginDegraded is a Gauge that is 1 while an origin has exceeded its error budget
+var ProxyOriginDegraded *prometheus.GaugeVec
+
+// ProxyRetentionTrims is a Counter of cached timeseries trimmed to an origin's retention window
+var ProxyRetentionTrims *prometheus.CounterVec
+
+// ProxyPeerRequests is a Counter of requests forwarded to the cluster peer that owns their cache key
+var ProxyPeerRequests *prometheus.CounterVec
+
+// ProxyPeerGossip is a Counter of cluster membership exchanges with a peer
+var ProxyPeerGossip *prometheus.CounterVec
+
+// ProxyClusterPeers is a Gauge of the number of peers among which the cache key space is divided
+var ProxyClusterPeers prometheus.Gauge
+
+// ProxyLoopsDetected is a Counter of requests to an upstream Trickster rejected as proxy loops
+var ProxyLoopsDetected *prometheus.CounterVec
+
+// ProxyReplicationObjects is a Counter of cached timeseries sent to or received from an origin's replication peers
+var ProxyReplicationObjects *prometheus.CounterVec
+
+// ProxyIngestMessages is a Counter of messages consumed from an origin's cache ingestion source
+var ProxyIngestMessages *prometheus.CounterVec
+
+// ProxyIngestSourceErrors is a Counter of failed connections to an origin's cache ingestion source
+var ProxyIngestSourceErrors *prometheus.CounterVec
+
+// ProxyInvalidationRequests is a Counter of requests to the cache invalidation webhook
+var ProxyInvalidationRequests *prometheus.CounterVec
+
+// ProxyInvalidatedObjects is a Counter of an origin's cached objects invalidated by the cache invalidation webhook
+var ProxyInvalidatedObjects *prometheus.CounterVec
+
+// ProxyRemoteWriteRequests is a Counter of remote_write requests buffered for, and forwarded to, an origin
+var ProxyRemoteWriteRequests *prometheus.CounterVec
+
+// ProxyRemoteWriteDroppedSeries is a Counter of remote_write series dropped by an origin's relabel rules
+var ProxyRemoteWriteDroppedSeries *prometheus.CounterVec
+
+// ProxyRemoteWriteBuffered is a Gauge of the remote_write requests buffered for an origin
+var ProxyRemoteWriteBuffered *prometheus.GaugeVec
+
+// ProxyOriginClockSkew is a Gauge of the offset in seconds of the local clock from an origin's Date header
+var ProxyOriginClockSkew *prometheus.GaugeVec
+
+// ProxyOriginClockSkewWarnings is a Counter of origin responses whose Date header exceeded the clock skew tolerance
+var ProxyOriginClockSkewWarnings *prometheus.CounterVec
+
+// ProxyOriginFetchedBytes is a Counter of the response body bytes fetched from an origin
+var ProxyOriginFetchedBytes *prometheus.CounterVec
+
+// ProxyClientServedBytes is a Counter of the response body bytes served to clients for an origin
+var ProxyClientServedBytes *prometheus.CounterVec
+
+// ProxyCollapsedRequests is a Counter of the requests whose upstream fetch was collapsed into
+// an identical fetch that was already in flight
+var ProxyCollapsedRequests *prometheus.CounterVec
+
+// ProxyOriginEgressAvoided is a Gauge of the bytes served to clients for an origin that were not
+// fetched from the origin
+var ProxyOriginEgressAvoided *prometheus.GaugeVec
+
+// ProxyOriginEgressAvoidedRatio is a Gauge of the ratio of the bytes served to clients for an
+// origin that were not fetched from the origin
+var ProxyOriginEgressAvoidedRatio *prometheus.GaugeVec
+
+// RuntimeMemoryLimit is a Gauge of the soft memory limit applied to the process
+var RuntimeMemoryLimit prometheus.Gauge
+
+// RuntimeMemoryPressure is a Gauge that is 1 while memory use is above the configured watermark
+var RuntimeMemoryPressure prometheus.Gauge
+
+// RuntimeFeatureFlagEvaluations is a Counter of feature flag evaluations for an origin's requests
+var RuntimeFeatureFlagEvaluations *prometheus.CounterVec
+
 // ProxyMaxConnections is a Gauge representing the max number of active concurrent connections in the server
 var ProxyMaxConnections prometheus.Gauge
 
@@ -135,6 +266,35 @@ func init() {
 		},
 	)
 
+	ConfigGeneration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: configSubsystem,
+			Name:      "generation",
+			Help:      "Number of configurations that have been applied since Trickster started.",
+		},
+	)
+
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: configSubsystem,
+			Name:      "reloads_total",
+			Help:      "Count of configuration reload attempts, by result.",
+		},
+		[]string{"result"},
+	)
+
+	ConfigLastReloadChanges = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: configSubsystem,
+			Name:      "last_reload_changes",
+			Help:      "Number of items added, removed or changed in each configuration section by the last successful reload.",
+		},
+		[]string{"section", "change"},
+	)
+
 	FrontendRequestStatus = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -165,6 +325,16 @@ func init() {
 		},
 		[]string{"origin_name", "origin_type", "method", "path", "http_status"})
 
+	FrontendPanics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: frontendSubsystem,
+			Name:      "panics_total",
+			Help:      "Count of panics recovered while handling front end requests.",
+		},
+		[]string{"origin_name", "origin_type", "path", "quarantined"},
+	)
+
 	ProxyRequestStatus = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -196,6 +366,304 @@ func init() {
 		[]string{"origin_name", "origin_type", "method", "status", "http_status", "path"},
 	)
 
+	ProxyDNSLookupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "dns_lookup_duration_seconds",
+			Help:      "Time required in seconds to resolve an origin hostname.",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		},
+		[]string{"origin_name", "status"},
+	)
+
+	ProxyDNSLookupFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "dns_lookup_failures_total",
+			Help:      "Count of failed origin hostname resolutions.",
+		},
+		[]string{"origin_name"},
+	)
+
+	ProxyDNSCacheEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "dns_cache_events_total",
+			Help:      "Count of events performed on an origin's DNS cache.",
+		},
+		[]string{"origin_name", "event"},
+	)
+
+	ProxyAuthorizerDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "authorizer_decisions_total",
+			Help:      "Count of external authorization decisions made for an origin.",
+		},
+		[]string{"origin_name", "authorizer_name", "decision", "cache"},
+	)
+
+	ProxySLOBurnRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "slo_burn_rate",
+			Help:      "Rate at which an origin's SLO error budget is consumed over a window, where 1 exhausts the budget by the end of the period.",
+		},
+		[]string{"origin_name", "origin_type", "objective", "window"},
+	)
+
+	ProxySLOErrorBudgetRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "slo_error_budget_remaining",
+			Help:      "Fraction of an origin's SLO error budget that is unspent over the compliance period.",
+		},
+		[]string{"origin_name", "origin_type", "objective"},
+	)
+
+	ProxyOriginDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_degraded",
+			Help:      "1 while an origin has exceeded its error budget and cache TTLs are stretched, otherwise 0.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyRetentionTrims = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "retention_trims_total",
+			Help:      "Count of cached timeseries objects truncated or removed by an origin's retention window trimmer.",
+		},
+		[]string{"origin_name", "origin_type", "result"},
+	)
+
+	ProxyPeerRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "peer_requests_total",
+			Help:      "Count of requests for cache keys owned by another cluster peer, by whether they were forwarded to it.",
+		},
+		[]string{"peer", "result"},
+	)
+
+	ProxyPeerGossip = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "peer_gossip_total",
+			Help:      "Count of cluster membership exchanges with a randomly chosen peer.",
+		},
+		[]string{"result"},
+	)
+
+	ProxyClusterPeers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "cluster_peers",
+			Help:      "Number of cluster peers among which the cache key space is divided.",
+		},
+	)
+
+	ProxyLoopsDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "loops_detected_total",
+			Help:      "Count of requests to an upstream Trickster that were rejected as proxy loops.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyReplicationObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "replication_objects_total",
+			Help:      "Count of cached timeseries objects sent to or received from an origin's replication peers.",
+		},
+		[]string{"origin_name", "origin_type", "direction", "result"},
+	)
+
+	ProxyIngestMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "ingest_messages_total",
+			Help:      "Count of messages consumed from an origin's cache ingestion source.",
+		},
+		[]string{"origin_name", "origin_type", "result"},
+	)
+
+	ProxyIngestSourceErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "ingest_source_errors_total",
+			Help:      "Count of failed connections to an origin's cache ingestion source.",
+		},
+		[]string{"origin_name", "origin_type", "source"},
+	)
+
+	ProxyInvalidationRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "invalidation_requests_total",
+			Help:      "Count of requests to the cache invalidation webhook.",
+		},
+		[]string{"origin_name", "origin_type", "result"},
+	)
+
+	ProxyInvalidatedObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "invalidated_objects_total",
+			Help:      "Count of an origin's cached objects invalidated by the cache invalidation webhook.",
+		},
+		[]string{"origin_name", "origin_type", "mode"},
+	)
+
+	ProxyRemoteWriteRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "remote_write_requests_total",
+			Help:      "Count of remote_write requests buffered for, and forwarded to, an origin.",
+		},
+		[]string{"origin_name", "origin_type", "result"},
+	)
+
+	ProxyRemoteWriteDroppedSeries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "remote_write_dropped_series_total",
+			Help:      "Count of remote_write series dropped by an origin's relabel rules.",
+		},
+		[]string{"origin_name", "origin_type", "rule"},
+	)
+
+	ProxyRemoteWriteBuffered = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "remote_write_buffered_requests",
+			Help:      "Number of remote_write requests buffered for an origin.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyOriginClockSkew = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_clock_skew_seconds",
+			Help:      "Offset in seconds of the local clock from the Date header of the origin's most recent response.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyOriginClockSkewWarnings = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_clock_skew_warnings_total",
+			Help:      "Count of origin responses whose Date header was outside of the origin's clock skew tolerance.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyOriginFetchedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_fetched_bytes_total",
+			Help:      "Count of response body bytes fetched from the origin.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyCollapsedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "collapsed_requests_total",
+			Help:      "Count of requests served by an identical upstream fetch that was already in flight.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyClientServedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "client_served_bytes_total",
+			Help:      "Count of response body bytes served to clients for the origin.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyOriginEgressAvoided = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_egress_avoided_bytes",
+			Help:      "Bytes served to clients for the origin since startup that were not fetched from the origin.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	ProxyOriginEgressAvoidedRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_egress_avoided_ratio",
+			Help:      "Ratio of the bytes served to clients for the origin since startup that were not fetched from the origin.",
+		},
+		[]string{"origin_name", "origin_type"},
+	)
+
+	RuntimeMemoryLimit = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: runtimeSubsystem,
+			Name:      "memory_limit_bytes",
+			Help:      "The soft memory limit applied to the process, or 0 when there is none.",
+		},
+	)
+
+	RuntimeMemoryPressure = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: runtimeSubsystem,
+			Name:      "memory_pressure",
+			Help:      "1 while memory use is above the configured watermark, otherwise 0.",
+		},
+	)
+
+	RuntimeFeatureFlagEvaluations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: runtimeSubsystem,
+			Name:      "feature_flag_evaluations_total",
+			Help:      "Count of feature flag evaluations for an origin's requests, by whether the gated behavior was on or off.",
+		},
+		[]string{"flag", "origin_name", "result"},
+	)
+
 	ProxyMaxConnections = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
@@ -319,13 +787,124 @@ func init() {
 		[]string{"cache_name", "cache_type"},
 	)
 
+	CacheIngestRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "ingest_bytes_per_second",
+			Help:      "Recent rate at which bytes are written to the Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	CacheRemovalRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "removal_bytes_per_second",
+			Help:      "Recent rate at which bytes are removed from the Trickster cache by expiration, eviction or deletion.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	CacheTimeToFull = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "time_to_full_seconds",
+			Help:      "Projected time until the Trickster cache reaches its Max Byte Threshold, or -1 if it is not growing.",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	CacheNamespaceObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "namespace_usage_objects",
+			Help:      "Number of objects in an origin's namespace within a Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type", "namespace"},
+	)
+
+	CacheNamespaceBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "namespace_usage_bytes",
+			Help:      "Number of bytes in an origin's namespace within a Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type", "namespace"},
+	)
+
+	CacheProjectedHitRetention = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "projected_hit_retention_ratio",
+			Help:      "Projected share of the Trickster cache's hits that would be retained at a fraction of its max size.",
+		},
+		[]string{"cache_name", "cache_type", "size_ratio"},
+	)
+
+	CacheOversizedObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "oversized_objects_total",
+			Help:      "Count of objects that were proxied without being cached because they exceeded a max object size.",
+		},
+		[]string{"cache_name", "origin_name", "limit"},
+	)
+
+	CacheWarmingQueries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "warming_queries_total",
+			Help:      "Count of queries replayed against an origin to warm the Trickster cache.",
+		},
+		[]string{"cache_name", "origin_name", "result"},
+	)
+
 	// Register Metrics
 	prometheus.MustRegister(FrontendRequestStatus)
 	prometheus.MustRegister(FrontendRequestDuration)
 	prometheus.MustRegister(FrontendRequestWrittenBytes)
+	prometheus.MustRegister(FrontendPanics)
 	prometheus.MustRegister(ProxyRequestStatus)
 	prometheus.MustRegister(ProxyRequestElements)
 	prometheus.MustRegister(ProxyRequestDuration)
+	prometheus.MustRegister(ProxyDNSLookupDuration)
+	prometheus.MustRegister(ProxyDNSLookupFailures)
+	prometheus.MustRegister(ProxyDNSCacheEvents)
+	prometheus.MustRegister(ProxyAuthorizerDecisions)
+	prometheus.MustRegister(ProxySLOBurnRate)
+	prometheus.MustRegister(ProxySLOErrorBudgetRemaining)
+	prometheus.MustRegister(ProxyOriginDegraded)
+	prometheus.MustRegister(ProxyRetentionTrims)
+	prometheus.MustRegister(ProxyPeerRequests)
+	prometheus.MustRegister(ProxyPeerGossip)
+	prometheus.MustRegister(ProxyClusterPeers)
+	prometheus.MustRegister(ProxyLoopsDetected)
+	prometheus.MustRegister(ProxyReplicationObjects)
+	prometheus.MustRegister(ProxyIngestMessages)
+	prometheus.MustRegister(ProxyIngestSourceErrors)
+	prometheus.MustRegister(ProxyInvalidationRequests)
+	prometheus.MustRegister(ProxyInvalidatedObjects)
+	prometheus.MustRegister(ProxyRemoteWriteRequests)
+	prometheus.MustRegister(ProxyRemoteWriteDroppedSeries)
+	prometheus.MustRegister(ProxyRemoteWriteBuffered)
+	prometheus.MustRegister(ProxyOriginClockSkew)
+	prometheus.MustRegister(ProxyOriginClockSkewWarnings)
+	prometheus.MustRegister(ProxyOriginFetchedBytes)
+	prometheus.MustRegister(ProxyClientServedBytes)
+	prometheus.MustRegister(ProxyCollapsedRequests)
+	prometheus.MustRegister(ProxyOriginEgressAvoided)
+	prometheus.MustRegister(ProxyOriginEgressAvoidedRatio)
+	prometheus.MustRegister(RuntimeMemoryLimit)
+	prometheus.MustRegister(RuntimeMemoryPressure)
+	prometheus.MustRegister(RuntimeFeatureFlagEvaluations)
 	prometheus.MustRegister(ProxyMaxConnections)
 	prometheus.MustRegister(ProxyActiveConnections)
 	prometheus.MustRegister(ProxyConnectionRequested)
@@ -339,9 +918,20 @@ func init() {
 	prometheus.MustRegister(CacheBytes)
 	prometheus.MustRegister(CacheMaxObjects)
 	prometheus.MustRegister(CacheMaxBytes)
+	prometheus.MustRegister(CacheIngestRate)
+	prometheus.MustRegister(CacheRemovalRate)
+	prometheus.MustRegister(CacheTimeToFull)
+	prometheus.MustRegister(CacheNamespaceObjects)
+	prometheus.MustRegister(CacheNamespaceBytes)
+	prometheus.MustRegister(CacheProjectedHitRetention)
+	prometheus.MustRegister(CacheOversizedObjects)
+	prometheus.MustRegister(CacheWarmingQueries)
 	prometheus.MustRegister(BuildInfo)
 	prometheus.MustRegister(LastReloadSuccessful)
 	prometheus.MustRegister(LastReloadSuccessfulTimestamp)
+	prometheus.MustRegister(ConfigGeneration)
+	prometheus.MustRegister(ConfigReloads)
+	prometheus.MustRegister(ConfigLastReloadChanges)
 }
 
 // Handler returns the http handler for the listener
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Authorize checks each request with the provided external Authorizer before passing it
+// to the next handler. Allowed requests receive any upstream headers provided by the
+// authorization service, while denied requests are answered with the service's response
+func Authorize(a *authz.Authorizer, log *tl.Logger, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := a.Check(r)
+		if err != nil {
+			o := a.Options()
+			log.WarnOnce("authorizer.error."+o.Name, "external authorization failed",
+				tl.Pairs{"authorizerName": o.Name, "detail": err.Error(),
+					"failureModeAllow": o.FailureModeAllow})
+			if o.FailureModeAllow {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.WriteHeader(o.StatusOnError)
+			return
+		}
+		// a Decision may be cached and shared by concurrent requests, so its header
+		// values are copied rather than assigned
+		if !d.Allowed {
+			h := w.Header()
+			for k, v := range d.Header {
+				h[k] = append([]string(nil), v...)
+			}
+			w.WriteHeader(d.StatusCode)
+			w.Write(d.Body)
+			return
+		}
+		for k, v := range d.Header {
+			r.Header[k] = append([]string(nil), v...)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz"
+	"github.com/tricksterproxy/trickster/pkg/proxy/authz/options"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+var testLogger = tl.ConsoleLogger("error")
+
+func newTestAuthorizer(url string) *authz.Authorizer {
+	o := options.NewOptions()
+	o.Name = "test"
+	o.URL = url
+	o.UpstreamHeaders = []string{"X-User"}
+	o.ClientHeaders = []string{"WWW-Authenticate"}
+	return authz.New("test-origin", o)
+}
+
+func TestAuthorizeNil(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if h := Authorize(nil, testLogger, next); h == nil {
+		t.Error("expected non-nil handler")
+	}
+}
+
+func TestAuthorizeAllow(t *testing.T) {
+
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User", "test-user")
+		w.Header().Set("X-Other", "not-forwarded")
+	}))
+	defer as.Close()
+
+	var user, other string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = r.Header.Get("X-User")
+		other = r.Header.Get("X-Other")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	Authorize(newTestAuthorizer(as.URL), testLogger, next).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if user != "test-user" {
+		t.Errorf("expected %s got %s", "test-user", user)
+	}
+	if other != "" {
+		t.Errorf("expected empty string got %s", other)
+	}
+}
+
+func TestAuthorizeDeny(t *testing.T) {
+
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		w.Header().Set("X-User", "not-forwarded")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("denied"))
+	}))
+	defer as.Close()
+
+	var called bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	Authorize(newTestAuthorizer(as.URL), testLogger, next).ServeHTTP(w, r)
+
+	if called {
+		t.Error("expected denied request not to reach the next handler")
+	}
+	resp := w.Result()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected %d got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+	if v := resp.Header.Get("WWW-Authenticate"); v != "Basic" {
+		t.Errorf("expected %s got %s", "Basic", v)
+	}
+	if v := resp.Header.Get("X-User"); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "denied" {
+		t.Errorf("expected %s got %s", "denied", string(b))
+	}
+}
+
+func TestAuthorizeUnreachable(t *testing.T) {
+
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	as.Close()
+
+	tests := []struct {
+		failureModeAllow bool
+		expectedCode     int
+		expectedCalled   bool
+	}{
+		// fail closed with the configured status
+		{false, http.StatusServiceUnavailable, false},
+		// fail open to the next handler
+		{true, http.StatusOK, true},
+	}
+
+	for i, test := range tests {
+		a := newTestAuthorizer(as.URL)
+		a.Options().FailureModeAllow = test.failureModeAllow
+		a.Options().StatusOnError = http.StatusServiceUnavailable
+
+		var called bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			w.WriteHeader(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+		Authorize(a, testLogger, next).ServeHTTP(w, r)
+
+		if w.Code != test.expectedCode {
+			t.Errorf("test %d: expected %d got %d", i, test.expectedCode, w.Code)
+		}
+		if called != test.expectedCalled {
+			t.Errorf("test %d: expected %t got %t", i, test.expectedCalled, called)
+		}
+	}
+}
+
+func TestAuthorizeSharedDecision(t *testing.T) {
+
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User", "test-user")
+	}))
+	defer as.Close()
+
+	a := newTestAuthorizer(as.URL)
+	a.Options().DecisionCacheTTL = time.Minute
+
+	// each request changes its own copy of the upstream header in place, and
+	// appends to it, which must not be seen by other requests
+	var mtx sync.Mutex
+	seen := make([]string, 0)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header["X-User"]
+		mtx.Lock()
+		seen = append(seen, v[0])
+		mtx.Unlock()
+		v[0] = r.Header.Get("X-Test-Id")
+		r.Header.Add("X-User", "appended")
+	})
+	h := Authorize(a, testLogger, next)
+
+	// X-Test-Id is not passed to the authorization service, so each request
+	// is answered with the same cached Decision
+	serve := func(id string) {
+		r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+		r.Header.Set("X-Test-Id", id)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// the first request caches the decision
+	serve("0")
+
+	wg := sync.WaitGroup{}
+	for _, id := range []string{"1", "2"} {
+		wg.Add(1)
+		go func(id string) {
+			serve(id)
+			wg.Done()
+		}(id)
+	}
+	wg.Wait()
+
+	serve("3")
+
+	if len(seen) != 4 {
+		t.Fatalf("expected %d got %d", 4, len(seen))
+	}
+	for i, v := range seen {
+		if v != "test-user" {
+			t.Errorf("request %d: expected %s got %s", i, "test-user", v)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/capture"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+)
+
+// Capture records each client request with the provided Recorder before passing it
+// to the next handler
+func Capture(rec *capture.Recorder, originName string, log *tl.Logger, next http.Handler) http.Handler {
+	if rec == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := rec.RecordClient(r); err != nil {
+			log.WarnOnce("capture.error."+originName, "could not capture client request",
+				tl.Pairs{"originName": originName, "detail": err.Error()})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
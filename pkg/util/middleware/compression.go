@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	"github.com/tricksterproxy/trickster/pkg/util/buffers"
+	"github.com/tricksterproxy/trickster/pkg/util/compress/gzip"
+	co "github.com/tricksterproxy/trickster/pkg/util/compress/options"
+)
+
+// Compress gzip-encodes the responses of the next handler that are of one of the provided
+// content types and at least the configured minimum size, for clients that accept gzip.
+// Since the size of the response must be known, its body is buffered until the handler
+// returns, unless the handler flushes it, in which case the response is sent uncompressed
+func Compress(o *co.Options, types map[string]bool, next http.Handler) http.Handler {
+	if o == nil || !o.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// responses to HEAD requests have no body, and protocol switches have no response body
+		if r.Method == http.MethodHead || r.Header.Get(headers.NameUpgrade) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			options:        o,
+			types:          types,
+			accepted:       headers.AcceptsEncoding(r.Header, headers.ValueGzip),
+		}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+
+	options  *co.Options
+	types    map[string]bool
+	accepted bool
+
+	statusCode  int
+	buf         *bytes.Buffer
+	passthrough bool
+}
+
+// compressable returns true if the response may be compressed, and adds the Vary header
+// to responses of a compressable type, since their encoding depends on the client
+func (w *compressWriter) compressable() bool {
+	h := w.ResponseWriter.Header()
+	mt, _, err := mime.ParseMediaType(h.Get(headers.NameContentType))
+	if err != nil || !w.types[mt] {
+		return false
+	}
+	h.Add(headers.NameVary, headers.NameAcceptEncoding)
+	if !w.accepted || h.Get(headers.NameContentEncoding) != "" ||
+		h.Get(headers.NameContentRange) != "" {
+		return false
+	}
+	switch {
+	case w.statusCode < http.StatusOK,
+		w.statusCode == http.StatusNoContent,
+		w.statusCode == http.StatusPartialContent,
+		w.statusCode == http.StatusNotModified:
+		return false
+	}
+	if cl, err := strconv.Atoi(h.Get(headers.NameContentLength)); err == nil &&
+		cl < w.options.MinSizeBytes {
+		return false
+	}
+	return true
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.statusCode != 0 {
+		return
+	}
+	w.statusCode = statusCode
+	if !w.compressable() {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf == nil {
+		n := len(b)
+		if cl, err := strconv.Atoi(w.Header().Get(headers.NameContentLength)); err == nil && cl > n {
+			n = cl
+		}
+		w.buf = buffers.Get(n)
+	}
+	return w.buf.Write(b)
+}
+
+// send writes the headers and any buffered body to the client as-is
+func (w *compressWriter) send() {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if w.buf != nil {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		buffers.Put(w.buf)
+		w.buf = nil
+	}
+}
+
+// finish compresses and writes the buffered response once the handler has returned
+func (w *compressWriter) finish() {
+	if w.passthrough || w.statusCode == 0 {
+		return
+	}
+	if w.buf == nil || w.buf.Len() < w.options.MinSizeBytes {
+		w.send()
+		return
+	}
+
+	out := buffers.Get(w.buf.Len() / 4)
+	defer buffers.Put(out)
+	var err error
+	if w.options.ParallelMinSizeBytes > 0 && w.buf.Len() >= w.options.ParallelMinSizeBytes {
+		err = gzip.DeflateParallel(out, w.buf.Bytes(), w.options.Level)
+	} else {
+		err = gzip.Deflate(out, w.buf.Bytes(), w.options.Level)
+	}
+	if err != nil {
+		w.send()
+		return
+	}
+	buffers.Put(w.buf)
+	w.buf = nil
+
+	h := w.ResponseWriter.Header()
+	h.Set(headers.NameContentEncoding, headers.ValueGzip)
+	h.Set(headers.NameContentLength, strconv.Itoa(out.Len()))
+	// the encoded representation is no longer byte-for-byte identical to that of a strong ETag
+	if etag := h.Get(headers.NameETag); strings.HasPrefix(etag, `"`) {
+		h.Set(headers.NameETag, "W/"+etag)
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.ResponseWriter.Write(out.Bytes())
+}
+
+// Flush sends the response uncompressed, since a flushing handler is streaming its body
+func (w *compressWriter) Flush() {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough {
+		w.send()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports proxying connections that switch protocols, such as WebSockets
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	return hj.Hijack()
+}
@@ -40,6 +40,7 @@ func WithResourcesContext(client origins.Client, oc *oo.Options,
 		} else {
 			resources = request.NewResources(oc, p, c.Configuration(), c, client, t, l)
 		}
+		resources.Canonical = tl.Pairs{}
 		next.ServeHTTP(w, r.WithContext(context.WithResources(r.Context(), resources)))
 	})
 }
@@ -17,12 +17,17 @@
 package middleware
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/tricksterproxy/trickster/pkg/util/metrics"
 )
 
+var errHijackNotSupported = errors.New("response writer does not support hijacking")
+
 // Decorate decorates a function in such a way that it captures both the
 // returned status and the time used to execute a request from the front end
 // perspective
@@ -43,6 +48,7 @@ func Decorate(originName, originType, path string, next http.Handler) http.Handl
 			r.Method, path, observer.status).Inc()
 		metrics.FrontendRequestWrittenBytes.WithLabelValues(originName, originType,
 			r.Method, path, observer.status).Add(observer.bytesWritten)
+		metrics.ObserveClientServedBytes(originName, originType, observer.bytesWritten)
 	})
 }
 
@@ -69,6 +75,22 @@ func (w *responseObserver) WriteHeader(statusCode int) {
 	}
 }
 
+// Hijack supports proxying connections that switch protocols, such as WebSockets
+func (w *responseObserver) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	w.status = "1xx"
+	return hj.Hijack()
+}
+
+func (w *responseObserver) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (w *responseObserver) Write(b []byte) (int, error) {
 	bytesWritten, err := w.ResponseWriter.Write(b)
 
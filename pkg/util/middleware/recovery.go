@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+	oo "github.com/tricksterproxy/trickster/pkg/proxy/origins/options"
+	"github.com/tricksterproxy/trickster/pkg/proxy/request"
+	tl "github.com/tricksterproxy/trickster/pkg/util/log"
+	"github.com/tricksterproxy/trickster/pkg/util/metrics"
+)
+
+// Recover answers any request whose handler panics with a 500 and an error ID, and logs the
+// panic, its stack and the request's details under that ID. When the origin quarantines
+// panicking cache keys, the request's cache object is removed, so that a malformed cached
+// object can not repeatedly fail subsequent requests
+func Recover(o *oo.Options, pathName string, log *tl.Logger, next http.Handler) http.Handler {
+	if o == nil || o.PanicRecoveryDisabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// an aborted handler is the server's own signal to drop the connection
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			id := newErrorID()
+			pairs := tl.Pairs{}
+			quarantined := "false"
+			if rsc := request.GetResources(r); rsc != nil {
+				for k, cv := range rsc.Canonical {
+					pairs[k] = cv
+				}
+				if key, ok := rsc.Canonical["cacheKey"].(string); ok && key != "" &&
+					o.QuarantinePanicCacheKeys && rsc.CacheClient != nil {
+					rsc.CacheClient.Remove(key)
+					quarantined = "true"
+				}
+			}
+			pairs["errorID"] = id
+			pairs["originName"] = o.Name
+			pairs["method"] = r.Method
+			pairs["uri"] = r.URL.RequestURI()
+			pairs["quarantined"] = quarantined
+			pairs["panic"] = fmt.Sprint(v)
+			pairs["stack"] = string(debug.Stack())
+			log.Error("recovered from panic while handling request", pairs)
+			metrics.FrontendPanics.WithLabelValues(o.Name, o.OriginType, pathName, quarantined).Inc()
+			w.Header().Set(headers.NameTricksterErrorID, id)
+			http.Error(w, "internal server error (error id "+id+")", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newErrorID returns a random identifier that correlates an error response with its log event
+func newErrorID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	sh "github.com/tricksterproxy/trickster/pkg/proxy/headers/security/options"
+)
+
+// SecurityHeaders applies the provided security headers profile to the response
+// just before its headers are written. When the handler is nested inside of another
+// SecurityHeaders handler, the inner (more specific) profile is used instead
+func SecurityHeaders(o *sh.Options, next http.Handler) http.Handler {
+	if o == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sw, ok := w.(*securityHeadersWriter); ok {
+			sw.options = o
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&securityHeadersWriter{ResponseWriter: w, options: o}, r)
+	})
+}
+
+type securityHeadersWriter struct {
+	http.ResponseWriter
+
+	options     *sh.Options
+	wroteHeader bool
+}
+
+func (w *securityHeadersWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.options.Apply(w.ResponseWriter.Header())
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *securityHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack supports proxying connections that switch protocols, such as WebSockets
+func (w *securityHeadersWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	return hj.Hijack()
+}
+
+func (w *securityHeadersWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/proxy/context"
+	"github.com/tricksterproxy/trickster/pkg/proxy/headers"
+)
+
+// TrustedProxies applies the Forwarded Headers Policy to inbound requests before they are
+// routed, and attaches the resolved originating client address to the request context
+func TrustedProxies(policy string, tp headers.TrustedProxies, next http.Handler) http.Handler {
+	if policy == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := headers.ApplyForwardedPolicy(r, policy, tp)
+		next.ServeHTTP(w, r.WithContext(context.WithClientAddress(r.Context(), client)))
+	})
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tricksterproxy/trickster/pkg/cache/warming"
+)
+
+// Warming records each client GET request with the provided Warmer, toward the cache's
+// most requested queries, before passing it to the next handler
+func Warming(w *warming.Warmer, originName string, next http.Handler) http.Handler {
+	if !w.Recording() {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && !warming.IsReplay(r.Context()) {
+			w.Record(originName, r.URL.RequestURI())
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
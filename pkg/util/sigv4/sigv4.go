@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sigv4 signs requests to AWS APIs with Signature Version 4
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the algorithm named in the Authorization header of signed requests
+	Algorithm = "AWS4-HMAC-SHA256"
+	// AmzDateFormat is the layout of the X-Amz-Date header
+	AmzDateFormat = "20060102T150405Z"
+	// HeaderAmzDate is the header carrying the signing time
+	HeaderAmzDate = "X-Amz-Date"
+	// HeaderAmzSHA256 is the header carrying the hex SHA-256 of the request body
+	HeaderAmzSHA256 = "X-Amz-Content-Sha256"
+	// HeaderAmzToken is the header carrying the session token of temporary credentials
+	HeaderAmzToken = "X-Amz-Security-Token"
+)
+
+// Credentials are the keys used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// NewCredentials returns the provided Credentials, or when accessKeyID is empty, those
+// found in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables
+func NewCredentials(accessKeyID, secretAccessKey, sessionToken string) Credentials {
+	if accessKeyID == "" {
+		return Credentials{AccessKeyID: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN")}
+	}
+	return Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey,
+		SessionToken: sessionToken}
+}
+
+// Sign adds the AWS Signature Version 4 Authorization header to the request for the named
+// service, signing the host and each of the request's headers. body is the request body,
+// which must not be changed after the request is signed
+func Sign(r *http.Request, body []byte, creds Credentials, region, service string,
+	now time.Time) {
+
+	now = now.UTC()
+	payloadHash := SHA256Hex(body)
+	r.Header.Set(HeaderAmzDate, now.Format(AmzDateFormat))
+	r.Header.Set(HeaderAmzSHA256, payloadHash)
+	if creds.SessionToken != "" {
+		r.Header.Set(HeaderAmzToken, creds.SessionToken)
+	}
+
+	names := make([]string, 0, len(r.Header)+1)
+	values := map[string]string{"host": r.URL.Host}
+	names = append(names, "host")
+	for k, v := range r.Header {
+		lk := strings.ToLower(k)
+		names = append(names, lk)
+		values[lk] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	sort.Strings(names)
+	var ch strings.Builder
+	for _, n := range names {
+		ch.WriteString(n + ":" + values[n] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(r),
+		ch.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := Algorithm + "\n" + now.Format(AmzDateFormat) + "\n" + scope + "\n" +
+		SHA256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery returns the query string with its parameters sorted and escaped as required
+// by Signature Version 4
+func canonicalQuery(r *http.Request) string {
+	qp := r.URL.Query()
+	pairs := make([]string, 0, len(qp))
+	for k, vals := range qp {
+		for _, v := range vals {
+			pairs = append(pairs, URIEncode(k, true)+"="+URIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// URIEncode escapes each byte of s other than the unreserved characters. '/' is escaped
+// only when encodeSlash is true
+func URIEncode(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexChars[c>>4])
+		b.WriteByte(hexChars[c&15])
+	}
+	return b.String()
+}
+
+// SHA256Hex returns the hex-encoded SHA-256 digest of b
+func SHA256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
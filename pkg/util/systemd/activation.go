@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidEnvironment indicates that a systemd environment variable could not be parsed
+var ErrInvalidEnvironment = errors.New("invalid systemd environment")
+
+// listenFDsStart is the first file descriptor passed by socket activation
+var listenFDsStart = 3
+
+// Listeners returns the listening sockets passed to this process by systemd socket activation,
+// keyed by the FileDescriptorName of each socket. It returns nil when no sockets are passed.
+// The activation environment is cleared, so that the sockets are not inherited by child processes
+func Listeners() (map[string][]net.Listener, error) {
+	pid := os.Getenv("LISTEN_PID")
+	if pid == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return nil, ErrInvalidEnvironment
+	}
+	var names []string
+	if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+		names = strings.Split(s, ":")
+	}
+
+	out := make(map[string][]net.Listener)
+	for i := 0; i < n; i++ {
+		name := "unknown"
+		if i < len(names) {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		// FileListener duplicates the descriptor, so the original is closed once it is wrapped
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ls := range out {
+				for _, l := range ls {
+					l.Close()
+				}
+			}
+			return nil, err
+		}
+		out[name] = append(out[name], l)
+	}
+	return out, nil
+}
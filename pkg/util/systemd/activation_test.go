@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+
+	os.Unsetenv("LISTEN_PID")
+	ls, err := Listeners()
+	if ls != nil || err != nil {
+		t.Errorf("expected nil listeners and error, got %v %v", ls, err)
+	}
+
+	// sockets passed to another process are ignored
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	ls, err = Listeners()
+	if ls != nil || err != nil {
+		t.Errorf("expected nil listeners and error, got %v %v", ls, err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fdStart := listenFDsStart
+	listenFDsStart = int(f.Fd())
+	defer func() { listenFDsStart = fdStart }()
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "http")
+	ls, err = Listeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls["http"]) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(ls["http"]))
+	}
+	defer ls["http"][0].Close()
+	if ls["http"][0].Addr().String() != l.Addr().String() {
+		t.Errorf("expected %s got %s", l.Addr().String(), ls["http"][0].Addr().String())
+	}
+
+	// the environment is cleared once the sockets are consumed
+	if os.Getenv("LISTEN_PID") != "" || os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected activation environment to be cleared")
+	}
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "x")
+	if _, err = Listeners(); err != ErrInvalidEnvironment {
+		t.Errorf("expected %v got %v", ErrInvalidEnvironment, err)
+	}
+
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package systemd integrates Trickster with the systemd service manager, using its socket
+// activation and sd_notify protocols. Each is a no-op when Trickster is not run by systemd.
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notification states sent to the service manager
+const (
+	// Ready indicates that startup or a config reload has completed
+	Ready = "READY=1"
+	// Reloading indicates that a config reload has started
+	Reloading = "RELOADING=1"
+	// Watchdog is the keep-alive sent within each watchdog interval
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends the provided state to the service manager's notification socket. It returns
+// false without error when no notification socket is provided, such as when the service is
+// not run by systemd or its Type is not 'notify'
+func Notify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return false, nil
+	}
+	// a leading @ indicates a socket in the abstract namespace
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval within which the service manager expects a Watchdog
+// notification, or 0 if the watchdog is not enabled for this process
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, ErrInvalidEnvironment
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// RunWatchdog sends a Watchdog notification at half of the provided interval until quit is closed
+func RunWatchdog(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			Notify(Watchdog)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+
+	os.Unsetenv("NOTIFY_SOCKET")
+	ok, err := Notify(Ready)
+	if ok || err != nil {
+		t.Errorf("expected false and nil error, got %t %v", ok, err)
+	}
+
+	dir, err := ioutil.TempDir("/tmp", "trickster-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	ok, err = Notify(Ready)
+	if !ok || err != nil {
+		t.Errorf("expected true and nil error, got %t %v", ok, err)
+	}
+
+	b := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != Ready {
+		t.Errorf("expected %s got %s", Ready, string(b[:n]))
+	}
+
+	os.Setenv("NOTIFY_SOCKET", filepath.Join(dir, "missing.sock"))
+	if _, err = Notify(Ready); err == nil {
+		t.Error("expected error for missing socket")
+	}
+
+}
+
+func TestWatchdogInterval(t *testing.T) {
+
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	if d, err := WatchdogInterval(); d != 0 || err != nil {
+		t.Errorf("expected 0 and nil error, got %s %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	if d, err := WatchdogInterval(); d != 30*time.Second || err != nil {
+		t.Errorf("expected %s and nil error, got %s %v", 30*time.Second, d, err)
+	}
+
+	// the watchdog is for another process
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d, err := WatchdogInterval(); d != 0 || err != nil {
+		t.Errorf("expected 0 and nil error, got %s %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("WATCHDOG_USEC", "x")
+	if _, err := WatchdogInterval(); err != ErrInvalidEnvironment {
+		t.Errorf("expected %v got %v", ErrInvalidEnvironment, err)
+	}
+
+}